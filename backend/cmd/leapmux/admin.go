@@ -62,6 +62,8 @@ var adminTree = adminGroup{
 				{Name: "grant-admin", Summary: "Grant admin privileges", Run: runUserGrantAdmin},
 				{Name: "revoke-admin", Summary: "Revoke admin privileges", Run: runUserRevokeAdmin},
 				{Name: "list-sessions", Summary: "List a user's active sessions", Run: runUserListSessions},
+				{Name: "export", Summary: "Export all data held about a user as JSON", Run: runUserExport},
+				{Name: "anonymize", Summary: "Scrub a user's personal data and delete the user", Run: runUserAnonymize},
 			},
 		},
 		{
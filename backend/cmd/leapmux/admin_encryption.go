@@ -75,8 +75,8 @@ func runRemoveEncryptionKey(cmd adminCmdCtx, args []string) error {
 
 // countEncryptedRefs returns human-readable descriptions of data still
 // encrypted under the given key version. An empty result means the version is
-// safe to remove. It scans the persistent OAuth secrets — provider client
-// secrets and user OAuth tokens. Transient pending_oauth_signups are not
+// safe to remove. It scans the persistent secrets — OAuth provider client
+// secrets, user OAuth tokens and org git credentials. Transient pending_oauth_signups are not
 // scanned: they auto-expire, are never migrated by reencrypt, and a bricked
 // one only fails an in-flight signup the user can simply retry.
 func countEncryptedRefs(ctx context.Context, st store.Store, version uint32) ([]string, error) {
@@ -104,6 +104,34 @@ func countEncryptedRefs(ctx context.Context, st store.Store, version uint32) ([]
 		refs = append(refs, fmt.Sprintf("%d OAuth provider secret(s)", providerCount))
 	}
 
+	creds, err := st.GitCredentials().ListAll(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("list git credentials: %w", err)
+	}
+	var credCount int
+	for _, c := range creds {
+		if ver, e := keystore.CiphertextVersion(c.Secret); e == nil && ver == version {
+			credCount++
+		}
+	}
+	if credCount > 0 {
+		refs = append(refs, fmt.Sprintf("%d git credential(s)", credCount))
+	}
+
+	modelCreds, err := st.ModelCredentials().ListAll(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("list model credentials: %w", err)
+	}
+	var modelCredCount int
+	for _, c := range modelCreds {
+		if ver, e := keystore.CiphertextVersion(c.Secret); e == nil && ver == version {
+			modelCredCount++
+		}
+	}
+	if modelCredCount > 0 {
+		refs = append(refs, fmt.Sprintf("%d model credential(s)", modelCredCount))
+	}
+
 	return refs, nil
 }
 
@@ -169,6 +197,54 @@ func runReencryptSecrets(cmd adminCmdCtx, args []string) error {
 			count++
 		}
 
+		// Re-encrypt git_credentials.secret.
+		creds, err := st.GitCredentials().ListAll(ctx)
+		if err != nil {
+			return fmt.Errorf("list git credentials: %w", err)
+		}
+		for _, c := range creds {
+			if ver, err := keystore.CiphertextVersion(c.Secret); err == nil && ver == activeVer {
+				continue
+			}
+			aad := keystore.GitCredentialAAD(c.ID)
+			plain, decErr := ks.Decrypt(c.Secret, aad)
+			if decErr != nil {
+				return fmt.Errorf("decrypt git credential %s: %w", c.ID, decErr)
+			}
+			newCt, encErr := ks.Encrypt(plain, aad)
+			if encErr != nil {
+				return fmt.Errorf("re-encrypt git credential %s: %w", c.ID, encErr)
+			}
+			if err := st.GitCredentials().UpdateSecret(ctx, c.ID, newCt); err != nil {
+				return fmt.Errorf("update git credential %s: %w", c.ID, err)
+			}
+			count++
+		}
+
+		// Re-encrypt model_credentials.secret.
+		modelCreds, err := st.ModelCredentials().ListAll(ctx)
+		if err != nil {
+			return fmt.Errorf("list model credentials: %w", err)
+		}
+		for _, c := range modelCreds {
+			if ver, err := keystore.CiphertextVersion(c.Secret); err == nil && ver == activeVer {
+				continue
+			}
+			aad := keystore.ModelCredentialAAD(c.ID)
+			plain, decErr := ks.Decrypt(c.Secret, aad)
+			if decErr != nil {
+				return fmt.Errorf("decrypt model credential %s: %w", c.ID, decErr)
+			}
+			newCt, encErr := ks.Encrypt(plain, aad)
+			if encErr != nil {
+				return fmt.Errorf("re-encrypt model credential %s: %w", c.ID, encErr)
+			}
+			if err := st.ModelCredentials().UpdateSecret(ctx, c.ID, newCt); err != nil {
+				return fmt.Errorf("update model credential %s: %w", c.ID, err)
+			}
+			count++
+		}
+
 		// Re-encrypt oauth_tokens.
 		for _, ver := range ks.Versions() {
 			if ver == activeVer {
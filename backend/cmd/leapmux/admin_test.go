@@ -3,8 +3,10 @@ package main
 import (
 	"context"
 	"database/sql"
+	"encoding/json"
 	"fmt"
 	"path/filepath"
+	"strings"
 	"testing"
 	"time"
 
@@ -448,6 +450,92 @@ func TestCLI_RemoveEncryptionKey_RefusesWhenTokenReferenced(t *testing.T) {
 	assert.Contains(t, err.Error(), "OAuth token")
 }
 
+func TestCLI_ReencryptSecrets_GitCredentials(t *testing.T) {
+	dir := setupTestDataDir(t)
+	ctx := context.Background()
+	keyPath := filepath.Join(dir, "encryption.key")
+
+	// Seed a git credential encrypted under key version 1.
+	ks, err := keystore.LoadFromFile(keyPath)
+	require.NoError(t, err)
+	st, err := storeopen.Open(ctx, adminConfig(dir))
+	require.NoError(t, err)
+	orgID := storetest.SeedOrg(t, st, "org")
+	secret, err := ks.Encrypt([]byte("ghp_token"), keystore.GitCredentialAAD("cred-1"))
+	require.NoError(t, err)
+	_, err = st.GitCredentials().Create(ctx, store.CreateGitCredentialParams{
+		ID:     "cred-1",
+		OrgID:  orgID,
+		Name:   "github",
+		Kind:   leapmuxv1.GitCredentialKind_GIT_CREDENTIAL_KIND_HTTPS_TOKEN,
+		Secret: secret,
+	})
+	require.NoError(t, err)
+	require.NoError(t, st.Close())
+
+	require.NoError(t, runRotateEncryptionKey(testAdminCtx, []string{"--data-dir", dir}))
+	err = runRemoveEncryptionKey(testAdminCtx, []string{"--version", "1", "--data-dir", dir})
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "git credential")
+
+	require.NoError(t, runReencryptSecrets(testAdminCtx, []string{"--data-dir", dir}))
+	require.NoError(t, runRemoveEncryptionKey(testAdminCtx, []string{"--version", "1", "--data-dir", dir}))
+
+	ks, err = keystore.LoadFromFile(keyPath)
+	require.NoError(t, err)
+	st, err = storeopen.Open(ctx, adminConfig(dir))
+	require.NoError(t, err)
+	defer func() { _ = st.Close() }()
+	cred, err := st.GitCredentials().Get(ctx, store.GetGitCredentialParams{ID: "cred-1", OrgID: orgID})
+	require.NoError(t, err)
+	plain, err := ks.Decrypt(cred.Secret, keystore.GitCredentialAAD("cred-1"))
+	require.NoError(t, err)
+	assert.Equal(t, "ghp_token", string(plain))
+}
+
+func TestCLI_ReencryptSecrets_ModelCredentials(t *testing.T) {
+	dir := setupTestDataDir(t)
+	ctx := context.Background()
+	keyPath := filepath.Join(dir, "encryption.key")
+
+	// Seed a model credential encrypted under key version 1.
+	ks, err := keystore.LoadFromFile(keyPath)
+	require.NoError(t, err)
+	st, err := storeopen.Open(ctx, adminConfig(dir))
+	require.NoError(t, err)
+	orgID := storetest.SeedOrg(t, st, "org")
+	secret, err := ks.Encrypt([]byte("sk-ant-key"), keystore.ModelCredentialAAD("cred-1"))
+	require.NoError(t, err)
+	_, err = st.ModelCredentials().Create(ctx, store.CreateModelCredentialParams{
+		ID:     "cred-1",
+		OrgID:  orgID,
+		Name:   "team-a",
+		Kind:   leapmuxv1.ModelCredentialKind_MODEL_CREDENTIAL_KIND_ANTHROPIC_API_KEY,
+		Secret: secret,
+	})
+	require.NoError(t, err)
+	require.NoError(t, st.Close())
+
+	require.NoError(t, runRotateEncryptionKey(testAdminCtx, []string{"--data-dir", dir}))
+	err = runRemoveEncryptionKey(testAdminCtx, []string{"--version", "1", "--data-dir", dir})
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "model credential")
+
+	require.NoError(t, runReencryptSecrets(testAdminCtx, []string{"--data-dir", dir}))
+	require.NoError(t, runRemoveEncryptionKey(testAdminCtx, []string{"--version", "1", "--data-dir", dir}))
+
+	ks, err = keystore.LoadFromFile(keyPath)
+	require.NoError(t, err)
+	st, err = storeopen.Open(ctx, adminConfig(dir))
+	require.NoError(t, err)
+	defer func() { _ = st.Close() }()
+	cred, err := st.ModelCredentials().Get(ctx, store.GetModelCredentialParams{ID: "cred-1", OrgID: orgID})
+	require.NoError(t, err)
+	plain, err := ks.Decrypt(cred.Secret, keystore.ModelCredentialAAD("cred-1"))
+	require.NoError(t, err)
+	assert.Equal(t, "sk-ant-key", string(plain))
+}
+
 func TestCLI_RotatePepper_RequiresYes(t *testing.T) {
 	dir := setupTestDataDir(t)
 
@@ -1118,6 +1206,108 @@ func TestCLI_UserDelete_WorkspaceSoftDeleted(t *testing.T) {
 	assert.True(t, deletedAt.Valid, "workspace should have non-null deleted_at")
 }
 
+// seedUserData gives user one row in each table `user export` reads and
+// `user anonymize` scrubs, and returns the open store.
+func seedUserData(t *testing.T, dir string, user gendb.User) store.Store {
+	t.Helper()
+	ctx := context.Background()
+	st, err := storeopen.Open(ctx, adminConfig(dir))
+	require.NoError(t, err)
+	t.Cleanup(func() { _ = st.Close() })
+
+	uid := userid.MustNew(user.ID)
+	storetest.SeedWorkspace(t, st, user.OrgID, user.ID, "alice's workspace")
+	storetest.SeedSession(t, st, user.ID)
+	storetest.SeedWorker(t, st, user.ID)
+	_, err = st.Snippets().Create(ctx, store.CreateSnippetParams{
+		ID: id.Generate(), UserID: uid, Name: "greet", Body: "hello",
+	})
+	require.NoError(t, err)
+	prov := storetest.SeedOAuthProvider(t, st, "idp")
+	require.NoError(t, st.OAuthUserLinks().Create(ctx, store.CreateOAuthUserLinkParams{
+		UserID: uid, ProviderID: prov.ID, ProviderSubject: "alice@idp",
+	}))
+	return st
+}
+
+func TestCLI_UserExport_CollectsUserData(t *testing.T) {
+	dir := setupTestDataDir(t)
+	require.NoError(t, runUserCreate(testAdminCtx, []string{
+		"--username", "alice", "--password", "TestPassword1!",
+		"--email", "alice@example.com", "--data-dir", dir,
+	}))
+	_, q := openTestDB(t, dir)
+	alice, err := q.GetUserByUsername(context.Background(), "alice")
+	require.NoError(t, err)
+	st := seedUserData(t, dir, alice)
+
+	user, err := resolveUser(context.Background(), st, "", "alice")
+	require.NoError(t, err)
+	bundle, err := exportUserData(context.Background(), st, user, userid.MustNew(user.ID))
+	require.NoError(t, err)
+
+	assert.Equal(t, "alice", bundle.User.Username)
+	assert.Equal(t, "alice@example.com", bundle.User.Email)
+	assert.Len(t, bundle.Workspaces, 1)
+	assert.Len(t, bundle.Sessions, 1)
+	assert.Len(t, bundle.Workers, 1)
+	assert.Len(t, bundle.Snippets, 1)
+	require.Len(t, bundle.OAuthLinks, 1)
+	assert.Equal(t, "alice@idp", bundle.OAuthLinks[0].ProviderSubject)
+
+	// No secret may ride along in the bundle.
+	data, err := json.Marshal(bundle)
+	require.NoError(t, err)
+	assert.NotContains(t, string(data), user.PasswordHash)
+}
+
+func TestCLI_UserAnonymize_ScrubsAndDeletes(t *testing.T) {
+	dir := setupTestDataDir(t)
+	require.NoError(t, runUserCreate(testAdminCtx, []string{
+		"--username", "alice", "--password", "TestPassword1!",
+		"--display-name", "Alice Liddell", "--email", "alice@example.com", "--data-dir", dir,
+	}))
+	_, q := openTestDB(t, dir)
+	alice, err := q.GetUserByUsername(context.Background(), "alice")
+	require.NoError(t, err)
+	st := seedUserData(t, dir, alice)
+
+	require.NoError(t, runUserAnonymize(testAdminCtx, []string{"--username", "alice", "--data-dir", dir}))
+
+	_, q = openTestDB(t, dir)
+	row, err := q.GetUserByIDIncludeDeleted(context.Background(), alice.ID)
+	require.NoError(t, err)
+	assert.True(t, row.DeletedAt.Valid, "user should be soft-deleted")
+	assert.True(t, strings.HasPrefix(row.Username, "deleted-"), "username %q should be a tombstone", row.Username)
+	assert.Equal(t, anonymizedDisplayName, row.DisplayName)
+	assert.Empty(t, row.Email)
+
+	uid := userid.MustNew(alice.ID)
+	snippets, err := st.Snippets().ListByUser(context.Background(), uid)
+	require.NoError(t, err)
+	assert.Empty(t, snippets)
+	links, err := st.OAuthUserLinks().ListByUser(context.Background(), uid)
+	require.NoError(t, err)
+	assert.Empty(t, links)
+	assert.Empty(t, listSessions(t, q, alice.ID))
+
+	// The old username is free again.
+	createTestUser(t, dir, "alice")
+}
+
+func TestCLI_UserAnonymize_AdminRequiresForce(t *testing.T) {
+	dir := setupTestDataDir(t)
+	require.NoError(t, runUserCreate(testAdminCtx, []string{
+		"--username", "root1", "--password", "TestPassword1!", "--admin", "--data-dir", dir,
+	}))
+
+	err := runUserAnonymize(testAdminCtx, []string{"--username", "root1", "--data-dir", dir})
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "pass --force")
+
+	require.NoError(t, runUserAnonymize(testAdminCtx, []string{"--username", "root1", "--force", "--data-dir", dir}))
+}
+
 func TestCLI_UserResetPassword_MissingPassword(t *testing.T) {
 	dir := setupTestDataDir(t)
 	user := createTestUser(t, dir, "alice")
@@ -1722,3 +1912,70 @@ func TestCLI_DBPath(t *testing.T) {
 	err := runDBPath(testAdminCtx, []string{"--data-dir", dir})
 	require.NoError(t, err)
 }
+
+func TestCLI_UserExportAndAnonymize_PromptsGuestLinksAndLayouts(t *testing.T) {
+	dir := setupTestDataDir(t)
+	ctx := context.Background()
+	user := createTestUser(t, dir, "alice")
+	uid := userid.MustNew(user.ID)
+
+	st, err := storeopen.Open(ctx, adminConfig(dir))
+	require.NoError(t, err)
+	wsID := storetest.SeedWorkspace(t, st, user.OrgID, user.ID, "WS")
+	prompt, err := st.SystemPrompts().Create(ctx, store.CreateSystemPromptParams{
+		ID:          id.Generate(),
+		OrgID:       user.OrgID,
+		OwnerUserID: uid,
+		Name:        "reviewer",
+		Shared:      true,
+		Content:     "You are a reviewer.",
+	})
+	require.NoError(t, err)
+	invitationID := id.Generate()
+	require.NoError(t, st.GuestInvitations().Create(ctx, store.CreateGuestInvitationParams{
+		ID:          invitationID,
+		UserID:      uid,
+		WorkspaceID: wsID,
+		Role:        leapmuxv1.GuestRole_GUEST_ROLE_VIEWER,
+		Label:       "pairing",
+		SecretHash:  []byte("secret"),
+		ExpiresAt:   time.Now().Add(time.Hour),
+	}))
+	_, err = st.WorkspaceLayoutPresets().Upsert(ctx, store.UpsertWorkspaceLayoutPresetParams{
+		ID:          id.Generate(),
+		UserID:      uid,
+		WorkspaceID: wsID,
+		Name:        "review",
+		Root:        []byte{},
+	})
+	require.NoError(t, err)
+
+	u, err := st.Users().GetByID(ctx, user.ID)
+	require.NoError(t, err)
+	bundle, err := exportUserData(ctx, st, u, uid)
+	require.NoError(t, err)
+	require.Len(t, bundle.SystemPrompts, 1)
+	assert.Equal(t, prompt.ID, bundle.SystemPrompts[0].ID)
+	require.Len(t, bundle.SystemPrompts[0].Versions, 1)
+	assert.Equal(t, "You are a reviewer.", bundle.SystemPrompts[0].Versions[0].Content)
+	require.Len(t, bundle.GuestInvitations, 1)
+	assert.Equal(t, "GUEST_ROLE_VIEWER", bundle.GuestInvitations[0].Role)
+	require.Len(t, bundle.LayoutPresets, 1)
+	assert.Equal(t, "review", bundle.LayoutPresets[0].Name)
+	require.NoError(t, st.Close())
+
+	require.NoError(t, runUserAnonymize(testAdminCtx, []string{"--id", user.ID, "--data-dir", dir}))
+
+	st, err = storeopen.Open(ctx, adminConfig(dir))
+	require.NoError(t, err)
+	defer func() { _ = st.Close() }()
+	prompts, err := st.SystemPrompts().ListByOwner(ctx, uid)
+	require.NoError(t, err)
+	assert.Empty(t, prompts)
+	invitation, err := st.GuestInvitations().GetByID(ctx, invitationID)
+	require.NoError(t, err)
+	assert.NotNil(t, invitation.RevokedAt)
+	presets, err := st.WorkspaceLayoutPresets().ListByUser(ctx, uid)
+	require.NoError(t, err)
+	assert.Empty(t, presets)
+}
@@ -13,6 +13,7 @@ import (
 	"github.com/leapmux/leapmux/internal/hub/store"
 	"github.com/leapmux/leapmux/internal/hub/usernames"
 	"github.com/leapmux/leapmux/internal/util/timefmt"
+	"github.com/leapmux/leapmux/internal/util/userid"
 	"github.com/leapmux/leapmux/util/validate"
 )
 
@@ -279,31 +280,7 @@ func runUserDelete(cmd adminCmdCtx, args []string) error {
 		}
 
 		err = st.RunInUserAuthTransaction(ctx, delUID, func(tx store.Store) error {
-			if err := tx.Workers().MarkAllDeletedByUser(ctx, delUID); err != nil {
-				return fmt.Errorf("mark workers deleted: %w", err)
-			}
-			if err := tx.Workspaces().SoftDeleteAllByUser(ctx, delUID); err != nil {
-				return fmt.Errorf("soft-delete workspaces: %w", err)
-			}
-			if err := tx.Sessions().DeleteByUser(ctx, delUID); err != nil {
-				return fmt.Errorf("delete sessions: %w", err)
-			}
-			// User deletion implies every credential the user had —
-			// CLI api tokens, agent delegation tokens, browser
-			// sessions — must die. The store records durable
-			// revocation events in this transaction, so the hub's
-			// in-memory bearer cache and any open channels (cookie
-			// or bearer) are torn down on the watcher's next sweep —
-			// no IPC from this admin CLI required.
-			if _, _, err := auth.RevokeAllUserCredentials(ctx, tx, delUID); err != nil {
-				return err
-			}
-			// Users().Delete soft-deletes the personal org too, so the org name is
-			// freed for a future re-signup without a separate, easy-to-forget call.
-			if err := tx.Users().Delete(ctx, user.ID); err != nil {
-				return fmt.Errorf("delete user: %w", err)
-			}
-			return nil
+			return deleteUserTx(ctx, tx, user, delUID)
 		})
 		if err != nil {
 			return err
@@ -314,6 +291,36 @@ func runUserDelete(cmd adminCmdCtx, args []string) error {
 	})
 }
 
+// deleteUserTx is the body of `user delete`, shared with `user anonymize`.
+// It must run inside RunInUserAuthTransaction for uid.
+func deleteUserTx(ctx context.Context, tx store.Store, user *store.User, uid userid.UserID) error {
+	if err := tx.Workers().MarkAllDeletedByUser(ctx, uid); err != nil {
+		return fmt.Errorf("mark workers deleted: %w", err)
+	}
+	if err := tx.Workspaces().SoftDeleteAllByUser(ctx, uid); err != nil {
+		return fmt.Errorf("soft-delete workspaces: %w", err)
+	}
+	if err := tx.Sessions().DeleteByUser(ctx, uid); err != nil {
+		return fmt.Errorf("delete sessions: %w", err)
+	}
+	// User deletion implies every credential the user had —
+	// CLI api tokens, agent delegation tokens, browser
+	// sessions — must die. The store records durable
+	// revocation events in this transaction, so the hub's
+	// in-memory bearer cache and any open channels (cookie
+	// or bearer) are torn down on the watcher's next sweep —
+	// no IPC from this admin CLI required.
+	if _, _, err := auth.RevokeAllUserCredentials(ctx, tx, uid); err != nil {
+		return err
+	}
+	// Users().Delete soft-deletes the personal org too, so the org name is
+	// freed for a future re-signup without a separate, easy-to-forget call.
+	if err := tx.Users().Delete(ctx, user.ID); err != nil {
+		return fmt.Errorf("delete user: %w", err)
+	}
+	return nil
+}
+
 func runUserResetPassword(cmd adminCmdCtx, args []string) error {
 	var userID *string
 	var username *string
@@ -0,0 +1,522 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"strings"
+	"time"
+
+	leapmuxv1 "github.com/leapmux/leapmux/generated/proto/leapmux/v1"
+	"github.com/leapmux/leapmux/internal/hub/config"
+	"github.com/leapmux/leapmux/internal/hub/store"
+	"github.com/leapmux/leapmux/internal/util/id"
+	"github.com/leapmux/leapmux/internal/util/userid"
+	"google.golang.org/protobuf/encoding/protojson"
+	"google.golang.org/protobuf/proto"
+)
+
+// userExportVersion is bumped whenever a field of userExport changes meaning,
+// so a consumer can tell bundles from different Hub releases apart.
+const userExportVersion = 1
+
+// anonymizedDisplayName replaces an anonymized user's display name.
+const anonymizedDisplayName = "Deleted user"
+
+// userExport is the bundle `user export` prints: everything the Hub's
+// database holds about one user. Secrets (password hash, token hashes,
+// verification codes) are left out; they identify nobody and exporting them
+// would only widen their exposure. Agent and terminal content is not here:
+// it lives on the user's workers, end-to-end encrypted, where the Hub cannot
+// read it.
+type userExport struct {
+	Version          int                         `json:"version"`
+	ExportedAt       time.Time                   `json:"exported_at"`
+	User             userExportProfile           `json:"user"`
+	Preferences      json.RawMessage             `json:"preferences,omitempty"`
+	OAuthLinks       []userExportOAuthLink       `json:"oauth_links"`
+	Sessions         []userExportSession         `json:"sessions"`
+	Workspaces       []userExportWorkspace       `json:"workspaces"`
+	Workers          []userExportWorker          `json:"workers"`
+	APITokens        []userExportAPIToken        `json:"api_tokens"`
+	DelegationTokens []userExportDelegation      `json:"delegation_tokens"`
+	Snippets         []userExportSnippet         `json:"snippets"`
+	SystemPrompts    []userExportSystemPrompt    `json:"system_prompts"`
+	GuestInvitations []userExportGuestInvitation `json:"guest_invitations"`
+	LayoutPresets    []userExportLayoutPreset    `json:"layout_presets"`
+}
+
+type userExportProfile struct {
+	ID            string     `json:"id"`
+	OrgID         string     `json:"org_id"`
+	Username      string     `json:"username"`
+	DisplayName   string     `json:"display_name"`
+	Email         string     `json:"email"`
+	EmailVerified bool       `json:"email_verified"`
+	PendingEmail  string     `json:"pending_email,omitempty"`
+	PasswordSet   bool       `json:"password_set"`
+	IsAdmin       bool       `json:"is_admin"`
+	CreatedAt     time.Time  `json:"created_at"`
+	UpdatedAt     time.Time  `json:"updated_at"`
+	DeletedAt     *time.Time `json:"deleted_at,omitempty"`
+}
+
+type userExportOAuthLink struct {
+	ProviderID      string    `json:"provider_id"`
+	ProviderSubject string    `json:"provider_subject"`
+	CreatedAt       time.Time `json:"created_at"`
+}
+
+type userExportSession struct {
+	ID           string    `json:"id"`
+	CreatedAt    time.Time `json:"created_at"`
+	LastActiveAt time.Time `json:"last_active_at"`
+	ExpiresAt    time.Time `json:"expires_at"`
+	IPAddress    string    `json:"ip_address"`
+	UserAgent    string    `json:"user_agent"`
+}
+
+type userExportWorkspace struct {
+	ID        string    `json:"id"`
+	OrgID     string    `json:"org_id"`
+	Title     string    `json:"title"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+type userExportWorker struct {
+	ID         string     `json:"id"`
+	Status     string     `json:"status"`
+	CreatedAt  time.Time  `json:"created_at"`
+	LastSeenAt *time.Time `json:"last_seen_at,omitempty"`
+}
+
+type userExportAPIToken struct {
+	ID         string     `json:"id"`
+	ClientType string     `json:"client_type"`
+	ClientName string     `json:"client_name"`
+	Scope      string     `json:"scope"`
+	CreatedAt  time.Time  `json:"created_at"`
+	LastUsedAt *time.Time `json:"last_used_at,omitempty"`
+	ExpiresAt  *time.Time `json:"expires_at,omitempty"`
+	RevokedAt  *time.Time `json:"revoked_at,omitempty"`
+}
+
+type userExportDelegation struct {
+	ID          string     `json:"id"`
+	WorkerID    string     `json:"worker_id"`
+	WorkspaceID string     `json:"workspace_id"`
+	AgentID     string     `json:"agent_id,omitempty"`
+	TerminalID  string     `json:"terminal_id,omitempty"`
+	CreatedAt   time.Time  `json:"created_at"`
+	LastUsedAt  *time.Time `json:"last_used_at,omitempty"`
+	ExpiresAt   time.Time  `json:"expires_at"`
+}
+
+type userExportSnippet struct {
+	ID          string    `json:"id"`
+	Name        string    `json:"name"`
+	Description string    `json:"description"`
+	Body        string    `json:"body"`
+	CreatedAt   time.Time `json:"created_at"`
+	UpdatedAt   time.Time `json:"updated_at"`
+}
+
+type userExportSystemPrompt struct {
+	ID        string                          `json:"id"`
+	OrgID     string                          `json:"org_id"`
+	Name      string                          `json:"name"`
+	Shared    bool                            `json:"shared"`
+	Versions  []userExportSystemPromptVersion `json:"versions"`
+	CreatedAt time.Time                       `json:"created_at"`
+	UpdatedAt time.Time                       `json:"updated_at"`
+}
+
+type userExportSystemPromptVersion struct {
+	Version   int64     `json:"version"`
+	Content   string    `json:"content"`
+	CreatedBy string    `json:"created_by"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+type userExportGuestInvitation struct {
+	ID          string     `json:"id"`
+	WorkspaceID string     `json:"workspace_id"`
+	Role        string     `json:"role"`
+	Label       string     `json:"label"`
+	CreatedAt   time.Time  `json:"created_at"`
+	LastUsedAt  *time.Time `json:"last_used_at,omitempty"`
+	ExpiresAt   time.Time  `json:"expires_at"`
+	RevokedAt   *time.Time `json:"revoked_at,omitempty"`
+}
+
+type userExportLayoutPreset struct {
+	ID          string `json:"id"`
+	WorkspaceID string `json:"workspace_id"`
+	Name        string `json:"name"`
+	// Root is the preset's layout tree in its protojson form.
+	Root      json.RawMessage `json:"root,omitempty"`
+	CreatedAt time.Time       `json:"created_at"`
+	UpdatedAt time.Time       `json:"updated_at"`
+}
+
+func runUserExport(cmd adminCmdCtx, args []string) error {
+	var userID *string
+	var username *string
+	return withAdminStore(cmd, args, func(fs *flag.FlagSet) {
+		userID = fs.String("id", "", "user ID")
+		username = fs.String("username", "", "username")
+	}, func(ctx context.Context, _ *config.Config, st store.Store) error {
+		user, err := resolveUser(ctx, st, *userID, *username)
+		if err != nil {
+			return err
+		}
+		// Refuse a blank id rather than exporting every blank-owner row.
+		uid, err := mintResolvedUserID(user)
+		if err != nil {
+			return err
+		}
+		bundle, err := exportUserData(ctx, st, user, uid)
+		if err != nil {
+			return err
+		}
+		return printJSON(bundle)
+	})
+}
+
+// exportUserData collects the userExport for user.
+func exportUserData(ctx context.Context, st store.Store, user *store.User, uid userid.UserID) (*userExport, error) {
+	bundle := &userExport{
+		Version:    userExportVersion,
+		ExportedAt: time.Now().UTC(),
+		User: userExportProfile{
+			ID:            user.ID,
+			OrgID:         user.OrgID,
+			Username:      user.Username,
+			DisplayName:   user.DisplayName,
+			Email:         user.Email,
+			EmailVerified: user.EmailVerified,
+			PendingEmail:  user.PendingEmail,
+			PasswordSet:   user.PasswordSet,
+			IsAdmin:       user.IsAdmin,
+			CreatedAt:     user.CreatedAt,
+			UpdatedAt:     user.UpdatedAt,
+			DeletedAt:     user.DeletedAt,
+		},
+		OAuthLinks:       []userExportOAuthLink{},
+		Sessions:         []userExportSession{},
+		Workspaces:       []userExportWorkspace{},
+		Workers:          []userExportWorker{},
+		APITokens:        []userExportAPIToken{},
+		DelegationTokens: []userExportDelegation{},
+		Snippets:         []userExportSnippet{},
+		SystemPrompts:    []userExportSystemPrompt{},
+		GuestInvitations: []userExportGuestInvitation{},
+		LayoutPresets:    []userExportLayoutPreset{},
+	}
+
+	prefs, err := st.Users().GetPrefs(ctx, user.ID)
+	if err != nil {
+		return nil, fmt.Errorf("get preferences: %w", err)
+	}
+	if json.Valid([]byte(prefs)) {
+		bundle.Preferences = json.RawMessage(prefs)
+	}
+
+	links, err := st.OAuthUserLinks().ListByUser(ctx, uid)
+	if err != nil {
+		return nil, fmt.Errorf("list oauth links: %w", err)
+	}
+	for _, l := range links {
+		bundle.OAuthLinks = append(bundle.OAuthLinks, userExportOAuthLink{
+			ProviderID:      l.ProviderID,
+			ProviderSubject: l.ProviderSubject,
+			CreatedAt:       l.CreatedAt,
+		})
+	}
+
+	for cursor := ""; ; {
+		page, err := st.Sessions().ListByUserID(ctx, store.ListUserSessionsParams{
+			UserID:     uid,
+			PageParams: store.PageParams{Cursor: cursor, Limit: 100},
+		})
+		if err != nil {
+			return nil, fmt.Errorf("list sessions: %w", err)
+		}
+		for _, s := range page.Rows {
+			bundle.Sessions = append(bundle.Sessions, userExportSession{
+				ID:           s.ID,
+				CreatedAt:    s.CreatedAt,
+				LastActiveAt: s.LastActiveAt,
+				ExpiresAt:    s.ExpiresAt,
+				IPAddress:    s.IPAddress,
+				UserAgent:    s.UserAgent,
+			})
+		}
+		if !page.HasMore() {
+			break
+		}
+		cursor = page.NextCursor
+	}
+
+	workspaces, err := st.Workspaces().ListAccessible(ctx, store.ListAccessibleWorkspacesParams{
+		UserID: uid,
+		OrgID:  user.OrgID,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("list workspaces: %w", err)
+	}
+	for _, w := range workspaces {
+		bundle.Workspaces = append(bundle.Workspaces, userExportWorkspace{
+			ID:        w.ID,
+			OrgID:     w.OrgID,
+			Title:     w.Title,
+			CreatedAt: w.CreatedAt,
+		})
+	}
+
+	for cursor := ""; ; {
+		page, err := st.Workers().ListByUserID(ctx, store.ListWorkersByUserIDParams{
+			RegisteredBy: uid,
+			PageParams:   store.PageParams{Cursor: cursor, Limit: 100},
+		})
+		if err != nil {
+			return nil, fmt.Errorf("list workers: %w", err)
+		}
+		for _, w := range page.Rows {
+			bundle.Workers = append(bundle.Workers, userExportWorker{
+				ID:         w.ID,
+				Status:     w.Status.String(),
+				CreatedAt:  w.CreatedAt,
+				LastSeenAt: w.LastSeenAt,
+			})
+		}
+		if !page.HasMore() {
+			break
+		}
+		cursor = page.NextCursor
+	}
+
+	tokens, err := st.APITokens().ListByUser(ctx, store.ListAPITokensByUserParams{UserID: uid})
+	if err != nil {
+		return nil, fmt.Errorf("list api tokens: %w", err)
+	}
+	for _, t := range tokens {
+		bundle.APITokens = append(bundle.APITokens, userExportAPIToken{
+			ID:         t.ID,
+			ClientType: t.ClientType,
+			ClientName: t.ClientName,
+			Scope:      t.Scope,
+			CreatedAt:  t.CreatedAt,
+			LastUsedAt: t.LastUsedAt,
+			ExpiresAt:  t.ExpiresAt,
+			RevokedAt:  t.RevokedAt,
+		})
+	}
+
+	delegations, err := st.DelegationTokens().ListActiveByUser(ctx, uid)
+	if err != nil {
+		return nil, fmt.Errorf("list delegation tokens: %w", err)
+	}
+	for _, d := range delegations {
+		bundle.DelegationTokens = append(bundle.DelegationTokens, userExportDelegation{
+			ID:          d.ID,
+			WorkerID:    d.WorkerID,
+			WorkspaceID: d.WorkspaceID,
+			AgentID:     d.AgentID,
+			TerminalID:  d.TerminalID,
+			CreatedAt:   d.CreatedAt,
+			LastUsedAt:  d.LastUsedAt,
+			ExpiresAt:   d.ExpiresAt,
+		})
+	}
+
+	snippets, err := st.Snippets().ListByUser(ctx, uid)
+	if err != nil {
+		return nil, fmt.Errorf("list snippets: %w", err)
+	}
+	for _, s := range snippets {
+		bundle.Snippets = append(bundle.Snippets, userExportSnippet{
+			ID:          s.ID,
+			Name:        s.Name,
+			Description: s.Description,
+			Body:        s.Body,
+			CreatedAt:   s.CreatedAt,
+			UpdatedAt:   s.UpdatedAt,
+		})
+	}
+
+	prompts, err := st.SystemPrompts().ListByOwner(ctx, uid)
+	if err != nil {
+		return nil, fmt.Errorf("list system prompts: %w", err)
+	}
+	for _, p := range prompts {
+		versions, err := st.SystemPrompts().ListVersions(ctx, store.GetSystemPromptParams{ID: p.ID, OrgID: p.OrgID})
+		if err != nil {
+			return nil, fmt.Errorf("list system prompt versions: %w", err)
+		}
+		prompt := userExportSystemPrompt{
+			ID:        p.ID,
+			OrgID:     p.OrgID,
+			Name:      p.Name,
+			Shared:    p.Shared,
+			Versions:  []userExportSystemPromptVersion{},
+			CreatedAt: p.CreatedAt,
+			UpdatedAt: p.UpdatedAt,
+		}
+		for _, v := range versions {
+			prompt.Versions = append(prompt.Versions, userExportSystemPromptVersion{
+				Version:   v.Version,
+				Content:   v.Content,
+				CreatedBy: v.CreatedBy,
+				CreatedAt: v.CreatedAt,
+			})
+		}
+		bundle.SystemPrompts = append(bundle.SystemPrompts, prompt)
+	}
+
+	invitations, err := st.GuestInvitations().ListByUser(ctx, uid)
+	if err != nil {
+		return nil, fmt.Errorf("list guest invitations: %w", err)
+	}
+	for _, g := range invitations {
+		bundle.GuestInvitations = append(bundle.GuestInvitations, userExportGuestInvitation{
+			ID:          g.ID,
+			WorkspaceID: g.WorkspaceID,
+			Role:        g.Role.String(),
+			Label:       g.Label,
+			CreatedAt:   g.CreatedAt,
+			LastUsedAt:  g.LastUsedAt,
+			ExpiresAt:   g.ExpiresAt,
+			RevokedAt:   g.RevokedAt,
+		})
+	}
+
+	presets, err := st.WorkspaceLayoutPresets().ListByUser(ctx, uid)
+	if err != nil {
+		return nil, fmt.Errorf("list layout presets: %w", err)
+	}
+	for _, p := range presets {
+		preset := userExportLayoutPreset{
+			ID:          p.ID,
+			WorkspaceID: p.WorkspaceID,
+			Name:        p.Name,
+			CreatedAt:   p.CreatedAt,
+			UpdatedAt:   p.UpdatedAt,
+		}
+		// A tree that no longer decodes is left out rather than failing
+		// the whole export.
+		root := &leapmuxv1.LayoutPresetNode{}
+		if proto.Unmarshal(p.Root, root) == nil {
+			if b, err := protojson.Marshal(root); err == nil {
+				preset.Root = b
+			}
+		}
+		bundle.LayoutPresets = append(bundle.LayoutPresets, preset)
+	}
+
+	return bundle, nil
+}
+
+func runUserAnonymize(cmd adminCmdCtx, args []string) error {
+	var userID *string
+	var username *string
+	var force *bool
+	return withAdminStore(cmd, args, func(fs *flag.FlagSet) {
+		userID = fs.String("id", "", "user ID")
+		username = fs.String("username", "", "username")
+		force = fs.Bool("force", false, "required to anonymize an admin user")
+	}, func(ctx context.Context, _ *config.Config, st store.Store) error {
+		user, err := resolveUser(ctx, st, *userID, *username)
+		if err != nil {
+			return err
+		}
+
+		if user.IsAdmin && !*force {
+			return fmt.Errorf("user %q is an admin; pass --force to confirm anonymization", user.Username)
+		}
+
+		uid, err := mintResolvedUserID(user)
+		if err != nil {
+			return err
+		}
+
+		// A fresh random name rather than one derived from the user id, so
+		// the tombstone row cannot be matched back to the old username.
+		tombstone := "deleted-" + strings.ToLower(id.Generate()[:16])
+
+		err = st.RunInUserAuthTransaction(ctx, uid, func(tx store.Store) error {
+			// Scrub first: deleteUserTx soft-deletes, and the soft-deleted
+			// row lingers until the cleanup sweep hard-deletes it.
+			if err := tx.Users().UpdateProfile(ctx, store.UpdateUserProfileParams{
+				ID:          user.ID,
+				Username:    tombstone,
+				DisplayName: anonymizedDisplayName,
+			}); err != nil {
+				return fmt.Errorf("scrub profile: %w", err)
+			}
+			if err := tx.Users().UpdateEmail(ctx, store.UpdateUserEmailParams{ID: user.ID}); err != nil {
+				return fmt.Errorf("scrub email: %w", err)
+			}
+			if err := tx.Users().ClearPendingEmail(ctx, user.ID); err != nil {
+				return fmt.Errorf("clear pending email: %w", err)
+			}
+			if err := tx.Users().UpdatePrefs(ctx, store.UpdateUserPrefsParams{ID: user.ID, Prefs: "{}"}); err != nil {
+				return fmt.Errorf("scrub preferences: %w", err)
+			}
+			if err := tx.OAuthTokens().DeleteByUser(ctx, uid); err != nil {
+				return fmt.Errorf("delete oauth tokens: %w", err)
+			}
+			links, err := tx.OAuthUserLinks().ListByUser(ctx, uid)
+			if err != nil {
+				return fmt.Errorf("list oauth links: %w", err)
+			}
+			for _, l := range links {
+				if err := tx.OAuthUserLinks().Delete(ctx, store.DeleteOAuthUserLinkParams{UserID: uid, ProviderID: l.ProviderID}); err != nil {
+					return fmt.Errorf("delete oauth link: %w", err)
+				}
+			}
+			snippets, err := tx.Snippets().ListByUser(ctx, uid)
+			if err != nil {
+				return fmt.Errorf("list snippets: %w", err)
+			}
+			for _, s := range snippets {
+				if _, err := tx.Snippets().Delete(ctx, store.GetSnippetParams{ID: s.ID, UserID: uid}); err != nil {
+					return fmt.Errorf("delete snippet: %w", err)
+				}
+			}
+			// Shared prompts go too: their content is the user's.
+			prompts, err := tx.SystemPrompts().ListByOwner(ctx, uid)
+			if err != nil {
+				return fmt.Errorf("list system prompts: %w", err)
+			}
+			for _, p := range prompts {
+				if _, err := tx.SystemPrompts().Delete(ctx, store.GetSystemPromptParams{ID: p.ID, OrgID: p.OrgID}); err != nil {
+					return fmt.Errorf("delete system prompt: %w", err)
+				}
+			}
+			invitations, err := tx.GuestInvitations().ListByUser(ctx, uid)
+			if err != nil {
+				return fmt.Errorf("list guest invitations: %w", err)
+			}
+			for _, g := range invitations {
+				if g.RevokedAt != nil {
+					continue
+				}
+				if _, err := tx.GuestInvitations().Revoke(ctx, g.ID); err != nil {
+					return fmt.Errorf("revoke guest invitation: %w", err)
+				}
+			}
+			if err := tx.WorkspaceLayoutPresets().DeleteByUser(ctx, uid); err != nil {
+				return fmt.Errorf("delete layout presets: %w", err)
+			}
+			return deleteUserTx(ctx, tx, user, uid)
+		})
+		if err != nil {
+			return err
+		}
+
+		fmt.Printf("Anonymized and deleted user %q (id: %s) as %q\n", user.Username, user.ID, tombstone)
+		return nil
+	})
+}
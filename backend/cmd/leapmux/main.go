@@ -110,6 +110,11 @@ func runCLI(args []string, stdout, stderr io.Writer, runners cliRunners) int {
 			return handleRunError(stderr, err)
 		}
 		return 0
+	case simulateClaudeCommand:
+		if err := runSimulateClaude(args[1:]); err != nil {
+			return handleRunError(stderr, err)
+		}
+		return 0
 	case "version":
 		if len(args) > 1 && internalconfig.IsHelpArg(args[1]) {
 			printVersionUsage(stdout)
@@ -69,6 +69,7 @@ var remoteTree = adminGroup{
 			Commands: []adminCommand{
 				{Name: "list", Summary: "List accessible workers", Run: remoteRun(cmdremote.RunWorkerList)},
 				{Name: "get", Summary: "Show metadata for one worker", Run: remoteRun(cmdremote.RunWorkerGet)},
+				{Name: "exec", Summary: "Run a command on a worker (--path, then -- argv)", Run: remoteRun(cmdremote.RunWorkerExec)},
 			},
 			Subgroups: []adminGroup{
 				{
@@ -87,6 +88,7 @@ var remoteTree = adminGroup{
 			Summary: "Agent-specific operations (use `tab open/close/list/rename` for the generic surface)",
 			Commands: []adminCommand{
 				{Name: "send", Summary: "Send a user message to an agent", Run: remoteRun(cmdremote.RunAgentSend)},
+				{Name: "send-terminal", Summary: "Send a terminal's recent output to an agent", Run: remoteRun(cmdremote.RunAgentSendTerminal)},
 				{Name: "interrupt", Summary: "Abort an agent's current turn", Run: remoteRun(cmdremote.RunAgentInterrupt)},
 				{Name: "get", Summary: "Show one agent (settings, status, available models)", Run: remoteRun(cmdremote.RunAgentGet)},
 				{Name: "providers", Summary: "List available providers on the resolved worker", Run: remoteRun(cmdremote.RunAgentProviders)},
@@ -122,6 +124,7 @@ var remoteTree = adminGroup{
 			Commands: []adminCommand{
 				{Name: "list", Summary: "List a directory", Run: remoteRun(cmdremote.RunFileList)},
 				{Name: "read", Summary: "Read a file (with optional --offset/--limit)", Run: remoteRun(cmdremote.RunFileRead)},
+				{Name: "download", Summary: "Stream a whole file to stdout or --out", Run: remoteRun(cmdremote.RunFileDownload)},
 				{Name: "stat", Summary: "Stat a path", Run: remoteRun(cmdremote.RunFileStat)},
 			},
 		},
@@ -0,0 +1,53 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"syscall"
+
+	"github.com/leapmux/leapmux/internal/worker/claudesim"
+)
+
+// simulateClaudeCommand is the hidden subcommand the claude shim execs.
+// It is not listed in the usage text: only `leapmux worker --simulate`
+// is meant to reach it.
+const simulateClaudeCommand = "simulate-claude"
+
+// runSimulateClaude plays a simulated Claude Code session on stdin/stdout.
+// args are the CLI arguments the worker passed to "claude".
+func runSimulateClaude(args []string) error {
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+	return claudesim.Run(ctx, os.Stdin, os.Stdout, claudesim.Options{Args: args})
+}
+
+// installClaudeSimulator puts a "claude" shim that runs this binary's
+// simulate-claude subcommand at the front of PATH, so every Claude Code
+// agent the worker starts talks to the simulator. The shim is found
+// through PATH, so it needs the login shell disabled: a profile that
+// rebuilds PATH would put the real claude back in front.
+func installClaudeSimulator(dataDir string) error {
+	if runtime.GOOS == "windows" {
+		return errors.New("--simulate is not supported on Windows")
+	}
+	exe, err := os.Executable()
+	if err != nil {
+		return fmt.Errorf("locate leapmux binary: %w", err)
+	}
+	dir := filepath.Join(dataDir, "simulate-bin")
+	if err := os.MkdirAll(dir, 0o750); err != nil {
+		return fmt.Errorf("create simulator dir: %w", err)
+	}
+	quoted := "'" + strings.ReplaceAll(exe, "'", `'\''`) + "'"
+	script := fmt.Sprintf("#!/bin/sh\nexec %s %s \"$@\"\n", quoted, simulateClaudeCommand)
+	if err := os.WriteFile(filepath.Join(dir, "claude"), []byte(script), 0o755); err != nil {
+		return fmt.Errorf("write claude shim: %w", err)
+	}
+	return os.Setenv("PATH", dir+string(os.PathListSeparator)+os.Getenv("PATH"))
+}
@@ -31,7 +31,7 @@ func runSolo(args []string, soloMode bool) error {
 	configFile := configDir + "/" + modeName + ".yaml"
 
 	cliFlags := []string{
-		"listen", "data-dir", "dev-frontend",
+		"listen", "data-dir", "dev-frontend", "ui-dir",
 		"storage-sqlite-max-conns",
 		"api-timeout-seconds", "agent-startup-timeout-seconds", "worktree-create-timeout-seconds",
 		"log-level", "use-login-shell",
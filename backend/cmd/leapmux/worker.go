@@ -10,22 +10,30 @@ import (
 	"syscall"
 
 	"github.com/leapmux/leapmux/internal/logging"
+	"github.com/leapmux/leapmux/internal/plugin"
+	"github.com/leapmux/leapmux/internal/worker/agent"
+	"github.com/leapmux/leapmux/internal/worker/archive"
 	"github.com/leapmux/leapmux/internal/worker/bootstrap"
 	"github.com/leapmux/leapmux/internal/worker/config"
 	workerdb "github.com/leapmux/leapmux/internal/worker/db"
 	"github.com/leapmux/leapmux/internal/worker/hub"
+	"github.com/leapmux/leapmux/internal/worker/service"
+	"github.com/leapmux/leapmux/internal/worker/transcribe"
 	"github.com/leapmux/leapmux/internal/worker/wakelock"
 	"github.com/leapmux/leapmux/util/version"
 )
 
 func runWorker(args []string) error {
 	// Pre-dispatch admin-style subcommands so the daemon parser doesn't
-	// see flags meant for them. Today the only such subcommand is
-	// `cross-worker-pins`, which manages the worker-local TOFU pin store
-	// without spinning up a worker process.
+	// see flags meant for them: `cross-worker-pins` manages the
+	// worker-local TOFU pin store and `replay` re-runs a captured agent
+	// transcript, neither spinning up a worker process.
 	if len(args) > 0 && args[0] == "cross-worker-pins" {
 		return runWorkerCrossWorkerPins(args[1:])
 	}
+	if len(args) > 0 && args[0] == "replay" {
+		return runWorkerReplay(args[1:])
+	}
 	cfg, showVersion, err := config.Load(args)
 	if err != nil {
 		return err
@@ -48,6 +56,16 @@ func runWorker(args []string) error {
 		return fmt.Errorf("validate config: %w", err)
 	}
 
+	if cfg.Simulate {
+		if err := installClaudeSimulator(cfg.DataDir); err != nil {
+			return fmt.Errorf("simulate: %w", err)
+		}
+		// The simulator is found through PATH, which a login shell's
+		// profile may rebuild.
+		cfg.UseLoginShell = false
+		slog.Warn("simulating Claude Code: claude agents run scripted sessions, not the real CLI")
+	}
+
 	// Use a manually-cancelled context (rather than signal.NotifyContext)
 	// so SIGTERM/SIGINT can run svc.Shutdown() *before* the bidi stream
 	// is torn down. Otherwise the disconnect-notice broadcasts emitted by
@@ -135,7 +153,14 @@ func runWorker(args []string) error {
 		return fmt.Errorf("migrate worker db: %w", err)
 	}
 
-	client := hub.New(cfg.HubURL)
+	client := hub.New(cfg.HubURL, cfg.HubFallbackList()...)
+	client.Labels = cfg.LabelList()
+	client.Reconnect = hub.ReconnectPolicy{
+		MinInterval:   cfg.ReconnectMinInterval(),
+		MaxInterval:   cfg.ReconnectMaxInterval(),
+		Jitter:        cfg.ReconnectJitter(),
+		HealthTimeout: cfg.HubHealthTimeout(),
+	}
 	defer client.Stop()
 
 	homeDir, _ := os.UserHomeDir()
@@ -146,6 +171,23 @@ func runWorker(args []string) error {
 	// Set up E2EE channel manager with service handlers.
 	encMode := cfg.EncryptionModeProto()
 
+	// Validate already rejected unknown providers.
+	idleParkExcludedProviders, _ := cfg.IdleParkExcludedProviders()
+	transcriber, _ := transcribe.New(cfg.TranscriptionConfig())
+	archiveBucket, _ := archive.New(cfg.ArchiveConfig())
+	plugins, err := plugin.New(cfg.PluginDir, "worker")
+	if err != nil {
+		return fmt.Errorf("load plugins: %w", err)
+	}
+	redactor, err := cfg.Redactor()
+	if err != nil {
+		return err
+	}
+	classifier, err := cfg.Classifier()
+	if err != nil {
+		return err
+	}
+
 	// SeedRegisteredBy is deliberately not set: the Hub delivers the owner
 	// on connect (see Client.OnWorkerIdentity, wired by Wire) and is the
 	// only authority for it. Everything else this entry point needs --
@@ -169,6 +211,42 @@ func runWorker(args []string) error {
 		APITimeout:           cfg.APITimeout(),
 		UseLoginShell:        cfg.UseLoginShell,
 		WakeLock:             wakeLockTracker,
+		CaptureAgentOutput:   cfg.CaptureAgentOutput,
+		ClaudeOutputSchema:   agent.OutputSchemaMode(cfg.ClaudeOutputSchema),
+		PermissionGuardrails: service.PermissionGuardrails{
+			Forbidden: cfg.ForbiddenPermissionModeList(),
+			Default:   cfg.DefaultPermissionMode,
+		},
+		IdlePark: service.IdleParkPolicy{
+			After:              cfg.IdleParkAfter(),
+			ExcludedProviders:  idleParkExcludedProviders,
+			ExcludedWorkspaces: cfg.IdleParkExcludedWorkspaceList(),
+		},
+		ContextPressure: service.ContextPressurePolicy{
+			WarnPercent:     cfg.ContextWarnPercent,
+			CriticalPercent: cfg.ContextCriticalPercent,
+			CriticalAction:  service.ContextPressureAction(cfg.ContextCriticalAction),
+		},
+		Anomaly: service.AnomalyPolicy{
+			MaxToolCalls:        cfg.AnomalyMaxToolCalls,
+			MaxRepeatedCommands: cfg.AnomalyMaxRepeatedCommands,
+			MaxDeletedPaths:     cfg.AnomalyMaxDeletedPaths,
+			MaxTurnCostUSD:      cfg.AnomalyMaxTurnCostUSD,
+			WebhookURL:          cfg.AnomalyWebhookURL,
+		},
+		StuckTurn: service.StuckTurnPolicy{
+			After:  cfg.StuckTurnAfter(),
+			Action: service.StuckTurnAction(cfg.StuckTurnAction),
+		},
+		ClaudeSessionRetention: cfg.ClaudeSessionRetention(),
+		PersistTerminals:       cfg.PersistTerminals,
+		Plugins:                plugins,
+		Redactor:               redactor,
+		Classifier:             classifier,
+		ClassifiedRetention:    cfg.ClassifiedRetention(),
+		Archive:                archiveBucket,
+		ArchiveRetention:       cfg.ArchiveRetention(),
+		Transcriber:            transcriber,
 	})
 	svc := wiring.Service
 	// svc.Shutdown persists terminal screen snapshots and broadcasts the
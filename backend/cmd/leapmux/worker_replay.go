@@ -0,0 +1,52 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"os/signal"
+
+	"github.com/leapmux/leapmux/internal/worker/replay"
+)
+
+// runWorkerReplay implements `leapmux worker replay [--db <path>]
+// [--agent-id <id>] <transcript>`.
+//
+// The transcript is a file the worker wrote with --capture-agent-output.
+// It is fed through the same output handling a live agent's stdout goes
+// through, against a scratch database, and the persisted rows are printed
+// as JSON -- so a threading or notification bug seen in the UI can be
+// reproduced and bisected from the transcript alone.
+func runWorkerReplay(args []string) error {
+	fs := flag.NewFlagSet("leapmux worker replay", flag.ContinueOnError)
+	var dbPath, agentID string
+	fs.StringVar(&dbPath, "db", "", "scratch database to keep the replayed rows in (must not exist; default: in memory)")
+	fs.StringVar(&agentID, "agent-id", replay.DefaultAgentID, "agent id to store the rows under")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if fs.NArg() != 1 {
+		return workerReplayUsage(errors.New("exactly one transcript file is required"))
+	}
+
+	f, err := os.Open(fs.Arg(0))
+	if err != nil {
+		return err
+	}
+	defer func() { _ = f.Close() }()
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer stop()
+	rows, err := replay.Run(ctx, f, replay.Options{DBPath: dbPath, AgentID: agentID})
+	if err != nil {
+		return fmt.Errorf("replay: %w", err)
+	}
+	return printJSON(rows)
+}
+
+func workerReplayUsage(err error) error {
+	fmt.Fprintln(os.Stderr, "usage: leapmux worker replay [--db=<path>] [--agent-id=<id>] <transcript.ndjson>")
+	return err
+}
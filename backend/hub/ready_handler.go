@@ -0,0 +1,21 @@
+package hub
+
+import (
+	"net/http"
+	"sync/atomic"
+)
+
+// readyHandler serves the unauthenticated /readyz endpoint. It answers 200
+// while the hub accepts new work and 503 once shutdown has begun, so a load
+// balancer stops routing to a draining hub before its connections close.
+func readyHandler(draining *atomic.Bool) http.HandlerFunc {
+	return func(w http.ResponseWriter, _ *http.Request) {
+		w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+		if draining.Load() {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			_, _ = w.Write([]byte("draining\n"))
+			return
+		}
+		_, _ = w.Write([]byte("ok\n"))
+	}
+}
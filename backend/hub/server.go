@@ -9,16 +9,19 @@ import (
 	"log/slog"
 	"net"
 	"net/http"
+	"sync/atomic"
 	"time"
 
 	"connectrpc.com/connect"
 	"github.com/leapmux/leapmux/generated/proto/leapmux/v1/leapmuxv1connect"
 	"github.com/leapmux/leapmux/internal/hub/auth"
 	"github.com/leapmux/leapmux/internal/hub/bootstrap"
+	"github.com/leapmux/leapmux/internal/hub/certs"
 	"github.com/leapmux/leapmux/internal/hub/channelmgr"
 	"github.com/leapmux/leapmux/internal/hub/cleanup"
 	"github.com/leapmux/leapmux/internal/hub/config"
 	"github.com/leapmux/leapmux/internal/hub/crdt"
+	"github.com/leapmux/leapmux/internal/hub/crossorigin"
 	"github.com/leapmux/leapmux/internal/hub/frontend"
 	"github.com/leapmux/leapmux/internal/hub/keystore"
 	"github.com/leapmux/leapmux/internal/hub/mail"
@@ -31,6 +34,7 @@ import (
 	"github.com/leapmux/leapmux/internal/hub/workermgr"
 	"github.com/leapmux/leapmux/internal/logging"
 	"github.com/leapmux/leapmux/internal/metrics"
+	"github.com/leapmux/leapmux/internal/plugin"
 	"github.com/leapmux/leapmux/internal/util/id"
 	"github.com/leapmux/leapmux/internal/util/userid"
 	"github.com/leapmux/leapmux/locallisten"
@@ -42,11 +46,16 @@ import (
 // failure and on runtime shutdown -- one constant so the two paths cannot drift.
 const crdtShutdownTimeout = 10 * time.Second
 
+// shutdownRetryDelaySeconds is how long workers and frontends are told to
+// wait before reconnecting to a hub that is shutting down.
+const shutdownRetryDelaySeconds = 10
+
 // ServerOption configures optional aspects of a Hub server.
 type ServerOption func(*serverOptions)
 
 type serverOptions struct {
 	frontendHandler http.Handler
+	dnsProvider     certs.DNSProvider
 }
 
 // WithFrontendHandler overrides the default frontend handler.
@@ -56,6 +65,15 @@ func WithFrontendHandler(h http.Handler) ServerOption {
 	}
 }
 
+// WithDNSProvider publishes ACME dns-01 records through p instead of the
+// configured acme_dns_hook, for binaries that talk to their DNS host's API
+// directly.
+func WithDNSProvider(p certs.DNSProvider) ServerOption {
+	return func(o *serverOptions) {
+		o.dnsProvider = p
+	}
+}
+
 // Server is a reusable Hub server instance.
 type Server struct {
 	cfg               *config.Config
@@ -67,10 +85,15 @@ type Server struct {
 	localLn           net.Listener
 	listenURL         string
 	shutdownCh        chan struct{}
+	draining          *atomic.Bool
+	pendingReqs       *workermgr.PendingRequests
+	broadcaster       *service.HubEventBroadcaster
 	authContexts      *auth.AuthContextRegistry
 	workerMgr         *workermgr.Manager
 	crdtRegistry      *crdt.Registry
 	revocationWatcher *revocationwatcher.Watcher
+	certSource        certs.Source // nil unless the hub terminates TLS
+	plugins           *plugin.Host // nil unless plugin_dir is set
 }
 
 // NewServer creates a new Hub server. It binds the TCP port and local IPC
@@ -85,6 +108,10 @@ func NewServer(cfg *config.Config, opts ...ServerOption) (*Server, error) {
 	if err := cfg.Validate(); err != nil {
 		return nil, fmt.Errorf("validate config: %w", err)
 	}
+	plugins, err := plugin.New(cfg.PluginDir, "hub")
+	if err != nil {
+		return nil, fmt.Errorf("load plugins: %w", err)
+	}
 
 	// Records each resource as it is acquired, so every failure below closes
 	// exactly what is open without restating the subset (see acquiredResources).
@@ -174,6 +201,7 @@ func NewServer(cfg *config.Config, opts ...ServerOption) (*Server, error) {
 	// lifecycle) extend its already-open channels' expiry, not just its leases
 	// (which the registry owns directly).
 	authContexts.SetChannelExpiryRescheduler(cMgr)
+	authContexts.SetPublicWorkspaces(cfg.PublicWorkspaceIDs())
 	connectOpts := connect.WithInterceptors(
 		auth.NewShutdownInterceptor(shutdownCh),
 		metrics.NewInterceptor(),
@@ -250,7 +278,8 @@ func NewServer(cfg *config.Config, opts ...ServerOption) (*Server, error) {
 	// WebSocket endpoint for encrypted channel relay (Frontend <-> Worker).
 	channelRelay := service.NewChannelRelayHandler(st, wMgr, cMgr, authContexts, soloUser, cfg.SecureCookies).
 		WithTokenValidator(tokenValidator).
-		WithChannelCloseEnqueuer(channelSvc)
+		WithChannelCloseEnqueuer(channelSvc).
+		WithAllowedOrigins(cfg.AllowedOriginList())
 	mux.Handle("/ws/channel", channelRelay)
 
 	// OAuth HTTP endpoints.
@@ -275,14 +304,56 @@ func NewServer(cfg *config.Config, opts ...ServerOption) (*Server, error) {
 	userPath, userHandler := leapmuxv1connect.NewUserServiceHandler(userSvc, connectOpts)
 	mux.Handle(userPath, userHandler)
 
-	sectionSvc := service.NewSectionService(st)
+	sectionSvc := service.NewSectionService(st, broadcaster)
 	sectionPath, sectionHandler := leapmuxv1connect.NewSectionServiceHandler(sectionSvc, connectOpts)
 	mux.Handle(sectionPath, sectionHandler)
 
-	workspaceSvc := service.NewWorkspaceService(st, crdtRegistry, channelSvc)
+	layoutSvc := service.NewLayoutService(st)
+	layoutPath, layoutHandler := leapmuxv1connect.NewLayoutServiceHandler(layoutSvc, connectOpts)
+	mux.Handle(layoutPath, layoutHandler)
+
+	repoSvc := service.NewRepoService(st, ks)
+	repoPath, repoHandler := leapmuxv1connect.NewRepoServiceHandler(repoSvc, connectOpts)
+	mux.Handle(repoPath, repoHandler)
+
+	modelCredentialSvc := service.NewModelCredentialService(st, ks)
+	modelCredentialPath, modelCredentialHandler := leapmuxv1connect.NewModelCredentialServiceHandler(modelCredentialSvc, connectOpts)
+	mux.Handle(modelCredentialPath, modelCredentialHandler)
+
+	settingsSvc := service.NewSettingsService(st, wMgr)
+	settingsPath, settingsHandler := leapmuxv1connect.NewSettingsServiceHandler(settingsSvc, connectOpts)
+	mux.Handle(settingsPath, settingsHandler)
+
+	systemPromptSvc := service.NewSystemPromptService(st)
+	systemPromptPath, systemPromptHandler := leapmuxv1connect.NewSystemPromptServiceHandler(systemPromptSvc, connectOpts)
+	mux.Handle(systemPromptPath, systemPromptHandler)
+
+	snippetSvc := service.NewSnippetService(st)
+	snippetPath, snippetHandler := leapmuxv1connect.NewSnippetServiceHandler(snippetSvc, connectOpts)
+	mux.Handle(snippetPath, snippetHandler)
+
+	announcementSvc := service.NewAnnouncementService(st, wMgr)
+	announcementPath, announcementHandler := leapmuxv1connect.NewAnnouncementServiceHandler(announcementSvc, connectOpts)
+	mux.Handle(announcementPath, announcementHandler)
+
+	paletteSvc := service.NewPaletteService(st, wMgr)
+	palettePath, paletteHandler := leapmuxv1connect.NewPaletteServiceHandler(paletteSvc, connectOpts)
+	mux.Handle(palettePath, paletteHandler)
+
+	workspaceSvc := service.NewWorkspaceService(st, crdtRegistry, channelSvc).
+		WithRepoCheckouts(wMgr, pendingReqs, ks).
+		WithPlugins(plugins)
 	workspacePath, workspaceHandler := leapmuxv1connect.NewWorkspaceServiceHandler(workspaceSvc, connectOpts)
 	mux.Handle(workspacePath, workspaceHandler)
 
+	transferSvc := service.NewWorkspaceTransferService(st, workspaceSvc)
+	transferPath, transferHandler := leapmuxv1connect.NewWorkspaceTransferServiceHandler(transferSvc, connectOpts)
+	mux.Handle(transferPath, transferHandler)
+
+	guestSvc := service.NewGuestInvitationService(st, tokenValidator, lifecycle)
+	guestPath, guestHandler := leapmuxv1connect.NewGuestInvitationServiceHandler(guestSvc, connectOpts)
+	mux.Handle(guestPath, guestHandler)
+
 	crdtSvc := service.NewCRDTService(st, crdtRegistry, slog.Default(), scopeCache)
 	crdtPath, crdtHandler := leapmuxv1connect.NewOrgCRDTHandler(crdtSvc, connectOpts)
 	mux.Handle(crdtPath, crdtHandler)
@@ -296,10 +367,11 @@ func NewServer(cfg *config.Config, opts ...ServerOption) (*Server, error) {
 	// chunked-stream buffering hazards (some proxies / Tauri's
 	// buffered fetch) that motivated retiring the streaming RPC.
 	orgEventsHandler := service.NewOrgEventsHandler(st, crdtRegistry, authContexts, soloUser, cfg.SecureCookies).
-		WithTokenValidator(tokenValidator)
+		WithTokenValidator(tokenValidator).
+		WithAllowedOrigins(cfg.AllowedOriginList())
 	mux.Handle("/ws/orgevents", orgEventsHandler)
 
-	reconcilerSvc := service.NewWorkerReconcilerService(st)
+	reconcilerSvc := service.NewWorkerReconcilerService(st, cMgr, ks)
 	reconcilerPath, reconcilerHandler := leapmuxv1connect.NewWorkerReconcilerServiceHandler(reconcilerSvc, connectOpts)
 	mux.Handle(reconcilerPath, reconcilerHandler)
 
@@ -311,6 +383,11 @@ func NewServer(cfg *config.Config, opts ...ServerOption) (*Server, error) {
 	// hub versions without needing an authenticated session.
 	mux.HandleFunc("/version", versionHandler)
 
+	// Unauthenticated /readyz endpoint for load balancers: it fails as
+	// soon as shutdown begins, while the hub is still draining.
+	draining := new(atomic.Bool)
+	mux.HandleFunc("/readyz", readyHandler(draining))
+
 	// Frontend handler.
 	if so.frontendHandler != nil {
 		mux.Handle("/", so.frontendHandler)
@@ -322,16 +399,40 @@ func NewServer(cfg *config.Config, opts ...ServerOption) (*Server, error) {
 		}
 		mux.Handle("/", devProxy)
 		slog.Info("dev mode: proxying frontend", "target", cfg.DevFrontend)
+	} else if cfg.UIDir != "" {
+		uiHandler, uiErr := frontend.DirHandler(cfg.UIDir)
+		if uiErr != nil {
+			return nil, acquired.close(
+				fmt.Errorf("serve ui dir: %w", uiErr))
+		}
+		mux.Handle("/", uiHandler)
+		slog.Info("serving frontend from disk", "dir", cfg.UIDir)
 	} else {
 		mux.Handle("/", frontend.Handler())
 	}
 
+	// Cross-origin policy: CORS for a web UI served from another origin,
+	// CSRF checks on every state-changing request, and the gRPC-Web switch.
+	var trustedOrigins []string
+	if cfg.PublicURL != "" {
+		trustedOrigins = append(trustedOrigins, cfg.PublicURL)
+	}
+	handler, err := crossorigin.Handler(crossorigin.Options{
+		AllowedOrigins: cfg.AllowedOriginList(),
+		TrustedOrigins: trustedOrigins,
+		GRPCWeb:        cfg.GRPCWeb,
+	}, mux)
+	if err != nil {
+		return nil, acquired.close(
+			fmt.Errorf("cross-origin policy: %w", err))
+	}
+
 	protocols := &http.Protocols{}
 	protocols.SetHTTP1(true)
 	protocols.SetUnencryptedHTTP2(true)
 
 	server := &http.Server{
-		Handler:           logging.HTTPMiddleware(metrics.HTTPMiddleware(mux)),
+		Handler:           logging.HTTPMiddleware(metrics.HTTPMiddleware(handler)),
 		ReadHeaderTimeout: 10 * time.Second,
 		Protocols:         protocols,
 		HTTP2: &http.HTTP2Config{
@@ -339,6 +440,19 @@ func NewServer(cfg *config.Config, opts ...ServerOption) (*Server, error) {
 		},
 	}
 
+	// HTTPS on the TCP listener, from a manual pair or ACME. The local IPC
+	// listener stays cleartext: only processes on this machine reach it.
+	var certSource certs.Source
+	if cfg.TLSEnabled() {
+		certSource, err = newCertSource(cfg, so.dnsProvider)
+		if err != nil {
+			return nil, acquired.close(
+				fmt.Errorf("tls: %w", err))
+		}
+		protocols.SetHTTP2(true)
+		server.TLSConfig = certs.TLSConfig(certSource)
+	}
+
 	// Watcher for cross-process revocations: admin CLI commands mutate
 	// auth state and record durable revocation events, and the watcher
 	// publishes + consumes that stream to drive the matching cache
@@ -359,13 +473,38 @@ func NewServer(cfg *config.Config, opts ...ServerOption) (*Server, error) {
 		localLn:           localLn,
 		listenURL:         listenURL,
 		shutdownCh:        shutdownCh,
+		draining:          draining,
+		pendingReqs:       pendingReqs,
+		broadcaster:       broadcaster,
 		authContexts:      authContexts,
 		workerMgr:         wMgr,
 		crdtRegistry:      crdtRegistry,
 		revocationWatcher: revWatcher,
+		certSource:        certSource,
+		plugins:           plugins,
 	}, nil
 }
 
+// newCertSource builds the certificate source cfg describes. dnsProvider,
+// when set, takes precedence over the acme_dns_hook script.
+func newCertSource(cfg *config.Config, dnsProvider certs.DNSProvider) (certs.Source, error) {
+	if dnsProvider == nil && cfg.ACMEDNSHook != "" {
+		dnsProvider = certs.ExecDNSProvider{Path: cfg.ACMEDNSHook}
+	}
+	return certs.New(certs.Options{
+		CertFile:       cfg.TLSCertFile,
+		KeyFile:        cfg.TLSKeyFile,
+		Domains:        cfg.ACMEDomainList(),
+		Email:          cfg.ACMEEmail,
+		DirectoryURL:   cfg.ACMEDirectoryURL,
+		Challenge:      cfg.ACMEChallenge,
+		CacheDir:       certs.CacheDir(cfg.DataDir),
+		HTTPListen:     cfg.ACMEHTTPListen,
+		DNSProvider:    dnsProvider,
+		DNSPropagation: cfg.ACMEDNSPropagation(),
+	})
+}
+
 // Store returns the Hub's store for direct database access
 // (e.g. for solo/dev auto-registration).
 func (s *Server) Store() store.Store {
@@ -490,6 +629,20 @@ func (s *Server) Serve(ctx context.Context) error {
 	// Start periodic cleanup of soft-deleted records.
 	cleanup.StartLoop(serveCtx, s.store)
 
+	// Run the operator's exec plugins; a no-op when there are none.
+	s.plugins.Start(serveCtx)
+
+	// Keep the TLS certificate current: reload a manual pair, renew an ACME
+	// one, answer http-01 challenges. A failure here leaves the current
+	// certificate serving, so it is logged rather than fatal.
+	if s.certSource != nil {
+		go func() {
+			if err := s.certSource.Run(serveCtx); err != nil {
+				slog.Error("tls certificate source stopped", "error", err)
+			}
+		}()
+	}
+
 	// Start the revocation watcher: publishes and consumes the durable
 	// revocation stream so admin-CLI mutations land in the hub's
 	// in-memory caches and channelmgr without IPC. Seed past events that
@@ -501,16 +654,33 @@ func (s *Server) Serve(ctx context.Context) error {
 		<-serveCtx.Done()
 		slog.Info("hub shutting down...")
 
-		// 1. Reject all new RPCs and stop background tasks.
+		// 1. Fail /readyz and keep serving for the drain period, giving a
+		// load balancer time to take the hub out of rotation.
+		s.draining.Store(true)
+		if drain := s.cfg.ShutdownDrain(); drain > 0 {
+			slog.Info("draining before shutdown", "duration", drain)
+			time.Sleep(drain)
+		}
+
+		// 2. Reject all new RPCs and stop background tasks.
 		close(s.shutdownCh)
 		s.authContexts.Stop()
 
-		// 2. Notify connected workers to delay reconnection.
+		// 3. Notify connected workers and frontends to delay reconnection.
 		notifyCtx, cancelNotify := context.WithTimeout(context.Background(), 2*time.Second)
-		s.workerMgr.NotifyShutdown(notifyCtx, 10)
+		s.workerMgr.NotifyShutdown(notifyCtx, shutdownRetryDelaySeconds)
 		cancelNotify()
+		s.broadcaster.NotifyShuttingDown(shutdownRetryDelaySeconds)
 
-		// 3. Drain in-flight HTTP requests, then force-close any connections
+		// 4. Let in-flight worker requests finish, so a frontend waiting on
+		// one gets its answer rather than a dropped connection.
+		shutdownCtx, cancel := context.WithTimeout(context.Background(), s.cfg.ShutdownTimeout())
+		defer cancel()
+		if err := s.pendingReqs.WaitIdle(shutdownCtx); err != nil {
+			slog.Warn("shutting down with worker requests in flight", "error", err)
+		}
+
+		// 5. Drain in-flight HTTP requests, then force-close any connections
 		// the drain left behind. On Windows each accepted named-pipe
 		// connection is its own pipe instance; if any survive, the next
 		// ListenPipe with FILE_FLAG_FIRST_PIPE_INSTANCE on the same name
@@ -523,8 +693,6 @@ func (s *Server) Serve(ctx context.Context) error {
 		// them. locallisten.CloseAccepted closes the underlying pipe handles
 		// directly via the listener's own accepted-connection tracking,
 		// which is the only level that sees every accepted conn.
-		shutdownCtx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
-		defer cancel()
 		httpShutdownErr := s.server.Shutdown(shutdownCtx)
 		httpCloseErr := s.server.Close()
 		locallisten.CloseAccepted(s.localLn)
@@ -546,12 +714,12 @@ func (s *Server) Serve(ctx context.Context) error {
 	errCh := make(chan listenerResult, listenerCount)
 
 	if tcpLn != nil {
-		go func() { errCh <- listenerResult{isTCP: true, err: s.server.Serve(tcpLn)} }()
+		go func() { errCh <- listenerResult{isTCP: true, err: s.serveTCP(tcpLn)} }()
 	}
 	go func() { errCh <- listenerResult{err: s.server.Serve(localLn)} }()
 
 	if tcpLn != nil {
-		slog.Info("hub listening", "listen", s.cfg.Listen, "local", listenURL)
+		slog.Info("hub listening", "listen", s.cfg.Listen, "tls", s.certSource != nil, "local", listenURL)
 	} else {
 		slog.Info("hub listening", "local", listenURL)
 	}
@@ -610,6 +778,16 @@ func (s *Server) Serve(ctx context.Context) error {
 	return teardownErrs.finalize()
 }
 
+// serveTCP serves the TCP listener, over TLS when the hub has a
+// certificate source. The certificates come from server.TLSConfig, hence
+// the empty file names.
+func (s *Server) serveTCP(ln net.Listener) error {
+	if s.certSource != nil {
+		return s.server.ServeTLS(ln, "", "")
+	}
+	return s.server.Serve(ln)
+}
+
 // foldPendingWatcherError folds a still-buffered fatal watcher error into
 // primary. Serve's teardown select consumes exactly one of {listener error,
 // watcher error, ctx-done}; a watcher lease-loss racing a listener error is left
@@ -45,6 +45,9 @@ var identityComparisonSites = map[string]string{
 	// minted (and a blank one 403'd) before the comparison, so again the named
 	// test pins the boundary rather than the comparison behind it.
 	"internal/hub/service.(*WorkerDelegationHandler).handleMint": "TestWorkerDelegation_Mint_RejectsBlankUserID",
+	// Decides whether a caller may revoke a guest link; identity comes from the
+	// context, and a miss reads as NotFound so links cannot be probed.
+	"internal/hub/service.(*GuestInvitationService).RevokeGuestInvitation": "TestGuestInvitationService_RevokeDeniesZeroCaller",
 
 	// ---- hub/store ----
 
@@ -53,10 +53,30 @@ var workerReachSites = map[string]workerReachKind{
 	"internal/hub/service.(*ChannelRelayHandler).relayFrontendMessageToWorker": reachEstablishedChan,
 	"internal/hub/service.(*workerCloseDispatcher).enqueueChannelCloses":       reachServerInitiated,
 	"internal/hub/service.(*workerCloseDispatcher).deliverWorkerCloses":        reachServerInitiated,
-	// workerToProto publishes the online bit on rows its two callers loaded
-	// via Workers().GetOwned and Workers().ListByUserID, both of which scope
-	// to the caller's user id in SQL.
+	// Connect flags the upgrade-required state of the worker whose own auth
+	// token opened the stream. No user is in the path.
+	"internal/hub/service.(*WorkerConnectorService).Connect": reachServerInitiated,
+	// workerToProto publishes the online bit, the upgrade-required flag and
+	// the connection report on rows its two callers loaded via
+	// Workers().GetOwned and Workers().ListByUserID, both of which scope to
+	// the caller's user id in SQL.
 	"internal/hub/service.(*WorkerManagementService).workerToProto": reachStoreScoped,
+	// SuggestActions probes only workers it loaded via Workers().ListByUserID;
+	// tab rows' client-written worker ids are matched against that set, never
+	// probed directly.
+	"internal/hub/service.(*PaletteService).SuggestActions": reachStoreScoped,
+	// pickCheckoutWorker reads the online bit and labels only of workers it
+	// loaded via Workers().ListByUserID; the checkout itself then goes
+	// through the gated ConnForUser.
+	"internal/hub/service.pickCheckoutWorker": reachStoreScoped,
+	// annotateWorkspaceActivity reads the activity reports only of workers
+	// ownedActivityWorkers matched to the caller via Workers().GetOwned;
+	// tab rows' client-written worker ids are never probed as given.
+	"internal/hub/service.annotateWorkspaceActivity": reachStoreScoped,
+	// pushEmergencyStops is entered from EmergencyStop and ReleaseEmergencyStop
+	// after requireAdmin, with ids from ownedWorkerIDs: the stopped org's
+	// owner's rows, or the one worker GetOwned matched to that owner.
+	"internal/hub/service.(*WorkerManagementService).pushEmergencyStops": reachServerInitiated,
 	// The notifier's worker ids come from an authorized store row or a trusted
 	// server flow (deregister, reconnect flush), never from a user request, and
 	// it holds a 3-method narrow interface rather than *workermgr.Manager -- so
@@ -71,8 +91,8 @@ var workerReachSites = map[string]workerReachKind{
 }
 
 // registryMethodKind names WHY one exported *workermgr.Manager method that
-// touches the live-worker maps (conns, deregistering) needs -- or does not need
-// -- its call sites classified in workerReachSites.
+// touches the live-worker maps (conns, deregistering, upgradeRequired) needs
+// -- or does not need -- its call sites classified in workerReachSites.
 //
 // The point of classifying the METHODS, not just their call sites, is that the
 // scanned set stops being a hand-written list of three names. That list was
@@ -98,19 +118,27 @@ const (
 )
 
 // registryMethodKinds classifies every exported *workermgr.Manager method whose
-// body reads or writes conns / deregistering. The walk in TestRepoInvariants
-// fails on a method missing from this map, AND on an entry whose kind the
-// source contradicts -- a registryUngatedByID that calls the authorizer, a
-// registryConnScoped that takes no *Conn, a registryBroadcast that takes a
-// worker id. So the kind is a claim about the code, not a comment with a type.
+// body reads or writes conns / deregistering / upgradeRequired. The walk in
+// TestRepoInvariants fails on a method missing from this map, AND on an entry
+// whose kind the source contradicts -- a registryUngatedByID that calls the
+// authorizer, a registryConnScoped that takes no *Conn, a registryBroadcast
+// that takes a worker id. So the kind is a claim about the code, not a comment
+// with a type.
 var registryMethodKinds = map[string]registryMethodKind{
-	"ConnForTrustedPath":   registryUngatedByID,
-	"OnlineForTrustedPath": registryUngatedByID,
-	"IsDeregistering":      registryUngatedByID,
-	"MarkDeregistering":    registryUngatedByID,
-	"ClearDeregistering":   registryUngatedByID,
-	"ConnForUser":          registryGated,
-	"Register":             registryConnScoped,
-	"Unregister":           registryConnScoped,
-	"NotifyShutdown":       registryBroadcast,
+	"ConnForTrustedPath":              registryUngatedByID,
+	"OnlineForTrustedPath":            registryUngatedByID,
+	"ConnectionStatsForTrustedPath":   registryUngatedByID,
+	"LabelsForTrustedPath":            registryUngatedByID,
+	"DiskUsageForTrustedPath":         registryUngatedByID,
+	"WorkspaceActivityForTrustedPath": registryUngatedByID,
+	"UpgradeRequiredForTrustedPath":   registryUngatedByID,
+	"SetUpgradeRequired":              registryUngatedByID,
+	"IsDeregistering":                 registryUngatedByID,
+	"MarkDeregistering":               registryUngatedByID,
+	"ClearDeregistering":              registryUngatedByID,
+	"ConnForUser":                     registryGated,
+	"Register":                        registryConnScoped,
+	"Unregister":                      registryConnScoped,
+	"NotifyShutdown":                  registryBroadcast,
+	"Broadcast":                       registryBroadcast,
 }
@@ -4,6 +4,7 @@ import (
 	"context"
 	"errors"
 	"flag"
+	"fmt"
 	"io"
 	"os"
 	"strings"
@@ -47,14 +48,63 @@ func RunAgentSend(rawCtx any, args []string) error {
 	})
 }
 
+// RunAgentSendTerminal sends the tail of a terminal's output to the agent
+// as a user message. The worker reads and cleans the output itself, so the
+// CLI only names the terminal; it must live on the agent's worker.
+func RunAgentSendTerminal(rawCtx any, args []string) error {
+	var terminalID, note string
+	var lines uint
+	return withResolvedAgent(rawCtx, args, agentScaffoldOpts{
+		setup: func(fs *flag.FlagSet) {
+			fs.StringVar(&terminalID, "terminal-id", "", "terminal whose output to send (on the agent's worker)")
+			fs.UintVar(&lines, "lines", 0, "trailing lines to send (default 100, max 1000)")
+			fs.StringVar(&note, "note", "", "text to place before the output")
+		},
+		validate: func() error {
+			if terminalID == "" {
+				return remote.EmitError("invalid_request", "--terminal-id is required")
+			}
+			return nil
+		},
+		body: func(ctx context.Context, c *remote.Client, workerID, agentID, _ string) error {
+			var resp leapmuxv1.SendTerminalOutputToAgentResponse
+			if err := callInnerRPC(ctx, c, workerID, "SendTerminalOutputToAgent", &leapmuxv1.SendTerminalOutputToAgentRequest{
+				AgentId:    agentID,
+				TerminalId: terminalID,
+				Lines:      uint32(lines),
+				Note:       note,
+			}, &resp); err != nil {
+				return err
+			}
+			return remote.EmitData(map[string]any{
+				"agent_id":   agentID,
+				"message_id": resp.GetMessageId(),
+				"lines":      resp.GetLines(),
+				"truncated":  resp.GetTruncated(),
+			})
+		},
+	})
+}
+
 func RunAgentInterrupt(rawCtx any, args []string) error {
-	var reason string
+	var reason, kind string
+	var reasonKind leapmuxv1.InterruptReason
 	return withResolvedAgent(rawCtx, args, agentScaffoldOpts{
 		setup: func(fs *flag.FlagSet) {
 			fs.StringVar(&reason, "reason", "", "audit reason")
+			fs.StringVar(&kind, "kind", "user", "who is interrupting: user, watchdog, budget, or policy")
+		},
+		validate: func() error {
+			v, ok := parseEnumFlag(kind, interruptReasonMap)
+			if !ok {
+				return remote.EmitError("invalid_request", fmt.Sprintf(`--kind must be one of "user", "watchdog", "budget", "policy"; got %q`, kind))
+			}
+			reasonKind = v
+			return nil
 		},
 		body: func(ctx context.Context, c *remote.Client, workerID, agentID, _ string) error {
-			if err := callInnerRPC(ctx, c, workerID, "InterruptAgent", &leapmuxv1.InterruptAgentRequest{AgentId: agentID, Reason: reason}, nil); err != nil {
+			req := &leapmuxv1.InterruptAgentRequest{AgentId: agentID, Reason: reason, ReasonKind: reasonKind}
+			if err := callInnerRPC(ctx, c, workerID, "InterruptAgent", req, nil); err != nil {
 				return err
 			}
 			return remote.EmitData(map[string]string{"agent_id": agentID})
@@ -62,6 +112,13 @@ func RunAgentInterrupt(rawCtx any, args []string) error {
 	})
 }
 
+var interruptReasonMap = map[string]leapmuxv1.InterruptReason{
+	"user":     leapmuxv1.InterruptReason_INTERRUPT_REASON_USER,
+	"watchdog": leapmuxv1.InterruptReason_INTERRUPT_REASON_WATCHDOG,
+	"budget":   leapmuxv1.InterruptReason_INTERRUPT_REASON_BUDGET,
+	"policy":   leapmuxv1.InterruptReason_INTERRUPT_REASON_POLICY,
+}
+
 // RunAgentGet returns the worker-side agent record (settings, status,
 // available models). Resolution mirrors `agent send`: --worker-id wins,
 // then GetTab on the hub. Implementation reuses ListAgents with a
@@ -182,6 +182,23 @@ func TestRunAgentSend_RequiresMessageOrStdin(t *testing.T) {
 	assert.Contains(t, env.Error["message"], "stdin")
 }
 
+// TestRunAgentSendTerminal_RequiresTerminalID pins the early-validation
+// path on `agent send-terminal`: without --terminal-id there is nothing
+// to read, so the CLI fails before any RPC traffic.
+func TestRunAgentSendTerminal_RequiresTerminalID(t *testing.T) {
+	clearRemoteEnv(t)
+	out := withCapturedStdout(t, func() {
+		err := RunAgentSendTerminal(fakeCmdCtx{}, []string{"--hub", "https://stub", "--tab-id", "ag-1"})
+		require.Error(t, err)
+	})
+	var env struct {
+		Error map[string]string `json:"error"`
+	}
+	require.NoError(t, json.Unmarshal(out, &env))
+	assert.Equal(t, "invalid_request", env.Error["code"])
+	assert.Contains(t, env.Error["message"], "--terminal-id")
+}
+
 // TestRunTabRename_RequiresTitle pins the early-validation path on
 // the new universal `tab rename` command. Missing --title surfaces
 // invalid_request without any RPC traffic.
@@ -2,10 +2,16 @@ package cmd
 
 import (
 	"context"
+	"errors"
+	"io"
+	"os"
+	"os/signal"
+	"syscall"
 
 	leapmuxv1 "github.com/leapmux/leapmux/generated/proto/leapmux/v1"
 	"github.com/leapmux/leapmux/internal/cli/remote"
 	"github.com/leapmux/leapmux/internal/cli/remote/resolve"
+	"github.com/leapmux/leapmux/tunnel"
 )
 
 // resolveWorker is shared by file / git handlers: bind the universal
@@ -111,3 +117,106 @@ func RunFileStat(rawCtx any, args []string) error {
 		&leapmuxv1.StatFileRequest{WorkerId: workerID, Path: f.Path}, &resp,
 		func() any { return resp.GetInfo() })
 }
+
+// RunFileDownload streams a whole file to stdout, or to --out with a JSON
+// summary on stdout. Unlike `file read` it has no size cap: over a hub the
+// bytes ride a flow-controlled DownloadFile sub-stream, so the transfer is
+// bounded only by the channel's lifetime (Ctrl-C cancels it on the worker).
+func RunFileDownload(rawCtx any, args []string) error {
+	cmd := asCtx(rawCtx)
+	f := bindPathCmd(cmd, false, "path to download (required)")
+	var offset int64
+	var out string
+	f.FS.Int64Var(&offset, "offset", 0, "byte offset to resume from")
+	f.FS.StringVar(&out, "out", "", "write the file here and print a JSON summary (default: raw bytes to stdout)")
+	if err := parseFlags(f.FS, args, cmd.Description()); err != nil {
+		return err
+	}
+	if err := f.Require(""); err != nil {
+		return err
+	}
+	if offset < 0 {
+		return remote.EmitError("invalid_request", "--offset must not be negative")
+	}
+	c, workerID, err := resolveWorker(f.Hub, f.In)
+	if err != nil {
+		return err
+	}
+
+	dst := remote.Out
+	if out != "" {
+		file, err := os.Create(out)
+		if err != nil {
+			return remote.EmitErrorWith("write_failed", err)
+		}
+		defer func() { _ = file.Close() }()
+		dst = file
+	}
+
+	ctx, cancel := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
+	defer cancel()
+	path, n, err := downloadFile(ctx, c, workerID, f.Path, offset, dst)
+	if err != nil {
+		var coded *codedRPCError
+		if errors.As(err, &coded) {
+			return remote.EmitErrorWith(coded.Code, coded.Cause)
+		}
+		return remote.EmitErrorWith("download_failed", err)
+	}
+	if out == "" {
+		return nil
+	}
+	return remote.EmitData(map[string]any{"path": path, "out": out, "bytes": n})
+}
+
+// downloadFile copies the file at path into dst and returns its resolved path
+// and the bytes written. Local IPC has no channel to carry a sub-stream, so it
+// pages through ReadFile instead.
+func downloadFile(ctx context.Context, c *remote.Client, workerID, path string, offset int64, dst io.Writer) (string, int64, error) {
+	if c.IsLocal() {
+		return downloadFileByPages(ctx, c, workerID, path, offset, dst)
+	}
+	openCtx, cancel := rpcDeadline(ctx)
+	defer cancel()
+	if err := maybePreflightWorker(openCtx, c, workerID); err != nil {
+		return "", 0, err
+	}
+	ch, err := c.OpenE2EEChannel(openCtx, ctx, workerID)
+	if err != nil {
+		return "", 0, &codedRPCError{Code: "channel_open_failed", Cause: err}
+	}
+	defer ch.Close()
+	d, err := tunnel.DownloadFile(openCtx, ch, path, offset)
+	if err != nil {
+		return "", 0, &codedRPCError{Code: "rpc_failed", Cause: err}
+	}
+	defer func() { _ = d.Close() }()
+	n, err := io.Copy(dst, d)
+	return d.Path(), n, err
+}
+
+func downloadFileByPages(ctx context.Context, c *remote.Client, workerID, path string, offset int64, dst io.Writer) (string, int64, error) {
+	var written int64
+	for {
+		pageCtx, cancel := rpcDeadline(ctx)
+		var resp leapmuxv1.ReadFileResponse
+		err := callInnerRPCBest(pageCtx, c, workerID, "ReadFile",
+			&leapmuxv1.ReadFileRequest{WorkerId: workerID, Path: path, Offset: offset}, &resp)
+		cancel()
+		if err != nil {
+			return "", written, err
+		}
+		content := resp.GetContent()
+		if len(content) > 0 {
+			n, err := dst.Write(content)
+			written += int64(n)
+			if err != nil {
+				return "", written, err
+			}
+			offset += int64(n)
+		}
+		if len(content) == 0 || offset >= resp.GetTotalSize() {
+			return resp.GetPath(), written, nil
+		}
+	}
+}
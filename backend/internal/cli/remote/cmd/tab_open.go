@@ -34,7 +34,7 @@ func RunTabOpen(rawCtx any, args []string) error {
 	// Agent-only flags.
 	var provider, model, effort, title, permissionMode, workingDir, initialMessage string
 	// Terminal-only flags.
-	var shell, shellStartDir string
+	var shell, shellStartDir, profile string
 	var in resolve.Inputs
 	fs := flagSet(cmd, &hub)
 	resolve.BindEntityFlags(fs, &in, resolve.FlagOptions{HideOrg: true, HideUser: true})
@@ -54,6 +54,7 @@ func RunTabOpen(rawCtx any, args []string) error {
 	// Terminal-only flags.
 	fs.StringVar(&shell, "shell", "", "--type=terminal: shell path (empty -> worker default)")
 	fs.StringVar(&shellStartDir, "shell-start-dir", "", "--type=terminal: shell start directory (empty -> working-dir)")
+	fs.StringVar(&profile, "profile", "", "--type=terminal: terminal profile to start from (--shell and --shell-start-dir override its own)")
 	if err := parseFlags(fs, args, cmd.Description()); err != nil {
 		return err
 	}
@@ -140,6 +141,7 @@ func RunTabOpen(rawCtx any, args []string) error {
 			WorkingDir:    workingDir,
 			Shell:         shell,
 			ShellStartDir: shellStartDir,
+			Profile:       profile,
 			Position:      spec,
 		})
 		if err != nil {
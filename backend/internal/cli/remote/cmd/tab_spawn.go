@@ -242,8 +242,8 @@ func addTabToCRDTWithBootstrap(
 	return resolvedTileID, position, nil
 }
 
-// openTerminalArgs mirrors openAgentArgs for terminal spawns. Shell
-// and ShellStartDir are terminal-only. The PTY's initial dimensions
+// openTerminalArgs mirrors openAgentArgs for terminal spawns. Shell,
+// ShellStartDir and Profile are terminal-only. The PTY's initial dimensions
 // are not exposed at the CLI: the worker defaults to 80x25 and the
 // frontend immediately resizes once the user attaches, so any caller-
 // supplied value would be overwritten in milliseconds.
@@ -254,6 +254,7 @@ type openTerminalArgs struct {
 	WorkingDir    string
 	Shell         string
 	ShellStartDir string
+	Profile       string
 	Position      positionSpec
 }
 
@@ -285,6 +286,7 @@ func openTerminalAndAddTab(ctx context.Context, c *remote.Client, args openTermi
 		WorkingDir:    args.WorkingDir,
 		Shell:         args.Shell,
 		ShellStartDir: args.ShellStartDir,
+		Profile:       args.Profile,
 	}
 	var resp leapmuxv1.OpenTerminalResponse
 	if err := callInnerRPC(ctx, c, args.WorkerID, "OpenTerminal", req, &resp); err != nil {
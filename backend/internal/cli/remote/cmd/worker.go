@@ -1,13 +1,19 @@
 package cmd
 
 import (
+	"bytes"
 	"context"
+	"errors"
 	"flag"
 	"os"
+	"os/signal"
+	"slices"
+	"syscall"
 
 	leapmuxv1 "github.com/leapmux/leapmux/generated/proto/leapmux/v1"
 	"github.com/leapmux/leapmux/internal/cli/remote"
 	"github.com/leapmux/leapmux/internal/cli/remote/resolve"
+	"github.com/leapmux/leapmux/tunnel"
 )
 
 // RunWorkerGet prints metadata for a single worker. The resolver
@@ -43,6 +49,79 @@ func RunWorkerList(rawCtx any, args []string) error {
 		func() any { return resp.GetWorkers() })
 }
 
+// RunWorkerExec runs one command on a worker and prints its captured
+// output and exit status. The command follows a `--`:
+//
+//	leapmux remote worker exec --path ~/repo -- make test
+//
+// The worker caps the output it streams back at 1 MiB and the run at
+// --timeout; Ctrl-C kills the command on the worker. It needs the E2EE
+// channel, so it is unavailable over local IPC.
+func RunWorkerExec(rawCtx any, args []string) error {
+	cmd := asCtx(rawCtx)
+	var argv []string
+	if i := slices.Index(args, "--"); i >= 0 {
+		args, argv = args[:i], args[i+1:]
+	}
+	f := bindPathCmd(cmd, true, "directory to run in (defaults to $LEAPMUX_REMOTE_WORKING_DIR)")
+	var timeout int64
+	f.FS.Int64Var(&timeout, "timeout", 0, "seconds before the command is killed (0 = 60, max 600)")
+	if err := parseFlags(f.FS, args, cmd.Description()); err != nil {
+		return err
+	}
+	if f.Path == "" {
+		return emitMissingPathErr()
+	}
+	if len(argv) == 0 {
+		return remote.EmitError("invalid_request", "a command is required after --")
+	}
+	c, workerID, err := resolveWorker(f.Hub, f.In)
+	if err != nil {
+		return err
+	}
+	if c.IsLocal() {
+		return remote.EmitError("unsupported", "worker exec is not available over local IPC")
+	}
+
+	ctx, cancel := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
+	defer cancel()
+	var stdout, stderr bytes.Buffer
+	res, err := execOnWorker(ctx, c, workerID, &leapmuxv1.ExecCommandRequest{
+		WorkingDir:     f.Path,
+		Argv:           argv,
+		TimeoutSeconds: timeout,
+	}, &stdout, &stderr)
+	if err != nil {
+		var coded *codedRPCError
+		if errors.As(err, &coded) {
+			return remote.EmitErrorWith(coded.Code, coded.Cause)
+		}
+		return remote.EmitErrorWith("exec_failed", err)
+	}
+	return remote.EmitData(map[string]any{
+		"working_dir": res.WorkingDir,
+		"exit_code":   res.ExitCode,
+		"timed_out":   res.TimedOut,
+		"truncated":   res.Truncated,
+		"stdout":      stdout.String(),
+		"stderr":      stderr.String(),
+	})
+}
+
+func execOnWorker(ctx context.Context, c *remote.Client, workerID string, req *leapmuxv1.ExecCommandRequest, stdout, stderr *bytes.Buffer) (*tunnel.ExecResult, error) {
+	openCtx, cancel := rpcDeadline(ctx)
+	defer cancel()
+	if err := maybePreflightWorker(openCtx, c, workerID); err != nil {
+		return nil, err
+	}
+	ch, err := c.OpenE2EEChannel(openCtx, ctx, workerID)
+	if err != nil {
+		return nil, &codedRPCError{Code: "channel_open_failed", Cause: err}
+	}
+	defer ch.Close()
+	return tunnel.ExecCommand(ctx, ch, req, stdout, stderr)
+}
+
 // `worker pins` is a subgroup whose list/show/remove leaves operate on
 // the worker-local TOFU pin store; the file lives under
 // $LEAPMUX_REMOTE_CONFIG_DIR keyed by --hub. No hub RPC is involved,
@@ -0,0 +1,92 @@
+// Package errcode is the catalog of stable, user-facing error codes. A code
+// travels with an error so that clients and support can react to a failure
+// programmatically instead of matching its message: as a leapmux.v1.
+// ErrorDetail on Connect errors, and as error_reason on a worker's inner
+// RPC and stream errors.
+//
+// Codes are part of the public API. Add new ones freely, but never rename
+// or reuse one.
+package errcode
+
+import (
+	"errors"
+
+	"connectrpc.com/connect"
+
+	leapmuxv1 "github.com/leapmux/leapmux/generated/proto/leapmux/v1"
+)
+
+// Code is a stable error code.
+type Code string
+
+const (
+	// WorkerOffline: the worker the request needs is not connected.
+	WorkerOffline Code = "LEAPMUX_WORKER_OFFLINE"
+	// WorkerNotFound: no worker with that ID is visible to the caller.
+	WorkerNotFound Code = "LEAPMUX_WORKER_NOT_FOUND"
+	// WorkspaceNotFound: no workspace with that ID is visible to the caller.
+	WorkspaceNotFound Code = "LEAPMUX_WORKSPACE_NOT_FOUND"
+	// AgentNotFound: the agent does not exist or is not running.
+	AgentNotFound Code = "LEAPMUX_AGENT_NOT_FOUND"
+	// TerminalNotFound: the terminal does not exist.
+	TerminalNotFound Code = "LEAPMUX_TERMINAL_NOT_FOUND"
+	// AgentStartupTimeout: the agent process did not finish its startup
+	// handshake within the agent startup timeout.
+	AgentStartupTimeout Code = "LEAPMUX_AGENT_STARTUP_TIMEOUT"
+	// DiskQuotaExceeded: a worktree or checkout was refused because the
+	// worker is out of disk space or over a disk quota.
+	DiskQuotaExceeded Code = "LEAPMUX_DISK_QUOTA_EXCEEDED"
+	// HubShuttingDown: the hub is shutting down and takes no new requests.
+	HubShuttingDown Code = "LEAPMUX_HUB_SHUTTING_DOWN"
+)
+
+// codedError carries a Code alongside the error it describes.
+type codedError struct {
+	code Code
+	err  error
+}
+
+func (e *codedError) Error() string { return e.err.Error() }
+func (e *codedError) Unwrap() error { return e.err }
+
+// Wrap attaches code to err, leaving its message unchanged. Wrap of a nil
+// error is nil.
+func Wrap(code Code, err error) error {
+	if err == nil {
+		return nil
+	}
+	return &codedError{code: code, err: err}
+}
+
+// New returns a Connect error with the given status code whose details
+// carry code.
+func New(c connect.Code, code Code, err error) *connect.Error {
+	cerr := connect.NewError(c, err)
+	if detail, derr := connect.NewErrorDetail(&leapmuxv1.ErrorDetail{Code: string(code)}); derr == nil {
+		cerr.AddDetail(detail)
+	}
+	return cerr
+}
+
+// Of returns the code carried by err: the outermost code attached with
+// Wrap, else the first ErrorDetail of a Connect error in its chain. It
+// returns "" when err carries no code.
+func Of(err error) Code {
+	var coded *codedError
+	if errors.As(err, &coded) {
+		return coded.code
+	}
+	var cerr *connect.Error
+	if errors.As(err, &cerr) {
+		for _, d := range cerr.Details() {
+			v, derr := d.Value()
+			if derr != nil {
+				continue
+			}
+			if detail, ok := v.(*leapmuxv1.ErrorDetail); ok && detail.GetCode() != "" {
+				return Code(detail.GetCode())
+			}
+		}
+	}
+	return ""
+}
@@ -0,0 +1,44 @@
+package errcode
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+
+	"connectrpc.com/connect"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestWrapAndOf(t *testing.T) {
+	sentinel := errors.New("disk full")
+	err := fmt.Errorf("create worktree: %w", Wrap(DiskQuotaExceeded, sentinel))
+
+	assert.Equal(t, DiskQuotaExceeded, Of(err))
+	assert.ErrorIs(t, err, sentinel, "the wrapped error stays reachable")
+	assert.Equal(t, "create worktree: disk full", err.Error(), "the message is unchanged")
+
+	assert.NoError(t, Wrap(AgentNotFound, nil))
+	assert.Equal(t, Code(""), Of(sentinel))
+	assert.Equal(t, Code(""), Of(nil))
+}
+
+func TestNew_CarriesCodeAsDetail(t *testing.T) {
+	err := New(connect.CodeUnavailable, WorkerOffline, errors.New("worker is offline"))
+
+	assert.Equal(t, connect.CodeUnavailable, err.Code())
+	assert.Equal(t, WorkerOffline, Of(err))
+	assert.Equal(t, WorkerOffline, Of(fmt.Errorf("list repos: %w", err)))
+
+	// The detail survives the wire: a client rebuilds the error from the
+	// code, message, and details it received.
+	received := connect.NewError(err.Code(), errors.New(err.Message()))
+	for _, d := range err.Details() {
+		v, verr := d.Value()
+		require.NoError(t, verr)
+		detail, derr := connect.NewErrorDetail(v)
+		require.NoError(t, derr)
+		received.AddDetail(detail)
+	}
+	assert.Equal(t, WorkerOffline, Of(received))
+}
@@ -40,6 +40,9 @@ const (
 	// (one-per-spawn ephemeral bearers). Wire form
 	// "lmx_d<id>_<secret>".
 	BearerKindDelegation BearerKind = 'd'
+	// BearerKindGuest marks owner-minted guest_invitations (time-boxed
+	// links to one workspace). Wire form "lmx_g<id>_<secret>".
+	BearerKindGuest BearerKind = 'g'
 )
 
 // AccessTokenTTL is the lifetime of a freshly minted CLI access token.
@@ -52,6 +55,15 @@ const RefreshTokenTTL = 90 * 24 * time.Hour
 // token. Short by design: agents that outlive the TTL refresh.
 const DelegationTokenTTL = 1 * time.Hour
 
+// GuestInvitationTTL is the lifetime of a guest link created without an
+// explicit one; MaxGuestInvitationTTL caps what the owner may ask for.
+// Guest links have no refresh half: when one lapses the owner shares a
+// new link.
+const (
+	GuestInvitationTTL    = 1 * time.Hour
+	MaxGuestInvitationTTL = 7 * 24 * time.Hour
+)
+
 // RefreshReuseGrace is how long a previously-rotated refresh token is
 // honoured as a benign retry after rotation. Reuse outside this window
 // triggers compromise revocation.
@@ -140,7 +152,7 @@ func ParseBearer(bearer string) (kind BearerKind, tokenID, secret string, err er
 // how to look up.
 func (k BearerKind) IsValid() bool {
 	switch k {
-	case BearerKindAPI, BearerKindDelegation:
+	case BearerKindAPI, BearerKindDelegation, BearerKindGuest:
 		return true
 	default:
 		return false
@@ -271,6 +283,12 @@ func (v *TokenValidator) lookupRowSecretHashes(ctx context.Context, kind BearerK
 			return "", nil, err
 		}
 		return row.ID, [][]byte{row.SecretHash, row.RefreshHash}, nil
+	case BearerKindGuest:
+		row, err := v.store.GuestInvitations().GetByID(ctx, tokenID)
+		if err != nil {
+			return "", nil, err
+		}
+		return row.ID, [][]byte{row.SecretHash}, nil
 	}
 	return "", nil, ErrInvalidToken
 }
@@ -372,6 +390,36 @@ func (v *TokenValidator) loadBearer(ctx context.Context, kind BearerKind, tokenI
 			touch:      func() { _ = v.store.DelegationTokens().Touch(ctx, del.ID) },
 			credential: DelegationCredential(tokenID, del.WorkspaceID, del.WorkerID),
 		}, nil
+
+	case BearerKindGuest:
+		guest, err := v.store.GuestInvitations().GetByID(ctx, tokenID)
+		if err != nil {
+			if errors.Is(err, store.ErrNotFound) {
+				return loadedBearer{}, connect.NewError(connect.CodeUnauthenticated, ErrInvalidToken)
+			}
+			return loadedBearer{}, connect.NewError(connect.CodeInternal, err)
+		}
+		readOnly, ok := guestRoleReadOnly(guest.Role)
+		if guest.WorkspaceID == "" || !ok {
+			// Same reasoning as the delegation guard above: a row the
+			// constructor would reject is an invalid token, not a panic --
+			// and an unknown role must never fall through to driver rights.
+			return loadedBearer{}, connect.NewError(connect.CodeUnauthenticated, ErrInvalidToken)
+		}
+		return loadedBearer{
+			fields: validateRowFields{
+				Revoked:        guest.RevokedAt != nil,
+				Expired:        IsExpired(time.Now(), guest.ExpiresAt),
+				SecretHash:     guest.SecretHash,
+				UserID:         guest.UserID,
+				RowID:          guest.ID,
+				CreatedAt:      guest.CreatedAt,
+				ExpiresAt:      guest.ExpiresAt,
+				AuthGeneration: guest.AuthGeneration,
+			},
+			touch:      func() { _ = v.store.GuestInvitations().Touch(ctx, guest.ID) },
+			credential: GuestCredential(tokenID, guest.WorkspaceID, readOnly),
+		}, nil
 	}
 
 	// parseBearer rejects unknown kinds; this case is unreachable but
@@ -282,7 +282,7 @@ func TestAuthenticateUsesTouchedSessionExpiry(t *testing.T) {
 
 	ctx, err := a.authenticate(
 		context.Background(), "/private",
-		CookieName+"=session", "",
+		CookieName+"=session", "", "",
 	)
 	require.NoError(t, err)
 	user := GetUser(ctx)
@@ -313,7 +313,7 @@ func TestZeroRowTouchDoesNotExtendSessionExpiry(t *testing.T) {
 
 	ctx, err := a.authenticate(
 		context.Background(), "/private",
-		CookieName+"=session", "",
+		CookieName+"=session", "", "",
 	)
 	require.NoError(t, err)
 	user := GetUser(ctx)
@@ -555,7 +555,7 @@ func TestSoloAuthenticationAdvancesPastUserRevocation(t *testing.T) {
 	cache := &AuthContextRegistry{state: state}
 
 	cache.RevokeUserAuthContextAtGeneration("solo", 2)
-	ctx, err := a.authenticate(context.Background(), "/private", "", "")
+	ctx, err := a.authenticate(context.Background(), "/private", "", "", "")
 	require.NoError(t, err)
 	current := GetUser(ctx)
 	require.NotNil(t, current)
@@ -611,7 +611,7 @@ func TestEvictByUserIDInvalidatesBearerUserInfo(t *testing.T) {
 func TestAuthenticateHTTPPreservesInternalSessionValidationError(t *testing.T) {
 	validationErr := errors.New("database unavailable")
 	req := httptest.NewRequest("GET", "/ws/channel", nil)
-	req.AddCookie(BuildSessionCookie("session", time.Now().Add(time.Hour), false))
+	req.AddCookie(BuildSessionCookie("session", time.Now().Add(time.Hour), false, false))
 
 	_, err := AuthenticateHTTP(context.Background(), req, HTTPAuthOpts{
 		Store:   validationErrorStore{err: validationErr},
@@ -20,8 +20,18 @@ func cookieName(secure bool) string {
 	return CookieName
 }
 
+// sameSite returns the session cookie's SameSite attribute. Lax keeps the
+// cookie to the hub's own site; crossSite relaxes it to None so a web UI on
+// another site can send it, leaving CSRF to the hub's origin checks.
+func sameSite(crossSite bool) http.SameSite {
+	if crossSite {
+		return http.SameSiteNoneMode
+	}
+	return http.SameSiteLaxMode
+}
+
 // BuildSessionCookie creates an HttpOnly session cookie.
-func BuildSessionCookie(sessionID string, expiresAt time.Time, secure bool) *http.Cookie {
+func BuildSessionCookie(sessionID string, expiresAt time.Time, secure, crossSite bool) *http.Cookie {
 	return &http.Cookie{
 		Name:     cookieName(secure),
 		Value:    sessionID,
@@ -30,12 +40,12 @@ func BuildSessionCookie(sessionID string, expiresAt time.Time, secure bool) *htt
 		MaxAge:   int(time.Until(expiresAt).Seconds()),
 		HttpOnly: true,
 		Secure:   secure,
-		SameSite: http.SameSiteLaxMode,
+		SameSite: sameSite(crossSite),
 	}
 }
 
 // ClearSessionCookie creates a cookie that clears the session.
-func ClearSessionCookie(secure bool) *http.Cookie {
+func ClearSessionCookie(secure, crossSite bool) *http.Cookie {
 	return &http.Cookie{
 		Name:     cookieName(secure),
 		Value:    "",
@@ -43,7 +53,7 @@ func ClearSessionCookie(secure bool) *http.Cookie {
 		MaxAge:   -1,
 		HttpOnly: true,
 		Secure:   secure,
-		SameSite: http.SameSiteLaxMode,
+		SameSite: sameSite(crossSite),
 	}
 }
 
@@ -12,7 +12,7 @@ import (
 
 func TestBuildSessionCookie_Insecure(t *testing.T) {
 	expires := time.Now().Add(24 * time.Hour)
-	c := auth.BuildSessionCookie("sess-123", expires, false)
+	c := auth.BuildSessionCookie("sess-123", expires, false, false)
 
 	assert.Equal(t, auth.CookieName, c.Name)
 	assert.Equal(t, "sess-123", c.Value)
@@ -24,15 +24,27 @@ func TestBuildSessionCookie_Insecure(t *testing.T) {
 
 func TestBuildSessionCookie_Secure(t *testing.T) {
 	expires := time.Now().Add(24 * time.Hour)
-	c := auth.BuildSessionCookie("sess-456", expires, true)
+	c := auth.BuildSessionCookie("sess-456", expires, true, false)
 
 	assert.Equal(t, auth.SecureCookieName, c.Name)
 	assert.True(t, c.Secure)
 	assert.True(t, c.HttpOnly)
 }
 
+func TestBuildSessionCookie_CrossSite(t *testing.T) {
+	expires := time.Now().Add(24 * time.Hour)
+	c := auth.BuildSessionCookie("sess-789", expires, true, true)
+
+	assert.Equal(t, auth.SecureCookieName, c.Name)
+	assert.True(t, c.Secure)
+	assert.Equal(t, http.SameSiteNoneMode, c.SameSite)
+
+	cleared := auth.ClearSessionCookie(true, true)
+	assert.Equal(t, http.SameSiteNoneMode, cleared.SameSite, "the clearing cookie must match the one it replaces")
+}
+
 func TestClearSessionCookie(t *testing.T) {
-	c := auth.ClearSessionCookie(false)
+	c := auth.ClearSessionCookie(false, false)
 
 	assert.Equal(t, auth.CookieName, c.Name)
 	assert.Empty(t, c.Value)
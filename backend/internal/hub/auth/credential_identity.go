@@ -13,6 +13,8 @@ type CredentialIdentity struct {
 	// token may be used (see ChannelService.verifyDelegationWorkerScope); empty
 	// for every other kind.
 	workerID string
+	// readOnly marks a viewer guest; a public viewer is read-only by kind.
+	readOnly bool
 }
 
 type credentialKind uint8
@@ -21,6 +23,8 @@ const (
 	credentialSession credentialKind = iota + 1
 	credentialAPI
 	credentialDelegation
+	credentialPublicViewer
+	credentialGuest
 )
 
 // SessionCredential identifies a cookie-backed user session.
@@ -63,6 +67,28 @@ func DelegationCredential(tokenID, workspaceID, workerID string) CredentialIdent
 	return CredentialIdentity{kind: credentialDelegation, id: tokenID, workspaceID: workspaceID, workerID: workerID}
 }
 
+// PublicViewerCredential identifies an anonymous read-only viewer of a
+// workspace the hub exposes publicly. It names no stored row: the viewer
+// acts as the workspace owner, pinned to workspaceID like a delegation
+// bearer, and is limited to publicViewerProcedures.
+func PublicViewerCredential(workspaceID string) CredentialIdentity {
+	if workspaceID == "" {
+		panic("auth: public viewer credential requires a workspace ID")
+	}
+	return CredentialIdentity{kind: credentialPublicViewer, workspaceID: workspaceID}
+}
+
+// GuestCredential identifies a guest_invitations bearer row. The guest acts
+// as the owner who created the link, pinned to workspaceID; a read-only
+// (viewer) guest is limited like a public viewer, and a driver like a
+// delegation bearer.
+func GuestCredential(invitationID, workspaceID string, readOnly bool) CredentialIdentity {
+	if invitationID == "" || workspaceID == "" {
+		panic("auth: guest credential requires invitation and workspace IDs")
+	}
+	return CredentialIdentity{kind: credentialGuest, id: invitationID, workspaceID: workspaceID, readOnly: readOnly}
+}
+
 // WorkerScopeID returns the worker that minted a delegation credential, or an
 // empty string for other kinds.
 //
@@ -138,25 +164,51 @@ func (c CredentialIdentity) Bearer() (BearerKind, string, bool) {
 		return BearerKindAPI, c.id, true
 	case credentialDelegation:
 		return BearerKindDelegation, c.id, true
+	case credentialGuest:
+		return BearerKindGuest, c.id, true
 	default:
 		return 0, "", false
 	}
 }
 
-// WorkspaceScopeID returns the delegation workspace scope, if any.
+// WorkspaceScopeID returns the workspace a delegation bearer, guest, or
+// public viewer is pinned to, if any.
 func (c CredentialIdentity) WorkspaceScopeID() string {
 	return c.workspaceID
 }
 
 // IsDelegation reports whether this identity is a workspace-scoped delegation
-// bearer. Equivalent to WorkspaceScopeID() != "" -- a delegation credential
-// always carries a workspace scope and no other kind ever does -- but names the
-// intent so call sites stop re-encoding "is a delegation token" as an emptiness
-// check on the scope string.
+// bearer. A delegation credential always carries a workspace scope, but so
+// does a public viewer; use IsWorkspaceScoped for the pin and this for what
+// is specific to the bearer.
 func (c CredentialIdentity) IsDelegation() bool {
 	return c.kind == credentialDelegation
 }
 
+// IsPublicViewer reports whether this identity is an anonymous read-only
+// viewer of a public workspace.
+func (c CredentialIdentity) IsPublicViewer() bool {
+	return c.kind == credentialPublicViewer
+}
+
+// IsGuest reports whether this identity is a guest link bearer.
+func (c CredentialIdentity) IsGuest() bool {
+	return c.kind == credentialGuest
+}
+
+// IsReadOnly reports whether channels opened with this identity are
+// read-only: a public viewer always, a guest when its role is viewer.
+func (c CredentialIdentity) IsReadOnly() bool {
+	return c.kind == credentialPublicViewer || c.kind == credentialGuest && c.readOnly
+}
+
+// IsWorkspaceScoped reports whether this identity is pinned to the single
+// workspace WorkspaceScopeID names. Equivalent to WorkspaceScopeID() != "",
+// but names the intent at call sites that narrow what the caller may see.
+func (c CredentialIdentity) IsWorkspaceScoped() bool {
+	return c.kind == credentialDelegation || c.kind == credentialPublicViewer || c.kind == credentialGuest
+}
+
 // Matches reports whether both values identify the same credential row and
 // delegation scope.
 func (c CredentialIdentity) Matches(other CredentialIdentity) bool {
@@ -168,8 +220,8 @@ func (c CredentialIdentity) MatchesSession(sessionID string) bool {
 	return sessionID != "" && c.kind == credentialSession && c.id == sessionID
 }
 
-// PrincipalKey returns a stable CRDT actor key for this credential. Both
-// bearer kinds share one key format sourced from Bearer(), so a new bearer
+// PrincipalKey returns a stable CRDT actor key for this credential. Every
+// bearer kind shares one key format sourced from Bearer(), so a new bearer
 // kind needs no new arm here.
 func (c CredentialIdentity) PrincipalKey() string {
 	if c.kind == credentialSession {
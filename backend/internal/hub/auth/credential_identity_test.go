@@ -35,6 +35,17 @@ func TestCredentialIdentityKindsAreMutuallyExclusive(t *testing.T) {
 	assert.Equal(t, "workspace-1", delegation.WorkspaceScopeID())
 	assert.Equal(t, "worker-mint", delegation.WorkerScopeID())
 	assert.Empty(t, delegation.SessionID())
+
+	guest := GuestCredential("guest-1", "workspace-1", true)
+	kind, tokenID, bearer = guest.Bearer()
+	require.True(t, bearer)
+	assert.Equal(t, BearerKindGuest, kind)
+	assert.Equal(t, "guest-1", tokenID)
+	assert.Equal(t, "workspace-1", guest.WorkspaceScopeID())
+	assert.Empty(t, guest.WorkerScopeID())
+	assert.False(t, guest.IsDelegation())
+	assert.True(t, guest.IsReadOnly())
+	assert.False(t, GuestCredential("guest-1", "workspace-1", false).IsReadOnly())
 }
 
 // The minter bounds where a token may be used, so it must reach the two
@@ -93,6 +104,8 @@ func TestCredentialIdentityRejectsIncompleteStoredCredentials(t *testing.T) {
 	// may be used, and delegation_tokens.worker_id is NOT NULL, so an empty one means
 	// a code path dropped it rather than a data case.
 	assert.Panics(t, func() { DelegationCredential("token", "workspace", "") })
+	assert.Panics(t, func() { GuestCredential("", "workspace", true) })
+	assert.Panics(t, func() { GuestCredential("invitation", "", true) })
 }
 
 func TestCredentialIdentityMatchesWholeIdentity(t *testing.T) {
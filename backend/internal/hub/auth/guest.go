@@ -0,0 +1,27 @@
+package auth
+
+import leapmuxv1 "github.com/leapmux/leapmux/generated/proto/leapmux/v1"
+
+// guestRoleReadOnly maps a stored guest role to whether the guest is
+// read-only. ok is false for a role this hub does not know, which the
+// validator treats as an invalid token rather than guessing at rights.
+func guestRoleReadOnly(role leapmuxv1.GuestRole) (readOnly, ok bool) {
+	switch role {
+	case leapmuxv1.GuestRole_GUEST_ROLE_VIEWER:
+		return true, true
+	case leapmuxv1.GuestRole_GUEST_ROLE_DRIVER:
+		return false, true
+	default:
+		return false, false
+	}
+}
+
+// guestProcedureAllowed reports whether a guest may call procedure. A
+// viewer gets what a public workspace viewer gets and a driver what a
+// delegation bearer gets; neither may manage the workspace or its links.
+func guestProcedureAllowed(c CredentialIdentity, procedure string) bool {
+	if c.IsReadOnly() {
+		return publicViewerProcedures[procedure]
+	}
+	return delegationAllowedProcedures[procedure]
+}
@@ -0,0 +1,149 @@
+package auth_test
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"connectrpc.com/connect"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	leapmuxv1 "github.com/leapmux/leapmux/generated/proto/leapmux/v1"
+	"github.com/leapmux/leapmux/generated/proto/leapmux/v1/leapmuxv1connect"
+	"github.com/leapmux/leapmux/internal/hub/auth"
+	"github.com/leapmux/leapmux/internal/hub/store"
+	"github.com/leapmux/leapmux/internal/util/id"
+	"github.com/leapmux/leapmux/internal/util/userid"
+)
+
+// seedGuestBearer creates a guest link for workspaceID and returns its bearer.
+func seedGuestBearer(t *testing.T, st store.Store, v *auth.TokenValidator, userID, workspaceID string, role leapmuxv1.GuestRole, expiresAt time.Time) (bearer, invitationID string) {
+	t.Helper()
+	invitationID = id.Generate()
+	secret := auth.MintAccessSecret()
+	require.NoError(t, st.GuestInvitations().Create(context.Background(), store.CreateGuestInvitationParams{
+		ID:          invitationID,
+		UserID:      userid.MustNew(userID),
+		WorkspaceID: workspaceID,
+		Role:        role,
+		SecretHash:  v.HashSecret(secret),
+		ExpiresAt:   expiresAt,
+	}))
+	return auth.FormatBearer(auth.BearerKindGuest, invitationID, secret), invitationID
+}
+
+func TestTokenValidator_GuestBearerIsPinnedToItsWorkspace(t *testing.T) {
+	st := newTestStore(t)
+	userID := seedUser(t, st)
+	v, err := auth.NewTokenValidator(st, []byte("0123456789abcdef0123456789abcdef"))
+	require.NoError(t, err)
+	_, workspaceID := seedWorkerAndWorkspace(t, st, userID)
+
+	viewer, viewerID := seedGuestBearer(t, st, v, userID, workspaceID, leapmuxv1.GuestRole_GUEST_ROLE_VIEWER, time.Now().Add(time.Hour))
+	info, err := v.ValidateBearer(context.Background(), viewer)
+	require.NoError(t, err)
+	assert.Equal(t, userID, info.ID.String(), "a guest acts as the owner who shared the link")
+	assert.True(t, info.Credential.IsGuest())
+	assert.True(t, info.Credential.IsReadOnly())
+	assert.True(t, info.Credential.IsWorkspaceScoped())
+	assert.False(t, info.Credential.IsDelegation())
+	assert.Equal(t, workspaceID, info.Credential.WorkspaceScopeID())
+	ref, ok := info.Credential.BearerRef()
+	require.True(t, ok)
+	assert.Equal(t, auth.NewBearerRef(auth.BearerKindGuest, viewerID), ref)
+
+	driver, _ := seedGuestBearer(t, st, v, userID, workspaceID, leapmuxv1.GuestRole_GUEST_ROLE_DRIVER, time.Now().Add(time.Hour))
+	info, err = v.ValidateBearer(context.Background(), driver)
+	require.NoError(t, err)
+	assert.True(t, info.Credential.IsGuest())
+	assert.False(t, info.Credential.IsReadOnly())
+}
+
+func TestTokenValidator_RejectsEndedGuestLinks(t *testing.T) {
+	st := newTestStore(t)
+	userID := seedUser(t, st)
+	v, err := auth.NewTokenValidator(st, []byte("0123456789abcdef0123456789abcdef"))
+	require.NoError(t, err)
+	_, workspaceID := seedWorkerAndWorkspace(t, st, userID)
+
+	expired, _ := seedGuestBearer(t, st, v, userID, workspaceID, leapmuxv1.GuestRole_GUEST_ROLE_DRIVER, time.Now().Add(-time.Minute))
+	_, err = v.ValidateBearer(context.Background(), expired)
+	require.ErrorIs(t, err, auth.ErrTokenExpired)
+
+	revoked, revokedID := seedGuestBearer(t, st, v, userID, workspaceID, leapmuxv1.GuestRole_GUEST_ROLE_DRIVER, time.Now().Add(time.Hour))
+	_, err = st.GuestInvitations().Revoke(context.Background(), revokedID)
+	require.NoError(t, err)
+	_, err = v.ValidateBearer(context.Background(), revoked)
+	require.ErrorIs(t, err, auth.ErrTokenRevoked)
+
+	unknownRole, _ := seedGuestBearer(t, st, v, userID, workspaceID, leapmuxv1.GuestRole_GUEST_ROLE_UNSPECIFIED, time.Now().Add(time.Hour))
+	_, err = v.ValidateBearer(context.Background(), unknownRole)
+	require.ErrorIs(t, err, auth.ErrInvalidToken, "an unknown role must not fall through to driver rights")
+
+	// The owner's logout-everywhere ends their guests too.
+	live, _ := seedGuestBearer(t, st, v, userID, workspaceID, leapmuxv1.GuestRole_GUEST_ROLE_VIEWER, time.Now().Add(time.Hour))
+	_, err = st.Users().RevokeUserTokens(context.Background(), userid.MustNew(userID))
+	require.NoError(t, err)
+	_, err = v.ValidateBearer(context.Background(), live)
+	require.ErrorIs(t, err, auth.ErrTokenRevoked)
+}
+
+func TestInterceptor_GuestProceduresFollowRole(t *testing.T) {
+	st := newTestStore(t)
+	userID := seedUser(t, st)
+	v, err := auth.NewTokenValidator(st, []byte("0123456789abcdef0123456789abcdef"))
+	require.NoError(t, err)
+	_, workspaceID := seedWorkerAndWorkspace(t, st, userID)
+	viewer, _ := seedGuestBearer(t, st, v, userID, workspaceID, leapmuxv1.GuestRole_GUEST_ROLE_VIEWER, time.Now().Add(time.Hour))
+	driver, _ := seedGuestBearer(t, st, v, userID, workspaceID, leapmuxv1.GuestRole_GUEST_ROLE_DRIVER, time.Now().Add(time.Hour))
+
+	interceptor, contexts := auth.NewInterceptorWithTokens(st, nil, v, false, false)
+	t.Cleanup(contexts.Stop)
+	ok := func(context.Context, *connect.Request[leapmuxv1.GetWorkspaceRequest]) (*connect.Response[leapmuxv1.GetWorkspaceResponse], error) {
+		return connect.NewResponse(&leapmuxv1.GetWorkspaceResponse{}), nil
+	}
+	procedures := []string{
+		leapmuxv1connect.WorkspaceServiceGetWorkspaceProcedure,
+		leapmuxv1connect.ChannelServicePrepareWorkspaceAccessProcedure,
+		leapmuxv1connect.OrgCRDTSubmitOpsProcedure,
+		leapmuxv1connect.GuestInvitationServiceCreateGuestInvitationProcedure,
+	}
+	mux := http.NewServeMux()
+	for _, procedure := range procedures {
+		mux.Handle(procedure, connect.NewUnaryHandler(procedure, ok, connect.WithInterceptors(interceptor)))
+	}
+	server := httptest.NewServer(mux)
+	t.Cleanup(server.Close)
+	call := func(bearer, procedure string) error {
+		client := connect.NewClient[leapmuxv1.GetWorkspaceRequest, leapmuxv1.GetWorkspaceResponse](http.DefaultClient, server.URL+procedure)
+		req := connect.NewRequest(&leapmuxv1.GetWorkspaceRequest{})
+		req.Header().Set("Authorization", auth.BearerPrefix+bearer)
+		_, err := client.CallUnary(context.Background(), req)
+		return err
+	}
+
+	for _, tc := range []struct {
+		bearer    string
+		procedure string
+		allowed   bool
+	}{
+		{viewer, leapmuxv1connect.WorkspaceServiceGetWorkspaceProcedure, true},
+		{viewer, leapmuxv1connect.ChannelServicePrepareWorkspaceAccessProcedure, false},
+		{viewer, leapmuxv1connect.OrgCRDTSubmitOpsProcedure, false},
+		{viewer, leapmuxv1connect.GuestInvitationServiceCreateGuestInvitationProcedure, false},
+		{driver, leapmuxv1connect.WorkspaceServiceGetWorkspaceProcedure, true},
+		{driver, leapmuxv1connect.ChannelServicePrepareWorkspaceAccessProcedure, true},
+		{driver, leapmuxv1connect.OrgCRDTSubmitOpsProcedure, true},
+		{driver, leapmuxv1connect.GuestInvitationServiceCreateGuestInvitationProcedure, false},
+	} {
+		err := call(tc.bearer, tc.procedure)
+		if tc.allowed {
+			assert.NoError(t, err, tc.procedure)
+		} else {
+			assert.Equal(t, connect.CodePermissionDenied, connect.CodeOf(err), tc.procedure)
+		}
+	}
+}
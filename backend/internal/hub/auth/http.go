@@ -61,10 +61,15 @@ type HTTPAuthOpts struct {
 	// Cookies lists the secure modes to try, in order. Empty means
 	// "no cookie fallback" (handlers that only accept bearer/solo).
 	Cookies []bool
+	// PublicViewers lets a request with no credential authenticate as the
+	// read-only viewer of the public workspace its PublicWorkspaceQueryParam
+	// names. Requires Store and Contexts.
+	PublicViewers bool
 }
 
 // AuthenticateHTTP resolves the caller of `r` through the standard
-// hub auth ladder: solo override → leapmux bearer → session cookie.
+// hub auth ladder: solo override → leapmux bearer → session cookie →
+// public workspace viewer.
 // Returns the resolved UserInfo or a descriptive error.
 //
 // Each rung is optional: nil SoloUser, nil Validator, or empty
@@ -104,5 +109,15 @@ func AuthenticateHTTP(ctx context.Context, r *http.Request, opts HTTPAuthOpts) (
 		}
 		return user, nil
 	}
+	if workspaceID := r.URL.Query().Get(PublicWorkspaceQueryParam); opts.PublicViewers && workspaceID != "" && opts.Contexts != nil {
+		user, err := authenticatePublicViewer(ctx, opts.Store, opts.Contexts.state, workspaceID)
+		if err != nil {
+			if connect.CodeOf(err) == connect.CodeUnauthenticated {
+				return nil, fmt.Errorf("%w: %w", ErrHTTPUnauthenticated, errNotPublicWorkspace)
+			}
+			return nil, err
+		}
+		return user, nil
+	}
 	return nil, fmt.Errorf("%w: no credentials", ErrHTTPUnauthenticated)
 }
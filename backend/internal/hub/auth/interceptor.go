@@ -115,6 +115,10 @@ type authState struct {
 	// (set once at startup; nil until wired). Kept off revocationMu so a
 	// session touch does not hold the auth lock across the channel-manager lock.
 	channelRescheduler atomic.Pointer[ChannelExpiryRescheduler]
+
+	// publicWorkspaces is the set of workspace ids exposed read-only without
+	// login (set once at startup; nil when the hub exposes none).
+	publicWorkspaces atomic.Pointer[map[string]bool]
 }
 
 func cloneUserInfoWithGeneration(u *UserInfo, gen uint64) *UserInfo {
@@ -668,7 +672,7 @@ func (c *AuthContextRegistry) Stop() {
 
 func (a *authInterceptor) WrapUnary(next connect.UnaryFunc) connect.UnaryFunc {
 	return func(ctx context.Context, req connect.AnyRequest) (connect.AnyResponse, error) {
-		ctx, err := a.authenticate(ctx, req.Spec().Procedure, req.Header().Get("Cookie"), req.Header().Get("Authorization"))
+		ctx, err := a.authenticate(ctx, req.Spec().Procedure, req.Header().Get("Cookie"), req.Header().Get("Authorization"), req.Header().Get(PublicWorkspaceHeader))
 		if err != nil {
 			return nil, err
 		}
@@ -682,7 +686,7 @@ func (a *authInterceptor) WrapStreamingClient(next connect.StreamingClientFunc)
 
 func (a *authInterceptor) WrapStreamingHandler(next connect.StreamingHandlerFunc) connect.StreamingHandlerFunc {
 	return func(ctx context.Context, conn connect.StreamingHandlerConn) error {
-		ctx, err := a.authenticate(ctx, conn.Spec().Procedure, conn.RequestHeader().Get("Cookie"), conn.RequestHeader().Get("Authorization"))
+		ctx, err := a.authenticate(ctx, conn.Spec().Procedure, conn.RequestHeader().Get("Cookie"), conn.RequestHeader().Get("Authorization"), conn.RequestHeader().Get(PublicWorkspaceHeader))
 		if err != nil {
 			return err
 		}
@@ -694,7 +698,9 @@ func (a *authInterceptor) WrapStreamingHandler(next connect.StreamingHandlerFunc
 // Public procedures pass through with optional solo-mode user. Authenticated
 // requests are checked for email verification when required. Bearer tokens
 // (Authorization: Bearer lmx_...) are accepted alongside the cookie path.
-func (a *authInterceptor) authenticate(ctx context.Context, procedure, cookieHeader, authHeader string) (context.Context, error) {
+// A request carrying neither, but naming a public workspace, authenticates
+// as that workspace's read-only viewer.
+func (a *authInterceptor) authenticate(ctx context.Context, procedure, cookieHeader, authHeader, publicWorkspace string) (context.Context, error) {
 	// Solo mode authenticates every procedure -- public or not -- as the
 	// synthetic user and short-circuits the bearer/cookie paths.
 	if a.soloUser != nil {
@@ -712,6 +718,9 @@ func (a *authInterceptor) authenticate(ctx context.Context, procedure, cookieHea
 		if userInfo.Credential.IsDelegation() && !delegationAllowedProcedures[procedure] {
 			return ctx, connect.NewError(connect.CodePermissionDenied, fmt.Errorf("delegation token cannot call this procedure"))
 		}
+		if userInfo.Credential.IsGuest() && !guestProcedureAllowed(userInfo.Credential, procedure) {
+			return ctx, connect.NewError(connect.CodePermissionDenied, fmt.Errorf("guest link cannot call this procedure"))
+		}
 		if err := a.enforceEmailVerification(procedure, userInfo); err != nil {
 			return ctx, err
 		}
@@ -720,6 +729,9 @@ func (a *authInterceptor) authenticate(ctx context.Context, procedure, cookieHea
 
 	token := SessionIDFromHeader(cookieHeader, a.secureCookie)
 	if token == "" {
+		if publicWorkspace != "" {
+			return a.authenticatePublicViewer(ctx, procedure, publicWorkspace)
+		}
 		return ctx, connect.NewError(connect.CodeUnauthenticated, nil)
 	}
 
@@ -738,6 +750,20 @@ func (a *authInterceptor) authenticate(ctx context.Context, procedure, cookieHea
 	return ctx, nil
 }
 
+// authenticatePublicViewer admits an anonymous viewer of a public workspace
+// to the read-only procedures and rejects every other call.
+func (a *authInterceptor) authenticatePublicViewer(ctx context.Context, procedure, workspaceID string) (context.Context, error) {
+	userInfo, err := authenticatePublicViewer(ctx, a.store, a.state, workspaceID)
+	if err != nil {
+		return ctx, err
+	}
+	ctx = WithUser(ctx, userInfo)
+	if !publicViewerProcedures[procedure] {
+		return ctx, connect.NewError(connect.CodePermissionDenied, fmt.Errorf("public workspaces are read-only"))
+	}
+	return ctx, nil
+}
+
 // enforceEmailVerification rejects a request from an unverified, non-admin user
 // unless the procedure is on the pre-verification allowlist. Shared by the
 // bearer and cookie auth paths so the gate cannot drift between them.
@@ -0,0 +1,103 @@
+package auth
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"connectrpc.com/connect"
+
+	leapmuxv1connect "github.com/leapmux/leapmux/generated/proto/leapmux/v1/leapmuxv1connect"
+	"github.com/leapmux/leapmux/internal/hub/store"
+	"github.com/leapmux/leapmux/internal/util/userid"
+)
+
+// PublicWorkspaceHeader names the public workspace an unauthenticated RPC
+// views. Browsers cannot set headers on a WebSocket upgrade, so the
+// WebSocket endpoints read PublicWorkspaceQueryParam instead.
+const (
+	PublicWorkspaceHeader     = "Leapmux-Public-Workspace"
+	PublicWorkspaceQueryParam = "public_workspace"
+)
+
+// publicViewerProcedures lists the RPC procedures an anonymous viewer of a
+// public workspace may call: enough to render the workspace and open a
+// read-only channel to its workers, and nothing that changes state. The
+// worker enforces the read-only half of the channel itself.
+var publicViewerProcedures = map[string]bool{
+	leapmuxv1connect.ChannelServiceGetWorkerHandshakeParamsProcedure: true,
+	leapmuxv1connect.ChannelServiceOpenChannelProcedure:              true,
+	leapmuxv1connect.ChannelServiceCloseChannelProcedure:             true,
+	leapmuxv1connect.WorkspaceServiceListWorkspacesProcedure:         true,
+	leapmuxv1connect.WorkspaceServiceGetWorkspaceProcedure:           true,
+	leapmuxv1connect.WorkspaceServiceListTabsProcedure:               true,
+	leapmuxv1connect.WorkspaceServiceGetTabProcedure:                 true,
+	leapmuxv1connect.OrgCRDTGetMaterializedProcedure:                 true,
+}
+
+// errNotPublicWorkspace is returned for a public-workspace request naming a
+// workspace the hub does not expose. It reads as Unauthenticated, the same as
+// sending no credential at all, so the set of public workspaces cannot be
+// probed.
+var errNotPublicWorkspace = errors.New("not a public workspace")
+
+// SetPublicWorkspaces wires the workspaces the hub exposes read-only without
+// login. Call once at startup before serving requests; an empty list leaves
+// the public viewer path disabled.
+func (c *AuthContextRegistry) SetPublicWorkspaces(workspaceIDs []string) {
+	if c == nil || c.state == nil || len(workspaceIDs) == 0 {
+		return
+	}
+	ids := make(map[string]bool, len(workspaceIDs))
+	for _, id := range workspaceIDs {
+		if id != "" {
+			ids[id] = true
+		}
+	}
+	c.state.publicWorkspaces.Store(&ids)
+}
+
+// isPublicWorkspace reports whether workspaceID is exposed read-only.
+func (s *authState) isPublicWorkspace(workspaceID string) bool {
+	ids := s.publicWorkspaces.Load()
+	return ids != nil && workspaceID != "" && (*ids)[workspaceID]
+}
+
+// authenticatePublicViewer resolves an anonymous viewer of workspaceID. The
+// viewer acts as the workspace owner, so every read path's ownership check
+// holds, and carries a PublicViewerCredential pinning it to the workspace.
+// A deleted workspace or owner fails as though the workspace were never
+// public.
+func authenticatePublicViewer(ctx context.Context, st store.Store, state *authState, workspaceID string) (*UserInfo, error) {
+	if !state.isPublicWorkspace(workspaceID) {
+		return nil, connect.NewError(connect.CodeUnauthenticated, errNotPublicWorkspace)
+	}
+	ws, err := st.Workspaces().GetByID(ctx, workspaceID)
+	if errors.Is(err, store.ErrNotFound) {
+		return nil, connect.NewError(connect.CodeUnauthenticated, errNotPublicWorkspace)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("load public workspace: %w", err)
+	}
+	owner, err := st.Users().GetByID(ctx, ws.OwnerUserID)
+	if errors.Is(err, store.ErrNotFound) {
+		return nil, connect.NewError(connect.CodeUnauthenticated, errNotPublicWorkspace)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("load public workspace owner: %w", err)
+	}
+	id, ok := userid.New(owner.ID)
+	if !ok {
+		return nil, connect.NewError(connect.CodeUnauthenticated, errNotPublicWorkspace)
+	}
+	return state.currentSyntheticUser(&UserInfo{
+		ID:                  id,
+		OrgID:               ws.OrgID,
+		Username:            owner.Username,
+		Credential:          PublicViewerCredential(ws.ID),
+		AuthenticatedAt:     time.Now().UTC(),
+		CredentialExpiresAt: NeverExpires(),
+		UserAuthGeneration:  owner.AuthGeneration,
+	}), nil
+}
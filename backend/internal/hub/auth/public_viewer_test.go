@@ -0,0 +1,153 @@
+package auth_test
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"connectrpc.com/connect"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	leapmuxv1 "github.com/leapmux/leapmux/generated/proto/leapmux/v1"
+	"github.com/leapmux/leapmux/generated/proto/leapmux/v1/leapmuxv1connect"
+	"github.com/leapmux/leapmux/internal/hub/auth"
+	"github.com/leapmux/leapmux/internal/hub/store"
+	hubtestutil "github.com/leapmux/leapmux/internal/hub/testutil"
+	"github.com/leapmux/leapmux/internal/util/id"
+	"github.com/leapmux/leapmux/internal/util/userid"
+)
+
+// createAdminWorkspace creates a workspace owned by the test admin and
+// returns the admin's user id and the workspace id.
+func createAdminWorkspace(t *testing.T, st store.Store) (ownerID, wsID string) {
+	t.Helper()
+	owner, err := st.Users().GetByUsername(context.Background(), "admin")
+	require.NoError(t, err)
+	wsID = id.Generate()
+	require.NoError(t, st.Workspaces().Create(context.Background(), store.CreateWorkspaceParams{
+		ID:          wsID,
+		OrgID:       owner.OrgID,
+		OwnerUserID: userid.MustNew(owner.ID),
+		Title:       "demo",
+	}))
+	return owner.ID, wsID
+}
+
+// setupPublicViewerServer serves one procedure a public viewer may call and
+// one it may not, each recording the authenticated user, behind an
+// interceptor exposing a single public workspace. It returns the server URL,
+// the public workspace and its owner, and a private workspace of the same
+// owner.
+func setupPublicViewerServer(t *testing.T, seen **auth.UserInfo) (url, publicWS, ownerID, privateWS string) {
+	t.Helper()
+	st := hubtestutil.OpenTestStore(t)
+	hubtestutil.CreateTestAdmin(t, st)
+	ownerID, publicWS = createAdminWorkspace(t, st)
+	_, privateWS = createAdminWorkspace(t, st)
+
+	interceptor, contexts := auth.NewInterceptor(st, nil, false, false)
+	contexts.SetPublicWorkspaces([]string{publicWS})
+	record := func(ctx context.Context, _ *connect.Request[leapmuxv1.GetWorkspaceRequest]) (*connect.Response[leapmuxv1.GetWorkspaceResponse], error) {
+		*seen = auth.GetUser(ctx)
+		return connect.NewResponse(&leapmuxv1.GetWorkspaceResponse{}), nil
+	}
+	mux := http.NewServeMux()
+	for _, procedure := range []string{
+		leapmuxv1connect.WorkspaceServiceGetWorkspaceProcedure,
+		leapmuxv1connect.WorkspaceServiceDeleteWorkspaceProcedure,
+	} {
+		mux.Handle(procedure, connect.NewUnaryHandler(procedure, record, connect.WithInterceptors(interceptor)))
+	}
+	server := httptest.NewServer(mux)
+	t.Cleanup(server.Close)
+	return server.URL, publicWS, ownerID, privateWS
+}
+
+func callAsPublicViewer(t *testing.T, url, procedure, workspaceID string) error {
+	t.Helper()
+	client := connect.NewClient[leapmuxv1.GetWorkspaceRequest, leapmuxv1.GetWorkspaceResponse](http.DefaultClient, url+procedure)
+	req := connect.NewRequest(&leapmuxv1.GetWorkspaceRequest{})
+	req.Header().Set(auth.PublicWorkspaceHeader, workspaceID)
+	_, err := client.CallUnary(context.Background(), req)
+	return err
+}
+
+func TestInterceptor_PublicViewer_ReadsAsPinnedOwner(t *testing.T) {
+	var seen *auth.UserInfo
+	url, publicWS, ownerID, _ := setupPublicViewerServer(t, &seen)
+
+	require.NoError(t, callAsPublicViewer(t, url, leapmuxv1connect.WorkspaceServiceGetWorkspaceProcedure, publicWS))
+	require.NotNil(t, seen)
+	assert.Equal(t, ownerID, seen.ID.String())
+	assert.True(t, seen.Credential.IsPublicViewer())
+	assert.True(t, seen.Credential.IsWorkspaceScoped())
+	assert.False(t, seen.Credential.IsDelegation())
+	assert.Equal(t, publicWS, seen.Credential.WorkspaceScopeID())
+	assert.Empty(t, seen.Credential.PrincipalKey(), "a public viewer must not act as a CRDT principal")
+}
+
+func TestInterceptor_PublicViewer_RejectsControlProcedure(t *testing.T) {
+	var seen *auth.UserInfo
+	url, publicWS, _, _ := setupPublicViewerServer(t, &seen)
+
+	err := callAsPublicViewer(t, url, leapmuxv1connect.WorkspaceServiceDeleteWorkspaceProcedure, publicWS)
+	require.Error(t, err)
+	assert.Equal(t, connect.CodePermissionDenied, connect.CodeOf(err))
+	assert.Nil(t, seen, "the handler must not run")
+}
+
+func TestInterceptor_PublicViewer_RejectsUnlistedWorkspace(t *testing.T) {
+	var seen *auth.UserInfo
+	url, _, _, privateWS := setupPublicViewerServer(t, &seen)
+
+	for _, wsID := range []string{privateWS, id.Generate()} {
+		err := callAsPublicViewer(t, url, leapmuxv1connect.WorkspaceServiceGetWorkspaceProcedure, wsID)
+		require.Error(t, err)
+		assert.Equal(t, connect.CodeUnauthenticated, connect.CodeOf(err),
+			"an unlisted workspace must read exactly like no credential")
+	}
+	assert.Nil(t, seen)
+}
+
+func TestInterceptor_PublicViewer_DisabledByDefault(t *testing.T) {
+	st := hubtestutil.OpenTestStore(t)
+	hubtestutil.CreateTestAdmin(t, st)
+	_, wsID := createAdminWorkspace(t, st)
+
+	interceptor, _ := auth.NewInterceptor(st, nil, false, false)
+	procedure := leapmuxv1connect.WorkspaceServiceGetWorkspaceProcedure
+	mux := http.NewServeMux()
+	mux.Handle(procedure, connect.NewUnaryHandler(procedure,
+		func(context.Context, *connect.Request[leapmuxv1.GetWorkspaceRequest]) (*connect.Response[leapmuxv1.GetWorkspaceResponse], error) {
+			return connect.NewResponse(&leapmuxv1.GetWorkspaceResponse{}), nil
+		}, connect.WithInterceptors(interceptor)))
+	server := httptest.NewServer(mux)
+	t.Cleanup(server.Close)
+
+	err := callAsPublicViewer(t, server.URL, procedure, wsID)
+	require.Error(t, err)
+	assert.Equal(t, connect.CodeUnauthenticated, connect.CodeOf(err))
+}
+
+func TestAuthenticateHTTP_PublicViewerOptIn(t *testing.T) {
+	st := hubtestutil.OpenTestStore(t)
+	hubtestutil.CreateTestAdmin(t, st)
+	_, publicWS := createAdminWorkspace(t, st)
+	_, contexts := auth.NewInterceptor(st, nil, false, false)
+	t.Cleanup(contexts.Stop)
+	contexts.SetPublicWorkspaces([]string{publicWS})
+
+	r := httptest.NewRequest(http.MethodGet, "/ws/channel?"+auth.PublicWorkspaceQueryParam+"="+publicWS, nil)
+	opts := auth.HTTPAuthOpts{Store: st, Contexts: contexts, Cookies: []bool{false}}
+
+	_, err := auth.AuthenticateHTTP(context.Background(), r, opts)
+	require.ErrorIs(t, err, auth.ErrHTTPUnauthenticated, "endpoints that do not opt in must ignore the parameter")
+
+	opts.PublicViewers = true
+	user, err := auth.AuthenticateHTTP(context.Background(), r, opts)
+	require.NoError(t, err)
+	assert.True(t, user.Credential.IsPublicViewer())
+	assert.Equal(t, publicWS, user.Credential.WorkspaceScopeID())
+}
@@ -2,9 +2,11 @@ package auth
 
 import (
 	"context"
-	"fmt"
+	"errors"
 
 	"connectrpc.com/connect"
+
+	"github.com/leapmux/leapmux/internal/errcode"
 )
 
 // shutdownInterceptor rejects all RPCs once the shutdown channel is closed.
@@ -32,7 +34,7 @@ func (s *shutdownInterceptor) isShuttingDown() bool {
 func (s *shutdownInterceptor) WrapUnary(next connect.UnaryFunc) connect.UnaryFunc {
 	return func(ctx context.Context, req connect.AnyRequest) (connect.AnyResponse, error) {
 		if s.isShuttingDown() {
-			return nil, connect.NewError(connect.CodeUnavailable, fmt.Errorf("hub is shutting down"))
+			return nil, errcode.New(connect.CodeUnavailable, errcode.HubShuttingDown, errors.New("hub is shutting down"))
 		}
 		return next(ctx, req)
 	}
@@ -45,7 +47,7 @@ func (s *shutdownInterceptor) WrapStreamingClient(next connect.StreamingClientFu
 func (s *shutdownInterceptor) WrapStreamingHandler(next connect.StreamingHandlerFunc) connect.StreamingHandlerFunc {
 	return func(ctx context.Context, conn connect.StreamingHandlerConn) error {
 		if s.isShuttingDown() {
-			return connect.NewError(connect.CodeUnavailable, fmt.Errorf("hub is shutting down"))
+			return errcode.New(connect.CodeUnavailable, errcode.HubShuttingDown, errors.New("hub is shutting down"))
 		}
 		return next(ctx, conn)
 	}
@@ -0,0 +1,64 @@
+package certs
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net"
+	"net/http"
+	"time"
+
+	"golang.org/x/crypto/acme"
+	"golang.org/x/crypto/acme/autocert"
+)
+
+// autocertSource obtains and renews certificates with autocert, which
+// answers TLS-ALPN-01 on the hub's TLS port and, given a listener,
+// HTTP-01 on port 80.
+type autocertSource struct {
+	*autocert.Manager
+	httpListen string
+}
+
+func newAutocertSource(opts Options) *autocertSource {
+	m := &autocert.Manager{
+		Prompt:     autocert.AcceptTOS,
+		Cache:      autocert.DirCache(opts.CacheDir),
+		HostPolicy: autocert.HostWhitelist(opts.Domains...),
+		Email:      opts.Email,
+	}
+	if opts.DirectoryURL != "" {
+		m.Client = &acme.Client{DirectoryURL: opts.DirectoryURL}
+	}
+	return &autocertSource{Manager: m, httpListen: opts.HTTPListen}
+}
+
+// Run serves HTTP-01 challenges until ctx ends; renewal itself runs inside
+// autocert. Without an HTTP listener there is nothing to run.
+func (s *autocertSource) Run(ctx context.Context) error {
+	if s.httpListen == "" {
+		<-ctx.Done()
+		return nil
+	}
+	ln, err := net.Listen("tcp", s.httpListen)
+	if err != nil {
+		return fmt.Errorf("listen for http-01 challenges: %w", err)
+	}
+	srv := &http.Server{
+		// A nil fallback redirects every other request to HTTPS.
+		Handler:           s.HTTPHandler(nil),
+		ReadHeaderTimeout: 10 * time.Second,
+	}
+	go func() {
+		<-ctx.Done()
+		shutdownCtx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		_ = srv.Shutdown(shutdownCtx)
+	}()
+	slog.Info("tls: answering http-01 challenges", "listen", s.httpListen)
+	if err := srv.Serve(ln); err != nil && !errors.Is(err, http.ErrServerClosed) {
+		return fmt.Errorf("serve http-01 challenges: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,115 @@
+// Package certs supplies the certificates the hub terminates TLS with:
+// either a cert/key pair an operator manages, reloaded when the files
+// change, or certificates obtained and renewed from an ACME CA (Let's
+// Encrypt by default) over HTTP-01, TLS-ALPN-01 or DNS-01.
+//
+// Every Source hands out certificates through GetCertificate, so a renewal
+// or reload takes effect on the next handshake without a restart.
+package certs
+
+import (
+	"context"
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"path/filepath"
+	"time"
+
+	"golang.org/x/crypto/acme"
+)
+
+// Challenge types for Options.Challenge.
+const (
+	// ChallengeHTTP01 answers HTTP-01 on Options.HTTPListen and TLS-ALPN-01
+	// on the hub's own TLS port, whichever the CA tries.
+	ChallengeHTTP01 = "http-01"
+	// ChallengeDNS01 publishes a TXT record through a DNSProvider. It is the
+	// only challenge that can issue wildcard names or work for a hub the CA
+	// cannot reach.
+	ChallengeDNS01 = "dns-01"
+)
+
+// Source supplies the hub's TLS certificates.
+type Source interface {
+	// GetCertificate returns the certificate for a handshake; it is
+	// tls.Config.GetCertificate.
+	GetCertificate(hello *tls.ClientHelloInfo) (*tls.Certificate, error)
+	// Run keeps the certificates current until ctx ends.
+	Run(ctx context.Context) error
+}
+
+// Options selects and configures a Source.
+type Options struct {
+	// CertFile and KeyFile name a PEM cert/key pair. Setting them selects
+	// the manual source; the ACME fields must then be empty.
+	CertFile string
+	KeyFile  string
+
+	// Domains are the names to obtain ACME certificates for. Setting them
+	// selects ACME.
+	Domains      []string
+	Email        string
+	DirectoryURL string // Empty means Let's Encrypt production.
+	Challenge    string // See Challenge* constants; empty means ChallengeHTTP01.
+	// CacheDir holds the ACME account key and issued certificates across
+	// restarts, so a restart does not count against the CA's rate limits.
+	CacheDir string
+	// HTTPListen is where HTTP-01 challenges are answered (normally ":80");
+	// other plain-HTTP requests there are redirected to HTTPS. Empty leaves
+	// only TLS-ALPN-01.
+	HTTPListen string
+	// DNSProvider publishes DNS-01 records. Required for ChallengeDNS01.
+	DNSProvider DNSProvider
+	// DNSPropagation is how long to wait after publishing a record before
+	// asking the CA to check it.
+	DNSPropagation time.Duration
+}
+
+// Enabled reports whether opts asks for TLS at all.
+func (o Options) Enabled() bool {
+	return o.CertFile != "" || len(o.Domains) > 0
+}
+
+// New returns the Source opts describes. A manual source loads its pair
+// immediately, so a bad path fails startup rather than the first handshake.
+func New(opts Options) (Source, error) {
+	if opts.CertFile != "" {
+		if len(opts.Domains) > 0 {
+			return nil, errors.New("a manual certificate and ACME domains are mutually exclusive")
+		}
+		return newFileSource(opts.CertFile, opts.KeyFile)
+	}
+	if len(opts.Domains) == 0 {
+		return nil, errors.New("no certificate or ACME domains configured")
+	}
+	if opts.CacheDir == "" {
+		return nil, errors.New("an ACME cache directory is required")
+	}
+	switch opts.Challenge {
+	case "", ChallengeHTTP01:
+		return newAutocertSource(opts), nil
+	case ChallengeDNS01:
+		if opts.DNSProvider == nil {
+			return nil, errors.New("the dns-01 challenge needs a DNS provider")
+		}
+		return newDNSSource(opts)
+	default:
+		return nil, fmt.Errorf("unsupported ACME challenge %q (valid: %s, %s)", opts.Challenge, ChallengeHTTP01, ChallengeDNS01)
+	}
+}
+
+// TLSConfig returns the server TLS configuration for src. It offers HTTP/2
+// (which the worker's Connect stream needs) and the ACME TLS-ALPN protocol,
+// which only the autocert source answers.
+func TLSConfig(src Source) *tls.Config {
+	return &tls.Config{
+		MinVersion:     tls.VersionTLS12,
+		GetCertificate: src.GetCertificate,
+		NextProtos:     []string{"h2", "http/1.1", acme.ALPNProto},
+	}
+}
+
+// CacheDir returns the ACME cache directory under a hub data directory.
+func CacheDir(dataDir string) string {
+	return filepath.Join(dataDir, "acme")
+}
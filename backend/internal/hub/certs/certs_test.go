@@ -0,0 +1,208 @@
+package certs
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"math/big"
+	"os"
+	"path/filepath"
+	"runtime"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"golang.org/x/crypto/acme/autocert"
+)
+
+// selfSigned returns a key and a self-signed certificate for names, valid
+// until notAfter.
+func selfSigned(t *testing.T, notAfter time.Time, names ...string) (*ecdsa.PrivateKey, []byte) {
+	t.Helper()
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+	tmpl := &x509.Certificate{
+		SerialNumber: big.NewInt(time.Now().UnixNano()),
+		Subject:      pkix.Name{CommonName: names[0]},
+		DNSNames:     names,
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     notAfter,
+	}
+	der, err := x509.CreateCertificate(rand.Reader, tmpl, tmpl, &key.PublicKey, key)
+	require.NoError(t, err)
+	return key, der
+}
+
+// writePair writes a PEM cert/key pair for name into dir.
+func writePair(t *testing.T, dir, name string) (certFile, keyFile string) {
+	t.Helper()
+	key, der := selfSigned(t, time.Now().Add(90*24*time.Hour), name)
+	keyDER, err := x509.MarshalECPrivateKey(key)
+	require.NoError(t, err)
+	certFile = filepath.Join(dir, "cert.pem")
+	keyFile = filepath.Join(dir, "key.pem")
+	require.NoError(t, os.WriteFile(certFile, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}), 0o600))
+	require.NoError(t, os.WriteFile(keyFile, pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDER}), 0o600))
+	return certFile, keyFile
+}
+
+func servedName(t *testing.T, src Source) string {
+	t.Helper()
+	cert, err := src.GetCertificate(&tls.ClientHelloInfo{})
+	require.NoError(t, err)
+	leaf, err := x509.ParseCertificate(cert.Certificate[0])
+	require.NoError(t, err)
+	return leaf.Subject.CommonName
+}
+
+func TestNew_SelectsSource(t *testing.T) {
+	dir := t.TempDir()
+	certFile, keyFile := writePair(t, dir, "hub.example.com")
+
+	src, err := New(Options{CertFile: certFile, KeyFile: keyFile})
+	require.NoError(t, err)
+	assert.IsType(t, &fileSource{}, src)
+
+	src, err = New(Options{Domains: []string{"hub.example.com"}, CacheDir: dir})
+	require.NoError(t, err)
+	assert.IsType(t, &autocertSource{}, src)
+
+	src, err = New(Options{Domains: []string{"*.example.com"}, CacheDir: dir, Challenge: ChallengeDNS01, DNSProvider: ExecDNSProvider{Path: "true"}})
+	require.NoError(t, err)
+	assert.IsType(t, &dnsSource{}, src)
+}
+
+func TestNew_Rejects(t *testing.T) {
+	dir := t.TempDir()
+	certFile, keyFile := writePair(t, dir, "hub.example.com")
+
+	cases := map[string]Options{
+		"nothing configured":    {},
+		"manual and acme":       {CertFile: certFile, KeyFile: keyFile, Domains: []string{"hub.example.com"}},
+		"manual without key":    {CertFile: certFile},
+		"missing cert file":     {CertFile: filepath.Join(dir, "missing.pem"), KeyFile: keyFile},
+		"acme without cache":    {Domains: []string{"hub.example.com"}},
+		"dns-01 without hook":   {Domains: []string{"hub.example.com"}, CacheDir: dir, Challenge: ChallengeDNS01},
+		"unknown challenge":     {Domains: []string{"hub.example.com"}, CacheDir: dir, Challenge: "tls-sni-01"},
+		"cert and key mismatch": {CertFile: certFile, KeyFile: certFile},
+	}
+	for name, opts := range cases {
+		t.Run(name, func(t *testing.T) {
+			_, err := New(opts)
+			assert.Error(t, err)
+		})
+	}
+}
+
+func TestFileSource_ReloadsRenewedPair(t *testing.T) {
+	dir := t.TempDir()
+	certFile, keyFile := writePair(t, dir, "old.example.com")
+	src, err := newFileSource(certFile, keyFile)
+	require.NoError(t, err)
+	assert.Equal(t, "old.example.com", servedName(t, src))
+
+	// A half-written renewal keeps the old certificate serving.
+	require.NoError(t, os.WriteFile(certFile, []byte("not pem"), 0o600))
+	src.reloadIfChanged()
+	assert.Equal(t, "old.example.com", servedName(t, src))
+
+	writePair(t, dir, "new.example.com")
+	// Some filesystems keep coarse modification times; make the change visible.
+	future := time.Now().Add(time.Minute)
+	require.NoError(t, os.Chtimes(certFile, future, future))
+	src.reloadIfChanged()
+	assert.Equal(t, "new.example.com", servedName(t, src))
+}
+
+func TestFileSource_RunChecksPeriodically(t *testing.T) {
+	old := fileCheckInterval
+	fileCheckInterval = 10 * time.Millisecond
+	t.Cleanup(func() { fileCheckInterval = old })
+
+	dir := t.TempDir()
+	certFile, keyFile := writePair(t, dir, "old.example.com")
+	src, err := newFileSource(certFile, keyFile)
+	require.NoError(t, err)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error, 1)
+	go func() { done <- src.Run(ctx) }()
+
+	writePair(t, dir, "new.example.com")
+	future := time.Now().Add(time.Minute)
+	require.NoError(t, os.Chtimes(certFile, future, future))
+	assert.Eventually(t, func() bool { return servedName(t, src) == "new.example.com" }, 2*time.Second, 10*time.Millisecond)
+
+	cancel()
+	assert.NoError(t, <-done)
+}
+
+func TestDNSSource_LoadsCachedCertificateAndTracksRenewal(t *testing.T) {
+	dir := t.TempDir()
+	opts := Options{Domains: []string{"*.example.com", "example.com"}, CacheDir: dir, Challenge: ChallengeDNS01, DNSProvider: ExecDNSProvider{Path: "true"}}
+
+	src, err := newDNSSource(opts)
+	require.NoError(t, err)
+	_, err = src.GetCertificate(&tls.ClientHelloInfo{})
+	assert.Error(t, err, "nothing is served before the first issuance")
+	assert.True(t, src.due(time.Now()))
+
+	key, der := selfSigned(t, time.Now().Add(60*24*time.Hour), "*.example.com", "example.com")
+	data, err := encodeCertPEM(key, [][]byte{der})
+	require.NoError(t, err)
+	require.NoError(t, autocert.DirCache(dir).Put(context.Background(), src.certCacheKey(), data))
+
+	src, err = newDNSSource(opts)
+	require.NoError(t, err)
+	assert.Equal(t, "*.example.com", servedName(t, src), "a restart serves the cached certificate")
+	assert.False(t, src.due(time.Now()))
+	assert.True(t, src.due(time.Now().Add(31*24*time.Hour)), "renewal starts 30 days before expiry")
+
+	opts.Domains = append(opts.Domains, "hub.example.net")
+	src, err = newDNSSource(opts)
+	require.NoError(t, err)
+	assert.True(t, src.due(time.Now()), "a newly configured domain forces a new certificate")
+}
+
+func TestChallengeRecordName(t *testing.T) {
+	assert.Equal(t, "_acme-challenge.hub.example.com", challengeRecordName("hub.example.com"))
+	assert.Equal(t, "_acme-challenge.example.com", challengeRecordName("*.example.com"))
+}
+
+func TestExecDNSProvider(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("uses a shell script hook")
+	}
+	dir := t.TempDir()
+	log := filepath.Join(dir, "calls")
+	hook := filepath.Join(dir, "hook.sh")
+	require.NoError(t, os.WriteFile(hook, []byte("#!/bin/sh\necho \"$@\" >> "+log+"\n[ \"$1\" != fail ]\n"), 0o755))
+
+	p := ExecDNSProvider{Path: hook}
+	ctx := context.Background()
+	require.NoError(t, p.Present(ctx, "_acme-challenge.example.com", "token"))
+	require.NoError(t, p.CleanUp(ctx, "_acme-challenge.example.com", "token"))
+	calls, err := os.ReadFile(log)
+	require.NoError(t, err)
+	assert.Equal(t, "present _acme-challenge.example.com token\ncleanup _acme-challenge.example.com token\n", string(calls))
+
+	err = p.run(ctx, "fail", "x", "y")
+	assert.Error(t, err)
+}
+
+func TestTLSConfig_OffersHTTP2AndACME(t *testing.T) {
+	dir := t.TempDir()
+	certFile, keyFile := writePair(t, dir, "hub.example.com")
+	src, err := New(Options{CertFile: certFile, KeyFile: keyFile})
+	require.NoError(t, err)
+
+	cfg := TLSConfig(src)
+	assert.Equal(t, []string{"h2", "http/1.1", "acme-tls/1"}, cfg.NextProtos)
+	assert.Equal(t, uint16(tls.VersionTLS12), cfg.MinVersion)
+}
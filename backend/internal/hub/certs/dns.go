@@ -0,0 +1,325 @@
+package certs
+
+import (
+	"bytes"
+	"context"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"log/slog"
+	"os/exec"
+	"slices"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	"golang.org/x/crypto/acme"
+	"golang.org/x/crypto/acme/autocert"
+)
+
+// DNSProvider publishes the TXT records that prove control of a domain for
+// the DNS-01 challenge. An implementation wraps one DNS host's API; a hub
+// binary plugs its own in with hub.WithDNSProvider, and ExecDNSProvider
+// covers everything else through a script.
+type DNSProvider interface {
+	// Present publishes a TXT record named fqdn with value.
+	Present(ctx context.Context, fqdn, value string) error
+	// CleanUp removes the record Present published.
+	CleanUp(ctx context.Context, fqdn, value string) error
+}
+
+// ExecDNSProvider runs Path as "Path present|cleanup <fqdn> <value>", the
+// hook convention of common ACME clients, so any DNS host with a CLI or an
+// API reachable from a shell script works. A non-zero exit is an error
+// carrying the script's output.
+type ExecDNSProvider struct {
+	Path string
+}
+
+// Present runs the hook's present step.
+func (p ExecDNSProvider) Present(ctx context.Context, fqdn, value string) error {
+	return p.run(ctx, "present", fqdn, value)
+}
+
+// CleanUp runs the hook's cleanup step.
+func (p ExecDNSProvider) CleanUp(ctx context.Context, fqdn, value string) error {
+	return p.run(ctx, "cleanup", fqdn, value)
+}
+
+func (p ExecDNSProvider) run(ctx context.Context, step, fqdn, value string) error {
+	out, err := exec.CommandContext(ctx, p.Path, step, fqdn, value).CombinedOutput()
+	if err != nil {
+		return fmt.Errorf("dns hook %s %s: %w: %s", step, fqdn, err, bytes.TrimSpace(out))
+	}
+	return nil
+}
+
+// renewBefore is how long before expiry the DNS-01 source renews, matching
+// the window Let's Encrypt recommends for its 90-day certificates.
+const renewBefore = 30 * 24 * time.Hour
+
+var (
+	// dnsCheckInterval is how often the DNS-01 source checks whether its
+	// certificate is due; dnsRetryInterval is the wait after a failed
+	// attempt. Vars so tests can shorten them.
+	dnsCheckInterval = 12 * time.Hour
+	dnsRetryInterval = time.Hour
+)
+
+// dnsSource obtains one certificate covering every configured domain over
+// DNS-01, caches it beside autocert's, and renews it ahead of expiry.
+// autocert cannot do this itself: it only speaks HTTP-01 and TLS-ALPN-01.
+type dnsSource struct {
+	opts  Options
+	cache autocert.DirCache
+	cert  atomic.Pointer[tls.Certificate]
+}
+
+func newDNSSource(opts Options) (*dnsSource, error) {
+	s := &dnsSource{opts: opts, cache: autocert.DirCache(opts.CacheDir)}
+	data, err := s.cache.Get(context.Background(), s.certCacheKey())
+	switch {
+	case errors.Is(err, autocert.ErrCacheMiss):
+	case err != nil:
+		return nil, fmt.Errorf("read cached certificate: %w", err)
+	default:
+		cert, parseErr := parseCertPEM(data)
+		if parseErr != nil {
+			slog.Warn("tls: discarding unreadable cached certificate", "error", parseErr)
+		} else {
+			s.cert.Store(cert)
+		}
+	}
+	return s, nil
+}
+
+func (s *dnsSource) GetCertificate(*tls.ClientHelloInfo) (*tls.Certificate, error) {
+	cert := s.cert.Load()
+	if cert == nil {
+		return nil, errors.New("the dns-01 certificate has not been issued yet")
+	}
+	return cert, nil
+}
+
+func (s *dnsSource) Run(ctx context.Context) error {
+	for {
+		wait := dnsCheckInterval
+		if s.due(time.Now()) {
+			if err := s.obtain(ctx); err != nil {
+				if ctx.Err() != nil {
+					return nil
+				}
+				slog.Error("tls: dns-01 issuance failed", "domains", s.opts.Domains, "error", err)
+				wait = dnsRetryInterval
+			}
+		}
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-time.After(wait):
+		}
+	}
+}
+
+// due reports whether the current certificate is missing, expiring within
+// renewBefore, or no longer covers the configured domains.
+func (s *dnsSource) due(now time.Time) bool {
+	cert := s.cert.Load()
+	if cert == nil || cert.Leaf == nil {
+		return true
+	}
+	if now.Add(renewBefore).After(cert.Leaf.NotAfter) {
+		return true
+	}
+	for _, domain := range s.opts.Domains {
+		if !slices.Contains(cert.Leaf.DNSNames, domain) {
+			return true
+		}
+	}
+	return false
+}
+
+// obtain runs one ACME order for every domain and installs the result.
+func (s *dnsSource) obtain(ctx context.Context) error {
+	accountKey, err := s.accountKey(ctx)
+	if err != nil {
+		return err
+	}
+	directory := s.opts.DirectoryURL
+	if directory == "" {
+		directory = autocert.DefaultACMEDirectory
+	}
+	client := &acme.Client{Key: accountKey, DirectoryURL: directory}
+	account := &acme.Account{}
+	if s.opts.Email != "" {
+		account.Contact = []string{"mailto:" + s.opts.Email}
+	}
+	if _, err := client.Register(ctx, account, acme.AcceptTOS); err != nil && !errors.Is(err, acme.ErrAccountAlreadyExists) {
+		return fmt.Errorf("register account: %w", err)
+	}
+
+	order, err := client.AuthorizeOrder(ctx, acme.DomainIDs(s.opts.Domains...))
+	if err != nil {
+		return fmt.Errorf("create order: %w", err)
+	}
+	for _, authzURL := range order.AuthzURLs {
+		if err := s.authorize(ctx, client, authzURL); err != nil {
+			return err
+		}
+	}
+	if order, err = client.WaitOrder(ctx, order.URI); err != nil {
+		return fmt.Errorf("wait for order: %w", err)
+	}
+
+	certKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		return fmt.Errorf("generate certificate key: %w", err)
+	}
+	csr, err := x509.CreateCertificateRequest(rand.Reader, &x509.CertificateRequest{DNSNames: s.opts.Domains}, certKey)
+	if err != nil {
+		return fmt.Errorf("create csr: %w", err)
+	}
+	chain, _, err := client.CreateOrderCert(ctx, order.FinalizeURL, csr, true)
+	if err != nil {
+		return fmt.Errorf("finalize order: %w", err)
+	}
+
+	data, err := encodeCertPEM(certKey, chain)
+	if err != nil {
+		return err
+	}
+	cert, err := parseCertPEM(data)
+	if err != nil {
+		return fmt.Errorf("parse issued certificate: %w", err)
+	}
+	if err := s.cache.Put(ctx, s.certCacheKey(), data); err != nil {
+		// The certificate still serves; only the next restart re-issues.
+		slog.Warn("tls: cache issued certificate", "error", err)
+	}
+	s.cert.Store(cert)
+	slog.Info("tls: issued certificate over dns-01", "domains", s.opts.Domains, "not_after", cert.Leaf.NotAfter)
+	return nil
+}
+
+// authorize completes one authorization over DNS-01, publishing and then
+// removing its TXT record.
+func (s *dnsSource) authorize(ctx context.Context, client *acme.Client, authzURL string) error {
+	authz, err := client.GetAuthorization(ctx, authzURL)
+	if err != nil {
+		return fmt.Errorf("get authorization: %w", err)
+	}
+	if authz.Status == acme.StatusValid {
+		return nil
+	}
+	var chal *acme.Challenge
+	for _, c := range authz.Challenges {
+		if c.Type == ChallengeDNS01 {
+			chal = c
+			break
+		}
+	}
+	if chal == nil {
+		return fmt.Errorf("the CA offered no dns-01 challenge for %s", authz.Identifier.Value)
+	}
+	value, err := client.DNS01ChallengeRecord(chal.Token)
+	if err != nil {
+		return fmt.Errorf("compute dns-01 record: %w", err)
+	}
+	fqdn := challengeRecordName(authz.Identifier.Value)
+	if err := s.opts.DNSProvider.Present(ctx, fqdn, value); err != nil {
+		return fmt.Errorf("publish %s: %w", fqdn, err)
+	}
+	defer func() {
+		// Cleanup must run even when ctx ended mid-challenge.
+		cleanupCtx, cancel := context.WithTimeout(context.WithoutCancel(ctx), time.Minute)
+		defer cancel()
+		if err := s.opts.DNSProvider.CleanUp(cleanupCtx, fqdn, value); err != nil {
+			slog.Warn("tls: remove dns-01 record", "record", fqdn, "error", err)
+		}
+	}()
+
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-time.After(s.opts.DNSPropagation):
+	}
+	if _, err := client.Accept(ctx, chal); err != nil {
+		return fmt.Errorf("accept challenge for %s: %w", authz.Identifier.Value, err)
+	}
+	if _, err := client.WaitAuthorization(ctx, authz.URI); err != nil {
+		return fmt.Errorf("authorize %s: %w", authz.Identifier.Value, err)
+	}
+	return nil
+}
+
+// accountKey loads the DNS-01 source's ACME account key, creating it on
+// first use.
+func (s *dnsSource) accountKey(ctx context.Context) (crypto.Signer, error) {
+	const name = "dns01+account.key"
+	data, err := s.cache.Get(ctx, name)
+	if err == nil {
+		block, _ := pem.Decode(data)
+		if block == nil {
+			return nil, errors.New("cached account key is not PEM")
+		}
+		return x509.ParseECPrivateKey(block.Bytes)
+	}
+	if !errors.Is(err, autocert.ErrCacheMiss) {
+		return nil, fmt.Errorf("read account key: %w", err)
+	}
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		return nil, fmt.Errorf("generate account key: %w", err)
+	}
+	der, err := x509.MarshalECPrivateKey(key)
+	if err != nil {
+		return nil, fmt.Errorf("encode account key: %w", err)
+	}
+	if err := s.cache.Put(ctx, name, pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: der})); err != nil {
+		return nil, fmt.Errorf("store account key: %w", err)
+	}
+	return key, nil
+}
+
+// certCacheKey names the cached certificate after its first domain. A
+// wildcard's "*" is spelled out so the file name is valid everywhere.
+func (s *dnsSource) certCacheKey() string {
+	return "dns01+" + strings.ReplaceAll(s.opts.Domains[0], "*", "_wildcard_")
+}
+
+// challengeRecordName is the TXT record DNS-01 checks for domain. A
+// wildcard is validated on its base name.
+func challengeRecordName(domain string) string {
+	return "_acme-challenge." + strings.TrimPrefix(domain, "*.")
+}
+
+// encodeCertPEM renders a key and its chain in one PEM document, the layout
+// parseCertPEM reads back.
+func encodeCertPEM(key *ecdsa.PrivateKey, chain [][]byte) ([]byte, error) {
+	der, err := x509.MarshalECPrivateKey(key)
+	if err != nil {
+		return nil, fmt.Errorf("encode certificate key: %w", err)
+	}
+	var buf bytes.Buffer
+	_ = pem.Encode(&buf, &pem.Block{Type: "EC PRIVATE KEY", Bytes: der})
+	for _, cert := range chain {
+		_ = pem.Encode(&buf, &pem.Block{Type: "CERTIFICATE", Bytes: cert})
+	}
+	return buf.Bytes(), nil
+}
+
+// parseCertPEM reads a key-plus-chain PEM document; tls.X509KeyPair picks
+// the key and certificate blocks out of the same bytes.
+func parseCertPEM(data []byte) (*tls.Certificate, error) {
+	cert, err := tls.X509KeyPair(data, data)
+	if err != nil {
+		return nil, err
+	}
+	return &cert, nil
+}
@@ -0,0 +1,95 @@
+package certs
+
+import (
+	"context"
+	"crypto/tls"
+	"fmt"
+	"log/slog"
+	"os"
+	"sync/atomic"
+	"time"
+)
+
+// fileCheckInterval is how often the manual source looks for a renewed
+// pair. A var so tests can shorten it.
+var fileCheckInterval = time.Minute
+
+// fileSource serves a cert/key pair from disk and reloads it when either
+// file changes, so a renewal by certbot or a secrets manager needs no
+// restart.
+type fileSource struct {
+	certFile, keyFile string
+	cert              atomic.Pointer[tls.Certificate]
+	// stamp is the pair's modification times as of the last load.
+	stamp [2]time.Time
+}
+
+func newFileSource(certFile, keyFile string) (*fileSource, error) {
+	if keyFile == "" {
+		return nil, fmt.Errorf("a key file is required with certificate %s", certFile)
+	}
+	s := &fileSource{certFile: certFile, keyFile: keyFile}
+	if err := s.load(); err != nil {
+		return nil, err
+	}
+	return s, nil
+}
+
+func (s *fileSource) GetCertificate(*tls.ClientHelloInfo) (*tls.Certificate, error) {
+	return s.cert.Load(), nil
+}
+
+func (s *fileSource) Run(ctx context.Context) error {
+	ticker := time.NewTicker(fileCheckInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-ticker.C:
+			s.reloadIfChanged()
+		}
+	}
+}
+
+// reloadIfChanged reloads the pair when either file's modification time
+// moved. A pair that fails to load keeps the previous certificate serving:
+// a renewal tool that writes the two files one at a time is caught halfway
+// through, and the next check sees both.
+func (s *fileSource) reloadIfChanged() {
+	stamp, err := s.modTimes()
+	if err != nil || stamp == s.stamp {
+		return
+	}
+	if err := s.load(); err != nil {
+		slog.Warn("tls: keeping the current certificate", "cert_file", s.certFile, "error", err)
+		return
+	}
+	slog.Info("tls: reloaded certificate", "cert_file", s.certFile)
+}
+
+func (s *fileSource) load() error {
+	stamp, err := s.modTimes()
+	if err != nil {
+		return err
+	}
+	cert, err := tls.LoadX509KeyPair(s.certFile, s.keyFile)
+	if err != nil {
+		return fmt.Errorf("load certificate: %w", err)
+	}
+	s.cert.Store(&cert)
+	s.stamp = stamp
+	return nil
+}
+
+func (s *fileSource) modTimes() ([2]time.Time, error) {
+	var stamp [2]time.Time
+	for i, path := range []string{s.certFile, s.keyFile} {
+		info, err := os.Stat(path)
+		if err != nil {
+			return stamp, fmt.Errorf("stat %s: %w", path, err)
+		}
+		stamp[i] = info.ModTime()
+	}
+	return stamp, nil
+}
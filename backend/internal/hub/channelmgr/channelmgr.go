@@ -1109,6 +1109,25 @@ func (m *Manager) SendToUser(userID string, msg *leapmuxv1.ChannelMessage) {
 	}
 }
 
+// SendToAllUsers sends a ChannelMessage to every user's WebSocket
+// connections. Used for Hub-wide control frames such as shutdown notices.
+func (m *Manager) SendToAllUsers(msg *leapmuxv1.ChannelMessage) {
+	m.mu.RLock()
+	var senders []SendFunc
+	for _, conns := range m.userSenders {
+		for _, uc := range conns {
+			senders = append(senders, uc.sendFn)
+		}
+	}
+	m.mu.RUnlock()
+
+	for _, sender := range senders {
+		if err := sender(msg); err != nil {
+			slog.Debug("failed to send control frame", "error", err)
+		}
+	}
+}
+
 // Exists returns true if the channel exists.
 func (m *Manager) Exists(channelID string) bool {
 	m.mu.RLock()
@@ -797,6 +797,22 @@ func TestSendToUser_UnknownUser(t *testing.T) {
 	m.SendToUser("nonexistent", msg)
 }
 
+func TestSendToAllUsers(t *testing.T) {
+	m := New()
+
+	received := map[string]int{}
+	for _, c := range []struct{ user, conn string }{{"u1", "conn1"}, {"u1", "conn2"}, {"u2", "conn3"}} {
+		m.BindUser(c.user, c.conn, func(*leapmuxv1.ChannelMessage) error {
+			received[c.conn]++
+			return nil
+		}, nil)
+	}
+
+	m.SendToAllUsers(&leapmuxv1.ChannelMessage{ChannelId: HubControlChannelID, Ciphertext: []byte("ctrl")})
+
+	assert.Equal(t, map[string]int{"conn1": 1, "conn2": 1, "conn3": 1}, received)
+}
+
 // --- Credential- and ACL-scoped close tests ---
 
 // TestCloseByBearer_DropsOnlyMatchingChannels verifies the bearer-keyed
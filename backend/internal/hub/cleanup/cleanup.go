@@ -14,6 +14,7 @@ const (
 	cleanupRetention               = 7 * 24 * time.Hour
 	cleanupJitter                  = 5 * time.Minute
 	maxRevocationCompactionBatches = 100
+	guestRevocationBatch           = 100
 )
 
 // StartLoop starts a background goroutine that periodically hard-deletes
@@ -57,6 +58,11 @@ func run(ctx context.Context, st store.Store) {
 	// Expired delegation tokens (TTL passed without an explicit revoke)
 	// are also worth pruning eagerly since they accumulate one-per-spawn.
 	cleanupStep("expired delegation tokens", func() (int64, error) { return cs.DeleteExpiredDelegationTokensBefore(ctx, now) })
+	// Guest links stop authenticating at their expiry on their own; revoking
+	// them here publishes the revocation event that closes any channel a
+	// guest still holds open and takes the link off the owner's list.
+	cleanupStep("expired guest invitations", func() (int64, error) { return revokeExpiredGuestInvitations(ctx, st, now) })
+	cleanupStep("revoked guest invitations", func() (int64, error) { return cs.DeleteRevokedGuestInvitationsBefore(ctx, cutoff) })
 	cleanupStep("published revocation events", func() (int64, error) {
 		var total int64
 		for range maxRevocationCompactionBatches {
@@ -82,6 +88,29 @@ func run(ctx context.Context, st store.Store) {
 	})
 }
 
+// revokeExpiredGuestInvitations revokes, one at a time so each gets its own
+// revocation event, every live guest link that expired by now.
+func revokeExpiredGuestInvitations(ctx context.Context, st store.Store, now time.Time) (int64, error) {
+	var total int64
+	for ctx.Err() == nil {
+		ids, err := st.GuestInvitations().ListExpiredLiveIDs(ctx, now, guestRevocationBatch)
+		if err != nil {
+			return total, err
+		}
+		for _, id := range ids {
+			n, err := st.GuestInvitations().Revoke(ctx, id)
+			if err != nil {
+				return total, err
+			}
+			total += n
+		}
+		if len(ids) < guestRevocationBatch {
+			break
+		}
+	}
+	return total, nil
+}
+
 func cleanupStep(name string, fn func() (int64, error)) {
 	n, err := fn()
 	if err != nil {
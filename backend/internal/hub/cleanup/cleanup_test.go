@@ -7,10 +7,13 @@ import (
 
 	"github.com/stretchr/testify/require"
 
+	leapmuxv1 "github.com/leapmux/leapmux/generated/proto/leapmux/v1"
 	"github.com/leapmux/leapmux/internal/hub/password"
 	"github.com/leapmux/leapmux/internal/hub/store"
 	"github.com/leapmux/leapmux/internal/hub/store/sqlite"
+	"github.com/leapmux/leapmux/internal/hub/store/storetest"
 	"github.com/leapmux/leapmux/internal/util/id"
+	"github.com/leapmux/leapmux/internal/util/userid"
 )
 
 func setupTestStore(t *testing.T) store.TestableStore {
@@ -118,6 +121,41 @@ func TestRun_RetainsRecentlyDeleted(t *testing.T) {
 	require.NotNil(t, user.DeletedAt)
 }
 
+func TestRun_RevokesExpiredGuestInvitations(t *testing.T) {
+	st := setupTestStore(t)
+	ctx := context.Background()
+	orgID := storetest.SeedOrg(t, st, "guest-org")
+	user := storetest.SeedUser(t, st, orgID, "guest-owner")
+	wsID := storetest.SeedWorkspace(t, st, orgID, user.ID, "WS")
+	create := func(expiresAt time.Time) string {
+		invitationID := id.Generate()
+		require.NoError(t, st.GuestInvitations().Create(ctx, store.CreateGuestInvitationParams{
+			ID:          invitationID,
+			UserID:      userid.MustNew(user.ID),
+			WorkspaceID: wsID,
+			Role:        leapmuxv1.GuestRole_GUEST_ROLE_DRIVER,
+			SecretHash:  []byte("secret"),
+			ExpiresAt:   expiresAt,
+		}))
+		return invitationID
+	}
+	expired := create(time.Now().Add(-time.Minute))
+	live := create(time.Now().Add(time.Hour))
+
+	run(ctx, st)
+
+	row, err := st.GuestInvitations().GetByID(ctx, expired)
+	require.NoError(t, err)
+	require.NotNil(t, row.RevokedAt, "an expired link is revoked")
+	row, err = st.GuestInvitations().GetByID(ctx, live)
+	require.NoError(t, err)
+	require.Nil(t, row.RevokedAt, "a live link is left alone")
+
+	published, err := st.RevocationEvents().PublishPending(ctx, 10)
+	require.NoError(t, err)
+	require.Equal(t, int64(1), published, "the sweep publishes a revocation event so open channels close")
+}
+
 func TestRun_CompactsPublishedRevocationEvents(t *testing.T) {
 	st := setupTestStore(t)
 	spy := &cleanupSpy{CleanupStore: st.Cleanup()}
@@ -30,6 +30,7 @@ const (
 	DefaultAPITimeoutSeconds            = 10
 	DefaultAgentStartupTimeoutSeconds   = 300
 	DefaultWorktreeCreateTimeoutSeconds = 60
+	DefaultShutdownTimeoutSeconds       = 10
 )
 
 // Config holds the hub's runtime configuration.
@@ -39,9 +40,11 @@ type Config struct {
 	PublicURL                    string        `koanf:"public_url"`
 	DataDir                      string        `koanf:"data_dir"`
 	DevFrontend                  string        `koanf:"dev_frontend"`
+	UIDir                        string        `koanf:"ui_dir"`
 	LogLevel                     string        `koanf:"log_level"`
 	SignupEnabled                bool          `koanf:"signup_enabled"`
 	EmailVerificationRequired    bool          `koanf:"email_verification_required"`
+	PublicWorkspaces             string        `koanf:"public_workspaces"` // Comma-separated; see PublicWorkspaceIDs.
 	SmtpHost                     string        `koanf:"smtp_host"`
 	SmtpPort                     int           `koanf:"smtp_port"`
 	SmtpUsername                 string        `koanf:"smtp_username"`
@@ -51,8 +54,23 @@ type Config struct {
 	APITimeoutSeconds            int           `koanf:"api_timeout_seconds"`
 	AgentStartupTimeoutSeconds   int           `koanf:"agent_startup_timeout_seconds"`
 	WorktreeCreateTimeoutSeconds int           `koanf:"worktree_create_timeout_seconds"`
+	ShutdownDrainSeconds         int           `koanf:"shutdown_drain_seconds"`
+	ShutdownTimeoutSeconds       int           `koanf:"shutdown_timeout_seconds"`
 	SecureCookies                bool          `koanf:"secure_cookies"`
+	AllowedOrigins               string        `koanf:"allowed_origins"` // Comma-separated; see AllowedOriginList.
+	GRPCWeb                      bool          `koanf:"grpc_web"`
+	CrossSiteCookies             bool          `koanf:"cross_site_cookies"`
+	TLSCertFile                  string        `koanf:"tls_cert_file"`
+	TLSKeyFile                   string        `koanf:"tls_key_file"`
+	ACMEDomains                  string        `koanf:"acme_domains"` // Comma-separated; see ACMEDomainList.
+	ACMEEmail                    string        `koanf:"acme_email"`
+	ACMEDirectoryURL             string        `koanf:"acme_directory_url"`
+	ACMEChallenge                string        `koanf:"acme_challenge"` // See ACMEChallenge* constants for valid values.
+	ACMEHTTPListen               string        `koanf:"acme_http_listen"`
+	ACMEDNSHook                  string        `koanf:"acme_dns_hook"`
+	ACMEDNSPropagationSeconds    int           `koanf:"acme_dns_propagation_seconds"`
 	EncryptionKeyPath            string        `koanf:"encryption_key_path"`
+	PluginDir                    string        `koanf:"plugin_dir"` // Exec plugins sent hub events (see internal/plugin).
 	Storage                      StorageConfig `koanf:"storage"`
 	SoloMode                     bool
 	DevMode                      bool              // Dev mode: non-solo but with auto-bootstrapped admin
@@ -78,6 +96,24 @@ const (
 // validSmtpTLSModes is the display string for valid smtp_tls_mode values.
 const validSmtpTLSModes = "starttls, implicit, none"
 
+// ACME challenge constants for ACMEChallenge. They match the values
+// internal/hub/certs takes.
+//
+// ACMEChallengeHTTP01 answers HTTP-01 on acme_http_listen and TLS-ALPN-01
+// on the hub's own TLS port. ACMEChallengeDNS01 publishes a TXT record
+// through acme_dns_hook and is the only one that can issue wildcards.
+const (
+	ACMEChallengeHTTP01 = "http-01"
+	ACMEChallengeDNS01  = "dns-01"
+)
+
+// validACMEChallenges is the display string for valid acme_challenge values.
+const validACMEChallenges = "http-01, dns-01"
+
+// DefaultACMEDNSPropagationSeconds is the wait between publishing a DNS-01
+// record and asking the CA to check it.
+const DefaultACMEDNSPropagationSeconds = 30
+
 // StorageType identifies a storage backend.
 type StorageType string
 
@@ -161,6 +197,23 @@ func (c *Config) WorktreeCreateTimeout() time.Duration {
 	return time.Duration(v) * time.Second
 }
 
+// ShutdownDrain returns how long the hub keeps serving after a shutdown
+// signal while /readyz reports it unready, so a load balancer can stop
+// routing to it first. Zero skips the wait.
+func (c *Config) ShutdownDrain() time.Duration {
+	return time.Duration(max(c.ShutdownDrainSeconds, 0)) * time.Second
+}
+
+// ShutdownTimeout returns how long shutdown waits for in-flight requests,
+// both those waiting on a worker and HTTP requests, before closing them.
+func (c *Config) ShutdownTimeout() time.Duration {
+	v := c.ShutdownTimeoutSeconds
+	if v <= 0 {
+		v = DefaultShutdownTimeoutSeconds
+	}
+	return time.Duration(v) * time.Second
+}
+
 // ExtraFlagDef defines a string CLI flag that is not part of the hub's own
 // config but should be parsed alongside it (e.g. worker-specific flags in
 // solo mode).
@@ -273,9 +326,26 @@ func LoadWithOptions(args []string, opts LoadOptions) (*Config, bool, error) {
 		{"public-url", "public_url", "Server options", "public base URL when running behind a reverse proxy (e.g. 'https://hub.example.com')", ptrconv.Ptr(""), nil, nil},
 		{"data-dir", "data_dir", "Server options", "data directory", ptrconv.Ptr("."), nil, nil},
 		{"dev-frontend", "dev_frontend", "Server options", "frontend dev server URL for local development reverse proxy", ptrconv.Ptr(""), nil, nil},
+		{"ui-dir", "ui_dir", "Server options", "serve the web UI from this frontend build directory instead of the embedded one", ptrconv.Ptr(""), nil, nil},
 		{"log-level", "log_level", "Server options", "log level (debug, info, warn, error)", ptrconv.Ptr(defaultLogLevel), nil, nil},
+		{"shutdown-drain-seconds", "shutdown_drain_seconds", "Server options", "seconds to keep serving after a shutdown signal while /readyz reports unready", nil, ptrconv.Ptr(0), nil},
+		{"shutdown-timeout-seconds", "shutdown_timeout_seconds", "Server options", "seconds to wait for in-flight requests during shutdown", nil, ptrconv.Ptr(DefaultShutdownTimeoutSeconds), nil},
+		{"plugin-dir", "plugin_dir", "Server options", "directory of exec plugins to run and send hub events to (empty = none)", ptrconv.Ptr(""), nil, nil},
 		{"signup-enabled", "signup_enabled", "Auth options", "enable user sign-up", nil, nil, ptrconv.Ptr(false)},
 		{"email-verification-required", "email_verification_required", "Auth options", "require email verification on sign-up", nil, nil, ptrconv.Ptr(false)},
+		{"public-workspaces", "public_workspaces", "Auth options", "comma-separated workspace IDs anyone may view read-only without logging in", ptrconv.Ptr(""), nil, nil},
+		{"allowed-origins", "allowed_origins", "Cross-origin options", "comma-separated origins (e.g. 'https://app.example.com') allowed to call the hub from a web UI served elsewhere", ptrconv.Ptr(""), nil, nil},
+		{"grpc-web", "grpc_web", "Cross-origin options", "accept gRPC-Web requests alongside Connect and gRPC", nil, nil, ptrconv.Ptr(true)},
+		{"cross-site-cookies", "cross_site_cookies", "Cross-origin options", "issue the session cookie with SameSite=None so a web UI on another site can use it (requires secure cookies and allowed origins)", nil, nil, ptrconv.Ptr(false)},
+		{"tls-cert-file", "tls_cert_file", "TLS options", "PEM certificate file to serve HTTPS with; reloaded when it changes", ptrconv.Ptr(""), nil, nil},
+		{"tls-key-file", "tls_key_file", "TLS options", "PEM private key file for -tls-cert-file", ptrconv.Ptr(""), nil, nil},
+		{"acme-domains", "acme_domains", "TLS options", "comma-separated domains to obtain certificates for from an ACME CA (Let's Encrypt by default)", ptrconv.Ptr(""), nil, nil},
+		{"acme-email", "acme_email", "TLS options", "contact email for the ACME account", ptrconv.Ptr(""), nil, nil},
+		{"acme-directory-url", "acme_directory_url", "TLS options", "ACME directory URL (default: Let's Encrypt production)", ptrconv.Ptr(""), nil, nil},
+		{"acme-challenge", "acme_challenge", "TLS options", "ACME challenge type (" + validACMEChallenges + ")", ptrconv.Ptr(ACMEChallengeHTTP01), nil, nil},
+		{"acme-http-listen", "acme_http_listen", "TLS options", "address answering http-01 challenges and redirecting to HTTPS; empty leaves only tls-alpn-01", ptrconv.Ptr(":80"), nil, nil},
+		{"acme-dns-hook", "acme_dns_hook", "TLS options", "executable run as 'hook present|cleanup <fqdn> <value>' to publish dns-01 records", ptrconv.Ptr(""), nil, nil},
+		{"acme-dns-propagation-seconds", "acme_dns_propagation_seconds", "TLS options", "seconds to wait for a dns-01 record to propagate", nil, ptrconv.Ptr(DefaultACMEDNSPropagationSeconds), nil},
 		{"smtp-host", "smtp_host", "SMTP options", "SMTP server host", ptrconv.Ptr(""), nil, nil},
 		{"smtp-port", "smtp_port", "SMTP options", "SMTP server port", nil, ptrconv.Ptr(587), nil},
 		{"smtp-username", "smtp_username", "SMTP options", "SMTP username", ptrconv.Ptr(""), nil, nil},
@@ -406,6 +476,12 @@ func LoadWithOptions(args []string, opts LoadOptions) (*Config, bool, error) {
 		return nil, false, fmt.Errorf("public_url is not supported in solo mode")
 	}
 
+	// Solo mode already authenticates every caller as the one user, so a
+	// read-only public view would be the only restriction it ever applied.
+	if cfg.SoloMode && cfg.PublicWorkspaces != "" {
+		return nil, false, fmt.Errorf("public_workspaces is not supported in solo mode")
+	}
+
 	// Populate extra flag values.
 	if len(opts.ExtraFlags) > 0 {
 		cfg.Extras = make(map[string]string, len(opts.ExtraFlags))
@@ -421,6 +497,8 @@ var hubFlagCategoryOrder = []string{
 	"Common options",
 	"Server options",
 	"Auth options",
+	"Cross-origin options",
+	"TLS options",
 	"SMTP options",
 	"Timeout and limit options",
 	"Storage common options",
@@ -448,6 +526,69 @@ func (c *Config) Validate() error {
 		return fmt.Errorf("public_url is not supported in solo mode")
 	}
 
+	// TLS: a manual pair or ACME, never both. Serving HTTPS makes every
+	// cookie Secure and the derived base URL https, so the hub is set up
+	// as if secure_cookies were on.
+	if (c.TLSCertFile == "") != (c.TLSKeyFile == "") {
+		return fmt.Errorf("tls_cert_file and tls_key_file must be set together")
+	}
+	domains := c.ACMEDomainList()
+	if c.TLSCertFile != "" && len(domains) > 0 {
+		return fmt.Errorf("tls_cert_file and acme_domains are mutually exclusive")
+	}
+	if c.ACMEChallenge == "" {
+		c.ACMEChallenge = ACMEChallengeHTTP01
+	}
+	switch c.ACMEChallenge {
+	case ACMEChallengeHTTP01:
+		for _, domain := range domains {
+			if strings.HasPrefix(domain, "*.") {
+				return fmt.Errorf("acme_domains: wildcard %q needs acme_challenge=%s", domain, ACMEChallengeDNS01)
+			}
+		}
+	case ACMEChallengeDNS01:
+	default:
+		return fmt.Errorf("unsupported acme_challenge: %q (valid: %s)", c.ACMEChallenge, validACMEChallenges)
+	}
+	if c.TLSEnabled() {
+		if c.SoloMode {
+			return fmt.Errorf("tls is not supported in solo mode")
+		}
+		if c.Listen == "" {
+			return fmt.Errorf("tls needs a TCP listen address")
+		}
+		c.SecureCookies = true
+	}
+
+	// Origins are compared verbatim against the browser's Origin header, so
+	// each must be exactly scheme://host[:port]. A wildcard is refused: the
+	// session cookie rides along on cross-origin calls, and "any origin with
+	// credentials" is what CORS exists to prevent.
+	origins := c.AllowedOriginList()
+	for _, origin := range origins {
+		if _, err := normalizeOrigin("allowed_origins", origin); err != nil {
+			return err
+		}
+	}
+	if c.SoloMode && len(origins) > 0 {
+		return fmt.Errorf("allowed_origins is not supported in solo mode")
+	}
+	// Browsers drop a SameSite=None cookie that is not also Secure, and with
+	// no allowed origin there is no other site that could send it.
+	if c.CrossSiteCookies {
+		if !c.SecureCookies {
+			return fmt.Errorf("cross_site_cookies requires secure_cookies")
+		}
+		if len(origins) == 0 {
+			return fmt.Errorf("cross_site_cookies requires allowed_origins")
+		}
+	}
+
+	// Both replace the embedded UI, so one would silently shadow the other.
+	if c.DevFrontend != "" && c.UIDir != "" {
+		return fmt.Errorf("dev_frontend and ui_dir are mutually exclusive")
+	}
+
 	// Ensure data dir exists.
 	if err := os.MkdirAll(c.DataDir, 0o750); err != nil {
 		return fmt.Errorf("create data dir: %w", err)
@@ -546,6 +687,59 @@ func isLocalhost(host string) bool {
 	return false
 }
 
+// PublicWorkspaceIDs returns the workspaces exposed read-only without login,
+// parsed from the comma-separated PublicWorkspaces. Blank entries are
+// dropped.
+func (c *Config) PublicWorkspaceIDs() []string {
+	var ids []string
+	for _, id := range strings.Split(c.PublicWorkspaces, ",") {
+		if id = strings.TrimSpace(id); id != "" {
+			ids = append(ids, id)
+		}
+	}
+	return ids
+}
+
+// AllowedOriginList returns the origins allowed to call the hub cross-origin,
+// parsed from the comma-separated AllowedOrigins. A trailing slash is
+// trimmed and blank entries are dropped.
+func (c *Config) AllowedOriginList() []string {
+	var origins []string
+	for _, origin := range strings.Split(c.AllowedOrigins, ",") {
+		if origin = strings.TrimSuffix(strings.TrimSpace(origin), "/"); origin != "" {
+			origins = append(origins, origin)
+		}
+	}
+	return origins
+}
+
+// ACMEDomainList returns the domains to obtain ACME certificates for,
+// parsed from the comma-separated ACMEDomains. Blank entries are dropped.
+func (c *Config) ACMEDomainList() []string {
+	var domains []string
+	for _, domain := range strings.Split(c.ACMEDomains, ",") {
+		if domain = strings.ToLower(strings.TrimSpace(domain)); domain != "" {
+			domains = append(domains, domain)
+		}
+	}
+	return domains
+}
+
+// TLSEnabled reports whether the hub terminates TLS itself, from either a
+// manual certificate or ACME.
+func (c *Config) TLSEnabled() bool {
+	return c.TLSCertFile != "" || len(c.ACMEDomainList()) > 0
+}
+
+// ACMEDNSPropagation returns the dns-01 propagation wait as a duration.
+func (c *Config) ACMEDNSPropagation() time.Duration {
+	v := c.ACMEDNSPropagationSeconds
+	if v <= 0 {
+		v = DefaultACMEDNSPropagationSeconds
+	}
+	return time.Duration(v) * time.Second
+}
+
 // DefaultHubDataDir returns the default hub data directory with ~ expanded.
 func DefaultHubDataDir() string {
 	return internalconfig.ExpandHome(defaultConfigDir)
@@ -604,28 +798,37 @@ func (c *Config) BaseURL() string {
 // rejected — the rest of the codebase concatenates this URL with a leading
 // slash and does not yet support a base path.
 func normalizePublicURL(raw string) (string, error) {
+	return normalizeOrigin("public_url", raw)
+}
+
+// normalizeOrigin is normalizePublicURL for any option holding a bare
+// origin; key names the option in errors.
+func normalizeOrigin(key, raw string) (string, error) {
 	trimmed := strings.TrimSuffix(raw, "/")
 	u, err := url.Parse(trimmed)
 	if err != nil {
-		return "", fmt.Errorf("invalid public_url: %w", err)
+		return "", fmt.Errorf("invalid %s: %w", key, err)
 	}
 	if u.Scheme != "http" && u.Scheme != "https" {
-		return "", fmt.Errorf("invalid public_url: scheme must be http or https, got %q", u.Scheme)
+		return "", fmt.Errorf("invalid %s: scheme must be http or https, got %q", key, u.Scheme)
 	}
 	if u.Hostname() == "" {
-		return "", fmt.Errorf("invalid public_url: host is required")
+		return "", fmt.Errorf("invalid %s: host is required", key)
+	}
+	if strings.ContainsAny(u.Host, "*?[") {
+		return "", fmt.Errorf("invalid %s: pattern characters (*, ?, [) are not allowed", key)
 	}
 	if u.User != nil {
-		return "", fmt.Errorf("invalid public_url: userinfo is not allowed")
+		return "", fmt.Errorf("invalid %s: userinfo is not allowed", key)
 	}
 	if u.Path != "" {
-		return "", fmt.Errorf("invalid public_url: path is not allowed (sub-path deployments are not supported)")
+		return "", fmt.Errorf("invalid %s: path is not allowed (sub-path deployments are not supported)", key)
 	}
 	if u.RawQuery != "" || u.ForceQuery {
-		return "", fmt.Errorf("invalid public_url: query is not allowed")
+		return "", fmt.Errorf("invalid %s: query is not allowed", key)
 	}
 	if u.Fragment != "" {
-		return "", fmt.Errorf("invalid public_url: fragment is not allowed")
+		return "", fmt.Errorf("invalid %s: fragment is not allowed", key)
 	}
 	return trimmed, nil
 }
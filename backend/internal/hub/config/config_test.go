@@ -7,6 +7,7 @@ import (
 	"path/filepath"
 	"strings"
 	"testing"
+	"time"
 
 	"github.com/leapmux/leapmux/internal/util/sqlitedb"
 	"github.com/leapmux/leapmux/internal/util/testutil"
@@ -30,6 +31,18 @@ func TestLoad(t *testing.T) {
 		assert.Equal(t, "info", cfg.LogLevel)
 	})
 
+	t.Run("shutdown timings", func(t *testing.T) {
+		cfg, _, err := Load(nil)
+		require.NoError(t, err)
+		assert.Zero(t, cfg.ShutdownDrain())
+		assert.Equal(t, 10*time.Second, cfg.ShutdownTimeout())
+
+		cfg, _, err = Load([]string{"-shutdown-drain-seconds", "15", "-shutdown-timeout-seconds", "30"})
+		require.NoError(t, err)
+		assert.Equal(t, 15*time.Second, cfg.ShutdownDrain())
+		assert.Equal(t, 30*time.Second, cfg.ShutdownTimeout())
+	})
+
 	t.Run("config file overrides defaults", func(t *testing.T) {
 		tmpDir := t.TempDir()
 		configPath := filepath.Join(tmpDir, "hub.yaml")
@@ -379,6 +392,73 @@ func TestLoadPublicURL(t *testing.T) {
 	})
 }
 
+func TestLoadPublicWorkspaces(t *testing.T) {
+	t.Run("CLI flag parsed into IDs", func(t *testing.T) {
+		cfg, _, err := Load([]string{"-public-workspaces", "ws-a, ws-b,,"})
+		require.NoError(t, err)
+		assert.Equal(t, []string{"ws-a", "ws-b"}, cfg.PublicWorkspaceIDs())
+	})
+
+	t.Run("unset exposes nothing", func(t *testing.T) {
+		cfg, _, err := Load(nil)
+		require.NoError(t, err)
+		assert.Empty(t, cfg.PublicWorkspaceIDs())
+	})
+
+	t.Run("rejected in solo mode", func(t *testing.T) {
+		t.Setenv("LEAPMUX_HUB_PUBLIC_WORKSPACES", "ws-a")
+		_, _, err := LoadWithOptions(nil, LoadOptions{SoloMode: true})
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), "solo mode")
+	})
+}
+
+func TestLoadCrossOrigin(t *testing.T) {
+	t.Run("defaults keep the hub same-origin with gRPC-Web on", func(t *testing.T) {
+		cfg, _, err := Load(nil)
+		require.NoError(t, err)
+		assert.Empty(t, cfg.AllowedOriginList())
+		assert.True(t, cfg.GRPCWeb)
+		assert.False(t, cfg.CrossSiteCookies)
+	})
+
+	t.Run("CLI flags parsed", func(t *testing.T) {
+		cfg, _, err := Load([]string{"-allowed-origins", "https://app.example.com/, http://localhost:3000,,", "-grpc-web=false", "-cross-site-cookies"})
+		require.NoError(t, err)
+		assert.Equal(t, []string{"https://app.example.com", "http://localhost:3000"}, cfg.AllowedOriginList())
+		assert.False(t, cfg.GRPCWeb)
+		assert.True(t, cfg.CrossSiteCookies)
+	})
+}
+
+func TestLoadTLS(t *testing.T) {
+	t.Run("defaults leave TLS off", func(t *testing.T) {
+		cfg, _, err := Load(nil)
+		require.NoError(t, err)
+		assert.False(t, cfg.TLSEnabled())
+		assert.Equal(t, ACMEChallengeHTTP01, cfg.ACMEChallenge)
+		assert.Equal(t, ":80", cfg.ACMEHTTPListen)
+		assert.Equal(t, 30*time.Second, cfg.ACMEDNSPropagation())
+	})
+
+	t.Run("CLI flags parsed", func(t *testing.T) {
+		cfg, _, err := Load([]string{
+			"-acme-domains", "Hub.Example.com, *.example.com,,",
+			"-acme-email", "ops@example.com",
+			"-acme-challenge", "dns-01",
+			"-acme-dns-hook", "/usr/local/bin/dns-hook",
+			"-acme-dns-propagation-seconds", "90",
+		})
+		require.NoError(t, err)
+		assert.True(t, cfg.TLSEnabled())
+		assert.Equal(t, []string{"hub.example.com", "*.example.com"}, cfg.ACMEDomainList())
+		assert.Equal(t, "ops@example.com", cfg.ACMEEmail)
+		assert.Equal(t, ACMEChallengeDNS01, cfg.ACMEChallenge)
+		assert.Equal(t, "/usr/local/bin/dns-hook", cfg.ACMEDNSHook)
+		assert.Equal(t, 90*time.Second, cfg.ACMEDNSPropagation())
+	})
+}
+
 func TestBaseURL(t *testing.T) {
 	t.Run("derived from listen + http when PublicURL empty", func(t *testing.T) {
 		cfg := &Config{Listen: ":4327"}
@@ -461,6 +541,81 @@ func TestValidate(t *testing.T) {
 		assert.Contains(t, err.Error(), "solo mode")
 	})
 
+	t.Run("cross-origin rejection cases", func(t *testing.T) {
+		cases := []struct {
+			name     string
+			cfg      Config
+			contains string
+		}{
+			{"origin with a path", Config{AllowedOrigins: "https://app.example.com/ui"}, "invalid allowed_origins"},
+			{"origin without a scheme", Config{AllowedOrigins: "app.example.com"}, "invalid allowed_origins"},
+			{"wildcard origin", Config{AllowedOrigins: "https://*.example.com"}, "pattern characters"},
+			{"origins in solo mode", Config{SoloMode: true, AllowedOrigins: "https://app.example.com"}, "solo mode"},
+			{"cross-site cookies without TLS", Config{AllowedOrigins: "https://app.example.com", CrossSiteCookies: true}, "requires secure_cookies"},
+			{"cross-site cookies without origins", Config{SecureCookies: true, CrossSiteCookies: true}, "requires allowed_origins"},
+		}
+		for _, tc := range cases {
+			t.Run(tc.name, func(t *testing.T) {
+				cfg := tc.cfg
+				cfg.Listen = ":4327"
+				cfg.DataDir = t.TempDir()
+				err := cfg.Validate()
+				require.Error(t, err)
+				assert.Contains(t, err.Error(), tc.contains)
+			})
+		}
+	})
+
+	t.Run("dev_frontend and ui_dir are mutually exclusive", func(t *testing.T) {
+		cfg := &Config{Listen: ":4327", DataDir: t.TempDir(), DevFrontend: "http://localhost:4328", UIDir: "frontend/.output/public"}
+		err := cfg.Validate()
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), "mutually exclusive")
+	})
+
+	t.Run("tls rejection cases", func(t *testing.T) {
+		cases := []struct {
+			name     string
+			cfg      Config
+			contains string
+		}{
+			{"cert without key", Config{TLSCertFile: "cert.pem"}, "must be set together"},
+			{"key without cert", Config{TLSKeyFile: "key.pem"}, "must be set together"},
+			{"cert and acme", Config{TLSCertFile: "cert.pem", TLSKeyFile: "key.pem", ACMEDomains: "hub.example.com"}, "mutually exclusive"},
+			{"wildcard over http-01", Config{ACMEDomains: "*.example.com"}, "needs acme_challenge=dns-01"},
+			{"unknown challenge", Config{ACMEDomains: "hub.example.com", ACMEChallenge: "tls-sni-01"}, "unsupported acme_challenge"},
+			{"solo mode", Config{SoloMode: true, ACMEDomains: "hub.example.com"}, "solo mode"},
+		}
+		for _, tc := range cases {
+			t.Run(tc.name, func(t *testing.T) {
+				cfg := tc.cfg
+				cfg.Listen = ":4327"
+				cfg.DataDir = t.TempDir()
+				err := cfg.Validate()
+				require.Error(t, err)
+				assert.Contains(t, err.Error(), tc.contains)
+			})
+		}
+	})
+
+	t.Run("tls forces secure cookies", func(t *testing.T) {
+		cfg := &Config{Listen: ":443", DataDir: t.TempDir(), ACMEDomains: "hub.example.com"}
+		require.NoError(t, cfg.Validate())
+		assert.True(t, cfg.SecureCookies)
+		assert.Equal(t, ACMEChallengeHTTP01, cfg.ACMEChallenge)
+	})
+
+	t.Run("cross-site cookies with TLS and origins are accepted", func(t *testing.T) {
+		cfg := &Config{
+			Listen:           ":4327",
+			DataDir:          t.TempDir(),
+			SecureCookies:    true,
+			AllowedOrigins:   "https://app.example.com",
+			CrossSiteCookies: true,
+		}
+		require.NoError(t, cfg.Validate())
+	})
+
 	t.Run("empty SmtpTLSMode is normalized to starttls", func(t *testing.T) {
 		cfg := &Config{Listen: ":4327", DataDir: t.TempDir()}
 		require.NoError(t, cfg.Validate())
@@ -5,4 +5,8 @@ const (
 	// frontend constant so layout ops that exceed the cap are
 	// rejected uniformly on both sides.
 	MaxGridDimension uint32 = 20
+
+	// MaxTabGroupLabelLength caps a tab group label, in runes. Mirrors
+	// the frontend constant.
+	MaxTabGroupLabelLength = 64
 )
@@ -290,6 +290,16 @@ func applySetTabRegister(state *leapmuxv1.OrgCrdtState, op *leapmuxv1.SetTabRegi
 		setLWWInt32(&rec.FileViewMode, hlc, field.FileViewMode)
 	case *leapmuxv1.SetTabRegisterOp_FileDiffBase:
 		setLWWString(&rec.FileDiffBase, hlc, field.FileDiffBase)
+	case *leapmuxv1.SetTabRegisterOp_GroupLabel:
+		setLWWString(&rec.GroupLabel, hlc, field.GroupLabel)
+	case *leapmuxv1.SetTabRegisterOp_GroupColor:
+		setLWWString(&rec.GroupColor, hlc, field.GroupColor)
+	case *leapmuxv1.SetTabRegisterOp_GroupCollapsed:
+		var collapsed int32
+		if field.GroupCollapsed {
+			collapsed = 1
+		}
+		setLWWInt32(&rec.GroupCollapsed, hlc, collapsed)
 	}
 }
 
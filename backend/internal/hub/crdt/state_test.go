@@ -229,3 +229,31 @@ func TestClock_ObserveAdvancesPast(t *testing.T) {
 	assert.Equal(t, int64(500), next.GetPhysical())
 	assert.Equal(t, int64(8), next.GetLogical())
 }
+
+func TestApply_TabGroupRegisters(t *testing.T) {
+	state := crdt.NewState("org")
+	tabOp := func(field *leapmuxv1.SetTabRegisterOp) *leapmuxv1.SetTabRegisterOp {
+		field.TabType = leapmuxv1.TabType_TAB_TYPE_AGENT
+		field.TabId = "t1"
+		return field
+	}
+	crdt.Apply(state, stamped(tabOp(&leapmuxv1.SetTabRegisterOp{
+		Field: &leapmuxv1.SetTabRegisterOp_GroupLabel{GroupLabel: "Backend"},
+	}), hlcAt(10, 0, "a")))
+	crdt.Apply(state, stamped(tabOp(&leapmuxv1.SetTabRegisterOp{
+		Field: &leapmuxv1.SetTabRegisterOp_GroupColor{GroupColor: "#3366ff"},
+	}), hlcAt(11, 0, "a")))
+	crdt.Apply(state, stamped(tabOp(&leapmuxv1.SetTabRegisterOp{
+		Field: &leapmuxv1.SetTabRegisterOp_GroupCollapsed{GroupCollapsed: true},
+	}), hlcAt(12, 0, "a")))
+
+	rec := state.Tabs["t1"]
+	assert.Equal(t, "Backend", rec.GetGroupLabel().GetValue())
+	assert.Equal(t, "#3366ff", rec.GetGroupColor().GetValue())
+	assert.Equal(t, int32(1), rec.GetGroupCollapsed().GetValue())
+
+	crdt.Apply(state, stamped(tabOp(&leapmuxv1.SetTabRegisterOp{
+		Field: &leapmuxv1.SetTabRegisterOp_GroupCollapsed{GroupCollapsed: false},
+	}), hlcAt(13, 0, "b")))
+	assert.Equal(t, int32(0), rec.GetGroupCollapsed().GetValue())
+}
@@ -3,6 +3,7 @@ package crdt
 import (
 	"context"
 	"math"
+	"unicode/utf8"
 
 	leapmuxv1 "github.com/leapmux/leapmux/generated/proto/leapmux/v1"
 )
@@ -366,7 +367,8 @@ func validateSetOnce(pre *leapmuxv1.OrgCrdtState, op *leapmuxv1.OrgOp, internal
 	return leapmuxv1.BatchRejectionReason_BATCH_REJECTION_UNSPECIFIED, ""
 }
 
-// valueDomainCheck rejects NaN/±∞/out-of-range values.
+// valueDomainCheck rejects NaN/±∞/out-of-range values, over-long tab
+// group labels, and malformed tab group colors.
 func valueDomainCheck(op *leapmuxv1.OrgOp) (leapmuxv1.BatchRejectionReason, string) {
 	switch body := op.GetBody().(type) {
 	case *leapmuxv1.OrgOp_SetNodeRegister:
@@ -393,6 +395,18 @@ func valueDomainCheck(op *leapmuxv1.OrgOp) (leapmuxv1.BatchRejectionReason, stri
 				return leapmuxv1.BatchRejectionReason_BATCH_REJECTION_VALUE_DOMAIN, op.GetOpId()
 			}
 		}
+	case *leapmuxv1.OrgOp_SetTabRegister:
+		setOp := body.SetTabRegister
+		switch field := setOp.GetField().(type) {
+		case *leapmuxv1.SetTabRegisterOp_GroupLabel:
+			if utf8.RuneCountInString(field.GroupLabel) > MaxTabGroupLabelLength {
+				return leapmuxv1.BatchRejectionReason_BATCH_REJECTION_VALUE_DOMAIN, op.GetOpId()
+			}
+		case *leapmuxv1.SetTabRegisterOp_GroupColor:
+			if !validGroupColor(field.GroupColor) {
+				return leapmuxv1.BatchRejectionReason_BATCH_REJECTION_VALUE_DOMAIN, op.GetOpId()
+			}
+		}
 	case *leapmuxv1.OrgOp_SetFloatingWindowRegister:
 		setOp := body.SetFloatingWindowRegister
 		switch field := setOp.GetField().(type) {
@@ -421,6 +435,24 @@ func valueDomainCheck(op *leapmuxv1.OrgOp) (leapmuxv1.BatchRejectionReason, stri
 	return leapmuxv1.BatchRejectionReason_BATCH_REJECTION_UNSPECIFIED, ""
 }
 
+// validGroupColor accepts "" (the default color) or a "#rrggbb" hex color.
+func validGroupColor(c string) bool {
+	if c == "" {
+		return true
+	}
+	if len(c) != 7 || c[0] != '#' {
+		return false
+	}
+	for i := 1; i < len(c); i++ {
+		switch ch := c[i]; {
+		case ch >= '0' && ch <= '9', ch >= 'a' && ch <= 'f', ch >= 'A' && ch <= 'F':
+		default:
+			return false
+		}
+	}
+	return true
+}
+
 func finite(v float64) bool {
 	return !math.IsNaN(v) && !math.IsInf(v, 0)
 }
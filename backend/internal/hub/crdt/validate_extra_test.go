@@ -3,6 +3,7 @@ package crdt_test
 import (
 	"context"
 	"math"
+	"strings"
 	"testing"
 
 	"github.com/stretchr/testify/assert"
@@ -388,3 +389,46 @@ func TestValidate_PureDelete_OnlyPreWorkspacePermissionRequired(t *testing.T) {
 	require.Equal(t, leapmuxv1.BatchRejectionReason_BATCH_REJECTION_UNSPECIFIED, res.Reason,
 		"pure-delete should only require pre-workspace write; got %v at %q", res.Reason, res.OffendingOpID)
 }
+
+// TestValidate_ValueDomain_TabGroup covers the tab group label length
+// cap and the "#rrggbb" color format.
+func TestValidate_ValueDomain_TabGroup(t *testing.T) {
+	pre := seedWorkspaceWithRoot("w1", "root1")
+	for i, field := range []*leapmuxv1.SetTabRegisterOp{
+		{Field: &leapmuxv1.SetTabRegisterOp_TileId{TileId: "root1"}},
+		{Field: &leapmuxv1.SetTabRegisterOp_Position{Position: "N"}},
+		{Field: &leapmuxv1.SetTabRegisterOp_WorkerId{WorkerId: "worker-1"}},
+	} {
+		field.TabType = leapmuxv1.TabType_TAB_TYPE_AGENT
+		field.TabId = "t1"
+		crdt.Apply(pre, stamped(field, hlcAt(2, int64(i), "seed")))
+	}
+	cases := []struct {
+		name   string
+		op     *leapmuxv1.SetTabRegisterOp
+		reject bool
+	}{
+		{"label", &leapmuxv1.SetTabRegisterOp{Field: &leapmuxv1.SetTabRegisterOp_GroupLabel{GroupLabel: "Backend"}}, false},
+		{"empty label", &leapmuxv1.SetTabRegisterOp{Field: &leapmuxv1.SetTabRegisterOp_GroupLabel{GroupLabel: ""}}, false},
+		{"label at cap", &leapmuxv1.SetTabRegisterOp{Field: &leapmuxv1.SetTabRegisterOp_GroupLabel{GroupLabel: strings.Repeat("é", crdt.MaxTabGroupLabelLength)}}, false},
+		{"label over cap", &leapmuxv1.SetTabRegisterOp{Field: &leapmuxv1.SetTabRegisterOp_GroupLabel{GroupLabel: strings.Repeat("x", crdt.MaxTabGroupLabelLength+1)}}, true},
+		{"color", &leapmuxv1.SetTabRegisterOp{Field: &leapmuxv1.SetTabRegisterOp_GroupColor{GroupColor: "#A1b2C3"}}, false},
+		{"default color", &leapmuxv1.SetTabRegisterOp{Field: &leapmuxv1.SetTabRegisterOp_GroupColor{GroupColor: ""}}, false},
+		{"named color", &leapmuxv1.SetTabRegisterOp{Field: &leapmuxv1.SetTabRegisterOp_GroupColor{GroupColor: "red"}}, true},
+		{"short color", &leapmuxv1.SetTabRegisterOp{Field: &leapmuxv1.SetTabRegisterOp_GroupColor{GroupColor: "#abc"}}, true},
+		{"non-hex color", &leapmuxv1.SetTabRegisterOp{Field: &leapmuxv1.SetTabRegisterOp_GroupColor{GroupColor: "#12345g"}}, true},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			setOp := tc.op
+			setOp.TabType = leapmuxv1.TabType_TAB_TYPE_AGENT
+			setOp.TabId = "t1"
+			res, _ := crdt.ValidateBatch(context.Background(), pre, []*leapmuxv1.OrgOp{stamped(setOp, hlcAt(10, 0, "a"))}, true, "p1", allowAll{})
+			if tc.reject {
+				assert.Equal(t, leapmuxv1.BatchRejectionReason_BATCH_REJECTION_VALUE_DOMAIN, res.Reason)
+			} else {
+				assert.Equal(t, leapmuxv1.BatchRejectionReason_BATCH_REJECTION_UNSPECIFIED, res.Reason)
+			}
+		})
+	}
+}
@@ -0,0 +1,115 @@
+// Package crossorigin decides which requests from other origins the hub
+// serves: CORS for the configured origins, CSRF checks on every
+// state-changing request, and whether gRPC-Web is accepted at all.
+//
+// Without any allowed origins the hub stays same-origin only -- the web UI
+// it embeds is the one browser client, and a cross-origin page gets no CORS
+// headers and has its state-changing requests refused.
+package crossorigin
+
+import (
+	"fmt"
+	"net/http"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Options configures Handler.
+type Options struct {
+	// AllowedOrigins are the exact origins (scheme://host[:port]) a web UI
+	// may call the hub from, with credentials.
+	AllowedOrigins []string
+	// TrustedOrigins are also exempt from the CSRF check but get no CORS
+	// headers: the hub's own public URL, which a browser behind a proxy
+	// that rewrites Host would otherwise appear to leave.
+	TrustedOrigins []string
+	// GRPCWeb accepts gRPC-Web requests. The Connect handlers speak it
+	// natively, so turning it off is a refusal in front of them.
+	GRPCWeb bool
+}
+
+// Request headers a cross-origin client may send: the Connect, gRPC and
+// gRPC-Web protocol headers plus the hub's own credentials.
+var allowedHeaders = []string{
+	"Authorization",
+	"Content-Type",
+	"Content-Encoding",
+	"Accept-Encoding",
+	"Connect-Protocol-Version",
+	"Connect-Timeout-Ms",
+	"Connect-Content-Encoding",
+	"Connect-Accept-Encoding",
+	"Grpc-Timeout",
+	"Grpc-Accept-Encoding",
+	"Grpc-Encoding",
+	"X-Grpc-Web",
+	"X-User-Agent",
+	"Leapmux-Public-Workspace",
+}
+
+// Response headers a cross-origin client may read. gRPC-Web reports the
+// call's status in these when the response has no body to trail it.
+var exposedHeaders = []string{
+	"Content-Encoding",
+	"Connect-Content-Encoding",
+	"Grpc-Status",
+	"Grpc-Message",
+	"Grpc-Status-Details-Bin",
+	"Grpc-Encoding",
+}
+
+// preflightMaxAge is how long a browser may cache a preflight answer.
+const preflightMaxAge = 2 * time.Hour
+
+// Handler wraps next with the cross-origin policy opts describes. It fails
+// only on an origin net/http's CSRF protection cannot parse, which config
+// validation has already ruled out.
+func Handler(opts Options, next http.Handler) (http.Handler, error) {
+	csrf := http.NewCrossOriginProtection()
+	for _, origin := range slices.Concat(opts.AllowedOrigins, opts.TrustedOrigins) {
+		if err := csrf.AddTrustedOrigin(origin); err != nil {
+			return nil, fmt.Errorf("trust origin %q: %w", origin, err)
+		}
+	}
+	allowed := make(map[string]bool, len(opts.AllowedOrigins))
+	for _, origin := range opts.AllowedOrigins {
+		allowed[origin] = true
+	}
+	protected := csrf.Handler(next)
+
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if !opts.GRPCWeb && isGRPCWeb(r) {
+			http.Error(w, "gRPC-Web is disabled on this hub", http.StatusUnsupportedMediaType)
+			return
+		}
+
+		origin := r.Header.Get("Origin")
+		if origin != "" {
+			w.Header().Add("Vary", "Origin")
+		}
+		if !allowed[origin] {
+			protected.ServeHTTP(w, r)
+			return
+		}
+
+		h := w.Header()
+		h.Set("Access-Control-Allow-Origin", origin)
+		h.Set("Access-Control-Allow-Credentials", "true")
+		if r.Method == http.MethodOptions && r.Header.Get("Access-Control-Request-Method") != "" {
+			h.Set("Access-Control-Allow-Methods", "GET, POST")
+			h.Set("Access-Control-Allow-Headers", strings.Join(allowedHeaders, ", "))
+			h.Set("Access-Control-Max-Age", strconv.Itoa(int(preflightMaxAge.Seconds())))
+			w.WriteHeader(http.StatusNoContent)
+			return
+		}
+		h.Set("Access-Control-Expose-Headers", strings.Join(exposedHeaders, ", "))
+		protected.ServeHTTP(w, r)
+	}), nil
+}
+
+// isGRPCWeb reports whether r uses the gRPC-Web protocol.
+func isGRPCWeb(r *http.Request) bool {
+	return strings.HasPrefix(r.Header.Get("Content-Type"), "application/grpc-web")
+}
@@ -0,0 +1,113 @@
+package crossorigin
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const uiOrigin = "https://app.example.com"
+
+func newHandler(t *testing.T, opts Options) http.Handler {
+	t.Helper()
+	h, err := Handler(opts, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	}))
+	require.NoError(t, err)
+	return h
+}
+
+func serve(h http.Handler, r *http.Request) *httptest.ResponseRecorder {
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, r)
+	return rec
+}
+
+func rpc(origin, site string) *http.Request {
+	r := httptest.NewRequest(http.MethodPost, "http://hub.example.com/leapmux.v1.UserService/GetTimeouts", nil)
+	r.Header.Set("Content-Type", "application/json")
+	if origin != "" {
+		r.Header.Set("Origin", origin)
+	}
+	if site != "" {
+		r.Header.Set("Sec-Fetch-Site", site)
+	}
+	return r
+}
+
+func TestHandler_AllowedOriginGetsCORS(t *testing.T) {
+	h := newHandler(t, Options{AllowedOrigins: []string{uiOrigin}})
+
+	rec := serve(h, rpc(uiOrigin, "cross-site"))
+	assert.Equal(t, http.StatusOK, rec.Code)
+	assert.Equal(t, uiOrigin, rec.Header().Get("Access-Control-Allow-Origin"))
+	assert.Equal(t, "true", rec.Header().Get("Access-Control-Allow-Credentials"))
+	assert.Contains(t, rec.Header().Get("Access-Control-Expose-Headers"), "Grpc-Status")
+	assert.Equal(t, "Origin", rec.Header().Get("Vary"))
+}
+
+func TestHandler_Preflight(t *testing.T) {
+	h := newHandler(t, Options{AllowedOrigins: []string{uiOrigin}})
+
+	r := httptest.NewRequest(http.MethodOptions, "http://hub.example.com/leapmux.v1.UserService/GetTimeouts", nil)
+	r.Header.Set("Origin", uiOrigin)
+	r.Header.Set("Access-Control-Request-Method", http.MethodPost)
+	r.Header.Set("Access-Control-Request-Headers", "content-type,connect-protocol-version")
+	rec := serve(h, r)
+
+	assert.Equal(t, http.StatusNoContent, rec.Code)
+	assert.Equal(t, uiOrigin, rec.Header().Get("Access-Control-Allow-Origin"))
+	assert.Contains(t, rec.Header().Get("Access-Control-Allow-Headers"), "Connect-Protocol-Version")
+	assert.Contains(t, rec.Header().Get("Access-Control-Allow-Headers"), "Authorization")
+	assert.NotEmpty(t, rec.Header().Get("Access-Control-Max-Age"))
+}
+
+func TestHandler_RefusesOtherCrossOriginWrites(t *testing.T) {
+	h := newHandler(t, Options{AllowedOrigins: []string{uiOrigin}})
+
+	rec := serve(h, rpc("https://evil.example.net", "cross-site"))
+	assert.Equal(t, http.StatusForbidden, rec.Code, "a page on another site must not ride the session cookie")
+	assert.Empty(t, rec.Header().Get("Access-Control-Allow-Origin"))
+
+	// The same check applies with no allowed origins at all.
+	rec = serve(newHandler(t, Options{}), rpc(uiOrigin, "cross-site"))
+	assert.Equal(t, http.StatusForbidden, rec.Code)
+}
+
+func TestHandler_SameOriginAndNonBrowserPass(t *testing.T) {
+	h := newHandler(t, Options{})
+
+	assert.Equal(t, http.StatusOK, serve(h, rpc("", "")).Code, "workers and the CLI send no Origin")
+	assert.Equal(t, http.StatusOK, serve(h, rpc("http://hub.example.com", "same-origin")).Code)
+	assert.Empty(t, serve(h, rpc("", "")).Header().Get("Vary"))
+}
+
+func TestHandler_TrustedOriginSkipsCSRFWithoutCORS(t *testing.T) {
+	h := newHandler(t, Options{TrustedOrigins: []string{"https://hub.example.com"}})
+
+	r := rpc("https://hub.example.com", "")
+	r.Host = "127.0.0.1:4327" // a proxy that rewrites Host
+	rec := serve(h, r)
+	assert.Equal(t, http.StatusOK, rec.Code)
+	assert.Empty(t, rec.Header().Get("Access-Control-Allow-Origin"))
+}
+
+func TestHandler_GRPCWebSwitch(t *testing.T) {
+	grpcWeb := func() *http.Request {
+		r := rpc("", "")
+		r.Header.Set("Content-Type", "application/grpc-web+proto")
+		return r
+	}
+
+	assert.Equal(t, http.StatusOK, serve(newHandler(t, Options{GRPCWeb: true}), grpcWeb()).Code)
+	assert.Equal(t, http.StatusUnsupportedMediaType, serve(newHandler(t, Options{}), grpcWeb()).Code)
+	assert.Equal(t, http.StatusOK, serve(newHandler(t, Options{}), rpc("", "")).Code, "Connect requests are unaffected")
+}
+
+func TestHandler_RejectsMalformedOrigin(t *testing.T) {
+	_, err := Handler(Options{AllowedOrigins: []string{"app.example.com"}}, http.NotFoundHandler())
+	assert.Error(t, err)
+}
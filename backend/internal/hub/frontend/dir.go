@@ -0,0 +1,27 @@
+package frontend
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"net/http"
+	"os"
+	"path/filepath"
+
+	"github.com/leapmux/leapmux/spautil"
+)
+
+// DirHandler returns an http.Handler that serves a frontend build from dir
+// on disk instead of the embedded one, so a rebuilt UI is picked up without
+// rebuilding the hub. It serves the same way as Handler, using pre-compressed
+// variants only where the build produced them.
+func DirHandler(dir string) (http.Handler, error) {
+	index := filepath.Join(dir, "index.html")
+	if _, err := os.Stat(index); err != nil {
+		if errors.Is(err, fs.ErrNotExist) {
+			return nil, fmt.Errorf("ui dir %s has no index.html; point it at the frontend build output", dir)
+		}
+		return nil, fmt.Errorf("stat ui dir: %w", err)
+	}
+	return spautil.NewHandler(os.DirFS(dir)), nil
+}
@@ -0,0 +1,51 @@
+package frontend
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestDirHandler_ServesBuildFromDisk(t *testing.T) {
+	dir := t.TempDir()
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "index.html"), []byte("<!doctype html>ui"), 0o644))
+	require.NoError(t, os.MkdirAll(filepath.Join(dir, "_build"), 0o755))
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "_build", "app-abc123.js"), []byte("js"), 0o644))
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "_build", "app-abc123.js.gz"), []byte("gz"), 0o644))
+
+	h, err := DirHandler(dir)
+	require.NoError(t, err)
+
+	get := func(path, acceptEncoding string) *httptest.ResponseRecorder {
+		r := httptest.NewRequest(http.MethodGet, path, nil)
+		if acceptEncoding != "" {
+			r.Header.Set("Accept-Encoding", acceptEncoding)
+		}
+		rec := httptest.NewRecorder()
+		h.ServeHTTP(rec, r)
+		return rec
+	}
+
+	rec := get("/workspaces/abc", "")
+	assert.Equal(t, http.StatusOK, rec.Code, "routes fall back to index.html")
+	assert.Equal(t, "<!doctype html>ui", rec.Body.String())
+	assert.Equal(t, "no-cache", rec.Header().Get("Cache-Control"))
+
+	rec = get("/_build/app-abc123.js", "br, gzip")
+	assert.Equal(t, "gz", rec.Body.String(), "a pre-compressed variant wins when the build has one")
+	assert.Equal(t, "gzip", rec.Header().Get("Content-Encoding"))
+	assert.Contains(t, rec.Header().Get("Cache-Control"), "immutable")
+
+	assert.Equal(t, http.StatusNotFound, get("/_build/missing.js", "").Code)
+}
+
+func TestDirHandler_RequiresIndex(t *testing.T) {
+	_, err := DirHandler(t.TempDir())
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "index.html")
+}
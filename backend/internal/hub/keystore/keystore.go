@@ -49,6 +49,16 @@ func RefreshTokenAAD(userID, providerID string) []byte {
 	return []byte("refresh_token:" + userID + ":" + providerID)
 }
 
+// GitCredentialAAD returns the AAD for an org git credential's secret.
+func GitCredentialAAD(credentialID string) []byte {
+	return []byte("git_credential:" + credentialID)
+}
+
+// ModelCredentialAAD returns the AAD for an org model credential's secret.
+func ModelCredentialAAD(credentialID string) []byte {
+	return []byte("model_credential:" + credentialID)
+}
+
 // Keystore manages a versioned key ring for XChaCha20-Poly1305 envelope
 // encryption plus a dedicated, stable pepper for bearer-token hashing.
 type Keystore struct {
@@ -0,0 +1,129 @@
+package notifier
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	leapmuxv1 "github.com/leapmux/leapmux/generated/proto/leapmux/v1"
+	"github.com/leapmux/leapmux/internal/hub/config"
+	"github.com/leapmux/leapmux/internal/hub/store"
+	"github.com/leapmux/leapmux/internal/hub/store/storetest"
+	hubtestutil "github.com/leapmux/leapmux/internal/hub/testutil"
+	"github.com/leapmux/leapmux/internal/hub/workermgr"
+	"github.com/leapmux/leapmux/internal/hub/workermgr/workerchaos"
+)
+
+// chaosEnv is a notifier whose worker connection is a workerchaos harness
+// the test can swap, as a worker reconnecting would.
+type chaosEnv struct {
+	st      store.Store
+	reg     *fakeRegistry
+	pending *workermgr.PendingRequests
+	n       *Notifier
+	worker  string
+}
+
+func setupChaosEnv(t *testing.T) chaosEnv {
+	t.Helper()
+	st := hubtestutil.OpenTestStore(t)
+	orgID := storetest.SeedOrg(t, st, "chaos-org")
+	owner := storetest.SeedUser(t, st, orgID, "owner")
+	// One second is the shortest timeout the config can express; it bounds
+	// how long a lost frame holds up each test.
+	cfg := &config.Config{APITimeoutSeconds: 1}
+	pending := workermgr.NewPendingRequests(cfg.APITimeout)
+	reg := &fakeRegistry{}
+	return chaosEnv{
+		st:      st,
+		reg:     reg,
+		pending: pending,
+		n:       New(st, reg, pending, cfg),
+		worker:  storetest.SeedWorker(t, st, owner.ID).ID,
+	}
+}
+
+// connect points the registry at a fresh faulty connection.
+func (e chaosEnv) connect(faults workerchaos.Faults) *workerchaos.Harness {
+	h := workerchaos.New(e.worker, e.pending, workerchaos.AckAll, faults)
+	e.reg.conn = h.Conn()
+	return h
+}
+
+func (e chaosEnv) queued(t *testing.T) []store.WorkerNotification {
+	t.Helper()
+	pending, err := e.st.WorkerNotifications().ListPendingByWorker(context.Background(), e.worker)
+	require.NoError(t, err)
+	return pending
+}
+
+// A deregister whose frame is lost must be queued, and the worker's next
+// connection must receive it and complete the deregistration.
+func TestChaos_LostDeregisterIsRedeliveredOnReconnect(t *testing.T) {
+	env := setupChaosEnv(t)
+	ctx := context.Background()
+
+	lossy := env.connect(workerchaos.Faults{DropRate: 1})
+	require.NoError(t, env.n.SendDeregister(ctx, env.worker))
+	assert.Equal(t, 1, lossy.Dropped())
+	require.Len(t, env.queued(t), 1, "an unacknowledged deregister is queued, not forgotten")
+	assert.Empty(t, env.reg.cleared)
+
+	healthy := env.connect(workerchaos.Faults{})
+	require.NoError(t, env.n.ProcessPendingNotifications(ctx, env.worker))
+	require.Len(t, healthy.Delivered(), 1)
+	assert.NotNil(t, healthy.Delivered()[0].GetDeregister())
+	assert.Empty(t, env.queued(t))
+	assert.Equal(t, []string{env.worker}, env.reg.cleared, "the ack completes the deregistration")
+	worker, err := env.st.Workers().GetByIDIncludeDeleted(ctx, env.worker)
+	require.NoError(t, err)
+	assert.NotNil(t, worker.DeletedAt)
+}
+
+// A worker that drops the connection mid-conversation fails the send at
+// once rather than after the timeout, and the notification is queued.
+func TestChaos_DisconnectedWorkerQueuesWithoutWaiting(t *testing.T) {
+	env := setupChaosEnv(t)
+	h := env.connect(workerchaos.Faults{})
+	h.Disconnect()
+
+	require.NoError(t, env.n.SendDeregister(context.Background(), env.worker))
+	assert.Empty(t, h.Delivered())
+	assert.Len(t, env.queued(t), 1)
+}
+
+// A slow link and a duplicated ack still deliver exactly once.
+func TestChaos_SlowDuplicatingLinkDeliversOnce(t *testing.T) {
+	env := setupChaosEnv(t)
+	h := env.connect(workerchaos.Faults{Latency: 50 * time.Millisecond, DuplicateAckRate: 1})
+
+	require.NoError(t, env.n.SendDeregister(context.Background(), env.worker))
+	h.Wait()
+	assert.Len(t, h.Delivered(), 1)
+	assert.Equal(t, 1, h.DuplicateAcks())
+	assert.Empty(t, env.queued(t), "an acknowledged notification is never queued")
+}
+
+// Retrying stops at the notification's attempt budget: a worker that keeps
+// dropping the connection exhausts it and the notification is marked
+// failed instead of being retried on every reconnect forever.
+func TestChaos_RetriesStopAtMaxAttempts(t *testing.T) {
+	env := setupChaosEnv(t)
+	ctx := context.Background()
+	require.NoError(t, env.n.SendOrQueue(ctx, env.worker, leapmuxv1.NotificationType_NOTIFICATION_TYPE_DEREGISTER, "{}", &leapmuxv1.ConnectResponse{}))
+	queued := env.queued(t)
+	require.Len(t, queued, 1, "no connection, so the first send queues")
+
+	for range queued[0].MaxAttempts {
+		env.connect(workerchaos.Faults{}).Disconnect()
+		require.NoError(t, env.n.ProcessPendingNotifications(ctx, env.worker))
+	}
+	assert.Empty(t, env.queued(t), "the exhausted notification is no longer pending")
+
+	healthy := env.connect(workerchaos.Faults{})
+	require.NoError(t, env.n.ProcessPendingNotifications(ctx, env.worker))
+	assert.Empty(t, healthy.Delivered(), "a failed notification is not resurrected")
+}
@@ -821,6 +821,8 @@ func (w *Watcher) applyEvent(event store.PublishedRevocationEvent) {
 		w.applyAPITokenRotationEvent(event.Event)
 	case store.RevocationEventKindDelegationToken:
 		w.applyTokenEvent(auth.BearerKindDelegation, event.Event)
+	case store.RevocationEventKindGuestInvitation:
+		w.applyTokenEvent(auth.BearerKindGuest, event.Event)
 	case store.RevocationEventKindUserTokens:
 		w.applyUserTokensEvent(event.Event)
 	case store.RevocationEventKindUserInfo:
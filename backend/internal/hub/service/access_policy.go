@@ -0,0 +1,207 @@
+package service
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+
+	"connectrpc.com/connect"
+	leapmuxv1 "github.com/leapmux/leapmux/generated/proto/leapmux/v1"
+	"github.com/leapmux/leapmux/internal/hub/auth"
+	"github.com/leapmux/leapmux/internal/hub/store"
+	"github.com/leapmux/leapmux/internal/policy"
+)
+
+// storedAccessPolicy is an org's access_policies row; Rules is stored as
+// the row's JSON rules column. It has a table of its own, unlike the other
+// org settings in the preferences blob, so that no preferences write can
+// undo a policy.
+type storedAccessPolicy struct {
+	Rules []storedAccessPolicyRule `json:"rules,omitempty"`
+}
+
+type storedAccessPolicyRule struct {
+	Name      string                         `json:"name"`
+	Actions   []leapmuxv1.AccessPolicyAction `json:"actions,omitempty"`
+	Condition string                         `json:"condition"`
+	Effect    leapmuxv1.AccessPolicyEffect   `json:"effect"`
+	Message   string                         `json:"message,omitempty"`
+}
+
+// validateAccessPolicy checks p and returns its stored form. Every rule
+// must compile, since a worker drops the ones it cannot and would enforce
+// less than the org wrote.
+func validateAccessPolicy(p *leapmuxv1.AccessPolicy) (*storedAccessPolicy, error) {
+	if _, errs := policy.Compile(p); len(errs) > 0 {
+		return nil, errors.Join(errs...)
+	}
+	stored := &storedAccessPolicy{}
+	for _, r := range p.GetRules() {
+		stored.Rules = append(stored.Rules, storedAccessPolicyRule{
+			Name:      r.GetName(),
+			Actions:   r.GetActions(),
+			Condition: r.GetCondition(),
+			Effect:    r.GetEffect(),
+			Message:   r.GetMessage(),
+		})
+	}
+	return stored, nil
+}
+
+// accessPolicyToProto converts the stored form; nil is the default of no
+// rules.
+func accessPolicyToProto(p *storedAccessPolicy) *leapmuxv1.AccessPolicy {
+	out := &leapmuxv1.AccessPolicy{}
+	if p == nil {
+		return out
+	}
+	for _, r := range p.Rules {
+		out.Rules = append(out.Rules, &leapmuxv1.AccessPolicyRule{
+			Name:      r.Name,
+			Actions:   r.Actions,
+			Condition: r.Condition,
+			Effect:    r.Effect,
+			Message:   r.Message,
+		})
+	}
+	return out
+}
+
+// loadStoredAccessPolicy returns orgID's access policy, or nil if it never
+// set one. Rules that no longer decode are an error rather than no policy:
+// an unreadable policy must not enforce nothing.
+func loadStoredAccessPolicy(ctx context.Context, st store.Store, orgID string) (*storedAccessPolicy, error) {
+	row, err := st.AccessPolicies().Get(ctx, orgID)
+	if errors.Is(err, store.ErrNotFound) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	stored := &storedAccessPolicy{}
+	if err := json.Unmarshal([]byte(row.Rules), &stored.Rules); err != nil {
+		return nil, fmt.Errorf("decode access policy rules: %w", err)
+	}
+	return stored, nil
+}
+
+// loadAccessPolicy returns orgID's compiled access policy.
+func loadAccessPolicy(ctx context.Context, st store.Store, orgID string) (*policy.Policy, error) {
+	stored, err := loadStoredAccessPolicy(ctx, st, orgID)
+	if err != nil {
+		return nil, err
+	}
+	// Stored rules were validated on the way in.
+	p, _ := policy.Compile(accessPolicyToProto(stored))
+	return p, nil
+}
+
+func (s *WorkerManagementService) GetAccessPolicy(
+	ctx context.Context,
+	_ *connect.Request[leapmuxv1.GetAccessPolicyRequest],
+) (*connect.Response[leapmuxv1.GetAccessPolicyResponse], error) {
+	user, err := auth.MustGetUser(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	stored, err := loadStoredAccessPolicy(ctx, s.store, user.OrgID)
+	if err != nil {
+		return nil, connect.NewError(connect.CodeInternal, err)
+	}
+	return connect.NewResponse(&leapmuxv1.GetAccessPolicyResponse{Policy: accessPolicyToProto(stored)}), nil
+}
+
+// UpdateAccessPolicy replaces the org's access policy and pushes it to the
+// org's workers connected to this Hub, the same way
+// UpdateAgentTerminalPolicy does.
+func (s *WorkerManagementService) UpdateAccessPolicy(
+	ctx context.Context,
+	req *connect.Request[leapmuxv1.UpdateAccessPolicyRequest],
+) (*connect.Response[leapmuxv1.UpdateAccessPolicyResponse], error) {
+	user, err := auth.MustGetUser(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	stored, err := validateAccessPolicy(req.Msg.GetPolicy())
+	if err != nil {
+		return nil, connect.NewError(connect.CodeInvalidArgument, err)
+	}
+
+	rules, err := json.Marshal(stored.Rules)
+	if err != nil {
+		return nil, connect.NewError(connect.CodeInternal, fmt.Errorf("marshal access policy: %w", err))
+	}
+	if err := s.store.AccessPolicies().Put(ctx, store.PutAccessPolicyParams{
+		OrgID:     user.OrgID,
+		Rules:     string(rules),
+		UpdatedBy: user.ID.String(),
+	}); err != nil {
+		return nil, connect.NewError(connect.CodeInternal, err)
+	}
+
+	p := accessPolicyToProto(stored)
+	pushToUserWorkers(ctx, s.store, s.workerMgr, user, &leapmuxv1.ConnectResponse{
+		Payload: &leapmuxv1.ConnectResponse_AccessPolicy{AccessPolicy: p},
+	}, "access policy")
+	return connect.NewResponse(&leapmuxv1.UpdateAccessPolicyResponse{Policy: p}), nil
+}
+
+// EvaluateAccessPolicy dry-runs the request's policy, or the org's stored
+// one, over the request's input. The user_id defaults to the caller's.
+func (s *WorkerManagementService) EvaluateAccessPolicy(
+	ctx context.Context,
+	req *connect.Request[leapmuxv1.EvaluateAccessPolicyRequest],
+) (*connect.Response[leapmuxv1.EvaluateAccessPolicyResponse], error) {
+	user, err := auth.MustGetUser(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	in, err := policy.InputFromProto(req.Msg.GetInput())
+	if err != nil {
+		return nil, connect.NewError(connect.CodeInvalidArgument, fmt.Errorf("input: %w", err))
+	}
+	if in.UserID == "" {
+		in.UserID = user.ID.String()
+	}
+
+	var p *policy.Policy
+	if draft := req.Msg.GetPolicy(); draft != nil {
+		compiled, errs := policy.Compile(draft)
+		if len(errs) > 0 {
+			return nil, connect.NewError(connect.CodeInvalidArgument, errors.Join(errs...))
+		}
+		p = compiled
+	} else if p, err = loadAccessPolicy(ctx, s.store, user.OrgID); err != nil {
+		return nil, connect.NewError(connect.CodeInternal, err)
+	}
+	return connect.NewResponse(p.Evaluate(in).ToProto()), nil
+}
+
+// workerSelectionDecision evaluates whether access lets user's workspace
+// wsID be checked out on workerID.
+func workerSelectionDecision(access *policy.Policy, user *auth.UserInfo, wsID, workerID string, labels []string) policy.Decision {
+	if access.Empty() {
+		return policy.Decision{}
+	}
+	return access.Evaluate(policy.Input{
+		Action:       leapmuxv1.AccessPolicyAction_ACCESS_POLICY_ACTION_WORKER_SELECTION,
+		UserID:       user.ID.String(),
+		WorkspaceID:  wsID,
+		WorkerID:     workerID,
+		WorkerLabels: labels,
+	})
+}
+
+// accessPolicyDenied is the error for a request the org's access policy
+// denied.
+func accessPolicyDenied(d policy.Decision) error {
+	msg := fmt.Sprintf("denied by access policy rule %q", d.Rule)
+	if d.Message != "" {
+		msg += ": " + d.Message
+	}
+	return connect.NewError(connect.CodePermissionDenied, errors.New(msg))
+}
@@ -0,0 +1,227 @@
+package service_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"connectrpc.com/connect"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	leapmuxv1 "github.com/leapmux/leapmux/generated/proto/leapmux/v1"
+	"github.com/leapmux/leapmux/internal/hub/auth"
+	"github.com/leapmux/leapmux/internal/hub/config"
+	"github.com/leapmux/leapmux/internal/hub/mail"
+	"github.com/leapmux/leapmux/internal/hub/service"
+	"github.com/leapmux/leapmux/internal/hub/store"
+	"github.com/leapmux/leapmux/internal/hub/store/storetest"
+	"github.com/leapmux/leapmux/internal/hub/testutil"
+	"github.com/leapmux/leapmux/internal/hub/workermgr"
+	"github.com/leapmux/leapmux/internal/util/userid"
+)
+
+func noRmRule() *leapmuxv1.AccessPolicyRule {
+	return &leapmuxv1.AccessPolicyRule{
+		Name:      "no-rm",
+		Actions:   []leapmuxv1.AccessPolicyAction{leapmuxv1.AccessPolicyAction_ACCESS_POLICY_ACTION_TOOL_USE},
+		Condition: `tool == "Bash" && command.startsWith("rm ")`,
+		Effect:    leapmuxv1.AccessPolicyEffect_ACCESS_POLICY_EFFECT_DENY,
+		Message:   "Ask a human first.",
+	}
+}
+
+// policyCaller creates a user and returns it with a context authenticated
+// as it, carrying the org its access policy is stored under.
+func policyCaller(t *testing.T, st store.Store, username string) (userid.UserID, context.Context) {
+	t.Helper()
+	uid := userid.MustNew(testutil.CreateTestUser(t, st, username, "password123"))
+	u, err := st.Users().GetByID(context.Background(), uid.String())
+	require.NoError(t, err)
+	return uid, auth.WithUser(context.Background(), &auth.UserInfo{ID: uid, OrgID: u.OrgID})
+}
+
+func TestAccessPolicy_RoundTripAndPush(t *testing.T) {
+	st := testutil.OpenTestStore(t)
+	uid, ctx := policyCaller(t, st, "policy")
+
+	require.NoError(t, st.Workers().Create(ctx, store.CreateWorkerParams{
+		ID:              "w-online",
+		AuthToken:       "token-w-online",
+		RegisteredBy:    uid,
+		PublicKey:       []byte("test-x25519-key-32-bytes-padding"),
+		MlkemPublicKey:  []byte("mlkem"),
+		SlhdsaPublicKey: []byte("slhdsa"),
+	}))
+	mgr := workermgr.New(service.NewWorkerReachAuthorizer(st))
+	pushed := make(chan *leapmuxv1.ConnectResponse, 4)
+	_, err := mgr.Register(&workermgr.Conn{
+		WorkerID: "w-online",
+		SendFn: func(msg *leapmuxv1.ConnectResponse) error {
+			pushed <- msg
+			return nil
+		},
+	})
+	require.NoError(t, err)
+	svc := service.NewWorkerManagementService(st, mgr, nil, nil, nil, mail.Renderer{}, &config.Config{}, nil)
+
+	got, err := svc.GetAccessPolicy(ctx, connect.NewRequest(&leapmuxv1.GetAccessPolicyRequest{}))
+	require.NoError(t, err)
+	assert.Empty(t, got.Msg.GetPolicy().GetRules(), "unset decides nothing")
+
+	_, err = svc.UpdateAccessPolicy(ctx, connect.NewRequest(&leapmuxv1.UpdateAccessPolicyRequest{
+		Policy: &leapmuxv1.AccessPolicy{Rules: []*leapmuxv1.AccessPolicyRule{noRmRule()}},
+	}))
+	require.NoError(t, err)
+
+	got, err = svc.GetAccessPolicy(ctx, connect.NewRequest(&leapmuxv1.GetAccessPolicyRequest{}))
+	require.NoError(t, err)
+	require.Len(t, got.Msg.GetPolicy().GetRules(), 1)
+	assert.Equal(t, noRmRule().GetCondition(), got.Msg.GetPolicy().GetRules()[0].GetCondition())
+
+	require.Len(t, pushed, 1)
+	msg := <-pushed
+	require.Len(t, msg.GetAccessPolicy().GetRules(), 1)
+	assert.Equal(t, "no-rm", msg.GetAccessPolicy().GetRules()[0].GetName())
+}
+
+func TestUpdatePreferences_KeepsAccessPolicy(t *testing.T) {
+	st := testutil.OpenTestStore(t)
+	uid, ctx := policyCaller(t, st, "keeper")
+	mgmt := service.NewWorkerManagementService(st, workermgr.New(workermgr.DenyAllReach()), nil, nil, nil, mail.Renderer{}, &config.Config{}, nil)
+
+	_, err := mgmt.UpdateAccessPolicy(ctx, connect.NewRequest(&leapmuxv1.UpdateAccessPolicyRequest{
+		Policy: &leapmuxv1.AccessPolicy{Rules: []*leapmuxv1.AccessPolicyRule{noRmRule()}},
+	}))
+	require.NoError(t, err)
+
+	// The policy is not kept in the preferences, so neither a blob that no
+	// longer decodes nor the write that replaces it touches it.
+	require.NoError(t, st.Users().UpdatePrefs(ctx, store.UpdateUserPrefsParams{ID: uid.String(), Prefs: "{not json"}))
+	users := service.NewUserService(st, &config.Config{}, auth.NewCredentialLifecycleEffects(nil, nil, nil), mail.NewStubSender(), mail.Renderer{})
+	_, err = users.UpdatePreferences(ctx, connect.NewRequest(&leapmuxv1.UpdatePreferencesRequest{Theme: "dark"}))
+	require.NoError(t, err)
+
+	got, err := mgmt.GetAccessPolicy(ctx, connect.NewRequest(&leapmuxv1.GetAccessPolicyRequest{}))
+	require.NoError(t, err)
+	require.Len(t, got.Msg.GetPolicy().GetRules(), 1)
+	assert.Equal(t, "no-rm", got.Msg.GetPolicy().GetRules()[0].GetName())
+}
+
+func TestAccessPolicy_RejectsInvalid(t *testing.T) {
+	deny := leapmuxv1.AccessPolicyEffect_ACCESS_POLICY_EFFECT_DENY
+	tests := []struct {
+		name string
+		rule *leapmuxv1.AccessPolicyRule
+	}{
+		{name: "syntax error", rule: &leapmuxv1.AccessPolicyRule{Name: "x", Condition: `tool ==`, Effect: deny}},
+		{name: "unknown variable", rule: &leapmuxv1.AccessPolicyRule{Name: "x", Condition: `branch == "main"`, Effect: deny}},
+		{name: "not bool", rule: &leapmuxv1.AccessPolicyRule{Name: "x", Condition: `tool`, Effect: deny}},
+		{name: "no effect", rule: &leapmuxv1.AccessPolicyRule{Name: "x", Condition: `true`}},
+		{name: "no name", rule: &leapmuxv1.AccessPolicyRule{Condition: `true`, Effect: deny}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			st := testutil.OpenTestStore(t)
+			_, ctx := policyCaller(t, st, "bad")
+			svc := service.NewWorkerManagementService(st, workermgr.New(workermgr.DenyAllReach()), nil, nil, nil, mail.Renderer{}, &config.Config{}, nil)
+
+			_, err := svc.UpdateAccessPolicy(ctx, connect.NewRequest(&leapmuxv1.UpdateAccessPolicyRequest{
+				Policy: &leapmuxv1.AccessPolicy{Rules: []*leapmuxv1.AccessPolicyRule{tt.rule}},
+			}))
+			assert.Equal(t, connect.CodeInvalidArgument, connect.CodeOf(err))
+		})
+	}
+}
+
+func TestAccessPolicy_Evaluate(t *testing.T) {
+	st := testutil.OpenTestStore(t)
+	uid, ctx := policyCaller(t, st, "dryrun")
+	svc := service.NewWorkerManagementService(st, workermgr.New(workermgr.DenyAllReach()), nil, nil, nil, mail.Renderer{}, &config.Config{}, nil)
+	rm := &leapmuxv1.AccessPolicyInput{
+		Action:  leapmuxv1.AccessPolicyAction_ACCESS_POLICY_ACTION_TOOL_USE,
+		Tool:    "Bash",
+		Command: "rm -rf build",
+	}
+
+	// The stored policy, empty so far, decides nothing.
+	got, err := svc.EvaluateAccessPolicy(ctx, connect.NewRequest(&leapmuxv1.EvaluateAccessPolicyRequest{Input: rm}))
+	require.NoError(t, err)
+	assert.Equal(t, leapmuxv1.AccessPolicyEffect_ACCESS_POLICY_EFFECT_UNSPECIFIED, got.Msg.GetEffect())
+
+	// A draft is evaluated without being saved.
+	got, err = svc.EvaluateAccessPolicy(ctx, connect.NewRequest(&leapmuxv1.EvaluateAccessPolicyRequest{
+		Policy: &leapmuxv1.AccessPolicy{Rules: []*leapmuxv1.AccessPolicyRule{noRmRule()}},
+		Input:  rm,
+	}))
+	require.NoError(t, err)
+	assert.Equal(t, leapmuxv1.AccessPolicyEffect_ACCESS_POLICY_EFFECT_DENY, got.Msg.GetEffect())
+	assert.Equal(t, "no-rm", got.Msg.GetRule())
+	assert.Equal(t, "Ask a human first.", got.Msg.GetMessage())
+	stored, err := svc.GetAccessPolicy(ctx, connect.NewRequest(&leapmuxv1.GetAccessPolicyRequest{}))
+	require.NoError(t, err)
+	assert.Empty(t, stored.Msg.GetPolicy().GetRules())
+
+	// The caller is the default user, and the time can be pinned.
+	got, err = svc.EvaluateAccessPolicy(ctx, connect.NewRequest(&leapmuxv1.EvaluateAccessPolicyRequest{
+		Policy: &leapmuxv1.AccessPolicy{Rules: []*leapmuxv1.AccessPolicyRule{{
+			Name:      "me-after-hours",
+			Condition: `user_id == "` + uid.String() + `" && hour >= 18`,
+			Effect:    leapmuxv1.AccessPolicyEffect_ACCESS_POLICY_EFFECT_DENY,
+		}}},
+		Input: &leapmuxv1.AccessPolicyInput{
+			Action: leapmuxv1.AccessPolicyAction_ACCESS_POLICY_ACTION_PERMISSION_MODE,
+			Time:   "2026-03-02T19:00:00Z",
+		},
+	}))
+	require.NoError(t, err)
+	assert.Equal(t, "me-after-hours", got.Msg.GetRule())
+
+	// An input needs an action.
+	_, err = svc.EvaluateAccessPolicy(ctx, connect.NewRequest(&leapmuxv1.EvaluateAccessPolicyRequest{
+		Input: &leapmuxv1.AccessPolicyInput{Tool: "Bash"},
+	}))
+	assert.Equal(t, connect.CodeInvalidArgument, connect.CodeOf(err))
+}
+
+func TestWorkspaceService_CreateWorkspace_AccessPolicyPicksWorker(t *testing.T) {
+	st := testutil.OpenTestStore(t)
+	orgID := storetest.SeedOrg(t, st, "policy-org")
+	user := storetest.SeedUser(t, st, orgID, "alice")
+	uid := userid.MustNew(user.ID)
+	ctx := auth.WithUser(context.Background(), &auth.UserInfo{ID: uid, OrgID: orgID})
+
+	mgr := workermgr.New(service.NewWorkerReachAuthorizer(st))
+	pending := workermgr.NewPendingRequests(func() time.Duration { return 5 * time.Second })
+	registerCheckoutWorker(t, st, mgr, pending, uid, "w-gpu", "gpu")
+	plain := registerCheckoutWorker(t, st, mgr, pending, uid, "w-plain")
+
+	created, err := service.NewRepoService(st, newTestKeystore(t)).CreateRepo(ctx, connect.NewRequest(&leapmuxv1.CreateRepoRequest{
+		Url: "https://example.com/acme/model.git", DefaultBranch: "main", WorkerLabels: []string{"gpu"},
+	}))
+	require.NoError(t, err)
+	repoID := created.Msg.GetRepo().GetId()
+
+	mgmt := service.NewWorkerManagementService(st, mgr, nil, nil, nil, mail.Renderer{}, &config.Config{}, nil)
+	_, err = mgmt.UpdateAccessPolicy(ctx, connect.NewRequest(&leapmuxv1.UpdateAccessPolicyRequest{
+		Policy: &leapmuxv1.AccessPolicy{Rules: []*leapmuxv1.AccessPolicyRule{{
+			Name:      "no-gpu",
+			Actions:   []leapmuxv1.AccessPolicyAction{leapmuxv1.AccessPolicyAction_ACCESS_POLICY_ACTION_WORKER_SELECTION},
+			Condition: `"gpu" in worker_labels`,
+			Effect:    leapmuxv1.AccessPolicyEffect_ACCESS_POLICY_EFFECT_DENY,
+			Message:   "GPU workers are reserved.",
+		}}},
+	}))
+	require.NoError(t, err)
+
+	svc := service.NewWorkspaceService(st, nil, noopWorkspaceChannelCloser{}).WithRepoCheckouts(mgr, pending, newTestKeystore(t))
+	resp, err := svc.CreateWorkspace(ctx, connect.NewRequest(&leapmuxv1.CreateWorkspaceRequest{Title: "model", RepoId: repoID}))
+	require.NoError(t, err)
+	assert.Equal(t, "w-plain", resp.Msg.GetWorkerId(), "the preferred but denied worker is passed over")
+	<-plain
+
+	// Naming a denied worker is refused outright.
+	_, err = svc.CreateWorkspace(ctx, connect.NewRequest(&leapmuxv1.CreateWorkspaceRequest{Title: "gpu", RepoId: repoID, WorkerId: "w-gpu"}))
+	assert.Equal(t, connect.CodePermissionDenied, connect.CodeOf(err))
+	assert.Contains(t, err.Error(), "GPU workers are reserved.")
+}
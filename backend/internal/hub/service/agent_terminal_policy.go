@@ -0,0 +1,116 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"regexp"
+
+	"connectrpc.com/connect"
+	leapmuxv1 "github.com/leapmux/leapmux/generated/proto/leapmux/v1"
+	"github.com/leapmux/leapmux/internal/hub/auth"
+	"github.com/leapmux/leapmux/internal/hub/store"
+)
+
+const (
+	// maxAgentTerminalPatterns bounds the policy every worker matches each
+	// Bash permission request against.
+	maxAgentTerminalPatterns = 32
+	// maxAgentTerminalPatternLen keeps one pattern to something a person
+	// wrote for one command, not a generated alternation of hundreds.
+	maxAgentTerminalPatternLen = 256
+)
+
+// storedAgentTerminalPolicy is the "agentTerminal" entry of the
+// user_preferences JSON blob, stored with the org's one member like
+// storedWorkerStreamSettings.
+type storedAgentTerminalPolicy struct {
+	CommandPatterns []string `json:"commandPatterns,omitempty"`
+}
+
+// validateAgentTerminalPolicy checks p and returns its stored form. Every
+// pattern must compile as RE2, since that is what the worker compiles it
+// with, and none may be empty: an empty pattern matches every command.
+func validateAgentTerminalPolicy(p *leapmuxv1.AgentTerminalPolicy) (*storedAgentTerminalPolicy, error) {
+	patterns := p.GetCommandPatterns()
+	if len(patterns) > maxAgentTerminalPatterns {
+		return nil, fmt.Errorf("at most %d command patterns are allowed", maxAgentTerminalPatterns)
+	}
+	for i, pattern := range patterns {
+		if pattern == "" {
+			return nil, fmt.Errorf("command pattern %d is empty", i+1)
+		}
+		if len(pattern) > maxAgentTerminalPatternLen {
+			return nil, fmt.Errorf("command pattern %d exceeds %d characters", i+1, maxAgentTerminalPatternLen)
+		}
+		if _, err := regexp.Compile(pattern); err != nil {
+			return nil, fmt.Errorf("command pattern %d: %w", i+1, err)
+		}
+	}
+	return &storedAgentTerminalPolicy{CommandPatterns: patterns}, nil
+}
+
+// agentTerminalPolicyToProto converts the stored form; nil is the default
+// of no patterns.
+func agentTerminalPolicyToProto(p *storedAgentTerminalPolicy) *leapmuxv1.AgentTerminalPolicy {
+	if p == nil {
+		return &leapmuxv1.AgentTerminalPolicy{}
+	}
+	return &leapmuxv1.AgentTerminalPolicy{CommandPatterns: p.CommandPatterns}
+}
+
+// loadAgentTerminalPolicy returns the agent terminal policy for the workers
+// userID registered.
+func loadAgentTerminalPolicy(ctx context.Context, st store.Store, userID string) (*leapmuxv1.AgentTerminalPolicy, error) {
+	sp, err := loadStoredPreferences(ctx, st, userID)
+	if err != nil {
+		return nil, err
+	}
+	return agentTerminalPolicyToProto(sp.AgentTerminal), nil
+}
+
+func (s *WorkerManagementService) GetAgentTerminalPolicy(
+	ctx context.Context,
+	_ *connect.Request[leapmuxv1.GetAgentTerminalPolicyRequest],
+) (*connect.Response[leapmuxv1.GetAgentTerminalPolicyResponse], error) {
+	user, err := auth.MustGetUser(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	policy, err := loadAgentTerminalPolicy(ctx, s.store, user.ID.String())
+	if err != nil {
+		return nil, connect.NewError(connect.CodeInternal, err)
+	}
+	return connect.NewResponse(&leapmuxv1.GetAgentTerminalPolicyResponse{Policy: policy}), nil
+}
+
+// UpdateAgentTerminalPolicy replaces the org's agent terminal policy and
+// pushes it to the org's workers connected to this Hub, the same way
+// UpdateWorkerStreamSettings does.
+func (s *WorkerManagementService) UpdateAgentTerminalPolicy(
+	ctx context.Context,
+	req *connect.Request[leapmuxv1.UpdateAgentTerminalPolicyRequest],
+) (*connect.Response[leapmuxv1.UpdateAgentTerminalPolicyResponse], error) {
+	user, err := auth.MustGetUser(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	stored, err := validateAgentTerminalPolicy(req.Msg.GetPolicy())
+	if err != nil {
+		return nil, connect.NewError(connect.CodeInvalidArgument, err)
+	}
+
+	if _, err := updateStoredPreferences(ctx, s.store, user.ID.String(), func(sp *storedPreferences) error {
+		sp.AgentTerminal = stored
+		return nil
+	}); err != nil {
+		return nil, err
+	}
+
+	policy := agentTerminalPolicyToProto(stored)
+	pushToUserWorkers(ctx, s.store, s.workerMgr, user, &leapmuxv1.ConnectResponse{
+		Payload: &leapmuxv1.ConnectResponse_AgentTerminalPolicy{AgentTerminalPolicy: policy},
+	}, "agent terminal policy")
+	return connect.NewResponse(&leapmuxv1.UpdateAgentTerminalPolicyResponse{Policy: policy}), nil
+}
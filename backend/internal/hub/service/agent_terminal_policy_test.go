@@ -0,0 +1,114 @@
+package service_test
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"connectrpc.com/connect"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	leapmuxv1 "github.com/leapmux/leapmux/generated/proto/leapmux/v1"
+	"github.com/leapmux/leapmux/internal/hub/auth"
+	"github.com/leapmux/leapmux/internal/hub/config"
+	"github.com/leapmux/leapmux/internal/hub/mail"
+	"github.com/leapmux/leapmux/internal/hub/service"
+	"github.com/leapmux/leapmux/internal/hub/store"
+	"github.com/leapmux/leapmux/internal/hub/testutil"
+	"github.com/leapmux/leapmux/internal/hub/workermgr"
+	"github.com/leapmux/leapmux/internal/util/userid"
+)
+
+func TestAgentTerminalPolicy_RoundTripAndPush(t *testing.T) {
+	st := testutil.OpenTestStore(t)
+	uid := userid.MustNew(testutil.CreateTestUser(t, st, "policy", "password123"))
+	ctx := auth.WithUser(context.Background(), &auth.UserInfo{ID: uid})
+
+	require.NoError(t, st.Workers().Create(ctx, store.CreateWorkerParams{
+		ID:              "w-online",
+		AuthToken:       "token-w-online",
+		RegisteredBy:    uid,
+		PublicKey:       []byte("test-x25519-key-32-bytes-padding"),
+		MlkemPublicKey:  []byte("mlkem"),
+		SlhdsaPublicKey: []byte("slhdsa"),
+	}))
+	mgr := workermgr.New(service.NewWorkerReachAuthorizer(st))
+	pushed := make(chan *leapmuxv1.ConnectResponse, 4)
+	_, err := mgr.Register(&workermgr.Conn{
+		WorkerID: "w-online",
+		SendFn: func(msg *leapmuxv1.ConnectResponse) error {
+			pushed <- msg
+			return nil
+		},
+	})
+	require.NoError(t, err)
+	svc := service.NewWorkerManagementService(st, mgr, nil, nil, nil, mail.Renderer{}, &config.Config{}, nil)
+
+	got, err := svc.GetAgentTerminalPolicy(ctx, connect.NewRequest(&leapmuxv1.GetAgentTerminalPolicyRequest{}))
+	require.NoError(t, err)
+	assert.Empty(t, got.Msg.GetPolicy().GetCommandPatterns(), "unset diverts nothing")
+
+	patterns := []string{`^npm run dev\b`, `^tail -f `}
+	_, err = svc.UpdateAgentTerminalPolicy(ctx, connect.NewRequest(&leapmuxv1.UpdateAgentTerminalPolicyRequest{
+		Policy: &leapmuxv1.AgentTerminalPolicy{CommandPatterns: patterns},
+	}))
+	require.NoError(t, err)
+
+	got, err = svc.GetAgentTerminalPolicy(ctx, connect.NewRequest(&leapmuxv1.GetAgentTerminalPolicyRequest{}))
+	require.NoError(t, err)
+	assert.Equal(t, patterns, got.Msg.GetPolicy().GetCommandPatterns())
+
+	require.Len(t, pushed, 1)
+	msg := <-pushed
+	assert.Equal(t, patterns, msg.GetAgentTerminalPolicy().GetCommandPatterns())
+}
+
+func TestAgentTerminalPolicy_RejectsInvalid(t *testing.T) {
+	tooMany := make([]string, 33)
+	for i := range tooMany {
+		tooMany[i] = "^x"
+	}
+	tests := []struct {
+		name     string
+		patterns []string
+	}{
+		{name: "bad regex", patterns: []string{`^npm (run`}},
+		{name: "empty pattern", patterns: []string{"^ok", ""}},
+		{name: "too long", patterns: []string{strings.Repeat("a", 257)}},
+		{name: "too many", patterns: tooMany},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			st := testutil.OpenTestStore(t)
+			uid := userid.MustNew(testutil.CreateTestUser(t, st, "bad", "password123"))
+			ctx := auth.WithUser(context.Background(), &auth.UserInfo{ID: uid})
+			svc := service.NewWorkerManagementService(st, workermgr.New(workermgr.DenyAllReach()), nil, nil, nil, mail.Renderer{}, &config.Config{}, nil)
+
+			_, err := svc.UpdateAgentTerminalPolicy(ctx, connect.NewRequest(&leapmuxv1.UpdateAgentTerminalPolicyRequest{
+				Policy: &leapmuxv1.AgentTerminalPolicy{CommandPatterns: tt.patterns},
+			}))
+			assert.Equal(t, connect.CodeInvalidArgument, connect.CodeOf(err))
+		})
+	}
+}
+
+func TestUpdatePreferences_KeepsAgentTerminalPolicy(t *testing.T) {
+	st := testutil.OpenTestStore(t)
+	uid := userid.MustNew(testutil.CreateTestUser(t, st, "keeper", "password123"))
+	ctx := auth.WithUser(context.Background(), &auth.UserInfo{ID: uid})
+	mgmt := service.NewWorkerManagementService(st, workermgr.New(workermgr.DenyAllReach()), nil, nil, nil, mail.Renderer{}, &config.Config{}, nil)
+
+	_, err := mgmt.UpdateAgentTerminalPolicy(ctx, connect.NewRequest(&leapmuxv1.UpdateAgentTerminalPolicyRequest{
+		Policy: &leapmuxv1.AgentTerminalPolicy{CommandPatterns: []string{"^make watch$"}},
+	}))
+	require.NoError(t, err)
+
+	users := service.NewUserService(st, &config.Config{}, auth.NewCredentialLifecycleEffects(nil, nil, nil), mail.NewStubSender(), mail.Renderer{})
+	_, err = users.UpdatePreferences(ctx, connect.NewRequest(&leapmuxv1.UpdatePreferencesRequest{Theme: "dark"}))
+	require.NoError(t, err)
+
+	got, err := mgmt.GetAgentTerminalPolicy(ctx, connect.NewRequest(&leapmuxv1.GetAgentTerminalPolicyRequest{}))
+	require.NoError(t, err)
+	assert.Equal(t, []string{"^make watch$"}, got.Msg.GetPolicy().GetCommandPatterns())
+}
@@ -0,0 +1,198 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"time"
+
+	leapmuxv1 "github.com/leapmux/leapmux/generated/proto/leapmux/v1"
+	"github.com/leapmux/leapmux/internal/hub/crdt"
+	"github.com/leapmux/leapmux/internal/hub/store"
+	"github.com/leapmux/leapmux/internal/util/id"
+	"github.com/leapmux/leapmux/internal/util/lexorank"
+)
+
+// The tab of a terminal opened while its agent starts (its setup script)
+// can reach the Hub before the agent's own tab does: the client adds that
+// tab as it asks the worker to open the agent. addAgentTerminalTab waits
+// this long for it, polling every agentTabWaitStep.
+const (
+	agentTabWaitTimeout = 5 * time.Second
+	agentTabWaitStep    = 50 * time.Millisecond
+)
+
+// handleAgentTerminalOpened adds the tab of a terminal a worker opened for
+// an agent's command, right after the agent's own tab in the same tile.
+//
+// The worker names the agent, and the agent's tab row is what authorizes
+// the write: it must be an agent tab in the named workspace that this
+// worker hosts. A worker therefore cannot place a tab in a workspace it
+// has no agent in, nor beside another worker's agent. Clients write tab
+// placement through the CRDT themselves; this is the one case with no
+// client in the path, so the Hub writes it with its reserved principal.
+func (s *WorkerConnectorService) handleAgentTerminalOpened(ctx context.Context, workerID string, opened *leapmuxv1.AgentTerminalOpened) {
+	if err := s.addAgentTerminalTab(ctx, workerID, opened); err != nil {
+		slog.Warn("agent terminal tab not added",
+			"worker_id", workerID,
+			"workspace_id", opened.GetWorkspaceId(),
+			"agent_id", opened.GetAgentId(),
+			"terminal_id", opened.GetTerminalId(),
+			"error", err)
+	}
+}
+
+func (s *WorkerConnectorService) addAgentTerminalTab(ctx context.Context, workerID string, opened *leapmuxv1.AgentTerminalOpened) error {
+	if s.crdtRegistry == nil {
+		return errors.New("no workspace layout registry")
+	}
+	workspaceID, terminalID := opened.GetWorkspaceId(), opened.GetTerminalId()
+	if workspaceID == "" || opened.GetAgentId() == "" || terminalID == "" {
+		return errors.New("workspace, agent and terminal ids are required")
+	}
+
+	tabs := s.store.WorkspaceTabIndex()
+	agentTab, err := waitForOwnedTab(ctx, tabs, store.GetOwnedTabParams{WorkspaceID: workspaceID, TabID: opened.GetAgentId()})
+	if err != nil {
+		return fmt.Errorf("look up agent tab: %w", err)
+	}
+	if agentTab.TabType != leapmuxv1.TabType_TAB_TYPE_AGENT || agentTab.WorkerID != workerID {
+		return errors.New("agent tab is not hosted by this worker")
+	}
+	if _, err := tabs.GetOwned(ctx, store.GetOwnedTabParams{WorkspaceID: workspaceID, TabID: terminalID}); err == nil {
+		// A resend after a reconnect; the tab is already placed.
+		return nil
+	} else if !errors.Is(err, store.ErrNotFound) {
+		return fmt.Errorf("look up terminal tab: %w", err)
+	}
+
+	siblings, err := tabs.ListOwnedByWorkspace(ctx, workspaceID)
+	if err != nil {
+		return fmt.Errorf("list workspace tabs: %w", err)
+	}
+	position := lexorank.Mid(agentTab.Position, nextTabPosition(siblings, agentTab))
+
+	mgr, err := s.crdtRegistry.Get(ctx, agentTab.OrgID)
+	if err != nil {
+		return fmt.Errorf("get layout manager: %w", err)
+	}
+	tabOp := func(set func(*leapmuxv1.SetTabRegisterOp)) *leapmuxv1.OrgOp {
+		inner := &leapmuxv1.SetTabRegisterOp{TabType: leapmuxv1.TabType_TAB_TYPE_TERMINAL, TabId: terminalID}
+		set(inner)
+		return &leapmuxv1.OrgOp{OpId: id.Generate(), Body: &leapmuxv1.OrgOp_SetTabRegister{SetTabRegister: inner}}
+	}
+	results, err := mgr.SubmitInternal(ctx, crdt.SubmitInput{
+		OrgID: agentTab.OrgID,
+		Batches: []*leapmuxv1.OpBatch{{
+			// Fixed per terminal, so a resend that raced the check above
+			// is deduplicated rather than placed twice.
+			BatchId: "agent-terminal-" + terminalID,
+			Ops: []*leapmuxv1.OrgOp{
+				tabOp(func(o *leapmuxv1.SetTabRegisterOp) {
+					o.Field = &leapmuxv1.SetTabRegisterOp_TileId{TileId: agentTab.TileID}
+				}),
+				tabOp(func(o *leapmuxv1.SetTabRegisterOp) {
+					o.Field = &leapmuxv1.SetTabRegisterOp_Position{Position: position}
+				}),
+				tabOp(func(o *leapmuxv1.SetTabRegisterOp) {
+					o.Field = &leapmuxv1.SetTabRegisterOp_WorkerId{WorkerId: workerID}
+				}),
+			},
+		}},
+		PrincipalID: crdt.HubReservedPrincipal,
+	})
+	if err != nil {
+		return fmt.Errorf("submit tab: %w", err)
+	}
+	for _, r := range results {
+		if rj := r.GetRejected(); rj != nil {
+			return fmt.Errorf("tab batch rejected: %v", rj.GetReason())
+		}
+	}
+	return nil
+}
+
+// handleAgentTerminalClosed removes the tab of an agent terminal the worker
+// closed along with its agent. Only a terminal tab this worker hosts is
+// removed, so the message cannot reach any other tab.
+func (s *WorkerConnectorService) handleAgentTerminalClosed(ctx context.Context, workerID string, closed *leapmuxv1.AgentTerminalClosed) {
+	if err := s.removeAgentTerminalTab(ctx, workerID, closed); err != nil {
+		slog.Warn("agent terminal tab not removed",
+			"worker_id", workerID,
+			"workspace_id", closed.GetWorkspaceId(),
+			"terminal_id", closed.GetTerminalId(),
+			"error", err)
+	}
+}
+
+func (s *WorkerConnectorService) removeAgentTerminalTab(ctx context.Context, workerID string, closed *leapmuxv1.AgentTerminalClosed) error {
+	if s.crdtRegistry == nil {
+		return errors.New("no workspace layout registry")
+	}
+	tab, err := s.store.WorkspaceTabIndex().GetOwned(ctx, store.GetOwnedTabParams{
+		WorkspaceID: closed.GetWorkspaceId(),
+		TabID:       closed.GetTerminalId(),
+	})
+	if errors.Is(err, store.ErrNotFound) {
+		// The user already closed it, or it was never placed.
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("look up terminal tab: %w", err)
+	}
+	if tab.TabType != leapmuxv1.TabType_TAB_TYPE_TERMINAL || tab.WorkerID != workerID {
+		return errors.New("terminal tab is not hosted by this worker")
+	}
+	mgr, err := s.crdtRegistry.Get(ctx, tab.OrgID)
+	if err != nil {
+		return fmt.Errorf("get layout manager: %w", err)
+	}
+	_, err = mgr.SubmitInternal(ctx, crdt.SubmitInput{
+		OrgID: tab.OrgID,
+		Batches: []*leapmuxv1.OpBatch{{
+			BatchId: "agent-terminal-close-" + tab.TabID,
+			Ops: []*leapmuxv1.OrgOp{{
+				OpId: id.Generate(),
+				Body: &leapmuxv1.OrgOp_TombstoneTab{TombstoneTab: &leapmuxv1.TombstoneTabOp{
+					TabType: tab.TabType,
+					TabId:   tab.TabID,
+				}},
+			}},
+		}},
+		PrincipalID: crdt.HubReservedPrincipal,
+	})
+	return err
+}
+
+// waitForOwnedTab looks a tab up, retrying while it is not yet indexed for
+// up to agentTabWaitTimeout.
+func waitForOwnedTab(ctx context.Context, tabs store.WorkspaceTabIndexStore, params store.GetOwnedTabParams) (*store.WorkspaceTabRow, error) {
+	deadline := time.Now().Add(agentTabWaitTimeout)
+	for {
+		tab, err := tabs.GetOwned(ctx, params)
+		if !errors.Is(err, store.ErrNotFound) || time.Now().After(deadline) {
+			return tab, err
+		}
+		select {
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		case <-time.After(agentTabWaitStep):
+		}
+	}
+}
+
+// nextTabPosition returns the position of the tab that follows anchor in
+// its tile, or "" when anchor is last.
+func nextTabPosition(tabs []store.WorkspaceTabRow, anchor *store.WorkspaceTabRow) string {
+	next := ""
+	for _, t := range tabs {
+		if t.TileID != anchor.TileID || t.Position <= anchor.Position {
+			continue
+		}
+		if next == "" || t.Position < next {
+			next = t.Position
+		}
+	}
+	return next
+}
@@ -0,0 +1,69 @@
+package service
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	leapmuxv1 "github.com/leapmux/leapmux/generated/proto/leapmux/v1"
+	"github.com/leapmux/leapmux/internal/hub/crdt"
+	"github.com/leapmux/leapmux/internal/hub/store"
+	"github.com/leapmux/leapmux/internal/hub/store/storetest"
+	hubtestutil "github.com/leapmux/leapmux/internal/hub/testutil"
+)
+
+// unreachableRegistry fails the test if a refused request gets as far as
+// writing the layout.
+type unreachableRegistry struct{ t *testing.T }
+
+func (r unreachableRegistry) Get(context.Context, string) (*crdt.Manager, error) {
+	r.t.Fatal("layout written for a refused request")
+	return nil, nil
+}
+
+// The worker names the agent and the terminal, so the tab rows are what
+// stand between it and another worker's tabs.
+func TestAgentTerminalTab_RefusesTabsOfOtherWorkers(t *testing.T) {
+	st := hubtestutil.OpenTestStore(t)
+	ctx := context.Background()
+	orgID := storetest.SeedOrg(t, st, "agent-term-org")
+	owner := storetest.SeedUser(t, st, orgID, "agent-term-owner")
+	wsID := storetest.SeedWorkspace(t, st, orgID, owner.ID, "ws")
+	for _, tab := range []store.UpsertOwnedTabParams{
+		{TabType: leapmuxv1.TabType_TAB_TYPE_AGENT, TabID: "agent-1", WorkerID: "w-other", Position: "a"},
+		{TabType: leapmuxv1.TabType_TAB_TYPE_TERMINAL, TabID: "term-1", WorkerID: "w-other", Position: "b"},
+		{TabType: leapmuxv1.TabType_TAB_TYPE_AGENT, TabID: "agent-2", WorkerID: "w-1", Position: "c"},
+	} {
+		tab.OrgID, tab.WorkspaceID, tab.TileID = orgID, wsID, "tile-1"
+		require.NoError(t, st.WorkspaceTabIndex().UpsertOwned(ctx, tab))
+	}
+	s := &WorkerConnectorService{store: st, crdtRegistry: unreachableRegistry{t}}
+
+	err := s.addAgentTerminalTab(ctx, "w-1", &leapmuxv1.AgentTerminalOpened{WorkspaceId: wsID, AgentId: "agent-1", TerminalId: "term-new"})
+	assert.ErrorContains(t, err, "not hosted by this worker", "beside another worker's agent")
+	err = s.addAgentTerminalTab(ctx, "w-1", &leapmuxv1.AgentTerminalOpened{WorkspaceId: wsID, AgentId: "term-1", TerminalId: "term-new"})
+	assert.ErrorContains(t, err, "not hosted by this worker", "a terminal is not an agent")
+	err = s.addAgentTerminalTab(ctx, "w-1", &leapmuxv1.AgentTerminalOpened{WorkspaceId: wsID, AgentId: "agent-2", TerminalId: "term-1"})
+	assert.NoError(t, err, "an id already placed is left alone")
+
+	err = s.removeAgentTerminalTab(ctx, "w-1", &leapmuxv1.AgentTerminalClosed{WorkspaceId: wsID, TerminalId: "term-1"})
+	assert.ErrorContains(t, err, "not hosted by this worker")
+	err = s.removeAgentTerminalTab(ctx, "w-1", &leapmuxv1.AgentTerminalClosed{WorkspaceId: wsID, TerminalId: "agent-2"})
+	assert.ErrorContains(t, err, "not hosted by this worker", "only terminal tabs are removed")
+	err = s.removeAgentTerminalTab(ctx, "w-1", &leapmuxv1.AgentTerminalClosed{WorkspaceId: wsID, TerminalId: "gone"})
+	assert.NoError(t, err, "a tab the user already closed is fine")
+}
+
+func TestNextTabPosition(t *testing.T) {
+	tabs := []store.WorkspaceTabRow{
+		{TabID: "a", TileID: "t1", Position: "a"},
+		{TabID: "c", TileID: "t1", Position: "c"},
+		{TabID: "b", TileID: "t1", Position: "b"},
+		{TabID: "x", TileID: "t2", Position: "bb"},
+	}
+	assert.Equal(t, "b", nextTabPosition(tabs, &tabs[0]))
+	assert.Equal(t, "c", nextTabPosition(tabs, &tabs[2]))
+	assert.Empty(t, nextTabPosition(tabs, &tabs[1]), "last in its tile")
+}
@@ -0,0 +1,253 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"connectrpc.com/connect"
+
+	leapmuxv1 "github.com/leapmux/leapmux/generated/proto/leapmux/v1"
+	"github.com/leapmux/leapmux/internal/hub/auth"
+	"github.com/leapmux/leapmux/internal/hub/store"
+	"github.com/leapmux/leapmux/internal/hub/workermgr"
+	"github.com/leapmux/leapmux/internal/util/id"
+	"github.com/leapmux/leapmux/internal/util/timefmt"
+)
+
+const (
+	// maxAnnouncementTitleLen caps an announcement's title, in characters.
+	maxAnnouncementTitleLen = 200
+	// maxAnnouncementBodyLen caps an announcement's body, in characters.
+	maxAnnouncementBodyLen = 4000
+)
+
+var errAnnouncementNotFound = errors.New("announcement not found")
+
+// AnnouncementService implements the AnnouncementServiceHandler interface.
+// The admin publishes; the Hub hands every connected worker the list of
+// announcements that have not ended, and each worker sends one to its
+// WatchEvents streams once it starts. Login returns the running ones the
+// user has not acknowledged.
+type AnnouncementService struct {
+	store     store.Store
+	workerMgr *workermgr.Manager
+}
+
+// NewAnnouncementService creates a new AnnouncementService.
+func NewAnnouncementService(st store.Store, workerMgr *workermgr.Manager) *AnnouncementService {
+	return &AnnouncementService{store: st, workerMgr: workerMgr}
+}
+
+func (s *AnnouncementService) PublishAnnouncement(
+	ctx context.Context,
+	req *connect.Request[leapmuxv1.PublishAnnouncementRequest],
+) (*connect.Response[leapmuxv1.PublishAnnouncementResponse], error) {
+	if err := requireAdmin(ctx); err != nil {
+		return nil, err
+	}
+	admin, err := auth.MustGetUser(ctx)
+	if err != nil {
+		return nil, err
+	}
+	params, err := validateAnnouncement(req.Msg, time.Now())
+	if err != nil {
+		return nil, connect.NewError(connect.CodeInvalidArgument, err)
+	}
+	params.ID = id.Generate()
+	params.CreatedBy = admin.ID.String()
+	a, err := s.store.Announcements().Create(ctx, params)
+	if err != nil {
+		return nil, connect.NewError(connect.CodeInternal, fmt.Errorf("create announcement: %w", err))
+	}
+
+	slog.Warn("announcement published",
+		"admin_id", admin.ID, "announcement_id", a.ID, "severity", a.Severity, "title", a.Title)
+	s.pushAnnouncements(ctx)
+	return connect.NewResponse(&leapmuxv1.PublishAnnouncementResponse{Announcement: announcementToProto(a)}), nil
+}
+
+func (s *AnnouncementService) ListAnnouncements(
+	ctx context.Context,
+	_ *connect.Request[leapmuxv1.ListAnnouncementsRequest],
+) (*connect.Response[leapmuxv1.ListAnnouncementsResponse], error) {
+	if err := requireAdmin(ctx); err != nil {
+		return nil, err
+	}
+	announcements, err := s.store.Announcements().ListAll(ctx)
+	if err != nil {
+		return nil, connect.NewError(connect.CodeInternal, err)
+	}
+	return connect.NewResponse(&leapmuxv1.ListAnnouncementsResponse{Announcements: announcementsToProto(announcements)}), nil
+}
+
+func (s *AnnouncementService) DeleteAnnouncement(
+	ctx context.Context,
+	req *connect.Request[leapmuxv1.DeleteAnnouncementRequest],
+) (*connect.Response[leapmuxv1.DeleteAnnouncementResponse], error) {
+	if err := requireAdmin(ctx); err != nil {
+		return nil, err
+	}
+	admin, err := auth.MustGetUser(ctx)
+	if err != nil {
+		return nil, err
+	}
+	announcementID := req.Msg.GetAnnouncementId()
+	n, err := s.store.Announcements().Delete(ctx, announcementID)
+	if err != nil {
+		return nil, connect.NewError(connect.CodeInternal, err)
+	}
+	if n == 0 {
+		return nil, connect.NewError(connect.CodeNotFound, errAnnouncementNotFound)
+	}
+
+	slog.Warn("announcement withdrawn", "admin_id", admin.ID, "announcement_id", announcementID)
+	s.pushAnnouncements(ctx)
+	return connect.NewResponse(&leapmuxv1.DeleteAnnouncementResponse{}), nil
+}
+
+func (s *AnnouncementService) ListActiveAnnouncements(
+	ctx context.Context,
+	_ *connect.Request[leapmuxv1.ListActiveAnnouncementsRequest],
+) (*connect.Response[leapmuxv1.ListActiveAnnouncementsResponse], error) {
+	user, err := auth.MustGetUser(ctx)
+	if err != nil {
+		return nil, err
+	}
+	announcements, err := s.store.Announcements().ListActiveForUser(ctx, user.ID)
+	if err != nil {
+		return nil, connect.NewError(connect.CodeInternal, err)
+	}
+	return connect.NewResponse(&leapmuxv1.ListActiveAnnouncementsResponse{Announcements: announcementsToProto(announcements)}), nil
+}
+
+func (s *AnnouncementService) AcknowledgeAnnouncement(
+	ctx context.Context,
+	req *connect.Request[leapmuxv1.AcknowledgeAnnouncementRequest],
+) (*connect.Response[leapmuxv1.AcknowledgeAnnouncementResponse], error) {
+	user, err := auth.MustGetUser(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if user.Credential.IsWorkspaceScoped() {
+		return nil, connect.NewError(connect.CodePermissionDenied, errors.New("announcements are acknowledged by the account's own credentials"))
+	}
+	announcementID := req.Msg.GetAnnouncementId()
+	if _, err := s.store.Announcements().GetByID(ctx, announcementID); err != nil {
+		if errors.Is(err, store.ErrNotFound) {
+			return nil, connect.NewError(connect.CodeNotFound, errAnnouncementNotFound)
+		}
+		return nil, connect.NewError(connect.CodeInternal, err)
+	}
+	err = s.store.Announcements().Acknowledge(ctx, store.AcknowledgeAnnouncementParams{
+		AnnouncementID: announcementID,
+		UserID:         user.ID,
+	})
+	if errors.Is(err, store.ErrNotFound) {
+		return nil, connect.NewError(connect.CodeNotFound, errAnnouncementNotFound)
+	}
+	if err != nil {
+		return nil, connect.NewError(connect.CodeInternal, err)
+	}
+	return connect.NewResponse(&leapmuxv1.AcknowledgeAnnouncementResponse{}), nil
+}
+
+// pushAnnouncements sends every connected worker the announcements that
+// have not ended. A failure is logged, not returned: the change is stored,
+// and a worker that misses the push gets the list on reconnect.
+func (s *AnnouncementService) pushAnnouncements(ctx context.Context) {
+	list, err := liveAnnouncements(ctx, s.store)
+	if err != nil {
+		slog.Warn("failed to list announcements for push", "error", err)
+		return
+	}
+	s.workerMgr.Broadcast(&leapmuxv1.ConnectResponse{
+		Payload: &leapmuxv1.ConnectResponse_Announcements{Announcements: list},
+	})
+}
+
+// liveAnnouncements returns the announcements that have not ended, as the
+// Hub sends them to workers.
+func liveAnnouncements(ctx context.Context, st store.Store) (*leapmuxv1.AnnouncementList, error) {
+	announcements, err := st.Announcements().ListLive(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return &leapmuxv1.AnnouncementList{Announcements: announcementsToProto(announcements)}, nil
+}
+
+// validateAnnouncement checks a publish request and returns the
+// announcement it describes, starting at now when it names no start.
+func validateAnnouncement(msg *leapmuxv1.PublishAnnouncementRequest, now time.Time) (store.CreateAnnouncementParams, error) {
+	var p store.CreateAnnouncementParams
+	p.Title = strings.TrimSpace(msg.GetTitle())
+	if p.Title == "" {
+		return p, errors.New("title is required")
+	}
+	if utf8.RuneCountInString(p.Title) > maxAnnouncementTitleLen {
+		return p, fmt.Errorf("title exceeds %d characters", maxAnnouncementTitleLen)
+	}
+	p.Body = strings.TrimSpace(msg.GetBody())
+	if utf8.RuneCountInString(p.Body) > maxAnnouncementBodyLen {
+		return p, fmt.Errorf("body exceeds %d characters", maxAnnouncementBodyLen)
+	}
+	p.Severity = msg.GetSeverity()
+	if p.Severity == leapmuxv1.AnnouncementSeverity_ANNOUNCEMENT_SEVERITY_UNSPECIFIED {
+		p.Severity = leapmuxv1.AnnouncementSeverity_ANNOUNCEMENT_SEVERITY_INFO
+	}
+	if _, ok := leapmuxv1.AnnouncementSeverity_name[int32(p.Severity)]; !ok {
+		return p, errors.New("severity: must be INFO, MAINTENANCE or INCIDENT")
+	}
+
+	p.StartsAt = now
+	if s := msg.GetStartsAt(); s != "" {
+		t, err := time.Parse(time.RFC3339, s)
+		if err != nil {
+			return p, fmt.Errorf("starts_at: %w", err)
+		}
+		p.StartsAt = t
+	}
+	if s := msg.GetEndsAt(); s != "" {
+		t, err := time.Parse(time.RFC3339, s)
+		if err != nil {
+			return p, fmt.Errorf("ends_at: %w", err)
+		}
+		if !t.After(p.StartsAt) {
+			return p, errors.New("ends_at must be after starts_at")
+		}
+		if !t.After(now) {
+			return p, errors.New("ends_at is in the past")
+		}
+		p.EndsAt = &t
+	}
+	return p, nil
+}
+
+func announcementToProto(a *store.Announcement) *leapmuxv1.Announcement {
+	pb := &leapmuxv1.Announcement{
+		Id:                a.ID,
+		Title:             a.Title,
+		Body:              a.Body,
+		Severity:          a.Severity,
+		StartsAt:          timefmt.Format(a.StartsAt),
+		CreatedBy:         a.CreatedBy,
+		CreatedAt:         timefmt.Format(a.CreatedAt),
+		AcknowledgedCount: a.AcknowledgedCount,
+	}
+	if a.EndsAt != nil {
+		pb.EndsAt = timefmt.Format(*a.EndsAt)
+	}
+	return pb
+}
+
+func announcementsToProto(announcements []store.Announcement) []*leapmuxv1.Announcement {
+	pb := make([]*leapmuxv1.Announcement, len(announcements))
+	for i := range announcements {
+		pb[i] = announcementToProto(&announcements[i])
+	}
+	return pb
+}
@@ -0,0 +1,148 @@
+package service_test
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	"connectrpc.com/connect"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	leapmuxv1 "github.com/leapmux/leapmux/generated/proto/leapmux/v1"
+	"github.com/leapmux/leapmux/internal/hub/auth"
+	"github.com/leapmux/leapmux/internal/hub/service"
+	"github.com/leapmux/leapmux/internal/hub/testutil"
+	"github.com/leapmux/leapmux/internal/hub/workermgr"
+	"github.com/leapmux/leapmux/internal/util/timefmt"
+	"github.com/leapmux/leapmux/internal/util/userid"
+)
+
+type announcementEnv struct {
+	svc    *service.AnnouncementService
+	user   context.Context
+	admin  context.Context
+	pushed chan *leapmuxv1.AnnouncementList
+}
+
+func setupAnnouncements(t *testing.T) *announcementEnv {
+	t.Helper()
+	st := testutil.OpenTestStore(t)
+	uid := userid.MustNew(testutil.CreateTestUser(t, st, "reader", "password123"))
+	env := &announcementEnv{
+		user:   auth.WithUser(context.Background(), &auth.UserInfo{ID: uid}),
+		admin:  auth.WithUser(context.Background(), &auth.UserInfo{ID: userid.MustNew("admin"), IsAdmin: true}),
+		pushed: make(chan *leapmuxv1.AnnouncementList, 4),
+	}
+	mgr := workermgr.New(service.NewWorkerReachAuthorizer(st))
+	_, err := mgr.Register(&workermgr.Conn{
+		WorkerID: "w-1",
+		SendFn: func(msg *leapmuxv1.ConnectResponse) error {
+			env.pushed <- msg.GetAnnouncements()
+			return nil
+		},
+	})
+	require.NoError(t, err)
+	env.svc = service.NewAnnouncementService(st, mgr)
+	return env
+}
+
+func (env *announcementEnv) publish(t *testing.T, req *leapmuxv1.PublishAnnouncementRequest) *leapmuxv1.Announcement {
+	t.Helper()
+	resp, err := env.svc.PublishAnnouncement(env.admin, connect.NewRequest(req))
+	require.NoError(t, err)
+	return resp.Msg.GetAnnouncement()
+}
+
+func (env *announcementEnv) active(t *testing.T) []*leapmuxv1.Announcement {
+	t.Helper()
+	resp, err := env.svc.ListActiveAnnouncements(env.user, connect.NewRequest(&leapmuxv1.ListActiveAnnouncementsRequest{}))
+	require.NoError(t, err)
+	return resp.Msg.GetAnnouncements()
+}
+
+func TestAnnouncements_PublishPushesToWorkers(t *testing.T) {
+	env := setupAnnouncements(t)
+
+	a := env.publish(t, &leapmuxv1.PublishAnnouncementRequest{Title: " Upgrade tonight ", Body: "Expect a short outage."})
+	assert.Equal(t, "Upgrade tonight", a.GetTitle())
+	assert.Equal(t, leapmuxv1.AnnouncementSeverity_ANNOUNCEMENT_SEVERITY_INFO, a.GetSeverity())
+	assert.Equal(t, "admin", a.GetCreatedBy())
+	assert.Empty(t, a.GetEndsAt())
+	pushed := <-env.pushed
+	require.Len(t, pushed.GetAnnouncements(), 1)
+	assert.Equal(t, a.GetId(), pushed.GetAnnouncements()[0].GetId())
+
+	// A scheduled announcement is pushed too, so the worker can start it.
+	later := env.publish(t, &leapmuxv1.PublishAnnouncementRequest{
+		Title:    "Maintenance",
+		Severity: leapmuxv1.AnnouncementSeverity_ANNOUNCEMENT_SEVERITY_MAINTENANCE,
+		StartsAt: timefmt.Format(time.Now().Add(time.Hour)),
+		EndsAt:   timefmt.Format(time.Now().Add(2 * time.Hour)),
+	})
+	assert.Len(t, (<-env.pushed).GetAnnouncements(), 2)
+
+	_, err := env.svc.DeleteAnnouncement(env.admin, connect.NewRequest(&leapmuxv1.DeleteAnnouncementRequest{AnnouncementId: a.GetId()}))
+	require.NoError(t, err)
+	pushed = <-env.pushed
+	require.Len(t, pushed.GetAnnouncements(), 1)
+	assert.Equal(t, later.GetId(), pushed.GetAnnouncements()[0].GetId())
+
+	_, err = env.svc.DeleteAnnouncement(env.admin, connect.NewRequest(&leapmuxv1.DeleteAnnouncementRequest{AnnouncementId: a.GetId()}))
+	assert.Equal(t, connect.CodeNotFound, connect.CodeOf(err))
+}
+
+func TestAnnouncements_AcknowledgeHidesIt(t *testing.T) {
+	env := setupAnnouncements(t)
+	a := env.publish(t, &leapmuxv1.PublishAnnouncementRequest{Title: "Incident", Severity: leapmuxv1.AnnouncementSeverity_ANNOUNCEMENT_SEVERITY_INCIDENT})
+	env.publish(t, &leapmuxv1.PublishAnnouncementRequest{Title: "Later", StartsAt: timefmt.Format(time.Now().Add(time.Hour))})
+
+	active := env.active(t)
+	require.Len(t, active, 1, "a scheduled announcement is not active yet")
+	assert.Equal(t, a.GetId(), active[0].GetId())
+
+	for range 2 {
+		_, err := env.svc.AcknowledgeAnnouncement(env.user, connect.NewRequest(&leapmuxv1.AcknowledgeAnnouncementRequest{AnnouncementId: a.GetId()}))
+		require.NoError(t, err, "acknowledging twice is not an error")
+	}
+	assert.Empty(t, env.active(t))
+
+	list, err := env.svc.ListAnnouncements(env.admin, connect.NewRequest(&leapmuxv1.ListAnnouncementsRequest{}))
+	require.NoError(t, err)
+	require.Len(t, list.Msg.GetAnnouncements(), 2)
+	for _, got := range list.Msg.GetAnnouncements() {
+		if got.GetId() == a.GetId() {
+			assert.EqualValues(t, 1, got.GetAcknowledgedCount())
+		}
+	}
+
+	_, err = env.svc.AcknowledgeAnnouncement(env.user, connect.NewRequest(&leapmuxv1.AcknowledgeAnnouncementRequest{AnnouncementId: "missing"}))
+	assert.Equal(t, connect.CodeNotFound, connect.CodeOf(err))
+}
+
+func TestAnnouncements_RequireAdmin(t *testing.T) {
+	env := setupAnnouncements(t)
+	_, err := env.svc.PublishAnnouncement(env.user, connect.NewRequest(&leapmuxv1.PublishAnnouncementRequest{Title: "Hi"}))
+	assert.Equal(t, connect.CodePermissionDenied, connect.CodeOf(err))
+	_, err = env.svc.ListAnnouncements(env.user, connect.NewRequest(&leapmuxv1.ListAnnouncementsRequest{}))
+	assert.Equal(t, connect.CodePermissionDenied, connect.CodeOf(err))
+	_, err = env.svc.DeleteAnnouncement(env.user, connect.NewRequest(&leapmuxv1.DeleteAnnouncementRequest{AnnouncementId: "x"}))
+	assert.Equal(t, connect.CodePermissionDenied, connect.CodeOf(err))
+}
+
+func TestAnnouncements_RejectsInvalid(t *testing.T) {
+	env := setupAnnouncements(t)
+	now := time.Now()
+	for name, req := range map[string]*leapmuxv1.PublishAnnouncementRequest{
+		"no title":       {Body: "body"},
+		"bad start":      {Title: "t", StartsAt: "tomorrow"},
+		"end before":     {Title: "t", StartsAt: timefmt.Format(now.Add(time.Hour)), EndsAt: timefmt.Format(now.Add(time.Minute))},
+		"already ended":  {Title: "t", StartsAt: timefmt.Format(now.Add(-2 * time.Hour)), EndsAt: timefmt.Format(now.Add(-time.Hour))},
+		"bad severity":   {Title: "t", Severity: leapmuxv1.AnnouncementSeverity(42)},
+		"title too long": {Title: strings.Repeat("a", 201)},
+	} {
+		_, err := env.svc.PublishAnnouncement(env.admin, connect.NewRequest(req))
+		assert.Equal(t, connect.CodeInvalidArgument, connect.CodeOf(err), name)
+	}
+}
@@ -510,6 +510,12 @@ func (h *APIAuthHandler) handleRevoke(w http.ResponseWriter, r *http.Request) {
 			return
 		}
 		h.lifecycle.BearerRevoked(auth.BearerKindDelegation, tokenID)
+	case auth.BearerKindGuest:
+		if _, err := h.store.GuestInvitations().Revoke(r.Context(), tokenID); err != nil {
+			writeInternalError(w, "guest invitation revocation failed", err)
+			return
+		}
+		h.lifecycle.BearerRevoked(auth.BearerKindGuest, tokenID)
 	}
 	w.WriteHeader(http.StatusOK)
 }
@@ -18,6 +18,7 @@ import (
 	pwdhash "github.com/leapmux/leapmux/internal/hub/password"
 	"github.com/leapmux/leapmux/internal/hub/store"
 	"github.com/leapmux/leapmux/internal/hub/usernames"
+	"github.com/leapmux/leapmux/internal/util/userid"
 	"github.com/leapmux/leapmux/util/validate"
 	"github.com/leapmux/leapmux/util/version"
 )
@@ -71,10 +72,22 @@ func (s *AuthService) Login(ctx context.Context, req *connect.Request[leapmuxv1.
 		return nil, connect.NewError(connect.CodeInternal, err)
 	}
 
+	// A failed announcements read does not fail the login; the frontend
+	// still gets each one from WatchEvents.
+	var announcements []*leapmuxv1.Announcement
+	if uid, ok := userid.New(user.ID); ok {
+		active, err := s.store.Announcements().ListActiveForUser(ctx, uid)
+		if err != nil {
+			slog.Warn("failed to list announcements at login", "user_id", user.ID, "error", err)
+		}
+		announcements = announcementsToProto(active)
+	}
+
 	resp := connect.NewResponse(&leapmuxv1.LoginResponse{
-		User: userToProtoWithOrgName(user, org.Name),
+		User:          userToProtoWithOrgName(user, org.Name),
+		Announcements: announcements,
 	})
-	resp.Header().Set("Set-Cookie", auth.BuildSessionCookie(token, expiresAt, s.cfg.SecureCookies).String())
+	resp.Header().Set("Set-Cookie", auth.BuildSessionCookie(token, expiresAt, s.cfg.SecureCookies, s.cfg.CrossSiteCookies).String())
 	return resp, nil
 }
 
@@ -83,13 +96,13 @@ func (s *AuthService) Logout(ctx context.Context, req *connect.Request[leapmuxv1
 	if token != "" {
 		if _, err := s.store.Sessions().Delete(ctx, token); err != nil {
 			connectErr := connect.NewError(connect.CodeInternal, fmt.Errorf("delete session: %w", err))
-			connectErr.Meta().Set("Set-Cookie", auth.ClearSessionCookie(s.cfg.SecureCookies).String())
+			connectErr.Meta().Set("Set-Cookie", auth.ClearSessionCookie(s.cfg.SecureCookies, s.cfg.CrossSiteCookies).String())
 			return nil, connectErr
 		}
 		s.lifecycle.SessionRevoked(token)
 	}
 	resp := connect.NewResponse(&leapmuxv1.LogoutResponse{})
-	resp.Header().Set("Set-Cookie", auth.ClearSessionCookie(s.cfg.SecureCookies).String())
+	resp.Header().Set("Set-Cookie", auth.ClearSessionCookie(s.cfg.SecureCookies, s.cfg.CrossSiteCookies).String())
 	return resp, nil
 }
 
@@ -246,7 +259,7 @@ func (s *AuthService) SignUp(ctx context.Context, req *connect.Request[leapmuxv1
 			VerificationRequired:  true,
 			VerificationEmailSent: emailSent,
 		})
-		resp.Header().Set("Set-Cookie", auth.BuildSessionCookie(sessionID, sessionExpires, s.cfg.SecureCookies).String())
+		resp.Header().Set("Set-Cookie", auth.BuildSessionCookie(sessionID, sessionExpires, s.cfg.SecureCookies, s.cfg.CrossSiteCookies).String())
 		return resp, nil
 	}
 
@@ -322,7 +335,7 @@ func (s *AuthService) signUpResponse(ctx context.Context, user *store.User, orgN
 	resp := connect.NewResponse(&leapmuxv1.SignUpResponse{
 		User: userToProtoWithOrgName(user, orgName),
 	})
-	resp.Header().Set("Set-Cookie", auth.BuildSessionCookie(sessionID, expiresAt, s.cfg.SecureCookies).String())
+	resp.Header().Set("Set-Cookie", auth.BuildSessionCookie(sessionID, expiresAt, s.cfg.SecureCookies, s.cfg.CrossSiteCookies).String())
 	return resp, nil
 }
 
@@ -596,7 +609,7 @@ func (s *AuthService) CompleteOAuthSignup(ctx context.Context, req *connect.Requ
 		VerificationRequired:  pendingEmail != "",
 		VerificationEmailSent: emailSent,
 	})
-	resp.Header().Set("Set-Cookie", auth.BuildSessionCookie(sessionID, expiresAt, s.cfg.SecureCookies).String())
+	resp.Header().Set("Set-Cookie", auth.BuildSessionCookie(sessionID, expiresAt, s.cfg.SecureCookies, s.cfg.CrossSiteCookies).String())
 	return resp, nil
 }
 
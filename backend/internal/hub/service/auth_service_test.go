@@ -109,6 +109,28 @@ func TestAuthService_LoginSuccess(t *testing.T) {
 	assert.Contains(t, setCookie, "HttpOnly")
 }
 
+func TestAuthService_LoginReturnsActiveAnnouncements(t *testing.T) {
+	client, st := setupAuthTestServer(t, testConfig())
+	ctx := context.Background()
+	for _, p := range []store.CreateAnnouncementParams{
+		{ID: "running", Title: "Maintenance tonight", StartsAt: time.Now().Add(-time.Minute)},
+		{ID: "scheduled", Title: "Later", StartsAt: time.Now().Add(time.Hour)},
+	} {
+		p.Severity = leapmuxv1.AnnouncementSeverity_ANNOUNCEMENT_SEVERITY_MAINTENANCE
+		p.CreatedBy = "admin"
+		_, err := st.Announcements().Create(ctx, p)
+		require.NoError(t, err)
+	}
+
+	resp, err := client.Login(ctx, connect.NewRequest(&leapmuxv1.LoginRequest{
+		Username: "admin",
+		Password: "admin123",
+	}))
+	require.NoError(t, err)
+	require.Len(t, resp.Msg.GetAnnouncements(), 1)
+	assert.Equal(t, "running", resp.Msg.GetAnnouncements()[0].GetId())
+}
+
 func TestAuthService_LoginInvalidPassword(t *testing.T) {
 	client, _ := setupAuthTestServer(t, testConfig())
 
@@ -25,14 +25,14 @@ func channelAuthInfo(user *auth.UserInfo) channelmgr.AuthInfo {
 
 // channelWorkspaceUpdateAuthorized authorizes pushing a workspace-access update
 // for workspaceID to a channel. Unscoped callers reach every same-user channel
-// not pinned to a different delegation workspace; a delegation caller reaches
-// only channels opened by the same bearer in the same delegated workspace, never
+// not pinned to a different delegation workspace; a workspace-scoped caller
+// reaches only channels opened by the same bearer in the same workspace, never
 // an unrestricted cookie/API channel. Delegation-scope policy lives here beside
 // userCanUseChannel, not inside the channel manager's routing index.
 func channelWorkspaceUpdateAuthorized(caller auth.CredentialIdentity, workspaceID string) func(channelmgr.ChannelInfo) bool {
 	return func(info channelmgr.ChannelInfo) bool {
 		channelScope := info.AuthInfo.Credential.WorkspaceScopeID()
-		if caller.IsDelegation() {
+		if caller.IsWorkspaceScoped() {
 			return info.AuthInfo.Credential.Matches(caller) && channelScope == workspaceID
 		}
 		return channelScope == "" || channelScope == workspaceID
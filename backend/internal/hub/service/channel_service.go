@@ -10,6 +10,7 @@ import (
 	"golang.org/x/sync/errgroup"
 
 	leapmuxv1 "github.com/leapmux/leapmux/generated/proto/leapmux/v1"
+	"github.com/leapmux/leapmux/internal/errcode"
 	"github.com/leapmux/leapmux/internal/hub/auth"
 	"github.com/leapmux/leapmux/internal/hub/channelmgr"
 	"github.com/leapmux/leapmux/internal/hub/store"
@@ -89,7 +90,7 @@ func (s *ChannelService) GetWorkerHandshakeParams(
 	keys, err := s.store.Workers().GetPublicKey(ctx, workerID)
 	if err != nil {
 		if errors.Is(err, store.ErrNotFound) {
-			return nil, connect.NewError(connect.CodeNotFound, fmt.Errorf("worker not found"))
+			return nil, errcode.New(connect.CodeNotFound, errcode.WorkerNotFound, errors.New("worker not found"))
 		}
 		return nil, connect.NewError(connect.CodeInternal, err)
 	}
@@ -115,9 +116,10 @@ func (s *ChannelService) GetWorkerHandshakeParams(
 // worker on channel open. Sessions and API tokens get every workspace the user
 // owns in their (personal) org. A delegation bearer is re-verified against
 // current ownership and pinned to its single mint-scope workspace so a stolen
-// token cannot pivot the channel beyond that scope.
+// token cannot pivot the channel beyond that scope. A guest or public viewer is
+// pinned to its workspace the same way.
 func (s *ChannelService) accessibleWorkspaceIDs(ctx context.Context, user *auth.UserInfo) ([]string, error) {
-	if user.Credential.IsDelegation() {
+	if user.Credential.IsWorkspaceScoped() {
 		// Re-verify the pin against current ownership so deleted / transferred
 		// workspaces are caught at channel open time.
 		hasAccess, err := auth.WorkspaceCanRead(ctx, s.store, auth.AnyOrg(), user.Credential.WorkspaceScopeID(), user.ID)
@@ -212,6 +214,9 @@ func (s *ChannelService) OpenChannel(
 						UserId:                 user.ID.String(),
 						HandshakePayload:       req.Msg.GetHandshakePayload(),
 						AccessibleWorkspaceIds: accessibleWSIDs,
+						ReadOnly:               user.Credential.IsReadOnly(),
+						Guest:                  user.Credential.IsGuest(),
+						WorkspaceScoped:        user.Credential.IsWorkspaceScoped(),
 					},
 				},
 			})
@@ -529,7 +534,7 @@ func (s *ChannelService) requireOnlineWorker(ctx context.Context, user *auth.Use
 		return nil, err
 	}
 	if conn == nil {
-		return nil, connect.NewError(connect.CodeUnavailable, fmt.Errorf("worker is offline"))
+		return nil, errcode.New(connect.CodeUnavailable, errcode.WorkerOffline, errors.New("worker is offline"))
 	}
 	return conn, nil
 }
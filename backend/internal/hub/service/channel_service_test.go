@@ -15,6 +15,7 @@ import (
 
 	leapmuxv1 "github.com/leapmux/leapmux/generated/proto/leapmux/v1"
 	"github.com/leapmux/leapmux/generated/proto/leapmux/v1/leapmuxv1connect"
+	"github.com/leapmux/leapmux/internal/errcode"
 	"github.com/leapmux/leapmux/internal/hub/password"
 
 	"github.com/leapmux/leapmux/internal/hub/auth"
@@ -241,6 +242,7 @@ func TestGetWorkerHandshakeParams_WorkerOffline(t *testing.T) {
 		&leapmuxv1.GetWorkerHandshakeParamsRequest{WorkerId: workerID}, token))
 	require.Error(t, err)
 	assert.Equal(t, connect.CodeUnavailable, connect.CodeOf(err))
+	assert.Equal(t, errcode.WorkerOffline, errcode.Of(err), "the code survives the wire as an error detail")
 }
 
 func TestGetWorkerHandshakeParams_RejectsStaleAuthGeneration(t *testing.T) {
@@ -23,7 +23,7 @@ func workerScopePredicate(scope auth.DelegationWorkerScope) func(string) bool {
 }
 
 func delegationWorkspaceMismatch(user *auth.UserInfo, workspaceID string) bool {
-	return user != nil && user.Credential.IsDelegation() && workspaceID != user.Credential.WorkspaceScopeID()
+	return user != nil && user.Credential.IsWorkspaceScoped() && workspaceID != user.Credential.WorkspaceScopeID()
 }
 
 func requireDelegationWorkspace(user *auth.UserInfo, workspaceID string) error {
@@ -65,10 +65,11 @@ type scopedWorkspaceRequest struct {
 }
 
 // delegationScopedWorkspaceRequest narrows a workspace-id request to what a
-// delegation bearer may see. A non-delegation caller passes through unchanged.
-// See scopedWorkspaceRequest for why the deny case is a named field.
+// delegation bearer (or public viewer) may see. An unscoped caller passes
+// through unchanged. See scopedWorkspaceRequest for why the deny case is a
+// named field.
 func delegationScopedWorkspaceRequest(user *auth.UserInfo, requested []string) (scopedWorkspaceRequest, error) {
-	if user == nil || !user.Credential.IsDelegation() {
+	if user == nil || !user.Credential.IsWorkspaceScoped() {
 		return scopedWorkspaceRequest{Workspaces: requested}, nil
 	}
 	if len(requested) == 0 {
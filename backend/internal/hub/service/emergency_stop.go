@@ -0,0 +1,238 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"unicode/utf8"
+
+	"connectrpc.com/connect"
+	leapmuxv1 "github.com/leapmux/leapmux/generated/proto/leapmux/v1"
+	"github.com/leapmux/leapmux/internal/errcode"
+	"github.com/leapmux/leapmux/internal/hub/auth"
+	"github.com/leapmux/leapmux/internal/hub/store"
+	"github.com/leapmux/leapmux/internal/util/timefmt"
+	"github.com/leapmux/leapmux/internal/util/userid"
+)
+
+// maxEmergencyStopReasonLen bounds the reason every stopped agent's chat
+// shows.
+const maxEmergencyStopReasonLen = 500
+
+// emergencyStops is an org's stops as EmergencyStopStore.ListByOrg
+// returns them.
+type emergencyStops []store.EmergencyStop
+
+// forWorker returns the stop workerID runs under, its own before the
+// org's; nil when there is none.
+func (s emergencyStops) forWorker(workerID string) *leapmuxv1.EmergencyStopState {
+	var org *store.EmergencyStop
+	for i := range s {
+		switch s[i].WorkerID {
+		case workerID:
+			return emergencyStopToProto(&s[i])
+		case "":
+			org = &s[i]
+		}
+	}
+	if org == nil {
+		return nil
+	}
+	return emergencyStopToProto(org)
+}
+
+func emergencyStopToProto(s *store.EmergencyStop) *leapmuxv1.EmergencyStopState {
+	return &leapmuxv1.EmergencyStopState{
+		Active:    true,
+		Reason:    s.Reason,
+		StoppedBy: s.StoppedBy,
+		StoppedAt: timefmt.Format(s.StoppedAt),
+	}
+}
+
+// orgOwner returns the one member of orgID. An org is named after its
+// member, so the lookup goes by name and checks the member really belongs
+// to the org.
+func orgOwner(ctx context.Context, st store.Store, orgID string) (*store.User, error) {
+	notFound := connect.NewError(connect.CodeNotFound, errors.New("org not found"))
+	if orgID == "" {
+		return nil, connect.NewError(connect.CodeInvalidArgument, errors.New("org_id is required"))
+	}
+	org, err := st.Orgs().GetByID(ctx, orgID)
+	if errors.Is(err, store.ErrNotFound) {
+		return nil, notFound
+	}
+	if err != nil {
+		return nil, connect.NewError(connect.CodeInternal, err)
+	}
+	owner, err := st.Users().GetByUsername(ctx, org.Name)
+	if errors.Is(err, store.ErrNotFound) || (err == nil && owner.OrgID != orgID) {
+		return nil, notFound
+	}
+	if err != nil {
+		return nil, connect.NewError(connect.CodeInternal, err)
+	}
+	return owner, nil
+}
+
+// emergencyStopTarget checks the org, and the worker when one is named,
+// an emergency stop request is for, and returns the org's owner.
+func (s *WorkerManagementService) emergencyStopTarget(ctx context.Context, orgID, workerID string) (userid.UserID, error) {
+	owner, err := orgOwner(ctx, s.store, orgID)
+	if err != nil {
+		return userid.UserID{}, err
+	}
+	ownerID, ok := userid.New(owner.ID)
+	if !ok {
+		return userid.UserID{}, connect.NewError(connect.CodeInternal, fmt.Errorf("org %s has an invalid owner id", orgID))
+	}
+	if workerID != "" {
+		if _, err := s.store.Workers().GetOwned(ctx, store.GetOwnedWorkerParams{WorkerID: workerID, UserID: ownerID}); err != nil {
+			if errors.Is(err, store.ErrNotFound) {
+				return userid.UserID{}, errcode.New(connect.CodeNotFound, errcode.WorkerNotFound, errors.New("worker not found"))
+			}
+			return userid.UserID{}, connect.NewError(connect.CodeInternal, err)
+		}
+	}
+	return ownerID, nil
+}
+
+// EmergencyStop stops every agent in an org, or on one of its workers, and
+// logs who did it.
+func (s *WorkerManagementService) EmergencyStop(
+	ctx context.Context,
+	req *connect.Request[leapmuxv1.EmergencyStopRequest],
+) (*connect.Response[leapmuxv1.EmergencyStopResponse], error) {
+	if err := requireAdmin(ctx); err != nil {
+		return nil, err
+	}
+	admin, err := auth.MustGetUser(ctx)
+	if err != nil {
+		return nil, err
+	}
+	reason := req.Msg.GetReason()
+	if utf8.RuneCountInString(reason) > maxEmergencyStopReasonLen {
+		return nil, connect.NewError(connect.CodeInvalidArgument, fmt.Errorf("reason exceeds %d characters", maxEmergencyStopReasonLen))
+	}
+	orgID, workerID := req.Msg.GetOrgId(), req.Msg.GetWorkerId()
+	ownerID, err := s.emergencyStopTarget(ctx, orgID, workerID)
+	if err != nil {
+		return nil, err
+	}
+
+	stop, err := s.store.EmergencyStops().Put(ctx, store.PutEmergencyStopParams{
+		OrgID:     orgID,
+		WorkerID:  workerID,
+		Reason:    reason,
+		StoppedBy: admin.ID.String(),
+	})
+	if err != nil {
+		return nil, connect.NewError(connect.CodeInternal, err)
+	}
+	stops, err := s.store.EmergencyStops().ListByOrg(ctx, orgID)
+	if err != nil {
+		return nil, connect.NewError(connect.CodeInternal, err)
+	}
+
+	slog.Warn("emergency stop",
+		"admin_id", admin.ID, "org_id", orgID, "worker_id", workerID, "reason", reason)
+	workerIDs, err := s.ownedWorkerIDs(ctx, ownerID, workerID)
+	if err != nil {
+		slog.Warn("failed to list workers for emergency stop push", "org_id", orgID, "error", err)
+	}
+	connected := s.pushEmergencyStops(workerIDs, stops)
+	return connect.NewResponse(&leapmuxv1.EmergencyStopResponse{
+		State:            emergencyStopToProto(stop),
+		ConnectedWorkers: connected,
+	}), nil
+}
+
+// ReleaseEmergencyStop lifts the stop EmergencyStop put on an org or a
+// worker, and logs who did it.
+func (s *WorkerManagementService) ReleaseEmergencyStop(
+	ctx context.Context,
+	req *connect.Request[leapmuxv1.ReleaseEmergencyStopRequest],
+) (*connect.Response[leapmuxv1.ReleaseEmergencyStopResponse], error) {
+	if err := requireAdmin(ctx); err != nil {
+		return nil, err
+	}
+	admin, err := auth.MustGetUser(ctx)
+	if err != nil {
+		return nil, err
+	}
+	orgID, workerID := req.Msg.GetOrgId(), req.Msg.GetWorkerId()
+	ownerID, err := s.emergencyStopTarget(ctx, orgID, workerID)
+	if err != nil {
+		return nil, err
+	}
+
+	if _, err := s.store.EmergencyStops().Delete(ctx, store.DeleteEmergencyStopParams{OrgID: orgID, WorkerID: workerID}); err != nil {
+		return nil, connect.NewError(connect.CodeInternal, err)
+	}
+	stops, err := s.store.EmergencyStops().ListByOrg(ctx, orgID)
+	if err != nil {
+		return nil, connect.NewError(connect.CodeInternal, err)
+	}
+
+	slog.Warn("emergency stop released",
+		"admin_id", admin.ID, "org_id", orgID, "worker_id", workerID)
+	workerIDs, err := s.ownedWorkerIDs(ctx, ownerID, workerID)
+	if err != nil {
+		slog.Warn("failed to list workers for emergency stop push", "org_id", orgID, "error", err)
+	}
+	connected := s.pushEmergencyStops(workerIDs, stops)
+	return connect.NewResponse(&leapmuxv1.ReleaseEmergencyStopResponse{ConnectedWorkers: connected}), nil
+}
+
+// ownedWorkerIDs lists the ids of the workers ownerID registered, or just
+// workerID when one is named.
+func (s *WorkerManagementService) ownedWorkerIDs(ctx context.Context, ownerID userid.UserID, workerID string) ([]string, error) {
+	if workerID != "" {
+		return []string{workerID}, nil
+	}
+	var ids []string
+	cursor := ""
+	for {
+		page, err := s.store.Workers().ListByUserID(ctx, store.ListWorkersByUserIDParams{
+			RegisteredBy: ownerID,
+			PageParams:   store.PageParams{Cursor: cursor, Limit: 100},
+		})
+		if err != nil {
+			return ids, err
+		}
+		for i := range page.Rows {
+			ids = append(ids, page.Rows[i].ID)
+		}
+		if !page.HasMore() {
+			return ids, nil
+		}
+		cursor = page.NextCursor
+	}
+}
+
+// pushEmergencyStops sends each connected worker in workerIDs the stop it
+// now runs under and returns how many were connected. Failures are logged,
+// not returned, as in pushToUserWorkers: the stop is stored, and a worker
+// that misses the push gets it on reconnect.
+func (s *WorkerManagementService) pushEmergencyStops(workerIDs []string, stops emergencyStops) int32 {
+	var connected int32
+	for _, id := range workerIDs {
+		conn := s.workerMgr.ConnForTrustedPath(id)
+		if conn == nil {
+			continue
+		}
+		state := stops.forWorker(id)
+		if state == nil {
+			state = &leapmuxv1.EmergencyStopState{}
+		}
+		if err := conn.Send(&leapmuxv1.ConnectResponse{
+			Payload: &leapmuxv1.ConnectResponse_EmergencyStop{EmergencyStop: state},
+		}); err != nil {
+			slog.Warn("failed to push emergency stop", "worker_id", id, "error", err)
+			continue
+		}
+		connected++
+	}
+	return connected
+}
@@ -0,0 +1,162 @@
+package service_test
+
+import (
+	"context"
+	"testing"
+
+	"connectrpc.com/connect"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	leapmuxv1 "github.com/leapmux/leapmux/generated/proto/leapmux/v1"
+	"github.com/leapmux/leapmux/internal/hub/auth"
+	"github.com/leapmux/leapmux/internal/hub/config"
+	"github.com/leapmux/leapmux/internal/hub/mail"
+	"github.com/leapmux/leapmux/internal/hub/service"
+	"github.com/leapmux/leapmux/internal/hub/store"
+	"github.com/leapmux/leapmux/internal/hub/testutil"
+	"github.com/leapmux/leapmux/internal/hub/workermgr"
+	"github.com/leapmux/leapmux/internal/util/userid"
+)
+
+type emergencyStopEnv struct {
+	st     store.Store
+	svc    *service.WorkerManagementService
+	orgID  string
+	owner  context.Context
+	admin  context.Context
+	pushed map[string]chan *leapmuxv1.EmergencyStopState
+}
+
+func setupEmergencyStop(t *testing.T, workerIDs ...string) *emergencyStopEnv {
+	t.Helper()
+	st := testutil.OpenTestStore(t)
+	uid := userid.MustNew(testutil.CreateTestUser(t, st, "stopped", "password123"))
+	user, err := st.Users().GetByID(context.Background(), uid.String())
+	require.NoError(t, err)
+	env := &emergencyStopEnv{
+		st:     st,
+		orgID:  user.OrgID,
+		owner:  auth.WithUser(context.Background(), &auth.UserInfo{ID: uid}),
+		admin:  auth.WithUser(context.Background(), &auth.UserInfo{ID: userid.MustNew("admin"), IsAdmin: true}),
+		pushed: map[string]chan *leapmuxv1.EmergencyStopState{},
+	}
+	mgr := workermgr.New(service.NewWorkerReachAuthorizer(st))
+	for _, id := range workerIDs {
+		require.NoError(t, st.Workers().Create(env.owner, store.CreateWorkerParams{
+			ID:              id,
+			AuthToken:       "token-" + id,
+			RegisteredBy:    uid,
+			PublicKey:       []byte("test-x25519-key-32-bytes-padding"),
+			MlkemPublicKey:  []byte("mlkem"),
+			SlhdsaPublicKey: []byte("slhdsa"),
+		}))
+		pushed := make(chan *leapmuxv1.EmergencyStopState, 4)
+		env.pushed[id] = pushed
+		_, err := mgr.Register(&workermgr.Conn{
+			WorkerID: id,
+			SendFn: func(msg *leapmuxv1.ConnectResponse) error {
+				pushed <- msg.GetEmergencyStop()
+				return nil
+			},
+		})
+		require.NoError(t, err)
+	}
+	env.svc = service.NewWorkerManagementService(st, mgr, nil, nil, nil, mail.Renderer{}, &config.Config{}, nil)
+	return env
+}
+
+func TestEmergencyStop_StopsAndReleasesOrgAndWorker(t *testing.T) {
+	env := setupEmergencyStop(t, "w-1", "w-2")
+
+	resp, err := env.svc.EmergencyStop(env.admin, connect.NewRequest(&leapmuxv1.EmergencyStopRequest{
+		OrgId:  env.orgID,
+		Reason: "runaway spend",
+	}))
+	require.NoError(t, err)
+	assert.EqualValues(t, 2, resp.Msg.GetConnectedWorkers())
+	assert.True(t, resp.Msg.GetState().GetActive())
+	assert.Equal(t, "admin", resp.Msg.GetState().GetStoppedBy())
+	for _, id := range []string{"w-1", "w-2"} {
+		got := <-env.pushed[id]
+		assert.True(t, got.GetActive(), id)
+		assert.Equal(t, "runaway spend", got.GetReason(), id)
+	}
+
+	// A worker's own stop outlives the org's.
+	resp, err = env.svc.EmergencyStop(env.admin, connect.NewRequest(&leapmuxv1.EmergencyStopRequest{
+		OrgId:    env.orgID,
+		WorkerId: "w-2",
+		Reason:   "deleting files",
+	}))
+	require.NoError(t, err)
+	assert.EqualValues(t, 1, resp.Msg.GetConnectedWorkers(), "only the named worker is pushed")
+	assert.Equal(t, "deleting files", (<-env.pushed["w-2"]).GetReason())
+	assert.Empty(t, env.pushed["w-1"])
+
+	released, err := env.svc.ReleaseEmergencyStop(env.admin, connect.NewRequest(&leapmuxv1.ReleaseEmergencyStopRequest{OrgId: env.orgID}))
+	require.NoError(t, err)
+	assert.EqualValues(t, 2, released.Msg.GetConnectedWorkers())
+	assert.False(t, (<-env.pushed["w-1"]).GetActive())
+	assert.Equal(t, "deleting files", (<-env.pushed["w-2"]).GetReason(), "w-2 stays stopped")
+
+	_, err = env.svc.ReleaseEmergencyStop(env.admin, connect.NewRequest(&leapmuxv1.ReleaseEmergencyStopRequest{OrgId: env.orgID, WorkerId: "w-2"}))
+	require.NoError(t, err)
+	assert.False(t, (<-env.pushed["w-2"]).GetActive())
+}
+
+func TestEmergencyStop_RequiresAdmin(t *testing.T) {
+	env := setupEmergencyStop(t, "w-1")
+
+	_, err := env.svc.EmergencyStop(env.owner, connect.NewRequest(&leapmuxv1.EmergencyStopRequest{OrgId: env.orgID}))
+	assert.Equal(t, connect.CodePermissionDenied, connect.CodeOf(err), "an org's member is not the admin")
+	_, err = env.svc.ReleaseEmergencyStop(env.owner, connect.NewRequest(&leapmuxv1.ReleaseEmergencyStopRequest{OrgId: env.orgID}))
+	assert.Equal(t, connect.CodePermissionDenied, connect.CodeOf(err))
+	assert.Empty(t, env.pushed["w-1"])
+}
+
+func TestEmergencyStop_RejectsUnknownTargets(t *testing.T) {
+	env := setupEmergencyStop(t, "w-1")
+	other := testutil.CreateTestUser(t, env.st, "other", "password123")
+	require.NoError(t, env.st.Workers().Create(env.owner, store.CreateWorkerParams{
+		ID:              "w-other",
+		AuthToken:       "token-w-other",
+		RegisteredBy:    userid.MustNew(other),
+		PublicKey:       []byte("test-x25519-key-32-bytes-padding"),
+		MlkemPublicKey:  []byte("mlkem"),
+		SlhdsaPublicKey: []byte("slhdsa"),
+	}))
+
+	_, err := env.svc.EmergencyStop(env.admin, connect.NewRequest(&leapmuxv1.EmergencyStopRequest{}))
+	assert.Equal(t, connect.CodeInvalidArgument, connect.CodeOf(err))
+	_, err = env.svc.EmergencyStop(env.admin, connect.NewRequest(&leapmuxv1.EmergencyStopRequest{OrgId: "no-such-org"}))
+	assert.Equal(t, connect.CodeNotFound, connect.CodeOf(err))
+	_, err = env.svc.EmergencyStop(env.admin, connect.NewRequest(&leapmuxv1.EmergencyStopRequest{OrgId: env.orgID, WorkerId: "w-other"}))
+	assert.Equal(t, connect.CodeNotFound, connect.CodeOf(err), "a worker of another org")
+	assert.Empty(t, env.pushed["w-1"])
+}
+
+func TestEmergencyStop_SurvivesPreferencesWrites(t *testing.T) {
+	env := setupEmergencyStop(t, "w-1")
+	_, err := env.svc.EmergencyStop(env.admin, connect.NewRequest(&leapmuxv1.EmergencyStopRequest{OrgId: env.orgID}))
+	require.NoError(t, err)
+	<-env.pushed["w-1"]
+
+	// Stops are not kept in the owner's preferences, so neither a blob
+	// that no longer decodes nor the writes that replace it touch them.
+	owner, err := auth.MustGetUser(env.owner)
+	require.NoError(t, err)
+	require.NoError(t, env.st.Users().UpdatePrefs(env.owner, store.UpdateUserPrefsParams{ID: owner.ID.String(), Prefs: "{not json"}))
+	users := service.NewUserService(env.st, &config.Config{}, auth.NewCredentialLifecycleEffects(nil, nil, nil), mail.NewStubSender(), mail.Renderer{})
+	_, err = users.UpdatePreferences(env.owner, connect.NewRequest(&leapmuxv1.UpdatePreferencesRequest{Theme: "dark"}))
+	require.NoError(t, err)
+	_, err = users.UpdateNotificationPreferences(env.owner, connect.NewRequest(&leapmuxv1.UpdateNotificationPreferencesRequest{
+		Preferences: &leapmuxv1.NotificationPreferences{},
+	}))
+	require.NoError(t, err)
+
+	// Releasing w-1's own (absent) stop re-sends it the org's.
+	_, err = env.svc.ReleaseEmergencyStop(env.admin, connect.NewRequest(&leapmuxv1.ReleaseEmergencyStopRequest{OrgId: env.orgID, WorkerId: "w-1"}))
+	require.NoError(t, err)
+	assert.True(t, (<-env.pushed["w-1"]).GetActive(), "the org's stop survived the preferences writes")
+}
@@ -0,0 +1,189 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"connectrpc.com/connect"
+
+	leapmuxv1 "github.com/leapmux/leapmux/generated/proto/leapmux/v1"
+	"github.com/leapmux/leapmux/internal/hub/auth"
+	"github.com/leapmux/leapmux/internal/hub/store"
+	"github.com/leapmux/leapmux/internal/util/id"
+	"github.com/leapmux/leapmux/internal/util/timefmt"
+	"github.com/leapmux/leapmux/util/validate"
+)
+
+// maxGuestInvitationsPerWorkspace caps how many live guest links one
+// workspace may have at a time.
+const maxGuestInvitationsPerWorkspace = 32
+
+// GuestInvitationService implements the GuestInvitationServiceHandler
+// interface. Only the workspace owner manages its guest links; a guest
+// cannot reach this service at all (see guestProcedureAllowed).
+type GuestInvitationService struct {
+	store     store.Store
+	validator *auth.TokenValidator
+	lifecycle *auth.CredentialLifecycleEffects
+}
+
+// NewGuestInvitationService creates a new GuestInvitationService. Revocation
+// is routed through lifecycle so a revoked link's channels close on this hub
+// at once, ahead of the revocation watcher.
+func NewGuestInvitationService(st store.Store, v *auth.TokenValidator, lifecycle *auth.CredentialLifecycleEffects) *GuestInvitationService {
+	if lifecycle == nil {
+		panic("guest invitation service requires credential lifecycle effects")
+	}
+	return &GuestInvitationService{store: st, validator: v, lifecycle: lifecycle}
+}
+
+func (s *GuestInvitationService) CreateGuestInvitation(
+	ctx context.Context,
+	req *connect.Request[leapmuxv1.CreateGuestInvitationRequest],
+) (*connect.Response[leapmuxv1.CreateGuestInvitationResponse], error) {
+	user, err := s.ownerCaller(ctx)
+	if err != nil {
+		return nil, err
+	}
+	ws, err := loadWorkspaceForRead(ctx, s.store, req.Msg.GetWorkspaceId(), user)
+	if err != nil {
+		return nil, err
+	}
+
+	role := req.Msg.GetRole()
+	if role != leapmuxv1.GuestRole_GUEST_ROLE_VIEWER && role != leapmuxv1.GuestRole_GUEST_ROLE_DRIVER {
+		return nil, connect.NewError(connect.CodeInvalidArgument, errors.New("role must be viewer or driver"))
+	}
+	ttl := time.Duration(req.Msg.GetTtlSeconds()) * time.Second
+	if ttl == 0 {
+		ttl = auth.GuestInvitationTTL
+	}
+	if ttl > auth.MaxGuestInvitationTTL {
+		return nil, connect.NewError(connect.CodeInvalidArgument, fmt.Errorf("ttl must be at most %s", auth.MaxGuestInvitationTTL))
+	}
+	var label string
+	if req.Msg.GetLabel() != "" {
+		if label, err = validate.SanitizeName(req.Msg.GetLabel()); err != nil {
+			return nil, connect.NewError(connect.CodeInvalidArgument, err)
+		}
+	}
+
+	live, err := s.store.GuestInvitations().ListActiveByWorkspace(ctx, ws.ID)
+	if err != nil {
+		return nil, connect.NewError(connect.CodeInternal, err)
+	}
+	if len(live) >= maxGuestInvitationsPerWorkspace {
+		return nil, connect.NewError(connect.CodeResourceExhausted,
+			fmt.Errorf("a workspace may have at most %d live guest links", maxGuestInvitationsPerWorkspace))
+	}
+
+	invitationID := id.Generate()
+	secret := auth.MintAccessSecret()
+	if err := s.store.GuestInvitations().Create(ctx, store.CreateGuestInvitationParams{
+		ID:          invitationID,
+		UserID:      user.ID,
+		WorkspaceID: ws.ID,
+		Role:        role,
+		Label:       label,
+		SecretHash:  s.validator.HashSecret(secret),
+		ExpiresAt:   time.Now().Add(ttl),
+	}); err != nil {
+		return nil, connect.NewError(connect.CodeInternal, err)
+	}
+	invitation, err := s.store.GuestInvitations().GetByID(ctx, invitationID)
+	if err != nil {
+		return nil, connect.NewError(connect.CodeInternal, err)
+	}
+
+	return connect.NewResponse(&leapmuxv1.CreateGuestInvitationResponse{
+		Invitation: guestInvitationToProto(invitation),
+		Token:      auth.FormatBearer(auth.BearerKindGuest, invitationID, secret),
+	}), nil
+}
+
+func (s *GuestInvitationService) ListGuestInvitations(
+	ctx context.Context,
+	req *connect.Request[leapmuxv1.ListGuestInvitationsRequest],
+) (*connect.Response[leapmuxv1.ListGuestInvitationsResponse], error) {
+	user, err := s.ownerCaller(ctx)
+	if err != nil {
+		return nil, err
+	}
+	ws, err := loadWorkspaceForRead(ctx, s.store, req.Msg.GetWorkspaceId(), user)
+	if err != nil {
+		return nil, err
+	}
+
+	invitations, err := s.store.GuestInvitations().ListActiveByWorkspace(ctx, ws.ID)
+	if err != nil {
+		return nil, connect.NewError(connect.CodeInternal, err)
+	}
+	pb := make([]*leapmuxv1.GuestInvitation, len(invitations))
+	for i := range invitations {
+		pb[i] = guestInvitationToProto(&invitations[i])
+	}
+	return connect.NewResponse(&leapmuxv1.ListGuestInvitationsResponse{Invitations: pb}), nil
+}
+
+func (s *GuestInvitationService) RevokeGuestInvitation(
+	ctx context.Context,
+	req *connect.Request[leapmuxv1.RevokeGuestInvitationRequest],
+) (*connect.Response[leapmuxv1.RevokeGuestInvitationResponse], error) {
+	user, err := s.ownerCaller(ctx)
+	if err != nil {
+		return nil, err
+	}
+	invitation, err := s.store.GuestInvitations().GetByID(ctx, req.Msg.GetInvitationId())
+	if errors.Is(err, store.ErrNotFound) {
+		return nil, connect.NewError(connect.CodeNotFound, errors.New("guest invitation not found"))
+	}
+	if err != nil {
+		return nil, connect.NewError(connect.CodeInternal, err)
+	}
+	// Another user's link reads as missing, so invitation IDs cannot be
+	// probed. The workspace check catches a link whose workspace has since
+	// changed hands.
+	if !user.ID.Matches(invitation.UserID) {
+		return nil, connect.NewError(connect.CodeNotFound, errors.New("guest invitation not found"))
+	}
+	if _, err := loadWorkspaceForRead(ctx, s.store, invitation.WorkspaceID, user); err != nil {
+		return nil, err
+	}
+
+	if _, err := s.store.GuestInvitations().Revoke(ctx, invitation.ID); err != nil {
+		return nil, connect.NewError(connect.CodeInternal, err)
+	}
+	s.lifecycle.BearerRevoked(auth.BearerKindGuest, invitation.ID)
+	return connect.NewResponse(&leapmuxv1.RevokeGuestInvitationResponse{}), nil
+}
+
+// ownerCaller returns the caller, refusing any workspace-scoped credential:
+// guest links are managed by the owner's own session or API token, never by
+// a bearer that is itself pinned to a workspace.
+func (s *GuestInvitationService) ownerCaller(ctx context.Context) (*auth.UserInfo, error) {
+	user, err := auth.MustGetUser(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if user.Credential.IsWorkspaceScoped() {
+		return nil, connect.NewError(connect.CodePermissionDenied, errors.New("guest links are managed by the workspace owner"))
+	}
+	return user, nil
+}
+
+func guestInvitationToProto(g *store.GuestInvitation) *leapmuxv1.GuestInvitation {
+	pb := &leapmuxv1.GuestInvitation{
+		Id:          g.ID,
+		WorkspaceId: g.WorkspaceID,
+		Role:        g.Role,
+		Label:       g.Label,
+		CreatedAt:   timefmt.Format(g.CreatedAt),
+		ExpiresAt:   timefmt.Format(g.ExpiresAt),
+	}
+	if g.LastUsedAt != nil {
+		pb.LastUsedAt = timefmt.Format(*g.LastUsedAt)
+	}
+	return pb
+}
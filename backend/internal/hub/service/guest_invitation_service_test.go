@@ -0,0 +1,171 @@
+package service_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"connectrpc.com/connect"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	leapmuxv1 "github.com/leapmux/leapmux/generated/proto/leapmux/v1"
+	"github.com/leapmux/leapmux/internal/hub/auth"
+	"github.com/leapmux/leapmux/internal/hub/service"
+	"github.com/leapmux/leapmux/internal/hub/store"
+	"github.com/leapmux/leapmux/internal/hub/store/storetest"
+	hubtestutil "github.com/leapmux/leapmux/internal/hub/testutil"
+	"github.com/leapmux/leapmux/internal/util/userid"
+)
+
+type guestInvitationEnv struct {
+	st        store.Store
+	validator *auth.TokenValidator
+	svc       *service.GuestInvitationService
+	ownerCtx  context.Context
+	otherCtx  context.Context
+	wsID      string
+}
+
+func setupGuestInvitationEnv(t *testing.T) guestInvitationEnv {
+	t.Helper()
+	st := hubtestutil.OpenTestStore(t)
+	orgID := storetest.SeedOrg(t, st, "guest-org")
+	owner := storetest.SeedUser(t, st, orgID, "owner")
+	other := storetest.SeedUser(t, st, storetest.SeedOrg(t, st, "other-org"), "other")
+	v, err := auth.NewTokenValidator(st, []byte("0123456789abcdef0123456789abcdef"))
+	require.NoError(t, err)
+	return guestInvitationEnv{
+		st:        st,
+		validator: v,
+		svc:       service.NewGuestInvitationService(st, v, auth.NewCredentialLifecycleEffects(nil, nil, nil)),
+		ownerCtx:  auth.WithUser(context.Background(), &auth.UserInfo{ID: userid.MustNew(owner.ID), OrgID: orgID}),
+		otherCtx:  auth.WithUser(context.Background(), &auth.UserInfo{ID: userid.MustNew(other.ID)}),
+		wsID:      storetest.SeedWorkspace(t, st, orgID, owner.ID, "Debugging"),
+	}
+}
+
+func TestGuestInvitationService_CreateListRevoke(t *testing.T) {
+	env := setupGuestInvitationEnv(t)
+
+	created, err := env.svc.CreateGuestInvitation(env.ownerCtx, connect.NewRequest(&leapmuxv1.CreateGuestInvitationRequest{
+		WorkspaceId: env.wsID,
+		Role:        leapmuxv1.GuestRole_GUEST_ROLE_VIEWER,
+		Label:       "consultant",
+		TtlSeconds:  uint32((30 * time.Minute).Seconds()),
+	}))
+	require.NoError(t, err)
+	invitation := created.Msg.GetInvitation()
+	assert.Equal(t, env.wsID, invitation.GetWorkspaceId())
+	assert.Equal(t, "consultant", invitation.GetLabel())
+	assert.Empty(t, invitation.GetLastUsedAt())
+
+	guest, err := env.validator.ValidateBearer(context.Background(), created.Msg.GetToken())
+	require.NoError(t, err, "the returned token authenticates")
+	assert.True(t, guest.Credential.IsReadOnly())
+	assert.Equal(t, env.wsID, guest.Credential.WorkspaceScopeID())
+	expiresAt, ok := guest.CredentialExpiresAt.At()
+	require.True(t, ok, "a guest's channels close at the link's expiry")
+	assert.WithinDuration(t, time.Now().Add(30*time.Minute), expiresAt, time.Minute)
+
+	listed, err := env.svc.ListGuestInvitations(env.ownerCtx, connect.NewRequest(&leapmuxv1.ListGuestInvitationsRequest{WorkspaceId: env.wsID}))
+	require.NoError(t, err)
+	require.Len(t, listed.Msg.GetInvitations(), 1)
+	assert.Equal(t, invitation.GetId(), listed.Msg.GetInvitations()[0].GetId())
+	assert.NotEmpty(t, listed.Msg.GetInvitations()[0].GetLastUsedAt(), "validating the token touches the link")
+
+	_, err = env.svc.RevokeGuestInvitation(env.ownerCtx, connect.NewRequest(&leapmuxv1.RevokeGuestInvitationRequest{InvitationId: invitation.GetId()}))
+	require.NoError(t, err)
+	_, err = env.validator.ValidateBearer(context.Background(), created.Msg.GetToken())
+	require.ErrorIs(t, err, auth.ErrTokenRevoked)
+	listed, err = env.svc.ListGuestInvitations(env.ownerCtx, connect.NewRequest(&leapmuxv1.ListGuestInvitationsRequest{WorkspaceId: env.wsID}))
+	require.NoError(t, err)
+	assert.Empty(t, listed.Msg.GetInvitations())
+}
+
+func TestGuestInvitationService_DefaultTTL(t *testing.T) {
+	env := setupGuestInvitationEnv(t)
+
+	created, err := env.svc.CreateGuestInvitation(env.ownerCtx, connect.NewRequest(&leapmuxv1.CreateGuestInvitationRequest{
+		WorkspaceId: env.wsID,
+		Role:        leapmuxv1.GuestRole_GUEST_ROLE_DRIVER,
+	}))
+	require.NoError(t, err)
+	row, err := env.st.GuestInvitations().GetByID(context.Background(), created.Msg.GetInvitation().GetId())
+	require.NoError(t, err)
+	assert.WithinDuration(t, time.Now().Add(auth.GuestInvitationTTL), row.ExpiresAt, time.Minute)
+}
+
+func TestGuestInvitationService_RejectsBadRequests(t *testing.T) {
+	env := setupGuestInvitationEnv(t)
+
+	for _, tc := range []struct {
+		name string
+		req  *leapmuxv1.CreateGuestInvitationRequest
+		code connect.Code
+	}{
+		{"unspecified role", &leapmuxv1.CreateGuestInvitationRequest{WorkspaceId: env.wsID}, connect.CodeInvalidArgument},
+		{"ttl over the cap", &leapmuxv1.CreateGuestInvitationRequest{
+			WorkspaceId: env.wsID,
+			Role:        leapmuxv1.GuestRole_GUEST_ROLE_VIEWER,
+			TtlSeconds:  uint32((auth.MaxGuestInvitationTTL + time.Second).Seconds()),
+		}, connect.CodeInvalidArgument},
+		{"missing workspace", &leapmuxv1.CreateGuestInvitationRequest{
+			WorkspaceId: "missing",
+			Role:        leapmuxv1.GuestRole_GUEST_ROLE_VIEWER,
+		}, connect.CodeNotFound},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			_, err := env.svc.CreateGuestInvitation(env.ownerCtx, connect.NewRequest(tc.req))
+			assert.Equal(t, tc.code, connect.CodeOf(err))
+		})
+	}
+}
+
+func TestGuestInvitationService_OwnerOnly(t *testing.T) {
+	env := setupGuestInvitationEnv(t)
+	created, err := env.svc.CreateGuestInvitation(env.ownerCtx, connect.NewRequest(&leapmuxv1.CreateGuestInvitationRequest{
+		WorkspaceId: env.wsID,
+		Role:        leapmuxv1.GuestRole_GUEST_ROLE_DRIVER,
+	}))
+	require.NoError(t, err)
+	invitationID := created.Msg.GetInvitation().GetId()
+
+	_, err = env.svc.CreateGuestInvitation(env.otherCtx, connect.NewRequest(&leapmuxv1.CreateGuestInvitationRequest{
+		WorkspaceId: env.wsID,
+		Role:        leapmuxv1.GuestRole_GUEST_ROLE_DRIVER,
+	}))
+	assert.Equal(t, connect.CodePermissionDenied, connect.CodeOf(err))
+	_, err = env.svc.ListGuestInvitations(env.otherCtx, connect.NewRequest(&leapmuxv1.ListGuestInvitationsRequest{WorkspaceId: env.wsID}))
+	assert.Equal(t, connect.CodePermissionDenied, connect.CodeOf(err))
+	_, err = env.svc.RevokeGuestInvitation(env.otherCtx, connect.NewRequest(&leapmuxv1.RevokeGuestInvitationRequest{InvitationId: invitationID}))
+	assert.Equal(t, connect.CodeNotFound, connect.CodeOf(err), "another user's link reads as missing")
+
+	// A guest acts as the owner but must not mint further links.
+	guest, err := env.validator.ValidateBearer(context.Background(), created.Msg.GetToken())
+	require.NoError(t, err)
+	_, err = env.svc.CreateGuestInvitation(auth.WithUser(context.Background(), guest), connect.NewRequest(&leapmuxv1.CreateGuestInvitationRequest{
+		WorkspaceId: env.wsID,
+		Role:        leapmuxv1.GuestRole_GUEST_ROLE_DRIVER,
+	}))
+	assert.Equal(t, connect.CodePermissionDenied, connect.CodeOf(err))
+
+	row, err := env.st.GuestInvitations().GetByID(context.Background(), invitationID)
+	require.NoError(t, err)
+	assert.Nil(t, row.RevokedAt)
+}
+
+func TestGuestInvitationService_RevokeDeniesZeroCaller(t *testing.T) {
+	env := setupGuestInvitationEnv(t)
+	created, err := env.svc.CreateGuestInvitation(env.ownerCtx, connect.NewRequest(&leapmuxv1.CreateGuestInvitationRequest{
+		WorkspaceId: env.wsID,
+		Role:        leapmuxv1.GuestRole_GUEST_ROLE_VIEWER,
+	}))
+	require.NoError(t, err)
+
+	zeroCtx := auth.WithUser(context.Background(), &auth.UserInfo{})
+	_, err = env.svc.RevokeGuestInvitation(zeroCtx, connect.NewRequest(&leapmuxv1.RevokeGuestInvitationRequest{
+		InvitationId: created.Msg.GetInvitation().GetId(),
+	}))
+	assert.Equal(t, connect.CodeNotFound, connect.CodeOf(err))
+}
@@ -57,6 +57,37 @@ func (b *HubEventBroadcaster) NotifyWorkersChanged(userID string) {
 	b.enqueue(userID, leapmuxv1.HubControlEvent_HUB_CONTROL_EVENT_WORKERS_CHANGED)
 }
 
+// NotifySectionsChanged schedules a SectionsChanged event for the specified
+// user, so their other tabs and devices re-fetch the sidebar sections.
+func (b *HubEventBroadcaster) NotifySectionsChanged(userID string) {
+	if b == nil || b.cMgr == nil {
+		return
+	}
+	b.enqueue(userID, leapmuxv1.HubControlEvent_HUB_CONTROL_EVENT_SECTIONS_CHANGED)
+}
+
+// NotifyShuttingDown tells every connected frontend, at once rather than
+// after the debounce window, that the Hub is going away and to wait
+// retryDelaySeconds before reconnecting.
+func (b *HubEventBroadcaster) NotifyShuttingDown(retryDelaySeconds uint32) {
+	if b == nil || b.cMgr == nil {
+		return
+	}
+	data, err := proto.Marshal(&leapmuxv1.HubControlFrame{
+		Events:            []leapmuxv1.HubControlEvent{leapmuxv1.HubControlEvent_HUB_CONTROL_EVENT_SHUTTING_DOWN},
+		RetryDelaySeconds: retryDelaySeconds,
+	})
+	if err != nil {
+		slog.Error("failed to marshal HubControlFrame", "error", err)
+		return
+	}
+	b.cMgr.SendToAllUsers(&leapmuxv1.ChannelMessage{
+		ProtocolVersion: 1,
+		ChannelId:       channelmgr.HubControlChannelID,
+		Ciphertext:      data,
+	})
+}
+
 // enqueue adds an event for the given user and resets the debounce timer.
 func (b *HubEventBroadcaster) enqueue(userID string, evt leapmuxv1.HubControlEvent) {
 	b.mu.Lock()
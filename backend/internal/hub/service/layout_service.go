@@ -0,0 +1,341 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math"
+
+	"connectrpc.com/connect"
+	"google.golang.org/protobuf/proto"
+
+	leapmuxv1 "github.com/leapmux/leapmux/generated/proto/leapmux/v1"
+	"github.com/leapmux/leapmux/internal/hub/auth"
+	"github.com/leapmux/leapmux/internal/hub/crdt"
+	"github.com/leapmux/leapmux/internal/hub/store"
+	"github.com/leapmux/leapmux/internal/util/id"
+	"github.com/leapmux/leapmux/util/validate"
+)
+
+const (
+	// maxLayoutPresetsPerWorkspace caps how many presets one user may keep
+	// for a single workspace.
+	maxLayoutPresetsPerWorkspace = 32
+	// maxLayoutPresetNodes and maxLayoutPresetDepth bound a preset tree so a
+	// stored blob stays small and the frontend's recursive apply terminates
+	// quickly. Both are far above anything the layout UI can build.
+	maxLayoutPresetNodes = 512
+	maxLayoutPresetDepth = 16
+)
+
+// LayoutService implements the LayoutServiceHandler interface. Presets are
+// per user and per workspace; the workspace itself gates access through
+// loadWorkspaceForRead.
+type LayoutService struct {
+	store store.Store
+}
+
+// NewLayoutService creates a new LayoutService.
+func NewLayoutService(st store.Store) *LayoutService {
+	return &LayoutService{store: st}
+}
+
+func (s *LayoutService) ListLayoutPresets(
+	ctx context.Context,
+	req *connect.Request[leapmuxv1.ListLayoutPresetsRequest],
+) (*connect.Response[leapmuxv1.ListLayoutPresetsResponse], error) {
+	user, err := auth.MustGetUser(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := loadWorkspaceForRead(ctx, s.store, req.Msg.GetWorkspaceId(), user); err != nil {
+		return nil, err
+	}
+
+	scope := store.ListWorkspaceLayoutPresetsParams{UserID: user.ID, WorkspaceID: req.Msg.GetWorkspaceId()}
+	presets, err := s.store.WorkspaceLayoutPresets().ListByWorkspace(ctx, scope)
+	if err != nil {
+		return nil, connect.NewError(connect.CodeInternal, err)
+	}
+	active, err := s.store.WorkspaceLayoutPresets().ListActive(ctx, scope)
+	if err != nil {
+		return nil, connect.NewError(connect.CodeInternal, err)
+	}
+
+	pbPresets := make([]*leapmuxv1.LayoutPreset, 0, len(presets))
+	for i := range presets {
+		pb, err := layoutPresetToProto(&presets[i])
+		if err != nil {
+			return nil, connect.NewError(connect.CodeInternal, err)
+		}
+		pbPresets = append(pbPresets, pb)
+	}
+	pbActive := make([]*leapmuxv1.ActiveLayout, len(active))
+	for i, a := range active {
+		pbActive[i] = &leapmuxv1.ActiveLayout{DeviceClass: a.DeviceClass, LayoutId: a.LayoutID}
+	}
+
+	return connect.NewResponse(&leapmuxv1.ListLayoutPresetsResponse{
+		Presets: pbPresets,
+		Active:  pbActive,
+	}), nil
+}
+
+func (s *LayoutService) SaveLayoutPreset(
+	ctx context.Context,
+	req *connect.Request[leapmuxv1.SaveLayoutPresetRequest],
+) (*connect.Response[leapmuxv1.SaveLayoutPresetResponse], error) {
+	user, err := auth.MustGetUser(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	name, err := validate.SanitizeName(req.Msg.GetName())
+	if err != nil {
+		return nil, connect.NewError(connect.CodeInvalidArgument, fmt.Errorf("name: %w", err))
+	}
+	if err := validateLayoutPresetTree(req.Msg.GetRoot()); err != nil {
+		return nil, connect.NewError(connect.CodeInvalidArgument, fmt.Errorf("root: %w", err))
+	}
+	if _, err := loadWorkspaceForRead(ctx, s.store, req.Msg.GetWorkspaceId(), user); err != nil {
+		return nil, err
+	}
+
+	// Replacing an existing preset never grows the set, so the cap only
+	// applies to a name this workspace has not seen yet.
+	scope := store.ListWorkspaceLayoutPresetsParams{UserID: user.ID, WorkspaceID: req.Msg.GetWorkspaceId()}
+	existing, err := s.store.WorkspaceLayoutPresets().ListByWorkspace(ctx, scope)
+	if err != nil {
+		return nil, connect.NewError(connect.CodeInternal, err)
+	}
+	isNew := true
+	for _, p := range existing {
+		if p.Name == name {
+			isNew = false
+			break
+		}
+	}
+	if isNew && len(existing) >= maxLayoutPresetsPerWorkspace {
+		return nil, connect.NewError(connect.CodeResourceExhausted,
+			fmt.Errorf("a workspace can hold at most %d layout presets", maxLayoutPresetsPerWorkspace))
+	}
+
+	root, err := proto.Marshal(req.Msg.GetRoot())
+	if err != nil {
+		return nil, connect.NewError(connect.CodeInternal, fmt.Errorf("marshal layout: %w", err))
+	}
+	preset, err := s.store.WorkspaceLayoutPresets().Upsert(ctx, store.UpsertWorkspaceLayoutPresetParams{
+		ID:          id.Generate(),
+		UserID:      user.ID,
+		WorkspaceID: req.Msg.GetWorkspaceId(),
+		Name:        name,
+		Root:        root,
+	})
+	if err != nil {
+		return nil, connect.NewError(connect.CodeInternal, fmt.Errorf("save layout preset: %w", err))
+	}
+
+	pb, err := layoutPresetToProto(preset)
+	if err != nil {
+		return nil, connect.NewError(connect.CodeInternal, err)
+	}
+	return connect.NewResponse(&leapmuxv1.SaveLayoutPresetResponse{Preset: pb}), nil
+}
+
+func (s *LayoutService) DeleteLayoutPreset(
+	ctx context.Context,
+	req *connect.Request[leapmuxv1.DeleteLayoutPresetRequest],
+) (*connect.Response[leapmuxv1.DeleteLayoutPresetResponse], error) {
+	user, err := auth.MustGetUser(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := loadWorkspaceForRead(ctx, s.store, req.Msg.GetWorkspaceId(), user); err != nil {
+		return nil, err
+	}
+	if _, err := s.requireWorkspacePreset(ctx, user, req.Msg.GetWorkspaceId(), req.Msg.GetLayoutId()); err != nil {
+		return nil, err
+	}
+
+	// Selections pointing at the preset go with it (FK cascade), so every
+	// device class that showed it falls back to the default.
+	if _, err := s.store.WorkspaceLayoutPresets().Delete(ctx, store.DeleteWorkspaceLayoutPresetParams{
+		ID:     req.Msg.GetLayoutId(),
+		UserID: user.ID,
+	}); err != nil {
+		return nil, connect.NewError(connect.CodeInternal, fmt.Errorf("delete layout preset: %w", err))
+	}
+
+	return connect.NewResponse(&leapmuxv1.DeleteLayoutPresetResponse{}), nil
+}
+
+func (s *LayoutService) SetActiveLayout(
+	ctx context.Context,
+	req *connect.Request[leapmuxv1.SetActiveLayoutRequest],
+) (*connect.Response[leapmuxv1.SetActiveLayoutResponse], error) {
+	user, err := auth.MustGetUser(ctx)
+	if err != nil {
+		return nil, err
+	}
+	deviceClass := req.Msg.GetDeviceClass()
+	if _, ok := leapmuxv1.DeviceClass_name[int32(deviceClass)]; !ok {
+		return nil, connect.NewError(connect.CodeInvalidArgument, fmt.Errorf("unknown device class %d", deviceClass))
+	}
+	if _, err := loadWorkspaceForRead(ctx, s.store, req.Msg.GetWorkspaceId(), user); err != nil {
+		return nil, err
+	}
+
+	if req.Msg.GetLayoutId() == "" {
+		if err := s.store.WorkspaceLayoutPresets().ClearActive(ctx, store.ClearWorkspaceLayoutSelectionParams{
+			UserID:      user.ID,
+			WorkspaceID: req.Msg.GetWorkspaceId(),
+			DeviceClass: deviceClass,
+		}); err != nil {
+			return nil, connect.NewError(connect.CodeInternal, fmt.Errorf("clear active layout: %w", err))
+		}
+		return connect.NewResponse(&leapmuxv1.SetActiveLayoutResponse{}), nil
+	}
+
+	if _, err := s.requireWorkspacePreset(ctx, user, req.Msg.GetWorkspaceId(), req.Msg.GetLayoutId()); err != nil {
+		return nil, err
+	}
+	if err := s.store.WorkspaceLayoutPresets().SetActive(ctx, store.SetWorkspaceLayoutSelectionParams{
+		UserID:      user.ID,
+		WorkspaceID: req.Msg.GetWorkspaceId(),
+		DeviceClass: deviceClass,
+		LayoutID:    req.Msg.GetLayoutId(),
+	}); err != nil {
+		return nil, connect.NewError(connect.CodeInternal, fmt.Errorf("set active layout: %w", err))
+	}
+
+	return connect.NewResponse(&leapmuxv1.SetActiveLayoutResponse{}), nil
+}
+
+// requireWorkspacePreset loads one of the caller's presets and checks that it
+// belongs to workspaceID. A preset of another user or another workspace is
+// NotFound, so a caller cannot probe preset ids across either boundary.
+func (s *LayoutService) requireWorkspacePreset(ctx context.Context, user *auth.UserInfo, workspaceID, layoutID string) (*store.WorkspaceLayoutPreset, error) {
+	if layoutID == "" {
+		return nil, connect.NewError(connect.CodeInvalidArgument, errors.New("layout_id is required"))
+	}
+	preset, err := s.store.WorkspaceLayoutPresets().GetByID(ctx, store.GetWorkspaceLayoutPresetParams{
+		ID:     layoutID,
+		UserID: user.ID,
+	})
+	if err != nil {
+		if errors.Is(err, store.ErrNotFound) {
+			return nil, connect.NewError(connect.CodeNotFound, errors.New("layout preset not found"))
+		}
+		return nil, connect.NewError(connect.CodeInternal, err)
+	}
+	if preset.WorkspaceID != workspaceID {
+		return nil, connect.NewError(connect.CodeNotFound, errors.New("layout preset not found"))
+	}
+	return preset, nil
+}
+
+func layoutPresetToProto(p *store.WorkspaceLayoutPreset) (*leapmuxv1.LayoutPreset, error) {
+	root := &leapmuxv1.LayoutPresetNode{}
+	if err := proto.Unmarshal(p.Root, root); err != nil {
+		return nil, fmt.Errorf("unmarshal layout preset %s: %w", p.ID, err)
+	}
+	return &leapmuxv1.LayoutPreset{
+		Id:          p.ID,
+		WorkspaceId: p.WorkspaceID,
+		Name:        p.Name,
+		Root:        root,
+		CreatedAt:   p.CreatedAt.UTC().Format("2006-01-02T15:04:05.000Z"),
+		UpdatedAt:   p.UpdatedAt.UTC().Format("2006-01-02T15:04:05.000Z"),
+	}, nil
+}
+
+// validateLayoutPresetTree checks a preset tree against the same shape rules
+// the CRDT enforces on live nodes: a leaf holds tabs and no children, a split
+// has one ratio per child, and a grid has rows×cols children with matching
+// ratio lists. A tab id may appear only once across the whole tree.
+func validateLayoutPresetTree(root *leapmuxv1.LayoutPresetNode) error {
+	if root == nil {
+		return errors.New("is required")
+	}
+	nodes := 0
+	tabs := make(map[string]struct{})
+	var walk func(n *leapmuxv1.LayoutPresetNode, depth int) error
+	walk = func(n *leapmuxv1.LayoutPresetNode, depth int) error {
+		nodes++
+		if nodes > maxLayoutPresetNodes {
+			return fmt.Errorf("more than %d nodes", maxLayoutPresetNodes)
+		}
+		if depth > maxLayoutPresetDepth {
+			return fmt.Errorf("deeper than %d levels", maxLayoutPresetDepth)
+		}
+		switch n.GetKind() {
+		case leapmuxv1.NodeKind_NODE_KIND_LEAF:
+			if len(n.GetChildren()) > 0 {
+				return errors.New("leaf node has children")
+			}
+			for _, tabID := range n.GetTabIds() {
+				if tabID == "" {
+					return errors.New("empty tab id")
+				}
+				if _, dup := tabs[tabID]; dup {
+					return fmt.Errorf("tab %s appears more than once", tabID)
+				}
+				tabs[tabID] = struct{}{}
+			}
+			return nil
+		case leapmuxv1.NodeKind_NODE_KIND_SPLIT:
+			switch n.GetDirection() {
+			case leapmuxv1.SplitDirection_SPLIT_DIRECTION_HORIZONTAL, leapmuxv1.SplitDirection_SPLIT_DIRECTION_VERTICAL:
+			default:
+				return errors.New("split node needs a direction")
+			}
+			if len(n.GetChildren()) < 2 {
+				return errors.New("split node needs at least two children")
+			}
+			if len(n.GetRatios()) != len(n.GetChildren()) {
+				return errors.New("split ratios do not match its children")
+			}
+			if err := validateLayoutRatios(n.GetRatios()); err != nil {
+				return err
+			}
+		case leapmuxv1.NodeKind_NODE_KIND_GRID:
+			rows, cols := n.GetRows(), n.GetCols()
+			if rows == 0 || cols == 0 || rows > crdt.MaxGridDimension || cols > crdt.MaxGridDimension {
+				return fmt.Errorf("grid dimensions must be between 1 and %d", crdt.MaxGridDimension)
+			}
+			if uint32(len(n.GetChildren())) != rows*cols {
+				return errors.New("grid children do not fill its cells")
+			}
+			if uint32(len(n.GetRowRatios())) != rows || uint32(len(n.GetColRatios())) != cols {
+				return errors.New("grid ratios do not match its dimensions")
+			}
+			if err := validateLayoutRatios(n.GetRowRatios()); err != nil {
+				return err
+			}
+			if err := validateLayoutRatios(n.GetColRatios()); err != nil {
+				return err
+			}
+		default:
+			return fmt.Errorf("unknown node kind %d", n.GetKind())
+		}
+		if len(n.GetTabIds()) > 0 {
+			return errors.New("only leaf nodes hold tabs")
+		}
+		for _, child := range n.GetChildren() {
+			if err := walk(child, depth+1); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+	return walk(root, 1)
+}
+
+func validateLayoutRatios(ratios []float64) error {
+	for _, r := range ratios {
+		if math.IsNaN(r) || math.IsInf(r, 0) || r <= 0 {
+			return errors.New("ratios must be finite and positive")
+		}
+	}
+	return nil
+}
@@ -0,0 +1,365 @@
+package service_test
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"connectrpc.com/connect"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	leapmuxv1 "github.com/leapmux/leapmux/generated/proto/leapmux/v1"
+	"github.com/leapmux/leapmux/generated/proto/leapmux/v1/leapmuxv1connect"
+	"github.com/leapmux/leapmux/internal/hub/auth"
+	"github.com/leapmux/leapmux/internal/hub/password"
+	"github.com/leapmux/leapmux/internal/hub/service"
+	"github.com/leapmux/leapmux/internal/hub/store"
+	"github.com/leapmux/leapmux/internal/hub/store/sqlite"
+	"github.com/leapmux/leapmux/internal/hub/store/storetest"
+	"github.com/leapmux/leapmux/internal/util/id"
+	"github.com/leapmux/leapmux/internal/util/sqlitedb"
+)
+
+type layoutTestEnv struct {
+	client      leapmuxv1connect.LayoutServiceClient
+	store       store.Store
+	token       string
+	orgID       string
+	userID      string
+	workspaceID string
+}
+
+func setupLayoutTest(t *testing.T) *layoutTestEnv {
+	t.Helper()
+
+	st, err := sqlite.Open(":memory:", sqlitedb.Config{})
+	require.NoError(t, err)
+	t.Cleanup(func() { _ = st.Close() })
+	require.NoError(t, st.Migrator().Migrate(context.Background()))
+
+	mux := http.NewServeMux()
+	interceptor, _ := auth.NewInterceptor(st, nil, false, false)
+	path, handler := leapmuxv1connect.NewLayoutServiceHandler(service.NewLayoutService(st), connect.WithInterceptors(interceptor))
+	mux.Handle(path, handler)
+
+	server := httptest.NewUnstartedServer(mux)
+	server.EnableHTTP2 = true
+	server.StartTLS()
+	t.Cleanup(server.Close)
+
+	client := leapmuxv1connect.NewLayoutServiceClient(server.Client(), server.URL, connect.WithGRPC())
+
+	orgID := storetest.SeedOrg(t, st, "layout-org")
+	userID := seedLoginUser(t, st, orgID, "layoutuser")
+	token, _, _, err := auth.Login(context.Background(), st, "layoutuser", "testpass")
+	require.NoError(t, err)
+
+	return &layoutTestEnv{
+		client:      client,
+		store:       st,
+		token:       token,
+		orgID:       orgID,
+		userID:      userID,
+		workspaceID: storetest.SeedWorkspace(t, st, orgID, userID, "Layout WS"),
+	}
+}
+
+func seedLoginUser(t *testing.T, st store.Store, orgID, username string) string {
+	t.Helper()
+	userID := id.Generate()
+	hash, err := password.Hash("testpass")
+	require.NoError(t, err)
+	require.NoError(t, st.Users().Create(context.Background(), store.CreateUserParams{
+		ID:           userID,
+		OrgID:        orgID,
+		Username:     username,
+		PasswordHash: hash,
+		DisplayName:  username,
+		PasswordSet:  true,
+	}))
+	return userID
+}
+
+func twoPaneLayout() *leapmuxv1.LayoutPresetNode {
+	return &leapmuxv1.LayoutPresetNode{
+		Kind:      leapmuxv1.NodeKind_NODE_KIND_SPLIT,
+		Direction: leapmuxv1.SplitDirection_SPLIT_DIRECTION_HORIZONTAL,
+		Ratios:    []float64{0.5, 0.5},
+		Children: []*leapmuxv1.LayoutPresetNode{
+			{Kind: leapmuxv1.NodeKind_NODE_KIND_LEAF, TabIds: []string{"tab-a"}},
+			{Kind: leapmuxv1.NodeKind_NODE_KIND_LEAF, TabIds: []string{"tab-b", "tab-c"}},
+		},
+	}
+}
+
+func (env *layoutTestEnv) save(t *testing.T, name string, root *leapmuxv1.LayoutPresetNode) *leapmuxv1.LayoutPreset {
+	t.Helper()
+	resp, err := env.client.SaveLayoutPreset(context.Background(), authedReq(&leapmuxv1.SaveLayoutPresetRequest{
+		WorkspaceId: env.workspaceID,
+		Name:        name,
+		Root:        root,
+	}, env.token))
+	require.NoError(t, err)
+	return resp.Msg.GetPreset()
+}
+
+func (env *layoutTestEnv) list(t *testing.T) *leapmuxv1.ListLayoutPresetsResponse {
+	t.Helper()
+	resp, err := env.client.ListLayoutPresets(context.Background(), authedReq(&leapmuxv1.ListLayoutPresetsRequest{
+		WorkspaceId: env.workspaceID,
+	}, env.token))
+	require.NoError(t, err)
+	return resp.Msg
+}
+
+func TestLayoutService_SaveAndList(t *testing.T) {
+	env := setupLayoutTest(t)
+
+	review := env.save(t, "Review", twoPaneLayout())
+	assert.NotEmpty(t, review.GetId())
+	assert.Equal(t, env.workspaceID, review.GetWorkspaceId())
+	assert.Equal(t, "Review", review.GetName())
+	assert.NotEmpty(t, review.GetCreatedAt())
+	env.save(t, "Debugging", &leapmuxv1.LayoutPresetNode{Kind: leapmuxv1.NodeKind_NODE_KIND_LEAF})
+
+	listed := env.list(t)
+	require.Len(t, listed.GetPresets(), 2)
+	assert.Equal(t, "Debugging", listed.GetPresets()[0].GetName())
+	assert.Equal(t, "Review", listed.GetPresets()[1].GetName())
+	assert.Equal(t, []string{"tab-b", "tab-c"}, listed.GetPresets()[1].GetRoot().GetChildren()[1].GetTabIds())
+	assert.Empty(t, listed.GetActive())
+}
+
+func TestLayoutService_SaveSameNameReplacesTree(t *testing.T) {
+	env := setupLayoutTest(t)
+
+	first := env.save(t, "Review", twoPaneLayout())
+	second := env.save(t, "Review", &leapmuxv1.LayoutPresetNode{
+		Kind:   leapmuxv1.NodeKind_NODE_KIND_LEAF,
+		TabIds: []string{"tab-a"},
+	})
+	assert.Equal(t, first.GetId(), second.GetId())
+	assert.Equal(t, leapmuxv1.NodeKind_NODE_KIND_LEAF, second.GetRoot().GetKind())
+
+	listed := env.list(t)
+	require.Len(t, listed.GetPresets(), 1)
+	assert.Equal(t, leapmuxv1.NodeKind_NODE_KIND_LEAF, listed.GetPresets()[0].GetRoot().GetKind())
+}
+
+func TestLayoutService_SaveRejectsInvalidInput(t *testing.T) {
+	env := setupLayoutTest(t)
+	leaf := func(tabs ...string) *leapmuxv1.LayoutPresetNode {
+		return &leapmuxv1.LayoutPresetNode{Kind: leapmuxv1.NodeKind_NODE_KIND_LEAF, TabIds: tabs}
+	}
+
+	tests := []struct {
+		name   string
+		preset string
+		root   *leapmuxv1.LayoutPresetNode
+	}{
+		{"empty name", "  ", leaf()},
+		{"missing root", "x", nil},
+		{"unknown kind", "x", &leapmuxv1.LayoutPresetNode{}},
+		{"leaf with children", "x", &leapmuxv1.LayoutPresetNode{
+			Kind:     leapmuxv1.NodeKind_NODE_KIND_LEAF,
+			Children: []*leapmuxv1.LayoutPresetNode{leaf()},
+		}},
+		{"split ratio mismatch", "x", &leapmuxv1.LayoutPresetNode{
+			Kind:      leapmuxv1.NodeKind_NODE_KIND_SPLIT,
+			Direction: leapmuxv1.SplitDirection_SPLIT_DIRECTION_VERTICAL,
+			Ratios:    []float64{1},
+			Children:  []*leapmuxv1.LayoutPresetNode{leaf(), leaf()},
+		}},
+		{"split without direction", "x", &leapmuxv1.LayoutPresetNode{
+			Kind:     leapmuxv1.NodeKind_NODE_KIND_SPLIT,
+			Ratios:   []float64{0.5, 0.5},
+			Children: []*leapmuxv1.LayoutPresetNode{leaf(), leaf()},
+		}},
+		{"negative ratio", "x", &leapmuxv1.LayoutPresetNode{
+			Kind:      leapmuxv1.NodeKind_NODE_KIND_SPLIT,
+			Direction: leapmuxv1.SplitDirection_SPLIT_DIRECTION_VERTICAL,
+			Ratios:    []float64{1.5, -0.5},
+			Children:  []*leapmuxv1.LayoutPresetNode{leaf(), leaf()},
+		}},
+		{"grid cells missing", "x", &leapmuxv1.LayoutPresetNode{
+			Kind:      leapmuxv1.NodeKind_NODE_KIND_GRID,
+			Rows:      2,
+			Cols:      2,
+			RowRatios: []float64{0.5, 0.5},
+			ColRatios: []float64{0.5, 0.5},
+			Children:  []*leapmuxv1.LayoutPresetNode{leaf(), leaf(), leaf()},
+		}},
+		{"grid too large", "x", &leapmuxv1.LayoutPresetNode{
+			Kind: leapmuxv1.NodeKind_NODE_KIND_GRID,
+			Rows: 21,
+			Cols: 1,
+		}},
+		{"tabs on a split", "x", &leapmuxv1.LayoutPresetNode{
+			Kind:      leapmuxv1.NodeKind_NODE_KIND_SPLIT,
+			Direction: leapmuxv1.SplitDirection_SPLIT_DIRECTION_VERTICAL,
+			Ratios:    []float64{0.5, 0.5},
+			Children:  []*leapmuxv1.LayoutPresetNode{leaf(), leaf()},
+			TabIds:    []string{"tab-a"},
+		}},
+		{"duplicate tab", "x", &leapmuxv1.LayoutPresetNode{
+			Kind:      leapmuxv1.NodeKind_NODE_KIND_SPLIT,
+			Direction: leapmuxv1.SplitDirection_SPLIT_DIRECTION_VERTICAL,
+			Ratios:    []float64{0.5, 0.5},
+			Children:  []*leapmuxv1.LayoutPresetNode{leaf("tab-a"), leaf("tab-a")},
+		}},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			_, err := env.client.SaveLayoutPreset(context.Background(), authedReq(&leapmuxv1.SaveLayoutPresetRequest{
+				WorkspaceId: env.workspaceID,
+				Name:        tc.preset,
+				Root:        tc.root,
+			}, env.token))
+			require.Error(t, err)
+			assert.Equal(t, connect.CodeInvalidArgument, connect.CodeOf(err))
+		})
+	}
+}
+
+func TestLayoutService_SaveEnforcesPresetCap(t *testing.T) {
+	env := setupLayoutTest(t)
+	leaf := &leapmuxv1.LayoutPresetNode{Kind: leapmuxv1.NodeKind_NODE_KIND_LEAF}
+
+	for i := 0; i < 32; i++ {
+		env.save(t, fmt.Sprintf("preset-%02d", i), leaf)
+	}
+	_, err := env.client.SaveLayoutPreset(context.Background(), authedReq(&leapmuxv1.SaveLayoutPresetRequest{
+		WorkspaceId: env.workspaceID,
+		Name:        "one too many",
+		Root:        leaf,
+	}, env.token))
+	require.Error(t, err)
+	assert.Equal(t, connect.CodeResourceExhausted, connect.CodeOf(err))
+
+	// Replacing an existing preset still works at the cap.
+	env.save(t, "preset-00", twoPaneLayout())
+}
+
+func TestLayoutService_SetActiveLayout(t *testing.T) {
+	env := setupLayoutTest(t)
+	review := env.save(t, "Review", twoPaneLayout())
+	mobile := env.save(t, "Mobile", &leapmuxv1.LayoutPresetNode{Kind: leapmuxv1.NodeKind_NODE_KIND_LEAF})
+
+	setActive := func(class leapmuxv1.DeviceClass, layoutID string) error {
+		_, err := env.client.SetActiveLayout(context.Background(), authedReq(&leapmuxv1.SetActiveLayoutRequest{
+			WorkspaceId: env.workspaceID,
+			DeviceClass: class,
+			LayoutId:    layoutID,
+		}, env.token))
+		return err
+	}
+
+	require.NoError(t, setActive(leapmuxv1.DeviceClass_DEVICE_CLASS_UNSPECIFIED, review.GetId()))
+	require.NoError(t, setActive(leapmuxv1.DeviceClass_DEVICE_CLASS_MOBILE, mobile.GetId()))
+
+	active := env.list(t).GetActive()
+	require.Len(t, active, 2)
+	assert.Equal(t, leapmuxv1.DeviceClass_DEVICE_CLASS_UNSPECIFIED, active[0].GetDeviceClass())
+	assert.Equal(t, review.GetId(), active[0].GetLayoutId())
+	assert.Equal(t, leapmuxv1.DeviceClass_DEVICE_CLASS_MOBILE, active[1].GetDeviceClass())
+	assert.Equal(t, mobile.GetId(), active[1].GetLayoutId())
+
+	// An empty layout_id clears only that device class.
+	require.NoError(t, setActive(leapmuxv1.DeviceClass_DEVICE_CLASS_MOBILE, ""))
+	active = env.list(t).GetActive()
+	require.Len(t, active, 1)
+	assert.Equal(t, leapmuxv1.DeviceClass_DEVICE_CLASS_UNSPECIFIED, active[0].GetDeviceClass())
+
+	err := setActive(leapmuxv1.DeviceClass_DEVICE_CLASS_DESKTOP, "no-such-layout")
+	require.Error(t, err)
+	assert.Equal(t, connect.CodeNotFound, connect.CodeOf(err))
+
+	err = setActive(leapmuxv1.DeviceClass(99), review.GetId())
+	require.Error(t, err)
+	assert.Equal(t, connect.CodeInvalidArgument, connect.CodeOf(err))
+}
+
+func TestLayoutService_DeleteClearsSelections(t *testing.T) {
+	env := setupLayoutTest(t)
+	review := env.save(t, "Review", twoPaneLayout())
+
+	_, err := env.client.SetActiveLayout(context.Background(), authedReq(&leapmuxv1.SetActiveLayoutRequest{
+		WorkspaceId: env.workspaceID,
+		DeviceClass: leapmuxv1.DeviceClass_DEVICE_CLASS_DESKTOP,
+		LayoutId:    review.GetId(),
+	}, env.token))
+	require.NoError(t, err)
+
+	_, err = env.client.DeleteLayoutPreset(context.Background(), authedReq(&leapmuxv1.DeleteLayoutPresetRequest{
+		WorkspaceId: env.workspaceID,
+		LayoutId:    review.GetId(),
+	}, env.token))
+	require.NoError(t, err)
+
+	listed := env.list(t)
+	assert.Empty(t, listed.GetPresets())
+	assert.Empty(t, listed.GetActive())
+
+	_, err = env.client.DeleteLayoutPreset(context.Background(), authedReq(&leapmuxv1.DeleteLayoutPresetRequest{
+		WorkspaceId: env.workspaceID,
+		LayoutId:    review.GetId(),
+	}, env.token))
+	require.Error(t, err)
+	assert.Equal(t, connect.CodeNotFound, connect.CodeOf(err))
+}
+
+func TestLayoutService_PresetBoundToWorkspace(t *testing.T) {
+	env := setupLayoutTest(t)
+	review := env.save(t, "Review", twoPaneLayout())
+	otherWS := storetest.SeedWorkspace(t, env.store, env.orgID, env.userID, "Other WS")
+
+	// A preset id from one workspace cannot be selected or deleted through
+	// another, even by its owner.
+	_, err := env.client.SetActiveLayout(context.Background(), authedReq(&leapmuxv1.SetActiveLayoutRequest{
+		WorkspaceId: otherWS,
+		LayoutId:    review.GetId(),
+	}, env.token))
+	require.Error(t, err)
+	assert.Equal(t, connect.CodeNotFound, connect.CodeOf(err))
+
+	_, err = env.client.DeleteLayoutPreset(context.Background(), authedReq(&leapmuxv1.DeleteLayoutPresetRequest{
+		WorkspaceId: otherWS,
+		LayoutId:    review.GetId(),
+	}, env.token))
+	require.Error(t, err)
+	assert.Equal(t, connect.CodeNotFound, connect.CodeOf(err))
+	assert.Len(t, env.list(t).GetPresets(), 1)
+}
+
+func TestLayoutService_OtherUsersWorkspaceDenied(t *testing.T) {
+	env := setupLayoutTest(t)
+	seedLoginUser(t, env.store, env.orgID, "stranger")
+	strangerToken, _, _, err := auth.Login(context.Background(), env.store, "stranger", "testpass")
+	require.NoError(t, err)
+
+	_, err = env.client.ListLayoutPresets(context.Background(), authedReq(&leapmuxv1.ListLayoutPresetsRequest{
+		WorkspaceId: env.workspaceID,
+	}, strangerToken))
+	require.Error(t, err)
+	assert.Equal(t, connect.CodePermissionDenied, connect.CodeOf(err))
+
+	_, err = env.client.SaveLayoutPreset(context.Background(), authedReq(&leapmuxv1.SaveLayoutPresetRequest{
+		WorkspaceId: env.workspaceID,
+		Name:        "Hijack",
+		Root:        twoPaneLayout(),
+	}, strangerToken))
+	require.Error(t, err)
+	assert.Equal(t, connect.CodePermissionDenied, connect.CodeOf(err))
+}
+
+func TestLayoutService_Unauthenticated(t *testing.T) {
+	env := setupLayoutTest(t)
+
+	_, err := env.client.ListLayoutPresets(context.Background(),
+		connect.NewRequest(&leapmuxv1.ListLayoutPresetsRequest{WorkspaceId: env.workspaceID}))
+	require.Error(t, err)
+	assert.Equal(t, connect.CodeUnauthenticated, connect.CodeOf(err))
+}
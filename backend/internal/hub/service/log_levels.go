@@ -0,0 +1,44 @@
+package service
+
+import (
+	"context"
+	"errors"
+
+	"connectrpc.com/connect"
+
+	leapmuxv1 "github.com/leapmux/leapmux/generated/proto/leapmux/v1"
+	"github.com/leapmux/leapmux/internal/hub/auth"
+	"github.com/leapmux/leapmux/internal/logging"
+)
+
+// requireAdmin admits an admin acting through their own session or API
+// token. A workspace-scoped credential (delegation, guest, public viewer)
+// is refused even when minted for an admin: it stands for access to one
+// workspace, not for the Hub.
+func requireAdmin(ctx context.Context) error {
+	user, err := auth.MustGetUser(ctx)
+	if err != nil {
+		return err
+	}
+	if !user.IsAdmin || user.Credential.IsWorkspaceScoped() {
+		return connect.NewError(connect.CodePermissionDenied, errors.New("admin only"))
+	}
+	return nil
+}
+
+func (s *UserService) GetLogLevels(ctx context.Context, _ *connect.Request[leapmuxv1.GetLogLevelsRequest]) (*connect.Response[leapmuxv1.GetLogLevelsResponse], error) {
+	if err := requireAdmin(ctx); err != nil {
+		return nil, err
+	}
+	return connect.NewResponse(&leapmuxv1.GetLogLevelsResponse{Levels: logging.LogLevelsProto()}), nil
+}
+
+func (s *UserService) SetLogLevels(ctx context.Context, req *connect.Request[leapmuxv1.SetLogLevelsRequest]) (*connect.Response[leapmuxv1.SetLogLevelsResponse], error) {
+	if err := requireAdmin(ctx); err != nil {
+		return nil, err
+	}
+	if err := logging.ApplyLogLevels(req.Msg); err != nil {
+		return nil, connect.NewError(connect.CodeInvalidArgument, err)
+	}
+	return connect.NewResponse(&leapmuxv1.SetLogLevelsResponse{Levels: logging.LogLevelsProto()}), nil
+}
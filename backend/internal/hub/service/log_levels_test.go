@@ -0,0 +1,58 @@
+package service_test
+
+import (
+	"context"
+	"testing"
+
+	"connectrpc.com/connect"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	leapmuxv1 "github.com/leapmux/leapmux/generated/proto/leapmux/v1"
+	"github.com/leapmux/leapmux/internal/hub/auth"
+	"github.com/leapmux/leapmux/internal/hub/config"
+	"github.com/leapmux/leapmux/internal/hub/mail"
+	"github.com/leapmux/leapmux/internal/hub/service"
+	"github.com/leapmux/leapmux/internal/util/userid"
+)
+
+func TestUserService_LogLevels(t *testing.T) {
+	svc := service.NewUserService(nil, &config.Config{}, auth.NewCredentialLifecycleEffects(nil, nil, nil), mail.NewStubSender(), mail.Renderer{})
+	admin := auth.WithUser(context.Background(), &auth.UserInfo{ID: userid.MustNew("admin"), IsAdmin: true})
+	t.Cleanup(func() {
+		_, _ = svc.SetLogLevels(admin, connect.NewRequest(&leapmuxv1.SetLogLevelsRequest{
+			DefaultLevel:   "info",
+			Components:     map[string]string{"hub.workermgr": ""},
+			CaptureAgentId: "agent-1",
+		}))
+	})
+
+	got, err := svc.SetLogLevels(admin, connect.NewRequest(&leapmuxv1.SetLogLevelsRequest{
+		Components:     map[string]string{"hub.workermgr": "debug"},
+		CaptureAgentId: "agent-1",
+		CaptureSeconds: 60,
+	}))
+	require.NoError(t, err)
+	assert.Equal(t, "debug", got.Msg.GetLevels().GetComponents()["hub.workermgr"])
+	require.Len(t, got.Msg.GetLevels().GetAgentCaptures(), 1)
+
+	read, err := svc.GetLogLevels(admin, connect.NewRequest(&leapmuxv1.GetLogLevelsRequest{}))
+	require.NoError(t, err)
+	assert.Equal(t, "info", read.Msg.GetLevels().GetDefaultLevel())
+	assert.Equal(t, "debug", read.Msg.GetLevels().GetComponents()["hub.workermgr"])
+
+	_, err = svc.SetLogLevels(admin, connect.NewRequest(&leapmuxv1.SetLogLevelsRequest{DefaultLevel: "loud"}))
+	assert.Equal(t, connect.CodeInvalidArgument, connect.CodeOf(err))
+
+	member := auth.WithUser(context.Background(), &auth.UserInfo{ID: userid.MustNew("member")})
+	_, err = svc.GetLogLevels(member, connect.NewRequest(&leapmuxv1.GetLogLevelsRequest{}))
+	assert.Equal(t, connect.CodePermissionDenied, connect.CodeOf(err))
+
+	delegated := auth.WithUser(context.Background(), &auth.UserInfo{
+		ID:         userid.MustNew("admin"),
+		IsAdmin:    true,
+		Credential: auth.DelegationCredential("tok-1", "ws-1", "w-1"),
+	})
+	_, err = svc.SetLogLevels(delegated, connect.NewRequest(&leapmuxv1.SetLogLevelsRequest{DefaultLevel: "debug"}))
+	assert.Equal(t, connect.CodePermissionDenied, connect.CodeOf(err), "a workspace-scoped credential is not the admin")
+}
@@ -0,0 +1,252 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
+	"unicode"
+
+	"connectrpc.com/connect"
+
+	leapmuxv1 "github.com/leapmux/leapmux/generated/proto/leapmux/v1"
+	"github.com/leapmux/leapmux/internal/hub/keystore"
+	"github.com/leapmux/leapmux/internal/hub/store"
+	"github.com/leapmux/leapmux/internal/util/id"
+	"github.com/leapmux/leapmux/internal/util/timefmt"
+)
+
+const (
+	// maxModelCredentialsPerOrg caps an org's model credentials.
+	maxModelCredentialsPerOrg = 64
+	// maxModelCredentialSecretLen bounds an API key or token.
+	maxModelCredentialSecretLen = 4 << 10
+	// maxModelCredentialWorkspaces caps the workspaces one credential is
+	// pinned to.
+	maxModelCredentialWorkspaces = 256
+)
+
+// ModelCredentialService implements the ModelCredentialServiceHandler
+// interface. Credentials belong to the caller's org; workspace-scoped
+// credentials (delegation, guest, public viewer) cannot reach them.
+type ModelCredentialService struct {
+	store    store.Store
+	keystore *keystore.Keystore
+}
+
+// NewModelCredentialService creates a new ModelCredentialService. ks
+// encrypts credential secrets.
+func NewModelCredentialService(st store.Store, ks *keystore.Keystore) *ModelCredentialService {
+	return &ModelCredentialService{store: st, keystore: ks}
+}
+
+func (s *ModelCredentialService) ListModelCredentials(
+	ctx context.Context,
+	req *connect.Request[leapmuxv1.ListModelCredentialsRequest],
+) (*connect.Response[leapmuxv1.ListModelCredentialsResponse], error) {
+	orgID, err := repoCallerOrg(ctx, req.Msg.GetOrgId())
+	if err != nil {
+		return nil, err
+	}
+	creds, err := s.store.ModelCredentials().ListByOrg(ctx, orgID)
+	if err != nil {
+		return nil, connect.NewError(connect.CodeInternal, err)
+	}
+	pb := make([]*leapmuxv1.ModelCredential, len(creds))
+	for i := range creds {
+		pb[i] = modelCredentialToProto(&creds[i])
+	}
+	return connect.NewResponse(&leapmuxv1.ListModelCredentialsResponse{Credentials: pb}), nil
+}
+
+func (s *ModelCredentialService) CreateModelCredential(
+	ctx context.Context,
+	req *connect.Request[leapmuxv1.CreateModelCredentialRequest],
+) (*connect.Response[leapmuxv1.CreateModelCredentialResponse], error) {
+	orgID, err := repoCallerOrg(ctx, req.Msg.GetOrgId())
+	if err != nil {
+		return nil, err
+	}
+	name := strings.TrimSpace(req.Msg.GetName())
+	if !repoNamePattern.MatchString(name) || strings.Contains(name, "..") {
+		return nil, connect.NewError(connect.CodeInvalidArgument,
+			errors.New("name: must be 1-100 letters, digits, '.', '_' or '-', starting with a letter or digit"))
+	}
+	kind := req.Msg.GetKind()
+	if _, ok := leapmuxv1.ModelCredentialKind_name[int32(kind)]; !ok || kind == leapmuxv1.ModelCredentialKind_MODEL_CREDENTIAL_KIND_UNSPECIFIED {
+		return nil, connect.NewError(connect.CodeInvalidArgument, errors.New("kind: must be ANTHROPIC_API_KEY or CLAUDE_OAUTH_TOKEN"))
+	}
+	if err := validateModelCredentialSecret(req.Msg.GetSecret()); err != nil {
+		return nil, connect.NewError(connect.CodeInvalidArgument, err)
+	}
+	workspaceIDs, err := s.orgWorkspaceIDs(ctx, orgID, req.Msg.GetWorkspaceIds())
+	if err != nil {
+		return nil, err
+	}
+
+	existing, err := s.store.ModelCredentials().ListByOrg(ctx, orgID)
+	if err != nil {
+		return nil, connect.NewError(connect.CodeInternal, err)
+	}
+	if len(existing) >= maxModelCredentialsPerOrg {
+		return nil, connect.NewError(connect.CodeResourceExhausted,
+			fmt.Errorf("an organization can store at most %d model credentials", maxModelCredentialsPerOrg))
+	}
+
+	credID := id.Generate()
+	secret, err := s.keystore.Encrypt([]byte(req.Msg.GetSecret()), keystore.ModelCredentialAAD(credID))
+	if err != nil {
+		return nil, connect.NewError(connect.CodeInternal, fmt.Errorf("encrypt secret: %w", err))
+	}
+	cred, err := s.store.ModelCredentials().Create(ctx, store.CreateModelCredentialParams{
+		ID:           credID,
+		OrgID:        orgID,
+		Name:         name,
+		Kind:         kind,
+		WorkspaceIDs: workspaceIDs,
+		Secret:       secret,
+	})
+	if errors.Is(err, store.ErrConflict) {
+		return nil, connect.NewError(connect.CodeAlreadyExists, fmt.Errorf("a model credential named %q already exists", name))
+	}
+	if err != nil {
+		return nil, connect.NewError(connect.CodeInternal, fmt.Errorf("create model credential: %w", err))
+	}
+	return connect.NewResponse(&leapmuxv1.CreateModelCredentialResponse{Credential: modelCredentialToProto(cred)}), nil
+}
+
+func (s *ModelCredentialService) UpdateModelCredential(
+	ctx context.Context,
+	req *connect.Request[leapmuxv1.UpdateModelCredentialRequest],
+) (*connect.Response[leapmuxv1.UpdateModelCredentialResponse], error) {
+	orgID, err := repoCallerOrg(ctx, "")
+	if err != nil {
+		return nil, err
+	}
+	current, err := s.store.ModelCredentials().Get(ctx, store.GetModelCredentialParams{ID: req.Msg.GetCredentialId(), OrgID: orgID})
+	if errors.Is(err, store.ErrNotFound) {
+		return nil, connect.NewError(connect.CodeNotFound, errors.New("model credential not found"))
+	}
+	if err != nil {
+		return nil, connect.NewError(connect.CodeInternal, err)
+	}
+	workspaceIDs, err := s.orgWorkspaceIDs(ctx, orgID, req.Msg.GetWorkspaceIds())
+	if err != nil {
+		return nil, err
+	}
+	secret := current.Secret
+	if req.Msg.GetSecret() != "" {
+		if err := validateModelCredentialSecret(req.Msg.GetSecret()); err != nil {
+			return nil, connect.NewError(connect.CodeInvalidArgument, err)
+		}
+		secret, err = s.keystore.Encrypt([]byte(req.Msg.GetSecret()), keystore.ModelCredentialAAD(current.ID))
+		if err != nil {
+			return nil, connect.NewError(connect.CodeInternal, fmt.Errorf("encrypt secret: %w", err))
+		}
+	}
+	cred, err := s.store.ModelCredentials().Update(ctx, store.UpdateModelCredentialParams{
+		ID:           current.ID,
+		OrgID:        orgID,
+		WorkspaceIDs: workspaceIDs,
+		Secret:       secret,
+	})
+	if errors.Is(err, store.ErrNotFound) {
+		return nil, connect.NewError(connect.CodeNotFound, errors.New("model credential not found"))
+	}
+	if err != nil {
+		return nil, connect.NewError(connect.CodeInternal, fmt.Errorf("update model credential: %w", err))
+	}
+	return connect.NewResponse(&leapmuxv1.UpdateModelCredentialResponse{Credential: modelCredentialToProto(cred)}), nil
+}
+
+func (s *ModelCredentialService) DeleteModelCredential(
+	ctx context.Context,
+	req *connect.Request[leapmuxv1.DeleteModelCredentialRequest],
+) (*connect.Response[leapmuxv1.DeleteModelCredentialResponse], error) {
+	orgID, err := repoCallerOrg(ctx, "")
+	if err != nil {
+		return nil, err
+	}
+	n, err := s.store.ModelCredentials().Delete(ctx, store.GetModelCredentialParams{ID: req.Msg.GetCredentialId(), OrgID: orgID})
+	if err != nil {
+		return nil, connect.NewError(connect.CodeInternal, err)
+	}
+	if n == 0 {
+		return nil, connect.NewError(connect.CodeNotFound, errors.New("model credential not found"))
+	}
+	return connect.NewResponse(&leapmuxv1.DeleteModelCredentialResponse{}), nil
+}
+
+// orgWorkspaceIDs dedupes and sorts ids, failing with InvalidArgument
+// unless each names one of the org's live workspaces.
+func (s *ModelCredentialService) orgWorkspaceIDs(ctx context.Context, orgID string, ids []string) ([]string, error) {
+	ids = slices.Compact(slices.Sorted(slices.Values(ids)))
+	if len(ids) > maxModelCredentialWorkspaces {
+		return nil, connect.NewError(connect.CodeInvalidArgument,
+			fmt.Errorf("workspace_ids: at most %d workspaces", maxModelCredentialWorkspaces))
+	}
+	if len(ids) == 0 {
+		return nil, nil
+	}
+	found, err := s.store.Workspaces().ListByIDs(ctx, ids)
+	if err != nil {
+		return nil, connect.NewError(connect.CodeInternal, err)
+	}
+	inOrg := make(map[string]bool, len(found))
+	for _, ws := range found {
+		if ws.OrgID == orgID {
+			inOrg[ws.ID] = true
+		}
+	}
+	for _, wsID := range ids {
+		if !inOrg[wsID] {
+			return nil, connect.NewError(connect.CodeInvalidArgument, fmt.Errorf("workspace_ids: no workspace %q in the organization", wsID))
+		}
+	}
+	return ids, nil
+}
+
+// validateModelCredentialSecret checks an API key or token.
+func validateModelCredentialSecret(secret string) error {
+	if secret == "" {
+		return errors.New("secret: must not be empty")
+	}
+	if len(secret) > maxModelCredentialSecretLen {
+		return fmt.Errorf("secret: must be at most %d bytes", maxModelCredentialSecretLen)
+	}
+	if strings.IndexFunc(secret, func(r rune) bool { return unicode.IsSpace(r) || unicode.IsControl(r) }) >= 0 {
+		return errors.New("secret: must not contain whitespace")
+	}
+	return nil
+}
+
+// workspaceModelCredentials returns the credentials serving workspaceID:
+// those pinned to it, or when none is, those pinned to no workspace.
+func workspaceModelCredentials(creds []store.ModelCredential, workspaceID string) []store.ModelCredential {
+	var pinned, unpinned []store.ModelCredential
+	for _, c := range creds {
+		switch {
+		case slices.Contains(c.WorkspaceIDs, workspaceID):
+			pinned = append(pinned, c)
+		case len(c.WorkspaceIDs) == 0:
+			unpinned = append(unpinned, c)
+		}
+	}
+	if len(pinned) > 0 {
+		return pinned
+	}
+	return unpinned
+}
+
+func modelCredentialToProto(c *store.ModelCredential) *leapmuxv1.ModelCredential {
+	return &leapmuxv1.ModelCredential{
+		Id:           c.ID,
+		OrgId:        c.OrgID,
+		Name:         c.Name,
+		Kind:         c.Kind,
+		WorkspaceIds: c.WorkspaceIDs,
+		CreatedAt:    timefmt.Format(c.CreatedAt),
+		UpdatedAt:    timefmt.Format(c.UpdatedAt),
+	}
+}
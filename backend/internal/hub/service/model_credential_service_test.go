@@ -0,0 +1,121 @@
+package service_test
+
+import (
+	"context"
+	"testing"
+
+	"connectrpc.com/connect"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	leapmuxv1 "github.com/leapmux/leapmux/generated/proto/leapmux/v1"
+	"github.com/leapmux/leapmux/internal/hub/auth"
+	"github.com/leapmux/leapmux/internal/hub/channelmgr"
+	"github.com/leapmux/leapmux/internal/hub/service"
+	"github.com/leapmux/leapmux/internal/hub/store/storetest"
+	hubtestutil "github.com/leapmux/leapmux/internal/hub/testutil"
+	"github.com/leapmux/leapmux/internal/util/userid"
+)
+
+const testAPIKeyKind = leapmuxv1.ModelCredentialKind_MODEL_CREDENTIAL_KIND_ANTHROPIC_API_KEY
+
+func TestModelCredentialService_CRUD(t *testing.T) {
+	st := hubtestutil.OpenTestStore(t)
+	orgID := storetest.SeedOrg(t, st, "model-org")
+	user := storetest.SeedUser(t, st, orgID, "alice")
+	wsID := storetest.SeedWorkspace(t, st, orgID, user.ID, "ws")
+	otherOrg := storetest.SeedOrg(t, st, "other-org")
+	stranger := storetest.SeedUser(t, st, otherOrg, "bob")
+	foreignWS := storetest.SeedWorkspace(t, st, otherOrg, stranger.ID, "foreign")
+	ctx := auth.WithUser(context.Background(), &auth.UserInfo{ID: userid.MustNew(user.ID), OrgID: orgID})
+	svc := service.NewModelCredentialService(st, newTestKeystore(t))
+
+	created, err := svc.CreateModelCredential(ctx, connect.NewRequest(&leapmuxv1.CreateModelCredentialRequest{
+		Name: "team-a", Kind: testAPIKeyKind, Secret: "sk-ant-a", WorkspaceIds: []string{wsID, wsID},
+	}))
+	require.NoError(t, err)
+	cred := created.Msg.GetCredential()
+	assert.Equal(t, []string{wsID}, cred.GetWorkspaceIds(), "pins are deduplicated")
+
+	for _, req := range []*leapmuxv1.CreateModelCredentialRequest{
+		{Name: "bad kind", Kind: testAPIKeyKind, Secret: "k"},
+		{Name: "b", Secret: "k"},
+		{Name: "b", Kind: testAPIKeyKind, Secret: "sk ant"},
+		{Name: "b", Kind: testAPIKeyKind},
+		{Name: "b", Kind: testAPIKeyKind, Secret: "k", WorkspaceIds: []string{foreignWS}},
+	} {
+		_, err := svc.CreateModelCredential(ctx, connect.NewRequest(req))
+		assert.Equal(t, connect.CodeInvalidArgument, connect.CodeOf(err), "%v", req)
+	}
+	_, err = svc.CreateModelCredential(ctx, connect.NewRequest(&leapmuxv1.CreateModelCredentialRequest{
+		Name: "team-a", Kind: testAPIKeyKind, Secret: "k",
+	}))
+	assert.Equal(t, connect.CodeAlreadyExists, connect.CodeOf(err))
+
+	updated, err := svc.UpdateModelCredential(ctx, connect.NewRequest(&leapmuxv1.UpdateModelCredentialRequest{
+		CredentialId: cred.GetId(),
+	}))
+	require.NoError(t, err)
+	assert.Empty(t, updated.Msg.GetCredential().GetWorkspaceIds(), "an update replaces the pins")
+
+	list, err := svc.ListModelCredentials(ctx, connect.NewRequest(&leapmuxv1.ListModelCredentialsRequest{}))
+	require.NoError(t, err)
+	assert.Len(t, list.Msg.GetCredentials(), 1)
+
+	_, err = svc.DeleteModelCredential(ctx, connect.NewRequest(&leapmuxv1.DeleteModelCredentialRequest{CredentialId: cred.GetId()}))
+	require.NoError(t, err)
+	_, err = svc.DeleteModelCredential(ctx, connect.NewRequest(&leapmuxv1.DeleteModelCredentialRequest{CredentialId: cred.GetId()}))
+	assert.Equal(t, connect.CodeNotFound, connect.CodeOf(err))
+}
+
+func TestWorkerReconcilerService_GetModelCredentialsForWorker(t *testing.T) {
+	st := hubtestutil.OpenTestStore(t)
+	orgID := storetest.SeedOrg(t, st, "model-org")
+	user := storetest.SeedUser(t, st, orgID, "alice")
+	peer := storetest.SeedUser(t, st, orgID, "carol")
+	pinnedWS := storetest.SeedWorkspace(t, st, orgID, user.ID, "pinned")
+	plainWS := storetest.SeedWorkspace(t, st, orgID, user.ID, "plain")
+	peerWS := storetest.SeedWorkspace(t, st, orgID, peer.ID, "peer")
+	worker := storetest.SeedWorker(t, st, user.ID)
+	ks := newTestKeystore(t)
+	ctx := auth.WithUser(context.Background(), &auth.UserInfo{ID: userid.MustNew(user.ID), OrgID: orgID})
+	creds := service.NewModelCredentialService(st, ks)
+	for _, req := range []*leapmuxv1.CreateModelCredentialRequest{
+		{Name: "shared-1", Kind: testAPIKeyKind, Secret: "sk-1"},
+		{Name: "shared-2", Kind: testAPIKeyKind, Secret: "sk-2"},
+		{Name: "dedicated", Kind: leapmuxv1.ModelCredentialKind_MODEL_CREDENTIAL_KIND_CLAUDE_OAUTH_TOKEN, Secret: "tok", WorkspaceIds: []string{pinnedWS}},
+	} {
+		_, err := creds.CreateModelCredential(ctx, connect.NewRequest(req))
+		require.NoError(t, err)
+	}
+
+	channels := channelmgr.New()
+	channels.RegisterWithAuthInfo("ch-1", worker.ID, user.ID, channelmgr.AuthInfo{}, nil)
+	svc := service.NewWorkerReconcilerService(st, channels, ks)
+	get := func(workspaceID string) ([]*leapmuxv1.ModelCredentialSecret, error) {
+		req := connect.NewRequest(&leapmuxv1.GetModelCredentialsForWorkerRequest{ChannelId: "ch-1", WorkspaceId: workspaceID})
+		req.Header().Set("Authorization", "Bearer "+worker.AuthToken)
+		resp, err := svc.GetModelCredentialsForWorker(context.Background(), req)
+		if err != nil {
+			return nil, err
+		}
+		return resp.Msg.GetCredentials(), nil
+	}
+
+	pinned, err := get(pinnedWS)
+	require.NoError(t, err)
+	require.Len(t, pinned, 1, "a pinned workspace uses only its pinned credentials")
+	assert.Equal(t, "dedicated", pinned[0].GetName())
+	assert.Equal(t, "tok", pinned[0].GetSecret())
+
+	plain, err := get(plainWS)
+	require.NoError(t, err)
+	names := make([]string, len(plain))
+	for i, c := range plain {
+		names[i] = c.GetName()
+	}
+	assert.ElementsMatch(t, []string{"shared-1", "shared-2"}, names)
+
+	_, err = get(peerWS)
+	assert.Equal(t, connect.CodeNotFound, connect.CodeOf(err), "the channel's user does not own the workspace")
+}
@@ -0,0 +1,272 @@
+package service
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"slices"
+	"time"
+
+	"connectrpc.com/connect"
+	leapmuxv1 "github.com/leapmux/leapmux/generated/proto/leapmux/v1"
+	"github.com/leapmux/leapmux/internal/hub/auth"
+	"github.com/leapmux/leapmux/internal/hub/store"
+)
+
+const (
+	// minutesPerDay bounds QuietHours start/end minutes.
+	minutesPerDay = 24 * 60
+	// maxMutedWorkspaces caps muted_workspace_ids.
+	maxMutedWorkspaces = 256
+	// maxWorkspaceIDLen caps each muted workspace id.
+	maxWorkspaceIDLen = 128
+	// maxPrefsWriteAttempts bounds how often updateStoredPreferences
+	// re-reads the blob after losing a race to another write.
+	maxPrefsWriteAttempts = 8
+)
+
+// storedNotificationPreferences is the "notifications" entry of the
+// user_preferences JSON blob. Enum values are stored by name so the blob
+// survives enum renumbering.
+type storedNotificationPreferences struct {
+	DoNotDisturb      bool              `json:"doNotDisturb,omitempty"`
+	MutedEventTypes   []string          `json:"mutedEventTypes,omitempty"`
+	DisabledChannels  []string          `json:"disabledChannels,omitempty"`
+	QuietHours        *storedQuietHours `json:"quietHours,omitempty"`
+	MutedWorkspaceIDs []string          `json:"mutedWorkspaceIds,omitempty"`
+}
+
+type storedQuietHours struct {
+	StartMinute int    `json:"startMinute"`
+	EndMinute   int    `json:"endMinute"`
+	TimeZone    string `json:"timeZone,omitempty"`
+}
+
+// validateNotificationPreferences checks p and returns its stored form,
+// with repeated values deduplicated.
+func validateNotificationPreferences(p *leapmuxv1.NotificationPreferences) (*storedNotificationPreferences, error) {
+	sp := &storedNotificationPreferences{DoNotDisturb: p.GetDoNotDisturb()}
+
+	for _, t := range p.GetMutedEventTypes() {
+		name, ok := leapmuxv1.NotificationEventType_name[int32(t)]
+		if !ok || t == leapmuxv1.NotificationEventType_NOTIFICATION_EVENT_TYPE_UNSPECIFIED {
+			return nil, fmt.Errorf("muted_event_types: unknown event type %d", t)
+		}
+		if !slices.Contains(sp.MutedEventTypes, name) {
+			sp.MutedEventTypes = append(sp.MutedEventTypes, name)
+		}
+	}
+
+	for _, c := range p.GetDisabledChannels() {
+		name, ok := leapmuxv1.NotificationChannel_name[int32(c)]
+		if !ok || c == leapmuxv1.NotificationChannel_NOTIFICATION_CHANNEL_UNSPECIFIED {
+			return nil, fmt.Errorf("disabled_channels: unknown channel %d", c)
+		}
+		if !slices.Contains(sp.DisabledChannels, name) {
+			sp.DisabledChannels = append(sp.DisabledChannels, name)
+		}
+	}
+
+	if qh := p.GetQuietHours(); qh != nil {
+		if qh.GetStartMinute() >= minutesPerDay || qh.GetEndMinute() >= minutesPerDay {
+			return nil, fmt.Errorf("quiet_hours: minutes must be below %d", minutesPerDay)
+		}
+		if qh.GetStartMinute() == qh.GetEndMinute() {
+			return nil, errors.New("quiet_hours: start and end must differ")
+		}
+		if _, err := time.LoadLocation(qh.GetTimeZone()); err != nil {
+			return nil, fmt.Errorf("quiet_hours: unknown time zone %q", qh.GetTimeZone())
+		}
+		sp.QuietHours = &storedQuietHours{
+			StartMinute: int(qh.GetStartMinute()),
+			EndMinute:   int(qh.GetEndMinute()),
+			TimeZone:    qh.GetTimeZone(),
+		}
+	}
+
+	if len(p.GetMutedWorkspaceIds()) > maxMutedWorkspaces {
+		return nil, fmt.Errorf("muted_workspace_ids: at most %d allowed", maxMutedWorkspaces)
+	}
+	for _, id := range p.GetMutedWorkspaceIds() {
+		if id == "" || len(id) > maxWorkspaceIDLen {
+			return nil, fmt.Errorf("muted_workspace_ids: ids must be 1-%d bytes", maxWorkspaceIDLen)
+		}
+		if !slices.Contains(sp.MutedWorkspaceIDs, id) {
+			sp.MutedWorkspaceIDs = append(sp.MutedWorkspaceIDs, id)
+		}
+	}
+	return sp, nil
+}
+
+func notificationPreferencesToProto(sp *storedNotificationPreferences) *leapmuxv1.NotificationPreferences {
+	p := &leapmuxv1.NotificationPreferences{}
+	if sp == nil {
+		return p
+	}
+	p.DoNotDisturb = sp.DoNotDisturb
+	for _, name := range sp.MutedEventTypes {
+		if v, ok := leapmuxv1.NotificationEventType_value[name]; ok {
+			p.MutedEventTypes = append(p.MutedEventTypes, leapmuxv1.NotificationEventType(v))
+		}
+	}
+	for _, name := range sp.DisabledChannels {
+		if v, ok := leapmuxv1.NotificationChannel_value[name]; ok {
+			p.DisabledChannels = append(p.DisabledChannels, leapmuxv1.NotificationChannel(v))
+		}
+	}
+	if sp.QuietHours != nil {
+		p.QuietHours = &leapmuxv1.QuietHours{
+			StartMinute: uint32(sp.QuietHours.StartMinute),
+			EndMinute:   uint32(sp.QuietHours.EndMinute),
+			TimeZone:    sp.QuietHours.TimeZone,
+		}
+	}
+	p.MutedWorkspaceIds = sp.MutedWorkspaceIDs
+	return p
+}
+
+// inQuietHours reports whether now falls in the window. A window whose
+// start is after its end wraps past midnight.
+func (qh *storedQuietHours) inQuietHours(now time.Time) bool {
+	loc, err := time.LoadLocation(qh.TimeZone)
+	if err != nil {
+		loc = time.UTC
+	}
+	local := now.In(loc)
+	minute := local.Hour()*60 + local.Minute()
+	if qh.StartMinute < qh.EndMinute {
+		return minute >= qh.StartMinute && minute < qh.EndMinute
+	}
+	return minute >= qh.StartMinute || minute < qh.EndMinute
+}
+
+// allows reports whether an event of eventType in workspaceID may be
+// delivered over channel at now.
+func (sp *storedNotificationPreferences) allows(eventType leapmuxv1.NotificationEventType, channel leapmuxv1.NotificationChannel, workspaceID string, now time.Time) bool {
+	if sp == nil {
+		return true
+	}
+	if sp.DoNotDisturb {
+		return false
+	}
+	if slices.Contains(sp.MutedEventTypes, eventType.String()) {
+		return false
+	}
+	if slices.Contains(sp.DisabledChannels, channel.String()) {
+		return false
+	}
+	if workspaceID != "" && slices.Contains(sp.MutedWorkspaceIDs, workspaceID) {
+		return false
+	}
+	if sp.QuietHours != nil && sp.QuietHours.inQuietHours(now) {
+		return false
+	}
+	return true
+}
+
+func loadStoredPreferences(ctx context.Context, st store.Store, userID string) (storedPreferences, error) {
+	prefs, err := st.Users().GetPrefs(ctx, userID)
+	if err != nil {
+		return storedPreferences{}, err
+	}
+	var sp storedPreferences
+	if err := json.Unmarshal([]byte(prefs), &sp); err != nil {
+		sp = storedPreferences{}
+	}
+	return sp, nil
+}
+
+// updateStoredPreferences applies fn to userID's stored preferences and
+// writes the result back, and returns what it wrote. Every RPC that
+// changes part of the blob goes through it: the write only lands if no
+// other write did since the read, and otherwise fn runs again on the
+// fresh blob, so two RPCs changing different parts cannot undo each
+// other. fn may run more than once and must only change sp; an error it
+// returns is passed through, so it should be a connect error.
+func updateStoredPreferences(ctx context.Context, st store.Store, userID string, fn func(sp *storedPreferences) error) (storedPreferences, error) {
+	for range maxPrefsWriteAttempts {
+		read, err := st.Users().GetPrefsVersion(ctx, userID)
+		if err != nil {
+			return storedPreferences{}, connect.NewError(connect.CodeInternal, err)
+		}
+		var sp storedPreferences
+		if err := json.Unmarshal([]byte(read.Prefs), &sp); err != nil {
+			sp = storedPreferences{}
+		}
+		if err := fn(&sp); err != nil {
+			return storedPreferences{}, err
+		}
+		prefsJSON, err := json.Marshal(sp)
+		if err != nil {
+			return storedPreferences{}, connect.NewError(connect.CodeInternal, fmt.Errorf("marshal prefs: %w", err))
+		}
+		swapped, err := st.Users().SwapPrefs(ctx, store.SwapUserPrefsParams{
+			ID:      userID,
+			Prefs:   string(prefsJSON),
+			Version: read.Version,
+		})
+		if err != nil {
+			return storedPreferences{}, connect.NewError(connect.CodeInternal, err)
+		}
+		if swapped {
+			return sp, nil
+		}
+	}
+	return storedPreferences{}, connect.NewError(connect.CodeAborted, errors.New("preferences changed concurrently; retry"))
+}
+
+// NotificationAllowed reports whether userID's notification preferences,
+// and the notification defaults of their org, permit delivering an event of
+// eventType in workspaceID over channel right now. Delivery paths consult
+// it before sending anything to the user.
+func NotificationAllowed(ctx context.Context, st store.Store, userID string, eventType leapmuxv1.NotificationEventType, channel leapmuxv1.NotificationChannel, workspaceID string) (bool, error) {
+	sp, err := loadStoredPreferences(ctx, st, userID)
+	if err != nil {
+		return false, err
+	}
+	now := time.Now()
+	return sp.Notifications.allows(eventType, channel, workspaceID, now) &&
+		sp.OrgDefaults.orgNotifications().allows(eventType, channel, workspaceID, now), nil
+}
+
+func (s *UserService) GetNotificationPreferences(ctx context.Context, req *connect.Request[leapmuxv1.GetNotificationPreferencesRequest]) (*connect.Response[leapmuxv1.GetNotificationPreferencesResponse], error) {
+	userInfo, err := auth.MustGetUser(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	sp, err := loadStoredPreferences(ctx, s.store, userInfo.ID.String())
+	if err != nil {
+		return nil, connect.NewError(connect.CodeInternal, err)
+	}
+
+	return connect.NewResponse(&leapmuxv1.GetNotificationPreferencesResponse{
+		Preferences: notificationPreferencesToProto(sp.Notifications),
+	}), nil
+}
+
+// UpdateNotificationPreferences replaces the caller's notification
+// preferences, leaving the rest of their preferences untouched.
+func (s *UserService) UpdateNotificationPreferences(ctx context.Context, req *connect.Request[leapmuxv1.UpdateNotificationPreferencesRequest]) (*connect.Response[leapmuxv1.UpdateNotificationPreferencesResponse], error) {
+	userInfo, err := auth.MustGetUser(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	notifications, err := validateNotificationPreferences(req.Msg.GetPreferences())
+	if err != nil {
+		return nil, connect.NewError(connect.CodeInvalidArgument, err)
+	}
+
+	if _, err := updateStoredPreferences(ctx, s.store, userInfo.ID.String(), func(sp *storedPreferences) error {
+		sp.Notifications = notifications
+		return nil
+	}); err != nil {
+		return nil, err
+	}
+
+	return connect.NewResponse(&leapmuxv1.UpdateNotificationPreferencesResponse{
+		Preferences: notificationPreferencesToProto(notifications),
+	}), nil
+}
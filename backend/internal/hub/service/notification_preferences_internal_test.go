@@ -0,0 +1,93 @@
+package service
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	leapmuxv1 "github.com/leapmux/leapmux/generated/proto/leapmux/v1"
+	"github.com/leapmux/leapmux/internal/hub/store"
+	"github.com/leapmux/leapmux/internal/hub/store/storetest"
+	hubtestutil "github.com/leapmux/leapmux/internal/hub/testutil"
+)
+
+func TestStoredQuietHours_InQuietHours(t *testing.T) {
+	at := func(hour, minute int) time.Time {
+		return time.Date(2026, 1, 15, hour, minute, 0, 0, time.UTC)
+	}
+
+	daytime := &storedQuietHours{StartMinute: 9 * 60, EndMinute: 17 * 60}
+	assert.True(t, daytime.inQuietHours(at(9, 0)))
+	assert.False(t, daytime.inQuietHours(at(17, 0)), "end is exclusive")
+
+	overnight := &storedQuietHours{StartMinute: 22 * 60, EndMinute: 7 * 60}
+	assert.True(t, overnight.inQuietHours(at(23, 30)))
+	assert.True(t, overnight.inQuietHours(at(6, 59)))
+	assert.False(t, overnight.inQuietHours(at(12, 0)))
+
+	// 21:30 UTC is 06:30 in Tokyo, inside the overnight window there.
+	tokyo := &storedQuietHours{StartMinute: 22 * 60, EndMinute: 7 * 60, TimeZone: "Asia/Tokyo"}
+	assert.True(t, tokyo.inQuietHours(at(21, 30)))
+	assert.False(t, tokyo.inQuietHours(at(23, 30)))
+}
+
+func TestStoredNotificationPreferences_Allows(t *testing.T) {
+	now := time.Date(2026, 1, 15, 12, 0, 0, 0, time.UTC)
+	turnEnd := leapmuxv1.NotificationEventType_NOTIFICATION_EVENT_TYPE_TURN_END
+	inputRequired := leapmuxv1.NotificationEventType_NOTIFICATION_EVENT_TYPE_INPUT_REQUIRED
+	push := leapmuxv1.NotificationChannel_NOTIFICATION_CHANNEL_WEB_PUSH
+	slack := leapmuxv1.NotificationChannel_NOTIFICATION_CHANNEL_SLACK
+
+	var unset *storedNotificationPreferences
+	assert.True(t, unset.allows(turnEnd, push, "ws-1", now))
+
+	assert.False(t, (&storedNotificationPreferences{DoNotDisturb: true}).allows(inputRequired, push, "", now))
+
+	sp := &storedNotificationPreferences{
+		MutedEventTypes:   []string{turnEnd.String()},
+		DisabledChannels:  []string{slack.String()},
+		MutedWorkspaceIDs: []string{"ws-muted"},
+	}
+	assert.False(t, sp.allows(turnEnd, push, "", now))
+	assert.False(t, sp.allows(inputRequired, slack, "", now))
+	assert.False(t, sp.allows(inputRequired, push, "ws-muted", now))
+	assert.True(t, sp.allows(inputRequired, push, "ws-1", now))
+
+	sp.QuietHours = &storedQuietHours{StartMinute: 11 * 60, EndMinute: 13 * 60}
+	assert.False(t, sp.allows(inputRequired, push, "ws-1", now))
+}
+
+// A write that lands between updateStoredPreferences' read and its write
+// must not be lost: the swap misses, fn runs again on the fresh blob, and
+// both changes end up stored.
+func TestUpdateStoredPreferences_RetriesALostRace(t *testing.T) {
+	st := hubtestutil.OpenTestStore(t)
+	ctx := context.Background()
+	orgID := storetest.SeedOrg(t, st, "prefs-org")
+	user := storetest.SeedUser(t, st, orgID, "prefs-user")
+
+	calls := 0
+	sp, err := updateStoredPreferences(ctx, st, user.ID, func(sp *storedPreferences) error {
+		calls++
+		if calls == 1 {
+			// Another RPC writes its own part of the blob meanwhile.
+			require.NoError(t, st.Users().UpdatePrefs(ctx, store.UpdateUserPrefsParams{
+				ID:    user.ID,
+				Prefs: `{"diffView":2}`,
+			}))
+		}
+		sp.Theme = "dark"
+		return nil
+	})
+	require.NoError(t, err)
+	assert.Equal(t, 2, calls, "the lost race reran fn")
+	assert.Equal(t, "dark", sp.Theme)
+
+	stored, err := loadStoredPreferences(ctx, st, user.ID)
+	require.NoError(t, err)
+	assert.Equal(t, "dark", stored.Theme)
+	assert.Equal(t, 2, stored.DiffView, "the concurrent write survived")
+}
@@ -291,7 +291,7 @@ func (h *OAuthHandler) loginOAuthUser(w http.ResponseWriter, r *http.Request, us
 		return
 	}
 
-	http.SetCookie(w, auth.BuildSessionCookie(sessionID, expiresAt, h.cfg.SecureCookies))
+	http.SetCookie(w, auth.BuildSessionCookie(sessionID, expiresAt, h.cfg.SecureCookies, h.cfg.CrossSiteCookies))
 
 	redirectTo := "/"
 	if redirectURI != "" {
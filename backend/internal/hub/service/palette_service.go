@@ -0,0 +1,286 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"strings"
+
+	"connectrpc.com/connect"
+
+	leapmuxv1 "github.com/leapmux/leapmux/generated/proto/leapmux/v1"
+	"github.com/leapmux/leapmux/internal/hub/auth"
+	"github.com/leapmux/leapmux/internal/hub/store"
+	"github.com/leapmux/leapmux/internal/hub/workermgr"
+)
+
+const (
+	defaultPaletteLimit = 20
+	maxPaletteLimit     = 50
+	// maxPaletteWorkers bounds the worker read. The palette only offers
+	// online workers, and nobody picks from a list this long by typing.
+	maxPaletteWorkers = 100
+)
+
+// Palette action groups, in the order an empty query lists them: what the
+// current workspace already holds, then ways to grow it, then elsewhere.
+const (
+	paletteRankCurrentAgent = iota
+	paletteRankCurrentPreset
+	paletteRankOpenAgent
+	paletteRankWorkspace
+	paletteRankOtherAgent
+)
+
+// PaletteService implements the PaletteServiceHandler interface. It only
+// reads: every suggestion is carried out by the frontend through the RPC
+// that already owns that action.
+type PaletteService struct {
+	store     store.Store
+	workerMgr *workermgr.Manager
+}
+
+// NewPaletteService creates a new PaletteService.
+func NewPaletteService(st store.Store, workerMgr *workermgr.Manager) *PaletteService {
+	return &PaletteService{store: st, workerMgr: workerMgr}
+}
+
+// paletteCandidate is an action plus what SuggestActions sorts it by.
+type paletteCandidate struct {
+	action *leapmuxv1.PaletteAction
+	rank   int
+}
+
+func (s *PaletteService) SuggestActions(
+	ctx context.Context,
+	req *connect.Request[leapmuxv1.SuggestActionsRequest],
+) (*connect.Response[leapmuxv1.SuggestActionsResponse], error) {
+	user, err := auth.MustGetUser(ctx)
+	if err != nil {
+		return nil, err
+	}
+	limit := int(req.Msg.GetLimit())
+	if limit <= 0 {
+		limit = defaultPaletteLimit
+	}
+	limit = min(limit, maxPaletteLimit)
+
+	workspaces, err := s.paletteWorkspaces(ctx, user, req.Msg.GetOrgId())
+	if err != nil {
+		return nil, err
+	}
+	archived, err := archivedWorkspaceIDs(ctx, s.store, user.ID)
+	if err != nil {
+		return nil, connect.NewError(connect.CodeInternal, fmt.Errorf("list archived workspaces: %w", err))
+	}
+
+	// The current workspace is the caller's, or else the newest one not
+	// archived. Archived workspaces are otherwise left out: the palette is
+	// for getting to work, not for digging through old projects.
+	var current *store.Workspace
+	visible := make([]store.Workspace, 0, len(workspaces))
+	for _, ws := range workspaces {
+		if ws.ID == req.Msg.GetWorkspaceId() {
+			current = &ws
+		} else if archived[ws.ID] {
+			continue
+		}
+		visible = append(visible, ws)
+	}
+	if current == nil && len(visible) > 0 {
+		current = &visible[0]
+	}
+
+	// Worker ids on tab rows come from client-written CRDT ops, so only
+	// workers the store says the caller registered are probed for
+	// liveness (see workermgr.Manager.OnlineForTrustedPath).
+	workers, err := s.store.Workers().ListByUserID(ctx, store.ListWorkersByUserIDParams{
+		RegisteredBy: user.ID,
+		PageParams:   store.PageParams{Limit: maxPaletteWorkers},
+	})
+	if err != nil {
+		return nil, connect.NewError(connect.CodeInternal, fmt.Errorf("list workers: %w", err))
+	}
+	online := make(map[string]bool, len(workers.Rows))
+	for _, w := range workers.Rows {
+		if s.workerMgr.OnlineForTrustedPath(w.ID) {
+			online[w.ID] = true
+		}
+	}
+
+	titles := make(map[string]string, len(visible))
+	ids := make([]string, len(visible))
+	for i, ws := range visible {
+		titles[ws.ID] = ws.Title
+		ids[i] = ws.ID
+	}
+	tabs, err := s.store.WorkspaceTabIndex().ListRenderedByWorkspaceIDs(ctx, ids)
+	if err != nil {
+		return nil, connect.NewError(connect.CodeInternal, fmt.Errorf("list rendered tabs: %w", err))
+	}
+
+	var candidates []paletteCandidate
+	add := func(rank int, a *leapmuxv1.PaletteAction) {
+		candidates = append(candidates, paletteCandidate{action: a, rank: rank})
+	}
+
+	agentOrdinal := make(map[string]int)
+	hostsTab := make(map[string]bool)
+	for _, t := range tabs {
+		if t.TabType != leapmuxv1.TabType_TAB_TYPE_AGENT {
+			continue
+		}
+		agentOrdinal[t.WorkspaceID]++
+		if current != nil && t.WorkspaceID == current.ID {
+			hostsTab[t.WorkerID] = true
+		}
+		if !online[t.WorkerID] {
+			continue
+		}
+		rank := paletteRankOtherAgent
+		if current != nil && t.WorkspaceID == current.ID {
+			rank = paletteRankCurrentAgent
+		}
+		add(rank, &leapmuxv1.PaletteAction{
+			Kind:        leapmuxv1.PaletteActionKind_PALETTE_ACTION_KIND_RESUME_AGENT,
+			Title:       fmt.Sprintf("Resume agent %d in %s", agentOrdinal[t.WorkspaceID], titles[t.WorkspaceID]),
+			Subtitle:    "on worker " + t.WorkerID,
+			WorkspaceId: t.WorkspaceID,
+			WorkerId:    t.WorkerID,
+			TabId:       t.TabID,
+		})
+	}
+
+	if current != nil {
+		presets, err := s.store.WorkspaceLayoutPresets().ListByWorkspace(ctx, store.ListWorkspaceLayoutPresetsParams{
+			UserID:      user.ID,
+			WorkspaceID: current.ID,
+		})
+		if err != nil {
+			return nil, connect.NewError(connect.CodeInternal, fmt.Errorf("list layout presets: %w", err))
+		}
+		for _, p := range presets {
+			add(paletteRankCurrentPreset, &leapmuxv1.PaletteAction{
+				Kind:        leapmuxv1.PaletteActionKind_PALETTE_ACTION_KIND_APPLY_LAYOUT_PRESET,
+				Title:       "Apply layout " + p.Name,
+				Subtitle:    current.Title,
+				WorkspaceId: current.ID,
+				LayoutId:    p.ID,
+			})
+		}
+	}
+
+	for _, w := range workers.Rows {
+		// A delegation bearer is pinned to one workspace, so it is only
+		// offered the workers that workspace already uses rather than the
+		// user's whole fleet.
+		if !online[w.ID] || (user.Credential.IsDelegation() && !hostsTab[w.ID]) {
+			continue
+		}
+		a := &leapmuxv1.PaletteAction{
+			Kind:     leapmuxv1.PaletteActionKind_PALETTE_ACTION_KIND_OPEN_AGENT,
+			Title:    "Open agent on worker " + w.ID,
+			WorkerId: w.ID,
+		}
+		if current != nil {
+			a.Subtitle = current.Title
+			a.WorkspaceId = current.ID
+		}
+		add(paletteRankOpenAgent, a)
+	}
+
+	for _, ws := range visible {
+		if current != nil && ws.ID == current.ID {
+			continue
+		}
+		add(paletteRankWorkspace, &leapmuxv1.PaletteAction{
+			Kind:        leapmuxv1.PaletteActionKind_PALETTE_ACTION_KIND_OPEN_WORKSPACE,
+			Title:       "Open workspace " + ws.Title,
+			WorkspaceId: ws.ID,
+		})
+	}
+
+	return connect.NewResponse(&leapmuxv1.SuggestActionsResponse{
+		Actions: rankPaletteActions(candidates, req.Msg.GetQuery(), limit),
+	}), nil
+}
+
+// paletteWorkspaces returns the workspaces the palette may draw on, newest
+// first. A delegation bearer sees only the workspace it is pinned to, as in
+// ListWorkspaces.
+func (s *PaletteService) paletteWorkspaces(ctx context.Context, user *auth.UserInfo, orgID string) ([]store.Workspace, error) {
+	if user.Credential.IsDelegation() {
+		ws, err := loadWorkspaceForRead(ctx, s.store, user.Credential.WorkspaceScopeID(), user)
+		if err != nil {
+			code := connect.CodeOf(err)
+			if code == connect.CodeNotFound || code == connect.CodePermissionDenied {
+				return nil, nil
+			}
+			return nil, err
+		}
+		if orgID != "" && ws.OrgID != orgID {
+			return nil, nil
+		}
+		return []store.Workspace{*ws}, nil
+	}
+	if orgID == "" {
+		orgID = user.OrgID
+	}
+	workspaces, err := s.store.Workspaces().ListAccessible(ctx, store.ListAccessibleWorkspacesParams{
+		UserID: user.ID,
+		OrgID:  orgID,
+	})
+	if err != nil {
+		return nil, connect.NewError(connect.CodeInternal, fmt.Errorf("list workspaces: %w", err))
+	}
+	return workspaces, nil
+}
+
+// rankPaletteActions keeps the candidates matching every query term and
+// returns the first limit of them. Within the matches, a title with a word
+// starting with the query's first term leads, then the candidates' group
+// order; ties keep the order they were gathered in.
+func rankPaletteActions(candidates []paletteCandidate, query string, limit int) []*leapmuxv1.PaletteAction {
+	terms := strings.Fields(strings.ToLower(query))
+	type match struct {
+		paletteCandidate
+		prefix bool
+	}
+	var matches []match
+	for _, c := range candidates {
+		title := strings.ToLower(c.action.GetTitle())
+		haystack := title + "\n" + strings.ToLower(c.action.GetSubtitle())
+		ok := true
+		for _, term := range terms {
+			if !strings.Contains(haystack, term) {
+				ok = false
+				break
+			}
+		}
+		if !ok {
+			continue
+		}
+		m := match{paletteCandidate: c}
+		if len(terms) > 0 {
+			m.prefix = slices.ContainsFunc(strings.Fields(title), func(word string) bool {
+				return strings.HasPrefix(word, terms[0])
+			})
+		}
+		matches = append(matches, m)
+	}
+	slices.SortStableFunc(matches, func(a, b match) int {
+		if a.prefix != b.prefix {
+			if a.prefix {
+				return -1
+			}
+			return 1
+		}
+		return a.rank - b.rank
+	})
+
+	actions := make([]*leapmuxv1.PaletteAction, 0, min(limit, len(matches)))
+	for _, m := range matches[:min(limit, len(matches))] {
+		actions = append(actions, m.action)
+	}
+	return actions
+}
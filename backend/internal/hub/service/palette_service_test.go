@@ -0,0 +1,163 @@
+package service_test
+
+import (
+	"context"
+	"testing"
+
+	"connectrpc.com/connect"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	leapmuxv1 "github.com/leapmux/leapmux/generated/proto/leapmux/v1"
+	"github.com/leapmux/leapmux/internal/hub/auth"
+	"github.com/leapmux/leapmux/internal/hub/service"
+	"github.com/leapmux/leapmux/internal/hub/store"
+	"github.com/leapmux/leapmux/internal/hub/store/storetest"
+	hubtestutil "github.com/leapmux/leapmux/internal/hub/testutil"
+	"github.com/leapmux/leapmux/internal/hub/workermgr"
+	"github.com/leapmux/leapmux/internal/util/userid"
+)
+
+type paletteTestEnv struct {
+	svc     *service.PaletteService
+	st      store.Store
+	ctx     context.Context
+	orgID   string
+	online  string // worker id
+	offline string // worker id
+	current string // workspace id
+	other   string // workspace id
+}
+
+func setupPaletteTest(t *testing.T) *paletteTestEnv {
+	t.Helper()
+	st := hubtestutil.OpenTestStore(t)
+	orgID := storetest.SeedOrg(t, st, "primary-org")
+	user := storetest.SeedUser(t, st, orgID, "alice")
+
+	wMgr := workermgr.New(workermgr.DenyAllReach())
+	online := storetest.SeedWorker(t, st, user.ID)
+	offline := storetest.SeedWorker(t, st, user.ID)
+	_, err := wMgr.Register(&workermgr.Conn{
+		WorkerID: online.ID,
+		SendFn:   func(*leapmuxv1.ConnectResponse) error { return nil },
+	})
+	require.NoError(t, err)
+
+	env := &paletteTestEnv{
+		svc:     service.NewPaletteService(st, wMgr),
+		st:      st,
+		ctx:     auth.WithUser(context.Background(), &auth.UserInfo{ID: userid.MustNew(user.ID), OrgID: orgID}),
+		orgID:   orgID,
+		online:  online.ID,
+		offline: offline.ID,
+		current: storetest.SeedWorkspace(t, st, orgID, user.ID, "Backend"),
+		other:   storetest.SeedWorkspace(t, st, orgID, user.ID, "Docs site"),
+	}
+	env.seedAgentTab(t, env.current, "agent-1", online.ID)
+	env.seedAgentTab(t, env.current, "agent-2", offline.ID)
+	env.seedAgentTab(t, env.other, "agent-3", online.ID)
+
+	_, err = st.WorkspaceLayoutPresets().Upsert(context.Background(), store.UpsertWorkspaceLayoutPresetParams{
+		ID:          "preset-1",
+		UserID:      userid.MustNew(user.ID),
+		WorkspaceID: env.current,
+		Name:        "Review",
+		Root:        []byte{},
+	})
+	require.NoError(t, err)
+	return env
+}
+
+func (e *paletteTestEnv) seedAgentTab(t *testing.T, workspaceID, tabID, workerID string) {
+	t.Helper()
+	require.NoError(t, e.st.WorkspaceTabIndex().UpsertRendered(context.Background(), store.UpsertRenderedTabParams{
+		OrgID:       e.orgID,
+		WorkspaceID: workspaceID,
+		TabType:     leapmuxv1.TabType_TAB_TYPE_AGENT,
+		TabID:       tabID,
+		WorkerID:    workerID,
+		TileID:      "tile-" + tabID,
+		Position:    "pos-" + tabID,
+	}))
+}
+
+func (e *paletteTestEnv) suggest(t *testing.T, req *leapmuxv1.SuggestActionsRequest) []*leapmuxv1.PaletteAction {
+	t.Helper()
+	resp, err := e.svc.SuggestActions(e.ctx, connect.NewRequest(req))
+	require.NoError(t, err)
+	return resp.Msg.GetActions()
+}
+
+func paletteKinds(actions []*leapmuxv1.PaletteAction) []leapmuxv1.PaletteActionKind {
+	kinds := make([]leapmuxv1.PaletteActionKind, len(actions))
+	for i, a := range actions {
+		kinds[i] = a.GetKind()
+	}
+	return kinds
+}
+
+func TestPaletteService_SuggestActions_EmptyQueryOrdersByContext(t *testing.T) {
+	env := setupPaletteTest(t)
+
+	actions := env.suggest(t, &leapmuxv1.SuggestActionsRequest{WorkspaceId: env.current})
+	assert.Equal(t, []leapmuxv1.PaletteActionKind{
+		leapmuxv1.PaletteActionKind_PALETTE_ACTION_KIND_RESUME_AGENT,
+		leapmuxv1.PaletteActionKind_PALETTE_ACTION_KIND_APPLY_LAYOUT_PRESET,
+		leapmuxv1.PaletteActionKind_PALETTE_ACTION_KIND_OPEN_AGENT,
+		leapmuxv1.PaletteActionKind_PALETTE_ACTION_KIND_OPEN_WORKSPACE,
+		leapmuxv1.PaletteActionKind_PALETTE_ACTION_KIND_RESUME_AGENT,
+	}, paletteKinds(actions))
+
+	// Only agents and workers that are online are offered.
+	assert.Equal(t, "agent-1", actions[0].GetTabId())
+	assert.Equal(t, env.current, actions[0].GetWorkspaceId())
+	assert.Equal(t, "preset-1", actions[1].GetLayoutId())
+	assert.Equal(t, env.online, actions[2].GetWorkerId())
+	assert.Equal(t, env.current, actions[2].GetWorkspaceId(), "a new agent opens in the current workspace")
+	assert.Equal(t, env.other, actions[3].GetWorkspaceId())
+	assert.Equal(t, "agent-3", actions[4].GetTabId())
+}
+
+func TestPaletteService_SuggestActions_QueryFiltersAndRanks(t *testing.T) {
+	env := setupPaletteTest(t)
+
+	actions := env.suggest(t, &leapmuxv1.SuggestActionsRequest{WorkspaceId: env.current, Query: "DOCS"})
+	require.Len(t, actions, 2)
+	assert.Equal(t, leapmuxv1.PaletteActionKind_PALETTE_ACTION_KIND_OPEN_WORKSPACE, actions[0].GetKind())
+	assert.Equal(t, "agent-3", actions[1].GetTabId())
+
+	// Every term must match; the subtitle counts.
+	actions = env.suggest(t, &leapmuxv1.SuggestActionsRequest{WorkspaceId: env.current, Query: "resume " + env.online})
+	assert.Len(t, actions, 2)
+
+	// A title word starting with the query outranks group order.
+	actions = env.suggest(t, &leapmuxv1.SuggestActionsRequest{WorkspaceId: env.current, Query: "open"})
+	assert.Equal(t, []leapmuxv1.PaletteActionKind{
+		leapmuxv1.PaletteActionKind_PALETTE_ACTION_KIND_OPEN_AGENT,
+		leapmuxv1.PaletteActionKind_PALETTE_ACTION_KIND_OPEN_WORKSPACE,
+	}, paletteKinds(actions))
+
+	assert.Empty(t, env.suggest(t, &leapmuxv1.SuggestActionsRequest{Query: "nonesuch"}))
+}
+
+func TestPaletteService_SuggestActions_Limit(t *testing.T) {
+	env := setupPaletteTest(t)
+
+	actions := env.suggest(t, &leapmuxv1.SuggestActionsRequest{WorkspaceId: env.current, Limit: 2})
+	assert.Equal(t, []leapmuxv1.PaletteActionKind{
+		leapmuxv1.PaletteActionKind_PALETTE_ACTION_KIND_RESUME_AGENT,
+		leapmuxv1.PaletteActionKind_PALETTE_ACTION_KIND_APPLY_LAYOUT_PRESET,
+	}, paletteKinds(actions))
+}
+
+func TestPaletteService_SuggestActions_IgnoresOtherUsersWorkspace(t *testing.T) {
+	env := setupPaletteTest(t)
+	bob := storetest.SeedUser(t, env.st, env.orgID, "bob")
+	foreign := storetest.SeedWorkspace(t, env.st, env.orgID, bob.ID, "Secret")
+
+	for _, a := range env.suggest(t, &leapmuxv1.SuggestActionsRequest{WorkspaceId: foreign}) {
+		assert.NotEqual(t, foreign, a.GetWorkspaceId())
+		assert.NotContains(t, a.GetTitle(), "Secret")
+	}
+}
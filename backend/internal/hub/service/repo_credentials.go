@@ -0,0 +1,295 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/url"
+	"strings"
+	"unicode"
+
+	"connectrpc.com/connect"
+	"golang.org/x/crypto/ssh"
+
+	leapmuxv1 "github.com/leapmux/leapmux/generated/proto/leapmux/v1"
+	"github.com/leapmux/leapmux/internal/hub/keystore"
+	"github.com/leapmux/leapmux/internal/hub/store"
+	"github.com/leapmux/leapmux/internal/util/id"
+	"github.com/leapmux/leapmux/internal/util/timefmt"
+)
+
+const (
+	// maxGitCredentialsPerOrg caps an org's git credentials.
+	maxGitCredentialsPerOrg = 64
+	// maxGitCredentialSecretLen bounds a private key or token.
+	maxGitCredentialSecretLen = 16 << 10
+	// maxGitCredentialUsernameLen bounds an HTTPS username.
+	maxGitCredentialUsernameLen = 255
+	// defaultGitTokenUsername is the username sent with a token when none
+	// is given. GitHub and GitLab accept any non-empty username alongside
+	// a token; this is the one GitHub documents for app tokens.
+	defaultGitTokenUsername = "x-access-token"
+)
+
+func (s *RepoService) ListGitCredentials(
+	ctx context.Context,
+	req *connect.Request[leapmuxv1.ListGitCredentialsRequest],
+) (*connect.Response[leapmuxv1.ListGitCredentialsResponse], error) {
+	orgID, err := repoCallerOrg(ctx, req.Msg.GetOrgId())
+	if err != nil {
+		return nil, err
+	}
+	creds, err := s.store.GitCredentials().ListByOrg(ctx, orgID)
+	if err != nil {
+		return nil, connect.NewError(connect.CodeInternal, err)
+	}
+	pb := make([]*leapmuxv1.GitCredential, len(creds))
+	for i := range creds {
+		pb[i] = gitCredentialToProto(&creds[i])
+	}
+	return connect.NewResponse(&leapmuxv1.ListGitCredentialsResponse{Credentials: pb}), nil
+}
+
+func (s *RepoService) CreateGitCredential(
+	ctx context.Context,
+	req *connect.Request[leapmuxv1.CreateGitCredentialRequest],
+) (*connect.Response[leapmuxv1.CreateGitCredentialResponse], error) {
+	orgID, err := repoCallerOrg(ctx, req.Msg.GetOrgId())
+	if err != nil {
+		return nil, err
+	}
+	name := strings.TrimSpace(req.Msg.GetName())
+	if !repoNamePattern.MatchString(name) || strings.Contains(name, "..") {
+		return nil, connect.NewError(connect.CodeInvalidArgument,
+			errors.New("name: must be 1-100 letters, digits, '.', '_' or '-', starting with a letter or digit"))
+	}
+	kind := req.Msg.GetKind()
+	username, err := validateGitCredential(kind, req.Msg.GetUsername(), req.Msg.GetSecret())
+	if err != nil {
+		return nil, connect.NewError(connect.CodeInvalidArgument, err)
+	}
+
+	existing, err := s.store.GitCredentials().ListByOrg(ctx, orgID)
+	if err != nil {
+		return nil, connect.NewError(connect.CodeInternal, err)
+	}
+	if len(existing) >= maxGitCredentialsPerOrg {
+		return nil, connect.NewError(connect.CodeResourceExhausted,
+			fmt.Errorf("an organization can store at most %d git credentials", maxGitCredentialsPerOrg))
+	}
+
+	credID := id.Generate()
+	secret, err := s.keystore.Encrypt([]byte(req.Msg.GetSecret()), keystore.GitCredentialAAD(credID))
+	if err != nil {
+		return nil, connect.NewError(connect.CodeInternal, fmt.Errorf("encrypt secret: %w", err))
+	}
+	cred, err := s.store.GitCredentials().Create(ctx, store.CreateGitCredentialParams{
+		ID:       credID,
+		OrgID:    orgID,
+		Name:     name,
+		Kind:     kind,
+		Username: username,
+		Secret:   secret,
+	})
+	if errors.Is(err, store.ErrConflict) {
+		return nil, connect.NewError(connect.CodeAlreadyExists, fmt.Errorf("a git credential named %q already exists", name))
+	}
+	if err != nil {
+		return nil, connect.NewError(connect.CodeInternal, fmt.Errorf("create git credential: %w", err))
+	}
+	return connect.NewResponse(&leapmuxv1.CreateGitCredentialResponse{Credential: gitCredentialToProto(cred)}), nil
+}
+
+func (s *RepoService) UpdateGitCredential(
+	ctx context.Context,
+	req *connect.Request[leapmuxv1.UpdateGitCredentialRequest],
+) (*connect.Response[leapmuxv1.UpdateGitCredentialResponse], error) {
+	orgID, err := repoCallerOrg(ctx, "")
+	if err != nil {
+		return nil, err
+	}
+	current, err := s.store.GitCredentials().Get(ctx, store.GetGitCredentialParams{ID: req.Msg.GetCredentialId(), OrgID: orgID})
+	if errors.Is(err, store.ErrNotFound) {
+		return nil, connect.NewError(connect.CodeNotFound, errors.New("git credential not found"))
+	}
+	if err != nil {
+		return nil, connect.NewError(connect.CodeInternal, err)
+	}
+	username, err := validateGitCredential(current.Kind, req.Msg.GetUsername(), req.Msg.GetSecret())
+	if err != nil {
+		return nil, connect.NewError(connect.CodeInvalidArgument, err)
+	}
+	secret, err := s.keystore.Encrypt([]byte(req.Msg.GetSecret()), keystore.GitCredentialAAD(current.ID))
+	if err != nil {
+		return nil, connect.NewError(connect.CodeInternal, fmt.Errorf("encrypt secret: %w", err))
+	}
+	cred, err := s.store.GitCredentials().Update(ctx, store.UpdateGitCredentialParams{
+		ID:       current.ID,
+		OrgID:    orgID,
+		Username: username,
+		Secret:   secret,
+	})
+	if errors.Is(err, store.ErrNotFound) {
+		return nil, connect.NewError(connect.CodeNotFound, errors.New("git credential not found"))
+	}
+	if err != nil {
+		return nil, connect.NewError(connect.CodeInternal, fmt.Errorf("update git credential: %w", err))
+	}
+	return connect.NewResponse(&leapmuxv1.UpdateGitCredentialResponse{Credential: gitCredentialToProto(cred)}), nil
+}
+
+func (s *RepoService) DeleteGitCredential(
+	ctx context.Context,
+	req *connect.Request[leapmuxv1.DeleteGitCredentialRequest],
+) (*connect.Response[leapmuxv1.DeleteGitCredentialResponse], error) {
+	orgID, err := repoCallerOrg(ctx, "")
+	if err != nil {
+		return nil, err
+	}
+	cred, err := s.store.GitCredentials().Get(ctx, store.GetGitCredentialParams{ID: req.Msg.GetCredentialId(), OrgID: orgID})
+	if errors.Is(err, store.ErrNotFound) {
+		return nil, connect.NewError(connect.CodeNotFound, errors.New("git credential not found"))
+	}
+	if err != nil {
+		return nil, connect.NewError(connect.CodeInternal, err)
+	}
+	repos, err := s.store.Repos().ListByOrg(ctx, orgID)
+	if err != nil {
+		return nil, connect.NewError(connect.CodeInternal, err)
+	}
+	for _, r := range repos {
+		if r.CredentialRef == cred.Name {
+			return nil, connect.NewError(connect.CodeFailedPrecondition,
+				fmt.Errorf("git credential %q is used by repository %q", cred.Name, r.Name))
+		}
+	}
+	if _, err := s.store.GitCredentials().Delete(ctx, store.GetGitCredentialParams{ID: cred.ID, OrgID: orgID}); err != nil {
+		return nil, connect.NewError(connect.CodeInternal, err)
+	}
+	return connect.NewResponse(&leapmuxv1.DeleteGitCredentialResponse{}), nil
+}
+
+// requireGitCredential fails with InvalidArgument unless name is empty or
+// names one of the org's git credentials that can be used with repoURL.
+func (s *RepoService) requireGitCredential(ctx context.Context, orgID, name, repoURL string) error {
+	if name == "" {
+		return nil
+	}
+	cred, err := s.store.GitCredentials().GetByName(ctx, store.GetGitCredentialByNameParams{OrgID: orgID, Name: name})
+	if errors.Is(err, store.ErrNotFound) {
+		return connect.NewError(connect.CodeInvalidArgument, fmt.Errorf("credential_ref: no git credential named %q", name))
+	}
+	if err != nil {
+		return connect.NewError(connect.CodeInternal, err)
+	}
+	if err := checkCredentialURL(cred.Kind, repoURL); err != nil {
+		return connect.NewError(connect.CodeInvalidArgument, fmt.Errorf("url: %w", err))
+	}
+	return nil
+}
+
+// checkCredentialURL rejects a repository URL the worker would refuse to
+// use a credential of kind with. A token goes over https only, never in
+// cleartext over http.
+func checkCredentialURL(kind leapmuxv1.GitCredentialKind, repoURL string) error {
+	var scheme string
+	if strings.Contains(repoURL, "://") {
+		if u, err := url.Parse(repoURL); err == nil {
+			scheme = u.Scheme
+		}
+	}
+	isHTTPS := scheme == "https"
+	isHTTP := isHTTPS || scheme == "http"
+	switch kind {
+	case leapmuxv1.GitCredentialKind_GIT_CREDENTIAL_KIND_SSH_KEY:
+		if isHTTP {
+			return errors.New("an SSH key credential needs an ssh or user@host:path URL")
+		}
+	case leapmuxv1.GitCredentialKind_GIT_CREDENTIAL_KIND_HTTPS_TOKEN:
+		if !isHTTPS {
+			return errors.New("a token credential needs an https URL")
+		}
+	}
+	return nil
+}
+
+// validateGitCredential checks a secret against its kind and returns the
+// username to store.
+func validateGitCredential(kind leapmuxv1.GitCredentialKind, username, secret string) (string, error) {
+	if secret == "" {
+		return "", errors.New("secret: must not be empty")
+	}
+	if len(secret) > maxGitCredentialSecretLen {
+		return "", fmt.Errorf("secret: must be at most %d bytes", maxGitCredentialSecretLen)
+	}
+	username = strings.TrimSpace(username)
+	switch kind {
+	case leapmuxv1.GitCredentialKind_GIT_CREDENTIAL_KIND_SSH_KEY:
+		if username != "" {
+			return "", errors.New("username: SSH keys take the user from the repository URL")
+		}
+		_, err := ssh.ParseRawPrivateKey([]byte(secret))
+		var missing *ssh.PassphraseMissingError
+		switch {
+		case errors.As(err, &missing):
+			return "", errors.New("secret: the key must not be protected by a passphrase")
+		case err != nil:
+			return "", errors.New("secret: not a PEM-encoded SSH private key")
+		}
+		return "", nil
+	case leapmuxv1.GitCredentialKind_GIT_CREDENTIAL_KIND_HTTPS_TOKEN:
+		if strings.IndexFunc(secret, func(r rune) bool { return unicode.IsSpace(r) || unicode.IsControl(r) }) >= 0 {
+			return "", errors.New("secret: a token must not contain whitespace")
+		}
+		if username == "" {
+			username = defaultGitTokenUsername
+		}
+		if len(username) > maxGitCredentialUsernameLen ||
+			strings.IndexFunc(username, func(r rune) bool { return r == ':' || unicode.IsSpace(r) || unicode.IsControl(r) }) >= 0 {
+			return "", fmt.Errorf("username: must be at most %d characters, without ':' or whitespace", maxGitCredentialUsernameLen)
+		}
+		return username, nil
+	default:
+		return "", errors.New("kind: must be SSH_KEY or HTTPS_TOKEN")
+	}
+}
+
+// loadRepoCredential decrypts the org's git credential named name for
+// delivery to a worker. An empty name yields nil.
+func loadRepoCredential(ctx context.Context, st store.Store, ks *keystore.Keystore, orgID, name string) (*leapmuxv1.RepoCredential, error) {
+	if name == "" {
+		return nil, nil
+	}
+	cred, err := st.GitCredentials().GetByName(ctx, store.GetGitCredentialByNameParams{OrgID: orgID, Name: name})
+	if errors.Is(err, store.ErrNotFound) {
+		return nil, connect.NewError(connect.CodeFailedPrecondition, fmt.Errorf("the repository's git credential %q no longer exists", name))
+	}
+	if err != nil {
+		return nil, connect.NewError(connect.CodeInternal, fmt.Errorf("load git credential: %w", err))
+	}
+	if ks == nil {
+		return nil, connect.NewError(connect.CodeUnimplemented, errors.New("git credentials are not available on this hub"))
+	}
+	secret, err := ks.Decrypt(cred.Secret, keystore.GitCredentialAAD(cred.ID))
+	if err != nil {
+		return nil, connect.NewError(connect.CodeInternal, fmt.Errorf("decrypt git credential: %w", err))
+	}
+	return &leapmuxv1.RepoCredential{
+		Id:       cred.ID,
+		Kind:     cred.Kind,
+		Username: cred.Username,
+		Secret:   string(secret),
+	}, nil
+}
+
+func gitCredentialToProto(c *store.GitCredential) *leapmuxv1.GitCredential {
+	return &leapmuxv1.GitCredential{
+		Id:        c.ID,
+		OrgId:     c.OrgID,
+		Name:      c.Name,
+		Kind:      c.Kind,
+		Username:  c.Username,
+		CreatedAt: timefmt.Format(c.CreatedAt),
+		UpdatedAt: timefmt.Format(c.UpdatedAt),
+	}
+}
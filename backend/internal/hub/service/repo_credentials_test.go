@@ -0,0 +1,176 @@
+package service_test
+
+import (
+	"context"
+	"crypto/ed25519"
+	"crypto/rand"
+	"encoding/pem"
+	"testing"
+	"time"
+
+	"connectrpc.com/connect"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"golang.org/x/crypto/ssh"
+
+	leapmuxv1 "github.com/leapmux/leapmux/generated/proto/leapmux/v1"
+	"github.com/leapmux/leapmux/internal/hub/auth"
+	"github.com/leapmux/leapmux/internal/hub/service"
+	"github.com/leapmux/leapmux/internal/hub/store/storetest"
+	hubtestutil "github.com/leapmux/leapmux/internal/hub/testutil"
+	"github.com/leapmux/leapmux/internal/hub/workermgr"
+	"github.com/leapmux/leapmux/internal/util/userid"
+)
+
+func testSSHKey(t *testing.T, passphrase string) string {
+	t.Helper()
+	_, priv, err := ed25519.GenerateKey(rand.Reader)
+	require.NoError(t, err)
+	var block *pem.Block
+	if passphrase == "" {
+		block, err = ssh.MarshalPrivateKey(priv, "")
+	} else {
+		block, err = ssh.MarshalPrivateKeyWithPassphrase(priv, "", []byte(passphrase))
+	}
+	require.NoError(t, err)
+	return string(pem.EncodeToMemory(block))
+}
+
+func TestRepoService_GitCredentials(t *testing.T) {
+	st := hubtestutil.OpenTestStore(t)
+	orgID := storetest.SeedOrg(t, st, "repo-org")
+	user := storetest.SeedUser(t, st, orgID, "alice")
+	ctx := auth.WithUser(context.Background(), &auth.UserInfo{ID: userid.MustNew(user.ID), OrgID: orgID})
+	svc := service.NewRepoService(st, newTestKeystore(t))
+
+	created, err := svc.CreateGitCredential(ctx, connect.NewRequest(&leapmuxv1.CreateGitCredentialRequest{
+		Name: "deploy", Kind: leapmuxv1.GitCredentialKind_GIT_CREDENTIAL_KIND_SSH_KEY, Secret: testSSHKey(t, ""),
+	}))
+	require.NoError(t, err)
+	cred := created.Msg.GetCredential()
+	assert.Equal(t, "deploy", cred.GetName())
+	assert.Empty(t, cred.GetUsername())
+
+	token, err := svc.CreateGitCredential(ctx, connect.NewRequest(&leapmuxv1.CreateGitCredentialRequest{
+		Name: "ci", Kind: leapmuxv1.GitCredentialKind_GIT_CREDENTIAL_KIND_HTTPS_TOKEN, Secret: "ghp_abc",
+	}))
+	require.NoError(t, err)
+	assert.Equal(t, "x-access-token", token.Msg.GetCredential().GetUsername(), "default token username")
+
+	_, err = svc.CreateGitCredential(ctx, connect.NewRequest(&leapmuxv1.CreateGitCredentialRequest{
+		Name: "deploy", Kind: leapmuxv1.GitCredentialKind_GIT_CREDENTIAL_KIND_HTTPS_TOKEN, Secret: "t",
+	}))
+	assert.Equal(t, connect.CodeAlreadyExists, connect.CodeOf(err))
+
+	list, err := svc.ListGitCredentials(ctx, connect.NewRequest(&leapmuxv1.ListGitCredentialsRequest{}))
+	require.NoError(t, err)
+	assert.Len(t, list.Msg.GetCredentials(), 2)
+
+	updated, err := svc.UpdateGitCredential(ctx, connect.NewRequest(&leapmuxv1.UpdateGitCredentialRequest{
+		CredentialId: token.Msg.GetCredential().GetId(), Username: "bot", Secret: "ghp_def",
+	}))
+	require.NoError(t, err)
+	assert.Equal(t, "bot", updated.Msg.GetCredential().GetUsername())
+
+	// A repository using the credential keeps it from being deleted.
+	_, err = svc.CreateRepo(ctx, connect.NewRequest(&leapmuxv1.CreateRepoRequest{
+		Url: "git@github.com:acme/private.git", CredentialRef: "deploy",
+	}))
+	require.NoError(t, err)
+
+	// A token is never sent over cleartext http, nor an SSH key offered
+	// to an http(s) remote.
+	_, err = svc.CreateRepo(ctx, connect.NewRequest(&leapmuxv1.CreateRepoRequest{
+		Url: "http://git.internal/acme/app.git", CredentialRef: "ci",
+	}))
+	assert.Equal(t, connect.CodeInvalidArgument, connect.CodeOf(err), "token over http")
+	_, err = svc.CreateRepo(ctx, connect.NewRequest(&leapmuxv1.CreateRepoRequest{
+		Url: "git@github.com:acme/app.git", CredentialRef: "ci",
+	}))
+	assert.Equal(t, connect.CodeInvalidArgument, connect.CodeOf(err), "token over ssh")
+	_, err = svc.CreateRepo(ctx, connect.NewRequest(&leapmuxv1.CreateRepoRequest{
+		Url: "https://github.com/acme/app.git", CredentialRef: "deploy",
+	}))
+	assert.Equal(t, connect.CodeInvalidArgument, connect.CodeOf(err), "SSH key over https")
+	app, err := svc.CreateRepo(ctx, connect.NewRequest(&leapmuxv1.CreateRepoRequest{
+		Url: "https://github.com/acme/app.git", CredentialRef: "ci",
+	}))
+	require.NoError(t, err)
+	_, err = svc.UpdateRepo(ctx, connect.NewRequest(&leapmuxv1.UpdateRepoRequest{
+		RepoId: app.Msg.GetRepo().GetId(), Url: "http://github.com/acme/app.git", CredentialRef: "ci",
+	}))
+	assert.Equal(t, connect.CodeInvalidArgument, connect.CodeOf(err), "moving a token repo to http")
+	_, err = svc.DeleteRepo(ctx, connect.NewRequest(&leapmuxv1.DeleteRepoRequest{RepoId: app.Msg.GetRepo().GetId()}))
+	require.NoError(t, err)
+	_, err = svc.DeleteGitCredential(ctx, connect.NewRequest(&leapmuxv1.DeleteGitCredentialRequest{CredentialId: cred.GetId()}))
+	assert.Equal(t, connect.CodeFailedPrecondition, connect.CodeOf(err))
+	_, err = svc.DeleteGitCredential(ctx, connect.NewRequest(&leapmuxv1.DeleteGitCredentialRequest{CredentialId: token.Msg.GetCredential().GetId()}))
+	require.NoError(t, err)
+
+	_, err = svc.CreateRepo(ctx, connect.NewRequest(&leapmuxv1.CreateRepoRequest{
+		Url: "https://github.com/acme/other.git", CredentialRef: "ci",
+	}))
+	assert.Equal(t, connect.CodeInvalidArgument, connect.CodeOf(err), "credential_ref names a credential that no longer exists")
+}
+
+func TestRepoService_GitCredentialsRejectInvalid(t *testing.T) {
+	tests := []struct {
+		name string
+		req  *leapmuxv1.CreateGitCredentialRequest
+	}{
+		{name: "no kind", req: &leapmuxv1.CreateGitCredentialRequest{Name: "a", Secret: "t"}},
+		{name: "no secret", req: &leapmuxv1.CreateGitCredentialRequest{Name: "a", Kind: leapmuxv1.GitCredentialKind_GIT_CREDENTIAL_KIND_HTTPS_TOKEN}},
+		{name: "bad name", req: &leapmuxv1.CreateGitCredentialRequest{Name: "../a", Kind: leapmuxv1.GitCredentialKind_GIT_CREDENTIAL_KIND_HTTPS_TOKEN, Secret: "t"}},
+		{name: "token with space", req: &leapmuxv1.CreateGitCredentialRequest{Name: "a", Kind: leapmuxv1.GitCredentialKind_GIT_CREDENTIAL_KIND_HTTPS_TOKEN, Secret: "a b"}},
+		{name: "username with colon", req: &leapmuxv1.CreateGitCredentialRequest{Name: "a", Kind: leapmuxv1.GitCredentialKind_GIT_CREDENTIAL_KIND_HTTPS_TOKEN, Username: "a:b", Secret: "t"}},
+		{name: "not a key", req: &leapmuxv1.CreateGitCredentialRequest{Name: "a", Kind: leapmuxv1.GitCredentialKind_GIT_CREDENTIAL_KIND_SSH_KEY, Secret: "hello"}},
+		{name: "key with username", req: &leapmuxv1.CreateGitCredentialRequest{Name: "a", Kind: leapmuxv1.GitCredentialKind_GIT_CREDENTIAL_KIND_SSH_KEY, Username: "git", Secret: testSSHKey(t, "")}},
+		{name: "encrypted key", req: &leapmuxv1.CreateGitCredentialRequest{Name: "a", Kind: leapmuxv1.GitCredentialKind_GIT_CREDENTIAL_KIND_SSH_KEY, Secret: testSSHKey(t, "pw")}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			st := hubtestutil.OpenTestStore(t)
+			orgID := storetest.SeedOrg(t, st, "repo-org")
+			user := storetest.SeedUser(t, st, orgID, "alice")
+			ctx := auth.WithUser(context.Background(), &auth.UserInfo{ID: userid.MustNew(user.ID), OrgID: orgID})
+
+			_, err := service.NewRepoService(st, newTestKeystore(t)).CreateGitCredential(ctx, connect.NewRequest(tt.req))
+			assert.Equal(t, connect.CodeInvalidArgument, connect.CodeOf(err))
+		})
+	}
+}
+
+func TestWorkspaceService_CreateWorkspace_DeliversRepoCredential(t *testing.T) {
+	st := hubtestutil.OpenTestStore(t)
+	orgID := storetest.SeedOrg(t, st, "repo-org")
+	user := storetest.SeedUser(t, st, orgID, "alice")
+	uid := userid.MustNew(user.ID)
+	ctx := auth.WithUser(context.Background(), &auth.UserInfo{ID: uid, OrgID: orgID})
+	ks := newTestKeystore(t)
+
+	mgr := workermgr.New(service.NewWorkerReachAuthorizer(st))
+	pending := workermgr.NewPendingRequests(func() time.Duration { return 5 * time.Second })
+	got := registerCheckoutWorker(t, st, mgr, pending, uid, "w-1")
+
+	repos := service.NewRepoService(st, ks)
+	cred, err := repos.CreateGitCredential(ctx, connect.NewRequest(&leapmuxv1.CreateGitCredentialRequest{
+		Name: "ci", Kind: leapmuxv1.GitCredentialKind_GIT_CREDENTIAL_KIND_HTTPS_TOKEN, Secret: "ghp_abc",
+	}))
+	require.NoError(t, err)
+	repo, err := repos.CreateRepo(ctx, connect.NewRequest(&leapmuxv1.CreateRepoRequest{
+		Url: "https://github.com/acme/private.git", CredentialRef: "ci",
+	}))
+	require.NoError(t, err)
+
+	svc := service.NewWorkspaceService(st, nil, noopWorkspaceChannelCloser{}).WithRepoCheckouts(mgr, pending, ks)
+	_, err = svc.CreateWorkspace(ctx, connect.NewRequest(&leapmuxv1.CreateWorkspaceRequest{
+		Title: "private", RepoId: repo.Msg.GetRepo().GetId(),
+	}))
+	require.NoError(t, err)
+	delivered := (<-got).GetCredential()
+	require.NotNil(t, delivered)
+	assert.Equal(t, cred.Msg.GetCredential().GetId(), delivered.GetId())
+	assert.Equal(t, leapmuxv1.GitCredentialKind_GIT_CREDENTIAL_KIND_HTTPS_TOKEN, delivered.GetKind())
+	assert.Equal(t, "x-access-token", delivered.GetUsername())
+	assert.Equal(t, "ghp_abc", delivered.GetSecret(), "decrypted for the worker")
+}
@@ -0,0 +1,425 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/url"
+	"path"
+	"regexp"
+	"slices"
+	"strings"
+	"unicode"
+
+	"connectrpc.com/connect"
+
+	leapmuxv1 "github.com/leapmux/leapmux/generated/proto/leapmux/v1"
+	"github.com/leapmux/leapmux/internal/errcode"
+	"github.com/leapmux/leapmux/internal/hub/auth"
+	"github.com/leapmux/leapmux/internal/hub/keystore"
+	"github.com/leapmux/leapmux/internal/hub/store"
+	"github.com/leapmux/leapmux/internal/hub/workermgr"
+	"github.com/leapmux/leapmux/internal/policy"
+	"github.com/leapmux/leapmux/internal/util/id"
+	"github.com/leapmux/leapmux/internal/util/timefmt"
+)
+
+const (
+	// maxReposPerOrg caps an org's registry.
+	maxReposPerOrg = 256
+	// maxRepoLabels caps the preferred labels of a repo and the labels a
+	// worker may report.
+	maxRepoLabels = 16
+	// maxRepoURLLen and maxRepoBranchLen bound the free-form fields.
+	maxRepoURLLen    = 2048
+	maxRepoBranchLen = 256
+	// maxWorkersConsidered bounds the workers scanned when picking one for
+	// a checkout.
+	maxWorkersConsidered = 100
+)
+
+var (
+	// repoNamePattern keeps a repo name usable as a directory name on every
+	// platform a worker runs on.
+	repoNamePattern = regexp.MustCompile(`^[A-Za-z0-9][A-Za-z0-9._-]{0,99}$`)
+	// workerLabelPattern is the shape of a worker label.
+	workerLabelPattern = regexp.MustCompile(`^[a-z0-9][a-z0-9._-]{0,62}$`)
+	// scpLikeURLPattern matches git's scp-like syntax, user@host:path.
+	scpLikeURLPattern = regexp.MustCompile(`^[A-Za-z0-9._-]+@[A-Za-z0-9.-]+:[^:]`)
+)
+
+// RepoService implements the RepoServiceHandler interface. Repos and git
+// credentials belong to the caller's org; workspace-scoped credentials
+// (delegation, guest, public viewer) cannot reach them.
+type RepoService struct {
+	store    store.Store
+	keystore *keystore.Keystore
+}
+
+// NewRepoService creates a new RepoService. ks encrypts git credential
+// secrets.
+func NewRepoService(st store.Store, ks *keystore.Keystore) *RepoService {
+	return &RepoService{store: st, keystore: ks}
+}
+
+func (s *RepoService) ListRepos(
+	ctx context.Context,
+	req *connect.Request[leapmuxv1.ListReposRequest],
+) (*connect.Response[leapmuxv1.ListReposResponse], error) {
+	orgID, err := repoCallerOrg(ctx, req.Msg.GetOrgId())
+	if err != nil {
+		return nil, err
+	}
+	repos, err := s.store.Repos().ListByOrg(ctx, orgID)
+	if err != nil {
+		return nil, connect.NewError(connect.CodeInternal, err)
+	}
+	pb := make([]*leapmuxv1.Repo, len(repos))
+	for i := range repos {
+		pb[i] = repoToProto(&repos[i])
+	}
+	return connect.NewResponse(&leapmuxv1.ListReposResponse{Repos: pb}), nil
+}
+
+func (s *RepoService) CreateRepo(
+	ctx context.Context,
+	req *connect.Request[leapmuxv1.CreateRepoRequest],
+) (*connect.Response[leapmuxv1.CreateRepoResponse], error) {
+	orgID, err := repoCallerOrg(ctx, req.Msg.GetOrgId())
+	if err != nil {
+		return nil, err
+	}
+	spec, err := validateRepoSpec(req.Msg.GetName(), req.Msg.GetUrl(), req.Msg.GetDefaultBranch(), req.Msg.GetCredentialRef(), req.Msg.GetWorkerLabels())
+	if err != nil {
+		return nil, connect.NewError(connect.CodeInvalidArgument, err)
+	}
+	if err := s.requireGitCredential(ctx, orgID, spec.CredentialRef, spec.URL); err != nil {
+		return nil, err
+	}
+
+	existing, err := s.store.Repos().ListByOrg(ctx, orgID)
+	if err != nil {
+		return nil, connect.NewError(connect.CodeInternal, err)
+	}
+	if len(existing) >= maxReposPerOrg {
+		return nil, connect.NewError(connect.CodeResourceExhausted,
+			fmt.Errorf("an organization can register at most %d repositories", maxReposPerOrg))
+	}
+
+	spec.ID = id.Generate()
+	spec.OrgID = orgID
+	repo, err := s.store.Repos().Create(ctx, spec)
+	if errors.Is(err, store.ErrConflict) {
+		return nil, connect.NewError(connect.CodeAlreadyExists, fmt.Errorf("a repository named %q is already registered", spec.Name))
+	}
+	if err != nil {
+		return nil, connect.NewError(connect.CodeInternal, fmt.Errorf("create repo: %w", err))
+	}
+	return connect.NewResponse(&leapmuxv1.CreateRepoResponse{Repo: repoToProto(repo)}), nil
+}
+
+func (s *RepoService) UpdateRepo(
+	ctx context.Context,
+	req *connect.Request[leapmuxv1.UpdateRepoRequest],
+) (*connect.Response[leapmuxv1.UpdateRepoResponse], error) {
+	orgID, err := repoCallerOrg(ctx, "")
+	if err != nil {
+		return nil, err
+	}
+	spec, err := validateRepoSpec(req.Msg.GetName(), req.Msg.GetUrl(), req.Msg.GetDefaultBranch(), req.Msg.GetCredentialRef(), req.Msg.GetWorkerLabels())
+	if err != nil {
+		return nil, connect.NewError(connect.CodeInvalidArgument, err)
+	}
+	if err := s.requireGitCredential(ctx, orgID, spec.CredentialRef, spec.URL); err != nil {
+		return nil, err
+	}
+
+	repo, err := s.store.Repos().Update(ctx, store.UpdateRepoParams{
+		ID:            req.Msg.GetRepoId(),
+		OrgID:         orgID,
+		Name:          spec.Name,
+		URL:           spec.URL,
+		DefaultBranch: spec.DefaultBranch,
+		CredentialRef: spec.CredentialRef,
+		WorkerLabels:  spec.WorkerLabels,
+	})
+	switch {
+	case errors.Is(err, store.ErrNotFound):
+		return nil, connect.NewError(connect.CodeNotFound, errors.New("repository not found"))
+	case errors.Is(err, store.ErrConflict):
+		return nil, connect.NewError(connect.CodeAlreadyExists, fmt.Errorf("a repository named %q is already registered", spec.Name))
+	case err != nil:
+		return nil, connect.NewError(connect.CodeInternal, fmt.Errorf("update repo: %w", err))
+	}
+	return connect.NewResponse(&leapmuxv1.UpdateRepoResponse{Repo: repoToProto(repo)}), nil
+}
+
+func (s *RepoService) DeleteRepo(
+	ctx context.Context,
+	req *connect.Request[leapmuxv1.DeleteRepoRequest],
+) (*connect.Response[leapmuxv1.DeleteRepoResponse], error) {
+	orgID, err := repoCallerOrg(ctx, "")
+	if err != nil {
+		return nil, err
+	}
+	n, err := s.store.Repos().Delete(ctx, store.GetRepoParams{ID: req.Msg.GetRepoId(), OrgID: orgID})
+	if err != nil {
+		return nil, connect.NewError(connect.CodeInternal, err)
+	}
+	if n == 0 {
+		return nil, connect.NewError(connect.CodeNotFound, errors.New("repository not found"))
+	}
+	return connect.NewResponse(&leapmuxv1.DeleteRepoResponse{}), nil
+}
+
+// repoCallerOrg resolves the org a repo request acts on. Only a credential
+// that speaks for the whole account may manage the org's registry.
+func repoCallerOrg(ctx context.Context, requestedOrgID string) (string, error) {
+	user, err := auth.MustGetUser(ctx)
+	if err != nil {
+		return "", err
+	}
+	if user.Credential.IsWorkspaceScoped() {
+		return "", connect.NewError(connect.CodePermissionDenied, errors.New("repositories are managed by the organization's own account"))
+	}
+	return auth.ResolveOrgID(user, requestedOrgID)
+}
+
+// validateRepoSpec checks and normalizes a repo's fields. An empty name is
+// derived from the URL.
+func validateRepoSpec(name, rawURL, branch, credentialRef string, labels []string) (store.CreateRepoParams, error) {
+	rawURL = strings.TrimSpace(rawURL)
+	if err := validateRepoURL(rawURL); err != nil {
+		return store.CreateRepoParams{}, fmt.Errorf("url: %w", err)
+	}
+	name = strings.TrimSpace(name)
+	if name == "" {
+		name = repoNameFromURL(rawURL)
+	}
+	if !repoNamePattern.MatchString(name) || strings.Contains(name, "..") {
+		return store.CreateRepoParams{}, errors.New("name: must be 1-100 letters, digits, '.', '_' or '-', starting with a letter or digit")
+	}
+	branch = strings.TrimSpace(branch)
+	if err := validateRepoBranch(branch); err != nil {
+		return store.CreateRepoParams{}, fmt.Errorf("default_branch: %w", err)
+	}
+	credentialRef = strings.TrimSpace(credentialRef)
+	if credentialRef != "" && !repoNamePattern.MatchString(credentialRef) {
+		return store.CreateRepoParams{}, errors.New("credential_ref: must name a git credential")
+	}
+	normalized, err := normalizeWorkerLabels(labels)
+	if err != nil {
+		return store.CreateRepoParams{}, fmt.Errorf("worker_labels: %w", err)
+	}
+	return store.CreateRepoParams{
+		Name:          name,
+		URL:           rawURL,
+		DefaultBranch: branch,
+		CredentialRef: credentialRef,
+		WorkerLabels:  normalized,
+	}, nil
+}
+
+// validateRepoURL accepts the remote URL forms git clones over a network.
+// Local paths and file:// would let a registry entry read the worker's own
+// disk, and embedded passwords belong in credential_ref, not a stored URL.
+func validateRepoURL(raw string) error {
+	if raw == "" {
+		return errors.New("must not be empty")
+	}
+	if len(raw) > maxRepoURLLen {
+		return fmt.Errorf("must be at most %d characters", maxRepoURLLen)
+	}
+	if strings.IndexFunc(raw, func(r rune) bool { return unicode.IsSpace(r) || unicode.IsControl(r) }) >= 0 {
+		return errors.New("must not contain whitespace")
+	}
+	if !strings.Contains(raw, "://") {
+		if !scpLikeURLPattern.MatchString(raw) {
+			return errors.New("must be an https, http, ssh or git URL, or user@host:path")
+		}
+		return nil
+	}
+	u, err := url.Parse(raw)
+	if err != nil {
+		return errors.New("is not a valid URL")
+	}
+	switch u.Scheme {
+	case "https", "http", "ssh", "git":
+	default:
+		return fmt.Errorf("scheme %q is not supported", u.Scheme)
+	}
+	if u.Host == "" {
+		return errors.New("must name a host")
+	}
+	if _, hasPassword := u.User.Password(); hasPassword {
+		return errors.New("must not embed a password; use credential_ref")
+	}
+	return nil
+}
+
+// validateRepoBranch rejects branch names git would parse as something
+// else. The worker applies git's full ref-name rules before it clones.
+func validateRepoBranch(branch string) error {
+	if branch == "" {
+		return nil
+	}
+	if len(branch) > maxRepoBranchLen {
+		return fmt.Errorf("must be at most %d characters", maxRepoBranchLen)
+	}
+	if strings.IndexFunc(branch, func(r rune) bool { return unicode.IsSpace(r) || unicode.IsControl(r) }) >= 0 {
+		return errors.New("must not contain whitespace")
+	}
+	if strings.HasPrefix(branch, "-") || strings.Contains(branch, "..") {
+		return errors.New("is not a valid branch name")
+	}
+	return nil
+}
+
+// repoNameFromURL names a repo after the last path segment of its URL,
+// without a ".git" suffix.
+func repoNameFromURL(raw string) string {
+	p := raw
+	if u, err := url.Parse(raw); err == nil && u.Scheme != "" {
+		p = u.Path
+	} else if _, after, ok := strings.Cut(raw, ":"); ok {
+		p = after
+	}
+	return strings.TrimSuffix(path.Base(strings.TrimRight(p, "/")), ".git")
+}
+
+// normalizeWorkerLabels lowercases, dedupes and sorts labels, rejecting
+// any that do not fit workerLabelPattern.
+func normalizeWorkerLabels(labels []string) ([]string, error) {
+	var out []string
+	for _, l := range labels {
+		l = strings.ToLower(strings.TrimSpace(l))
+		if !workerLabelPattern.MatchString(l) {
+			return nil, fmt.Errorf("label %q must be 1-63 lowercase letters, digits, '.', '_' or '-'", l)
+		}
+		if !slices.Contains(out, l) {
+			out = append(out, l)
+		}
+	}
+	if len(out) > maxRepoLabels {
+		return nil, fmt.Errorf("at most %d labels", maxRepoLabels)
+	}
+	slices.Sort(out)
+	return out, nil
+}
+
+// reportedWorkerLabels keeps the well-formed labels a worker reported. A
+// malformed one is dropped rather than failing the connection.
+func reportedWorkerLabels(labels []string) []string {
+	var out []string
+	for _, l := range labels {
+		if n, err := normalizeWorkerLabels([]string{l}); err == nil && !slices.Contains(out, n[0]) {
+			out = append(out, n[0])
+		}
+		if len(out) == maxRepoLabels {
+			break
+		}
+	}
+	slices.Sort(out)
+	return out
+}
+
+// pickCheckoutWorker returns the caller's online worker carrying the most
+// of the repo's preferred labels, among those access lets workspace wsID
+// use. Ties go to the earlier worker in the store's listing.
+func pickCheckoutWorker(ctx context.Context, st store.Store, workerMgr *workermgr.Manager, user *auth.UserInfo, preferred []string, access *policy.Policy, wsID string) (string, error) {
+	workers, err := st.Workers().ListByUserID(ctx, store.ListWorkersByUserIDParams{
+		RegisteredBy: user.ID,
+		PageParams:   store.PageParams{Limit: maxWorkersConsidered},
+	})
+	if err != nil {
+		return "", connect.NewError(connect.CodeInternal, fmt.Errorf("list workers: %w", err))
+	}
+	best, bestScore := "", -1
+	var refused policy.Decision
+	for _, w := range workers.Rows {
+		if !workerMgr.OnlineForTrustedPath(w.ID) {
+			continue
+		}
+		labels := workerMgr.LabelsForTrustedPath(w.ID)
+		if d := workerSelectionDecision(access, user, wsID, w.ID, labels); d.Denied() {
+			refused = d
+			continue
+		}
+		score := 0
+		for _, l := range preferred {
+			if slices.Contains(labels, l) {
+				score++
+			}
+		}
+		if score > bestScore {
+			best, bestScore = w.ID, score
+		}
+	}
+	if best == "" {
+		if refused.Denied() {
+			return "", accessPolicyDenied(refused)
+		}
+		return "", connect.NewError(connect.CodeFailedPrecondition, errors.New("no worker is online to check the repository out on"))
+	}
+	return best, nil
+}
+
+// checkoutConn returns the connection of workerID, one user may reach, to
+// check a repository out on.
+func checkoutConn(ctx context.Context, workerMgr *workermgr.Manager, user *auth.UserInfo, workerID string) (*workermgr.Conn, error) {
+	conn, err := workerMgr.ConnForUser(ctx, user, workerID)
+	if err != nil {
+		return nil, errcode.New(connect.CodeNotFound, errcode.WorkerNotFound, errors.New("worker not found"))
+	}
+	if conn == nil {
+		return nil, errcode.New(connect.CodeFailedPrecondition, errcode.WorkerOffline, errors.New("worker is offline"))
+	}
+	return conn, nil
+}
+
+// prepareRepoCheckout asks the worker on conn for a checkout of repo for
+// workspace wsID and returns its directory.
+func prepareRepoCheckout(
+	ctx context.Context,
+	pending *workermgr.PendingRequests,
+	conn *workermgr.Conn,
+	wsID, branch string,
+	repo *store.Repo,
+	credential *leapmuxv1.RepoCredential,
+) (string, error) {
+	resp, err := pending.SendAndWait(ctx, conn, &leapmuxv1.ConnectResponse{
+		Payload: &leapmuxv1.ConnectResponse_PrepareRepoCheckout{PrepareRepoCheckout: &leapmuxv1.PrepareRepoCheckout{
+			WorkspaceId: wsID,
+			RepoId:      repo.ID,
+			Name:        repo.Name,
+			Url:         repo.URL,
+			Branch:      branch,
+			Credential:  credential,
+		}},
+	})
+	if err != nil {
+		return "", connect.NewError(connect.CodeUnavailable, fmt.Errorf("prepare checkout: %w", err))
+	}
+	result := resp.GetPrepareRepoCheckoutResp()
+	switch {
+	case result == nil:
+		return "", connect.NewError(connect.CodeUnimplemented, errors.New("worker does not support repository checkouts"))
+	case result.GetError() != "":
+		return "", connect.NewError(connect.CodeFailedPrecondition, fmt.Errorf("prepare checkout: %s", result.GetError()))
+	}
+	return result.GetCheckoutDir(), nil
+}
+
+func repoToProto(r *store.Repo) *leapmuxv1.Repo {
+	return &leapmuxv1.Repo{
+		Id:            r.ID,
+		OrgId:         r.OrgID,
+		Name:          r.Name,
+		Url:           r.URL,
+		DefaultBranch: r.DefaultBranch,
+		CredentialRef: r.CredentialRef,
+		WorkerLabels:  r.WorkerLabels,
+		CreatedAt:     timefmt.Format(r.CreatedAt),
+		UpdatedAt:     timefmt.Format(r.UpdatedAt),
+	}
+}
@@ -18,12 +18,15 @@ import (
 
 // SectionService implements the SectionServiceHandler interface.
 type SectionService struct {
-	store store.Store
+	store       store.Store
+	broadcaster *HubEventBroadcaster
 }
 
-// NewSectionService creates a new SectionService.
-func NewSectionService(st store.Store) *SectionService {
-	return &SectionService{store: st}
+// NewSectionService creates a new SectionService. broadcaster is optional;
+// when set, every section mutation tells the caller's other sessions to
+// re-fetch.
+func NewSectionService(st store.Store, broadcaster *HubEventBroadcaster) *SectionService {
+	return &SectionService{store: st, broadcaster: broadcaster}
 }
 
 func (s *SectionService) ListSections(
@@ -152,6 +155,7 @@ func (s *SectionService) CreateSection(
 		return nil, connect.NewError(connect.CodeInternal, err)
 	}
 
+	s.broadcaster.NotifySectionsChanged(user.ID.String())
 	return connect.NewResponse(&leapmuxv1.CreateSectionResponse{
 		SectionId: sectionID,
 	}), nil
@@ -183,6 +187,7 @@ func (s *SectionService) RenameSection(
 		return nil, connect.NewError(connect.CodeNotFound, fmt.Errorf("section not found or not a custom section"))
 	}
 
+	s.broadcaster.NotifySectionsChanged(user.ID.String())
 	return connect.NewResponse(&leapmuxv1.RenameSectionResponse{}), nil
 }
 
@@ -309,6 +314,7 @@ func (s *SectionService) DeleteSection(
 		return nil, connect.NewError(connect.CodeInternal, err)
 	}
 
+	s.broadcaster.NotifySectionsChanged(user.ID.String())
 	return connect.NewResponse(&leapmuxv1.DeleteSectionResponse{}), nil
 }
 
@@ -372,6 +378,7 @@ func (s *SectionService) MoveSection(
 		return nil, connect.NewError(connect.CodeInternal, err)
 	}
 
+	s.broadcaster.NotifySectionsChanged(user.ID.String())
 	return connect.NewResponse(&leapmuxv1.MoveSectionResponse{}), nil
 }
 
@@ -399,6 +406,7 @@ func (s *SectionService) MoveWorkspace(
 		return nil, connect.NewError(connect.CodeInternal, err)
 	}
 
+	s.broadcaster.NotifySectionsChanged(user.ID.String())
 	return connect.NewResponse(&leapmuxv1.MoveWorkspaceResponse{}), nil
 }
 
@@ -5,6 +5,7 @@ import (
 	"net/http"
 	"net/http/httptest"
 	"testing"
+	"time"
 
 	"github.com/leapmux/leapmux/internal/util/userid"
 
@@ -12,14 +13,18 @@ import (
 	"github.com/leapmux/leapmux/internal/hub/password"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
+	"google.golang.org/protobuf/proto"
 
 	leapmuxv1 "github.com/leapmux/leapmux/generated/proto/leapmux/v1"
 	"github.com/leapmux/leapmux/generated/proto/leapmux/v1/leapmuxv1connect"
 	"github.com/leapmux/leapmux/internal/hub/auth"
+	"github.com/leapmux/leapmux/internal/hub/channelmgr"
 
 	"github.com/leapmux/leapmux/internal/hub/service"
 	"github.com/leapmux/leapmux/internal/hub/store"
 	"github.com/leapmux/leapmux/internal/hub/store/sqlite"
+	"github.com/leapmux/leapmux/internal/hub/store/storetest"
+	hubtestutil "github.com/leapmux/leapmux/internal/hub/testutil"
 	"github.com/leapmux/leapmux/internal/util/id"
 	"github.com/leapmux/leapmux/internal/util/sqlitedb"
 )
@@ -42,7 +47,7 @@ func setupSectionTest(t *testing.T) *sectionTestEnv {
 	err = st.Migrator().Migrate(context.Background())
 	require.NoError(t, err)
 
-	sectionSvc := service.NewSectionService(st)
+	sectionSvc := service.NewSectionService(st, nil)
 
 	mux := http.NewServeMux()
 	interceptor, _ := auth.NewInterceptor(st, nil, false, false)
@@ -609,7 +614,7 @@ func TestMoveSectionDeniesZeroCallerOnBlankOwnedSection(t *testing.T) {
 
 	// A UserInfo whose ID never got minted -- the zero value.
 	zeroCaller := auth.WithUser(ctx, &auth.UserInfo{OrgID: orgID, Username: "nobody"})
-	_, err := service.NewSectionService(env.store).MoveSection(zeroCaller,
+	_, err := service.NewSectionService(env.store, nil).MoveSection(zeroCaller,
 		connect.NewRequest(&leapmuxv1.MoveSectionRequest{
 			SectionId: sectionID,
 			Position:  "z",
@@ -624,3 +629,44 @@ func TestMoveSectionDeniesZeroCallerOnBlankOwnedSection(t *testing.T) {
 	require.NoError(t, getErr)
 	assert.Equal(t, "n", section.Position, "the denied move must not have written")
 }
+
+// TestSectionService_MutationsNotifySectionsChanged pins the cross-session
+// sync: a section mutation pushes a SectionsChanged hub control frame to the
+// caller's connections so their other tabs re-fetch ListSections.
+func TestSectionService_MutationsNotifySectionsChanged(t *testing.T) {
+	st := hubtestutil.OpenTestStore(t)
+	orgID := storetest.SeedOrg(t, st, "sections-org")
+	user := storetest.SeedUser(t, st, orgID, "alice")
+	other := storetest.SeedUser(t, st, orgID, "bob")
+
+	cMgr := channelmgr.New()
+	frames := make(chan *leapmuxv1.HubControlFrame, 4)
+	bind := func(userID string) {
+		cMgr.BindUser(userID, "conn-"+userID, func(msg *leapmuxv1.ChannelMessage) error {
+			var frame leapmuxv1.HubControlFrame
+			require.NoError(t, proto.Unmarshal(msg.GetCiphertext(), &frame))
+			if userID == user.ID {
+				frames <- &frame
+			} else {
+				t.Errorf("user %s received another user's section frame", userID)
+			}
+			return nil
+		}, nil)
+	}
+	bind(user.ID)
+	bind(other.ID)
+	broadcaster := service.NewHubEventBroadcaster(cMgr)
+	broadcaster.SetDebounceInterval(time.Millisecond)
+
+	svc := service.NewSectionService(st, broadcaster)
+	ctx := auth.WithUser(context.Background(), &auth.UserInfo{ID: userid.MustNew(user.ID), OrgID: orgID})
+	_, err := svc.CreateSection(ctx, connect.NewRequest(&leapmuxv1.CreateSectionRequest{OrgId: orgID, Name: "Reviews"}))
+	require.NoError(t, err)
+
+	select {
+	case frame := <-frames:
+		assert.Equal(t, []leapmuxv1.HubControlEvent{leapmuxv1.HubControlEvent_HUB_CONTROL_EVENT_SECTIONS_CHANGED}, frame.GetEvents())
+	case <-time.After(5 * time.Second):
+		t.Fatal("no SectionsChanged frame was sent")
+	}
+}
@@ -0,0 +1,375 @@
+package service
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"strings"
+
+	"connectrpc.com/connect"
+	leapmuxv1 "github.com/leapmux/leapmux/generated/proto/leapmux/v1"
+	"github.com/leapmux/leapmux/internal/hub/auth"
+	"github.com/leapmux/leapmux/internal/hub/store"
+	"github.com/leapmux/leapmux/internal/hub/workermgr"
+	"github.com/leapmux/leapmux/util/validate"
+	"google.golang.org/protobuf/encoding/protojson"
+)
+
+const (
+	// maxAgentDefaultLen caps each AgentDefaults string. Model ids and
+	// option values are short; the cap only keeps the blob bounded.
+	maxAgentDefaultLen = 128
+	// maxClosedRetentionDays caps closed_retention_days at ten years.
+	maxClosedRetentionDays = 3650
+	// maxForbiddenPermissionModes caps forbidden_permission_modes; no
+	// provider has more than a handful of modes.
+	maxForbiddenPermissionModes = 32
+)
+
+// SettingsService implements the SettingsServiceHandler interface. Org
+// defaults are stored with the org's one member like the worker settings
+// WorkerManagementService owns, and reach workers the same way.
+type SettingsService struct {
+	store     store.Store
+	workerMgr *workermgr.Manager
+}
+
+// NewSettingsService creates a new SettingsService.
+func NewSettingsService(st store.Store, workerMgr *workermgr.Manager) *SettingsService {
+	return &SettingsService{store: st, workerMgr: workerMgr}
+}
+
+// storedOrgDefaults is the "orgDefaults" entry of the user_preferences JSON
+// blob. The auto-continue policy is kept in its protojson form, which the
+// worker validates again on receipt. Providers are stored by name so the
+// blob survives enum renumbering.
+type storedOrgDefaults struct {
+	Agents               []storedAgentDefaults          `json:"agents,omitempty"`
+	AutoContinue         json.RawMessage                `json:"autoContinue,omitempty"`
+	ClosedRetentionDays  uint32                         `json:"closedRetentionDays,omitempty"`
+	Notifications        *storedNotificationPreferences `json:"notifications,omitempty"`
+	Env                  []storedEnvVar                 `json:"env,omitempty"`
+	TerminalProfiles     []storedTerminalProfile        `json:"terminalProfiles,omitempty"`
+	ImmutableTranscripts bool                           `json:"immutableTranscripts,omitempty"`
+	// ForbiddenPermissionModes and DefaultPermissionMode are the org's
+	// permission guardrails.
+	ForbiddenPermissionModes []string `json:"forbiddenPermissionModes,omitempty"`
+	DefaultPermissionMode    string   `json:"defaultPermissionMode,omitempty"`
+}
+
+type storedEnvVar struct {
+	Name  string `json:"name"`
+	Value string `json:"value"`
+}
+
+type storedTerminalProfile struct {
+	Name           string         `json:"name"`
+	Shell          string         `json:"shell,omitempty"`
+	ShellStartDir  string         `json:"shellStartDir,omitempty"`
+	Env            []storedEnvVar `json:"env,omitempty"`
+	StartupCommand string         `json:"startupCommand,omitempty"`
+}
+
+type storedAgentDefaults struct {
+	Provider       string `json:"provider"`
+	Model          string `json:"model,omitempty"`
+	Effort         string `json:"effort,omitempty"`
+	PermissionMode string `json:"permissionMode,omitempty"`
+}
+
+// validateOrgDefaults checks d and returns its stored form. Option values
+// are only bounded here: which models and modes exist is the worker's
+// call, and it skips a default it would refuse.
+func validateOrgDefaults(d *leapmuxv1.OrgDefaults) (*storedOrgDefaults, error) {
+	sd := &storedOrgDefaults{
+		ClosedRetentionDays:  d.GetClosedRetentionDays(),
+		ImmutableTranscripts: d.GetImmutableTranscripts(),
+	}
+
+	seen := make(map[leapmuxv1.AgentProvider]bool)
+	for _, a := range d.GetAgents() {
+		p := a.GetAgentProvider()
+		name, ok := leapmuxv1.AgentProvider_name[int32(p)]
+		if !ok || p == leapmuxv1.AgentProvider_AGENT_PROVIDER_UNSPECIFIED {
+			return nil, fmt.Errorf("agents: unknown provider %d", p)
+		}
+		if seen[p] {
+			return nil, fmt.Errorf("agents: duplicate defaults for %s", p)
+		}
+		seen[p] = true
+		stored := storedAgentDefaults{
+			Provider:       name,
+			Model:          strings.TrimSpace(a.GetModel()),
+			Effort:         strings.TrimSpace(a.GetEffort()),
+			PermissionMode: strings.TrimSpace(a.GetPermissionMode()),
+		}
+		if len(stored.Model) > maxAgentDefaultLen || len(stored.Effort) > maxAgentDefaultLen || len(stored.PermissionMode) > maxAgentDefaultLen {
+			return nil, fmt.Errorf("agents: values must be at most %d bytes", maxAgentDefaultLen)
+		}
+		sd.Agents = append(sd.Agents, stored)
+	}
+
+	if policy := d.GetAutoContinue(); len(policy.GetRules()) > 0 {
+		if err := validateOrgRetryPolicy(policy); err != nil {
+			return nil, fmt.Errorf("auto_continue: %w", err)
+		}
+		raw, err := protojson.Marshal(policy)
+		if err != nil {
+			return nil, fmt.Errorf("auto_continue: %w", err)
+		}
+		sd.AutoContinue = raw
+	}
+
+	if sd.ClosedRetentionDays > maxClosedRetentionDays {
+		return nil, fmt.Errorf("closed_retention_days must be at most %d", maxClosedRetentionDays)
+	}
+
+	if n := d.GetNotifications(); n != nil {
+		notifications, err := validateNotificationPreferences(n)
+		if err != nil {
+			return nil, fmt.Errorf("notifications: %w", err)
+		}
+		sd.Notifications = notifications
+	}
+
+	env, err := validateEnvVars(d.GetEnv())
+	if err != nil {
+		return nil, fmt.Errorf("env: %w", err)
+	}
+	sd.Env = env
+
+	if len(d.GetTerminalProfiles()) > validate.MaxTerminalProfiles {
+		return nil, fmt.Errorf("terminal_profiles: at most %d profiles", validate.MaxTerminalProfiles)
+	}
+	seenProfiles := make(map[string]bool)
+	for _, p := range d.GetTerminalProfiles() {
+		if err := validate.ValidateTerminalProfile(p.GetName(), p.GetShell(), p.GetShellStartDir(), p.GetStartupCommand()); err != nil {
+			return nil, fmt.Errorf("terminal_profiles: %w", err)
+		}
+		if seenProfiles[p.GetName()] {
+			return nil, fmt.Errorf("terminal_profiles: duplicate profile %s", p.GetName())
+		}
+		seenProfiles[p.GetName()] = true
+		env, err := validateEnvVars(p.GetEnv())
+		if err != nil {
+			return nil, fmt.Errorf("terminal_profiles: %s: %w", p.GetName(), err)
+		}
+		sd.TerminalProfiles = append(sd.TerminalProfiles, storedTerminalProfile{
+			Name:           p.GetName(),
+			Shell:          p.GetShell(),
+			ShellStartDir:  p.GetShellStartDir(),
+			Env:            env,
+			StartupCommand: p.GetStartupCommand(),
+		})
+	}
+
+	if err := validatePermissionGuardrails(d, sd); err != nil {
+		return nil, err
+	}
+	return sd, nil
+}
+
+// validatePermissionGuardrails checks the org's permission guardrails and
+// stores them in sd. Like the agent defaults, modes are only bounded: the
+// worker knows which exist, and a default its provider lacks is skipped.
+func validatePermissionGuardrails(d *leapmuxv1.OrgDefaults, sd *storedOrgDefaults) error {
+	if len(d.GetForbiddenPermissionModes()) > maxForbiddenPermissionModes {
+		return fmt.Errorf("forbidden_permission_modes: at most %d modes", maxForbiddenPermissionModes)
+	}
+	seen := make(map[string]bool)
+	for _, m := range d.GetForbiddenPermissionModes() {
+		m = strings.TrimSpace(m)
+		if m == "" || len(m) > maxAgentDefaultLen {
+			return fmt.Errorf("forbidden_permission_modes: modes must be 1 to %d bytes", maxAgentDefaultLen)
+		}
+		if seen[m] {
+			continue
+		}
+		seen[m] = true
+		sd.ForbiddenPermissionModes = append(sd.ForbiddenPermissionModes, m)
+	}
+	sd.DefaultPermissionMode = strings.TrimSpace(d.GetDefaultPermissionMode())
+	if len(sd.DefaultPermissionMode) > maxAgentDefaultLen {
+		return fmt.Errorf("default_permission_mode must be at most %d bytes", maxAgentDefaultLen)
+	}
+	if seen[sd.DefaultPermissionMode] {
+		return fmt.Errorf("default_permission_mode %q is forbidden", sd.DefaultPermissionMode)
+	}
+	return nil
+}
+
+// validateEnvVars checks one layer of environment variables and returns
+// its stored form.
+func validateEnvVars(vars []*leapmuxv1.EnvVar) ([]storedEnvVar, error) {
+	if len(vars) > validate.MaxEnvVars {
+		return nil, fmt.Errorf("at most %d variables", validate.MaxEnvVars)
+	}
+	var out []storedEnvVar
+	seen := make(map[string]bool)
+	for _, v := range vars {
+		if err := validate.ValidateEnvVar(v.GetName(), v.GetValue()); err != nil {
+			return nil, err
+		}
+		if seen[v.GetName()] {
+			return nil, fmt.Errorf("duplicate variable %s", v.GetName())
+		}
+		seen[v.GetName()] = true
+		out = append(out, storedEnvVar{Name: v.GetName(), Value: v.GetValue()})
+	}
+	return out, nil
+}
+
+func envVarsToProto(vars []storedEnvVar) []*leapmuxv1.EnvVar {
+	var out []*leapmuxv1.EnvVar
+	for _, v := range vars {
+		out = append(out, &leapmuxv1.EnvVar{Name: v.Name, Value: v.Value})
+	}
+	return out
+}
+
+// validateOrgRetryPolicy rejects a rule the worker could not place. The
+// worker owns the bounds on backoff and attempts and drops a policy that
+// breaks them, so only the shape is checked here.
+func validateOrgRetryPolicy(policy *leapmuxv1.RetryPolicy) error {
+	seen := make(map[leapmuxv1.RetryCondition]bool)
+	for _, r := range policy.GetRules() {
+		cond := r.GetCondition()
+		if _, ok := leapmuxv1.RetryCondition_name[int32(cond)]; !ok || cond == leapmuxv1.RetryCondition_RETRY_CONDITION_UNSPECIFIED {
+			return errors.New("retry rule condition is required")
+		}
+		if seen[cond] {
+			return fmt.Errorf("duplicate retry rule for %s", cond)
+		}
+		seen[cond] = true
+		if r.GetInitialBackoffMs() < 0 || r.GetMaxBackoffMs() < 0 || r.GetMultiplier() < 0 || r.GetMaxAttempts() < 0 {
+			return errors.New("retry rule values must not be negative")
+		}
+	}
+	return nil
+}
+
+// orgDefaultsToProto converts the stored form; nil is the default of no org
+// layer at all.
+func orgDefaultsToProto(sd *storedOrgDefaults) *leapmuxv1.OrgDefaults {
+	d := &leapmuxv1.OrgDefaults{}
+	if sd == nil {
+		return d
+	}
+	for _, a := range sd.Agents {
+		v, ok := leapmuxv1.AgentProvider_value[a.Provider]
+		if !ok {
+			continue
+		}
+		d.Agents = append(d.Agents, &leapmuxv1.AgentDefaults{
+			AgentProvider:  leapmuxv1.AgentProvider(v),
+			Model:          a.Model,
+			Effort:         a.Effort,
+			PermissionMode: a.PermissionMode,
+		})
+	}
+	if len(sd.AutoContinue) > 0 {
+		policy := &leapmuxv1.RetryPolicy{}
+		if err := protojson.Unmarshal(sd.AutoContinue, policy); err == nil {
+			d.AutoContinue = policy
+		}
+	}
+	d.ClosedRetentionDays = sd.ClosedRetentionDays
+	d.ImmutableTranscripts = sd.ImmutableTranscripts
+	d.ForbiddenPermissionModes = sd.ForbiddenPermissionModes
+	d.DefaultPermissionMode = sd.DefaultPermissionMode
+	if sd.Notifications != nil {
+		d.Notifications = notificationPreferencesToProto(sd.Notifications)
+	}
+	d.Env = envVarsToProto(sd.Env)
+	for _, p := range sd.TerminalProfiles {
+		d.TerminalProfiles = append(d.TerminalProfiles, &leapmuxv1.TerminalProfile{
+			Name:           p.Name,
+			Shell:          p.Shell,
+			ShellStartDir:  p.ShellStartDir,
+			Env:            envVarsToProto(p.Env),
+			StartupCommand: p.StartupCommand,
+		})
+	}
+	return d
+}
+
+// orgNotifications returns the org layer of the notification checks; nil
+// allows everything.
+func (sd *storedOrgDefaults) orgNotifications() *storedNotificationPreferences {
+	if sd == nil {
+		return nil
+	}
+	return sd.Notifications
+}
+
+// settingsCaller returns the caller, refusing workspace-scoped credentials:
+// org defaults are managed by the org's own account, like repositories.
+func settingsCaller(ctx context.Context) (*auth.UserInfo, error) {
+	user, err := auth.MustGetUser(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if user.Credential.IsWorkspaceScoped() {
+		return nil, connect.NewError(connect.CodePermissionDenied, errors.New("org defaults are managed by the organization's own account"))
+	}
+	return user, nil
+}
+
+func (s *SettingsService) GetOrgDefaults(
+	ctx context.Context,
+	_ *connect.Request[leapmuxv1.GetOrgDefaultsRequest],
+) (*connect.Response[leapmuxv1.GetOrgDefaultsResponse], error) {
+	user, err := settingsCaller(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	sp, err := loadStoredPreferences(ctx, s.store, user.ID.String())
+	if err != nil {
+		return nil, connect.NewError(connect.CodeInternal, err)
+	}
+	return connect.NewResponse(&leapmuxv1.GetOrgDefaultsResponse{Defaults: orgDefaultsToProto(sp.OrgDefaults)}), nil
+}
+
+// UpdateOrgDefaults replaces the org's defaults and pushes them to the
+// org's workers connected to this Hub, the same way UpdateWorkerDiskQuota
+// does.
+func (s *SettingsService) UpdateOrgDefaults(
+	ctx context.Context,
+	req *connect.Request[leapmuxv1.UpdateOrgDefaultsRequest],
+) (*connect.Response[leapmuxv1.UpdateOrgDefaultsResponse], error) {
+	user, err := settingsCaller(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	stored, err := validateOrgDefaults(req.Msg.GetDefaults())
+	if err != nil {
+		return nil, connect.NewError(connect.CodeInvalidArgument, err)
+	}
+
+	var turnedOn bool
+	if _, err := updateStoredPreferences(ctx, s.store, user.ID.String(), func(sp *storedPreferences) error {
+		// Compliance mode is one-way: turning it off would let the next
+		// delete or purge through, which is what it exists to rule out.
+		if sp.OrgDefaults != nil && sp.OrgDefaults.ImmutableTranscripts && !stored.ImmutableTranscripts {
+			return connect.NewError(connect.CodeFailedPrecondition, errors.New("immutable_transcripts cannot be turned off once on"))
+		}
+		turnedOn = stored.ImmutableTranscripts && (sp.OrgDefaults == nil || !sp.OrgDefaults.ImmutableTranscripts)
+		sp.OrgDefaults = stored
+		return nil
+	}); err != nil {
+		return nil, err
+	}
+	if turnedOn {
+		slog.Warn("immutable transcripts turned on", "user_id", user.ID)
+	}
+
+	defaults := orgDefaultsToProto(stored)
+	pushToUserWorkers(ctx, s.store, s.workerMgr, user, &leapmuxv1.ConnectResponse{
+		Payload: &leapmuxv1.ConnectResponse_OrgDefaults{OrgDefaults: defaults},
+	}, "org defaults")
+	return connect.NewResponse(&leapmuxv1.UpdateOrgDefaultsResponse{Defaults: defaults}), nil
+}
@@ -0,0 +1,202 @@
+package service_test
+
+import (
+	"context"
+	"testing"
+
+	"connectrpc.com/connect"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/protobuf/proto"
+
+	leapmuxv1 "github.com/leapmux/leapmux/generated/proto/leapmux/v1"
+	"github.com/leapmux/leapmux/internal/hub/auth"
+	"github.com/leapmux/leapmux/internal/hub/config"
+	"github.com/leapmux/leapmux/internal/hub/mail"
+	"github.com/leapmux/leapmux/internal/hub/service"
+	"github.com/leapmux/leapmux/internal/hub/store"
+	"github.com/leapmux/leapmux/internal/hub/testutil"
+	"github.com/leapmux/leapmux/internal/hub/workermgr"
+	"github.com/leapmux/leapmux/internal/util/userid"
+)
+
+func TestOrgDefaults_RoundTripAndPush(t *testing.T) {
+	st := testutil.OpenTestStore(t)
+	uid := userid.MustNew(testutil.CreateTestUser(t, st, "defaults", "password123"))
+	ctx := auth.WithUser(context.Background(), &auth.UserInfo{ID: uid})
+
+	require.NoError(t, st.Workers().Create(ctx, store.CreateWorkerParams{
+		ID:              "w-online",
+		AuthToken:       "token-w-online",
+		RegisteredBy:    uid,
+		PublicKey:       []byte("test-x25519-key-32-bytes-padding"),
+		MlkemPublicKey:  []byte("mlkem"),
+		SlhdsaPublicKey: []byte("slhdsa"),
+	}))
+	mgr := workermgr.New(service.NewWorkerReachAuthorizer(st))
+	pushed := make(chan *leapmuxv1.ConnectResponse, 4)
+	_, err := mgr.Register(&workermgr.Conn{
+		WorkerID: "w-online",
+		SendFn: func(msg *leapmuxv1.ConnectResponse) error {
+			pushed <- msg
+			return nil
+		},
+	})
+	require.NoError(t, err)
+	svc := service.NewSettingsService(st, mgr)
+
+	got, err := svc.GetOrgDefaults(ctx, connect.NewRequest(&leapmuxv1.GetOrgDefaultsRequest{}))
+	require.NoError(t, err)
+	assert.True(t, proto.Equal(&leapmuxv1.OrgDefaults{}, got.Msg.GetDefaults()), "unset means no org layer")
+
+	want := &leapmuxv1.OrgDefaults{
+		Agents: []*leapmuxv1.AgentDefaults{{
+			AgentProvider:  leapmuxv1.AgentProvider_AGENT_PROVIDER_CLAUDE_CODE,
+			Model:          "opus",
+			Effort:         "high",
+			PermissionMode: "plan",
+		}},
+		AutoContinue: &leapmuxv1.RetryPolicy{Rules: []*leapmuxv1.RetryRule{{
+			Condition:   leapmuxv1.RetryCondition_RETRY_CONDITION_API_ERROR,
+			MaxAttempts: 5,
+		}}},
+		ClosedRetentionDays: 30,
+		Notifications: &leapmuxv1.NotificationPreferences{
+			DisabledChannels: []leapmuxv1.NotificationChannel{leapmuxv1.NotificationChannel_NOTIFICATION_CHANNEL_SLACK},
+		},
+		Env: []*leapmuxv1.EnvVar{{Name: "HTTPS_PROXY", Value: "http://proxy:3128"}},
+		TerminalProfiles: []*leapmuxv1.TerminalProfile{{
+			Name:           "psql",
+			Env:            []*leapmuxv1.EnvVar{{Name: "PGHOST", Value: "localhost"}},
+			StartupCommand: "psql",
+		}},
+		ForbiddenPermissionModes: []string{"bypassPermissions"},
+		DefaultPermissionMode:    "plan",
+	}
+	_, err = svc.UpdateOrgDefaults(ctx, connect.NewRequest(&leapmuxv1.UpdateOrgDefaultsRequest{Defaults: want}))
+	require.NoError(t, err)
+
+	got, err = svc.GetOrgDefaults(ctx, connect.NewRequest(&leapmuxv1.GetOrgDefaultsRequest{}))
+	require.NoError(t, err)
+	assert.True(t, proto.Equal(want, got.Msg.GetDefaults()))
+
+	require.Len(t, pushed, 1)
+	assert.True(t, proto.Equal(want, (<-pushed).GetOrgDefaults()))
+}
+
+func TestOrgDefaults_RejectsInvalid(t *testing.T) {
+	claude := leapmuxv1.AgentProvider_AGENT_PROVIDER_CLAUDE_CODE
+	tests := []struct {
+		name     string
+		defaults *leapmuxv1.OrgDefaults
+	}{
+		{name: "unspecified provider", defaults: &leapmuxv1.OrgDefaults{Agents: []*leapmuxv1.AgentDefaults{{Model: "opus"}}}},
+		{name: "duplicate provider", defaults: &leapmuxv1.OrgDefaults{Agents: []*leapmuxv1.AgentDefaults{
+			{AgentProvider: claude, Model: "opus"},
+			{AgentProvider: claude, Model: "sonnet"},
+		}}},
+		{name: "rule without condition", defaults: &leapmuxv1.OrgDefaults{AutoContinue: &leapmuxv1.RetryPolicy{
+			Rules: []*leapmuxv1.RetryRule{{MaxAttempts: 3}},
+		}}},
+		{name: "retention past ten years", defaults: &leapmuxv1.OrgDefaults{ClosedRetentionDays: 4000}},
+		{name: "bad quiet hours", defaults: &leapmuxv1.OrgDefaults{Notifications: &leapmuxv1.NotificationPreferences{
+			QuietHours: &leapmuxv1.QuietHours{StartMinute: 60, EndMinute: 60},
+		}}},
+		{name: "reserved env name", defaults: &leapmuxv1.OrgDefaults{Env: []*leapmuxv1.EnvVar{{Name: "LEAPMUX_WORKER", Value: "0"}}}},
+		{name: "duplicate env name", defaults: &leapmuxv1.OrgDefaults{Env: []*leapmuxv1.EnvVar{
+			{Name: "GOFLAGS", Value: "-mod=mod"},
+			{Name: "GOFLAGS", Value: ""},
+		}}},
+		{name: "duplicate terminal profile", defaults: &leapmuxv1.OrgDefaults{TerminalProfiles: []*leapmuxv1.TerminalProfile{
+			{Name: "psql"},
+			{Name: "psql", Shell: "/bin/zsh"},
+		}}},
+		{name: "reserved terminal profile env", defaults: &leapmuxv1.OrgDefaults{TerminalProfiles: []*leapmuxv1.TerminalProfile{
+			{Name: "psql", Env: []*leapmuxv1.EnvVar{{Name: "LEAPMUX_TAB_ID", Value: "x"}}},
+		}}},
+		{name: "blank forbidden permission mode", defaults: &leapmuxv1.OrgDefaults{ForbiddenPermissionModes: []string{" "}}},
+		{name: "forbidden default permission mode", defaults: &leapmuxv1.OrgDefaults{
+			ForbiddenPermissionModes: []string{"bypassPermissions"},
+			DefaultPermissionMode:    "bypassPermissions",
+		}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			st := testutil.OpenTestStore(t)
+			uid := userid.MustNew(testutil.CreateTestUser(t, st, "defaults", "password123"))
+			ctx := auth.WithUser(context.Background(), &auth.UserInfo{ID: uid})
+			svc := service.NewSettingsService(st, workermgr.New(workermgr.DenyAllReach()))
+
+			_, err := svc.UpdateOrgDefaults(ctx, connect.NewRequest(&leapmuxv1.UpdateOrgDefaultsRequest{Defaults: tt.defaults}))
+			assert.Equal(t, connect.CodeInvalidArgument, connect.CodeOf(err))
+		})
+	}
+}
+
+func TestUpdatePreferences_KeepsOrgDefaults(t *testing.T) {
+	st := testutil.OpenTestStore(t)
+	uid := userid.MustNew(testutil.CreateTestUser(t, st, "keeper", "password123"))
+	ctx := auth.WithUser(context.Background(), &auth.UserInfo{ID: uid})
+	settings := service.NewSettingsService(st, workermgr.New(workermgr.DenyAllReach()))
+
+	_, err := settings.UpdateOrgDefaults(ctx, connect.NewRequest(&leapmuxv1.UpdateOrgDefaultsRequest{
+		Defaults: &leapmuxv1.OrgDefaults{ClosedRetentionDays: 14},
+	}))
+	require.NoError(t, err)
+
+	users := service.NewUserService(st, &config.Config{}, auth.NewCredentialLifecycleEffects(nil, nil, nil), mail.NewStubSender(), mail.Renderer{})
+	_, err = users.UpdatePreferences(ctx, connect.NewRequest(&leapmuxv1.UpdatePreferencesRequest{Theme: "dark"}))
+	require.NoError(t, err)
+
+	got, err := settings.GetOrgDefaults(ctx, connect.NewRequest(&leapmuxv1.GetOrgDefaultsRequest{}))
+	require.NoError(t, err)
+	assert.EqualValues(t, 14, got.Msg.GetDefaults().GetClosedRetentionDays())
+}
+
+func TestOrgDefaults_ImmutableTranscriptsIsOneWay(t *testing.T) {
+	st := testutil.OpenTestStore(t)
+	uid := userid.MustNew(testutil.CreateTestUser(t, st, "auditor", "password123"))
+	ctx := auth.WithUser(context.Background(), &auth.UserInfo{ID: uid})
+	svc := service.NewSettingsService(st, workermgr.New(workermgr.DenyAllReach()))
+
+	_, err := svc.UpdateOrgDefaults(ctx, connect.NewRequest(&leapmuxv1.UpdateOrgDefaultsRequest{
+		Defaults: &leapmuxv1.OrgDefaults{ImmutableTranscripts: true},
+	}))
+	require.NoError(t, err)
+
+	_, err = svc.UpdateOrgDefaults(ctx, connect.NewRequest(&leapmuxv1.UpdateOrgDefaultsRequest{
+		Defaults: &leapmuxv1.OrgDefaults{ClosedRetentionDays: 14},
+	}))
+	assert.Equal(t, connect.CodeFailedPrecondition, connect.CodeOf(err))
+
+	_, err = svc.UpdateOrgDefaults(ctx, connect.NewRequest(&leapmuxv1.UpdateOrgDefaultsRequest{
+		Defaults: &leapmuxv1.OrgDefaults{ImmutableTranscripts: true, ClosedRetentionDays: 14},
+	}))
+	require.NoError(t, err)
+	got, err := svc.GetOrgDefaults(ctx, connect.NewRequest(&leapmuxv1.GetOrgDefaultsRequest{}))
+	require.NoError(t, err)
+	assert.True(t, got.Msg.GetDefaults().GetImmutableTranscripts())
+}
+
+func TestNotificationAllowed_HonorsOrgDefaults(t *testing.T) {
+	st := testutil.OpenTestStore(t)
+	uid := userid.MustNew(testutil.CreateTestUser(t, st, "notify", "password123"))
+	ctx := auth.WithUser(context.Background(), &auth.UserInfo{ID: uid})
+	settings := service.NewSettingsService(st, workermgr.New(workermgr.DenyAllReach()))
+
+	_, err := settings.UpdateOrgDefaults(ctx, connect.NewRequest(&leapmuxv1.UpdateOrgDefaultsRequest{
+		Defaults: &leapmuxv1.OrgDefaults{Notifications: &leapmuxv1.NotificationPreferences{
+			DisabledChannels: []leapmuxv1.NotificationChannel{leapmuxv1.NotificationChannel_NOTIFICATION_CHANNEL_SLACK},
+		}},
+	}))
+	require.NoError(t, err)
+
+	eventType := leapmuxv1.NotificationEventType_NOTIFICATION_EVENT_TYPE_TURN_END
+	allowed, err := service.NotificationAllowed(ctx, st, uid.String(), eventType, leapmuxv1.NotificationChannel_NOTIFICATION_CHANNEL_SLACK, "")
+	require.NoError(t, err)
+	assert.False(t, allowed, "the org's disabled channel applies even though the member's own preferences allow it")
+
+	allowed, err = service.NotificationAllowed(ctx, st, uid.String(), eventType, leapmuxv1.NotificationChannel_NOTIFICATION_CHANNEL_EMAIL, "")
+	require.NoError(t, err)
+	assert.True(t, allowed)
+}
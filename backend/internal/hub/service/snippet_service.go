@@ -0,0 +1,183 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"unicode/utf8"
+
+	"connectrpc.com/connect"
+
+	leapmuxv1 "github.com/leapmux/leapmux/generated/proto/leapmux/v1"
+	"github.com/leapmux/leapmux/internal/hub/auth"
+	"github.com/leapmux/leapmux/internal/hub/store"
+	"github.com/leapmux/leapmux/internal/util/id"
+	"github.com/leapmux/leapmux/internal/util/snippet"
+	"github.com/leapmux/leapmux/internal/util/timefmt"
+)
+
+const (
+	// maxSnippetDescriptionLen caps a snippet's description, in characters.
+	maxSnippetDescriptionLen = 200
+	// maxSnippetsPerUser caps the snippets one user keeps.
+	maxSnippetsPerUser = 256
+)
+
+var errSnippetNotFound = errors.New("snippet not found")
+
+// SnippetService implements the SnippetServiceHandler interface. Snippets
+// belong to the calling user, in every org; workers expand them (see
+// WorkerReconcilerService.GetSnippetForWorker). Workspace-scoped
+// credentials cannot reach the library.
+type SnippetService struct {
+	store store.Store
+}
+
+// NewSnippetService creates a new SnippetService.
+func NewSnippetService(st store.Store) *SnippetService {
+	return &SnippetService{store: st}
+}
+
+func (s *SnippetService) ListSnippets(
+	ctx context.Context,
+	_ *connect.Request[leapmuxv1.ListSnippetsRequest],
+) (*connect.Response[leapmuxv1.ListSnippetsResponse], error) {
+	user, err := snippetCaller(ctx)
+	if err != nil {
+		return nil, err
+	}
+	snippets, err := s.store.Snippets().ListByUser(ctx, user.ID)
+	if err != nil {
+		return nil, connect.NewError(connect.CodeInternal, err)
+	}
+	pb := make([]*leapmuxv1.Snippet, len(snippets))
+	for i := range snippets {
+		pb[i] = snippetToProto(&snippets[i])
+	}
+	return connect.NewResponse(&leapmuxv1.ListSnippetsResponse{Snippets: pb}), nil
+}
+
+func (s *SnippetService) CreateSnippet(
+	ctx context.Context,
+	req *connect.Request[leapmuxv1.CreateSnippetRequest],
+) (*connect.Response[leapmuxv1.CreateSnippetResponse], error) {
+	user, err := snippetCaller(ctx)
+	if err != nil {
+		return nil, err
+	}
+	description, err := validateSnippet(req.Msg.GetName(), req.Msg.GetDescription(), req.Msg.GetBody())
+	if err != nil {
+		return nil, connect.NewError(connect.CodeInvalidArgument, err)
+	}
+	existing, err := s.store.Snippets().ListByUser(ctx, user.ID)
+	if err != nil {
+		return nil, connect.NewError(connect.CodeInternal, err)
+	}
+	if len(existing) >= maxSnippetsPerUser {
+		return nil, connect.NewError(connect.CodeResourceExhausted,
+			fmt.Errorf("a user can keep at most %d snippets", maxSnippetsPerUser))
+	}
+
+	created, err := s.store.Snippets().Create(ctx, store.CreateSnippetParams{
+		ID:          id.Generate(),
+		UserID:      user.ID,
+		Name:        req.Msg.GetName(),
+		Description: description,
+		Body:        req.Msg.GetBody(),
+	})
+	if errors.Is(err, store.ErrConflict) {
+		return nil, connect.NewError(connect.CodeAlreadyExists, fmt.Errorf("you already have a snippet named /%s", req.Msg.GetName()))
+	}
+	if err != nil {
+		return nil, connect.NewError(connect.CodeInternal, fmt.Errorf("create snippet: %w", err))
+	}
+	return connect.NewResponse(&leapmuxv1.CreateSnippetResponse{Snippet: snippetToProto(created)}), nil
+}
+
+func (s *SnippetService) UpdateSnippet(
+	ctx context.Context,
+	req *connect.Request[leapmuxv1.UpdateSnippetRequest],
+) (*connect.Response[leapmuxv1.UpdateSnippetResponse], error) {
+	user, err := snippetCaller(ctx)
+	if err != nil {
+		return nil, err
+	}
+	description, err := validateSnippet(req.Msg.GetName(), req.Msg.GetDescription(), req.Msg.GetBody())
+	if err != nil {
+		return nil, connect.NewError(connect.CodeInvalidArgument, err)
+	}
+	updated, err := s.store.Snippets().Update(ctx, store.UpdateSnippetParams{
+		ID:          req.Msg.GetSnippetId(),
+		UserID:      user.ID,
+		Name:        req.Msg.GetName(),
+		Description: description,
+		Body:        req.Msg.GetBody(),
+	})
+	switch {
+	case errors.Is(err, store.ErrNotFound):
+		return nil, connect.NewError(connect.CodeNotFound, errSnippetNotFound)
+	case errors.Is(err, store.ErrConflict):
+		return nil, connect.NewError(connect.CodeAlreadyExists, fmt.Errorf("you already have a snippet named /%s", req.Msg.GetName()))
+	case err != nil:
+		return nil, connect.NewError(connect.CodeInternal, fmt.Errorf("update snippet: %w", err))
+	}
+	return connect.NewResponse(&leapmuxv1.UpdateSnippetResponse{Snippet: snippetToProto(updated)}), nil
+}
+
+func (s *SnippetService) DeleteSnippet(
+	ctx context.Context,
+	req *connect.Request[leapmuxv1.DeleteSnippetRequest],
+) (*connect.Response[leapmuxv1.DeleteSnippetResponse], error) {
+	user, err := snippetCaller(ctx)
+	if err != nil {
+		return nil, err
+	}
+	n, err := s.store.Snippets().Delete(ctx, store.GetSnippetParams{ID: req.Msg.GetSnippetId(), UserID: user.ID})
+	if err != nil {
+		return nil, connect.NewError(connect.CodeInternal, err)
+	}
+	if n == 0 {
+		return nil, connect.NewError(connect.CodeNotFound, errSnippetNotFound)
+	}
+	return connect.NewResponse(&leapmuxv1.DeleteSnippetResponse{}), nil
+}
+
+// snippetCaller resolves the caller of a library request. Only a
+// credential that speaks for the whole account may reach the library.
+func snippetCaller(ctx context.Context) (*auth.UserInfo, error) {
+	user, err := auth.MustGetUser(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if user.Credential.IsWorkspaceScoped() {
+		return nil, connect.NewError(connect.CodePermissionDenied, errors.New("snippets are managed by the account's own credentials"))
+	}
+	return user, nil
+}
+
+// validateSnippet checks a snippet and returns its trimmed description.
+func validateSnippet(name, description, body string) (string, error) {
+	if err := snippet.ValidateName(name); err != nil {
+		return "", err
+	}
+	description = strings.TrimSpace(description)
+	if utf8.RuneCountInString(description) > maxSnippetDescriptionLen {
+		return "", fmt.Errorf("description: must be at most %d characters", maxSnippetDescriptionLen)
+	}
+	if err := snippet.ValidateBody(body); err != nil {
+		return "", err
+	}
+	return description, nil
+}
+
+func snippetToProto(s *store.Snippet) *leapmuxv1.Snippet {
+	return &leapmuxv1.Snippet{
+		Id:          s.ID,
+		Name:        s.Name,
+		Description: s.Description,
+		Body:        s.Body,
+		CreatedAt:   timefmt.Format(s.CreatedAt),
+		UpdatedAt:   timefmt.Format(s.UpdatedAt),
+	}
+}
@@ -0,0 +1,105 @@
+package service_test
+
+import (
+	"context"
+	"testing"
+
+	"connectrpc.com/connect"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	leapmuxv1 "github.com/leapmux/leapmux/generated/proto/leapmux/v1"
+	"github.com/leapmux/leapmux/internal/hub/auth"
+	"github.com/leapmux/leapmux/internal/hub/channelmgr"
+	"github.com/leapmux/leapmux/internal/hub/service"
+	"github.com/leapmux/leapmux/internal/hub/store/storetest"
+	hubtestutil "github.com/leapmux/leapmux/internal/hub/testutil"
+	"github.com/leapmux/leapmux/internal/util/userid"
+)
+
+func TestSnippetService_CRUD(t *testing.T) {
+	st := hubtestutil.OpenTestStore(t)
+	orgID := storetest.SeedOrg(t, st, "snippet-org")
+	user := storetest.SeedUser(t, st, orgID, "alice")
+	ctx := auth.WithUser(context.Background(), &auth.UserInfo{ID: userid.MustNew(user.ID), OrgID: orgID})
+	svc := service.NewSnippetService(st)
+
+	created, err := svc.CreateSnippet(ctx, connect.NewRequest(&leapmuxv1.CreateSnippetRequest{
+		Name: "review", Description: "  Review a file ", Body: "Review {{1}} on {{branch}}.",
+	}))
+	require.NoError(t, err)
+	sn := created.Msg.GetSnippet()
+	assert.Equal(t, "Review a file", sn.GetDescription())
+
+	_, err = svc.CreateSnippet(ctx, connect.NewRequest(&leapmuxv1.CreateSnippetRequest{Name: "review", Body: "x"}))
+	assert.Equal(t, connect.CodeAlreadyExists, connect.CodeOf(err))
+
+	updated, err := svc.UpdateSnippet(ctx, connect.NewRequest(&leapmuxv1.UpdateSnippetRequest{
+		SnippetId: sn.GetId(), Name: "critique", Body: "Critique {{args}}.",
+	}))
+	require.NoError(t, err)
+	assert.Equal(t, "critique", updated.Msg.GetSnippet().GetName())
+
+	list, err := svc.ListSnippets(ctx, connect.NewRequest(&leapmuxv1.ListSnippetsRequest{}))
+	require.NoError(t, err)
+	require.Len(t, list.Msg.GetSnippets(), 1)
+
+	_, err = svc.DeleteSnippet(ctx, connect.NewRequest(&leapmuxv1.DeleteSnippetRequest{SnippetId: sn.GetId()}))
+	require.NoError(t, err)
+	_, err = svc.DeleteSnippet(ctx, connect.NewRequest(&leapmuxv1.DeleteSnippetRequest{SnippetId: sn.GetId()}))
+	assert.Equal(t, connect.CodeNotFound, connect.CodeOf(err))
+}
+
+func TestSnippetService_RejectsInvalid(t *testing.T) {
+	st := hubtestutil.OpenTestStore(t)
+	orgID := storetest.SeedOrg(t, st, "snippet-org")
+	user := storetest.SeedUser(t, st, orgID, "alice")
+	ctx := auth.WithUser(context.Background(), &auth.UserInfo{ID: userid.MustNew(user.ID), OrgID: orgID})
+	svc := service.NewSnippetService(st)
+
+	for _, req := range []*leapmuxv1.CreateSnippetRequest{
+		{Name: "Review", Body: "x"},
+		{Name: "review", Body: " "},
+		{Name: "review", Body: "Hello {{name}}"},
+	} {
+		_, err := svc.CreateSnippet(ctx, connect.NewRequest(req))
+		assert.Equal(t, connect.CodeInvalidArgument, connect.CodeOf(err), "%v", req)
+	}
+}
+
+func TestWorkerReconcilerService_GetSnippetForWorker(t *testing.T) {
+	st := hubtestutil.OpenTestStore(t)
+	orgID := storetest.SeedOrg(t, st, "snippet-org")
+	user := storetest.SeedUser(t, st, orgID, "alice")
+	worker := storetest.SeedWorker(t, st, user.ID)
+	other := storetest.SeedWorker(t, st, user.ID)
+	ctx := auth.WithUser(context.Background(), &auth.UserInfo{ID: userid.MustNew(user.ID), OrgID: orgID})
+	_, err := service.NewSnippetService(st).CreateSnippet(ctx, connect.NewRequest(&leapmuxv1.CreateSnippetRequest{
+		Name: "tests", Body: "Write tests for {{args}}.",
+	}))
+	require.NoError(t, err)
+
+	channels := channelmgr.New()
+	channels.RegisterWithAuthInfo("ch-1", worker.ID, user.ID, channelmgr.AuthInfo{}, nil)
+	svc := service.NewWorkerReconcilerService(st, channels, nil)
+	get := func(w, channelID, name string) (*leapmuxv1.Snippet, error) {
+		req := connect.NewRequest(&leapmuxv1.GetSnippetForWorkerRequest{ChannelId: channelID, Name: name})
+		req.Header().Set("Authorization", "Bearer "+w)
+		resp, err := svc.GetSnippetForWorker(context.Background(), req)
+		if err != nil {
+			return nil, err
+		}
+		return resp.Msg.GetSnippet(), nil
+	}
+
+	sn, err := get(worker.AuthToken, "ch-1", "tests")
+	require.NoError(t, err)
+	assert.Equal(t, "Write tests for {{args}}.", sn.GetBody())
+
+	_, err = get(worker.AuthToken, "ch-1", "review")
+	assert.Equal(t, connect.CodeNotFound, connect.CodeOf(err))
+	_, err = get(other.AuthToken, "ch-1", "tests")
+	assert.Equal(t, connect.CodeNotFound, connect.CodeOf(err), "another worker cannot use the channel")
+	_, err = get("bogus", "ch-1", "tests")
+	assert.Equal(t, connect.CodeUnauthenticated, connect.CodeOf(err))
+}
@@ -0,0 +1,322 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+
+	"connectrpc.com/connect"
+
+	leapmuxv1 "github.com/leapmux/leapmux/generated/proto/leapmux/v1"
+	"github.com/leapmux/leapmux/internal/hub/auth"
+	"github.com/leapmux/leapmux/internal/hub/store"
+	"github.com/leapmux/leapmux/internal/util/id"
+	"github.com/leapmux/leapmux/internal/util/timefmt"
+	"github.com/leapmux/leapmux/internal/util/userid"
+)
+
+const (
+	// maxSystemPromptLen caps a prompt's content. Workers apply the same
+	// cap to the content OpenAgent hands them.
+	maxSystemPromptLen = 64 << 10
+	// maxSystemPromptNameLen caps a prompt's name, in characters.
+	maxSystemPromptNameLen = 100
+	// maxSystemPromptsPerUser caps the prompts one member owns.
+	maxSystemPromptsPerUser = 256
+)
+
+var errSystemPromptNotFound = errors.New("system prompt not found")
+
+// SystemPromptService implements the SystemPromptServiceHandler interface.
+// A member sees their own prompts and those shared in their org; only the
+// owner may change or delete a prompt. Workspace-scoped credentials cannot
+// reach the library.
+type SystemPromptService struct {
+	store store.Store
+}
+
+// NewSystemPromptService creates a new SystemPromptService.
+func NewSystemPromptService(st store.Store) *SystemPromptService {
+	return &SystemPromptService{store: st}
+}
+
+func (s *SystemPromptService) ListSystemPrompts(
+	ctx context.Context,
+	req *connect.Request[leapmuxv1.ListSystemPromptsRequest],
+) (*connect.Response[leapmuxv1.ListSystemPromptsResponse], error) {
+	user, orgID, err := systemPromptCaller(ctx, req.Msg.GetOrgId())
+	if err != nil {
+		return nil, err
+	}
+	prompts, err := s.store.SystemPrompts().ListByOrg(ctx, orgID)
+	if err != nil {
+		return nil, connect.NewError(connect.CodeInternal, err)
+	}
+	pb := make([]*leapmuxv1.SystemPrompt, 0, len(prompts))
+	for i := range prompts {
+		if systemPromptVisible(&prompts[i], user.ID) {
+			pb = append(pb, systemPromptToProto(&prompts[i]))
+		}
+	}
+	return connect.NewResponse(&leapmuxv1.ListSystemPromptsResponse{Prompts: pb}), nil
+}
+
+func (s *SystemPromptService) GetSystemPrompt(
+	ctx context.Context,
+	req *connect.Request[leapmuxv1.GetSystemPromptRequest],
+) (*connect.Response[leapmuxv1.GetSystemPromptResponse], error) {
+	user, orgID, err := systemPromptCaller(ctx, "")
+	if err != nil {
+		return nil, err
+	}
+	prompt, err := s.store.SystemPrompts().Get(ctx, store.GetSystemPromptParams{
+		ID:      req.Msg.GetPromptId(),
+		OrgID:   orgID,
+		Version: int64(req.Msg.GetVersion()),
+	})
+	if errors.Is(err, store.ErrNotFound) || (err == nil && !systemPromptVisible(prompt, user.ID)) {
+		return nil, connect.NewError(connect.CodeNotFound, errSystemPromptNotFound)
+	}
+	if err != nil {
+		return nil, connect.NewError(connect.CodeInternal, err)
+	}
+	return connect.NewResponse(&leapmuxv1.GetSystemPromptResponse{Prompt: systemPromptToProto(prompt)}), nil
+}
+
+func (s *SystemPromptService) CreateSystemPrompt(
+	ctx context.Context,
+	req *connect.Request[leapmuxv1.CreateSystemPromptRequest],
+) (*connect.Response[leapmuxv1.CreateSystemPromptResponse], error) {
+	user, orgID, err := systemPromptCaller(ctx, req.Msg.GetOrgId())
+	if err != nil {
+		return nil, err
+	}
+	name, err := validateSystemPromptName(req.Msg.GetName())
+	if err != nil {
+		return nil, connect.NewError(connect.CodeInvalidArgument, err)
+	}
+	if err := validateSystemPromptContent(req.Msg.GetContent()); err != nil {
+		return nil, connect.NewError(connect.CodeInvalidArgument, err)
+	}
+
+	existing, err := s.store.SystemPrompts().ListByOrg(ctx, orgID)
+	if err != nil {
+		return nil, connect.NewError(connect.CodeInternal, err)
+	}
+	owned := 0
+	for i := range existing {
+		if existing[i].OwnerUserID == user.ID.String() {
+			owned++
+		}
+	}
+	if owned >= maxSystemPromptsPerUser {
+		return nil, connect.NewError(connect.CodeResourceExhausted,
+			fmt.Errorf("a member can keep at most %d system prompts", maxSystemPromptsPerUser))
+	}
+
+	prompt, err := s.store.SystemPrompts().Create(ctx, store.CreateSystemPromptParams{
+		ID:          id.Generate(),
+		OrgID:       orgID,
+		OwnerUserID: user.ID,
+		Name:        name,
+		Shared:      req.Msg.GetShared(),
+		Content:     req.Msg.GetContent(),
+	})
+	if errors.Is(err, store.ErrConflict) {
+		return nil, connect.NewError(connect.CodeAlreadyExists, fmt.Errorf("you already have a system prompt named %q", name))
+	}
+	if err != nil {
+		return nil, connect.NewError(connect.CodeInternal, fmt.Errorf("create system prompt: %w", err))
+	}
+	return connect.NewResponse(&leapmuxv1.CreateSystemPromptResponse{Prompt: systemPromptToProto(prompt)}), nil
+}
+
+func (s *SystemPromptService) UpdateSystemPrompt(
+	ctx context.Context,
+	req *connect.Request[leapmuxv1.UpdateSystemPromptRequest],
+) (*connect.Response[leapmuxv1.UpdateSystemPromptResponse], error) {
+	user, orgID, err := systemPromptCaller(ctx, "")
+	if err != nil {
+		return nil, err
+	}
+	name, err := validateSystemPromptName(req.Msg.GetName())
+	if err != nil {
+		return nil, connect.NewError(connect.CodeInvalidArgument, err)
+	}
+	if req.Msg.Content != nil {
+		if err := validateSystemPromptContent(req.Msg.GetContent()); err != nil {
+			return nil, connect.NewError(connect.CodeInvalidArgument, err)
+		}
+	}
+	current, err := s.ownedSystemPrompt(ctx, orgID, user.ID, req.Msg.GetPromptId())
+	if err != nil {
+		return nil, err
+	}
+
+	prompt, err := s.store.SystemPrompts().Update(ctx, store.UpdateSystemPromptParams{
+		ID:     current.ID,
+		OrgID:  orgID,
+		Name:   name,
+		Shared: req.Msg.GetShared(),
+	})
+	if err == nil && req.Msg.Content != nil && req.Msg.GetContent() != current.Content {
+		prompt, err = s.store.SystemPrompts().AddVersion(ctx, store.AddSystemPromptVersionParams{
+			ID:        current.ID,
+			OrgID:     orgID,
+			Content:   req.Msg.GetContent(),
+			CreatedBy: user.ID,
+		})
+		if errors.Is(err, store.ErrConflict) {
+			return nil, connect.NewError(connect.CodeAborted, errors.New("the system prompt changed concurrently; retry"))
+		}
+	}
+	switch {
+	case errors.Is(err, store.ErrNotFound):
+		return nil, connect.NewError(connect.CodeNotFound, errSystemPromptNotFound)
+	case errors.Is(err, store.ErrConflict):
+		return nil, connect.NewError(connect.CodeAlreadyExists, fmt.Errorf("you already have a system prompt named %q", name))
+	case err != nil:
+		return nil, connect.NewError(connect.CodeInternal, fmt.Errorf("update system prompt: %w", err))
+	}
+	return connect.NewResponse(&leapmuxv1.UpdateSystemPromptResponse{Prompt: systemPromptToProto(prompt)}), nil
+}
+
+func (s *SystemPromptService) DeleteSystemPrompt(
+	ctx context.Context,
+	req *connect.Request[leapmuxv1.DeleteSystemPromptRequest],
+) (*connect.Response[leapmuxv1.DeleteSystemPromptResponse], error) {
+	user, orgID, err := systemPromptCaller(ctx, "")
+	if err != nil {
+		return nil, err
+	}
+	if _, err := s.ownedSystemPrompt(ctx, orgID, user.ID, req.Msg.GetPromptId()); err != nil {
+		return nil, err
+	}
+	n, err := s.store.SystemPrompts().Delete(ctx, store.GetSystemPromptParams{ID: req.Msg.GetPromptId(), OrgID: orgID})
+	if err != nil {
+		return nil, connect.NewError(connect.CodeInternal, err)
+	}
+	if n == 0 {
+		return nil, connect.NewError(connect.CodeNotFound, errSystemPromptNotFound)
+	}
+	return connect.NewResponse(&leapmuxv1.DeleteSystemPromptResponse{}), nil
+}
+
+func (s *SystemPromptService) ListSystemPromptVersions(
+	ctx context.Context,
+	req *connect.Request[leapmuxv1.ListSystemPromptVersionsRequest],
+) (*connect.Response[leapmuxv1.ListSystemPromptVersionsResponse], error) {
+	user, orgID, err := systemPromptCaller(ctx, "")
+	if err != nil {
+		return nil, err
+	}
+	params := store.GetSystemPromptParams{ID: req.Msg.GetPromptId(), OrgID: orgID}
+	prompt, err := s.store.SystemPrompts().Get(ctx, params)
+	if errors.Is(err, store.ErrNotFound) || (err == nil && !systemPromptVisible(prompt, user.ID)) {
+		return nil, connect.NewError(connect.CodeNotFound, errSystemPromptNotFound)
+	}
+	if err != nil {
+		return nil, connect.NewError(connect.CodeInternal, err)
+	}
+	versions, err := s.store.SystemPrompts().ListVersions(ctx, params)
+	if err != nil {
+		return nil, connect.NewError(connect.CodeInternal, err)
+	}
+	pb := make([]*leapmuxv1.SystemPromptVersion, len(versions))
+	for i, v := range versions {
+		pb[i] = &leapmuxv1.SystemPromptVersion{
+			Version:   uint32(v.Version),
+			Content:   v.Content,
+			CreatedBy: v.CreatedBy,
+			CreatedAt: timefmt.Format(v.CreatedAt),
+		}
+	}
+	return connect.NewResponse(&leapmuxv1.ListSystemPromptVersionsResponse{Versions: pb}), nil
+}
+
+// ownedSystemPrompt loads a prompt the caller may change. Another member's
+// shared prompt is PermissionDenied; one the caller cannot see at all is
+// NotFound, as if it did not exist.
+func (s *SystemPromptService) ownedSystemPrompt(ctx context.Context, orgID string, userID userid.UserID, promptID string) (*store.SystemPrompt, error) {
+	prompt, err := s.store.SystemPrompts().Get(ctx, store.GetSystemPromptParams{ID: promptID, OrgID: orgID})
+	if errors.Is(err, store.ErrNotFound) || (err == nil && !systemPromptVisible(prompt, userID)) {
+		return nil, connect.NewError(connect.CodeNotFound, errSystemPromptNotFound)
+	}
+	if err != nil {
+		return nil, connect.NewError(connect.CodeInternal, err)
+	}
+	if prompt.OwnerUserID != userID.String() {
+		return nil, connect.NewError(connect.CodePermissionDenied, errors.New("only the owner can change a system prompt"))
+	}
+	return prompt, nil
+}
+
+// systemPromptCaller resolves the caller and the org a library request
+// acts on. Only a credential that speaks for the whole account may reach
+// the library.
+func systemPromptCaller(ctx context.Context, requestedOrgID string) (*auth.UserInfo, string, error) {
+	user, err := auth.MustGetUser(ctx)
+	if err != nil {
+		return nil, "", err
+	}
+	if user.Credential.IsWorkspaceScoped() {
+		return nil, "", connect.NewError(connect.CodePermissionDenied, errors.New("system prompts are managed by the account's own credentials"))
+	}
+	orgID, err := auth.ResolveOrgID(user, requestedOrgID)
+	if err != nil {
+		return nil, "", err
+	}
+	return user, orgID, nil
+}
+
+// systemPromptVisible reports whether userID may read p.
+func systemPromptVisible(p *store.SystemPrompt, userID userid.UserID) bool {
+	return p.Shared || p.OwnerUserID == userID.String()
+}
+
+// validateSystemPromptName trims and checks a prompt's name.
+func validateSystemPromptName(name string) (string, error) {
+	name = strings.TrimSpace(name)
+	if name == "" {
+		return "", errors.New("name: must not be empty")
+	}
+	if utf8.RuneCountInString(name) > maxSystemPromptNameLen {
+		return "", fmt.Errorf("name: must be at most %d characters", maxSystemPromptNameLen)
+	}
+	if strings.IndexFunc(name, unicode.IsControl) >= 0 {
+		return "", errors.New("name: must not contain control characters")
+	}
+	return name, nil
+}
+
+// validateSystemPromptContent checks a prompt's content. Whitespace-only
+// content would attach nothing, so it is refused like empty content.
+func validateSystemPromptContent(content string) error {
+	if strings.TrimSpace(content) == "" {
+		return errors.New("content: must not be empty")
+	}
+	if len(content) > maxSystemPromptLen {
+		return fmt.Errorf("content: must be at most %d bytes", maxSystemPromptLen)
+	}
+	if !utf8.ValidString(content) {
+		return errors.New("content: must be valid UTF-8")
+	}
+	return nil
+}
+
+func systemPromptToProto(p *store.SystemPrompt) *leapmuxv1.SystemPrompt {
+	return &leapmuxv1.SystemPrompt{
+		Id:          p.ID,
+		OrgId:       p.OrgID,
+		OwnerUserId: p.OwnerUserID,
+		Name:        p.Name,
+		Shared:      p.Shared,
+		Version:     uint32(p.Version),
+		Content:     p.Content,
+		CreatedAt:   timefmt.Format(p.CreatedAt),
+		UpdatedAt:   timefmt.Format(p.UpdatedAt),
+	}
+}
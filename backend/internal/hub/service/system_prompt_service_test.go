@@ -0,0 +1,131 @@
+package service_test
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"connectrpc.com/connect"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	leapmuxv1 "github.com/leapmux/leapmux/generated/proto/leapmux/v1"
+	"github.com/leapmux/leapmux/internal/hub/auth"
+	"github.com/leapmux/leapmux/internal/hub/service"
+	"github.com/leapmux/leapmux/internal/hub/store/storetest"
+	hubtestutil "github.com/leapmux/leapmux/internal/hub/testutil"
+	"github.com/leapmux/leapmux/internal/util/userid"
+)
+
+func TestSystemPromptService_Versions(t *testing.T) {
+	st := hubtestutil.OpenTestStore(t)
+	orgID := storetest.SeedOrg(t, st, "prompt-org")
+	user := storetest.SeedUser(t, st, orgID, "alice")
+	ctx := auth.WithUser(context.Background(), &auth.UserInfo{ID: userid.MustNew(user.ID), OrgID: orgID})
+	svc := service.NewSystemPromptService(st)
+
+	created, err := svc.CreateSystemPrompt(ctx, connect.NewRequest(&leapmuxv1.CreateSystemPromptRequest{
+		Name: "  Reviewer ", Content: "Review carefully.",
+	}))
+	require.NoError(t, err)
+	prompt := created.Msg.GetPrompt()
+	assert.Equal(t, "Reviewer", prompt.GetName())
+	assert.EqualValues(t, 1, prompt.GetVersion())
+
+	// Renaming alone keeps the version; new content adds one.
+	content := "Review carefully and cite line numbers."
+	updated, err := svc.UpdateSystemPrompt(ctx, connect.NewRequest(&leapmuxv1.UpdateSystemPromptRequest{
+		PromptId: prompt.GetId(), Name: "Reviewer", Content: &content,
+	}))
+	require.NoError(t, err)
+	assert.EqualValues(t, 2, updated.Msg.GetPrompt().GetVersion())
+	assert.Equal(t, content, updated.Msg.GetPrompt().GetContent())
+
+	updated, err = svc.UpdateSystemPrompt(ctx, connect.NewRequest(&leapmuxv1.UpdateSystemPromptRequest{
+		PromptId: prompt.GetId(), Name: "Strict reviewer", Content: &content,
+	}))
+	require.NoError(t, err)
+	assert.EqualValues(t, 2, updated.Msg.GetPrompt().GetVersion(), "unchanged content adds no version")
+
+	got, err := svc.GetSystemPrompt(ctx, connect.NewRequest(&leapmuxv1.GetSystemPromptRequest{PromptId: prompt.GetId(), Version: 1}))
+	require.NoError(t, err)
+	assert.Equal(t, "Review carefully.", got.Msg.GetPrompt().GetContent())
+	assert.Equal(t, "Strict reviewer", got.Msg.GetPrompt().GetName())
+
+	versions, err := svc.ListSystemPromptVersions(ctx, connect.NewRequest(&leapmuxv1.ListSystemPromptVersionsRequest{PromptId: prompt.GetId()}))
+	require.NoError(t, err)
+	require.Len(t, versions.Msg.GetVersions(), 2)
+	assert.EqualValues(t, 2, versions.Msg.GetVersions()[0].GetVersion())
+	assert.Equal(t, user.ID, versions.Msg.GetVersions()[0].GetCreatedBy())
+
+	_, err = svc.DeleteSystemPrompt(ctx, connect.NewRequest(&leapmuxv1.DeleteSystemPromptRequest{PromptId: prompt.GetId()}))
+	require.NoError(t, err)
+	_, err = svc.GetSystemPrompt(ctx, connect.NewRequest(&leapmuxv1.GetSystemPromptRequest{PromptId: prompt.GetId()}))
+	assert.Equal(t, connect.CodeNotFound, connect.CodeOf(err))
+}
+
+func TestSystemPromptService_Sharing(t *testing.T) {
+	st := hubtestutil.OpenTestStore(t)
+	orgID := storetest.SeedOrg(t, st, "prompt-org")
+	alice := storetest.SeedUser(t, st, orgID, "alice")
+	bob := storetest.SeedUser(t, st, orgID, "bob")
+	aliceCtx := auth.WithUser(context.Background(), &auth.UserInfo{ID: userid.MustNew(alice.ID), OrgID: orgID})
+	bobCtx := auth.WithUser(context.Background(), &auth.UserInfo{ID: userid.MustNew(bob.ID), OrgID: orgID})
+	svc := service.NewSystemPromptService(st)
+
+	create := func(name string, shared bool) string {
+		t.Helper()
+		resp, err := svc.CreateSystemPrompt(aliceCtx, connect.NewRequest(&leapmuxv1.CreateSystemPromptRequest{
+			Name: name, Content: "You are " + name + ".", Shared: shared,
+		}))
+		require.NoError(t, err)
+		return resp.Msg.GetPrompt().GetId()
+	}
+	private := create("private", false)
+	shared := create("shared", true)
+
+	listed, err := svc.ListSystemPrompts(bobCtx, connect.NewRequest(&leapmuxv1.ListSystemPromptsRequest{}))
+	require.NoError(t, err)
+	require.Len(t, listed.Msg.GetPrompts(), 1)
+	assert.Equal(t, shared, listed.Msg.GetPrompts()[0].GetId())
+
+	_, err = svc.GetSystemPrompt(bobCtx, connect.NewRequest(&leapmuxv1.GetSystemPromptRequest{PromptId: private}))
+	assert.Equal(t, connect.CodeNotFound, connect.CodeOf(err), "another member's personal prompt reads as missing")
+	_, err = svc.GetSystemPrompt(bobCtx, connect.NewRequest(&leapmuxv1.GetSystemPromptRequest{PromptId: shared}))
+	require.NoError(t, err)
+
+	_, err = svc.UpdateSystemPrompt(bobCtx, connect.NewRequest(&leapmuxv1.UpdateSystemPromptRequest{PromptId: shared, Name: "mine"}))
+	assert.Equal(t, connect.CodePermissionDenied, connect.CodeOf(err))
+	_, err = svc.DeleteSystemPrompt(bobCtx, connect.NewRequest(&leapmuxv1.DeleteSystemPromptRequest{PromptId: shared}))
+	assert.Equal(t, connect.CodePermissionDenied, connect.CodeOf(err))
+	_, err = svc.DeleteSystemPrompt(bobCtx, connect.NewRequest(&leapmuxv1.DeleteSystemPromptRequest{PromptId: private}))
+	assert.Equal(t, connect.CodeNotFound, connect.CodeOf(err))
+
+	// Names are per owner, so bob may reuse one of alice's.
+	_, err = svc.CreateSystemPrompt(bobCtx, connect.NewRequest(&leapmuxv1.CreateSystemPromptRequest{Name: "shared", Content: "Mine."}))
+	require.NoError(t, err)
+}
+
+func TestSystemPromptService_RejectsInvalid(t *testing.T) {
+	st := hubtestutil.OpenTestStore(t)
+	orgID := storetest.SeedOrg(t, st, "prompt-org")
+	user := storetest.SeedUser(t, st, orgID, "alice")
+	ctx := auth.WithUser(context.Background(), &auth.UserInfo{ID: userid.MustNew(user.ID), OrgID: orgID})
+	svc := service.NewSystemPromptService(st)
+
+	tests := []struct {
+		name string
+		req  *leapmuxv1.CreateSystemPromptRequest
+	}{
+		{name: "empty name", req: &leapmuxv1.CreateSystemPromptRequest{Name: " ", Content: "x"}},
+		{name: "long name", req: &leapmuxv1.CreateSystemPromptRequest{Name: strings.Repeat("n", 101), Content: "x"}},
+		{name: "blank content", req: &leapmuxv1.CreateSystemPromptRequest{Name: "blank", Content: "\n\t"}},
+		{name: "oversized content", req: &leapmuxv1.CreateSystemPromptRequest{Name: "big", Content: strings.Repeat("x", 64<<10+1)}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := svc.CreateSystemPrompt(ctx, connect.NewRequest(tt.req))
+			assert.Equal(t, connect.CodeInvalidArgument, connect.CodeOf(err))
+		})
+	}
+}
@@ -31,6 +31,16 @@ type storedPreferences struct {
 	TurnEndSoundVolume    *int     `json:"turnEndSoundVolume,omitempty"`
 	DebugLogging          bool     `json:"debugLogging,omitempty"`
 	CustomKeybindingsJSON string   `json:"customKeybindingsJSON,omitempty"`
+	// Notifications is owned by Get/UpdateNotificationPreferences.
+	Notifications *storedNotificationPreferences `json:"notifications,omitempty"`
+	// WorkerStream is owned by Get/UpdateWorkerStreamSettings.
+	WorkerStream *storedWorkerStreamSettings `json:"workerStream,omitempty"`
+	// AgentTerminal is owned by Get/UpdateAgentTerminalPolicy.
+	AgentTerminal *storedAgentTerminalPolicy `json:"agentTerminal,omitempty"`
+	// WorkerDiskQuota is owned by Get/UpdateWorkerDiskQuota.
+	WorkerDiskQuota *storedWorkerDiskQuota `json:"workerDiskQuota,omitempty"`
+	// OrgDefaults is owned by SettingsService.
+	OrgDefaults *storedOrgDefaults `json:"orgDefaults,omitempty"`
 }
 
 // maxCustomKeybindings is the maximum number of keybinding overrides allowed.
@@ -636,47 +646,33 @@ func (s *UserService) UpdatePreferences(ctx context.Context, req *connect.Reques
 	}
 
 	// Validate custom keybindings JSON if provided.
-	customKeybindingsJSON := ""
 	if req.Msg.CustomKeybindingsJson != nil {
-		customKeybindingsJSON = *req.Msg.CustomKeybindingsJson
-		if err := validateCustomKeybindingsJSON(customKeybindingsJSON); err != nil {
+		if err := validateCustomKeybindingsJSON(*req.Msg.CustomKeybindingsJson); err != nil {
 			return nil, connect.NewError(connect.CodeInvalidArgument, fmt.Errorf("custom_keybindings_json: %w", err))
 		}
-	} else {
-		// Preserve existing value when the field is not provided.
-		existing, err := s.store.Users().GetPrefs(ctx, userInfo.ID.String())
-		if err == nil {
-			var prev storedPreferences
-			if json.Unmarshal([]byte(existing), &prev) == nil {
-				customKeybindingsJSON = prev.CustomKeybindingsJSON
-			}
-		}
-	}
-
-	sp := storedPreferences{
-		Theme:                 theme,
-		TerminalTheme:         terminalTheme,
-		UIFontCustomEnabled:   req.Msg.GetUiFontCustomEnabled(),
-		MonoFontCustomEnabled: req.Msg.GetMonoFontCustomEnabled(),
-		UIFonts:               uiFonts,
-		MonoFonts:             monoFonts,
-		DiffView:              int(req.Msg.GetDiffView()),
-		TurnEndSound:          int(req.Msg.GetTurnEndSound()),
-		TurnEndSoundVolume:    ptrconv.Convert[uint32, int](req.Msg.TurnEndSoundVolume),
-		DebugLogging:          req.Msg.GetDebugLogging(),
-		CustomKeybindingsJSON: customKeybindingsJSON,
 	}
 
-	prefsJSON, err := json.Marshal(sp)
+	// Fields this RPC does not own (notification preferences, worker stream
+	// settings, the agent terminal policy, and custom keybindings when the
+	// field is omitted) carry over from the stored record.
+	sp, err := updateStoredPreferences(ctx, s.store, userInfo.ID.String(), func(sp *storedPreferences) error {
+		sp.Theme = theme
+		sp.TerminalTheme = terminalTheme
+		sp.UIFontCustomEnabled = req.Msg.GetUiFontCustomEnabled()
+		sp.MonoFontCustomEnabled = req.Msg.GetMonoFontCustomEnabled()
+		sp.UIFonts = uiFonts
+		sp.MonoFonts = monoFonts
+		sp.DiffView = int(req.Msg.GetDiffView())
+		sp.TurnEndSound = int(req.Msg.GetTurnEndSound())
+		sp.TurnEndSoundVolume = ptrconv.Convert[uint32, int](req.Msg.TurnEndSoundVolume)
+		sp.DebugLogging = req.Msg.GetDebugLogging()
+		if req.Msg.CustomKeybindingsJson != nil {
+			sp.CustomKeybindingsJSON = *req.Msg.CustomKeybindingsJson
+		}
+		return nil
+	})
 	if err != nil {
-		return nil, connect.NewError(connect.CodeInternal, fmt.Errorf("marshal prefs: %w", err))
-	}
-
-	if err := s.store.Users().UpdatePrefs(ctx, store.UpdateUserPrefsParams{
-		Prefs: string(prefsJSON),
-		ID:    userInfo.ID.String(),
-	}); err != nil {
-		return nil, connect.NewError(connect.CodeInternal, err)
+		return nil, err
 	}
 
 	// Echo the persisted record, not the raw request: the response must report the
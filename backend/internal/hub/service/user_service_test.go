@@ -339,6 +339,60 @@ func TestUserService_UpdatePreferences_ResponseEchoesSanitizedTheme(t *testing.T
 	assert.Equal(t, updated.GetTerminalTheme(), got.Msg.GetPreferences().GetTerminalTheme())
 }
 
+func TestUserService_NotificationPreferences_RoundTrip(t *testing.T) {
+	env := setupUserTest(t)
+
+	_, err := env.client.UpdateNotificationPreferences(context.Background(), authedReq(&leapmuxv1.UpdateNotificationPreferencesRequest{
+		Preferences: &leapmuxv1.NotificationPreferences{
+			MutedEventTypes:   []leapmuxv1.NotificationEventType{leapmuxv1.NotificationEventType_NOTIFICATION_EVENT_TYPE_TURN_END},
+			DisabledChannels:  []leapmuxv1.NotificationChannel{leapmuxv1.NotificationChannel_NOTIFICATION_CHANNEL_EMAIL},
+			QuietHours:        &leapmuxv1.QuietHours{StartMinute: 22 * 60, EndMinute: 7 * 60, TimeZone: "Europe/Berlin"},
+			MutedWorkspaceIds: []string{"ws-1", "ws-1"},
+		},
+	}, env.token))
+	require.NoError(t, err)
+
+	// Saving general preferences must not wipe notification preferences.
+	_, err = env.client.UpdatePreferences(context.Background(), authedReq(&leapmuxv1.UpdatePreferencesRequest{Theme: "dark"}, env.token))
+	require.NoError(t, err)
+
+	resp, err := env.client.GetNotificationPreferences(context.Background(), authedReq(&leapmuxv1.GetNotificationPreferencesRequest{}, env.token))
+	require.NoError(t, err)
+	prefs := resp.Msg.GetPreferences()
+	assert.Equal(t, []leapmuxv1.NotificationEventType{leapmuxv1.NotificationEventType_NOTIFICATION_EVENT_TYPE_TURN_END}, prefs.GetMutedEventTypes())
+	assert.Equal(t, []leapmuxv1.NotificationChannel{leapmuxv1.NotificationChannel_NOTIFICATION_CHANNEL_EMAIL}, prefs.GetDisabledChannels())
+	assert.Equal(t, uint32(22*60), prefs.GetQuietHours().GetStartMinute())
+	assert.Equal(t, "Europe/Berlin", prefs.GetQuietHours().GetTimeZone())
+	assert.Equal(t, []string{"ws-1"}, prefs.GetMutedWorkspaceIds())
+
+	// And notification updates must not wipe general preferences.
+	got, err := env.client.GetPreferences(context.Background(), authedReq(&leapmuxv1.GetPreferencesRequest{}, env.token))
+	require.NoError(t, err)
+	assert.Equal(t, "dark", got.Msg.GetPreferences().GetTheme())
+
+	allowed, err := service.NotificationAllowed(context.Background(), env.store, env.userID,
+		leapmuxv1.NotificationEventType_NOTIFICATION_EVENT_TYPE_TURN_END, leapmuxv1.NotificationChannel_NOTIFICATION_CHANNEL_WEB_PUSH, "")
+	require.NoError(t, err)
+	assert.False(t, allowed)
+}
+
+func TestUserService_UpdateNotificationPreferences_Invalid(t *testing.T) {
+	env := setupUserTest(t)
+
+	for name, prefs := range map[string]*leapmuxv1.NotificationPreferences{
+		"unspecified event": {MutedEventTypes: []leapmuxv1.NotificationEventType{leapmuxv1.NotificationEventType_NOTIFICATION_EVENT_TYPE_UNSPECIFIED}},
+		"unknown channel":   {DisabledChannels: []leapmuxv1.NotificationChannel{99}},
+		"minute overflow":   {QuietHours: &leapmuxv1.QuietHours{StartMinute: 24 * 60, EndMinute: 60}},
+		"empty window":      {QuietHours: &leapmuxv1.QuietHours{StartMinute: 60, EndMinute: 60}},
+		"bad time zone":     {QuietHours: &leapmuxv1.QuietHours{StartMinute: 0, EndMinute: 60, TimeZone: "Mars/Olympus"}},
+		"empty workspace":   {MutedWorkspaceIds: []string{""}},
+	} {
+		_, err := env.client.UpdateNotificationPreferences(context.Background(), authedReq(&leapmuxv1.UpdateNotificationPreferencesRequest{Preferences: prefs}, env.token))
+		require.Error(t, err, name)
+		assert.Equal(t, connect.CodeInvalidArgument, connect.CodeOf(err), name)
+	}
+}
+
 func TestUserService_UpdatePreferences_InvalidFontName(t *testing.T) {
 	env := setupUserTest(t)
 
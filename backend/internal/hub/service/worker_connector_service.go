@@ -16,6 +16,7 @@ import (
 	"github.com/leapmux/leapmux/internal/hub/notifier"
 	"github.com/leapmux/leapmux/internal/hub/store"
 	"github.com/leapmux/leapmux/internal/hub/workermgr"
+	"github.com/leapmux/leapmux/internal/protocol"
 	"github.com/leapmux/leapmux/internal/util/id"
 	"github.com/leapmux/leapmux/internal/util/ptrconv"
 	"github.com/leapmux/leapmux/internal/util/userid"
@@ -167,11 +168,74 @@ func (s *WorkerConnectorService) Connect(
 		return connect.NewError(connect.CodeInternal, err)
 	}
 
+	// Refuse a worker too old to understand the payloads this hub sends. It
+	// would otherwise connect and drop them as unhandled, which looks like a
+	// hang rather than a version problem; ListWorkers reports the refusal as
+	// upgrade_required instead.
+	workerProtocol := protocol.Parse(stream.RequestHeader().Get(protocol.Header))
+	if err := protocol.CheckWorker(workerProtocol); err != nil {
+		slog.Warn("refusing worker with an old protocol version", "worker_id", worker.ID, "protocol_version", workerProtocol)
+		if s.workerMgr.SetUpgradeRequired(worker.ID, true) {
+			s.broadcaster.NotifyWorkersChanged(worker.RegisteredBy)
+		}
+		return connect.NewError(connect.CodeFailedPrecondition, err)
+	}
+	if s.workerMgr.SetUpgradeRequired(worker.ID, false) {
+		s.broadcaster.NotifyWorkersChanged(worker.RegisteredBy)
+	}
+
 	// Register the connection. Replacement cancels this derived context to
 	// terminate the superseded handler without affecting the request context of
 	// the newly connected worker.
 	connCtx, cancelConn := context.WithCancel(ctx)
 	defer cancelConn()
+	// A worker that cannot learn its org's settings still connects; it runs
+	// unlimited, uncompressed, with no agent terminal policy, no access
+	// policy, no disk quota, no org defaults and no emergency stop until the
+	// next push or reconnect.
+	var (
+		streamSettings      *leapmuxv1.WorkerStreamSettings
+		agentTerminalPolicy *leapmuxv1.AgentTerminalPolicy
+		accessPolicy        *leapmuxv1.AccessPolicy
+		diskQuota           *leapmuxv1.WorkerDiskQuota
+		orgDefaults         *leapmuxv1.OrgDefaults
+		emergencyStop       *leapmuxv1.EmergencyStopState
+	)
+	if sp, err := loadStoredPreferences(ctx, s.store, worker.RegisteredBy); err != nil {
+		slog.Warn("failed to load worker org settings", "worker_id", worker.ID, "error", err)
+	} else {
+		streamSettings = workerStreamSettingsToProto(sp.WorkerStream)
+		agentTerminalPolicy = agentTerminalPolicyToProto(sp.AgentTerminal)
+		diskQuota = workerDiskQuotaToProto(sp.WorkerDiskQuota)
+		orgDefaults = orgDefaultsToProto(sp.OrgDefaults)
+	}
+	// Nor does a failed announcements read keep the worker out: it sends
+	// none until the next publish.
+	announcements, err := liveAnnouncements(ctx, s.store)
+	if err != nil {
+		slog.Warn("failed to load announcements", "worker_id", worker.ID, "error", err)
+	}
+	// Nor a failed owner read: the worker goes without its org, and so
+	// without its org's emergency stops and access policy, until the next
+	// connect or push.
+	var orgID string
+	if owner, err := s.store.Users().GetByID(ctx, worker.RegisteredBy); err != nil {
+		slog.Warn("failed to load worker owner", "worker_id", worker.ID, "error", err)
+	} else {
+		orgID = owner.OrgID
+	}
+	if orgID != "" {
+		if stops, err := s.store.EmergencyStops().ListByOrg(ctx, orgID); err != nil {
+			slog.Warn("failed to load emergency stops", "worker_id", worker.ID, "error", err)
+		} else {
+			emergencyStop = emergencyStops(stops).forWorker(worker.ID)
+		}
+		if stored, err := loadStoredAccessPolicy(ctx, s.store, orgID); err != nil {
+			slog.Warn("failed to load access policy", "worker_id", worker.ID, "error", err)
+		} else {
+			accessPolicy = accessPolicyToProto(stored)
+		}
+	}
 	conn := &workermgr.Conn{
 		WorkerID: worker.ID,
 		Stream:   stream,
@@ -183,11 +247,22 @@ func (s *WorkerConnectorService) Connect(
 		// conn is reachable. Handing it to Register rather than sending it here is what
 		// makes that ordering impossible to get wrong.
 		//
-		// worker.RegisteredBy is already in hand from the GetByAuthToken above, so this
-		// costs no query.
+		// worker.RegisteredBy is already in hand from the GetByAuthToken above; the
+		// org settings and the owner's org are the extra reads.
 		Greeting: &leapmuxv1.ConnectResponse{
 			Payload: &leapmuxv1.ConnectResponse_WorkerIdentity{
-				WorkerIdentity: &leapmuxv1.WorkerIdentity{RegisteredBy: worker.RegisteredBy},
+				WorkerIdentity: &leapmuxv1.WorkerIdentity{
+					RegisteredBy:        worker.RegisteredBy,
+					ProtocolVersion:     protocol.Current,
+					StreamSettings:      streamSettings,
+					AgentTerminalPolicy: agentTerminalPolicy,
+					DiskQuota:           diskQuota,
+					OrgDefaults:         orgDefaults,
+					EmergencyStop:       emergencyStop,
+					Announcements:       announcements,
+					AccessPolicy:        accessPolicy,
+					OrgId:               orgID,
+				},
 			},
 		},
 	}
@@ -218,7 +293,7 @@ func (s *WorkerConnectorService) Connect(
 		slog.Warn("failed to update worker last seen", "worker_id", worker.ID, "error", err)
 	}
 
-	slog.Info("worker connected", "worker_id", worker.ID, "status", worker.Status)
+	slog.Info("worker connected", "worker_id", worker.ID, "status", worker.Status, "protocol_version", workerProtocol)
 	defer slog.Info("worker disconnected", "worker_id", worker.ID)
 
 	// Process pending notifications.
@@ -352,6 +427,12 @@ func (s *WorkerConnectorService) processWorkerMessage(
 			encMode = leapmuxv1.EncryptionMode_ENCRYPTION_MODE_POST_QUANTUM
 		}
 		conn.EncryptionMode = encMode
+		if stats := hb.GetConnectionStats(); stats != nil {
+			conn.SetConnectionStats(stats)
+		}
+		if labels := reportedWorkerLabels(hb.GetLabels()); len(labels) > 0 {
+			conn.SetLabels(labels)
+		}
 		// Persist worker's public keys if provided (sent with the initial heartbeat).
 		if pk := hb.GetPublicKey(); len(pk) > 0 {
 			mlkemPK := hb.GetMlkemPublicKey()
@@ -398,6 +479,27 @@ func (s *WorkerConnectorService) processWorkerMessage(
 		return nil
 	}
 
+	if usage := msg.GetDiskUsage(); usage != nil {
+		s.handleDiskUsage(ctx, conn, workerID, usage)
+		return nil
+	}
+
+	if activity := msg.GetWorkspaceActivity(); activity != nil {
+		conn.ApplyWorkspaceActivity(reportedWorkspaceActivity(activity))
+		return nil
+	}
+
+	// Place, or remove, the tab of a terminal the worker runs for an agent.
+	// Placing may wait for the agent's tab, so it runs off the stream loop.
+	if opened := msg.GetAgentTerminalOpened(); opened != nil {
+		go s.handleAgentTerminalOpened(ctx, workerID, opened)
+		return nil
+	}
+	if closed := msg.GetAgentTerminalClosed(); closed != nil {
+		s.handleAgentTerminalClosed(ctx, workerID, closed)
+		return nil
+	}
+
 	// Route channel messages from worker to frontend.
 	if chMsg := msg.GetChannelMessageResp(); chMsg != nil {
 		if s.channelMgr != nil {
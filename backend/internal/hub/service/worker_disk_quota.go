@@ -0,0 +1,140 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+
+	"connectrpc.com/connect"
+	leapmuxv1 "github.com/leapmux/leapmux/generated/proto/leapmux/v1"
+	"github.com/leapmux/leapmux/internal/hub/auth"
+	"github.com/leapmux/leapmux/internal/hub/workermgr"
+)
+
+const (
+	// minDiskQuotaBytes is the smallest non-zero quota. Below it a single
+	// dependency install fills the quota, and the worker would refuse
+	// every worktree after the first.
+	minDiskQuotaBytes = 64 << 20
+	// maxReportedDiskWorkspaces and maxReportedDiskWarnings bound the disk
+	// report the Hub keeps per connected worker.
+	maxReportedDiskWorkspaces = 256
+	maxReportedDiskWarnings   = 8
+)
+
+// storedWorkerDiskQuota is the "workerDiskQuota" entry of the
+// user_preferences JSON blob, stored with the org's one member like
+// storedWorkerStreamSettings.
+type storedWorkerDiskQuota struct {
+	WorkspaceBytes uint64 `json:"workspaceBytes,omitempty"`
+	WorkerBytes    uint64 `json:"workerBytes,omitempty"`
+	MinFreeBytes   uint64 `json:"minFreeBytes,omitempty"`
+}
+
+// validateWorkerDiskQuota checks q and returns its stored form.
+func validateWorkerDiskQuota(q *leapmuxv1.WorkerDiskQuota) (*storedWorkerDiskQuota, error) {
+	for _, f := range []struct {
+		name  string
+		value uint64
+	}{
+		{"workspace_bytes", q.GetWorkspaceBytes()},
+		{"worker_bytes", q.GetWorkerBytes()},
+	} {
+		if f.value != 0 && f.value < minDiskQuotaBytes {
+			return nil, fmt.Errorf("%s must be 0 (unlimited) or at least %d", f.name, minDiskQuotaBytes)
+		}
+	}
+	if w, t := q.GetWorkspaceBytes(), q.GetWorkerBytes(); w != 0 && t != 0 && w > t {
+		return nil, fmt.Errorf("workspace_bytes must not exceed worker_bytes")
+	}
+	return &storedWorkerDiskQuota{
+		WorkspaceBytes: q.GetWorkspaceBytes(),
+		WorkerBytes:    q.GetWorkerBytes(),
+		MinFreeBytes:   q.GetMinFreeBytes(),
+	}, nil
+}
+
+// workerDiskQuotaToProto converts the stored form; nil is the default of no
+// quota and the worker's default free-space floor.
+func workerDiskQuotaToProto(q *storedWorkerDiskQuota) *leapmuxv1.WorkerDiskQuota {
+	if q == nil {
+		return &leapmuxv1.WorkerDiskQuota{}
+	}
+	return &leapmuxv1.WorkerDiskQuota{
+		WorkspaceBytes: q.WorkspaceBytes,
+		WorkerBytes:    q.WorkerBytes,
+		MinFreeBytes:   q.MinFreeBytes,
+	}
+}
+
+func (s *WorkerManagementService) GetWorkerDiskQuota(
+	ctx context.Context,
+	_ *connect.Request[leapmuxv1.GetWorkerDiskQuotaRequest],
+) (*connect.Response[leapmuxv1.GetWorkerDiskQuotaResponse], error) {
+	user, err := auth.MustGetUser(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	sp, err := loadStoredPreferences(ctx, s.store, user.ID.String())
+	if err != nil {
+		return nil, connect.NewError(connect.CodeInternal, err)
+	}
+	return connect.NewResponse(&leapmuxv1.GetWorkerDiskQuotaResponse{Quota: workerDiskQuotaToProto(sp.WorkerDiskQuota)}), nil
+}
+
+// UpdateWorkerDiskQuota replaces the org's worker disk quota and pushes it
+// to the org's workers connected to this Hub, the same way
+// UpdateWorkerStreamSettings does.
+func (s *WorkerManagementService) UpdateWorkerDiskQuota(
+	ctx context.Context,
+	req *connect.Request[leapmuxv1.UpdateWorkerDiskQuotaRequest],
+) (*connect.Response[leapmuxv1.UpdateWorkerDiskQuotaResponse], error) {
+	user, err := auth.MustGetUser(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	stored, err := validateWorkerDiskQuota(req.Msg.GetQuota())
+	if err != nil {
+		return nil, connect.NewError(connect.CodeInvalidArgument, err)
+	}
+
+	if _, err := updateStoredPreferences(ctx, s.store, user.ID.String(), func(sp *storedPreferences) error {
+		sp.WorkerDiskQuota = stored
+		return nil
+	}); err != nil {
+		return nil, err
+	}
+
+	quota := workerDiskQuotaToProto(stored)
+	pushToUserWorkers(ctx, s.store, s.workerMgr, user, &leapmuxv1.ConnectResponse{
+		Payload: &leapmuxv1.ConnectResponse_DiskQuota{DiskQuota: quota},
+	}, "disk quota")
+	return connect.NewResponse(&leapmuxv1.UpdateWorkerDiskQuotaResponse{Quota: quota}), nil
+}
+
+// reportedDiskUsage trims a worker's disk report to what the Hub keeps.
+func reportedDiskUsage(u *leapmuxv1.WorkerDiskUsage) *leapmuxv1.WorkerDiskUsage {
+	if len(u.Workspaces) > maxReportedDiskWorkspaces {
+		u.Workspaces = u.Workspaces[:maxReportedDiskWorkspaces]
+	}
+	if len(u.Warnings) > maxReportedDiskWarnings {
+		u.Warnings = u.Warnings[:maxReportedDiskWarnings]
+	}
+	return u
+}
+
+// handleDiskUsage records a worker's disk report and, when its warnings
+// changed, tells the owner's clients to refetch the worker.
+func (s *WorkerConnectorService) handleDiskUsage(ctx context.Context, conn *workermgr.Conn, workerID string, u *leapmuxv1.WorkerDiskUsage) {
+	if !conn.SetDiskUsage(reportedDiskUsage(u)) {
+		return
+	}
+	worker, err := s.store.Workers().GetByID(ctx, workerID)
+	if err != nil {
+		slog.Warn("failed to load worker for disk warning", "worker_id", workerID, "error", err)
+		return
+	}
+	s.broadcaster.NotifyWorkersChanged(worker.RegisteredBy)
+}
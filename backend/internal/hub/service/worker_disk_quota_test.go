@@ -0,0 +1,142 @@
+package service_test
+
+import (
+	"context"
+	"testing"
+
+	"connectrpc.com/connect"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	leapmuxv1 "github.com/leapmux/leapmux/generated/proto/leapmux/v1"
+	"github.com/leapmux/leapmux/internal/hub/auth"
+	"github.com/leapmux/leapmux/internal/hub/config"
+	"github.com/leapmux/leapmux/internal/hub/mail"
+	"github.com/leapmux/leapmux/internal/hub/service"
+	"github.com/leapmux/leapmux/internal/hub/store"
+	"github.com/leapmux/leapmux/internal/hub/testutil"
+	"github.com/leapmux/leapmux/internal/hub/workermgr"
+	"github.com/leapmux/leapmux/internal/util/userid"
+)
+
+func TestWorkerDiskQuota_RoundTripAndPush(t *testing.T) {
+	st := testutil.OpenTestStore(t)
+	uid := userid.MustNew(testutil.CreateTestUser(t, st, "quota", "password123"))
+	ctx := auth.WithUser(context.Background(), &auth.UserInfo{ID: uid})
+
+	require.NoError(t, st.Workers().Create(ctx, store.CreateWorkerParams{
+		ID:              "w-online",
+		AuthToken:       "token-w-online",
+		RegisteredBy:    uid,
+		PublicKey:       []byte("test-x25519-key-32-bytes-padding"),
+		MlkemPublicKey:  []byte("mlkem"),
+		SlhdsaPublicKey: []byte("slhdsa"),
+	}))
+	mgr := workermgr.New(service.NewWorkerReachAuthorizer(st))
+	pushed := make(chan *leapmuxv1.ConnectResponse, 4)
+	_, err := mgr.Register(&workermgr.Conn{
+		WorkerID: "w-online",
+		SendFn: func(msg *leapmuxv1.ConnectResponse) error {
+			pushed <- msg
+			return nil
+		},
+	})
+	require.NoError(t, err)
+	svc := service.NewWorkerManagementService(st, mgr, nil, nil, nil, mail.Renderer{}, &config.Config{}, nil)
+
+	got, err := svc.GetWorkerDiskQuota(ctx, connect.NewRequest(&leapmuxv1.GetWorkerDiskQuotaRequest{}))
+	require.NoError(t, err)
+	assert.Zero(t, got.Msg.GetQuota().GetWorkerBytes(), "unset means no quota")
+
+	want := &leapmuxv1.WorkerDiskQuota{WorkspaceBytes: 1 << 30, WorkerBytes: 10 << 30, MinFreeBytes: 2 << 30}
+	_, err = svc.UpdateWorkerDiskQuota(ctx, connect.NewRequest(&leapmuxv1.UpdateWorkerDiskQuotaRequest{Quota: want}))
+	require.NoError(t, err)
+
+	got, err = svc.GetWorkerDiskQuota(ctx, connect.NewRequest(&leapmuxv1.GetWorkerDiskQuotaRequest{}))
+	require.NoError(t, err)
+	assert.EqualValues(t, 1<<30, got.Msg.GetQuota().GetWorkspaceBytes())
+	assert.EqualValues(t, 10<<30, got.Msg.GetQuota().GetWorkerBytes())
+	assert.EqualValues(t, 2<<30, got.Msg.GetQuota().GetMinFreeBytes())
+
+	require.Len(t, pushed, 1)
+	assert.EqualValues(t, 10<<30, (<-pushed).GetDiskQuota().GetWorkerBytes())
+}
+
+func TestWorkerDiskQuota_RejectsInvalid(t *testing.T) {
+	tests := []struct {
+		name  string
+		quota *leapmuxv1.WorkerDiskQuota
+	}{
+		{name: "tiny workspace quota", quota: &leapmuxv1.WorkerDiskQuota{WorkspaceBytes: 1 << 20}},
+		{name: "tiny worker quota", quota: &leapmuxv1.WorkerDiskQuota{WorkerBytes: 1 << 20}},
+		{name: "workspace above worker", quota: &leapmuxv1.WorkerDiskQuota{WorkspaceBytes: 2 << 30, WorkerBytes: 1 << 30}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			st := testutil.OpenTestStore(t)
+			uid := userid.MustNew(testutil.CreateTestUser(t, st, "quota", "password123"))
+			ctx := auth.WithUser(context.Background(), &auth.UserInfo{ID: uid})
+			svc := service.NewWorkerManagementService(st, workermgr.New(workermgr.DenyAllReach()), nil, nil, nil, mail.Renderer{}, &config.Config{}, nil)
+
+			_, err := svc.UpdateWorkerDiskQuota(ctx, connect.NewRequest(&leapmuxv1.UpdateWorkerDiskQuotaRequest{Quota: tt.quota}))
+			assert.Equal(t, connect.CodeInvalidArgument, connect.CodeOf(err))
+		})
+	}
+}
+
+func TestUpdatePreferences_KeepsWorkerDiskQuota(t *testing.T) {
+	st := testutil.OpenTestStore(t)
+	uid := userid.MustNew(testutil.CreateTestUser(t, st, "keeper", "password123"))
+	ctx := auth.WithUser(context.Background(), &auth.UserInfo{ID: uid})
+	mgmt := service.NewWorkerManagementService(st, workermgr.New(workermgr.DenyAllReach()), nil, nil, nil, mail.Renderer{}, &config.Config{}, nil)
+
+	_, err := mgmt.UpdateWorkerDiskQuota(ctx, connect.NewRequest(&leapmuxv1.UpdateWorkerDiskQuotaRequest{
+		Quota: &leapmuxv1.WorkerDiskQuota{WorkerBytes: 1 << 30},
+	}))
+	require.NoError(t, err)
+
+	users := service.NewUserService(st, &config.Config{}, auth.NewCredentialLifecycleEffects(nil, nil, nil), mail.NewStubSender(), mail.Renderer{})
+	_, err = users.UpdatePreferences(ctx, connect.NewRequest(&leapmuxv1.UpdatePreferencesRequest{Theme: "dark"}))
+	require.NoError(t, err)
+
+	got, err := mgmt.GetWorkerDiskQuota(ctx, connect.NewRequest(&leapmuxv1.GetWorkerDiskQuotaRequest{}))
+	require.NoError(t, err)
+	assert.EqualValues(t, 1<<30, got.Msg.GetQuota().GetWorkerBytes())
+}
+
+func TestGetWorker_ReportsDiskUsage(t *testing.T) {
+	st := testutil.OpenTestStore(t)
+	uid := userid.MustNew(testutil.CreateTestUser(t, st, "disk", "password123"))
+	ctx := auth.WithUser(context.Background(), &auth.UserInfo{ID: uid})
+	require.NoError(t, st.Workers().Create(ctx, store.CreateWorkerParams{
+		ID:              "w-1",
+		AuthToken:       "token-w-1",
+		RegisteredBy:    uid,
+		PublicKey:       []byte("test-x25519-key-32-bytes-padding"),
+		MlkemPublicKey:  []byte("mlkem"),
+		SlhdsaPublicKey: []byte("slhdsa"),
+	}))
+	mgr := workermgr.New(service.NewWorkerReachAuthorizer(st))
+	conn := &workermgr.Conn{WorkerID: "w-1", SendFn: func(*leapmuxv1.ConnectResponse) error { return nil }}
+	_, err := mgr.Register(conn)
+	require.NoError(t, err)
+	svc := service.NewWorkerManagementService(st, mgr, nil, nil, nil, mail.Renderer{}, &config.Config{}, nil)
+
+	got, err := svc.GetWorker(ctx, connect.NewRequest(&leapmuxv1.GetWorkerRequest{WorkerId: "w-1"}))
+	require.NoError(t, err)
+	assert.Nil(t, got.Msg.GetWorker().GetDiskUsage(), "nothing reported yet")
+
+	assert.True(t, conn.SetDiskUsage(&leapmuxv1.WorkerDiskUsage{
+		UsedBytes: 5 << 30,
+		Warnings:  []string{"Only 512.0 MiB free on the worker's disk"},
+	}), "new warnings are a change")
+	assert.False(t, conn.SetDiskUsage(&leapmuxv1.WorkerDiskUsage{
+		UsedBytes: 6 << 30,
+		Warnings:  []string{"Only 512.0 MiB free on the worker's disk"},
+	}), "same warnings are not")
+
+	got, err = svc.GetWorker(ctx, connect.NewRequest(&leapmuxv1.GetWorkerRequest{WorkerId: "w-1"}))
+	require.NoError(t, err)
+	assert.EqualValues(t, 6<<30, got.Msg.GetWorker().GetDiskUsage().GetUsedBytes())
+	assert.Len(t, got.Msg.GetWorker().GetDiskUsage().GetWarnings(), 1)
+}
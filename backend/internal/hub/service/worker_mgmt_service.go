@@ -10,6 +10,7 @@ import (
 	"google.golang.org/protobuf/types/known/timestamppb"
 
 	leapmuxv1 "github.com/leapmux/leapmux/generated/proto/leapmux/v1"
+	"github.com/leapmux/leapmux/internal/errcode"
 	"github.com/leapmux/leapmux/internal/hub/auth"
 	"github.com/leapmux/leapmux/internal/hub/config"
 	"github.com/leapmux/leapmux/internal/hub/mail"
@@ -311,7 +312,7 @@ func (s *WorkerManagementService) GetWorker(
 	})
 	if err != nil {
 		if errors.Is(err, store.ErrNotFound) {
-			return nil, connect.NewError(connect.CodeNotFound, fmt.Errorf("worker not found"))
+			return nil, errcode.New(connect.CodeNotFound, errcode.WorkerNotFound, errors.New("worker not found"))
 		}
 		return nil, connect.NewError(connect.CodeInternal, err)
 	}
@@ -341,7 +342,7 @@ func (s *WorkerManagementService) DeregisterWorker(
 	})
 	if err != nil {
 		if errors.Is(err, store.ErrNotFound) {
-			return nil, connect.NewError(connect.CodeNotFound, fmt.Errorf("worker not found"))
+			return nil, errcode.New(connect.CodeNotFound, errcode.WorkerNotFound, errors.New("worker not found"))
 		}
 		return nil, connect.NewError(connect.CodeInternal, err)
 	}
@@ -357,7 +358,7 @@ func (s *WorkerManagementService) DeregisterWorker(
 		return nil, connect.NewError(connect.CodeInternal, err)
 	}
 	if rows == 0 {
-		return nil, connect.NewError(connect.CodeNotFound, fmt.Errorf("worker not found"))
+		return nil, errcode.New(connect.CodeNotFound, errcode.WorkerNotFound, errors.New("worker not found"))
 	}
 
 	// Deregistration is the operator's containment action against a compromised
@@ -389,12 +390,16 @@ func (s *WorkerManagementService) workerToProto(b *store.Worker, orgID string) *
 	}
 
 	return &leapmuxv1.Worker{
-		Id:             b.ID,
-		OrgId:          orgID,
-		Online:         s.workerMgr.OnlineForTrustedPath(b.ID),
-		CreatedAt:      timefmt.Format(b.CreatedAt),
-		LastSeenAt:     lastSeen,
-		RegisteredBy:   b.RegisteredBy,
-		AutoRegistered: b.AutoRegistered,
+		Id:              b.ID,
+		OrgId:           orgID,
+		Online:          s.workerMgr.OnlineForTrustedPath(b.ID),
+		CreatedAt:       timefmt.Format(b.CreatedAt),
+		LastSeenAt:      lastSeen,
+		RegisteredBy:    b.RegisteredBy,
+		AutoRegistered:  b.AutoRegistered,
+		UpgradeRequired: s.workerMgr.UpgradeRequiredForTrustedPath(b.ID),
+		ConnectionStats: s.workerMgr.ConnectionStatsForTrustedPath(b.ID),
+		Labels:          s.workerMgr.LabelsForTrustedPath(b.ID),
+		DiskUsage:       s.workerMgr.DiskUsageForTrustedPath(b.ID),
 	}
 }
@@ -3,10 +3,10 @@ package service
 import (
 	"context"
 	"errors"
-	"fmt"
 
 	"connectrpc.com/connect"
 
+	"github.com/leapmux/leapmux/internal/errcode"
 	"github.com/leapmux/leapmux/internal/hub/auth"
 	"github.com/leapmux/leapmux/internal/hub/store"
 )
@@ -46,7 +46,7 @@ func (a *WorkerReachAuthorizer) AuthorizeWorkerReach(ctx context.Context, user *
 		return connect.NewError(connect.CodeInternal, err)
 	}
 	if worker == nil || !ok || !auth.WorkerUsableNow(worker) {
-		return connect.NewError(connect.CodeNotFound, fmt.Errorf("worker not found"))
+		return errcode.New(connect.CodeNotFound, errcode.WorkerNotFound, errors.New("worker not found"))
 	}
 	return a.verifyDelegationWorkerScope(ctx, user, workerID)
 }
@@ -2,26 +2,35 @@ package service
 
 import (
 	"context"
+	"errors"
 	"fmt"
 
 	"connectrpc.com/connect"
 
 	leapmuxv1 "github.com/leapmux/leapmux/generated/proto/leapmux/v1"
 	"github.com/leapmux/leapmux/internal/hub/auth"
+	"github.com/leapmux/leapmux/internal/hub/channelmgr"
+	"github.com/leapmux/leapmux/internal/hub/keystore"
 	"github.com/leapmux/leapmux/internal/hub/store"
+	"github.com/leapmux/leapmux/internal/util/userid"
 )
 
 // WorkerReconcilerService implements WorkerReconcilerServiceHandler.
 // Authenticated by the worker's auth_token (the same bearer used for
 // Connect). Provides the periodic worker-side orphan reconciler with
-// a snapshot of `workspace_tab_owned` filtered to the calling worker.
+// a snapshot of `workspace_tab_owned` filtered to the calling worker,
+// and resolves snippets and model credentials for the users connected
+// to it.
 type WorkerReconcilerService struct {
-	store store.Store
+	store    store.Store
+	channels *channelmgr.Manager
+	keystore *keystore.Keystore
 }
 
-// NewWorkerReconcilerService returns a service handler.
-func NewWorkerReconcilerService(st store.Store) *WorkerReconcilerService {
-	return &WorkerReconcilerService{store: st}
+// NewWorkerReconcilerService returns a service handler. ks decrypts
+// model credential secrets.
+func NewWorkerReconcilerService(st store.Store, channels *channelmgr.Manager, ks *keystore.Keystore) *WorkerReconcilerService {
+	return &WorkerReconcilerService{store: st, channels: channels, keystore: ks}
 }
 
 // ListOwnedTabsForWorker resolves the calling worker via its bearer
@@ -51,3 +60,79 @@ func (s *WorkerReconcilerService) ListOwnedTabsForWorker(
 	}
 	return connect.NewResponse(&leapmuxv1.ListOwnedTabsForWorkerResponse{Tabs: out}), nil
 }
+
+// GetSnippetForWorker resolves the calling worker, then answers for the
+// user behind the named channel. A channel the worker does not hold is
+// NotFound, so a worker can read only the snippets of users connected to
+// it.
+func (s *WorkerReconcilerService) GetSnippetForWorker(
+	ctx context.Context,
+	req *connect.Request[leapmuxv1.GetSnippetForWorkerRequest],
+) (*connect.Response[leapmuxv1.GetSnippetForWorkerResponse], error) {
+	w, err := auth.AuthenticateWorkerBearer(ctx, s.store, req.Header().Get("Authorization"))
+	if err != nil {
+		return nil, connect.NewError(connect.CodeUnauthenticated, err)
+	}
+	ch, ok := s.channels.GetChannelInfo(req.Msg.GetChannelId())
+	if !ok || ch.WorkerID != w.ID {
+		return nil, connect.NewError(connect.CodeNotFound, errors.New("channel not found"))
+	}
+	userID, ok := userid.New(ch.UserID)
+	if !ok {
+		return nil, connect.NewError(connect.CodeNotFound, errors.New("channel not found"))
+	}
+	sn, err := s.store.Snippets().GetByName(ctx, store.GetSnippetByNameParams{UserID: userID, Name: req.Msg.GetName()})
+	if errors.Is(err, store.ErrNotFound) {
+		return nil, connect.NewError(connect.CodeNotFound, errSnippetNotFound)
+	}
+	if err != nil {
+		return nil, connect.NewError(connect.CodeInternal, fmt.Errorf("get snippet: %w", err))
+	}
+	return connect.NewResponse(&leapmuxv1.GetSnippetForWorkerResponse{Snippet: snippetToProto(sn)}), nil
+}
+
+// GetModelCredentialsForWorker returns, decrypted, the model credentials
+// serving a workspace of the user behind the named channel. As with
+// GetSnippetForWorker, a channel the worker does not hold is NotFound,
+// and so is a workspace that user does not own.
+func (s *WorkerReconcilerService) GetModelCredentialsForWorker(
+	ctx context.Context,
+	req *connect.Request[leapmuxv1.GetModelCredentialsForWorkerRequest],
+) (*connect.Response[leapmuxv1.GetModelCredentialsForWorkerResponse], error) {
+	w, err := auth.AuthenticateWorkerBearer(ctx, s.store, req.Header().Get("Authorization"))
+	if err != nil {
+		return nil, connect.NewError(connect.CodeUnauthenticated, err)
+	}
+	ch, ok := s.channels.GetChannelInfo(req.Msg.GetChannelId())
+	if !ok || ch.WorkerID != w.ID {
+		return nil, connect.NewError(connect.CodeNotFound, errors.New("channel not found"))
+	}
+	userID, ok := userid.New(ch.UserID)
+	if !ok {
+		return nil, connect.NewError(connect.CodeNotFound, errors.New("channel not found"))
+	}
+	ws, err := loadOwnedWorkspaceOr403(ctx, s.store, req.Msg.GetWorkspaceId(), userID, "workspace not found")
+	if connect.CodeOf(err) == connect.CodePermissionDenied {
+		return nil, connect.NewError(connect.CodeNotFound, errors.New("workspace not found"))
+	}
+	if err != nil {
+		return nil, err
+	}
+	creds, err := s.store.ModelCredentials().ListByOrg(ctx, ws.OrgID)
+	if err != nil {
+		return nil, connect.NewError(connect.CodeInternal, fmt.Errorf("list model credentials: %w", err))
+	}
+	creds = workspaceModelCredentials(creds, ws.ID)
+	if len(creds) > 0 && s.keystore == nil {
+		return nil, connect.NewError(connect.CodeUnimplemented, errors.New("model credentials are not available on this hub"))
+	}
+	out := make([]*leapmuxv1.ModelCredentialSecret, 0, len(creds))
+	for _, c := range creds {
+		secret, err := s.keystore.Decrypt(c.Secret, keystore.ModelCredentialAAD(c.ID))
+		if err != nil {
+			return nil, connect.NewError(connect.CodeInternal, fmt.Errorf("decrypt model credential: %w", err))
+		}
+		out = append(out, &leapmuxv1.ModelCredentialSecret{Id: c.ID, Name: c.Name, Kind: c.Kind, Secret: string(secret)})
+	}
+	return connect.NewResponse(&leapmuxv1.GetModelCredentialsForWorkerResponse{Credentials: out}), nil
+}
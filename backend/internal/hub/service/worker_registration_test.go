@@ -27,6 +27,7 @@ import (
 	"github.com/leapmux/leapmux/internal/hub/store"
 	hubtestutil "github.com/leapmux/leapmux/internal/hub/testutil"
 	"github.com/leapmux/leapmux/internal/hub/workermgr"
+	"github.com/leapmux/leapmux/internal/protocol"
 	"github.com/leapmux/leapmux/locallisten"
 	"github.com/leapmux/leapmux/locallisten/locallistentest"
 )
@@ -616,22 +617,10 @@ func TestRegister_OverUnixSocket_StillRequiresValidKey(t *testing.T) {
 	})
 }
 
-// The Hub must send WorkerIdentity as the FIRST message on every Connect stream.
-//
-// It is the worker's only source for its own owner: requireWorkerOwner gates every
-// machine-scoped family (file, git, sysinfo, tunnel) on it, and the worker keeps no
-// local copy -- a cached copy is what previously went missing and left the worker
-// permanently denying its own legitimate user. Identity must also PRECEDE the
-// connection being published to the worker manager, so it cannot be overtaken by a
-// frontend-driven ChannelOpen on the same stream; asserting it is the first frame the
-// worker receives is how that ordering stays true.
-func TestConnect_SendsWorkerIdentityFirst(t *testing.T) {
-	if runtime.GOOS == "windows" {
-		t.Skip("unix sockets are not available on Windows")
-	}
-	env := setupRegKeyEnv(t)
-
-	// Register a worker so it has an auth token and a recorded owner.
+// connectableWorker registers a worker through a registration key, returning
+// its stored row and the admin session that owns it.
+func connectableWorker(t *testing.T, env *regKeyEnv) (*store.Worker, string) {
+	t.Helper()
 	token := env.login(t, "admin", "admin123")
 	createResp, err := env.mgmtClient.CreateRegistrationKey(context.Background(),
 		authedReq(&leapmuxv1.CreateRegistrationKeyRequest{}, token))
@@ -642,9 +631,13 @@ func TestConnect_SendsWorkerIdentityFirst(t *testing.T) {
 	worker, err := env.store.Workers().GetByID(context.Background(), regResp.Msg.GetWorkerId())
 	require.NoError(t, err)
 	require.NotEmpty(t, worker.RegisteredBy)
+	return worker, token
+}
 
-	// Connect needs gRPC-over-h2c (a bidi stream), which httptest's HTTP/1 server
-	// cannot serve -- mirror the unix-socket harness above.
+// h2cConnectorClient serves env over a unix socket. Connect needs
+// gRPC-over-h2c (a bidi stream), which httptest's HTTP/1 server cannot serve.
+func h2cConnectorClient(t *testing.T, env *regKeyEnv) leapmuxv1connect.WorkerConnectorServiceClient {
+	t.Helper()
 	socketURL := locallistentest.UniqueListenURL(t, "hub-identity")
 	ln, err := locallisten.Listen(socketURL)
 	require.NoError(t, err)
@@ -660,8 +653,26 @@ func TestConnect_SendsWorkerIdentityFirst(t *testing.T) {
 	require.NoError(t, err)
 	httpClient := &http.Client{Transport: locallisten.NewLocalH2CTransport(dial)}
 	t.Cleanup(httpClient.CloseIdleConnections)
-	connectorClient := leapmuxv1connect.NewWorkerConnectorServiceClient(
+	return leapmuxv1connect.NewWorkerConnectorServiceClient(
 		httpClient, "http://localhost", connect.WithGRPC())
+}
+
+// The Hub must send WorkerIdentity as the FIRST message on every Connect stream.
+//
+// It is the worker's only source for its own owner: requireWorkerOwner gates every
+// machine-scoped family (file, git, sysinfo, tunnel) on it, and the worker keeps no
+// local copy -- a cached copy is what previously went missing and left the worker
+// permanently denying its own legitimate user. Identity must also PRECEDE the
+// connection being published to the worker manager, so it cannot be overtaken by a
+// frontend-driven ChannelOpen on the same stream; asserting it is the first frame the
+// worker receives is how that ordering stays true.
+func TestConnect_SendsWorkerIdentityFirst(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("unix sockets are not available on Windows")
+	}
+	env := setupRegKeyEnv(t)
+	worker, _ := connectableWorker(t, env)
+	connectorClient := h2cConnectorClient(t, env)
 
 	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
 	defer cancel()
@@ -678,5 +689,165 @@ func TestConnect_SendsWorkerIdentityFirst(t *testing.T) {
 		"the FIRST message on the stream must be WorkerIdentity, not the heartbeat echo")
 	assert.Equal(t, worker.RegisteredBy, first.GetWorkerIdentity().GetRegisteredBy(),
 		"the Hub must name the worker's recorded owner")
+	assert.Equal(t, protocol.Current, first.GetWorkerIdentity().GetProtocolVersion())
+	owner, err := env.store.Users().GetByID(ctx, worker.RegisteredBy)
+	require.NoError(t, err)
+	assert.Equal(t, owner.OrgID, first.GetWorkerIdentity().GetOrgId(), "the Hub must name the owner's org")
+	require.NoError(t, stream.CloseRequest())
+}
+
+// A worker reconnecting under an emergency stop learns of it from the
+// greeting, before any channel can reach its agents.
+func TestConnect_GreetsWithTheEmergencyStop(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("unix sockets are not available on Windows")
+	}
+	env := setupRegKeyEnv(t)
+	worker, _ := connectableWorker(t, env)
+	connectorClient := h2cConnectorClient(t, env)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	owner, err := env.store.Users().GetByID(ctx, worker.RegisteredBy)
+	require.NoError(t, err)
+	_, err = env.store.EmergencyStops().Put(ctx, store.PutEmergencyStopParams{
+		OrgID:     owner.OrgID,
+		WorkerID:  worker.ID,
+		Reason:    "runaway spend",
+		StoppedBy: "admin",
+	})
+	require.NoError(t, err)
+
+	stream := connectorClient.Connect(ctx)
+	stream.RequestHeader().Set("Authorization", "Bearer "+worker.AuthToken)
+	require.NoError(t, stream.Send(&leapmuxv1.ConnectRequest{
+		Payload: &leapmuxv1.ConnectRequest_Heartbeat{Heartbeat: &leapmuxv1.Heartbeat{}},
+	}))
+	first, err := stream.Receive()
+	require.NoError(t, err)
+	stop := first.GetWorkerIdentity().GetEmergencyStop()
+	assert.True(t, stop.GetActive())
+	assert.Equal(t, "runaway spend", stop.GetReason())
+	require.NoError(t, stream.CloseRequest())
+}
+
+func TestConnect_GreetsWithTheAccessPolicy(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("unix sockets are not available on Windows")
+	}
+	env := setupRegKeyEnv(t)
+	worker, _ := connectableWorker(t, env)
+	connectorClient := h2cConnectorClient(t, env)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	owner, err := env.store.Users().GetByID(ctx, worker.RegisteredBy)
+	require.NoError(t, err)
+	require.NoError(t, env.store.AccessPolicies().Put(ctx, store.PutAccessPolicyParams{
+		OrgID:     owner.OrgID,
+		Rules:     `[{"name":"no-rm","condition":"tool == \"Bash\"","effect":2}]`,
+		UpdatedBy: owner.ID,
+	}))
+
+	stream := connectorClient.Connect(ctx)
+	stream.RequestHeader().Set("Authorization", "Bearer "+worker.AuthToken)
+	require.NoError(t, stream.Send(&leapmuxv1.ConnectRequest{
+		Payload: &leapmuxv1.ConnectRequest_Heartbeat{Heartbeat: &leapmuxv1.Heartbeat{}},
+	}))
+	first, err := stream.Receive()
+	require.NoError(t, err)
+	rules := first.GetWorkerIdentity().GetAccessPolicy().GetRules()
+	require.Len(t, rules, 1)
+	assert.Equal(t, "no-rm", rules[0].GetName())
+	require.NoError(t, stream.CloseRequest())
+}
+
+// A worker older than the hub's minimum protocol is refused with a clear error
+// and shows up in ListWorkers as needing an upgrade, rather than connecting and
+// silently dropping payloads it does not know.
+func TestConnect_RefusesWorkerBelowMinimumProtocol(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("unix sockets are not available on Windows")
+	}
+	oldMin := protocol.MinWorker
+	protocol.MinWorker = protocol.Current
+	t.Cleanup(func() { protocol.MinWorker = oldMin })
+
+	env := setupRegKeyEnv(t)
+	worker, token := connectableWorker(t, env)
+	connectorClient := h2cConnectorClient(t, env)
+
+	connectOnce := func(version string) error {
+		ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+		defer cancel()
+		stream := connectorClient.Connect(ctx)
+		stream.RequestHeader().Set("Authorization", "Bearer "+worker.AuthToken)
+		if version != "" {
+			stream.RequestHeader().Set(protocol.Header, version)
+		}
+		if err := stream.Send(&leapmuxv1.ConnectRequest{
+			Payload: &leapmuxv1.ConnectRequest_Heartbeat{Heartbeat: &leapmuxv1.Heartbeat{}},
+		}); err != nil {
+			return err
+		}
+		_, err := stream.Receive()
+		_ = stream.CloseRequest()
+		return err
+	}
+	upgradeRequired := func() bool {
+		resp, err := env.mgmtClient.ListWorkers(context.Background(),
+			authedReq(&leapmuxv1.ListWorkersRequest{}, token))
+		require.NoError(t, err)
+		require.Len(t, resp.Msg.GetWorkers(), 1)
+		return resp.Msg.GetWorkers()[0].GetUpgradeRequired()
+	}
+
+	// A worker that predates the exchange sends no version at all.
+	err := connectOnce("")
+	require.Error(t, err)
+	assert.Equal(t, connect.CodeFailedPrecondition, connect.CodeOf(err))
+	assert.Contains(t, err.Error(), "upgrade the worker")
+	assert.True(t, upgradeRequired())
+
+	require.NoError(t, connectOnce(protocol.Format(protocol.Current)))
+	assert.False(t, upgradeRequired(), "a compatible connect clears the flag")
+}
+
+// The connection report a worker sends with its first heartbeat is what the
+// worker info panel shows, so ListWorkers must carry it while the worker is
+// connected.
+func TestListWorkers_ReportsConnectionStats(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("unix sockets are not available on Windows")
+	}
+	env := setupRegKeyEnv(t)
+	worker, token := connectableWorker(t, env)
+	connectorClient := h2cConnectorClient(t, env)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	stream := connectorClient.Connect(ctx)
+	stream.RequestHeader().Set("Authorization", "Bearer "+worker.AuthToken)
+	require.NoError(t, stream.Send(&leapmuxv1.ConnectRequest{
+		Payload: &leapmuxv1.ConnectRequest_Heartbeat{Heartbeat: &leapmuxv1.Heartbeat{
+			ConnectionStats: &leapmuxv1.WorkerConnectionStats{Connects: 3, Failovers: 1, HubUrl: "https://hub-2.example"},
+		}},
+	}))
+	_, err := stream.Receive()
+	require.NoError(t, err)
+
+	listStats := func() *leapmuxv1.WorkerConnectionStats {
+		resp, err := env.mgmtClient.ListWorkers(context.Background(),
+			authedReq(&leapmuxv1.ListWorkersRequest{}, token))
+		require.NoError(t, err)
+		require.Len(t, resp.Msg.GetWorkers(), 1)
+		return resp.Msg.GetWorkers()[0].GetConnectionStats()
+	}
+	// The heartbeat is handled after the greeting, so poll for it.
+	require.Eventually(t, func() bool { return listStats() != nil }, 5*time.Second, 10*time.Millisecond)
+	stats := listStats()
+	assert.Equal(t, uint32(3), stats.GetConnects())
+	assert.Equal(t, uint32(1), stats.GetFailovers())
+	assert.Equal(t, "https://hub-2.example", stats.GetHubUrl())
 	require.NoError(t, stream.CloseRequest())
 }
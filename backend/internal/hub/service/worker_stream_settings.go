@@ -0,0 +1,144 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+
+	"connectrpc.com/connect"
+	leapmuxv1 "github.com/leapmux/leapmux/generated/proto/leapmux/v1"
+	"github.com/leapmux/leapmux/internal/hub/auth"
+	"github.com/leapmux/leapmux/internal/hub/store"
+	"github.com/leapmux/leapmux/internal/hub/workermgr"
+)
+
+// minUploadBytesPerSecond is the lowest non-zero upload cap. Below it a single
+// full-size channel frame holds the stream for seconds, long enough to trip
+// client RPC timeouts.
+const minUploadBytesPerSecond = 16 << 10
+
+// storedWorkerStreamSettings is the "workerStream" entry of the
+// user_preferences JSON blob. Orgs are personal, so the org's settings live
+// with its one member's preferences.
+type storedWorkerStreamSettings struct {
+	UploadBytesPerSecond uint64 `json:"uploadBytesPerSecond,omitempty"`
+	CompressOutput       bool   `json:"compressOutput,omitempty"`
+}
+
+// validateWorkerStreamSettings checks s and returns its stored form.
+func validateWorkerStreamSettings(s *leapmuxv1.WorkerStreamSettings) (*storedWorkerStreamSettings, error) {
+	rate := s.GetUploadBytesPerSecond()
+	if rate != 0 && rate < minUploadBytesPerSecond {
+		return nil, fmt.Errorf("upload_bytes_per_second must be 0 (unlimited) or at least %d", minUploadBytesPerSecond)
+	}
+	return &storedWorkerStreamSettings{
+		UploadBytesPerSecond: rate,
+		CompressOutput:       s.GetCompressOutput(),
+	}, nil
+}
+
+// workerStreamSettingsToProto converts the stored form; nil is the default of
+// unlimited and uncompressed.
+func workerStreamSettingsToProto(s *storedWorkerStreamSettings) *leapmuxv1.WorkerStreamSettings {
+	if s == nil {
+		return &leapmuxv1.WorkerStreamSettings{}
+	}
+	return &leapmuxv1.WorkerStreamSettings{
+		UploadBytesPerSecond: s.UploadBytesPerSecond,
+		CompressOutput:       s.CompressOutput,
+	}
+}
+
+// loadWorkerStreamSettings returns the stream settings for the workers userID
+// registered.
+func loadWorkerStreamSettings(ctx context.Context, st store.Store, userID string) (*leapmuxv1.WorkerStreamSettings, error) {
+	sp, err := loadStoredPreferences(ctx, st, userID)
+	if err != nil {
+		return nil, err
+	}
+	return workerStreamSettingsToProto(sp.WorkerStream), nil
+}
+
+func (s *WorkerManagementService) GetWorkerStreamSettings(
+	ctx context.Context,
+	_ *connect.Request[leapmuxv1.GetWorkerStreamSettingsRequest],
+) (*connect.Response[leapmuxv1.GetWorkerStreamSettingsResponse], error) {
+	user, err := auth.MustGetUser(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	settings, err := loadWorkerStreamSettings(ctx, s.store, user.ID.String())
+	if err != nil {
+		return nil, connect.NewError(connect.CodeInternal, err)
+	}
+	return connect.NewResponse(&leapmuxv1.GetWorkerStreamSettingsResponse{Settings: settings}), nil
+}
+
+// UpdateWorkerStreamSettings replaces the org's worker stream settings and
+// pushes them to the org's workers connected to this Hub. Workers connected
+// elsewhere, or offline, pick them up from the greeting on their next connect.
+func (s *WorkerManagementService) UpdateWorkerStreamSettings(
+	ctx context.Context,
+	req *connect.Request[leapmuxv1.UpdateWorkerStreamSettingsRequest],
+) (*connect.Response[leapmuxv1.UpdateWorkerStreamSettingsResponse], error) {
+	user, err := auth.MustGetUser(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	stored, err := validateWorkerStreamSettings(req.Msg.GetSettings())
+	if err != nil {
+		return nil, connect.NewError(connect.CodeInvalidArgument, err)
+	}
+
+	if _, err := updateStoredPreferences(ctx, s.store, user.ID.String(), func(sp *storedPreferences) error {
+		sp.WorkerStream = stored
+		return nil
+	}); err != nil {
+		return nil, err
+	}
+
+	settings := workerStreamSettingsToProto(stored)
+	pushToUserWorkers(ctx, s.store, s.workerMgr, user, &leapmuxv1.ConnectResponse{
+		Payload: &leapmuxv1.ConnectResponse_StreamSettings{StreamSettings: settings},
+	}, "stream settings")
+	return connect.NewResponse(&leapmuxv1.UpdateWorkerStreamSettingsResponse{Settings: settings}), nil
+}
+
+// pushToUserWorkers sends msg to every worker user registered that is
+// connected to this Hub. Each connection is fetched through ConnForUser, so
+// the push is gated by the same reach check as any other user-initiated
+// send. Failures are logged, not returned: what is pushed is org settings
+// that are already stored, and a worker that misses the push gets them on
+// reconnect. what names the settings in those logs.
+func pushToUserWorkers(ctx context.Context, st store.Store, workerMgr *workermgr.Manager, user *auth.UserInfo, msg *leapmuxv1.ConnectResponse, what string) {
+	cursor := ""
+	for {
+		page, err := st.Workers().ListByUserID(ctx, store.ListWorkersByUserIDParams{
+			RegisteredBy: user.ID,
+			PageParams:   store.PageParams{Cursor: cursor, Limit: 100},
+		})
+		if err != nil {
+			slog.Warn("failed to list workers for settings push", "what", what, "user_id", user.ID, "error", err)
+			return
+		}
+		for i := range page.Rows {
+			conn, err := workerMgr.ConnForUser(ctx, user, page.Rows[i].ID)
+			if err != nil {
+				slog.Warn("worker settings push denied", "what", what, "worker_id", page.Rows[i].ID, "error", err)
+				continue
+			}
+			if conn == nil {
+				continue
+			}
+			if err := conn.Send(msg); err != nil {
+				slog.Warn("failed to push worker settings", "what", what, "worker_id", page.Rows[i].ID, "error", err)
+			}
+		}
+		if !page.HasMore() {
+			return
+		}
+		cursor = page.NextCursor
+	}
+}
@@ -0,0 +1,99 @@
+package service_test
+
+import (
+	"context"
+	"testing"
+
+	"connectrpc.com/connect"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	leapmuxv1 "github.com/leapmux/leapmux/generated/proto/leapmux/v1"
+	"github.com/leapmux/leapmux/internal/hub/auth"
+	"github.com/leapmux/leapmux/internal/hub/config"
+	"github.com/leapmux/leapmux/internal/hub/mail"
+	"github.com/leapmux/leapmux/internal/hub/service"
+	"github.com/leapmux/leapmux/internal/hub/store"
+	"github.com/leapmux/leapmux/internal/hub/testutil"
+	"github.com/leapmux/leapmux/internal/hub/workermgr"
+	"github.com/leapmux/leapmux/internal/util/userid"
+)
+
+func TestWorkerStreamSettings_RoundTripAndPush(t *testing.T) {
+	st := testutil.OpenTestStore(t)
+	uid := userid.MustNew(testutil.CreateTestUser(t, st, "streamer", "password123"))
+	ctx := auth.WithUser(context.Background(), &auth.UserInfo{ID: uid})
+
+	for _, workerID := range []string{"w-online", "w-offline"} {
+		require.NoError(t, st.Workers().Create(ctx, store.CreateWorkerParams{
+			ID:              workerID,
+			AuthToken:       "token-" + workerID,
+			RegisteredBy:    uid,
+			PublicKey:       []byte("test-x25519-key-32-bytes-padding"),
+			MlkemPublicKey:  []byte("mlkem"),
+			SlhdsaPublicKey: []byte("slhdsa"),
+		}))
+	}
+	mgr := workermgr.New(service.NewWorkerReachAuthorizer(st))
+	pushed := make(chan *leapmuxv1.ConnectResponse, 4)
+	_, err := mgr.Register(&workermgr.Conn{
+		WorkerID: "w-online",
+		SendFn: func(msg *leapmuxv1.ConnectResponse) error {
+			pushed <- msg
+			return nil
+		},
+	})
+	require.NoError(t, err)
+	svc := service.NewWorkerManagementService(st, mgr, nil, nil, nil, mail.Renderer{}, &config.Config{}, nil)
+
+	got, err := svc.GetWorkerStreamSettings(ctx, connect.NewRequest(&leapmuxv1.GetWorkerStreamSettingsRequest{}))
+	require.NoError(t, err)
+	assert.Zero(t, got.Msg.GetSettings().GetUploadBytesPerSecond(), "unset means unlimited")
+	assert.False(t, got.Msg.GetSettings().GetCompressOutput())
+
+	want := &leapmuxv1.WorkerStreamSettings{UploadBytesPerSecond: 256 << 10, CompressOutput: true}
+	_, err = svc.UpdateWorkerStreamSettings(ctx, connect.NewRequest(&leapmuxv1.UpdateWorkerStreamSettingsRequest{Settings: want}))
+	require.NoError(t, err)
+
+	got, err = svc.GetWorkerStreamSettings(ctx, connect.NewRequest(&leapmuxv1.GetWorkerStreamSettingsRequest{}))
+	require.NoError(t, err)
+	assert.EqualValues(t, 256<<10, got.Msg.GetSettings().GetUploadBytesPerSecond())
+	assert.True(t, got.Msg.GetSettings().GetCompressOutput())
+
+	require.Len(t, pushed, 1, "only the connected worker is pushed to")
+	msg := <-pushed
+	assert.EqualValues(t, 256<<10, msg.GetStreamSettings().GetUploadBytesPerSecond())
+	assert.True(t, msg.GetStreamSettings().GetCompressOutput())
+}
+
+func TestWorkerStreamSettings_RejectsTinyRate(t *testing.T) {
+	st := testutil.OpenTestStore(t)
+	uid := userid.MustNew(testutil.CreateTestUser(t, st, "tiny", "password123"))
+	ctx := auth.WithUser(context.Background(), &auth.UserInfo{ID: uid})
+	svc := service.NewWorkerManagementService(st, workermgr.New(workermgr.DenyAllReach()), nil, nil, nil, mail.Renderer{}, &config.Config{}, nil)
+
+	_, err := svc.UpdateWorkerStreamSettings(ctx, connect.NewRequest(&leapmuxv1.UpdateWorkerStreamSettingsRequest{
+		Settings: &leapmuxv1.WorkerStreamSettings{UploadBytesPerSecond: 100},
+	}))
+	assert.Equal(t, connect.CodeInvalidArgument, connect.CodeOf(err))
+}
+
+func TestUpdatePreferences_KeepsWorkerStreamSettings(t *testing.T) {
+	st := testutil.OpenTestStore(t)
+	uid := userid.MustNew(testutil.CreateTestUser(t, st, "keeper", "password123"))
+	ctx := auth.WithUser(context.Background(), &auth.UserInfo{ID: uid})
+	mgmt := service.NewWorkerManagementService(st, workermgr.New(workermgr.DenyAllReach()), nil, nil, nil, mail.Renderer{}, &config.Config{}, nil)
+
+	_, err := mgmt.UpdateWorkerStreamSettings(ctx, connect.NewRequest(&leapmuxv1.UpdateWorkerStreamSettingsRequest{
+		Settings: &leapmuxv1.WorkerStreamSettings{CompressOutput: true},
+	}))
+	require.NoError(t, err)
+
+	users := service.NewUserService(st, &config.Config{}, auth.NewCredentialLifecycleEffects(nil, nil, nil), mail.NewStubSender(), mail.Renderer{})
+	_, err = users.UpdatePreferences(ctx, connect.NewRequest(&leapmuxv1.UpdatePreferencesRequest{Theme: "dark"}))
+	require.NoError(t, err)
+
+	got, err := mgmt.GetWorkerStreamSettings(ctx, connect.NewRequest(&leapmuxv1.GetWorkerStreamSettingsRequest{}))
+	require.NoError(t, err)
+	assert.True(t, got.Msg.GetSettings().GetCompressOutput())
+}
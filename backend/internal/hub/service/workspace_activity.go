@@ -0,0 +1,105 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"connectrpc.com/connect"
+
+	leapmuxv1 "github.com/leapmux/leapmux/generated/proto/leapmux/v1"
+	"github.com/leapmux/leapmux/internal/hub/store"
+	"github.com/leapmux/leapmux/internal/hub/workermgr"
+	"github.com/leapmux/leapmux/internal/util/timefmt"
+	"github.com/leapmux/leapmux/internal/util/userid"
+)
+
+// maxReportedActivityWorkspaces and maxReportedUnreadUsers bound the
+// activity the Hub keeps per connected worker.
+const (
+	maxReportedActivityWorkspaces = 4096
+	maxReportedUnreadUsers        = 256
+)
+
+// reportedWorkspaceActivity trims a worker's activity report to what the
+// Hub keeps.
+func reportedWorkspaceActivity(r *leapmuxv1.WorkspaceActivityReport) *leapmuxv1.WorkspaceActivityReport {
+	if len(r.Workspaces) > maxReportedActivityWorkspaces {
+		r.Workspaces = r.Workspaces[:maxReportedActivityWorkspaces]
+	}
+	for _, a := range r.Workspaces {
+		if len(a.UserUnreadCounts) > maxReportedUnreadUsers {
+			a.UserUnreadCounts = a.UserUnreadCounts[:maxReportedUnreadUsers]
+		}
+	}
+	return r
+}
+
+// ownedActivityWorkers narrows each workspace's agent workers, in place, to
+// the ones the store says userID registered. Worker ids on tab rows come
+// from client-written CRDT ops, so they are never probed as given.
+func ownedActivityWorkers(ctx context.Context, st store.Store, userID userid.UserID, byWorkspace map[string]map[string]bool) error {
+	owned := map[string]bool{}
+	for _, workerIDs := range byWorkspace {
+		for workerID := range workerIDs {
+			if _, checked := owned[workerID]; checked {
+				continue
+			}
+			_, err := st.Workers().GetOwned(ctx, store.GetOwnedWorkerParams{WorkerID: workerID, UserID: userID})
+			switch {
+			case err == nil:
+				owned[workerID] = true
+			case errors.Is(err, store.ErrNotFound):
+				owned[workerID] = false
+			default:
+				return connect.NewError(connect.CodeInternal, fmt.Errorf("load worker: %w", err))
+			}
+		}
+	}
+	for _, workerIDs := range byWorkspace {
+		for workerID := range workerIDs {
+			if !owned[workerID] {
+				delete(workerIDs, workerID)
+			}
+		}
+	}
+	return nil
+}
+
+// annotateWorkspaceActivity fills w's activity fields, for userID, from the
+// reports of the online workers among workerIDs, the ones hosting its agent
+// tabs, which ownedActivityWorkers has narrowed to userID's own. Only those
+// workers are asked, so a worker cannot speak for a workspace it hosts
+// nothing of.
+func annotateWorkspaceActivity(mgr *workermgr.Manager, w *leapmuxv1.Workspace, workerIDs map[string]bool, userID userid.UserID) {
+	if mgr == nil {
+		return
+	}
+	var last time.Time
+	for workerID := range workerIDs {
+		a := mgr.WorkspaceActivityForTrustedPath(workerID, w.GetId())
+		if a == nil {
+			continue
+		}
+		w.AwaitingApprovalCount += a.GetAwaitingApprovalCount()
+		w.UnreadCount += unreadCountFor(a, userID)
+		if t, err := time.Parse(time.RFC3339Nano, a.GetLastMessageAt()); err == nil && t.After(last) {
+			last = t
+		}
+	}
+	if !last.IsZero() {
+		w.LastMessageAt = timefmt.Format(last)
+	}
+}
+
+// unreadCountFor returns userID's unread count in a: their own when they
+// have marked one of its agents read, else every counted message.
+func unreadCountFor(a *leapmuxv1.WorkspaceActivity, userID userid.UserID) int32 {
+	for _, u := range a.GetUserUnreadCounts() {
+		if u.GetUserId() == userID.String() {
+			return max(0, u.GetUnreadCount())
+		}
+	}
+	return max(0, a.GetMessageCount())
+}
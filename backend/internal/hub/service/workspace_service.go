@@ -4,14 +4,21 @@ import (
 	"context"
 	"errors"
 	"fmt"
+	"strings"
 
 	"connectrpc.com/connect"
+	"google.golang.org/protobuf/proto"
 
 	leapmuxv1 "github.com/leapmux/leapmux/generated/proto/leapmux/v1"
+	"github.com/leapmux/leapmux/internal/errcode"
 	"github.com/leapmux/leapmux/internal/hub/auth"
 	"github.com/leapmux/leapmux/internal/hub/crdt"
+	"github.com/leapmux/leapmux/internal/hub/keystore"
 	"github.com/leapmux/leapmux/internal/hub/store"
+	"github.com/leapmux/leapmux/internal/hub/workermgr"
+	"github.com/leapmux/leapmux/internal/plugin"
 	"github.com/leapmux/leapmux/internal/util/id"
+	"github.com/leapmux/leapmux/internal/util/idempotency"
 	"github.com/leapmux/leapmux/internal/util/nilcheck"
 	"github.com/leapmux/leapmux/internal/util/userid"
 	"github.com/leapmux/leapmux/util/validate"
@@ -25,6 +32,18 @@ type WorkspaceService struct {
 	store         store.Store
 	registry      *crdt.Registry
 	channelCloser WorkspaceChannelCloser
+	// workerMgr and pending reach workers for repo checkouts; nil leaves
+	// CreateWorkspace unable to take a repo_id, and ListWorkspaces without
+	// activity fields. keystore decrypts the repo's git credential for the
+	// worker.
+	workerMgr *workermgr.Manager
+	pending   *workermgr.PendingRequests
+	keystore  *keystore.Keystore
+	// createKeys replays CreateWorkspace responses for retried
+	// idempotency keys.
+	createKeys *idempotency.Keys[*leapmuxv1.CreateWorkspaceResponse]
+	// plugins is told about each workspace created; nil tells no one.
+	plugins *plugin.Host
 }
 
 // WorkspaceChannelCloser removes channels whose worker-side workspace
@@ -48,9 +67,35 @@ func NewWorkspaceService(
 		store:         st,
 		registry:      registry,
 		channelCloser: channelCloser,
+		createKeys:    idempotency.New[*leapmuxv1.CreateWorkspaceResponse](idempotency.DefaultWindow),
 	}
 }
 
+// WithRepoCheckouts lets CreateWorkspace check a registered repo out on a
+// worker. ks may be nil, which fails checkouts of repos that name a git
+// credential. Returns the receiver for chaining.
+func (s *WorkspaceService) WithRepoCheckouts(workerMgr *workermgr.Manager, pending *workermgr.PendingRequests, ks *keystore.Keystore) *WorkspaceService {
+	s.workerMgr = workerMgr
+	s.pending = pending
+	s.keystore = ks
+	return s
+}
+
+// WithPlugins sends the exec plugins in h a workspace_created event for
+// each workspace created.
+func (s *WorkspaceService) WithPlugins(h *plugin.Host) *WorkspaceService {
+	s.plugins = h
+	return s
+}
+
+// workspaceCreatedEvent is the data of a workspace_created plugin event.
+type workspaceCreatedEvent struct {
+	WorkspaceID string `json:"workspace_id"`
+	OrgID       string `json:"org_id"`
+	Title       string `json:"title"`
+	CreatedBy   string `json:"created_by"`
+}
+
 // workspaceToProto converts a hub DB workspace row to the proto Workspace message.
 func workspaceToProto(w *store.Workspace) *leapmuxv1.Workspace {
 	return &leapmuxv1.Workspace{
@@ -79,7 +124,7 @@ func loadWorkspaceOr404(ctx context.Context, st store.Store, workspaceID string)
 	ws, err := st.Workspaces().GetByID(ctx, workspaceID)
 	if err != nil {
 		if errors.Is(err, store.ErrNotFound) {
-			return nil, connect.NewError(connect.CodeNotFound, fmt.Errorf("workspace not found"))
+			return nil, errcode.New(connect.CodeNotFound, errcode.WorkspaceNotFound, errors.New("workspace not found"))
 		}
 		return nil, connect.NewError(connect.CodeInternal, err)
 	}
@@ -148,7 +193,135 @@ func (s *WorkspaceService) CreateWorkspace(
 		return nil, connect.NewError(connect.CodeInvalidArgument, fmt.Errorf("title: %w", err))
 	}
 
+	// A retry of a create that already ran (the client lost the response)
+	// gets the original workspace rather than a second one with its own
+	// checkout.
+	if key := req.Msg.GetIdempotencyKey(); key != "" {
+		if len(key) > idempotency.MaxKeyLen {
+			return nil, connect.NewError(connect.CodeInvalidArgument, fmt.Errorf("idempotency_key exceeds %d bytes", idempotency.MaxKeyLen))
+		}
+		claimKey := user.ID.String() + "\x00" + key
+		fingerprint := proto.Clone(req.Msg).(*leapmuxv1.CreateWorkspaceRequest)
+		fingerprint.IdempotencyKey = ""
+		original, owned, err := s.createKeys.Claim(ctx, claimKey, idempotency.Fingerprint(fingerprint))
+		if errors.Is(err, idempotency.ErrKeyReused) {
+			return nil, connect.NewError(connect.CodeInvalidArgument, err)
+		}
+		if err != nil {
+			return nil, err
+		}
+		if !owned {
+			return connect.NewResponse(proto.Clone(original).(*leapmuxv1.CreateWorkspaceResponse)), nil
+		}
+		resp, err := s.createWorkspaceWithRepo(ctx, user, orgID, title, req.Msg)
+		if err != nil {
+			s.createKeys.Release(claimKey)
+			return nil, err
+		}
+		s.createKeys.Complete(claimKey, resp)
+		return connect.NewResponse(proto.Clone(resp).(*leapmuxv1.CreateWorkspaceResponse)), nil
+	}
+
+	resp, err := s.createWorkspaceWithRepo(ctx, user, orgID, title, req.Msg)
+	if err != nil {
+		return nil, err
+	}
+	return connect.NewResponse(resp), nil
+}
+
+// createWorkspaceWithRepo creates the workspace CreateWorkspace asked for,
+// checking its repository out first when the request names one.
+func (s *WorkspaceService) createWorkspaceWithRepo(
+	ctx context.Context,
+	user *auth.UserInfo,
+	orgID, title string,
+	req *leapmuxv1.CreateWorkspaceRequest,
+) (*leapmuxv1.CreateWorkspaceResponse, error) {
 	wsID := id.Generate()
+	resp := &leapmuxv1.CreateWorkspaceResponse{WorkspaceId: wsID}
+	// The checkout comes first so a worker that cannot start one fails the
+	// call before there is a workspace to clean up.
+	if repoID := req.GetRepoId(); repoID != "" {
+		workerID, dir, err := s.prepareWorkspaceRepo(ctx, user, orgID, wsID, repoID, req.GetWorkerId(), req.GetBranch())
+		if err != nil {
+			return nil, err
+		}
+		resp.WorkerId = workerID
+		resp.CheckoutDir = dir
+	}
+
+	if _, err := s.createWorkspace(ctx, user, orgID, wsID, title, nil); err != nil {
+		return nil, err
+	}
+	return resp, nil
+}
+
+// prepareWorkspaceRepo checks repoID out for workspace wsID on workerID,
+// or on a worker picked by the repo's labels, and returns where.
+func (s *WorkspaceService) prepareWorkspaceRepo(
+	ctx context.Context,
+	user *auth.UserInfo,
+	orgID, wsID, repoID, workerID, branch string,
+) (string, string, error) {
+	if s.workerMgr == nil || s.pending == nil {
+		return "", "", connect.NewError(connect.CodeUnimplemented, errors.New("repository checkouts are not available on this hub"))
+	}
+	repo, err := s.store.Repos().Get(ctx, store.GetRepoParams{ID: repoID, OrgID: orgID})
+	if errors.Is(err, store.ErrNotFound) {
+		return "", "", connect.NewError(connect.CodeNotFound, errors.New("repository not found"))
+	}
+	if err != nil {
+		return "", "", connect.NewError(connect.CodeInternal, fmt.Errorf("load repo: %w", err))
+	}
+	branch = strings.TrimSpace(branch)
+	if branch == "" {
+		branch = repo.DefaultBranch
+	} else if err := validateRepoBranch(branch); err != nil {
+		return "", "", connect.NewError(connect.CodeInvalidArgument, fmt.Errorf("branch: %w", err))
+	}
+	credential, err := loadRepoCredential(ctx, s.store, s.keystore, orgID, repo.CredentialRef)
+	if err != nil {
+		return "", "", err
+	}
+	access, err := loadAccessPolicy(ctx, s.store, user.OrgID)
+	if err != nil {
+		return "", "", connect.NewError(connect.CodeInternal, fmt.Errorf("load access policy: %w", err))
+	}
+	picked := workerID == ""
+	if picked {
+		if workerID, err = pickCheckoutWorker(ctx, s.store, s.workerMgr, user, repo.WorkerLabels, access, wsID); err != nil {
+			return "", "", err
+		}
+	}
+	conn, err := checkoutConn(ctx, s.workerMgr, user, workerID)
+	if err != nil {
+		return "", "", err
+	}
+	// pickCheckoutWorker only offers workers the policy allows; a worker
+	// named in the request is checked here, on the labels of the
+	// connection the caller was let reach.
+	if !picked {
+		if d := workerSelectionDecision(access, user, wsID, workerID, conn.Labels()); d.Denied() {
+			return "", "", accessPolicyDenied(d)
+		}
+	}
+	dir, err := prepareRepoCheckout(ctx, s.pending, conn, wsID, branch, repo, credential)
+	if err != nil {
+		return "", "", err
+	}
+	return workerID, dir, nil
+}
+
+// createWorkspace creates workspace wsID owned by user in orgID with a
+// fresh root tile and returns its id. seed, when set, runs in the same
+// transaction after the workspace row exists, so anything it writes lands
+// together with the workspace or not at all.
+func (s *WorkspaceService) createWorkspace(
+	ctx context.Context,
+	user *auth.UserInfo,
+	orgID, wsID, title string,
+	seed func(tx store.Store, wsID string) error,
+) (string, error) {
 	rootID := id.Generate()
 
 	if err := s.runLifecycleMutation(ctx, lifecycleMutation{
@@ -162,6 +335,11 @@ func (s *WorkspaceService) CreateWorkspace(
 			}); err != nil {
 				return "", crdt.LifecyclePayload{}, nil, connect.NewError(connect.CodeInternal, fmt.Errorf("create workspace: %w", err))
 			}
+			if seed != nil {
+				if err := seed(tx, wsID); err != nil {
+					return "", crdt.LifecyclePayload{}, nil, err
+				}
+			}
 			return orgID, crdt.LifecyclePayload{
 				OpType:      crdt.LifecycleOpCreate,
 				WorkspaceID: wsID,
@@ -170,12 +348,15 @@ func (s *WorkspaceService) CreateWorkspace(
 			}, buildSeedRootOps(wsID, rootID, user.ID.String()), nil
 		},
 	}); err != nil {
-		return nil, err
+		return "", err
 	}
-
-	return connect.NewResponse(&leapmuxv1.CreateWorkspaceResponse{
-		WorkspaceId: wsID,
-	}), nil
+	s.plugins.Emit(plugin.HookWorkspaceCreated, workspaceCreatedEvent{
+		WorkspaceID: wsID,
+		OrgID:       orgID,
+		Title:       title,
+		CreatedBy:   user.ID.String(),
+	})
+	return wsID, nil
 }
 
 func (s *WorkspaceService) ListWorkspaces(
@@ -190,11 +371,12 @@ func (s *WorkspaceService) ListWorkspaces(
 	// time (`auth.UserInfo.Credential.WorkspaceScopeID()`). Mirror
 	// ChannelService.OpenChannel's "narrow accessible-workspace
 	// reasoning to this single id" rule on the read side so a leaked
-	// delegation token cannot enumerate the user's full grant set.
+	// delegation token cannot enumerate the user's full grant set. A
+	// public viewer is pinned the same way.
 	// loadWorkspaceForRead returns NotFound for soft-deleted rows and
 	// PermissionDenied for revoked access — both collapse to an
 	// empty list here.
-	if user.Credential.IsDelegation() {
+	if user.Credential.IsWorkspaceScoped() {
 		ws, err := loadWorkspaceForRead(ctx, s.store, user.Credential.WorkspaceScopeID(), user)
 		if err != nil {
 			code := connect.CodeOf(err)
@@ -206,9 +388,7 @@ func (s *WorkspaceService) ListWorkspaces(
 		if reqOrgID := req.Msg.GetOrgId(); reqOrgID != "" && ws.OrgID != reqOrgID {
 			return connect.NewResponse(&leapmuxv1.ListWorkspacesResponse{}), nil
 		}
-		return connect.NewResponse(&leapmuxv1.ListWorkspacesResponse{
-			Workspaces: []*leapmuxv1.Workspace{workspaceToProto(ws)},
-		}), nil
+		return s.workspacePage(ctx, user.ID, []store.Workspace{*ws}, req.Msg)
 	}
 	// The underlying SQL filter matches `w.org_id = sqlc.arg(org_id)`
 	// literally, so an empty arg never hits a row. Fall back to the
@@ -227,11 +407,143 @@ func (s *WorkspaceService) ListWorkspaces(
 	if err != nil {
 		return nil, connect.NewError(connect.CodeInternal, fmt.Errorf("list workspaces: %w", err))
 	}
+	return s.workspacePage(ctx, user.ID, workspaces, req.Msg)
+}
+
+// workspacePage applies ListWorkspaces' filters and page to workspaces, which
+// arrive newest first, and annotates each row with its agent count and the
+// activity its agents' workers reported. The title, archived, and cursor
+// filters run before the tab-index read so it only covers candidates; the
+// worker filter needs that read. An unset page.limit keeps the original
+// return-everything behavior.
+func (s *WorkspaceService) workspacePage(
+	ctx context.Context,
+	userID userid.UserID,
+	workspaces []store.Workspace,
+	req *leapmuxv1.ListWorkspacesRequest,
+) (*connect.Response[leapmuxv1.ListWorkspacesResponse], error) {
+	cursor, err := store.ParseCursor(req.GetPage().GetCursor())
+	if err != nil {
+		return nil, connect.NewError(connect.CodeInvalidArgument, err)
+	}
+	var archived map[string]bool
+	if req.Archived != nil {
+		if archived, err = archivedWorkspaceIDs(ctx, s.store, userID); err != nil {
+			return nil, connect.NewError(connect.CodeInternal, fmt.Errorf("list archived workspaces: %w", err))
+		}
+	}
+	query := strings.ToLower(strings.TrimSpace(req.GetQuery()))
+	candidates := make([]store.Workspace, 0, len(workspaces))
+	for _, ws := range workspaces {
+		if query != "" && !strings.Contains(strings.ToLower(ws.Title), query) {
+			continue
+		}
+		if req.Archived != nil && archived[ws.ID] != req.GetArchived() {
+			continue
+		}
+		if cursor != nil && !workspaceAfterCursor(&ws, cursor) {
+			continue
+		}
+		candidates = append(candidates, ws)
+	}
+
+	agentCounts := make(map[string]int32, len(candidates))
+	agentWorkers := make(map[string]map[string]bool, len(candidates))
+	onWorker := make(map[string]bool)
+	if len(candidates) > 0 {
+		ids := make([]string, len(candidates))
+		for i := range candidates {
+			ids[i] = candidates[i].ID
+		}
+		tabs, err := s.store.WorkspaceTabIndex().ListRenderedByWorkspaceIDs(ctx, ids)
+		if err != nil {
+			return nil, connect.NewError(connect.CodeInternal, fmt.Errorf("list rendered tabs: %w", err))
+		}
+		for _, t := range tabs {
+			if t.TabType == leapmuxv1.TabType_TAB_TYPE_AGENT {
+				agentCounts[t.WorkspaceID]++
+				if agentWorkers[t.WorkspaceID] == nil {
+					agentWorkers[t.WorkspaceID] = make(map[string]bool)
+				}
+				agentWorkers[t.WorkspaceID][t.WorkerID] = true
+			}
+			if t.WorkerID == req.GetWorkerId() {
+				onWorker[t.WorkspaceID] = true
+			}
+		}
+	}
+	if req.GetWorkerId() != "" {
+		filtered := candidates[:0]
+		for _, ws := range candidates {
+			if onWorker[ws.ID] {
+				filtered = append(filtered, ws)
+			}
+		}
+		candidates = filtered
+	}
+
+	page := store.Page[store.Workspace]{Rows: candidates}
+	if limit := req.GetPage().GetLimit(); limit > 0 {
+		page = store.NewPage(candidates, int64(limit))
+	}
+	pageWorkers := make(map[string]map[string]bool, len(page.Rows))
+	for _, ws := range page.Rows {
+		if workerIDs := agentWorkers[ws.ID]; workerIDs != nil {
+			pageWorkers[ws.ID] = workerIDs
+		}
+	}
+	if err := ownedActivityWorkers(ctx, s.store, userID, pageWorkers); err != nil {
+		return nil, err
+	}
+	pb := workspacesToProto(page.Rows)
+	for _, w := range pb {
+		w.AgentCount = agentCounts[w.GetId()]
+		annotateWorkspaceActivity(s.workerMgr, w, agentWorkers[w.GetId()], userID)
+	}
 	return connect.NewResponse(&leapmuxv1.ListWorkspacesResponse{
-		Workspaces: workspacesToProto(workspaces),
+		Workspaces: pb,
+		Page: &leapmuxv1.PageResponse{
+			NextCursor: page.NextCursor,
+			HasMore:    page.HasMore(),
+		},
 	}), nil
 }
 
+// archivedWorkspaceIDs returns the workspaces userID has filed under their
+// Archived section.
+func archivedWorkspaceIDs(ctx context.Context, st store.Store, userID userid.UserID) (map[string]bool, error) {
+	sections, err := st.WorkspaceSections().ListByUserID(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+	archivedSections := make(map[string]bool)
+	for _, sec := range sections {
+		if sec.SectionType == leapmuxv1.SectionType_SECTION_TYPE_WORKSPACES_ARCHIVED {
+			archivedSections[sec.ID] = true
+		}
+	}
+	items, err := st.WorkspaceSectionItems().ListByUser(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+	archived := make(map[string]bool)
+	for _, item := range items {
+		if archivedSections[item.SectionID] {
+			archived[item.WorkspaceID] = true
+		}
+	}
+	return archived, nil
+}
+
+// workspaceAfterCursor reports whether ws sorts strictly after cursor in
+// ListAccessible's (created_at DESC, id DESC) order.
+func workspaceAfterCursor(ws *store.Workspace, cursor *store.Cursor) bool {
+	if ws.CreatedAt.Equal(cursor.Time) {
+		return ws.ID < cursor.ID
+	}
+	return ws.CreatedAt.Before(cursor.Time)
+}
+
 func (s *WorkspaceService) GetWorkspace(
 	ctx context.Context,
 	req *connect.Request[leapmuxv1.GetWorkspaceRequest],
@@ -245,7 +557,7 @@ func (s *WorkspaceService) GetWorkspace(
 		return nil, err
 	}
 	if reqOrgID := req.Msg.GetOrgId(); reqOrgID != "" && ws.OrgID != reqOrgID {
-		return nil, connect.NewError(connect.CodeNotFound, fmt.Errorf("workspace not found"))
+		return nil, errcode.New(connect.CodeNotFound, errcode.WorkspaceNotFound, errors.New("workspace not found"))
 	}
 	return connect.NewResponse(&leapmuxv1.GetWorkspaceResponse{
 		Workspace: workspaceToProto(ws),
@@ -2,7 +2,11 @@ package service_test
 
 import (
 	"context"
+	"encoding/json"
 	"errors"
+	"os"
+	"path/filepath"
+	"runtime"
 	"sync"
 	"testing"
 	"time"
@@ -10,6 +14,7 @@ import (
 	"connectrpc.com/connect"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
+	"google.golang.org/protobuf/proto"
 
 	leapmuxv1 "github.com/leapmux/leapmux/generated/proto/leapmux/v1"
 	"github.com/leapmux/leapmux/internal/hub/auth"
@@ -18,6 +23,9 @@ import (
 	"github.com/leapmux/leapmux/internal/hub/store"
 	"github.com/leapmux/leapmux/internal/hub/store/storetest"
 	hubtestutil "github.com/leapmux/leapmux/internal/hub/testutil"
+	"github.com/leapmux/leapmux/internal/hub/workermgr"
+	"github.com/leapmux/leapmux/internal/plugin"
+	"github.com/leapmux/leapmux/internal/util/testutil"
 	"github.com/leapmux/leapmux/internal/util/userid"
 )
 
@@ -98,6 +106,67 @@ func TestWorkspaceService_CreateWorkspace_RejectsNonMemberOrg(t *testing.T) {
 	assert.Equal(t, homeOrg, created.OrgID, "empty org_id must home the workspace in the caller's org")
 }
 
+func TestWorkspaceService_CreateWorkspace_IdempotencyKey(t *testing.T) {
+	st := hubtestutil.OpenTestStore(t)
+	orgID := storetest.SeedOrg(t, st, "idem-org")
+	user := storetest.SeedUser(t, st, orgID, "alice")
+	svc := service.NewWorkspaceService(st, nil, noopWorkspaceChannelCloser{})
+	ctx := auth.WithUser(context.Background(), &auth.UserInfo{ID: userid.MustNew(user.ID), OrgID: orgID})
+
+	req := &leapmuxv1.CreateWorkspaceRequest{Title: "retried", IdempotencyKey: "key-1"}
+	first, err := svc.CreateWorkspace(ctx, connect.NewRequest(req))
+	require.NoError(t, err)
+	retry, err := svc.CreateWorkspace(ctx, connect.NewRequest(req))
+	require.NoError(t, err)
+	assert.Equal(t, first.Msg.GetWorkspaceId(), retry.Msg.GetWorkspaceId(), "a retry returns the original workspace")
+
+	other, err := svc.CreateWorkspace(ctx, connect.NewRequest(&leapmuxv1.CreateWorkspaceRequest{Title: "retried", IdempotencyKey: "key-2"}))
+	require.NoError(t, err)
+	assert.NotEqual(t, first.Msg.GetWorkspaceId(), other.Msg.GetWorkspaceId())
+
+	_, err = svc.CreateWorkspace(ctx, connect.NewRequest(&leapmuxv1.CreateWorkspaceRequest{Title: "renamed", IdempotencyKey: "key-1"}))
+	assert.Equal(t, connect.CodeInvalidArgument, connect.CodeOf(err), "a key reused for a different request is rejected")
+
+	created, err := st.Workspaces().ListAccessible(ctx, store.ListAccessibleWorkspacesParams{UserID: userid.MustNew(user.ID), OrgID: orgID})
+	require.NoError(t, err)
+	assert.Len(t, created, 2)
+}
+
+func TestWorkspaceService_CreateWorkspace_EmitsPluginEvent(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("shell script plugins")
+	}
+	dir := t.TempDir()
+	out := filepath.Join(t.TempDir(), "events")
+	t.Setenv("PLUGIN_TEST_OUT", out)
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "recorder"), []byte("#!/bin/sh\ncat >>\"$PLUGIN_TEST_OUT\"\n"), 0o755))
+	host, err := plugin.New(dir, "hub")
+	require.NoError(t, err)
+	pluginCtx, cancel := context.WithCancel(context.Background())
+	t.Cleanup(cancel)
+	host.Start(pluginCtx)
+
+	st := hubtestutil.OpenTestStore(t)
+	orgID := storetest.SeedOrg(t, st, "plugin-org")
+	user := storetest.SeedUser(t, st, orgID, "alice")
+	svc := service.NewWorkspaceService(st, nil, noopWorkspaceChannelCloser{}).WithPlugins(host)
+	ctx := auth.WithUser(context.Background(), &auth.UserInfo{ID: userid.MustNew(user.ID), OrgID: orgID})
+
+	resp, err := svc.CreateWorkspace(ctx, connect.NewRequest(&leapmuxv1.CreateWorkspaceRequest{Title: "watched"}))
+	require.NoError(t, err)
+
+	var event map[string]any
+	testutil.AssertEventually(t, func() bool {
+		data, _ := os.ReadFile(out)
+		return json.Unmarshal(data, &event) == nil
+	}, "expected a plugin event")
+	assert.Equal(t, "workspace_created", event["hook"])
+	assert.Equal(t, "hub", event["host"])
+	assert.Equal(t, map[string]any{
+		"workspace_id": resp.Msg.GetWorkspaceId(), "org_id": orgID, "title": "watched", "created_by": user.ID,
+	}, event["data"])
+}
+
 // TestWorkspaceService_ListWorkspaces_DefaultsOrgIDToUserHome locks in
 // the CLI-friendly default: when the caller doesn't specify an
 // org_id, the handler falls back to the authenticated user's home
@@ -183,6 +252,188 @@ func TestWorkspaceService_ListWorkspaces_ForeignOrgReturnsNothing(t *testing.T)
 		"another user's org must never surface workspaces the caller does not own")
 }
 
+func listedWorkspaceIDs(resp *connect.Response[leapmuxv1.ListWorkspacesResponse]) []string {
+	ids := make([]string, 0, len(resp.Msg.GetWorkspaces()))
+	for _, w := range resp.Msg.GetWorkspaces() {
+		ids = append(ids, w.GetId())
+	}
+	return ids
+}
+
+func TestWorkspaceService_ListWorkspaces_FiltersAndAgentCounts(t *testing.T) {
+	st := hubtestutil.OpenTestStore(t)
+	orgID := storetest.SeedOrg(t, st, "primary-org")
+	user := storetest.SeedUser(t, st, orgID, "alice")
+	backend := storetest.SeedWorkspace(t, st, orgID, user.ID, "Backend API")
+	frontend := storetest.SeedWorkspace(t, st, orgID, user.ID, "frontend")
+	docs := storetest.SeedWorkspace(t, st, orgID, user.ID, "Docs")
+	seedRenderedTab(t, st, orgID, backend, "a1")
+	seedRenderedTab(t, st, orgID, backend, "a2")
+	seedRenderedTab(t, st, orgID, frontend, "b1")
+
+	svc := service.NewWorkspaceService(st, nil, noopWorkspaceChannelCloser{})
+	ctx := auth.WithUser(context.Background(), &auth.UserInfo{ID: userid.MustNew(user.ID), OrgID: orgID})
+
+	resp, err := svc.ListWorkspaces(ctx, connect.NewRequest(&leapmuxv1.ListWorkspacesRequest{Query: "END"}))
+	require.NoError(t, err)
+	assert.ElementsMatch(t, []string{backend, frontend}, listedWorkspaceIDs(resp), "title search is a case-insensitive substring match")
+
+	resp, err = svc.ListWorkspaces(ctx, connect.NewRequest(&leapmuxv1.ListWorkspacesRequest{WorkerId: "worker-b1"}))
+	require.NoError(t, err)
+	assert.Equal(t, []string{frontend}, listedWorkspaceIDs(resp))
+
+	resp, err = svc.ListWorkspaces(ctx, connect.NewRequest(&leapmuxv1.ListWorkspacesRequest{}))
+	require.NoError(t, err)
+	counts := map[string]int32{}
+	for _, w := range resp.Msg.GetWorkspaces() {
+		counts[w.GetId()] = w.GetAgentCount()
+	}
+	assert.Equal(t, map[string]int32{backend: 2, frontend: 1, docs: 0}, counts)
+}
+
+// connectActivityWorker registers an online worker whose activity
+// reports the test applies to the returned conn.
+func connectActivityWorker(t *testing.T, st store.Store, mgr *workermgr.Manager, owner userid.UserID, workerID string) *workermgr.Conn {
+	t.Helper()
+	require.NoError(t, st.Workers().Create(context.Background(), store.CreateWorkerParams{
+		ID:              workerID,
+		AuthToken:       "token-" + workerID,
+		RegisteredBy:    owner,
+		PublicKey:       []byte("test-x25519-key-32-bytes-padding"),
+		MlkemPublicKey:  []byte("mlkem"),
+		SlhdsaPublicKey: []byte("slhdsa"),
+	}))
+	conn := &workermgr.Conn{WorkerID: workerID, SendFn: func(*leapmuxv1.ConnectResponse) error { return nil }}
+	_, err := mgr.Register(conn)
+	require.NoError(t, err)
+	return conn
+}
+
+func TestWorkspaceService_ListWorkspaces_ActivityFromAgentWorkers(t *testing.T) {
+	st := hubtestutil.OpenTestStore(t)
+	orgID := storetest.SeedOrg(t, st, "primary-org")
+	user := storetest.SeedUser(t, st, orgID, "alice")
+	uid := userid.MustNew(user.ID)
+	backend := storetest.SeedWorkspace(t, st, orgID, user.ID, "backend")
+	frontend := storetest.SeedWorkspace(t, st, orgID, user.ID, "frontend")
+	docs := storetest.SeedWorkspace(t, st, orgID, user.ID, "docs")
+	seedRenderedTab(t, st, orgID, backend, "a1")
+	seedRenderedTab(t, st, orgID, backend, "a2")
+	seedRenderedTab(t, st, orgID, frontend, "b1")
+	seedRenderedTab(t, st, orgID, docs, "c1")
+
+	mgr := workermgr.New(service.NewWorkerReachAuthorizer(st))
+	connectActivityWorker(t, st, mgr, uid, "worker-a1").ApplyWorkspaceActivity(&leapmuxv1.WorkspaceActivityReport{Full: true, Workspaces: []*leapmuxv1.WorkspaceActivity{{
+		WorkspaceId: backend, LastMessageAt: "2026-10-19T10:00:00.000Z", MessageCount: 5, AwaitingApprovalCount: 1,
+		UserUnreadCounts: []*leapmuxv1.UserUnreadCount{{UserId: user.ID, UnreadCount: 2}, {UserId: "someone-else", UnreadCount: 0}},
+	}}})
+	connectActivityWorker(t, st, mgr, uid, "worker-a2").ApplyWorkspaceActivity(&leapmuxv1.WorkspaceActivityReport{Full: true, Workspaces: []*leapmuxv1.WorkspaceActivity{{
+		WorkspaceId: backend, LastMessageAt: "2026-10-19T11:00:00.000Z", MessageCount: 3,
+	}}})
+	// worker-b1 hosts none of backend's agents, so its word on backend is
+	// not taken.
+	connectActivityWorker(t, st, mgr, uid, "worker-b1").ApplyWorkspaceActivity(&leapmuxv1.WorkspaceActivityReport{Full: true, Workspaces: []*leapmuxv1.WorkspaceActivity{
+		{WorkspaceId: backend, MessageCount: 100, AwaitingApprovalCount: 7},
+		{WorkspaceId: frontend, LastMessageAt: "2026-10-19T09:00:00.000Z", MessageCount: 4},
+	}})
+	// docs's tab names bob's worker; a client wrote that id, so bob's
+	// worker is not asked about it.
+	bob := storetest.SeedUser(t, st, orgID, "bob")
+	connectActivityWorker(t, st, mgr, userid.MustNew(bob.ID), "worker-c1").ApplyWorkspaceActivity(&leapmuxv1.WorkspaceActivityReport{Full: true, Workspaces: []*leapmuxv1.WorkspaceActivity{
+		{WorkspaceId: docs, LastMessageAt: "2026-10-19T08:00:00.000Z", MessageCount: 9},
+	}})
+
+	svc := service.NewWorkspaceService(st, nil, noopWorkspaceChannelCloser{}).WithRepoCheckouts(mgr, nil, nil)
+	ctx := auth.WithUser(context.Background(), &auth.UserInfo{ID: uid, OrgID: orgID})
+	resp, err := svc.ListWorkspaces(ctx, connect.NewRequest(&leapmuxv1.ListWorkspacesRequest{}))
+	require.NoError(t, err)
+	byID := map[string]*leapmuxv1.Workspace{}
+	for _, w := range resp.Msg.GetWorkspaces() {
+		byID[w.GetId()] = w
+	}
+	assert.Equal(t, "2026-10-19T11:00:00.000Z", byID[backend].GetLastMessageAt())
+	assert.EqualValues(t, 1, byID[backend].GetAwaitingApprovalCount())
+	assert.EqualValues(t, 5, byID[backend].GetUnreadCount(), "alice's own count on worker-a1, every message on worker-a2")
+	assert.EqualValues(t, 4, byID[frontend].GetUnreadCount())
+	assert.Empty(t, byID[docs].GetLastMessageAt())
+	assert.Zero(t, byID[docs].GetUnreadCount())
+}
+
+func TestWorkspaceService_ListWorkspaces_ArchivedFilter(t *testing.T) {
+	st := hubtestutil.OpenTestStore(t)
+	orgID := storetest.SeedOrg(t, st, "primary-org")
+	user := storetest.SeedUser(t, st, orgID, "alice")
+	active := storetest.SeedWorkspace(t, st, orgID, user.ID, "active")
+	old := storetest.SeedWorkspace(t, st, orgID, user.ID, "old")
+
+	ctx := auth.WithUser(context.Background(), &auth.UserInfo{ID: userid.MustNew(user.ID), OrgID: orgID})
+	sections := service.NewSectionService(st, nil)
+	listed, err := sections.ListSections(ctx, connect.NewRequest(&leapmuxv1.ListSectionsRequest{OrgId: orgID}))
+	require.NoError(t, err)
+	var archivedID string
+	for _, sec := range listed.Msg.GetSections() {
+		if sec.GetSectionType() == leapmuxv1.SectionType_SECTION_TYPE_WORKSPACES_ARCHIVED {
+			archivedID = sec.GetId()
+		}
+	}
+	require.NotEmpty(t, archivedID)
+	_, err = sections.MoveWorkspace(ctx, connect.NewRequest(&leapmuxv1.MoveWorkspaceRequest{
+		WorkspaceId: old, SectionId: archivedID, Position: "a",
+	}))
+	require.NoError(t, err)
+
+	svc := service.NewWorkspaceService(st, nil, noopWorkspaceChannelCloser{})
+	for _, tc := range []struct {
+		archived *bool
+		want     []string
+	}{
+		{nil, []string{active, old}},
+		{proto.Bool(true), []string{old}},
+		{proto.Bool(false), []string{active}},
+	} {
+		resp, err := svc.ListWorkspaces(ctx, connect.NewRequest(&leapmuxv1.ListWorkspacesRequest{Archived: tc.archived}))
+		require.NoError(t, err)
+		assert.ElementsMatch(t, tc.want, listedWorkspaceIDs(resp))
+	}
+}
+
+func TestWorkspaceService_ListWorkspaces_CursorPagination(t *testing.T) {
+	st := hubtestutil.OpenTestStore(t)
+	orgID := storetest.SeedOrg(t, st, "primary-org")
+	user := storetest.SeedUser(t, st, orgID, "alice")
+	for _, title := range []string{"one", "two", "three", "four", "five"} {
+		storetest.SeedWorkspace(t, st, orgID, user.ID, title)
+	}
+
+	svc := service.NewWorkspaceService(st, nil, noopWorkspaceChannelCloser{})
+	ctx := auth.WithUser(context.Background(), &auth.UserInfo{ID: userid.MustNew(user.ID), OrgID: orgID})
+
+	all, err := svc.ListWorkspaces(ctx, connect.NewRequest(&leapmuxv1.ListWorkspacesRequest{}))
+	require.NoError(t, err)
+	require.Len(t, all.Msg.GetWorkspaces(), 5, "an unset page limit returns every workspace")
+	assert.False(t, all.Msg.GetPage().GetHasMore())
+
+	var paged []string
+	cursor := ""
+	for range 5 {
+		resp, err := svc.ListWorkspaces(ctx, connect.NewRequest(&leapmuxv1.ListWorkspacesRequest{
+			Page: &leapmuxv1.PageRequest{Limit: 2, Cursor: cursor},
+		}))
+		require.NoError(t, err)
+		paged = append(paged, listedWorkspaceIDs(resp)...)
+		cursor = resp.Msg.GetPage().GetNextCursor()
+		if !resp.Msg.GetPage().GetHasMore() {
+			break
+		}
+	}
+	assert.Equal(t, listedWorkspaceIDs(all), paged)
+
+	_, err = svc.ListWorkspaces(ctx, connect.NewRequest(&leapmuxv1.ListWorkspacesRequest{
+		Page: &leapmuxv1.PageRequest{Cursor: "garbage"},
+	}))
+	assert.Equal(t, connect.CodeInvalidArgument, connect.CodeOf(err))
+}
+
 // TestWorkspaceService_ListWorkspaces_DelegationVerifiesAccess
 // catches the "workspace deleted but bearer still alive" edge: a
 // delegation token outlives its workspace when the workspace is
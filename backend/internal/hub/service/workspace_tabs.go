@@ -43,7 +43,7 @@ func listTabsOrgBinding(reqOrgID string, user *auth.UserInfo) auth.OrgBinding {
 	if reqOrgID != "" {
 		return auth.BindOrg(reqOrgID)
 	}
-	if user.Credential.IsDelegation() {
+	if user.Credential.IsWorkspaceScoped() {
 		return auth.AnyOrg()
 	}
 	return auth.BindOrg(user.OrgID)
@@ -139,7 +139,7 @@ func (s *WorkspaceService) LocateTab(
 		return nil, connect.NewError(connect.CodeInvalidArgument, errors.New("tab_id is required"))
 	}
 	var row *store.WorkspaceTabRow
-	if user.Credential.IsDelegation() {
+	if user.Credential.IsWorkspaceScoped() {
 		if _, err := loadWorkspaceForRead(ctx, s.store, user.Credential.WorkspaceScopeID(), user); err != nil {
 			if code := connect.CodeOf(err); code == connect.CodePermissionDenied || code == connect.CodeNotFound {
 				return nil, connect.NewError(connect.CodeNotFound, fmt.Errorf("tab not found in any accessible workspace"))
@@ -251,7 +251,7 @@ func (s *WorkspaceService) LocateTile(
 // missing scope to NotFound). A regular caller's workspaces all live in their
 // own (personal) org, so that is the only candidate.
 func (s *WorkspaceService) locateTileOrgCandidates(ctx context.Context, user *auth.UserInfo) ([]string, error) {
-	if user.Credential.IsDelegation() {
+	if user.Credential.IsWorkspaceScoped() {
 		ws, err := loadWorkspaceForRead(ctx, s.store, user.Credential.WorkspaceScopeID(), user)
 		if err != nil {
 			if code := connect.CodeOf(err); code == connect.CodePermissionDenied || code == connect.CodeNotFound {
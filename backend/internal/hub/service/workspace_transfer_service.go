@@ -0,0 +1,236 @@
+package service
+
+import (
+	"cmp"
+	"context"
+	"errors"
+	"fmt"
+	"slices"
+	"time"
+
+	"connectrpc.com/connect"
+	"google.golang.org/protobuf/proto"
+
+	leapmuxv1 "github.com/leapmux/leapmux/generated/proto/leapmux/v1"
+	"github.com/leapmux/leapmux/internal/hub/auth"
+	"github.com/leapmux/leapmux/internal/hub/crdt"
+	"github.com/leapmux/leapmux/internal/hub/store"
+	"github.com/leapmux/leapmux/internal/util/id"
+	"github.com/leapmux/leapmux/util/validate"
+)
+
+// workspaceBundleVersion is the WorkspaceBundle format this hub writes and
+// the only one it reads.
+const workspaceBundleVersion = 1
+
+// WorkspaceTransferService implements the WorkspaceTransferServiceHandler
+// interface. It owns the hub half of a workspace bundle; agent history and
+// plans are carried by the workers' own export and import RPCs.
+type WorkspaceTransferService struct {
+	store      store.Store
+	workspaces *WorkspaceService
+}
+
+// NewWorkspaceTransferService creates a new WorkspaceTransferService.
+// Imports create workspaces through workspaces, so they run the same
+// lifecycle path as CreateWorkspace.
+func NewWorkspaceTransferService(st store.Store, workspaces *WorkspaceService) *WorkspaceTransferService {
+	return &WorkspaceTransferService{store: st, workspaces: workspaces}
+}
+
+func (s *WorkspaceTransferService) ExportWorkspace(
+	ctx context.Context,
+	req *connect.Request[leapmuxv1.ExportWorkspaceRequest],
+) (*connect.Response[leapmuxv1.ExportWorkspaceResponse], error) {
+	user, err := auth.MustGetUser(ctx)
+	if err != nil {
+		return nil, err
+	}
+	ws, err := loadWorkspaceForRead(ctx, s.store, req.Msg.GetWorkspaceId(), user)
+	if err != nil {
+		return nil, err
+	}
+
+	bundle := &leapmuxv1.WorkspaceBundle{
+		Version:    workspaceBundleVersion,
+		Title:      ws.Title,
+		ExportedAt: time.Now().UTC().Format("2006-01-02T15:04:05.000Z"),
+	}
+	if err := s.exportLayout(ctx, ws, bundle); err != nil {
+		return nil, err
+	}
+
+	presets, err := s.store.WorkspaceLayoutPresets().ListByWorkspace(ctx, store.ListWorkspaceLayoutPresetsParams{
+		UserID:      user.ID,
+		WorkspaceID: ws.ID,
+	})
+	if err != nil {
+		return nil, connect.NewError(connect.CodeInternal, fmt.Errorf("list layout presets: %w", err))
+	}
+	for _, p := range presets {
+		pb, err := layoutPresetToProto(&p)
+		if err != nil {
+			return nil, connect.NewError(connect.CodeInternal, err)
+		}
+		bundle.LayoutPresets = append(bundle.LayoutPresets, &leapmuxv1.WorkspaceBundlePreset{
+			Name: pb.GetName(),
+			Root: pb.GetRoot(),
+		})
+	}
+
+	return connect.NewResponse(&leapmuxv1.ExportWorkspaceResponse{Bundle: bundle}), nil
+}
+
+// exportLayout fills the bundle's tabs and main tile tree from the
+// workspace's projected CRDT state. Tabs in floating windows are listed but
+// have no place in the tree. Without a CRDT registry there is no layout to
+// read, and the bundle carries none.
+func (s *WorkspaceTransferService) exportLayout(ctx context.Context, ws *store.Workspace, bundle *leapmuxv1.WorkspaceBundle) error {
+	if s.workspaces.registry == nil {
+		return nil
+	}
+	mgr, err := s.workspaces.registry.Get(ctx, ws.OrgID)
+	if err != nil {
+		return connect.NewError(connect.CodeInternal, fmt.Errorf("load org state: %w", err))
+	}
+	projection := crdt.Project(mgr.State())
+	projected := projection.Workspaces[ws.ID]
+	if projected == nil {
+		return nil
+	}
+
+	var tabs []*crdt.RenderedTab
+	for _, t := range projection.RenderedTabs {
+		if t.WorkspaceID == ws.ID {
+			tabs = append(tabs, t)
+		}
+	}
+	slices.SortStableFunc(tabs, func(a, b *crdt.RenderedTab) int {
+		return cmp.Or(cmp.Compare(a.Position, b.Position), cmp.Compare(a.TabID, b.TabID))
+	})
+	tilesTabs := make(map[string][]string)
+	for _, t := range tabs {
+		bundle.Tabs = append(bundle.Tabs, &leapmuxv1.WorkspaceBundleTab{
+			TabType:  t.TabType,
+			TabId:    t.TabID,
+			WorkerId: t.WorkerID,
+		})
+		tilesTabs[t.TileID] = append(tilesTabs[t.TileID], t.TabID)
+	}
+	if projected.MainTree != nil {
+		bundle.Layout = renderTreeToPresetNode(projected.MainTree, tilesTabs)
+	}
+	return nil
+}
+
+// renderTreeToPresetNode converts a projected tile tree into the preset
+// shape, placing each leaf's tabs in tab order.
+func renderTreeToPresetNode(t *crdt.RenderTree, tilesTabs map[string][]string) *leapmuxv1.LayoutPresetNode {
+	n := &leapmuxv1.LayoutPresetNode{
+		Kind:      t.Kind,
+		Direction: t.Direction,
+		Ratios:    t.Ratios,
+		Rows:      t.Rows,
+		Cols:      t.Cols,
+		RowRatios: t.RowRatios,
+		ColRatios: t.ColRatios,
+	}
+	if t.Kind == leapmuxv1.NodeKind_NODE_KIND_LEAF {
+		n.TabIds = tilesTabs[t.NodeID]
+		return n
+	}
+	for _, child := range t.Children {
+		n.Children = append(n.Children, renderTreeToPresetNode(child, tilesTabs))
+	}
+	return n
+}
+
+// ImportWorkspace creates a new workspace from the hub half of a bundle:
+// its title and layout presets. Tabs and the main layout are rebuilt by the
+// frontend through ordinary CRDT ops once each worker has imported its
+// agents and returned their new ids.
+func (s *WorkspaceTransferService) ImportWorkspace(
+	ctx context.Context,
+	req *connect.Request[leapmuxv1.ImportWorkspaceRequest],
+) (*connect.Response[leapmuxv1.ImportWorkspaceResponse], error) {
+	user, err := auth.MustGetUser(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if err := rejectDelegationBearer(user, "workspace lifecycle mutation"); err != nil {
+		return nil, err
+	}
+	// As in CreateWorkspace, an import lands only in the caller's own org.
+	orgID, err := auth.ResolveOrgID(user, req.Msg.GetOrgId())
+	if err != nil {
+		return nil, err
+	}
+
+	bundle := req.Msg.GetBundle()
+	if bundle == nil {
+		return nil, connect.NewError(connect.CodeInvalidArgument, errors.New("bundle is required"))
+	}
+	if bundle.GetVersion() != workspaceBundleVersion {
+		return nil, connect.NewError(connect.CodeInvalidArgument,
+			fmt.Errorf("unsupported bundle version %d", bundle.GetVersion()))
+	}
+	title, err := validate.SanitizeName(bundle.GetTitle())
+	if err != nil {
+		return nil, connect.NewError(connect.CodeInvalidArgument, fmt.Errorf("title: %w", err))
+	}
+	if bundle.GetLayout() != nil {
+		if err := validateLayoutPresetTree(bundle.GetLayout()); err != nil {
+			return nil, connect.NewError(connect.CodeInvalidArgument, fmt.Errorf("layout: %w", err))
+		}
+	}
+	presets, err := bundlePresetParams(bundle.GetLayoutPresets())
+	if err != nil {
+		return nil, err
+	}
+
+	wsID, err := s.workspaces.createWorkspace(ctx, user, orgID, id.Generate(), title, func(tx store.Store, wsID string) error {
+		for _, p := range presets {
+			p.UserID = user.ID
+			p.WorkspaceID = wsID
+			if _, err := tx.WorkspaceLayoutPresets().Upsert(ctx, p); err != nil {
+				return connect.NewError(connect.CodeInternal, fmt.Errorf("save layout preset: %w", err))
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return connect.NewResponse(&leapmuxv1.ImportWorkspaceResponse{WorkspaceId: wsID}), nil
+}
+
+// bundlePresetParams validates a bundle's presets under the same rules as
+// SaveLayoutPreset and returns them ready to store, less the owner and
+// workspace.
+func bundlePresetParams(presets []*leapmuxv1.WorkspaceBundlePreset) ([]store.UpsertWorkspaceLayoutPresetParams, error) {
+	if len(presets) > maxLayoutPresetsPerWorkspace {
+		return nil, connect.NewError(connect.CodeInvalidArgument,
+			fmt.Errorf("a workspace can hold at most %d layout presets", maxLayoutPresetsPerWorkspace))
+	}
+	params := make([]store.UpsertWorkspaceLayoutPresetParams, 0, len(presets))
+	for _, p := range presets {
+		name, err := validate.SanitizeName(p.GetName())
+		if err != nil {
+			return nil, connect.NewError(connect.CodeInvalidArgument, fmt.Errorf("layout preset name: %w", err))
+		}
+		if err := validateLayoutPresetTree(p.GetRoot()); err != nil {
+			return nil, connect.NewError(connect.CodeInvalidArgument, fmt.Errorf("layout preset %s: %w", name, err))
+		}
+		root, err := proto.Marshal(p.GetRoot())
+		if err != nil {
+			return nil, connect.NewError(connect.CodeInternal, fmt.Errorf("marshal layout: %w", err))
+		}
+		params = append(params, store.UpsertWorkspaceLayoutPresetParams{
+			ID:   id.Generate(),
+			Name: name,
+			Root: root,
+		})
+	}
+	return params, nil
+}
@@ -0,0 +1,123 @@
+package service_test
+
+import (
+	"context"
+	"testing"
+
+	"connectrpc.com/connect"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/protobuf/encoding/protojson"
+	"google.golang.org/protobuf/proto"
+
+	leapmuxv1 "github.com/leapmux/leapmux/generated/proto/leapmux/v1"
+	"github.com/leapmux/leapmux/internal/hub/auth"
+	"github.com/leapmux/leapmux/internal/hub/service"
+	"github.com/leapmux/leapmux/internal/hub/store"
+	"github.com/leapmux/leapmux/internal/hub/store/storetest"
+	hubtestutil "github.com/leapmux/leapmux/internal/hub/testutil"
+	"github.com/leapmux/leapmux/internal/util/id"
+	"github.com/leapmux/leapmux/internal/util/userid"
+)
+
+func TestWorkspaceTransferService_ExportThenImport(t *testing.T) {
+	st := hubtestutil.OpenTestStore(t)
+	orgID := storetest.SeedOrg(t, st, "transfer-org")
+	user := storetest.SeedUser(t, st, orgID, "alice")
+	wsID := storetest.SeedWorkspace(t, st, orgID, user.ID, "Project")
+	uid := userid.MustNew(user.ID)
+	ctx := auth.WithUser(context.Background(), &auth.UserInfo{ID: uid, OrgID: orgID})
+
+	env := setupLocateTileEnv(t, orgID)
+	env.mgr.MutateInternal(func(s *leapmuxv1.OrgCrdtState) {
+		s.Workspaces[wsID] = &leapmuxv1.WorkspaceContentsRecord{WorkspaceId: wsID, RootNodeId: "root-1"}
+		s.Nodes["root-1"] = &leapmuxv1.NodeRecord{
+			NodeId: "root-1",
+			Kind:   &leapmuxv1.LWWNodeKind{Value: leapmuxv1.NodeKind_NODE_KIND_LEAF},
+		}
+		for tabID, pos := range map[string]string{"agent-b": "a1", "agent-a": "a0"} {
+			s.Tabs[tabID] = &leapmuxv1.TabRecord{
+				TabType:  leapmuxv1.TabType_TAB_TYPE_AGENT,
+				TabId:    tabID,
+				TileId:   &leapmuxv1.LWWString{Value: "root-1"},
+				WorkerId: &leapmuxv1.LWWString{Value: "worker-1"},
+				Position: &leapmuxv1.LWWString{Value: pos},
+			}
+		}
+	})
+	root, err := proto.Marshal(twoPaneLayout())
+	require.NoError(t, err)
+	_, err = st.WorkspaceLayoutPresets().Upsert(context.Background(), store.UpsertWorkspaceLayoutPresetParams{
+		ID:          id.Generate(),
+		UserID:      uid,
+		WorkspaceID: wsID,
+		Name:        "Review",
+		Root:        root,
+	})
+	require.NoError(t, err)
+
+	workspaces := service.NewWorkspaceService(st, env.registry, noopWorkspaceChannelCloser{})
+	svc := service.NewWorkspaceTransferService(st, workspaces)
+
+	exported, err := svc.ExportWorkspace(ctx, connect.NewRequest(&leapmuxv1.ExportWorkspaceRequest{WorkspaceId: wsID}))
+	require.NoError(t, err)
+	bundle := exported.Msg.GetBundle()
+	assert.Equal(t, uint32(1), bundle.GetVersion())
+	assert.Equal(t, "Project", bundle.GetTitle())
+	assert.Equal(t, []string{"agent-a", "agent-b"}, bundle.GetLayout().GetTabIds(), "tabs keep their tab order")
+	require.Len(t, bundle.GetTabs(), 2)
+	assert.Equal(t, "worker-1", bundle.GetTabs()[0].GetWorkerId())
+	require.Len(t, bundle.GetLayoutPresets(), 1)
+	assert.Equal(t, "Review", bundle.GetLayoutPresets()[0].GetName())
+
+	// A bundle saved as JSON must import the same as the binary form.
+	raw, err := protojson.Marshal(bundle)
+	require.NoError(t, err)
+	var restored leapmuxv1.WorkspaceBundle
+	require.NoError(t, protojson.Unmarshal(raw, &restored))
+
+	imported, err := svc.ImportWorkspace(ctx, connect.NewRequest(&leapmuxv1.ImportWorkspaceRequest{Bundle: &restored}))
+	require.NoError(t, err)
+	newID := imported.Msg.GetWorkspaceId()
+	require.NotEqual(t, wsID, newID)
+
+	ws, err := st.Workspaces().GetByID(context.Background(), newID)
+	require.NoError(t, err)
+	assert.Equal(t, "Project", ws.Title)
+	assert.Equal(t, orgID, ws.OrgID)
+	presets, err := st.WorkspaceLayoutPresets().ListByWorkspace(context.Background(), store.ListWorkspaceLayoutPresetsParams{
+		UserID:      uid,
+		WorkspaceID: newID,
+	})
+	require.NoError(t, err)
+	require.Len(t, presets, 1)
+	assert.Equal(t, "Review", presets[0].Name)
+}
+
+func TestWorkspaceTransferService_ImportRejectsBadBundles(t *testing.T) {
+	st := hubtestutil.OpenTestStore(t)
+	orgID := storetest.SeedOrg(t, st, "transfer-org")
+	user := storetest.SeedUser(t, st, orgID, "alice")
+	ctx := auth.WithUser(context.Background(), &auth.UserInfo{ID: userid.MustNew(user.ID), OrgID: orgID})
+	svc := service.NewWorkspaceTransferService(st, service.NewWorkspaceService(st, nil, noopWorkspaceChannelCloser{}))
+
+	for name, bundle := range map[string]*leapmuxv1.WorkspaceBundle{
+		"missing":     nil,
+		"version":     {Version: 2, Title: "Project"},
+		"title":       {Version: 1},
+		"layout":      {Version: 1, Title: "Project", Layout: &leapmuxv1.LayoutPresetNode{}},
+		"preset name": {Version: 1, Title: "Project", LayoutPresets: []*leapmuxv1.WorkspaceBundlePreset{{Root: twoPaneLayout()}}},
+	} {
+		t.Run(name, func(t *testing.T) {
+			_, err := svc.ImportWorkspace(ctx, connect.NewRequest(&leapmuxv1.ImportWorkspaceRequest{Bundle: bundle}))
+			assert.Equal(t, connect.CodeInvalidArgument, connect.CodeOf(err))
+		})
+	}
+
+	workspaces, err := st.Workspaces().ListAccessible(context.Background(), store.ListAccessibleWorkspacesParams{
+		UserID: userid.MustNew(user.ID),
+		OrgID:  orgID,
+	})
+	require.NoError(t, err)
+	assert.Empty(t, workspaces, "a rejected import creates nothing")
+}
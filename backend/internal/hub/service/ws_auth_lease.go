@@ -12,7 +12,7 @@ import (
 )
 
 // wsAuthenticator carries the inputs every WebSocket handler needs to run the
-// shared HTTP auth ladder (solo -> bearer -> cookie) and bind an authenticated
+// shared HTTP auth ladder (solo -> bearer -> cookie -> public viewer) and bind an authenticated
 // lease. Both OrgEventsHandler and ChannelRelayHandler embed it, so the auth
 // option set lives in one place and cannot drift between endpoints.
 type wsAuthenticator struct {
@@ -21,6 +21,9 @@ type wsAuthenticator struct {
 	secureCookie   bool
 	tokenValidator *auth.TokenValidator
 	authLease      webSocketAuthLease
+	// allowedOrigins are the other origins whose pages may open the socket;
+	// without them the upgrade only accepts the hub's own origin.
+	allowedOrigins []string
 }
 
 // authenticate resolves the caller via the shared HTTP auth ladder so every WS
@@ -34,9 +37,23 @@ func (a wsAuthenticator) authenticate(r *http.Request) (*auth.UserInfo, error) {
 		SoloUser:  a.soloUser,
 		Cookies:   []bool{a.secureCookie},
 		Contexts:  a.authLease.registry,
+		// A public viewer is pinned to its workspace like a delegation
+		// bearer, so both relays scope it without a separate path.
+		PublicViewers: true,
 	})
 }
 
+// acceptOptions returns the upgrade options for a socket speaking
+// subprotocol, admitting the configured cross-origin pages. The websocket
+// library matches each full origin exactly, as config validation keeps them
+// free of pattern characters.
+func (a wsAuthenticator) acceptOptions(subprotocol string) *websocket.AcceptOptions {
+	return &websocket.AcceptOptions{
+		Subprotocols:   []string{subprotocol},
+		OriginPatterns: a.allowedOrigins,
+	}
+}
+
 type webSocketAuthLease struct {
 	registry *auth.AuthContextRegistry
 }
@@ -79,6 +79,13 @@ func (h *ChannelRelayHandler) WithTokenValidator(v *auth.TokenValidator) *Channe
 	return h
 }
 
+// WithAllowedOrigins admits upgrades from pages on these other origins.
+// Returns the receiver for chaining.
+func (h *ChannelRelayHandler) WithAllowedOrigins(origins []string) *ChannelRelayHandler {
+	h.allowedOrigins = origins
+	return h
+}
+
 // ServeHTTP upgrades the connection to a multiplexed WebSocket and relays
 // channel messages for all channels belonging to the authenticated user.
 func (h *ChannelRelayHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
@@ -89,9 +96,7 @@ func (h *ChannelRelayHandler) ServeHTTP(w http.ResponseWriter, r *http.Request)
 	}
 
 	// Upgrade to WebSocket.
-	wsConn, err := websocket.Accept(w, r, &websocket.AcceptOptions{
-		Subprotocols: []string{"channel-relay"},
-	})
+	wsConn, err := websocket.Accept(w, r, h.acceptOptions("channel-relay"))
 	if err != nil {
 		slog.Error("channel relay websocket upgrade failed", "user_id", user.ID, "error", err)
 		return
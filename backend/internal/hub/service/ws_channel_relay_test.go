@@ -155,7 +155,7 @@ func TestWebSocketHandlers_InternalAuthFailureReturnsGeneric500(t *testing.T) {
 	for name, handler := range handlers {
 		t.Run(name, func(t *testing.T) {
 			req := httptest.NewRequest(http.MethodGet, "/ws", nil)
-			req.AddCookie(auth.BuildSessionCookie("session", time.Now().Add(time.Hour), false))
+			req.AddCookie(auth.BuildSessionCookie("session", time.Now().Add(time.Hour), false, false))
 			rec := httptest.NewRecorder()
 			handler.ServeHTTP(rec, req)
 
@@ -375,6 +375,36 @@ func TestChannelRelay_Bearer_AcceptsValidToken(t *testing.T) {
 	defer func() { _ = conn.Close(websocket.StatusNormalClosure, "") }()
 }
 
+func TestChannelRelay_UpgradeOnlyFromAllowedOrigins(t *testing.T) {
+	st := hubtestutil.OpenTestStore(t)
+	hubtestutil.CreateTestAdmin(t, st)
+	tv, err := auth.NewTokenValidator(st, []byte("0123456789abcdef0123456789abcdef"))
+	require.NoError(t, err)
+	h := NewChannelRelayHandler(st, workermgr.New(workermgr.DenyAllReach()), channelmgr.New(), newTestAuthContexts(t), nil, false).
+		WithTokenValidator(tv).
+		WithAllowedOrigins([]string{"https://app.example.com"})
+	bearer := mintAdminAPIToken(t, st, tv)
+
+	srv := httptest.NewServer(h)
+	t.Cleanup(srv.Close)
+	url := "ws" + strings.TrimPrefix(srv.URL, "http") + "/ws/channel"
+
+	dial := func(origin string) error {
+		hdr := http.Header{}
+		hdr.Set("Authorization", "Bearer "+bearer)
+		hdr.Set("Origin", origin)
+		ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+		defer cancel()
+		conn, _, err := websocket.Dial(ctx, url, &websocket.DialOptions{HTTPHeader: hdr})
+		if err == nil {
+			_ = conn.Close(websocket.StatusNormalClosure, "")
+		}
+		return err
+	}
+	assert.NoError(t, dial("https://app.example.com"))
+	assert.Error(t, dial("https://evil.example.net"), "a page on an unlisted origin must not open the relay")
+}
+
 func TestChannelRelay_BearerRevocationClosesLiveConnection(t *testing.T) {
 	st := hubtestutil.OpenTestStore(t)
 	hubtestutil.CreateTestAdmin(t, st)
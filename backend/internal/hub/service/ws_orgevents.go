@@ -71,6 +71,13 @@ func (h *OrgEventsHandler) WithTokenValidator(v *auth.TokenValidator) *OrgEvents
 	return h
 }
 
+// WithAllowedOrigins admits upgrades from pages on these other origins.
+// Returns the receiver for chaining.
+func (h *OrgEventsHandler) WithAllowedOrigins(origins []string) *OrgEventsHandler {
+	h.allowedOrigins = origins
+	return h
+}
+
 func (h *OrgEventsHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 	user, err := h.authenticate(r)
 	if err != nil {
@@ -119,9 +126,7 @@ func (h *OrgEventsHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 		}
 	}
 
-	wsConn, err := websocket.Accept(w, r, &websocket.AcceptOptions{
-		Subprotocols: []string{"orgevents-relay"},
-	})
+	wsConn, err := websocket.Accept(w, r, h.acceptOptions("orgevents-relay"))
 	if err != nil {
 		slog.Error("orgevents websocket upgrade failed", "user_id", user.ID, "error", err)
 		return
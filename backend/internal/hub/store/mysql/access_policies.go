@@ -0,0 +1,35 @@
+package mysql
+
+import (
+	"context"
+
+	"github.com/leapmux/leapmux/internal/hub/store"
+	gendb "github.com/leapmux/leapmux/internal/hub/store/mysql/generated/db"
+)
+
+type accessPolicyStore struct {
+	conn *mysqlConn
+}
+
+var _ store.AccessPolicyStore = (*accessPolicyStore)(nil)
+
+func (s *accessPolicyStore) Get(ctx context.Context, orgID string) (*store.AccessPolicy, error) {
+	p, err := s.conn.q.GetAccessPolicy(ctx, orgID)
+	if err != nil {
+		return nil, mapErr(err)
+	}
+	return &store.AccessPolicy{
+		OrgID:     p.OrgID,
+		Rules:     p.Rules,
+		UpdatedBy: p.UpdatedBy,
+		UpdatedAt: p.UpdatedAt.Time,
+	}, nil
+}
+
+func (s *accessPolicyStore) Put(ctx context.Context, p store.PutAccessPolicyParams) error {
+	return mapErr(s.conn.q.PutAccessPolicy(ctx, gendb.PutAccessPolicyParams{
+		OrgID:     p.OrgID,
+		Rules:     p.Rules,
+		UpdatedBy: p.UpdatedBy,
+	}))
+}
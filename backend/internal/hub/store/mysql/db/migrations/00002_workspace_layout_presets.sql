@@ -0,0 +1,33 @@
+-- +goose Up
+
+-- See the sqlite migration for the rationale.
+CREATE TABLE workspace_layout_presets (
+    id           VARCHAR(255) PRIMARY KEY,
+    user_id      VARCHAR(255) NOT NULL,
+    workspace_id VARCHAR(255) NOT NULL,
+    name         VARCHAR(255) NOT NULL,
+    root         LONGBLOB NOT NULL,
+    created_at   DATETIME(3) NOT NULL DEFAULT CURRENT_TIMESTAMP(3),
+    updated_at   DATETIME(3) NOT NULL DEFAULT CURRENT_TIMESTAMP(3),
+    FOREIGN KEY (user_id) REFERENCES users(id) ON DELETE CASCADE,
+    FOREIGN KEY (workspace_id) REFERENCES workspaces(id) ON DELETE CASCADE
+) COLLATE=utf8mb4_bin;
+CREATE UNIQUE INDEX idx_workspace_layout_presets_user_workspace_name ON workspace_layout_presets(user_id, workspace_id, name);
+CREATE INDEX idx_workspace_layout_presets_workspace_id ON workspace_layout_presets(workspace_id);
+
+CREATE TABLE workspace_layout_selections (
+    user_id      VARCHAR(255) NOT NULL,
+    workspace_id VARCHAR(255) NOT NULL,
+    device_class INT NOT NULL,
+    layout_id    VARCHAR(255) NOT NULL,
+    PRIMARY KEY (user_id, workspace_id, device_class),
+    FOREIGN KEY (user_id) REFERENCES users(id) ON DELETE CASCADE,
+    FOREIGN KEY (workspace_id) REFERENCES workspaces(id) ON DELETE CASCADE,
+    FOREIGN KEY (layout_id) REFERENCES workspace_layout_presets(id) ON DELETE CASCADE
+) COLLATE=utf8mb4_bin;
+CREATE INDEX idx_workspace_layout_selections_workspace_id ON workspace_layout_selections(workspace_id);
+CREATE INDEX idx_workspace_layout_selections_layout_id ON workspace_layout_selections(layout_id);
+
+-- +goose Down
+DROP TABLE IF EXISTS workspace_layout_selections;
+DROP TABLE IF EXISTS workspace_layout_presets;
@@ -0,0 +1,34 @@
+-- +goose Up
+
+-- See the sqlite migration for the rationale.
+CREATE TABLE guest_invitations (
+    id              VARCHAR(255) PRIMARY KEY,
+    user_id         VARCHAR(255) NOT NULL,
+    workspace_id    VARCHAR(255) NOT NULL,
+    role            INT NOT NULL,
+    label           VARCHAR(255) NOT NULL DEFAULT '',
+    secret_hash     VARBINARY(64) NOT NULL,
+    auth_generation BIGINT NOT NULL DEFAULT 0,
+    created_at      DATETIME(3) NOT NULL DEFAULT CURRENT_TIMESTAMP(3),
+    last_used_at    DATETIME(3),
+    expires_at      DATETIME(3) NOT NULL,
+    revoked_at      DATETIME(3),
+    FOREIGN KEY (user_id) REFERENCES users(id) ON DELETE CASCADE,
+    FOREIGN KEY (workspace_id) REFERENCES workspaces(id) ON DELETE CASCADE
+) COLLATE=utf8mb4_bin;
+CREATE INDEX idx_guest_invitations_user ON guest_invitations(user_id);
+CREATE INDEX idx_guest_invitations_workspace ON guest_invitations(workspace_id);
+CREATE INDEX idx_guest_invitations_expires_at ON guest_invitations(expires_at);
+
+-- The kind CHECK is unnamed in 00001; it is the table's first CHECK, so
+-- MySQL named it revocation_events_chk_1.
+ALTER TABLE revocation_events DROP CHECK revocation_events_chk_1;
+ALTER TABLE revocation_events ADD CONSTRAINT revocation_events_kind_check
+    CHECK (kind IN ('session', 'api_token', 'api_token_rotation', 'delegation_token', 'user_tokens', 'user_info', 'guest_invitation'));
+
+-- +goose Down
+DELETE FROM revocation_events WHERE kind = 'guest_invitation';
+ALTER TABLE revocation_events DROP CHECK revocation_events_kind_check;
+ALTER TABLE revocation_events ADD CONSTRAINT revocation_events_chk_1
+    CHECK (kind IN ('session', 'api_token', 'api_token_rotation', 'delegation_token', 'user_tokens', 'user_info'));
+DROP TABLE IF EXISTS guest_invitations;
@@ -0,0 +1,19 @@
+-- +goose Up
+
+-- See the sqlite migration for the rationale.
+CREATE TABLE repos (
+    id             VARCHAR(255) PRIMARY KEY,
+    org_id         VARCHAR(255) NOT NULL,
+    name           VARCHAR(255) NOT NULL,
+    url            TEXT NOT NULL,
+    default_branch VARCHAR(255) NOT NULL DEFAULT '',
+    credential_ref VARCHAR(255) NOT NULL DEFAULT '',
+    worker_labels  TEXT NOT NULL,
+    created_at     DATETIME(3) NOT NULL DEFAULT CURRENT_TIMESTAMP(3),
+    updated_at     DATETIME(3) NOT NULL DEFAULT CURRENT_TIMESTAMP(3),
+    FOREIGN KEY (org_id) REFERENCES orgs(id) ON DELETE CASCADE
+) COLLATE=utf8mb4_bin;
+CREATE UNIQUE INDEX idx_repos_org_name ON repos(org_id, name);
+
+-- +goose Down
+DROP TABLE IF EXISTS repos;
@@ -0,0 +1,30 @@
+-- +goose Up
+
+-- See the sqlite migration for the rationale.
+CREATE TABLE system_prompts (
+    id             VARCHAR(255) PRIMARY KEY,
+    org_id         VARCHAR(255) NOT NULL,
+    owner_user_id  VARCHAR(255) NOT NULL,
+    name           VARCHAR(255) NOT NULL,
+    shared         BOOLEAN NOT NULL DEFAULT FALSE,
+    latest_version BIGINT NOT NULL DEFAULT 1,
+    created_at     DATETIME(3) NOT NULL DEFAULT CURRENT_TIMESTAMP(3),
+    updated_at     DATETIME(3) NOT NULL DEFAULT CURRENT_TIMESTAMP(3),
+    FOREIGN KEY (org_id) REFERENCES orgs(id) ON DELETE CASCADE,
+    FOREIGN KEY (owner_user_id) REFERENCES users(id) ON DELETE CASCADE
+) COLLATE=utf8mb4_bin;
+CREATE UNIQUE INDEX idx_system_prompts_owner_name ON system_prompts(org_id, owner_user_id, name);
+
+CREATE TABLE system_prompt_versions (
+    prompt_id  VARCHAR(255) NOT NULL,
+    version    BIGINT NOT NULL,
+    content    MEDIUMTEXT NOT NULL,
+    created_by VARCHAR(255) NOT NULL,
+    created_at DATETIME(3) NOT NULL DEFAULT CURRENT_TIMESTAMP(3),
+    PRIMARY KEY (prompt_id, version),
+    FOREIGN KEY (prompt_id) REFERENCES system_prompts(id) ON DELETE CASCADE
+) COLLATE=utf8mb4_bin;
+
+-- +goose Down
+DROP TABLE IF EXISTS system_prompt_versions;
+DROP TABLE IF EXISTS system_prompts;
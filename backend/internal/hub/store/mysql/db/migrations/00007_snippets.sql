@@ -0,0 +1,17 @@
+-- +goose Up
+
+-- See the sqlite migration for the rationale.
+CREATE TABLE snippets (
+    id          VARCHAR(255) PRIMARY KEY,
+    user_id     VARCHAR(255) NOT NULL,
+    name        VARCHAR(255) NOT NULL,
+    description VARCHAR(1024) NOT NULL DEFAULT '',
+    body        MEDIUMTEXT NOT NULL,
+    created_at  DATETIME(3) NOT NULL DEFAULT CURRENT_TIMESTAMP(3),
+    updated_at  DATETIME(3) NOT NULL DEFAULT CURRENT_TIMESTAMP(3),
+    FOREIGN KEY (user_id) REFERENCES users(id) ON DELETE CASCADE
+) COLLATE=utf8mb4_bin;
+CREATE UNIQUE INDEX idx_snippets_user_name ON snippets(user_id, name);
+
+-- +goose Down
+DROP TABLE IF EXISTS snippets;
@@ -0,0 +1,18 @@
+-- +goose Up
+
+-- See the sqlite migration for the rationale.
+CREATE TABLE model_credentials (
+    id            VARCHAR(255) PRIMARY KEY,
+    org_id        VARCHAR(255) NOT NULL,
+    name          VARCHAR(255) NOT NULL,
+    kind          INT NOT NULL,
+    workspace_ids TEXT NOT NULL,
+    secret        BLOB NOT NULL,
+    created_at    DATETIME(3) NOT NULL DEFAULT CURRENT_TIMESTAMP(3),
+    updated_at    DATETIME(3) NOT NULL DEFAULT CURRENT_TIMESTAMP(3),
+    FOREIGN KEY (org_id) REFERENCES orgs(id) ON DELETE CASCADE
+) COLLATE=utf8mb4_bin;
+CREATE UNIQUE INDEX idx_model_credentials_org_name ON model_credentials(org_id, name);
+
+-- +goose Down
+DROP TABLE IF EXISTS model_credentials;
@@ -0,0 +1,27 @@
+-- +goose Up
+
+-- See the sqlite migration for the rationale.
+CREATE TABLE announcements (
+    id         VARCHAR(255) PRIMARY KEY,
+    title      TEXT NOT NULL,
+    body       TEXT NOT NULL,
+    severity   INT NOT NULL,
+    starts_at  DATETIME(3) NOT NULL,
+    ends_at    DATETIME(3),
+    created_by VARCHAR(255) NOT NULL,
+    created_at DATETIME(3) NOT NULL DEFAULT CURRENT_TIMESTAMP(3)
+) COLLATE=utf8mb4_bin;
+
+CREATE TABLE announcement_acks (
+    announcement_id VARCHAR(255) NOT NULL,
+    user_id         VARCHAR(255) NOT NULL,
+    acknowledged_at DATETIME(3) NOT NULL DEFAULT CURRENT_TIMESTAMP(3),
+    PRIMARY KEY (announcement_id, user_id),
+    FOREIGN KEY (announcement_id) REFERENCES announcements(id) ON DELETE CASCADE,
+    FOREIGN KEY (user_id) REFERENCES users(id) ON DELETE CASCADE
+) COLLATE=utf8mb4_bin;
+CREATE INDEX idx_announcement_acks_user ON announcement_acks(user_id);
+
+-- +goose Down
+DROP TABLE IF EXISTS announcement_acks;
+DROP TABLE IF EXISTS announcements;
@@ -0,0 +1,15 @@
+-- +goose Up
+
+-- See the sqlite migration for the rationale.
+CREATE TABLE emergency_stops (
+    org_id     VARCHAR(255) NOT NULL,
+    worker_id  VARCHAR(255) NOT NULL DEFAULT '',
+    reason     TEXT NOT NULL,
+    stopped_by VARCHAR(255) NOT NULL,
+    stopped_at DATETIME(3) NOT NULL DEFAULT CURRENT_TIMESTAMP(3),
+    PRIMARY KEY (org_id, worker_id),
+    FOREIGN KEY (org_id) REFERENCES orgs(id) ON DELETE CASCADE
+) COLLATE=utf8mb4_bin;
+
+-- +goose Down
+DROP TABLE IF EXISTS emergency_stops;
@@ -0,0 +1,13 @@
+-- +goose Up
+
+-- See the sqlite migration for the rationale.
+CREATE TABLE access_policies (
+    org_id     VARCHAR(255) NOT NULL PRIMARY KEY,
+    rules      TEXT NOT NULL,
+    updated_by VARCHAR(255) NOT NULL,
+    updated_at DATETIME(3) NOT NULL DEFAULT CURRENT_TIMESTAMP(3),
+    FOREIGN KEY (org_id) REFERENCES orgs(id) ON DELETE CASCADE
+) COLLATE=utf8mb4_bin;
+
+-- +goose Down
+DROP TABLE IF EXISTS access_policies;
@@ -0,0 +1,11 @@
+-- name: PutAccessPolicy :exec
+INSERT INTO access_policies (org_id, rules, updated_by)
+VALUES (?, ?, ?)
+ON DUPLICATE KEY UPDATE
+  rules = VALUES(rules),
+  updated_by = VALUES(updated_by),
+  updated_at = NOW(3);
+
+-- name: GetAccessPolicy :one
+SELECT * FROM access_policies
+WHERE org_id = ?;
@@ -0,0 +1,21 @@
+-- name: PutEmergencyStop :exec
+-- Stopping an org or worker that is already stopped replaces the stop.
+INSERT INTO emergency_stops (org_id, worker_id, reason, stopped_by)
+VALUES (?, ?, ?, ?)
+ON DUPLICATE KEY UPDATE
+  reason = VALUES(reason),
+  stopped_by = VALUES(stopped_by),
+  stopped_at = NOW(3);
+
+-- name: GetEmergencyStop :one
+SELECT * FROM emergency_stops
+WHERE org_id = ? AND worker_id = ?;
+
+-- name: ListEmergencyStopsByOrg :many
+SELECT * FROM emergency_stops
+WHERE org_id = ?
+ORDER BY worker_id;
+
+-- name: DeleteEmergencyStop :execresult
+DELETE FROM emergency_stops
+WHERE org_id = ? AND worker_id = ?;
@@ -0,0 +1,54 @@
+-- name: CreateGuestInvitation :exec
+INSERT INTO guest_invitations (
+    id, user_id, workspace_id, role, label, secret_hash, expires_at, auth_generation
+) VALUES (
+    sqlc.arg(id),
+    sqlc.arg(user_id),
+    sqlc.arg(workspace_id),
+    sqlc.arg(role),
+    sqlc.arg(label),
+    sqlc.arg(secret_hash),
+    sqlc.arg(expires_at),
+    (SELECT auth_generation FROM users WHERE users.id = sqlc.arg(user_id))
+);
+
+-- name: GetGuestInvitationByID :one
+SELECT * FROM guest_invitations WHERE id = ?;
+
+-- name: ListActiveGuestInvitationsByWorkspace :many
+SELECT * FROM guest_invitations
+WHERE workspace_id = ?
+  AND revoked_at IS NULL
+  AND expires_at > NOW(3)
+ORDER BY created_at DESC, id DESC;
+
+-- name: ListGuestInvitationsByUser :many
+-- Revoked and expired links included, for the user data export.
+SELECT * FROM guest_invitations
+WHERE user_id = ?
+ORDER BY created_at DESC, id DESC;
+
+-- name: ListExpiredLiveGuestInvitationIDs :many
+SELECT id FROM guest_invitations
+WHERE revoked_at IS NULL AND expires_at <= sqlc.arg(cutoff)
+ORDER BY expires_at
+LIMIT ?;
+
+-- name: TouchGuestInvitation :exec
+UPDATE guest_invitations
+SET last_used_at = NOW(3)
+WHERE id = ?;
+
+-- name: GetLiveGuestInvitationForUpdate :one
+SELECT id, user_id FROM guest_invitations
+WHERE id = ? AND revoked_at IS NULL
+FOR UPDATE;
+
+-- name: RevokeGuestInvitationAt :execresult
+UPDATE guest_invitations
+SET revoked_at = sqlc.arg(revoked_at)
+WHERE id = sqlc.arg(id) AND revoked_at IS NULL;
+
+-- name: DeleteRevokedGuestInvitationsBefore :execresult
+DELETE FROM guest_invitations
+WHERE revoked_at IS NOT NULL AND revoked_at < ?;
@@ -0,0 +1,31 @@
+-- name: CreateModelCredential :exec
+INSERT INTO model_credentials (id, org_id, name, kind, workspace_ids, secret)
+VALUES (?, ?, ?, ?, ?, ?);
+
+-- name: GetModelCredential :one
+SELECT * FROM model_credentials
+WHERE id = ? AND org_id = ?;
+
+-- name: ListModelCredentialsByOrg :many
+SELECT * FROM model_credentials
+WHERE org_id = ?
+ORDER BY name, id;
+
+-- name: ListAllModelCredentials :many
+SELECT * FROM model_credentials
+ORDER BY id;
+
+-- name: UpdateModelCredential :exec
+UPDATE model_credentials SET
+  workspace_ids = ?,
+  secret = ?,
+  updated_at = NOW(3)
+WHERE id = ? AND org_id = ?;
+
+-- name: UpdateModelCredentialSecret :exec
+UPDATE model_credentials SET secret = ?
+WHERE id = ?;
+
+-- name: DeleteModelCredential :execresult
+DELETE FROM model_credentials
+WHERE id = ? AND org_id = ?;
@@ -0,0 +1,26 @@
+-- name: CreateRepo :exec
+INSERT INTO repos (id, org_id, name, url, default_branch, credential_ref, worker_labels)
+VALUES (?, ?, ?, ?, ?, ?, ?);
+
+-- name: GetRepo :one
+SELECT * FROM repos
+WHERE id = ? AND org_id = ?;
+
+-- name: ListReposByOrg :many
+SELECT * FROM repos
+WHERE org_id = ?
+ORDER BY name, id;
+
+-- name: UpdateRepo :exec
+UPDATE repos SET
+  name = ?,
+  url = ?,
+  default_branch = ?,
+  credential_ref = ?,
+  worker_labels = ?,
+  updated_at = NOW(3)
+WHERE id = ? AND org_id = ?;
+
+-- name: DeleteRepo :execresult
+DELETE FROM repos
+WHERE id = ? AND org_id = ?;
@@ -0,0 +1,28 @@
+-- name: CreateSnippet :exec
+INSERT INTO snippets (id, user_id, name, description, body)
+VALUES (?, ?, ?, ?, ?);
+
+-- name: GetSnippet :one
+SELECT * FROM snippets
+WHERE id = ? AND user_id = ?;
+
+-- name: GetSnippetByName :one
+SELECT * FROM snippets
+WHERE user_id = ? AND name = ?;
+
+-- name: ListSnippetsByUser :many
+SELECT * FROM snippets
+WHERE user_id = ?
+ORDER BY name, id;
+
+-- name: UpdateSnippet :exec
+UPDATE snippets SET
+  name = ?,
+  description = ?,
+  body = ?,
+  updated_at = NOW(3)
+WHERE id = ? AND user_id = ?;
+
+-- name: DeleteSnippet :execresult
+DELETE FROM snippets
+WHERE id = ? AND user_id = ?;
@@ -0,0 +1,52 @@
+package mysql
+
+import (
+	"context"
+
+	"github.com/leapmux/leapmux/internal/hub/store"
+	gendb "github.com/leapmux/leapmux/internal/hub/store/mysql/generated/db"
+)
+
+type emergencyStopStore struct {
+	conn *mysqlConn
+}
+
+var _ store.EmergencyStopStore = (*emergencyStopStore)(nil)
+
+func fromDBEmergencyStop(e gendb.EmergencyStop) *store.EmergencyStop {
+	return &store.EmergencyStop{
+		OrgID:     e.OrgID,
+		WorkerID:  e.WorkerID,
+		Reason:    e.Reason,
+		StoppedBy: e.StoppedBy,
+		StoppedAt: e.StoppedAt.Time,
+	}
+}
+
+func (s *emergencyStopStore) Put(ctx context.Context, p store.PutEmergencyStopParams) (*store.EmergencyStop, error) {
+	if err := s.conn.q.PutEmergencyStop(ctx, gendb.PutEmergencyStopParams{
+		OrgID:     p.OrgID,
+		WorkerID:  p.WorkerID,
+		Reason:    p.Reason,
+		StoppedBy: p.StoppedBy,
+	}); err != nil {
+		return nil, mapErr(err)
+	}
+	e, err := s.conn.q.GetEmergencyStop(ctx, gendb.GetEmergencyStopParams{OrgID: p.OrgID, WorkerID: p.WorkerID})
+	if err != nil {
+		return nil, mapErr(err)
+	}
+	return fromDBEmergencyStop(e), nil
+}
+
+func (s *emergencyStopStore) ListByOrg(ctx context.Context, orgID string) ([]store.EmergencyStop, error) {
+	rows, err := s.conn.q.ListEmergencyStopsByOrg(ctx, orgID)
+	if err != nil {
+		return nil, mapErr(err)
+	}
+	return store.MapSlice(rows, func(e gendb.EmergencyStop) store.EmergencyStop { return *fromDBEmergencyStop(e) }), nil
+}
+
+func (s *emergencyStopStore) Delete(ctx context.Context, p store.DeleteEmergencyStopParams) (int64, error) {
+	return rowsAffected(s.conn.q.DeleteEmergencyStop(ctx, gendb.DeleteEmergencyStopParams{OrgID: p.OrgID, WorkerID: p.WorkerID}))
+}
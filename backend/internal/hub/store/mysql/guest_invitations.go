@@ -0,0 +1,118 @@
+package mysql
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/leapmux/leapmux/internal/hub/store"
+	gendb "github.com/leapmux/leapmux/internal/hub/store/mysql/generated/db"
+	"github.com/leapmux/leapmux/internal/util/sqltime"
+	"github.com/leapmux/leapmux/internal/util/userid"
+)
+
+type guestInvitationStore struct{ conn *mysqlConn }
+
+var _ store.GuestInvitationStore = (*guestInvitationStore)(nil)
+
+func fromDBGuestInvitation(g gendb.GuestInvitation) store.GuestInvitation {
+	return store.GuestInvitation{
+		ID:             g.ID,
+		UserID:         g.UserID,
+		WorkspaceID:    g.WorkspaceID,
+		Role:           g.Role,
+		Label:          g.Label,
+		SecretHash:     g.SecretHash,
+		AuthGeneration: g.AuthGeneration,
+		CreatedAt:      g.CreatedAt.Time,
+		LastUsedAt:     g.LastUsedAt.Ptr(),
+		ExpiresAt:      g.ExpiresAt.Time,
+		RevokedAt:      g.RevokedAt.Ptr(),
+	}
+}
+
+func (s *guestInvitationStore) Create(ctx context.Context, p store.CreateGuestInvitationParams) error {
+	return (&mysqlStore{conn: s.conn}).RunInUserAuthTransaction(ctx, p.UserID, func(tx store.Store) error {
+		return mapErr(tx.(*mysqlStore).conn.q.CreateGuestInvitation(ctx, gendb.CreateGuestInvitationParams{
+			ID:          p.ID,
+			UserID:      p.UserID.String(),
+			WorkspaceID: p.WorkspaceID,
+			Role:        p.Role,
+			Label:       p.Label,
+			SecretHash:  p.SecretHash,
+			ExpiresAt:   sqltime.NewMySQLTime(p.ExpiresAt),
+		}))
+	})
+}
+
+func (s *guestInvitationStore) GetByID(ctx context.Context, id string) (*store.GuestInvitation, error) {
+	g, err := s.conn.q.GetGuestInvitationByID(ctx, id)
+	if err != nil {
+		return nil, mapErr(err)
+	}
+	out := fromDBGuestInvitation(g)
+	return &out, nil
+}
+
+func (s *guestInvitationStore) ListActiveByWorkspace(ctx context.Context, workspaceID string) ([]store.GuestInvitation, error) {
+	rows, err := s.conn.q.ListActiveGuestInvitationsByWorkspace(ctx, workspaceID)
+	if err != nil {
+		return nil, mapErr(err)
+	}
+	return store.MapSlice(rows, fromDBGuestInvitation), nil
+}
+
+func (s *guestInvitationStore) ListByUser(ctx context.Context, userID userid.UserID) ([]store.GuestInvitation, error) {
+	owner, ok := store.OwnerFilter(userID)
+	if !ok {
+		// An unminted caller owns nothing; binding "" would MATCH every
+		// blank-owner row rather than none. See store.OwnerFilter.
+		return nil, nil
+	}
+	rows, err := s.conn.q.ListGuestInvitationsByUser(ctx, owner)
+	if err != nil {
+		return nil, mapErr(err)
+	}
+	return store.MapSlice(rows, fromDBGuestInvitation), nil
+}
+
+func (s *guestInvitationStore) ListExpiredLiveIDs(ctx context.Context, cutoff time.Time, limit int) ([]string, error) {
+	ids, err := s.conn.q.ListExpiredLiveGuestInvitationIDs(ctx, gendb.ListExpiredLiveGuestInvitationIDsParams{
+		Cutoff: sqltime.NewMySQLTime(cutoff),
+		Limit:  int32(limit),
+	})
+	return ids, mapErr(err)
+}
+
+func (s *guestInvitationStore) Touch(ctx context.Context, id string) error {
+	return mapErr(s.conn.q.TouchGuestInvitation(ctx, id))
+}
+
+func (s *guestInvitationStore) Revoke(ctx context.Context, id string) (int64, error) {
+	return store.RunCredentialMutation(ctx, s.conn.withTransaction, func(ctx context.Context, conn *mysqlConn) (*store.CredentialEvent, error) {
+		row, err := conn.q.GetLiveGuestInvitationForUpdate(ctx, id)
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, nil
+		}
+		if err != nil {
+			return nil, mapErr(err)
+		}
+		revokedAt, err := mysqlRevocationNow(ctx, conn)
+		if err != nil {
+			return nil, err
+		}
+		n, err := rowsAffected(conn.q.RevokeGuestInvitationAt(ctx, gendb.RevokeGuestInvitationAtParams{
+			ID:        row.ID,
+			RevokedAt: sqltime.MySQLNullTimeOf(revokedAt),
+		}))
+		if err != nil {
+			return nil, err
+		}
+		if n != 1 {
+			return nil, fmt.Errorf("revoke guest invitation %q: updated %d rows after locking live row", row.ID, n)
+		}
+		return &store.CredentialEvent{Kind: store.RevocationEventKindGuestInvitation, SubjectID: row.ID, UserID: row.UserID, At: revokedAt}, nil
+	}, emitCredentialEvent)
+}
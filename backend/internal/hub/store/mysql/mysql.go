@@ -149,6 +149,33 @@ func (s *mysqlStore) WorkspaceSections() store.WorkspaceSectionStore {
 func (s *mysqlStore) WorkspaceSectionItems() store.WorkspaceSectionItemStore {
 	return &workspaceSectionItemStore{conn: s.conn}
 }
+func (s *mysqlStore) WorkspaceLayoutPresets() store.WorkspaceLayoutPresetStore {
+	return &workspaceLayoutPresetStore{conn: s.conn}
+}
+func (s *mysqlStore) Repos() store.RepoStore {
+	return &repoStore{conn: s.conn}
+}
+func (s *mysqlStore) GitCredentials() store.GitCredentialStore {
+	return &gitCredentialStore{conn: s.conn}
+}
+func (s *mysqlStore) ModelCredentials() store.ModelCredentialStore {
+	return &modelCredentialStore{conn: s.conn}
+}
+func (s *mysqlStore) SystemPrompts() store.SystemPromptStore {
+	return &systemPromptStore{conn: s.conn}
+}
+func (s *mysqlStore) Snippets() store.SnippetStore {
+	return &snippetStore{conn: s.conn}
+}
+func (s *mysqlStore) Announcements() store.AnnouncementStore {
+	return &announcementStore{conn: s.conn}
+}
+func (s *mysqlStore) EmergencyStops() store.EmergencyStopStore {
+	return &emergencyStopStore{conn: s.conn}
+}
+func (s *mysqlStore) AccessPolicies() store.AccessPolicyStore {
+	return &accessPolicyStore{conn: s.conn}
+}
 func (s *mysqlStore) OAuthProviders() store.OAuthProviderStore {
 	return &oauthProviderStore{conn: s.conn}
 }
@@ -164,6 +191,9 @@ func (s *mysqlStore) APITokens() store.APITokenStore { return &apiTokenStore{con
 func (s *mysqlStore) DelegationTokens() store.DelegationTokenStore {
 	return &delegationTokenStore{conn: s.conn}
 }
+func (s *mysqlStore) GuestInvitations() store.GuestInvitationStore {
+	return &guestInvitationStore{conn: s.conn}
+}
 func (s *mysqlStore) RevocationEvents() store.RevocationEventStore {
 	return newRevocationEventStore(s.conn)
 }
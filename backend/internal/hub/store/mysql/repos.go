@@ -0,0 +1,81 @@
+package mysql
+
+import (
+	"context"
+
+	"github.com/leapmux/leapmux/internal/hub/store"
+	gendb "github.com/leapmux/leapmux/internal/hub/store/mysql/generated/db"
+	"github.com/leapmux/leapmux/internal/hub/store/sqlutil"
+)
+
+type repoStore struct {
+	conn *mysqlConn
+}
+
+var _ store.RepoStore = (*repoStore)(nil)
+
+func fromDBRepo(r gendb.Repo) *store.Repo {
+	return &store.Repo{
+		ID:            r.ID,
+		OrgID:         r.OrgID,
+		Name:          r.Name,
+		URL:           r.Url,
+		DefaultBranch: r.DefaultBranch,
+		CredentialRef: r.CredentialRef,
+		WorkerLabels:  sqlutil.SplitLabels(r.WorkerLabels),
+		CreatedAt:     r.CreatedAt.Time,
+		UpdatedAt:     r.UpdatedAt.Time,
+	}
+}
+
+func (s *repoStore) Create(ctx context.Context, p store.CreateRepoParams) (*store.Repo, error) {
+	if err := s.conn.q.CreateRepo(ctx, gendb.CreateRepoParams{
+		ID:            p.ID,
+		OrgID:         p.OrgID,
+		Name:          p.Name,
+		Url:           p.URL,
+		DefaultBranch: p.DefaultBranch,
+		CredentialRef: p.CredentialRef,
+		WorkerLabels:  sqlutil.JoinLabels(p.WorkerLabels),
+	}); err != nil {
+		return nil, mapErr(err)
+	}
+	return s.Get(ctx, store.GetRepoParams{ID: p.ID, OrgID: p.OrgID})
+}
+
+func (s *repoStore) Get(ctx context.Context, p store.GetRepoParams) (*store.Repo, error) {
+	r, err := s.conn.q.GetRepo(ctx, gendb.GetRepoParams{ID: p.ID, OrgID: p.OrgID})
+	if err != nil {
+		return nil, mapErr(err)
+	}
+	return fromDBRepo(r), nil
+}
+
+func (s *repoStore) ListByOrg(ctx context.Context, orgID string) ([]store.Repo, error) {
+	rows, err := s.conn.q.ListReposByOrg(ctx, orgID)
+	if err != nil {
+		return nil, mapErr(err)
+	}
+	return store.MapSlice(rows, func(r gendb.Repo) store.Repo { return *fromDBRepo(r) }), nil
+}
+
+func (s *repoStore) Update(ctx context.Context, p store.UpdateRepoParams) (*store.Repo, error) {
+	// Existence is read back rather than taken from the affected-row count,
+	// which MySQL reports as zero for an update that changed nothing.
+	if err := s.conn.q.UpdateRepo(ctx, gendb.UpdateRepoParams{
+		Name:          p.Name,
+		Url:           p.URL,
+		DefaultBranch: p.DefaultBranch,
+		CredentialRef: p.CredentialRef,
+		WorkerLabels:  sqlutil.JoinLabels(p.WorkerLabels),
+		ID:            p.ID,
+		OrgID:         p.OrgID,
+	}); err != nil {
+		return nil, mapErr(err)
+	}
+	return s.Get(ctx, store.GetRepoParams{ID: p.ID, OrgID: p.OrgID})
+}
+
+func (s *repoStore) Delete(ctx context.Context, p store.GetRepoParams) (int64, error) {
+	return rowsAffected(s.conn.q.DeleteRepo(ctx, gendb.DeleteRepoParams{ID: p.ID, OrgID: p.OrgID}))
+}
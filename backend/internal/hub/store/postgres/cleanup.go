@@ -70,6 +70,10 @@ func (s *cleanupStore) DeleteExpiredDelegationTokensBefore(ctx context.Context,
 	return s.conn.q.DeleteExpiredDelegationTokensBefore(ctx, pgtime.New(cutoff))
 }
 
+func (s *cleanupStore) DeleteRevokedGuestInvitationsBefore(ctx context.Context, cutoff time.Time) (int64, error) {
+	return s.conn.q.DeleteRevokedGuestInvitationsBefore(ctx, pgtime.NullOf(cutoff))
+}
+
 func (s *cleanupStore) CompactPublishedRevocationEvents(
 	ctx context.Context,
 	p store.CompactRevocationEventsParams,
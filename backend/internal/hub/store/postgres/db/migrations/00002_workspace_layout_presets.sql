@@ -0,0 +1,28 @@
+-- +goose Up
+
+-- See the sqlite migration for the rationale.
+CREATE TABLE workspace_layout_presets (
+    id           TEXT COLLATE "C" PRIMARY KEY,
+    user_id      TEXT COLLATE "C" NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    workspace_id TEXT COLLATE "C" NOT NULL REFERENCES workspaces(id) ON DELETE CASCADE,
+    name         TEXT COLLATE "C" NOT NULL,
+    root         BYTEA NOT NULL,
+    created_at   TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    updated_at   TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
+CREATE UNIQUE INDEX idx_workspace_layout_presets_user_workspace_name ON workspace_layout_presets(user_id, workspace_id, name);
+CREATE INDEX idx_workspace_layout_presets_workspace_id ON workspace_layout_presets(workspace_id);
+
+CREATE TABLE workspace_layout_selections (
+    user_id      TEXT COLLATE "C" NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    workspace_id TEXT COLLATE "C" NOT NULL REFERENCES workspaces(id) ON DELETE CASCADE,
+    device_class INTEGER NOT NULL,
+    layout_id    TEXT COLLATE "C" NOT NULL REFERENCES workspace_layout_presets(id) ON DELETE CASCADE,
+    PRIMARY KEY (user_id, workspace_id, device_class)
+);
+CREATE INDEX idx_workspace_layout_selections_workspace_id ON workspace_layout_selections(workspace_id);
+CREATE INDEX idx_workspace_layout_selections_layout_id ON workspace_layout_selections(layout_id);
+
+-- +goose Down
+DROP TABLE IF EXISTS workspace_layout_selections;
+DROP TABLE IF EXISTS workspace_layout_presets;
@@ -0,0 +1,34 @@
+-- +goose Up
+
+-- See the sqlite migration for the rationale.
+CREATE TABLE guest_invitations (
+    id              TEXT COLLATE "C" PRIMARY KEY,
+    user_id         TEXT COLLATE "C" NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    workspace_id    TEXT COLLATE "C" NOT NULL REFERENCES workspaces(id) ON DELETE CASCADE,
+    role            INTEGER NOT NULL,
+    label           TEXT COLLATE "C" NOT NULL DEFAULT '',
+    secret_hash     BYTEA NOT NULL,
+    auth_generation BIGINT NOT NULL DEFAULT 0,
+    created_at      TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    last_used_at    TIMESTAMPTZ,
+    expires_at      TIMESTAMPTZ NOT NULL,
+    revoked_at      TIMESTAMPTZ
+);
+CREATE INDEX idx_guest_invitations_user ON guest_invitations(user_id);
+CREATE INDEX idx_guest_invitations_workspace ON guest_invitations(workspace_id);
+CREATE INDEX idx_guest_invitations_expires_at ON guest_invitations(expires_at) WHERE revoked_at IS NULL;
+
+-- The column CHECK is unnamed in 00001, so drop it under both default
+-- names: PostgreSQL's and CockroachDB's.
+ALTER TABLE revocation_events DROP CONSTRAINT IF EXISTS revocation_events_kind_check;
+ALTER TABLE revocation_events DROP CONSTRAINT IF EXISTS check_kind;
+ALTER TABLE revocation_events ADD CONSTRAINT revocation_events_kind_check
+    CHECK (kind IN ('session', 'api_token', 'api_token_rotation', 'delegation_token', 'user_tokens', 'user_info', 'guest_invitation'));
+
+-- +goose Down
+DELETE FROM revocation_events WHERE kind = 'guest_invitation';
+ALTER TABLE revocation_events DROP CONSTRAINT IF EXISTS revocation_events_kind_check;
+ALTER TABLE revocation_events DROP CONSTRAINT IF EXISTS check_kind;
+ALTER TABLE revocation_events ADD CONSTRAINT revocation_events_kind_check
+    CHECK (kind IN ('session', 'api_token', 'api_token_rotation', 'delegation_token', 'user_tokens', 'user_info'));
+DROP TABLE IF EXISTS guest_invitations;
@@ -0,0 +1,18 @@
+-- +goose Up
+
+-- See the sqlite migration for the rationale.
+CREATE TABLE repos (
+    id             TEXT COLLATE "C" PRIMARY KEY,
+    org_id         TEXT COLLATE "C" NOT NULL REFERENCES orgs(id) ON DELETE CASCADE,
+    name           TEXT COLLATE "C" NOT NULL,
+    url            TEXT NOT NULL,
+    default_branch TEXT NOT NULL DEFAULT '',
+    credential_ref TEXT NOT NULL DEFAULT '',
+    worker_labels  TEXT NOT NULL DEFAULT '',
+    created_at     TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    updated_at     TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
+CREATE UNIQUE INDEX idx_repos_org_name ON repos(org_id, name);
+
+-- +goose Down
+DROP TABLE IF EXISTS repos;
@@ -0,0 +1,27 @@
+-- +goose Up
+
+-- See the sqlite migration for the rationale.
+CREATE TABLE system_prompts (
+    id             TEXT COLLATE "C" PRIMARY KEY,
+    org_id         TEXT COLLATE "C" NOT NULL REFERENCES orgs(id) ON DELETE CASCADE,
+    owner_user_id  TEXT COLLATE "C" NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    name           TEXT COLLATE "C" NOT NULL,
+    shared         BOOLEAN NOT NULL DEFAULT FALSE,
+    latest_version BIGINT NOT NULL DEFAULT 1,
+    created_at     TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    updated_at     TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
+CREATE UNIQUE INDEX idx_system_prompts_owner_name ON system_prompts(org_id, owner_user_id, name);
+
+CREATE TABLE system_prompt_versions (
+    prompt_id  TEXT COLLATE "C" NOT NULL REFERENCES system_prompts(id) ON DELETE CASCADE,
+    version    BIGINT NOT NULL,
+    content    TEXT NOT NULL,
+    created_by TEXT COLLATE "C" NOT NULL,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    PRIMARY KEY (prompt_id, version)
+);
+
+-- +goose Down
+DROP TABLE IF EXISTS system_prompt_versions;
+DROP TABLE IF EXISTS system_prompts;
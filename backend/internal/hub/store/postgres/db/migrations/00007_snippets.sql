@@ -0,0 +1,16 @@
+-- +goose Up
+
+-- See the sqlite migration for the rationale.
+CREATE TABLE snippets (
+    id          TEXT COLLATE "C" PRIMARY KEY,
+    user_id     TEXT COLLATE "C" NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    name        TEXT COLLATE "C" NOT NULL,
+    description TEXT NOT NULL DEFAULT '',
+    body        TEXT NOT NULL,
+    created_at  TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    updated_at  TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
+CREATE UNIQUE INDEX idx_snippets_user_name ON snippets(user_id, name);
+
+-- +goose Down
+DROP TABLE IF EXISTS snippets;
@@ -0,0 +1,17 @@
+-- +goose Up
+
+-- See the sqlite migration for the rationale.
+CREATE TABLE model_credentials (
+    id            TEXT COLLATE "C" PRIMARY KEY,
+    org_id        TEXT COLLATE "C" NOT NULL REFERENCES orgs(id) ON DELETE CASCADE,
+    name          TEXT COLLATE "C" NOT NULL,
+    kind          INTEGER NOT NULL,
+    workspace_ids TEXT COLLATE "C" NOT NULL DEFAULT '',
+    secret        BYTEA NOT NULL,
+    created_at    TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    updated_at    TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
+CREATE UNIQUE INDEX idx_model_credentials_org_name ON model_credentials(org_id, name);
+
+-- +goose Down
+DROP TABLE IF EXISTS model_credentials;
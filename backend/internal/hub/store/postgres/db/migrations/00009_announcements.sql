@@ -0,0 +1,25 @@
+-- +goose Up
+
+-- See the sqlite migration for the rationale.
+CREATE TABLE announcements (
+    id         TEXT COLLATE "C" PRIMARY KEY,
+    title      TEXT NOT NULL,
+    body       TEXT NOT NULL,
+    severity   INTEGER NOT NULL,
+    starts_at  TIMESTAMPTZ NOT NULL,
+    ends_at    TIMESTAMPTZ,
+    created_by TEXT COLLATE "C" NOT NULL,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
+
+CREATE TABLE announcement_acks (
+    announcement_id TEXT COLLATE "C" NOT NULL REFERENCES announcements(id) ON DELETE CASCADE,
+    user_id         TEXT COLLATE "C" NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    acknowledged_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    PRIMARY KEY (announcement_id, user_id)
+);
+CREATE INDEX idx_announcement_acks_user ON announcement_acks(user_id);
+
+-- +goose Down
+DROP TABLE IF EXISTS announcement_acks;
+DROP TABLE IF EXISTS announcements;
@@ -0,0 +1,14 @@
+-- +goose Up
+
+-- See the sqlite migration for the rationale.
+CREATE TABLE emergency_stops (
+    org_id     TEXT COLLATE "C" NOT NULL REFERENCES orgs(id) ON DELETE CASCADE,
+    worker_id  TEXT COLLATE "C" NOT NULL DEFAULT '',
+    reason     TEXT NOT NULL DEFAULT '',
+    stopped_by TEXT COLLATE "C" NOT NULL,
+    stopped_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    PRIMARY KEY (org_id, worker_id)
+);
+
+-- +goose Down
+DROP TABLE IF EXISTS emergency_stops;
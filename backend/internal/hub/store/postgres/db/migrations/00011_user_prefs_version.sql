@@ -0,0 +1,7 @@
+-- +goose Up
+
+-- See the sqlite migration for the rationale.
+ALTER TABLE users ADD COLUMN prefs_version BIGINT NOT NULL DEFAULT 0;
+
+-- +goose Down
+ALTER TABLE users DROP COLUMN IF EXISTS prefs_version;
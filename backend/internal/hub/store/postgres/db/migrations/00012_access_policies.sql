@@ -0,0 +1,12 @@
+-- +goose Up
+
+-- See the sqlite migration for the rationale.
+CREATE TABLE access_policies (
+    org_id     TEXT COLLATE "C" NOT NULL PRIMARY KEY REFERENCES orgs(id) ON DELETE CASCADE,
+    rules      TEXT NOT NULL DEFAULT '[]',
+    updated_by TEXT COLLATE "C" NOT NULL,
+    updated_at TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
+
+-- +goose Down
+DROP TABLE IF EXISTS access_policies;
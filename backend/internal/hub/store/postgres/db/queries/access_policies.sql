@@ -0,0 +1,11 @@
+-- name: PutAccessPolicy :exec
+INSERT INTO access_policies (org_id, rules, updated_by)
+VALUES ($1, $2, $3)
+ON CONFLICT (org_id) DO UPDATE SET
+  rules = EXCLUDED.rules,
+  updated_by = EXCLUDED.updated_by,
+  updated_at = NOW();
+
+-- name: GetAccessPolicy :one
+SELECT * FROM access_policies
+WHERE org_id = $1;
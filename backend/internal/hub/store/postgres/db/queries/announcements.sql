@@ -0,0 +1,37 @@
+-- name: CreateAnnouncement :exec
+INSERT INTO announcements (id, title, body, severity, starts_at, ends_at, created_by)
+VALUES ($1, $2, $3, $4, $5, $6, $7);
+
+-- name: GetAnnouncement :one
+SELECT * FROM announcements
+WHERE id = $1;
+
+-- name: ListAnnouncements :many
+SELECT a.*,
+  (SELECT COUNT(*) FROM announcement_acks k WHERE k.announcement_id = a.id) AS acknowledged_count
+FROM announcements a
+ORDER BY a.starts_at DESC, a.id;
+
+-- name: ListLiveAnnouncements :many
+SELECT * FROM announcements
+WHERE ends_at IS NULL OR ends_at > NOW()
+ORDER BY starts_at, id;
+
+-- name: ListActiveAnnouncementsForUser :many
+SELECT a.* FROM announcements a
+WHERE a.starts_at <= NOW()
+  AND (a.ends_at IS NULL OR a.ends_at > NOW())
+  AND NOT EXISTS (
+    SELECT 1 FROM announcement_acks k
+    WHERE k.announcement_id = a.id AND k.user_id = $1
+  )
+ORDER BY a.starts_at, a.id;
+
+-- name: DeleteAnnouncement :execresult
+DELETE FROM announcements
+WHERE id = $1;
+
+-- name: AcknowledgeAnnouncement :exec
+INSERT INTO announcement_acks (announcement_id, user_id)
+VALUES ($1, $2)
+ON CONFLICT (announcement_id, user_id) DO NOTHING;
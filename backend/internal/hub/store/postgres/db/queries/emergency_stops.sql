@@ -0,0 +1,21 @@
+-- name: PutEmergencyStop :exec
+-- Stopping an org or worker that is already stopped replaces the stop.
+INSERT INTO emergency_stops (org_id, worker_id, reason, stopped_by)
+VALUES ($1, $2, $3, $4)
+ON CONFLICT (org_id, worker_id) DO UPDATE SET
+  reason = EXCLUDED.reason,
+  stopped_by = EXCLUDED.stopped_by,
+  stopped_at = NOW();
+
+-- name: GetEmergencyStop :one
+SELECT * FROM emergency_stops
+WHERE org_id = $1 AND worker_id = $2;
+
+-- name: ListEmergencyStopsByOrg :many
+SELECT * FROM emergency_stops
+WHERE org_id = $1
+ORDER BY worker_id;
+
+-- name: DeleteEmergencyStop :execresult
+DELETE FROM emergency_stops
+WHERE org_id = $1 AND worker_id = $2;
@@ -0,0 +1,35 @@
+-- name: CreateGitCredential :exec
+INSERT INTO git_credentials (id, org_id, name, kind, username, secret)
+VALUES ($1, $2, $3, $4, $5, $6);
+
+-- name: GetGitCredential :one
+SELECT * FROM git_credentials
+WHERE id = $1 AND org_id = $2;
+
+-- name: GetGitCredentialByName :one
+SELECT * FROM git_credentials
+WHERE org_id = $1 AND name = $2;
+
+-- name: ListGitCredentialsByOrg :many
+SELECT * FROM git_credentials
+WHERE org_id = $1
+ORDER BY name, id;
+
+-- name: ListAllGitCredentials :many
+SELECT * FROM git_credentials
+ORDER BY id;
+
+-- name: UpdateGitCredential :exec
+UPDATE git_credentials SET
+  username = $1,
+  secret = $2,
+  updated_at = NOW()
+WHERE id = $3 AND org_id = $4;
+
+-- name: UpdateGitCredentialSecret :exec
+UPDATE git_credentials SET secret = $1
+WHERE id = $2;
+
+-- name: DeleteGitCredential :execresult
+DELETE FROM git_credentials
+WHERE id = $1 AND org_id = $2;
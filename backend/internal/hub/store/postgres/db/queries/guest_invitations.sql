@@ -0,0 +1,50 @@
+-- name: CreateGuestInvitation :exec
+INSERT INTO guest_invitations (
+    id, user_id, workspace_id, role, label, secret_hash, expires_at, auth_generation
+) VALUES (
+    sqlc.arg(id),
+    sqlc.arg(user_id),
+    sqlc.arg(workspace_id),
+    sqlc.arg(role),
+    sqlc.arg(label),
+    sqlc.arg(secret_hash),
+    sqlc.arg(expires_at),
+    (SELECT auth_generation FROM users WHERE users.id = sqlc.arg(user_id))
+);
+
+-- name: GetGuestInvitationByID :one
+SELECT * FROM guest_invitations WHERE id = $1;
+
+-- name: ListActiveGuestInvitationsByWorkspace :many
+SELECT * FROM guest_invitations
+WHERE workspace_id = $1
+  AND revoked_at IS NULL
+  AND expires_at > NOW()
+ORDER BY created_at DESC, id DESC;
+
+-- name: ListGuestInvitationsByUser :many
+-- Revoked and expired links included, for the user data export.
+SELECT * FROM guest_invitations
+WHERE user_id = $1
+ORDER BY created_at DESC, id DESC;
+
+-- name: ListExpiredLiveGuestInvitationIDs :many
+SELECT id FROM guest_invitations
+WHERE revoked_at IS NULL AND expires_at <= sqlc.arg(cutoff)
+ORDER BY expires_at
+LIMIT sqlc.arg('limit');
+
+-- name: TouchGuestInvitation :exec
+UPDATE guest_invitations
+SET last_used_at = NOW()
+WHERE id = $1;
+
+-- name: RevokeGuestInvitation :one
+UPDATE guest_invitations
+SET revoked_at = clock_timestamp()
+WHERE id = $1 AND revoked_at IS NULL
+RETURNING id, user_id, revoked_at;
+
+-- name: DeleteRevokedGuestInvitationsBefore :execrows
+DELETE FROM guest_invitations
+WHERE revoked_at IS NOT NULL AND revoked_at < $1;
@@ -0,0 +1,31 @@
+-- name: CreateModelCredential :exec
+INSERT INTO model_credentials (id, org_id, name, kind, workspace_ids, secret)
+VALUES ($1, $2, $3, $4, $5, $6);
+
+-- name: GetModelCredential :one
+SELECT * FROM model_credentials
+WHERE id = $1 AND org_id = $2;
+
+-- name: ListModelCredentialsByOrg :many
+SELECT * FROM model_credentials
+WHERE org_id = $1
+ORDER BY name, id;
+
+-- name: ListAllModelCredentials :many
+SELECT * FROM model_credentials
+ORDER BY id;
+
+-- name: UpdateModelCredential :exec
+UPDATE model_credentials SET
+  workspace_ids = $1,
+  secret = $2,
+  updated_at = NOW()
+WHERE id = $3 AND org_id = $4;
+
+-- name: UpdateModelCredentialSecret :exec
+UPDATE model_credentials SET secret = $1
+WHERE id = $2;
+
+-- name: DeleteModelCredential :execresult
+DELETE FROM model_credentials
+WHERE id = $1 AND org_id = $2;
@@ -0,0 +1,26 @@
+-- name: CreateRepo :exec
+INSERT INTO repos (id, org_id, name, url, default_branch, credential_ref, worker_labels)
+VALUES ($1, $2, $3, $4, $5, $6, $7);
+
+-- name: GetRepo :one
+SELECT * FROM repos
+WHERE id = $1 AND org_id = $2;
+
+-- name: ListReposByOrg :many
+SELECT * FROM repos
+WHERE org_id = $1
+ORDER BY name, id;
+
+-- name: UpdateRepo :exec
+UPDATE repos SET
+  name = $1,
+  url = $2,
+  default_branch = $3,
+  credential_ref = $4,
+  worker_labels = $5,
+  updated_at = NOW()
+WHERE id = $6 AND org_id = $7;
+
+-- name: DeleteRepo :execresult
+DELETE FROM repos
+WHERE id = $1 AND org_id = $2;
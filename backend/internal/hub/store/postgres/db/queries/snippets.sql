@@ -0,0 +1,28 @@
+-- name: CreateSnippet :exec
+INSERT INTO snippets (id, user_id, name, description, body)
+VALUES ($1, $2, $3, $4, $5);
+
+-- name: GetSnippet :one
+SELECT * FROM snippets
+WHERE id = $1 AND user_id = $2;
+
+-- name: GetSnippetByName :one
+SELECT * FROM snippets
+WHERE user_id = $1 AND name = $2;
+
+-- name: ListSnippetsByUser :many
+SELECT * FROM snippets
+WHERE user_id = $1
+ORDER BY name, id;
+
+-- name: UpdateSnippet :exec
+UPDATE snippets SET
+  name = $1,
+  description = $2,
+  body = $3,
+  updated_at = NOW()
+WHERE id = $4 AND user_id = $5;
+
+-- name: DeleteSnippet :execresult
+DELETE FROM snippets
+WHERE id = $1 AND user_id = $2;
@@ -0,0 +1,58 @@
+-- name: CreateSystemPrompt :exec
+INSERT INTO system_prompts (id, org_id, owner_user_id, name, shared)
+VALUES ($1, $2, $3, $4, $5);
+
+-- name: CreateSystemPromptVersion :exec
+INSERT INTO system_prompt_versions (prompt_id, version, content, created_by)
+VALUES ($1, $2, $3, $4);
+
+-- name: GetSystemPrompt :one
+SELECT p.id, p.org_id, p.owner_user_id, p.name, p.shared, p.latest_version, p.created_at, p.updated_at, v.version, v.content
+FROM system_prompts p
+JOIN system_prompt_versions v ON v.prompt_id = p.id AND v.version = p.latest_version
+WHERE p.id = $1 AND p.org_id = $2;
+
+-- name: GetSystemPromptAtVersion :one
+SELECT p.id, p.org_id, p.owner_user_id, p.name, p.shared, p.latest_version, p.created_at, p.updated_at, v.version, v.content
+FROM system_prompts p
+JOIN system_prompt_versions v ON v.prompt_id = p.id
+WHERE p.id = $1 AND p.org_id = $2 AND v.version = $3;
+
+-- name: ListSystemPromptsByOrg :many
+SELECT p.id, p.org_id, p.owner_user_id, p.name, p.shared, p.latest_version, p.created_at, p.updated_at, v.version, v.content
+FROM system_prompts p
+JOIN system_prompt_versions v ON v.prompt_id = p.id AND v.version = p.latest_version
+WHERE p.org_id = $1
+ORDER BY p.name, p.id;
+
+-- name: ListSystemPromptsByOwner :many
+-- Across orgs, for the user data export.
+SELECT p.id, p.org_id, p.owner_user_id, p.name, p.shared, p.latest_version, p.created_at, p.updated_at, v.version, v.content
+FROM system_prompts p
+JOIN system_prompt_versions v ON v.prompt_id = p.id AND v.version = p.latest_version
+WHERE p.owner_user_id = $1
+ORDER BY p.org_id, p.name, p.id;
+
+-- name: UpdateSystemPrompt :exec
+UPDATE system_prompts SET
+  name = $1,
+  shared = $2,
+  updated_at = NOW()
+WHERE id = $3 AND org_id = $4;
+
+-- name: SetSystemPromptLatestVersion :exec
+UPDATE system_prompts SET
+  latest_version = $1,
+  updated_at = NOW()
+WHERE id = $2 AND org_id = $3;
+
+-- name: ListSystemPromptVersions :many
+SELECT v.prompt_id, v.version, v.content, v.created_by, v.created_at
+FROM system_prompt_versions v
+JOIN system_prompts p ON p.id = v.prompt_id
+WHERE p.id = $1 AND p.org_id = $2
+ORDER BY v.version DESC;
+
+-- name: DeleteSystemPrompt :execresult
+DELETE FROM system_prompts
+WHERE id = $1 AND org_id = $2;
@@ -150,10 +150,19 @@ DELETE FROM users WHERE id IN (SELECT id FROM to_delete);
 -- name: GetUserPrefs :one
 SELECT prefs FROM users WHERE id = $1 AND deleted_at IS NULL;
 
+-- name: GetUserPrefsVersion :one
+SELECT prefs, prefs_version FROM users WHERE id = $1 AND deleted_at IS NULL;
+
 -- name: UpdateUserPrefs :exec
-UPDATE users SET prefs = $1, updated_at = NOW()
+UPDATE users SET prefs = $1, prefs_version = prefs_version + 1, updated_at = NOW()
 WHERE id = $2;
 
+-- name: SwapUserPrefs :execrows
+-- Writes prefs only if no write has landed since the caller read
+-- prefs_version; it affects no row when one has.
+UPDATE users SET prefs = $1, prefs_version = prefs_version + 1, updated_at = NOW()
+WHERE id = $2 AND prefs_version = $3 AND deleted_at IS NULL;
+
 -- name: CountUsers :one
 SELECT count(*) FROM users WHERE deleted_at IS NULL;
 
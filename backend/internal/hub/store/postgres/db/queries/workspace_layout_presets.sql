@@ -0,0 +1,49 @@
+-- name: UpsertWorkspaceLayoutPreset :exec
+-- Saving under an existing name replaces that preset's tree and keeps its
+-- id, so device classes that have it active follow the new tree.
+INSERT INTO workspace_layout_presets (id, user_id, workspace_id, name, root)
+VALUES ($1, $2, $3, $4, $5)
+ON CONFLICT (user_id, workspace_id, name) DO UPDATE SET
+  root = EXCLUDED.root,
+  updated_at = NOW();
+
+-- name: GetWorkspaceLayoutPresetByName :one
+SELECT * FROM workspace_layout_presets
+WHERE user_id = $1 AND workspace_id = $2 AND name = $3;
+
+-- name: GetWorkspaceLayoutPresetByID :one
+SELECT * FROM workspace_layout_presets
+WHERE id = $1 AND user_id = $2;
+
+-- name: ListWorkspaceLayoutPresets :many
+SELECT * FROM workspace_layout_presets
+WHERE user_id = $1 AND workspace_id = $2
+ORDER BY name, id;
+
+-- name: ListWorkspaceLayoutPresetsByUser :many
+SELECT * FROM workspace_layout_presets
+WHERE user_id = $1
+ORDER BY workspace_id, name, id;
+
+-- name: DeleteWorkspaceLayoutPreset :execresult
+DELETE FROM workspace_layout_presets
+WHERE id = $1 AND user_id = $2;
+
+-- name: DeleteWorkspaceLayoutPresetsByUser :exec
+DELETE FROM workspace_layout_presets
+WHERE user_id = $1;
+
+-- name: SetWorkspaceLayoutSelection :exec
+INSERT INTO workspace_layout_selections (user_id, workspace_id, device_class, layout_id)
+VALUES ($1, $2, $3, $4)
+ON CONFLICT (user_id, workspace_id, device_class) DO UPDATE SET
+  layout_id = EXCLUDED.layout_id;
+
+-- name: ClearWorkspaceLayoutSelection :exec
+DELETE FROM workspace_layout_selections
+WHERE user_id = $1 AND workspace_id = $2 AND device_class = $3;
+
+-- name: ListWorkspaceLayoutSelections :many
+SELECT * FROM workspace_layout_selections
+WHERE user_id = $1 AND workspace_id = $2
+ORDER BY device_class;
@@ -0,0 +1,95 @@
+package postgres
+
+import (
+	"context"
+
+	"github.com/leapmux/leapmux/internal/hub/store"
+	gendb "github.com/leapmux/leapmux/internal/hub/store/postgres/generated/db"
+)
+
+type gitCredentialStore struct {
+	conn *pgConn
+}
+
+var _ store.GitCredentialStore = (*gitCredentialStore)(nil)
+
+func fromDBGitCredential(c gendb.GitCredential) *store.GitCredential {
+	return &store.GitCredential{
+		ID:        c.ID,
+		OrgID:     c.OrgID,
+		Name:      c.Name,
+		Kind:      c.Kind,
+		Username:  c.Username,
+		Secret:    c.Secret,
+		CreatedAt: c.CreatedAt.Time,
+		UpdatedAt: c.UpdatedAt.Time,
+	}
+}
+
+func (s *gitCredentialStore) Create(ctx context.Context, p store.CreateGitCredentialParams) (*store.GitCredential, error) {
+	if err := s.conn.q.CreateGitCredential(ctx, gendb.CreateGitCredentialParams{
+		ID:       p.ID,
+		OrgID:    p.OrgID,
+		Name:     p.Name,
+		Kind:     p.Kind,
+		Username: p.Username,
+		Secret:   p.Secret,
+	}); err != nil {
+		return nil, mapErr(err)
+	}
+	return s.Get(ctx, store.GetGitCredentialParams{ID: p.ID, OrgID: p.OrgID})
+}
+
+func (s *gitCredentialStore) Get(ctx context.Context, p store.GetGitCredentialParams) (*store.GitCredential, error) {
+	c, err := s.conn.q.GetGitCredential(ctx, gendb.GetGitCredentialParams{ID: p.ID, OrgID: p.OrgID})
+	if err != nil {
+		return nil, mapErr(err)
+	}
+	return fromDBGitCredential(c), nil
+}
+
+func (s *gitCredentialStore) GetByName(ctx context.Context, p store.GetGitCredentialByNameParams) (*store.GitCredential, error) {
+	c, err := s.conn.q.GetGitCredentialByName(ctx, gendb.GetGitCredentialByNameParams{OrgID: p.OrgID, Name: p.Name})
+	if err != nil {
+		return nil, mapErr(err)
+	}
+	return fromDBGitCredential(c), nil
+}
+
+func (s *gitCredentialStore) ListByOrg(ctx context.Context, orgID string) ([]store.GitCredential, error) {
+	rows, err := s.conn.q.ListGitCredentialsByOrg(ctx, orgID)
+	if err != nil {
+		return nil, mapErr(err)
+	}
+	return store.MapSlice(rows, func(c gendb.GitCredential) store.GitCredential { return *fromDBGitCredential(c) }), nil
+}
+
+func (s *gitCredentialStore) ListAll(ctx context.Context) ([]store.GitCredential, error) {
+	rows, err := s.conn.q.ListAllGitCredentials(ctx)
+	if err != nil {
+		return nil, mapErr(err)
+	}
+	return store.MapSlice(rows, func(c gendb.GitCredential) store.GitCredential { return *fromDBGitCredential(c) }), nil
+}
+
+func (s *gitCredentialStore) Update(ctx context.Context, p store.UpdateGitCredentialParams) (*store.GitCredential, error) {
+	// Existence is read back rather than taken from the affected-row count,
+	// which MySQL reports as zero for an update that changed nothing.
+	if err := s.conn.q.UpdateGitCredential(ctx, gendb.UpdateGitCredentialParams{
+		Username: p.Username,
+		Secret:   p.Secret,
+		ID:       p.ID,
+		OrgID:    p.OrgID,
+	}); err != nil {
+		return nil, mapErr(err)
+	}
+	return s.Get(ctx, store.GetGitCredentialParams{ID: p.ID, OrgID: p.OrgID})
+}
+
+func (s *gitCredentialStore) UpdateSecret(ctx context.Context, id string, secret []byte) error {
+	return mapErr(s.conn.q.UpdateGitCredentialSecret(ctx, gendb.UpdateGitCredentialSecretParams{Secret: secret, ID: id}))
+}
+
+func (s *gitCredentialStore) Delete(ctx context.Context, p store.GetGitCredentialParams) (int64, error) {
+	return rowsAffected(s.conn.q.DeleteGitCredential(ctx, gendb.DeleteGitCredentialParams{ID: p.ID, OrgID: p.OrgID}))
+}
@@ -1,6 +1,7 @@
 package postgres
 
 import (
+	"bytes"
 	"io"
 	"io/fs"
 	"regexp"
@@ -54,6 +55,7 @@ func TestTransformFSServesTransformedContentConsistently(t *testing.T) {
 	sub, err := fs.Sub(migrations, "db/migrations")
 	require.NoError(t, err)
 	tfs := transformFS{inner: sub, transform: stripCollateC}
+	transformed := 0
 
 	entries, err := fs.ReadDir(tfs, ".")
 	require.NoError(t, err)
@@ -78,7 +80,13 @@ func TestTransformFSServesTransformedContentConsistently(t *testing.T) {
 
 		raw, err := fs.ReadFile(sub, e.Name())
 		require.NoError(t, err)
-		assert.NotEqual(t, raw, viaOpen,
-			"%s: the transform must have been applied on both paths", e.Name())
+		// A migration with no collated column (one that only adds an
+		// integer, say) has nothing to transform.
+		if bytes.Contains(raw, []byte(` COLLATE "C"`)) {
+			assert.NotEqual(t, raw, viaOpen,
+				"%s: the transform must have been applied on both paths", e.Name())
+			transformed++
+		}
 	}
+	assert.Positive(t, transformed, "no embedded migration was transformed")
 }
@@ -140,6 +140,33 @@ func (s *pgStore) WorkspaceSections() store.WorkspaceSectionStore {
 func (s *pgStore) WorkspaceSectionItems() store.WorkspaceSectionItemStore {
 	return &workspaceSectionItemStore{conn: s.conn}
 }
+func (s *pgStore) WorkspaceLayoutPresets() store.WorkspaceLayoutPresetStore {
+	return &workspaceLayoutPresetStore{conn: s.conn}
+}
+func (s *pgStore) Repos() store.RepoStore {
+	return &repoStore{conn: s.conn}
+}
+func (s *pgStore) GitCredentials() store.GitCredentialStore {
+	return &gitCredentialStore{conn: s.conn}
+}
+func (s *pgStore) ModelCredentials() store.ModelCredentialStore {
+	return &modelCredentialStore{conn: s.conn}
+}
+func (s *pgStore) SystemPrompts() store.SystemPromptStore {
+	return &systemPromptStore{conn: s.conn}
+}
+func (s *pgStore) Snippets() store.SnippetStore {
+	return &snippetStore{conn: s.conn}
+}
+func (s *pgStore) Announcements() store.AnnouncementStore {
+	return &announcementStore{conn: s.conn}
+}
+func (s *pgStore) EmergencyStops() store.EmergencyStopStore {
+	return &emergencyStopStore{conn: s.conn}
+}
+func (s *pgStore) AccessPolicies() store.AccessPolicyStore {
+	return &accessPolicyStore{conn: s.conn}
+}
 func (s *pgStore) OAuthProviders() store.OAuthProviderStore { return &oauthProviderStore{conn: s.conn} }
 func (s *pgStore) OAuthStates() store.OAuthStateStore       { return &oauthStateStore{conn: s.conn} }
 func (s *pgStore) OAuthTokens() store.OAuthTokenStore       { return &oauthTokenStore{conn: s.conn} }
@@ -153,6 +180,9 @@ func (s *pgStore) APITokens() store.APITokenStore { return &apiTokenStore{conn:
 func (s *pgStore) DelegationTokens() store.DelegationTokenStore {
 	return &delegationTokenStore{conn: s.conn}
 }
+func (s *pgStore) GuestInvitations() store.GuestInvitationStore {
+	return &guestInvitationStore{conn: s.conn}
+}
 func (s *pgStore) RevocationEvents() store.RevocationEventStore {
 	return newRevocationEventStore(s.conn)
 }
@@ -138,6 +138,14 @@ func (s *userStore) GetPrefs(ctx context.Context, id string) (string, error) {
 	return prefs, mapErr(err)
 }
 
+func (s *userStore) GetPrefsVersion(ctx context.Context, id string) (store.UserPrefs, error) {
+	row, err := s.conn.q.GetUserPrefsVersion(ctx, id)
+	if err != nil {
+		return store.UserPrefs{}, mapErr(err)
+	}
+	return store.UserPrefs{Prefs: row.Prefs, Version: row.PrefsVersion}, nil
+}
+
 func (s *userStore) HasAny(ctx context.Context) (bool, error) {
 	ok, err := s.conn.q.HasAnyUser(ctx)
 	if err != nil {
@@ -353,6 +361,18 @@ func (s *userStore) UpdatePrefs(ctx context.Context, p store.UpdateUserPrefsPara
 	}))
 }
 
+func (s *userStore) SwapPrefs(ctx context.Context, p store.SwapUserPrefsParams) (bool, error) {
+	n, err := s.conn.q.SwapUserPrefs(ctx, gendb.SwapUserPrefsParams{
+		Prefs:        p.Prefs,
+		ID:           p.ID,
+		PrefsVersion: p.Version,
+	})
+	if err != nil {
+		return false, mapErr(err)
+	}
+	return n == 1, nil
+}
+
 func (s *userStore) SetPendingEmail(ctx context.Context, p store.SetPendingEmailParams) error {
 	return mapErr(s.conn.q.SetPendingEmail(ctx, gendb.SetPendingEmailParams{
 		PendingEmail:          store.NormalizeEmail(p.PendingEmail),
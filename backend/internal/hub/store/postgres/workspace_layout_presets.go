@@ -0,0 +1,172 @@
+package postgres
+
+import (
+	"context"
+
+	"github.com/leapmux/leapmux/internal/hub/store"
+	gendb "github.com/leapmux/leapmux/internal/hub/store/postgres/generated/db"
+	"github.com/leapmux/leapmux/internal/util/userid"
+)
+
+type workspaceLayoutPresetStore struct {
+	conn *pgConn
+}
+
+var _ store.WorkspaceLayoutPresetStore = (*workspaceLayoutPresetStore)(nil)
+
+func fromDBWorkspaceLayoutPreset(l gendb.WorkspaceLayoutPreset) *store.WorkspaceLayoutPreset {
+	return &store.WorkspaceLayoutPreset{
+		ID:          l.ID,
+		UserID:      l.UserID,
+		WorkspaceID: l.WorkspaceID,
+		Name:        l.Name,
+		Root:        l.Root,
+		CreatedAt:   l.CreatedAt.Time,
+		UpdatedAt:   l.UpdatedAt.Time,
+	}
+}
+
+func fromDBWorkspaceLayoutSelection(a gendb.WorkspaceLayoutSelection) store.WorkspaceLayoutSelection {
+	return store.WorkspaceLayoutSelection{
+		UserID:      a.UserID,
+		WorkspaceID: a.WorkspaceID,
+		DeviceClass: a.DeviceClass,
+		LayoutID:    a.LayoutID,
+	}
+}
+
+func (s *workspaceLayoutPresetStore) Upsert(ctx context.Context, p store.UpsertWorkspaceLayoutPresetParams) (*store.WorkspaceLayoutPreset, error) {
+	owner, ok := store.OwnerFilter(p.UserID)
+	if !ok {
+		// An unminted caller owns nothing; binding "" would MATCH every
+		// blank-owner row rather than none. See store.OwnerFilter.
+		return nil, store.ErrNotFound
+	}
+	if err := s.conn.q.UpsertWorkspaceLayoutPreset(ctx, gendb.UpsertWorkspaceLayoutPresetParams{
+		ID:          p.ID,
+		UserID:      owner,
+		WorkspaceID: p.WorkspaceID,
+		Name:        p.Name,
+		Root:        p.Root,
+	}); err != nil {
+		return nil, mapErr(err)
+	}
+	l, err := s.conn.q.GetWorkspaceLayoutPresetByName(ctx, gendb.GetWorkspaceLayoutPresetByNameParams{
+		UserID:      owner,
+		WorkspaceID: p.WorkspaceID,
+		Name:        p.Name,
+	})
+	if err != nil {
+		return nil, mapErr(err)
+	}
+	return fromDBWorkspaceLayoutPreset(l), nil
+}
+
+func (s *workspaceLayoutPresetStore) GetByID(ctx context.Context, p store.GetWorkspaceLayoutPresetParams) (*store.WorkspaceLayoutPreset, error) {
+	owner, ok := store.OwnerFilter(p.UserID)
+	if !ok {
+		// An unminted caller owns nothing; binding "" would MATCH every
+		// blank-owner row rather than none. See store.OwnerFilter.
+		return nil, store.ErrNotFound
+	}
+	l, err := s.conn.q.GetWorkspaceLayoutPresetByID(ctx, gendb.GetWorkspaceLayoutPresetByIDParams{ID: p.ID, UserID: owner})
+	if err != nil {
+		return nil, mapErr(err)
+	}
+	return fromDBWorkspaceLayoutPreset(l), nil
+}
+
+func (s *workspaceLayoutPresetStore) ListByWorkspace(ctx context.Context, p store.ListWorkspaceLayoutPresetsParams) ([]store.WorkspaceLayoutPreset, error) {
+	owner, ok := store.OwnerFilter(p.UserID)
+	if !ok {
+		// An unminted caller owns nothing; binding "" would MATCH every
+		// blank-owner row rather than none. See store.OwnerFilter.
+		return nil, nil
+	}
+	rows, err := s.conn.q.ListWorkspaceLayoutPresets(ctx, gendb.ListWorkspaceLayoutPresetsParams{UserID: owner, WorkspaceID: p.WorkspaceID})
+	if err != nil {
+		return nil, mapErr(err)
+	}
+	return store.MapSlice(rows, func(l gendb.WorkspaceLayoutPreset) store.WorkspaceLayoutPreset {
+		return *fromDBWorkspaceLayoutPreset(l)
+	}), nil
+}
+
+func (s *workspaceLayoutPresetStore) ListByUser(ctx context.Context, userID userid.UserID) ([]store.WorkspaceLayoutPreset, error) {
+	owner, ok := store.OwnerFilter(userID)
+	if !ok {
+		// An unminted caller owns nothing; binding "" would MATCH every
+		// blank-owner row rather than none. See store.OwnerFilter.
+		return nil, nil
+	}
+	rows, err := s.conn.q.ListWorkspaceLayoutPresetsByUser(ctx, owner)
+	if err != nil {
+		return nil, mapErr(err)
+	}
+	return store.MapSlice(rows, func(l gendb.WorkspaceLayoutPreset) store.WorkspaceLayoutPreset {
+		return *fromDBWorkspaceLayoutPreset(l)
+	}), nil
+}
+
+func (s *workspaceLayoutPresetStore) Delete(ctx context.Context, p store.DeleteWorkspaceLayoutPresetParams) (int64, error) {
+	owner, ok := store.OwnerFilter(p.UserID)
+	if !ok {
+		// An unminted caller owns nothing; binding "" would MATCH every
+		// blank-owner row rather than none. See store.OwnerFilter.
+		return 0, nil
+	}
+	return rowsAffected(s.conn.q.DeleteWorkspaceLayoutPreset(ctx, gendb.DeleteWorkspaceLayoutPresetParams{ID: p.ID, UserID: owner}))
+}
+
+func (s *workspaceLayoutPresetStore) DeleteByUser(ctx context.Context, userID userid.UserID) error {
+	owner, ok := store.OwnerFilter(userID)
+	if !ok {
+		// An unminted caller owns nothing; binding "" would MATCH every
+		// blank-owner row rather than none. See store.OwnerFilter.
+		return nil
+	}
+	return mapErr(s.conn.q.DeleteWorkspaceLayoutPresetsByUser(ctx, owner))
+}
+
+func (s *workspaceLayoutPresetStore) SetActive(ctx context.Context, p store.SetWorkspaceLayoutSelectionParams) error {
+	owner, ok := store.OwnerFilter(p.UserID)
+	if !ok {
+		// An unminted caller owns nothing; binding "" would MATCH every
+		// blank-owner row rather than none. See store.OwnerFilter.
+		return store.ErrNotFound
+	}
+	return mapErr(s.conn.q.SetWorkspaceLayoutSelection(ctx, gendb.SetWorkspaceLayoutSelectionParams{
+		UserID:      owner,
+		WorkspaceID: p.WorkspaceID,
+		DeviceClass: p.DeviceClass,
+		LayoutID:    p.LayoutID,
+	}))
+}
+
+func (s *workspaceLayoutPresetStore) ClearActive(ctx context.Context, p store.ClearWorkspaceLayoutSelectionParams) error {
+	owner, ok := store.OwnerFilter(p.UserID)
+	if !ok {
+		// An unminted caller owns nothing; binding "" would MATCH every
+		// blank-owner row rather than none. See store.OwnerFilter.
+		return nil
+	}
+	return mapErr(s.conn.q.ClearWorkspaceLayoutSelection(ctx, gendb.ClearWorkspaceLayoutSelectionParams{
+		UserID:      owner,
+		WorkspaceID: p.WorkspaceID,
+		DeviceClass: p.DeviceClass,
+	}))
+}
+
+func (s *workspaceLayoutPresetStore) ListActive(ctx context.Context, p store.ListWorkspaceLayoutPresetsParams) ([]store.WorkspaceLayoutSelection, error) {
+	owner, ok := store.OwnerFilter(p.UserID)
+	if !ok {
+		// An unminted caller owns nothing; binding "" would MATCH every
+		// blank-owner row rather than none. See store.OwnerFilter.
+		return nil, nil
+	}
+	rows, err := s.conn.q.ListWorkspaceLayoutSelections(ctx, gendb.ListWorkspaceLayoutSelectionsParams{UserID: owner, WorkspaceID: p.WorkspaceID})
+	if err != nil {
+		return nil, mapErr(err)
+	}
+	return store.MapSlice(rows, fromDBWorkspaceLayoutSelection), nil
+}
@@ -0,0 +1,108 @@
+package sqlite
+
+import (
+	"context"
+
+	"github.com/leapmux/leapmux/internal/hub/store"
+	gendb "github.com/leapmux/leapmux/internal/hub/store/sqlite/generated/db"
+	"github.com/leapmux/leapmux/internal/util/sqltime"
+	"github.com/leapmux/leapmux/internal/util/userid"
+)
+
+type announcementStore struct {
+	conn *sqliteConn
+}
+
+var _ store.AnnouncementStore = (*announcementStore)(nil)
+
+func fromDBAnnouncement(a gendb.Announcement) *store.Announcement {
+	return &store.Announcement{
+		ID:        a.ID,
+		Title:     a.Title,
+		Body:      a.Body,
+		Severity:  a.Severity,
+		StartsAt:  a.StartsAt.Time,
+		EndsAt:    a.EndsAt.Ptr(),
+		CreatedBy: a.CreatedBy,
+		CreatedAt: a.CreatedAt.Time,
+	}
+}
+
+func (s *announcementStore) Create(ctx context.Context, p store.CreateAnnouncementParams) (*store.Announcement, error) {
+	if err := s.conn.q.CreateAnnouncement(ctx, gendb.CreateAnnouncementParams{
+		ID:        p.ID,
+		Title:     p.Title,
+		Body:      p.Body,
+		Severity:  p.Severity,
+		StartsAt:  sqltime.NewSQLiteTime(p.StartsAt),
+		EndsAt:    sqltime.NewSQLiteNullTime(p.EndsAt),
+		CreatedBy: p.CreatedBy,
+	}); err != nil {
+		return nil, mapErr(err)
+	}
+	return s.GetByID(ctx, p.ID)
+}
+
+func (s *announcementStore) GetByID(ctx context.Context, id string) (*store.Announcement, error) {
+	a, err := s.conn.q.GetAnnouncement(ctx, id)
+	if err != nil {
+		return nil, mapErr(err)
+	}
+	return fromDBAnnouncement(a), nil
+}
+
+func (s *announcementStore) ListAll(ctx context.Context) ([]store.Announcement, error) {
+	rows, err := s.conn.q.ListAnnouncements(ctx)
+	if err != nil {
+		return nil, mapErr(err)
+	}
+	return store.MapSlice(rows, func(r gendb.ListAnnouncementsRow) store.Announcement {
+		a := fromDBAnnouncement(gendb.Announcement{
+			ID:        r.ID,
+			Title:     r.Title,
+			Body:      r.Body,
+			Severity:  r.Severity,
+			StartsAt:  r.StartsAt,
+			EndsAt:    r.EndsAt,
+			CreatedBy: r.CreatedBy,
+			CreatedAt: r.CreatedAt,
+		})
+		a.AcknowledgedCount = r.AcknowledgedCount
+		return *a
+	}), nil
+}
+
+func (s *announcementStore) ListLive(ctx context.Context) ([]store.Announcement, error) {
+	rows, err := s.conn.q.ListLiveAnnouncements(ctx)
+	if err != nil {
+		return nil, mapErr(err)
+	}
+	return store.MapSlice(rows, func(r gendb.Announcement) store.Announcement { return *fromDBAnnouncement(r) }), nil
+}
+
+func (s *announcementStore) ListActiveForUser(ctx context.Context, userID userid.UserID) ([]store.Announcement, error) {
+	owner, ok := store.OwnerFilter(userID)
+	if !ok {
+		return nil, nil // an unminted caller has nothing to read; see OwnerFilter
+	}
+	rows, err := s.conn.q.ListActiveAnnouncementsForUser(ctx, owner)
+	if err != nil {
+		return nil, mapErr(err)
+	}
+	return store.MapSlice(rows, func(r gendb.Announcement) store.Announcement { return *fromDBAnnouncement(r) }), nil
+}
+
+func (s *announcementStore) Delete(ctx context.Context, id string) (int64, error) {
+	return rowsAffected(s.conn.q.DeleteAnnouncement(ctx, id))
+}
+
+func (s *announcementStore) Acknowledge(ctx context.Context, p store.AcknowledgeAnnouncementParams) error {
+	owner, ok := store.OwnerFilter(p.UserID)
+	if !ok {
+		return store.ErrNotFound // an unminted caller cannot acknowledge; see OwnerFilter
+	}
+	return mapErr(s.conn.q.AcknowledgeAnnouncement(ctx, gendb.AcknowledgeAnnouncementParams{
+		AnnouncementID: p.AnnouncementID,
+		UserID:         owner,
+	}))
+}
@@ -67,6 +67,18 @@ func TestAllDatetimeColumnsStoreCanonicalLayout(t *testing.T) {
 		RefreshExpiresAt: ptr(farFuture),
 	}))
 
+	// guest_invitations: expires_at on Create (created_at via its column
+	// DEFAULT); last_used_at and revoked_at via the Touch/Revoke fixtures below.
+	guestID := id.Generate()
+	require.NoError(t, st.GuestInvitations().Create(ctx, store.CreateGuestInvitationParams{
+		ID:          guestID,
+		UserID:      userid.MustNew(user.ID),
+		WorkspaceID: workspaceID,
+		Role:        leapmuxv1.GuestRole_GUEST_ROLE_VIEWER,
+		SecretHash:  []byte("gi-secret"),
+		ExpiresAt:   future,
+	}))
+
 	// api_tokens: expires_at + refresh_expires_at on Create, the New*/Prev*
 	// triplet on RotateRefresh, and revocation_events.revoked_at via Revoke.
 	rotatedID := id.Generate()
@@ -260,6 +272,98 @@ func TestAllDatetimeColumnsStoreCanonicalLayout(t *testing.T) {
 		Sidebar:     leapmuxv1.Sidebar_SIDEBAR_LEFT,
 	}))
 
+	// workspace_layout_presets: created_at and updated_at via their column
+	// DEFAULTs on insert.
+	_, err = st.WorkspaceLayoutPresets().Upsert(ctx, store.UpsertWorkspaceLayoutPresetParams{
+		ID:          id.Generate(),
+		UserID:      userid.MustNew(user.ID),
+		WorkspaceID: workspaceID,
+		Name:        "canon-layout",
+		Root:        []byte("root"),
+	})
+	require.NoError(t, err)
+
+	// repos: created_at and updated_at via their column DEFAULTs on insert.
+	_, err = st.Repos().Create(ctx, store.CreateRepoParams{
+		ID:    id.Generate(),
+		OrgID: orgID,
+		Name:  "canon-repo",
+		URL:   "https://example.com/canon.git",
+	})
+	require.NoError(t, err)
+
+	// git_credentials: created_at and updated_at via their column DEFAULTs
+	// on insert.
+	_, err = st.GitCredentials().Create(ctx, store.CreateGitCredentialParams{
+		ID:     id.Generate(),
+		OrgID:  orgID,
+		Name:   "canon-credential",
+		Kind:   leapmuxv1.GitCredentialKind_GIT_CREDENTIAL_KIND_SSH_KEY,
+		Secret: []byte("ciphertext"),
+	})
+	require.NoError(t, err)
+
+	// system_prompts (created_at, updated_at) and system_prompt_versions
+	// (created_at) via their column DEFAULTs on insert.
+	_, err = st.SystemPrompts().Create(ctx, store.CreateSystemPromptParams{
+		ID:          id.Generate(),
+		OrgID:       orgID,
+		OwnerUserID: userid.MustNew(user.ID),
+		Name:        "canon-prompt",
+		Content:     "Be brief.",
+	})
+	require.NoError(t, err)
+
+	// snippets.created_at and updated_at via their column DEFAULTs.
+	_, err = st.Snippets().Create(ctx, store.CreateSnippetParams{
+		ID:     id.Generate(),
+		UserID: userid.MustNew(user.ID),
+		Name:   "canon-snippet",
+		Body:   "Review this.",
+	})
+	require.NoError(t, err)
+
+	// announcements.starts_at and ends_at via bound params, created_at
+	// via its column DEFAULT; announcement_acks.acknowledged_at likewise.
+	announcement, err := st.Announcements().Create(ctx, store.CreateAnnouncementParams{
+		ID:        id.Generate(),
+		Title:     "canon-announcement",
+		Body:      "Maintenance tonight.",
+		StartsAt:  now,
+		EndsAt:    &future,
+		CreatedBy: user.ID,
+	})
+	require.NoError(t, err)
+	require.NoError(t, st.Announcements().Acknowledge(ctx, store.AcknowledgeAnnouncementParams{
+		AnnouncementID: announcement.ID,
+		UserID:         userid.MustNew(user.ID),
+	}))
+
+	// emergency_stops.stopped_at via its column DEFAULT.
+	_, err = st.EmergencyStops().Put(ctx, store.PutEmergencyStopParams{
+		OrgID:     orgID,
+		StoppedBy: user.ID,
+	})
+	require.NoError(t, err)
+
+	// access_policies.updated_at via its column DEFAULT.
+	require.NoError(t, st.AccessPolicies().Put(ctx, store.PutAccessPolicyParams{
+		OrgID:     orgID,
+		Rules:     "[]",
+		UpdatedBy: user.ID,
+	}))
+
+	// model_credentials: created_at and updated_at via their column
+	// DEFAULTs on insert.
+	_, err = st.ModelCredentials().Create(ctx, store.CreateModelCredentialParams{
+		ID:     id.Generate(),
+		OrgID:  orgID,
+		Name:   "canon-model-credential",
+		Kind:   leapmuxv1.ModelCredentialKind_MODEL_CREDENTIAL_KIND_ANTHROPIC_API_KEY,
+		Secret: []byte("ciphertext"),
+	})
+	require.NoError(t, err)
+
 	// oauth_user_links.created_at via its column DEFAULT.
 	require.NoError(t, st.OAuthUserLinks().Create(ctx, store.CreateOAuthUserLinkParams{
 		UserID:          userid.MustNew(user.ID),
@@ -287,6 +391,12 @@ func TestAllDatetimeColumnsStoreCanonicalLayout(t *testing.T) {
 	require.NoError(t, err)
 	require.EqualValues(t, 1, revokedDeleg)
 
+	// guest_invitations: last_used_at via Touch, revoked_at via Revoke.
+	require.NoError(t, st.GuestInvitations().Touch(ctx, guestID))
+	revokedGuest, err := st.GuestInvitations().Revoke(ctx, guestID)
+	require.NoError(t, err)
+	require.EqualValues(t, 1, revokedGuest)
+
 	// users.tokens_revoked_at via RevokeUserTokens, which also enqueues
 	// another pending revocation event.
 	revokedUsers, err := st.Users().RevokeUserTokens(ctx, userid.MustNew(user.ID))
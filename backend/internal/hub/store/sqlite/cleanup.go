@@ -70,6 +70,10 @@ func (s *cleanupStore) DeleteExpiredDelegationTokensBefore(ctx context.Context,
 	return rowsAffected(s.conn.q.DeleteExpiredDelegationTokensBefore(ctx, sqltime.NewSQLiteTime(cutoff)))
 }
 
+func (s *cleanupStore) DeleteRevokedGuestInvitationsBefore(ctx context.Context, cutoff time.Time) (int64, error) {
+	return rowsAffected(s.conn.q.DeleteRevokedGuestInvitationsBefore(ctx, sqltime.SQLiteNullTimeOf(cutoff)))
+}
+
 func (s *cleanupStore) CompactPublishedRevocationEvents(
 	ctx context.Context,
 	p store.CompactRevocationEventsParams,
@@ -0,0 +1,32 @@
+-- +goose Up
+
+-- Named layout presets (per-user). root is a proto-marshalled
+-- LayoutPresetNode; the live tile tree stays in the org CRDT, and the
+-- frontend applies a preset through ordinary CRDT ops.
+CREATE TABLE workspace_layout_presets (
+    id           TEXT PRIMARY KEY,
+    user_id      TEXT NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    workspace_id TEXT NOT NULL REFERENCES workspaces(id) ON DELETE CASCADE,
+    name         TEXT NOT NULL,
+    root         BLOB NOT NULL,
+    created_at   DATETIME NOT NULL DEFAULT (strftime('%Y-%m-%dT%H:%M:%fZ', 'now')),
+    updated_at   DATETIME NOT NULL DEFAULT (strftime('%Y-%m-%dT%H:%M:%fZ', 'now'))
+);
+CREATE UNIQUE INDEX idx_workspace_layout_presets_user_workspace_name ON workspace_layout_presets(user_id, workspace_id, name);
+CREATE INDEX idx_workspace_layout_presets_workspace_id ON workspace_layout_presets(workspace_id);
+
+-- The preset each device class shows (per-user). device_class 0
+-- (DEVICE_CLASS_UNSPECIFIED) is the default for classes without a row.
+CREATE TABLE workspace_layout_selections (
+    user_id      TEXT NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    workspace_id TEXT NOT NULL REFERENCES workspaces(id) ON DELETE CASCADE,
+    device_class INTEGER NOT NULL,
+    layout_id    TEXT NOT NULL REFERENCES workspace_layout_presets(id) ON DELETE CASCADE,
+    PRIMARY KEY (user_id, workspace_id, device_class)
+);
+CREATE INDEX idx_workspace_layout_selections_workspace_id ON workspace_layout_selections(workspace_id);
+CREATE INDEX idx_workspace_layout_selections_layout_id ON workspace_layout_selections(layout_id);
+
+-- +goose Down
+DROP TABLE IF EXISTS workspace_layout_selections;
+DROP TABLE IF EXISTS workspace_layout_presets;
@@ -0,0 +1,68 @@
+-- +goose Up
+
+-- Time-boxed guest links to one workspace. A guest bearer ("lmx_g...")
+-- acts as user_id, the workspace owner who created the link, pinned to
+-- workspace_id and limited by role (a leapmuxv1.GuestRole).
+-- auth_generation snapshots the owner's like the other bearer tables, so
+-- the owner's logout-everywhere ends their guests too.
+CREATE TABLE guest_invitations (
+    id              TEXT PRIMARY KEY,
+    user_id         TEXT NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    workspace_id    TEXT NOT NULL REFERENCES workspaces(id) ON DELETE CASCADE,
+    role            INTEGER NOT NULL,
+    label           TEXT NOT NULL DEFAULT '',
+    secret_hash     BLOB NOT NULL,
+    auth_generation BIGINT NOT NULL DEFAULT 0,
+    created_at      DATETIME NOT NULL DEFAULT (strftime('%Y-%m-%dT%H:%M:%fZ', 'now')),
+    last_used_at    DATETIME,
+    expires_at      DATETIME NOT NULL,
+    revoked_at      DATETIME
+);
+CREATE INDEX idx_guest_invitations_user ON guest_invitations(user_id);
+CREATE INDEX idx_guest_invitations_workspace ON guest_invitations(workspace_id);
+-- Serves the cleanup job's scan for expired links it has not revoked yet.
+CREATE INDEX idx_guest_invitations_expires_at ON guest_invitations(expires_at) WHERE revoked_at IS NULL;
+
+-- Revoking a guest link publishes a guest_invitation revocation event so
+-- every hub tears down the channels opened with it. SQLite cannot alter a
+-- CHECK constraint, so the table is rebuilt with the wider kind list.
+CREATE TABLE revocation_events_new (
+    id         TEXT PRIMARY KEY,
+    kind       TEXT NOT NULL CHECK (kind IN ('session', 'api_token', 'api_token_rotation', 'delegation_token', 'user_tokens', 'user_info', 'guest_invitation')),
+    subject_id TEXT NOT NULL,
+    user_id    TEXT NOT NULL,
+    revoked_at DATETIME NOT NULL,
+    user_auth_generation BIGINT NOT NULL DEFAULT 0,
+    created_at DATETIME NOT NULL DEFAULT (strftime('%Y-%m-%dT%H:%M:%fZ','now')),
+    seq BIGINT UNIQUE CHECK (seq IS NULL OR seq > 0),
+    published_at DATETIME,
+    CHECK ((seq IS NULL) = (published_at IS NULL))
+);
+INSERT INTO revocation_events_new (id, kind, subject_id, user_id, revoked_at, user_auth_generation, created_at, seq, published_at)
+SELECT id, kind, subject_id, user_id, revoked_at, user_auth_generation, created_at, seq, published_at FROM revocation_events;
+DROP TABLE revocation_events;
+ALTER TABLE revocation_events_new RENAME TO revocation_events;
+CREATE INDEX idx_revocation_events_pending ON revocation_events(created_at, id) WHERE seq IS NULL;
+CREATE INDEX idx_revocation_events_published ON revocation_events(published_at, seq) WHERE seq IS NOT NULL;
+
+-- +goose Down
+DELETE FROM revocation_events WHERE kind = 'guest_invitation';
+CREATE TABLE revocation_events_old (
+    id         TEXT PRIMARY KEY,
+    kind       TEXT NOT NULL CHECK (kind IN ('session', 'api_token', 'api_token_rotation', 'delegation_token', 'user_tokens', 'user_info')),
+    subject_id TEXT NOT NULL,
+    user_id    TEXT NOT NULL,
+    revoked_at DATETIME NOT NULL,
+    user_auth_generation BIGINT NOT NULL DEFAULT 0,
+    created_at DATETIME NOT NULL DEFAULT (strftime('%Y-%m-%dT%H:%M:%fZ','now')),
+    seq BIGINT UNIQUE CHECK (seq IS NULL OR seq > 0),
+    published_at DATETIME,
+    CHECK ((seq IS NULL) = (published_at IS NULL))
+);
+INSERT INTO revocation_events_old (id, kind, subject_id, user_id, revoked_at, user_auth_generation, created_at, seq, published_at)
+SELECT id, kind, subject_id, user_id, revoked_at, user_auth_generation, created_at, seq, published_at FROM revocation_events;
+DROP TABLE revocation_events;
+ALTER TABLE revocation_events_old RENAME TO revocation_events;
+CREATE INDEX idx_revocation_events_pending ON revocation_events(created_at, id) WHERE seq IS NULL;
+CREATE INDEX idx_revocation_events_published ON revocation_events(published_at, seq) WHERE seq IS NOT NULL;
+DROP TABLE IF EXISTS guest_invitations;
@@ -0,0 +1,21 @@
+-- +goose Up
+
+-- An org's registered git repositories (leapmuxv1.Repo). worker_labels is
+-- the preferred worker labels joined by spaces; a label never contains
+-- whitespace. credential_ref is opaque here: it names credentials the
+-- worker clones with.
+CREATE TABLE repos (
+    id             TEXT PRIMARY KEY,
+    org_id         TEXT NOT NULL REFERENCES orgs(id) ON DELETE CASCADE,
+    name           TEXT NOT NULL,
+    url            TEXT NOT NULL,
+    default_branch TEXT NOT NULL DEFAULT '',
+    credential_ref TEXT NOT NULL DEFAULT '',
+    worker_labels  TEXT NOT NULL DEFAULT '',
+    created_at     DATETIME NOT NULL DEFAULT (strftime('%Y-%m-%dT%H:%M:%fZ', 'now')),
+    updated_at     DATETIME NOT NULL DEFAULT (strftime('%Y-%m-%dT%H:%M:%fZ', 'now'))
+);
+CREATE UNIQUE INDEX idx_repos_org_name ON repos(org_id, name);
+
+-- +goose Down
+DROP TABLE IF EXISTS repos;
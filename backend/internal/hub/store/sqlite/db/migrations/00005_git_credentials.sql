@@ -0,0 +1,20 @@
+-- +goose Up
+
+-- An org's git credentials (leapmuxv1.GitCredential), named by
+-- repos.credential_ref. kind is a leapmuxv1.GitCredentialKind. secret is
+-- the private key or token, encrypted with the encryption key; AAD:
+-- 'git_credential:' || id.
+CREATE TABLE git_credentials (
+    id         TEXT PRIMARY KEY,
+    org_id     TEXT NOT NULL REFERENCES orgs(id) ON DELETE CASCADE,
+    name       TEXT NOT NULL,
+    kind       INTEGER NOT NULL,
+    username   TEXT NOT NULL DEFAULT '',
+    secret     BLOB NOT NULL,
+    created_at DATETIME NOT NULL DEFAULT (strftime('%Y-%m-%dT%H:%M:%fZ', 'now')),
+    updated_at DATETIME NOT NULL DEFAULT (strftime('%Y-%m-%dT%H:%M:%fZ', 'now'))
+);
+CREATE UNIQUE INDEX idx_git_credentials_org_name ON git_credentials(org_id, name);
+
+-- +goose Down
+DROP TABLE IF EXISTS git_credentials;
@@ -0,0 +1,30 @@
+-- +goose Up
+
+-- The system prompt library (leapmuxv1.SystemPrompt). A prompt belongs to
+-- its owner and, when shared, is readable by the whole org. Its content
+-- lives in system_prompt_versions, one immutable row per version;
+-- latest_version is the highest of them.
+CREATE TABLE system_prompts (
+    id             TEXT PRIMARY KEY,
+    org_id         TEXT NOT NULL REFERENCES orgs(id) ON DELETE CASCADE,
+    owner_user_id  TEXT NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    name           TEXT NOT NULL,
+    shared         BOOLEAN NOT NULL DEFAULT FALSE,
+    latest_version BIGINT NOT NULL DEFAULT 1,
+    created_at     DATETIME NOT NULL DEFAULT (strftime('%Y-%m-%dT%H:%M:%fZ', 'now')),
+    updated_at     DATETIME NOT NULL DEFAULT (strftime('%Y-%m-%dT%H:%M:%fZ', 'now'))
+);
+CREATE UNIQUE INDEX idx_system_prompts_owner_name ON system_prompts(org_id, owner_user_id, name);
+
+CREATE TABLE system_prompt_versions (
+    prompt_id  TEXT NOT NULL REFERENCES system_prompts(id) ON DELETE CASCADE,
+    version    BIGINT NOT NULL,
+    content    TEXT NOT NULL,
+    created_by TEXT NOT NULL,
+    created_at DATETIME NOT NULL DEFAULT (strftime('%Y-%m-%dT%H:%M:%fZ', 'now')),
+    PRIMARY KEY (prompt_id, version)
+);
+
+-- +goose Down
+DROP TABLE IF EXISTS system_prompt_versions;
+DROP TABLE IF EXISTS system_prompts;
@@ -0,0 +1,18 @@
+-- +goose Up
+
+-- Per-user prompt snippets (leapmuxv1.Snippet). A message "/name args"
+-- sent to an agent expands to the body of the sender's snippet named
+-- name; see the worker's SendAgentMessage.
+CREATE TABLE snippets (
+    id          TEXT PRIMARY KEY,
+    user_id     TEXT NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    name        TEXT NOT NULL,
+    description TEXT NOT NULL DEFAULT '',
+    body        TEXT NOT NULL,
+    created_at  DATETIME NOT NULL DEFAULT (strftime('%Y-%m-%dT%H:%M:%fZ', 'now')),
+    updated_at  DATETIME NOT NULL DEFAULT (strftime('%Y-%m-%dT%H:%M:%fZ', 'now'))
+);
+CREATE UNIQUE INDEX idx_snippets_user_name ON snippets(user_id, name);
+
+-- +goose Down
+DROP TABLE IF EXISTS snippets;
@@ -0,0 +1,22 @@
+-- +goose Up
+
+-- An org's model provider credentials (leapmuxv1.ModelCredential) that
+-- workers inject into the agents they launch. kind is a
+-- leapmuxv1.ModelCredentialKind. workspace_ids pins the credential to
+-- those workspaces, joined by spaces; empty serves any workspace of the
+-- org that no credential is pinned to. secret is the API key or token,
+-- encrypted with the encryption key; AAD: 'model_credential:' || id.
+CREATE TABLE model_credentials (
+    id            TEXT PRIMARY KEY,
+    org_id        TEXT NOT NULL REFERENCES orgs(id) ON DELETE CASCADE,
+    name          TEXT NOT NULL,
+    kind          INTEGER NOT NULL,
+    workspace_ids TEXT NOT NULL DEFAULT '',
+    secret        BLOB NOT NULL,
+    created_at    DATETIME NOT NULL DEFAULT (strftime('%Y-%m-%dT%H:%M:%fZ', 'now')),
+    updated_at    DATETIME NOT NULL DEFAULT (strftime('%Y-%m-%dT%H:%M:%fZ', 'now'))
+);
+CREATE UNIQUE INDEX idx_model_credentials_org_name ON model_credentials(org_id, name);
+
+-- +goose Down
+DROP TABLE IF EXISTS model_credentials;
@@ -0,0 +1,29 @@
+-- +goose Up
+
+-- The Hub admin's announcements (leapmuxv1.Announcement). severity is a
+-- leapmuxv1.AnnouncementSeverity. A NULL ends_at runs the announcement
+-- until it is deleted. created_by is the publishing admin, kept without a
+-- foreign key so the record outlives the account.
+CREATE TABLE announcements (
+    id         TEXT PRIMARY KEY,
+    title      TEXT NOT NULL,
+    body       TEXT NOT NULL,
+    severity   INTEGER NOT NULL,
+    starts_at  DATETIME NOT NULL,
+    ends_at    DATETIME,
+    created_by TEXT NOT NULL,
+    created_at DATETIME NOT NULL DEFAULT (strftime('%Y-%m-%dT%H:%M:%fZ', 'now'))
+);
+
+-- One row per user who acknowledged an announcement.
+CREATE TABLE announcement_acks (
+    announcement_id TEXT NOT NULL REFERENCES announcements(id) ON DELETE CASCADE,
+    user_id         TEXT NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    acknowledged_at DATETIME NOT NULL DEFAULT (strftime('%Y-%m-%dT%H:%M:%fZ', 'now')),
+    PRIMARY KEY (announcement_id, user_id)
+);
+CREATE INDEX idx_announcement_acks_user ON announcement_acks(user_id);
+
+-- +goose Down
+DROP TABLE IF EXISTS announcement_acks;
+DROP TABLE IF EXISTS announcements;
@@ -0,0 +1,18 @@
+-- +goose Up
+
+-- Emergency stops a Hub admin put in place (leapmuxv1.EmergencyStopState):
+-- the one on a whole org has worker_id '', the others are on single
+-- workers of it. A row per stop, rather than an entry in the owner's
+-- preferences, so no preferences write can undo one. stopped_by is the
+-- admin, kept without a foreign key so the record outlives the account.
+CREATE TABLE emergency_stops (
+    org_id     TEXT NOT NULL REFERENCES orgs(id) ON DELETE CASCADE,
+    worker_id  TEXT NOT NULL DEFAULT '',
+    reason     TEXT NOT NULL DEFAULT '',
+    stopped_by TEXT NOT NULL,
+    stopped_at DATETIME NOT NULL DEFAULT (strftime('%Y-%m-%dT%H:%M:%fZ', 'now')),
+    PRIMARY KEY (org_id, worker_id)
+);
+
+-- +goose Down
+DROP TABLE IF EXISTS emergency_stops;
@@ -0,0 +1,11 @@
+-- +goose Up
+
+-- prefs_version counts writes to users.prefs. Several RPCs each rewrite
+-- the one prefs JSON blob to change their own part of it, so a write is
+-- a compare-and-swap on this counter: of two concurrent writers the
+-- second re-reads the blob and reapplies its change instead of saving
+-- over the first's.
+ALTER TABLE users ADD COLUMN prefs_version INTEGER NOT NULL DEFAULT 0;
+
+-- +goose Down
+ALTER TABLE users DROP COLUMN prefs_version;
@@ -0,0 +1,16 @@
+-- +goose Up
+
+-- Each org's access policy (leapmuxv1.AccessPolicy). rules is the JSON
+-- array of its rules. A row per org, rather than an entry in the owner's
+-- preferences, so no preferences write can undo a policy. updated_by is
+-- the user who last set it, kept without a foreign key so the record
+-- outlives the account.
+CREATE TABLE access_policies (
+    org_id     TEXT NOT NULL PRIMARY KEY REFERENCES orgs(id) ON DELETE CASCADE,
+    rules      TEXT NOT NULL DEFAULT '[]',
+    updated_by TEXT NOT NULL,
+    updated_at DATETIME NOT NULL DEFAULT (strftime('%Y-%m-%dT%H:%M:%fZ', 'now'))
+);
+
+-- +goose Down
+DROP TABLE IF EXISTS access_policies;
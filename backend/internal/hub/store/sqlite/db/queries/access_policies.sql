@@ -0,0 +1,11 @@
+-- name: PutAccessPolicy :exec
+INSERT INTO access_policies (org_id, rules, updated_by)
+VALUES (?, ?, ?)
+ON CONFLICT (org_id) DO UPDATE SET
+  rules = excluded.rules,
+  updated_by = excluded.updated_by,
+  updated_at = strftime('%Y-%m-%dT%H:%M:%fZ', 'now');
+
+-- name: GetAccessPolicy :one
+SELECT * FROM access_policies
+WHERE org_id = ?;
@@ -0,0 +1,37 @@
+-- name: CreateAnnouncement :exec
+INSERT INTO announcements (id, title, body, severity, starts_at, ends_at, created_by)
+VALUES (?, ?, ?, ?, ?, ?, ?);
+
+-- name: GetAnnouncement :one
+SELECT * FROM announcements
+WHERE id = ?;
+
+-- name: ListAnnouncements :many
+SELECT a.*,
+  (SELECT COUNT(*) FROM announcement_acks k WHERE k.announcement_id = a.id) AS acknowledged_count
+FROM announcements a
+ORDER BY a.starts_at DESC, a.id;
+
+-- name: ListLiveAnnouncements :many
+SELECT * FROM announcements
+WHERE ends_at IS NULL OR ends_at > strftime('%Y-%m-%dT%H:%M:%fZ', 'now')
+ORDER BY starts_at, id;
+
+-- name: ListActiveAnnouncementsForUser :many
+SELECT a.* FROM announcements a
+WHERE a.starts_at <= strftime('%Y-%m-%dT%H:%M:%fZ', 'now')
+  AND (a.ends_at IS NULL OR a.ends_at > strftime('%Y-%m-%dT%H:%M:%fZ', 'now'))
+  AND NOT EXISTS (
+    SELECT 1 FROM announcement_acks k
+    WHERE k.announcement_id = a.id AND k.user_id = ?
+  )
+ORDER BY a.starts_at, a.id;
+
+-- name: DeleteAnnouncement :execresult
+DELETE FROM announcements
+WHERE id = ?;
+
+-- name: AcknowledgeAnnouncement :exec
+INSERT INTO announcement_acks (announcement_id, user_id)
+VALUES (?, ?)
+ON CONFLICT (announcement_id, user_id) DO NOTHING;
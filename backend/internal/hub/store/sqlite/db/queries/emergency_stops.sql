@@ -0,0 +1,21 @@
+-- name: PutEmergencyStop :exec
+-- Stopping an org or worker that is already stopped replaces the stop.
+INSERT INTO emergency_stops (org_id, worker_id, reason, stopped_by)
+VALUES (?, ?, ?, ?)
+ON CONFLICT (org_id, worker_id) DO UPDATE SET
+  reason = excluded.reason,
+  stopped_by = excluded.stopped_by,
+  stopped_at = strftime('%Y-%m-%dT%H:%M:%fZ', 'now');
+
+-- name: GetEmergencyStop :one
+SELECT * FROM emergency_stops
+WHERE org_id = ? AND worker_id = ?;
+
+-- name: ListEmergencyStopsByOrg :many
+SELECT * FROM emergency_stops
+WHERE org_id = ?
+ORDER BY worker_id;
+
+-- name: DeleteEmergencyStop :execresult
+DELETE FROM emergency_stops
+WHERE org_id = ? AND worker_id = ?;
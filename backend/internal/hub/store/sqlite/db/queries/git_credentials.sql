@@ -0,0 +1,35 @@
+-- name: CreateGitCredential :exec
+INSERT INTO git_credentials (id, org_id, name, kind, username, secret)
+VALUES (?, ?, ?, ?, ?, ?);
+
+-- name: GetGitCredential :one
+SELECT * FROM git_credentials
+WHERE id = ? AND org_id = ?;
+
+-- name: GetGitCredentialByName :one
+SELECT * FROM git_credentials
+WHERE org_id = ? AND name = ?;
+
+-- name: ListGitCredentialsByOrg :many
+SELECT * FROM git_credentials
+WHERE org_id = ?
+ORDER BY name, id;
+
+-- name: ListAllGitCredentials :many
+SELECT * FROM git_credentials
+ORDER BY id;
+
+-- name: UpdateGitCredential :exec
+UPDATE git_credentials SET
+  username = ?,
+  secret = ?,
+  updated_at = strftime('%Y-%m-%dT%H:%M:%fZ', 'now')
+WHERE id = ? AND org_id = ?;
+
+-- name: UpdateGitCredentialSecret :exec
+UPDATE git_credentials SET secret = ?
+WHERE id = ?;
+
+-- name: DeleteGitCredential :execresult
+DELETE FROM git_credentials
+WHERE id = ? AND org_id = ?;
@@ -0,0 +1,57 @@
+-- name: CreateGuestInvitation :exec
+INSERT INTO guest_invitations (
+    id, user_id, workspace_id, role, label, secret_hash, expires_at, auth_generation
+) VALUES (
+    sqlc.arg(id),
+    sqlc.arg(user_id),
+    sqlc.arg(workspace_id),
+    sqlc.arg(role),
+    sqlc.arg(label),
+    sqlc.arg(secret_hash),
+    sqlc.arg(expires_at),
+    (SELECT auth_generation FROM users WHERE users.id = sqlc.arg(user_id))
+);
+
+-- name: GetGuestInvitationByID :one
+SELECT * FROM guest_invitations WHERE id = ?;
+
+-- name: ListActiveGuestInvitationsByWorkspace :many
+-- Raw compare: expires_at is stored canonical (CreateGuestInvitation binds a
+-- SQLiteTime), so the liveness filter is millisecond-exact against the same
+-- canonical RHS layout.
+SELECT * FROM guest_invitations
+WHERE workspace_id = ?
+  AND revoked_at IS NULL
+  AND expires_at > strftime('%Y-%m-%dT%H:%M:%fZ', 'now')
+ORDER BY created_at DESC, id DESC;
+
+-- name: ListGuestInvitationsByUser :many
+-- Revoked and expired links included, for the user data export.
+SELECT * FROM guest_invitations
+WHERE user_id = ?
+ORDER BY created_at DESC, id DESC;
+
+-- name: ListExpiredLiveGuestInvitationIDs :many
+-- The cleanup job's scan, served by the partial
+-- idx_guest_invitations_expires_at.
+SELECT id FROM guest_invitations
+WHERE revoked_at IS NULL AND expires_at <= sqlc.arg(cutoff)
+ORDER BY expires_at
+LIMIT sqlc.arg(limit);
+
+-- name: TouchGuestInvitation :exec
+UPDATE guest_invitations
+SET last_used_at = strftime('%Y-%m-%dT%H:%M:%fZ', 'now')
+WHERE id = ?;
+
+-- name: RevokeGuestInvitation :one
+UPDATE guest_invitations
+SET revoked_at = strftime('%Y-%m-%dT%H:%M:%fZ', 'now')
+WHERE id = ? AND revoked_at IS NULL
+RETURNING id, user_id, revoked_at;
+
+-- name: DeleteRevokedGuestInvitationsBefore :execresult
+-- Raw compare: RevokeGuestInvitation stores the canonical strftime layout and
+-- the Go side binds a SQLiteTime cutoff, so the lexicographic < is byte-exact.
+DELETE FROM guest_invitations
+WHERE revoked_at IS NOT NULL AND revoked_at < sqlc.arg(cutoff);
@@ -0,0 +1,58 @@
+-- name: CreateSystemPrompt :exec
+INSERT INTO system_prompts (id, org_id, owner_user_id, name, shared)
+VALUES (?, ?, ?, ?, ?);
+
+-- name: CreateSystemPromptVersion :exec
+INSERT INTO system_prompt_versions (prompt_id, version, content, created_by)
+VALUES (?, ?, ?, ?);
+
+-- name: GetSystemPrompt :one
+SELECT p.id, p.org_id, p.owner_user_id, p.name, p.shared, p.latest_version, p.created_at, p.updated_at, v.version, v.content
+FROM system_prompts p
+JOIN system_prompt_versions v ON v.prompt_id = p.id AND v.version = p.latest_version
+WHERE p.id = ? AND p.org_id = ?;
+
+-- name: GetSystemPromptAtVersion :one
+SELECT p.id, p.org_id, p.owner_user_id, p.name, p.shared, p.latest_version, p.created_at, p.updated_at, v.version, v.content
+FROM system_prompts p
+JOIN system_prompt_versions v ON v.prompt_id = p.id
+WHERE p.id = ? AND p.org_id = ? AND v.version = ?;
+
+-- name: ListSystemPromptsByOrg :many
+SELECT p.id, p.org_id, p.owner_user_id, p.name, p.shared, p.latest_version, p.created_at, p.updated_at, v.version, v.content
+FROM system_prompts p
+JOIN system_prompt_versions v ON v.prompt_id = p.id AND v.version = p.latest_version
+WHERE p.org_id = ?
+ORDER BY p.name, p.id;
+
+-- name: ListSystemPromptsByOwner :many
+-- Across orgs, for the user data export.
+SELECT p.id, p.org_id, p.owner_user_id, p.name, p.shared, p.latest_version, p.created_at, p.updated_at, v.version, v.content
+FROM system_prompts p
+JOIN system_prompt_versions v ON v.prompt_id = p.id AND v.version = p.latest_version
+WHERE p.owner_user_id = ?
+ORDER BY p.org_id, p.name, p.id;
+
+-- name: UpdateSystemPrompt :exec
+UPDATE system_prompts SET
+  name = ?,
+  shared = ?,
+  updated_at = strftime('%Y-%m-%dT%H:%M:%fZ', 'now')
+WHERE id = ? AND org_id = ?;
+
+-- name: SetSystemPromptLatestVersion :exec
+UPDATE system_prompts SET
+  latest_version = ?,
+  updated_at = strftime('%Y-%m-%dT%H:%M:%fZ', 'now')
+WHERE id = ? AND org_id = ?;
+
+-- name: ListSystemPromptVersions :many
+SELECT v.prompt_id, v.version, v.content, v.created_by, v.created_at
+FROM system_prompt_versions v
+JOIN system_prompts p ON p.id = v.prompt_id
+WHERE p.id = ? AND p.org_id = ?
+ORDER BY v.version DESC;
+
+-- name: DeleteSystemPrompt :execresult
+DELETE FROM system_prompts
+WHERE id = ? AND org_id = ?;
@@ -161,10 +161,19 @@ DELETE FROM users WHERE rowid IN (
 -- name: GetUserPrefs :one
 SELECT prefs FROM users WHERE id = ? AND deleted_at IS NULL;
 
+-- name: GetUserPrefsVersion :one
+SELECT prefs, prefs_version FROM users WHERE id = ? AND deleted_at IS NULL;
+
 -- name: UpdateUserPrefs :exec
-UPDATE users SET prefs = ?, updated_at = strftime('%Y-%m-%dT%H:%M:%fZ', 'now')
+UPDATE users SET prefs = ?, prefs_version = prefs_version + 1, updated_at = strftime('%Y-%m-%dT%H:%M:%fZ', 'now')
 WHERE id = ?;
 
+-- name: SwapUserPrefs :execrows
+-- Writes prefs only if no write has landed since the caller read
+-- prefs_version; it affects no row when one has.
+UPDATE users SET prefs = ?, prefs_version = prefs_version + 1, updated_at = strftime('%Y-%m-%dT%H:%M:%fZ', 'now')
+WHERE id = ? AND prefs_version = ? AND deleted_at IS NULL;
+
 -- name: CountUsers :one
 SELECT count(*) FROM users WHERE deleted_at IS NULL;
 
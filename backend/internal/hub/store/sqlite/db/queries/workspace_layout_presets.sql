@@ -0,0 +1,49 @@
+-- name: UpsertWorkspaceLayoutPreset :exec
+-- Saving under an existing name replaces that preset's tree and keeps its
+-- id, so device classes that have it active follow the new tree.
+INSERT INTO workspace_layout_presets (id, user_id, workspace_id, name, root)
+VALUES (?, ?, ?, ?, ?)
+ON CONFLICT (user_id, workspace_id, name) DO UPDATE SET
+  root = excluded.root,
+  updated_at = strftime('%Y-%m-%dT%H:%M:%fZ', 'now');
+
+-- name: GetWorkspaceLayoutPresetByName :one
+SELECT * FROM workspace_layout_presets
+WHERE user_id = ? AND workspace_id = ? AND name = ?;
+
+-- name: GetWorkspaceLayoutPresetByID :one
+SELECT * FROM workspace_layout_presets
+WHERE id = ? AND user_id = ?;
+
+-- name: ListWorkspaceLayoutPresets :many
+SELECT * FROM workspace_layout_presets
+WHERE user_id = ? AND workspace_id = ?
+ORDER BY name, id;
+
+-- name: ListWorkspaceLayoutPresetsByUser :many
+SELECT * FROM workspace_layout_presets
+WHERE user_id = ?
+ORDER BY workspace_id, name, id;
+
+-- name: DeleteWorkspaceLayoutPreset :execresult
+DELETE FROM workspace_layout_presets
+WHERE id = ? AND user_id = ?;
+
+-- name: DeleteWorkspaceLayoutPresetsByUser :exec
+DELETE FROM workspace_layout_presets
+WHERE user_id = ?;
+
+-- name: SetWorkspaceLayoutSelection :exec
+INSERT INTO workspace_layout_selections (user_id, workspace_id, device_class, layout_id)
+VALUES (?, ?, ?, ?)
+ON CONFLICT (user_id, workspace_id, device_class) DO UPDATE SET
+  layout_id = excluded.layout_id;
+
+-- name: ClearWorkspaceLayoutSelection :exec
+DELETE FROM workspace_layout_selections
+WHERE user_id = ? AND workspace_id = ? AND device_class = ?;
+
+-- name: ListWorkspaceLayoutSelections :many
+SELECT * FROM workspace_layout_selections
+WHERE user_id = ? AND workspace_id = ?
+ORDER BY device_class;
@@ -0,0 +1,95 @@
+package sqlite
+
+import (
+	"context"
+	"time"
+
+	"github.com/leapmux/leapmux/internal/hub/store"
+	gendb "github.com/leapmux/leapmux/internal/hub/store/sqlite/generated/db"
+	"github.com/leapmux/leapmux/internal/util/sqltime"
+	"github.com/leapmux/leapmux/internal/util/userid"
+)
+
+type guestInvitationStore struct{ conn *sqliteConn }
+
+var _ store.GuestInvitationStore = (*guestInvitationStore)(nil)
+
+func fromDBGuestInvitation(g gendb.GuestInvitation) store.GuestInvitation {
+	return store.GuestInvitation{
+		ID:             g.ID,
+		UserID:         g.UserID,
+		WorkspaceID:    g.WorkspaceID,
+		Role:           g.Role,
+		Label:          g.Label,
+		SecretHash:     g.SecretHash,
+		AuthGeneration: g.AuthGeneration,
+		CreatedAt:      g.CreatedAt.Time,
+		LastUsedAt:     g.LastUsedAt.Ptr(),
+		ExpiresAt:      g.ExpiresAt.Time,
+		RevokedAt:      g.RevokedAt.Ptr(),
+	}
+}
+
+func (s *guestInvitationStore) Create(ctx context.Context, p store.CreateGuestInvitationParams) error {
+	return (&sqliteStore{conn: s.conn}).RunInUserAuthTransaction(ctx, p.UserID, func(tx store.Store) error {
+		return mapErr(tx.(*sqliteStore).conn.q.CreateGuestInvitation(ctx, gendb.CreateGuestInvitationParams{
+			ID:          p.ID,
+			UserID:      p.UserID.String(),
+			WorkspaceID: p.WorkspaceID,
+			Role:        p.Role,
+			Label:       p.Label,
+			SecretHash:  p.SecretHash,
+			ExpiresAt:   sqltime.NewSQLiteTime(p.ExpiresAt),
+		}))
+	})
+}
+
+func (s *guestInvitationStore) GetByID(ctx context.Context, id string) (*store.GuestInvitation, error) {
+	g, err := s.conn.q.GetGuestInvitationByID(ctx, id)
+	if err != nil {
+		return nil, mapErr(err)
+	}
+	out := fromDBGuestInvitation(g)
+	return &out, nil
+}
+
+func (s *guestInvitationStore) ListActiveByWorkspace(ctx context.Context, workspaceID string) ([]store.GuestInvitation, error) {
+	rows, err := s.conn.q.ListActiveGuestInvitationsByWorkspace(ctx, workspaceID)
+	if err != nil {
+		return nil, mapErr(err)
+	}
+	return store.MapSlice(rows, fromDBGuestInvitation), nil
+}
+
+func (s *guestInvitationStore) ListByUser(ctx context.Context, userID userid.UserID) ([]store.GuestInvitation, error) {
+	owner, ok := store.OwnerFilter(userID)
+	if !ok {
+		// An unminted caller owns nothing; binding "" would MATCH every
+		// blank-owner row rather than none. See store.OwnerFilter.
+		return nil, nil
+	}
+	rows, err := s.conn.q.ListGuestInvitationsByUser(ctx, owner)
+	if err != nil {
+		return nil, mapErr(err)
+	}
+	return store.MapSlice(rows, fromDBGuestInvitation), nil
+}
+
+func (s *guestInvitationStore) ListExpiredLiveIDs(ctx context.Context, cutoff time.Time, limit int) ([]string, error) {
+	ids, err := s.conn.q.ListExpiredLiveGuestInvitationIDs(ctx, gendb.ListExpiredLiveGuestInvitationIDsParams{
+		Cutoff: sqltime.NewSQLiteTime(cutoff),
+		Limit:  int64(limit),
+	})
+	return ids, mapErr(err)
+}
+
+func (s *guestInvitationStore) Touch(ctx context.Context, id string) error {
+	return mapErr(s.conn.q.TouchGuestInvitation(ctx, id))
+}
+
+func (s *guestInvitationStore) Revoke(ctx context.Context, id string) (int64, error) {
+	return store.RunCredentialMutation(ctx, s.conn.withTransaction, func(ctx context.Context, conn *sqliteConn) (*store.CredentialEvent, error) {
+		row, err := conn.q.RevokeGuestInvitation(ctx, id)
+		return revokedCredentialEvent(row.ID, row.UserID, row.RevokedAt, store.RevocationEventKindGuestInvitation, err)
+	}, emitCredentialEvent)
+}
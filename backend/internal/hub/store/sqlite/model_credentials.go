@@ -0,0 +1,88 @@
+package sqlite
+
+import (
+	"context"
+
+	"github.com/leapmux/leapmux/internal/hub/store"
+	gendb "github.com/leapmux/leapmux/internal/hub/store/sqlite/generated/db"
+	"github.com/leapmux/leapmux/internal/hub/store/sqlutil"
+)
+
+type modelCredentialStore struct {
+	conn *sqliteConn
+}
+
+var _ store.ModelCredentialStore = (*modelCredentialStore)(nil)
+
+func fromDBModelCredential(c gendb.ModelCredential) *store.ModelCredential {
+	return &store.ModelCredential{
+		ID:           c.ID,
+		OrgID:        c.OrgID,
+		Name:         c.Name,
+		Kind:         c.Kind,
+		WorkspaceIDs: sqlutil.SplitLabels(c.WorkspaceIds),
+		Secret:       c.Secret,
+		CreatedAt:    c.CreatedAt.Time,
+		UpdatedAt:    c.UpdatedAt.Time,
+	}
+}
+
+func (s *modelCredentialStore) Create(ctx context.Context, p store.CreateModelCredentialParams) (*store.ModelCredential, error) {
+	if err := s.conn.q.CreateModelCredential(ctx, gendb.CreateModelCredentialParams{
+		ID:           p.ID,
+		OrgID:        p.OrgID,
+		Name:         p.Name,
+		Kind:         p.Kind,
+		WorkspaceIds: sqlutil.JoinLabels(p.WorkspaceIDs),
+		Secret:       p.Secret,
+	}); err != nil {
+		return nil, mapErr(err)
+	}
+	return s.Get(ctx, store.GetModelCredentialParams{ID: p.ID, OrgID: p.OrgID})
+}
+
+func (s *modelCredentialStore) Get(ctx context.Context, p store.GetModelCredentialParams) (*store.ModelCredential, error) {
+	c, err := s.conn.q.GetModelCredential(ctx, gendb.GetModelCredentialParams{ID: p.ID, OrgID: p.OrgID})
+	if err != nil {
+		return nil, mapErr(err)
+	}
+	return fromDBModelCredential(c), nil
+}
+
+func (s *modelCredentialStore) ListByOrg(ctx context.Context, orgID string) ([]store.ModelCredential, error) {
+	rows, err := s.conn.q.ListModelCredentialsByOrg(ctx, orgID)
+	if err != nil {
+		return nil, mapErr(err)
+	}
+	return store.MapSlice(rows, func(c gendb.ModelCredential) store.ModelCredential { return *fromDBModelCredential(c) }), nil
+}
+
+func (s *modelCredentialStore) ListAll(ctx context.Context) ([]store.ModelCredential, error) {
+	rows, err := s.conn.q.ListAllModelCredentials(ctx)
+	if err != nil {
+		return nil, mapErr(err)
+	}
+	return store.MapSlice(rows, func(c gendb.ModelCredential) store.ModelCredential { return *fromDBModelCredential(c) }), nil
+}
+
+func (s *modelCredentialStore) Update(ctx context.Context, p store.UpdateModelCredentialParams) (*store.ModelCredential, error) {
+	// Existence is read back rather than taken from the affected-row count,
+	// which MySQL reports as zero for an update that changed nothing.
+	if err := s.conn.q.UpdateModelCredential(ctx, gendb.UpdateModelCredentialParams{
+		WorkspaceIds: sqlutil.JoinLabels(p.WorkspaceIDs),
+		Secret:       p.Secret,
+		ID:           p.ID,
+		OrgID:        p.OrgID,
+	}); err != nil {
+		return nil, mapErr(err)
+	}
+	return s.Get(ctx, store.GetModelCredentialParams{ID: p.ID, OrgID: p.OrgID})
+}
+
+func (s *modelCredentialStore) UpdateSecret(ctx context.Context, id string, secret []byte) error {
+	return mapErr(s.conn.q.UpdateModelCredentialSecret(ctx, gendb.UpdateModelCredentialSecretParams{Secret: secret, ID: id}))
+}
+
+func (s *modelCredentialStore) Delete(ctx context.Context, p store.GetModelCredentialParams) (int64, error) {
+	return rowsAffected(s.conn.q.DeleteModelCredential(ctx, gendb.DeleteModelCredentialParams{ID: p.ID, OrgID: p.OrgID}))
+}
@@ -0,0 +1,103 @@
+package sqlite
+
+import (
+	"context"
+
+	"github.com/leapmux/leapmux/internal/hub/store"
+	gendb "github.com/leapmux/leapmux/internal/hub/store/sqlite/generated/db"
+	"github.com/leapmux/leapmux/internal/util/userid"
+)
+
+type snippetStore struct {
+	conn *sqliteConn
+}
+
+var _ store.SnippetStore = (*snippetStore)(nil)
+
+func fromDBSnippet(s gendb.Snippet) *store.Snippet {
+	return &store.Snippet{
+		ID:          s.ID,
+		UserID:      s.UserID,
+		Name:        s.Name,
+		Description: s.Description,
+		Body:        s.Body,
+		CreatedAt:   s.CreatedAt.Time,
+		UpdatedAt:   s.UpdatedAt.Time,
+	}
+}
+
+func (s *snippetStore) Create(ctx context.Context, p store.CreateSnippetParams) (*store.Snippet, error) {
+	if err := s.conn.q.CreateSnippet(ctx, gendb.CreateSnippetParams{
+		ID:          p.ID,
+		UserID:      p.UserID.String(),
+		Name:        p.Name,
+		Description: p.Description,
+		Body:        p.Body,
+	}); err != nil {
+		return nil, mapErr(err)
+	}
+	return s.Get(ctx, store.GetSnippetParams{ID: p.ID, UserID: p.UserID})
+}
+
+func (s *snippetStore) Get(ctx context.Context, p store.GetSnippetParams) (*store.Snippet, error) {
+	owner, ok := store.OwnerFilter(p.UserID)
+	if !ok {
+		return nil, store.ErrNotFound // an unminted caller owns nothing; see OwnerFilter
+	}
+	r, err := s.conn.q.GetSnippet(ctx, gendb.GetSnippetParams{ID: p.ID, UserID: owner})
+	if err != nil {
+		return nil, mapErr(err)
+	}
+	return fromDBSnippet(r), nil
+}
+
+func (s *snippetStore) GetByName(ctx context.Context, p store.GetSnippetByNameParams) (*store.Snippet, error) {
+	owner, ok := store.OwnerFilter(p.UserID)
+	if !ok {
+		return nil, store.ErrNotFound // an unminted caller owns nothing; see OwnerFilter
+	}
+	r, err := s.conn.q.GetSnippetByName(ctx, gendb.GetSnippetByNameParams{UserID: owner, Name: p.Name})
+	if err != nil {
+		return nil, mapErr(err)
+	}
+	return fromDBSnippet(r), nil
+}
+
+func (s *snippetStore) ListByUser(ctx context.Context, userID userid.UserID) ([]store.Snippet, error) {
+	owner, ok := store.OwnerFilter(userID)
+	if !ok {
+		return nil, nil // an unminted caller owns nothing; see OwnerFilter
+	}
+	rows, err := s.conn.q.ListSnippetsByUser(ctx, owner)
+	if err != nil {
+		return nil, mapErr(err)
+	}
+	return store.MapSlice(rows, func(r gendb.Snippet) store.Snippet { return *fromDBSnippet(r) }), nil
+}
+
+func (s *snippetStore) Update(ctx context.Context, p store.UpdateSnippetParams) (*store.Snippet, error) {
+	owner, ok := store.OwnerFilter(p.UserID)
+	if !ok {
+		return nil, store.ErrNotFound // an unminted caller owns nothing; see OwnerFilter
+	}
+	// Existence is read back rather than taken from the affected-row count,
+	// which MySQL reports as zero for an update that changed nothing.
+	if err := s.conn.q.UpdateSnippet(ctx, gendb.UpdateSnippetParams{
+		Name:        p.Name,
+		Description: p.Description,
+		Body:        p.Body,
+		ID:          p.ID,
+		UserID:      owner,
+	}); err != nil {
+		return nil, mapErr(err)
+	}
+	return s.Get(ctx, store.GetSnippetParams{ID: p.ID, UserID: p.UserID})
+}
+
+func (s *snippetStore) Delete(ctx context.Context, p store.GetSnippetParams) (int64, error) {
+	owner, ok := store.OwnerFilter(p.UserID)
+	if !ok {
+		return 0, nil // an unminted caller owns nothing; see OwnerFilter
+	}
+	return rowsAffected(s.conn.q.DeleteSnippet(ctx, gendb.DeleteSnippetParams{ID: p.ID, UserID: owner}))
+}
@@ -98,6 +98,33 @@ func (s *sqliteStore) WorkspaceSections() store.WorkspaceSectionStore {
 func (s *sqliteStore) WorkspaceSectionItems() store.WorkspaceSectionItemStore {
 	return &workspaceSectionItemStore{conn: s.conn}
 }
+func (s *sqliteStore) WorkspaceLayoutPresets() store.WorkspaceLayoutPresetStore {
+	return &workspaceLayoutPresetStore{conn: s.conn}
+}
+func (s *sqliteStore) Repos() store.RepoStore {
+	return &repoStore{conn: s.conn}
+}
+func (s *sqliteStore) GitCredentials() store.GitCredentialStore {
+	return &gitCredentialStore{conn: s.conn}
+}
+func (s *sqliteStore) ModelCredentials() store.ModelCredentialStore {
+	return &modelCredentialStore{conn: s.conn}
+}
+func (s *sqliteStore) SystemPrompts() store.SystemPromptStore {
+	return &systemPromptStore{conn: s.conn}
+}
+func (s *sqliteStore) Snippets() store.SnippetStore {
+	return &snippetStore{conn: s.conn}
+}
+func (s *sqliteStore) Announcements() store.AnnouncementStore {
+	return &announcementStore{conn: s.conn}
+}
+func (s *sqliteStore) EmergencyStops() store.EmergencyStopStore {
+	return &emergencyStopStore{conn: s.conn}
+}
+func (s *sqliteStore) AccessPolicies() store.AccessPolicyStore {
+	return &accessPolicyStore{conn: s.conn}
+}
 func (s *sqliteStore) OAuthProviders() store.OAuthProviderStore {
 	return &oauthProviderStore{conn: s.conn}
 }
@@ -113,6 +140,9 @@ func (s *sqliteStore) APITokens() store.APITokenStore { return &apiTokenStore{co
 func (s *sqliteStore) DelegationTokens() store.DelegationTokenStore {
 	return &delegationTokenStore{conn: s.conn}
 }
+func (s *sqliteStore) GuestInvitations() store.GuestInvitationStore {
+	return &guestInvitationStore{conn: s.conn}
+}
 func (s *sqliteStore) RevocationEvents() store.RevocationEventStore {
 	return newRevocationEventStore(s.conn)
 }
@@ -0,0 +1,161 @@
+package sqlite
+
+import (
+	"context"
+
+	"github.com/leapmux/leapmux/internal/hub/store"
+	gendb "github.com/leapmux/leapmux/internal/hub/store/sqlite/generated/db"
+	"github.com/leapmux/leapmux/internal/util/userid"
+)
+
+type systemPromptStore struct {
+	conn *sqliteConn
+}
+
+var _ store.SystemPromptStore = (*systemPromptStore)(nil)
+
+// fromDBSystemPrompt converts the columns every prompt query selects; the
+// Get and List row types differ only in name.
+func fromDBSystemPrompt(r gendb.GetSystemPromptRow) *store.SystemPrompt {
+	return &store.SystemPrompt{
+		ID:            r.ID,
+		OrgID:         r.OrgID,
+		OwnerUserID:   r.OwnerUserID,
+		Name:          r.Name,
+		Shared:        r.Shared,
+		LatestVersion: r.LatestVersion,
+		Version:       r.Version,
+		Content:       r.Content,
+		CreatedAt:     r.CreatedAt.Time,
+		UpdatedAt:     r.UpdatedAt.Time,
+	}
+}
+
+func (s *systemPromptStore) Create(ctx context.Context, p store.CreateSystemPromptParams) (*store.SystemPrompt, error) {
+	err := s.conn.withTransaction(ctx, func(conn *sqliteConn) error {
+		if err := conn.q.CreateSystemPrompt(ctx, gendb.CreateSystemPromptParams{
+			ID:          p.ID,
+			OrgID:       p.OrgID,
+			OwnerUserID: p.OwnerUserID.String(),
+			Name:        p.Name,
+			Shared:      p.Shared,
+		}); err != nil {
+			return mapErr(err)
+		}
+		return mapErr(conn.q.CreateSystemPromptVersion(ctx, gendb.CreateSystemPromptVersionParams{
+			PromptID:  p.ID,
+			Version:   1,
+			Content:   p.Content,
+			CreatedBy: p.OwnerUserID.String(),
+		}))
+	})
+	if err != nil {
+		return nil, err
+	}
+	return s.Get(ctx, store.GetSystemPromptParams{ID: p.ID, OrgID: p.OrgID})
+}
+
+func (s *systemPromptStore) Get(ctx context.Context, p store.GetSystemPromptParams) (*store.SystemPrompt, error) {
+	if p.Version == 0 {
+		r, err := s.conn.q.GetSystemPrompt(ctx, gendb.GetSystemPromptParams{ID: p.ID, OrgID: p.OrgID})
+		if err != nil {
+			return nil, mapErr(err)
+		}
+		return fromDBSystemPrompt(r), nil
+	}
+	r, err := s.conn.q.GetSystemPromptAtVersion(ctx, gendb.GetSystemPromptAtVersionParams{ID: p.ID, OrgID: p.OrgID, Version: p.Version})
+	if err != nil {
+		return nil, mapErr(err)
+	}
+	return fromDBSystemPrompt(gendb.GetSystemPromptRow(r)), nil
+}
+
+func (s *systemPromptStore) ListByOrg(ctx context.Context, orgID string) ([]store.SystemPrompt, error) {
+	rows, err := s.conn.q.ListSystemPromptsByOrg(ctx, orgID)
+	if err != nil {
+		return nil, mapErr(err)
+	}
+	return store.MapSlice(rows, func(r gendb.ListSystemPromptsByOrgRow) store.SystemPrompt {
+		return *fromDBSystemPrompt(gendb.GetSystemPromptRow(r))
+	}), nil
+}
+
+func (s *systemPromptStore) ListByOwner(ctx context.Context, ownerUserID userid.UserID) ([]store.SystemPrompt, error) {
+	owner, ok := store.OwnerFilter(ownerUserID)
+	if !ok {
+		// An unminted caller owns nothing; binding "" would MATCH every
+		// blank-owner row rather than none. See store.OwnerFilter.
+		return nil, nil
+	}
+	rows, err := s.conn.q.ListSystemPromptsByOwner(ctx, owner)
+	if err != nil {
+		return nil, mapErr(err)
+	}
+	return store.MapSlice(rows, func(r gendb.ListSystemPromptsByOwnerRow) store.SystemPrompt {
+		return *fromDBSystemPrompt(gendb.GetSystemPromptRow(r))
+	}), nil
+}
+
+func (s *systemPromptStore) Update(ctx context.Context, p store.UpdateSystemPromptParams) (*store.SystemPrompt, error) {
+	// Existence is read back rather than taken from the affected-row count,
+	// which MySQL reports as zero for an update that changed nothing.
+	if err := s.conn.q.UpdateSystemPrompt(ctx, gendb.UpdateSystemPromptParams{
+		Name:   p.Name,
+		Shared: p.Shared,
+		ID:     p.ID,
+		OrgID:  p.OrgID,
+	}); err != nil {
+		return nil, mapErr(err)
+	}
+	return s.Get(ctx, store.GetSystemPromptParams{ID: p.ID, OrgID: p.OrgID})
+}
+
+func (s *systemPromptStore) AddVersion(ctx context.Context, p store.AddSystemPromptVersionParams) (*store.SystemPrompt, error) {
+	var version int64
+	err := s.conn.withTransaction(ctx, func(conn *sqliteConn) error {
+		cur, err := conn.q.GetSystemPrompt(ctx, gendb.GetSystemPromptParams{ID: p.ID, OrgID: p.OrgID})
+		if err != nil {
+			return mapErr(err)
+		}
+		// A concurrent AddVersion that read the same latest version loses
+		// on the (prompt_id, version) key and fails with ErrConflict.
+		version = cur.LatestVersion + 1
+		if err := conn.q.CreateSystemPromptVersion(ctx, gendb.CreateSystemPromptVersionParams{
+			PromptID:  p.ID,
+			Version:   version,
+			Content:   p.Content,
+			CreatedBy: p.CreatedBy.String(),
+		}); err != nil {
+			return mapErr(err)
+		}
+		return mapErr(conn.q.SetSystemPromptLatestVersion(ctx, gendb.SetSystemPromptLatestVersionParams{
+			LatestVersion: version,
+			ID:            p.ID,
+			OrgID:         p.OrgID,
+		}))
+	})
+	if err != nil {
+		return nil, err
+	}
+	return s.Get(ctx, store.GetSystemPromptParams{ID: p.ID, OrgID: p.OrgID, Version: version})
+}
+
+func (s *systemPromptStore) ListVersions(ctx context.Context, p store.GetSystemPromptParams) ([]store.SystemPromptVersion, error) {
+	rows, err := s.conn.q.ListSystemPromptVersions(ctx, gendb.ListSystemPromptVersionsParams{ID: p.ID, OrgID: p.OrgID})
+	if err != nil {
+		return nil, mapErr(err)
+	}
+	return store.MapSlice(rows, func(v gendb.SystemPromptVersion) store.SystemPromptVersion {
+		return store.SystemPromptVersion{
+			PromptID:  v.PromptID,
+			Version:   v.Version,
+			Content:   v.Content,
+			CreatedBy: v.CreatedBy,
+			CreatedAt: v.CreatedAt.Time,
+		}
+	}), nil
+}
+
+func (s *systemPromptStore) Delete(ctx context.Context, p store.GetSystemPromptParams) (int64, error) {
+	return rowsAffected(s.conn.q.DeleteSystemPrompt(ctx, gendb.DeleteSystemPromptParams{ID: p.ID, OrgID: p.OrgID}))
+}
@@ -3,6 +3,7 @@ package sqlutil
 import (
 	"database/sql"
 	"fmt"
+	"strings"
 	"time"
 
 	"github.com/leapmux/leapmux/internal/util/userid"
@@ -52,3 +53,15 @@ func NullUserID(u userid.UserID) sql.NullString {
 	}
 	return sql.NullString{String: u.String(), Valid: true}
 }
+
+// JoinLabels encodes a label list for a space-separated TEXT column. Labels
+// are validated to contain no whitespace before they reach the store.
+func JoinLabels(labels []string) string {
+	return strings.Join(labels, " ")
+}
+
+// SplitLabels decodes a column written by JoinLabels. An empty column is a
+// nil list.
+func SplitLabels(s string) []string {
+	return strings.Fields(s)
+}
@@ -9,8 +9,11 @@ var SQLTruncateTableOrder = []string{
 	"hub_runtime_lease", "revocation_events", "revocation_event_sequence",
 	"lifecycle_outbox", "org_recent_batch_ids", "workspace_tab_rendered", "workspace_tab_owned",
 	"org_state", "org_op_batches",
+	"workspace_layout_selections", "workspace_layout_presets",
+	"repos", "git_credentials", "model_credentials", "system_prompt_versions", "system_prompts",
+	"snippets", "announcement_acks", "announcements", "emergency_stops", "access_policies",
 	"workspace_section_items", "workspace_sections",
-	"delegation_tokens", "api_tokens",
+	"guest_invitations", "delegation_tokens", "api_tokens",
 	"workspaces", "worker_notifications", "worker_registration_keys", "workers",
 	"user_sessions", "users", "orgs",
 }
@@ -29,6 +29,15 @@ type Store interface {
 	LifecycleOutbox() LifecycleOutboxStore
 	WorkspaceSections() WorkspaceSectionStore
 	WorkspaceSectionItems() WorkspaceSectionItemStore
+	WorkspaceLayoutPresets() WorkspaceLayoutPresetStore
+	Repos() RepoStore
+	GitCredentials() GitCredentialStore
+	ModelCredentials() ModelCredentialStore
+	SystemPrompts() SystemPromptStore
+	Snippets() SnippetStore
+	Announcements() AnnouncementStore
+	EmergencyStops() EmergencyStopStore
+	AccessPolicies() AccessPolicyStore
 	OAuthProviders() OAuthProviderStore
 	OAuthStates() OAuthStateStore
 	OAuthTokens() OAuthTokenStore
@@ -36,6 +45,7 @@ type Store interface {
 	PendingOAuthSignups() PendingOAuthSignupStore
 	APITokens() APITokenStore
 	DelegationTokens() DelegationTokenStore
+	GuestInvitations() GuestInvitationStore
 	RevocationEvents() RevocationEventStore
 	DeviceAuthorizations() DeviceAuthorizationStore
 	CLIAuthorizationCodes() CLIAuthorizationCodeStore
@@ -114,6 +124,9 @@ type UserStore interface {
 	// for the constant-time code comparison that follows.
 	ConsumeVerificationAttempt(ctx context.Context, id string) (*User, error)
 	GetPrefs(ctx context.Context, id string) (string, error)
+	// GetPrefsVersion returns the user's prefs with the version SwapPrefs
+	// compares against.
+	GetPrefsVersion(ctx context.Context, id string) (UserPrefs, error)
 	HasAny(ctx context.Context) (bool, error)
 	Count(ctx context.Context) (int64, error)
 	ListAll(ctx context.Context, p ListAllUsersParams) (Page[User], error)
@@ -124,6 +137,11 @@ type UserStore interface {
 	UpdateEmailVerified(ctx context.Context, p UpdateUserEmailVerifiedParams) error
 	UpdateAdmin(ctx context.Context, p UpdateUserAdminParams) error
 	UpdatePrefs(ctx context.Context, p UpdateUserPrefsParams) error
+	// SwapPrefs writes p.Prefs only if the stored version is still
+	// p.Version, and reports whether it did. A read-modify-write of the
+	// prefs blob must go through it so a concurrent write is retried
+	// rather than lost.
+	SwapPrefs(ctx context.Context, p SwapUserPrefsParams) (bool, error)
 	SetPendingEmail(ctx context.Context, p SetPendingEmailParams) error
 	PromotePendingEmail(ctx context.Context, id string) error
 	ClearPendingEmail(ctx context.Context, id string) error
@@ -420,12 +438,34 @@ type DelegationTokenStore interface {
 	RevokeByUser(ctx context.Context, userID userid.UserID) (int64, error)
 }
 
+// GuestInvitationStore manages time-boxed guest links to a workspace.
+type GuestInvitationStore interface {
+	Create(ctx context.Context, p CreateGuestInvitationParams) error
+	GetByID(ctx context.Context, id string) (*GuestInvitation, error)
+	// ListActiveByWorkspace returns the workspace's links that are neither
+	// revoked nor expired, newest first.
+	ListActiveByWorkspace(ctx context.Context, workspaceID string) ([]GuestInvitation, error)
+	// ListByUser returns every link userID created, revoked and expired
+	// ones included, newest first.
+	ListByUser(ctx context.Context, userID userid.UserID) ([]GuestInvitation, error)
+	// ListExpiredLiveIDs returns up to limit links that expired at or
+	// before cutoff but are not revoked yet, oldest expiry first, for the
+	// cleanup job to revoke.
+	ListExpiredLiveIDs(ctx context.Context, cutoff time.Time, limit int) ([]string, error)
+	Touch(ctx context.Context, id string) error
+	// Revoke revokes a live link and publishes a guest_invitation
+	// revocation event with it. Returns 0 when the link was already
+	// revoked or does not exist.
+	Revoke(ctx context.Context, id string) (int64, error)
+}
+
 // Credential lifecycle event kinds persisted in revocation_events.kind.
 const (
 	RevocationEventKindSession          = "session"
 	RevocationEventKindAPIToken         = "api_token"
 	RevocationEventKindAPITokenRotation = "api_token_rotation"
 	RevocationEventKindDelegationToken  = "delegation_token"
+	RevocationEventKindGuestInvitation  = "guest_invitation"
 	RevocationEventKindUserTokens       = "user_tokens"
 	// RevocationEventKindUserInfo is a cache-invalidation signal rather
 	// than a credential revocation: an admin changed a user's cached
@@ -530,6 +570,160 @@ type WorkspaceSectionItemStore interface {
 	IsInArchivedSection(ctx context.Context, p IsWorkspaceInArchivedSectionParams) (bool, error)
 }
 
+// WorkspaceLayoutPresetStore manages each user's named layout presets per
+// workspace and the preset each of their device classes shows.
+type WorkspaceLayoutPresetStore interface {
+	// Upsert creates the preset, or replaces the root of the user's preset
+	// with the same name in the workspace, keeping that preset's id.
+	Upsert(ctx context.Context, p UpsertWorkspaceLayoutPresetParams) (*WorkspaceLayoutPreset, error)
+	GetByID(ctx context.Context, p GetWorkspaceLayoutPresetParams) (*WorkspaceLayoutPreset, error)
+	ListByWorkspace(ctx context.Context, p ListWorkspaceLayoutPresetsParams) ([]WorkspaceLayoutPreset, error)
+	// ListByUser returns the user's presets in every workspace.
+	ListByUser(ctx context.Context, userID userid.UserID) ([]WorkspaceLayoutPreset, error)
+	// Delete removes the preset and, by cascade, every device-class
+	// selection of it.
+	Delete(ctx context.Context, p DeleteWorkspaceLayoutPresetParams) (int64, error)
+	// DeleteByUser removes every preset of the user, and so every
+	// selection of them.
+	DeleteByUser(ctx context.Context, userID userid.UserID) error
+	SetActive(ctx context.Context, p SetWorkspaceLayoutSelectionParams) error
+	ClearActive(ctx context.Context, p ClearWorkspaceLayoutSelectionParams) error
+	ListActive(ctx context.Context, p ListWorkspaceLayoutPresetsParams) ([]WorkspaceLayoutSelection, error)
+}
+
+// RepoStore manages each org's registry of git repositories. Every method
+// is scoped to an org, so a repo id from another org reads as not found.
+type RepoStore interface {
+	// Create fails with ErrConflict when the org already has a repo by
+	// that name.
+	Create(ctx context.Context, p CreateRepoParams) (*Repo, error)
+	Get(ctx context.Context, p GetRepoParams) (*Repo, error)
+	ListByOrg(ctx context.Context, orgID string) ([]Repo, error)
+	// Update replaces every field but the id and org. It fails with
+	// ErrNotFound for an unknown repo and ErrConflict for a taken name.
+	Update(ctx context.Context, p UpdateRepoParams) (*Repo, error)
+	Delete(ctx context.Context, p GetRepoParams) (int64, error)
+}
+
+// GitCredentialStore manages each org's git credentials. All methods but
+// ListAll and UpdateSecret, which serve key rotation, are scoped to an org.
+type GitCredentialStore interface {
+	// Create fails with ErrConflict when the org already has a credential
+	// by that name.
+	Create(ctx context.Context, p CreateGitCredentialParams) (*GitCredential, error)
+	Get(ctx context.Context, p GetGitCredentialParams) (*GitCredential, error)
+	GetByName(ctx context.Context, p GetGitCredentialByNameParams) (*GitCredential, error)
+	ListByOrg(ctx context.Context, orgID string) ([]GitCredential, error)
+	ListAll(ctx context.Context) ([]GitCredential, error)
+	// Update replaces the username and secret. It fails with ErrNotFound
+	// for an unknown credential.
+	Update(ctx context.Context, p UpdateGitCredentialParams) (*GitCredential, error)
+	// UpdateSecret replaces only the ciphertext, for re-encryption under a
+	// new key version.
+	UpdateSecret(ctx context.Context, id string, secret []byte) error
+	Delete(ctx context.Context, p GetGitCredentialParams) (int64, error)
+}
+
+// SystemPromptStore manages the system prompt library. Every method is
+// scoped to an org; who may see or change a prompt within it is the
+// caller's decision.
+type SystemPromptStore interface {
+	// Create stores the prompt with its content as version 1. It fails
+	// with ErrConflict when the owner already has a prompt by that name.
+	Create(ctx context.Context, p CreateSystemPromptParams) (*SystemPrompt, error)
+	// Get fails with ErrNotFound for an unknown prompt or version.
+	Get(ctx context.Context, p GetSystemPromptParams) (*SystemPrompt, error)
+	// ListByOrg returns every prompt in the org at its latest version.
+	ListByOrg(ctx context.Context, orgID string) ([]SystemPrompt, error)
+	// ListByOwner returns the prompts ownerUserID owns, in every org, at
+	// their latest version.
+	ListByOwner(ctx context.Context, ownerUserID userid.UserID) ([]SystemPrompt, error)
+	// Update replaces the name and sharing. It fails with ErrNotFound for
+	// an unknown prompt and ErrConflict for a taken name.
+	Update(ctx context.Context, p UpdateSystemPromptParams) (*SystemPrompt, error)
+	// AddVersion stores content as the prompt's next version and returns
+	// the prompt at it.
+	AddVersion(ctx context.Context, p AddSystemPromptVersionParams) (*SystemPrompt, error)
+	// ListVersions returns the prompt's versions, newest first.
+	ListVersions(ctx context.Context, p GetSystemPromptParams) ([]SystemPromptVersion, error)
+	// Delete removes the prompt and all its versions.
+	Delete(ctx context.Context, p GetSystemPromptParams) (int64, error)
+}
+
+// SnippetStore manages users' prompt snippets. Every method is scoped to
+// the owning user.
+type SnippetStore interface {
+	// Create fails with ErrConflict when the user already has a snippet by
+	// that name.
+	Create(ctx context.Context, p CreateSnippetParams) (*Snippet, error)
+	Get(ctx context.Context, p GetSnippetParams) (*Snippet, error)
+	// GetByName fails with ErrNotFound when the user has no snippet by
+	// that name.
+	GetByName(ctx context.Context, p GetSnippetByNameParams) (*Snippet, error)
+	ListByUser(ctx context.Context, userID userid.UserID) ([]Snippet, error)
+	// Update replaces the name, description and body. It fails with
+	// ErrNotFound for an unknown snippet and ErrConflict for a taken name.
+	Update(ctx context.Context, p UpdateSnippetParams) (*Snippet, error)
+	Delete(ctx context.Context, p GetSnippetParams) (int64, error)
+}
+
+// ModelCredentialStore manages each org's model credentials. All methods
+// but ListAll and UpdateSecret, which serve key rotation, are scoped to an
+// org.
+type ModelCredentialStore interface {
+	// Create fails with ErrConflict when the org already has a credential
+	// by that name.
+	Create(ctx context.Context, p CreateModelCredentialParams) (*ModelCredential, error)
+	Get(ctx context.Context, p GetModelCredentialParams) (*ModelCredential, error)
+	ListByOrg(ctx context.Context, orgID string) ([]ModelCredential, error)
+	ListAll(ctx context.Context) ([]ModelCredential, error)
+	// Update replaces the workspace pins and secret. It fails with
+	// ErrNotFound for an unknown credential.
+	Update(ctx context.Context, p UpdateModelCredentialParams) (*ModelCredential, error)
+	// UpdateSecret replaces only the ciphertext, for re-encryption under a
+	// new key version.
+	UpdateSecret(ctx context.Context, id string, secret []byte) error
+	Delete(ctx context.Context, p GetModelCredentialParams) (int64, error)
+}
+
+// AnnouncementStore manages the Hub admin's announcements and who
+// acknowledged them. "Now" is the database's clock.
+type AnnouncementStore interface {
+	Create(ctx context.Context, p CreateAnnouncementParams) (*Announcement, error)
+	GetByID(ctx context.Context, id string) (*Announcement, error)
+	// ListAll returns every announcement, latest start first, with its
+	// AcknowledgedCount.
+	ListAll(ctx context.Context) ([]Announcement, error)
+	// ListLive returns the announcements that have not ended, scheduled
+	// ones included, earliest start first.
+	ListLive(ctx context.Context) ([]Announcement, error)
+	// ListActiveForUser returns the running announcements userID has not
+	// acknowledged, earliest start first.
+	ListActiveForUser(ctx context.Context, userID userid.UserID) ([]Announcement, error)
+	Delete(ctx context.Context, id string) (int64, error)
+	// Acknowledge records that userID read the announcement; doing so
+	// again is a no-op.
+	Acknowledge(ctx context.Context, p AcknowledgeAnnouncementParams) error
+}
+
+// EmergencyStopStore manages the emergency stops a Hub admin put on orgs
+// and their workers. A WorkerID of "" is the stop on the whole org.
+type EmergencyStopStore interface {
+	// Put places the stop, replacing any already on the same org or worker.
+	Put(ctx context.Context, p PutEmergencyStopParams) (*EmergencyStop, error)
+	// ListByOrg returns the org's stops, the org-wide one first.
+	ListByOrg(ctx context.Context, orgID string) ([]EmergencyStop, error)
+	Delete(ctx context.Context, p DeleteEmergencyStopParams) (int64, error)
+}
+
+// AccessPolicyStore manages each org's access policy.
+type AccessPolicyStore interface {
+	// Get returns ErrNotFound for an org that never set a policy.
+	Get(ctx context.Context, orgID string) (*AccessPolicy, error)
+	// Put replaces the org's policy.
+	Put(ctx context.Context, p PutAccessPolicyParams) error
+}
+
 type OAuthProviderStore interface {
 	Create(ctx context.Context, p CreateOAuthProviderParams) error
 	GetByID(ctx context.Context, id string) (*OAuthProvider, error)
@@ -594,6 +788,7 @@ type CleanupStore interface {
 	DeleteRevokedAPITokensBefore(ctx context.Context, cutoff time.Time) (int64, error)
 	DeleteRevokedDelegationTokensBefore(ctx context.Context, cutoff time.Time) (int64, error)
 	DeleteExpiredDelegationTokensBefore(ctx context.Context, cutoff time.Time) (int64, error)
+	DeleteRevokedGuestInvitationsBefore(ctx context.Context, cutoff time.Time) (int64, error)
 	// CompactPublishedRevocationEvents removes an expired Hub runtime lease,
 	// then deletes retained events only through the live Hub cursor.
 	CompactPublishedRevocationEvents(ctx context.Context, p CompactRevocationEventsParams) (int64, error)
@@ -33,6 +33,15 @@ func (s *Suite) Run(t *testing.T) {
 	// than via plain table CRUD.
 	t.Run("workspace_sections", s.testWorkspaceSections)
 	t.Run("workspace_section_items", s.testWorkspaceSectionItems)
+	t.Run("workspace_layout_presets", s.testWorkspaceLayoutPresets)
+	t.Run("repos", s.testRepos)
+	t.Run("git credentials", s.testGitCredentials)
+	t.Run("model credentials", s.testModelCredentials)
+	t.Run("system_prompts", s.testSystemPrompts)
+	t.Run("snippets", s.testSnippets)
+	t.Run("announcements", s.testAnnouncements)
+	t.Run("emergency_stops", s.testEmergencyStops)
+	t.Run("access_policies", s.testAccessPolicies)
 	t.Run("oauth_providers", s.testOAuthProviders)
 	t.Run("oauth_states", s.testOAuthStates)
 	t.Run("oauth_tokens", s.testOAuthTokens)
@@ -45,6 +54,7 @@ func (s *Suite) Run(t *testing.T) {
 	t.Run("time_floor", s.testTimeFloor)
 	t.Run("token_revocation", s.testTokenRevocation)
 	t.Run("token_listing", s.testTokenListing)
+	t.Run("guest_invitations", s.testGuestInvitations)
 	// `migrator` runs last because its `migrate to zero` subtest leaves
 	// the schema partially dropped, and the suite's per-test re-migrate
 	// trampoline can't always recover the dropped state cleanly. Any
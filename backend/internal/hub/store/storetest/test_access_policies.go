@@ -0,0 +1,39 @@
+package storetest
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/leapmux/leapmux/internal/hub/store"
+)
+
+func (s *Suite) testAccessPolicies(t *testing.T) {
+	t.Run("get before put is not found", func(t *testing.T) {
+		st := s.NewStore(t)
+		orgID := SeedOrg(t, st, "policy-org")
+
+		_, err := st.AccessPolicies().Get(ctx, orgID)
+		assert.ErrorIs(t, err, store.ErrNotFound)
+	})
+
+	t.Run("put replaces", func(t *testing.T) {
+		st := s.NewStore(t)
+		orgID := SeedOrg(t, st, "policy-org")
+
+		require.NoError(t, st.AccessPolicies().Put(ctx, store.PutAccessPolicyParams{
+			OrgID: orgID, Rules: `[{"name":"first"}]`, UpdatedBy: "user-1",
+		}))
+		require.NoError(t, st.AccessPolicies().Put(ctx, store.PutAccessPolicyParams{
+			OrgID: orgID, Rules: `[{"name":"second"}]`, UpdatedBy: "user-2",
+		}))
+
+		p, err := st.AccessPolicies().Get(ctx, orgID)
+		require.NoError(t, err)
+		assert.Equal(t, orgID, p.OrgID)
+		assert.JSONEq(t, `[{"name":"second"}]`, p.Rules)
+		assert.Equal(t, "user-2", p.UpdatedBy)
+		assert.False(t, p.UpdatedAt.IsZero())
+	})
+}
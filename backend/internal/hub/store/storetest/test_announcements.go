@@ -0,0 +1,127 @@
+package storetest
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	leapmuxv1 "github.com/leapmux/leapmux/generated/proto/leapmux/v1"
+	"github.com/leapmux/leapmux/internal/hub/store"
+	"github.com/leapmux/leapmux/internal/util/id"
+	"github.com/leapmux/leapmux/internal/util/userid"
+)
+
+func (s *Suite) testAnnouncements(t *testing.T) {
+	create := func(t *testing.T, st store.Store, title string, startsAt time.Time, endsAt *time.Time) *store.Announcement {
+		t.Helper()
+		a, err := st.Announcements().Create(ctx, store.CreateAnnouncementParams{
+			ID:        id.Generate(),
+			Title:     title,
+			Body:      "the " + title + " announcement",
+			Severity:  leapmuxv1.AnnouncementSeverity_ANNOUNCEMENT_SEVERITY_MAINTENANCE,
+			StartsAt:  startsAt,
+			EndsAt:    endsAt,
+			CreatedBy: "admin",
+		})
+		require.NoError(t, err)
+		return a
+	}
+	titles := func(as []store.Announcement) []string {
+		out := make([]string, len(as))
+		for i, a := range as {
+			out[i] = a.Title
+		}
+		return out
+	}
+	now := time.Now().UTC()
+	ptr := func(t time.Time) *time.Time { return &t }
+
+	t.Run("create and get", func(t *testing.T) {
+		st := s.NewStore(t)
+		ends := now.Add(time.Hour)
+		a := create(t, st, "upgrade", now.Add(-time.Minute), &ends)
+
+		got, err := st.Announcements().GetByID(ctx, a.ID)
+		require.NoError(t, err)
+		assert.Equal(t, "upgrade", got.Title)
+		assert.Equal(t, "the upgrade announcement", got.Body)
+		assert.Equal(t, leapmuxv1.AnnouncementSeverity_ANNOUNCEMENT_SEVERITY_MAINTENANCE, got.Severity)
+		assert.WithinDuration(t, now.Add(-time.Minute), got.StartsAt, time.Millisecond)
+		require.NotNil(t, got.EndsAt)
+		assert.WithinDuration(t, ends, *got.EndsAt, time.Millisecond)
+		assert.Equal(t, "admin", got.CreatedBy)
+		assert.False(t, got.CreatedAt.IsZero())
+
+		_, err = st.Announcements().GetByID(ctx, "missing")
+		assert.ErrorIs(t, err, store.ErrNotFound)
+	})
+
+	t.Run("live and active", func(t *testing.T) {
+		st := s.NewStore(t)
+		orgID := SeedOrg(t, st, "announce-org")
+		user := SeedUser(t, st, orgID, "announce-user")
+		uid := userid.MustNew(user.ID)
+
+		create(t, st, "ended", now.Add(-2*time.Hour), ptr(now.Add(-time.Hour)))
+		running := create(t, st, "running", now.Add(-time.Hour), nil)
+		create(t, st, "scheduled", now.Add(time.Hour), ptr(now.Add(2*time.Hour)))
+
+		live, err := st.Announcements().ListLive(ctx)
+		require.NoError(t, err)
+		assert.Equal(t, []string{"running", "scheduled"}, titles(live))
+
+		active, err := st.Announcements().ListActiveForUser(ctx, uid)
+		require.NoError(t, err)
+		assert.Equal(t, []string{"running"}, titles(active))
+
+		ack := store.AcknowledgeAnnouncementParams{AnnouncementID: running.ID, UserID: uid}
+		require.NoError(t, st.Announcements().Acknowledge(ctx, ack))
+		require.NoError(t, st.Announcements().Acknowledge(ctx, ack), "acknowledging twice is a no-op")
+		active, err = st.Announcements().ListActiveForUser(ctx, uid)
+		require.NoError(t, err)
+		assert.Empty(t, active)
+	})
+
+	t.Run("list all counts acknowledgments", func(t *testing.T) {
+		st := s.NewStore(t)
+		orgID := SeedOrg(t, st, "announce-org")
+		first := SeedUser(t, st, orgID, "announce-first")
+		second := SeedUser(t, st, orgID, "announce-second")
+
+		older := create(t, st, "older", now.Add(-2*time.Hour), nil)
+		create(t, st, "newer", now.Add(-time.Hour), nil)
+		for _, u := range []string{first.ID, second.ID} {
+			require.NoError(t, st.Announcements().Acknowledge(ctx, store.AcknowledgeAnnouncementParams{
+				AnnouncementID: older.ID, UserID: userid.MustNew(u),
+			}))
+		}
+
+		all, err := st.Announcements().ListAll(ctx)
+		require.NoError(t, err)
+		require.Equal(t, []string{"newer", "older"}, titles(all))
+		assert.Zero(t, all[0].AcknowledgedCount)
+		assert.EqualValues(t, 2, all[1].AcknowledgedCount)
+	})
+
+	t.Run("delete drops acknowledgments", func(t *testing.T) {
+		st := s.NewStore(t)
+		orgID := SeedOrg(t, st, "announce-org")
+		user := SeedUser(t, st, orgID, "announce-user")
+		a := create(t, st, "gone", now.Add(-time.Hour), nil)
+		require.NoError(t, st.Announcements().Acknowledge(ctx, store.AcknowledgeAnnouncementParams{
+			AnnouncementID: a.ID, UserID: userid.MustNew(user.ID),
+		}))
+
+		n, err := st.Announcements().Delete(ctx, a.ID)
+		require.NoError(t, err)
+		assert.EqualValues(t, 1, n)
+		n, err = st.Announcements().Delete(ctx, a.ID)
+		require.NoError(t, err)
+		assert.Zero(t, n)
+		all, err := st.Announcements().ListAll(ctx)
+		require.NoError(t, err)
+		assert.Empty(t, all)
+	})
+}
@@ -0,0 +1,63 @@
+package storetest
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/leapmux/leapmux/internal/hub/store"
+)
+
+func (s *Suite) testEmergencyStops(t *testing.T) {
+	t.Run("put replaces and list puts the org first", func(t *testing.T) {
+		st := s.NewStore(t)
+		orgID := SeedOrg(t, st, "stop-org")
+
+		stop, err := st.EmergencyStops().Put(ctx, store.PutEmergencyStopParams{
+			OrgID: orgID, WorkerID: "w-1", Reason: "first", StoppedBy: "admin",
+		})
+		require.NoError(t, err)
+		assert.Equal(t, "w-1", stop.WorkerID)
+		assert.Equal(t, "first", stop.Reason)
+		assert.False(t, stop.StoppedAt.IsZero())
+
+		_, err = st.EmergencyStops().Put(ctx, store.PutEmergencyStopParams{
+			OrgID: orgID, WorkerID: "w-1", Reason: "second", StoppedBy: "other-admin",
+		})
+		require.NoError(t, err)
+		_, err = st.EmergencyStops().Put(ctx, store.PutEmergencyStopParams{
+			OrgID: orgID, Reason: "whole org", StoppedBy: "admin",
+		})
+		require.NoError(t, err)
+
+		stops, err := st.EmergencyStops().ListByOrg(ctx, orgID)
+		require.NoError(t, err)
+		require.Len(t, stops, 2)
+		assert.Empty(t, stops[0].WorkerID, "the org-wide stop sorts first")
+		assert.Equal(t, "whole org", stops[0].Reason)
+		assert.Equal(t, "second", stops[1].Reason)
+		assert.Equal(t, "other-admin", stops[1].StoppedBy)
+
+		other, err := st.EmergencyStops().ListByOrg(ctx, SeedOrg(t, st, "other-org"))
+		require.NoError(t, err)
+		assert.Empty(t, other)
+	})
+
+	t.Run("delete", func(t *testing.T) {
+		st := s.NewStore(t)
+		orgID := SeedOrg(t, st, "stop-delete-org")
+		_, err := st.EmergencyStops().Put(ctx, store.PutEmergencyStopParams{OrgID: orgID, StoppedBy: "admin"})
+		require.NoError(t, err)
+
+		n, err := st.EmergencyStops().Delete(ctx, store.DeleteEmergencyStopParams{OrgID: orgID, WorkerID: "w-1"})
+		require.NoError(t, err)
+		assert.Zero(t, n, "the org-wide stop is not the worker's")
+		n, err = st.EmergencyStops().Delete(ctx, store.DeleteEmergencyStopParams{OrgID: orgID})
+		require.NoError(t, err)
+		assert.EqualValues(t, 1, n)
+		stops, err := st.EmergencyStops().ListByOrg(ctx, orgID)
+		require.NoError(t, err)
+		assert.Empty(t, stops)
+	})
+}
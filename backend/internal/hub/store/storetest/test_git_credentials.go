@@ -0,0 +1,108 @@
+package storetest
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	leapmuxv1 "github.com/leapmux/leapmux/generated/proto/leapmux/v1"
+	"github.com/leapmux/leapmux/internal/hub/store"
+	"github.com/leapmux/leapmux/internal/util/id"
+)
+
+func (s *Suite) testGitCredentials(t *testing.T) {
+	create := func(t *testing.T, st store.Store, orgID, name string) *store.GitCredential {
+		t.Helper()
+		cred, err := st.GitCredentials().Create(ctx, store.CreateGitCredentialParams{
+			ID:       id.Generate(),
+			OrgID:    orgID,
+			Name:     name,
+			Kind:     leapmuxv1.GitCredentialKind_GIT_CREDENTIAL_KIND_HTTPS_TOKEN,
+			Username: "bot",
+			Secret:   []byte("ciphertext-" + name),
+		})
+		require.NoError(t, err)
+		return cred
+	}
+
+	t.Run("create and get round-trip", func(t *testing.T) {
+		st := s.NewStore(t)
+		orgID := SeedOrg(t, st, "cred-org")
+
+		cred := create(t, st, orgID, "github")
+		got, err := st.GitCredentials().Get(ctx, store.GetGitCredentialParams{ID: cred.ID, OrgID: orgID})
+		require.NoError(t, err)
+		assert.Equal(t, "github", got.Name)
+		assert.Equal(t, leapmuxv1.GitCredentialKind_GIT_CREDENTIAL_KIND_HTTPS_TOKEN, got.Kind)
+		assert.Equal(t, "bot", got.Username)
+		assert.Equal(t, []byte("ciphertext-github"), got.Secret)
+		assert.False(t, got.CreatedAt.IsZero())
+
+		byName, err := st.GitCredentials().GetByName(ctx, store.GetGitCredentialByNameParams{OrgID: orgID, Name: "github"})
+		require.NoError(t, err)
+		assert.Equal(t, cred.ID, byName.ID)
+	})
+
+	t.Run("names are unique per org and lookups are scoped to it", func(t *testing.T) {
+		st := s.NewStore(t)
+		orgID := SeedOrg(t, st, "cred-org")
+		otherOrg := SeedOrg(t, st, "cred-other-org")
+
+		cred := create(t, st, orgID, "github")
+		_, err := st.GitCredentials().Create(ctx, store.CreateGitCredentialParams{
+			ID: id.Generate(), OrgID: orgID, Name: "github",
+			Kind: leapmuxv1.GitCredentialKind_GIT_CREDENTIAL_KIND_SSH_KEY, Secret: []byte("x"),
+		})
+		assert.ErrorIs(t, err, store.ErrConflict)
+		create(t, st, otherOrg, "github")
+		create(t, st, orgID, "gitlab")
+
+		list, err := st.GitCredentials().ListByOrg(ctx, orgID)
+		require.NoError(t, err)
+		require.Len(t, list, 2)
+		assert.Equal(t, "github", list[0].Name)
+		assert.Equal(t, "gitlab", list[1].Name)
+
+		all, err := st.GitCredentials().ListAll(ctx)
+		require.NoError(t, err)
+		assert.Len(t, all, 3)
+
+		_, err = st.GitCredentials().Get(ctx, store.GetGitCredentialParams{ID: cred.ID, OrgID: otherOrg})
+		assert.ErrorIs(t, err, store.ErrNotFound)
+		n, err := st.GitCredentials().Delete(ctx, store.GetGitCredentialParams{ID: cred.ID, OrgID: otherOrg})
+		require.NoError(t, err)
+		assert.Zero(t, n)
+	})
+
+	t.Run("update and delete", func(t *testing.T) {
+		st := s.NewStore(t)
+		orgID := SeedOrg(t, st, "cred-org")
+		cred := create(t, st, orgID, "github")
+
+		updated, err := st.GitCredentials().Update(ctx, store.UpdateGitCredentialParams{
+			ID: cred.ID, OrgID: orgID, Username: "ci", Secret: []byte("rotated"),
+		})
+		require.NoError(t, err)
+		assert.Equal(t, "ci", updated.Username)
+		assert.Equal(t, []byte("rotated"), updated.Secret)
+		_, err = st.GitCredentials().Update(ctx, store.UpdateGitCredentialParams{
+			ID: cred.ID, OrgID: orgID, Username: "ci", Secret: []byte("rotated"),
+		})
+		require.NoError(t, err, "writing the same values again still finds the row")
+		_, err = st.GitCredentials().Update(ctx, store.UpdateGitCredentialParams{ID: id.Generate(), OrgID: orgID, Secret: []byte("x")})
+		assert.ErrorIs(t, err, store.ErrNotFound)
+
+		require.NoError(t, st.GitCredentials().UpdateSecret(ctx, cred.ID, []byte("reencrypted")))
+		got, err := st.GitCredentials().Get(ctx, store.GetGitCredentialParams{ID: cred.ID, OrgID: orgID})
+		require.NoError(t, err)
+		assert.Equal(t, []byte("reencrypted"), got.Secret)
+		assert.Equal(t, "ci", got.Username)
+
+		n, err := st.GitCredentials().Delete(ctx, store.GetGitCredentialParams{ID: cred.ID, OrgID: orgID})
+		require.NoError(t, err)
+		assert.Equal(t, int64(1), n)
+		_, err = st.GitCredentials().GetByName(ctx, store.GetGitCredentialByNameParams{OrgID: orgID, Name: "github"})
+		assert.ErrorIs(t, err, store.ErrNotFound)
+	})
+}
@@ -0,0 +1,164 @@
+package storetest
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	leapmuxv1 "github.com/leapmux/leapmux/generated/proto/leapmux/v1"
+	"github.com/leapmux/leapmux/internal/hub/store"
+	"github.com/leapmux/leapmux/internal/util/id"
+	"github.com/leapmux/leapmux/internal/util/userid"
+)
+
+func (s *Suite) testGuestInvitations(t *testing.T) {
+	create := func(t *testing.T, st store.Store, userID, wsID string, expiresAt time.Time) string {
+		t.Helper()
+		invitationID := id.Generate()
+		require.NoError(t, st.GuestInvitations().Create(ctx, store.CreateGuestInvitationParams{
+			ID:          invitationID,
+			UserID:      userid.MustNew(userID),
+			WorkspaceID: wsID,
+			Role:        leapmuxv1.GuestRole_GUEST_ROLE_VIEWER,
+			Label:       "pairing",
+			SecretHash:  []byte("gi-secret"),
+			ExpiresAt:   expiresAt,
+		}))
+		return invitationID
+	}
+
+	t.Run("create and get", func(t *testing.T) {
+		st := s.NewStore(t)
+		orgID := SeedOrg(t, st, "gi-org")
+		user := SeedUser(t, st, orgID, "gi-user")
+		wsID := SeedWorkspace(t, st, orgID, user.ID, "WS")
+
+		invitationID := create(t, st, user.ID, wsID, time.Now().Add(time.Hour))
+		got, err := st.GuestInvitations().GetByID(ctx, invitationID)
+		require.NoError(t, err)
+		assert.Equal(t, user.ID, got.UserID)
+		assert.Equal(t, wsID, got.WorkspaceID)
+		assert.Equal(t, leapmuxv1.GuestRole_GUEST_ROLE_VIEWER, got.Role)
+		assert.Equal(t, "pairing", got.Label)
+		assert.Equal(t, []byte("gi-secret"), got.SecretHash)
+		assert.Equal(t, user.AuthGeneration, got.AuthGeneration)
+		assert.Nil(t, got.LastUsedAt)
+		assert.Nil(t, got.RevokedAt)
+
+		require.NoError(t, st.GuestInvitations().Touch(ctx, invitationID))
+		got, err = st.GuestInvitations().GetByID(ctx, invitationID)
+		require.NoError(t, err)
+		assert.NotNil(t, got.LastUsedAt)
+	})
+
+	t.Run("list active skips revoked and expired", func(t *testing.T) {
+		st := s.NewStore(t)
+		orgID := SeedOrg(t, st, "gi-org")
+		user := SeedUser(t, st, orgID, "gi-list-user")
+		ws1 := SeedWorkspace(t, st, orgID, user.ID, "WS 1")
+		ws2 := SeedWorkspace(t, st, orgID, user.ID, "WS 2")
+
+		live := create(t, st, user.ID, ws1, time.Now().Add(time.Hour))
+		revoked := create(t, st, user.ID, ws1, time.Now().Add(time.Hour))
+		create(t, st, user.ID, ws1, time.Now().Add(-time.Minute))
+		create(t, st, user.ID, ws2, time.Now().Add(time.Hour))
+		n, err := st.GuestInvitations().Revoke(ctx, revoked)
+		require.NoError(t, err)
+		require.Equal(t, int64(1), n)
+
+		invitations, err := st.GuestInvitations().ListActiveByWorkspace(ctx, ws1)
+		require.NoError(t, err)
+		require.Len(t, invitations, 1)
+		assert.Equal(t, live, invitations[0].ID)
+	})
+
+	t.Run("list expired live ids", func(t *testing.T) {
+		st := s.NewStore(t)
+		orgID := SeedOrg(t, st, "gi-org")
+		user := SeedUser(t, st, orgID, "gi-expired-user")
+		wsID := SeedWorkspace(t, st, orgID, user.ID, "WS")
+
+		create(t, st, user.ID, wsID, time.Now().Add(time.Hour))
+		expired := create(t, st, user.ID, wsID, time.Now().Add(-time.Minute))
+		alreadyRevoked := create(t, st, user.ID, wsID, time.Now().Add(-time.Minute))
+		_, err := st.GuestInvitations().Revoke(ctx, alreadyRevoked)
+		require.NoError(t, err)
+
+		ids, err := st.GuestInvitations().ListExpiredLiveIDs(ctx, time.Now(), 10)
+		require.NoError(t, err)
+		assert.Equal(t, []string{expired}, ids)
+	})
+
+	t.Run("revoke emits one durable event", func(t *testing.T) {
+		st := s.NewStore(t)
+		orgID := SeedOrg(t, st, "gi-org")
+		user := SeedUser(t, st, orgID, "gi-revoke-user")
+		wsID := SeedWorkspace(t, st, orgID, user.ID, "WS")
+		invitationID := create(t, st, user.ID, wsID, time.Now().Add(time.Hour))
+
+		n, err := st.GuestInvitations().Revoke(ctx, invitationID)
+		require.NoError(t, err)
+		require.Equal(t, int64(1), n)
+		n, err = st.GuestInvitations().Revoke(ctx, invitationID)
+		require.NoError(t, err)
+		require.Equal(t, int64(0), n)
+
+		published, err := st.RevocationEvents().PublishPending(ctx, 10)
+		require.NoError(t, err)
+		require.Equal(t, int64(1), published)
+		events, err := st.RevocationEvents().ListPublishedAfter(ctx, 0, 10)
+		require.NoError(t, err)
+		require.Len(t, events, 1)
+		assert.Equal(t, store.RevocationEventKindGuestInvitation, events[0].Event.Kind)
+		assert.Equal(t, invitationID, events[0].Event.SubjectID)
+		assert.Equal(t, user.ID, events[0].Event.UserID)
+	})
+
+	t.Run("cleanup deletes links revoked before the cutoff", func(t *testing.T) {
+		st := s.NewStore(t)
+		orgID := SeedOrg(t, st, "gi-org")
+		user := SeedUser(t, st, orgID, "gi-cleanup-user")
+		wsID := SeedWorkspace(t, st, orgID, user.ID, "WS")
+		revoked := create(t, st, user.ID, wsID, time.Now().Add(time.Hour))
+		live := create(t, st, user.ID, wsID, time.Now().Add(time.Hour))
+		_, err := st.GuestInvitations().Revoke(ctx, revoked)
+		require.NoError(t, err)
+		row, err := st.GuestInvitations().GetByID(ctx, revoked)
+		require.NoError(t, err)
+
+		deleted, err := st.Cleanup().DeleteRevokedGuestInvitationsBefore(ctx, *row.RevokedAt)
+		require.NoError(t, err)
+		assert.Zero(t, deleted, "cutoff is exclusive")
+		deleted, err = st.Cleanup().DeleteRevokedGuestInvitationsBefore(ctx, row.RevokedAt.Add(time.Millisecond))
+		require.NoError(t, err)
+		assert.Equal(t, int64(1), deleted)
+
+		_, err = st.GuestInvitations().GetByID(ctx, revoked)
+		require.ErrorIs(t, err, store.ErrNotFound)
+		_, err = st.GuestInvitations().GetByID(ctx, live)
+		require.NoError(t, err)
+	})
+
+	t.Run("list by user includes revoked links", func(t *testing.T) {
+		st := s.NewStore(t)
+		orgID := SeedOrg(t, st, "gi-org")
+		user := SeedUser(t, st, orgID, "gi-list-user")
+		other := SeedUser(t, st, orgID, "gi-list-other")
+		wsID := SeedWorkspace(t, st, orgID, user.ID, "WS")
+		revoked := create(t, st, user.ID, wsID, time.Now().Add(time.Hour))
+		live := create(t, st, user.ID, wsID, time.Now().Add(time.Hour))
+		create(t, st, other.ID, wsID, time.Now().Add(time.Hour))
+		_, err := st.GuestInvitations().Revoke(ctx, revoked)
+		require.NoError(t, err)
+
+		got, err := st.GuestInvitations().ListByUser(ctx, userid.MustNew(user.ID))
+		require.NoError(t, err)
+		ids := []string{}
+		for _, g := range got {
+			ids = append(ids, g.ID)
+		}
+		assert.ElementsMatch(t, []string{revoked, live}, ids)
+	})
+}
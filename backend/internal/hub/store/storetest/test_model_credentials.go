@@ -0,0 +1,109 @@
+package storetest
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	leapmuxv1 "github.com/leapmux/leapmux/generated/proto/leapmux/v1"
+	"github.com/leapmux/leapmux/internal/hub/store"
+	"github.com/leapmux/leapmux/internal/util/id"
+)
+
+func (s *Suite) testModelCredentials(t *testing.T) {
+	create := func(t *testing.T, st store.Store, orgID, name string, workspaceIDs ...string) *store.ModelCredential {
+		t.Helper()
+		cred, err := st.ModelCredentials().Create(ctx, store.CreateModelCredentialParams{
+			ID:           id.Generate(),
+			OrgID:        orgID,
+			Name:         name,
+			Kind:         leapmuxv1.ModelCredentialKind_MODEL_CREDENTIAL_KIND_ANTHROPIC_API_KEY,
+			WorkspaceIDs: workspaceIDs,
+			Secret:       []byte("ciphertext-" + name),
+		})
+		require.NoError(t, err)
+		return cred
+	}
+
+	t.Run("create and get round-trip", func(t *testing.T) {
+		st := s.NewStore(t)
+		orgID := SeedOrg(t, st, "model-cred-org")
+
+		cred := create(t, st, orgID, "team-a", "ws-1", "ws-2")
+		got, err := st.ModelCredentials().Get(ctx, store.GetModelCredentialParams{ID: cred.ID, OrgID: orgID})
+		require.NoError(t, err)
+		assert.Equal(t, "team-a", got.Name)
+		assert.Equal(t, leapmuxv1.ModelCredentialKind_MODEL_CREDENTIAL_KIND_ANTHROPIC_API_KEY, got.Kind)
+		assert.Equal(t, []string{"ws-1", "ws-2"}, got.WorkspaceIDs)
+		assert.Equal(t, []byte("ciphertext-team-a"), got.Secret)
+		assert.False(t, got.CreatedAt.IsZero())
+
+		unpinned := create(t, st, orgID, "shared")
+		got, err = st.ModelCredentials().Get(ctx, store.GetModelCredentialParams{ID: unpinned.ID, OrgID: orgID})
+		require.NoError(t, err)
+		assert.Empty(t, got.WorkspaceIDs)
+	})
+
+	t.Run("names are unique per org and lookups are scoped to it", func(t *testing.T) {
+		st := s.NewStore(t)
+		orgID := SeedOrg(t, st, "model-cred-org")
+		otherOrg := SeedOrg(t, st, "model-cred-other-org")
+
+		cred := create(t, st, orgID, "team-a")
+		_, err := st.ModelCredentials().Create(ctx, store.CreateModelCredentialParams{
+			ID: id.Generate(), OrgID: orgID, Name: "team-a",
+			Kind: leapmuxv1.ModelCredentialKind_MODEL_CREDENTIAL_KIND_CLAUDE_OAUTH_TOKEN, Secret: []byte("x"),
+		})
+		assert.ErrorIs(t, err, store.ErrConflict)
+		create(t, st, otherOrg, "team-a")
+		create(t, st, orgID, "team-b")
+
+		list, err := st.ModelCredentials().ListByOrg(ctx, orgID)
+		require.NoError(t, err)
+		require.Len(t, list, 2)
+		assert.Equal(t, "team-a", list[0].Name)
+		assert.Equal(t, "team-b", list[1].Name)
+
+		all, err := st.ModelCredentials().ListAll(ctx)
+		require.NoError(t, err)
+		assert.Len(t, all, 3)
+
+		_, err = st.ModelCredentials().Get(ctx, store.GetModelCredentialParams{ID: cred.ID, OrgID: otherOrg})
+		assert.ErrorIs(t, err, store.ErrNotFound)
+		n, err := st.ModelCredentials().Delete(ctx, store.GetModelCredentialParams{ID: cred.ID, OrgID: otherOrg})
+		require.NoError(t, err)
+		assert.Zero(t, n)
+	})
+
+	t.Run("update and delete", func(t *testing.T) {
+		st := s.NewStore(t)
+		orgID := SeedOrg(t, st, "model-cred-org")
+		cred := create(t, st, orgID, "team-a")
+
+		updated, err := st.ModelCredentials().Update(ctx, store.UpdateModelCredentialParams{
+			ID: cred.ID, OrgID: orgID, WorkspaceIDs: []string{"ws-1"}, Secret: []byte("rotated"),
+		})
+		require.NoError(t, err)
+		assert.Equal(t, []string{"ws-1"}, updated.WorkspaceIDs)
+		assert.Equal(t, []byte("rotated"), updated.Secret)
+		_, err = st.ModelCredentials().Update(ctx, store.UpdateModelCredentialParams{
+			ID: cred.ID, OrgID: orgID, WorkspaceIDs: []string{"ws-1"}, Secret: []byte("rotated"),
+		})
+		require.NoError(t, err, "writing the same values again still finds the row")
+		_, err = st.ModelCredentials().Update(ctx, store.UpdateModelCredentialParams{ID: id.Generate(), OrgID: orgID, Secret: []byte("x")})
+		assert.ErrorIs(t, err, store.ErrNotFound)
+
+		require.NoError(t, st.ModelCredentials().UpdateSecret(ctx, cred.ID, []byte("reencrypted")))
+		got, err := st.ModelCredentials().Get(ctx, store.GetModelCredentialParams{ID: cred.ID, OrgID: orgID})
+		require.NoError(t, err)
+		assert.Equal(t, []byte("reencrypted"), got.Secret)
+		assert.Equal(t, []string{"ws-1"}, got.WorkspaceIDs)
+
+		n, err := st.ModelCredentials().Delete(ctx, store.GetModelCredentialParams{ID: cred.ID, OrgID: orgID})
+		require.NoError(t, err)
+		assert.Equal(t, int64(1), n)
+		_, err = st.ModelCredentials().Get(ctx, store.GetModelCredentialParams{ID: cred.ID, OrgID: orgID})
+		assert.ErrorIs(t, err, store.ErrNotFound)
+	})
+}
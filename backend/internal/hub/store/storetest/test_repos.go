@@ -0,0 +1,120 @@
+package storetest
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/leapmux/leapmux/internal/hub/store"
+	"github.com/leapmux/leapmux/internal/util/id"
+)
+
+func (s *Suite) testRepos(t *testing.T) {
+	create := func(t *testing.T, st store.Store, orgID, name string, labels []string) *store.Repo {
+		t.Helper()
+		repo, err := st.Repos().Create(ctx, store.CreateRepoParams{
+			ID:            id.Generate(),
+			OrgID:         orgID,
+			Name:          name,
+			URL:           "https://example.com/acme/" + name + ".git",
+			DefaultBranch: "main",
+			WorkerLabels:  labels,
+		})
+		require.NoError(t, err)
+		return repo
+	}
+
+	t.Run("create and get round-trip", func(t *testing.T) {
+		st := s.NewStore(t)
+		orgID := SeedOrg(t, st, "repo-org")
+
+		repo := create(t, st, orgID, "api", []string{"gpu", "linux"})
+		got, err := st.Repos().Get(ctx, store.GetRepoParams{ID: repo.ID, OrgID: orgID})
+		require.NoError(t, err)
+		assert.Equal(t, "api", got.Name)
+		assert.Equal(t, "https://example.com/acme/api.git", got.URL)
+		assert.Equal(t, "main", got.DefaultBranch)
+		assert.Equal(t, []string{"gpu", "linux"}, got.WorkerLabels)
+		assert.False(t, got.CreatedAt.IsZero())
+
+		none := create(t, st, orgID, "web", nil)
+		assert.Empty(t, none.WorkerLabels)
+	})
+
+	t.Run("names are unique per org", func(t *testing.T) {
+		st := s.NewStore(t)
+		orgID := SeedOrg(t, st, "repo-org")
+		otherOrg := SeedOrg(t, st, "repo-other-org")
+
+		create(t, st, orgID, "api", nil)
+		_, err := st.Repos().Create(ctx, store.CreateRepoParams{ID: id.Generate(), OrgID: orgID, Name: "api", URL: "https://example.com/x.git"})
+		assert.ErrorIs(t, err, store.ErrConflict)
+		create(t, st, otherOrg, "api", nil)
+	})
+
+	t.Run("list and get are scoped to the org", func(t *testing.T) {
+		st := s.NewStore(t)
+		orgID := SeedOrg(t, st, "repo-org")
+		otherOrg := SeedOrg(t, st, "repo-other-org")
+
+		create(t, st, orgID, "web", nil)
+		api := create(t, st, orgID, "api", nil)
+		create(t, st, otherOrg, "mobile", nil)
+
+		repos, err := st.Repos().ListByOrg(ctx, orgID)
+		require.NoError(t, err)
+		require.Len(t, repos, 2)
+		assert.Equal(t, "api", repos[0].Name)
+		assert.Equal(t, "web", repos[1].Name)
+
+		_, err = st.Repos().Get(ctx, store.GetRepoParams{ID: api.ID, OrgID: otherOrg})
+		assert.ErrorIs(t, err, store.ErrNotFound)
+	})
+
+	t.Run("update replaces fields", func(t *testing.T) {
+		st := s.NewStore(t)
+		orgID := SeedOrg(t, st, "repo-org")
+		repo := create(t, st, orgID, "api", []string{"linux"})
+		create(t, st, orgID, "web", nil)
+
+		updated, err := st.Repos().Update(ctx, store.UpdateRepoParams{
+			ID:            repo.ID,
+			OrgID:         orgID,
+			Name:          "api-v2",
+			URL:           "git@example.com:acme/api.git",
+			CredentialRef: "deploy-key",
+		})
+		require.NoError(t, err)
+		assert.Equal(t, "api-v2", updated.Name)
+		assert.Equal(t, "git@example.com:acme/api.git", updated.URL)
+		assert.Empty(t, updated.DefaultBranch)
+		assert.Equal(t, "deploy-key", updated.CredentialRef)
+		assert.Empty(t, updated.WorkerLabels)
+
+		// Writing the same values again still finds the row.
+		_, err = st.Repos().Update(ctx, store.UpdateRepoParams{ID: repo.ID, OrgID: orgID, Name: "api-v2", URL: updated.URL, CredentialRef: "deploy-key"})
+		require.NoError(t, err)
+
+		_, err = st.Repos().Update(ctx, store.UpdateRepoParams{ID: repo.ID, OrgID: orgID, Name: "web", URL: updated.URL})
+		assert.ErrorIs(t, err, store.ErrConflict)
+		_, err = st.Repos().Update(ctx, store.UpdateRepoParams{ID: id.Generate(), OrgID: orgID, Name: "x", URL: updated.URL})
+		assert.ErrorIs(t, err, store.ErrNotFound)
+	})
+
+	t.Run("delete is scoped to the org", func(t *testing.T) {
+		st := s.NewStore(t)
+		orgID := SeedOrg(t, st, "repo-org")
+		otherOrg := SeedOrg(t, st, "repo-other-org")
+		repo := create(t, st, orgID, "api", nil)
+
+		n, err := st.Repos().Delete(ctx, store.GetRepoParams{ID: repo.ID, OrgID: otherOrg})
+		require.NoError(t, err)
+		assert.Zero(t, n)
+		n, err = st.Repos().Delete(ctx, store.GetRepoParams{ID: repo.ID, OrgID: orgID})
+		require.NoError(t, err)
+		assert.Equal(t, int64(1), n)
+		_, err = st.Repos().Get(ctx, store.GetRepoParams{ID: repo.ID, OrgID: orgID})
+		assert.ErrorIs(t, err, store.ErrNotFound)
+	})
+}
@@ -0,0 +1,96 @@
+package storetest
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/leapmux/leapmux/internal/hub/store"
+	"github.com/leapmux/leapmux/internal/util/id"
+	"github.com/leapmux/leapmux/internal/util/userid"
+)
+
+func (s *Suite) testSnippets(t *testing.T) {
+	create := func(t *testing.T, st store.Store, userID, name string) *store.Snippet {
+		t.Helper()
+		snippet, err := st.Snippets().Create(ctx, store.CreateSnippetParams{
+			ID:          id.Generate(),
+			UserID:      userid.MustNew(userID),
+			Name:        name,
+			Description: "the " + name + " snippet",
+			Body:        "Please " + name + " {{args}}.",
+		})
+		require.NoError(t, err)
+		return snippet
+	}
+
+	t.Run("create and get by name", func(t *testing.T) {
+		st := s.NewStore(t)
+		orgID := SeedOrg(t, st, "snippet-org")
+		user := SeedUser(t, st, orgID, "snippet-user")
+
+		snippet := create(t, st, user.ID, "review")
+		got, err := st.Snippets().GetByName(ctx, store.GetSnippetByNameParams{UserID: userid.MustNew(user.ID), Name: "review"})
+		require.NoError(t, err)
+		assert.Equal(t, snippet.ID, got.ID)
+		assert.Equal(t, user.ID, got.UserID)
+		assert.Equal(t, "the review snippet", got.Description)
+		assert.Equal(t, "Please review {{args}}.", got.Body)
+		assert.False(t, got.CreatedAt.IsZero())
+
+		_, err = st.Snippets().GetByName(ctx, store.GetSnippetByNameParams{UserID: userid.MustNew(user.ID), Name: "tests"})
+		assert.ErrorIs(t, err, store.ErrNotFound)
+	})
+
+	t.Run("names are unique per user", func(t *testing.T) {
+		st := s.NewStore(t)
+		orgID := SeedOrg(t, st, "snippet-org")
+		user := SeedUser(t, st, orgID, "snippet-user")
+		other := SeedUser(t, st, orgID, "snippet-other")
+
+		create(t, st, user.ID, "review")
+		_, err := st.Snippets().Create(ctx, store.CreateSnippetParams{
+			ID: id.Generate(), UserID: userid.MustNew(user.ID), Name: "review", Body: "x",
+		})
+		assert.ErrorIs(t, err, store.ErrConflict)
+		create(t, st, other.ID, "review")
+
+		snippets, err := st.Snippets().ListByUser(ctx, userid.MustNew(user.ID))
+		require.NoError(t, err)
+		assert.Len(t, snippets, 1)
+	})
+
+	t.Run("update and delete are scoped to the user", func(t *testing.T) {
+		st := s.NewStore(t)
+		orgID := SeedOrg(t, st, "snippet-org")
+		user := SeedUser(t, st, orgID, "snippet-user")
+		other := SeedUser(t, st, orgID, "snippet-other")
+		snippet := create(t, st, user.ID, "review")
+		create(t, st, user.ID, "tests")
+
+		updated, err := st.Snippets().Update(ctx, store.UpdateSnippetParams{
+			ID: snippet.ID, UserID: userid.MustNew(user.ID), Name: "critique", Body: "Critique {{1}}.",
+		})
+		require.NoError(t, err)
+		assert.Equal(t, "critique", updated.Name)
+		assert.Equal(t, "Critique {{1}}.", updated.Body)
+		assert.Empty(t, updated.Description)
+
+		_, err = st.Snippets().Update(ctx, store.UpdateSnippetParams{
+			ID: snippet.ID, UserID: userid.MustNew(user.ID), Name: "tests", Body: "x",
+		})
+		assert.ErrorIs(t, err, store.ErrConflict)
+		_, err = st.Snippets().Update(ctx, store.UpdateSnippetParams{
+			ID: snippet.ID, UserID: userid.MustNew(other.ID), Name: "mine", Body: "x",
+		})
+		assert.ErrorIs(t, err, store.ErrNotFound)
+
+		n, err := st.Snippets().Delete(ctx, store.GetSnippetParams{ID: snippet.ID, UserID: userid.MustNew(other.ID)})
+		require.NoError(t, err)
+		assert.Zero(t, n)
+		n, err = st.Snippets().Delete(ctx, store.GetSnippetParams{ID: snippet.ID, UserID: userid.MustNew(user.ID)})
+		require.NoError(t, err)
+		assert.Equal(t, int64(1), n)
+	})
+}
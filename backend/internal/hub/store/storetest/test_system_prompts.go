@@ -0,0 +1,180 @@
+package storetest
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/leapmux/leapmux/internal/hub/store"
+	"github.com/leapmux/leapmux/internal/util/id"
+	"github.com/leapmux/leapmux/internal/util/userid"
+)
+
+func (s *Suite) testSystemPrompts(t *testing.T) {
+	create := func(t *testing.T, st store.Store, orgID, ownerID, name string) *store.SystemPrompt {
+		t.Helper()
+		prompt, err := st.SystemPrompts().Create(ctx, store.CreateSystemPromptParams{
+			ID:          id.Generate(),
+			OrgID:       orgID,
+			OwnerUserID: userid.MustNew(ownerID),
+			Name:        name,
+			Content:     "You are " + name + ".",
+		})
+		require.NoError(t, err)
+		return prompt
+	}
+
+	t.Run("create and get round-trip", func(t *testing.T) {
+		st := s.NewStore(t)
+		orgID := SeedOrg(t, st, "prompt-org")
+		owner := SeedUser(t, st, orgID, "prompt-owner")
+
+		prompt := create(t, st, orgID, owner.ID, "reviewer")
+		got, err := st.SystemPrompts().Get(ctx, store.GetSystemPromptParams{ID: prompt.ID, OrgID: orgID})
+		require.NoError(t, err)
+		assert.Equal(t, "reviewer", got.Name)
+		assert.Equal(t, owner.ID, got.OwnerUserID)
+		assert.False(t, got.Shared)
+		assert.Equal(t, int64(1), got.LatestVersion)
+		assert.Equal(t, int64(1), got.Version)
+		assert.Equal(t, "You are reviewer.", got.Content)
+		assert.False(t, got.CreatedAt.IsZero())
+	})
+
+	t.Run("names are unique per owner", func(t *testing.T) {
+		st := s.NewStore(t)
+		orgID := SeedOrg(t, st, "prompt-org")
+		owner := SeedUser(t, st, orgID, "prompt-owner")
+		other := SeedUser(t, st, orgID, "prompt-other")
+
+		create(t, st, orgID, owner.ID, "reviewer")
+		_, err := st.SystemPrompts().Create(ctx, store.CreateSystemPromptParams{
+			ID: id.Generate(), OrgID: orgID, OwnerUserID: userid.MustNew(owner.ID), Name: "reviewer", Content: "x",
+		})
+		assert.ErrorIs(t, err, store.ErrConflict)
+		create(t, st, orgID, other.ID, "reviewer")
+	})
+
+	t.Run("versions accumulate", func(t *testing.T) {
+		st := s.NewStore(t)
+		orgID := SeedOrg(t, st, "prompt-org")
+		owner := SeedUser(t, st, orgID, "prompt-owner")
+		prompt := create(t, st, orgID, owner.ID, "reviewer")
+
+		updated, err := st.SystemPrompts().AddVersion(ctx, store.AddSystemPromptVersionParams{
+			ID:        prompt.ID,
+			OrgID:     orgID,
+			Content:   "You are a strict reviewer.",
+			CreatedBy: userid.MustNew(owner.ID),
+		})
+		require.NoError(t, err)
+		assert.Equal(t, int64(2), updated.Version)
+		assert.Equal(t, int64(2), updated.LatestVersion)
+		assert.Equal(t, "You are a strict reviewer.", updated.Content)
+
+		first, err := st.SystemPrompts().Get(ctx, store.GetSystemPromptParams{ID: prompt.ID, OrgID: orgID, Version: 1})
+		require.NoError(t, err)
+		assert.Equal(t, "You are reviewer.", first.Content)
+		assert.Equal(t, int64(2), first.LatestVersion)
+
+		_, err = st.SystemPrompts().Get(ctx, store.GetSystemPromptParams{ID: prompt.ID, OrgID: orgID, Version: 3})
+		assert.ErrorIs(t, err, store.ErrNotFound)
+
+		versions, err := st.SystemPrompts().ListVersions(ctx, store.GetSystemPromptParams{ID: prompt.ID, OrgID: orgID})
+		require.NoError(t, err)
+		require.Len(t, versions, 2)
+		assert.Equal(t, int64(2), versions[0].Version)
+		assert.Equal(t, int64(1), versions[1].Version)
+		assert.Equal(t, owner.ID, versions[0].CreatedBy)
+
+		_, err = st.SystemPrompts().AddVersion(ctx, store.AddSystemPromptVersionParams{ID: id.Generate(), OrgID: orgID, Content: "x"})
+		assert.ErrorIs(t, err, store.ErrNotFound)
+	})
+
+	t.Run("list is scoped to the org at the latest version", func(t *testing.T) {
+		st := s.NewStore(t)
+		orgID := SeedOrg(t, st, "prompt-org")
+		otherOrg := SeedOrg(t, st, "prompt-other-org")
+		owner := SeedUser(t, st, orgID, "prompt-owner")
+		outsider := SeedUser(t, st, otherOrg, "prompt-outsider")
+
+		create(t, st, orgID, owner.ID, "writer")
+		reviewer := create(t, st, orgID, owner.ID, "reviewer")
+		create(t, st, otherOrg, outsider.ID, "planner")
+		_, err := st.SystemPrompts().AddVersion(ctx, store.AddSystemPromptVersionParams{
+			ID: reviewer.ID, OrgID: orgID, Content: "v2", CreatedBy: userid.MustNew(owner.ID),
+		})
+		require.NoError(t, err)
+
+		prompts, err := st.SystemPrompts().ListByOrg(ctx, orgID)
+		require.NoError(t, err)
+		require.Len(t, prompts, 2)
+		assert.Equal(t, "reviewer", prompts[0].Name)
+		assert.Equal(t, "v2", prompts[0].Content)
+		assert.Equal(t, "writer", prompts[1].Name)
+
+		_, err = st.SystemPrompts().Get(ctx, store.GetSystemPromptParams{ID: reviewer.ID, OrgID: otherOrg})
+		assert.ErrorIs(t, err, store.ErrNotFound)
+	})
+
+	t.Run("update replaces name and sharing", func(t *testing.T) {
+		st := s.NewStore(t)
+		orgID := SeedOrg(t, st, "prompt-org")
+		owner := SeedUser(t, st, orgID, "prompt-owner")
+		prompt := create(t, st, orgID, owner.ID, "reviewer")
+		create(t, st, orgID, owner.ID, "writer")
+
+		updated, err := st.SystemPrompts().Update(ctx, store.UpdateSystemPromptParams{ID: prompt.ID, OrgID: orgID, Name: "critic", Shared: true})
+		require.NoError(t, err)
+		assert.Equal(t, "critic", updated.Name)
+		assert.True(t, updated.Shared)
+		assert.Equal(t, int64(1), updated.Version, "an update leaves the content alone")
+
+		// Writing the same values again still finds the row.
+		_, err = st.SystemPrompts().Update(ctx, store.UpdateSystemPromptParams{ID: prompt.ID, OrgID: orgID, Name: "critic", Shared: true})
+		require.NoError(t, err)
+
+		_, err = st.SystemPrompts().Update(ctx, store.UpdateSystemPromptParams{ID: prompt.ID, OrgID: orgID, Name: "writer"})
+		assert.ErrorIs(t, err, store.ErrConflict)
+		_, err = st.SystemPrompts().Update(ctx, store.UpdateSystemPromptParams{ID: id.Generate(), OrgID: orgID, Name: "x"})
+		assert.ErrorIs(t, err, store.ErrNotFound)
+	})
+
+	t.Run("delete removes every version", func(t *testing.T) {
+		st := s.NewStore(t)
+		orgID := SeedOrg(t, st, "prompt-org")
+		otherOrg := SeedOrg(t, st, "prompt-other-org")
+		owner := SeedUser(t, st, orgID, "prompt-owner")
+		prompt := create(t, st, orgID, owner.ID, "reviewer")
+
+		n, err := st.SystemPrompts().Delete(ctx, store.GetSystemPromptParams{ID: prompt.ID, OrgID: otherOrg})
+		require.NoError(t, err)
+		assert.Zero(t, n)
+		n, err = st.SystemPrompts().Delete(ctx, store.GetSystemPromptParams{ID: prompt.ID, OrgID: orgID})
+		require.NoError(t, err)
+		assert.Equal(t, int64(1), n)
+		versions, err := st.SystemPrompts().ListVersions(ctx, store.GetSystemPromptParams{ID: prompt.ID, OrgID: orgID})
+		require.NoError(t, err)
+		assert.Empty(t, versions)
+	})
+
+	t.Run("list by owner spans orgs", func(t *testing.T) {
+		st := s.NewStore(t)
+		orgID := SeedOrg(t, st, "prompt-org")
+		otherOrg := SeedOrg(t, st, "prompt-other-org")
+		owner := SeedUser(t, st, orgID, "prompt-owner")
+		other := SeedUser(t, st, orgID, "prompt-other")
+		first := create(t, st, orgID, owner.ID, "reviewer")
+		second := create(t, st, otherOrg, owner.ID, "writer")
+		create(t, st, orgID, other.ID, "planner")
+
+		got, err := st.SystemPrompts().ListByOwner(ctx, userid.MustNew(owner.ID))
+		require.NoError(t, err)
+		ids := []string{}
+		for _, p := range got {
+			ids = append(ids, p.ID)
+		}
+		assert.ElementsMatch(t, []string{first.ID, second.ID}, ids)
+	})
+}
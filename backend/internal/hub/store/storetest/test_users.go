@@ -490,6 +490,49 @@ func (s *Suite) testUsers(t *testing.T) {
 		assert.Equal(t, `{"theme":"dark"}`, prefs)
 	})
 
+	t.Run("swap prefs", func(t *testing.T) {
+		st := s.NewStore(t)
+		orgID := SeedOrg(t, st, "user-org")
+		user := SeedUser(t, st, orgID, "swap-prefs-user")
+
+		read, err := st.Users().GetPrefsVersion(ctx, user.ID)
+		require.NoError(t, err)
+
+		swapped, err := st.Users().SwapPrefs(ctx, store.SwapUserPrefsParams{
+			ID:      user.ID,
+			Prefs:   `{"theme":"dark"}`,
+			Version: read.Version,
+		})
+		require.NoError(t, err)
+		assert.True(t, swapped)
+
+		// A second writer that read the same version lost the race.
+		swapped, err = st.Users().SwapPrefs(ctx, store.SwapUserPrefsParams{
+			ID:      user.ID,
+			Prefs:   `{"theme":"light"}`,
+			Version: read.Version,
+		})
+		require.NoError(t, err)
+		assert.False(t, swapped, "a stale version must not overwrite")
+
+		// An unconditional write moves the version too, so a reader that
+		// saw the blob before it cannot write over it.
+		current, err := st.Users().GetPrefsVersion(ctx, user.ID)
+		require.NoError(t, err)
+		assert.Equal(t, `{"theme":"dark"}`, current.Prefs)
+		require.NoError(t, st.Users().UpdatePrefs(ctx, store.UpdateUserPrefsParams{
+			ID:    user.ID,
+			Prefs: `{"theme":"dark"}`,
+		}))
+		swapped, err = st.Users().SwapPrefs(ctx, store.SwapUserPrefsParams{
+			ID:      user.ID,
+			Prefs:   `{"theme":"light"}`,
+			Version: current.Version,
+		})
+		require.NoError(t, err)
+		assert.False(t, swapped, "UpdatePrefs must bump the version")
+	})
+
 	t.Run("pending email lifecycle", func(t *testing.T) {
 		st := s.NewStore(t)
 		orgID := SeedOrg(t, st, "user-org")
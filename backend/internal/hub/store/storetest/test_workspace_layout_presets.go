@@ -0,0 +1,178 @@
+package storetest
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	leapmuxv1 "github.com/leapmux/leapmux/generated/proto/leapmux/v1"
+	"github.com/leapmux/leapmux/internal/hub/store"
+	"github.com/leapmux/leapmux/internal/util/id"
+	"github.com/leapmux/leapmux/internal/util/userid"
+)
+
+func (s *Suite) testWorkspaceLayoutPresets(t *testing.T) {
+	upsert := func(t *testing.T, st store.Store, userID, wsID, name string, root []byte) *store.WorkspaceLayoutPreset {
+		t.Helper()
+		preset, err := st.WorkspaceLayoutPresets().Upsert(ctx, store.UpsertWorkspaceLayoutPresetParams{
+			ID:          id.Generate(),
+			UserID:      userid.MustNew(userID),
+			WorkspaceID: wsID,
+			Name:        name,
+			Root:        root,
+		})
+		require.NoError(t, err)
+		return preset
+	}
+
+	t.Run("upsert by name keeps the id", func(t *testing.T) {
+		st := s.NewStore(t)
+		orgID := SeedOrg(t, st, "wlp-org")
+		user := SeedUser(t, st, orgID, "wlp-user")
+		wsID := SeedWorkspace(t, st, orgID, user.ID, "WS")
+
+		first := upsert(t, st, user.ID, wsID, "review", []byte{1})
+		second := upsert(t, st, user.ID, wsID, "review", []byte{2})
+		assert.Equal(t, first.ID, second.ID)
+		assert.Equal(t, []byte{2}, second.Root)
+
+		got, err := st.WorkspaceLayoutPresets().GetByID(ctx, store.GetWorkspaceLayoutPresetParams{
+			ID:     first.ID,
+			UserID: userid.MustNew(user.ID),
+		})
+		require.NoError(t, err)
+		assert.Equal(t, "review", got.Name)
+		assert.Equal(t, wsID, got.WorkspaceID)
+		assert.Equal(t, []byte{2}, got.Root)
+	})
+
+	t.Run("list is scoped to user and workspace", func(t *testing.T) {
+		st := s.NewStore(t)
+		orgID := SeedOrg(t, st, "wlp-org")
+		user := SeedUser(t, st, orgID, "wlp-list-user")
+		other := SeedUser(t, st, SeedOrg(t, st, "wlp-other-org"), "wlp-other-user")
+		ws1 := SeedWorkspace(t, st, orgID, user.ID, "WS 1")
+		ws2 := SeedWorkspace(t, st, orgID, user.ID, "WS 2")
+
+		upsert(t, st, user.ID, ws1, "review", []byte{1})
+		upsert(t, st, user.ID, ws1, "debugging", []byte{1})
+		upsert(t, st, user.ID, ws2, "mobile", []byte{1})
+
+		scope := store.ListWorkspaceLayoutPresetsParams{UserID: userid.MustNew(user.ID), WorkspaceID: ws1}
+		presets, err := st.WorkspaceLayoutPresets().ListByWorkspace(ctx, scope)
+		require.NoError(t, err)
+		require.Len(t, presets, 2)
+		assert.Equal(t, "debugging", presets[0].Name)
+		assert.Equal(t, "review", presets[1].Name)
+
+		presets, err = st.WorkspaceLayoutPresets().ListByWorkspace(ctx, store.ListWorkspaceLayoutPresetsParams{
+			UserID:      userid.MustNew(other.ID),
+			WorkspaceID: ws1,
+		})
+		require.NoError(t, err)
+		assert.Empty(t, presets)
+	})
+
+	t.Run("get by another user is not found", func(t *testing.T) {
+		st := s.NewStore(t)
+		orgID := SeedOrg(t, st, "wlp-org")
+		user := SeedUser(t, st, orgID, "wlp-owner")
+		wsID := SeedWorkspace(t, st, orgID, user.ID, "WS")
+		preset := upsert(t, st, user.ID, wsID, "review", []byte{1})
+
+		_, err := st.WorkspaceLayoutPresets().GetByID(ctx, store.GetWorkspaceLayoutPresetParams{
+			ID:     preset.ID,
+			UserID: userid.MustNew("someone-else"),
+		})
+		assert.ErrorIs(t, err, store.ErrNotFound)
+	})
+
+	t.Run("set, replace, and clear the active preset", func(t *testing.T) {
+		st := s.NewStore(t)
+		orgID := SeedOrg(t, st, "wlp-org")
+		user := SeedUser(t, st, orgID, "wlp-active-user")
+		wsID := SeedWorkspace(t, st, orgID, user.ID, "WS")
+		review := upsert(t, st, user.ID, wsID, "review", []byte{1})
+		mobile := upsert(t, st, user.ID, wsID, "mobile", []byte{1})
+		uid := userid.MustNew(user.ID)
+		layouts := st.WorkspaceLayoutPresets()
+
+		require.NoError(t, layouts.SetActive(ctx, store.SetWorkspaceLayoutSelectionParams{
+			UserID: uid, WorkspaceID: wsID, DeviceClass: leapmuxv1.DeviceClass_DEVICE_CLASS_UNSPECIFIED, LayoutID: review.ID,
+		}))
+		require.NoError(t, layouts.SetActive(ctx, store.SetWorkspaceLayoutSelectionParams{
+			UserID: uid, WorkspaceID: wsID, DeviceClass: leapmuxv1.DeviceClass_DEVICE_CLASS_MOBILE, LayoutID: review.ID,
+		}))
+		require.NoError(t, layouts.SetActive(ctx, store.SetWorkspaceLayoutSelectionParams{
+			UserID: uid, WorkspaceID: wsID, DeviceClass: leapmuxv1.DeviceClass_DEVICE_CLASS_MOBILE, LayoutID: mobile.ID,
+		}))
+
+		scope := store.ListWorkspaceLayoutPresetsParams{UserID: uid, WorkspaceID: wsID}
+		active, err := layouts.ListActive(ctx, scope)
+		require.NoError(t, err)
+		require.Len(t, active, 2)
+		assert.Equal(t, leapmuxv1.DeviceClass_DEVICE_CLASS_UNSPECIFIED, active[0].DeviceClass)
+		assert.Equal(t, review.ID, active[0].LayoutID)
+		assert.Equal(t, leapmuxv1.DeviceClass_DEVICE_CLASS_MOBILE, active[1].DeviceClass)
+		assert.Equal(t, mobile.ID, active[1].LayoutID)
+
+		require.NoError(t, layouts.ClearActive(ctx, store.ClearWorkspaceLayoutSelectionParams{
+			UserID: uid, WorkspaceID: wsID, DeviceClass: leapmuxv1.DeviceClass_DEVICE_CLASS_MOBILE,
+		}))
+		active, err = layouts.ListActive(ctx, scope)
+		require.NoError(t, err)
+		require.Len(t, active, 1)
+		assert.Equal(t, leapmuxv1.DeviceClass_DEVICE_CLASS_UNSPECIFIED, active[0].DeviceClass)
+	})
+
+	t.Run("delete drops the preset's selections", func(t *testing.T) {
+		st := s.NewStore(t)
+		orgID := SeedOrg(t, st, "wlp-org")
+		user := SeedUser(t, st, orgID, "wlp-delete-user")
+		wsID := SeedWorkspace(t, st, orgID, user.ID, "WS")
+		preset := upsert(t, st, user.ID, wsID, "review", []byte{1})
+		uid := userid.MustNew(user.ID)
+		layouts := st.WorkspaceLayoutPresets()
+		require.NoError(t, layouts.SetActive(ctx, store.SetWorkspaceLayoutSelectionParams{
+			UserID: uid, WorkspaceID: wsID, DeviceClass: leapmuxv1.DeviceClass_DEVICE_CLASS_DESKTOP, LayoutID: preset.ID,
+		}))
+
+		n, err := layouts.Delete(ctx, store.DeleteWorkspaceLayoutPresetParams{ID: preset.ID, UserID: userid.MustNew("someone-else")})
+		require.NoError(t, err)
+		assert.Equal(t, int64(0), n)
+
+		n, err = layouts.Delete(ctx, store.DeleteWorkspaceLayoutPresetParams{ID: preset.ID, UserID: uid})
+		require.NoError(t, err)
+		assert.Equal(t, int64(1), n)
+
+		active, err := layouts.ListActive(ctx, store.ListWorkspaceLayoutPresetsParams{UserID: uid, WorkspaceID: wsID})
+		require.NoError(t, err)
+		assert.Empty(t, active)
+	})
+
+	t.Run("list and delete by user", func(t *testing.T) {
+		st := s.NewStore(t)
+		orgID := SeedOrg(t, st, "wlp-org")
+		user := SeedUser(t, st, orgID, "wlp-by-user")
+		other := SeedUser(t, st, orgID, "wlp-by-other")
+		ws1 := SeedWorkspace(t, st, orgID, user.ID, "WS 1")
+		ws2 := SeedWorkspace(t, st, orgID, user.ID, "WS 2")
+		upsert(t, st, user.ID, ws1, "review", []byte{1})
+		upsert(t, st, user.ID, ws2, "review", []byte{2})
+		kept := upsert(t, st, other.ID, ws1, "review", []byte{3})
+		layouts := st.WorkspaceLayoutPresets()
+		uid := userid.MustNew(user.ID)
+
+		got, err := layouts.ListByUser(ctx, uid)
+		require.NoError(t, err)
+		assert.Len(t, got, 2)
+
+		require.NoError(t, layouts.DeleteByUser(ctx, uid))
+		got, err = layouts.ListByUser(ctx, uid)
+		require.NoError(t, err)
+		assert.Empty(t, got)
+		_, err = layouts.GetByID(ctx, store.GetWorkspaceLayoutPresetParams{ID: kept.ID, UserID: userid.MustNew(other.ID)})
+		require.NoError(t, err)
+	})
+}
@@ -233,6 +233,10 @@ type Workspace struct {
 	DeletedAt   *time.Time
 }
 
+// PageCursor returns the keyset position for ListAccessible, which orders by
+// (created_at DESC, id DESC).
+func (w Workspace) PageCursor() (time.Time, string) { return w.CreatedAt, w.ID }
+
 // WorkspaceTabRow is a row from workspace_tab_owned or
 // workspace_tab_rendered. The two views have the same shape; the
 // distinction is *which* table they came from. Worker reconciliation
@@ -315,6 +319,138 @@ type WorkspaceSectionItem struct {
 	Position    string
 }
 
+// WorkspaceLayoutPreset is a user's named layout preset for a workspace. Root is
+// a proto-marshalled leapmuxv1.LayoutPresetNode.
+type WorkspaceLayoutPreset struct {
+	ID          string
+	UserID      string
+	WorkspaceID string
+	Name        string
+	Root        []byte
+	CreatedAt   time.Time
+	UpdatedAt   time.Time
+}
+
+// WorkspaceLayoutSelection records the preset a user's device class shows for
+// a workspace.
+type WorkspaceLayoutSelection struct {
+	UserID      string
+	WorkspaceID string
+	DeviceClass leapmuxv1.DeviceClass
+	LayoutID    string
+}
+
+// Repo is a git repository registered with an org. WorkerLabels are the
+// labels of the workers its checkouts should preferably land on.
+type Repo struct {
+	ID            string
+	OrgID         string
+	Name          string
+	URL           string
+	DefaultBranch string
+	CredentialRef string
+	WorkerLabels  []string
+	CreatedAt     time.Time
+	UpdatedAt     time.Time
+}
+
+// GitCredential is an org's git credential. Secret is encrypted with the
+// keystore under keystore.GitCredentialAAD(ID).
+type GitCredential struct {
+	ID        string
+	OrgID     string
+	Name      string
+	Kind      leapmuxv1.GitCredentialKind
+	Username  string
+	Secret    []byte
+	CreatedAt time.Time
+	UpdatedAt time.Time
+}
+
+// SystemPrompt is a library system prompt, with the content of one of its
+// versions: the latest unless it was read at another.
+type SystemPrompt struct {
+	ID            string
+	OrgID         string
+	OwnerUserID   string
+	Name          string
+	Shared        bool
+	LatestVersion int64
+	Version       int64
+	Content       string
+	CreatedAt     time.Time
+	UpdatedAt     time.Time
+}
+
+// SystemPromptVersion is one immutable revision of a SystemPrompt.
+type SystemPromptVersion struct {
+	PromptID  string
+	Version   int64
+	Content   string
+	CreatedBy string
+	CreatedAt time.Time
+}
+
+// Snippet is a user's prompt snippet, expanded from "/Name" in messages
+// the user sends to agents.
+type Snippet struct {
+	ID          string
+	UserID      string
+	Name        string
+	Description string
+	Body        string
+	CreatedAt   time.Time
+	UpdatedAt   time.Time
+}
+
+// ModelCredential is an org's model provider credential. Secret is
+// encrypted with the keystore under keystore.ModelCredentialAAD(ID).
+// WorkspaceIDs pins it to those workspaces; empty serves the rest.
+type ModelCredential struct {
+	ID           string
+	OrgID        string
+	Name         string
+	Kind         leapmuxv1.ModelCredentialKind
+	WorkspaceIDs []string
+	Secret       []byte
+	CreatedAt    time.Time
+	UpdatedAt    time.Time
+}
+
+// Announcement is a notice the Hub admin published to every user. A nil
+// EndsAt runs it until it is deleted. AcknowledgedCount is set by
+// AnnouncementStore.ListAll only.
+type Announcement struct {
+	ID                string
+	Title             string
+	Body              string
+	Severity          leapmuxv1.AnnouncementSeverity
+	StartsAt          time.Time
+	EndsAt            *time.Time
+	CreatedBy         string
+	CreatedAt         time.Time
+	AcknowledgedCount int64
+}
+
+// EmergencyStop is a stop a Hub admin put on an org, or on one of its
+// workers when WorkerID is set.
+type EmergencyStop struct {
+	OrgID     string
+	WorkerID  string
+	Reason    string
+	StoppedBy string
+	StoppedAt time.Time
+}
+
+// AccessPolicy is an org's access policy. Rules is the JSON array of its
+// rules, which the service layer owns the shape of.
+type AccessPolicy struct {
+	OrgID     string
+	Rules     string
+	UpdatedBy string
+	UpdatedAt time.Time
+}
+
 // OAuthProviderSummary holds all OAuth provider fields except the encrypted secret.
 type OAuthProviderSummary struct {
 	ID           string
@@ -474,6 +610,18 @@ type UpdateUserPrefsParams struct {
 	Prefs string
 }
 
+// UserPrefs is a user's prefs JSON and the number of writes it has seen.
+type UserPrefs struct {
+	Prefs   string
+	Version int64
+}
+
+type SwapUserPrefsParams struct {
+	ID      string
+	Prefs   string
+	Version int64
+}
+
 type SetPendingEmailParams struct {
 	ID                    string
 	PendingEmail          string
@@ -829,6 +977,204 @@ type IsWorkspaceInArchivedSectionParams struct {
 	WorkspaceID string
 }
 
+type UpsertWorkspaceLayoutPresetParams struct {
+	ID          string
+	UserID      userid.UserID
+	WorkspaceID string
+	Name        string
+	Root        []byte
+}
+
+type GetWorkspaceLayoutPresetParams struct {
+	ID     string
+	UserID userid.UserID
+}
+
+type ListWorkspaceLayoutPresetsParams struct {
+	UserID      userid.UserID
+	WorkspaceID string
+}
+
+type DeleteWorkspaceLayoutPresetParams struct {
+	ID     string
+	UserID userid.UserID
+}
+
+type SetWorkspaceLayoutSelectionParams struct {
+	UserID      userid.UserID
+	WorkspaceID string
+	DeviceClass leapmuxv1.DeviceClass
+	LayoutID    string
+}
+
+type ClearWorkspaceLayoutSelectionParams struct {
+	UserID      userid.UserID
+	WorkspaceID string
+	DeviceClass leapmuxv1.DeviceClass
+}
+
+type CreateRepoParams struct {
+	ID            string
+	OrgID         string
+	Name          string
+	URL           string
+	DefaultBranch string
+	CredentialRef string
+	WorkerLabels  []string
+}
+
+type GetRepoParams struct {
+	ID    string
+	OrgID string
+}
+
+type UpdateRepoParams struct {
+	ID            string
+	OrgID         string
+	Name          string
+	URL           string
+	DefaultBranch string
+	CredentialRef string
+	WorkerLabels  []string
+}
+
+type CreateGitCredentialParams struct {
+	ID       string
+	OrgID    string
+	Name     string
+	Kind     leapmuxv1.GitCredentialKind
+	Username string
+	Secret   []byte
+}
+
+type GetGitCredentialParams struct {
+	ID    string
+	OrgID string
+}
+
+type GetGitCredentialByNameParams struct {
+	OrgID string
+	Name  string
+}
+
+type UpdateGitCredentialParams struct {
+	ID       string
+	OrgID    string
+	Username string
+	Secret   []byte
+}
+
+type CreateSystemPromptParams struct {
+	ID          string
+	OrgID       string
+	OwnerUserID userid.UserID
+	Name        string
+	Shared      bool
+	Content     string
+}
+
+// GetSystemPromptParams reads a prompt at Version, or its latest when
+// Version is zero.
+type GetSystemPromptParams struct {
+	ID      string
+	OrgID   string
+	Version int64
+}
+
+type UpdateSystemPromptParams struct {
+	ID     string
+	OrgID  string
+	Name   string
+	Shared bool
+}
+
+type AddSystemPromptVersionParams struct {
+	ID        string
+	OrgID     string
+	Content   string
+	CreatedBy userid.UserID
+}
+
+type CreateSnippetParams struct {
+	ID          string
+	UserID      userid.UserID
+	Name        string
+	Description string
+	Body        string
+}
+
+type GetSnippetParams struct {
+	ID     string
+	UserID userid.UserID
+}
+
+type GetSnippetByNameParams struct {
+	UserID userid.UserID
+	Name   string
+}
+
+type UpdateSnippetParams struct {
+	ID          string
+	UserID      userid.UserID
+	Name        string
+	Description string
+	Body        string
+}
+
+type CreateAnnouncementParams struct {
+	ID        string
+	Title     string
+	Body      string
+	Severity  leapmuxv1.AnnouncementSeverity
+	StartsAt  time.Time
+	EndsAt    *time.Time
+	CreatedBy string
+}
+
+type AcknowledgeAnnouncementParams struct {
+	AnnouncementID string
+	UserID         userid.UserID
+}
+
+type PutEmergencyStopParams struct {
+	OrgID     string
+	WorkerID  string
+	Reason    string
+	StoppedBy string
+}
+
+type DeleteEmergencyStopParams struct {
+	OrgID    string
+	WorkerID string
+}
+
+type PutAccessPolicyParams struct {
+	OrgID     string
+	Rules     string
+	UpdatedBy string
+}
+
+type CreateModelCredentialParams struct {
+	ID           string
+	OrgID        string
+	Name         string
+	Kind         leapmuxv1.ModelCredentialKind
+	WorkspaceIDs []string
+	Secret       []byte
+}
+
+type GetModelCredentialParams struct {
+	ID    string
+	OrgID string
+}
+
+type UpdateModelCredentialParams struct {
+	ID           string
+	OrgID        string
+	WorkspaceIDs []string
+	Secret       []byte
+}
+
 type CreateOAuthProviderParams struct {
 	ID           string
 	ProviderType string
@@ -952,6 +1298,23 @@ type DelegationToken struct {
 	RevokedAt        *time.Time
 }
 
+// GuestInvitation is a time-boxed guest link to one workspace. UserID is the
+// workspace owner who created it: the guest acts as that user, pinned to
+// WorkspaceID and limited by Role.
+type GuestInvitation struct {
+	ID             string
+	UserID         string
+	WorkspaceID    string
+	Role           leapmuxv1.GuestRole
+	Label          string
+	SecretHash     []byte
+	AuthGeneration int64
+	CreatedAt      time.Time
+	LastUsedAt     *time.Time
+	ExpiresAt      time.Time
+	RevokedAt      *time.Time
+}
+
 // DelegationTokenWithOwner augments DelegationToken with the owner's username
 // for the admin listing. A soft-deleted owner surfaces as OwnerUsername "" +
 // OwnerDeleted true; presentation layers decide how to render a deleted owner.
@@ -1055,6 +1418,16 @@ type CreateDelegationTokenParams struct {
 	RefreshExpiresAt *time.Time
 }
 
+type CreateGuestInvitationParams struct {
+	ID          string
+	UserID      userid.UserID
+	WorkspaceID string
+	Role        leapmuxv1.GuestRole
+	Label       string
+	SecretHash  []byte
+	ExpiresAt   time.Time
+}
+
 type CreateDeviceAuthorizationParams struct {
 	DeviceCode      string
 	UserCode        string
@@ -5,6 +5,7 @@ import (
 	"errors"
 	"fmt"
 	"log/slog"
+	"slices"
 	"sync"
 	"sync/atomic"
 	"time"
@@ -40,6 +41,80 @@ type Conn struct {
 
 	mu     sync.Mutex
 	closed atomic.Bool
+	// stats is what the worker reported about its hub connection with the
+	// first heartbeat, for ListWorkers.
+	stats atomic.Pointer[leapmuxv1.WorkerConnectionStats]
+	// labels are the worker's configured labels, from the same heartbeat.
+	labels atomic.Pointer[[]string]
+	// diskUsage is the worker's latest disk measurement.
+	diskUsage atomic.Pointer[leapmuxv1.WorkerDiskUsage]
+	// activity is the worker's latest activity summary per workspace, for
+	// ListWorkspaces.
+	activityMu sync.Mutex
+	activity   map[string]*leapmuxv1.WorkspaceActivity
+}
+
+// SetConnectionStats records the connection report the worker sent.
+func (c *Conn) SetConnectionStats(s *leapmuxv1.WorkerConnectionStats) {
+	c.stats.Store(s)
+}
+
+// ConnectionStats returns the worker's connection report, or nil if it
+// sent none (a worker that predates the report).
+func (c *Conn) ConnectionStats() *leapmuxv1.WorkerConnectionStats {
+	return c.stats.Load()
+}
+
+// SetLabels records the labels the worker reported.
+func (c *Conn) SetLabels(labels []string) {
+	c.labels.Store(&labels)
+}
+
+// SetDiskUsage records the worker's latest disk measurement and reports
+// whether its warnings differ from the previous measurement's.
+func (c *Conn) SetDiskUsage(u *leapmuxv1.WorkerDiskUsage) bool {
+	prev := c.diskUsage.Swap(u)
+	return !slices.Equal(prev.GetWarnings(), u.GetWarnings())
+}
+
+// DiskUsage returns the worker's latest disk measurement, or nil if it
+// sent none.
+func (c *Conn) DiskUsage() *leapmuxv1.WorkerDiskUsage {
+	return c.diskUsage.Load()
+}
+
+// ApplyWorkspaceActivity folds an activity report into what the worker
+// reported before; a full report replaces all of it. A workspace reported
+// with nothing to show is forgotten.
+func (c *Conn) ApplyWorkspaceActivity(r *leapmuxv1.WorkspaceActivityReport) {
+	c.activityMu.Lock()
+	defer c.activityMu.Unlock()
+	if r.GetFull() || c.activity == nil {
+		c.activity = make(map[string]*leapmuxv1.WorkspaceActivity, len(r.GetWorkspaces()))
+	}
+	for _, a := range r.GetWorkspaces() {
+		if a.GetLastMessageAt() == "" && a.GetMessageCount() == 0 && a.GetAwaitingApprovalCount() == 0 {
+			delete(c.activity, a.GetWorkspaceId())
+			continue
+		}
+		c.activity[a.GetWorkspaceId()] = a
+	}
+}
+
+// WorkspaceActivity returns the worker's latest activity summary for
+// workspaceID, or nil if it reported none.
+func (c *Conn) WorkspaceActivity(workspaceID string) *leapmuxv1.WorkspaceActivity {
+	c.activityMu.Lock()
+	defer c.activityMu.Unlock()
+	return c.activity[workspaceID]
+}
+
+// Labels returns the worker's labels, or nil if it reported none.
+func (c *Conn) Labels() []string {
+	if l := c.labels.Load(); l != nil {
+		return *l
+	}
+	return nil
 }
 
 // ErrConnectionClosed is returned when a sender races worker disconnect.
@@ -91,6 +166,9 @@ type Manager struct {
 	mu            sync.RWMutex
 	conns         map[string]*Conn // workerID -> Conn
 	deregistering map[string]bool  // workerID -> true if deregistering
+	// upgradeRequired holds workers whose last Connect was refused for an
+	// old protocol version (see internal/protocol).
+	upgradeRequired map[string]bool
 
 	regMu      sync.Mutex
 	regWaiters map[string]chan struct{} // regToken -> notify channel
@@ -198,10 +276,11 @@ func New(a ReachAuthorizer) *Manager {
 		panic("workermgr: New requires a ReachAuthorizer (use DenyAllReach() for an ungated-by-design registry)")
 	}
 	return &Manager{
-		conns:         make(map[string]*Conn),
-		deregistering: make(map[string]bool),
-		regWaiters:    make(map[string]chan struct{}),
-		reachAuth:     a,
+		conns:           make(map[string]*Conn),
+		deregistering:   make(map[string]bool),
+		upgradeRequired: make(map[string]bool),
+		regWaiters:      make(map[string]chan struct{}),
+		reachAuth:       a,
 	}
 }
 
@@ -278,6 +357,55 @@ func (m *Manager) OnlineForTrustedPath(workerID string) bool {
 	return ok
 }
 
+// ConnectionStatsForTrustedPath returns what a connected worker reported about
+// its hub connection, or nil when it is offline or sent no report. Like
+// OnlineForTrustedPath it discloses state, not a sendable connection.
+func (m *Manager) ConnectionStatsForTrustedPath(workerID string) *leapmuxv1.WorkerConnectionStats {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	if c := m.conns[workerID]; c != nil {
+		return c.ConnectionStats()
+	}
+	return nil
+}
+
+// LabelsForTrustedPath returns the labels a connected worker reported, or
+// nil when it is offline or reported none. Like OnlineForTrustedPath it
+// discloses state, not a sendable connection.
+func (m *Manager) LabelsForTrustedPath(workerID string) []string {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	if c := m.conns[workerID]; c != nil {
+		return c.Labels()
+	}
+	return nil
+}
+
+// DiskUsageForTrustedPath returns the latest disk measurement a connected
+// worker sent, or nil when it is offline or sent none. Like
+// OnlineForTrustedPath it discloses state, not a sendable connection.
+func (m *Manager) DiskUsageForTrustedPath(workerID string) *leapmuxv1.WorkerDiskUsage {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	if c := m.conns[workerID]; c != nil {
+		return c.DiskUsage()
+	}
+	return nil
+}
+
+// WorkspaceActivityForTrustedPath returns the latest activity summary a
+// connected worker sent for workspaceID, or nil when it is offline or sent
+// none. Like OnlineForTrustedPath it discloses state, not a sendable
+// connection.
+func (m *Manager) WorkspaceActivityForTrustedPath(workerID, workspaceID string) *leapmuxv1.WorkspaceActivity {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	if c := m.conns[workerID]; c != nil {
+		return c.WorkspaceActivity(workspaceID)
+	}
+	return nil
+}
+
 // MarkDeregistering marks a worker as being deregistered, which makes it
 // unreachable through ConnForUser until the flag is cleared. The trusted path
 // stays open so the deregister notification itself can be delivered.
@@ -301,6 +429,32 @@ func (m *Manager) ClearDeregistering(workerID string) {
 	delete(m.deregistering, workerID)
 }
 
+// SetUpgradeRequired records whether the worker's last Connect was refused
+// for speaking a protocol version older than the hub accepts, and reports
+// whether that changed the recorded state.
+func (m *Manager) SetUpgradeRequired(workerID string, required bool) bool {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if m.upgradeRequired[workerID] == required {
+		return false
+	}
+	if required {
+		m.upgradeRequired[workerID] = true
+	} else {
+		delete(m.upgradeRequired, workerID)
+	}
+	return true
+}
+
+// UpgradeRequiredForTrustedPath reports whether the worker's last Connect was
+// refused for an old protocol version. Like OnlineForTrustedPath, it is for
+// callers whose worker id did not come from a user request.
+func (m *Manager) UpgradeRequiredForTrustedPath(workerID string) bool {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	return m.upgradeRequired[workerID]
+}
+
 // WaitForRegistrationChange blocks until the registration identified by
 // regToken is notified, the context is cancelled, or the timeout expires.
 // Returns nil on notification, ctx.Err() on cancel, or a timeout error.
@@ -372,6 +526,28 @@ func (m *Manager) NotifyShutdown(ctx context.Context, retryDelaySeconds int32) {
 	slog.Info("sent shutdown notifications to workers", "count", sent, "total", len(connections))
 }
 
+// Broadcast sends msg to every connected worker and returns how many it
+// reached. Failures are logged and skipped: the caller's state is stored,
+// and a worker that misses the push gets it on reconnect.
+func (m *Manager) Broadcast(msg *leapmuxv1.ConnectResponse) int {
+	m.mu.RLock()
+	connections := make(map[string]*Conn, len(m.conns))
+	for workerID, conn := range m.conns {
+		connections[workerID] = conn
+	}
+	m.mu.RUnlock()
+
+	sent := 0
+	for workerID, conn := range connections {
+		if err := conn.Send(msg); err != nil {
+			slog.Warn("failed to broadcast to worker", "worker_id", workerID, "error", err)
+			continue
+		}
+		sent++
+	}
+	return sent
+}
+
 // NotifyRegistrationChange wakes up any waiter blocked on the given regToken.
 func (m *Manager) NotifyRegistrationChange(regToken string) {
 	m.regMu.Lock()
@@ -95,6 +95,20 @@ func TestMarkDeregistering(t *testing.T) {
 	assert.False(t, m.IsDeregistering("b2"))
 }
 
+func TestSetUpgradeRequired(t *testing.T) {
+	m := New(DenyAllReach())
+
+	assert.False(t, m.UpgradeRequiredForTrustedPath("w1"))
+	assert.True(t, m.SetUpgradeRequired("w1", true))
+	assert.False(t, m.SetUpgradeRequired("w1", true), "no change to report")
+	assert.True(t, m.UpgradeRequiredForTrustedPath("w1"))
+	assert.False(t, m.UpgradeRequiredForTrustedPath("w2"))
+
+	assert.True(t, m.SetUpgradeRequired("w1", false))
+	assert.False(t, m.UpgradeRequiredForTrustedPath("w1"))
+	assert.False(t, m.SetUpgradeRequired("w2", false))
+}
+
 func TestRegister_ReturnsReplacedFlag(t *testing.T) {
 	m := New(DenyAllReach())
 
@@ -442,3 +456,26 @@ func TestManager_ConnForUser_AuthorizedReturnsConn(t *testing.T) {
 	assert.Equal(t, []string{"w1", "not-connected"}, allow.asked,
 		"both lookups are authorized before the map is read")
 }
+
+// Broadcast reaches every connected worker and skips one whose send fails.
+func TestManager_Broadcast(t *testing.T) {
+	m := New(DenyAllReach())
+	var got []string
+	for _, workerID := range []string{"w1", "w2"} {
+		_, err := m.Register(&Conn{WorkerID: workerID, SendFn: func(*leapmuxv1.ConnectResponse) error {
+			got = append(got, workerID)
+			return nil
+		}})
+		require.NoError(t, err)
+	}
+	_, err := m.Register(&Conn{WorkerID: "broken", SendFn: func(*leapmuxv1.ConnectResponse) error {
+		return errors.New("stream closed")
+	}})
+	require.NoError(t, err)
+
+	sent := m.Broadcast(&leapmuxv1.ConnectResponse{
+		Payload: &leapmuxv1.ConnectResponse_Announcements{Announcements: &leapmuxv1.AnnouncementList{}},
+	})
+	assert.Equal(t, 2, sent)
+	assert.ElementsMatch(t, []string{"w1", "w2"}, got)
+}
@@ -17,15 +17,21 @@ type PendingRequests struct {
 	mu             sync.Mutex
 	pending        map[string]chan *leapmuxv1.ConnectRequest // requestID -> response channel
 	defaultTimeout func() time.Duration
+	// idle is closed while nothing is pending, and replaced by an open
+	// channel when the first request is added.
+	idle chan struct{}
 }
 
 // NewPendingRequests creates a new PendingRequests tracker.
 // The defaultTimeout function is called when a context has no deadline
 // to determine the send timeout.
 func NewPendingRequests(defaultTimeout func() time.Duration) *PendingRequests {
+	idle := make(chan struct{})
+	close(idle)
 	return &PendingRequests{
 		pending:        make(map[string]chan *leapmuxv1.ConnectRequest),
 		defaultTimeout: defaultTimeout,
+		idle:           idle,
 	}
 }
 
@@ -55,12 +61,18 @@ func (p *PendingRequests) SendAndWait(
 	ch := make(chan *leapmuxv1.ConnectRequest, 1)
 
 	p.mu.Lock()
+	if len(p.pending) == 0 {
+		p.idle = make(chan struct{})
+	}
 	p.pending[requestID] = ch
 	p.mu.Unlock()
 
 	defer func() {
 		p.mu.Lock()
 		delete(p.pending, requestID)
+		if len(p.pending) == 0 {
+			close(p.idle)
+		}
 		p.mu.Unlock()
 	}()
 
@@ -94,3 +106,18 @@ func (p *PendingRequests) Complete(requestID string, msg *leapmuxv1.ConnectReque
 		return false
 	}
 }
+
+// WaitIdle blocks until no request is waiting for a worker's response, or
+// until ctx is done. Shutdown uses it to let in-flight requests finish
+// before the worker connections close.
+func (p *PendingRequests) WaitIdle(ctx context.Context) error {
+	p.mu.Lock()
+	idle := p.idle
+	p.mu.Unlock()
+	select {
+	case <-idle:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
@@ -157,3 +157,28 @@ func TestPendingRequests_OutOfOrder(t *testing.T) {
 		t.Fatal("timeout waiting for ch-2 result")
 	}
 }
+
+func TestPendingRequests_WaitIdle(t *testing.T) {
+	p := NewPendingRequests(func() time.Duration { return 30 * time.Second })
+	require.NoError(t, p.WaitIdle(context.Background()), "nothing pending")
+
+	sent := make(chan *leapmuxv1.ConnectResponse, 1)
+	conn := &Conn{WorkerID: "b1", SendFn: func(msg *leapmuxv1.ConnectResponse) error {
+		sent <- msg
+		return nil
+	}}
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		_, _ = p.SendAndWait(context.Background(), conn, &leapmuxv1.ConnectResponse{})
+	}()
+	msg := <-sent
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	assert.ErrorIs(t, p.WaitIdle(ctx), context.DeadlineExceeded, "a request is in flight")
+
+	require.True(t, p.Complete(msg.GetRequestId(), &leapmuxv1.ConnectRequest{}))
+	<-done
+	require.NoError(t, p.WaitIdle(context.Background()))
+}
@@ -0,0 +1,166 @@
+// Package workerchaos is a test harness for the hub-to-worker protocol. It
+// builds a workermgr.Conn whose far end is a scripted fake worker and
+// injects the faults a real connection produces -- latency, frames lost in
+// transit, acknowledgements delivered twice, and the connection dropping --
+// so the hub's send-and-wait and queue-and-retry paths can be exercised
+// deterministically instead of only in production.
+//
+// Acknowledgements travel the way a real worker's do: the fake worker's
+// reply is handed to PendingRequests.Complete, which is what
+// WorkerConnectorService does with every worker frame that carries a
+// request id.
+package workerchaos
+
+import (
+	"math/rand/v2"
+	"sync"
+	"time"
+
+	leapmuxv1 "github.com/leapmux/leapmux/generated/proto/leapmux/v1"
+	"github.com/leapmux/leapmux/internal/hub/workermgr"
+)
+
+// Faults configures what goes wrong on a Conn. The zero value is a
+// healthy connection.
+type Faults struct {
+	// Latency delays every frame in each direction: the hub's send and
+	// the worker's acknowledgement.
+	Latency time.Duration
+	// DropRate is the probability, in [0, 1], that a hub frame is lost in
+	// transit. The send still reports success -- the hub cannot tell a lost
+	// frame from a slow worker -- and the worker never sees it.
+	DropRate float64
+	// DuplicateAckRate is the probability, in [0, 1], that the worker's
+	// acknowledgement is delivered twice.
+	DuplicateAckRate float64
+	// DisconnectAfter, when positive, drops the connection once that many
+	// frames have been sent: the next send fails with
+	// workermgr.ErrConnectionClosed, as it does when a worker goes away
+	// mid-conversation.
+	DisconnectAfter int
+	// Seed makes the random faults reproducible.
+	Seed uint64
+}
+
+// Responder is the fake worker. It receives each frame the hub managed to
+// deliver and returns the worker's reply, or nil for none. The harness
+// stamps the reply with the frame's request id.
+type Responder func(*leapmuxv1.ConnectResponse) *leapmuxv1.ConnectRequest
+
+// AckAll is a Responder that acknowledges every frame with an empty
+// reply, which is all SendAndWait needs to return.
+func AckAll(*leapmuxv1.ConnectResponse) *leapmuxv1.ConnectRequest {
+	return &leapmuxv1.ConnectRequest{}
+}
+
+// Harness owns one faulty connection and records what crossed it.
+type Harness struct {
+	conn    *workermgr.Conn
+	pending *workermgr.PendingRequests
+	respond Responder
+	faults  Faults
+
+	mu            sync.Mutex
+	rng           *rand.Rand
+	sent          int
+	delivered     []*leapmuxv1.ConnectResponse
+	dropped       int
+	duplicateAcks int
+	acks          sync.WaitGroup
+}
+
+// New builds a Harness for workerID. Replies are completed against
+// pending, which must be the same tracker the code under test sends with.
+func New(workerID string, pending *workermgr.PendingRequests, respond Responder, faults Faults) *Harness {
+	if pending == nil || respond == nil {
+		panic("workerchaos: New requires pending requests and a responder")
+	}
+	h := &Harness{
+		pending: pending,
+		respond: respond,
+		faults:  faults,
+		rng:     rand.New(rand.NewPCG(faults.Seed, faults.Seed)),
+	}
+	h.conn = &workermgr.Conn{WorkerID: workerID, SendFn: h.send}
+	return h
+}
+
+// Conn returns the connection to hand to the code under test.
+func (h *Harness) Conn() *workermgr.Conn { return h.conn }
+
+// send is the Conn's SendFn. It runs under the Conn's send lock, so it
+// must not call Conn.Close, which waits for that lock; Fence does not.
+func (h *Harness) send(msg *leapmuxv1.ConnectResponse) error {
+	h.mu.Lock()
+	if h.faults.DisconnectAfter > 0 && h.sent >= h.faults.DisconnectAfter {
+		h.mu.Unlock()
+		h.conn.Fence()
+		return workermgr.ErrConnectionClosed
+	}
+	h.sent++
+	drop := h.roll(h.faults.DropRate)
+	duplicate := h.roll(h.faults.DuplicateAckRate)
+	if drop {
+		h.dropped++
+	} else {
+		h.delivered = append(h.delivered, msg)
+	}
+	h.mu.Unlock()
+
+	time.Sleep(h.faults.Latency)
+	if drop {
+		return nil
+	}
+	reply := h.respond(msg)
+	if reply == nil {
+		return nil
+	}
+	reply.RequestId = msg.GetRequestId()
+	// The acknowledgement arrives on the worker's own stream, after the
+	// hub's send has returned -- never inside it.
+	h.acks.Go(func() {
+		time.Sleep(h.faults.Latency)
+		h.pending.Complete(reply.GetRequestId(), reply)
+		if duplicate {
+			h.mu.Lock()
+			h.duplicateAcks++
+			h.mu.Unlock()
+			h.pending.Complete(reply.GetRequestId(), reply)
+		}
+	})
+	return nil
+}
+
+// roll reports whether an event with probability p happens. Callers hold
+// h.mu, which guards the generator.
+func (h *Harness) roll(p float64) bool {
+	return p > 0 && h.rng.Float64() < p
+}
+
+// Disconnect drops the connection now, as a worker going offline does.
+// Later sends fail with workermgr.ErrConnectionClosed.
+func (h *Harness) Disconnect() { h.conn.Close() }
+
+// Wait blocks until every scheduled acknowledgement has been delivered.
+func (h *Harness) Wait() { h.acks.Wait() }
+
+// Delivered returns the frames the fake worker received, in order.
+func (h *Harness) Delivered() []*leapmuxv1.ConnectResponse {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	return append([]*leapmuxv1.ConnectResponse(nil), h.delivered...)
+}
+
+// Dropped reports how many frames were lost in transit.
+func (h *Harness) Dropped() int {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	return h.dropped
+}
+
+// DuplicateAcks reports how many acknowledgements were delivered twice.
+func (h *Harness) DuplicateAcks() int {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	return h.duplicateAcks
+}
@@ -0,0 +1,103 @@
+package workerchaos_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	leapmuxv1 "github.com/leapmux/leapmux/generated/proto/leapmux/v1"
+	"github.com/leapmux/leapmux/internal/hub/workermgr"
+	"github.com/leapmux/leapmux/internal/hub/workermgr/workerchaos"
+)
+
+func newPending() *workermgr.PendingRequests {
+	return workermgr.NewPendingRequests(func() time.Duration { return time.Second })
+}
+
+func deregister() *leapmuxv1.ConnectResponse {
+	return &leapmuxv1.ConnectResponse{
+		Payload: &leapmuxv1.ConnectResponse_Deregister{Deregister: &leapmuxv1.DeregisterNotification{}},
+	}
+}
+
+func TestHarness_LatencyDelaysButDelivers(t *testing.T) {
+	pending := newPending()
+	h := workerchaos.New("w1", pending, workerchaos.AckAll, workerchaos.Faults{Latency: 20 * time.Millisecond})
+
+	start := time.Now()
+	_, err := pending.SendAndWait(context.Background(), h.Conn(), deregister())
+	require.NoError(t, err)
+	assert.GreaterOrEqual(t, time.Since(start), 40*time.Millisecond, "one delay out, one back")
+	assert.Len(t, h.Delivered(), 1)
+}
+
+func TestHarness_DroppedFrameTimesOutTheWaiter(t *testing.T) {
+	pending := newPending()
+	h := workerchaos.New("w1", pending, workerchaos.AckAll, workerchaos.Faults{DropRate: 1})
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	_, err := pending.SendAndWait(ctx, h.Conn(), deregister())
+	require.ErrorIs(t, err, context.DeadlineExceeded, "a lost frame looks like a silent worker")
+	assert.Equal(t, 1, h.Dropped())
+	assert.Empty(t, h.Delivered())
+}
+
+// A duplicate acknowledgement must land on nothing: request ids are never
+// reused, so the second copy cannot complete a later request.
+func TestHarness_DuplicateAckCompletesOnlyItsOwnRequest(t *testing.T) {
+	pending := newPending()
+	var replies int
+	h := workerchaos.New("w1", pending, func(*leapmuxv1.ConnectResponse) *leapmuxv1.ConnectRequest {
+		replies++
+		return &leapmuxv1.ConnectRequest{Payload: &leapmuxv1.ConnectRequest_DeregisterAck{DeregisterAck: &leapmuxv1.DeregisterAck{}}}
+	}, workerchaos.Faults{DuplicateAckRate: 1})
+
+	first := deregister()
+	resp, err := pending.SendAndWait(context.Background(), h.Conn(), first)
+	require.NoError(t, err)
+	assert.Equal(t, first.GetRequestId(), resp.GetRequestId())
+	h.Wait()
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	second := deregister()
+	resp, err = pending.SendAndWait(ctx, h.Conn(), second)
+	require.NoError(t, err)
+	assert.Equal(t, second.GetRequestId(), resp.GetRequestId())
+	h.Wait()
+	assert.Equal(t, 2, h.DuplicateAcks())
+	assert.Equal(t, 2, replies)
+}
+
+func TestHarness_DisconnectAfterFailsLaterSends(t *testing.T) {
+	pending := newPending()
+	h := workerchaos.New("w1", pending, workerchaos.AckAll, workerchaos.Faults{DisconnectAfter: 1})
+
+	_, err := pending.SendAndWait(context.Background(), h.Conn(), deregister())
+	require.NoError(t, err)
+	_, err = pending.SendAndWait(context.Background(), h.Conn(), deregister())
+	require.ErrorIs(t, err, workermgr.ErrConnectionClosed)
+	_, err = pending.SendAndWait(context.Background(), h.Conn(), deregister())
+	require.ErrorIs(t, err, workermgr.ErrConnectionClosed, "the connection stays down")
+	assert.Len(t, h.Delivered(), 1)
+}
+
+func TestHarness_SeedReproducesTheFaults(t *testing.T) {
+	run := func() []bool {
+		pending := newPending()
+		h := workerchaos.New("w1", pending, workerchaos.AckAll, workerchaos.Faults{DropRate: 0.5, Seed: 7})
+		var lost []bool
+		for range 16 {
+			before := h.Dropped()
+			require.NoError(t, h.Conn().Send(deregister()))
+			lost = append(lost, h.Dropped() > before)
+		}
+		h.Wait()
+		return lost
+	}
+	assert.Equal(t, run(), run())
+}
@@ -0,0 +1,243 @@
+package logging
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"maps"
+	"runtime"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// modulePrefix is stripped from a package path to name its component.
+const modulePrefix = "github.com/leapmux/leapmux/"
+
+// captureAttr tags every log line of an agent under debug capture.
+const captureAttr = "debug_capture"
+
+// floor is the level the underlying handler filters at: the lowest of the
+// global level, every component override, and debug while any agent is
+// under capture. Records between floor and their effective level are
+// dropped by componentHandler.
+var floor = new(slog.LevelVar)
+
+var (
+	levelsMu   sync.RWMutex
+	components = map[string]slog.Level{}
+	captures   = map[string]time.Time{} // agentID -> capture end
+	// filtering is set while any override or capture exists, so the
+	// common case skips the per-record component lookup.
+	filtering atomic.Bool
+)
+
+// componentCache maps a caller PC to its component name.
+var componentCache sync.Map
+
+// SetComponentLevel overrides the log level of one component and of every
+// component below it, e.g. "hub" covers "hub.service" unless that has its
+// own override. A component is its package path below the module root
+// without the "internal/" segment, dot-separated: internal/hub/service is
+// "hub.service" and internal/worker/agent is "worker.agent".
+func SetComponentLevel(component string, l slog.Level) error {
+	if err := validateComponent(component); err != nil {
+		return err
+	}
+	levelsMu.Lock()
+	defer levelsMu.Unlock()
+	components[component] = l
+	recomputeLocked()
+	return nil
+}
+
+// ClearComponentLevel drops a component's override, returning it to the
+// level of its nearest overridden parent or the global level.
+func ClearComponentLevel(component string) {
+	levelsMu.Lock()
+	defer levelsMu.Unlock()
+	delete(components, component)
+	recomputeLocked()
+}
+
+// ComponentLevels returns the current component overrides.
+func ComponentLevels() map[string]slog.Level {
+	levelsMu.RLock()
+	defer levelsMu.RUnlock()
+	return maps.Clone(components)
+}
+
+// CaptureAgent logs everything about agentID at debug level for d, tagging
+// each of its lines with debug_capture=<agentID>. A line belongs to the
+// agent when it carries an agent_id attribute equal to agentID. A d of
+// zero or less ends the capture.
+func CaptureAgent(agentID string, d time.Duration) {
+	levelsMu.Lock()
+	defer levelsMu.Unlock()
+	if d <= 0 {
+		delete(captures, agentID)
+		recomputeLocked()
+		return
+	}
+	until := time.Now().Add(d)
+	captures[agentID] = until
+	recomputeLocked()
+	time.AfterFunc(d, func() {
+		levelsMu.Lock()
+		defer levelsMu.Unlock()
+		// A later CaptureAgent call may have extended this capture.
+		if captures[agentID].Equal(until) {
+			delete(captures, agentID)
+			recomputeLocked()
+		}
+	})
+}
+
+// AgentCaptures returns the agents under debug capture and when each
+// capture ends.
+func AgentCaptures() map[string]time.Time {
+	levelsMu.RLock()
+	defer levelsMu.RUnlock()
+	return maps.Clone(captures)
+}
+
+// recomputeLocked refreshes floor and filtering after any change to the
+// global level, the overrides, or the captures. levelsMu must be held.
+func recomputeLocked() {
+	lowest := Level.Level()
+	for _, l := range components {
+		lowest = min(lowest, l)
+	}
+	if len(captures) > 0 {
+		lowest = min(lowest, slog.LevelDebug)
+	}
+	floor.Set(lowest)
+	filtering.Store(len(components) > 0 || len(captures) > 0)
+}
+
+func validateComponent(component string) error {
+	if component == "" || strings.HasPrefix(component, ".") || strings.HasSuffix(component, ".") {
+		return fmt.Errorf("invalid log component %q", component)
+	}
+	for _, r := range component {
+		if !(r >= 'a' && r <= 'z' || r >= '0' && r <= '9' || r == '.' || r == '_') {
+			return fmt.Errorf("invalid log component %q", component)
+		}
+	}
+	return nil
+}
+
+// componentLevel returns the effective level for a component: its own
+// override, else its nearest overridden parent's, else the global level.
+func componentLevel(component string) slog.Level {
+	levelsMu.RLock()
+	defer levelsMu.RUnlock()
+	for c := component; c != ""; {
+		if l, ok := components[c]; ok {
+			return l
+		}
+		i := strings.LastIndexByte(c, '.')
+		if i < 0 {
+			break
+		}
+		c = c[:i]
+	}
+	return Level.Level()
+}
+
+func capturing(agentID string) bool {
+	levelsMu.RLock()
+	defer levelsMu.RUnlock()
+	until, ok := captures[agentID]
+	return ok && time.Now().Before(until)
+}
+
+// componentOf names the component of the function at pc.
+func componentOf(pc uintptr) string {
+	if pc == 0 {
+		return ""
+	}
+	if c, ok := componentCache.Load(pc); ok {
+		return c.(string)
+	}
+	frame, _ := runtime.CallersFrames([]uintptr{pc}).Next()
+	c := componentFromFunc(frame.Function)
+	componentCache.Store(pc, c)
+	return c
+}
+
+// componentFromFunc names the component of a fully qualified function
+// name such as "github.com/leapmux/leapmux/internal/hub/service.(*UserService).GetTimeouts".
+func componentFromFunc(fn string) string {
+	pkg := fn
+	if i := strings.LastIndexByte(pkg, '/'); i >= 0 {
+		if j := strings.IndexByte(pkg[i:], '.'); j >= 0 {
+			pkg = pkg[:i+j]
+		}
+	} else if j := strings.IndexByte(pkg, '.'); j >= 0 {
+		pkg = pkg[:j]
+	}
+	rel, ok := strings.CutPrefix(pkg, modulePrefix)
+	if !ok {
+		return ""
+	}
+	rel = strings.TrimPrefix(rel, "internal/")
+	return strings.ReplaceAll(rel, "/", ".")
+}
+
+// componentHandler applies the component overrides and agent captures on
+// top of an underlying handler that filters at floor.
+type componentHandler struct {
+	inner   slog.Handler
+	agentID string // set when a With attribute names the agent
+}
+
+func newComponentHandler(inner slog.Handler) slog.Handler {
+	return &componentHandler{inner: inner}
+}
+
+func (h *componentHandler) Enabled(ctx context.Context, l slog.Level) bool {
+	return h.inner.Enabled(ctx, l)
+}
+
+func (h *componentHandler) Handle(ctx context.Context, r slog.Record) error {
+	if !filtering.Load() {
+		return h.inner.Handle(ctx, r)
+	}
+	if id := h.recordAgentID(r); id != "" && capturing(id) {
+		r = r.Clone()
+		r.AddAttrs(slog.String(captureAttr, id))
+		return h.inner.Handle(ctx, r)
+	}
+	if r.Level < componentLevel(componentOf(r.PC)) {
+		return nil
+	}
+	return h.inner.Handle(ctx, r)
+}
+
+func (h *componentHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	agentID := h.agentID
+	for _, a := range attrs {
+		if a.Key == "agent_id" {
+			agentID = a.Value.String()
+		}
+	}
+	return &componentHandler{inner: h.inner.WithAttrs(attrs), agentID: agentID}
+}
+
+func (h *componentHandler) WithGroup(name string) slog.Handler {
+	return &componentHandler{inner: h.inner.WithGroup(name), agentID: h.agentID}
+}
+
+func (h *componentHandler) recordAgentID(r slog.Record) string {
+	agentID := h.agentID
+	r.Attrs(func(a slog.Attr) bool {
+		if a.Key == "agent_id" {
+			agentID = a.Value.String()
+			return false
+		}
+		return true
+	})
+	return agentID
+}
@@ -0,0 +1,115 @@
+package logging
+
+import (
+	"bytes"
+	"log/slog"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	leapmuxv1 "github.com/leapmux/leapmux/generated/proto/leapmux/v1"
+)
+
+// resetLevels restores the process-wide level state when the test ends.
+func resetLevels(t *testing.T) {
+	t.Cleanup(func() {
+		levelsMu.Lock()
+		defer levelsMu.Unlock()
+		Level.Set(slog.LevelInfo)
+		clear(components)
+		clear(captures)
+		recomputeLocked()
+	})
+}
+
+func newTestLogger() (*slog.Logger, *bytes.Buffer) {
+	var buf bytes.Buffer
+	return slog.New(newComponentHandler(slog.NewTextHandler(&buf, &slog.HandlerOptions{Level: floor}))), &buf
+}
+
+func TestComponentFromFunc(t *testing.T) {
+	tests := map[string]string{
+		"github.com/leapmux/leapmux/internal/hub/service.(*UserService).GetTimeouts": "hub.service",
+		"github.com/leapmux/leapmux/internal/worker/agent.Start.func1":               "worker.agent",
+		"github.com/leapmux/leapmux/hub.(*Server).Serve":                             "hub",
+		"log/slog.Info": "",
+		"main.main":     "",
+	}
+	for fn, want := range tests {
+		assert.Equal(t, want, componentFromFunc(fn), fn)
+	}
+}
+
+func TestComponentLevels(t *testing.T) {
+	resetLevels(t)
+	logger, buf := newTestLogger()
+
+	logger.Debug("hidden")
+	assert.Empty(t, buf.String(), "debug is below the global level")
+
+	// This test's own package is the "logging" component.
+	require.NoError(t, SetComponentLevel("logging", slog.LevelDebug))
+	logger.Debug("shown")
+	assert.Contains(t, buf.String(), "shown")
+
+	buf.Reset()
+	require.NoError(t, SetComponentLevel("hub", slog.LevelDebug))
+	ClearComponentLevel("logging")
+	logger.Debug("hidden again")
+	assert.Empty(t, buf.String(), "another component's override does not apply")
+
+	assert.Equal(t, map[string]slog.Level{"hub": slog.LevelDebug}, ComponentLevels())
+	assert.Error(t, SetComponentLevel("hub/service", slog.LevelDebug))
+}
+
+func TestCaptureAgent(t *testing.T) {
+	resetLevels(t)
+	logger, buf := newTestLogger()
+
+	CaptureAgent("agent-1", time.Minute)
+	logger.Debug("captured", "agent_id", "agent-1")
+	logger.With("agent_id", "agent-1").Info("captured via With")
+	logger.Debug("other agent", "agent_id", "agent-2")
+
+	out := buf.String()
+	assert.Contains(t, out, "msg=captured agent_id=agent-1 debug_capture=agent-1")
+	assert.Contains(t, out, `msg="captured via With" agent_id=agent-1 debug_capture=agent-1`)
+	assert.NotContains(t, out, "other agent")
+
+	CaptureAgent("agent-1", 0)
+	assert.Empty(t, AgentCaptures())
+	assert.Equal(t, slog.LevelInfo, floor.Level(), "the floor returns with the capture gone")
+}
+
+func TestApplyLogLevels(t *testing.T) {
+	resetLevels(t)
+
+	require.NoError(t, ApplyLogLevels(&leapmuxv1.SetLogLevelsRequest{
+		DefaultLevel:   "warn",
+		Components:     map[string]string{"hub.service": "debug"},
+		CaptureAgentId: "agent-1",
+		CaptureSeconds: 60,
+	}))
+	got := LogLevelsProto()
+	assert.Equal(t, "warn", got.GetDefaultLevel())
+	assert.Equal(t, map[string]string{"hub.service": "debug"}, got.GetComponents())
+	require.Len(t, got.GetAgentCaptures(), 1)
+	assert.Equal(t, "agent-1", got.GetAgentCaptures()[0].GetAgentId())
+
+	require.NoError(t, ApplyLogLevels(&leapmuxv1.SetLogLevelsRequest{Components: map[string]string{"hub.service": ""}}))
+	assert.Empty(t, LogLevelsProto().GetComponents(), "an empty level clears the override")
+
+	for name, req := range map[string]*leapmuxv1.SetLogLevelsRequest{
+		"bad default":       {DefaultLevel: "loud"},
+		"bad level":         {Components: map[string]string{"hub": "loud"}},
+		"bad component":     {Components: map[string]string{"Hub": "debug"}},
+		"capture no agent":  {CaptureSeconds: 60},
+		"capture too long":  {CaptureAgentId: "agent-1", CaptureSeconds: 2 * 3600},
+		"partly bad change": {DefaultLevel: "debug", Components: map[string]string{"": "debug"}},
+	} {
+		assert.Error(t, ApplyLogLevels(req), name)
+	}
+	assert.Equal(t, slog.LevelWarn, GetLevel(), "a rejected request applies nothing")
+}
@@ -12,8 +12,8 @@ import (
 	"github.com/mattn/go-isatty"
 )
 
-// Level is the global atomic log level. It can be changed at runtime
-// (e.g. via the admin API) without restarting the process.
+// Level is the global atomic log level. Change it through SetLevel, at
+// startup or at runtime via SetLogLevels, so the handler floor follows.
 var Level = new(slog.LevelVar) // default: INFO
 
 // Setup initializes the global slog logger. When stderr is a TTY it
@@ -23,20 +23,24 @@ func Setup() {
 	var handler slog.Handler
 	if isatty.IsTerminal(os.Stderr.Fd()) || isatty.IsCygwinTerminal(os.Stderr.Fd()) {
 		handler = tint.NewHandler(os.Stderr, &tint.Options{
-			Level:      Level,
+			Level:      floor,
 			TimeFormat: time.TimeOnly,
 		})
 	} else {
 		handler = slog.NewJSONHandler(os.Stderr, &slog.HandlerOptions{
-			Level: Level,
+			Level: floor,
 		})
 	}
-	slog.SetDefault(slog.New(handler))
+	slog.SetDefault(slog.New(newComponentHandler(handler)))
 }
 
-// SetLevel changes the global log level.
+// SetLevel changes the global log level. Component overrides (see
+// SetComponentLevel) keep their own levels.
 func SetLevel(l slog.Level) {
+	levelsMu.Lock()
+	defer levelsMu.Unlock()
 	Level.Set(l)
+	recomputeLocked()
 }
 
 // GetLevel returns the current global log level.
@@ -0,0 +1,91 @@
+package logging
+
+import (
+	"fmt"
+	"log/slog"
+	"maps"
+	"slices"
+	"strings"
+	"time"
+
+	leapmuxv1 "github.com/leapmux/leapmux/generated/proto/leapmux/v1"
+	"github.com/leapmux/leapmux/internal/util/timefmt"
+)
+
+// MaxAgentCapture bounds a single agent debug capture: debug output for a
+// busy agent is voluminous, so a capture must not outlive a debugging
+// session by much.
+const MaxAgentCapture = time.Hour
+
+// ApplyLogLevels validates and applies a SetLogLevels request to this
+// process. Nothing is applied when any part of the request is invalid.
+// The error is suitable for returning to the caller as an invalid
+// argument.
+func ApplyLogLevels(req *leapmuxv1.SetLogLevelsRequest) error {
+	var global *slog.Level
+	if s := req.GetDefaultLevel(); s != "" {
+		l, err := ParseLevel(s)
+		if err != nil {
+			return fmt.Errorf("invalid default_level %q", s)
+		}
+		global = &l
+	}
+	set := map[string]slog.Level{}
+	var clear []string
+	for component, s := range req.GetComponents() {
+		if err := validateComponent(component); err != nil {
+			return err
+		}
+		if s == "" {
+			clear = append(clear, component)
+			continue
+		}
+		l, err := ParseLevel(s)
+		if err != nil {
+			return fmt.Errorf("invalid level %q for component %q", s, component)
+		}
+		set[component] = l
+	}
+	capture := time.Duration(req.GetCaptureSeconds()) * time.Second
+	if req.GetCaptureAgentId() == "" && capture > 0 {
+		return fmt.Errorf("capture_seconds needs a capture_agent_id")
+	}
+	if capture > MaxAgentCapture {
+		return fmt.Errorf("capture_seconds must be at most %d", int(MaxAgentCapture.Seconds()))
+	}
+
+	if global != nil {
+		SetLevel(*global)
+	}
+	for component, l := range set {
+		_ = SetComponentLevel(component, l)
+	}
+	for _, component := range clear {
+		ClearComponentLevel(component)
+	}
+	if id := req.GetCaptureAgentId(); id != "" {
+		CaptureAgent(id, capture)
+	}
+	slog.Info("log levels changed",
+		"default_level", GetLevel().String(), "components", ComponentLevels(), "capture_agent_id", req.GetCaptureAgentId())
+	return nil
+}
+
+// LogLevelsProto reports this process's current log configuration.
+func LogLevelsProto() *leapmuxv1.LogLevels {
+	out := &leapmuxv1.LogLevels{
+		DefaultLevel: strings.ToLower(GetLevel().String()),
+		Components:   map[string]string{},
+	}
+	for component, l := range ComponentLevels() {
+		out.Components[component] = strings.ToLower(l.String())
+	}
+	captures := AgentCaptures()
+	for _, agentID := range slices.Sorted(maps.Keys(captures)) {
+		out.AgentCaptures = append(out.AgentCaptures, &leapmuxv1.AgentLogCapture{
+			AgentId:   agentID,
+			ExpiresAt: timefmt.Format(captures[agentID]),
+		})
+	}
+	return out
+}
@@ -52,6 +52,15 @@ var (
 	})
 )
 
+// Notification threading metrics. Counted by the Worker, so they show on
+// the Hub's endpoint only for Workers running inside the Hub process.
+var (
+	NotificationThreadEventsTotal = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "leapmux_notification_thread_events_total",
+		Help: "Notification threading outcomes: merge, grace_revive, merge_failure, standalone_fallback.",
+	}, []string{"outcome"})
+)
+
 // WebSocket metrics.
 var (
 	WSConnectionsActive = promauto.NewGauge(prometheus.GaugeOpts{
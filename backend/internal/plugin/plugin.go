@@ -0,0 +1,249 @@
+// Package plugin runs an operator's exec plugins: programs in a plugin
+// directory that the Hub or Worker keeps running beside itself and tells
+// about what happens, so a team can add its own automations (ticket
+// syncing, compliance scanning) without forking LeapMux.
+//
+// Each executable file directly in the directory is one plugin. It is
+// started with the Hub or Worker and receives one JSON Event per line on
+// stdin until stdin closes, which is its signal to exit. Anything it
+// prints is logged. A plugin that exits is started again after a backoff.
+//
+// Delivery is best effort: events a stalled or crashing plugin cannot
+// take are dropped once its backlog fills, rather than holding up agents
+// or RPCs.
+package plugin
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/fs"
+	"log/slog"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"runtime"
+	"sort"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	"github.com/leapmux/leapmux/internal/util/timefmt"
+)
+
+// Hook names what an Event reports.
+type Hook string
+
+const (
+	// HookMessageReceived is each line of output a Worker's agent prints.
+	HookMessageReceived Hook = "message_received"
+	// HookControlRequest is each permission or question request an agent
+	// raises on a Worker.
+	HookControlRequest Hook = "control_request"
+	// HookTurnCompleted is each agent turn that ends on a Worker.
+	HookTurnCompleted Hook = "turn_completed"
+	// HookWorkspaceCreated is each workspace created on the Hub.
+	HookWorkspaceCreated Hook = "workspace_created"
+)
+
+// Event is the JSON line a plugin reads for each hook.
+type Event struct {
+	Hook Hook `json:"hook"`
+	// Host is "hub" or "worker", whichever sent the event.
+	Host string `json:"host"`
+	Time string `json:"time"`
+	Data any    `json:"data"`
+}
+
+const (
+	// backlogSize is how many events wait for a plugin that is busy or
+	// restarting before newer ones are dropped.
+	backlogSize = 256
+	// minRestartDelay and maxRestartDelay bound the backoff between
+	// restarts of a plugin that keeps exiting.
+	minRestartDelay = time.Second
+	maxRestartDelay = time.Minute
+	// stopGrace is how long a plugin has to exit once its stdin closes.
+	stopGrace = 5 * time.Second
+)
+
+// Host runs the plugins of one directory. A nil *Host has no plugins;
+// every method is safe to call on it.
+type Host struct {
+	side    string
+	plugins []*plugin
+}
+
+type plugin struct {
+	name   string
+	path   string
+	events chan []byte
+	// dropping is set while events are being dropped, so a stalled plugin
+	// is logged once per stall instead of once per event.
+	dropping atomic.Bool
+}
+
+// New loads the executables in dir as plugins for side ("hub" or
+// "worker"). It returns nil when dir is empty. Nothing runs until Start.
+func New(dir, side string) (*Host, error) {
+	if dir == "" {
+		return nil, nil
+	}
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, fmt.Errorf("read plugin dir: %w", err)
+	}
+	h := &Host{side: side}
+	for _, e := range entries {
+		info, err := e.Info()
+		if err != nil || !isExecutable(info) {
+			continue
+		}
+		h.plugins = append(h.plugins, &plugin{
+			name:   e.Name(),
+			path:   filepath.Join(dir, e.Name()),
+			events: make(chan []byte, backlogSize),
+		})
+	}
+	sort.Slice(h.plugins, func(i, j int) bool { return h.plugins[i].name < h.plugins[j].name })
+	return h, nil
+}
+
+// isExecutable reports whether info is a file the OS would run: one with
+// an execute bit, or on Windows an executable extension.
+func isExecutable(info fs.FileInfo) bool {
+	if !info.Mode().IsRegular() {
+		return false
+	}
+	if runtime.GOOS == "windows" {
+		switch strings.ToLower(filepath.Ext(info.Name())) {
+		case ".exe", ".com", ".bat", ".cmd":
+			return true
+		}
+		return false
+	}
+	return info.Mode().Perm()&0o111 != 0
+}
+
+// Names returns the loaded plugins' file names.
+func (h *Host) Names() []string {
+	if h == nil {
+		return nil
+	}
+	names := make([]string, len(h.plugins))
+	for i, p := range h.plugins {
+		names[i] = p.name
+	}
+	return names
+}
+
+// Start runs every plugin until ctx is done.
+func (h *Host) Start(ctx context.Context) {
+	if h == nil {
+		return
+	}
+	for _, p := range h.plugins {
+		slog.Info("starting plugin", "plugin", p.name, "path", p.path)
+		go p.supervise(ctx, h.side)
+	}
+}
+
+// Emit sends hook's data to every plugin. It never blocks.
+func (h *Host) Emit(hook Hook, data any) {
+	if h == nil || len(h.plugins) == 0 {
+		return
+	}
+	line, err := json.Marshal(Event{Hook: hook, Host: h.side, Time: timefmt.Format(time.Now()), Data: data})
+	if err != nil {
+		slog.Warn("failed to encode plugin event", "hook", hook, "error", err)
+		return
+	}
+	line = append(line, '\n')
+	for _, p := range h.plugins {
+		select {
+		case p.events <- line:
+			p.dropping.Store(false)
+		default:
+			if !p.dropping.Swap(true) {
+				slog.Warn("plugin is not keeping up; dropping events", "plugin", p.name, "hook", hook)
+			}
+		}
+	}
+}
+
+// supervise runs the plugin, restarting it whenever it exits, until ctx
+// is done.
+func (p *plugin) supervise(ctx context.Context, side string) {
+	delay := minRestartDelay
+	for {
+		started := time.Now()
+		err := p.run(ctx, side)
+		if ctx.Err() != nil {
+			return
+		}
+		if time.Since(started) > maxRestartDelay {
+			delay = minRestartDelay
+		}
+		slog.Warn("plugin exited; restarting", "plugin", p.name, "error", err, "delay", delay)
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(delay):
+		}
+		delay = min(delay*2, maxRestartDelay)
+	}
+}
+
+// run starts the plugin once and feeds it events until it exits or ctx
+// is done.
+func (p *plugin) run(ctx context.Context, side string) error {
+	cmd := exec.CommandContext(ctx, p.path)
+	cmd.Dir = filepath.Dir(p.path)
+	cmd.Env = append(os.Environ(), "LEAPMUX_PLUGIN_HOST="+side)
+	stdin, err := cmd.StdinPipe()
+	if err != nil {
+		return err
+	}
+	// Closing stdin asks the plugin to exit; WaitDelay kills it if it
+	// does not.
+	cmd.Cancel = stdin.Close
+	cmd.WaitDelay = stopGrace
+	out := p.logWriter()
+	defer func() { _ = out.Close() }()
+	cmd.Stdout = out
+	cmd.Stderr = out
+	if err := cmd.Start(); err != nil {
+		return err
+	}
+
+	exited := make(chan error, 1)
+	go func() { exited <- cmd.Wait() }()
+	for {
+		select {
+		case err := <-exited:
+			return err
+		case line := <-p.events:
+			if _, err := stdin.Write(line); err != nil {
+				// The plugin is gone; Wait reports why.
+				return <-exited
+			}
+		}
+	}
+}
+
+// logWriter returns a writer that logs each line written to it as the
+// plugin's output.
+func (p *plugin) logWriter() io.WriteCloser {
+	r, w := io.Pipe()
+	go func() {
+		scanner := bufio.NewScanner(r)
+		scanner.Buffer(make([]byte, 0, 64*1024), 1024*1024)
+		for scanner.Scan() {
+			slog.Info("plugin output", "plugin", p.name, "line", scanner.Text())
+		}
+		_ = r.CloseWithError(scanner.Err())
+	}()
+	return w
+}
@@ -0,0 +1,106 @@
+package plugin
+
+import (
+	"context"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/leapmux/leapmux/internal/util/testutil"
+)
+
+func writePlugin(t *testing.T, dir, name, script string) {
+	t.Helper()
+	require.NoError(t, os.WriteFile(filepath.Join(dir, name), []byte("#!/bin/sh\n"+script), 0o755))
+}
+
+// readEvents returns the events a recording plugin appended to path.
+func readEvents(t *testing.T, path string) []Event {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil
+	}
+	var out []Event
+	for _, line := range strings.Split(strings.TrimSpace(string(data)), "\n") {
+		var e Event
+		if json.Unmarshal([]byte(line), &e) == nil {
+			out = append(out, e)
+		}
+	}
+	return out
+}
+
+func TestNew_LoadsExecutables(t *testing.T) {
+	h, err := New("", "worker")
+	require.NoError(t, err)
+	assert.Nil(t, h)
+	h.Emit(HookTurnCompleted, nil) // a nil Host ignores events
+	h.Start(context.Background())
+
+	_, err = New(filepath.Join(t.TempDir(), "missing"), "worker")
+	assert.Error(t, err)
+
+	if runtime.GOOS == "windows" {
+		t.Skip("execute bits")
+	}
+	dir := t.TempDir()
+	writePlugin(t, dir, "b-sync", "cat >/dev/null\n")
+	writePlugin(t, dir, "a-scan", "cat >/dev/null\n")
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "README"), []byte("notes"), 0o644))
+	require.NoError(t, os.Mkdir(filepath.Join(dir, "lib"), 0o755))
+	h, err = New(dir, "worker")
+	require.NoError(t, err)
+	assert.Equal(t, []string{"a-scan", "b-sync"}, h.Names())
+}
+
+func TestHost_DeliversEventsAndRestarts(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("shell script plugins")
+	}
+	dir := t.TempDir()
+	out := filepath.Join(t.TempDir(), "events")
+	starts := filepath.Join(t.TempDir(), "starts")
+	t.Setenv("PLUGIN_TEST_OUT", out)
+	t.Setenv("PLUGIN_TEST_STARTS", starts)
+	// Takes one event, then exits, so the second needs a restart.
+	writePlugin(t, dir, "recorder", `echo "$LEAPMUX_PLUGIN_HOST" >>"$PLUGIN_TEST_STARTS"
+read -r line && printf '%s\n' "$line" >>"$PLUGIN_TEST_OUT"
+`)
+	startCount := func() int {
+		data, _ := os.ReadFile(starts)
+		return strings.Count(string(data), "hub\n")
+	}
+	h, err := New(dir, "hub")
+	require.NoError(t, err)
+	ctx, cancel := context.WithCancel(context.Background())
+	t.Cleanup(cancel)
+	h.Start(ctx)
+
+	h.Emit(HookWorkspaceCreated, map[string]string{"workspace_id": "ws-1"})
+	testutil.AssertEventually(t, func() bool { return startCount() == 2 }, "expected the plugin to restart")
+	h.Emit(HookWorkspaceCreated, map[string]string{"workspace_id": "ws-2"})
+	testutil.AssertEventually(t, func() bool { return len(readEvents(t, out)) == 2 }, "expected both events")
+
+	events := readEvents(t, out)
+	require.Len(t, events, 2)
+	assert.Equal(t, HookWorkspaceCreated, events[0].Hook)
+	assert.Equal(t, "hub", events[0].Host)
+	assert.NotEmpty(t, events[0].Time)
+	assert.Equal(t, map[string]any{"workspace_id": "ws-1"}, events[0].Data)
+	assert.Equal(t, map[string]any{"workspace_id": "ws-2"}, events[1].Data)
+}
+
+func TestHost_DropsWhenBacklogFills(t *testing.T) {
+	h := &Host{side: "worker", plugins: []*plugin{{name: "stuck", events: make(chan []byte, 2)}}}
+	for range 5 {
+		h.Emit(HookMessageReceived, nil)
+	}
+	assert.Len(t, h.plugins[0].events, 2)
+	assert.True(t, h.plugins[0].dropping.Load())
+}
@@ -0,0 +1,277 @@
+// Package policy evaluates an org's access policy: rules whose CEL
+// conditions are checked against the context of a request -- who asks,
+// where, for which tool or command, at what time -- to allow or deny it.
+// The Hub uses it to validate and dry-run policies and to pick workers;
+// workers use it for tool uses and permission modes.
+package policy
+
+import (
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/google/cel-go/cel"
+	"github.com/google/cel-go/ext"
+
+	leapmuxv1 "github.com/leapmux/leapmux/generated/proto/leapmux/v1"
+)
+
+const (
+	// MaxRules bounds the rules evaluated for every tool use.
+	MaxRules = 64
+	// MaxConditionLen keeps a condition to something a person wrote.
+	MaxConditionLen = 2048
+	// MaxMessageLen bounds the message shown when a rule denies.
+	MaxMessageLen = 512
+	// costLimit stops a condition whose evaluation runs away, such as a
+	// comprehension over a long command.
+	costLimit = 100000
+)
+
+// Action names, the value of the condition's action variable.
+const (
+	ActionToolUse         = "tool_use"
+	ActionPermissionMode  = "permission_mode"
+	ActionWorkerSelection = "worker_selection"
+)
+
+// actionNames maps each action to its name in conditions.
+var actionNames = map[leapmuxv1.AccessPolicyAction]string{
+	leapmuxv1.AccessPolicyAction_ACCESS_POLICY_ACTION_TOOL_USE:         ActionToolUse,
+	leapmuxv1.AccessPolicyAction_ACCESS_POLICY_ACTION_PERMISSION_MODE:  ActionPermissionMode,
+	leapmuxv1.AccessPolicyAction_ACCESS_POLICY_ACTION_WORKER_SELECTION: ActionWorkerSelection,
+}
+
+// Input is the context of one request. Fields that do not apply to the
+// action are left empty.
+type Input struct {
+	Action         leapmuxv1.AccessPolicyAction
+	UserID         string
+	WorkspaceID    string
+	WorkerID       string
+	WorkerLabels   []string
+	Provider       string
+	Tool           string
+	Command        string
+	PermissionMode string
+	// Time is when the request is made; zero means now.
+	Time time.Time
+}
+
+// InputFromProto converts a dry-run request's input.
+func InputFromProto(in *leapmuxv1.AccessPolicyInput) (Input, error) {
+	out := Input{
+		Action:         in.GetAction(),
+		UserID:         in.GetUserId(),
+		WorkspaceID:    in.GetWorkspaceId(),
+		WorkerID:       in.GetWorkerId(),
+		WorkerLabels:   in.GetWorkerLabels(),
+		Provider:       in.GetProvider(),
+		Tool:           in.GetTool(),
+		Command:        in.GetCommand(),
+		PermissionMode: in.GetPermissionMode(),
+	}
+	if _, ok := actionNames[out.Action]; !ok {
+		return Input{}, errors.New("action is required")
+	}
+	if in.GetTime() != "" {
+		t, err := time.Parse(time.RFC3339, in.GetTime())
+		if err != nil {
+			return Input{}, fmt.Errorf("time: %w", err)
+		}
+		out.Time = t
+	}
+	return out, nil
+}
+
+// activation returns the variables a condition sees for in.
+func (in Input) activation() map[string]any {
+	now := in.Time
+	if now.IsZero() {
+		now = time.Now()
+	}
+	labels := in.WorkerLabels
+	if labels == nil {
+		labels = []string{}
+	}
+	utc := now.UTC()
+	return map[string]any{
+		"action":          actionNames[in.Action],
+		"user_id":         in.UserID,
+		"workspace_id":    in.WorkspaceID,
+		"worker_id":       in.WorkerID,
+		"worker_labels":   labels,
+		"provider":        in.Provider,
+		"tool":            in.Tool,
+		"command":         in.Command,
+		"permission_mode": in.PermissionMode,
+		"now":             now,
+		"hour":            int64(utc.Hour()),
+		"weekday":         int64(utc.Weekday()),
+	}
+}
+
+// Decision is the outcome of evaluating a policy.
+type Decision struct {
+	// Effect is UNSPECIFIED when no rule decided.
+	Effect  leapmuxv1.AccessPolicyEffect
+	Rule    string
+	Message string
+	// Errors lists the rules skipped because their condition failed to
+	// evaluate.
+	Errors []string
+}
+
+// Denied reports whether a rule denied the request.
+func (d Decision) Denied() bool {
+	return d.Effect == leapmuxv1.AccessPolicyEffect_ACCESS_POLICY_EFFECT_DENY
+}
+
+// Allowed reports whether a rule allowed the request.
+func (d Decision) Allowed() bool {
+	return d.Effect == leapmuxv1.AccessPolicyEffect_ACCESS_POLICY_EFFECT_ALLOW
+}
+
+// ToProto converts d to a dry-run response.
+func (d Decision) ToProto() *leapmuxv1.EvaluateAccessPolicyResponse {
+	return &leapmuxv1.EvaluateAccessPolicyResponse{
+		Effect:  d.Effect,
+		Rule:    d.Rule,
+		Message: d.Message,
+		Errors:  d.Errors,
+	}
+}
+
+// Policy is a compiled AccessPolicy. A nil *Policy has no rules.
+type Policy struct {
+	rules []rule
+}
+
+type rule struct {
+	name    string
+	actions []leapmuxv1.AccessPolicyAction
+	effect  leapmuxv1.AccessPolicyEffect
+	message string
+	program cel.Program
+}
+
+// env declares the variables conditions may use; see AccessPolicyRule.
+var env = func() *cel.Env {
+	e, err := cel.NewEnv(
+		cel.Variable("action", cel.StringType),
+		cel.Variable("user_id", cel.StringType),
+		cel.Variable("workspace_id", cel.StringType),
+		cel.Variable("worker_id", cel.StringType),
+		cel.Variable("worker_labels", cel.ListType(cel.StringType)),
+		cel.Variable("provider", cel.StringType),
+		cel.Variable("tool", cel.StringType),
+		cel.Variable("command", cel.StringType),
+		cel.Variable("permission_mode", cel.StringType),
+		cel.Variable("now", cel.TimestampType),
+		cel.Variable("hour", cel.IntType),
+		cel.Variable("weekday", cel.IntType),
+		ext.Strings(),
+	)
+	if err != nil {
+		panic(fmt.Sprintf("policy: build CEL environment: %v", err))
+	}
+	return e
+}()
+
+// Compile compiles p. Rules that fail to compile are left out, and an
+// error is returned for each, so the Hub can refuse the policy while a
+// worker still enforces what it can of one written for a newer Hub.
+func Compile(p *leapmuxv1.AccessPolicy) (*Policy, []error) {
+	var errs []error
+	if n := len(p.GetRules()); n > MaxRules {
+		return nil, []error{fmt.Errorf("at most %d rules are allowed", MaxRules)}
+	}
+	out := &Policy{}
+	for i, r := range p.GetRules() {
+		compiled, err := compileRule(r)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("rule %d (%s): %w", i+1, r.GetName(), err))
+			continue
+		}
+		out.rules = append(out.rules, compiled)
+	}
+	return out, errs
+}
+
+func compileRule(r *leapmuxv1.AccessPolicyRule) (rule, error) {
+	if strings.TrimSpace(r.GetName()) == "" {
+		return rule{}, errors.New("name is required")
+	}
+	switch r.GetEffect() {
+	case leapmuxv1.AccessPolicyEffect_ACCESS_POLICY_EFFECT_ALLOW, leapmuxv1.AccessPolicyEffect_ACCESS_POLICY_EFFECT_DENY:
+	default:
+		return rule{}, errors.New("effect must be allow or deny")
+	}
+	for _, a := range r.GetActions() {
+		if _, ok := actionNames[a]; !ok {
+			return rule{}, fmt.Errorf("unknown action %v", a)
+		}
+	}
+	if len(r.GetMessage()) > MaxMessageLen {
+		return rule{}, fmt.Errorf("message exceeds %d characters", MaxMessageLen)
+	}
+	condition := r.GetCondition()
+	if strings.TrimSpace(condition) == "" {
+		return rule{}, errors.New("condition is required")
+	}
+	if len(condition) > MaxConditionLen {
+		return rule{}, fmt.Errorf("condition exceeds %d characters", MaxConditionLen)
+	}
+	ast, iss := env.Compile(condition)
+	if iss.Err() != nil {
+		return rule{}, fmt.Errorf("condition: %w", iss.Err())
+	}
+	if ast.OutputType() != cel.BoolType {
+		return rule{}, fmt.Errorf("condition evaluates to %v, not bool", ast.OutputType())
+	}
+	program, err := env.Program(ast, cel.CostLimit(costLimit))
+	if err != nil {
+		return rule{}, fmt.Errorf("condition: %w", err)
+	}
+	return rule{
+		name:    r.GetName(),
+		actions: r.GetActions(),
+		effect:  r.GetEffect(),
+		message: r.GetMessage(),
+		program: program,
+	}, nil
+}
+
+// Empty reports whether p has no rules, so callers can skip building an
+// Input.
+func (p *Policy) Empty() bool {
+	return p == nil || len(p.rules) == 0
+}
+
+// Evaluate returns the decision of the first rule that covers in.Action
+// and whose condition holds. A condition that fails to evaluate skips its
+// rule rather than deciding the request.
+func (p *Policy) Evaluate(in Input) Decision {
+	var d Decision
+	if p.Empty() {
+		return d
+	}
+	vars := in.activation()
+	for _, r := range p.rules {
+		if len(r.actions) > 0 && !slices.Contains(r.actions, in.Action) {
+			continue
+		}
+		val, _, err := r.program.Eval(vars)
+		if err != nil {
+			d.Errors = append(d.Errors, fmt.Sprintf("rule %s: %v", r.name, err))
+			continue
+		}
+		if matched, ok := val.Value().(bool); ok && matched {
+			d.Effect, d.Rule, d.Message = r.effect, r.name, r.message
+			return d
+		}
+	}
+	return d
+}
@@ -0,0 +1,90 @@
+package policy
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	leapmuxv1 "github.com/leapmux/leapmux/generated/proto/leapmux/v1"
+)
+
+const (
+	toolUse = leapmuxv1.AccessPolicyAction_ACCESS_POLICY_ACTION_TOOL_USE
+	modes   = leapmuxv1.AccessPolicyAction_ACCESS_POLICY_ACTION_PERMISSION_MODE
+	allow   = leapmuxv1.AccessPolicyEffect_ACCESS_POLICY_EFFECT_ALLOW
+	deny    = leapmuxv1.AccessPolicyEffect_ACCESS_POLICY_EFFECT_DENY
+)
+
+func TestCompile_RejectsInvalidRules(t *testing.T) {
+	p, errs := Compile(&leapmuxv1.AccessPolicy{Rules: []*leapmuxv1.AccessPolicyRule{
+		{Name: "ok", Condition: `tool == "Read"`, Effect: allow},
+		{Name: "syntax", Condition: `tool ==`, Effect: deny},
+		{Name: "not-bool", Condition: `tool`, Effect: deny},
+		{Name: "unknown-var", Condition: `repo == "x"`, Effect: deny},
+		{Name: "no-effect", Condition: `true`},
+		{Condition: `true`, Effect: deny},
+	}})
+	require.Len(t, errs, 5)
+	assert.Contains(t, errs[1].Error(), "not bool")
+	assert.Len(t, p.rules, 1, "the valid rule is still enforced")
+}
+
+func TestEvaluate_FirstMatchingRuleDecides(t *testing.T) {
+	p, errs := Compile(&leapmuxv1.AccessPolicy{Rules: []*leapmuxv1.AccessPolicyRule{
+		{Name: "no-bypass", Actions: []leapmuxv1.AccessPolicyAction{modes}, Condition: `permission_mode == "bypassPermissions"`, Effect: deny, Message: "ask an admin"},
+		{Name: "no-push-at-night", Actions: []leapmuxv1.AccessPolicyAction{toolUse}, Condition: `command.contains("git push") && (hour < 8 || hour >= 20)`, Effect: deny},
+		{Name: "gpu-only", Condition: `tool == "Bash" && "gpu" in worker_labels`, Effect: allow},
+		{Name: "reads", Condition: `tool in ["Read", "Grep"]`, Effect: allow},
+	}})
+	require.Empty(t, errs)
+
+	night := time.Date(2026, 10, 18, 22, 0, 0, 0, time.UTC)
+	day := time.Date(2026, 10, 18, 12, 0, 0, 0, time.UTC)
+
+	d := p.Evaluate(Input{Action: modes, PermissionMode: "bypassPermissions"})
+	assert.True(t, d.Denied())
+	assert.Equal(t, "no-bypass", d.Rule)
+	assert.Equal(t, "ask an admin", d.Message)
+
+	d = p.Evaluate(Input{Action: toolUse, Tool: "Bash", Command: "git push origin", WorkerLabels: []string{"gpu"}, Time: night})
+	assert.Equal(t, "no-push-at-night", d.Rule, "an earlier rule wins")
+	d = p.Evaluate(Input{Action: toolUse, Tool: "Bash", Command: "git push origin", WorkerLabels: []string{"gpu"}, Time: day})
+	assert.True(t, d.Allowed())
+	assert.Equal(t, "gpu-only", d.Rule)
+
+	d = p.Evaluate(Input{Action: toolUse, Tool: "Edit"})
+	assert.Equal(t, leapmuxv1.AccessPolicyEffect_ACCESS_POLICY_EFFECT_UNSPECIFIED, d.Effect, "no rule decides")
+	assert.Empty(t, d.Rule)
+}
+
+func TestEvaluate_SkipsRulesThatFail(t *testing.T) {
+	p, errs := Compile(&leapmuxv1.AccessPolicy{Rules: []*leapmuxv1.AccessPolicyRule{
+		{Name: "bad-zone", Condition: `now.getHours("Nowhere/Land") > 3`, Effect: deny},
+		{Name: "fallback", Condition: `true`, Effect: allow},
+	}})
+	require.Empty(t, errs)
+	d := p.Evaluate(Input{Action: toolUse})
+	assert.Equal(t, "fallback", d.Rule)
+	require.Len(t, d.Errors, 1)
+	assert.Contains(t, d.Errors[0], "bad-zone")
+}
+
+func TestInputFromProto(t *testing.T) {
+	in, err := InputFromProto(&leapmuxv1.AccessPolicyInput{Action: toolUse, Tool: "Bash", Time: "2026-10-18T06:30:00Z"})
+	require.NoError(t, err)
+	assert.Equal(t, int64(6), in.activation()["hour"])
+	assert.Equal(t, int64(time.Sunday), in.activation()["weekday"])
+
+	_, err = InputFromProto(&leapmuxv1.AccessPolicyInput{Tool: "Bash"})
+	assert.Error(t, err, "the action is required")
+	_, err = InputFromProto(&leapmuxv1.AccessPolicyInput{Action: toolUse, Time: "yesterday"})
+	assert.Error(t, err)
+}
+
+func TestPolicy_NilHasNoRules(t *testing.T) {
+	var p *Policy
+	assert.True(t, p.Empty())
+	assert.Equal(t, Decision{}, p.Evaluate(Input{Action: toolUse}))
+}
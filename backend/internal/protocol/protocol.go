@@ -0,0 +1,78 @@
+// Package protocol declares the hub/worker wire protocol version and the
+// compatibility matrix both sides check on Connect.
+//
+// The version is bumped whenever the Connect stream gains a payload or a
+// field the other side must understand to work correctly. Bumping it
+// alone changes nothing: raising MinWorker or MinHub is what turns an old
+// peer away, with a clear "upgrade required" instead of payloads it
+// silently drops as unhandled.
+package protocol
+
+import (
+	"fmt"
+	"strconv"
+)
+
+// Header carries the worker's protocol version on the Connect request.
+// Workers that predate the exchange omit it.
+const Header = "X-LeapMux-Protocol-Version"
+
+// Legacy is the version assumed for a peer that does not report one:
+// everything built before the exchange existed.
+const Legacy uint32 = 1
+
+// Current is the protocol version this build speaks.
+const Current uint32 = 2
+
+// Compatibility matrix. Each entry records what a version added, so
+// deciding whether a minimum can move is a matter of reading this table.
+//
+//	1  Legacy: every Connect payload up to and including WorkerIdentity.
+//	2  The version exchange itself (Header, WorkerIdentity.protocol_version).
+var (
+	// MinWorker is the oldest worker protocol a hub accepts. Vars so tests
+	// can raise them.
+	MinWorker = Legacy
+	// MinHub is the oldest hub protocol a worker accepts.
+	MinHub = Legacy
+)
+
+// Parse reads a reported version. A missing or malformed value means the
+// peer predates the exchange.
+func Parse(s string) uint32 {
+	v, err := strconv.ParseUint(s, 10, 32)
+	if err != nil || v == 0 {
+		return Legacy
+	}
+	return uint32(v)
+}
+
+// Reported maps a version field from the wire to a version, treating the
+// zero an older peer sends as Legacy.
+func Reported(v uint32) uint32 {
+	if v == 0 {
+		return Legacy
+	}
+	return v
+}
+
+// Format renders a version for Header.
+func Format(v uint32) string {
+	return strconv.FormatUint(uint64(v), 10)
+}
+
+// CheckWorker reports whether a hub accepts a worker speaking version v.
+func CheckWorker(v uint32) error {
+	if v < MinWorker {
+		return fmt.Errorf("worker speaks protocol v%d, but this hub requires at least v%d; upgrade the worker", v, MinWorker)
+	}
+	return nil
+}
+
+// CheckHub reports whether a worker accepts a hub speaking version v.
+func CheckHub(v uint32) error {
+	if v < MinHub {
+		return fmt.Errorf("hub speaks protocol v%d, but this worker requires at least v%d; upgrade the hub", v, MinHub)
+	}
+	return nil
+}
@@ -0,0 +1,35 @@
+package protocol
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParse(t *testing.T) {
+	assert.Equal(t, Legacy, Parse(""), "an old worker sends no header")
+	assert.Equal(t, Legacy, Parse("garbage"))
+	assert.Equal(t, Legacy, Parse("0"))
+	assert.Equal(t, uint32(7), Parse("7"))
+	assert.Equal(t, Current, Parse(Format(Current)))
+}
+
+func TestCheck(t *testing.T) {
+	assert.NoError(t, CheckWorker(Current))
+	assert.NoError(t, CheckHub(Reported(0)), "a hub that predates the exchange is still accepted")
+
+	old := MinWorker
+	MinWorker = Current
+	t.Cleanup(func() { MinWorker = old })
+	err := CheckWorker(Legacy)
+	if assert.Error(t, err) {
+		assert.Contains(t, err.Error(), "upgrade the worker")
+	}
+	assert.NoError(t, CheckWorker(Current))
+}
+
+func TestCurrentMeetsMinimums(t *testing.T) {
+	// A build must always accept a peer of its own version.
+	assert.GreaterOrEqual(t, Current, MinWorker)
+	assert.GreaterOrEqual(t, Current, MinHub)
+}
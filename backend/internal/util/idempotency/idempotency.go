@@ -0,0 +1,148 @@
+// Package idempotency remembers the outcome of a create RPC under a
+// caller-chosen key for a bounded window, so a client retrying the call
+// over a flaky connection gets the original result instead of a second
+// workspace or agent.
+//
+// Keys live in memory only: a restart forgets them, and a retry that
+// straddles one is treated as a new call.
+package idempotency
+
+import (
+	"context"
+	"crypto/sha256"
+	"errors"
+	"sync"
+	"time"
+
+	"google.golang.org/protobuf/proto"
+)
+
+// MaxKeyLen caps the length of an idempotency key.
+const MaxKeyLen = 128
+
+// DefaultWindow is how long a completed call's result is replayed.
+const DefaultWindow = 10 * time.Minute
+
+// ErrKeyReused is returned by Claim when a key comes back with a request
+// that differs from the one it was first used for.
+var ErrKeyReused = errors.New("idempotency key was already used for a different request")
+
+type entry[T any] struct {
+	fingerprint string
+	done        chan struct{} // closed once the owning call completes or releases
+	value       T
+	completed   bool
+	expiresAt   time.Time
+}
+
+// Keys tracks idempotency keys and the values their calls produced. The
+// zero value is not usable; construct with New.
+type Keys[T any] struct {
+	window time.Duration
+	now    func() time.Time
+
+	mu      sync.Mutex
+	entries map[string]*entry[T]
+}
+
+// New returns an empty Keys that replays a completed call's value for
+// window.
+func New[T any](window time.Duration) *Keys[T] {
+	return &Keys[T]{
+		window:  window,
+		now:     time.Now,
+		entries: make(map[string]*entry[T]),
+	}
+}
+
+// Claim claims key for a call whose request hashes to fingerprint (see
+// Fingerprint). It returns owned=true when the caller must perform the
+// call and then either Complete or Release the key. Otherwise it returns
+// the value an earlier call with the same key produced, first waiting for
+// that call if it is still in flight.
+//
+// A key reused with a different fingerprint fails with ErrKeyReused.
+// Waiting ends early with ctx's error.
+func (k *Keys[T]) Claim(ctx context.Context, key, fingerprint string) (value T, owned bool, err error) {
+	for {
+		k.mu.Lock()
+		now := k.now()
+		e, ok := k.entries[key]
+		if ok && e.completed && !now.Before(e.expiresAt) {
+			delete(k.entries, key)
+			ok = false
+		}
+		if !ok {
+			k.sweepLocked(now)
+			k.entries[key] = &entry[T]{fingerprint: fingerprint, done: make(chan struct{})}
+			k.mu.Unlock()
+			return value, true, nil
+		}
+		if e.fingerprint != fingerprint {
+			k.mu.Unlock()
+			return value, false, ErrKeyReused
+		}
+		if e.completed {
+			k.mu.Unlock()
+			return e.value, false, nil
+		}
+		done := e.done
+		k.mu.Unlock()
+
+		// The first call is still running. Wait for it, then look again: it
+		// either completed (replay its value) or released the key (claim it).
+		select {
+		case <-done:
+		case <-ctx.Done():
+			return value, false, ctx.Err()
+		}
+	}
+}
+
+// Complete records value as the result of key's call and wakes any
+// duplicate waiting in Claim.
+func (k *Keys[T]) Complete(key string, value T) {
+	k.mu.Lock()
+	defer k.mu.Unlock()
+	e, ok := k.entries[key]
+	if !ok || e.completed {
+		return
+	}
+	e.value = value
+	e.completed = true
+	e.expiresAt = k.now().Add(k.window)
+	close(e.done)
+}
+
+// Release drops key's claim after its call failed, so a retry runs the
+// call again rather than replaying the failure.
+func (k *Keys[T]) Release(key string) {
+	k.mu.Lock()
+	defer k.mu.Unlock()
+	e, ok := k.entries[key]
+	if !ok || e.completed {
+		return
+	}
+	delete(k.entries, key)
+	close(e.done)
+}
+
+// sweepLocked drops completed entries whose window has passed. It runs on
+// every new claim, which keeps the map bounded by the keys claimed within
+// one window.
+func (k *Keys[T]) sweepLocked(now time.Time) {
+	for key, e := range k.entries {
+		if e.completed && !now.Before(e.expiresAt) {
+			delete(k.entries, key)
+		}
+	}
+}
+
+// Fingerprint returns a stable digest of req for Claim. Callers clear the
+// key field itself on a copy first, so only the request's substance is
+// compared.
+func Fingerprint(req proto.Message) string {
+	b, _ := proto.MarshalOptions{Deterministic: true}.Marshal(req)
+	sum := sha256.Sum256(b)
+	return string(sum[:])
+}
@@ -0,0 +1,92 @@
+package idempotency
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	leapmuxv1 "github.com/leapmux/leapmux/generated/proto/leapmux/v1"
+)
+
+func TestKeys_ReplaysCompletedCall(t *testing.T) {
+	k := New[string](time.Minute)
+	ctx := context.Background()
+
+	_, owned, err := k.Claim(ctx, "a", "fp")
+	require.NoError(t, err)
+	require.True(t, owned)
+	k.Complete("a", "ws-1")
+
+	v, owned, err := k.Claim(ctx, "a", "fp")
+	require.NoError(t, err)
+	assert.False(t, owned)
+	assert.Equal(t, "ws-1", v)
+
+	_, _, err = k.Claim(ctx, "a", "other")
+	assert.ErrorIs(t, err, ErrKeyReused)
+}
+
+func TestKeys_ReleaseLetsRetryRun(t *testing.T) {
+	k := New[string](time.Minute)
+	ctx := context.Background()
+
+	_, owned, _ := k.Claim(ctx, "a", "fp")
+	require.True(t, owned)
+	k.Release("a")
+
+	_, owned, err := k.Claim(ctx, "a", "fp")
+	require.NoError(t, err)
+	assert.True(t, owned, "a failed call is not replayed")
+}
+
+func TestKeys_DuplicateWaitsForInFlightCall(t *testing.T) {
+	k := New[string](time.Minute)
+	ctx := context.Background()
+	_, owned, _ := k.Claim(ctx, "a", "fp")
+	require.True(t, owned)
+
+	got := make(chan string)
+	go func() {
+		v, _, _ := k.Claim(ctx, "a", "fp")
+		got <- v
+	}()
+	time.Sleep(10 * time.Millisecond)
+	k.Complete("a", "ws-1")
+	select {
+	case v := <-got:
+		assert.Equal(t, "ws-1", v)
+	case <-time.After(5 * time.Second):
+		t.Fatal("duplicate did not wake on Complete")
+	}
+
+	cctx, cancel := context.WithCancel(ctx)
+	_, owned, _ = k.Claim(ctx, "b", "fp")
+	require.True(t, owned)
+	cancel()
+	_, _, err := k.Claim(cctx, "b", "fp")
+	assert.ErrorIs(t, err, context.Canceled)
+}
+
+func TestKeys_ExpiresAfterWindow(t *testing.T) {
+	now := time.Unix(1000, 0)
+	k := New[string](time.Minute)
+	k.now = func() time.Time { return now }
+	ctx := context.Background()
+
+	_, _, _ = k.Claim(ctx, "a", "fp")
+	k.Complete("a", "ws-1")
+	now = now.Add(time.Minute)
+
+	_, owned, err := k.Claim(ctx, "a", "changed")
+	require.NoError(t, err)
+	assert.True(t, owned, "an expired key is claimable again, even for a new request")
+}
+
+func TestFingerprint(t *testing.T) {
+	a := Fingerprint(&leapmuxv1.CreateWorkspaceRequest{Title: "x"})
+	assert.Equal(t, a, Fingerprint(&leapmuxv1.CreateWorkspaceRequest{Title: "x"}))
+	assert.NotEqual(t, a, Fingerprint(&leapmuxv1.CreateWorkspaceRequest{Title: "y"}))
+}
@@ -0,0 +1,129 @@
+// Package snippet parses and expands prompt snippets (leapmuxv1.Snippet).
+// The hub validates a snippet when it is saved; the worker expands it when
+// a message invoking it reaches SendAgentMessage. Both sides share this
+// package so a body the hub accepts always expands.
+package snippet
+
+import (
+	"errors"
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+const (
+	// MaxNameLen caps a snippet's name.
+	MaxNameLen = 64
+	// MaxBodyLen caps a snippet's body, in bytes.
+	MaxBodyLen = 64 << 10
+)
+
+var (
+	nameRE        = regexp.MustCompile(`^[a-z0-9][a-z0-9_-]*$`)
+	placeholderRE = regexp.MustCompile(`\{\{\s*([a-z0-9]+)\s*\}\}`)
+)
+
+// Vars are the workspace variables a body may reference. Empty when the
+// agent's working directory is not in a git repository.
+type Vars struct {
+	Branch string
+	Repo   string
+	Cwd    string
+}
+
+// ValidateName checks a snippet name.
+func ValidateName(name string) error {
+	if name == "" {
+		return errors.New("name: must not be empty")
+	}
+	if len(name) > MaxNameLen {
+		return fmt.Errorf("name: must be at most %d characters", MaxNameLen)
+	}
+	if !nameRE.MatchString(name) {
+		return errors.New(`name: must be lowercase letters, digits, "-" and "_", starting with a letter or digit`)
+	}
+	return nil
+}
+
+// ValidateBody checks a snippet body and that every placeholder in it is
+// one Expand knows.
+func ValidateBody(body string) error {
+	if strings.TrimSpace(body) == "" {
+		return errors.New("body: must not be empty")
+	}
+	if len(body) > MaxBodyLen {
+		return fmt.Errorf("body: must be at most %d bytes", MaxBodyLen)
+	}
+	if !utf8.ValidString(body) {
+		return errors.New("body: must be valid UTF-8")
+	}
+	for _, m := range placeholderRE.FindAllStringSubmatch(body, -1) {
+		if !knownPlaceholder(m[1]) {
+			return fmt.Errorf("body: unknown placeholder {{%s}}", m[1])
+		}
+	}
+	return nil
+}
+
+func knownPlaceholder(name string) bool {
+	switch name {
+	case "args", "branch", "repo", "cwd":
+		return true
+	}
+	n, err := strconv.Atoi(name)
+	return err == nil && n >= 1 && n <= 9 && name == strconv.Itoa(n)
+}
+
+// Parse splits a message invoking a snippet, "/name args", into the name
+// and the trimmed argument text. ok is false for a message that is not an
+// invocation, including one whose name is not a valid snippet name.
+func Parse(content string) (name, args string, ok bool) {
+	rest, found := strings.CutPrefix(content, "/")
+	if !found {
+		return "", "", false
+	}
+	name = rest
+	if i := strings.IndexFunc(rest, unicode.IsSpace); i >= 0 {
+		name, args = rest[:i], rest[i:]
+	}
+	if ValidateName(name) != nil {
+		return "", "", false
+	}
+	return name, strings.TrimSpace(args), true
+}
+
+// Expand substitutes args and vars into body. It fails when body uses a
+// positional argument the invocation did not supply.
+func Expand(body, args string, vars Vars) (string, error) {
+	fields := strings.Fields(args)
+	var missing int
+	out := placeholderRE.ReplaceAllStringFunc(body, func(m string) string {
+		name := placeholderRE.FindStringSubmatch(m)[1]
+		switch name {
+		case "args":
+			return args
+		case "branch":
+			return vars.Branch
+		case "repo":
+			return vars.Repo
+		case "cwd":
+			return vars.Cwd
+		}
+		n, err := strconv.Atoi(name)
+		if err != nil || !knownPlaceholder(name) {
+			return m
+		}
+		if n > len(fields) {
+			missing = max(missing, n)
+			return m
+		}
+		return fields[n-1]
+	})
+	if missing > 0 {
+		return "", fmt.Errorf("this snippet needs at least %d argument(s), got %d", missing, len(fields))
+	}
+	return out, nil
+}
@@ -0,0 +1,61 @@
+package snippet_test
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/leapmux/leapmux/internal/util/snippet"
+)
+
+func TestValidateName(t *testing.T) {
+	for _, name := range []string{"review", "tests", "fix-lint", "a_1"} {
+		assert.NoError(t, snippet.ValidateName(name), name)
+	}
+	for _, name := range []string{"", "Review", "-x", "has space", "ü", strings.Repeat("a", snippet.MaxNameLen+1)} {
+		assert.Error(t, snippet.ValidateName(name), name)
+	}
+}
+
+func TestValidateBody(t *testing.T) {
+	assert.NoError(t, snippet.ValidateBody("Review {{args}} on {{ branch }} of {{repo}} in {{cwd}}, starting at {{1}}."))
+	assert.Error(t, snippet.ValidateBody("  "))
+	assert.ErrorContains(t, snippet.ValidateBody("Hi {{name}}"), "{{name}}")
+	assert.ErrorContains(t, snippet.ValidateBody("Hi {{10}}"), "{{10}}")
+	assert.ErrorContains(t, snippet.ValidateBody("Hi {{01}}"), "{{01}}")
+}
+
+func TestParse(t *testing.T) {
+	for _, tc := range []struct {
+		content, name, args string
+		ok                  bool
+	}{
+		{"/review", "review", "", true},
+		{"/review  main.go  util.go ", "review", "main.go  util.go", true},
+		{"/review\nthe diff", "review", "the diff", true},
+		{"review", "", "", false},
+		{"/ review", "", "", false},
+		{"/etc/passwd is world readable", "", "", false},
+	} {
+		name, args, ok := snippet.Parse(tc.content)
+		assert.Equal(t, tc.ok, ok, tc.content)
+		assert.Equal(t, tc.name, name, tc.content)
+		assert.Equal(t, tc.args, args, tc.content)
+	}
+}
+
+func TestExpand(t *testing.T) {
+	vars := snippet.Vars{Branch: "feature/x", Repo: "leapmux", Cwd: "/src/leapmux"}
+	got, err := snippet.Expand("Review {{1}} against {{ 2 }} ({{args}}) on {{branch}} of {{repo}} in {{cwd}}.", "a.go main", vars)
+	require.NoError(t, err)
+	assert.Equal(t, "Review a.go against main (a.go main) on feature/x of leapmux in /src/leapmux.", got)
+
+	_, err = snippet.Expand("Compare {{1}} with {{3}}", "a b", vars)
+	assert.ErrorContains(t, err, "at least 3")
+
+	got, err = snippet.Expand("Write tests.", "", snippet.Vars{})
+	require.NoError(t, err)
+	assert.Equal(t, "Write tests.", got)
+}
@@ -1100,8 +1100,8 @@ func (b *jsonrpcBase) handleJSONRPCResponse(line *parsedLine) bool {
 
 // readOutputLoop reads JSONL lines from stdout, using handleJSONRPCResponse as
 // the interceptor and forwarding remaining lines to the given output handler.
-func (b *jsonrpcBase) readOutputLoop(scanner *bufio.Scanner, handle outputHandler) {
-	b.readOutput(scanner, b.handleJSONRPCResponse, handle)
+func (b *jsonrpcBase) readOutputLoop(scanner *bufio.Scanner, sink OutputSink, handle outputHandler) {
+	b.readOutput(scanner, sink, b.handleJSONRPCResponse, handle)
 }
 
 // enqueueOrSendPrompt queues a message if a prompt is in flight, or starts
@@ -1214,10 +1214,10 @@ func (b *acpBase) sendPrompt(
 	if err != nil {
 		if !b.IsStopped() {
 			slog.Error("acp prompt failed", "agent_id", b.agentID, "error", err)
-			b.sink.PersistLeapMuxNotification(map[string]interface{}{
-				"type":  NotificationTypeAgentError,
-				"error": fmt.Sprintf("prompt failed: %v", err),
-			})
+			b.sink.PersistLeapMuxNotification(NotificationContent(AgentErrorPayload{
+				Code:  AgentErrorPromptFailed,
+				Error: err.Error(),
+			}))
 		}
 		return
 	}
@@ -1373,10 +1373,11 @@ func unwrapACPResult(resp json.RawMessage) json.RawMessage {
 
 func (b *acpBase) handleToolCall(update json.RawMessage) {
 	var tc struct {
-		ToolCallID string `json:"toolCallId"`
-		Title      string `json:"title"`
-		Kind       string `json:"kind"`
-		Status     string `json:"status"`
+		ToolCallID string          `json:"toolCallId"`
+		Title      string          `json:"title"`
+		Kind       string          `json:"kind"`
+		Status     string          `json:"status"`
+		RawInput   json.RawMessage `json:"rawInput"`
 	}
 	if err := json.Unmarshal(update, &tc); err != nil {
 		slog.Warn("acp tool_call unmarshal failed", "provider", b.providerName, "agent_id", b.agentID, "error", err)
@@ -1391,11 +1392,16 @@ func (b *acpBase) handleToolCall(update json.RawMessage) {
 		spanType = acpUpdateToolCall
 	}
 
+	toolCall := &ToolCall{Name: spanType}
+	if tc.Kind == "execute" {
+		toolCall.Command = toolCallCommand(tc.RawInput)
+	}
+
 	// Tool calls that arrive already terminal (completed/failed/cancelled)
 	// are persisted as closing spans immediately — no open/close cycle.
 	if tc.Status == "completed" || tc.Status == "failed" || tc.Status == "cancelled" {
 		if err := b.sink.PersistMessage(leapmuxv1.MessageSource_MESSAGE_SOURCE_AGENT, update, SpanInfo{
-			SpanID: tc.ToolCallID, SpanType: spanType, Closing: true,
+			SpanID: tc.ToolCallID, SpanType: spanType, Closing: true, ToolCall: toolCall,
 		}); err != nil {
 			slog.Error("persist terminal acp tool_call", "agent_id", b.agentID, "kind", tc.Kind, "status", tc.Status, "error", err)
 		}
@@ -1404,7 +1410,7 @@ func (b *acpBase) handleToolCall(update json.RawMessage) {
 
 	spanColor := b.sink.ReserveSpanColor(tc.ToolCallID, "")
 	if err := b.sink.PersistMessage(leapmuxv1.MessageSource_MESSAGE_SOURCE_AGENT, update, SpanInfo{
-		SpanID: tc.ToolCallID, SpanType: spanType, SpanColor: spanColor,
+		SpanID: tc.ToolCallID, SpanType: spanType, SpanColor: spanColor, ToolCall: toolCall,
 	}); err != nil {
 		slog.Error("persist acp tool_call", "agent_id", b.agentID, "kind", tc.Kind, "error", err)
 	}
@@ -1615,7 +1621,7 @@ func (b *acpBase) startACPHandshake(
 	b.sink = newThinkingResetSink(b.sink, &b.thinkingTokens)
 
 	scanner := newStdoutScanner(stdout)
-	go b.readOutputLoop(scanner, b.handleOutput)
+	go b.readOutputLoop(scanner, b.sink, b.handleOutput)
 
 	cleanup := b.stopAndWait
 
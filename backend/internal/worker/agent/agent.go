@@ -53,6 +53,32 @@ type SpanInfo struct {
 	// as a scroll-rail jump target. Zero value (MARK_TYPE_UNSPECIFIED) leaves the
 	// row unmarked, so existing SpanInfo{...} literals need no change.
 	MarkType leapmuxv1.MarkType
+	// Usage, when set, is the token usage and cost the message accounts for,
+	// persisted beside the row so the transcript can show what each turn cost.
+	Usage *MessageUsage
+	// ToolCall, when set, is the tool call the message starts.
+	ToolCall *ToolCall
+}
+
+// MessageUsage is the token usage and cost attributed to one persisted
+// message. CostUSD is 0 when the provider reports no cost for it.
+type MessageUsage struct {
+	InputTokens              int64
+	OutputTokens             int64
+	CacheCreationInputTokens int64
+	CacheReadInputTokens     int64
+	CostUSD                  float64
+	// Turn marks a whole turn's total, which repeats the usage of the
+	// turn's own messages: a sum over turns counts each call once.
+	Turn bool
+	// InTurn marks a message whose usage its turn's Turn total repeats:
+	// a sum over every message skips it.
+	InTurn bool
+}
+
+// IsZero reports whether u records neither tokens nor cost.
+func (u MessageUsage) IsZero() bool {
+	return u == MessageUsage{}
 }
 
 type AutoContinueReason string
@@ -116,6 +142,11 @@ type OutputSink interface {
 	// without a second DB round-trip to read it back (and without the readback-failure window that
 	// would broadcast an empty token). Empty only when the sink mints none (test fakes).
 	PersistControlRequest(requestID string, payload []byte) (claimToken string)
+	// DivertControlRequest offers a control request to the worker before it is persisted. It
+	// returns true when the worker has answered the request itself (the org's access policy
+	// allowed or denied it, or its agent terminal policy ran the command in a terminal instead),
+	// in which case the caller must neither persist nor broadcast it.
+	DivertControlRequest(requestID string, payload []byte) bool
 	DeleteControlRequest(requestID string)
 	// BroadcastControlRequest fans the control request out to live windows, carrying the claim_token
 	// PersistControlRequest returned so the frontend can echo it in its answer (AgentControlRequest.claim_token).
@@ -144,6 +175,17 @@ type OutputSink interface {
 	UpdatePlan(content []byte, compression leapmuxv1.ContentCompression, title string)
 	ScheduleAutoContinue(schedule AutoContinueSchedule)
 	CancelAutoContinue(reason AutoContinueReason)
+	// StartSubAgentRun, AddSubAgentUsage, and EndSubAgentRun record the
+	// lifecycle of a sub-agent the agent launched, keyed by the launching
+	// tool_use id. parentToolUseID is the enclosing run for a nested
+	// sub-agent; status is one of the SubAgentRun* constants.
+	StartSubAgentRun(toolUseID, parentToolUseID, subAgentType, description string)
+	AddSubAgentUsage(toolUseID string, usage SubAgentUsage)
+	EndSubAgentRun(toolUseID, status string)
+	// PersistUnparsedOutput keeps a stdout line that is not valid JSON,
+	// with the parse error, as a dead letter an admin can inspect and
+	// replay through HandleOutput once the parser is fixed.
+	PersistUnparsedOutput(line []byte, parseErr error)
 }
 
 // Agent is the interface that all coding agent providers must implement.
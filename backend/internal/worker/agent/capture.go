@@ -0,0 +1,54 @@
+package agent
+
+import (
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// openOutputCapture opens the file a process's raw stdout is copied to
+// when Options.CaptureDir is set: one file per process, named after the
+// agent and the start time, so `leapmux worker replay` can feed a
+// session back through HandleOutput. Capture is a debugging aid, so a
+// failure to open the file is logged and the agent runs uncaptured.
+func openOutputCapture(opts Options, providerName string) io.WriteCloser {
+	if opts.CaptureDir == "" {
+		return nil
+	}
+	if err := os.MkdirAll(opts.CaptureDir, 0o700); err != nil {
+		slog.Warn("agent output capture disabled", "agent_id", opts.AgentID, "error", err)
+		return nil
+	}
+	name := fmt.Sprintf("%s-%s-%s.ndjson", opts.AgentID, providerName, time.Now().UTC().Format("20060102T150405.000"))
+	// Transcripts carry everything the agent printed, secrets included.
+	f, err := os.OpenFile(filepath.Join(opts.CaptureDir, name), os.O_WRONLY|os.O_CREATE|os.O_APPEND, 0o600)
+	if err != nil {
+		slog.Warn("agent output capture disabled", "agent_id", opts.AgentID, "error", err)
+		return nil
+	}
+	slog.Info("capturing agent output", "agent_id", opts.AgentID, "path", f.Name())
+	return f
+}
+
+// captureLine appends one stdout line to the capture file, if any. Only
+// the readOutput goroutine writes, so no lock is needed.
+func (p *processBase) captureLine(line []byte) {
+	if p.capture == nil {
+		return
+	}
+	if _, err := p.capture.Write(append(line[:len(line):len(line)], '\n')); err != nil {
+		slog.Warn("agent output capture stopped", "agent_id", p.agentID, "error", err)
+		p.closeCapture()
+	}
+}
+
+func (p *processBase) closeCapture() {
+	if p.capture == nil {
+		return
+	}
+	_ = p.capture.Close()
+	p.capture = nil
+}
@@ -107,6 +107,15 @@ type ClaudeCodeAgent struct {
 	contextUsage           *contextUsageSnapshot
 	lastAgentStatus        string
 	thirdPartyFromSettings bool // third-party LLM provider detected from settings at startup
+	outputSchema           claudeSchemaChecker
+
+	// openSubAgentRuns tracks the turn's sub-agent runs (see
+	// claude_subagents.go); lastUsageMessageID and lastResultCostUSD drive
+	// per-message usage attribution (see claude_usage.go). Like contextUsage
+	// they are only touched from the readOutputLoop goroutine.
+	openSubAgentRuns   map[string]struct{}
+	lastUsageMessageID map[string]string
+	lastResultCostUSD  float64
 
 	pendingControlMu        sync.Mutex
 	pendingControl          map[string]chan<- claudeCodeControlResult
@@ -169,6 +178,9 @@ func StartClaudeCode(ctx context.Context, opts Options, sink OutputSink) (*Claud
 	if opts.ResumeSessionID != "" {
 		baseArgs = append(baseArgs, "--resume", opts.ResumeSessionID)
 	}
+	if opts.SystemPrompt != "" {
+		baseArgs = append(baseArgs, "--append-system-prompt", opts.SystemPrompt)
+	}
 
 	// opts.Model() is the raw stored/operator-default value, which may be a legacy
 	// or fully-qualified id (a persisted "opus", a "claude-opus-4-8" from
@@ -231,6 +243,7 @@ func StartClaudeCode(ctx context.Context, opts Options, sink OutputSink) (*Claud
 		thirdPartyFromSettings: thirdPartyFromSettings,
 		pendingControl:         make(map[string]chan<- claudeCodeControlResult),
 		alwaysThinking:         AlwaysThinkingOn,
+		outputSchema:           claudeSchemaChecker{mode: opts.OutputSchemaMode},
 	}
 
 	TraceStartupPhase(opts.AgentID, "before_exec_start")
@@ -1943,7 +1956,7 @@ func (a *ClaudeCodeAgent) handlePendingControlResponse(line *parsedLine) bool {
 }
 
 func (a *ClaudeCodeAgent) readOutputLoop(scanner *bufio.Scanner) {
-	a.readOutput(scanner, a.handlePendingControlResponse, a.handleOutput)
+	a.readOutput(scanner, a.sink, a.handlePendingControlResponse, a.handleOutput)
 }
 
 // handleOutput adapts the parsedLine to the existing HandleOutput method,
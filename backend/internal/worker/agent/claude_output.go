@@ -57,6 +57,9 @@ type contextUsageSnapshot struct {
 	CacheCreationInputTokens int64
 	CacheReadInputTokens     int64
 	ContextWindow            int64
+	// SubAgent is the session's cumulative sub-agent usage, broadcast under
+	// SessionInfoKeySubAgentUsage rather than folded into the fields above.
+	SubAgent SubAgentUsage
 	// windowModel is the model id ContextWindow was derived for. The snapshot
 	// outlives a model change (a live model switch, or the account-default sentinel
 	// resolving to a concrete model after startup), so the window is re-seeded from
@@ -131,6 +134,9 @@ func (s *contextUsageSnapshot) buildBroadcast(msgType string, now time.Time) (ma
 	if s.ContextWindow > 0 {
 		usageMap["context_window"] = s.ContextWindow
 	}
+	if !s.SubAgent.IsZero() {
+		usageMap[SessionInfoKeySubAgentUsage] = s.SubAgent.broadcastMap()
+	}
 	return usageMap, true
 }
 
@@ -157,6 +163,13 @@ func (a *ClaudeCodeAgent) handleClaudeOutput(content []byte, msgType string) {
 
 	slog.Debug("HandleOutput", "agent_id", a.agentID, "type", msgType, "len", len(content))
 
+	if findings := a.outputSchema.check(a.agentID, msgType, content); len(findings) > 0 {
+		a.sink.PersistLeapMuxNotification(NotificationContent(AgentErrorPayload{
+			Code:  AgentErrorOutputSchemaMismatch,
+			Error: describeSchemaFindings(findings),
+		}))
+	}
+
 	switch msgType {
 	case claudeMsgTypeAssistant, claudeMsgTypeSystem, claudeMsgTypeResult:
 		a.handlePersistableMessage(content, msgType)
@@ -198,6 +211,22 @@ func (a *ClaudeCodeAgent) handleClaudeOutput(content []byte, msgType string) {
 	}
 }
 
+// persistUnparsedMessage persists a message whose envelope did not decode,
+// verbatim and outside any span.
+func (a *ClaudeCodeAgent) persistUnparsedMessage(content []byte, msgType string, source leapmuxv1.MessageSource) {
+	var err error
+	if msgType == claudeMsgTypeResult {
+		err = a.sink.PersistTurnEnd(content, SpanInfo{})
+		a.interruptSubAgentRuns()
+		a.sink.ResetSpans()
+	} else {
+		err = a.sink.PersistMessage(source, content, SpanInfo{})
+	}
+	if err != nil {
+		slog.Error("persist agent message", "agent_id", a.agentID, "error", err)
+	}
+}
+
 // enrichResultWithToolUses injects num_tool_uses into a result message so
 // the frontend can determine whether the turn involved tool use.
 func (a *ClaudeCodeAgent) enrichResultWithToolUses(content []byte) []byte {
@@ -211,6 +240,16 @@ type contentBlock struct {
 	Name      string          `json:"name"`
 	ToolUseID string          `json:"tool_use_id"`
 	Input     json.RawMessage `json:"input"`
+	IsError   bool            `json:"is_error"`
+}
+
+// claudeUsage is the token usage block Claude Code reports on assistant
+// and result messages.
+type claudeUsage struct {
+	InputTokens              int64 `json:"input_tokens"`
+	OutputTokens             int64 `json:"output_tokens"`
+	CacheCreationInputTokens int64 `json:"cache_creation_input_tokens"`
+	CacheReadInputTokens     int64 `json:"cache_read_input_tokens"`
 }
 
 // messageEnvelope is the shared top-level structure parsed once for
@@ -219,19 +258,18 @@ type messageEnvelope struct {
 	ParentToolUseID string `json:"parent_tool_use_id"`
 	ToolUseID       string `json:"tool_use_id"`
 	Message         struct {
+		ID         string          `json:"id"`
 		RawContent json.RawMessage `json:"content"`
-		Usage      *struct {
-			InputTokens              int64 `json:"input_tokens"`
-			OutputTokens             int64 `json:"output_tokens"`
-			CacheCreationInputTokens int64 `json:"cache_creation_input_tokens"`
-			CacheReadInputTokens     int64 `json:"cache_read_input_tokens"`
-		} `json:"usage"`
+		Usage      *claudeUsage    `json:"usage"`
 	} `json:"message"`
-	ToolUseResult json.RawMessage            `json:"tool_use_result"`
-	CostUSD       *float64                   `json:"total_cost_usd"`
-	ModelUsage    map[string]json.RawMessage `json:"modelUsage"`
-	IsError       bool                       `json:"is_error"`
-	Result        string                     `json:"result"`
+	ToolUseResult json.RawMessage `json:"tool_use_result"`
+	// Usage is a result message's token usage summed over the turn's API
+	// calls; assistant messages carry theirs on Message.Usage instead.
+	Usage      *claudeUsage               `json:"usage"`
+	CostUSD    *float64                   `json:"total_cost_usd"`
+	ModelUsage map[string]json.RawMessage `json:"modelUsage"`
+	IsError    bool                       `json:"is_error"`
+	Result     string                     `json:"result"`
 
 	// contentBlocks is lazily populated from RawContent.
 	contentBlocks []contentBlock
@@ -310,6 +348,8 @@ func (a *ClaudeCodeAgent) processAssistantBlocks(env *messageEnvelope) {
 				a.sink.StorePlanModeToolUse(block.ID, PermissionModePlan)
 			case ToolNameExitPlanMode:
 				a.sink.StorePlanModeToolUse(block.ID, PermissionModeDefault)
+			case ToolNameTask, ToolNameAgent:
+				a.startSubAgentRun(block, parentSpanID)
 			}
 
 			a.sink.OpenSpan(block.ID, parentSpanID)
@@ -352,7 +392,7 @@ func (a *ClaudeCodeAgent) processAssistantBlocks(env *messageEnvelope) {
 					if planContentStr != "" {
 						compressed, compression = msgcodec.Compress([]byte(planContentStr))
 					}
-					a.sink.UpdatePlan(compressed, compression, extractPlanTitle(planContentStr))
+					a.sink.UpdatePlan(compressed, compression, ExtractPlanTitle(planContentStr))
 				}
 			}
 		}
@@ -413,15 +453,23 @@ func (a *ClaudeCodeAgent) handlePersistableMessage(content []byte, msgType strin
 	// Parse the message envelope once for all downstream consumers.
 	var env messageEnvelope
 	if err := json.Unmarshal(content, &env); err != nil {
-		slog.Warn("invalid message envelope", "agent_id", a.agentID, "error", err)
+		// A field changed shape upstream (the schema check above names
+		// it). Keep the message, without the hierarchy and usage metadata
+		// it would have carried, rather than dropping it.
+		slog.Warn("invalid message envelope, persisting it unparsed", "agent_id", a.agentID, "type", msgType, "error", err)
+		a.persistUnparsedMessage(content, msgType, source)
 		return
 	}
 
 	// Extract agent context metadata from top-level assistant and result
 	// messages. Subagent messages (with parent_tool_use_id) have their own
 	// smaller context and would make the bar show a misleadingly low value.
+	// Their usage is attributed to the sub-agent run instead.
+	usage := a.messageUsage(&env, msgType)
 	if (msgType == claudeMsgTypeAssistant || msgType == claudeMsgTypeResult) && env.ParentToolUseID == "" {
 		a.extractAndBroadcastUsage(&env, msgType)
+	} else if msgType == claudeMsgTypeAssistant && usage != nil {
+		a.recordSubAgentUsage(env.ParentToolUseID, usage)
 	}
 
 	// Determine parent span ID for hierarchy tracking.
@@ -434,10 +482,15 @@ func (a *ClaudeCodeAgent) handlePersistableMessage(content []byte, msgType strin
 	// and tool name, for tool_result messages use the tool_use_id reference
 	// and look up the tool name from the span tracker.
 	var spanID, spanType string
+	var toolCall *ToolCall
 	if msgType == claudeMsgTypeAssistant {
 		for _, block := range env.ContentBlocks() {
 			if block.Type == "tool_use" && block.ID != "" {
 				spanID, spanType = block.ID, block.Name
+				toolCall = &ToolCall{Name: block.Name}
+				if block.Name == ToolNameBash {
+					toolCall.Command = toolCallCommand(block.Input)
+				}
 				break
 			}
 		}
@@ -486,6 +539,8 @@ func (a *ClaudeCodeAgent) handlePersistableMessage(content []byte, msgType strin
 		SpanColor:    spanColor,
 		Closing:      closing,
 		MarkType:     markType,
+		Usage:        usage,
+		ToolCall:     toolCall,
 	}
 	var persistErr error
 	if msgType == claudeMsgTypeResult {
@@ -516,6 +571,7 @@ func (a *ClaudeCodeAgent) handlePersistableMessage(content []byte, msgType strin
 	if msgType == claudeMsgTypeUser {
 		for _, block := range env.ContentBlocks() {
 			if block.Type == "tool_result" && block.ToolUseID != "" {
+				a.endSubAgentRun(block.ToolUseID, block.IsError)
 				a.sink.CloseSpan(block.ToolUseID)
 			}
 		}
@@ -525,6 +581,7 @@ func (a *ClaudeCodeAgent) handlePersistableMessage(content []byte, msgType strin
 		scheduleOrCancelAPIErrorAutoContinue(a.sink, env.IsError && isRetryableClaudeResultError(env.Result), content)
 
 		// Reset all span tracking so the next turn starts clean.
+		a.interruptSubAgentRuns()
 		a.sink.ResetSpans()
 	}
 }
@@ -601,7 +658,8 @@ func (a *ClaudeCodeAgent) claudeCodeHandleSystemInit(content []byte) {
 	a.sink.BroadcastStatusActive(initMsg.SessionID)
 }
 
-// claudeCodeHandleControlRequest persists and broadcasts a control_request.
+// claudeCodeHandleControlRequest persists and broadcasts a control_request,
+// unless the sink diverts it.
 func (a *ClaudeCodeAgent) claudeCodeHandleControlRequest(content []byte) {
 	var cr struct {
 		RequestID string `json:"request_id"`
@@ -610,6 +668,9 @@ func (a *ClaudeCodeAgent) claudeCodeHandleControlRequest(content []byte) {
 		slog.Warn("invalid control_request JSON", "agent_id", a.agentID, "error", err)
 		return
 	}
+	if a.sink.DivertControlRequest(cr.RequestID, content) {
+		return
+	}
 	claimToken := a.sink.PersistControlRequest(cr.RequestID, content)
 	a.sink.BroadcastControlRequest(cr.RequestID, content, claimToken)
 }
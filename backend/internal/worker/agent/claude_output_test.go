@@ -122,6 +122,7 @@ func TestHandleOutput_AssistantToolUse(t *testing.T) {
 	assert.Equal(t, "parent-123", msg.ParentSpanID)
 	assert.Equal(t, "tu-001", msg.SpanID)
 	assert.Equal(t, "Read", msg.SpanType)
+	assert.Equal(t, &ToolCall{Name: "Read"}, msg.ToolCall, "only Bash carries a command")
 
 	// processAssistantBlocks should have opened a span.
 	spans := sink.OpenedSpans()
@@ -156,6 +157,7 @@ func TestHandleOutput_AssistantToolUse_FallbackParentSpanID(t *testing.T) {
 	assert.Equal(t, "sys-tu-999", msgs[0].ParentSpanID)
 	assert.Equal(t, "tu-002", msgs[0].SpanID)
 	assert.Equal(t, "Bash", msgs[0].SpanType)
+	assert.Equal(t, &ToolCall{Name: ToolNameBash, Command: "ls"}, msgs[0].ToolCall)
 }
 
 func TestHandleOutput_UserToolResult(t *testing.T) {
@@ -1569,3 +1571,25 @@ func TestContextUsageSnapshot_BuildBroadcast(t *testing.T) {
 		assert.True(t, ok, "a result message always broadcasts, even mid-debounce")
 	})
 }
+
+// divertingSink answers every control request itself.
+type divertingSink struct {
+	recordingControlSink
+	diverted []string
+}
+
+func (s *divertingSink) DivertControlRequest(requestID string, _ []byte) bool {
+	s.diverted = append(s.diverted, requestID)
+	return true
+}
+
+func TestHandleOutput_DivertedControlRequestIsNotPersisted(t *testing.T) {
+	sink := &divertingSink{}
+	a := newTestAgent(sink)
+
+	a.HandleOutput([]byte(`{"type":"control_request","request_id":"req-1","request":{"subtype":"can_use_tool","tool_name":"Bash","input":{"command":"npm run dev"}}}`))
+
+	assert.Equal(t, []string{"req-1"}, sink.diverted)
+	assert.Zero(t, sink.PersistedControlCount())
+	assert.Zero(t, sink.BroadcastControlCount())
+}
@@ -0,0 +1,416 @@
+package agent
+
+import (
+	"bytes"
+	"encoding/json"
+	"log/slog"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// ClaudeOutputSchemaVersion versions claudeEnvelopeSchemas. Bump it with
+// any change to the schemas, so a finding logged by one worker build can be
+// told apart from another's.
+const ClaudeOutputSchemaVersion = 1
+
+// OutputSchemaMode says how loudly HandleOutput reports a Claude Code
+// envelope that does not match claudeEnvelopeSchemas. Neither mode drops
+// the envelope: it is handled as far as its fields allow either way.
+type OutputSchemaMode string
+
+const (
+	// OutputSchemaLenient logs each distinct finding once: an unknown
+	// field at info, a missing or mistyped one at warn. The default, and
+	// what the empty mode means.
+	OutputSchemaLenient OutputSchemaMode = "lenient"
+	// OutputSchemaStrict logs each distinct finding once at error and
+	// also posts it into the agent's chat as an agent_error, for catching
+	// a Claude Code format change on a canary worker before it ships.
+	OutputSchemaStrict OutputSchemaMode = "strict"
+)
+
+// jsonKind is the JSON type a schema field must have.
+type jsonKind uint8
+
+const (
+	kindAny jsonKind = iota
+	kindString
+	kindNumber
+	kindBool
+	kindObject
+	kindArray
+	// kindStringOrArray is message.content: a plain string or a block list.
+	kindStringOrArray
+)
+
+func (k jsonKind) String() string {
+	switch k {
+	case kindString:
+		return "string"
+	case kindNumber:
+		return "number"
+	case kindBool:
+		return "bool"
+	case kindObject:
+		return "object"
+	case kindArray:
+		return "array"
+	case kindStringOrArray:
+		return "string or array"
+	}
+	return "any"
+}
+
+// kindOf classifies raw by its first byte; it is only called on values
+// json.Unmarshal already accepted.
+func kindOf(raw json.RawMessage) jsonKind {
+	switch raw[0] {
+	case '"':
+		return kindString
+	case '{':
+		return kindObject
+	case '[':
+		return kindArray
+	case 't', 'f':
+		return kindBool
+	case 'n':
+		return kindAny
+	}
+	return kindNumber
+}
+
+func (k jsonKind) accepts(got jsonKind) bool {
+	switch k {
+	case kindAny:
+		return true
+	case kindStringOrArray:
+		return got == kindString || got == kindArray
+	}
+	// null stands in for an absent value of any kind.
+	return got == k || got == kindAny
+}
+
+// schemaField is one field of an envelope: the kind HandleOutput relies on,
+// whether it must be present, and the fields of an object value when
+// HandleOutput reads into it.
+type schemaField struct {
+	kind     jsonKind
+	required bool
+	fields   envelopeSchema
+}
+
+// envelopeSchema maps a JSON object's keys to their fields. A key absent
+// from it is unknown: not an error, but new upstream output worth a look.
+type envelopeSchema map[string]schemaField
+
+func req(k jsonKind) schemaField { return schemaField{kind: k, required: true} }
+func opt(k jsonKind) schemaField { return schemaField{kind: k} }
+
+// claudeUsageSchema is claudeUsage's wire shape.
+var claudeUsageSchema = envelopeSchema{
+	"input_tokens":                opt(kindNumber),
+	"output_tokens":               opt(kindNumber),
+	"cache_creation_input_tokens": opt(kindNumber),
+	"cache_read_input_tokens":     opt(kindNumber),
+	"cache_creation":              opt(kindObject),
+	"server_tool_use":             opt(kindObject),
+	"service_tier":                opt(kindString),
+}
+
+// claudeMessageSchema is the Anthropic API message an assistant or user
+// envelope wraps.
+var claudeMessageSchema = envelopeSchema{
+	"id":                 opt(kindString),
+	"type":               opt(kindString),
+	"role":               opt(kindString),
+	"model":              opt(kindString),
+	"content":            req(kindStringOrArray),
+	"stop_reason":        opt(kindString),
+	"stop_sequence":      opt(kindString),
+	"usage":              {kind: kindObject, fields: claudeUsageSchema},
+	"container":          opt(kindObject),
+	"context_management": opt(kindObject),
+}
+
+// claudeEnvelopeSchemas describes each Claude Code NDJSON envelope type
+// HandleOutput handles: the fields it reads, with the kinds it reads them
+// as, and the other fields Claude Code is known to send. Envelope types
+// LeapMux synthesizes itself (context_cleared and the like) are not listed;
+// validateClaudeEnvelope passes them.
+var claudeEnvelopeSchemas = map[string]envelopeSchema{
+	claudeMsgTypeAssistant: {
+		"type":               req(kindString),
+		"message":            {kind: kindObject, required: true, fields: claudeMessageSchema},
+		"parent_tool_use_id": opt(kindString),
+		"session_id":         opt(kindString),
+		"uuid":               opt(kindString),
+		"error":              opt(kindString),
+	},
+	claudeMsgTypeUser: {
+		"type":               req(kindString),
+		"message":            {kind: kindObject, required: true, fields: claudeMessageSchema},
+		"parent_tool_use_id": opt(kindString),
+		"tool_use_id":        opt(kindString),
+		"tool_use_result":    opt(kindAny),
+		"session_id":         opt(kindString),
+		"uuid":               opt(kindString),
+		"timestamp":          opt(kindString),
+		"isSynthetic":        opt(kindBool),
+		"isReplay":           opt(kindBool),
+	},
+	claudeMsgTypeSystem: {
+		"type":       req(kindString),
+		"subtype":    req(kindString),
+		"session_id": opt(kindString),
+		"uuid":       opt(kindString),
+		// init
+		"cwd":                 opt(kindString),
+		"model":               opt(kindString),
+		"permissionMode":      opt(kindString),
+		"tools":               opt(kindArray),
+		"mcp_servers":         opt(kindArray),
+		"slash_commands":      opt(kindArray),
+		"agents":              opt(kindArray),
+		"skills":              opt(kindArray),
+		"plugins":             opt(kindArray),
+		"betas":               opt(kindArray),
+		"apiKeySource":        opt(kindString),
+		"output_style":        opt(kindString),
+		"claude_code_version": opt(kindString),
+		"fast_mode_state":     opt(kindString),
+		// status, compact_boundary, thinking_tokens, api_retry, hooks
+		"status":           opt(kindString),
+		"compact_metadata": opt(kindObject),
+		"estimated_tokens": opt(kindNumber),
+		"attempt":          opt(kindNumber),
+		"max_retries":      opt(kindNumber),
+		"retry_delay_ms":   opt(kindNumber),
+		"error_status":     opt(kindNumber),
+		"error":            opt(kindAny),
+		"hook_id":          opt(kindString),
+		"hook_name":        opt(kindString),
+		"hook_event":       opt(kindString),
+		"stdout":           opt(kindString),
+		"stderr":           opt(kindString),
+		"exit_code":        opt(kindNumber),
+		"outcome":          opt(kindString),
+		"output":           opt(kindString),
+	},
+	claudeMsgTypeResult: {
+		"type":               req(kindString),
+		"subtype":            req(kindString),
+		"is_error":           req(kindBool),
+		"result":             opt(kindString),
+		"usage":              {kind: kindObject, fields: claudeUsageSchema},
+		"total_cost_usd":     opt(kindNumber),
+		"modelUsage":         opt(kindObject),
+		"session_id":         opt(kindString),
+		"uuid":               opt(kindString),
+		"duration_ms":        opt(kindNumber),
+		"duration_api_ms":    opt(kindNumber),
+		"num_turns":          opt(kindNumber),
+		"stop_reason":        opt(kindString),
+		"permission_denials": opt(kindArray),
+		"errors":             opt(kindArray),
+		"structured_output":  opt(kindAny),
+	},
+	claudeMsgTypeControlRequest: {
+		"type":       req(kindString),
+		"request_id": req(kindString),
+		"request":    req(kindObject),
+	},
+	claudeMsgTypeControlCancelRequest: {
+		"type":       req(kindString),
+		"request_id": req(kindString),
+	},
+	claudeMsgTypeControlResponse: {
+		"type": req(kindString),
+		"response": {kind: kindObject, required: true, fields: envelopeSchema{
+			"subtype":                     req(kindString),
+			"request_id":                  req(kindString),
+			"response":                    opt(kindObject),
+			"error":                       opt(kindString),
+			"pending_permission_requests": opt(kindArray),
+		}},
+	},
+	NotificationTypeRateLimitEvent: {
+		"type":            req(kindString),
+		"rate_limit_info": req(kindObject),
+		"session_id":      opt(kindString),
+		"uuid":            opt(kindString),
+	},
+}
+
+// schemaFinding is one way an envelope strays from its schema.
+type schemaFinding struct {
+	// Problem is "unknown_type", "unknown_field", "missing_field" or
+	// "wrong_kind".
+	Problem string
+	// Path names the field from the envelope's root, e.g.
+	// "assistant.message.usage"; for unknown_type it is the type.
+	Path string
+	// Detail is the expected and actual kinds, for wrong_kind.
+	Detail string
+}
+
+func (f schemaFinding) String() string {
+	if f.Detail != "" {
+		return f.Problem + " " + f.Path + " (" + f.Detail + ")"
+	}
+	return f.Problem + " " + f.Path
+}
+
+// violation reports whether f is output HandleOutput cannot handle fully: an
+// envelope type it does not know, or a field it reads gone missing or
+// changed shape. An unknown field is merely new.
+func (f schemaFinding) violation() bool {
+	return f.Problem != "unknown_field"
+}
+
+// validateClaudeEnvelope checks one NDJSON line of type msgType against
+// claudeEnvelopeSchemas, returning its findings sorted by path.
+func validateClaudeEnvelope(msgType string, content []byte) []schemaFinding {
+	schema, ok := claudeEnvelopeSchemas[msgType]
+	if !ok {
+		if isLeapMuxEnvelopeType(msgType) {
+			return nil
+		}
+		return []schemaFinding{{Problem: "unknown_type", Path: msgType}}
+	}
+	var findings []schemaFinding
+	validateObject(msgType, schema, json.RawMessage(content), &findings)
+	sort.Slice(findings, func(i, j int) bool { return findings[i].Path < findings[j].Path })
+	return findings
+}
+
+// isLeapMuxEnvelopeType reports whether msgType is one LeapMux writes into
+// a Claude session itself rather than one Claude Code emits.
+func isLeapMuxEnvelopeType(msgType string) bool {
+	switch msgType {
+	case NotificationTypeContextCleared, NotificationTypeInterrupted, NotificationTypePlanExecution:
+		return true
+	}
+	return false
+}
+
+func validateObject(path string, schema envelopeSchema, raw json.RawMessage, findings *[]schemaFinding) {
+	var obj map[string]json.RawMessage
+	if err := json.Unmarshal(raw, &obj); err != nil {
+		*findings = append(*findings, schemaFinding{Problem: "wrong_kind", Path: path, Detail: "want object"})
+		return
+	}
+	for key, field := range schema {
+		val, ok := obj[key]
+		if !ok {
+			if field.required {
+				*findings = append(*findings, schemaFinding{Problem: "missing_field", Path: path + "." + key})
+			}
+			continue
+		}
+		val = bytes.TrimSpace(val)
+		got := kindOf(val)
+		if !field.kind.accepts(got) {
+			*findings = append(*findings, schemaFinding{
+				Problem: "wrong_kind",
+				Path:    path + "." + key,
+				Detail:  "want " + field.kind.String() + ", got " + got.String(),
+			})
+			continue
+		}
+		if field.fields != nil && got == kindObject {
+			validateObject(path+"."+key, field.fields, val, findings)
+		}
+	}
+	for key := range obj {
+		if _, ok := schema[key]; !ok {
+			*findings = append(*findings, schemaFinding{Problem: "unknown_field", Path: path + "." + key})
+		}
+	}
+}
+
+// maxReportedSchemaFindings bounds the findings an agent remembers having
+// reported, since the paths come from upstream output.
+const maxReportedSchemaFindings = 256
+
+// claudeSchemaChecker validates a Claude agent's output envelopes and
+// reports each distinct finding once. The zero value is lenient.
+type claudeSchemaChecker struct {
+	mode OutputSchemaMode
+
+	mu         sync.Mutex
+	reported   map[string]bool
+	cliVersion string // claude_code_version from system/init, for the logs
+}
+
+// check validates one envelope and reports its new findings. It returns
+// the findings reported for the first time, for the caller to surface in
+// strict mode.
+func (c *claudeSchemaChecker) check(agentID, msgType string, content []byte) []schemaFinding {
+	findings := validateClaudeEnvelope(msgType, content)
+	if msgType == claudeMsgTypeSystem {
+		c.noteCLIVersion(content)
+	}
+	if len(findings) == 0 {
+		return nil
+	}
+
+	c.mu.Lock()
+	if c.reported == nil {
+		c.reported = map[string]bool{}
+	}
+	var fresh []schemaFinding
+	for _, f := range findings {
+		key := f.String()
+		if c.reported[key] || len(c.reported) >= maxReportedSchemaFindings {
+			continue
+		}
+		c.reported[key] = true
+		fresh = append(fresh, f)
+	}
+	cliVersion := c.cliVersion
+	c.mu.Unlock()
+
+	for _, f := range fresh {
+		attrs := []any{
+			"agent_id", agentID, "problem", f.Problem, "path", f.Path,
+			"schema_version", ClaudeOutputSchemaVersion, "claude_code_version", cliVersion,
+		}
+		if f.Detail != "" {
+			attrs = append(attrs, "detail", f.Detail)
+		}
+		switch {
+		case c.mode == OutputSchemaStrict:
+			slog.Error("claude output does not match its schema", attrs...)
+		case f.violation():
+			slog.Warn("claude output does not match its schema", attrs...)
+		default:
+			slog.Info("claude output has a field the schema does not know", attrs...)
+		}
+	}
+	if c.mode != OutputSchemaStrict {
+		return nil
+	}
+	return fresh
+}
+
+func (c *claudeSchemaChecker) noteCLIVersion(content []byte) {
+	var init struct {
+		Version string `json:"claude_code_version"`
+	}
+	if json.Unmarshal(content, &init) != nil || init.Version == "" {
+		return
+	}
+	c.mu.Lock()
+	c.cliVersion = init.Version
+	c.mu.Unlock()
+}
+
+// describeSchemaFindings joins findings for an agent_error's error.
+func describeSchemaFindings(findings []schemaFinding) string {
+	parts := make([]string, len(findings))
+	for i, f := range findings {
+		parts[i] = f.String()
+	}
+	return strings.Join(parts, "; ")
+}
@@ -0,0 +1,129 @@
+package agent
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	leapmuxv1 "github.com/leapmux/leapmux/generated/proto/leapmux/v1"
+)
+
+// TestClaudeEnvelopeSchemas_Corpus runs the captured Claude Code output in
+// testdata/claude_output through the schemas. A new upstream format lands
+// here as a fixture first: every line must validate cleanly, and every
+// schema'd envelope type must be covered by at least one line.
+func TestClaudeEnvelopeSchemas_Corpus(t *testing.T) {
+	files, err := filepath.Glob(filepath.Join("testdata", "claude_output", "*.ndjson"))
+	require.NoError(t, err)
+	require.NotEmpty(t, files)
+
+	seen := map[string]bool{}
+	for _, file := range files {
+		data, err := os.ReadFile(file)
+		require.NoError(t, err)
+		scanner := bufio.NewScanner(bytes.NewReader(data))
+		scanner.Buffer(nil, 1<<20)
+		for n := 1; scanner.Scan(); n++ {
+			line := scanner.Bytes()
+			var env struct {
+				Type string `json:"type"`
+			}
+			require.NoError(t, json.Unmarshal(line, &env), "%s:%d", file, n)
+			seen[env.Type] = true
+			assert.Empty(t, validateClaudeEnvelope(env.Type, line), "%s:%d", file, n)
+		}
+		require.NoError(t, scanner.Err())
+	}
+	for msgType := range claudeEnvelopeSchemas {
+		assert.True(t, seen[msgType], "no %s line in the corpus", msgType)
+	}
+}
+
+func TestValidateClaudeEnvelope(t *testing.T) {
+	tests := []struct {
+		name    string
+		msgType string
+		content string
+		want    []schemaFinding
+	}{
+		{
+			name:    "wrong kind",
+			msgType: claudeMsgTypeResult,
+			content: `{"type":"result","subtype":"success","is_error":false,"total_cost_usd":"0.5"}`,
+			want:    []schemaFinding{{Problem: "wrong_kind", Path: "result.total_cost_usd", Detail: "want number, got string"}},
+		},
+		{
+			name:    "missing field",
+			msgType: claudeMsgTypeAssistant,
+			content: `{"type":"assistant","session_id":"s"}`,
+			want:    []schemaFinding{{Problem: "missing_field", Path: "assistant.message"}},
+		},
+		{
+			name:    "nested field",
+			msgType: claudeMsgTypeAssistant,
+			content: `{"type":"assistant","message":{"content":[],"usage":{"input_tokens":"3","reasoning_tokens":1}}}`,
+			want: []schemaFinding{
+				{Problem: "wrong_kind", Path: "assistant.message.usage.input_tokens", Detail: "want number, got string"},
+				{Problem: "unknown_field", Path: "assistant.message.usage.reasoning_tokens"},
+			},
+		},
+		{
+			name:    "null stands in for absent",
+			msgType: claudeMsgTypeAssistant,
+			content: `{"type":"assistant","message":{"content":"hi","stop_reason":null},"parent_tool_use_id":null}`,
+		},
+		{
+			name:    "unknown type",
+			msgType: "tool_progress",
+			content: `{"type":"tool_progress"}`,
+			want:    []schemaFinding{{Problem: "unknown_type", Path: "tool_progress"}},
+		},
+		{
+			name:    "leapmux type",
+			msgType: NotificationTypeContextCleared,
+			content: `{"type":"context_cleared"}`,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.want, validateClaudeEnvelope(tt.msgType, []byte(tt.content)))
+		})
+	}
+}
+
+func TestClaudeSchemaChecker_ReportsOnce(t *testing.T) {
+	drift := []byte(`{"type":"result","subtype":"success","is_error":false,"total_cost_usd":"0.5"}`)
+
+	lenient := &claudeSchemaChecker{}
+	assert.Empty(t, lenient.check("a", claudeMsgTypeResult, drift), "lenient only logs")
+
+	strict := &claudeSchemaChecker{mode: OutputSchemaStrict}
+	assert.Len(t, strict.check("a", claudeMsgTypeResult, drift), 1)
+	assert.Empty(t, strict.check("a", claudeMsgTypeResult, drift), "a finding is reported once")
+}
+
+func TestHandleOutput_StrictSchemaMismatch(t *testing.T) {
+	sink := &recordingControlSink{}
+	agent := newTestAgent(sink)
+	agent.outputSchema.mode = OutputSchemaStrict
+
+	agent.HandleOutput([]byte(`{"type":"assistant","message":"hi"}`))
+
+	notifications := sink.Notifications()
+	require.Len(t, notifications, 1)
+	assert.Equal(t, NotificationTypeAgentError, notifications[0]["type"])
+	assert.Equal(t, AgentErrorOutputSchemaMismatch, notifications[0]["code"])
+	assert.Equal(t, "wrong_kind assistant.message (want object, got string)", notifications[0]["error"])
+
+	// The envelope does not decode, but it is kept rather than dropped.
+	msgs := sink.Messages()
+	require.Len(t, msgs, 1)
+	assert.Equal(t, leapmuxv1.MessageSource_MESSAGE_SOURCE_AGENT, msgs[0].Source)
+	assert.JSONEq(t, `{"type":"assistant","message":"hi"}`, string(msgs[0].Content))
+}
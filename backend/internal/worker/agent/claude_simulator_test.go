@@ -0,0 +1,110 @@
+//go:build unix
+
+package agent
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	leapmuxv1 "github.com/leapmux/leapmux/generated/proto/leapmux/v1"
+	"github.com/leapmux/leapmux/internal/util/testutil"
+	"github.com/leapmux/leapmux/internal/worker/claudesim"
+)
+
+// installClaudeSimulator puts a "claude" on PATH that runs the claudesim
+// package -- the runtime `leapmux worker --simulate` installs -- inside
+// this test binary.
+func installClaudeSimulator(t *testing.T) {
+	t.Helper()
+
+	dir := t.TempDir()
+	launcher := filepath.Join(dir, "claude")
+	script := fmt.Sprintf("#!/bin/sh\nexec %q -test.run=TestHelperProcessClaudeSim -- \"$@\"\n", os.Args[0])
+	require.NoError(t, os.WriteFile(launcher, []byte(script), 0o755))
+
+	t.Setenv("PATH", dir+string(os.PathListSeparator)+os.Getenv("PATH"))
+	t.Setenv("GO_WANT_HELPER_PROCESS_CLAUDESIM", "1")
+}
+
+func TestHelperProcessClaudeSim(t *testing.T) {
+	if os.Getenv("GO_WANT_HELPER_PROCESS_CLAUDESIM") != "1" {
+		return
+	}
+	var args []string
+	for i, arg := range os.Args {
+		if arg == "--" {
+			args = os.Args[i+1:]
+			break
+		}
+	}
+	_ = claudesim.Run(context.Background(), os.Stdin, os.Stdout, claudesim.Options{Args: args, Pace: time.Millisecond})
+	os.Exit(0)
+}
+
+// The simulator has to satisfy the real startup handshake, or --simulate
+// would fail every agent start.
+func TestStartClaudeCode_AgainstSimulator(t *testing.T) {
+	installClaudeSimulator(t)
+
+	sink := &recordingControlSink{}
+	agent, err := StartClaudeCode(context.Background(), Options{
+		AgentID:         "claude-sim",
+		WorkingDir:      t.TempDir(),
+		HomeDir:         t.TempDir(),
+		Shell:           testutil.TestShell(),
+		LoginShell:      false,
+		AgentProvider:   leapmuxv1.AgentProvider_AGENT_PROVIDER_CLAUDE_CODE,
+		ResumeSessionID: "sim-session",
+	}, sink)
+	require.NoError(t, err)
+	t.Cleanup(func() {
+		agent.Stop()
+		_ = agent.Wait()
+	})
+
+	assert.Equal(t, PermissionModeDefault, agent.confirmedPermissionMode)
+	require.NotNil(t, FindAvailableModel(agent.availableModels, "haiku"), "the simulated catalog reaches the picker")
+
+	require.NoError(t, agent.SendInput("run some bash", nil))
+	require.Eventually(t, func() bool { return sink.BroadcastControlCount() > 0 }, 10*time.Second, 10*time.Millisecond)
+	assert.Contains(t, string(sink.LastBroadcastControl().Payload), `"can_use_tool"`)
+	sink.mu.Lock()
+	defer sink.mu.Unlock()
+	assert.Contains(t, sink.sessionIDs, "sim-session")
+}
+
+func TestStartClaudeCode_CapturesRawOutput(t *testing.T) {
+	installClaudeSimulator(t)
+	captureDir := t.TempDir()
+
+	agent, err := StartClaudeCode(context.Background(), Options{
+		AgentID:       "claude-capture",
+		WorkingDir:    t.TempDir(),
+		HomeDir:       t.TempDir(),
+		Shell:         testutil.TestShell(),
+		LoginShell:    false,
+		AgentProvider: leapmuxv1.AgentProvider_AGENT_PROVIDER_CLAUDE_CODE,
+		CaptureDir:    captureDir,
+	}, &testSink{})
+	require.NoError(t, err)
+	agent.Stop()
+	_ = agent.Wait()
+
+	files, err := filepath.Glob(filepath.Join(captureDir, "claude-capture-claude-*.ndjson"))
+	require.NoError(t, err)
+	require.Len(t, files, 1)
+	info, err := os.Stat(files[0])
+	require.NoError(t, err)
+	assert.Equal(t, os.FileMode(0o600), info.Mode().Perm())
+	raw, err := os.ReadFile(files[0])
+	require.NoError(t, err)
+	assert.Contains(t, string(raw), `"subtype":"init"`)
+	assert.Contains(t, string(raw), `"type":"control_response"`, "lines the handshake consumes are captured too")
+}
@@ -0,0 +1,123 @@
+package agent
+
+import (
+	"encoding/json"
+	"log/slog"
+)
+
+// Claude Code launches a sub-agent through a tool_use of one of these tools
+// (Task on older CLIs, Agent on newer ones). Every message the sub-agent
+// produces carries that tool_use's id as its parent_tool_use_id, and the
+// matching tool_result ends the run.
+const (
+	ToolNameTask  = "Task"
+	ToolNameAgent = "Agent"
+)
+
+// isSubAgentTool reports whether a tool_use of name starts a sub-agent.
+func isSubAgentTool(name string) bool {
+	return name == ToolNameTask || name == ToolNameAgent
+}
+
+// Sub-agent run end states reported through OutputSink.EndSubAgentRun.
+const (
+	SubAgentRunCompleted   = "completed"
+	SubAgentRunFailed      = "failed"
+	SubAgentRunInterrupted = "interrupted"
+)
+
+// SessionInfoKeySubAgentUsage is the context_usage key under which the
+// session's cumulative sub-agent token usage is broadcast, kept apart from
+// the primary agent's per-call usage so the context bar never mixes them.
+const SessionInfoKeySubAgentUsage = "subagent_usage"
+
+// SubAgentUsage is the token usage attributed to sub-agents.
+type SubAgentUsage struct {
+	InputTokens              int64
+	OutputTokens             int64
+	CacheCreationInputTokens int64
+	CacheReadInputTokens     int64
+}
+
+// IsZero reports whether u records no tokens.
+func (u SubAgentUsage) IsZero() bool {
+	return u == SubAgentUsage{}
+}
+
+// Add accumulates other into u.
+func (u *SubAgentUsage) Add(other SubAgentUsage) {
+	u.InputTokens += other.InputTokens
+	u.OutputTokens += other.OutputTokens
+	u.CacheCreationInputTokens += other.CacheCreationInputTokens
+	u.CacheReadInputTokens += other.CacheReadInputTokens
+}
+
+func (u SubAgentUsage) broadcastMap() map[string]interface{} {
+	return map[string]interface{}{
+		"input_tokens":                u.InputTokens,
+		"output_tokens":               u.OutputTokens,
+		"cache_creation_input_tokens": u.CacheCreationInputTokens,
+		"cache_read_input_tokens":     u.CacheReadInputTokens,
+	}
+}
+
+// startSubAgentRun opens a run for a sub-agent tool_use block. parentToolUseID
+// is set when the launching agent is itself a sub-agent.
+func (a *ClaudeCodeAgent) startSubAgentRun(block contentBlock, parentToolUseID string) {
+	var input struct {
+		Description  string `json:"description"`
+		SubagentType string `json:"subagent_type"`
+	}
+	if len(block.Input) > 0 {
+		if err := json.Unmarshal(block.Input, &input); err != nil {
+			slog.Warn("claude sub-agent input unmarshal failed", "agent_id", a.agentID, "tool_use_id", block.ID, "error", err)
+		}
+	}
+	if a.openSubAgentRuns == nil {
+		a.openSubAgentRuns = make(map[string]struct{})
+	}
+	a.openSubAgentRuns[block.ID] = struct{}{}
+	a.sink.StartSubAgentRun(block.ID, parentToolUseID, input.SubagentType, input.Description)
+}
+
+// recordSubAgentUsage attributes the usage of an assistant message emitted
+// inside a sub-agent to its run. usage has already been deduplicated by
+// messageUsage, so each API call is counted once.
+func (a *ClaudeCodeAgent) recordSubAgentUsage(parentToolUseID string, u *MessageUsage) {
+	usage := SubAgentUsage{
+		InputTokens:              u.InputTokens,
+		OutputTokens:             u.OutputTokens,
+		CacheCreationInputTokens: u.CacheCreationInputTokens,
+		CacheReadInputTokens:     u.CacheReadInputTokens,
+	}
+	snapshot := a.getOrCreateUsageSnapshot()
+	snapshot.mu.Lock()
+	snapshot.SubAgent.Add(usage)
+	snapshot.mu.Unlock()
+	a.sink.AddSubAgentUsage(parentToolUseID, usage)
+}
+
+// endSubAgentRun closes the run a sub-agent tool_result answers. Results for
+// any other tool are ignored.
+func (a *ClaudeCodeAgent) endSubAgentRun(toolUseID string, isError bool) {
+	if _, ok := a.openSubAgentRuns[toolUseID]; !ok {
+		return
+	}
+	delete(a.openSubAgentRuns, toolUseID)
+	delete(a.lastUsageMessageID, toolUseID)
+	status := SubAgentRunCompleted
+	if isError {
+		status = SubAgentRunFailed
+	}
+	a.sink.EndSubAgentRun(toolUseID, status)
+}
+
+// interruptSubAgentRuns closes every run still open when the parent turn
+// ends: its sub-agents can no longer report back.
+func (a *ClaudeCodeAgent) interruptSubAgentRuns() {
+	for toolUseID := range a.openSubAgentRuns {
+		a.sink.EndSubAgentRun(toolUseID, SubAgentRunInterrupted)
+	}
+	clear(a.openSubAgentRuns)
+	clear(a.lastUsageMessageID)
+}
@@ -0,0 +1,48 @@
+package agent
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestClaudeSubAgentRuns_Lifecycle(t *testing.T) {
+	sink := &testSink{}
+	a := newTestAgent(sink)
+
+	a.HandleOutput([]byte(`{"type":"assistant","message":{"id":"m-1","content":[
+		{"type":"tool_use","id":"task-1","name":"Task","input":{"description":"scan","subagent_type":"explorer"}},
+		{"type":"tool_use","id":"task-2","name":"Agent","input":{"description":"lint"}}
+	]}}`))
+	// Two lines of the same sub-agent message repeat its usage; count it once.
+	sub := `{"type":"assistant","parent_tool_use_id":"task-1","message":{"id":"m-2","content":[{"type":"text","text":"x"}],"usage":{"input_tokens":10,"output_tokens":7}}}`
+	a.HandleOutput([]byte(sub))
+	a.HandleOutput([]byte(sub))
+	a.HandleOutput([]byte(`{"type":"user","message":{"content":[{"type":"tool_result","tool_use_id":"task-1","content":"done"}]}}`))
+	a.HandleOutput([]byte(`{"type":"result","subtype":"success"}`))
+
+	assert.Equal(t, []string{
+		"start:task-1::explorer",
+		"start:task-2::",
+		"usage:task-1:7",
+		"end:task-1:" + SubAgentRunCompleted,
+		"end:task-2:" + SubAgentRunInterrupted,
+	}, sink.subAgentEvents)
+}
+
+func TestClaudeSubAgentRuns_UsageKeptOutOfContextUsage(t *testing.T) {
+	sink := &testSink{}
+	a := newTestAgent(sink)
+
+	a.HandleOutput([]byte(`{"type":"assistant","message":{"content":[{"type":"tool_use","id":"task-1","name":"Task","input":{}}],"usage":{"input_tokens":100,"output_tokens":1}}}`))
+	a.HandleOutput([]byte(`{"type":"assistant","parent_tool_use_id":"task-1","message":{"id":"m-2","content":[],"usage":{"input_tokens":40,"output_tokens":5}}}`))
+	a.HandleOutput([]byte(`{"type":"result","subtype":"success"}`))
+
+	snapshot := a.getOrCreateUsageSnapshot()
+	assert.Equal(t, int64(100), snapshot.InputTokens)
+	assert.Equal(t, SubAgentUsage{InputTokens: 40, OutputTokens: 5}, snapshot.SubAgent)
+
+	last := sink.sessionInfos[len(sink.sessionInfos)-1]
+	usage := last["context_usage"].(map[string]interface{})
+	assert.Equal(t, SubAgentUsage{InputTokens: 40, OutputTokens: 5}.broadcastMap(), usage[SessionInfoKeySubAgentUsage])
+}
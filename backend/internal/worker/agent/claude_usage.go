@@ -0,0 +1,67 @@
+package agent
+
+// messageUsage returns the usage to persist beside the row env is about to
+// become, or nil when the row accounts for none.
+//
+// Claude Code emits one line per content block of an assistant message,
+// each repeating the message's usage, so only the first line of each
+// message id (per enclosing sub-agent) carries it. A top-level result
+// carries the turn's summed tokens, which the turn's top-level messages
+// are marked as repeating, and the turn's cost: total_cost_usd is
+// cumulative over the CLI process, so the cost is the increase since the
+// previous result. A total below the last one means the process restarted
+// and the count began again from zero.
+func (a *ClaudeCodeAgent) messageUsage(env *messageEnvelope, msgType string) *MessageUsage {
+	switch msgType {
+	case claudeMsgTypeAssistant:
+		u := env.Message.Usage
+		if u == nil {
+			return nil
+		}
+		if env.Message.ID != "" {
+			if a.lastUsageMessageID == nil {
+				a.lastUsageMessageID = make(map[string]string)
+			}
+			if a.lastUsageMessageID[env.ParentToolUseID] == env.Message.ID {
+				return nil
+			}
+			a.lastUsageMessageID[env.ParentToolUseID] = env.Message.ID
+		}
+		usage := u.messageUsage()
+		usage.InTurn = env.ParentToolUseID == "" && !usage.IsZero()
+		return usage
+
+	case claudeMsgTypeResult:
+		if env.ParentToolUseID != "" {
+			return nil
+		}
+		usage := &MessageUsage{}
+		if env.Usage != nil {
+			usage = env.Usage.messageUsage()
+		}
+		if env.CostUSD != nil {
+			total := *env.CostUSD
+			if total >= a.lastResultCostUSD {
+				usage.CostUSD = total - a.lastResultCostUSD
+			} else {
+				usage.CostUSD = total
+			}
+			a.lastResultCostUSD = total
+		}
+		if usage.IsZero() {
+			return nil
+		}
+		usage.Turn = true
+		return usage
+	}
+	return nil
+}
+
+func (u *claudeUsage) messageUsage() *MessageUsage {
+	return &MessageUsage{
+		InputTokens:              u.InputTokens,
+		OutputTokens:             u.OutputTokens,
+		CacheCreationInputTokens: u.CacheCreationInputTokens,
+		CacheReadInputTokens:     u.CacheReadInputTokens,
+	}
+}
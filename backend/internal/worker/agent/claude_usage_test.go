@@ -0,0 +1,39 @@
+package agent
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestClaudeMessageUsage_AttributedPerMessageAndTurn(t *testing.T) {
+	sink := &testSink{}
+	a := newTestAgent(sink)
+
+	// Both lines of message m-1 repeat its usage; only the first carries it.
+	line := `{"type":"assistant","message":{"id":"m-1","content":[{"type":"text","text":"x"}],"usage":{"input_tokens":10,"output_tokens":4,"cache_read_input_tokens":100}}}`
+	a.HandleOutput([]byte(line))
+	a.HandleOutput([]byte(line))
+	a.HandleOutput([]byte(`{"type":"result","subtype":"success","total_cost_usd":0.5,"usage":{"input_tokens":10,"output_tokens":4}}`))
+	// total_cost_usd is cumulative: the second turn cost the difference.
+	a.HandleOutput([]byte(`{"type":"result","subtype":"success","total_cost_usd":1.25}`))
+
+	require.Len(t, sink.messages, 4)
+	assert.Equal(t, &MessageUsage{InputTokens: 10, OutputTokens: 4, CacheReadInputTokens: 100, InTurn: true}, sink.messages[0].Usage,
+		"the turn total repeats a top-level message's usage")
+	assert.Nil(t, sink.messages[1].Usage)
+	assert.Equal(t, &MessageUsage{InputTokens: 10, OutputTokens: 4, CostUSD: 0.5, Turn: true}, sink.messages[2].Usage)
+	assert.Equal(t, &MessageUsage{CostUSD: 0.75, Turn: true}, sink.messages[3].Usage)
+}
+
+func TestClaudeMessageUsage_CostCounterRestart(t *testing.T) {
+	sink := &testSink{}
+	a := newTestAgent(sink)
+	a.lastResultCostUSD = 2
+
+	a.HandleOutput([]byte(`{"type":"result","subtype":"success","total_cost_usd":0.3}`))
+
+	require.Len(t, sink.messages, 1)
+	assert.Equal(t, &MessageUsage{CostUSD: 0.3, Turn: true}, sink.messages[0].Usage)
+}
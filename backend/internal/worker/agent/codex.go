@@ -144,7 +144,7 @@ func StartCodex(ctx context.Context, opts Options, sink OutputSink) (Agent, erro
 
 	// Read stdout JSONL in background.
 	scanner := newStdoutScanner(stdout)
-	go a.readOutputLoop(scanner, a.handleOutput)
+	go a.readOutputLoop(scanner, a.sink, a.handleOutput)
 
 	cleanup := func() {
 		a.Stop()
@@ -328,8 +328,12 @@ func (a *CodexAgent) handleItemStarted(raw []byte, params json.RawMessage) {
 		spanColor := a.sink.ReserveSpanColor(itemID, parentSpanID)
 		// Persist first at parent depth, then open span so the
 		// completed message is indented under the started message.
+		toolCall := &ToolCall{Name: itemType}
+		if itemType == "commandExecution" {
+			toolCall.Command = toolCallCommand(item)
+		}
 		if err := a.sink.PersistMessage(leapmuxv1.MessageSource_MESSAGE_SOURCE_AGENT, params, SpanInfo{
-			ParentSpanID: parentSpanID, SpanID: itemID, SpanType: itemType, SpanColor: spanColor,
+			ParentSpanID: parentSpanID, SpanID: itemID, SpanType: itemType, SpanColor: spanColor, ToolCall: toolCall,
 		}); err != nil {
 			slog.Error("codex persist item/started", "agent_id", a.agentID, "type", itemType, "error", err)
 		}
@@ -508,7 +512,7 @@ func (a *CodexAgent) handleTurnCompleted(params json.RawMessage) {
 		if turnStatus == "completed" && collaborationMode == CodexCollaborationPlan && sawPlan && planText != "" {
 			// Persist plan content so initiatePlanExecution can use it.
 			compressed, compression := msgcodec.Compress([]byte(planText))
-			a.sink.UpdatePlan(compressed, compression, extractPlanTitle(planText))
+			a.sink.UpdatePlan(compressed, compression, ExtractPlanTitle(planText))
 			requestID := fmt.Sprintf("codex-plan-prompt-%s", turnID)
 			payload, err := json.Marshal(map[string]interface{}{
 				"type":       "control_request",
@@ -627,10 +631,9 @@ func (a *CodexAgent) handleErrorNotification(params json.RawMessage) {
 		Message string `json:"message"`
 	}
 	if json.Unmarshal(params, &notif) == nil && notif.Message != "" {
-		a.sink.PersistLeapMuxNotification(map[string]interface{}{
-			"type":  NotificationTypeAgentError,
-			"error": notif.Message,
-		})
+		a.sink.PersistLeapMuxNotification(NotificationContent(AgentErrorPayload{
+			Error: notif.Message,
+		}))
 	}
 }
 
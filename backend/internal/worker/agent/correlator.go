@@ -2,11 +2,16 @@ package agent
 
 import (
 	"encoding/json"
+	"errors"
 	"fmt"
 	"sync"
 	"time"
 )
 
+// errResponseTimeout is wrapped by the error awaitResponse returns when no
+// response arrives within the timeout.
+var errResponseTimeout = errors.New("timeout")
+
 // responseCorrelator routes raw response bytes back to pending callers
 // keyed by id. Generic over the id type so JSON-RPC 2.0 (int64) and Pi
 // (opaque string) share the same plumbing without converging on a single
@@ -74,6 +79,6 @@ func (p *processBase) awaitResponse(
 	case <-p.ctx.Done():
 		return nil, p.ctx.Err()
 	case <-timer.C:
-		return nil, fmt.Errorf("timeout waiting for %s response", label)
+		return nil, fmt.Errorf("%w waiting for %s response", errResponseTimeout, label)
 	}
 }
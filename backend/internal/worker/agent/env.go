@@ -44,6 +44,12 @@ var agentIdentityEnvScrubKeys = []string{
 	"PI_CODING_AGENT",
 }
 
+// modelCredentialEnvKeys are the provider login variables an agent given a
+// model credential must not inherit: Claude Code prefers an API key over a
+// subscription token, so a worker's own key would outrank the credential's
+// token.
+var modelCredentialEnvKeys = []string{"ANTHROPIC_API_KEY", "ANTHROPIC_AUTH_TOKEN", "CLAUDE_CODE_OAUTH_TOKEN"}
+
 // FinalizeAgentEnv applies the env-mutations every spawned agent
 // process needs in one place: strips inherited agent-harness identity
 // vars (see agentIdentityEnvScrubKeys) so a worker launched from inside
@@ -52,7 +58,8 @@ var agentIdentityEnvScrubKeys = []string{
 // worker's session never inherits the parent's remote context (any
 // fresh values arrive via opts.ExtraEnv), appends the `LEAPMUX_WORKER=1`
 // marker (downstream CLI/agent code keys off it to detect "running
-// inside a LeapMux worker"), and appends `opts.ExtraEnv`.
+// inside a LeapMux worker"), and appends `opts.Env`, `opts.ExtraEnv` and
+// `opts.CredentialEnv`, the last in place of any inherited login.
 //
 // Provider-specific env additions (CLAUDE_CODE_ENTRYPOINT, CODEX_CI,
 // etc.) go BEFORE this call so they survive both the identity scrub and
@@ -63,8 +70,10 @@ func FinalizeAgentEnv(env []string, opts Options) []string {
 	env = envutil.FilterEnv(env, agentIdentityEnvScrubKeys...)
 	env = envutil.StripByPrefix(env, "LEAPMUX_REMOTE_")
 	env = append(env, "LEAPMUX_WORKER=1")
-	if len(opts.ExtraEnv) == 0 {
+	env = append(env, opts.Env...)
+	env = append(env, opts.ExtraEnv...)
+	if len(opts.CredentialEnv) == 0 {
 		return env
 	}
-	return append(env, opts.ExtraEnv...)
+	return append(envutil.FilterEnv(env, modelCredentialEnvKeys...), opts.CredentialEnv...)
 }
@@ -110,11 +110,31 @@ type Options struct {
 	LoginShell     bool                    // If true, use interactive+login shell flags
 	HomeDir        string                  // User's home directory (for reading Claude Code settings)
 	AgentProvider  leapmuxv1.AgentProvider // Coding agent provider (default: CLAUDE_CODE)
+	// Env is the user-set environment (NAME=value): the org's, the
+	// workspace's and the agent's own variables, already merged. It goes
+	// in ahead of ExtraEnv and CredentialEnv, which win on a clash.
+	Env []string
 	// ExtraEnv is appended verbatim to the spawned process's
 	// environment after the provider-specific env-var setup. The
 	// service.Service populates this with LEAPMUX_REMOTE_* so the
 	// running agent can drive the worker via the leapmux remote CLI.
 	ExtraEnv []string
+	// CredentialEnv runs the agent under an org model credential (see
+	// Provider.ModelCredentialEnv). The login variables it replaces are
+	// not inherited from the worker.
+	CredentialEnv []string
+	// CaptureDir, when set, receives a copy of the process's raw stdout,
+	// one file per process (see openOutputCapture).
+	CaptureDir string
+	// OutputSchemaMode says how a Claude Code agent reports output that
+	// strays from its envelope schema (see claude_schema.go).
+	OutputSchemaMode OutputSchemaMode
+	// SystemPrompt is appended to the provider's own system prompt. Only
+	// providers whose SupportsSystemPrompt is true honor it.
+	SystemPrompt string
+	// OutputProcessors see each output line, in order, before the
+	// provider handles it (see OutputProcessor).
+	OutputProcessors []OutputProcessor
 }
 
 // Get returns the resolved value of an option-group id, or "" if absent. The
@@ -88,6 +88,27 @@ func TestFinalizeAgentEnv_ScrubsAgentIdentity(t *testing.T) {
 			assert.Truef(t, envutil.HasKey(out, k), "var %q must survive the scrub", k)
 		}
 	})
+
+	t.Run("a model credential replaces the inherited login", func(t *testing.T) {
+		env := append(buildEnv(), "ANTHROPIC_API_KEY=worker-key")
+		out := FinalizeAgentEnv(env, Options{CredentialEnv: []string{"CLAUDE_CODE_OAUTH_TOKEN=org-token"}})
+
+		assert.False(t, envutil.HasKey(out, "ANTHROPIC_API_KEY"), "the worker's key would outrank the credential's token")
+		assert.Contains(t, out, "CLAUDE_CODE_OAUTH_TOKEN=org-token")
+		assert.NotContains(t, out, "CLAUDE_CODE_OAUTH_TOKEN=tok")
+		assert.Contains(t, out, "LEAPMUX_WORKER=1")
+	})
+
+	t.Run("user env lands after the inherited env and before ExtraEnv", func(t *testing.T) {
+		out := FinalizeAgentEnv([]string{"PATH=/usr/bin:/bin"}, Options{
+			Env:      []string{"PATH=/opt/bin", "NODE_ENV=test"},
+			ExtraEnv: []string{"LEAPMUX_REMOTE_NEW=fresh"},
+		})
+
+		assert.Equal(t, []string{
+			"PATH=/usr/bin:/bin", "LEAPMUX_WORKER=1", "PATH=/opt/bin", "NODE_ENV=test", "LEAPMUX_REMOTE_NEW=fresh",
+		}, out, "exec keeps the last duplicate, so the user's PATH wins")
+	})
 }
 
 func TestAvailableOptionGroups_DefaultOptionMetadata(t *testing.T) {
@@ -254,6 +254,21 @@ func (m *Manager) SendRawInput(agentID string, data []byte) error {
 	return p.SendRawInput(data)
 }
 
+// HandleOutput feeds content to the specified agent's output handler as
+// though its process had printed it, for replaying a dead letter.
+func (m *Manager) HandleOutput(agentID string, content []byte) error {
+	m.mu.RLock()
+	p, ok := m.agents[agentID]
+	m.mu.RUnlock()
+
+	if !ok {
+		return fmt.Errorf("%w: %s", ErrAgentNotFound, agentID)
+	}
+
+	p.HandleOutput(content)
+	return nil
+}
+
 // Interrupt aborts the agent's current turn using the provider-specific
 // signal. Returns ErrAgentNotFound when the agent isn't running.
 func (m *Manager) Interrupt(agentID string) error {
@@ -0,0 +1,257 @@
+package agent
+
+import (
+	"bytes"
+	"encoding/json"
+	"log/slog"
+)
+
+// Typed payloads for the LEAPMUX notifications the worker synthesizes. Each
+// struct mirrors the proto message of the same name in
+// proto/leapmux/v1/notification.proto, its json tags spelling the message's
+// field names, so the wire keys a client reads are declared once and checked
+// by a test rather than retyped as map keys at every emit site. Payloads
+// carry ids, enums and numbers, never display text: the client words them.
+
+// Notification is a typed LEAPMUX notification payload.
+type Notification interface {
+	NotificationType() string
+}
+
+// NotificationContent renders n as the content map PersistLeapMuxNotification
+// takes, with its `type` set.
+func NotificationContent(n Notification) map[string]any {
+	content := map[string]any{}
+	if data, err := json.Marshal(n); err != nil {
+		slog.Warn("marshal notification payload", "type", n.NotificationType(), "error", err)
+	} else {
+		// UseNumber keeps 64-bit counts exact on their way back out.
+		dec := json.NewDecoder(bytes.NewReader(data))
+		dec.UseNumber()
+		if err := dec.Decode(&content); err != nil {
+			slog.Warn("decode notification payload", "type", n.NotificationType(), "error", err)
+		}
+	}
+	content["type"] = n.NotificationType()
+	return content
+}
+
+// Agent error codes: what an agent_error notification reports as failing.
+const (
+	AgentErrorSettingsRestartFailed      = "settings_restart_failed"
+	AgentErrorClearContextRestartFailed  = "clear_context_restart_failed"
+	AgentErrorPlanExecutionRestartFailed = "plan_execution_restart_failed"
+	AgentErrorCompactFailed              = "compact_failed"
+	AgentErrorPromptFailed               = "prompt_failed"
+	AgentErrorOutputSchemaMismatch       = "output_schema_mismatch"
+)
+
+// AgentErrorPayload is an agent_error notification. Code is empty when the
+// provider reported the failure itself, and Error is then its whole message.
+type AgentErrorPayload struct {
+	Code  string `json:"code,omitempty"`
+	Error string `json:"error"`
+}
+
+func (AgentErrorPayload) NotificationType() string { return NotificationTypeAgentError }
+
+// SettingsChangedPayload is a settings_changed notification. Each value in
+// Changes is a SettingChange, or just its old and new ids when the labels
+// could not be resolved.
+type SettingsChangedPayload struct {
+	Changes map[string]any `json:"changes"`
+}
+
+func (SettingsChangedPayload) NotificationType() string { return NotificationTypeSettingsChanged }
+
+// SettingChange is one option's change in a settings_changed notification.
+// The labels are always written, so the client knows an empty one was
+// meant rather than missing.
+type SettingChange struct {
+	Old      string `json:"old"`
+	New      string `json:"new"`
+	OldLabel string `json:"oldLabel"`
+	NewLabel string `json:"newLabel"`
+	Label    string `json:"label"`
+}
+
+// ContextClearedPayload is a context_cleared notification.
+type ContextClearedPayload struct{}
+
+func (ContextClearedPayload) NotificationType() string { return NotificationTypeContextCleared }
+
+// InterruptedPayload is an interrupted notification.
+type InterruptedPayload struct{}
+
+func (InterruptedPayload) NotificationType() string { return NotificationTypeInterrupted }
+
+// PlanExecutionPayload is a plan_execution notification.
+type PlanExecutionPayload struct {
+	PlanFilePath string `json:"plan_file_path"`
+}
+
+func (PlanExecutionPayload) NotificationType() string { return NotificationTypePlanExecution }
+
+// PlanUpdatedPayload is a plan_updated notification.
+type PlanUpdatedPayload struct {
+	PlanTitle        string `json:"plan_title"`
+	PlanFilePath     string `json:"plan_file_path"`
+	UpdateAgentTitle bool   `json:"update_agent_title,omitempty"`
+}
+
+func (PlanUpdatedPayload) NotificationType() string { return NotificationTypePlanUpdated }
+
+// RetryScheduledPayload is a retry_scheduled notification.
+type RetryScheduledPayload struct {
+	Reason        string `json:"reason"`
+	Attempt       int64  `json:"attempt"`
+	MaxAttempts   int64  `json:"max_attempts"`
+	DueAt         string `json:"due_at"`
+	FallbackModel string `json:"fallback_model,omitempty"`
+}
+
+func (RetryScheduledPayload) NotificationType() string { return NotificationTypeRetryScheduled }
+
+// RetryExhaustedPayload is a retry_exhausted notification.
+type RetryExhaustedPayload struct {
+	Reason   string `json:"reason"`
+	Attempts int64  `json:"attempts"`
+}
+
+func (RetryExhaustedPayload) NotificationType() string { return NotificationTypeRetryExhausted }
+
+// PermissionModeBlockedPayload is a permission_mode_blocked notification.
+type PermissionModeBlockedPayload struct {
+	Mode    string `json:"mode"`
+	Applied string `json:"applied,omitempty"`
+	Rule    string `json:"rule,omitempty"`
+	Message string `json:"message,omitempty"`
+}
+
+func (PermissionModeBlockedPayload) NotificationType() string {
+	return NotificationTypePermissionModeBlocked
+}
+
+// DiskSpaceLowPayload is a disk_space_low notification.
+type DiskSpaceLowPayload struct {
+	Scope      string `json:"scope"`
+	FreeBytes  int64  `json:"free_bytes,omitempty"`
+	UsedBytes  int64  `json:"used_bytes,omitempty"`
+	LimitBytes int64  `json:"limit_bytes,omitempty"`
+}
+
+func (DiskSpaceLowPayload) NotificationType() string { return NotificationTypeDiskSpaceLow }
+
+// AgentStatusPayload is an agent_status notification.
+type AgentStatusPayload struct {
+	Status          string `json:"status"`
+	AgentSessionID  string `json:"agent_session_id"`
+	Model           string `json:"model"`
+	Effort          string `json:"effort"`
+	PermissionMode  string `json:"permission_mode"`
+	ContextTokens   int64  `json:"context_tokens"`
+	ContextWindow   int64  `json:"context_window"`
+	WorkerName      string `json:"worker_name"`
+	Hostname        string `json:"hostname"`
+	WorkingDir      string `json:"working_dir"`
+	GitBranch       string `json:"git_branch"`
+	ModelCredential string `json:"model_credential"`
+}
+
+func (AgentStatusPayload) NotificationType() string { return NotificationTypeAgentStatus }
+
+// ContextCompactionPayload is a context_compaction notification.
+type ContextCompactionPayload struct {
+	Phase         string `json:"phase"`
+	Method        string `json:"method"`
+	BeforeTokens  int64  `json:"before_tokens"`
+	ContextWindow int64  `json:"context_window"`
+	AfterTokens   int64  `json:"after_tokens,omitempty"`
+}
+
+func (ContextCompactionPayload) NotificationType() string { return NotificationTypeContextCompaction }
+
+// ContextPressurePayload is a context_pressure notification.
+type ContextPressurePayload struct {
+	Level         string `json:"level"`
+	ContextTokens int64  `json:"context_tokens"`
+	ContextWindow int64  `json:"context_window"`
+	Percent       int64  `json:"percent"`
+	Action        string `json:"action,omitempty"`
+}
+
+func (ContextPressurePayload) NotificationType() string { return NotificationTypeContextPressure }
+
+// TurnHeldPayload is a turn_held notification.
+type TurnHeldPayload struct {
+	Reason   string `json:"reason"`
+	Priority string `json:"priority"`
+	Until    string `json:"until"`
+}
+
+func (TurnHeldPayload) NotificationType() string { return NotificationTypeTurnHeld }
+
+// AgentAnomalyPayload is an agent_anomaly notification.
+type AgentAnomalyPayload struct {
+	Kind    string  `json:"kind"`
+	Count   float64 `json:"count"`
+	Limit   float64 `json:"limit"`
+	Command string  `json:"command,omitempty"`
+}
+
+func (AgentAnomalyPayload) NotificationType() string { return NotificationTypeAgentAnomaly }
+
+// EmergencyStopPayload is an emergency_stop notification.
+type EmergencyStopPayload struct {
+	Reason string `json:"reason"`
+}
+
+func (EmergencyStopPayload) NotificationType() string { return NotificationTypeEmergencyStop }
+
+// ToolUseDecidedPayload is a tool_use_decided notification.
+type ToolUseDecidedPayload struct {
+	Tool    string `json:"tool"`
+	Effect  string `json:"effect"`
+	Rule    string `json:"rule"`
+	Message string `json:"message,omitempty"`
+}
+
+func (ToolUseDecidedPayload) NotificationType() string { return NotificationTypeToolUseDecided }
+
+// SecretsRedactedPayload is a secrets_redacted notification.
+type SecretsRedactedPayload struct {
+	Count     int      `json:"count"`
+	Detectors []string `json:"detectors"`
+}
+
+func (SecretsRedactedPayload) NotificationType() string { return NotificationTypeSecretsRedacted }
+
+// TurnStalledPayload is a turn_stalled notification.
+type TurnStalledPayload struct {
+	Action        string `json:"action"`
+	SilentSeconds int64  `json:"silent_seconds"`
+	Attempt       int64  `json:"attempt,omitempty"`
+}
+
+func (TurnStalledPayload) NotificationType() string { return NotificationTypeTurnStalled }
+
+// LimitExceededPayload is a limit_exceeded notification.
+type LimitExceededPayload struct {
+	Limit          string  `json:"limit"`
+	Threshold      float64 `json:"threshold"`
+	ElapsedSeconds int64   `json:"elapsed_seconds"`
+	CostUSD        float64 `json:"cost_usd"`
+	InputTokens    int64   `json:"input_tokens"`
+	OutputTokens   int64   `json:"output_tokens"`
+	Interrupted    bool    `json:"interrupted"`
+}
+
+func (LimitExceededPayload) NotificationType() string { return NotificationTypeLimitExceeded }
+
+// AgentInterruptedPayload is an agent_interrupted notification.
+type AgentInterruptedPayload struct {
+	Reason string `json:"reason"`
+	Detail string `json:"detail,omitempty"`
+}
+
+func (AgentInterruptedPayload) NotificationType() string { return NotificationTypeAgentInterrupted }
@@ -0,0 +1,80 @@
+package agent
+
+import (
+	"encoding/json"
+	"maps"
+	"reflect"
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/protobuf/reflect/protoreflect"
+
+	leapmuxv1 "github.com/leapmux/leapmux/generated/proto/leapmux/v1"
+)
+
+// TestNotificationPayloads_MatchProto holds each payload struct to its
+// message in notification.proto: the same keys, no more and no fewer.
+func TestNotificationPayloads_MatchProto(t *testing.T) {
+	payloads := []any{
+		AgentErrorPayload{},
+		SettingsChangedPayload{},
+		SettingChange{},
+		ContextClearedPayload{},
+		InterruptedPayload{},
+		PlanExecutionPayload{},
+		PlanUpdatedPayload{},
+		RetryScheduledPayload{},
+		RetryExhaustedPayload{},
+		PermissionModeBlockedPayload{},
+		DiskSpaceLowPayload{},
+		AgentStatusPayload{},
+		ContextCompactionPayload{},
+		ContextPressurePayload{},
+		TurnHeldPayload{},
+		AgentAnomalyPayload{},
+		EmergencyStopPayload{},
+		ToolUseDecidedPayload{},
+		SecretsRedactedPayload{},
+		TurnStalledPayload{},
+		LimitExceededPayload{},
+		AgentInterruptedPayload{},
+	}
+	messages := leapmuxv1.File_leapmux_v1_notification_proto.Messages()
+	for _, p := range payloads {
+		typ := reflect.TypeOf(p)
+		t.Run(typ.Name(), func(t *testing.T) {
+			md := messages.ByName(protoreflect.Name(typ.Name()))
+			require.NotNil(t, md, "no %s message in notification.proto", typ.Name())
+
+			keys := map[string]bool{}
+			for f := range typ.Fields() {
+				keys[strings.Split(f.Tag.Get("json"), ",")[0]] = true
+			}
+			var fields []string
+			for i := range md.Fields().Len() {
+				fd := md.Fields().Get(i)
+				name := string(fd.Name())
+				if !keys[name] {
+					name = fd.JSONName() // SettingChange's camelCase labels
+				}
+				fields = append(fields, name)
+			}
+			assert.ElementsMatch(t, fields, slices.Collect(maps.Keys(keys)))
+		})
+	}
+}
+
+func TestNotificationContent(t *testing.T) {
+	content := NotificationContent(DiskSpaceLowPayload{Scope: "disk", FreeBytes: 1 << 62})
+	assert.Equal(t, NotificationTypeDiskSpaceLow, content["type"])
+	assert.NotContains(t, content, "used_bytes", "an omitted key stays out")
+
+	data, err := json.Marshal(content)
+	require.NoError(t, err)
+	assert.JSONEq(t, `{"type":"disk_space_low","scope":"disk","free_bytes":4611686018427387904}`, string(data))
+
+	assert.Equal(t, map[string]any{"type": NotificationTypeContextCleared}, NotificationContent(ContextClearedPayload{}))
+}
@@ -5,16 +5,20 @@ package agent
 // source for worker-synthesized events; AGENT source for agent-emitted
 // metadata that flows through the same renderer). Centralizing the
 // strings turns rename mistakes into compile errors and gives the
-// dispatch switches a single source of truth.
+// dispatch switches a single source of truth. The payloads of the
+// worker-synthesized ones are the messages in
+// proto/leapmux/v1/notification.proto, built from the structs in
+// notification_payloads.go.
 const (
 	// NotificationTypeAgentError is a worker-emitted agent failure (startup
-	// crash, restart failure, settings-apply failure). Carries an `error`
-	// string with the user-facing reason.
+	// crash, restart failure, settings-apply failure). Carries a `code`
+	// naming what failed (empty for a provider-reported failure) and the
+	// underlying `error`.
 	NotificationTypeAgentError = "agent_error"
 
 	// NotificationTypeSettingsChanged is emitted when the user updates the
 	// agent's model / effort / permission mode / options. Carries
-	// a `changes` map of {key: {old, new}} entries.
+	// a `changes` map of {key: {old, new, oldLabel, newLabel, label}} entries.
 	NotificationTypeSettingsChanged = "settings_changed"
 
 	// NotificationTypeContextCleared is emitted when the agent's context is
@@ -27,7 +31,7 @@ const (
 	NotificationTypeInterrupted = "interrupted"
 
 	// NotificationTypePlanExecution is emitted when the worker initiates
-	// plan-mode execution. Carries plan metadata (file path, title).
+	// plan-mode execution. Carries the `plan_file_path`.
 	NotificationTypePlanExecution = "plan_execution"
 
 	// NotificationTypePlanUpdated is emitted when the active plan file
@@ -49,4 +53,94 @@ const (
 	// route into the rate-limit popover.
 	NotificationTypeRateLimit      = "rate_limit"
 	NotificationTypeRateLimitEvent = "rate_limit_event"
+
+	// NotificationTypeRetryScheduled is emitted each time the worker arms an
+	// auto-continue retry under the workspace retry policy. Carries the
+	// `reason`, the 1-based `attempt`, `max_attempts` (0 = unlimited), the
+	// `due_at` instant, and `fallback_model` when this retry follows a
+	// policy-driven model switch.
+	NotificationTypeRetryScheduled = "retry_scheduled"
+
+	// NotificationTypeRetryExhausted is emitted when a failure exceeds the
+	// policy's attempt budget and no fallback model is left to try, so the
+	// worker stops retrying. Carries `reason` and `attempts`.
+	NotificationTypeRetryExhausted = "retry_exhausted"
+
+	// NotificationTypePermissionModeBlocked is emitted when the worker's
+	// permission guardrails, or the org's access policy, refuse a mode.
+	// Carries `mode` (the refused one) and, when another mode was applied
+	// in its place, `applied`. A refusal by the access policy adds the
+	// `rule` and its `message`.
+	NotificationTypePermissionModeBlocked = "permission_mode_blocked"
+
+	// NotificationTypeDiskSpaceLow is emitted when the worker's disk falls
+	// below its free-space floor or a disk quota nears its limit. Carries
+	// `scope` ("disk", "worker", or "workspace"); "disk" adds `free_bytes`,
+	// the others `used_bytes` and `limit_bytes`.
+	NotificationTypeDiskSpaceLow = "disk_space_low"
+
+	// NotificationTypeAgentStatus is the /status command's report. Carries
+	// the AgentRuntimeInfo fields under their proto names.
+	NotificationTypeAgentStatus = "agent_status"
+
+	// NotificationTypeContextCompaction is emitted when a context
+	// compaction starts (/compact, CompactAgentContext, or the context
+	// pressure policy) and again when it finishes. Carries `phase`
+	// ("started" or "finished"), `method` ("native" or "summary"),
+	// `before_tokens`, and `context_window`; "finished" adds `after_tokens`
+	// when the provider reported a new size.
+	NotificationTypeContextCompaction = "context_compaction"
+
+	// NotificationTypeContextPressure is emitted when an agent's context
+	// climbs past a configured share of its window. Carries `level`
+	// ("warning" or "critical"), `context_tokens`, `context_window`, and
+	// `percent`; `action` ("compact" or "checkpoint") names what runs once
+	// the turn ends.
+	NotificationTypeContextPressure = "context_pressure"
+
+	// NotificationTypeTurnHeld is emitted when a turn is held back because
+	// the agent's provider account is at or near its rate limit. Carries
+	// `reason` ("rate_limited" or "near_limit"), `priority` ("interactive"
+	// or "background"), and `until`, when the turn goes next.
+	NotificationTypeTurnHeld = "turn_held"
+
+	// NotificationTypeAgentAnomaly is emitted when a turn crosses one of
+	// the worker's anomaly limits. Carries `kind` ("tool_loop",
+	// "repeated_command", "file_deletions", or "turn_cost"), `count`, and
+	// `limit`; "repeated_command" adds the `command`.
+	NotificationTypeAgentAnomaly = "agent_anomaly"
+
+	// NotificationTypeEmergencyStop is emitted when an admin's emergency
+	// stop interrupts the agent mid-turn. Carries the stop's `reason`.
+	NotificationTypeEmergencyStop = "emergency_stop"
+
+	// NotificationTypeToolUseDecided is emitted when the org's access
+	// policy answers a tool permission request without asking the user.
+	// Carries the `tool`, the `effect` ("allow" or "deny"), the deciding
+	// `rule`, and the rule's `message` when it has one.
+	NotificationTypeToolUseDecided = "tool_use_decided"
+
+	// NotificationTypeSecretsRedacted is emitted when the worker replaced
+	// secrets in a line of agent output before storing it. Carries the
+	// `count` replaced and the `detectors` that found them.
+	NotificationTypeSecretsRedacted = "secrets_redacted"
+
+	// NotificationTypeTurnStalled is emitted when the worker's watchdog
+	// finds a turn that has produced no output for too long. Carries the
+	// `action` taken ("notify", "interrupt", or "restart") and
+	// `silent_seconds`; "restart" adds the 1-based `attempt`.
+	NotificationTypeTurnStalled = "turn_stalled"
+
+	// NotificationTypeLimitExceeded is emitted when a turn runs past one of
+	// its workspace's turn limits. Carries the `limit` ("duration" or
+	// "cost"), its `threshold`, the turn's `elapsed_seconds`, `cost_usd`,
+	// `input_tokens`, and `output_tokens` so far, and whether it was
+	// `interrupted`.
+	NotificationTypeLimitExceeded = "limit_exceeded"
+
+	// NotificationTypeAgentInterrupted is emitted when an InterruptAgent
+	// call stops a turn for something other than the user. Carries the
+	// `reason` ("watchdog", "budget", or "policy") and the caller's
+	// free-text `detail`, if any.
+	NotificationTypeAgentInterrupted = "agent_interrupted"
 )
@@ -0,0 +1,104 @@
+package agent
+
+import (
+	"bytes"
+	"encoding/json"
+	"log/slog"
+
+	leapmuxv1 "github.com/leapmux/leapmux/generated/proto/leapmux/v1"
+)
+
+// OutputLine is one line of agent stdout as an OutputProcessor sees it.
+// Type is set for providers whose lines carry a `type` discriminator
+// (Claude Code, Pi); Method for JSON-RPC notifications and requests, and
+// ID for JSON-RPC requests and responses.
+type OutputLine struct {
+	AgentID  string
+	Provider leapmuxv1.AgentProvider
+	Type     string
+	Method   string
+	ID       json.RawMessage
+	// Raw is the line itself. A processor may replace it, and later
+	// processors and the provider then see the replacement; one that does
+	// not parse as JSON is ignored.
+	Raw []byte
+}
+
+// IsRequest reports whether the line is a JSON-RPC request, which the
+// agent expects the worker to answer.
+func (l *OutputLine) IsRequest() bool {
+	return l.Method != "" && len(l.ID) > 0 && string(l.ID) != "null"
+}
+
+// OutputProcessor looks at each line an agent prints before the
+// provider's own handling does. It may persist or broadcast through the
+// sink, or rewrite the line, and returns true to consume the line so that
+// neither later processors nor the provider see it.
+//
+// Processors run on the agent's read goroutine, in Options order, so a
+// slow one holds up the agent's output. Replies to the worker's own
+// requests never reach them, and neither do lines fed through
+// HandleOutput (replay).
+type OutputProcessor interface {
+	ProcessOutput(line *OutputLine, sink OutputSink) (consumed bool)
+}
+
+// OutputProcessorFunc adapts a function to OutputProcessor.
+type OutputProcessorFunc func(line *OutputLine, sink OutputSink) bool
+
+func (f OutputProcessorFunc) ProcessOutput(line *OutputLine, sink OutputSink) bool {
+	return f(line, sink)
+}
+
+// runOutputProcessors passes line through the processor chain and
+// reports whether one consumed it. A processor that panics is logged and
+// skipped, so a broken extension cannot take the agent's output down
+// with it.
+func (p *processBase) runOutputProcessors(line *parsedLine, sink OutputSink) bool {
+	if len(p.outputProcessors) == 0 {
+		return false
+	}
+	out := &OutputLine{
+		AgentID:  p.agentID,
+		Provider: p.provider,
+		Type:     line.Type,
+		Method:   line.Method,
+		ID:       line.ID,
+		Raw:      line.Raw,
+	}
+	for i, proc := range p.outputProcessors {
+		raw := out.Raw
+		if runOutputProcessor(proc, out, sink, p.agentID, i) {
+			return true
+		}
+		if !bytes.Equal(raw, out.Raw) {
+			p.adoptRewrittenLine(line, out, raw, i)
+		}
+	}
+	return false
+}
+
+// adoptRewrittenLine re-parses the line a processor rewrote into line, so
+// the provider handles the rewrite. A rewrite that does not parse is
+// dropped in favor of prev.
+func (p *processBase) adoptRewrittenLine(line *parsedLine, out *OutputLine, prev []byte, index int) {
+	reparsed := &parsedLine{Raw: out.Raw}
+	if err := json.Unmarshal(out.Raw, reparsed); err != nil {
+		slog.Error("agent output processor rewrote a line into invalid JSON; ignoring the rewrite",
+			"agent_id", p.agentID, "processor", index, "error", err)
+		out.Raw = prev
+		return
+	}
+	*line = *reparsed
+	out.Type, out.Method, out.ID = line.Type, line.Method, line.ID
+}
+
+func runOutputProcessor(proc OutputProcessor, line *OutputLine, sink OutputSink, agentID string, index int) (consumed bool) {
+	defer func() {
+		if r := recover(); r != nil {
+			slog.Error("agent output processor panicked", "agent_id", agentID, "processor", index, "panic", r)
+			consumed = false
+		}
+	}()
+	return proc.ProcessOutput(line, sink)
+}
@@ -0,0 +1,69 @@
+package agent
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	leapmuxv1 "github.com/leapmux/leapmux/generated/proto/leapmux/v1"
+)
+
+func TestRunOutputProcessors(t *testing.T) {
+	var seen []string
+	record := func(name string, consume bool) OutputProcessor {
+		return OutputProcessorFunc(func(line *OutputLine, _ OutputSink) bool {
+			seen = append(seen, name+":"+line.Type)
+			assert.Equal(t, "agent-1", line.AgentID)
+			assert.Equal(t, leapmuxv1.AgentProvider_AGENT_PROVIDER_CLAUDE_CODE, line.Provider)
+			return consume && line.Type == "drop"
+		})
+	}
+	p := &processBase{
+		agentID:  "agent-1",
+		provider: leapmuxv1.AgentProvider_AGENT_PROVIDER_CLAUDE_CODE,
+		outputProcessors: []OutputProcessor{
+			record("first", false),
+			OutputProcessorFunc(func(*OutputLine, OutputSink) bool { panic("broken plugin") }),
+			record("second", true),
+			record("third", false),
+		},
+	}
+	sink := &testSink{}
+
+	assert.False(t, p.runOutputProcessors(&parsedLine{Type: "assistant", Raw: []byte(`{}`)}, sink))
+	assert.True(t, p.runOutputProcessors(&parsedLine{Type: "drop", Raw: []byte(`{}`)}, sink))
+	assert.Equal(t, []string{
+		"first:assistant", "second:assistant", "third:assistant",
+		"first:drop", "second:drop",
+	}, seen, "processors run in order, past a panic, until one consumes the line")
+
+	assert.False(t, (&processBase{}).runOutputProcessors(&parsedLine{Type: "assistant"}, sink))
+}
+
+func TestRunOutputProcessors_Rewrite(t *testing.T) {
+	rewrite := func(to string) OutputProcessor {
+		return OutputProcessorFunc(func(line *OutputLine, _ OutputSink) bool {
+			line.Raw = []byte(to)
+			return false
+		})
+	}
+	var seen []string
+	p := &processBase{
+		agentID: "agent-1",
+		outputProcessors: []OutputProcessor{
+			rewrite(`{"type":"user","message":"[REDACTED:test]"}`),
+			rewrite(`{not json`),
+			OutputProcessorFunc(func(line *OutputLine, _ OutputSink) bool {
+				seen = append(seen, line.Type+" "+string(line.Raw))
+				return false
+			}),
+		},
+	}
+	line := &parsedLine{Type: "assistant", Raw: []byte(`{"type":"assistant","message":"secret"}`)}
+
+	assert.False(t, p.runOutputProcessors(line, &testSink{}))
+	assert.Equal(t, []string{`user {"type":"user","message":"[REDACTED:test]"}`}, seen,
+		"later processors see the rewrite, and one that does not parse is ignored")
+	assert.Equal(t, "user", line.Type, "the provider handles the rewritten line")
+	assert.Equal(t, `{"type":"user","message":"[REDACTED:test]"}`, string(line.Raw))
+}
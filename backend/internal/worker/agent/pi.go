@@ -105,7 +105,7 @@ func StartPi(ctx context.Context, opts Options, sink OutputSink) (Agent, error)
 	a.drainStderr(stderrPipe)
 
 	scanner := newStdoutScanner(stdout)
-	go a.readOutput(scanner, a.handlePiResponse, a.handleOutput)
+	go a.readOutput(scanner, a.sink, a.handlePiResponse, a.handleOutput)
 
 	cleanup := func() {
 		a.Stop()
@@ -275,10 +275,10 @@ func (a *PiAgent) SendInput(content string, attachments []*leapmuxv1.Attachment)
 	go func() {
 		if _, err := a.sendPiCommand(PiCommandPrompt, payload, 0); err != nil {
 			slog.Error("pi prompt failed", "agent_id", a.agentID, "error", err)
-			a.sink.PersistLeapMuxNotification(map[string]any{
-				"type":  NotificationTypeAgentError,
-				"error": err.Error(),
-			})
+			a.sink.PersistLeapMuxNotification(NotificationContent(AgentErrorPayload{
+				Code:  AgentErrorPromptFailed,
+				Error: err.Error(),
+			}))
 		}
 	}()
 
@@ -23,8 +23,9 @@ type piMessageUpdateEnvelope struct {
 
 // piToolExecutionEnvelope captures `tool_execution_*` event headers.
 type piToolExecutionEnvelope struct {
-	ToolCallID string `json:"toolCallId"`
-	ToolName   string `json:"toolName"`
+	ToolCallID string          `json:"toolCallId"`
+	ToolName   string          `json:"toolName"`
+	Args       json.RawMessage `json:"args"`
 }
 
 // piToolUpdateEnvelope adds the partialResult content blocks consumed when
@@ -187,8 +188,8 @@ func isRetryablePiAgentEndFailure(raw []byte) bool {
 }
 
 func (a *PiAgent) handlePiMessageEnd(raw []byte) {
-	augmented := a.augmentPiMessageEnd(raw)
-	if err := a.sink.PersistMessage(leapmuxv1.MessageSource_MESSAGE_SOURCE_AGENT, augmented, SpanInfo{}); err != nil {
+	augmented, usage := a.augmentPiMessageEnd(raw)
+	if err := a.sink.PersistMessage(leapmuxv1.MessageSource_MESSAGE_SOURCE_AGENT, augmented, SpanInfo{Usage: usage}); err != nil {
 		slog.Error("pi persist message_end", "agent_id", a.agentID, "error", err)
 	}
 }
@@ -233,6 +234,7 @@ func (a *PiAgent) handlePiToolExecutionStart(raw []byte) {
 		SpanID:    env.ToolCallID,
 		SpanType:  env.ToolName,
 		SpanColor: spanColor,
+		ToolCall:  &ToolCall{Name: env.ToolName, Command: toolCallCommand(env.Args)},
 	}); err != nil {
 		slog.Error("pi persist tool_execution_start", "agent_id", a.agentID, "error", err)
 	}
@@ -256,6 +256,8 @@ func TestHandlePiOutput_ToolExecutionLifecycle(t *testing.T) {
 	assert.Equal(t, "call-1", msgs[0].SpanID)
 	assert.Equal(t, "bash", msgs[0].SpanType)
 	assert.False(t, msgs[0].Closing, "start should not be marked closing")
+	assert.Equal(t, &ToolCall{Name: "bash", Command: "ls"}, msgs[0].ToolCall)
+	assert.Nil(t, msgs[1].ToolCall, "only the start begins a tool call")
 	assert.Equal(t, "call-1", msgs[1].SpanID)
 	assert.True(t, msgs[1].Closing, "end should be marked closing")
 
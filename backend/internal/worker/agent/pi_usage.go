@@ -267,36 +267,48 @@ func (a *PiAgent) refreshPiSessionStats(timeout time.Duration) (piUsageSnapshot,
 // augmentPiMessageEnd parses an assistant message_end envelope once,
 // extracts the typed `message.usage` from the decoded map, records the
 // usage delta on the agent, and re-marshals the same map with the
-// broadcast-shaped fields injected.
-func (a *PiAgent) augmentPiMessageEnd(raw []byte) []byte {
+// broadcast-shaped fields injected. It also returns the message's own
+// usage for persisting beside its row, or nil when it carries none.
+func (a *PiAgent) augmentPiMessageEnd(raw []byte) ([]byte, *MessageUsage) {
 	var obj map[string]any
 	if err := json.Unmarshal(raw, &obj); err != nil || obj == nil {
-		return raw
+		return raw, nil
 	}
 	if t, _ := obj["type"].(string); t != PiEventMessageEnd {
-		return raw
+		return raw, nil
 	}
 	message, ok := obj["message"].(map[string]any)
 	if !ok {
-		return raw
+		return raw, nil
 	}
 	if role, _ := message["role"].(string); role != PiRoleAssistant {
-		return raw
+		return raw, nil
 	}
 	usageMap, ok := message["usage"].(map[string]any)
 	if !ok {
-		return raw
+		return raw, nil
 	}
 	// Re-encode just the usage submap and decode into the typed struct.
 	// Cheaper than a second full-envelope Unmarshal and avoids hand-rolled
 	// json.Number/float coercion helpers.
 	usageBytes, err := json.Marshal(usageMap)
 	if err != nil {
-		return raw
+		return raw, nil
 	}
 	var usage piAssistantUsage
 	if err := json.Unmarshal(usageBytes, &usage); err != nil {
-		return raw
+		return raw, nil
+	}
+
+	messageUsage := &MessageUsage{
+		InputTokens:              usage.Input,
+		OutputTokens:             usage.Output,
+		CacheCreationInputTokens: usage.CacheWrite,
+		CacheReadInputTokens:     usage.CacheRead,
+		CostUSD:                  usage.Cost.Total,
+	}
+	if messageUsage.IsZero() {
+		messageUsage = nil
 	}
 
 	contextUsage := piContextUsageFromAssistantUsage(usage, a.currentPiContextWindow())
@@ -305,14 +317,14 @@ func (a *PiAgent) augmentPiMessageEnd(raw []byte) []byte {
 		a.sink.BroadcastSessionInfo(info)
 	}
 	if !snap.HasTotalCost && len(snap.ContextUsage) == 0 {
-		return raw
+		return raw, messageUsage
 	}
 	mutatePiUsageFields(obj, snap)
 	augmented, err := json.Marshal(obj)
 	if err != nil {
-		return raw
+		return raw, messageUsage
 	}
-	return augmented
+	return augmented, messageUsage
 }
 
 func (a *PiAgent) persistPiAgentEnd(raw []byte, snap piUsageSnapshot) {
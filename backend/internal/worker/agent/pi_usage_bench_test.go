@@ -46,7 +46,7 @@ func BenchmarkAugmentPiMessageEnd(b *testing.B) {
 	b.ResetTimer()
 	b.ReportAllocs()
 	for i := 0; i < b.N; i++ {
-		_ = a.augmentPiMessageEnd(piMessageEndBenchPayload)
+		_, _ = a.augmentPiMessageEnd(piMessageEndBenchPayload)
 	}
 }
 
@@ -15,7 +15,7 @@ import (
 func TestAugmentPiMessageEnd_NoUsageReturnsRawUnchanged(t *testing.T) {
 	a := newPiAgentWithSink(&recordingControlSink{})
 	raw := []byte(`{"type":"message_end","message":{"role":"assistant","content":[{"type":"text","text":"hi"}]}}`)
-	out := a.augmentPiMessageEnd(raw)
+	out, _ := a.augmentPiMessageEnd(raw)
 	assert.Equal(t, string(raw), string(out))
 }
 
@@ -26,7 +26,7 @@ func TestAugmentPiMessageEnd_NoUsageReturnsRawUnchanged(t *testing.T) {
 func TestAugmentPiMessageEnd_NonAssistantRoleReturnsRawUnchanged(t *testing.T) {
 	a := newPiAgentWithSink(&recordingControlSink{})
 	raw := []byte(`{"type":"message_end","message":{"role":"user","content":[]}}`)
-	out := a.augmentPiMessageEnd(raw)
+	out, _ := a.augmentPiMessageEnd(raw)
 	assert.Equal(t, string(raw), string(out))
 }
 
@@ -36,7 +36,7 @@ func TestAugmentPiMessageEnd_NonAssistantRoleReturnsRawUnchanged(t *testing.T) {
 func TestAugmentPiMessageEnd_NonObjectMessageReturnsRawUnchanged(t *testing.T) {
 	a := newPiAgentWithSink(&recordingControlSink{})
 	raw := []byte(`{"type":"message_end","message":"not an object"}`)
-	out := a.augmentPiMessageEnd(raw)
+	out, _ := a.augmentPiMessageEnd(raw)
 	assert.Equal(t, string(raw), string(out))
 }
 
@@ -47,7 +47,7 @@ func TestAugmentPiMessageEnd_NonObjectMessageReturnsRawUnchanged(t *testing.T) {
 func TestAugmentPiMessageEnd_MalformedJSONReturnsRawUnchanged(t *testing.T) {
 	a := newPiAgentWithSink(&recordingControlSink{})
 	raw := []byte(`{"type":"message_end","message":`)
-	out := a.augmentPiMessageEnd(raw)
+	out, _ := a.augmentPiMessageEnd(raw)
 	assert.Equal(t, string(raw), string(out))
 }
 
@@ -59,8 +59,8 @@ func TestAugmentPiMessageEnd_TotalsAreCumulative(t *testing.T) {
 	a := newPiAgentWithSink(&recordingControlSink{})
 	a.model = "m1"
 	a.availableModels = []*ModelInfo{{Id: "m1", ContextWindow: 1000}}
-	first := a.augmentPiMessageEnd([]byte(`{"type":"message_end","message":{"role":"assistant","usage":{"input":100,"output":10,"cost":{"total":0.5}}}}`))
-	second := a.augmentPiMessageEnd([]byte(`{"type":"message_end","message":{"role":"assistant","usage":{"input":50,"output":5,"cost":{"total":0.25}}}}`))
+	first, _ := a.augmentPiMessageEnd([]byte(`{"type":"message_end","message":{"role":"assistant","usage":{"input":100,"output":10,"cost":{"total":0.5}}}}`))
+	second, _ := a.augmentPiMessageEnd([]byte(`{"type":"message_end","message":{"role":"assistant","usage":{"input":50,"output":5,"cost":{"total":0.25}}}}`))
 
 	var p1, p2 map[string]any
 	require.NoError(t, json.Unmarshal(first, &p1))
@@ -69,6 +69,18 @@ func TestAugmentPiMessageEnd_TotalsAreCumulative(t *testing.T) {
 	assert.InDelta(t, 0.75, p2["total_cost_usd"], 1e-9, "total_cost_usd must be cumulative across message_ends")
 }
 
+// TestAugmentPiMessageEnd_ReturnsMessageUsage verifies each message_end
+// reports its own tokens and cost for persisting beside its row, while the
+// broadcast totals stay cumulative.
+func TestAugmentPiMessageEnd_ReturnsMessageUsage(t *testing.T) {
+	a := newPiAgentWithSink(&recordingControlSink{})
+	_, first := a.augmentPiMessageEnd([]byte(`{"type":"message_end","message":{"role":"assistant","usage":{"input":100,"output":10,"cacheRead":7,"cost":{"total":0.5}}}}`))
+	_, second := a.augmentPiMessageEnd([]byte(`{"type":"message_end","message":{"role":"assistant","usage":{"input":50,"output":5,"cost":{"total":0.25}}}}`))
+
+	assert.Equal(t, &MessageUsage{InputTokens: 100, OutputTokens: 10, CacheReadInputTokens: 7, CostUSD: 0.5}, first)
+	assert.Equal(t, &MessageUsage{InputTokens: 50, OutputTokens: 5, CostUSD: 0.25}, second)
+}
+
 // TestPiAugmentRawWithSnapshot_NoOpWhenSnapshotEmpty exercises the
 // fast-path: when the snapshot has no cost and no contextUsage, the
 // helper returns raw without re-marshalling.
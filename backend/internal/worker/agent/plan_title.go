@@ -27,9 +27,9 @@ var (
 	htmlPolicy = bluemonday.StrictPolicy()
 )
 
-// extractPlanTitle extracts a human-readable title from markdown plan content.
+// ExtractPlanTitle extracts a human-readable title from markdown plan content.
 // It returns the first meaningful line, stripped of markdown formatting.
-func extractPlanTitle(content string) string {
+func ExtractPlanTitle(content string) string {
 	// Skip YAML frontmatter.
 	if strings.HasPrefix(content, "---\n") {
 		if idx := strings.Index(content[4:], "\n---\n"); idx >= 0 {
@@ -146,7 +146,7 @@ func TestExtractPlanTitle(t *testing.T) {
 
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
-			assert.Equal(t, tt.want, extractPlanTitle(tt.content))
+			assert.Equal(t, tt.want, ExtractPlanTitle(tt.content))
 		})
 	}
 }
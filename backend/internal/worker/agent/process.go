@@ -5,6 +5,7 @@ import (
 	"bytes"
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
 	"log/slog"
@@ -15,6 +16,8 @@ import (
 	"syscall"
 	"time"
 
+	leapmuxv1 "github.com/leapmux/leapmux/generated/proto/leapmux/v1"
+	"github.com/leapmux/leapmux/internal/errcode"
 	"github.com/leapmux/leapmux/util/procutil"
 )
 
@@ -63,6 +66,15 @@ type processBase struct {
 	preambleMeta       map[string]string // parsed key=value metadata from preamble
 	preambleOutput     []string          // captured preamble lines (before delimiter)
 
+	// capture, when non-nil, receives a copy of every stdout line (see
+	// openOutputCapture). Owned by the readOutput goroutine.
+	capture io.WriteCloser
+
+	// provider and outputProcessors feed the processor chain readOutput
+	// runs ahead of the provider's handler (see output_processor.go).
+	provider         leapmuxv1.AgentProvider
+	outputProcessors []OutputProcessor
+
 	apiTimeout   time.Duration // timeout for JSON-RPC requests
 	turnToolUses int           // number of tool uses in the current turn
 
@@ -269,7 +281,11 @@ func (p *processBase) formatStartupError(phase string, err error) error {
 	if preamble := strings.TrimSpace(p.PreambleOutput()); preamble != "" {
 		parts = append(parts, "shell preamble: "+preamble)
 	}
-	return fmt.Errorf("%s", strings.Join(parts, "; "))
+	formatted := fmt.Errorf("%s", strings.Join(parts, "; "))
+	if errors.Is(err, errResponseTimeout) || errors.Is(err, errControlTimeout) {
+		return errcode.Wrap(errcode.AgentStartupTimeout, formatted)
+	}
+	return formatted
 }
 
 // skipPreamble reads lines from the scanner until the preamble delimiter is
@@ -364,6 +380,9 @@ func newProcessBase(opts Options, providerName string, cmd *exec.Cmd, stdin io.W
 		preambleMetaPrefix: preambleMetaPrefix,
 		preambleMeta:       make(map[string]string),
 		apiTimeout:         opts.apiTimeout(),
+		capture:            openOutputCapture(opts, providerName),
+		provider:           opts.AgentProvider,
+		outputProcessors:   opts.OutputProcessors,
 	}
 }
 
@@ -486,8 +505,9 @@ type outputHandler func(line *parsedLine)
 
 // readOutput reads JSONL lines from stdout, JSON-parses them once into a
 // parsedLine, optionally intercepts responses, then forwards remaining lines
-// to the output handler.
-func (p *processBase) readOutput(scanner *bufio.Scanner, intercept outputInterceptor, handle outputHandler) {
+// to the output handler. A line that does not parse goes to sink as a dead
+// letter.
+func (p *processBase) readOutput(scanner *bufio.Scanner, sink OutputSink, intercept outputInterceptor, handle outputHandler) {
 	p.skipPreamble(scanner)
 
 	firstLineTraced := false
@@ -507,20 +527,26 @@ func (p *processBase) readOutput(scanner *bufio.Scanner, intercept outputInterce
 
 		lineCopy := make([]byte, len(line))
 		copy(lineCopy, line)
+		p.captureLine(lineCopy)
 
 		parsed := &parsedLine{Raw: lineCopy}
 		if err := json.Unmarshal(lineCopy, parsed); err != nil {
 			slog.Warn("invalid agent output JSON", "agent_id", p.agentID, "error", err)
+			sink.PersistUnparsedOutput(lineCopy, err)
 			continue
 		}
 
 		if intercept(parsed) {
 			continue
 		}
+		if p.runOutputProcessors(parsed, sink) {
+			continue
+		}
 
 		handle(parsed)
 	}
 
+	p.closeCapture()
 	if err := scanner.Err(); err != nil {
 		slog.Warn("agent stdout read error",
 			"agent_id", p.agentID,
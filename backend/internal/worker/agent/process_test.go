@@ -0,0 +1,32 @@
+package agent
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/leapmux/leapmux/internal/errcode"
+)
+
+func TestFormatStartupError_TimeoutCarriesCode(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	p := &processBase{ctx: ctx, cancel: cancel, processDone: make(chan struct{}), stderrDone: make(chan struct{})}
+	close(p.stderrDone)
+	p.stderrBuf.WriteString("still loading\n")
+
+	_, err := p.awaitResponse(make(chan json.RawMessage), "initialize", 10*time.Millisecond)
+	require.Error(t, err)
+	formatted := p.formatStartupError("initialize", err)
+	assert.Equal(t, "initialize: timeout waiting for initialize response; stderr: still loading", formatted.Error())
+	assert.Equal(t, errcode.AgentStartupTimeout, errcode.Of(formatted))
+
+	assert.Equal(t, errcode.AgentStartupTimeout, errcode.Of(p.formatStartupError("initialize", errControlTimeout)))
+	assert.Equal(t, errcode.Code(""), errcode.Of(p.formatStartupError("initialize", errors.New("exec: not found"))),
+		"only a timeout gets the startup-timeout code")
+}
@@ -124,6 +124,18 @@ type Provider interface {
 	// attachment. A nil return accepts it; a non-nil error rejects the whole send. Providers with
 	// no restrictions accept everything.
 	ValidateAttachment(attachment classifiedAttachment) error
+	// SupportsSystemPrompt reports whether the provider can launch with a library system prompt
+	// (Options.SystemPrompt) appended to its own. OpenAgent rejects a prompt for providers that
+	// cannot, rather than silently dropping it.
+	SupportsSystemPrompt() bool
+	// CompactInput returns the user input that makes the provider compact its own context in
+	// place, or "" when it has no such command; LeapMux then compacts by restarting the agent
+	// with a digest of the conversation instead.
+	CompactInput() string
+	// ModelCredentialEnv returns the environment that runs the provider under an org model
+	// credential of kind with secret, or nil when the provider cannot use that kind; the agent
+	// then keeps the worker host's own login.
+	ModelCredentialEnv(kind leapmuxv1.ModelCredentialKind, secret string) []string
 }
 
 type noopProvider struct{}
@@ -156,6 +168,12 @@ func (noopProvider) PlanApprovalOptions() PlanApprovalOptions { return PlanAppro
 // synthetic notice. The ACP-based providers inherit this via their noopProvider embedding.
 func (noopProvider) SyntheticInterruptNotice() string { return "" }
 
+func (noopProvider) SupportsSystemPrompt() bool { return false }
+
+func (noopProvider) CompactInput() string { return "" }
+
+func (noopProvider) ModelCredentialEnv(leapmuxv1.ModelCredentialKind, string) []string { return nil }
+
 // PermissionModeFromRawInput defaults to ("", false): a provider whose permission-mode changes
 // don't ride raw control frames carries no eager-parse path. The ACP-based providers inherit this
 // via their noopProvider embedding.
@@ -309,6 +327,12 @@ func (codexProvider) PlanApprovalOptions() PlanApprovalOptions {
 // persists this synthetic row to record the interrupt. The literal's single home lives here.
 func (codexProvider) SyntheticInterruptNotice() string { return "[Request interrupted by user]" }
 
+func (codexProvider) SupportsSystemPrompt() bool { return false }
+
+func (codexProvider) CompactInput() string { return "" }
+
+func (codexProvider) ModelCredentialEnv(leapmuxv1.ModelCredentialKind, string) []string { return nil }
+
 // PermissionModeFromRawInput: Codex has no set_permission_mode raw control frame.
 func (codexProvider) PermissionModeFromRawInput(string) (string, bool) { return "", false }
 
@@ -390,6 +414,22 @@ func (claudeProvider) PlanApprovalOptions() PlanApprovalOptions { return PlanApp
 // notice is persisted for a forwarded interrupt frame.
 func (claudeProvider) SyntheticInterruptNotice() string { return "" }
 
+func (claudeProvider) SupportsSystemPrompt() bool { return true }
+
+// CompactInput is Claude Code's own /compact, which it honors in stream-json mode.
+func (claudeProvider) CompactInput() string { return "/compact" }
+
+// ModelCredentialEnv sets the variable Claude Code reads each kind from.
+func (claudeProvider) ModelCredentialEnv(kind leapmuxv1.ModelCredentialKind, secret string) []string {
+	switch kind {
+	case leapmuxv1.ModelCredentialKind_MODEL_CREDENTIAL_KIND_ANTHROPIC_API_KEY:
+		return []string{"ANTHROPIC_API_KEY=" + secret}
+	case leapmuxv1.ModelCredentialKind_MODEL_CREDENTIAL_KIND_CLAUDE_OAUTH_TOKEN:
+		return []string{"CLAUDE_CODE_OAUTH_TOKEN=" + secret}
+	}
+	return nil
+}
+
 // PermissionModeFromRawInput parses Claude's set_permission_mode control_request
 // ({"request":{"subtype":"set_permission_mode","mode":"..."}}) and returns the requested mode.
 // Returns ("", false) when the frame isn't a set_permission_mode request. The service eagerly
@@ -477,6 +517,12 @@ func (piProvider) PlanApprovalOptions() PlanApprovalOptions { return PlanApprova
 // persisted for a forwarded interrupt frame.
 func (piProvider) SyntheticInterruptNotice() string { return "" }
 
+func (piProvider) SupportsSystemPrompt() bool { return false }
+
+func (piProvider) CompactInput() string { return "" }
+
+func (piProvider) ModelCredentialEnv(leapmuxv1.ModelCredentialKind, string) []string { return nil }
+
 // PermissionModeFromRawInput: Pi has no set_permission_mode raw control frame.
 func (piProvider) PermissionModeFromRawInput(string) (string, bool) { return "", false }
 
@@ -0,0 +1,26 @@
+package agent
+
+import "io"
+
+// NewClaudeCodeReplay returns a Claude Code agent with no process behind
+// it, for feeding a captured transcript through HandleOutput (see
+// `leapmux worker replay`). Anything the output handlers write back to
+// the CLI is discarded.
+func NewClaudeCodeReplay(agentID string, sink OutputSink) *ClaudeCodeAgent {
+	return &ClaudeCodeAgent{
+		processBase: processBase{
+			agentID:      agentID,
+			providerName: "claude",
+			stdin:        nopWriteCloser{io.Discard},
+			stderrDone:   make(chan struct{}),
+			processDone:  make(chan struct{}),
+		},
+		sink:           sink,
+		pendingControl: make(map[string]chan<- claudeCodeControlResult),
+		alwaysThinking: AlwaysThinkingOn,
+	}
+}
+
+type nopWriteCloser struct{ io.Writer }
+
+func (nopWriteCloser) Close() error { return nil }
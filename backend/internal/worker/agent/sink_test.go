@@ -1,6 +1,7 @@
 package agent
 
 import (
+	"fmt"
 	"sync"
 
 	leapmuxv1 "github.com/leapmux/leapmux/generated/proto/leapmux/v1"
@@ -40,6 +41,8 @@ type testSink struct {
 	statusActives     []string
 	autoSchedules     []AutoContinueSchedule
 	autoCancels       []AutoContinueReason
+	subAgentEvents    []string
+	unparsedOutput    [][]byte
 	planModeToolUses  sync.Map
 	// notifSuppressBroadcast makes PersistNotification report broadcast=false,
 	// simulating the service layer collapsing a flapping notification
@@ -57,6 +60,8 @@ type testSinkMessage struct {
 	SpanType        string
 	Closing         bool
 	MarkType        leapmuxv1.MarkType
+	Usage           *MessageUsage
+	ToolCall        *ToolCall
 	// TurnEnd is set on entries recorded by PersistTurnEnd so tests can
 	// distinguish the turn-end divider from regular AGENT messages
 	// without inspecting the inner content.
@@ -77,7 +82,7 @@ type testSinkSpanOpen struct {
 func (s *testSink) PersistMessage(source leapmuxv1.MessageSource, content []byte, span SpanInfo) error {
 	s.mu.Lock()
 	defer s.mu.Unlock()
-	s.messages = append(s.messages, testSinkMessage{Source: source, Content: append([]byte(nil), content...), ParentSpanID: span.ParentSpanID, ConnectorSpanID: span.ConnectorSpanID, SpanID: span.SpanID, SpanType: span.SpanType, Closing: span.Closing, MarkType: span.MarkType})
+	s.messages = append(s.messages, testSinkMessage{Source: source, Content: append([]byte(nil), content...), ParentSpanID: span.ParentSpanID, ConnectorSpanID: span.ConnectorSpanID, SpanID: span.SpanID, SpanType: span.SpanType, Closing: span.Closing, MarkType: span.MarkType, Usage: span.Usage, ToolCall: span.ToolCall})
 	return nil
 }
 
@@ -93,6 +98,7 @@ func (s *testSink) PersistTurnEnd(content []byte, span SpanInfo) error {
 		SpanType:        span.SpanType,
 		Closing:         span.Closing,
 		MarkType:        span.MarkType,
+		Usage:           span.Usage,
 		TurnEnd:         true,
 	})
 	return nil
@@ -154,6 +160,7 @@ func (s *testSink) BroadcastStreamEnd(spanID string) {
 }
 
 func (s *testSink) PersistControlRequest(string, []byte) string    { return "" }
+func (s *testSink) DivertControlRequest(string, []byte) bool       { return false }
 func (s *testSink) DeleteControlRequest(string)                    {}
 func (s *testSink) BroadcastControlRequest(string, []byte, string) {}
 func (s *testSink) BroadcastControlCancel(string)                  {}
@@ -233,6 +240,29 @@ func (s *testSink) CancelAutoContinue(reason AutoContinueReason) {
 	s.autoCancels = append(s.autoCancels, reason)
 }
 
+// The sub-agent hooks record one "event:tool_use_id[:detail]" string per
+// call, in order, so tests can assert the whole lifecycle at once.
+func (s *testSink) StartSubAgentRun(toolUseID, parentToolUseID, subAgentType, _ string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.subAgentEvents = append(s.subAgentEvents, "start:"+toolUseID+":"+parentToolUseID+":"+subAgentType)
+}
+func (s *testSink) AddSubAgentUsage(toolUseID string, usage SubAgentUsage) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.subAgentEvents = append(s.subAgentEvents, fmt.Sprintf("usage:%s:%d", toolUseID, usage.OutputTokens))
+}
+func (s *testSink) EndSubAgentRun(toolUseID, status string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.subAgentEvents = append(s.subAgentEvents, "end:"+toolUseID+":"+status)
+}
+func (s *testSink) PersistUnparsedOutput(line []byte, _ error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.unparsedOutput = append(s.unparsedOutput, append([]byte(nil), line...))
+}
+
 // MessageCount returns the number of persisted messages.
 func (s *testSink) MessageCount() int {
 	s.mu.Lock()
@@ -439,6 +469,7 @@ func (noopSink) ReserveSpanColor(string, string) int32
 func (noopSink) BroadcastStreamChunk([]byte, string, string)                       {}
 func (noopSink) BroadcastStreamEnd(string)                                         {}
 func (noopSink) PersistControlRequest(string, []byte) string                       { return "" }
+func (noopSink) DivertControlRequest(string, []byte) bool                          { return false }
 func (noopSink) DeleteControlRequest(string)                                       {}
 func (noopSink) BroadcastControlRequest(string, []byte, string)                    {}
 func (noopSink) BroadcastControlCancel(string)                                     {}
@@ -454,3 +485,7 @@ func (noopSink) LoadAndDeletePlanModeToolUse(string) (string, bool)
 func (noopSink) UpdatePlan([]byte, leapmuxv1.ContentCompression, string)           {}
 func (noopSink) ScheduleAutoContinue(AutoContinueSchedule)                         {}
 func (noopSink) CancelAutoContinue(AutoContinueReason)                             {}
+func (noopSink) StartSubAgentRun(string, string, string, string)                   {}
+func (noopSink) AddSubAgentUsage(string, SubAgentUsage)                            {}
+func (noopSink) EndSubAgentRun(string, string)                                     {}
+func (noopSink) PersistUnparsedOutput([]byte, error)                               {}
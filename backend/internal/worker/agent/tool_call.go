@@ -0,0 +1,39 @@
+package agent
+
+import (
+	"encoding/json"
+	"strings"
+)
+
+// ToolNameBash is Claude Code's shell tool.
+const ToolNameBash = "Bash"
+
+// ToolCall describes the tool call a persisted message starts, for the
+// worker's checks on what agents do.
+type ToolCall struct {
+	// Name is the provider's name for the tool.
+	Name string
+	// Command is the shell command the call runs; empty for a tool that
+	// runs none.
+	Command string
+}
+
+// toolCallCommand returns the shell command in a tool call's JSON input:
+// its "command" string, or its "command" argv joined with spaces.
+func toolCallCommand(input json.RawMessage) string {
+	var in struct {
+		Command json.RawMessage `json:"command"`
+	}
+	if len(input) == 0 || json.Unmarshal(input, &in) != nil || len(in.Command) == 0 {
+		return ""
+	}
+	var command string
+	if json.Unmarshal(in.Command, &command) == nil {
+		return command
+	}
+	var argv []string
+	if json.Unmarshal(in.Command, &argv) == nil {
+		return strings.Join(argv, " ")
+	}
+	return ""
+}
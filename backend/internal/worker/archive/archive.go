@@ -0,0 +1,189 @@
+// Package archive writes to an S3-compatible bucket, where the worker
+// ships closed agents' transcripts and audit records so they outlive the
+// worker's own retention. It speaks the few S3 calls it needs -- put,
+// list and delete, path-style and signed with SigV4 -- so it works with
+// AWS S3, MinIO, Cloudflare R2 and the like without an SDK.
+package archive
+
+import (
+	"bytes"
+	"context"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+)
+
+// Config selects a bucket. An empty Bucket disables archiving.
+type Config struct {
+	Endpoint        string // base URL of the S3 API, e.g. https://s3.us-east-1.amazonaws.com
+	Bucket          string
+	Prefix          string // prepended to every key; may be empty
+	Region          string // signing region; defaults to "us-east-1"
+	AccessKeyID     string
+	SecretAccessKey string
+	SessionToken    string // for temporary credentials; may be empty
+}
+
+// Bucket is a configured S3-compatible bucket.
+type Bucket struct {
+	cfg      Config
+	endpoint *url.URL
+	client   *http.Client
+	now      func() time.Time
+}
+
+// requestTimeout bounds each S3 call.
+const requestTimeout = 60 * time.Second
+
+// New validates cfg and returns its Bucket, or nil when archiving is
+// disabled.
+func New(cfg Config) (*Bucket, error) {
+	if cfg.Bucket == "" {
+		return nil, nil
+	}
+	if cfg.Endpoint == "" {
+		return nil, errors.New("archive bucket needs an endpoint")
+	}
+	endpoint, err := url.Parse(cfg.Endpoint)
+	if err != nil || (endpoint.Scheme != "http" && endpoint.Scheme != "https") || endpoint.Host == "" {
+		return nil, fmt.Errorf("archive endpoint %q is not an http(s) URL", cfg.Endpoint)
+	}
+	if cfg.AccessKeyID == "" || cfg.SecretAccessKey == "" {
+		return nil, errors.New("archive bucket needs an access key ID and secret access key")
+	}
+	if cfg.Region == "" {
+		cfg.Region = "us-east-1"
+	}
+	cfg.Prefix = strings.Trim(cfg.Prefix, "/")
+	return &Bucket{
+		cfg:      cfg,
+		endpoint: endpoint,
+		client:   &http.Client{Timeout: requestTimeout},
+		now:      time.Now,
+	}, nil
+}
+
+// Key joins the bucket's prefix and parts into an object key.
+func (b *Bucket) Key(parts ...string) string {
+	if b.cfg.Prefix != "" {
+		parts = append([]string{b.cfg.Prefix}, parts...)
+	}
+	return strings.Join(parts, "/")
+}
+
+// Put uploads body as key.
+func (b *Bucket) Put(ctx context.Context, key string, body []byte, contentType string) error {
+	req, err := b.newRequest(ctx, http.MethodPut, key, nil, body)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", contentType)
+	_, err = b.do(req, sha256Hex(body))
+	return err
+}
+
+// Delete removes key. Deleting a key that does not exist succeeds.
+func (b *Bucket) Delete(ctx context.Context, key string) error {
+	req, err := b.newRequest(ctx, http.MethodDelete, key, nil, nil)
+	if err != nil {
+		return err
+	}
+	_, err = b.do(req, emptyPayloadHash)
+	return err
+}
+
+// listResult is the part of a ListObjectsV2 response List reads.
+type listResult struct {
+	Contents []struct {
+		Key string `xml:"Key"`
+	} `xml:"Contents"`
+	IsTruncated           bool   `xml:"IsTruncated"`
+	NextContinuationToken string `xml:"NextContinuationToken"`
+}
+
+// List returns the keys that start with prefix, following pagination.
+func (b *Bucket) List(ctx context.Context, prefix string) ([]string, error) {
+	var keys []string
+	token := ""
+	for {
+		query := url.Values{"list-type": {"2"}, "prefix": {prefix}}
+		if token != "" {
+			query.Set("continuation-token", token)
+		}
+		req, err := b.newRequest(ctx, http.MethodGet, "", query, nil)
+		if err != nil {
+			return nil, err
+		}
+		body, err := b.do(req, emptyPayloadHash)
+		if err != nil {
+			return nil, err
+		}
+		var res listResult
+		if err := xml.Unmarshal(body, &res); err != nil {
+			return nil, fmt.Errorf("parse list response: %w", err)
+		}
+		for _, c := range res.Contents {
+			keys = append(keys, c.Key)
+		}
+		if !res.IsTruncated || res.NextContinuationToken == "" {
+			return keys, nil
+		}
+		token = res.NextContinuationToken
+	}
+}
+
+// newRequest builds a path-style request for key, or for the bucket
+// itself when key is empty.
+func (b *Bucket) newRequest(ctx context.Context, method, key string, query url.Values, body []byte) (*http.Request, error) {
+	u := *b.endpoint
+	path := strings.TrimSuffix(u.Path, "/") + "/" + b.cfg.Bucket
+	if key != "" {
+		path += "/" + key
+	}
+	u.Path = path
+	u.RawPath = uriEncode(path, false)
+	u.RawQuery = ""
+	if len(query) > 0 {
+		// Encode with the same rules as the signature so the two agree.
+		pairs := make([]string, 0, len(query))
+		for name, values := range query {
+			for _, v := range values {
+				pairs = append(pairs, uriEncode(name, true)+"="+uriEncode(v, true))
+			}
+		}
+		u.RawQuery = strings.Join(pairs, "&")
+	}
+	var r io.Reader
+	if body != nil {
+		r = bytes.NewReader(body)
+	}
+	return http.NewRequestWithContext(ctx, method, u.String(), r)
+}
+
+// do signs and sends req, returning the body of a 2xx response.
+func (b *Bucket) do(req *http.Request, payloadHash string) ([]byte, error) {
+	signV4(req, payloadHash, b.cfg.Region, b.cfg.AccessKeyID, b.cfg.SecretAccessKey, b.cfg.SessionToken, b.now())
+	resp, err := b.client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("s3 %s: %w", req.Method, err)
+	}
+	defer func() { _ = resp.Body.Close() }()
+	body, err := io.ReadAll(io.LimitReader(resp.Body, 16<<20))
+	if err != nil {
+		return nil, fmt.Errorf("s3 %s: read response: %w", req.Method, err)
+	}
+	if resp.StatusCode/100 != 2 {
+		var e struct {
+			Code    string `xml:"Code"`
+			Message string `xml:"Message"`
+		}
+		_ = xml.Unmarshal(body, &e)
+		return nil, fmt.Errorf("s3 %s %s: %s %s %s", req.Method, req.URL.Path, resp.Status, e.Code, e.Message)
+	}
+	return body, nil
+}
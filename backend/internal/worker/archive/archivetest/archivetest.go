@@ -0,0 +1,115 @@
+// Package archivetest is an in-memory S3 endpoint for tests of code that
+// writes to an archive.Bucket.
+package archivetest
+
+import (
+	"encoding/xml"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+)
+
+// Server answers the put, list and delete calls archive.Bucket makes,
+// for any bucket name. Requests without a SigV4 Authorization header are
+// refused.
+type Server struct {
+	URL string
+	// MaxKeys caps each list page, so tests can exercise pagination.
+	MaxKeys int
+
+	mu      sync.Mutex
+	objects map[string][]byte // "bucket/key" -> body
+}
+
+// NewServer starts a Server that stops when t ends.
+func NewServer(t testing.TB) *Server {
+	s := &Server{MaxKeys: 1000, objects: map[string][]byte{}}
+	srv := httptest.NewServer(http.HandlerFunc(s.serve))
+	t.Cleanup(srv.Close)
+	s.URL = srv.URL
+	return s
+}
+
+// Object returns the body stored under bucket and key.
+func (s *Server) Object(bucket, key string) ([]byte, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	b, ok := s.objects[bucket+"/"+key]
+	return b, ok
+}
+
+// Keys returns the sorted keys stored in bucket.
+func (s *Server) Keys(bucket string) []string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	var keys []string
+	for k := range s.objects {
+		if rest, ok := strings.CutPrefix(k, bucket+"/"); ok {
+			keys = append(keys, rest)
+		}
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+func (s *Server) serve(w http.ResponseWriter, r *http.Request) {
+	if !strings.HasPrefix(r.Header.Get("Authorization"), "AWS4-HMAC-SHA256 Credential=") {
+		w.WriteHeader(http.StatusForbidden)
+		_, _ = io.WriteString(w, "<Error><Code>AccessDenied</Code></Error>")
+		return
+	}
+	bucket, key, _ := strings.Cut(strings.TrimPrefix(r.URL.Path, "/"), "/")
+	switch {
+	case r.Method == http.MethodPut && key != "":
+		body, err := io.ReadAll(r.Body)
+		if err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		s.mu.Lock()
+		s.objects[bucket+"/"+key] = body
+		s.mu.Unlock()
+	case r.Method == http.MethodDelete && key != "":
+		s.mu.Lock()
+		delete(s.objects, bucket+"/"+key)
+		s.mu.Unlock()
+		w.WriteHeader(http.StatusNoContent)
+	case r.Method == http.MethodGet && key == "" && r.URL.Query().Get("list-type") == "2":
+		s.list(w, bucket, r.URL.Query().Get("prefix"), r.URL.Query().Get("continuation-token"))
+	default:
+		w.WriteHeader(http.StatusMethodNotAllowed)
+	}
+}
+
+type listResult struct {
+	XMLName               xml.Name `xml:"ListBucketResult"`
+	Contents              []struct{ Key string }
+	IsTruncated           bool
+	NextContinuationToken string `xml:",omitempty"`
+}
+
+func (s *Server) list(w http.ResponseWriter, bucket, prefix, token string) {
+	var matched []string
+	for _, k := range s.Keys(bucket) {
+		if strings.HasPrefix(k, prefix) {
+			matched = append(matched, k)
+		}
+	}
+	start, _ := strconv.Atoi(token)
+	end := min(start+s.MaxKeys, len(matched))
+	var res listResult
+	for _, k := range matched[min(start, end):end] {
+		res.Contents = append(res.Contents, struct{ Key string }{k})
+	}
+	if end < len(matched) {
+		res.IsTruncated = true
+		res.NextContinuationToken = strconv.Itoa(end)
+	}
+	w.Header().Set("Content-Type", "application/xml")
+	_ = xml.NewEncoder(w).Encode(res)
+}
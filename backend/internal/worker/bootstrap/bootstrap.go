@@ -25,12 +25,17 @@ import (
 
 	leapmuxv1 "github.com/leapmux/leapmux/generated/proto/leapmux/v1"
 	noiseutil "github.com/leapmux/leapmux/internal/noise"
+	"github.com/leapmux/leapmux/internal/plugin"
+	"github.com/leapmux/leapmux/internal/worker/agent"
+	"github.com/leapmux/leapmux/internal/worker/archive"
 	"github.com/leapmux/leapmux/internal/worker/channel"
 	"github.com/leapmux/leapmux/internal/worker/crossworker"
 	db "github.com/leapmux/leapmux/internal/worker/generated/db"
 	"github.com/leapmux/leapmux/internal/worker/hub"
+	"github.com/leapmux/leapmux/internal/worker/redact"
 	"github.com/leapmux/leapmux/internal/worker/remoteipc"
 	"github.com/leapmux/leapmux/internal/worker/service"
+	"github.com/leapmux/leapmux/internal/worker/transcribe"
 	"github.com/leapmux/leapmux/internal/worker/wakelock"
 )
 
@@ -67,6 +72,70 @@ type Params struct {
 	APITimeout          time.Duration
 	UseLoginShell       bool
 	WakeLock            *wakelock.ActivityTracker
+
+	// CaptureAgentOutput is the directory each agent process's raw stdout
+	// is copied to, for `leapmux worker replay`. Only the standalone
+	// worker reads it from config; empty disables capture.
+	CaptureAgentOutput string
+
+	// ClaudeOutputSchema says how Claude agents report output that strays
+	// from the envelope schema. Only the standalone worker reads it from
+	// config; zero is lenient.
+	ClaudeOutputSchema agent.OutputSchemaMode
+
+	// PermissionGuardrails constrains the permission modes agents may use.
+	// Only the standalone worker reads it from config; zero means none.
+	PermissionGuardrails service.PermissionGuardrails
+
+	// IdlePark stops agents that sit idle. Only the standalone worker reads
+	// it from config; zero means agents are never parked.
+	IdlePark service.IdleParkPolicy
+
+	// ContextPressure warns as agents' context windows fill. Only the
+	// standalone worker reads it from config; zero means no warnings.
+	ContextPressure service.ContextPressurePolicy
+
+	// Anomaly flags runaway or destructive agent turns. Only the standalone
+	// worker reads it from config; zero flags nothing.
+	Anomaly service.AnomalyPolicy
+
+	// StuckTurn acts on agent turns that stop producing output. Only the
+	// standalone worker reads it from config; zero never does.
+	StuckTurn service.StuckTurnPolicy
+
+	// ClaudeSessionRetention collects Claude Code session files no agent
+	// uses once they are this old. Only the standalone worker reads it from
+	// config; zero keeps them.
+	ClaudeSessionRetention time.Duration
+
+	// PersistTerminals runs terminal shells under tmux so they outlive
+	// the worker. Only the standalone worker reads it from config.
+	PersistTerminals bool
+
+	// Plugins are the exec plugins sent agent events, started with the
+	// other background loops. Only the standalone worker reads them from
+	// config; nil runs none.
+	Plugins *plugin.Host
+
+	// Redactor replaces secrets in agent output before it is stored. Only
+	// the standalone worker reads it from config; nil redacts nothing.
+	Redactor *redact.Redactor
+
+	// Classifier tags stored messages that hold personal data, and
+	// ClassifiedRetention deletes them once they are this old. Only the
+	// standalone worker reads them from config; nil and zero turn them off.
+	Classifier          *redact.Classifier
+	ClassifiedRetention time.Duration
+
+	// Archive is the bucket closed agents' transcripts are shipped to, and
+	// ArchiveRetention how long shipped objects stay there. Only the
+	// standalone worker reads them from config; nil and zero turn them off.
+	Archive          *archive.Bucket
+	ArchiveRetention time.Duration
+
+	// Transcriber turns voice notes into prompts. Only the standalone
+	// worker reads it from config; nil disables voice notes.
+	Transcriber transcribe.Transcriber
 }
 
 // Wiring is the assembled worker. Callers own the lifecycle: nothing here
@@ -109,11 +178,32 @@ func Wire(p Params) *Wiring {
 		DataDir:             p.DataDir,
 		WorkerID:            p.WorkerID,
 		Name:                p.Name,
+		Labels:              p.Client.Labels,
 		SeedRegisteredBy:    p.SeedRegisteredBy,
 		AgentStartupTimeout: p.AgentStartupTimeout,
 		APITimeout:          p.APITimeout,
 		UseLoginShell:       p.UseLoginShell,
 		WakeLock:            p.WakeLock,
+		CaptureAgentOutput:  p.CaptureAgentOutput,
+		ClaudeOutputSchema:  p.ClaudeOutputSchema,
+
+		PermissionGuardrails: p.PermissionGuardrails,
+		IdlePark:             p.IdlePark,
+		ContextPressure:      p.ContextPressure,
+		Anomaly:              p.Anomaly,
+		StuckTurn:            p.StuckTurn,
+		Transcriber:          p.Transcriber,
+		Snippets:             p.Client.GetSnippetForWorker,
+		ModelCredentials:     p.Client.GetModelCredentialsForWorker,
+
+		ClaudeSessionRetention: p.ClaudeSessionRetention,
+		PersistTerminals:       p.PersistTerminals,
+		Plugins:                p.Plugins,
+		Redactor:               p.Redactor,
+		Classifier:             p.Classifier,
+		ClassifiedRetention:    p.ClassifiedRetention,
+		Archive:                p.Archive,
+		ArchiveRetention:       p.ArchiveRetention,
 	})
 	svc.RestoreState()
 
@@ -133,9 +223,13 @@ func Wire(p Params) *Wiring {
 	// thread (and to-do/span trackers), which must survive a relaunch so
 	// two settings-change notifications bracketing a model/effort switch
 	// stay in one thread and consolidate. Permanent teardown does the full
-	// cleanup via its own ClearAgentRuntimeState call.
+	// cleanup via its own ClearAgentRuntimeState call. The exit also resets
+	// the agent's idle-park clock and, unless a restart replaces the
+	// process, tells watchers the agent is INACTIVE.
 	p.Client.AgentManager().SetOnExit(func(agentID string, _ int, _ error) {
 		svc.Output.ClearPendingControlRequests(agentID)
+		svc.Output.ResetAgentActivity(agentID)
+		svc.ReportAgentExit(agentID)
 	})
 
 	dispatcher := channel.NewDispatcher()
@@ -175,6 +269,19 @@ func Wire(p Params) *Wiring {
 	// than a closure over SetRegisteredBy, so the drift warning and the
 	// empty-owner refusal are shared by both entry points.
 	p.Client.OnWorkerIdentity = svc.UpdateRegisteredBy
+	p.Client.OnOrgID = svc.SetOrgID
+	// The client applies the upload cap itself; output compression lives in
+	// the watch fan-out.
+	p.Client.OnStreamSettings = func(s *leapmuxv1.WorkerStreamSettings) {
+		svc.Watchers.SetCompressOutput(s.GetCompressOutput())
+	}
+	p.Client.OnAgentTerminalPolicy = svc.SetAgentTerminalPolicy
+	p.Client.OnAccessPolicy = svc.SetAccessPolicy
+	p.Client.OnDiskQuota = svc.SetDiskQuota
+	p.Client.OnOrgDefaults = svc.SetOrgDefaults
+	p.Client.OnEmergencyStop = svc.SetEmergencyStop
+	p.Client.OnAnnouncements = svc.SetAnnouncements
+	p.Client.OnPrepareRepoCheckout = svc.PrepareRepoCheckout
 
 	startBackgroundLoops(p, svc)
 
@@ -269,8 +376,11 @@ func startBackgroundLoops(p Params, svc *service.Service) {
 	// hub has finished its side of the reconciliation; trigger the
 	// worker-side reconciler so this worker converges on every reconnect
 	// (not just on the hourly tick).
+	// It also marks a fresh hub connection, which holds no workspace
+	// activity from earlier ones.
 	p.Client.OnTabSyncResponse = func(*leapmuxv1.WorkspaceTabsSyncResponse) {
 		reconciler.Trigger()
+		go svc.ResendWorkspaceActivity(p.Ctx)
 	}
 
 	// Periodically reclaim in-memory agent tracker state orphaned by a
@@ -278,16 +388,67 @@ func startBackgroundLoops(p Params, svc *service.Service) {
 	// per-exit handler keeps the state for a possible relaunch).
 	svc.StartOrphanSweepLoop(p.Ctx)
 
-	StartRetentionLoops(p.Ctx, p.DB, p.DataDir)
+	// Park agents idle past the configured policy; a no-op when disabled.
+	svc.StartIdleParkLoop(p.Ctx)
+
+	// Act on turns that stop producing output; a no-op when disabled.
+	svc.StartStuckTurnLoop(p.Ctx)
+
+	// Hold running turns to their workspace's duration limit.
+	svc.StartTurnLimitLoop(p.Ctx)
+
+	// Remove Claude Code transcripts and plans no agent uses any more; a
+	// no-op when retention is off.
+	svc.StartClaudeSessionGCLoop(p.Ctx)
+
+	// Delete tagged messages sooner than the rest of their transcripts; a
+	// no-op when the override is off.
+	svc.StartClassifiedRetentionLoop(p.Ctx)
+
+	// Ship closed agents' transcripts to the archive bucket; a no-op when
+	// there is none.
+	svc.StartArchiveLoop(p.Ctx)
+
+	// Measure worktree and checkout disk usage, report it to the Hub, and
+	// warn agents before the disk or the org's quota fills.
+	svc.StartDiskUsageLoop(p.Ctx)
+
+	// Report each workspace's last message time, awaited approvals and
+	// unread counts to the Hub as they change, for ListWorkspaces.
+	svc.StartWorkspaceActivityLoop(p.Ctx)
+
+	// Sample the dashboard's activity and health series, and fold aged
+	// buckets into coarser ones.
+	svc.StartMetricsLoops(p.Ctx)
+
+	// Poll CI checks on agents' branches in workspaces that opted in.
+	svc.StartCIStatusLoop(p.Ctx)
+
+	// Run the operator's exec plugins; a no-op when there are none.
+	p.Plugins.Start(p.Ctx)
+
+	// Tell every watching client the last event_seq it was sent, so one
+	// that lost a trailing event resubscribes instead of waiting for the
+	// next event to reveal the gap.
+	svc.Watchers.StartHeartbeatLoop(p.Ctx)
+
+	StartRetentionLoops(p.Ctx, p.DB, p.DataDir, service.CleanupOptions{
+		Retention:    svc.ClosedRetention,
+		KeepAgents:   svc.ImmutableTranscripts,
+		ArchivedOnly: svc.Archive != nil,
+	})
 }
 
 // StartRetentionLoops starts the two data-retention loops. It is exported
 // separately because worker.Run runs them even on its degenerate
 // no-composite-key path, where there is no service to wire at all.
-func StartRetentionLoops(ctx context.Context, sqlDB *sql.DB, dataDir string) {
+// cleanup follows the org's retention and immutable-transcripts settings
+// and the worker's archive; the zero value, on that path, keeps the
+// defaults.
+func StartRetentionLoops(ctx context.Context, sqlDB *sql.DB, dataDir string, cleanup service.CleanupOptions) {
 	// Hard-delete agents and terminals closed for longer than the
 	// retention period.
-	service.StartCleanupLoop(ctx, db.New(sqlDB))
+	service.StartCleanupLoop(ctx, db.New(sqlDB), cleanup)
 
 	// Roll up old plan year directories (`<data_dir>/plans/<YYYY>/`) into
 	// per-year zip files.
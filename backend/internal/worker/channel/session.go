@@ -67,6 +67,15 @@ type channelSession struct {
 	// not the inner per-session map.
 	awsMu                  sync.RWMutex
 	accessibleWorkspaceIDs map[string]bool // workspaces the user can access (set from ChannelOpenRequest)
+	// readOnly is set from ChannelOpenRequest for an anonymous viewer of a
+	// public workspace or a viewer guest and never changes afterwards.
+	readOnly bool
+	// guest is set from ChannelOpenRequest for a guest link bearer and never
+	// changes afterwards.
+	guest bool
+	// workspaceScoped is set from ChannelOpenRequest for a credential pinned
+	// to one workspace and never changes afterwards.
+	workspaceScoped bool
 	// errorSends decouples the receive loop's error responses (reassembly cap,
 	// oversize, no dispatcher) from the shared send path. An inline send holds
 	// sender.mu across sendFn, which can block on the Connect stream's HTTP/2
@@ -327,6 +336,9 @@ func (m *Manager) HandleOpen(req *leapmuxv1.ChannelOpenRequest) *leapmuxv1.Chann
 		cancel:                 cancel,
 		reassembly:             newReassembler(m.maxMessageSize, m.maxIncompleteChunked),
 		accessibleWorkspaceIDs: awsIDs,
+		readOnly:               req.GetReadOnly(),
+		guest:                  req.GetGuest(),
+		workspaceScoped:        req.GetWorkspaceScoped(),
 		errorSends:             make(chan errorSend, errorSendQueueSize),
 	}
 	m.sessions[req.GetChannelId()] = sess
@@ -339,6 +351,9 @@ func (m *Manager) HandleOpen(req *leapmuxv1.ChannelOpenRequest) *leapmuxv1.Chann
 		"channel_id", req.GetChannelId(),
 		"user_id", req.GetUserId(),
 		"encryption_mode", m.encryptionMode,
+		"read_only", req.GetReadOnly(),
+		"guest", req.GetGuest(),
+		"workspace_scoped", req.GetWorkspaceScoped(),
 	)
 
 	return &leapmuxv1.ChannelOpenResponse{
@@ -400,6 +415,28 @@ func (m *Manager) IsWorkspaceAccessible(channelID, workspaceID string) bool {
 	return sess.accessibleWorkspaceIDs[workspaceID]
 }
 
+// IsReadOnly reports whether the channel belongs to an anonymous viewer of a
+// public workspace or a viewer guest. Returns false if the channel is not found; a closed
+// channel has nowhere to send a response anyway.
+func (m *Manager) IsReadOnly(channelID string) bool {
+	sess, ok := m.getSession(channelID)
+	return ok && sess.readOnly
+}
+
+// IsGuest reports whether the channel belongs to a guest of a workspace.
+// Returns false if the channel is not found.
+func (m *Manager) IsGuest(channelID string) bool {
+	sess, ok := m.getSession(channelID)
+	return ok && sess.guest
+}
+
+// IsWorkspaceScoped reports whether the channel was opened with a credential
+// pinned to one workspace. Returns false if the channel is not found.
+func (m *Manager) IsWorkspaceScoped(channelID string) bool {
+	sess, ok := m.getSession(channelID)
+	return ok && sess.workspaceScoped
+}
+
 // AddAccessibleWorkspaceID adds a workspace ID to the channel's accessible
 // set. This is needed when a workspace is created after the channel was
 // opened, so that subsequent WatchEvents calls can see the new workspace.
@@ -0,0 +1,257 @@
+// Package cistatus reads the CI checks a code host reports for a commit.
+// Only GitHub is supported: its check runs (GitHub Actions and other apps)
+// and its older commit statuses are folded into one list. The worker polls
+// rather than taking webhooks, since nothing can reach it except through
+// the Hub's end-to-end encrypted relay.
+package cistatus
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"regexp"
+	"strings"
+
+	"github.com/leapmux/leapmux/internal/worker/gitutil"
+)
+
+// DefaultBaseURL is GitHub's REST API.
+const DefaultBaseURL = "https://api.github.com"
+
+// maxLogBytes is how much of a failed job's log JobLog keeps: the tail,
+// where the failure usually is.
+const maxLogBytes = 16 << 10
+
+// State is a check's or a commit's CI outcome.
+type State int
+
+const (
+	// StateNone means no checks are reported.
+	StateNone State = iota
+	StatePending
+	StateSuccess
+	StateFailure
+)
+
+// Check is one CI check on a commit.
+type Check struct {
+	Name  string
+	State State
+	URL   string
+	// Summary is the check's own description of its result, if any.
+	Summary string
+	// JobID is the GitHub Actions job behind a check run, whose log
+	// JobLog reads; 0 for other checks.
+	JobID int64
+}
+
+// Repo names a GitHub repository.
+type Repo struct {
+	Owner, Name string
+}
+
+var gitHubRemote = regexp.MustCompile(`^(?:https?://(?:[^@/]+@)?github\.com/|ssh://git@github\.com/|git@github\.com:)([^/]+)/([^/]+?)(?:\.git)?/?$`)
+
+// ParseGitHubRemote returns the repository a GitHub remote URL points at,
+// and false for any other URL.
+func ParseGitHubRemote(remote string) (Repo, bool) {
+	m := gitHubRemote.FindStringSubmatch(strings.TrimSpace(remote))
+	if m == nil {
+		return Repo{}, false
+	}
+	return Repo{Owner: m[1], Name: m[2]}, true
+}
+
+// Aggregate folds checks into the commit's state: failed if any check
+// failed, else pending if any is still running, else succeeded.
+func Aggregate(checks []Check) State {
+	state := StateNone
+	for _, c := range checks {
+		switch {
+		case c.State == StateFailure:
+			return StateFailure
+		case c.State == StatePending:
+			state = StatePending
+		case c.State == StateSuccess && state == StateNone:
+			state = StateSuccess
+		}
+	}
+	return state
+}
+
+// Client calls the GitHub API.
+type Client struct {
+	BaseURL string
+	// Token authenticates the calls; empty calls anonymously, which only
+	// sees public repositories.
+	Token string
+	HTTP  *http.Client
+}
+
+// Checks returns the CI checks on commit sha.
+func (c *Client) Checks(ctx context.Context, repo Repo, sha string) ([]Check, error) {
+	var runs struct {
+		CheckRuns []struct {
+			ID         int64  `json:"id"`
+			Name       string `json:"name"`
+			Status     string `json:"status"`
+			Conclusion string `json:"conclusion"`
+			HTMLURL    string `json:"html_url"`
+			DetailsURL string `json:"details_url"`
+			App        struct {
+				Slug string `json:"slug"`
+			} `json:"app"`
+			Output struct {
+				Title   string `json:"title"`
+				Summary string `json:"summary"`
+			} `json:"output"`
+		} `json:"check_runs"`
+	}
+	if err := c.getJSON(ctx, repo, "commits/"+url.PathEscape(sha)+"/check-runs?per_page=100", &runs); err != nil {
+		return nil, err
+	}
+	var statuses struct {
+		Statuses []struct {
+			Context     string `json:"context"`
+			State       string `json:"state"`
+			TargetURL   string `json:"target_url"`
+			Description string `json:"description"`
+		} `json:"statuses"`
+	}
+	if err := c.getJSON(ctx, repo, "commits/"+url.PathEscape(sha)+"/status", &statuses); err != nil {
+		return nil, err
+	}
+
+	checks := make([]Check, 0, len(runs.CheckRuns)+len(statuses.Statuses))
+	for _, r := range runs.CheckRuns {
+		check := Check{Name: r.Name, State: checkRunState(r.Status, r.Conclusion), URL: r.HTMLURL}
+		if check.URL == "" {
+			check.URL = r.DetailsURL
+		}
+		check.Summary = strings.TrimSpace(strings.Join(nonEmpty(r.Output.Title, r.Output.Summary), "\n"))
+		if r.App.Slug == "github-actions" {
+			check.JobID = r.ID
+		}
+		checks = append(checks, check)
+	}
+	for _, s := range statuses.Statuses {
+		checks = append(checks, Check{Name: s.Context, State: commitStatusState(s.State), URL: s.TargetURL, Summary: s.Description})
+	}
+	return checks, nil
+}
+
+// JobLog returns the tail of a GitHub Actions job's log.
+func (c *Client) JobLog(ctx context.Context, repo Repo, jobID int64) (string, error) {
+	resp, err := c.get(ctx, repo, fmt.Sprintf("actions/jobs/%d/logs", jobID))
+	if err != nil {
+		return "", err
+	}
+	defer func() { _ = resp.Body.Close() }()
+	data, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return "", err
+	}
+	if len(data) > maxLogBytes {
+		data = data[len(data)-maxLogBytes:]
+		if i := bytes.IndexByte(data, '\n'); i >= 0 {
+			data = data[i+1:]
+		}
+	}
+	return string(data), nil
+}
+
+func (c *Client) getJSON(ctx context.Context, repo Repo, path string, v any) error {
+	resp, err := c.get(ctx, repo, path)
+	if err != nil {
+		return err
+	}
+	defer func() { _ = resp.Body.Close() }()
+	return json.NewDecoder(io.LimitReader(resp.Body, 8<<20)).Decode(v)
+}
+
+func (c *Client) get(ctx context.Context, repo Repo, path string) (*http.Response, error) {
+	base := c.BaseURL
+	if base == "" {
+		base = DefaultBaseURL
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet,
+		fmt.Sprintf("%s/repos/%s/%s/%s", strings.TrimRight(base, "/"), url.PathEscape(repo.Owner), url.PathEscape(repo.Name), path), nil)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Accept", "application/vnd.github+json")
+	req.Header.Set("X-GitHub-Api-Version", "2022-11-28")
+	if c.Token != "" {
+		req.Header.Set("Authorization", "Bearer "+c.Token)
+	}
+	client := c.HTTP
+	if client == nil {
+		client = http.DefaultClient
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode != http.StatusOK {
+		_ = resp.Body.Close()
+		return nil, fmt.Errorf("github %s: %s", path, resp.Status)
+	}
+	return resp, nil
+}
+
+func checkRunState(status, conclusion string) State {
+	if status != "completed" {
+		return StatePending
+	}
+	switch conclusion {
+	case "success", "neutral", "skipped":
+		return StateSuccess
+	case "failure", "timed_out", "cancelled", "action_required", "startup_failure":
+		return StateFailure
+	}
+	// stale, or a conclusion newer than this code.
+	return StatePending
+}
+
+func commitStatusState(state string) State {
+	switch state {
+	case "success":
+		return StateSuccess
+	case "failure", "error":
+		return StateFailure
+	}
+	return StatePending
+}
+
+func nonEmpty(ss ...string) []string {
+	var out []string
+	for _, s := range ss {
+		if s != "" {
+			out = append(out, s)
+		}
+	}
+	return out
+}
+
+// TokenFromGit asks git's credential helpers, as configured for the
+// repository at dir, for a github.com token: the same one git pushes
+// with. It returns "" when no helper has one, and never prompts.
+func TokenFromGit(ctx context.Context, dir string) string {
+	cmd := gitutil.NewGitCmd(ctx, "-C", dir, "credential", "fill")
+	cmd.Stdin = strings.NewReader("protocol=https\nhost=github.com\n\n")
+	var out bytes.Buffer
+	cmd.Stdout = &out
+	if err := cmd.Run(); err != nil {
+		return ""
+	}
+	for _, line := range strings.Split(out.String(), "\n") {
+		if v, ok := strings.CutPrefix(line, "password="); ok {
+			return v
+		}
+	}
+	return ""
+}
@@ -0,0 +1,311 @@
+// Package claudesim is a stand-in for the Claude Code CLI. It speaks the
+// same stream-json protocol on stdin/stdout -- the initialize handshake,
+// the control requests the worker sends, permission prompts answered
+// through control responses -- and plays scripted turns instead of calling
+// the API, so the worker's output handling and the frontend's rendering
+// can be exercised without an account or API spend.
+//
+// Each user message starts one turn. The scenario is picked by a keyword
+// in the prompt (see scenarios); a prompt with no keyword gets a plain
+// text reply that lists them.
+package claudesim
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/leapmux/leapmux/internal/util/id"
+)
+
+// DefaultPace is the delay between the lines of a turn.
+const DefaultPace = 300 * time.Millisecond
+
+// defaultModel is what the simulator reports when launched without --model.
+const defaultModel = "claude-sonnet-4-5"
+
+// Options configures a simulated session.
+type Options struct {
+	// Args are the CLI arguments the worker launched "claude" with. Only
+	// --model and --resume are honoured.
+	Args []string
+	// Pace is the delay between the lines of a turn; zero means
+	// DefaultPace. Tests pass a tiny value.
+	Pace time.Duration
+}
+
+// Run plays a simulated session until in is exhausted or ctx is done.
+func Run(ctx context.Context, in io.Reader, out io.Writer, opts Options) error {
+	s := newSession(out, opts)
+	ctx, cancel := context.WithCancel(ctx)
+
+	// Closing stdin ends the session: the turn in progress is abandoned,
+	// since no answer to its permission prompt can arrive any more.
+	turns := make(chan string, 16)
+	var wg sync.WaitGroup
+	wg.Go(func() { s.runTurns(ctx, turns) })
+	defer wg.Wait()
+	defer cancel()
+	defer close(turns)
+
+	scanner := bufio.NewScanner(in)
+	scanner.Buffer(make([]byte, 0, 64*1024), 16*1024*1024)
+	for scanner.Scan() {
+		var env struct {
+			Type      string          `json:"type"`
+			RequestID string          `json:"request_id"`
+			Request   json.RawMessage `json:"request"`
+			Response  json.RawMessage `json:"response"`
+			Message   json.RawMessage `json:"message"`
+		}
+		if err := json.Unmarshal(scanner.Bytes(), &env); err != nil {
+			continue
+		}
+		switch env.Type {
+		case "control_request":
+			s.handleControlRequest(env.RequestID, env.Request)
+		case "control_response":
+			s.handleControlResponse(env.Response)
+		case "user":
+			select {
+			case turns <- promptText(env.Message):
+			case <-ctx.Done():
+				return ctx.Err()
+			}
+		}
+	}
+	return scanner.Err()
+}
+
+// session is one simulated CLI process.
+type session struct {
+	pace time.Duration
+
+	outMu sync.Mutex
+	out   io.Writer
+
+	mu             sync.Mutex
+	sessionID      string
+	model          string
+	permissionMode string
+	// permissions holds the answer channel of each can_use_tool request
+	// the simulator is waiting on, by request id.
+	permissions map[string]chan permissionAnswer
+	// interrupt cancels the turn in progress, if any.
+	interrupt context.CancelFunc
+}
+
+type permissionAnswer struct {
+	allow   bool
+	message string
+}
+
+func newSession(out io.Writer, opts Options) *session {
+	s := &session{
+		pace:           opts.Pace,
+		out:            out,
+		sessionID:      id.Generate(),
+		model:          defaultModel,
+		permissionMode: "default",
+		permissions:    make(map[string]chan permissionAnswer),
+	}
+	if s.pace <= 0 {
+		s.pace = DefaultPace
+	}
+	for i := 0; i+1 < len(opts.Args); i++ {
+		switch opts.Args[i] {
+		case "--model":
+			s.model = opts.Args[i+1]
+		case "--resume":
+			s.sessionID = opts.Args[i+1]
+		}
+	}
+	return s
+}
+
+// emit writes one NDJSON line. Lines from the control handler and the
+// turn goroutine interleave, so each is written whole under outMu.
+func (s *session) emit(v any) {
+	b, err := json.Marshal(v)
+	if err != nil {
+		return
+	}
+	s.outMu.Lock()
+	defer s.outMu.Unlock()
+	_, _ = s.out.Write(append(b, '\n'))
+}
+
+func (s *session) controlSuccess(requestID string, response any) {
+	s.emit(map[string]any{
+		"type": "control_response",
+		"response": map[string]any{
+			"subtype":    "success",
+			"request_id": requestID,
+			"response":   response,
+		},
+	})
+}
+
+func (s *session) handleControlRequest(requestID string, raw json.RawMessage) {
+	var req struct {
+		Subtype string `json:"subtype"`
+		Mode    string `json:"mode"`
+		Model   string `json:"model"`
+	}
+	_ = json.Unmarshal(raw, &req)
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	switch req.Subtype {
+	case "initialize":
+		s.controlSuccess(requestID, map[string]any{
+			"output_style":            "default",
+			"available_output_styles": []string{"default", "Explanatory", "Learning"},
+			"fast_mode_state":         "off",
+			"models":                  simulatedModels,
+		})
+		s.emit(map[string]any{
+			"type":           "system",
+			"subtype":        "init",
+			"session_id":     s.sessionID,
+			"model":          s.model,
+			"permissionMode": s.permissionMode,
+			"tools":          []string{"Bash", "Read", "Edit", "TodoWrite", "ExitPlanMode"},
+		})
+	case "set_permission_mode":
+		s.permissionMode = req.Mode
+		s.controlSuccess(requestID, map[string]any{"mode": req.Mode})
+	case "set_model":
+		if req.Model != "" {
+			s.model = req.Model
+		}
+		s.controlSuccess(requestID, map[string]any{})
+	case "get_settings":
+		s.controlSuccess(requestID, map[string]any{
+			"effective": map[string]any{"outputStyle": "default", "fastMode": false, "alwaysThinkingEnabled": true},
+			"applied":   map[string]any{"model": s.model, "effort": "high"},
+		})
+	case "interrupt":
+		if s.interrupt != nil {
+			s.interrupt()
+		}
+		s.controlSuccess(requestID, map[string]any{})
+	default:
+		s.controlSuccess(requestID, map[string]any{})
+	}
+}
+
+func (s *session) handleControlResponse(raw json.RawMessage) {
+	var resp struct {
+		RequestID string `json:"request_id"`
+		Response  struct {
+			Behavior string `json:"behavior"`
+			Message  string `json:"message"`
+		} `json:"response"`
+	}
+	if err := json.Unmarshal(raw, &resp); err != nil {
+		return
+	}
+	s.mu.Lock()
+	ch, ok := s.permissions[resp.RequestID]
+	delete(s.permissions, resp.RequestID)
+	s.mu.Unlock()
+	if ok {
+		ch <- permissionAnswer{allow: resp.Response.Behavior == "allow", message: resp.Response.Message}
+	}
+}
+
+// runTurns plays queued prompts one at a time, as the CLI does.
+func (s *session) runTurns(ctx context.Context, turns <-chan string) {
+	for prompt := range turns {
+		turnCtx, cancel := context.WithCancel(ctx)
+		s.mu.Lock()
+		s.interrupt = cancel
+		s.mu.Unlock()
+
+		t := &turn{s: s, ctx: turnCtx, started: time.Now()}
+		err := scenarioFor(prompt).play(t, prompt)
+		// Any other error means the session is shutting down, with
+		// nothing left to report to.
+		if errors.Is(err, context.Canceled) && ctx.Err() == nil {
+			t.interrupted()
+		}
+
+		s.mu.Lock()
+		s.interrupt = nil
+		s.mu.Unlock()
+		cancel()
+	}
+}
+
+// promptText extracts the text of a stream-json user message, whose
+// content is either a string or an array of blocks.
+func promptText(raw json.RawMessage) string {
+	var msg struct {
+		Content json.RawMessage `json:"content"`
+	}
+	if err := json.Unmarshal(raw, &msg); err != nil {
+		return ""
+	}
+	var text string
+	if err := json.Unmarshal(msg.Content, &text); err == nil {
+		return text
+	}
+	var blocks []struct {
+		Type string `json:"type"`
+		Text string `json:"text"`
+	}
+	_ = json.Unmarshal(msg.Content, &blocks)
+	var parts []string
+	for _, b := range blocks {
+		if b.Type == "text" {
+			parts = append(parts, b.Text)
+		}
+	}
+	return strings.Join(parts, "\n")
+}
+
+// simulatedModels is the catalog the initialize response advertises.
+// Like the real CLI's, it lists aliases rather than full model ids.
+var simulatedModels = []map[string]any{
+	{
+		"value":                 "default",
+		"displayName":           "Default (recommended)",
+		"description":           "Simulated account default",
+		"supportsEffort":        true,
+		"supportedEffortLevels": []string{"low", "medium", "high"},
+	},
+	{
+		"value":                 "sonnet",
+		"displayName":           "Sonnet (simulated)",
+		"description":           "Simulated model; no API calls are made",
+		"supportsEffort":        true,
+		"supportedEffortLevels": []string{"low", "medium", "high"},
+	},
+	{
+		"value":          "haiku",
+		"displayName":    "Haiku (simulated)",
+		"description":    "Simulated model without effort levels",
+		"supportsEffort": false,
+	},
+}
+
+// usage is a plausible token count for a message of n characters.
+func usage(n int) map[string]any {
+	return map[string]any{
+		"input_tokens":                n/4 + 1200,
+		"output_tokens":               n/4 + 20,
+		"cache_read_input_tokens":     8000,
+		"cache_creation_input_tokens": 0,
+	}
+}
+
+func toolUseID() string { return "toolu_sim_" + id.Generate() }
+
+func messageID() string { return fmt.Sprintf("msg_sim_%s", id.Generate()) }
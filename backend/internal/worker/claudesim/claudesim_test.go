@@ -0,0 +1,234 @@
+package claudesim
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"io"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// simClient drives a simulated session the way the worker does: NDJSON
+// lines in, NDJSON lines out.
+type simClient struct {
+	t     *testing.T
+	in    *io.PipeWriter
+	lines chan map[string]any
+	done  chan error
+}
+
+func startSim(t *testing.T, args ...string) *simClient {
+	t.Helper()
+	inR, inW := io.Pipe()
+	outR, outW := io.Pipe()
+	c := &simClient{t: t, in: inW, lines: make(chan map[string]any, 64), done: make(chan error, 1)}
+	ctx, cancel := context.WithCancel(context.Background())
+	go func() {
+		c.done <- Run(ctx, inR, outW, Options{Args: args, Pace: time.Millisecond})
+		_ = outW.Close()
+	}()
+	go func() {
+		scanner := bufio.NewScanner(outR)
+		for scanner.Scan() {
+			var line map[string]any
+			if json.Unmarshal(scanner.Bytes(), &line) == nil {
+				c.lines <- line
+			}
+		}
+		close(c.lines)
+	}()
+	t.Cleanup(func() {
+		_ = inW.Close()
+		_ = outR.Close()
+		cancel()
+		<-c.done
+	})
+	return c
+}
+
+func (c *simClient) send(v any) {
+	c.t.Helper()
+	b, err := json.Marshal(v)
+	require.NoError(c.t, err)
+	_, err = c.in.Write(append(b, '\n'))
+	require.NoError(c.t, err)
+}
+
+func (c *simClient) control(requestID string, request map[string]any) {
+	c.send(map[string]any{"type": "control_request", "request_id": requestID, "request": request})
+}
+
+func (c *simClient) prompt(text string) {
+	c.send(map[string]any{"type": "user", "message": map[string]any{"role": "user", "content": text}})
+}
+
+func (c *simClient) answer(requestID, behavior string) {
+	c.send(map[string]any{
+		"type": "control_response",
+		"response": map[string]any{
+			"subtype":    "success",
+			"request_id": requestID,
+			"response":   map[string]any{"behavior": behavior},
+		},
+	})
+}
+
+// next returns the next line whose type is typ, skipping the others.
+func (c *simClient) next(typ string) map[string]any {
+	c.t.Helper()
+	timeout := time.After(5 * time.Second)
+	for {
+		select {
+		case line, ok := <-c.lines:
+			require.True(c.t, ok, "session ended waiting for %q", typ)
+			if line["type"] == typ {
+				return line
+			}
+		case <-timeout:
+			c.t.Fatalf("no %q line", typ)
+		}
+	}
+}
+
+func (c *simClient) permissionRequest() (requestID, toolName string) {
+	c.t.Helper()
+	line := c.next("control_request")
+	req := line["request"].(map[string]any)
+	require.Equal(c.t, "can_use_tool", req["subtype"])
+	return line["request_id"].(string), req["tool_name"].(string)
+}
+
+func TestRun_Handshake(t *testing.T) {
+	c := startSim(t, "--model", "claude-haiku-4-5", "--resume", "sess-1")
+
+	c.control("init", map[string]any{"subtype": "initialize"})
+	resp := c.next("control_response")["response"].(map[string]any)
+	assert.Equal(t, "init", resp["request_id"])
+	assert.NotEmpty(t, resp["response"].(map[string]any)["models"])
+	initLine := c.next("system")
+	assert.Equal(t, "init", initLine["subtype"])
+	assert.Equal(t, "sess-1", initLine["session_id"], "--resume keeps the session id")
+	assert.Equal(t, "claude-haiku-4-5", initLine["model"])
+
+	c.control("mode", map[string]any{"subtype": "set_permission_mode", "mode": "plan"})
+	resp = c.next("control_response")["response"].(map[string]any)
+	assert.Equal(t, "plan", resp["response"].(map[string]any)["mode"])
+
+	c.control("settings", map[string]any{"subtype": "get_settings"})
+	resp = c.next("control_response")["response"].(map[string]any)
+	applied := resp["response"].(map[string]any)["applied"].(map[string]any)
+	assert.Equal(t, "claude-haiku-4-5", applied["model"])
+}
+
+func TestRun_PlainReplyListsScenarios(t *testing.T) {
+	c := startSim(t)
+	c.prompt("hello")
+	msg := c.next("assistant")["message"].(map[string]any)
+	text := msg["content"].([]any)[0].(map[string]any)["text"].(string)
+	for _, sc := range scenarios {
+		assert.Contains(t, text, sc.keyword)
+	}
+	result := c.next("result")
+	assert.Equal(t, false, result["is_error"])
+}
+
+func TestRun_BashWaitsForPermission(t *testing.T) {
+	c := startSim(t)
+	c.prompt("run some bash")
+
+	requestID, tool := c.permissionRequest()
+	assert.Equal(t, "Bash", tool)
+	c.answer(requestID, "allow")
+
+	toolResult := c.next("user")["message"].(map[string]any)["content"].([]any)[0].(map[string]any)
+	assert.Equal(t, "tool_result", toolResult["type"])
+	assert.Equal(t, false, toolResult["is_error"])
+	assert.Equal(t, false, c.next("result")["is_error"])
+}
+
+func TestRun_BypassPermissionsSkipsThePrompt(t *testing.T) {
+	c := startSim(t)
+	c.control("mode", map[string]any{"subtype": "set_permission_mode", "mode": "bypassPermissions"})
+	c.next("control_response")
+	c.prompt("bash please")
+
+	for {
+		line := c.next("user")
+		content := line["message"].(map[string]any)["content"].([]any)[0].(map[string]any)
+		if content["type"] == "tool_result" {
+			assert.Equal(t, false, content["is_error"])
+			break
+		}
+	}
+	assert.Equal(t, false, c.next("result")["is_error"])
+}
+
+func TestRun_RejectedPlanStaysInPlanMode(t *testing.T) {
+	c := startSim(t)
+	c.prompt("make a plan")
+
+	requestID, tool := c.permissionRequest()
+	assert.Equal(t, "ExitPlanMode", tool)
+	c.answer(requestID, "deny")
+
+	toolResult := c.next("user")["message"].(map[string]any)["content"].([]any)[0].(map[string]any)
+	assert.Equal(t, true, toolResult["is_error"])
+	assert.Equal(t, false, c.next("result")["is_error"])
+}
+
+func TestRun_InterruptCancelsThePendingPrompt(t *testing.T) {
+	c := startSim(t)
+	c.prompt("bash")
+	requestID, _ := c.permissionRequest()
+
+	c.control("stop", map[string]any{"subtype": "interrupt"})
+	cancel := c.next("control_cancel_request")
+	assert.Equal(t, requestID, cancel["request_id"])
+	result := c.next("result")
+	assert.Equal(t, true, result["is_error"])
+	assert.Equal(t, "error_during_execution", result["subtype"])
+
+	// The session takes the next turn after an interrupt.
+	c.prompt("hello again")
+	assert.Equal(t, false, c.next("result")["is_error"])
+}
+
+func TestRun_RateLimitAndAPIError(t *testing.T) {
+	c := startSim(t)
+	c.prompt("rate")
+	info := c.next("rate_limit_event")["rate_limit_info"].(map[string]any)
+	assert.Equal(t, "allowed_warning", info["status"])
+	assert.Equal(t, false, c.next("result")["is_error"])
+
+	c.prompt("error")
+	result := c.next("result")
+	assert.Equal(t, true, result["is_error"])
+	assert.Contains(t, result["result"], "overloaded_error")
+}
+
+func TestRun_TodoProgresses(t *testing.T) {
+	c := startSim(t)
+	c.prompt("todo")
+
+	var last []any
+	// One update per step starting, then one marking the last done.
+	for range 4 {
+		msg := c.next("assistant")["message"].(map[string]any)
+		block := msg["content"].([]any)[0].(map[string]any)
+		require.Equal(t, "TodoWrite", block["name"])
+		last = block["input"].(map[string]any)["todos"].([]any)
+	}
+	for _, todo := range last {
+		assert.Equal(t, "completed", todo.(map[string]any)["status"])
+	}
+	assert.Equal(t, false, c.next("result")["is_error"])
+}
+
+func TestPromptText(t *testing.T) {
+	assert.Equal(t, "hi", promptText(json.RawMessage(`{"content":"hi"}`)))
+	assert.Equal(t, "a\nb", promptText(json.RawMessage(`{"content":[{"type":"text","text":"a"},{"type":"image"},{"type":"text","text":"b"}]}`)))
+}
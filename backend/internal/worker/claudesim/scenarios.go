@@ -0,0 +1,338 @@
+package claudesim
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// scenario is one scripted turn, chosen when its keyword appears in the
+// prompt.
+type scenario struct {
+	keyword     string
+	description string
+	play        func(t *turn, prompt string) error
+}
+
+// scenarios is checked in order; the first keyword found wins.
+var scenarios = []scenario{
+	{"plan", "plan mode: a plan to approve or reject through ExitPlanMode", playPlan},
+	{"bash", "a Bash tool call behind a permission prompt", playBash},
+	{"todo", "a TodoWrite checklist updated as the work proceeds", playTodo},
+	{"rate", "a rate-limit warning ahead of the reply", playRateLimit},
+	{"error", "an API error that ends the turn", playAPIError},
+}
+
+var plainReply = scenario{play: playPlain}
+
+func scenarioFor(prompt string) scenario {
+	lower := strings.ToLower(prompt)
+	for _, sc := range scenarios {
+		if strings.Contains(lower, sc.keyword) {
+			return sc
+		}
+	}
+	return plainReply
+}
+
+// turn emits the lines of one scripted turn.
+type turn struct {
+	s       *session
+	ctx     context.Context
+	started time.Time
+	turns   int
+	text    []string
+}
+
+// pause waits one pace step, or reports the turn was interrupted.
+func (t *turn) pause() error {
+	select {
+	case <-time.After(t.s.pace):
+		return nil
+	case <-t.ctx.Done():
+		return t.ctx.Err()
+	}
+}
+
+func (t *turn) assistant(content ...map[string]any) error {
+	if err := t.pause(); err != nil {
+		return err
+	}
+	t.s.mu.Lock()
+	model, sessionID := t.s.model, t.s.sessionID
+	t.s.mu.Unlock()
+	size := 0
+	for _, c := range content {
+		size += len(fmt.Sprint(c))
+	}
+	t.s.emit(map[string]any{
+		"type": "assistant",
+		"message": map[string]any{
+			"id":      messageID(),
+			"type":    "message",
+			"role":    "assistant",
+			"model":   model,
+			"content": content,
+			"usage":   usage(size),
+		},
+		"parent_tool_use_id": nil,
+		"session_id":         sessionID,
+	})
+	t.turns++
+	return nil
+}
+
+func (t *turn) say(text string) error {
+	t.text = append(t.text, text)
+	return t.assistant(map[string]any{"type": "text", "text": text})
+}
+
+func (t *turn) think(text string) error {
+	return t.assistant(map[string]any{"type": "thinking", "thinking": text, "signature": "sim"})
+}
+
+func (t *turn) toolUse(name string, input map[string]any) (string, error) {
+	toolID := toolUseID()
+	err := t.assistant(map[string]any{"type": "tool_use", "id": toolID, "name": name, "input": input})
+	return toolID, err
+}
+
+func (t *turn) toolResult(toolID, content string, isError bool, toolUseResult any) error {
+	if err := t.pause(); err != nil {
+		return err
+	}
+	line := map[string]any{
+		"type": "user",
+		"message": map[string]any{
+			"role": "user",
+			"content": []map[string]any{{
+				"type":        "tool_result",
+				"tool_use_id": toolID,
+				"content":     content,
+				"is_error":    isError,
+			}},
+		},
+		"parent_tool_use_id": nil,
+		"session_id":         t.s.sessionID,
+	}
+	if toolUseResult != nil {
+		line["tool_use_result"] = toolUseResult
+	}
+	t.s.emit(line)
+	return nil
+}
+
+// askPermission sends a can_use_tool request and waits for the answer the
+// user gives in the frontend.
+func (t *turn) askPermission(toolID, name string, input map[string]any) (permissionAnswer, error) {
+	requestID := "sim-" + toolID
+	ch := make(chan permissionAnswer, 1)
+	t.s.mu.Lock()
+	t.s.permissions[requestID] = ch
+	t.s.mu.Unlock()
+	t.s.emit(map[string]any{
+		"type":       "control_request",
+		"request_id": requestID,
+		"request": map[string]any{
+			"subtype":     "can_use_tool",
+			"tool_name":   name,
+			"input":       input,
+			"tool_use_id": toolID,
+		},
+	})
+	select {
+	case answer := <-ch:
+		return answer, nil
+	case <-t.ctx.Done():
+		t.s.mu.Lock()
+		delete(t.s.permissions, requestID)
+		t.s.mu.Unlock()
+		t.s.emit(map[string]any{"type": "control_cancel_request", "request_id": requestID})
+		return permissionAnswer{}, t.ctx.Err()
+	}
+}
+
+// result ends the turn.
+func (t *turn) result(isError bool, subtype, text string) {
+	if t.turns == 0 {
+		t.turns = 1
+	}
+	t.s.emit(map[string]any{
+		"type":            "result",
+		"subtype":         subtype,
+		"is_error":        isError,
+		"duration_ms":     time.Since(t.started).Milliseconds(),
+		"duration_api_ms": time.Since(t.started).Milliseconds(),
+		"num_turns":       t.turns,
+		"result":          text,
+		"session_id":      t.s.sessionID,
+		"total_cost_usd":  0,
+		"usage":           usage(len(text)),
+	})
+}
+
+func (t *turn) succeed() error {
+	t.result(false, "success", strings.Join(t.text, "\n\n"))
+	return nil
+}
+
+// interrupted reports a turn the user stopped, the way the CLI does.
+func (t *turn) interrupted() {
+	t.s.emit(map[string]any{
+		"type": "user",
+		"message": map[string]any{
+			"role":    "user",
+			"content": []map[string]any{{"type": "text", "text": "[Request interrupted by user]"}},
+		},
+		"session_id": t.s.sessionID,
+	})
+	t.result(true, "error_during_execution", "")
+}
+
+func playPlain(t *turn, prompt string) error {
+	var b strings.Builder
+	fmt.Fprintf(&b, "This is a simulated Claude Code session; no API calls are made. You said:\n\n> %s\n\n", strings.TrimSpace(prompt))
+	b.WriteString("Include one of these words in a prompt to play a scenario:\n")
+	for _, sc := range scenarios {
+		fmt.Fprintf(&b, "\n- **%s** -- %s", sc.keyword, sc.description)
+	}
+	if err := t.say(b.String()); err != nil {
+		return err
+	}
+	return t.succeed()
+}
+
+func playBash(t *turn, _ string) error {
+	if err := t.think("The user wants a command run. I'll list the working directory."); err != nil {
+		return err
+	}
+	input := map[string]any{"command": "ls -la", "description": "List files in the working directory"}
+	toolID, err := t.toolUse("Bash", input)
+	if err != nil {
+		return err
+	}
+	t.s.mu.Lock()
+	mode := t.s.permissionMode
+	t.s.mu.Unlock()
+	if mode != "bypassPermissions" {
+		answer, err := t.askPermission(toolID, "Bash", input)
+		if err != nil {
+			return err
+		}
+		if !answer.allow {
+			reason := answer.message
+			if reason == "" {
+				reason = "The user doesn't want to proceed with this tool use."
+			}
+			if err := t.toolResult(toolID, reason, true, "Error: "+reason); err != nil {
+				return err
+			}
+			if err := t.say("Understood -- I won't run that."); err != nil {
+				return err
+			}
+			return t.succeed()
+		}
+	}
+	out := "total 16\ndrwxr-xr-x  4 sim sim 4096 .\ndrwxr-xr-x 12 sim sim 4096 ..\n-rw-r--r--  1 sim sim  220 README.md\ndrwxr-xr-x  2 sim sim 4096 src"
+	if err := t.toolResult(toolID, out, false, map[string]any{"stdout": out, "stderr": "", "interrupted": false}); err != nil {
+		return err
+	}
+	if err := t.say("The directory holds a `README.md` and a `src/` directory."); err != nil {
+		return err
+	}
+	return t.succeed()
+}
+
+func playPlan(t *turn, _ string) error {
+	if err := t.think("I should look around before proposing changes."); err != nil {
+		return err
+	}
+	plan := "## Plan\n\n1. Read the existing module.\n2. Add the new function with tests.\n3. Run the test suite."
+	input := map[string]any{"plan": plan}
+	toolID, err := t.toolUse("ExitPlanMode", input)
+	if err != nil {
+		return err
+	}
+	answer, err := t.askPermission(toolID, "ExitPlanMode", input)
+	if err != nil {
+		return err
+	}
+	if !answer.allow {
+		reason := answer.message
+		if reason == "" {
+			reason = "The user doesn't want to proceed with this plan."
+		}
+		if err := t.toolResult(toolID, reason, true, "Error: "+reason); err != nil {
+			return err
+		}
+		if err := t.say("Okay, I'll stay in plan mode. What should change in the plan?"); err != nil {
+			return err
+		}
+		return t.succeed()
+	}
+	approved := "User has approved your plan. You can now start coding."
+	if err := t.toolResult(toolID, approved, false, map[string]any{"plan": plan, "isAgent": false}); err != nil {
+		return err
+	}
+	if err := t.say("Plan approved. Starting with step 1."); err != nil {
+		return err
+	}
+	return t.succeed()
+}
+
+func playTodo(t *turn, _ string) error {
+	steps := []string{"Read the code", "Write the change", "Run the tests"}
+	for done := 0; done <= len(steps); done++ {
+		todos := make([]map[string]any, len(steps))
+		for i, step := range steps {
+			status := "pending"
+			switch {
+			case i < done:
+				status = "completed"
+			case i == done:
+				status = "in_progress"
+			}
+			todos[i] = map[string]any{"content": step, "activeForm": step + " (in progress)", "status": status}
+		}
+		toolID, err := t.toolUse("TodoWrite", map[string]any{"todos": todos})
+		if err != nil {
+			return err
+		}
+		if err := t.toolResult(toolID, "Todos have been modified successfully.", false, nil); err != nil {
+			return err
+		}
+	}
+	if err := t.say("All three steps are done."); err != nil {
+		return err
+	}
+	return t.succeed()
+}
+
+func playRateLimit(t *turn, _ string) error {
+	if err := t.pause(); err != nil {
+		return err
+	}
+	t.s.emit(map[string]any{
+		"type": "rate_limit_event",
+		"rate_limit_info": map[string]any{
+			"status":        "allowed_warning",
+			"rateLimitType": "five_hour",
+			"utilization":   0.91,
+			"resetsAt":      time.Now().Add(2 * time.Hour).Unix(),
+		},
+		"session_id": t.s.sessionID,
+	})
+	if err := t.say("You're close to the five-hour usage limit, but this reply still went through."); err != nil {
+		return err
+	}
+	return t.succeed()
+}
+
+func playAPIError(t *turn, _ string) error {
+	if err := t.pause(); err != nil {
+		return err
+	}
+	t.result(true, "success", `API Error: 529 {"type":"error","error":{"type":"overloaded_error","message":"Overloaded"}}`)
+	return nil
+}
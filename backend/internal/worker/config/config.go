@@ -6,8 +6,10 @@ import (
 	"errors"
 	"flag"
 	"fmt"
+	"net/url"
 	"os"
 	"path/filepath"
+	"slices"
 	"strings"
 	"time"
 
@@ -15,7 +17,11 @@ import (
 	leapmuxv1 "github.com/leapmux/leapmux/generated/proto/leapmux/v1"
 	internalconfig "github.com/leapmux/leapmux/internal/config"
 	noiseutil "github.com/leapmux/leapmux/internal/noise"
+	"github.com/leapmux/leapmux/internal/util/agentlabels"
 	"github.com/leapmux/leapmux/internal/util/sqlitedb"
+	"github.com/leapmux/leapmux/internal/worker/archive"
+	"github.com/leapmux/leapmux/internal/worker/redact"
+	"github.com/leapmux/leapmux/internal/worker/transcribe"
 )
 
 const (
@@ -33,16 +39,65 @@ const (
 	// DefaultAPITimeoutSeconds is the default timeout (in seconds) for
 	// JSON-RPC requests to agent processes (e.g. turn/start, session/new).
 	DefaultAPITimeoutSeconds = 10
+
+	// Reconnect defaults. They match hub.DefaultReconnectPolicy.
+	defaultReconnectMinSeconds     = 1
+	defaultReconnectMaxSeconds     = 180
+	defaultReconnectJitterPercent  = 20
+	defaultHubHealthTimeoutSeconds = 15
+
+	// minHubHealthTimeoutSeconds keeps the health check clear of the
+	// worker's own ping cadence (a ping after 5s of quiet, checked every
+	// 2s), so a slow but healthy Hub does not trip it.
+	minHubHealthTimeoutSeconds = 10
+
+	// Context pressure defaults: warn at 80% of an agent's context window,
+	// and again at 95%.
+	defaultContextWarnPercent     = 80
+	defaultContextCriticalPercent = 95
+
+	// Anomaly defaults: generous enough that an ordinary turn, even a long
+	// one, never trips them.
+	defaultAnomalyMaxToolCalls        = 150
+	defaultAnomalyMaxRepeatedCommands = 5
+	defaultAnomalyMaxDeletedPaths     = 50
+	defaultAnomalyMaxTurnCostUSD      = 10.0
+
+	// Stuck turn defaults: a turn silent this long is almost certainly
+	// hung, not thinking; the default only says so in the chat.
+	defaultStuckTurnMinutes = 20
+	defaultStuckTurnAction  = "notify"
+
+	// defaultClaudeSessionRetentionDays matches Claude Code's own default
+	// cleanupPeriodDays, so collection never removes a transcript Claude
+	// Code would still have kept for a session it ran by itself.
+	defaultClaudeSessionRetentionDays = 30
 )
 
 // Config holds the worker's runtime configuration.
 type Config struct {
 	HubURL string `koanf:"hub" json:"hub_url"`
+	// HubFallback is a comma-separated list of other URLs of the same
+	// Hub, tried in order when HubURL cannot be reached.
+	HubFallback string `koanf:"hub_fallback" json:"hub_fallback"`
+	// ReconnectMinSeconds and ReconnectMaxSeconds bound the exponential
+	// backoff between reconnect rounds; ReconnectJitterPercent randomizes
+	// each delay by up to that much.
+	ReconnectMinSeconds    int `koanf:"reconnect_min_seconds" json:"reconnect_min_seconds"`
+	ReconnectMaxSeconds    int `koanf:"reconnect_max_seconds" json:"reconnect_max_seconds"`
+	ReconnectJitterPercent int `koanf:"reconnect_jitter_percent" json:"reconnect_jitter_percent"`
+	// HubHealthTimeoutSeconds drops a connection the Hub has sent nothing
+	// on for this long. 0 disables the check.
+	HubHealthTimeoutSeconds int `koanf:"hub_health_timeout_seconds" json:"hub_health_timeout_seconds"`
 	// RegistrationKey is the bearer credential the worker presents to
 	// WorkerConnectorService.Register. Required on first run; ignored on
 	// subsequent runs if the worker is already registered. Not persisted.
-	RegistrationKey            string `koanf:"registration_key" json:"-"`
-	Name                       string `koanf:"name" json:"name"`
+	RegistrationKey string `koanf:"registration_key" json:"-"`
+	Name            string `koanf:"name" json:"name"`
+	// Labels is a comma-separated list of labels the Hub matches against a
+	// repository's preferred worker labels when it picks where to check
+	// the repository out (e.g. "gpu,linux").
+	Labels                     string `koanf:"labels" json:"labels"`
 	DataDir                    string `koanf:"data_dir" json:"data_dir"`
 	DBMaxConns                 int    `koanf:"db_max_conns" json:"db_max_conns"`
 	DBCacheSize                int    `koanf:"db_cache_size" json:"db_cache_size"`
@@ -53,6 +108,243 @@ type Config struct {
 	LogLevel                   string `koanf:"log_level" json:"log_level"`
 	EncryptionMode             string `koanf:"encryption_mode" json:"encryption_mode"`
 	UseLoginShell              bool   `koanf:"use_login_shell" json:"use_login_shell"`
+	// Simulate replaces the claude CLI with the scripted stand-in in
+	// internal/worker/claudesim, for developing against realistic agent
+	// output without an account or API spend.
+	Simulate bool `koanf:"simulate" json:"simulate"`
+	// CaptureAgentOutput is a directory each agent process's raw stdout
+	// is copied to, one file per process, for `leapmux worker replay`.
+	// Empty disables capture.
+	CaptureAgentOutput string `koanf:"capture_agent_output" json:"capture_agent_output"`
+	// ClaudeOutputSchema is how Claude Code output that strays from the
+	// envelope schema the worker expects is reported: "lenient" logs it,
+	// "strict" also posts it into the agent's chat. Empty is lenient.
+	ClaudeOutputSchema string `koanf:"claude_output_schema" json:"claude_output_schema"`
+	// ForbiddenPermissionModes is a comma-separated list of permission modes
+	// no agent on this worker may switch to (e.g. "bypassPermissions" on a
+	// production machine).
+	ForbiddenPermissionModes string `koanf:"forbidden_permission_modes" json:"forbidden_permission_modes"`
+	// DefaultPermissionMode is the mode new agents start in when the client
+	// does not request one. Empty keeps each provider's own default.
+	DefaultPermissionMode string `koanf:"default_permission_mode" json:"default_permission_mode"`
+	// IdleParkMinutes stops an agent's subprocess after this many
+	// minutes without activity; its next message resumes it. 0 disables.
+	IdleParkMinutes int `koanf:"idle_park_minutes" json:"idle_park_minutes"`
+	// IdleParkExcludeProviders is a comma-separated list of agent
+	// providers (e.g. "claude,codex") whose agents are never parked.
+	IdleParkExcludeProviders string `koanf:"idle_park_exclude_providers" json:"idle_park_exclude_providers"`
+	// IdleParkExcludeWorkspaces is a comma-separated list of workspace
+	// ids whose agents are never parked.
+	IdleParkExcludeWorkspaces string `koanf:"idle_park_exclude_workspaces" json:"idle_park_exclude_workspaces"`
+	// ContextWarnPercent and ContextCriticalPercent post a chat warning
+	// when an agent's context reaches that share of its window. 0 disables
+	// either.
+	ContextWarnPercent     int `koanf:"context_warn_percent" json:"context_warn_percent"`
+	ContextCriticalPercent int `koanf:"context_critical_percent" json:"context_critical_percent"`
+	// ContextCriticalAction runs once the turn that reached
+	// ContextCriticalPercent ends: "compact" compacts the context,
+	// "checkpoint" asks the agent to write down where it stands. Empty
+	// only warns.
+	ContextCriticalAction string `koanf:"context_critical_action" json:"context_critical_action"`
+	// AnomalyMaxToolCalls, AnomalyMaxRepeatedCommands,
+	// AnomalyMaxDeletedPaths and AnomalyMaxTurnCostUSD flag a turn that
+	// makes that many tool calls, runs one shell command that many times,
+	// deletes that many paths, or costs that many dollars. 0 disables
+	// each.
+	AnomalyMaxToolCalls        int     `koanf:"anomaly_max_tool_calls" json:"anomaly_max_tool_calls"`
+	AnomalyMaxRepeatedCommands int     `koanf:"anomaly_max_repeated_commands" json:"anomaly_max_repeated_commands"`
+	AnomalyMaxDeletedPaths     int     `koanf:"anomaly_max_deleted_paths" json:"anomaly_max_deleted_paths"`
+	AnomalyMaxTurnCostUSD      float64 `koanf:"anomaly_max_turn_cost_usd" json:"anomaly_max_turn_cost_usd"`
+	// AnomalyWebhookURL, when set, is sent each flagged anomaly as a JSON
+	// POST.
+	AnomalyWebhookURL string `koanf:"anomaly_webhook_url" json:"anomaly_webhook_url"`
+	// StuckTurnMinutes flags a turn that has produced no output for this
+	// many minutes since its input was delivered. 0 disables.
+	StuckTurnMinutes int `koanf:"stuck_turn_minutes" json:"stuck_turn_minutes"`
+	// StuckTurnAction is what happens to a flagged turn: "notify" only
+	// says so in the chat, "interrupt" interrupts it, "restart" restarts
+	// the agent and asks it to continue.
+	StuckTurnAction string `koanf:"stuck_turn_action" json:"stuck_turn_action"`
+	// ClaudeSessionRetentionDays removes Claude Code session transcripts
+	// and plans no agent on this worker uses once they have gone this many
+	// days without a change. 0 keeps them.
+	ClaudeSessionRetentionDays int `koanf:"claude_session_retention_days" json:"claude_session_retention_days"`
+	// PersistTerminals runs terminal shells under tmux so they survive a
+	// worker restart and reattach when their tabs come back.
+	PersistTerminals bool `koanf:"persist_terminals" json:"persist_terminals"`
+	// PluginDir holds exec plugins the worker runs and sends agent events
+	// to (see internal/plugin). Empty runs none.
+	PluginDir string `koanf:"plugin_dir" json:"plugin_dir"`
+	// RedactSecrets replaces credentials in agent output with placeholders
+	// before it is stored. RedactPatternsFile adds detectors, one
+	// name=regexp per line.
+	RedactSecrets      bool   `koanf:"redact_secrets" json:"redact_secrets"`
+	RedactPatternsFile string `koanf:"redact_patterns_file" json:"redact_patterns_file"`
+	// ClassifyPII tags stored messages that hold email addresses or phone
+	// numbers. PIIPatternsFile adds classifiers, one tag=regexp per line.
+	// ClassifiedRetentionDays deletes tagged messages after this many
+	// days; 0 keeps them as long as the rest of the transcript.
+	ClassifyPII             bool   `koanf:"classify_pii" json:"classify_pii"`
+	PIIPatternsFile         string `koanf:"pii_patterns_file" json:"pii_patterns_file"`
+	ClassifiedRetentionDays int    `koanf:"classified_retention_days" json:"classified_retention_days"`
+	// TranscriptionBackend turns on voice notes: "whisper-cpp" runs a
+	// local whisper.cpp binary, "api" posts to a transcription endpoint.
+	// Empty disables voice notes.
+	TranscriptionBackend       string `koanf:"transcription_backend" json:"transcription_backend"`
+	TranscriptionWhisperBinary string `koanf:"transcription_whisper_binary" json:"transcription_whisper_binary"`
+	TranscriptionWhisperModel  string `koanf:"transcription_whisper_model" json:"transcription_whisper_model"`
+	TranscriptionAPIURL        string `koanf:"transcription_api_url" json:"transcription_api_url"`
+	TranscriptionAPIKey        string `koanf:"transcription_api_key" json:"-"`
+	TranscriptionAPIModel      string `koanf:"transcription_api_model" json:"transcription_api_model"`
+	// ArchiveBucket turns on shipping closed agents' transcripts to an
+	// S3-compatible bucket at ArchiveEndpoint. ArchiveRetentionDays
+	// deletes shipped objects after this many days; 0 keeps them.
+	ArchiveEndpoint        string `koanf:"archive_endpoint" json:"archive_endpoint"`
+	ArchiveBucket          string `koanf:"archive_bucket" json:"archive_bucket"`
+	ArchivePrefix          string `koanf:"archive_prefix" json:"archive_prefix"`
+	ArchiveRegion          string `koanf:"archive_region" json:"archive_region"`
+	ArchiveAccessKeyID     string `koanf:"archive_access_key_id" json:"archive_access_key_id"`
+	ArchiveSecretAccessKey string `koanf:"archive_secret_access_key" json:"-"`
+	ArchiveRetentionDays   int    `koanf:"archive_retention_days" json:"archive_retention_days"`
+}
+
+// Redactor builds the redactor RedactSecrets and RedactPatternsFile ask
+// for: the built-in detectors, then the file's. It returns nil when
+// redaction is off.
+func (c *Config) Redactor() (*redact.Redactor, error) {
+	if !c.RedactSecrets {
+		return nil, nil
+	}
+	detectors := redact.Defaults()
+	if c.RedactPatternsFile != "" {
+		extra, err := redact.LoadPatterns(c.RedactPatternsFile)
+		if err != nil {
+			return nil, fmt.Errorf("redact patterns: %w", err)
+		}
+		detectors = append(detectors, extra...)
+	}
+	return redact.New(detectors...), nil
+}
+
+// Classifier builds the classifier ClassifyPII and PIIPatternsFile ask
+// for: the built-in classifiers, then the file's. It returns nil when
+// classification is off.
+func (c *Config) Classifier() (*redact.Classifier, error) {
+	if !c.ClassifyPII {
+		return nil, nil
+	}
+	detectors := redact.PIIDefaults()
+	if c.PIIPatternsFile != "" {
+		extra, err := redact.LoadPatterns(c.PIIPatternsFile)
+		if err != nil {
+			return nil, fmt.Errorf("pii patterns: %w", err)
+		}
+		detectors = append(detectors, extra...)
+	}
+	return redact.NewClassifier(detectors...), nil
+}
+
+// ClassifiedRetention returns ClassifiedRetentionDays as a duration.
+func (c *Config) ClassifiedRetention() time.Duration {
+	return time.Duration(c.ClassifiedRetentionDays) * 24 * time.Hour
+}
+
+// ArchiveConfig collects the archive settings for archive.New.
+func (c *Config) ArchiveConfig() archive.Config {
+	return archive.Config{
+		Endpoint:        c.ArchiveEndpoint,
+		Bucket:          c.ArchiveBucket,
+		Prefix:          c.ArchivePrefix,
+		Region:          c.ArchiveRegion,
+		AccessKeyID:     c.ArchiveAccessKeyID,
+		SecretAccessKey: c.ArchiveSecretAccessKey,
+	}
+}
+
+// ArchiveRetention returns ArchiveRetentionDays as a duration.
+func (c *Config) ArchiveRetention() time.Duration {
+	return time.Duration(c.ArchiveRetentionDays) * 24 * time.Hour
+}
+
+// TranscriptionConfig collects the transcription settings for
+// transcribe.New.
+func (c *Config) TranscriptionConfig() transcribe.Config {
+	return transcribe.Config{
+		Backend:       c.TranscriptionBackend,
+		WhisperBinary: c.TranscriptionWhisperBinary,
+		WhisperModel:  c.TranscriptionWhisperModel,
+		APIURL:        c.TranscriptionAPIURL,
+		APIKey:        c.TranscriptionAPIKey,
+		APIModel:      c.TranscriptionAPIModel,
+	}
+}
+
+// ForbiddenPermissionModeList returns ForbiddenPermissionModes split into
+// its trimmed, non-empty entries.
+func (c *Config) ForbiddenPermissionModeList() []string {
+	return splitList(c.ForbiddenPermissionModes)
+}
+
+// LabelList returns Labels split into its trimmed, non-empty entries,
+// lowercased.
+func (c *Config) LabelList() []string {
+	labels := splitList(c.Labels)
+	for i, l := range labels {
+		labels[i] = strings.ToLower(l)
+	}
+	return labels
+}
+
+// HubFallbackList returns HubFallback split into its trimmed, non-empty
+// entries.
+func (c *Config) HubFallbackList() []string {
+	return splitList(c.HubFallback)
+}
+
+// IdleParkAfter returns IdleParkMinutes as a duration.
+func (c *Config) IdleParkAfter() time.Duration {
+	return time.Duration(c.IdleParkMinutes) * time.Minute
+}
+
+// StuckTurnAfter returns StuckTurnMinutes as a duration.
+func (c *Config) StuckTurnAfter() time.Duration {
+	return time.Duration(c.StuckTurnMinutes) * time.Minute
+}
+
+// ClaudeSessionRetention returns ClaudeSessionRetentionDays as a duration.
+func (c *Config) ClaudeSessionRetention() time.Duration {
+	return time.Duration(c.ClaudeSessionRetentionDays) * 24 * time.Hour
+}
+
+// IdleParkExcludedProviders parses IdleParkExcludeProviders.
+func (c *Config) IdleParkExcludedProviders() ([]leapmuxv1.AgentProvider, error) {
+	var providers []leapmuxv1.AgentProvider
+	for _, name := range splitList(c.IdleParkExcludeProviders) {
+		p, ok := agentlabels.ParseProvider(name)
+		if !ok {
+			return nil, fmt.Errorf("unknown agent provider %q", name)
+		}
+		providers = append(providers, p)
+	}
+	return providers, nil
+}
+
+// IdleParkExcludedWorkspaceList returns IdleParkExcludeWorkspaces
+// split into its trimmed, non-empty entries.
+func (c *Config) IdleParkExcludedWorkspaceList() []string {
+	return splitList(c.IdleParkExcludeWorkspaces)
+}
+
+// splitList splits a comma-separated config value into its trimmed,
+// non-empty entries.
+func splitList(s string) []string {
+	var items []string
+	for _, item := range strings.Split(s, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			items = append(items, item)
+		}
+	}
+	return items
 }
 
 // EncryptionModeProto returns the protobuf EncryptionMode value.
@@ -90,6 +382,35 @@ func (c *Config) APITimeout() time.Duration {
 	return time.Duration(v) * time.Second
 }
 
+// ReconnectMinInterval returns the first reconnect backoff delay.
+func (c *Config) ReconnectMinInterval() time.Duration {
+	v := c.ReconnectMinSeconds
+	if v <= 0 {
+		v = defaultReconnectMinSeconds
+	}
+	return time.Duration(v) * time.Second
+}
+
+// ReconnectMaxInterval returns the longest reconnect backoff delay.
+func (c *Config) ReconnectMaxInterval() time.Duration {
+	v := c.ReconnectMaxSeconds
+	if v <= 0 {
+		v = defaultReconnectMaxSeconds
+	}
+	return time.Duration(v) * time.Second
+}
+
+// ReconnectJitter returns ReconnectJitterPercent as a fraction.
+func (c *Config) ReconnectJitter() float64 {
+	return float64(c.ReconnectJitterPercent) / 100
+}
+
+// HubHealthTimeout returns HubHealthTimeoutSeconds as a duration; zero
+// disables the health check.
+func (c *Config) HubHealthTimeout() time.Duration {
+	return time.Duration(c.HubHealthTimeoutSeconds) * time.Second
+}
+
 // State holds the worker's persistent state (saved to disk after registration).
 type State struct {
 	WorkerID  string `json:"worker_id"`
@@ -162,8 +483,14 @@ func Load(args []string) (*Config, bool, error) {
 	fs := flag.NewFlagSet("leapmux worker", flag.ContinueOnError)
 	fs.String("config", defaultConfigFile, "path to config file")
 	fs.String("hub", defaultHubURL, "Hub server URL (http[s]://..., unix:<socket-path>, or npipe:<pipe-name>)")
+	fs.String("hub-fallback", "", "comma-separated other URLs of the same Hub, tried in order when -hub is unreachable")
+	fs.Int("reconnect-min-seconds", defaultReconnectMinSeconds, "first delay before reconnecting after every Hub URL failed")
+	fs.Int("reconnect-max-seconds", defaultReconnectMaxSeconds, "longest delay between reconnect rounds")
+	fs.Int("reconnect-jitter-percent", defaultReconnectJitterPercent, "randomize each reconnect delay by up to this percentage")
+	fs.Int("hub-health-timeout-seconds", defaultHubHealthTimeoutSeconds, "reconnect when the Hub has sent nothing for this many seconds (0 = never)")
 	fs.String("registration-key", "", "registration key from the hub UI (required on first run)")
 	fs.String("name", "", "worker display name (default: hostname)")
+	fs.String("labels", "", "comma-separated labels matched against repositories' preferred worker labels (e.g. gpu,linux)")
 	fs.String("data-dir", ".", "data directory")
 	fs.Int("db-max-conns", sqlitedb.DefaultMaxConns, "maximum number of open database connections")
 	fs.Int("db-cache-size", 0, "SQLite page cache size (positive = pages, negative = KiB, e.g. -65536 = 64 MiB; 0 = default)")
@@ -174,6 +501,45 @@ func Load(args []string) (*Config, bool, error) {
 	fs.String("log-level", defaultLogLevel, "log level (debug, info, warn, error)")
 	fs.String("encryption-mode", "post-quantum", "encryption mode (classic, post-quantum)")
 	fs.Bool("use-login-shell", true, "wrap claude invocation in user's login shell")
+	fs.String("capture-agent-output", "", "directory to save each agent process's raw output to, for \"leapmux worker replay\" (debugging)")
+	fs.String("claude-output-schema", "lenient", "how to report Claude Code output that does not match the expected schema: lenient (log it) or strict (also post it in the chat)")
+	fs.Bool("simulate", false, "run Claude Code agents against a scripted simulator instead of the claude CLI (development)")
+	fs.String("forbidden-permission-modes", "", "comma-separated permission modes agents may not use (e.g. bypassPermissions)")
+	fs.String("default-permission-mode", "", "permission mode for new agents that do not request one (default: provider default)")
+	fs.Int("idle-park-minutes", 0, "stop agents idle for this many minutes; they resume on the next message (0 = never)")
+	fs.String("idle-park-exclude-providers", "", "comma-separated agent providers never parked when idle (e.g. claude,codex)")
+	fs.String("idle-park-exclude-workspaces", "", "comma-separated workspace IDs whose agents are never parked when idle")
+	fs.Int("context-warn-percent", defaultContextWarnPercent, "warn in the chat when an agent's context reaches this percentage of its window (0 = never)")
+	fs.Int("context-critical-percent", defaultContextCriticalPercent, "warn again, and take -context-critical-action, at this percentage (0 = never)")
+	fs.String("context-critical-action", "", "what to do after the turn that reaches -context-critical-percent: compact, checkpoint, or empty to only warn")
+	fs.Int("anomaly-max-tool-calls", defaultAnomalyMaxToolCalls, "flag a turn that makes this many tool calls (0 = never)")
+	fs.Int("anomaly-max-repeated-commands", defaultAnomalyMaxRepeatedCommands, "flag a turn that runs the same shell command this many times (0 = never)")
+	fs.Int("anomaly-max-deleted-paths", defaultAnomalyMaxDeletedPaths, "flag a turn whose shell commands delete this many paths (0 = never)")
+	fs.Float64("anomaly-max-turn-cost-usd", defaultAnomalyMaxTurnCostUSD, "flag a turn that costs this many US dollars (0 = never)")
+	fs.String("anomaly-webhook-url", "", "URL each flagged anomaly is POSTed to as JSON (empty = chat notification only)")
+	fs.Int("stuck-turn-minutes", defaultStuckTurnMinutes, "flag a turn that produces no output for this many minutes (0 = never)")
+	fs.String("stuck-turn-action", defaultStuckTurnAction, "what to do with a flagged turn: notify, interrupt, or restart")
+	fs.Int("claude-session-retention-days", defaultClaudeSessionRetentionDays, "remove Claude Code sessions and plans no agent uses after this many days without a change (0 = never)")
+	fs.Bool("persist-terminals", false, "run terminal shells under tmux so they survive a worker restart (needs tmux 3.0+)")
+	fs.String("plugin-dir", "", "directory of exec plugins to run and send agent events to (empty = none)")
+	fs.Bool("redact-secrets", true, "replace credentials in agent output with placeholders before it is stored")
+	fs.String("redact-patterns-file", "", "file of extra secret patterns to redact, one name=regexp per line")
+	fs.Bool("classify-pii", true, "tag stored messages that hold email addresses or phone numbers")
+	fs.String("pii-patterns-file", "", "file of extra data classifiers to tag messages with, one tag=regexp per line")
+	fs.Int("classified-retention-days", 0, "delete tagged messages after this many days (0 = keep them like the rest of the transcript)")
+	fs.String("transcription-backend", "", "voice note transcription backend (whisper-cpp, api; empty = voice notes disabled)")
+	fs.String("transcription-whisper-binary", "", "whisper.cpp CLI for the whisper-cpp backend (default: whisper-cli on PATH)")
+	fs.String("transcription-whisper-model", "", "ggml model file for the whisper-cpp backend")
+	fs.String("transcription-api-url", "", "OpenAI-compatible transcription endpoint for the api backend")
+	fs.String("transcription-api-key", "", "bearer token for the transcription endpoint")
+	fs.String("transcription-api-model", "", "model for the api backend (default: whisper-1)")
+	fs.String("archive-endpoint", "", "S3-compatible endpoint to ship closed agents' transcripts to (e.g. https://s3.us-east-1.amazonaws.com)")
+	fs.String("archive-bucket", "", "bucket to ship closed agents' transcripts to (empty = no archive)")
+	fs.String("archive-prefix", "", "key prefix for shipped objects")
+	fs.String("archive-region", "", "signing region of the archive bucket (default: us-east-1)")
+	fs.String("archive-access-key-id", "", "access key ID for the archive bucket")
+	fs.String("archive-secret-access-key", "", "secret access key for the archive bucket")
+	fs.Int("archive-retention-days", 0, "delete shipped objects from the bucket after this many days (0 = keep them)")
 	showVersion := fs.Bool("version", false, "print version and exit")
 	usageCategories := map[string]string{
 		"config":                        "Common options",
@@ -181,10 +547,55 @@ func Load(args []string) (*Config, bool, error) {
 		"hub":                           "Worker options",
 		"registration-key":              "Worker options",
 		"name":                          "Worker options",
+		"labels":                        "Worker options",
 		"data-dir":                      "Worker options",
 		"log-level":                     "Worker options",
 		"encryption-mode":               "Worker options",
 		"use-login-shell":               "Worker options",
+		"simulate":                      "Worker options",
+		"capture-agent-output":          "Worker options",
+		"claude-output-schema":          "Worker options",
+		"claude-session-retention-days": "Worker options",
+		"persist-terminals":             "Worker options",
+		"plugin-dir":                    "Worker options",
+		"hub-fallback":                  "Hub connection options",
+		"reconnect-min-seconds":         "Hub connection options",
+		"reconnect-max-seconds":         "Hub connection options",
+		"reconnect-jitter-percent":      "Hub connection options",
+		"hub-health-timeout-seconds":    "Hub connection options",
+		"forbidden-permission-modes":    "Agent guardrail options",
+		"default-permission-mode":       "Agent guardrail options",
+		"idle-park-minutes":             "Agent guardrail options",
+		"idle-park-exclude-providers":   "Agent guardrail options",
+		"idle-park-exclude-workspaces":  "Agent guardrail options",
+		"context-warn-percent":          "Agent guardrail options",
+		"context-critical-percent":      "Agent guardrail options",
+		"context-critical-action":       "Agent guardrail options",
+		"anomaly-max-tool-calls":        "Agent guardrail options",
+		"anomaly-max-repeated-commands": "Agent guardrail options",
+		"anomaly-max-deleted-paths":     "Agent guardrail options",
+		"anomaly-max-turn-cost-usd":     "Agent guardrail options",
+		"anomaly-webhook-url":           "Agent guardrail options",
+		"stuck-turn-minutes":            "Agent guardrail options",
+		"stuck-turn-action":             "Agent guardrail options",
+		"redact-secrets":                "Agent guardrail options",
+		"redact-patterns-file":          "Agent guardrail options",
+		"classify-pii":                  "Agent guardrail options",
+		"pii-patterns-file":             "Agent guardrail options",
+		"classified-retention-days":     "Agent guardrail options",
+		"transcription-backend":         "Voice note options",
+		"transcription-whisper-binary":  "Voice note options",
+		"transcription-whisper-model":   "Voice note options",
+		"transcription-api-url":         "Voice note options",
+		"transcription-api-key":         "Voice note options",
+		"transcription-api-model":       "Voice note options",
+		"archive-endpoint":              "Archive options",
+		"archive-bucket":                "Archive options",
+		"archive-prefix":                "Archive options",
+		"archive-region":                "Archive options",
+		"archive-access-key-id":         "Archive options",
+		"archive-secret-access-key":     "Archive options",
+		"archive-retention-days":        "Archive options",
 		"max-incomplete-chunked":        "Timeout and limit options",
 		"agent-startup-timeout-seconds": "Timeout and limit options",
 		"api-timeout-seconds":           "Timeout and limit options",
@@ -203,8 +614,14 @@ func Load(args []string) (*Config, bool, error) {
 	// Flag name -> koanf key mapping.
 	fieldMap := map[string]string{
 		"hub":                           "hub",
+		"hub-fallback":                  "hub_fallback",
+		"reconnect-min-seconds":         "reconnect_min_seconds",
+		"reconnect-max-seconds":         "reconnect_max_seconds",
+		"reconnect-jitter-percent":      "reconnect_jitter_percent",
+		"hub-health-timeout-seconds":    "hub_health_timeout_seconds",
 		"registration-key":              "registration_key",
 		"name":                          "name",
+		"labels":                        "labels",
 		"data-dir":                      "data_dir",
 		"db-max-conns":                  "db_max_conns",
 		"db-cache-size":                 "db_cache_size",
@@ -215,12 +632,57 @@ func Load(args []string) (*Config, bool, error) {
 		"log-level":                     "log_level",
 		"encryption-mode":               "encryption_mode",
 		"use-login-shell":               "use_login_shell",
+		"simulate":                      "simulate",
+		"capture-agent-output":          "capture_agent_output",
+		"claude-output-schema":          "claude_output_schema",
+		"forbidden-permission-modes":    "forbidden_permission_modes",
+		"default-permission-mode":       "default_permission_mode",
+		"idle-park-minutes":             "idle_park_minutes",
+		"idle-park-exclude-providers":   "idle_park_exclude_providers",
+		"idle-park-exclude-workspaces":  "idle_park_exclude_workspaces",
+		"context-warn-percent":          "context_warn_percent",
+		"context-critical-percent":      "context_critical_percent",
+		"context-critical-action":       "context_critical_action",
+		"anomaly-max-tool-calls":        "anomaly_max_tool_calls",
+		"anomaly-max-repeated-commands": "anomaly_max_repeated_commands",
+		"anomaly-max-deleted-paths":     "anomaly_max_deleted_paths",
+		"anomaly-max-turn-cost-usd":     "anomaly_max_turn_cost_usd",
+		"anomaly-webhook-url":           "anomaly_webhook_url",
+		"stuck-turn-minutes":            "stuck_turn_minutes",
+		"stuck-turn-action":             "stuck_turn_action",
+		"claude-session-retention-days": "claude_session_retention_days",
+		"persist-terminals":             "persist_terminals",
+		"plugin-dir":                    "plugin_dir",
+		"redact-secrets":                "redact_secrets",
+		"redact-patterns-file":          "redact_patterns_file",
+		"classify-pii":                  "classify_pii",
+		"pii-patterns-file":             "pii_patterns_file",
+		"classified-retention-days":     "classified_retention_days",
+		"transcription-backend":         "transcription_backend",
+		"transcription-whisper-binary":  "transcription_whisper_binary",
+		"transcription-whisper-model":   "transcription_whisper_model",
+		"transcription-api-url":         "transcription_api_url",
+		"transcription-api-key":         "transcription_api_key",
+		"transcription-api-model":       "transcription_api_model",
+		"archive-endpoint":              "archive_endpoint",
+		"archive-bucket":                "archive_bucket",
+		"archive-prefix":                "archive_prefix",
+		"archive-region":                "archive_region",
+		"archive-access-key-id":         "archive_access_key_id",
+		"archive-secret-access-key":     "archive_secret_access_key",
+		"archive-retention-days":        "archive_retention_days",
 	}
 
 	defaults := map[string]interface{}{
 		"hub":                           defaultHubURL,
+		"hub_fallback":                  "",
+		"reconnect_min_seconds":         defaultReconnectMinSeconds,
+		"reconnect_max_seconds":         defaultReconnectMaxSeconds,
+		"reconnect_jitter_percent":      defaultReconnectJitterPercent,
+		"hub_health_timeout_seconds":    defaultHubHealthTimeoutSeconds,
 		"registration_key":              "",
 		"name":                          "",
+		"labels":                        "",
 		"data_dir":                      ".",
 		"db_max_conns":                  sqlitedb.DefaultMaxConns,
 		"db_cache_size":                 0,
@@ -231,6 +693,45 @@ func Load(args []string) (*Config, bool, error) {
 		"log_level":                     defaultLogLevel,
 		"encryption_mode":               "post-quantum",
 		"use_login_shell":               true,
+		"simulate":                      false,
+		"capture_agent_output":          "",
+		"claude_output_schema":          "lenient",
+		"forbidden_permission_modes":    "",
+		"default_permission_mode":       "",
+		"idle_park_minutes":             0,
+		"idle_park_exclude_providers":   "",
+		"idle_park_exclude_workspaces":  "",
+		"context_warn_percent":          defaultContextWarnPercent,
+		"context_critical_percent":      defaultContextCriticalPercent,
+		"context_critical_action":       "",
+		"anomaly_max_tool_calls":        defaultAnomalyMaxToolCalls,
+		"anomaly_max_repeated_commands": defaultAnomalyMaxRepeatedCommands,
+		"anomaly_max_deleted_paths":     defaultAnomalyMaxDeletedPaths,
+		"anomaly_max_turn_cost_usd":     defaultAnomalyMaxTurnCostUSD,
+		"anomaly_webhook_url":           "",
+		"stuck_turn_minutes":            defaultStuckTurnMinutes,
+		"stuck_turn_action":             defaultStuckTurnAction,
+		"claude_session_retention_days": defaultClaudeSessionRetentionDays,
+		"persist_terminals":             false,
+		"plugin_dir":                    "",
+		"redact_secrets":                true,
+		"redact_patterns_file":          "",
+		"classify_pii":                  true,
+		"pii_patterns_file":             "",
+		"classified_retention_days":     0,
+		"transcription_backend":         "",
+		"transcription_whisper_binary":  "",
+		"transcription_whisper_model":   "",
+		"transcription_api_url":         "",
+		"transcription_api_key":         "",
+		"transcription_api_model":       "",
+		"archive_endpoint":              "",
+		"archive_bucket":                "",
+		"archive_prefix":                "",
+		"archive_region":                "",
+		"archive_access_key_id":         "",
+		"archive_secret_access_key":     "",
+		"archive_retention_days":        0,
 	}
 
 	k := koanf.New(".")
@@ -254,6 +755,9 @@ func Load(args []string) (*Config, bool, error) {
 var workerFlagCategoryOrder = []string{
 	"Common options",
 	"Worker options",
+	"Hub connection options",
+	"Agent guardrail options",
+	"Voice note options",
 	"Timeout and limit options",
 	"SQLite database options",
 }
@@ -270,6 +774,74 @@ func (c *Config) Validate() error {
 		c.Name = hostname
 	}
 
+	if c.DefaultPermissionMode != "" && slices.Contains(c.ForbiddenPermissionModeList(), c.DefaultPermissionMode) {
+		return fmt.Errorf("default permission mode %q is forbidden", c.DefaultPermissionMode)
+	}
+
+	if c.ReconnectMaxInterval() < c.ReconnectMinInterval() {
+		return fmt.Errorf("reconnect max seconds must not be below reconnect min seconds")
+	}
+	if c.ReconnectJitterPercent < 0 || c.ReconnectJitterPercent > 100 {
+		return fmt.Errorf("reconnect jitter percent must be between 0 and 100")
+	}
+	if c.HubHealthTimeoutSeconds < 0 || (c.HubHealthTimeoutSeconds > 0 && c.HubHealthTimeoutSeconds < minHubHealthTimeoutSeconds) {
+		return fmt.Errorf("hub health timeout must be 0 or at least %d seconds", minHubHealthTimeoutSeconds)
+	}
+
+	if c.IdleParkMinutes < 0 {
+		return fmt.Errorf("idle park minutes must not be negative")
+	}
+	if _, err := c.IdleParkExcludedProviders(); err != nil {
+		return fmt.Errorf("idle park exclusions: %w", err)
+	}
+	if c.ContextWarnPercent < 0 || c.ContextWarnPercent > 100 || c.ContextCriticalPercent < 0 || c.ContextCriticalPercent > 100 {
+		return fmt.Errorf("context pressure percentages must be between 0 and 100")
+	}
+	if c.ContextWarnPercent > 0 && c.ContextCriticalPercent > 0 && c.ContextCriticalPercent < c.ContextWarnPercent {
+		return fmt.Errorf("context critical percent must not be below context warn percent")
+	}
+	switch c.ContextCriticalAction {
+	case "", "compact", "checkpoint":
+	default:
+		return fmt.Errorf("unknown context critical action %q", c.ContextCriticalAction)
+	}
+	if c.AnomalyMaxToolCalls < 0 || c.AnomalyMaxRepeatedCommands < 0 || c.AnomalyMaxDeletedPaths < 0 || c.AnomalyMaxTurnCostUSD < 0 {
+		return fmt.Errorf("anomaly limits must not be negative")
+	}
+	if c.AnomalyWebhookURL != "" {
+		if u, err := url.Parse(c.AnomalyWebhookURL); err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+			return fmt.Errorf("anomaly webhook URL must be an http or https URL")
+		}
+	}
+	if c.StuckTurnMinutes < 0 {
+		return fmt.Errorf("stuck turn minutes must not be negative")
+	}
+	switch c.StuckTurnAction {
+	case "", "notify", "interrupt", "restart":
+	default:
+		return fmt.Errorf("unknown stuck turn action %q", c.StuckTurnAction)
+	}
+	switch c.ClaudeOutputSchema {
+	case "", "lenient", "strict":
+	default:
+		return fmt.Errorf("unknown claude output schema mode %q", c.ClaudeOutputSchema)
+	}
+	if c.ClaudeSessionRetentionDays < 0 {
+		return fmt.Errorf("claude session retention days must not be negative")
+	}
+	if c.ClassifiedRetentionDays < 0 {
+		return fmt.Errorf("classified retention days must not be negative")
+	}
+	if _, err := transcribe.New(c.TranscriptionConfig()); err != nil {
+		return fmt.Errorf("transcription: %w", err)
+	}
+	if _, err := archive.New(c.ArchiveConfig()); err != nil {
+		return fmt.Errorf("archive: %w", err)
+	}
+	if c.ArchiveRetentionDays < 0 {
+		return fmt.Errorf("archive retention days must not be negative")
+	}
+
 	// Ensure data dir exists.
 	if err := os.MkdirAll(c.DataDir, 0o750); err != nil {
 		return fmt.Errorf("create data dir: %w", err)
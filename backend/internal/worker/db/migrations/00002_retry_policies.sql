@@ -0,0 +1,20 @@
+-- +goose Up
+
+-- attempts counts consecutive auto-continue retries for (agent, reason). It
+-- keeps climbing across fired schedules (a retried turn that fails again is
+-- the next attempt, not the first) and is reset when a turn completes without
+-- the matching failure. The retry policy's max_attempts is checked against it.
+ALTER TABLE auto_continue_schedules ADD COLUMN attempts INTEGER NOT NULL DEFAULT 0;
+
+-- Per-workspace retry policy (workspace_id is a hub-owned ID, no local FK).
+-- policy is the protojson encoding of leapmuxv1.RetryPolicy. A workspace with
+-- no row uses the built-in defaults.
+CREATE TABLE workspace_retry_policies (
+    workspace_id TEXT PRIMARY KEY,
+    policy       TEXT NOT NULL,
+    updated_at   DATETIME NOT NULL DEFAULT (strftime('%Y-%m-%dT%H:%M:%fZ','now'))
+);
+
+-- +goose Down
+DROP TABLE IF EXISTS workspace_retry_policies;
+ALTER TABLE auto_continue_schedules DROP COLUMN attempts;
@@ -0,0 +1,29 @@
+-- +goose Up
+
+-- Per-workspace model routing rules (workspace_id is a hub-owned ID, no local
+-- FK). policy is the protojson encoding of leapmuxv1.ModelRoutingPolicy. A
+-- workspace with no row does no routing.
+CREATE TABLE workspace_model_routing (
+    workspace_id TEXT PRIMARY KEY,
+    policy       TEXT NOT NULL,
+    updated_at   DATETIME NOT NULL DEFAULT (strftime('%Y-%m-%dT%H:%M:%fZ','now'))
+);
+
+-- The model each turn was delivered on, keyed by the user message that
+-- started it, so cost reporting can attribute usage after routing rules
+-- moved the agent between models. base_model is the model the agent was on
+-- before a prompt rule routed the turn; the next turn that matches no rule
+-- switches back to it.
+CREATE TABLE agent_turn_models (
+    agent_id   TEXT NOT NULL REFERENCES agents(id) ON DELETE CASCADE,
+    message_id TEXT NOT NULL,
+    model      TEXT NOT NULL,
+    base_model TEXT NOT NULL DEFAULT '',
+    routed     INTEGER NOT NULL DEFAULT 0,
+    created_at DATETIME NOT NULL DEFAULT (strftime('%Y-%m-%dT%H:%M:%fZ','now')),
+    PRIMARY KEY (agent_id, message_id)
+);
+
+-- +goose Down
+DROP TABLE IF EXISTS agent_turn_models;
+DROP TABLE IF EXISTS workspace_model_routing;
@@ -0,0 +1,44 @@
+-- +goose Up
+
+-- Per-workspace plan review requirement (workspace_id is a hub-owned ID, no
+-- local FK). A workspace with no row executes approved plans immediately.
+CREATE TABLE workspace_plan_review_policies (
+    workspace_id       TEXT PRIMARY KEY,
+    required_approvals INTEGER NOT NULL,
+    updated_at         DATETIME NOT NULL DEFAULT (strftime('%Y-%m-%dT%H:%M:%fZ','now'))
+);
+
+-- A plan held back from execution until enough reviewers approve it, keyed
+-- by the ExitPlanMode control request it answers. request_payload and
+-- tool_name are the pending request's render context, kept so a rejection
+-- can still be translated into the provider's deny after the request row is
+-- gone. status is 'pending', 'approved', or 'rejected'.
+CREATE TABLE plan_reviews (
+    agent_id           TEXT NOT NULL REFERENCES agents(id) ON DELETE CASCADE,
+    request_id         TEXT NOT NULL,
+    tool_name          TEXT NOT NULL DEFAULT '',
+    tool_use_id        TEXT NOT NULL DEFAULT '',
+    request_payload    BLOB NOT NULL,
+    target_mode        TEXT NOT NULL,
+    required_approvals INTEGER NOT NULL,
+    status             TEXT NOT NULL DEFAULT 'pending',
+    created_at         DATETIME NOT NULL DEFAULT (strftime('%Y-%m-%dT%H:%M:%fZ','now')),
+    resolved_at        DATETIME,
+    PRIMARY KEY (agent_id, request_id)
+);
+
+CREATE TABLE plan_review_votes (
+    agent_id   TEXT NOT NULL,
+    request_id TEXT NOT NULL,
+    user_id    TEXT NOT NULL,
+    approved   INTEGER NOT NULL,
+    comment    TEXT NOT NULL DEFAULT '',
+    created_at DATETIME NOT NULL DEFAULT (strftime('%Y-%m-%dT%H:%M:%fZ','now')),
+    PRIMARY KEY (agent_id, request_id, user_id),
+    FOREIGN KEY (agent_id, request_id) REFERENCES plan_reviews(agent_id, request_id) ON DELETE CASCADE
+);
+
+-- +goose Down
+DROP TABLE IF EXISTS plan_review_votes;
+DROP TABLE IF EXISTS plan_reviews;
+DROP TABLE IF EXISTS workspace_plan_review_policies;
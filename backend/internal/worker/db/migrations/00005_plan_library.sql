@@ -0,0 +1,27 @@
+-- +goose Up
+
+-- The per-workspace plan library (workspace_id is a hub-owned ID, no local
+-- FK). Each save appends a revision; plans are never edited in place.
+CREATE TABLE workspace_plans (
+    id           TEXT PRIMARY KEY,
+    workspace_id TEXT NOT NULL,
+    title        TEXT NOT NULL DEFAULT '',
+    created_at   DATETIME NOT NULL DEFAULT (strftime('%Y-%m-%dT%H:%M:%fZ','now')),
+    updated_at   DATETIME NOT NULL DEFAULT (strftime('%Y-%m-%dT%H:%M:%fZ','now'))
+);
+CREATE INDEX idx_workspace_plans_workspace ON workspace_plans(workspace_id, updated_at);
+
+-- source_agent_id is informational: the agent may since have been closed
+-- and deleted, so it is not a foreign key.
+CREATE TABLE plan_revisions (
+    plan_id         TEXT NOT NULL REFERENCES workspace_plans(id) ON DELETE CASCADE,
+    revision        INTEGER NOT NULL,
+    content         TEXT NOT NULL,
+    source_agent_id TEXT NOT NULL DEFAULT '',
+    created_at      DATETIME NOT NULL DEFAULT (strftime('%Y-%m-%dT%H:%M:%fZ','now')),
+    PRIMARY KEY (plan_id, revision)
+);
+
+-- +goose Down
+DROP TABLE IF EXISTS plan_revisions;
+DROP TABLE IF EXISTS workspace_plans;
@@ -0,0 +1,27 @@
+-- +goose Up
+
+-- Sub-agents an agent launched (Claude Code Task/Agent tool uses), keyed by
+-- the launching tool_use id. turn_message_id is the user message that
+-- started the parent turn (see agent_turn_models); parent_tool_use_id is
+-- the enclosing run of a nested sub-agent. Token usage accumulates as the
+-- sub-agent's messages arrive and is kept apart from the parent's.
+CREATE TABLE agent_sub_agent_runs (
+    agent_id                    TEXT NOT NULL REFERENCES agents(id) ON DELETE CASCADE,
+    tool_use_id                 TEXT NOT NULL,
+    parent_tool_use_id          TEXT NOT NULL DEFAULT '',
+    turn_message_id             TEXT NOT NULL DEFAULT '',
+    subagent_type               TEXT NOT NULL DEFAULT '',
+    description                 TEXT NOT NULL DEFAULT '',
+    status                      TEXT NOT NULL DEFAULT 'running',
+    input_tokens                INTEGER NOT NULL DEFAULT 0,
+    output_tokens               INTEGER NOT NULL DEFAULT 0,
+    cache_creation_input_tokens INTEGER NOT NULL DEFAULT 0,
+    cache_read_input_tokens     INTEGER NOT NULL DEFAULT 0,
+    started_at                  DATETIME NOT NULL DEFAULT (strftime('%Y-%m-%dT%H:%M:%fZ','now')),
+    ended_at                    DATETIME,
+    PRIMARY KEY (agent_id, tool_use_id)
+);
+CREATE INDEX idx_agent_sub_agent_runs_turn ON agent_sub_agent_runs(agent_id, turn_message_id);
+
+-- +goose Down
+DROP TABLE IF EXISTS agent_sub_agent_runs;
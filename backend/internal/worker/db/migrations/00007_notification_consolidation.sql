@@ -0,0 +1,14 @@
+-- +goose Up
+
+-- Per-workspace notification consolidation rules (workspace_id is a
+-- hub-owned ID, no local FK). policy is the protojson encoding of
+-- leapmuxv1.NotificationConsolidationPolicy. A workspace with no row uses
+-- the built-in consolidation.
+CREATE TABLE workspace_notification_consolidation (
+    workspace_id TEXT PRIMARY KEY,
+    policy       TEXT NOT NULL,
+    updated_at   DATETIME NOT NULL DEFAULT (strftime('%Y-%m-%dT%H:%M:%fZ','now'))
+);
+
+-- +goose Down
+DROP TABLE IF EXISTS workspace_notification_consolidation;
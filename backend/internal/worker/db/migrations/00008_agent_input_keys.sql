@@ -0,0 +1,17 @@
+-- +goose Up
+
+-- Idempotency keys for SendAgentMessage. The first send carrying a key
+-- claims (agent_id, idempotency_key) via ClaimAgentInputKey; a retry with
+-- the same key loses the claim and is acknowledged as a duplicate without
+-- being persisted or written to the agent's stdin again. message_id is the
+-- row the winning send created, echoed back to the retry. Keys live as
+-- long as their agent (ON DELETE CASCADE).
+CREATE TABLE agent_input_keys (
+    agent_id        TEXT NOT NULL REFERENCES agents(id) ON DELETE CASCADE,
+    idempotency_key TEXT NOT NULL,
+    message_id      TEXT NOT NULL,
+    PRIMARY KEY (agent_id, idempotency_key)
+);
+
+-- +goose Down
+DROP TABLE IF EXISTS agent_input_keys;
@@ -0,0 +1,11 @@
+-- +goose Up
+
+-- created_by is the user who opened the agent ('' for agents opened before
+-- it was recorded), so ListAllAgents can filter by creator. The index backs
+-- ListAllAgents' keyset order.
+ALTER TABLE agents ADD COLUMN created_by TEXT NOT NULL DEFAULT '';
+CREATE INDEX idx_agents_created_at ON agents(created_at DESC, id DESC);
+
+-- +goose Down
+DROP INDEX IF EXISTS idx_agents_created_at;
+ALTER TABLE agents DROP COLUMN created_by;
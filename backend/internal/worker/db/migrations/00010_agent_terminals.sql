@@ -0,0 +1,10 @@
+-- +goose Up
+
+-- agent_id links a terminal to the agent whose command it runs ('' for a
+-- terminal a user opened), so closing the agent can close its terminals.
+ALTER TABLE terminals ADD COLUMN agent_id TEXT NOT NULL DEFAULT '';
+CREATE INDEX idx_terminals_agent_id ON terminals(agent_id) WHERE agent_id != '';
+
+-- +goose Down
+DROP INDEX IF EXISTS idx_terminals_agent_id;
+ALTER TABLE terminals DROP COLUMN agent_id;
@@ -0,0 +1,26 @@
+-- +goose Up
+
+-- Per-workspace setup script (workspace_id is a hub-owned ID, no local FK).
+-- policy is the protojson encoding of leapmuxv1.WorkspaceProvisioning. A
+-- workspace with no row provisions nothing.
+CREATE TABLE workspace_provisioning (
+    workspace_id TEXT PRIMARY KEY,
+    policy       TEXT NOT NULL,
+    updated_at   DATETIME NOT NULL DEFAULT (strftime('%Y-%m-%dT%H:%M:%fZ','now'))
+);
+
+-- One row per working directory the workspace's script last succeeded in.
+-- script_sha256 is the hash of the script that ran, so editing the script
+-- sets every directory up again. terminal_id is the setup tab it ran in.
+CREATE TABLE workspace_provisioning_runs (
+    workspace_id  TEXT NOT NULL,
+    working_dir   TEXT NOT NULL,
+    script_sha256 TEXT NOT NULL,
+    terminal_id   TEXT NOT NULL,
+    finished_at   DATETIME NOT NULL DEFAULT (strftime('%Y-%m-%dT%H:%M:%fZ','now')),
+    PRIMARY KEY (workspace_id, working_dir)
+);
+
+-- +goose Down
+DROP TABLE IF EXISTS workspace_provisioning_runs;
+DROP TABLE IF EXISTS workspace_provisioning;
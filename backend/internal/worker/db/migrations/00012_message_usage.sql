@@ -0,0 +1,19 @@
+-- +goose Up
+
+-- Token usage and cost attributed to a single message: an assistant
+-- message's API call, or a turn-end result's whole turn. Kept beside the
+-- messages row rather than in it because few rows carry usage. cost_usd is
+-- 0 when the provider reported no cost for the message.
+CREATE TABLE message_usage (
+    message_id                  TEXT PRIMARY KEY REFERENCES messages(id) ON DELETE CASCADE,
+    agent_id                    TEXT NOT NULL REFERENCES agents(id) ON DELETE CASCADE,
+    input_tokens                INTEGER NOT NULL DEFAULT 0,
+    output_tokens               INTEGER NOT NULL DEFAULT 0,
+    cache_creation_input_tokens INTEGER NOT NULL DEFAULT 0,
+    cache_read_input_tokens     INTEGER NOT NULL DEFAULT 0,
+    cost_usd                    REAL NOT NULL DEFAULT 0
+);
+CREATE INDEX idx_message_usage_agent ON message_usage(agent_id);
+
+-- +goose Down
+DROP TABLE IF EXISTS message_usage;
@@ -0,0 +1,13 @@
+-- +goose Up
+
+-- Per-workspace agent defaults (workspace_id is a hub-owned ID, no local FK).
+-- defaults is the protojson encoding of leapmuxv1.WorkspaceAgentDefaults. A
+-- workspace with no row leaves new agents to the org's and user's defaults.
+CREATE TABLE workspace_agent_defaults (
+    workspace_id TEXT PRIMARY KEY,
+    defaults     TEXT NOT NULL,
+    updated_at   DATETIME NOT NULL DEFAULT (strftime('%Y-%m-%dT%H:%M:%fZ','now'))
+);
+
+-- +goose Down
+DROP TABLE IF EXISTS workspace_agent_defaults;
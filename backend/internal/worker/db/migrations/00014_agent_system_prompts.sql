@@ -0,0 +1,16 @@
+-- +goose Up
+
+-- The library system prompt an agent was opened with (see
+-- leapmuxv1.SystemPromptRef). content is the worker's own copy of the
+-- version attached, so every relaunch runs with the same text however the
+-- library entry changes later. Kept beside the agents row rather than in
+-- it because few agents carry one.
+CREATE TABLE agent_system_prompts (
+    agent_id       TEXT PRIMARY KEY REFERENCES agents(id) ON DELETE CASCADE,
+    prompt_id      TEXT NOT NULL,
+    prompt_version INTEGER NOT NULL,
+    content        TEXT NOT NULL
+);
+
+-- +goose Down
+DROP TABLE IF EXISTS agent_system_prompts;
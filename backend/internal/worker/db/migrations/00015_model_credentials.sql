@@ -0,0 +1,22 @@
+-- +goose Up
+
+-- The org model credential an agent was opened with. The secret itself is
+-- kept in the data directory, one file per credential, so a rotated secret
+-- reaches every agent using it; credential_name is the name at open time,
+-- for usage reports after the credential is renamed or deleted on the hub.
+CREATE TABLE agent_model_credentials (
+    agent_id        TEXT PRIMARY KEY REFERENCES agents(id) ON DELETE CASCADE,
+    credential_id   TEXT NOT NULL,
+    credential_name TEXT NOT NULL,
+    kind            INTEGER NOT NULL
+);
+CREATE INDEX idx_agent_model_credentials_credential ON agent_model_credentials(credential_id);
+
+-- turn marks a turn-end result's whole-turn total, which repeats the usage
+-- of the turn's own assistant messages: a sum over turn rows counts each
+-- API call once.
+ALTER TABLE message_usage ADD COLUMN turn INTEGER NOT NULL DEFAULT 0;
+
+-- +goose Down
+ALTER TABLE message_usage DROP COLUMN turn;
+DROP TABLE IF EXISTS agent_model_credentials;
@@ -0,0 +1,23 @@
+-- +goose Up
+
+-- Recorded metric series. Each row aggregates the samples of one metric in
+-- one bucket: resolution is a MetricResolution (1 minute, 2 hour, 3 day)
+-- and bucket_start the bucket's first instant. Rows are folded into the
+-- next coarser resolution as they age; count, sum, min and max merge
+-- exactly, so a folded bucket equals one recorded at that width.
+-- workspace_id is '' for the worker's own series.
+CREATE TABLE metric_buckets (
+    metric       TEXT NOT NULL,
+    workspace_id TEXT NOT NULL DEFAULT '',
+    resolution   INTEGER NOT NULL,
+    bucket_start DATETIME NOT NULL,
+    count        INTEGER NOT NULL,
+    sum          REAL NOT NULL,
+    min          REAL NOT NULL,
+    max          REAL NOT NULL,
+    PRIMARY KEY (metric, workspace_id, resolution, bucket_start)
+);
+CREATE INDEX idx_metric_buckets_resolution ON metric_buckets(resolution, bucket_start);
+
+-- +goose Down
+DROP TABLE IF EXISTS metric_buckets;
@@ -0,0 +1,19 @@
+-- +goose Up
+
+-- Agent stdout lines that were not valid JSON, kept instead of dropped so
+-- a session loses nothing to a format bug: an admin can inspect them and,
+-- once the parser is fixed, replay them through the agent's output
+-- handler. replayed_at is set once a line has been replayed. Each agent
+-- keeps only its newest lines (see PruneAgentOutputDeadLetters).
+CREATE TABLE agent_output_dead_letters (
+    id          INTEGER PRIMARY KEY AUTOINCREMENT,
+    agent_id    TEXT NOT NULL REFERENCES agents(id) ON DELETE CASCADE,
+    content     BLOB NOT NULL,
+    error       TEXT NOT NULL DEFAULT '',
+    created_at  DATETIME NOT NULL DEFAULT (strftime('%Y-%m-%dT%H:%M:%fZ','now')),
+    replayed_at DATETIME
+);
+CREATE INDEX idx_agent_output_dead_letters_agent ON agent_output_dead_letters(agent_id, id);
+
+-- +goose Down
+DROP TABLE IF EXISTS agent_output_dead_letters;
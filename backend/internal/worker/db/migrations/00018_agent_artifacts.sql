@@ -0,0 +1,27 @@
+-- +goose Up
+
+-- The file patterns an agent collects as artifacts at the end of each
+-- turn: a JSON array of globs relative to its working directory.
+CREATE TABLE agent_artifact_patterns (
+    agent_id TEXT PRIMARY KEY REFERENCES agents(id) ON DELETE CASCADE,
+    patterns TEXT NOT NULL DEFAULT '[]'
+);
+
+-- Collected artifacts. Each row is one snapshot of a file matching the
+-- agent's patterns, taken at a turn end when the file had changed since
+-- its last snapshot; the bytes live in the worker's data directory under
+-- artifacts/<agent_id>/<id>. Each agent keeps only its newest snapshots
+-- (see PruneAgentArtifacts).
+CREATE TABLE agent_artifacts (
+    id         INTEGER PRIMARY KEY AUTOINCREMENT,
+    agent_id   TEXT NOT NULL REFERENCES agents(id) ON DELETE CASCADE,
+    path       TEXT NOT NULL,
+    size       INTEGER NOT NULL,
+    sha256     TEXT NOT NULL,
+    created_at DATETIME NOT NULL DEFAULT (strftime('%Y-%m-%dT%H:%M:%fZ','now'))
+);
+CREATE INDEX idx_agent_artifacts_agent_path ON agent_artifacts(agent_id, path, id);
+
+-- +goose Down
+DROP TABLE IF EXISTS agent_artifacts;
+DROP TABLE IF EXISTS agent_artifact_patterns;
@@ -0,0 +1,25 @@
+-- +goose Up
+
+-- Test runs read out of the agent's shell tool output (see the testruns
+-- package), one row per tool call that ran go test, pytest, jest or a test
+-- script and printed a summary. turn_message_id is the user message that
+-- started the turn (see agent_turn_models). coverage_percent is meaningful
+-- only when has_coverage is 1.
+CREATE TABLE agent_test_runs (
+    id               INTEGER PRIMARY KEY AUTOINCREMENT,
+    agent_id         TEXT NOT NULL REFERENCES agents(id) ON DELETE CASCADE,
+    turn_message_id  TEXT NOT NULL DEFAULT '',
+    tool_use_id      TEXT NOT NULL,
+    runner           TEXT NOT NULL,
+    command          TEXT NOT NULL,
+    passed           INTEGER NOT NULL,
+    failed           INTEGER NOT NULL,
+    skipped          INTEGER NOT NULL,
+    has_coverage     INTEGER NOT NULL DEFAULT 0,
+    coverage_percent REAL NOT NULL DEFAULT 0,
+    created_at       DATETIME NOT NULL DEFAULT (strftime('%Y-%m-%dT%H:%M:%fZ','now'))
+);
+CREATE INDEX idx_agent_test_runs_turn ON agent_test_runs(agent_id, turn_message_id);
+
+-- +goose Down
+DROP TABLE IF EXISTS agent_test_runs;
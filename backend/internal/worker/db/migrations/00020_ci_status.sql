@@ -0,0 +1,28 @@
+-- +goose Up
+
+-- Per-workspace CI polling policy (workspace_id is a hub-owned ID, no local
+-- FK). policy is the protojson encoding of leapmuxv1.WorkspaceCIPolicy. A
+-- workspace with no row is not polled.
+CREATE TABLE workspace_ci_policies (
+    workspace_id TEXT PRIMARY KEY,
+    policy       TEXT NOT NULL,
+    updated_at   DATETIME NOT NULL DEFAULT (strftime('%Y-%m-%dT%H:%M:%fZ','now'))
+);
+
+-- Last polled CI status of each agent's branch head. status is the protojson
+-- encoding of leapmuxv1.AgentCIStatus. follow_up_sha is the last failing
+-- commit whose logs were sent to the agent, and follow_ups counts such
+-- commits since the branch last passed; the policy's max_follow_ups is
+-- checked against it.
+CREATE TABLE agent_ci_status (
+    agent_id      TEXT PRIMARY KEY REFERENCES agents(id) ON DELETE CASCADE,
+    commit_sha    TEXT NOT NULL,
+    status        TEXT NOT NULL,
+    follow_up_sha TEXT NOT NULL DEFAULT '',
+    follow_ups    INTEGER NOT NULL DEFAULT 0,
+    updated_at    DATETIME NOT NULL DEFAULT (strftime('%Y-%m-%dT%H:%M:%fZ','now'))
+);
+
+-- +goose Down
+DROP TABLE IF EXISTS agent_ci_status;
+DROP TABLE IF EXISTS workspace_ci_policies;
@@ -0,0 +1,19 @@
+-- +goose Up
+
+-- Team-analytics events that no other table keeps: kind is 'interrupt' (the
+-- user interrupted a turn), 'approval' (the user answered a control request,
+-- latency_ms after it was raised) or 'pull_request' (a shell call of the
+-- agent's opened one; detail is its URL). Turns and cost come from
+-- message_usage.
+CREATE TABLE agent_activity (
+    id         INTEGER PRIMARY KEY AUTOINCREMENT,
+    agent_id   TEXT NOT NULL REFERENCES agents(id) ON DELETE CASCADE,
+    kind       TEXT NOT NULL,
+    latency_ms INTEGER NOT NULL DEFAULT 0,
+    detail     TEXT NOT NULL DEFAULT '',
+    created_at DATETIME NOT NULL DEFAULT (strftime('%Y-%m-%dT%H:%M:%fZ','now'))
+);
+CREATE INDEX idx_agent_activity_created_at ON agent_activity(created_at);
+
+-- +goose Down
+DROP TABLE IF EXISTS agent_activity;
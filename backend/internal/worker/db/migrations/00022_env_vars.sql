@@ -0,0 +1,22 @@
+-- +goose Up
+
+-- Per-workspace environment variables (workspace_id is a hub-owned ID, no
+-- local FK). env is the protojson encoding of leapmuxv1.WorkspaceEnv. A
+-- workspace with no row adds nothing over the org's variables.
+CREATE TABLE workspace_env (
+    workspace_id TEXT PRIMARY KEY,
+    env          TEXT NOT NULL,
+    updated_at   DATETIME NOT NULL DEFAULT (strftime('%Y-%m-%dT%H:%M:%fZ','now'))
+);
+
+-- The variables an agent was opened with (OpenAgentRequest.env), in the
+-- same encoding, so every relaunch runs with them. Kept beside the agents
+-- row rather than in it because few agents carry any.
+CREATE TABLE agent_env (
+    agent_id TEXT PRIMARY KEY REFERENCES agents(id) ON DELETE CASCADE,
+    env      TEXT NOT NULL
+);
+
+-- +goose Down
+DROP TABLE IF EXISTS agent_env;
+DROP TABLE IF EXISTS workspace_env;
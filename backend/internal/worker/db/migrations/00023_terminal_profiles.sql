@@ -0,0 +1,22 @@
+-- +goose Up
+
+-- Per-workspace terminal profiles (workspace_id is a hub-owned ID, no local
+-- FK). profiles is the protojson encoding of
+-- leapmuxv1.WorkspaceTerminalProfiles.
+CREATE TABLE workspace_terminal_profiles (
+    workspace_id TEXT PRIMARY KEY,
+    profiles     TEXT NOT NULL,
+    updated_at   DATETIME NOT NULL DEFAULT (strftime('%Y-%m-%dT%H:%M:%fZ','now'))
+);
+
+-- The profile a terminal was opened with, as protojson
+-- leapmuxv1.TerminalProfile. A copy rather than a name so a restart runs
+-- the terminal the same way after the profile changes or is deleted.
+CREATE TABLE terminal_profiles (
+    terminal_id TEXT PRIMARY KEY REFERENCES terminals(id) ON DELETE CASCADE,
+    profile     TEXT NOT NULL
+);
+
+-- +goose Down
+DROP TABLE IF EXISTS terminal_profiles;
+DROP TABLE IF EXISTS workspace_terminal_profiles;
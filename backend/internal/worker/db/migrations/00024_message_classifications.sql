@@ -0,0 +1,15 @@
+-- +goose Up
+
+-- Data-classification tags of a message, e.g. "email,phone", set when the
+-- worker's PII classifiers match its content. Only tagged messages have a
+-- row. Kept beside the messages row like message_usage, so untagged
+-- history costs nothing.
+CREATE TABLE message_classifications (
+    message_id TEXT PRIMARY KEY REFERENCES messages(id) ON DELETE CASCADE,
+    agent_id   TEXT NOT NULL REFERENCES agents(id) ON DELETE CASCADE,
+    tags       TEXT NOT NULL
+);
+CREATE INDEX idx_message_classifications_agent ON message_classifications(agent_id);
+
+-- +goose Down
+DROP TABLE IF EXISTS message_classifications;
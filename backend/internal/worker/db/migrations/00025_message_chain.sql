@@ -0,0 +1,35 @@
+-- +goose Up
+
+-- Hash chain over the messages an agent persists while its org keeps
+-- immutable transcripts. Each link's hash covers the message's content and
+-- the previous link's hash, so an edited, removed or reordered message
+-- breaks every link after it. No foreign key to messages: a link must
+-- outlive its message for the removal to show.
+CREATE TABLE message_chain (
+    message_id TEXT PRIMARY KEY,
+    agent_id   TEXT NOT NULL REFERENCES agents(id) ON DELETE CASCADE,
+    position   INTEGER NOT NULL,
+    hash       TEXT NOT NULL,
+    UNIQUE(agent_id, position)
+);
+
+-- A message deleted while its org keeps immutable transcripts: the row as
+-- it was, and who removed it from the chat when.
+CREATE TABLE message_tombstones (
+    message_id          TEXT PRIMARY KEY,
+    agent_id            TEXT NOT NULL REFERENCES agents(id) ON DELETE CASCADE,
+    seq                 INTEGER NOT NULL,
+    source              INTEGER NOT NULL,
+    content             BLOB NOT NULL,
+    content_compression INTEGER NOT NULL,
+    agent_provider      INTEGER NOT NULL,
+    delivery_error      TEXT NOT NULL DEFAULT '',
+    created_at          DATETIME NOT NULL,
+    deleted_by          TEXT NOT NULL,
+    deleted_at          DATETIME NOT NULL DEFAULT (strftime('%Y-%m-%dT%H:%M:%fZ', 'now'))
+);
+CREATE INDEX idx_message_tombstones_agent ON message_tombstones(agent_id);
+
+-- +goose Down
+DROP TABLE IF EXISTS message_tombstones;
+DROP TABLE IF EXISTS message_chain;
@@ -0,0 +1,14 @@
+-- +goose Up
+
+-- Closed agents whose transcript the archive shipper has written to the
+-- bucket, and under which keys. While archiving is on, cleanup deletes
+-- only agents listed here, so nothing is purged before it is shipped.
+CREATE TABLE agent_archives (
+    agent_id       TEXT PRIMARY KEY REFERENCES agents(id) ON DELETE CASCADE,
+    transcript_key TEXT NOT NULL,
+    audit_key      TEXT NOT NULL DEFAULT '',
+    shipped_at     DATETIME NOT NULL DEFAULT (strftime('%Y-%m-%dT%H:%M:%fZ', 'now'))
+);
+
+-- +goose Down
+DROP TABLE IF EXISTS agent_archives;
@@ -0,0 +1,74 @@
+-- +goose Up
+
+-- Per-agent tallies behind the workspace activity the worker reports to
+-- the Hub for ListWorkspaces, so a sidebar needs no message page per agent:
+-- when the agent's newest message was written, and how many of its
+-- messages are agent or LeapMux ones (the ones a user reads; a user's own
+-- messages never count as unread). The triggers below keep them current on
+-- every write.
+CREATE TABLE agent_message_tallies (
+    agent_id        TEXT PRIMARY KEY REFERENCES agents(id) ON DELETE CASCADE,
+    last_message_at DATETIME NOT NULL,
+    message_count   INTEGER NOT NULL DEFAULT 0
+);
+
+-- How far each user has read each agent: through last_read_seq, which
+-- covers read_count of the messages agent_message_tallies counts. The
+-- agent's unread count for the user is message_count - read_count.
+CREATE TABLE agent_read_markers (
+    agent_id      TEXT NOT NULL REFERENCES agents(id) ON DELETE CASCADE,
+    user_id       TEXT NOT NULL,
+    last_read_seq INTEGER NOT NULL,
+    read_count    INTEGER NOT NULL,
+    updated_at    DATETIME NOT NULL DEFAULT (strftime('%Y-%m-%dT%H:%M:%fZ', 'now')),
+    PRIMARY KEY (agent_id, user_id)
+);
+
+INSERT INTO agent_message_tallies (agent_id, last_message_at, message_count)
+SELECT agent_id, MAX(created_at), SUM(source <> 1) FROM messages GROUP BY agent_id;
+
+-- +goose StatementBegin
+CREATE TRIGGER trg_messages_tallies_insert AFTER INSERT ON messages
+BEGIN
+    INSERT INTO agent_message_tallies (agent_id, last_message_at, message_count)
+    VALUES (NEW.agent_id, NEW.created_at, NEW.source <> 1)
+    ON CONFLICT (agent_id) DO UPDATE SET
+        last_message_at = MAX(last_message_at, excluded.last_message_at),
+        message_count = message_count + excluded.message_count;
+END;
+-- +goose StatementEnd
+
+-- A deleted message leaves the count, and the read count of every marker
+-- past it.
+-- +goose StatementBegin
+CREATE TRIGGER trg_messages_tallies_delete AFTER DELETE ON messages
+WHEN OLD.source <> 1
+BEGIN
+    UPDATE agent_message_tallies SET message_count = message_count - 1
+    WHERE agent_id = OLD.agent_id;
+    UPDATE agent_read_markers SET read_count = read_count - 1
+    WHERE agent_id = OLD.agent_id AND last_read_seq >= OLD.seq;
+END;
+-- +goose StatementEnd
+
+-- A consolidated notification moved to the tail (UpdateNotificationThread)
+-- is new activity, and unread again for every user who had read it where
+-- it was.
+-- +goose StatementBegin
+CREATE TRIGGER trg_messages_tallies_reseq AFTER UPDATE OF seq ON messages
+WHEN OLD.source <> 1 AND NEW.seq > OLD.seq
+BEGIN
+    UPDATE agent_message_tallies
+    SET last_message_at = MAX(last_message_at, strftime('%Y-%m-%dT%H:%M:%fZ', 'now'))
+    WHERE agent_id = OLD.agent_id;
+    UPDATE agent_read_markers SET read_count = read_count - 1
+    WHERE agent_id = OLD.agent_id AND last_read_seq >= OLD.seq AND last_read_seq < NEW.seq;
+END;
+-- +goose StatementEnd
+
+-- +goose Down
+DROP TRIGGER IF EXISTS trg_messages_tallies_reseq;
+DROP TRIGGER IF EXISTS trg_messages_tallies_delete;
+DROP TRIGGER IF EXISTS trg_messages_tallies_insert;
+DROP TABLE IF EXISTS agent_read_markers;
+DROP TABLE IF EXISTS agent_message_tallies;
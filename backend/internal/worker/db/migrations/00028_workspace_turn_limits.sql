@@ -0,0 +1,13 @@
+-- +goose Up
+
+-- Per-workspace turn limits (workspace_id is a hub-owned ID, no local FK).
+-- limits is the protojson encoding of leapmuxv1.TurnLimits. A workspace
+-- with no row has no limits.
+CREATE TABLE workspace_turn_limits (
+    workspace_id TEXT PRIMARY KEY,
+    limits       TEXT NOT NULL,
+    updated_at   DATETIME NOT NULL DEFAULT (strftime('%Y-%m-%dT%H:%M:%fZ','now'))
+);
+
+-- +goose Down
+DROP TABLE IF EXISTS workspace_turn_limits;
@@ -0,0 +1,12 @@
+-- +goose Up
+
+-- When this worker started keeping immutable transcripts. Every message
+-- created since has a message_chain link, so one without is a message
+-- written around the chain. A single row; the mode is never turned off.
+CREATE TABLE immutable_transcripts (
+    id         INTEGER PRIMARY KEY CHECK (id = 1),
+    enabled_at DATETIME NOT NULL
+);
+
+-- +goose Down
+DROP TABLE IF EXISTS immutable_transcripts;
@@ -0,0 +1,39 @@
+-- +goose Up
+
+-- Plan review is gone: only a workspace's owner can reach its agents, so a
+-- review could never collect a vote from anyone but the plan's author.
+DROP TABLE IF EXISTS plan_review_votes;
+DROP TABLE IF EXISTS plan_reviews;
+DROP TABLE IF EXISTS workspace_plan_review_policies;
+
+-- +goose Down
+CREATE TABLE workspace_plan_review_policies (
+    workspace_id       TEXT PRIMARY KEY,
+    required_approvals INTEGER NOT NULL,
+    updated_at         DATETIME NOT NULL DEFAULT (strftime('%Y-%m-%dT%H:%M:%fZ','now'))
+);
+
+CREATE TABLE plan_reviews (
+    agent_id           TEXT NOT NULL REFERENCES agents(id) ON DELETE CASCADE,
+    request_id         TEXT NOT NULL,
+    tool_name          TEXT NOT NULL DEFAULT '',
+    tool_use_id        TEXT NOT NULL DEFAULT '',
+    request_payload    BLOB NOT NULL,
+    target_mode        TEXT NOT NULL,
+    required_approvals INTEGER NOT NULL,
+    status             TEXT NOT NULL DEFAULT 'pending',
+    created_at         DATETIME NOT NULL DEFAULT (strftime('%Y-%m-%dT%H:%M:%fZ','now')),
+    resolved_at        DATETIME,
+    PRIMARY KEY (agent_id, request_id)
+);
+
+CREATE TABLE plan_review_votes (
+    agent_id   TEXT NOT NULL,
+    request_id TEXT NOT NULL,
+    user_id    TEXT NOT NULL,
+    approved   INTEGER NOT NULL,
+    comment    TEXT NOT NULL DEFAULT '',
+    created_at DATETIME NOT NULL DEFAULT (strftime('%Y-%m-%dT%H:%M:%fZ','now')),
+    PRIMARY KEY (agent_id, request_id, user_id),
+    FOREIGN KEY (agent_id, request_id) REFERENCES plan_reviews(agent_id, request_id) ON DELETE CASCADE
+);
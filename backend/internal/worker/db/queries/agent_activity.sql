@@ -0,0 +1,30 @@
+-- name: CreateAgentActivity :exec
+INSERT INTO agent_activity (agent_id, kind, latency_ms, detail)
+VALUES (?, ?, ?, ?);
+
+-- ListTurnTotals totals the turns agents ended in [since, until) and their
+-- cost, per workspace and the user who opened the agent. Raw compares
+-- against the canonical created_at layout.
+-- name: ListTurnTotals :many
+SELECT a.workspace_id, a.created_by,
+       COUNT(DISTINCT a.id) AS agents,
+       COUNT(*) AS turns,
+       CAST(SUM(u.cost_usd) AS REAL) AS cost_usd
+FROM message_usage u
+JOIN messages m ON m.id = u.message_id
+JOIN agents a ON a.id = u.agent_id
+WHERE u.turn = 1
+  AND m.created_at >= sqlc.arg(since) AND m.created_at < sqlc.arg(until)
+GROUP BY a.workspace_id, a.created_by;
+
+-- ListAgentActivityTotals counts agent_activity events in [since, until)
+-- and sums their latency, per workspace, user who opened the agent, and
+-- kind.
+-- name: ListAgentActivityTotals :many
+SELECT a.workspace_id, a.created_by, e.kind,
+       COUNT(*) AS events,
+       CAST(SUM(e.latency_ms) AS INTEGER) AS latency_ms
+FROM agent_activity e
+JOIN agents a ON a.id = e.agent_id
+WHERE e.created_at >= sqlc.arg(since) AND e.created_at < sqlc.arg(until)
+GROUP BY a.workspace_id, a.created_by, e.kind;
@@ -0,0 +1,18 @@
+-- name: ListUnarchivedClosedAgents :many
+-- Oldest first, so a shipper that fell behind catches up in order.
+SELECT * FROM agents
+WHERE closed_at IS NOT NULL
+  AND NOT EXISTS (SELECT 1 FROM agent_archives x WHERE x.agent_id = agents.id)
+ORDER BY closed_at ASC, id ASC
+LIMIT ?;
+
+-- name: CreateAgentArchive :exec
+INSERT INTO agent_archives (agent_id, transcript_key, audit_key)
+VALUES (?, ?, ?);
+
+-- name: DeleteArchivedClosedAgentsBefore :execresult
+-- DeleteClosedAgentsBefore, limited to the agents already shipped.
+DELETE FROM agents WHERE rowid IN (
+    SELECT a.rowid FROM agents a JOIN agent_archives x ON x.agent_id = a.id
+    WHERE a.closed_at < sqlc.arg(cutoff) LIMIT 1000
+);
@@ -0,0 +1,42 @@
+-- name: GetAgentArtifactPatterns :one
+SELECT patterns FROM agent_artifact_patterns WHERE agent_id = ?;
+
+-- name: SetAgentArtifactPatterns :exec
+INSERT INTO agent_artifact_patterns (agent_id, patterns)
+VALUES (?, ?)
+ON CONFLICT(agent_id) DO UPDATE SET patterns = excluded.patterns;
+
+-- GetLatestAgentArtifactSHA256 is the hash of a path's newest snapshot, so
+-- an unchanged file is not collected again.
+-- name: GetLatestAgentArtifactSHA256 :one
+SELECT sha256 FROM agent_artifacts
+WHERE agent_id = ? AND path = ?
+ORDER BY id DESC
+LIMIT 1;
+
+-- name: CreateAgentArtifact :one
+INSERT INTO agent_artifacts (agent_id, path, size, sha256)
+VALUES (?, ?, ?, ?)
+RETURNING id;
+
+-- name: DeleteAgentArtifact :exec
+DELETE FROM agent_artifacts WHERE id = ?;
+
+-- PruneAgentArtifacts drops all but the newest keep snapshots of an agent
+-- and returns the dropped ids, whose files the caller removes.
+-- name: PruneAgentArtifacts :many
+DELETE FROM agent_artifacts
+WHERE agent_id = sqlc.arg(agent_id)
+  AND id <= (
+    SELECT id FROM agent_artifacts
+    WHERE agent_id = sqlc.arg(agent_id)
+    ORDER BY id DESC
+    LIMIT 1 OFFSET sqlc.arg(keep)
+  )
+RETURNING id;
+
+-- name: ListAgentArtifacts :many
+SELECT * FROM agent_artifacts WHERE agent_id = ? ORDER BY id;
+
+-- name: GetAgentArtifact :one
+SELECT * FROM agent_artifacts WHERE id = ?;
@@ -0,0 +1,14 @@
+-- name: ClaimAgentInputKey :execrows
+-- Atomically claim idempotency_key for agent_id. 1 row affected means this
+-- send won and must be delivered; 0 means an earlier send already holds
+-- the key.
+INSERT INTO agent_input_keys (agent_id, idempotency_key, message_id) VALUES (?, ?, ?)
+ON CONFLICT (agent_id, idempotency_key) DO NOTHING;
+
+-- name: GetAgentInputKeyMessageID :one
+SELECT message_id FROM agent_input_keys
+WHERE agent_id = ? AND idempotency_key = ?;
+
+-- name: ReleaseAgentInputKey :exec
+DELETE FROM agent_input_keys
+WHERE agent_id = ? AND idempotency_key = ?;
@@ -0,0 +1,44 @@
+-- name: CreateAgentModelCredential :exec
+INSERT INTO agent_model_credentials (agent_id, credential_id, credential_name, kind)
+VALUES (?, ?, ?, ?);
+
+-- name: GetAgentModelCredential :one
+SELECT * FROM agent_model_credentials
+WHERE agent_id = ?;
+
+-- CopyAgentModelCredential gives a cloned agent its source's credential; a
+-- source without one copies nothing.
+-- name: CopyAgentModelCredential :exec
+INSERT INTO agent_model_credentials (agent_id, credential_id, credential_name, kind)
+SELECT sqlc.arg(agent_id), credential_id, credential_name, kind
+FROM agent_model_credentials
+WHERE agent_id = sqlc.arg(source_agent_id);
+
+-- CountOpenAgentsByModelCredential is the load each credential carries.
+-- name: CountOpenAgentsByModelCredential :many
+SELECT c.credential_id, COUNT(*) AS agents
+FROM agent_model_credentials c
+JOIN agents a ON a.id = c.agent_id
+WHERE a.closed_at IS NULL
+GROUP BY c.credential_id;
+
+-- ListModelCredentialUsage totals the turn usage of agents opened with a
+-- credential, per credential and workspace, over turns that ended in
+-- [since, until). Raw compares against the canonical created_at layout.
+-- name: ListModelCredentialUsage :many
+SELECT c.credential_id, c.credential_name, a.workspace_id,
+       COUNT(DISTINCT a.id) AS agents,
+       COUNT(*) AS turns,
+       CAST(SUM(u.input_tokens) AS INTEGER) AS input_tokens,
+       CAST(SUM(u.output_tokens) AS INTEGER) AS output_tokens,
+       CAST(SUM(u.cache_creation_input_tokens) AS INTEGER) AS cache_creation_input_tokens,
+       CAST(SUM(u.cache_read_input_tokens) AS INTEGER) AS cache_read_input_tokens,
+       CAST(SUM(u.cost_usd) AS REAL) AS cost_usd
+FROM message_usage u
+JOIN messages m ON m.id = u.message_id
+JOIN agents a ON a.id = u.agent_id
+JOIN agent_model_credentials c ON c.agent_id = u.agent_id
+WHERE u.turn = 1
+  AND m.created_at >= sqlc.arg(since) AND m.created_at < sqlc.arg(until)
+GROUP BY c.credential_id, c.credential_name, a.workspace_id
+ORDER BY c.credential_name, c.credential_id, a.workspace_id;
@@ -0,0 +1,33 @@
+-- name: CreateAgentOutputDeadLetter :exec
+INSERT INTO agent_output_dead_letters (agent_id, content, error)
+VALUES (?, ?, ?);
+
+-- PruneAgentOutputDeadLetters drops all but the newest keep lines of an
+-- agent.
+-- name: PruneAgentOutputDeadLetters :exec
+DELETE FROM agent_output_dead_letters
+WHERE agent_id = sqlc.arg(agent_id)
+  AND id <= (
+    SELECT id FROM agent_output_dead_letters
+    WHERE agent_id = sqlc.arg(agent_id)
+    ORDER BY id DESC
+    LIMIT 1 OFFSET sqlc.arg(keep)
+  );
+
+-- ListAgentOutputDeadLetters pages through the lines after after_id, of
+-- one agent or, with agent_id '', of every agent.
+-- name: ListAgentOutputDeadLetters :many
+SELECT * FROM agent_output_dead_letters
+WHERE (CAST(sqlc.arg(agent_id) AS TEXT) = '' OR agent_id = sqlc.arg(agent_id))
+  AND (CAST(sqlc.arg(include_replayed) AS BOOLEAN) OR replayed_at IS NULL)
+  AND id > sqlc.arg(after_id)
+ORDER BY id
+LIMIT sqlc.arg(row_limit);
+
+-- name: GetAgentOutputDeadLetter :one
+SELECT * FROM agent_output_dead_letters WHERE id = ?;
+
+-- name: MarkAgentOutputDeadLetterReplayed :exec
+UPDATE agent_output_dead_letters
+SET replayed_at = strftime('%Y-%m-%dT%H:%M:%fZ', 'now')
+WHERE id = ?;
@@ -0,0 +1,22 @@
+-- name: CreateAgentSystemPrompt :exec
+INSERT INTO agent_system_prompts (agent_id, prompt_id, prompt_version, content)
+VALUES (?, ?, ?, ?);
+
+-- name: GetAgentSystemPrompt :one
+SELECT * FROM agent_system_prompts
+WHERE agent_id = ?;
+
+-- CopyAgentSystemPrompt gives a cloned agent its source's prompt; a source
+-- without one copies nothing.
+-- name: CopyAgentSystemPrompt :exec
+INSERT INTO agent_system_prompts (agent_id, prompt_id, prompt_version, content)
+SELECT sqlc.arg(agent_id), prompt_id, prompt_version, content
+FROM agent_system_prompts
+WHERE agent_id = sqlc.arg(source_agent_id);
+
+-- name: ListOpenAgentSystemPrompts :many
+SELECT a.id, a.workspace_id, a.title, p.prompt_id, p.prompt_version
+FROM agent_system_prompts p
+JOIN agents a ON a.id = p.agent_id
+WHERE a.closed_at IS NULL
+ORDER BY a.created_at, a.id;
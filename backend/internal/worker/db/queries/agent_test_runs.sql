@@ -0,0 +1,10 @@
+-- name: CreateAgentTestRun :one
+INSERT INTO agent_test_runs (agent_id, turn_message_id, tool_use_id, runner, command, passed, failed, skipped, has_coverage, coverage_percent)
+VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?)
+RETURNING *;
+
+-- name: ListAgentTestRuns :many
+SELECT * FROM agent_test_runs
+WHERE agent_id = sqlc.arg(agent_id)
+  AND (CAST(sqlc.arg(turn_message_id) AS TEXT) = '' OR turn_message_id = sqlc.arg(turn_message_id))
+ORDER BY id;
@@ -1,5 +1,5 @@
 -- name: CreateAgent :exec
-INSERT INTO agents (id, workspace_id, working_dir, home_dir, title, options, agent_provider, resumed) VALUES (?, ?, ?, ?, ?, ?, ?, ?);
+INSERT INTO agents (id, workspace_id, working_dir, home_dir, title, options, agent_provider, resumed, created_by) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?);
 
 -- name: GetAgentByID :one
 SELECT * FROM agents WHERE id = ?;
@@ -144,6 +144,38 @@ UPDATE agents SET workspace_id = ? WHERE id = ?;
 -- name: ListAgentsByIDs :many
 SELECT * FROM agents WHERE id IN (sqlc.slice('ids')) AND closed_at IS NULL;
 
+-- name: ListAgentsByWorkspaceID :many
+-- A workspace's agents, newest first, for the GraphQL query endpoint.
+-- include_closed adds the closed agents the worker still keeps.
+SELECT * FROM agents
+WHERE workspace_id = sqlc.arg(workspace_id)
+  AND (closed_at IS NULL OR CAST(sqlc.arg(include_closed) AS BOOLEAN))
+ORDER BY created_at DESC, id DESC
+LIMIT sqlc.arg(row_limit);
+
+-- name: ListAgentsPage :many
+-- One keyset page of the agents in workspace_ids, newest first, for
+-- ListAllAgents. The empty-string / zero arguments disable their filter.
+-- last_activity_at is the creation time of the agent's latest message, or
+-- of the agent itself when it has none. workspace_ids is a JSON array:
+-- sqlc.slice expands to bare placeholders that would shift the numbered
+-- ones the repeated filter arguments compile to.
+SELECT a.*,
+  CAST(COALESCE(
+    (SELECT m.created_at FROM messages m WHERE m.agent_id = a.id ORDER BY m.seq DESC LIMIT 1),
+    a.created_at
+  ) AS TEXT) AS last_activity_at
+FROM agents a
+WHERE a.workspace_id IN (SELECT value FROM json_each(CAST(sqlc.arg(workspace_ids) AS TEXT)))
+  AND (CAST(sqlc.arg(include_closed) AS BOOLEAN) OR a.closed_at IS NULL)
+  AND (CAST(sqlc.arg(created_by) AS TEXT) = '' OR a.created_by = sqlc.arg(created_by))
+  AND (CAST(sqlc.arg(model) AS TEXT) = '' OR json_extract(a.options, '$.model') = sqlc.arg(model))
+  AND (CAST(sqlc.arg(after_id) AS TEXT) = ''
+    OR a.created_at < sqlc.arg(after_created_at)
+    OR (a.created_at = sqlc.arg(after_created_at) AND a.id < sqlc.arg(after_id)))
+ORDER BY a.created_at DESC, a.id DESC
+LIMIT sqlc.arg(row_limit);
+
 -- name: DeleteClosedAgentsBefore :execresult
 -- Raw compare against a SQLiteNullTime cutoff (same canonical layout);
 -- see DeleteClosedTerminalsBefore for the rationale.
@@ -156,3 +188,9 @@ DELETE FROM agents WHERE rowid IN (SELECT a.rowid FROM agents a WHERE a.closed_a
 -- metacharacters cannot produce false positives or false negatives.
 -- name: ListAgentIDsWithPlanInDir :many
 SELECT id FROM agents WHERE instr(plan_file_path, ?) = 1;
+
+-- ListAgentSessionRefs returns the home directory, session id, and plan
+-- file of every agent row, open or closed, for the Claude session
+-- collector: a file any of them names is kept.
+-- name: ListAgentSessionRefs :many
+SELECT home_dir, agent_session_id, plan_file_path FROM agents;
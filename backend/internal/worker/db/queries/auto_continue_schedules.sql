@@ -7,7 +7,8 @@ INSERT INTO auto_continue_schedules (
   jitter_ms,
   next_backoff_ms,
   state,
-  source_payload
+  source_payload,
+  attempts
 ) VALUES (
   sqlc.arg(agent_id),
   sqlc.arg(reason),
@@ -21,7 +22,8 @@ INSERT INTO auto_continue_schedules (
   sqlc.arg(jitter_ms),
   sqlc.arg(next_backoff_ms),
   'active',
-  sqlc.arg(source_payload)
+  sqlc.arg(source_payload),
+  sqlc.arg(attempts)
 )
 ON CONFLICT(agent_id, reason) DO UPDATE SET
   content = excluded.content,
@@ -30,6 +32,7 @@ ON CONFLICT(agent_id, reason) DO UPDATE SET
   next_backoff_ms = excluded.next_backoff_ms,
   state = 'active',
   source_payload = excluded.source_payload,
+  attempts = excluded.attempts,
   updated_at = strftime('%Y-%m-%dT%H:%M:%fZ', 'now');
 
 -- name: GetAutoContinueSchedule :one
@@ -53,6 +56,14 @@ SET state = 'cancelled',
     updated_at = strftime('%Y-%m-%dT%H:%M:%fZ', 'now')
 WHERE agent_id = ? AND state = 'active';
 
+-- name: ResetAutoContinueAttempts :exec
+-- Runs on every successful turn end, so it leaves state alone: a fired row
+-- stays fired and only its retry count starts over.
+UPDATE auto_continue_schedules
+SET attempts = 0,
+    updated_at = strftime('%Y-%m-%dT%H:%M:%fZ', 'now')
+WHERE agent_id = ? AND reason = ? AND attempts != 0;
+
 -- name: MarkAutoContinueScheduleFired :exec
 UPDATE auto_continue_schedules
 SET state = 'fired',
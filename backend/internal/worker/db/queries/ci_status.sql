@@ -0,0 +1,31 @@
+-- name: GetWorkspaceCIPolicy :one
+SELECT policy FROM workspace_ci_policies
+WHERE workspace_id = ?;
+
+-- name: UpsertWorkspaceCIPolicy :exec
+INSERT INTO workspace_ci_policies (workspace_id, policy)
+VALUES (?, ?)
+ON CONFLICT(workspace_id) DO UPDATE SET
+  policy = excluded.policy,
+  updated_at = strftime('%Y-%m-%dT%H:%M:%fZ', 'now');
+
+-- name: DeleteWorkspaceCIPolicy :exec
+DELETE FROM workspace_ci_policies
+WHERE workspace_id = ?;
+
+-- name: ListWorkspaceCIPolicies :many
+SELECT * FROM workspace_ci_policies;
+
+-- name: GetAgentCIStatus :one
+SELECT * FROM agent_ci_status
+WHERE agent_id = ?;
+
+-- name: UpsertAgentCIStatus :exec
+INSERT INTO agent_ci_status (agent_id, commit_sha, status, follow_up_sha, follow_ups)
+VALUES (?, ?, ?, ?, ?)
+ON CONFLICT(agent_id) DO UPDATE SET
+  commit_sha = excluded.commit_sha,
+  status = excluded.status,
+  follow_up_sha = excluded.follow_up_sha,
+  follow_ups = excluded.follow_ups,
+  updated_at = strftime('%Y-%m-%dT%H:%M:%fZ', 'now');
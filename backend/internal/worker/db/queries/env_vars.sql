@@ -0,0 +1,30 @@
+-- name: GetWorkspaceEnv :one
+SELECT env FROM workspace_env
+WHERE workspace_id = ?;
+
+-- name: UpsertWorkspaceEnv :exec
+INSERT INTO workspace_env (workspace_id, env)
+VALUES (?, ?)
+ON CONFLICT(workspace_id) DO UPDATE SET
+  env = excluded.env,
+  updated_at = strftime('%Y-%m-%dT%H:%M:%fZ', 'now');
+
+-- name: DeleteWorkspaceEnv :exec
+DELETE FROM workspace_env
+WHERE workspace_id = ?;
+
+-- name: CreateAgentEnv :exec
+INSERT INTO agent_env (agent_id, env)
+VALUES (?, ?);
+
+-- name: GetAgentEnv :one
+SELECT env FROM agent_env
+WHERE agent_id = ?;
+
+-- CopyAgentEnv gives a cloned agent its source's variables; a source
+-- without any copies nothing.
+-- name: CopyAgentEnv :exec
+INSERT INTO agent_env (agent_id, env)
+SELECT sqlc.arg(agent_id), env
+FROM agent_env
+WHERE agent_id = sqlc.arg(source_agent_id);
@@ -0,0 +1,42 @@
+-- name: GetLatestMessageChainLink :one
+SELECT * FROM message_chain WHERE agent_id = ? ORDER BY position DESC LIMIT 1;
+
+-- name: CreateMessageChainLink :exec
+INSERT INTO message_chain (message_id, agent_id, position, hash)
+VALUES (?, ?, ?, ?);
+
+-- name: ListMessageChainByAgentID :many
+SELECT * FROM message_chain WHERE agent_id = ? ORDER BY position ASC;
+
+-- name: CreateMessageTombstone :exec
+INSERT INTO message_tombstones (message_id, agent_id, seq, source, content, content_compression, agent_provider, delivery_error, created_at, deleted_by)
+VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?);
+
+-- name: GetMessageTombstone :one
+SELECT * FROM message_tombstones WHERE message_id = ? AND agent_id = ?;
+
+-- name: ListMessageTombstonesByAgentID :many
+SELECT * FROM message_tombstones WHERE agent_id = ? ORDER BY seq ASC;
+
+-- name: EnableImmutableTranscripts :exec
+-- Keeps the first enabled_at: the mode is re-sent on every Hub connect.
+INSERT INTO immutable_transcripts (id, enabled_at)
+VALUES (1, ?)
+ON CONFLICT(id) DO NOTHING;
+
+-- name: GetImmutableTranscriptsEnabledAt :one
+SELECT enabled_at FROM immutable_transcripts WHERE id = 1;
+
+-- name: GetFirstUnchainedMessage :one
+SELECT m.id FROM messages m
+WHERE m.agent_id = ? AND m.created_at >= ?
+  AND NOT EXISTS (SELECT 1 FROM message_chain c WHERE c.message_id = m.id)
+ORDER BY m.seq ASC
+LIMIT 1;
+
+-- name: GetFirstUnchainedMessageTombstone :one
+SELECT t.message_id FROM message_tombstones t
+WHERE t.agent_id = ? AND t.created_at >= ?
+  AND NOT EXISTS (SELECT 1 FROM message_chain c WHERE c.message_id = t.message_id)
+ORDER BY t.seq ASC
+LIMIT 1;
@@ -0,0 +1,25 @@
+-- name: CreateMessageClassification :exec
+INSERT INTO message_classifications (message_id, agent_id, tags)
+VALUES (?, ?, ?);
+
+-- CopyMessageClassification gives a copied message the tags of the
+-- message it was copied from, if it had any.
+-- name: CopyMessageClassification :exec
+INSERT INTO message_classifications (message_id, agent_id, tags)
+SELECT sqlc.arg(dst_message_id), sqlc.arg(dst_agent_id), c.tags
+FROM message_classifications c
+WHERE c.message_id = sqlc.arg(src_message_id);
+
+-- name: ListMessageClassificationsByAgentID :many
+SELECT message_id, agent_id, tags FROM message_classifications WHERE agent_id = ?;
+
+-- DeleteClassifiedMessagesBefore removes, in batches, tagged messages
+-- created before cutoff. Raw compare against a SQLiteTime cutoff (same
+-- canonical layout); see DeleteClosedTerminalsBefore for the rationale.
+-- name: DeleteClassifiedMessagesBefore :execresult
+DELETE FROM messages WHERE rowid IN (
+    SELECT m.rowid FROM messages m
+    JOIN message_classifications c ON c.message_id = m.id
+    WHERE m.created_at < sqlc.arg(cutoff)
+    LIMIT 1000
+);
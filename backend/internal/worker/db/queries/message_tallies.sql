@@ -0,0 +1,32 @@
+-- name: UpsertAgentReadMarker :exec
+-- Moves a user's read marker on an agent forward to last_read_seq, counting
+-- the agent and LeapMux messages it covers. A marker never moves back.
+INSERT INTO agent_read_markers (agent_id, user_id, last_read_seq, read_count, updated_at)
+SELECT CAST(sqlc.arg(agent_id) AS TEXT), CAST(sqlc.arg(user_id) AS TEXT),
+       CAST(sqlc.arg(last_read_seq) AS INTEGER), COUNT(*),
+       strftime('%Y-%m-%dT%H:%M:%fZ', 'now')
+FROM messages
+WHERE agent_id = sqlc.arg(agent_id) AND seq <= sqlc.arg(last_read_seq) AND source <> 1
+ON CONFLICT (agent_id, user_id) DO UPDATE SET
+    last_read_seq = excluded.last_read_seq,
+    read_count = excluded.read_count,
+    updated_at = excluded.updated_at
+WHERE excluded.last_read_seq > agent_read_markers.last_read_seq;
+
+-- name: GetAgentReadMarkerSeq :one
+SELECT last_read_seq FROM agent_read_markers WHERE agent_id = ? AND user_id = ?;
+
+-- name: ListOpenAgentTallies :many
+-- The message tallies of every open agent that has messages, with the
+-- number of its control requests awaiting an answer.
+SELECT a.id, a.workspace_id, t.last_message_at, t.message_count,
+       (SELECT COUNT(*) FROM control_requests cr WHERE cr.agent_id = a.id) AS pending_requests
+FROM agents a
+JOIN agent_message_tallies t ON t.agent_id = a.id
+WHERE a.closed_at IS NULL;
+
+-- name: ListOpenAgentReadMarkers :many
+SELECT m.agent_id, m.user_id, m.read_count
+FROM agent_read_markers m
+JOIN agents a ON a.id = m.agent_id
+WHERE a.closed_at IS NULL;
@@ -0,0 +1,37 @@
+-- name: CreateMessageUsage :exec
+INSERT INTO message_usage (message_id, agent_id, input_tokens, output_tokens, cache_creation_input_tokens, cache_read_input_tokens, cost_usd, turn)
+VALUES (?, ?, ?, ?, ?, ?, ?, ?);
+
+-- ListMessageUsageBySeqRange returns the usage of an agent's messages whose
+-- seq falls within [min_seq, max_seq], for attaching to a transcript page.
+-- name: ListMessageUsageBySeqRange :many
+SELECT u.message_id, u.agent_id, u.input_tokens, u.output_tokens, u.cache_creation_input_tokens, u.cache_read_input_tokens, u.cost_usd
+FROM message_usage u
+JOIN messages m ON m.id = u.message_id
+WHERE m.agent_id = sqlc.arg(agent_id) AND m.seq BETWEEN sqlc.arg(min_seq) AND sqlc.arg(max_seq);
+
+-- name: GetAgentUsageTotals :one
+-- An agent's turns and their token and cost totals. Like ListTurnTotals it
+-- sums turn rows only, which already include their messages' usage.
+SELECT
+  COUNT(*) AS turns,
+  CAST(COALESCE(SUM(input_tokens), 0) AS INTEGER) AS input_tokens,
+  CAST(COALESCE(SUM(output_tokens), 0) AS INTEGER) AS output_tokens,
+  CAST(COALESCE(SUM(cache_creation_input_tokens), 0) AS INTEGER) AS cache_creation_input_tokens,
+  CAST(COALESCE(SUM(cache_read_input_tokens), 0) AS INTEGER) AS cache_read_input_tokens,
+  CAST(COALESCE(SUM(cost_usd), 0) AS REAL) AS cost_usd
+FROM message_usage
+WHERE agent_id = ? AND turn = 1;
+
+-- name: GetWorkspaceUsageTotals :one
+-- GetAgentUsageTotals over every agent of a workspace the worker keeps.
+SELECT
+  COUNT(*) AS turns,
+  CAST(COALESCE(SUM(u.input_tokens), 0) AS INTEGER) AS input_tokens,
+  CAST(COALESCE(SUM(u.output_tokens), 0) AS INTEGER) AS output_tokens,
+  CAST(COALESCE(SUM(u.cache_creation_input_tokens), 0) AS INTEGER) AS cache_creation_input_tokens,
+  CAST(COALESCE(SUM(u.cache_read_input_tokens), 0) AS INTEGER) AS cache_read_input_tokens,
+  CAST(COALESCE(SUM(u.cost_usd), 0) AS REAL) AS cost_usd
+FROM message_usage u
+JOIN agents a ON a.id = u.agent_id
+WHERE a.workspace_id = ? AND u.turn = 1;
@@ -46,6 +46,39 @@ WHERE agent_id = ?
 ORDER BY seq DESC
 LIMIT ?;
 
+-- name: ListFilteredMessagesByAgentID :many
+-- A ListAgentMessages page with the request's MessageFilter applied, ascending over
+-- after_seq < seq < before_seq. Every predicate reads a column set at write time, so
+-- filtering never decompresses content. source_mask has bit (1 << source) set for
+-- each allowed source. A tool span is any span but the Codex items that reuse the
+-- span columns for plain agent output (agentMessage, plan, reasoning, compaction;
+-- nonToolSpanTypes in the service package).
+SELECT * FROM messages
+WHERE agent_id = sqlc.arg(agent_id)
+  AND seq > sqlc.arg(after_seq) AND seq < sqlc.arg(before_seq)
+  AND ((1 << source) & CAST(sqlc.arg(source_mask) AS INTEGER)) <> 0
+  AND (NOT CAST(sqlc.arg(only_errors) AS BOOLEAN) OR delivery_error <> '')
+  AND (NOT CAST(sqlc.arg(only_user) AS BOOLEAN) OR mark_type = 1)
+  AND (NOT CAST(sqlc.arg(has_tool_use) AS BOOLEAN)
+       OR (span_type <> '' AND span_type NOT IN ('agentMessage', 'plan', 'reasoning', 'contextCompaction')))
+  AND created_at >= sqlc.arg(since) AND created_at < sqlc.arg(until)
+ORDER BY seq ASC
+LIMIT sqlc.arg(row_limit);
+
+-- name: ListFilteredMessagesByAgentIDReverse :many
+-- ListFilteredMessagesByAgentID, descending (the BEFORE and LATEST pages).
+SELECT * FROM messages
+WHERE agent_id = sqlc.arg(agent_id)
+  AND seq > sqlc.arg(after_seq) AND seq < sqlc.arg(before_seq)
+  AND ((1 << source) & CAST(sqlc.arg(source_mask) AS INTEGER)) <> 0
+  AND (NOT CAST(sqlc.arg(only_errors) AS BOOLEAN) OR delivery_error <> '')
+  AND (NOT CAST(sqlc.arg(only_user) AS BOOLEAN) OR mark_type = 1)
+  AND (NOT CAST(sqlc.arg(has_tool_use) AS BOOLEAN)
+       OR (span_type <> '' AND span_type NOT IN ('agentMessage', 'plan', 'reasoning', 'contextCompaction')))
+  AND created_at >= sqlc.arg(since) AND created_at < sqlc.arg(until)
+ORDER BY seq DESC
+LIMIT sqlc.arg(row_limit);
+
 -- name: GetMessageByAgentAndID :one
 SELECT * FROM messages WHERE id = ? AND agent_id = ?;
 
@@ -87,6 +120,12 @@ SELECT * FROM messages WHERE agent_id = ? ORDER BY seq DESC LIMIT 1;
 -- name: HasUserMessages :one
 SELECT EXISTS(SELECT 1 FROM messages m JOIN agents a ON m.agent_id = a.id WHERE m.agent_id = ? AND m.source = 1 AND m.seq > a.session_start_seq) AS has_messages;
 
+-- name: ListLatestSessionUserMessages :many
+SELECT m.content, m.content_compression FROM messages m JOIN agents a ON m.agent_id = a.id
+WHERE m.agent_id = ? AND m.source = 1 AND m.seq > a.session_start_seq
+ORDER BY m.seq DESC
+LIMIT ?;
+
 -- name: DeleteMessageByAgentAndID :one
 DELETE FROM messages WHERE id = ? AND agent_id = ?
 RETURNING seq;
@@ -112,3 +151,25 @@ SELECT
 SELECT seq, mark_type FROM messages
 WHERE agent_id = ? AND mark_type <> 0
 ORDER BY seq ASC;
+
+-- name: ListMessagesByAgentIDInSeqRange :many
+-- An inclusive seq window, ascending. Seqs have gaps (deleted rows), so a window can
+-- hold fewer rows than its width; row_limit caps it at one page.
+SELECT * FROM messages
+WHERE agent_id = sqlc.arg(agent_id) AND seq >= sqlc.arg(from_seq) AND seq <= sqlc.arg(to_seq)
+ORDER BY seq ASC
+LIMIT sqlc.arg(row_limit);
+
+-- name: ListMessageStatsByAgentID :many
+-- Per-source message count and stored content size for one agent. content is the
+-- stored (possibly compressed) blob, so content_bytes approximates what loading the
+-- messages costs on the wire, not their decoded size. The CASTs pin the aggregates
+-- to int64 so sqlc doesn't infer interface{}.
+SELECT
+  source,
+  CAST(COUNT(*) AS INTEGER) AS message_count,
+  CAST(COALESCE(SUM(LENGTH(content)), 0) AS INTEGER) AS content_bytes
+FROM messages
+WHERE agent_id = ?
+GROUP BY source
+ORDER BY source;
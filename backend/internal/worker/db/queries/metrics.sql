@@ -0,0 +1,45 @@
+-- RecordMetric adds one sample to a minute bucket.
+-- name: RecordMetric :exec
+INSERT INTO metric_buckets (metric, workspace_id, resolution, bucket_start, count, sum, min, max)
+VALUES (sqlc.arg(metric), sqlc.arg(workspace_id), 1, sqlc.arg(bucket_start), 1, sqlc.arg(value), sqlc.arg(value), sqlc.arg(value))
+ON CONFLICT (metric, workspace_id, resolution, bucket_start) DO UPDATE SET
+    count = count + 1,
+    sum = sum + excluded.sum,
+    min = MIN(min, excluded.min),
+    max = MAX(max, excluded.max);
+
+-- RollUpMetrics folds the buckets of from_resolution that start before
+-- cutoff into buckets of to_resolution, whose starts bucket_format
+-- truncates to. DeleteMetricsBefore then drops the folded rows.
+-- name: RollUpMetrics :exec
+INSERT INTO metric_buckets (metric, workspace_id, resolution, bucket_start, count, sum, min, max)
+SELECT metric, workspace_id, CAST(sqlc.arg(to_resolution) AS INTEGER), strftime(CAST(sqlc.arg(bucket_format) AS TEXT), bucket_start),
+       SUM(count), SUM(sum), MIN(min), MAX(max)
+FROM metric_buckets
+WHERE resolution = sqlc.arg(from_resolution) AND bucket_start < sqlc.arg(cutoff)
+GROUP BY metric, workspace_id, strftime(CAST(sqlc.arg(bucket_format) AS TEXT), bucket_start)
+ON CONFLICT (metric, workspace_id, resolution, bucket_start) DO UPDATE SET
+    count = count + excluded.count,
+    sum = sum + excluded.sum,
+    min = MIN(min, excluded.min),
+    max = MAX(max, excluded.max);
+
+-- name: DeleteMetricsBefore :execresult
+DELETE FROM metric_buckets
+WHERE resolution = sqlc.arg(resolution) AND bucket_start < sqlc.arg(cutoff);
+
+-- ListMetrics buckets every row at or finer than resolution whose bucket
+-- starts in [since, until) into that resolution's buckets, whose starts
+-- bucket_format truncates to. Raw compares against the canonical layout.
+-- name: ListMetrics :many
+SELECT metric, workspace_id,
+       CAST(strftime(CAST(sqlc.arg(bucket_format) AS TEXT), bucket_start) AS TEXT) AS bucket,
+       CAST(SUM(count) AS INTEGER) AS count,
+       CAST(SUM(sum) AS REAL) AS sum,
+       CAST(MIN(min) AS REAL) AS min,
+       CAST(MAX(max) AS REAL) AS max
+FROM metric_buckets
+WHERE resolution <= sqlc.arg(resolution)
+  AND bucket_start >= sqlc.arg(since) AND bucket_start < sqlc.arg(until)
+GROUP BY metric, workspace_id, bucket
+ORDER BY metric, workspace_id, bucket;
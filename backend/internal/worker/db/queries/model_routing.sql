@@ -0,0 +1,30 @@
+-- name: GetWorkspaceModelRouting :one
+SELECT policy FROM workspace_model_routing
+WHERE workspace_id = ?;
+
+-- name: UpsertWorkspaceModelRouting :exec
+INSERT INTO workspace_model_routing (workspace_id, policy)
+VALUES (?, ?)
+ON CONFLICT(workspace_id) DO UPDATE SET
+  policy = excluded.policy,
+  updated_at = strftime('%Y-%m-%dT%H:%M:%fZ', 'now');
+
+-- name: DeleteWorkspaceModelRouting :exec
+DELETE FROM workspace_model_routing
+WHERE workspace_id = ?;
+
+-- name: RecordAgentTurnModel :exec
+INSERT INTO agent_turn_models (agent_id, message_id, model, base_model, routed)
+VALUES (?, ?, ?, ?, ?)
+ON CONFLICT(agent_id, message_id) DO NOTHING;
+
+-- name: GetLatestAgentTurnModel :one
+SELECT * FROM agent_turn_models
+WHERE agent_id = ?
+ORDER BY created_at DESC, message_id DESC
+LIMIT 1;
+
+-- name: ListAgentTurnModels :many
+SELECT * FROM agent_turn_models
+WHERE agent_id = ?
+ORDER BY created_at, message_id;
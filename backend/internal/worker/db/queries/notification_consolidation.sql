@@ -0,0 +1,19 @@
+-- name: GetWorkspaceNotificationConsolidation :one
+SELECT policy FROM workspace_notification_consolidation
+WHERE workspace_id = ?;
+
+-- name: GetAgentNotificationConsolidation :one
+SELECT p.policy FROM workspace_notification_consolidation p
+JOIN agents a ON a.workspace_id = p.workspace_id
+WHERE a.id = ?;
+
+-- name: UpsertWorkspaceNotificationConsolidation :exec
+INSERT INTO workspace_notification_consolidation (workspace_id, policy)
+VALUES (?, ?)
+ON CONFLICT(workspace_id) DO UPDATE SET
+  policy = excluded.policy,
+  updated_at = strftime('%Y-%m-%dT%H:%M:%fZ', 'now');
+
+-- name: DeleteWorkspaceNotificationConsolidation :exec
+DELETE FROM workspace_notification_consolidation
+WHERE workspace_id = ?;
@@ -0,0 +1,50 @@
+-- name: CreateWorkspacePlan :exec
+INSERT INTO workspace_plans (id, workspace_id, title)
+VALUES (?, ?, ?);
+
+-- name: GetWorkspacePlan :one
+SELECT * FROM workspace_plans
+WHERE id = ? AND workspace_id = ?;
+
+-- name: TouchWorkspacePlan :exec
+UPDATE workspace_plans
+SET title = ?, updated_at = strftime('%Y-%m-%dT%H:%M:%fZ', 'now')
+WHERE id = ?;
+
+-- name: ListWorkspacePlans :many
+SELECT p.*, CAST(COALESCE(MAX(r.revision), 0) AS INTEGER) AS latest_revision
+FROM workspace_plans p
+LEFT JOIN plan_revisions r ON r.plan_id = p.id
+WHERE p.workspace_id = ?
+GROUP BY p.id
+ORDER BY p.updated_at DESC, p.id;
+
+-- name: DeleteWorkspacePlan :execrows
+DELETE FROM workspace_plans
+WHERE id = ? AND workspace_id = ?;
+
+-- name: DeleteWorkspacePlansByWorkspace :exec
+DELETE FROM workspace_plans
+WHERE workspace_id = ?;
+
+-- name: AppendPlanRevision :one
+INSERT INTO plan_revisions (plan_id, revision, content, source_agent_id)
+SELECT sqlc.arg(plan_id), COALESCE(MAX(revision), 0) + 1, sqlc.arg(content), sqlc.arg(source_agent_id)
+FROM plan_revisions
+WHERE plan_id = sqlc.arg(plan_id)
+RETURNING revision;
+
+-- name: GetPlanRevision :one
+SELECT * FROM plan_revisions
+WHERE plan_id = ? AND revision = ?;
+
+-- name: GetLatestPlanRevision :one
+SELECT * FROM plan_revisions
+WHERE plan_id = ?
+ORDER BY revision DESC
+LIMIT 1;
+
+-- name: ListPlanRevisions :many
+SELECT * FROM plan_revisions
+WHERE plan_id = ?
+ORDER BY revision;
@@ -0,0 +1,33 @@
+-- name: CreateSubAgentRun :exec
+INSERT INTO agent_sub_agent_runs (agent_id, tool_use_id, parent_tool_use_id, turn_message_id, subagent_type, description)
+VALUES (?, ?, ?, ?, ?, ?)
+ON CONFLICT(agent_id, tool_use_id) DO NOTHING;
+
+-- name: AddSubAgentRunUsage :exec
+UPDATE agent_sub_agent_runs
+SET input_tokens = input_tokens + sqlc.arg(input_tokens),
+    output_tokens = output_tokens + sqlc.arg(output_tokens),
+    cache_creation_input_tokens = cache_creation_input_tokens + sqlc.arg(cache_creation_input_tokens),
+    cache_read_input_tokens = cache_read_input_tokens + sqlc.arg(cache_read_input_tokens)
+WHERE agent_id = sqlc.arg(agent_id) AND tool_use_id = sqlc.arg(tool_use_id);
+
+-- name: EndSubAgentRun :exec
+UPDATE agent_sub_agent_runs
+SET status = ?, ended_at = strftime('%Y-%m-%dT%H:%M:%fZ', 'now')
+WHERE agent_id = ? AND tool_use_id = ? AND status = 'running';
+
+-- name: ListSubAgentRuns :many
+SELECT * FROM agent_sub_agent_runs
+WHERE agent_id = sqlc.arg(agent_id)
+  AND (CAST(sqlc.arg(turn_message_id) AS TEXT) = '' OR turn_message_id = sqlc.arg(turn_message_id))
+ORDER BY started_at, tool_use_id;
+
+-- name: ListSubAgentUsageByTurn :many
+SELECT turn_message_id,
+       CAST(SUM(input_tokens) AS INTEGER) AS input_tokens,
+       CAST(SUM(output_tokens) AS INTEGER) AS output_tokens,
+       CAST(SUM(cache_creation_input_tokens) AS INTEGER) AS cache_creation_input_tokens,
+       CAST(SUM(cache_read_input_tokens) AS INTEGER) AS cache_read_input_tokens
+FROM agent_sub_agent_runs
+WHERE agent_id = ?
+GROUP BY turn_message_id;
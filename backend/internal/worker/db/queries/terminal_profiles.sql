@@ -0,0 +1,22 @@
+-- name: GetWorkspaceTerminalProfiles :one
+SELECT profiles FROM workspace_terminal_profiles
+WHERE workspace_id = ?;
+
+-- name: UpsertWorkspaceTerminalProfiles :exec
+INSERT INTO workspace_terminal_profiles (workspace_id, profiles)
+VALUES (?, ?)
+ON CONFLICT(workspace_id) DO UPDATE SET
+  profiles = excluded.profiles,
+  updated_at = strftime('%Y-%m-%dT%H:%M:%fZ', 'now');
+
+-- name: DeleteWorkspaceTerminalProfiles :exec
+DELETE FROM workspace_terminal_profiles
+WHERE workspace_id = ?;
+
+-- name: CreateTerminalProfile :exec
+INSERT INTO terminal_profiles (terminal_id, profile)
+VALUES (?, ?);
+
+-- name: GetTerminalProfile :one
+SELECT profile FROM terminal_profiles
+WHERE terminal_id = ?;
@@ -93,3 +93,9 @@ UPDATE terminals SET workspace_id = ? WHERE id = ?;
 
 -- name: SetTerminalStartupError :exec
 UPDATE terminals SET startup_error = ? WHERE id = ?;
+
+-- name: SetTerminalAgentID :exec
+UPDATE terminals SET agent_id = ? WHERE id = ?;
+
+-- name: ListOpenTerminalIDsByAgentID :many
+SELECT id FROM terminals WHERE agent_id = ? AND closed_at IS NULL;
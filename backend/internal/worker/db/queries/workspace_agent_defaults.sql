@@ -0,0 +1,14 @@
+-- name: GetWorkspaceAgentDefaults :one
+SELECT defaults FROM workspace_agent_defaults
+WHERE workspace_id = ?;
+
+-- name: UpsertWorkspaceAgentDefaults :exec
+INSERT INTO workspace_agent_defaults (workspace_id, defaults)
+VALUES (?, ?)
+ON CONFLICT(workspace_id) DO UPDATE SET
+  defaults = excluded.defaults,
+  updated_at = strftime('%Y-%m-%dT%H:%M:%fZ', 'now');
+
+-- name: DeleteWorkspaceAgentDefaults :exec
+DELETE FROM workspace_agent_defaults
+WHERE workspace_id = ?;
@@ -0,0 +1,30 @@
+-- name: GetWorkspaceProvisioning :one
+SELECT policy FROM workspace_provisioning
+WHERE workspace_id = ?;
+
+-- name: UpsertWorkspaceProvisioning :exec
+INSERT INTO workspace_provisioning (workspace_id, policy)
+VALUES (?, ?)
+ON CONFLICT(workspace_id) DO UPDATE SET
+  policy = excluded.policy,
+  updated_at = strftime('%Y-%m-%dT%H:%M:%fZ', 'now');
+
+-- name: DeleteWorkspaceProvisioning :exec
+DELETE FROM workspace_provisioning
+WHERE workspace_id = ?;
+
+-- name: GetWorkspaceProvisioningRunHash :one
+SELECT script_sha256 FROM workspace_provisioning_runs
+WHERE workspace_id = ? AND working_dir = ?;
+
+-- name: UpsertWorkspaceProvisioningRun :exec
+INSERT INTO workspace_provisioning_runs (workspace_id, working_dir, script_sha256, terminal_id)
+VALUES (?, ?, ?, ?)
+ON CONFLICT(workspace_id, working_dir) DO UPDATE SET
+  script_sha256 = excluded.script_sha256,
+  terminal_id = excluded.terminal_id,
+  finished_at = strftime('%Y-%m-%dT%H:%M:%fZ', 'now');
+
+-- name: DeleteWorkspaceProvisioningRuns :exec
+DELETE FROM workspace_provisioning_runs
+WHERE workspace_id = ?;
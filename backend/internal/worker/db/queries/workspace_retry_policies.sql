@@ -0,0 +1,14 @@
+-- name: GetWorkspaceRetryPolicy :one
+SELECT policy FROM workspace_retry_policies
+WHERE workspace_id = ?;
+
+-- name: UpsertWorkspaceRetryPolicy :exec
+INSERT INTO workspace_retry_policies (workspace_id, policy)
+VALUES (?, ?)
+ON CONFLICT(workspace_id) DO UPDATE SET
+  policy = excluded.policy,
+  updated_at = strftime('%Y-%m-%dT%H:%M:%fZ', 'now');
+
+-- name: DeleteWorkspaceRetryPolicy :exec
+DELETE FROM workspace_retry_policies
+WHERE workspace_id = ?;
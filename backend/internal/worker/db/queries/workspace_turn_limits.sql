@@ -0,0 +1,14 @@
+-- name: GetWorkspaceTurnLimits :one
+SELECT limits FROM workspace_turn_limits
+WHERE workspace_id = ?;
+
+-- name: UpsertWorkspaceTurnLimits :exec
+INSERT INTO workspace_turn_limits (workspace_id, limits)
+VALUES (?, ?)
+ON CONFLICT(workspace_id) DO UPDATE SET
+  limits = excluded.limits,
+  updated_at = strftime('%Y-%m-%dT%H:%M:%fZ', 'now');
+
+-- name: DeleteWorkspaceTurnLimits :exec
+DELETE FROM workspace_turn_limits
+WHERE workspace_id = ?;
@@ -64,3 +64,23 @@ WHERE w.deleted_at IS NULL
   AND NOT EXISTS (
     SELECT 1 FROM worktree_tab_liveness l WHERE l.worktree_id = w.id AND l.is_live = 1
   );
+
+-- ListLiveWorktreeWorkspaces returns every tracked worktree once per
+-- workspace with a live tab in it (liveness as in worktree_tab_liveness),
+-- and once with an empty workspace_id when no live tab uses it. The disk
+-- usage report charges a worktree to each workspace using it.
+-- name: ListLiveWorktreeWorkspaces :many
+SELECT w.worktree_path, CAST(COALESCE(l.workspace_id, '') AS TEXT) AS workspace_id
+FROM worktrees w
+LEFT JOIN (
+    SELECT t.worktree_id, a.workspace_id FROM worktree_tabs t
+    JOIN agents a ON a.id = t.tab_id AND a.closed_at IS NULL
+    UNION
+    SELECT t.worktree_id, te.workspace_id FROM worktree_tabs t
+    JOIN terminals te ON te.id = t.tab_id AND te.closed_at IS NULL
+    UNION
+    SELECT t.worktree_id, f.workspace_id FROM worktree_tabs t
+    JOIN worker_file_tabs f ON f.tab_id = t.tab_id AND f.org_id = t.org_id
+) l ON l.worktree_id = w.id
+WHERE w.deleted_at IS NULL
+ORDER BY w.worktree_path;
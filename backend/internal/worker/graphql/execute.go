@@ -0,0 +1,255 @@
+package graphql
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"reflect"
+)
+
+// executor runs a validated operation, collecting field errors as it
+// goes.
+type executor struct {
+	doc    *document
+	vars   map[string]any
+	errors []*Error
+
+	// The result budget: what the data has used so far, and whether it
+	// ran out. Once it has, no further field resolves.
+	maxBytes, maxValues int
+	bytes, values       int
+	exceeded            bool
+}
+
+// charge spends values and n bytes of the result budget, reporting false
+// -- and an error at path, the first time -- once either runs out.
+func (e *executor) charge(path []any, loc Location, values, n int) bool {
+	if e.exceeded {
+		return false
+	}
+	e.values += values
+	e.bytes += n
+	var msg string
+	switch {
+	case e.bytes > e.maxBytes:
+		msg = fmt.Sprintf("the result is larger than %d bytes", e.maxBytes)
+	case e.values > e.maxValues:
+		msg = fmt.Sprintf("the result has more than %d values", e.maxValues)
+	default:
+		return true
+	}
+	e.exceeded = true
+	e.addError(path, loc, msg)
+	return false
+}
+
+// scalarSize is roughly how many bytes v encodes to: exact for all but
+// strings, whose escapes it leaves out.
+func scalarSize(v any) int {
+	if s, ok := v.(string); ok {
+		return len(s) + 2
+	}
+	b, _ := json.Marshal(v)
+	return len(b)
+}
+
+func (e *executor) addError(path []any, loc Location, msg string) {
+	e.errors = append(e.errors, &Error{Message: msg, Locations: []Location{loc}, Path: path})
+}
+
+// collectedField is one response key and every selection that asked for
+// it; their subfields are merged.
+type collectedField struct {
+	key   string
+	nodes []*selection
+}
+
+// collect flattens sels -- applying @skip and @include and expanding
+// fragments -- into the fields to resolve on obj, in query order.
+func (e *executor) collect(sels []*selection, fields []*collectedField, index map[string]int, visited map[string]bool) []*collectedField {
+	for _, s := range sels {
+		if e.skipped(s.directives) {
+			continue
+		}
+		switch {
+		case s.field != nil:
+			key := s.field.responseKey()
+			if i, ok := index[key]; ok {
+				fields[i].nodes = append(fields[i].nodes, s)
+				continue
+			}
+			index[key] = len(fields)
+			fields = append(fields, &collectedField{key: key, nodes: []*selection{s}})
+		case s.inline != nil:
+			fields = e.collect(s.inline.selections, fields, index, visited)
+		default:
+			if visited[s.spread] {
+				continue
+			}
+			visited[s.spread] = true
+			f := e.doc.fragments[s.spread]
+			if e.skipped(f.directives) {
+				continue
+			}
+			fields = e.collect(f.selections, fields, index, visited)
+		}
+	}
+	return fields
+}
+
+func (e *executor) skipped(dirs []*directive) bool {
+	for _, d := range dirs {
+		cond, _ := coerceLiteral(NonNullOf(Boolean), d.arguments[0].value, e.vars)
+		if b, _ := cond.(bool); b == (d.name == "skip") {
+			return true
+		}
+	}
+	return false
+}
+
+// selectionSet resolves sels on obj. It reports false when a non-null
+// field came back null, which nulls obj in turn.
+func (e *executor) selectionSet(ctx context.Context, obj *Object, source any, sels []*selection, path []any) (*result, bool) {
+	fields := e.collect(sels, nil, map[string]int{}, map[string]bool{})
+	res := &result{values: make(map[string]any, len(fields))}
+	for _, cf := range fields {
+		v, ok := e.field(ctx, obj, source, cf, appendPath(path, cf.key))
+		if !ok {
+			if def := obj.Fields[cf.nodes[0].field.name]; def != nil && isNonNull(def.Type) {
+				return nil, false
+			}
+			v = nil
+		}
+		res.set(cf.key, v)
+	}
+	return res, true
+}
+
+// field resolves and completes one response key. false means the value
+// is null because of an error already reported.
+func (e *executor) field(ctx context.Context, obj *Object, source any, cf *collectedField, path []any) (any, bool) {
+	node := cf.nodes[0]
+	f := node.field
+	// The key, its quotes, the colon and a comma.
+	if !e.charge(path, node.loc, 0, len(cf.key)+4) {
+		return nil, false
+	}
+	if f.name == "__typename" {
+		return obj.Name, e.charge(path, node.loc, 1, scalarSize(obj.Name))
+	}
+	def := obj.Fields[f.name]
+	args, err := e.arguments(def.Args, f.arguments)
+	if err == nil {
+		err = ctx.Err()
+	}
+	var v any
+	if err == nil {
+		v, err = def.Resolve(ctx, ResolveParams{Source: source, Args: args})
+	}
+	if err != nil {
+		e.addError(path, node.loc, err.Error())
+		return nil, false
+	}
+	var sub []*selection
+	for _, n := range cf.nodes {
+		sub = append(sub, n.field.selections...)
+	}
+	return e.complete(ctx, def.Type, v, sub, path, node.loc)
+}
+
+// arguments coerces a field's arguments, applying defaults.
+func (e *executor) arguments(defs map[string]*Argument, nodes []*argumentNode) (map[string]any, error) {
+	args := make(map[string]any, len(defs))
+	for _, a := range nodes {
+		if a.value.kind == valueVariable {
+			if _, ok := e.vars[a.value.text]; !ok {
+				continue // as if left out
+			}
+		}
+		v, err := coerceLiteral(defs[a.name].Type, a.value, e.vars)
+		if err != nil {
+			return nil, err
+		}
+		args[a.name] = v
+	}
+	for name, def := range defs {
+		if _, ok := args[name]; ok || def.Default == nil {
+			continue
+		}
+		v, err := coerceInput(def.Type, def.Default)
+		if err != nil {
+			return nil, err
+		}
+		args[name] = v
+	}
+	return args, nil
+}
+
+// complete shapes a resolved value to t.
+func (e *executor) complete(ctx context.Context, t Type, v any, sels []*selection, path []any, loc Location) (any, bool) {
+	if nn, ok := t.(*NonNull); ok {
+		r, ok := e.complete(ctx, nn.OfType, v, sels, path, loc)
+		if !ok {
+			return nil, false
+		}
+		if r == nil {
+			e.addError(path, loc, "cannot return null for non-nullable field")
+			return nil, false
+		}
+		return r, true
+	}
+	rv := reflect.ValueOf(v)
+	if v == nil || (rv.Kind() == reflect.Pointer || rv.Kind() == reflect.Map) && rv.IsNil() {
+		return nil, true
+	}
+
+	switch t := t.(type) {
+	case *List:
+		if rv.Kind() != reflect.Slice && rv.Kind() != reflect.Array {
+			e.addError(path, loc, "expected a list")
+			return nil, false
+		}
+		if !e.charge(path, loc, 1, 2) {
+			return nil, false
+		}
+		out := make([]any, rv.Len())
+		for i := range out {
+			item, ok := e.complete(ctx, t.OfType, rv.Index(i).Interface(), sels, appendPath(path, i), loc)
+			if !ok && isNonNull(t.OfType) {
+				return nil, false
+			}
+			out[i] = item
+		}
+		return out, true
+	case *Scalar:
+		if rv.Kind() == reflect.Pointer {
+			v = rv.Elem().Interface()
+		}
+		s, err := serialize(t, v)
+		if err != nil {
+			e.addError(path, loc, err.Error())
+			return nil, false
+		}
+		if !e.charge(path, loc, 1, scalarSize(s)) {
+			return nil, false
+		}
+		return s, true
+	case *Object:
+		if !e.charge(path, loc, 1, 2) {
+			return nil, false
+		}
+		r, ok := e.selectionSet(ctx, t, v, sels, path)
+		if !ok {
+			return nil, false
+		}
+		return r, true
+	}
+	return nil, true
+}
+
+// appendPath returns path plus elem without aliasing path's array.
+func appendPath(path []any, elem any) []any {
+	out := make([]any, len(path), len(path)+1)
+	copy(out, path)
+	return append(out, elem)
+}
@@ -0,0 +1,249 @@
+// Package graphql executes read-only GraphQL queries against a schema
+// declared in Go. It implements the parts of the spec a dashboard needs to
+// fetch exactly the fields it wants in one round trip -- operations,
+// variables, aliases, arguments, named and inline fragments, @skip and
+// @include, and null propagation -- and leaves out mutations,
+// subscriptions, interfaces, unions, input objects, enums and
+// introspection beyond __typename. Int values are 64-bit: token totals
+// outgrow the spec's 32 bits.
+package graphql
+
+import (
+	"bytes"
+	"cmp"
+	"context"
+	"encoding/json"
+	"fmt"
+)
+
+// Type is a GraphQL output or input type: a *Scalar, an *Object, a *List
+// or a *NonNull.
+type Type interface {
+	String() string
+}
+
+// Scalar is a built-in scalar type. A schema cannot declare its own.
+type Scalar struct {
+	name string
+}
+
+func (s *Scalar) String() string { return s.name }
+
+// The built-in scalars.
+var (
+	Int     = &Scalar{name: "Int"}
+	Float   = &Scalar{name: "Float"}
+	String  = &Scalar{name: "String"}
+	Boolean = &Scalar{name: "Boolean"}
+	ID      = &Scalar{name: "ID"}
+)
+
+var scalars = map[string]*Scalar{"Int": Int, "Float": Float, "String": String, "Boolean": Boolean, "ID": ID}
+
+// Object is an object type.
+type Object struct {
+	Name   string
+	Fields map[string]*Field
+}
+
+func (o *Object) String() string { return o.Name }
+
+// List is a list of OfType.
+type List struct{ OfType Type }
+
+func (l *List) String() string { return "[" + l.OfType.String() + "]" }
+
+// NonNull is OfType without null.
+type NonNull struct{ OfType Type }
+
+func (n *NonNull) String() string { return n.OfType.String() + "!" }
+
+// ListOf returns [t].
+func ListOf(t Type) *List { return &List{OfType: t} }
+
+// NonNullOf returns t!.
+func NonNullOf(t Type) *NonNull { return &NonNull{OfType: t} }
+
+// Field is one field of an Object.
+type Field struct {
+	Type Type
+	Args map[string]*Argument
+	// Resolve returns the field's value. A scalar's is a Go string, bool,
+	// integer or float (or a pointer to one, nil for null); a list's is any
+	// slice; an object's is whatever its own fields' resolvers take as
+	// Source. An error nulls the field and is reported in the response.
+	Resolve func(ctx context.Context, p ResolveParams) (any, error)
+}
+
+// Argument is a field argument. Its type is a scalar, or a list or
+// non-null of one.
+type Argument struct {
+	Type    Type
+	Default any // used when the query leaves the argument out; nil for none
+}
+
+// ResolveParams is what a resolver is called with.
+type ResolveParams struct {
+	// Source is the value the parent field resolved to; nil on Query.
+	Source any
+	// Args holds the field's arguments, coerced to int64, float64,
+	// string, bool, []any or nil. An argument with no value and no
+	// default is absent.
+	Args map[string]any
+}
+
+// Schema is a query root plus the limits that bound a query's cost.
+type Schema struct {
+	Query *Object
+	// MaxDepth caps how deeply fields nest; 0 means DefaultMaxDepth.
+	MaxDepth int
+	// MaxFields caps the fields an operation selects, counting each
+	// fragment spread as one more plus everything it expands to; 0 means
+	// DefaultMaxFields.
+	MaxFields int
+	// MaxResultBytes caps the size of the data a query returns, counting
+	// keys and scalar values as they encode; 0 means
+	// DefaultMaxResultBytes. Lists make a few selected fields expand to
+	// any number of values, so the query's shape alone does not bound it.
+	MaxResultBytes int
+	// MaxResultValues caps the objects, lists and scalars in the data; 0
+	// means DefaultMaxResultValues.
+	MaxResultValues int
+}
+
+const (
+	DefaultMaxDepth        = 10
+	DefaultMaxFields       = 500
+	DefaultMaxResultBytes  = 4 << 20
+	DefaultMaxResultValues = 100_000
+)
+
+// Request is a query and its variables, as a GraphQL-over-HTTP request
+// body carries them.
+type Request struct {
+	Query         string         `json:"query"`
+	Variables     map[string]any `json:"variables,omitempty"`
+	OperationName string         `json:"operationName,omitempty"`
+}
+
+// Location is a 1-based line and column in the query.
+type Location struct {
+	Line   int `json:"line"`
+	Column int `json:"column"`
+}
+
+// Error is an entry of a response's errors.
+type Error struct {
+	Message   string     `json:"message"`
+	Locations []Location `json:"locations,omitempty"`
+	Path      []any      `json:"path,omitempty"` // field names and list indices
+}
+
+func (e *Error) Error() string { return e.Message }
+
+// Response is a query's result. Data is absent when the request failed
+// before execution -- a syntax or validation error -- and null when a
+// non-null root field failed or the result outgrew the schema's limits.
+type Response struct {
+	Data   json.RawMessage `json:"data,omitempty"`
+	Errors []*Error        `json:"errors,omitempty"`
+}
+
+// Execute parses, validates and runs req against s.
+func (s *Schema) Execute(ctx context.Context, req Request) *Response {
+	doc, perr := parse(req.Query)
+	if perr != nil {
+		return &Response{Errors: []*Error{perr}}
+	}
+	op, err := doc.operation(req.OperationName)
+	if err != nil {
+		return &Response{Errors: []*Error{err}}
+	}
+	v := &validator{schema: s, doc: doc, op: op}
+	if errs := v.validate(); len(errs) > 0 {
+		return &Response{Errors: errs}
+	}
+	vars, errs := coerceVariables(op, req.Variables)
+	if len(errs) > 0 {
+		return &Response{Errors: errs}
+	}
+
+	e := &executor{
+		doc:       doc,
+		vars:      vars,
+		maxBytes:  cmp.Or(s.MaxResultBytes, DefaultMaxResultBytes),
+		maxValues: cmp.Or(s.MaxResultValues, DefaultMaxResultValues),
+	}
+	data, ok := e.selectionSet(ctx, s.Query, nil, op.selections, nil)
+	resp := &Response{Errors: e.errors, Data: json.RawMessage("null")}
+	if ok && !e.exceeded {
+		raw, merr := json.Marshal(data)
+		if merr != nil {
+			return &Response{Errors: append(e.errors, &Error{Message: "encode result: " + merr.Error()})}
+		}
+		resp.Data = raw
+	}
+	return resp
+}
+
+// operation picks the operation named name, or the only one.
+func (d *document) operation(name string) (*operation, *Error) {
+	var op *operation
+	if name == "" {
+		if len(d.operations) > 1 {
+			return nil, &Error{Message: "the document has several operations; name the one to run"}
+		}
+		op = d.operations[0]
+	} else {
+		for _, o := range d.operations {
+			if o.name == name {
+				op = o
+				break
+			}
+		}
+		if op == nil {
+			return nil, &Error{Message: fmt.Sprintf("unknown operation %q", name)}
+		}
+	}
+	if op.kind != "query" {
+		return nil, &Error{Message: op.kind + " operations are not supported", Locations: []Location{op.loc}}
+	}
+	return op, nil
+}
+
+// result is an object value whose keys keep the order the query selected
+// them in, as the spec asks of a serialized response.
+type result struct {
+	keys   []string
+	values map[string]any
+}
+
+func (r *result) set(key string, v any) {
+	if _, ok := r.values[key]; !ok {
+		r.keys = append(r.keys, key)
+	}
+	r.values[key] = v
+}
+
+func (r *result) MarshalJSON() ([]byte, error) {
+	var buf bytes.Buffer
+	buf.WriteByte('{')
+	for i, k := range r.keys {
+		if i > 0 {
+			buf.WriteByte(',')
+		}
+		key, err := json.Marshal(k)
+		if err != nil {
+			return nil, err
+		}
+		buf.Write(key)
+		buf.WriteByte(':')
+		b, err := json.Marshal(r.values[k])
+		if err != nil {
+			return nil, err
+		}
+		buf.Write(b)
+	}
+	buf.WriteByte('}')
+	return buf.Bytes(), nil
+}
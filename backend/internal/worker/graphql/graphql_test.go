@@ -0,0 +1,306 @@
+package graphql
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type testBook struct {
+	ID     string
+	Title  string
+	Pages  int32
+	Rating *float64
+}
+
+// testSchema is a small library: books with an author, which lists the
+// books back.
+func testSchema() *Schema {
+	rating := 4.5
+	books := []*testBook{
+		{ID: "b1", Title: "Dune", Pages: 412, Rating: &rating},
+		{ID: "b2", Title: "Emma", Pages: 474},
+	}
+	author := &Object{Name: "Author", Fields: map[string]*Field{}}
+	book := &Object{Name: "Book", Fields: map[string]*Field{
+		"id":    {Type: NonNullOf(ID), Resolve: func(_ context.Context, p ResolveParams) (any, error) { return p.Source.(*testBook).ID, nil }},
+		"title": {Type: NonNullOf(String), Resolve: func(_ context.Context, p ResolveParams) (any, error) { return p.Source.(*testBook).Title, nil }},
+		"pages": {Type: NonNullOf(Int), Resolve: func(_ context.Context, p ResolveParams) (any, error) { return p.Source.(*testBook).Pages, nil }},
+		"rating": {Type: Float, Resolve: func(_ context.Context, p ResolveParams) (any, error) {
+			return p.Source.(*testBook).Rating, nil
+		}},
+		"excerpt": {
+			Type: NonNullOf(String),
+			Args: map[string]*Argument{"length": {Type: Int, Default: 2}},
+			Resolve: func(_ context.Context, p ResolveParams) (any, error) {
+				return p.Source.(*testBook).Title[:p.Args["length"].(int64)], nil
+			},
+		},
+		"author": {Type: author, Resolve: func(context.Context, ResolveParams) (any, error) { return "Anon", nil }},
+		"broken": {Type: String, Resolve: func(context.Context, ResolveParams) (any, error) { return nil, errors.New("boom") }},
+		"missing": {Type: NonNullOf(String), Resolve: func(context.Context, ResolveParams) (any, error) {
+			return nil, nil
+		}},
+	}}
+	author.Fields["name"] = &Field{Type: NonNullOf(String), Resolve: func(_ context.Context, p ResolveParams) (any, error) { return p.Source, nil }}
+	author.Fields["books"] = &Field{Type: NonNullOf(ListOf(NonNullOf(book))), Resolve: func(context.Context, ResolveParams) (any, error) { return books, nil }}
+
+	return &Schema{Query: &Object{Name: "Query", Fields: map[string]*Field{
+		"books": {
+			Type: NonNullOf(ListOf(NonNullOf(book))),
+			Args: map[string]*Argument{"ids": {Type: ListOf(NonNullOf(ID))}},
+			Resolve: func(_ context.Context, p ResolveParams) (any, error) {
+				ids, ok := p.Args["ids"].([]any)
+				if !ok {
+					return books, nil
+				}
+				var out []*testBook
+				for _, b := range books {
+					for _, id := range ids {
+						if b.ID == id {
+							out = append(out, b)
+						}
+					}
+				}
+				return out, nil
+			},
+		},
+		"book": {
+			Type: book,
+			Args: map[string]*Argument{"id": {Type: NonNullOf(ID)}},
+			Resolve: func(_ context.Context, p ResolveParams) (any, error) {
+				for _, b := range books {
+					if b.ID == p.Args["id"] {
+						return b, nil
+					}
+				}
+				return nil, nil
+			},
+		},
+	}}}
+}
+
+func run(t *testing.T, s *Schema, req Request) (string, []*Error) {
+	t.Helper()
+	resp := s.Execute(context.Background(), req)
+	return string(resp.Data), resp.Errors
+}
+
+func TestExecute_SelectsFieldsInQueryOrder(t *testing.T) {
+	data, errs := run(t, testSchema(), Request{Query: `
+		# Dashboard query.
+		{
+			books { title, id, __typename }
+			first: book(id: "b1") { pages rating short: excerpt long: excerpt(length: 4) }
+			none: book(id: "nope") { id }
+		}`})
+	require.Empty(t, errs)
+	assert.Equal(t, `{"books":[{"title":"Dune","id":"b1","__typename":"Book"},{"title":"Emma","id":"b2","__typename":"Book"}],`+
+		`"first":{"pages":412,"rating":4.5,"short":"Du","long":"Dune"},"none":null}`, data)
+}
+
+func TestExecute_VariablesAndOperationName(t *testing.T) {
+	query := `
+		query One($id: ID!, $len: Int = 3) { book(id: $id) { excerpt(length: $len) } }
+		query Some($ids: [ID!]) { books(ids: $ids) { id } }`
+
+	data, errs := run(t, testSchema(), Request{Query: query, OperationName: "One", Variables: map[string]any{"id": "b2"}})
+	require.Empty(t, errs)
+	assert.JSONEq(t, `{"book":{"excerpt":"Emm"}}`, data)
+
+	// A single value where a list is expected is coerced to a list, and a
+	// JSON number decoded with UseNumber is accepted.
+	data, errs = run(t, testSchema(), Request{Query: query, OperationName: "Some", Variables: map[string]any{"ids": "b1"}})
+	require.Empty(t, errs)
+	assert.JSONEq(t, `{"books":[{"id":"b1"}]}`, data)
+	data, errs = run(t, testSchema(), Request{Query: query, OperationName: "One", Variables: map[string]any{"id": "b1", "len": json.Number("1")}})
+	require.Empty(t, errs)
+	assert.JSONEq(t, `{"book":{"excerpt":"D"}}`, data)
+
+	// An absent nullable variable leaves its argument out.
+	data, errs = run(t, testSchema(), Request{Query: query, OperationName: "Some"})
+	require.Empty(t, errs)
+	assert.JSONEq(t, `{"books":[{"id":"b1"},{"id":"b2"}]}`, data)
+
+	_, errs = run(t, testSchema(), Request{Query: query})
+	require.Len(t, errs, 1)
+	assert.Contains(t, errs[0].Message, "several operations")
+
+	_, errs = run(t, testSchema(), Request{Query: query, OperationName: "One"})
+	require.Len(t, errs, 1)
+	assert.Equal(t, "variable $id of required type ID! was not provided", errs[0].Message)
+
+	_, errs = run(t, testSchema(), Request{Query: query, OperationName: "One", Variables: map[string]any{"id": "b1", "len": 1.5}})
+	require.Len(t, errs, 1)
+	assert.Contains(t, errs[0].Message, "variable $len expected Int")
+}
+
+func TestExecute_FragmentsAndDirectives(t *testing.T) {
+	data, errs := run(t, testSchema(), Request{
+		Query: `
+			query($withPages: Boolean!) {
+				book(id: "b1") {
+					...Names
+					... on Book { pages @include(if: $withPages) }
+					... @skip(if: true) { rating }
+				}
+			}
+			fragment Names on Book { id title author { name } }`,
+		Variables: map[string]any{"withPages": false},
+	})
+	require.Empty(t, errs)
+	assert.Equal(t, `{"book":{"id":"b1","title":"Dune","author":{"name":"Anon"}}}`, data)
+}
+
+func TestExecute_MergesSubfieldsOfARepeatedField(t *testing.T) {
+	data, errs := run(t, testSchema(), Request{Query: `{ book(id: "b2") { id } book(id: "b2") { title } }`})
+	require.Empty(t, errs)
+	assert.Equal(t, `{"book":{"id":"b2","title":"Emma"}}`, data)
+}
+
+func TestExecute_FieldErrorsNullTheNearestNullableField(t *testing.T) {
+	data, errs := run(t, testSchema(), Request{Query: `{ book(id: "b1") { id broken } }`})
+	assert.Equal(t, `{"book":{"id":"b1","broken":null}}`, data)
+	require.Len(t, errs, 1)
+	assert.Equal(t, "boom", errs[0].Message)
+	assert.Equal(t, []any{"book", "broken"}, errs[0].Path)
+	assert.Equal(t, Location{Line: 1, Column: 23}, errs[0].Locations[0])
+
+	// missing is non-null, so book is nulled in its place.
+	data, errs = run(t, testSchema(), Request{Query: `{ book(id: "b1") { id missing } }`})
+	assert.Equal(t, `{"book":null}`, data)
+	require.Len(t, errs, 1)
+	assert.Equal(t, []any{"book", "missing"}, errs[0].Path)
+
+	// books is non-null all the way up, so data itself is null, and the
+	// rest of the list is not completed.
+	data, errs = run(t, testSchema(), Request{Query: `{ books { missing } }`})
+	assert.Equal(t, `null`, data)
+	require.Len(t, errs, 1)
+	assert.Equal(t, []any{"books", 0, "missing"}, errs[0].Path)
+}
+
+func TestExecute_RejectsInvalidQueriesWithoutData(t *testing.T) {
+	for query, want := range map[string]string{
+		`{ books { id `:                                  "syntax error: unexpected end of document",
+		`{ book(id: "b1") { id }`:                        "syntax error: unexpected end of document",
+		`{ books { nope } }`:                             `Book has no field "nope"`,
+		`{ books }`:                                      `field "books" is a [Book!]! and needs subfields`,
+		`{ books { id { x } } }`:                         `field "id" is a ID! and cannot have subfields`,
+		`{ book { id } }`:                                `field Query.book: argument "id" is required`,
+		`{ book(id: 1.5) { id } }`:                       `field Query.book: argument "id": expected ID, got 1.5`,
+		`{ book(id: "b1", bad: 1) { id } }`:              `field Query.book has no argument "bad"`,
+		`{ book(id: $id) { id } }`:                       `field Query.book: argument "id": variable $id is not defined`,
+		`query($id: ID) { book(id: $id) { id } }`:        `field Query.book: argument "id": variable $id of type ID cannot be used as ID!`,
+		`query($b: Book) { books { id } }`:               `variable $b: unknown input type "Book"`,
+		`{ books { ...F } } fragment F on Book { ...F }`: `fragment "F" spreads itself`,
+		`{ books { ...G } }`:                             `unknown fragment "G"`,
+		`{ books { ... on Author { name } } }`:           `a fragment on Author cannot be spread in Book`,
+		`{ books { id @defer } }`:                        `unknown directive @defer`,
+		`mutation { books { id } }`:                      `mutation operations are not supported`,
+		`{ books { title(x: "\q") } }`:                   `syntax error: invalid escape \q`,
+	} {
+		resp := testSchema().Execute(context.Background(), Request{Query: query})
+		assert.Nil(t, resp.Data, query)
+		if assert.NotEmpty(t, resp.Errors, query) {
+			assert.Equal(t, want, resp.Errors[0].Message, query)
+		}
+	}
+}
+
+func TestExecute_EnforcesDepthAndFieldLimits(t *testing.T) {
+	s := testSchema()
+	s.MaxDepth = 3
+	_, errs := run(t, s, Request{Query: `{ books { author { books { id } } } }`})
+	require.Len(t, errs, 1)
+	assert.Equal(t, "the query nests fields deeper than 3", errs[0].Message)
+
+	s = testSchema()
+	s.MaxFields = 4
+	_, errs = run(t, s, Request{Query: `{ books { ...F ...G } } fragment F on Book { id title } fragment G on Book { ...F }`})
+	require.Len(t, errs, 1)
+	assert.Equal(t, "the query selects more than 4 fields", errs[0].Message)
+}
+
+func TestExecute_EnforcesResultLimits(t *testing.T) {
+	s := testSchema()
+	s.MaxResultBytes = 40
+	resp := s.Execute(context.Background(), Request{Query: `{ books { id title } }`})
+	b, err := json.Marshal(resp)
+	require.NoError(t, err)
+	assert.JSONEq(t, `{"data":null,"errors":[{"message":"the result is larger than 40 bytes",`+
+		`"locations":[{"line":1,"column":11}],"path":["books",1,"id"]}]}`, string(b))
+
+	s = testSchema()
+	s.MaxResultValues = 3
+	data, errs := run(t, s, Request{Query: `{ books { id title } }`})
+	assert.Equal(t, "null", data)
+	require.Len(t, errs, 1)
+	assert.Equal(t, "the result has more than 3 values", errs[0].Message)
+	assert.Equal(t, []any{"books", 0, "title"}, errs[0].Path)
+
+	data, errs = run(t, testSchema(), Request{Query: `{ books { id title } }`})
+	require.Empty(t, errs)
+	assert.JSONEq(t, `{"books":[{"id":"b1","title":"Dune"},{"id":"b2","title":"Emma"}]}`, data)
+}
+
+func TestExecute_RejectsDoublingFragmentsCheaply(t *testing.T) {
+	// Each fragment spreads the next twice: expanded, the query is 2^30
+	// spreads of a missing fragment, in about 1 KB.
+	const n = 30
+	var b strings.Builder
+	b.WriteString("{ books { ...F0 } }")
+	for i := range n {
+		fmt.Fprintf(&b, " fragment F%d on Book { ...F%d ...F%d }", i, i+1, i+1)
+	}
+	fmt.Fprintf(&b, " fragment F%d on Book { ...Missing }", n)
+
+	start := time.Now()
+	_, errs := run(t, testSchema(), Request{Query: b.String()})
+	assert.Less(t, time.Since(start), time.Second)
+	require.NotEmpty(t, errs)
+	assert.LessOrEqual(t, len(errs), maxValidationErrors)
+	assert.Equal(t, `unknown fragment "Missing"`, errs[0].Message)
+
+	// With every fragment valid, the expansion is charged in full.
+	b.Reset()
+	b.WriteString("{ books { ...F0 } }")
+	for i := range n {
+		fmt.Fprintf(&b, " fragment F%d on Book { ...F%d ...F%d }", i, i+1, i+1)
+	}
+	fmt.Fprintf(&b, " fragment F%d on Book { id }", n)
+	_, errs = run(t, testSchema(), Request{Query: b.String()})
+	require.Len(t, errs, 1)
+	assert.Equal(t, fmt.Sprintf("the query selects more than %d fields", DefaultMaxFields), errs[0].Message)
+}
+
+func TestExecute_ChecksAFragmentsDepthWhereverItIsSpread(t *testing.T) {
+	s := testSchema()
+	s.MaxDepth = 3
+	_, errs := run(t, s, Request{Query: `{ books { ...F author { books { ...F } } } } fragment F on Book { author { name } }`})
+	require.Len(t, errs, 1)
+	assert.Equal(t, "the query nests fields deeper than 3", errs[0].Message)
+
+	data, errs := run(t, s, Request{Query: `{ books { ...F ...F } } fragment F on Book { author { name } }`})
+	require.Empty(t, errs)
+	assert.JSONEq(t, `{"books":[{"author":{"name":"Anon"}},{"author":{"name":"Anon"}}]}`, data)
+}
+
+func TestResponse_OmitsDataBeforeExecution(t *testing.T) {
+	b, err := json.Marshal(testSchema().Execute(context.Background(), Request{Query: `{`}))
+	require.NoError(t, err)
+	assert.JSONEq(t, `{"errors":[{"message":"syntax error: unexpected end of document","locations":[{"line":1,"column":2}]}]}`, string(b))
+}
+
+func TestBlockString(t *testing.T) {
+	data, errs := run(t, testSchema(), Request{Query: "{ book(id: \"\"\"\n    b1\n  \"\"\") { id } }"})
+	require.Empty(t, errs)
+	assert.Equal(t, `{"book":{"id":"b1"}}`, data)
+}
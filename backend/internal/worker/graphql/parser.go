@@ -0,0 +1,531 @@
+package graphql
+
+import (
+	"strconv"
+)
+
+// document is a parsed query: its operations and named fragments.
+type document struct {
+	operations []*operation
+	fragments  map[string]*fragment
+}
+
+type operation struct {
+	kind       string // "query", "mutation" or "subscription"
+	name       string
+	variables  []*variableDef
+	selections []*selection
+	loc        Location
+}
+
+type variableDef struct {
+	name         string
+	typ          *typeRef
+	defaultValue *value // nil when there is none
+	loc          Location
+}
+
+// typeRef is a type as written in a variable definition.
+type typeRef struct {
+	name    string   // a named type, or
+	elem    *typeRef // a list of elem
+	nonNull bool
+}
+
+func (t *typeRef) String() string {
+	s := t.name
+	if t.elem != nil {
+		s = "[" + t.elem.String() + "]"
+	}
+	if t.nonNull {
+		s += "!"
+	}
+	return s
+}
+
+type fragment struct {
+	name       string
+	typeCond   string
+	directives []*directive
+	selections []*selection
+	loc        Location
+}
+
+// selection is exactly one of a field, a fragment spread or an inline
+// fragment.
+type selection struct {
+	field      *fieldNode
+	spread     string    // a fragment spread's fragment name
+	inline     *fragment // an inline fragment; its name is empty
+	directives []*directive
+	loc        Location
+}
+
+type fieldNode struct {
+	alias      string
+	name       string
+	arguments  []*argumentNode
+	selections []*selection
+}
+
+// responseKey is the key the field's value takes in the result.
+func (f *fieldNode) responseKey() string {
+	if f.alias != "" {
+		return f.alias
+	}
+	return f.name
+}
+
+type argumentNode struct {
+	name  string
+	value *value
+	loc   Location
+}
+
+type directive struct {
+	name      string
+	arguments []*argumentNode
+	loc       Location
+}
+
+type valueKind int
+
+const (
+	valueNull valueKind = iota
+	valueVariable
+	valueInt
+	valueFloat
+	valueString
+	valueBoolean
+	valueEnum
+	valueList
+	valueObject
+)
+
+// value is a literal or variable reference in a query.
+type value struct {
+	kind   valueKind
+	text   string   // a variable's name, a number as written, a string or enum value
+	truth  bool     // valueBoolean
+	list   []*value // valueList
+	fields []*argumentNode
+	loc    Location
+}
+
+type parser struct {
+	lex *lexer
+	tok token
+}
+
+// parse parses a query document.
+func parse(src string) (*document, *Error) {
+	p := &parser{lex: newLexer(src)}
+	if err := p.advance(); err != nil {
+		return nil, err
+	}
+	doc := &document{fragments: map[string]*fragment{}}
+	for p.tok.kind != tokEOF {
+		switch {
+		case p.peekPunct("{"):
+			sels, err := p.selectionSet()
+			if err != nil {
+				return nil, err
+			}
+			doc.operations = append(doc.operations, &operation{kind: "query", selections: sels, loc: sels[0].loc})
+		case p.peekName("query"), p.peekName("mutation"), p.peekName("subscription"):
+			op, err := p.operation()
+			if err != nil {
+				return nil, err
+			}
+			doc.operations = append(doc.operations, op)
+		case p.peekName("fragment"):
+			f, err := p.fragmentDefinition()
+			if err != nil {
+				return nil, err
+			}
+			if _, dup := doc.fragments[f.name]; dup {
+				return nil, &Error{Message: "fragment " + strconv.Quote(f.name) + " is defined more than once", Locations: []Location{f.loc}}
+			}
+			doc.fragments[f.name] = f
+		default:
+			return nil, p.unexpected()
+		}
+	}
+	if len(doc.operations) == 0 {
+		return nil, &Error{Message: "the document has no operation"}
+	}
+	return doc, nil
+}
+
+func (p *parser) advance() *Error {
+	tok, err := p.lex.next()
+	if err != nil {
+		return err
+	}
+	p.tok = tok
+	return nil
+}
+
+func (p *parser) peekPunct(s string) bool { return p.tok.kind == tokPunct && p.tok.text == s }
+func (p *parser) peekName(s string) bool  { return p.tok.kind == tokName && p.tok.text == s }
+
+func (p *parser) unexpected() *Error {
+	if p.tok.kind == tokEOF {
+		return p.lex.errorf(p.tok.loc, "unexpected end of document")
+	}
+	return p.lex.errorf(p.tok.loc, "unexpected %q", p.tok.text)
+}
+
+// skipPunct consumes s when it is next and reports whether it was.
+func (p *parser) skipPunct(s string) (bool, *Error) {
+	if !p.peekPunct(s) {
+		return false, nil
+	}
+	return true, p.advance()
+}
+
+func (p *parser) expectPunct(s string) *Error {
+	if p.tok.kind == tokEOF {
+		return p.unexpected()
+	}
+	if !p.peekPunct(s) {
+		return p.lex.errorf(p.tok.loc, "expected %q", s)
+	}
+	return p.advance()
+}
+
+func (p *parser) name() (string, *Error) {
+	if p.tok.kind == tokEOF {
+		return "", p.unexpected()
+	}
+	if p.tok.kind != tokName {
+		return "", p.lex.errorf(p.tok.loc, "expected a name")
+	}
+	s := p.tok.text
+	return s, p.advance()
+}
+
+func (p *parser) operation() (*operation, *Error) {
+	op := &operation{kind: p.tok.text, loc: p.tok.loc}
+	if err := p.advance(); err != nil {
+		return nil, err
+	}
+	if p.tok.kind == tokName {
+		op.name = p.tok.text
+		if err := p.advance(); err != nil {
+			return nil, err
+		}
+	}
+	if ok, err := p.skipPunct("("); err != nil {
+		return nil, err
+	} else if ok {
+		for !p.peekPunct(")") {
+			v, err := p.variableDefinition()
+			if err != nil {
+				return nil, err
+			}
+			op.variables = append(op.variables, v)
+		}
+		if err := p.advance(); err != nil {
+			return nil, err
+		}
+	}
+	if _, err := p.directives(); err != nil {
+		return nil, err
+	}
+	sels, err := p.selectionSet()
+	if err != nil {
+		return nil, err
+	}
+	op.selections = sels
+	return op, nil
+}
+
+func (p *parser) variableDefinition() (*variableDef, *Error) {
+	v := &variableDef{loc: p.tok.loc}
+	if err := p.expectPunct("$"); err != nil {
+		return nil, err
+	}
+	name, err := p.name()
+	if err != nil {
+		return nil, err
+	}
+	v.name = name
+	if err := p.expectPunct(":"); err != nil {
+		return nil, err
+	}
+	if v.typ, err = p.typeReference(); err != nil {
+		return nil, err
+	}
+	if ok, err := p.skipPunct("="); err != nil {
+		return nil, err
+	} else if ok {
+		if v.defaultValue, err = p.value(true); err != nil {
+			return nil, err
+		}
+	}
+	if _, err := p.directives(); err != nil {
+		return nil, err
+	}
+	return v, nil
+}
+
+func (p *parser) typeReference() (*typeRef, *Error) {
+	t := &typeRef{}
+	if ok, err := p.skipPunct("["); err != nil {
+		return nil, err
+	} else if ok {
+		elem, err := p.typeReference()
+		if err != nil {
+			return nil, err
+		}
+		if err := p.expectPunct("]"); err != nil {
+			return nil, err
+		}
+		t.elem = elem
+	} else {
+		name, err := p.name()
+		if err != nil {
+			return nil, err
+		}
+		t.name = name
+	}
+	nonNull, err := p.skipPunct("!")
+	if err != nil {
+		return nil, err
+	}
+	t.nonNull = nonNull
+	return t, nil
+}
+
+func (p *parser) fragmentDefinition() (*fragment, *Error) {
+	f := &fragment{loc: p.tok.loc}
+	if err := p.advance(); err != nil {
+		return nil, err
+	}
+	name, err := p.name()
+	if err != nil {
+		return nil, err
+	}
+	if name == "on" {
+		return nil, p.lex.errorf(f.loc, "a fragment cannot be named \"on\"")
+	}
+	f.name = name
+	if !p.peekName("on") {
+		return nil, p.lex.errorf(p.tok.loc, "expected \"on\"")
+	}
+	if err := p.advance(); err != nil {
+		return nil, err
+	}
+	if f.typeCond, err = p.name(); err != nil {
+		return nil, err
+	}
+	if f.directives, err = p.directives(); err != nil {
+		return nil, err
+	}
+	if f.selections, err = p.selectionSet(); err != nil {
+		return nil, err
+	}
+	return f, nil
+}
+
+func (p *parser) selectionSet() ([]*selection, *Error) {
+	if err := p.expectPunct("{"); err != nil {
+		return nil, err
+	}
+	var sels []*selection
+	for !p.peekPunct("}") {
+		s, err := p.selection()
+		if err != nil {
+			return nil, err
+		}
+		sels = append(sels, s)
+	}
+	if len(sels) == 0 {
+		return nil, p.lex.errorf(p.tok.loc, "a selection set cannot be empty")
+	}
+	return sels, p.advance()
+}
+
+func (p *parser) selection() (*selection, *Error) {
+	s := &selection{loc: p.tok.loc}
+	var err *Error
+	if ok, err := p.skipPunct("..."); err != nil {
+		return nil, err
+	} else if ok {
+		switch {
+		case p.tok.kind == tokName && p.tok.text != "on":
+			s.spread = p.tok.text
+			if err := p.advance(); err != nil {
+				return nil, err
+			}
+			if s.directives, err = p.directives(); err != nil {
+				return nil, err
+			}
+		default:
+			f := &fragment{loc: s.loc}
+			if p.peekName("on") {
+				if err := p.advance(); err != nil {
+					return nil, err
+				}
+				if f.typeCond, err = p.name(); err != nil {
+					return nil, err
+				}
+			}
+			if s.directives, err = p.directives(); err != nil {
+				return nil, err
+			}
+			if f.selections, err = p.selectionSet(); err != nil {
+				return nil, err
+			}
+			s.inline = f
+		}
+		return s, nil
+	}
+
+	f := &fieldNode{}
+	if f.name, err = p.name(); err != nil {
+		return nil, err
+	}
+	if ok, err := p.skipPunct(":"); err != nil {
+		return nil, err
+	} else if ok {
+		f.alias = f.name
+		if f.name, err = p.name(); err != nil {
+			return nil, err
+		}
+	}
+	if f.arguments, err = p.arguments(false); err != nil {
+		return nil, err
+	}
+	if s.directives, err = p.directives(); err != nil {
+		return nil, err
+	}
+	if p.peekPunct("{") {
+		if f.selections, err = p.selectionSet(); err != nil {
+			return nil, err
+		}
+	}
+	s.field = f
+	return s, nil
+}
+
+func (p *parser) arguments(constant bool) ([]*argumentNode, *Error) {
+	if ok, err := p.skipPunct("("); err != nil || !ok {
+		return nil, err
+	}
+	var args []*argumentNode
+	for !p.peekPunct(")") {
+		a := &argumentNode{loc: p.tok.loc}
+		var err *Error
+		if a.name, err = p.name(); err != nil {
+			return nil, err
+		}
+		if err := p.expectPunct(":"); err != nil {
+			return nil, err
+		}
+		if a.value, err = p.value(constant); err != nil {
+			return nil, err
+		}
+		args = append(args, a)
+	}
+	if len(args) == 0 {
+		return nil, p.lex.errorf(p.tok.loc, "an argument list cannot be empty")
+	}
+	return args, p.advance()
+}
+
+func (p *parser) directives() ([]*directive, *Error) {
+	var dirs []*directive
+	for p.peekPunct("@") {
+		d := &directive{loc: p.tok.loc}
+		if err := p.advance(); err != nil {
+			return nil, err
+		}
+		var err *Error
+		if d.name, err = p.name(); err != nil {
+			return nil, err
+		}
+		if d.arguments, err = p.arguments(false); err != nil {
+			return nil, err
+		}
+		dirs = append(dirs, d)
+	}
+	return dirs, nil
+}
+
+// value parses a value; constant disallows variables, as in a variable's
+// default.
+func (p *parser) value(constant bool) (*value, *Error) {
+	v := &value{loc: p.tok.loc}
+	switch p.tok.kind {
+	case tokPunct:
+		switch p.tok.text {
+		case "$":
+			if constant {
+				return nil, p.lex.errorf(v.loc, "a variable is not allowed here")
+			}
+			if err := p.advance(); err != nil {
+				return nil, err
+			}
+			name, err := p.name()
+			if err != nil {
+				return nil, err
+			}
+			v.kind, v.text = valueVariable, name
+			return v, nil
+		case "[":
+			if err := p.advance(); err != nil {
+				return nil, err
+			}
+			v.kind = valueList
+			for !p.peekPunct("]") {
+				elem, err := p.value(constant)
+				if err != nil {
+					return nil, err
+				}
+				v.list = append(v.list, elem)
+			}
+			return v, p.advance()
+		case "{":
+			if err := p.advance(); err != nil {
+				return nil, err
+			}
+			v.kind = valueObject
+			for !p.peekPunct("}") {
+				f := &argumentNode{loc: p.tok.loc}
+				var err *Error
+				if f.name, err = p.name(); err != nil {
+					return nil, err
+				}
+				if err := p.expectPunct(":"); err != nil {
+					return nil, err
+				}
+				if f.value, err = p.value(constant); err != nil {
+					return nil, err
+				}
+				v.fields = append(v.fields, f)
+			}
+			return v, p.advance()
+		}
+		return nil, p.unexpected()
+	case tokInt:
+		v.kind, v.text = valueInt, p.tok.text
+	case tokFloat:
+		v.kind, v.text = valueFloat, p.tok.text
+	case tokString:
+		v.kind, v.text = valueString, p.tok.text
+	case tokName:
+		switch p.tok.text {
+		case "true", "false":
+			v.kind, v.truth = valueBoolean, p.tok.text == "true"
+		case "null":
+			v.kind = valueNull
+		default:
+			v.kind, v.text = valueEnum, p.tok.text
+		}
+	default:
+		return nil, p.unexpected()
+	}
+	return v, p.advance()
+}
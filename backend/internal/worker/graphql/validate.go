@@ -0,0 +1,271 @@
+package graphql
+
+import (
+	"fmt"
+	"slices"
+)
+
+// validator checks an operation against the schema before anything runs,
+// so a malformed query costs no resolver calls and gets no partial data.
+type validator struct {
+	schema *Schema
+	doc    *document
+	op     *operation
+
+	vars      map[string]*variableDef
+	varTypes  map[string]Type
+	spreading map[string]bool // fragments on the current spread path
+	// checked records each fragment validated so far and what spreading it
+	// costs, so a fragment spread many times is walked once.
+	checked map[string]fragmentCost
+	fields  int
+	deepest int // deepest field depth reached
+	tooBig  bool
+	errs    []*Error
+}
+
+// fragmentCost is what one spread of a named fragment adds to a query: the
+// fields it selects, fragments expanded, and how many levels below the
+// spread its fields reach (0 for fields at the spread's own level, -1 for
+// none).
+type fragmentCost struct {
+	fields int
+	depth  int
+}
+
+// maxValidationErrors caps the errors one query reports; a query with more
+// is rejected without looking further.
+const maxValidationErrors = 50
+
+func (v *validator) errorf(loc Location, format string, args ...any) {
+	if len(v.errs) >= maxValidationErrors {
+		v.tooBig = true
+		return
+	}
+	v.errs = append(v.errs, &Error{Message: fmt.Sprintf(format, args...), Locations: []Location{loc}})
+}
+
+// charge adds n selections to the query's field count, rejecting the query
+// once it passes the limit.
+func (v *validator) charge(loc Location, n int) bool {
+	v.fields += n
+	if v.fields > v.maxFields() {
+		v.errorf(loc, "the query selects more than %d fields", v.maxFields())
+		v.tooBig = true
+		return false
+	}
+	return true
+}
+
+func (v *validator) validate() []*Error {
+	v.vars = map[string]*variableDef{}
+	v.varTypes = map[string]Type{}
+	v.spreading = map[string]bool{}
+	v.checked = map[string]fragmentCost{}
+	for _, d := range v.op.variables {
+		if _, dup := v.vars[d.name]; dup {
+			v.errorf(d.loc, "variable $%s is defined more than once", d.name)
+			continue
+		}
+		v.vars[d.name] = d
+		t, err := typeFromRef(d.typ)
+		if err != nil {
+			v.errorf(d.loc, "variable $%s: %s", d.name, err)
+			continue
+		}
+		v.varTypes[d.name] = t
+		if d.defaultValue != nil {
+			if _, err := coerceLiteral(t, d.defaultValue, nil); err != nil {
+				v.errorf(d.defaultValue.loc, "variable $%s default: %s", d.name, err)
+			}
+		}
+	}
+	v.selectionSet(v.schema.Query, v.op.selections, 1)
+	return v.errs
+}
+
+func (v *validator) maxDepth() int {
+	if v.schema.MaxDepth > 0 {
+		return v.schema.MaxDepth
+	}
+	return DefaultMaxDepth
+}
+
+func (v *validator) maxFields() int {
+	if v.schema.MaxFields > 0 {
+		return v.schema.MaxFields
+	}
+	return DefaultMaxFields
+}
+
+func (v *validator) selectionSet(obj *Object, sels []*selection, depth int) {
+	for _, s := range sels {
+		if v.tooBig {
+			return
+		}
+		v.directives(s.directives)
+		switch {
+		case s.field != nil:
+			v.field(obj, s, depth)
+		case s.inline != nil:
+			if s.inline.typeCond != "" && s.inline.typeCond != obj.Name {
+				v.errorf(s.loc, "a fragment on %s cannot be spread in %s", s.inline.typeCond, obj.Name)
+				continue
+			}
+			v.selectionSet(obj, s.inline.selections, depth)
+		default:
+			v.spread(obj, s, depth)
+		}
+	}
+}
+
+// spread checks a named fragment spread. The fragment's own selections are
+// validated the first time only; later spreads charge its recorded cost,
+// so a query whose fragments spread each other twice over stays linear to
+// check rather than doubling with every fragment.
+func (v *validator) spread(obj *Object, s *selection, depth int) {
+	f, ok := v.doc.fragments[s.spread]
+	if !ok {
+		v.errorf(s.loc, "unknown fragment %q", s.spread)
+		return
+	}
+	if f.typeCond != obj.Name {
+		v.errorf(s.loc, "fragment %q on %s cannot be spread in %s", f.name, f.typeCond, obj.Name)
+		return
+	}
+	if v.spreading[f.name] {
+		v.errorf(s.loc, "fragment %q spreads itself", f.name)
+		return
+	}
+	if !v.charge(s.loc, 1) {
+		return
+	}
+	if cost, ok := v.checked[f.name]; ok {
+		if !v.charge(s.loc, cost.fields) {
+			return
+		}
+		if depth+cost.depth > v.maxDepth() {
+			v.errorf(s.loc, "the query nests fields deeper than %d", v.maxDepth())
+			v.tooBig = true
+		}
+		return
+	}
+	fields, deepest := v.fields, v.deepest
+	v.deepest = depth - 1
+	v.spreading[f.name] = true
+	v.directives(f.directives)
+	v.selectionSet(obj, f.selections, depth)
+	delete(v.spreading, f.name)
+	v.checked[f.name] = fragmentCost{fields: v.fields - fields, depth: v.deepest - depth}
+	v.deepest = max(v.deepest, deepest)
+}
+
+func (v *validator) field(obj *Object, s *selection, depth int) {
+	f := s.field
+	if !v.charge(s.loc, 1) {
+		return
+	}
+	v.deepest = max(v.deepest, depth)
+	if depth > v.maxDepth() {
+		v.errorf(s.loc, "the query nests fields deeper than %d", v.maxDepth())
+		v.tooBig = true
+		return
+	}
+	if f.name == "__typename" {
+		if len(f.arguments) > 0 || f.selections != nil {
+			v.errorf(s.loc, "__typename takes no arguments or subfields")
+		}
+		return
+	}
+	def, ok := obj.Fields[f.name]
+	if !ok {
+		v.errorf(s.loc, "%s has no field %q", obj.Name, f.name)
+		return
+	}
+	v.arguments(fmt.Sprintf("field %s.%s", obj.Name, f.name), def.Args, f.arguments, s.loc)
+	switch t := namedType(def.Type).(type) {
+	case *Scalar:
+		if f.selections != nil {
+			v.errorf(s.loc, "field %q is a %s and cannot have subfields", f.responseKey(), def.Type)
+		}
+	case *Object:
+		if f.selections == nil {
+			v.errorf(s.loc, "field %q is a %s and needs subfields", f.responseKey(), def.Type)
+			return
+		}
+		v.selectionSet(t, f.selections, depth+1)
+	}
+}
+
+func (v *validator) arguments(owner string, defs map[string]*Argument, args []*argumentNode, loc Location) {
+	seen := map[string]bool{}
+	for _, a := range args {
+		if seen[a.name] {
+			v.errorf(a.loc, "%s: argument %q is given more than once", owner, a.name)
+			continue
+		}
+		seen[a.name] = true
+		def, ok := defs[a.name]
+		if !ok {
+			v.errorf(a.loc, "%s has no argument %q", owner, a.name)
+			continue
+		}
+		if err := v.value(def.Type, a.value); err != nil {
+			v.errorf(a.loc, "%s: argument %q: %s", owner, a.name, err)
+		}
+	}
+	names := make([]string, 0, len(defs))
+	for name := range defs {
+		names = append(names, name)
+	}
+	slices.Sort(names)
+	for _, name := range names {
+		if def := defs[name]; isNonNull(def.Type) && def.Default == nil && !seen[name] {
+			v.errorf(loc, "%s: argument %q is required", owner, name)
+		}
+	}
+}
+
+// value checks a literal against t and a variable's declared type
+// against where it is used.
+func (v *validator) value(t Type, val *value) error {
+	switch {
+	case val.kind == valueVariable:
+		d, ok := v.vars[val.text]
+		if !ok {
+			return fmt.Errorf("variable $%s is not defined", val.text)
+		}
+		if vt, ok := v.varTypes[val.text]; ok && !assignable(vt, t, d.defaultValue != nil) {
+			return fmt.Errorf("variable $%s of type %s cannot be used as %s", val.text, vt, t)
+		}
+		return nil
+	case val.kind == valueList:
+		elem := t
+		if nn, ok := elem.(*NonNull); ok {
+			elem = nn.OfType
+		}
+		if l, ok := elem.(*List); ok {
+			for _, e := range val.list {
+				if err := v.value(l.OfType, e); err != nil {
+					return err
+				}
+			}
+			return nil
+		}
+	}
+	_, err := coerceLiteral(t, val, nil)
+	return err
+}
+
+// directives checks @skip and @include, the only directives there are.
+func (v *validator) directives(dirs []*directive) {
+	for _, d := range dirs {
+		if d.name != "skip" && d.name != "include" {
+			v.errorf(d.loc, "unknown directive @%s", d.name)
+			continue
+		}
+		v.arguments("directive @"+d.name, conditionArgs, d.arguments, d.loc)
+	}
+}
+
+var conditionArgs = map[string]*Argument{"if": {Type: NonNullOf(Boolean)}}
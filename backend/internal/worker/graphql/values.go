@@ -0,0 +1,324 @@
+package graphql
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"reflect"
+	"strconv"
+)
+
+// typeFromRef resolves a variable's declared type. Variables can only be
+// scalars and lists of them, since the schema has no input objects.
+func typeFromRef(ref *typeRef) (Type, error) {
+	var t Type
+	if ref.elem != nil {
+		elem, err := typeFromRef(ref.elem)
+		if err != nil {
+			return nil, err
+		}
+		t = ListOf(elem)
+	} else {
+		s, ok := scalars[ref.name]
+		if !ok {
+			return nil, fmt.Errorf("unknown input type %q", ref.name)
+		}
+		t = s
+	}
+	if ref.nonNull {
+		t = NonNullOf(t)
+	}
+	return t, nil
+}
+
+// namedType strips t's lists and non-nulls.
+func namedType(t Type) Type {
+	for {
+		switch w := t.(type) {
+		case *List:
+			t = w.OfType
+		case *NonNull:
+			t = w.OfType
+		default:
+			return t
+		}
+	}
+}
+
+// assignable reports whether a variable of type from may be used where
+// to is expected. A nullable variable with a default may fill a non-null
+// position.
+func assignable(from, to Type, hasDefault bool) bool {
+	if nn, ok := to.(*NonNull); ok {
+		if fnn, ok := from.(*NonNull); ok {
+			return assignable(fnn.OfType, nn.OfType, false)
+		}
+		return hasDefault && assignable(from, nn.OfType, false)
+	}
+	if fnn, ok := from.(*NonNull); ok {
+		return assignable(fnn.OfType, to, false)
+	}
+	if fl, ok := from.(*List); ok {
+		tl, ok := to.(*List)
+		return ok && assignable(fl.OfType, tl.OfType, false)
+	}
+	return from == to
+}
+
+// coerceVariables coerces the request's variables to the operation's
+// declared types, filling in defaults. A variable with neither a value
+// nor a default is left out.
+func coerceVariables(op *operation, raw map[string]any) (map[string]any, []*Error) {
+	vars := map[string]any{}
+	var errs []*Error
+	for _, d := range op.variables {
+		t, err := typeFromRef(d.typ)
+		if err != nil {
+			continue // reported by validation
+		}
+		v, ok := raw[d.name]
+		if !ok {
+			switch {
+			case d.defaultValue != nil:
+				vars[d.name], err = coerceLiteral(t, d.defaultValue, nil)
+			case isNonNull(t):
+				err = fmt.Errorf("of required type %s was not provided", t)
+			default:
+				continue
+			}
+		} else {
+			vars[d.name], err = coerceInput(t, v)
+		}
+		if err != nil {
+			errs = append(errs, &Error{Message: fmt.Sprintf("variable $%s %s", d.name, err), Locations: []Location{d.loc}})
+		}
+	}
+	return vars, errs
+}
+
+func isNonNull(t Type) bool {
+	_, ok := t.(*NonNull)
+	return ok
+}
+
+// coerceInput coerces a variable's JSON value, or an argument's Go
+// default, to t.
+func coerceInput(t Type, v any) (any, error) {
+	if nn, ok := t.(*NonNull); ok {
+		if v == nil {
+			return nil, fmt.Errorf("must not be null")
+		}
+		return coerceInput(nn.OfType, v)
+	}
+	if v == nil {
+		return nil, nil
+	}
+	if l, ok := t.(*List); ok {
+		rv := reflect.ValueOf(v)
+		if rv.Kind() != reflect.Slice && rv.Kind() != reflect.Array {
+			elem, err := coerceInput(l.OfType, v)
+			if err != nil {
+				return nil, err
+			}
+			return []any{elem}, nil
+		}
+		out := make([]any, rv.Len())
+		for i := range out {
+			elem, err := coerceInput(l.OfType, rv.Index(i).Interface())
+			if err != nil {
+				return nil, fmt.Errorf("at index %d: %w", i, err)
+			}
+			out[i] = elem
+		}
+		return out, nil
+	}
+	s, ok := t.(*Scalar)
+	if !ok {
+		return nil, fmt.Errorf("%s is not an input type", t)
+	}
+	var out any
+	switch s {
+	case Int:
+		if n, ok := toInt64(v); ok {
+			out = n
+		}
+	case Float:
+		if f, ok := toFloat64(v); ok {
+			out = f
+		}
+	case String:
+		if str, ok := v.(string); ok {
+			out = str
+		}
+	case ID:
+		if str, ok := v.(string); ok {
+			out = str
+		} else if n, ok := toInt64(v); ok {
+			out = strconv.FormatInt(n, 10)
+		}
+	case Boolean:
+		if b, ok := v.(bool); ok {
+			out = b
+		}
+	}
+	if out == nil {
+		return nil, fmt.Errorf("expected %s, got %v", s, v)
+	}
+	return out, nil
+}
+
+// coerceLiteral coerces a value written in the query to t. A variable
+// takes its coerced value from vars; one with no value is null here.
+func coerceLiteral(t Type, v *value, vars map[string]any) (any, error) {
+	if v.kind == valueVariable {
+		x := vars[v.text]
+		if x == nil && isNonNull(t) {
+			return nil, fmt.Errorf("variable $%s must not be null", v.text)
+		}
+		return x, nil
+	}
+	if nn, ok := t.(*NonNull); ok {
+		if v.kind == valueNull {
+			return nil, fmt.Errorf("expected %s, got null", t)
+		}
+		return coerceLiteral(nn.OfType, v, vars)
+	}
+	if v.kind == valueNull {
+		return nil, nil
+	}
+	if l, ok := t.(*List); ok {
+		if v.kind != valueList {
+			elem, err := coerceLiteral(l.OfType, v, vars)
+			if err != nil {
+				return nil, err
+			}
+			return []any{elem}, nil
+		}
+		out := make([]any, len(v.list))
+		for i, e := range v.list {
+			elem, err := coerceLiteral(l.OfType, e, vars)
+			if err != nil {
+				return nil, err
+			}
+			out[i] = elem
+		}
+		return out, nil
+	}
+	s, ok := t.(*Scalar)
+	if !ok {
+		return nil, fmt.Errorf("%s is not an input type", t)
+	}
+	switch {
+	case s == Int && v.kind == valueInt:
+		n, err := strconv.ParseInt(v.text, 10, 64)
+		if err != nil {
+			return nil, fmt.Errorf("%s does not fit in an Int", v.text)
+		}
+		return n, nil
+	case s == Float && (v.kind == valueInt || v.kind == valueFloat):
+		return strconv.ParseFloat(v.text, 64)
+	case s == String && v.kind == valueString:
+		return v.text, nil
+	case s == ID && (v.kind == valueString || v.kind == valueInt):
+		return v.text, nil
+	case s == Boolean && v.kind == valueBoolean:
+		return v.truth, nil
+	}
+	return nil, fmt.Errorf("expected %s, got %s", s, describeValue(v))
+}
+
+func describeValue(v *value) string {
+	switch v.kind {
+	case valueString:
+		return strconv.Quote(v.text)
+	case valueBoolean:
+		return strconv.FormatBool(v.truth)
+	case valueList:
+		return "a list"
+	case valueObject:
+		return "an object"
+	case valueNull:
+		return "null"
+	default:
+		return v.text
+	}
+}
+
+// serialize converts a resolved scalar to its JSON form.
+func serialize(s *Scalar, v any) (any, error) {
+	switch s {
+	case Int:
+		if n, ok := toInt64(v); ok {
+			return n, nil
+		}
+	case Float:
+		if f, ok := toFloat64(v); ok && !math.IsNaN(f) && !math.IsInf(f, 0) {
+			return f, nil
+		}
+	case String:
+		if rv := reflect.ValueOf(v); rv.Kind() == reflect.String {
+			return rv.String(), nil
+		}
+	case ID:
+		if rv := reflect.ValueOf(v); rv.Kind() == reflect.String {
+			return rv.String(), nil
+		}
+		if n, ok := toInt64(v); ok {
+			return strconv.FormatInt(n, 10), nil
+		}
+	case Boolean:
+		if rv := reflect.ValueOf(v); rv.Kind() == reflect.Bool {
+			return rv.Bool(), nil
+		}
+	}
+	return nil, fmt.Errorf("cannot represent %T as %s", v, s)
+}
+
+// toInt64 accepts any Go integer, a float with no fraction and a
+// json.Number that holds an integer.
+func toInt64(v any) (int64, bool) {
+	if n, ok := v.(json.Number); ok {
+		if i, err := n.Int64(); err == nil {
+			return i, true
+		}
+		f, err := n.Float64()
+		if err != nil {
+			return 0, false
+		}
+		v = f
+	}
+	rv := reflect.ValueOf(v)
+	switch rv.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return rv.Int(), true
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		if rv.Uint() > math.MaxInt64 {
+			return 0, false
+		}
+		return int64(rv.Uint()), true
+	case reflect.Float32, reflect.Float64:
+		f := rv.Float()
+		if f != math.Trunc(f) || f < math.MinInt64 || f >= math.MaxInt64 {
+			return 0, false
+		}
+		return int64(f), true
+	}
+	return 0, false
+}
+
+func toFloat64(v any) (float64, bool) {
+	if n, ok := v.(json.Number); ok {
+		f, err := n.Float64()
+		return f, err == nil
+	}
+	rv := reflect.ValueOf(v)
+	switch rv.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return float64(rv.Int()), true
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return float64(rv.Uint()), true
+	case reflect.Float32, reflect.Float64:
+		return rv.Float(), true
+	}
+	return 0, false
+}
@@ -12,13 +12,46 @@ const (
 	resetThreshold = 30 * time.Second
 )
 
-// newDefaultBackoff creates an exponential backoff: 1s → 180s, multiplier 2x, ±20% jitter.
-func newDefaultBackoff() *backoff.ExponentialBackOff {
+// ReconnectPolicy tunes how the worker reconnects to the Hub and how
+// quickly it gives up on a connection that has gone quiet.
+type ReconnectPolicy struct {
+	// MinInterval is the first backoff delay; each failed round doubles
+	// it up to MaxInterval.
+	MinInterval time.Duration
+	MaxInterval time.Duration
+	// Jitter randomizes each delay by up to this fraction (0.2 = ±20%),
+	// so workers cut off together do not reconnect together.
+	Jitter float64
+	// HealthTimeout drops a connection on which nothing has arrived from
+	// the Hub for this long. The worker pings whenever the Hub is quiet
+	// and the Hub answers every ping, so only a dead link trips it.
+	// Zero disables the check.
+	HealthTimeout time.Duration
+}
+
+// DefaultReconnectPolicy returns the policy New installs: 1s → 180s, ±20%
+// jitter, and a 15s health timeout.
+func DefaultReconnectPolicy() ReconnectPolicy {
+	return ReconnectPolicy{
+		MinInterval:   1 * time.Second,
+		MaxInterval:   180 * time.Second,
+		Jitter:        0.2,
+		HealthTimeout: 15 * time.Second,
+	}
+}
+
+// newBackoff creates the exponential backoff the policy describes.
+func (p ReconnectPolicy) newBackoff() *backoff.ExponentialBackOff {
 	b := backoff.NewExponentialBackOff()
-	b.InitialInterval = 1 * time.Second
-	b.MaxInterval = 180 * time.Second
+	b.InitialInterval = p.MinInterval
+	b.MaxInterval = p.MaxInterval
 	b.Multiplier = 2.0
-	b.RandomizationFactor = 0.2
+	b.RandomizationFactor = p.Jitter
 	b.Reset()
 	return b
 }
+
+// newDefaultBackoff creates an exponential backoff: 1s → 180s, multiplier 2x, ±20% jitter.
+func newDefaultBackoff() *backoff.ExponentialBackOff {
+	return DefaultReconnectPolicy().newBackoff()
+}
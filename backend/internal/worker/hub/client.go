@@ -17,10 +17,12 @@ import (
 
 	"github.com/cenkalti/backoff/v6"
 	"golang.org/x/net/http2"
+	"google.golang.org/protobuf/proto"
 
 	"connectrpc.com/connect"
 	leapmuxv1 "github.com/leapmux/leapmux/generated/proto/leapmux/v1"
 	"github.com/leapmux/leapmux/generated/proto/leapmux/v1/leapmuxv1connect"
+	"github.com/leapmux/leapmux/internal/protocol"
 	"github.com/leapmux/leapmux/internal/worker/agent"
 	"github.com/leapmux/leapmux/internal/worker/channel"
 	"github.com/leapmux/leapmux/internal/worker/terminal"
@@ -29,9 +31,10 @@ import (
 
 // Client manages the connection to the Hub.
 type Client struct {
-	connector  leapmuxv1connect.WorkerConnectorServiceClient
-	reconciler leapmuxv1connect.WorkerReconcilerServiceClient
-	hubURL     string
+	// endpoints are the Hub URLs the worker may connect to: the primary
+	// first, then the fallbacks in the order they are tried.
+	endpoints  []hubEndpoint
+	active     atomic.Int32 // index into endpoints of the current target
 	authToken  string
 	agents     *agent.Manager
 	terminals  *terminal.Manager
@@ -59,6 +62,47 @@ type Client struct {
 	// that could have gone missing.
 	OnWorkerIdentity func(registeredBy string)
 
+	// OnOrgID is called with the worker's org right after OnWorkerIdentity,
+	// on every Connect. It is empty from a Hub that predates it.
+	OnOrgID func(orgID string)
+
+	// OnStreamSettings is called with the org's stream settings: once per
+	// connection from the WorkerIdentity greeting (an empty message from a Hub
+	// that predates them), and again whenever the org changes them. The upload
+	// cap is applied by the client itself before the callback runs.
+	OnStreamSettings func(*leapmuxv1.WorkerStreamSettings)
+
+	// OnAgentTerminalPolicy is called with the org's agent terminal policy,
+	// on the same schedule as OnStreamSettings: from every greeting (an empty
+	// policy from a Hub that predates it) and on every change.
+	OnAgentTerminalPolicy func(*leapmuxv1.AgentTerminalPolicy)
+
+	// OnAccessPolicy is called with the org's access policy, on the same
+	// schedule as OnStreamSettings.
+	OnAccessPolicy func(*leapmuxv1.AccessPolicy)
+
+	// OnDiskQuota is called with the org's worker disk quota, on the same
+	// schedule as OnStreamSettings.
+	OnDiskQuota func(*leapmuxv1.WorkerDiskQuota)
+
+	// OnOrgDefaults is called with the org's defaults, on the same schedule
+	// as OnStreamSettings.
+	OnOrgDefaults func(*leapmuxv1.OrgDefaults)
+
+	// OnEmergencyStop is called with the emergency stop the worker runs
+	// under, on the same schedule as OnStreamSettings; an inactive state
+	// means none.
+	OnEmergencyStop func(*leapmuxv1.EmergencyStopState)
+
+	// OnAnnouncements is called with the announcements that have not
+	// ended, on the same schedule as OnStreamSettings.
+	OnAnnouncements func(*leapmuxv1.AnnouncementList)
+
+	// OnPrepareRepoCheckout is called when the Hub asks for a checkout of a
+	// registered repository. Its answer is sent back under the request's
+	// id; a nil callback answers with an error.
+	OnPrepareRepoCheckout func(*leapmuxv1.PrepareRepoCheckout) *leapmuxv1.PrepareRepoCheckoutResponse
+
 	// PublicKey is the Worker's X25519 public key for E2EE channels.
 	// Sent to the Hub with the initial heartbeat.
 	PublicKey []byte
@@ -68,15 +112,30 @@ type Client struct {
 	SlhdsaPublicKey []byte
 	// EncryptionMode is the Worker's encryption mode.
 	EncryptionMode leapmuxv1.EncryptionMode
+	// Labels are the Worker's configured labels, sent with the initial
+	// heartbeat for the Hub to match against repositories' preferred
+	// worker labels.
+	Labels []string
 
 	// TabSyncProvider returns the current tab state for WorkspaceTabsSync
 	// on connect. Set by the runner after initializing the worker service.
 	TabSyncProvider func() *leapmuxv1.WorkspaceTabsSync
 
+	// Reconnect tunes backoff and the connection health check. New sets
+	// DefaultReconnectPolicy.
+	Reconnect ReconnectPolicy
+
+	stats connStats
+
+	// upload paces channel traffic to the org's upload cap. See Send.
+	upload uploadLimiter
+
 	mu           sync.Mutex
 	stream       *connect.BidiStreamForClient[leapmuxv1.ConnectRequest, leapmuxv1.ConnectResponse]
 	connCancel   context.CancelFunc // cancel function for current connection context
+	connDone     <-chan struct{}    // closed when the current connection ends
 	lastSendTime time.Time          // last time a message was sent (for idle heartbeat)
+	lastRecvTime atomic.Int64       // unix nanos of the last message from the Hub (for the health check)
 	stopOnce     sync.Once
 	// identityReceived is set when the Hub delivers WorkerIdentity on the
 	// current connection. A watchdog (see watchForIdentity) force-closes the
@@ -91,15 +150,17 @@ type Client struct {
 	hubRetryDelay atomic.Int64
 }
 
-// New creates a new Hub client with integrated lifecycle management.
-// It creates agent and terminal managers internally.
-// hubURL may be:
-//   - http[s]://host:port — a remote Hub reached over TCP
-//   - unix:<socket-path>  — a local Hub reached over a Unix domain socket
-//   - npipe:<pipe-name>   — a local Hub reached over a Windows named pipe
-func New(hubURL string) *Client {
+// hubEndpoint is one URL the Hub can be reached at.
+type hubEndpoint struct {
+	url        string
+	connector  leapmuxv1connect.WorkerConnectorServiceClient
+	reconciler leapmuxv1connect.WorkerReconcilerServiceClient
+}
+
+func newHubEndpoint(hubURL string) hubEndpoint {
 	httpClient, connectURL := clientForHubURL(hubURL)
-	c := &Client{
+	return hubEndpoint{
+		url: hubURL,
 		connector: leapmuxv1connect.NewWorkerConnectorServiceClient(
 			httpClient,
 			connectURL,
@@ -109,9 +170,27 @@ func New(hubURL string) *Client {
 			httpClient,
 			connectURL,
 		),
-		hubURL:    hubURL,
+	}
+}
+
+// New creates a new Hub client with integrated lifecycle management.
+// It creates agent and terminal managers internally.
+// hubURL may be:
+//   - http[s]://host:port — a remote Hub reached over TCP
+//   - unix:<socket-path>  — a local Hub reached over a Unix domain socket
+//   - npipe:<pipe-name>   — a local Hub reached over a Windows named pipe
+//
+// fallbackURLs, in the same forms, are other addresses of the same Hub.
+// When a connection attempt fails the worker moves straight on to the
+// next one, and only backs off once every URL has failed.
+func New(hubURL string, fallbackURLs ...string) *Client {
+	c := &Client{
+		Reconnect: DefaultReconnectPolicy(),
 		terminals: terminal.NewManager(),
 	}
+	for _, u := range append([]string{hubURL}, fallbackURLs...) {
+		c.endpoints = append(c.endpoints, newHubEndpoint(u))
+	}
 	c.agents = agent.NewManager(func(agentID string, exitCode int, err error) {
 		if err != nil {
 			slog.Info("agent exited with error", "agent_id", agentID, "exit_code", exitCode, "error", err)
@@ -125,12 +204,16 @@ func New(hubURL string) *Client {
 // clientForHubURL picks the HTTP client and ConnectRPC URL for hubURL.
 // Local-IPC schemes (unix:/npipe:) get a dialer-backed h2c client and a
 // placeholder "http://localhost" route (the transport dials the real
-// endpoint); remote URLs pass through to a plain h2c client.
+// endpoint); https URLs get HTTP/2 over TLS, verified against the system
+// roots; other remote URLs pass through to a plain h2c client.
 func clientForHubURL(hubURL string) (*http.Client, string) {
 	return locallisten.SelectClient(
 		hubURL,
 		func() (*http.Client, string, error) { return locallisten.LocalH2CClient(hubURL, 0) },
 		func() (*http.Client, string) {
+			if strings.HasPrefix(hubURL, "https://") {
+				return &http.Client{Transport: &http2.Transport{}}, hubURL
+			}
 			transport := &http2.Transport{
 				AllowHTTP: true,
 				DialTLSContext: func(ctx context.Context, network, addr string, _ *tls.Config) (net.Conn, error) {
@@ -174,7 +257,17 @@ func (c *Client) TerminalManager() *terminal.Manager {
 // which would corrupt the HTTP/2 frame buffer ("short write" errors).
 // On send failure, the connection context is canceled to trigger
 // immediate reconnection rather than waiting for the Hub's idle timeout.
+//
+// Channel messages are first paced to the org's upload cap. The wait happens
+// before taking the mutex, so heartbeats and acks overtake a throttled
+// stream; it ends early, with an error, if the connection goes away.
 func (c *Client) Send(msg *leapmuxv1.ConnectRequest) error {
+	if _, ok := msg.GetPayload().(*leapmuxv1.ConnectRequest_ChannelMessageResp); ok {
+		if err := c.waitUpload(proto.Size(msg)); err != nil {
+			return err
+		}
+	}
+
 	c.mu.Lock()
 	defer c.mu.Unlock()
 
@@ -191,6 +284,130 @@ func (c *Client) Send(msg *leapmuxv1.ConnectRequest) error {
 	return err
 }
 
+// waitUpload blocks until n bytes fit under the upload cap.
+func (c *Client) waitUpload(n int) error {
+	wait := c.upload.reserve(n)
+	if wait <= 0 {
+		return nil
+	}
+	c.mu.Lock()
+	done := c.connDone
+	c.mu.Unlock()
+	if done == nil {
+		return fmt.Errorf("not connected")
+	}
+	timer := time.NewTimer(wait)
+	defer timer.Stop()
+	select {
+	case <-timer.C:
+		return nil
+	case <-done:
+		return fmt.Errorf("not connected")
+	}
+}
+
+// applyStreamSettings adopts the org's stream settings. nil (a Hub that
+// predates them) means unlimited and uncompressed.
+func (c *Client) applyStreamSettings(s *leapmuxv1.WorkerStreamSettings) {
+	if s == nil {
+		s = &leapmuxv1.WorkerStreamSettings{}
+	}
+	c.upload.setRate(s.GetUploadBytesPerSecond())
+	slog.Info("worker stream settings applied",
+		"upload_bytes_per_second", s.GetUploadBytesPerSecond(), "compress_output", s.GetCompressOutput())
+	if c.OnStreamSettings != nil {
+		c.OnStreamSettings(s)
+	}
+}
+
+// applyAgentTerminalPolicy hands the org's agent terminal policy to the
+// worker. nil (a Hub that predates it) means no patterns.
+func (c *Client) applyAgentTerminalPolicy(p *leapmuxv1.AgentTerminalPolicy) {
+	if p == nil {
+		p = &leapmuxv1.AgentTerminalPolicy{}
+	}
+	slog.Info("agent terminal policy applied", "patterns", len(p.GetCommandPatterns()))
+	if c.OnAgentTerminalPolicy != nil {
+		c.OnAgentTerminalPolicy(p)
+	}
+}
+
+// applyAccessPolicy hands the org's access policy to the worker. nil (a
+// Hub that predates it) means no rules.
+func (c *Client) applyAccessPolicy(p *leapmuxv1.AccessPolicy) {
+	if p == nil {
+		p = &leapmuxv1.AccessPolicy{}
+	}
+	slog.Info("access policy applied", "rules", len(p.GetRules()))
+	if c.OnAccessPolicy != nil {
+		c.OnAccessPolicy(p)
+	}
+}
+
+// applyOrgDefaults hands the org's defaults to the worker. nil (a Hub that
+// predates them) means no org layer.
+func (c *Client) applyOrgDefaults(d *leapmuxv1.OrgDefaults) {
+	if d == nil {
+		d = &leapmuxv1.OrgDefaults{}
+	}
+	slog.Info("org defaults applied",
+		"agents", len(d.GetAgents()), "auto_continue_rules", len(d.GetAutoContinue().GetRules()),
+		"closed_retention_days", d.GetClosedRetentionDays(), "immutable_transcripts", d.GetImmutableTranscripts())
+	if c.OnOrgDefaults != nil {
+		c.OnOrgDefaults(d)
+	}
+}
+
+// applyDiskQuota hands the org's disk quota to the worker. nil (a Hub
+// that predates it) means no quota and the default free-space floor.
+func (c *Client) applyDiskQuota(q *leapmuxv1.WorkerDiskQuota) {
+	if q == nil {
+		q = &leapmuxv1.WorkerDiskQuota{}
+	}
+	slog.Info("worker disk quota applied",
+		"workspace_bytes", q.GetWorkspaceBytes(), "worker_bytes", q.GetWorkerBytes(), "min_free_bytes", q.GetMinFreeBytes())
+	if c.OnDiskQuota != nil {
+		c.OnDiskQuota(q)
+	}
+}
+
+// applyEmergencyStop hands the worker the emergency stop it runs under.
+// nil (no stop, or a Hub that predates them) means none.
+func (c *Client) applyEmergencyStop(st *leapmuxv1.EmergencyStopState) {
+	if st == nil {
+		st = &leapmuxv1.EmergencyStopState{}
+	}
+	slog.Info("emergency stop applied", "active", st.GetActive(), "reason", st.GetReason())
+	if c.OnEmergencyStop != nil {
+		c.OnEmergencyStop(st)
+	}
+}
+
+// applyAnnouncements hands the worker the announcements that have not
+// ended. nil (a Hub that predates them) means none.
+func (c *Client) applyAnnouncements(list *leapmuxv1.AnnouncementList) {
+	if list == nil {
+		list = &leapmuxv1.AnnouncementList{}
+	}
+	slog.Info("announcements applied", "count", len(list.GetAnnouncements()))
+	if c.OnAnnouncements != nil {
+		c.OnAnnouncements(list)
+	}
+}
+
+func (c *Client) handlePrepareRepoCheckout(requestID string, req *leapmuxv1.PrepareRepoCheckout) {
+	resp := &leapmuxv1.PrepareRepoCheckoutResponse{Error: "this worker does not check out repositories"}
+	if c.OnPrepareRepoCheckout != nil {
+		resp = c.OnPrepareRepoCheckout(req)
+	}
+	if err := c.Send(&leapmuxv1.ConnectRequest{
+		RequestId: requestID,
+		Payload:   &leapmuxv1.ConnectRequest_PrepareRepoCheckoutResp{PrepareRepoCheckoutResp: resp},
+	}); err != nil {
+		slog.Warn("failed to answer repo checkout request", "workspace_id", req.GetWorkspaceId(), "error", err)
+	}
+}
+
 // ListOwnedTabsForWorker calls the hub's WorkerReconcilerService.
 // Authenticated by the worker's auth token (last seen on Connect).
 // Returns nil + error if Connect hasn't been called yet.
@@ -203,13 +420,54 @@ func (c *Client) ListOwnedTabsForWorker(ctx context.Context) ([]*leapmuxv1.Owned
 	}
 	req := connect.NewRequest(&leapmuxv1.ListOwnedTabsForWorkerRequest{})
 	req.Header().Set("Authorization", "Bearer "+token)
-	resp, err := c.reconciler.ListOwnedTabsForWorker(ctx, req)
+	resp, err := c.endpoint().reconciler.ListOwnedTabsForWorker(ctx, req)
 	if err != nil {
 		return nil, err
 	}
 	return resp.Msg.GetTabs(), nil
 }
 
+// GetSnippetForWorker calls the hub's WorkerReconcilerService for the
+// snippet named name of the user behind channelID. A snippet the user does
+// not have is (nil, nil).
+func (c *Client) GetSnippetForWorker(ctx context.Context, channelID, name string) (*leapmuxv1.Snippet, error) {
+	c.mu.Lock()
+	token := c.authToken
+	c.mu.Unlock()
+	if token == "" {
+		return nil, errors.New("hub client: no auth token (call Connect first)")
+	}
+	req := connect.NewRequest(&leapmuxv1.GetSnippetForWorkerRequest{ChannelId: channelID, Name: name})
+	req.Header().Set("Authorization", "Bearer "+token)
+	resp, err := c.endpoint().reconciler.GetSnippetForWorker(ctx, req)
+	if connect.CodeOf(err) == connect.CodeNotFound {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return resp.Msg.GetSnippet(), nil
+}
+
+// GetModelCredentialsForWorker calls the hub's WorkerReconcilerService for
+// the model credentials serving workspaceID, a workspace of the user
+// behind channelID.
+func (c *Client) GetModelCredentialsForWorker(ctx context.Context, channelID, workspaceID string) ([]*leapmuxv1.ModelCredentialSecret, error) {
+	c.mu.Lock()
+	token := c.authToken
+	c.mu.Unlock()
+	if token == "" {
+		return nil, errors.New("hub client: no auth token (call Connect first)")
+	}
+	req := connect.NewRequest(&leapmuxv1.GetModelCredentialsForWorkerRequest{ChannelId: channelID, WorkspaceId: workspaceID})
+	req.Header().Set("Authorization", "Bearer "+token)
+	resp, err := c.endpoint().reconciler.GetModelCredentialsForWorker(ctx, req)
+	if err != nil {
+		return nil, err
+	}
+	return resp.Msg.GetCredentials(), nil
+}
+
 // Connect establishes the bidirectional streaming connection to the Hub.
 func (c *Client) Connect(ctx context.Context, authToken string) error {
 	c.mu.Lock()
@@ -221,17 +479,21 @@ func (c *Client) Connect(ctx context.Context, authToken string) error {
 	connCtx, connCancel := context.WithCancel(ctx)
 	defer connCancel()
 
-	stream := c.connector.Connect(connCtx)
+	ep := c.endpoint()
+	stream := ep.connector.Connect(connCtx)
 	stream.RequestHeader().Set("Authorization", "Bearer "+authToken)
+	stream.RequestHeader().Set(protocol.Header, protocol.Format(protocol.Current))
 
 	c.mu.Lock()
 	c.stream = stream
 	c.connCancel = connCancel
+	c.connDone = connCtx.Done()
 	c.mu.Unlock()
 	defer func() {
 		c.mu.Lock()
 		c.stream = nil
 		c.connCancel = nil
+		c.connDone = nil
 		c.mu.Unlock()
 
 		// Close all channel sessions so watchers are unregistered and
@@ -251,19 +513,22 @@ func (c *Client) Connect(ctx context.Context, authToken string) error {
 				MlkemPublicKey:  c.MlkemPublicKey,
 				SlhdsaPublicKey: c.SlhdsaPublicKey,
 				EncryptionMode:  c.EncryptionMode,
+				ConnectionStats: c.stats.snapshot(ep.url),
+				Labels:          c.Labels,
 			},
 		},
 	}); err != nil {
 		return fmt.Errorf("initial heartbeat: %w", err)
 	}
 
-	slog.Info("connected to hub", "url", c.hubURL)
+	slog.Info("connected to hub", "url", ep.url)
 
 	// Reset identity tracking for this connection and arm the watchdog that
 	// force-closes the stream if the Hub never delivers WorkerIdentity. The
 	// Hub sends it before publishing the connection (worker_connector_service.go),
 	// so its absence within the budget signals a stripped/dropped greeting.
 	c.identityReceived.Store(false)
+	c.lastRecvTime.Store(time.Now().UnixNano())
 	go c.watchForIdentity(connCtx)
 
 	// Send workspace tab sync if a provider is configured.
@@ -288,6 +553,7 @@ func (c *Client) Connect(ctx context.Context, authToken string) error {
 		if err != nil {
 			return fmt.Errorf("receive: %w", err)
 		}
+		c.lastRecvTime.Store(time.Now().UnixNano())
 
 		c.handleMessage(msg)
 	}
@@ -322,10 +588,55 @@ func (c *Client) handleMessage(msg *leapmuxv1.ConnectResponse) {
 		}
 
 	case *leapmuxv1.ConnectResponse_WorkerIdentity:
+		// A hub too old for this worker would leave the worker waiting on
+		// payloads it never sends. Dropping the connection keeps the
+		// refusal loud: every reconnect logs it until the hub is upgraded.
+		hubProtocol := protocol.Reported(payload.WorkerIdentity.GetProtocolVersion())
+		if err := protocol.CheckHub(hubProtocol); err != nil {
+			slog.Error("refusing hub with an old protocol version", "protocol_version", hubProtocol, "error", err)
+			c.cancelConn()
+			return
+		}
 		c.identityReceived.Store(true)
+		c.stats.markEstablished()
 		if c.OnWorkerIdentity != nil {
 			c.OnWorkerIdentity(payload.WorkerIdentity.GetRegisteredBy())
 		}
+		if c.OnOrgID != nil {
+			c.OnOrgID(payload.WorkerIdentity.GetOrgId())
+		}
+		c.applyStreamSettings(payload.WorkerIdentity.GetStreamSettings())
+		c.applyAgentTerminalPolicy(payload.WorkerIdentity.GetAgentTerminalPolicy())
+		c.applyAccessPolicy(payload.WorkerIdentity.GetAccessPolicy())
+		c.applyDiskQuota(payload.WorkerIdentity.GetDiskQuota())
+		c.applyOrgDefaults(payload.WorkerIdentity.GetOrgDefaults())
+		c.applyEmergencyStop(payload.WorkerIdentity.GetEmergencyStop())
+		c.applyAnnouncements(payload.WorkerIdentity.GetAnnouncements())
+
+	case *leapmuxv1.ConnectResponse_StreamSettings:
+		c.applyStreamSettings(payload.StreamSettings)
+
+	case *leapmuxv1.ConnectResponse_AgentTerminalPolicy:
+		c.applyAgentTerminalPolicy(payload.AgentTerminalPolicy)
+
+	case *leapmuxv1.ConnectResponse_AccessPolicy:
+		c.applyAccessPolicy(payload.AccessPolicy)
+
+	case *leapmuxv1.ConnectResponse_DiskQuota:
+		c.applyDiskQuota(payload.DiskQuota)
+
+	case *leapmuxv1.ConnectResponse_OrgDefaults:
+		c.applyOrgDefaults(payload.OrgDefaults)
+
+	case *leapmuxv1.ConnectResponse_EmergencyStop:
+		c.applyEmergencyStop(payload.EmergencyStop)
+
+	case *leapmuxv1.ConnectResponse_Announcements:
+		c.applyAnnouncements(payload.Announcements)
+
+	case *leapmuxv1.ConnectResponse_PrepareRepoCheckout:
+		// Off the receive loop: preparing touches the disk.
+		go c.handlePrepareRepoCheckout(msg.GetRequestId(), payload.PrepareRepoCheckout)
 
 	default:
 		slog.Warn("unhandled hub message", "request_id", msg.GetRequestId(), "payload_type", fmt.Sprintf("%T", msg.GetPayload()))
@@ -375,6 +686,10 @@ func resolveWorkingDir(path string) (string, error) {
 
 const heartbeatIdleTimeout = 5 * time.Second
 
+// heartbeatTick is how often heartbeatLoop checks both idle clocks. A var
+// so tests can shorten it.
+var heartbeatTick = 2 * time.Second
+
 // workerIdentityTimeout bounds how long the worker waits for the Hub's
 // connect-time WorkerIdentity greeting before force-closing the stream. The
 // Hub sends it before publishing the connection, so its absence within this
@@ -416,7 +731,7 @@ func (c *Client) cancelConn() {
 }
 
 func (c *Client) heartbeatLoop(ctx context.Context) {
-	ticker := time.NewTicker(2 * time.Second)
+	ticker := time.NewTicker(heartbeatTick)
 	defer ticker.Stop()
 
 	for {
@@ -427,8 +742,19 @@ func (c *Client) heartbeatLoop(ctx context.Context) {
 			c.mu.Lock()
 			idle := time.Since(c.lastSendTime)
 			c.mu.Unlock()
+			quiet := time.Since(time.Unix(0, c.lastRecvTime.Load()))
 
-			if idle >= heartbeatIdleTimeout {
+			if timeout := c.Reconnect.HealthTimeout; timeout > 0 && quiet >= timeout {
+				slog.Warn("hub has been silent past the health timeout; forcing reconnect",
+					"silent_for", quiet.Round(time.Second), "timeout", timeout)
+				c.cancelConn()
+				return
+			}
+
+			// Ping when either direction is idle: the Hub answers every
+			// heartbeat, so a quiet Hub is what the health check needs
+			// to hear from.
+			if idle >= heartbeatIdleTimeout || quiet >= heartbeatIdleTimeout {
 				if err := c.Send(&leapmuxv1.ConnectRequest{
 					Payload: &leapmuxv1.ConnectRequest_Heartbeat{
 						Heartbeat: &leapmuxv1.Heartbeat{
@@ -449,19 +775,28 @@ func (c *Client) heartbeatLoop(ctx context.Context) {
 type connectFn func(ctx context.Context, authToken string) error
 
 // ConnectWithReconnect wraps Connect with automatic reconnection using
-// exponential backoff. Starts at 1s, doubles up to 60s, resets on
-// successful connection lasting longer than resetThreshold.
+// the exponential backoff c.Reconnect describes, reset on a successful
+// connection lasting longer than resetThreshold. An attempt that fails
+// before the Hub greets the worker moves straight on to the next hub URL;
+// the backoff applies once every URL has failed.
 func (c *Client) ConnectWithReconnect(ctx context.Context, authToken string) {
-	c.connectWithReconnect(ctx, authToken, c.Connect, newDefaultBackoff(), resetThreshold)
+	c.connectWithReconnect(ctx, authToken, c.Connect, c.Reconnect.newBackoff(), resetThreshold)
+}
+
+// endpoint returns the hub URL the next connection attempt targets.
+func (c *Client) endpoint() hubEndpoint {
+	return c.endpoints[c.active.Load()]
 }
 
 func (c *Client) connectWithReconnect(ctx context.Context, authToken string, connect connectFn, bo backoff.BackOff, threshold time.Duration) {
 	for {
 		start := time.Now()
+		c.stats.beginAttempt()
 		err := connect(ctx, authToken)
 		if ctx.Err() != nil {
 			return
 		}
+		established := c.stats.endAttempt(err, time.Now())
 
 		// If the Hub returns Unauthenticated, the worker has been deleted.
 		// Don't retry — call OnDeregister and exit.
@@ -492,6 +827,22 @@ func (c *Client) connectWithReconnect(ctx context.Context, authToken string, con
 			bo.Reset()
 		}
 
+		// A connection that got through went to a working Hub; start over
+		// from the primary. One that never did fails over to the next URL
+		// at once, so an unreachable primary costs one attempt, not a
+		// backoff cycle.
+		switch next := int(c.active.Load()) + 1; {
+		case established:
+			c.active.Store(0)
+		case next < len(c.endpoints):
+			c.active.Store(int32(next))
+			c.stats.failover()
+			slog.Warn("hub unreachable, failing over", "error", err, "next_url", c.endpoints[next].url)
+			continue
+		default:
+			c.active.Store(0)
+		}
+
 		interval := bo.NextBackOff()
 		slog.Warn("disconnected from hub, reconnecting...", "error", err, "backoff", interval)
 		select {
@@ -4,6 +4,7 @@ import (
 	"context"
 	"fmt"
 	"net/http"
+	"net/http/httptest"
 	"os"
 	"path/filepath"
 	"sync/atomic"
@@ -16,6 +17,7 @@ import (
 	"github.com/stretchr/testify/require"
 
 	leapmuxv1 "github.com/leapmux/leapmux/generated/proto/leapmux/v1"
+	"github.com/leapmux/leapmux/internal/protocol"
 )
 
 // TestNew_DispatchesOnURLScheme verifies the scheme-dispatch branches in
@@ -36,7 +38,7 @@ func TestNew_DispatchesOnURLScheme(t *testing.T) {
 		t.Run(tc.name, func(t *testing.T) {
 			client := New(tc.url)
 			require.NotNil(t, client, "New(%q) returned nil", tc.url)
-			assert.Equal(t, tc.url, client.hubURL, "hubURL preserved verbatim")
+			assert.Equal(t, tc.url, client.endpoint().url, "hubURL preserved verbatim")
 		})
 	}
 }
@@ -66,6 +68,27 @@ func TestHTTPClientForHubURL_HTTPFallsBackToH2C(t *testing.T) {
 	require.Error(t, err)
 }
 
+func TestHTTPClientForHubURL_HTTPSUsesTLS(t *testing.T) {
+	srv := httptest.NewUnstartedServer(http.NotFoundHandler())
+	srv.EnableHTTP2 = true
+	srv.StartTLS()
+	t.Cleanup(srv.Close)
+
+	httpClient, connectURL := clientForHubURL(srv.URL)
+	assert.Equal(t, srv.URL, connectURL)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+	defer cancel()
+	req, err := http.NewRequestWithContext(ctx, "GET", srv.URL+"/probe", nil)
+	require.NoError(t, err)
+
+	// The test server's certificate is self-signed, so reaching the
+	// verification step is what proves the client spoke TLS.
+	_, err = httpClient.Do(req)
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "certificate")
+}
+
 func assertDialNotRoutedToTCP(t *testing.T, url string) {
 	t.Helper()
 	httpClient, connectURL := clientForHubURL(url)
@@ -268,6 +291,88 @@ func TestConnectWithReconnect_ResetsBackoffAfterLongConnection(t *testing.T) {
 	assert.Less(t, gap56, gap34, "gap after reset should be shorter than gap before long connection")
 }
 
+// An unreachable URL costs one attempt: the next URL is tried at once, and
+// the backoff only applies after the whole list has failed.
+func TestConnectWithReconnect_FailsOverWithoutBackoff(t *testing.T) {
+	client := &Client{endpoints: []hubEndpoint{{url: "a"}, {url: "b"}, {url: "c"}}}
+	ctx, cancel := context.WithCancel(context.Background())
+
+	var urls []string
+	mockConnect := func(_ context.Context, _ string) error {
+		urls = append(urls, client.endpoint().url)
+		if len(urls) == 4 {
+			cancel()
+		}
+		return fmt.Errorf("unreachable")
+	}
+
+	bo := backoff.NewExponentialBackOff()
+	bo.InitialInterval = 50 * time.Millisecond
+	bo.RandomizationFactor = 0
+	bo.Reset()
+	start := time.Now()
+	client.connectWithReconnect(ctx, "token", mockConnect, bo, time.Hour)
+
+	assert.Equal(t, []string{"a", "b", "c", "a"}, urls, "a failed round starts over at the primary")
+	assert.GreaterOrEqual(t, time.Since(start), 50*time.Millisecond, "one backoff, after the whole round")
+	assert.Less(t, time.Since(start), 100*time.Millisecond, "no backoff between URLs")
+	stats := client.stats.snapshot("a")
+	assert.Equal(t, uint32(2), stats.GetFailovers())
+	assert.Equal(t, uint32(3), stats.GetFailedAttempts(), "the cancelled fourth attempt is not counted")
+}
+
+// A connection that got through to a fallback Hub is followed by a retry
+// of the primary, so the worker drifts back once the primary recovers.
+func TestConnectWithReconnect_ReturnsToPrimaryAfterEstablishing(t *testing.T) {
+	client := &Client{endpoints: []hubEndpoint{{url: "a"}, {url: "b"}}}
+	ctx, cancel := context.WithCancel(context.Background())
+
+	var urls []string
+	mockConnect := func(_ context.Context, _ string) error {
+		urls = append(urls, client.endpoint().url)
+		switch len(urls) {
+		case 2:
+			client.stats.markEstablished()
+			return fmt.Errorf("connection lost")
+		case 3:
+			cancel()
+		}
+		return fmt.Errorf("unreachable")
+	}
+	client.connectWithReconnect(ctx, "token", mockConnect, newFastBackoff(), time.Hour)
+
+	assert.Equal(t, []string{"a", "b", "a"}, urls)
+	stats := client.stats.snapshot("a")
+	assert.Equal(t, uint32(2), stats.GetConnects(), "counts the connection being reported")
+	assert.Equal(t, uint32(0), stats.GetFailedAttempts(), "the established connection cleared the count")
+	assert.Equal(t, "connection lost", stats.GetLastDisconnectReason())
+	assert.NotEmpty(t, stats.GetLastDisconnectAt())
+}
+
+func TestHeartbeatLoop_HealthTimeoutForcesReconnect(t *testing.T) {
+	oldTick := heartbeatTick
+	heartbeatTick = time.Millisecond
+	t.Cleanup(func() { heartbeatTick = oldTick })
+
+	client := &Client{Reconnect: ReconnectPolicy{HealthTimeout: time.Minute}}
+	var cancelled atomic.Bool
+	client.connCancel = func() { cancelled.Store(true) }
+	client.lastSendTime = time.Now()
+	client.lastRecvTime.Store(time.Now().Add(-2 * time.Minute).UnixNano())
+
+	done := make(chan struct{})
+	go func() {
+		client.heartbeatLoop(context.Background())
+		close(done)
+	}()
+	select {
+	case <-done:
+	case <-time.After(5 * time.Second):
+		t.Fatal("heartbeatLoop did not give up on a silent hub")
+	}
+	assert.True(t, cancelled.Load())
+}
+
 func TestConnectWithReconnect_BackoffCapsAtMax(t *testing.T) {
 	var timestamps []time.Time
 	targetAttempts := int32(8)
@@ -375,6 +480,38 @@ func TestHandleMessage_WorkerIdentity_InvokesCallback(t *testing.T) {
 	assert.Equal(t, "owner-1", captured, "OnWorkerIdentity should receive the Hub's owner")
 }
 
+// A hub older than this worker accepts is refused at the greeting: the owner is
+// never adopted and the connection is dropped so the reconnect loop keeps
+// reporting it.
+func TestHandleMessage_WorkerIdentity_RefusesOldHub(t *testing.T) {
+	oldMin := protocol.MinHub
+	protocol.MinHub = protocol.Current
+	t.Cleanup(func() { protocol.MinHub = oldMin })
+
+	c := New("http://localhost:0")
+	cancelled := false
+	c.connCancel = func() { cancelled = true }
+	called := false
+	c.OnWorkerIdentity = func(string) { called = true }
+
+	// A hub that predates the exchange leaves protocol_version unset.
+	c.handleMessage(&leapmuxv1.ConnectResponse{
+		Payload: &leapmuxv1.ConnectResponse_WorkerIdentity{
+			WorkerIdentity: &leapmuxv1.WorkerIdentity{RegisteredBy: "owner-1"},
+		},
+	})
+	assert.False(t, called)
+	assert.False(t, c.identityReceived.Load())
+	assert.True(t, cancelled)
+
+	c.handleMessage(&leapmuxv1.ConnectResponse{
+		Payload: &leapmuxv1.ConnectResponse_WorkerIdentity{
+			WorkerIdentity: &leapmuxv1.WorkerIdentity{RegisteredBy: "owner-1", ProtocolVersion: protocol.Current},
+		},
+	})
+	assert.True(t, called)
+}
+
 // The optional-callback contract: a client with no identity consumer wired (tests,
 // minimal embeddings) must consume the message without panicking.
 func TestHandleMessage_WorkerIdentity_NilCallbackIsSafe(t *testing.T) {
@@ -0,0 +1,82 @@
+package hub
+
+import (
+	"sync"
+	"time"
+
+	leapmuxv1 "github.com/leapmux/leapmux/generated/proto/leapmux/v1"
+	"github.com/leapmux/leapmux/internal/util/timefmt"
+)
+
+// connStats counts what the reconnect loop has been through, for the
+// WorkerConnectionStats the worker reports with each connection's first
+// heartbeat.
+type connStats struct {
+	mu             sync.Mutex
+	connects       uint32
+	failedAttempts uint32 // since the last established connection
+	failovers      uint32
+	established    bool // the current attempt reached WorkerIdentity
+	lastReason     string
+	lastAt         time.Time
+}
+
+// beginAttempt marks the start of a connection attempt.
+func (s *connStats) beginAttempt() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.established = false
+}
+
+// markEstablished records that the current attempt got through to the Hub.
+func (s *connStats) markEstablished() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if !s.established {
+		s.established = true
+		s.connects++
+		s.failedAttempts = 0
+	}
+}
+
+// endAttempt records how the current attempt ended and reports whether it
+// had been established.
+func (s *connStats) endAttempt(err error, at time.Time) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if !s.established {
+		s.failedAttempts++
+	}
+	if err != nil {
+		s.lastReason = err.Error()
+	} else {
+		s.lastReason = "closed"
+	}
+	s.lastAt = at
+	return s.established
+}
+
+func (s *connStats) failover() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.failovers++
+}
+
+// snapshot describes the connection about to be made to hubURL, which is
+// counted as established: the Hub only reads the report once it has
+// accepted the connection.
+func (s *connStats) snapshot(hubURL string) *leapmuxv1.WorkerConnectionStats {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	out := &leapmuxv1.WorkerConnectionStats{
+		Connects:             s.connects + 1,
+		FailedAttempts:       s.failedAttempts,
+		Failovers:            s.failovers,
+		HubUrl:               hubURL,
+		LastDisconnectReason: s.lastReason,
+	}
+	if !s.lastAt.IsZero() {
+		out.LastDisconnectAt = timefmt.Format(s.lastAt)
+	}
+	return out
+}
@@ -0,0 +1,64 @@
+package hub
+
+import (
+	"sync"
+	"time"
+
+	"github.com/leapmux/leapmux/channelwire"
+)
+
+// uploadLimiter caps the channel bytes the worker sends up its Connect stream
+// at the org's WorkerStreamSettings.upload_bytes_per_second.
+//
+// It is a token bucket that lets a sender go into debt: reserve charges the
+// whole message up front and returns how long the caller must wait for the
+// bucket to climb back to zero. Charging up front keeps concurrent senders in
+// arrival order, and a frame larger than the remaining tokens is never
+// starved waiting for a balance it cannot reach. The zero value is unlimited.
+type uploadLimiter struct {
+	mu     sync.Mutex
+	rate   float64 // bytes per second; 0 = unlimited
+	burst  float64
+	tokens float64
+	last   time.Time
+	// now is the clock; nil means time.Now. Tests substitute a fake.
+	now func() time.Time
+}
+
+func (l *uploadLimiter) clock() time.Time {
+	if l.now != nil {
+		return l.now()
+	}
+	return time.Now()
+}
+
+// setRate changes the cap. The bucket starts full so a new cap does not
+// stall the next frame; debt owed under the old cap is forgiven.
+func (l *uploadLimiter) setRate(bytesPerSecond uint64) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.rate = float64(bytesPerSecond)
+	// One full channel frame must fit in the bucket, or a cap below the frame
+	// size would delay every frame regardless of how idle the link was.
+	l.burst = max(l.rate, channelwire.MaxCiphertextForChunk)
+	l.tokens = l.burst
+	l.last = l.clock()
+}
+
+// reserve charges n bytes and returns how long the caller must wait before
+// sending them. Zero when unlimited or the bucket covers n.
+func (l *uploadLimiter) reserve(n int) time.Duration {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if l.rate == 0 {
+		return 0
+	}
+	now := l.clock()
+	l.tokens = min(l.burst, l.tokens+now.Sub(l.last).Seconds()*l.rate)
+	l.last = now
+	l.tokens -= float64(n)
+	if l.tokens >= 0 {
+		return 0
+	}
+	return time.Duration(-l.tokens / l.rate * float64(time.Second))
+}
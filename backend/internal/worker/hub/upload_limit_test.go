@@ -0,0 +1,83 @@
+package hub
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/leapmux/leapmux/channelwire"
+	leapmuxv1 "github.com/leapmux/leapmux/generated/proto/leapmux/v1"
+	"github.com/leapmux/leapmux/internal/protocol"
+)
+
+func TestUploadLimiter_ZeroValueIsUnlimited(t *testing.T) {
+	var l uploadLimiter
+	assert.Zero(t, l.reserve(10<<20))
+}
+
+func TestUploadLimiter_PacesToRate(t *testing.T) {
+	now := time.Unix(0, 0)
+	l := uploadLimiter{now: func() time.Time { return now }}
+	l.setRate(32 << 10)
+
+	// The bucket holds one full frame, so the first one goes straight out.
+	assert.Zero(t, l.reserve(channelwire.MaxCiphertextForChunk))
+	// The next one owes a full frame's worth at 32 KiB/s.
+	assert.InDelta(t, 2*time.Second, l.reserve(channelwire.MaxCiphertextForChunk), float64(10*time.Millisecond))
+
+	// Two seconds only pay the debt off; a third earns 32 KiB back.
+	now = now.Add(2 * time.Second)
+	assert.Positive(t, l.reserve(1<<10))
+	now = now.Add(time.Second)
+	assert.Zero(t, l.reserve(31<<10))
+
+	l.setRate(0)
+	assert.Zero(t, l.reserve(10<<20))
+}
+
+func TestHandleMessage_StreamSettings(t *testing.T) {
+	c := New("http://localhost:0")
+	var got []*leapmuxv1.WorkerStreamSettings
+	c.OnStreamSettings = func(s *leapmuxv1.WorkerStreamSettings) { got = append(got, s) }
+
+	// A Hub that predates stream settings greets without them.
+	c.handleMessage(&leapmuxv1.ConnectResponse{
+		Payload: &leapmuxv1.ConnectResponse_WorkerIdentity{
+			WorkerIdentity: &leapmuxv1.WorkerIdentity{RegisteredBy: "owner-1", ProtocolVersion: protocol.Current},
+		},
+	})
+	c.handleMessage(&leapmuxv1.ConnectResponse{
+		Payload: &leapmuxv1.ConnectResponse_StreamSettings{
+			StreamSettings: &leapmuxv1.WorkerStreamSettings{UploadBytesPerSecond: 64 << 10, CompressOutput: true},
+		},
+	})
+
+	require.Len(t, got, 2)
+	assert.False(t, got[0].GetCompressOutput())
+	assert.True(t, got[1].GetCompressOutput())
+	assert.EqualValues(t, 64<<10, c.upload.rate)
+}
+
+func TestSend_ThrottledChannelMessageEndsWithConnection(t *testing.T) {
+	c := &Client{}
+	c.upload.setRate(16 << 10)
+	done := make(chan struct{})
+	c.connDone = done
+	msg := &leapmuxv1.ConnectRequest{
+		Payload: &leapmuxv1.ConnectRequest_ChannelMessageResp{
+			ChannelMessageResp: &leapmuxv1.ChannelMessage{Ciphertext: make([]byte, 4*channelwire.MaxCiphertextForChunk)},
+		},
+	}
+
+	errc := make(chan error, 1)
+	go func() { errc <- c.Send(msg) }()
+	close(done)
+	select {
+	case err := <-errc:
+		assert.Error(t, err)
+	case <-time.After(5 * time.Second):
+		t.Fatal("throttled Send did not end with the connection")
+	}
+}
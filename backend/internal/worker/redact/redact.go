@@ -0,0 +1,314 @@
+// Package redact finds credentials in agent output and replaces them with
+// placeholders, so a key an agent happens to print -- by running `env`,
+// say -- is not kept in the chat history or sent to every watcher.
+//
+// Detection is pluggable: a Redactor runs a list of Detectors, and
+// Defaults covers the common key formats plus an entropy check on
+// secret-looking assignments. Detectors see the line as JSON text, the
+// form agents print it in, so nothing is decoded and re-encoded unless a
+// secret is found.
+//
+// A Classifier runs Detectors too, for personal data: it tags the content
+// with what it found instead of replacing it.
+package redact
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"os"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+// Detector finds one kind of secret.
+type Detector interface {
+	// Name labels the placeholder and the redaction event, e.g.
+	// "aws-access-key".
+	Name() string
+	// Find returns the [start, end) byte ranges of the secrets in line, a
+	// line of JSON text: inside a string a newline reads as \n and a quote
+	// as \". A range must lie within one JSON string.
+	Find(line []byte) [][2]int
+}
+
+// Placeholder is what a secret found by the named detector is replaced
+// with.
+func Placeholder(name string) string {
+	return "[REDACTED:" + name + "]"
+}
+
+// patternDetector is a Detector backed by a regular expression. When the
+// expression has a capture group, only the first group is the secret.
+type patternDetector struct {
+	name string
+	re   *regexp.Regexp
+	// accept, when set, must approve a match before it counts.
+	accept func(secret []byte) bool
+}
+
+// Pattern returns a Detector named name that finds matches of expr. When
+// expr has a capture group, only what the first group matches is
+// redacted, so context such as a variable name can stay readable.
+func Pattern(name, expr string) (Detector, error) {
+	if !validName.MatchString(name) {
+		return nil, fmt.Errorf("detector name %q must be lowercase letters, digits and dashes", name)
+	}
+	re, err := regexp.Compile(expr)
+	if err != nil {
+		return nil, fmt.Errorf("detector %s: %w", name, err)
+	}
+	return &patternDetector{name: name, re: re}, nil
+}
+
+func mustPattern(name, expr string) *patternDetector {
+	d, err := Pattern(name, expr)
+	if err != nil {
+		panic(err)
+	}
+	return d.(*patternDetector)
+}
+
+var validName = regexp.MustCompile(`^[a-z0-9][a-z0-9-]*$`)
+
+func (d *patternDetector) Name() string { return d.name }
+
+func (d *patternDetector) Find(line []byte) [][2]int {
+	var out [][2]int
+	for _, m := range d.re.FindAllSubmatchIndex(line, -1) {
+		start, end := m[0], m[1]
+		if len(m) >= 4 {
+			if m[2] < 0 {
+				continue
+			}
+			start, end = m[2], m[3]
+		}
+		if start == end {
+			continue
+		}
+		if d.accept != nil && !d.accept(line[start:end]) {
+			continue
+		}
+		out = append(out, [2]int{start, end})
+	}
+	return out
+}
+
+// assignmentPattern matches NAME=value and "name": "value" where the name
+// says it holds a secret. The value is checked with looksRandom, since
+// such names also hold paths, flags and placeholders.
+const assignmentPattern = `(?i)\b[A-Z0-9_.-]*(?:API_?KEY|ACCESS_?KEY|SECRET|TOKEN|PASSWORD|PASSWD|CREDENTIALS?|PRIVATE_?KEY)[A-Z0-9_]*(?:\\?["'])?\s*[=:]\s*(?:\\?["'])?([^\s"'\\,;}]+)`
+
+// Defaults returns the built-in detectors, most specific first.
+func Defaults() []Detector {
+	assignment := mustPattern("secret-assignment", assignmentPattern)
+	assignment.accept = looksRandom
+	return []Detector{
+		mustPattern("private-key", `-----BEGIN[A-Z ]*PRIVATE KEY-----(?:\\[nrt]|[A-Za-z0-9+/=\s:,.])*(?:-----END[A-Z ]*PRIVATE KEY-----)?`),
+		mustPattern("aws-access-key", `\b((?:AKIA|ASIA)[0-9A-Z]{16})\b`),
+		mustPattern("github-token", `\b((?:gh[pousr]_[A-Za-z0-9]{36,})|github_pat_[A-Za-z0-9_]{22,})`),
+		mustPattern("anthropic-api-key", `\b(sk-ant-[A-Za-z0-9_-]{20,})`),
+		mustPattern("openai-api-key", `\b(sk-(?:proj-|svcacct-)?[A-Za-z0-9_-]{20,})`),
+		mustPattern("google-api-key", `\b(AIza[0-9A-Za-z_-]{35})`),
+		mustPattern("slack-token", `\b(xox[abposr]-[A-Za-z0-9-]{10,})`),
+		mustPattern("stripe-key", `\b([rs]k_live_[A-Za-z0-9]{20,})`),
+		mustPattern("jwt", `\b(eyJ[A-Za-z0-9_-]{10,}\.eyJ[A-Za-z0-9_-]{10,}\.[A-Za-z0-9_-]{10,})`),
+		mustPattern("url-password", `\b[a-zA-Z][a-zA-Z0-9+.-]*://[^\s:/@"\\]+:([^\s/@"\\]+)@`),
+		assignment,
+	}
+}
+
+// looksRandom reports whether v reads like a generated secret rather than
+// a word, path or reference: long enough, of mixed character classes, and
+// with high Shannon entropy.
+func looksRandom(v []byte) bool {
+	if len(v) < 12 {
+		return false
+	}
+	switch v[0] {
+	case '$', '/', '~', '.', '<', '{', '%', '*', '[':
+		return false
+	}
+	var lower, upper, digit bool
+	for _, c := range v {
+		switch {
+		case c >= 'a' && c <= 'z':
+			lower = true
+		case c >= 'A' && c <= 'Z':
+			upper = true
+		case c >= '0' && c <= '9':
+			digit = true
+		}
+	}
+	classes := 0
+	for _, ok := range []bool{lower, upper, digit} {
+		if ok {
+			classes++
+		}
+	}
+	return classes >= 2 && entropy(v) >= 3.5
+}
+
+// entropy is the Shannon entropy of v in bits per byte.
+func entropy(v []byte) float64 {
+	var counts [256]int
+	for _, c := range v {
+		counts[c]++
+	}
+	var h float64
+	n := float64(len(v))
+	for _, c := range counts {
+		if c == 0 {
+			continue
+		}
+		p := float64(c) / n
+		h -= p * math.Log2(p)
+	}
+	return h
+}
+
+// LoadPatterns reads extra detectors from path, one per line as
+// name=regexp. Blank lines and lines starting with # are skipped.
+func LoadPatterns(path string) ([]Detector, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer func() { _ = f.Close() }()
+	var out []Detector
+	scanner := bufio.NewScanner(f)
+	for n := 1; scanner.Scan(); n++ {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		name, expr, ok := strings.Cut(line, "=")
+		if !ok {
+			return nil, fmt.Errorf("%s:%d: want name=regexp", path, n)
+		}
+		d, err := Pattern(strings.TrimSpace(name), strings.TrimSpace(expr))
+		if err != nil {
+			return nil, fmt.Errorf("%s:%d: %w", path, n, err)
+		}
+		out = append(out, d)
+	}
+	return out, scanner.Err()
+}
+
+// Redactor replaces what its detectors find. A nil *Redactor redacts
+// nothing.
+type Redactor struct {
+	detectors []Detector
+}
+
+// New returns a Redactor running detectors in order. Where two find
+// overlapping secrets, the earlier detector's wins.
+func New(detectors ...Detector) *Redactor {
+	return &Redactor{detectors: detectors}
+}
+
+// Result is what Redact replaced.
+type Result struct {
+	// Counts is the number of secrets each detector found.
+	Counts map[string]int
+}
+
+// Total is the number of secrets replaced.
+func (r Result) Total() int {
+	n := 0
+	for _, c := range r.Counts {
+		n += c
+	}
+	return n
+}
+
+// Detectors returns the names of the detectors that found something,
+// sorted.
+func (r Result) Detectors() []string {
+	names := make([]string, 0, len(r.Counts))
+	for name := range r.Counts {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// errBrokeJSON is returned when replacing the secrets would leave line
+// unparseable, which only a custom detector whose ranges cross a string's
+// boundary can cause.
+var errBrokeJSON = errors.New("redaction would break the line's JSON")
+
+// Redact returns line with every secret replaced by its Placeholder. It
+// returns line itself when nothing was found. When line is JSON, the
+// result is too: ranges that would split an escape or cross a string's
+// end are left alone, and if the line still would not parse, it is
+// returned unchanged with an error.
+func (r *Redactor) Redact(line []byte) ([]byte, Result, error) {
+	if r == nil || len(r.detectors) == 0 {
+		return line, Result{}, nil
+	}
+	type hit struct {
+		start, end int
+		name       string
+	}
+	var hits []hit
+	for _, d := range r.detectors {
+		for _, rg := range d.Find(line) {
+			if rg[0] < 0 || rg[1] > len(line) || rg[0] >= rg[1] || !withinString(line, rg[0], rg[1]) {
+				continue
+			}
+			hits = append(hits, hit{rg[0], rg[1], d.Name()})
+		}
+	}
+	if len(hits) == 0 {
+		return line, Result{}, nil
+	}
+	// Keep the earliest-starting hit of each overlapping run; the stable
+	// sort keeps detector order among hits that start together.
+	sort.SliceStable(hits, func(i, j int) bool { return hits[i].start < hits[j].start })
+	res := Result{Counts: map[string]int{}}
+	out := make([]byte, 0, len(line))
+	last := 0
+	for _, h := range hits {
+		if h.start < last {
+			continue
+		}
+		out = append(out, line[last:h.start]...)
+		out = append(out, Placeholder(h.name)...)
+		last = h.end
+		res.Counts[h.name]++
+	}
+	out = append(out, line[last:]...)
+	if json.Valid(line) && !json.Valid(out) {
+		return line, Result{}, errBrokeJSON
+	}
+	return out, res, nil
+}
+
+// withinString reports whether line[start:end] can be replaced without
+// splitting an escape sequence or swallowing a string's closing quote.
+func withinString(line []byte, start, end int) bool {
+	// An odd run of backslashes before start means start is mid-escape.
+	slashes := 0
+	for i := start - 1; i >= 0 && line[i] == '\\'; i-- {
+		slashes++
+	}
+	if slashes%2 == 1 {
+		return false
+	}
+	for i := start; i < end; i++ {
+		switch line[i] {
+		case '"':
+			return false
+		case '\\':
+			if i+1 >= end {
+				return false
+			}
+			i++
+		}
+	}
+	return true
+}
@@ -337,6 +337,7 @@ func (c *responseCollector) toResponse() *leapmuxv1.CallInnerResponse {
 		IsError:      c.resp.GetIsError(),
 		ErrorCode:    c.resp.GetErrorCode(),
 		ErrorMessage: c.resp.GetErrorMessage(),
+		ErrorReason:  c.resp.GetErrorReason(),
 	}
 }
 
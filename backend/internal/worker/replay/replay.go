@@ -0,0 +1,143 @@
+// Package replay feeds a captured agent transcript -- the raw stdout a
+// worker saves with --capture-agent-output -- back through the agent's
+// HandleOutput and the worker's real output sink, against a scratch
+// database. Threading, span and notification bugs then reproduce
+// deterministically, without the agent or a hub.
+package replay
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	leapmuxv1 "github.com/leapmux/leapmux/generated/proto/leapmux/v1"
+	"github.com/leapmux/leapmux/internal/util/msgcodec"
+	"github.com/leapmux/leapmux/internal/util/sqlitedb"
+	"github.com/leapmux/leapmux/internal/worker/agent"
+	workerdb "github.com/leapmux/leapmux/internal/worker/db"
+	db "github.com/leapmux/leapmux/internal/worker/generated/db"
+	"github.com/leapmux/leapmux/internal/worker/service"
+)
+
+// DefaultAgentID is the agent the replayed rows are stored under.
+const DefaultAgentID = "replay"
+
+// Options configures a replay.
+type Options struct {
+	// DBPath is the scratch database the rows are written to. It must not
+	// exist yet, so a replay can never write into a live worker.db.
+	// Empty means an in-memory database.
+	DBPath string
+	// AgentID names the agent row; empty means DefaultAgentID.
+	AgentID string
+}
+
+// Row is one persisted message, with its content decompressed.
+type Row struct {
+	Seq          int64           `json:"seq"`
+	Source       string          `json:"source"`
+	Depth        int64           `json:"depth,omitempty"`
+	SpanID       string          `json:"span_id,omitempty"`
+	ParentSpanID string          `json:"parent_span_id,omitempty"`
+	SpanType     string          `json:"span_type,omitempty"`
+	Content      json.RawMessage `json:"content"`
+}
+
+// Run replays transcript, one NDJSON line per agent stdout line, and
+// returns the rows the worker persisted, in seq order. Only Claude Code
+// transcripts are supported: the other providers' handlers depend on
+// request/response state a bare transcript does not carry.
+func Run(ctx context.Context, transcript io.Reader, opts Options) ([]Row, error) {
+	path := opts.DBPath
+	if path == "" {
+		path = ":memory:"
+	} else if _, err := os.Stat(path); err == nil {
+		return nil, fmt.Errorf("%s already exists; replay writes to a fresh database", path)
+	} else if !errors.Is(err, os.ErrNotExist) {
+		return nil, err
+	}
+	agentID := opts.AgentID
+	if agentID == "" {
+		agentID = DefaultAgentID
+	}
+
+	sqlDB, err := workerdb.Open(path, sqlitedb.Config{})
+	if err != nil {
+		return nil, fmt.Errorf("open scratch db: %w", err)
+	}
+	defer func() { _ = sqlDB.Close() }()
+	if err := workerdb.Migrate(sqlDB); err != nil {
+		return nil, fmt.Errorf("migrate scratch db: %w", err)
+	}
+
+	// Plan files land in DataDir; keep them out of the working directory.
+	dataDir, err := os.MkdirTemp("", "leapmux-replay-")
+	if err != nil {
+		return nil, err
+	}
+	defer func() { _ = os.RemoveAll(dataDir) }()
+
+	queries := db.New(sqlDB)
+	provider := leapmuxv1.AgentProvider_AGENT_PROVIDER_CLAUDE_CODE
+	if err := queries.CreateAgent(ctx, db.CreateAgentParams{
+		ID:            agentID,
+		WorkspaceID:   "replay",
+		WorkingDir:    dataDir,
+		HomeDir:       dataDir,
+		Title:         "Replay",
+		AgentProvider: provider,
+	}); err != nil {
+		return nil, fmt.Errorf("create agent: %w", err)
+	}
+
+	output := service.NewOutputHandler(sqlDB, queries, service.NewWatcherManager(), agent.NewManager(nil), nil)
+	output.DataDir = dataDir
+	// Stops the auto-continue timers a rate-limited transcript arms.
+	defer output.CleanupAgent(agentID)
+	a := agent.NewClaudeCodeReplay(agentID, output.NewSink(agentID, provider))
+
+	scanner := bufio.NewScanner(transcript)
+	scanner.Buffer(make([]byte, 0, 64*1024), 64*1024*1024)
+	for scanner.Scan() {
+		if err := ctx.Err(); err != nil {
+			return nil, err
+		}
+		if len(scanner.Bytes()) == 0 {
+			continue
+		}
+		a.HandleOutput(append([]byte(nil), scanner.Bytes()...))
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("read transcript: %w", err)
+	}
+
+	msgs, err := queries.ListAllMessagesByAgentID(ctx, db.ListAllMessagesByAgentIDParams{AgentID: agentID})
+	if err != nil {
+		return nil, fmt.Errorf("list messages: %w", err)
+	}
+	rows := make([]Row, len(msgs))
+	for i, m := range msgs {
+		content, err := msgcodec.Decompress(m.Content, m.ContentCompression)
+		if err != nil {
+			return nil, fmt.Errorf("decompress message %d: %w", m.Seq, err)
+		}
+		if !json.Valid(content) {
+			content, _ = json.Marshal(string(content))
+		}
+		rows[i] = Row{
+			Seq:          m.Seq,
+			Source:       strings.TrimPrefix(m.Source.String(), "MESSAGE_SOURCE_"),
+			Depth:        m.Depth,
+			SpanID:       m.SpanID,
+			ParentSpanID: m.ParentSpanID,
+			SpanType:     m.SpanType,
+			Content:      content,
+		}
+	}
+	return rows, nil
+}
@@ -0,0 +1,60 @@
+package replay
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const transcript = `{"type":"system","subtype":"init","session_id":"s1","model":"claude-sonnet-4-5","permissionMode":"default","tools":["Bash"]}
+{"type":"assistant","message":{"id":"m1","role":"assistant","content":[{"type":"text","text":"Listing files."}]},"parent_tool_use_id":null,"session_id":"s1"}
+{"type":"assistant","message":{"id":"m2","role":"assistant","content":[{"type":"tool_use","id":"toolu_1","name":"Bash","input":{"command":"ls"}}]},"parent_tool_use_id":null,"session_id":"s1"}
+
+{"type":"user","message":{"role":"user","content":[{"type":"tool_result","tool_use_id":"toolu_1","content":"README.md","is_error":false}]},"parent_tool_use_id":null,"session_id":"s1"}
+{"type":"result","subtype":"success","is_error":false,"num_turns":2,"result":"Done.","session_id":"s1"}
+{"type":"system","subtype":"compact_boundary","session_id":"s1","compact_metadata":{"trigger":"auto","pre_tokens":1000}}
+{"type":"system","subtype":"status","status":"compacting","session_id":"s1"}
+`
+
+func TestRun_ReproducesThreadingAndSpans(t *testing.T) {
+	rows, err := Run(context.Background(), strings.NewReader(transcript), Options{})
+	require.NoError(t, err)
+	require.Len(t, rows, 6)
+
+	assert.Contains(t, string(rows[0].Content), `"subtype":"init"`)
+	assert.Equal(t, "AGENT", rows[2].Source)
+	assert.Equal(t, "toolu_1", rows[2].SpanID, "the tool call opens a span")
+	assert.Equal(t, "USER", rows[3].Source)
+	assert.Equal(t, "toolu_1", rows[3].SpanID, "its result lands in the same span")
+	assert.Contains(t, string(rows[5].Content), `"notification_thread"`, "adjacent notifications merge into one thread")
+}
+
+func TestRun_IsDeterministic(t *testing.T) {
+	first, err := Run(context.Background(), strings.NewReader(transcript), Options{})
+	require.NoError(t, err)
+	second, err := Run(context.Background(), strings.NewReader(transcript), Options{})
+	require.NoError(t, err)
+	require.Len(t, second, len(first))
+	for i := range first {
+		assert.Equal(t, first[i].Seq, second[i].Seq)
+		assert.Equal(t, first[i].SpanID, second[i].SpanID)
+		assert.Equal(t, first[i].Source, second[i].Source)
+	}
+}
+
+func TestRun_KeepsRowsInAFreshDatabase(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "replay.db")
+	rows, err := Run(context.Background(), strings.NewReader(transcript), Options{DBPath: path, AgentID: "agent-7"})
+	require.NoError(t, err)
+	assert.NotEmpty(t, rows)
+	_, err = os.Stat(path)
+	require.NoError(t, err, "the scratch database is kept for inspection")
+
+	_, err = Run(context.Background(), strings.NewReader(transcript), Options{DBPath: path})
+	require.ErrorContains(t, err, "already exists", "a replay never writes into an existing database")
+}
@@ -23,6 +23,7 @@ const (
 	codeNotFound           = int32(5)
 	codePermissionDenied   = int32(7)
 	codeFailedPrecondition = int32(9)
+	codeInternal           = int32(13)
 )
 
 // seedAgent and seedTerminal create minimal DB rows in the given workspace.
@@ -62,6 +63,15 @@ var agentHandlerCases = []agentHandlerCase{
 	{"SendAgentMessage", func(id string) proto.Message {
 		return &leapmuxv1.SendAgentMessageRequest{AgentId: id, Content: "hello"}
 	}},
+	{"SendVoiceNote", func(id string) proto.Message {
+		return &leapmuxv1.SendVoiceNoteRequest{AgentId: id, Audio: &leapmuxv1.Attachment{Filename: "note.wav", MimeType: "audio/wav", Data: []byte("RIFF")}}
+	}},
+	{"SendTerminalOutputToAgent", func(id string) proto.Message {
+		return &leapmuxv1.SendTerminalOutputToAgentRequest{AgentId: id, TerminalId: "term-1"}
+	}},
+	{"CloneAgent", func(id string) proto.Message {
+		return &leapmuxv1.CloneAgentRequest{AgentId: id, CopyHistory: true}
+	}},
 	{"SendAgentRawMessage", func(id string) proto.Message {
 		return &leapmuxv1.SendAgentRawMessageRequest{AgentId: id, Content: "{}"}
 	}},
@@ -86,10 +96,70 @@ var agentHandlerCases = []agentHandlerCase{
 	{"ListMessageMarks", func(id string) proto.Message {
 		return &leapmuxv1.ListMessageMarksRequest{AgentId: id}
 	}},
+	{"ListAgentMessagesInRange", func(id string) proto.Message {
+		return &leapmuxv1.ListAgentMessagesInRangeRequest{AgentId: id, FromSeq: 1, ToSeq: 10}
+	}},
+	{"GetAgentMessageStats", func(id string) proto.Message {
+		return &leapmuxv1.GetAgentMessageStatsRequest{AgentId: id}
+	}},
+	{"MarkAgentRead", func(id string) proto.Message {
+		return &leapmuxv1.MarkAgentReadRequest{AgentId: id}
+	}},
+	{"RenderAgentTranscript", func(id string) proto.Message {
+		return &leapmuxv1.RenderAgentTranscriptRequest{AgentId: id}
+	}},
+	{"VerifyAgentTranscript", func(id string) proto.Message {
+		return &leapmuxv1.VerifyAgentTranscriptRequest{AgentId: id}
+	}},
+	{"SetAgentArtifactPatterns", func(id string) proto.Message {
+		return &leapmuxv1.SetAgentArtifactPatternsRequest{AgentId: id, Patterns: []string{"*.md"}}
+	}},
+	{"ListAgentArtifacts", func(id string) proto.Message {
+		return &leapmuxv1.ListAgentArtifactsRequest{AgentId: id}
+	}},
+	{"StartAgentUpload", func(id string) proto.Message {
+		return &leapmuxv1.StartAgentUploadRequest{AgentId: id, UploadId: "u1", Path: "f.txt", TotalSize: 1}
+	}},
+	{"WriteAgentUploadChunk", func(id string) proto.Message {
+		return &leapmuxv1.WriteAgentUploadChunkRequest{AgentId: id, UploadId: "u1", Data: []byte("x")}
+	}},
+	{"CancelAgentUpload", func(id string) proto.Message {
+		return &leapmuxv1.CancelAgentUploadRequest{AgentId: id, UploadId: "u1"}
+	}},
+	{"ReadAgentFile", func(id string) proto.Message {
+		return &leapmuxv1.ReadAgentFileRequest{AgentId: id, Path: "f.txt"}
+	}},
 	// InterruptAgent is agent-ID-scoped via registerAgentGated.
 	{"InterruptAgent", func(id string) proto.Message {
 		return &leapmuxv1.InterruptAgentRequest{AgentId: id}
 	}},
+	{"ListAgentTurnModels", func(id string) proto.Message {
+		return &leapmuxv1.ListAgentTurnModelsRequest{AgentId: id}
+	}},
+	{"ExecutePlan", func(id string) proto.Message {
+		return &leapmuxv1.ExecutePlanRequest{AgentId: id, PlanId: "plan-1"}
+	}},
+	{"UpdateAgentPlan", func(id string) proto.Message {
+		return &leapmuxv1.UpdateAgentPlanRequest{AgentId: id, Content: "# Plan"}
+	}},
+	{"ListSubAgentRuns", func(id string) proto.Message {
+		return &leapmuxv1.ListSubAgentRunsRequest{AgentId: id}
+	}},
+	{"ListAgentTestRuns", func(id string) proto.Message {
+		return &leapmuxv1.ListAgentTestRunsRequest{AgentId: id}
+	}},
+	{"GetAgentCIStatus", func(id string) proto.Message {
+		return &leapmuxv1.GetAgentCIStatusRequest{AgentId: id}
+	}},
+	{"GetAgentRuntimeInfo", func(id string) proto.Message {
+		return &leapmuxv1.GetAgentRuntimeInfoRequest{AgentId: id}
+	}},
+	{"CompactAgentContext", func(id string) proto.Message {
+		return &leapmuxv1.CompactAgentContextRequest{AgentId: id}
+	}},
+	{"GetRateLimitBudget", func(id string) proto.Message {
+		return &leapmuxv1.GetRateLimitBudgetRequest{AgentId: id}
+	}},
 }
 
 // terminalHandlerCases enumerates terminal-ID-scoped handlers gated via
@@ -348,6 +418,206 @@ var gatedMethodProbes = func() []gatedMethodProbe {
 				}
 			},
 		},
+		gatedMethodProbe{
+			name:   "GetWorkspaceRetryPolicy",
+			method: "GetWorkspaceRetryPolicy",
+			seed:   func(*testing.T, *Service) {},
+			req: func() proto.Message {
+				return &leapmuxv1.GetWorkspaceRetryPolicyRequest{WorkspaceId: "ws-other"}
+			},
+		},
+		gatedMethodProbe{
+			name:   "SetWorkspaceRetryPolicy",
+			method: "SetWorkspaceRetryPolicy",
+			seed:   func(*testing.T, *Service) {},
+			req: func() proto.Message {
+				return &leapmuxv1.SetWorkspaceRetryPolicyRequest{WorkspaceId: "ws-other"}
+			},
+		},
+		gatedMethodProbe{
+			name:   "GetWorkspaceTurnLimits",
+			method: "GetWorkspaceTurnLimits",
+			seed:   func(*testing.T, *Service) {},
+			req: func() proto.Message {
+				return &leapmuxv1.GetWorkspaceTurnLimitsRequest{WorkspaceId: "ws-other"}
+			},
+		},
+		gatedMethodProbe{
+			name:   "SetWorkspaceTurnLimits",
+			method: "SetWorkspaceTurnLimits",
+			seed:   func(*testing.T, *Service) {},
+			req: func() proto.Message {
+				return &leapmuxv1.SetWorkspaceTurnLimitsRequest{WorkspaceId: "ws-other"}
+			},
+		},
+		gatedMethodProbe{
+			name:   "GetWorkspaceAgentDefaults",
+			method: "GetWorkspaceAgentDefaults",
+			seed:   func(*testing.T, *Service) {},
+			req: func() proto.Message {
+				return &leapmuxv1.GetWorkspaceAgentDefaultsRequest{WorkspaceId: "ws-other"}
+			},
+		},
+		gatedMethodProbe{
+			name:   "SetWorkspaceAgentDefaults",
+			method: "SetWorkspaceAgentDefaults",
+			seed:   func(*testing.T, *Service) {},
+			req: func() proto.Message {
+				return &leapmuxv1.SetWorkspaceAgentDefaultsRequest{WorkspaceId: "ws-other"}
+			},
+		},
+		gatedMethodProbe{
+			name:   "GetWorkspaceModelRouting",
+			method: "GetWorkspaceModelRouting",
+			seed:   func(*testing.T, *Service) {},
+			req: func() proto.Message {
+				return &leapmuxv1.GetWorkspaceModelRoutingRequest{WorkspaceId: "ws-other"}
+			},
+		},
+		gatedMethodProbe{
+			name:   "SetWorkspaceModelRouting",
+			method: "SetWorkspaceModelRouting",
+			seed:   func(*testing.T, *Service) {},
+			req: func() proto.Message {
+				return &leapmuxv1.SetWorkspaceModelRoutingRequest{WorkspaceId: "ws-other"}
+			},
+		},
+		gatedMethodProbe{
+			name:   "SavePlan",
+			method: "SavePlan",
+			seed:   func(*testing.T, *Service) {},
+			req: func() proto.Message {
+				return &leapmuxv1.SavePlanRequest{WorkspaceId: "ws-other"}
+			},
+		},
+		gatedMethodProbe{
+			name:   "ListPlans",
+			method: "ListPlans",
+			seed:   func(*testing.T, *Service) {},
+			req: func() proto.Message {
+				return &leapmuxv1.ListPlansRequest{WorkspaceId: "ws-other"}
+			},
+		},
+		gatedMethodProbe{
+			name:   "GetPlanRevision",
+			method: "GetPlanRevision",
+			seed:   func(*testing.T, *Service) {},
+			req: func() proto.Message {
+				return &leapmuxv1.GetPlanRevisionRequest{WorkspaceId: "ws-other"}
+			},
+		},
+		gatedMethodProbe{
+			name:   "ComparePlanRevisions",
+			method: "ComparePlanRevisions",
+			seed:   func(*testing.T, *Service) {},
+			req: func() proto.Message {
+				return &leapmuxv1.ComparePlanRevisionsRequest{WorkspaceId: "ws-other"}
+			},
+		},
+		gatedMethodProbe{
+			name:   "DeletePlan",
+			method: "DeletePlan",
+			seed:   func(*testing.T, *Service) {},
+			req: func() proto.Message {
+				return &leapmuxv1.DeletePlanRequest{WorkspaceId: "ws-other"}
+			},
+		},
+		gatedMethodProbe{
+			name:   "ExportWorkspaceAgents",
+			method: "ExportWorkspaceAgents",
+			seed:   func(*testing.T, *Service) {},
+			req: func() proto.Message {
+				return &leapmuxv1.ExportWorkspaceAgentsRequest{WorkspaceId: "ws-other"}
+			},
+		},
+		gatedMethodProbe{
+			name:   "ImportWorkspaceAgents",
+			method: "ImportWorkspaceAgents",
+			seed:   func(*testing.T, *Service) {},
+			req: func() proto.Message {
+				return &leapmuxv1.ImportWorkspaceAgentsRequest{WorkspaceId: "ws-other"}
+			},
+		},
+		gatedMethodProbe{
+			name:   "GetWorkspaceNotificationConsolidation",
+			method: "GetWorkspaceNotificationConsolidation",
+			seed:   func(*testing.T, *Service) {},
+			req: func() proto.Message {
+				return &leapmuxv1.GetWorkspaceNotificationConsolidationRequest{WorkspaceId: "ws-other"}
+			},
+		},
+		gatedMethodProbe{
+			name:   "SetWorkspaceNotificationConsolidation",
+			method: "SetWorkspaceNotificationConsolidation",
+			seed:   func(*testing.T, *Service) {},
+			req: func() proto.Message {
+				return &leapmuxv1.SetWorkspaceNotificationConsolidationRequest{WorkspaceId: "ws-other"}
+			},
+		},
+		gatedMethodProbe{
+			name:   "GetWorkspaceProvisioning",
+			method: "GetWorkspaceProvisioning",
+			seed:   func(*testing.T, *Service) {},
+			req: func() proto.Message {
+				return &leapmuxv1.GetWorkspaceProvisioningRequest{WorkspaceId: "ws-other"}
+			},
+		},
+		gatedMethodProbe{
+			name:   "SetWorkspaceProvisioning",
+			method: "SetWorkspaceProvisioning",
+			seed:   func(*testing.T, *Service) {},
+			req: func() proto.Message {
+				return &leapmuxv1.SetWorkspaceProvisioningRequest{WorkspaceId: "ws-other"}
+			},
+		},
+		gatedMethodProbe{
+			name:   "GetWorkspaceCIPolicy",
+			method: "GetWorkspaceCIPolicy",
+			seed:   func(*testing.T, *Service) {},
+			req: func() proto.Message {
+				return &leapmuxv1.GetWorkspaceCIPolicyRequest{WorkspaceId: "ws-other"}
+			},
+		},
+		gatedMethodProbe{
+			name:   "SetWorkspaceCIPolicy",
+			method: "SetWorkspaceCIPolicy",
+			seed:   func(*testing.T, *Service) {},
+			req: func() proto.Message {
+				return &leapmuxv1.SetWorkspaceCIPolicyRequest{WorkspaceId: "ws-other"}
+			},
+		},
+		gatedMethodProbe{
+			name:   "GetWorkspaceEnv",
+			method: "GetWorkspaceEnv",
+			seed:   func(*testing.T, *Service) {},
+			req: func() proto.Message {
+				return &leapmuxv1.GetWorkspaceEnvRequest{WorkspaceId: "ws-other"}
+			},
+		},
+		gatedMethodProbe{
+			name:   "SetWorkspaceEnv",
+			method: "SetWorkspaceEnv",
+			seed:   func(*testing.T, *Service) {},
+			req: func() proto.Message {
+				return &leapmuxv1.SetWorkspaceEnvRequest{WorkspaceId: "ws-other"}
+			},
+		},
+		gatedMethodProbe{
+			name:   "GetWorkspaceTerminalProfiles",
+			method: "GetWorkspaceTerminalProfiles",
+			seed:   func(*testing.T, *Service) {},
+			req: func() proto.Message {
+				return &leapmuxv1.GetWorkspaceTerminalProfilesRequest{WorkspaceId: "ws-other"}
+			},
+		},
+		gatedMethodProbe{
+			name:   "SetWorkspaceTerminalProfiles",
+			method: "SetWorkspaceTerminalProfiles",
+			seed:   func(*testing.T, *Service) {},
+			req: func() proto.Message {
+				return &leapmuxv1.SetWorkspaceTerminalProfilesRequest{WorkspaceId: "ws-other"}
+			},
+		},
 		gatedMethodProbe{
 			name:   "MoveTabWorkspace",
 			method: "MoveTabWorkspace",
@@ -430,6 +700,29 @@ func TestAccessControl_WorkspaceFieldMethods_EmptyWorkspaceID(t *testing.T) {
 			TabId: "tab-1", OrgId: "org-1", FilePath: "/tmp/x",
 		}},
 		{"CleanupWorkspace", &leapmuxv1.CleanupWorkspaceRequest{}},
+		{"GetWorkspaceRetryPolicy", &leapmuxv1.GetWorkspaceRetryPolicyRequest{}},
+		{"SetWorkspaceRetryPolicy", &leapmuxv1.SetWorkspaceRetryPolicyRequest{}},
+		{"GetWorkspaceTurnLimits", &leapmuxv1.GetWorkspaceTurnLimitsRequest{}},
+		{"SetWorkspaceTurnLimits", &leapmuxv1.SetWorkspaceTurnLimitsRequest{}},
+		{"GetWorkspaceAgentDefaults", &leapmuxv1.GetWorkspaceAgentDefaultsRequest{}},
+		{"SetWorkspaceAgentDefaults", &leapmuxv1.SetWorkspaceAgentDefaultsRequest{}},
+		{"GetWorkspaceModelRouting", &leapmuxv1.GetWorkspaceModelRoutingRequest{}},
+		{"SetWorkspaceModelRouting", &leapmuxv1.SetWorkspaceModelRoutingRequest{}},
+		{"SavePlan", &leapmuxv1.SavePlanRequest{}},
+		{"ListPlans", &leapmuxv1.ListPlansRequest{}},
+		{"GetPlanRevision", &leapmuxv1.GetPlanRevisionRequest{}},
+		{"ComparePlanRevisions", &leapmuxv1.ComparePlanRevisionsRequest{}},
+		{"DeletePlan", &leapmuxv1.DeletePlanRequest{}},
+		{"ExportWorkspaceAgents", &leapmuxv1.ExportWorkspaceAgentsRequest{}},
+		{"ImportWorkspaceAgents", &leapmuxv1.ImportWorkspaceAgentsRequest{}},
+		{"GetWorkspaceNotificationConsolidation", &leapmuxv1.GetWorkspaceNotificationConsolidationRequest{}},
+		{"SetWorkspaceNotificationConsolidation", &leapmuxv1.SetWorkspaceNotificationConsolidationRequest{}},
+		{"GetWorkspaceCIPolicy", &leapmuxv1.GetWorkspaceCIPolicyRequest{}},
+		{"SetWorkspaceCIPolicy", &leapmuxv1.SetWorkspaceCIPolicyRequest{}},
+		{"GetWorkspaceEnv", &leapmuxv1.GetWorkspaceEnvRequest{}},
+		{"SetWorkspaceEnv", &leapmuxv1.SetWorkspaceEnvRequest{}},
+		{"GetWorkspaceTerminalProfiles", &leapmuxv1.GetWorkspaceTerminalProfilesRequest{}},
+		{"SetWorkspaceTerminalProfiles", &leapmuxv1.SetWorkspaceTerminalProfilesRequest{}},
 	}
 	for _, tc := range cases {
 		t.Run(tc.method, func(t *testing.T) {
@@ -637,7 +930,7 @@ func TestEveryRegisteredMethodIsClassified(t *testing.T) {
 			ungated = append(ungated, method)
 		}
 	}
-	assert.ElementsMatch(t, []string{"ListAgents", "ListTerminals", "WatchEvents"}, setFilter,
+	assert.ElementsMatch(t, []string{"GraphQLQuery", "ListAgentAnalytics", "ListAgents", "ListAllAgents", "ListModelCredentialUsage", "ListSystemPromptUsage", "ListTerminals", "QueryWorkspaceMetrics", "WatchEvents"}, setFilter,
 		"gateSetFilter additions must be an explicit reviewed decision")
 	assert.ElementsMatch(t, []string{"Ping"}, ungated,
 		"gateNone additions must be an explicit reviewed decision")
@@ -749,6 +1042,30 @@ func TestRequireWorkerOwnerRefusesEmptyIdentities(t *testing.T) {
 	}
 }
 
+// A driver guest's channel carries the owner's user id and is not read-only,
+// so the id match alone would hand an outside guest the owner's machine. The
+// guest flag the Hub sets must keep it off every owner-only method.
+func TestOwnerOnlyFamiliesRefuseDriverGuest(t *testing.T) {
+	_, d, _ := setupTestService(t, withWorkspaces("ws-1"), withGuest())
+
+	for _, tc := range []struct {
+		method string
+		req    proto.Message
+	}{
+		{"GetWorkerSystemInfo", &leapmuxv1.GetWorkerSystemInfoRequest{}},
+		{"ListDirectory", &leapmuxv1.ListDirectoryRequest{Path: "/"}},
+		{"ListAvailableShells", &leapmuxv1.ListAvailableShellsRequest{}},
+	} {
+		t.Run(tc.method, func(t *testing.T) {
+			w := newTestWriter()
+			dispatch(d, tc.method, tc.req, w)
+			require.Len(t, w.errors, 1, "a guest must be refused")
+			assert.Equal(t, codePermissionDenied, w.errors[0].code)
+			assert.Empty(t, w.responses)
+		})
+	}
+}
+
 // ...and the owner keeps unrestricted reach, including outside the home directory.
 // This is deliberate: the worker and its agents already have it.
 func TestMachineScopedFamiliesAllowOwnerOutsideHome(t *testing.T) {
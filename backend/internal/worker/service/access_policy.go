@@ -0,0 +1,147 @@
+package service
+
+import (
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"strings"
+
+	leapmuxv1 "github.com/leapmux/leapmux/generated/proto/leapmux/v1"
+	"github.com/leapmux/leapmux/internal/policy"
+	"github.com/leapmux/leapmux/internal/util/agentlabels"
+	"github.com/leapmux/leapmux/internal/worker/agent"
+	db "github.com/leapmux/leapmux/internal/worker/generated/db"
+)
+
+// SetAccessPolicy adopts the org's access policy. The Hub rejects rules
+// that do not compile; one that still fails here (a Hub with a newer
+// policy environment) is dropped rather than failing the whole policy.
+func (svc *Service) SetAccessPolicy(p *leapmuxv1.AccessPolicy) {
+	compiled, errs := policy.Compile(p)
+	for _, err := range errs {
+		slog.Warn("ignoring invalid access policy rule", "error", err)
+	}
+	svc.accessPolicy.Store(compiled)
+}
+
+// evaluateAccessPolicy fills in what the worker knows about dbAgent and
+// evaluates the org's access policy over in.
+func (svc *Service) evaluateAccessPolicy(dbAgent db.Agent, in policy.Input) policy.Decision {
+	p := svc.accessPolicy.Load()
+	if p.Empty() {
+		return policy.Decision{}
+	}
+	in.UserID = dbAgent.CreatedBy
+	if in.UserID == "" {
+		in.UserID = svc.RegisteredBy().String()
+	}
+	in.WorkspaceID = dbAgent.WorkspaceID
+	in.WorkerID = svc.WorkerID
+	in.WorkerLabels = svc.Labels
+	in.Provider = agentlabels.CLIAlias(dbAgent.AgentProvider)
+	d := p.Evaluate(in)
+	for _, e := range d.Errors {
+		slog.Warn("access policy rule failed to evaluate", "agent_id", dbAgent.ID, "error", e)
+	}
+	return d
+}
+
+// permissionModeDecision evaluates the org's access policy for dbAgent
+// starting in, or switching to, mode.
+func (svc *Service) permissionModeDecision(dbAgent db.Agent, mode string) policy.Decision {
+	if mode == "" {
+		return policy.Decision{}
+	}
+	return svc.evaluateAccessPolicy(dbAgent, policy.Input{
+		Action:         leapmuxv1.AccessPolicyAction_ACCESS_POLICY_ACTION_PERMISSION_MODE,
+		PermissionMode: mode,
+	})
+}
+
+// decideToolUse is the access policy's part of the OutputHandler's
+// control request diverter. A tool permission request a rule allows or
+// denies is answered on the spot, without asking the user, and leaves a
+// tool_use_decided notification in the chat. Questions and plan-mode
+// prompts are for the user and are never decided here.
+func (svc *Service) decideToolUse(agentID, requestID string, payload []byte) bool {
+	if svc.accessPolicy.Load().Empty() {
+		return false
+	}
+	var cr struct {
+		Request struct {
+			Subtype  string          `json:"subtype"`
+			ToolName string          `json:"tool_name"`
+			Input    json.RawMessage `json:"input"`
+		} `json:"request"`
+	}
+	if err := json.Unmarshal(payload, &cr); err != nil || cr.Request.Subtype != "can_use_tool" {
+		return false
+	}
+	tool := cr.Request.ToolName
+	dbAgent, err := svc.getAgentByID(bgCtx(), agentID)
+	if err != nil {
+		slog.Warn("access policy: failed to load agent", "agent_id", agentID, "error", err)
+		return false
+	}
+	provider := agent.ProviderFor(dbAgent.AgentProvider)
+	if tool == "" || tool == agent.ToolNameAskUserQuestion || provider.PlanModeControl(tool) != agent.PlanModeControlNone {
+		return false
+	}
+	var input struct {
+		Command string `json:"command"`
+	}
+	_ = json.Unmarshal(cr.Request.Input, &input)
+
+	d := svc.evaluateAccessPolicy(dbAgent, policy.Input{
+		Action:  leapmuxv1.AccessPolicyAction_ACCESS_POLICY_ACTION_TOOL_USE,
+		Tool:    tool,
+		Command: strings.TrimSpace(input.Command),
+	})
+	var effect string
+	switch {
+	case d.Denied():
+		effect = "deny"
+		err = svc.sendControlDenial(dbAgent.AgentProvider, agentID, requestID, payload, tool, accessPolicyDenialMessage(d))
+	case d.Allowed():
+		// An allowed command the agent terminal policy moves still runs,
+		// just not in the agent's own tool.
+		if svc.divertToAgentTerminal(agentID, requestID, payload) {
+			return true
+		}
+		effect = "allow"
+		err = svc.sendControlApproval(dbAgent.AgentProvider, agentID, requestID, payload, tool, cr.Request.Input)
+	default:
+		return false
+	}
+	if err != nil {
+		// Leave the request to the user rather than stall the turn.
+		slog.Warn("access policy: failed to answer agent", "agent_id", agentID, "request_id", requestID, "error", err)
+		return false
+	}
+	slog.Info("access policy decided tool use",
+		"agent_id", agentID, "tool", tool, "effect", effect, "rule", d.Rule)
+	svc.Output.PersistLeapMuxNotification(agentID, dbAgent.AgentProvider, agent.NotificationContent(agent.ToolUseDecidedPayload{
+		Tool:    tool,
+		Effect:  effect,
+		Rule:    d.Rule,
+		Message: d.Message,
+	}))
+	return true
+}
+
+// accessPolicyDenialMessage is what the agent is told when a rule denies
+// its tool use.
+func accessPolicyDenialMessage(d policy.Decision) string {
+	msg := fmt.Sprintf("The org's access policy rule %q denied this tool use.", d.Rule)
+	if d.Message != "" {
+		msg += " " + d.Message
+	}
+	return msg
+}
+
+// divertControlRequest is the OutputHandler's control request diverter:
+// the access policy decides first, then the agent terminal policy may move
+// a command into a terminal.
+func (svc *Service) divertControlRequest(agentID, requestID string, payload []byte) bool {
+	return svc.decideToolUse(agentID, requestID, payload) || svc.divertToAgentTerminal(agentID, requestID, payload)
+}
@@ -0,0 +1,150 @@
+package service
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	leapmuxv1 "github.com/leapmux/leapmux/generated/proto/leapmux/v1"
+	"github.com/leapmux/leapmux/internal/worker/agent"
+)
+
+const (
+	policyToolUse        = leapmuxv1.AccessPolicyAction_ACCESS_POLICY_ACTION_TOOL_USE
+	policyPermissionMode = leapmuxv1.AccessPolicyAction_ACCESS_POLICY_ACTION_PERMISSION_MODE
+	policyAllow          = leapmuxv1.AccessPolicyEffect_ACCESS_POLICY_EFFECT_ALLOW
+	policyDeny           = leapmuxv1.AccessPolicyEffect_ACCESS_POLICY_EFFECT_DENY
+)
+
+// startPolicyAgent seeds agent-1 and registers a mock process for it, so
+// the policy's answers have somewhere to go.
+func startPolicyAgent(t *testing.T, svc *Service) {
+	t.Helper()
+	row := seedGuardedAgent(t, svc, agent.PermissionModeDefault)
+	_, err := svc.Agents.MockStartAgent(context.Background(), agent.Options{AgentID: row.ID, WorkingDir: row.WorkingDir},
+		svc.Output.NewSink(row.ID, row.AgentProvider))
+	require.NoError(t, err)
+	t.Cleanup(func() { svc.Agents.StopAgent(row.ID) })
+}
+
+func TestDecideToolUse(t *testing.T) {
+	tests := []struct {
+		name    string
+		rule    *leapmuxv1.AccessPolicyRule
+		command string
+		decided bool
+		effect  string
+	}{
+		{
+			name:    "deny",
+			rule:    &leapmuxv1.AccessPolicyRule{Name: "no-rm", Actions: []leapmuxv1.AccessPolicyAction{policyToolUse}, Condition: `command.startsWith("rm ")`, Effect: policyDeny, Message: "Ask first."},
+			command: "rm -rf build",
+			decided: true,
+			effect:  "deny",
+		},
+		{
+			name:    "allow",
+			rule:    &leapmuxv1.AccessPolicyRule{Name: "ls", Condition: `tool == "Bash" && command == "ls"`, Effect: policyAllow},
+			command: "ls",
+			decided: true,
+			effect:  "allow",
+		},
+		{
+			name:    "no match",
+			rule:    &leapmuxv1.AccessPolicyRule{Name: "no-rm", Condition: `command.startsWith("rm ")`, Effect: policyDeny},
+			command: "ls",
+		},
+		{
+			name:    "other action",
+			rule:    &leapmuxv1.AccessPolicyRule{Name: "modes", Actions: []leapmuxv1.AccessPolicyAction{policyPermissionMode}, Condition: `true`, Effect: policyDeny},
+			command: "ls",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			svc, _, _ := setupTestService(t, withWorkspaces("ws-1"))
+			startPolicyAgent(t, svc)
+			svc.SetAccessPolicy(&leapmuxv1.AccessPolicy{Rules: []*leapmuxv1.AccessPolicyRule{tt.rule}})
+
+			assert.Equal(t, tt.decided, svc.decideToolUse("agent-1", "req-1", bashPermissionRequest(t, tt.command)))
+
+			decided := findNotificationsByType(readAllNotifications(t, svc.Queries, "agent-1"), agent.NotificationTypeToolUseDecided)
+			if !tt.decided {
+				assert.Empty(t, decided)
+				return
+			}
+			require.Len(t, decided, 1)
+			assert.Equal(t, "Bash", decided[0]["tool"])
+			assert.Equal(t, tt.effect, decided[0]["effect"])
+			assert.Equal(t, tt.rule.GetName(), decided[0]["rule"])
+		})
+	}
+}
+
+func TestDecideToolUse_LeavesQuestionsToTheUser(t *testing.T) {
+	svc, _, _ := setupTestService(t, withWorkspaces("ws-1"))
+	startPolicyAgent(t, svc)
+	svc.SetAccessPolicy(&leapmuxv1.AccessPolicy{Rules: []*leapmuxv1.AccessPolicyRule{
+		{Name: "everything", Condition: `true`, Effect: policyDeny},
+	}})
+
+	assert.False(t, svc.decideToolUse("agent-1", "req-1",
+		[]byte(`{"request":{"subtype":"can_use_tool","tool_name":"AskUserQuestion","input":{}}}`)))
+	assert.False(t, svc.decideToolUse("agent-1", "req-1",
+		[]byte(`{"request":{"subtype":"can_use_tool","tool_name":"ExitPlanMode","input":{}}}`)))
+}
+
+func TestUpdateAgentSettings_DropsPolicyDeniedPermissionMode(t *testing.T) {
+	svc, d, w := setupTestService(t, withWorkspaces("ws-1"))
+	seedGuardedAgent(t, svc, agent.PermissionModeDefault)
+	svc.SetAccessPolicy(&leapmuxv1.AccessPolicy{Rules: []*leapmuxv1.AccessPolicyRule{
+		{Name: "no-accept-edits", Condition: `permission_mode == "acceptEdits"`, Effect: policyDeny, Message: "Review edits."},
+	}})
+
+	dispatch(d, "UpdateAgentSettings", &leapmuxv1.UpdateAgentSettingsRequest{
+		AgentId: "agent-1",
+		Settings: &leapmuxv1.AgentSettings{Options: map[string]string{
+			agent.OptionIDPermissionMode: agent.PermissionModeAcceptEdits,
+		}},
+	}, w)
+
+	require.Empty(t, w.errors)
+	assert.Equal(t, agent.PermissionModeDefault, storedPermissionMode(t, svc))
+	blocked := findNotificationsByType(readAllNotifications(t, svc.Queries, "agent-1"), agent.NotificationTypePermissionModeBlocked)
+	require.Len(t, blocked, 1)
+	assert.Equal(t, "no-accept-edits", blocked[0]["rule"])
+	assert.Equal(t, "Review edits.", blocked[0]["message"])
+}
+
+func TestSetAgentPermissionMode_PolicyKeepsCurrentMode(t *testing.T) {
+	svc, _, _ := setupTestService(t, withWorkspaces("ws-1"))
+	dbAgent := seedGuardedAgent(t, svc, agent.PermissionModePlan)
+	svc.SetAccessPolicy(&leapmuxv1.AccessPolicy{Rules: []*leapmuxv1.AccessPolicyRule{
+		{Name: "no-accept-edits", Condition: `permission_mode == "acceptEdits"`, Effect: policyDeny},
+	}})
+
+	svc.setAgentPermissionModeWithAgent(dbAgent, agent.PermissionModeAcceptEdits)
+
+	assert.Equal(t, agent.PermissionModePlan, storedPermissionMode(t, svc))
+}
+
+func TestOpenAgent_RejectsPolicyDeniedPermissionMode(t *testing.T) {
+	svc, d, w := setupTestService(t, withWorkspaces("ws-1"))
+	defer drainAllInFlight(svc)
+	svc.SetAccessPolicy(&leapmuxv1.AccessPolicy{Rules: []*leapmuxv1.AccessPolicyRule{
+		{Name: "no-bypass", Condition: `permission_mode == "bypassPermissions"`, Effect: policyDeny},
+	}})
+
+	dispatch(d, "OpenAgent", &leapmuxv1.OpenAgentRequest{
+		WorkspaceId: "ws-1",
+		WorkingDir:  t.TempDir(),
+		Options:     map[string]string{agent.OptionIDPermissionMode: "bypassPermissions"},
+	}, w)
+
+	require.Len(t, w.errors, 1)
+	assert.Equal(t, codePermissionDenied, w.errors[0].code)
+	assert.Contains(t, w.errors[0].message, "no-bypass")
+	assert.Zero(t, countAgentRows(t, svc))
+}
@@ -13,6 +13,7 @@ import (
 	"unicode/utf8"
 
 	leapmuxv1 "github.com/leapmux/leapmux/generated/proto/leapmux/v1"
+	"github.com/leapmux/leapmux/internal/errcode"
 	"github.com/leapmux/leapmux/internal/util/agentlabels"
 	"github.com/leapmux/leapmux/internal/util/id"
 	"github.com/leapmux/leapmux/internal/util/msgcodec"
@@ -28,6 +29,7 @@ import (
 	"github.com/leapmux/leapmux/internal/worker/terminal"
 	"github.com/leapmux/leapmux/internal/worker/todoevents"
 	"github.com/leapmux/leapmux/util/validate"
+	"google.golang.org/grpc/codes"
 	"google.golang.org/protobuf/proto"
 )
 
@@ -43,22 +45,42 @@ func (svc *Service) agentLoginShell() bool {
 
 // baseAgentOptions builds an agent.Options pre-filled with the per-agent identity
 // (agentID, workingDir, provider) and the shared launch-environment block -- timeouts,
-// shell, and home dir -- that every launch / restart / clear-context / relaunch path
-// repeats verbatim. Callers overlay the per-site fields (ResumeSessionID, Options,
+// shell, home dir, the agent's library system prompt and model credential -- that every launch / restart /
+// clear-context / relaunch path repeats verbatim. Callers overlay the per-site fields (ResumeSessionID, Options,
 // ExtraEnv) on the returned value, so a new launch-environment field or a renamed
 // timeout accessor is a one-line change here instead of five parallel edits that one
 // path would eventually drift on.
 func (svc *Service) baseAgentOptions(agentID, workingDir string, provider leapmuxv1.AgentProvider) agent.Options {
 	return agent.Options{
-		AgentID:        agentID,
-		WorkingDir:     workingDir,
-		AgentProvider:  provider,
-		StartupTimeout: svc.agentStartupTimeout(),
-		APITimeout:     svc.agentAPITimeout(),
-		Shell:          svc.agentShell(),
-		LoginShell:     svc.agentLoginShell(),
-		HomeDir:        svc.HomeDir,
+		AgentID:          agentID,
+		WorkingDir:       workingDir,
+		AgentProvider:    provider,
+		StartupTimeout:   svc.agentStartupTimeout(),
+		APITimeout:       svc.agentAPITimeout(),
+		Shell:            svc.agentShell(),
+		LoginShell:       svc.agentLoginShell(),
+		HomeDir:          svc.HomeDir,
+		CaptureDir:       svc.CaptureAgentOutput,
+		OutputSchemaMode: svc.ClaudeOutputSchema,
+		SystemPrompt:     svc.agentSystemPrompt(agentID),
+		Env:              svc.agentEnv(agentID),
+		CredentialEnv:    svc.agentCredentialEnv(agentID, provider),
+		OutputProcessors: svc.outputProcessors,
+	}
+}
+
+// agentSystemPrompt returns the library system prompt the agent was opened
+// with, or "" when it has none. A failed read launches without it rather than
+// failing the launch.
+func (svc *Service) agentSystemPrompt(agentID string) string {
+	row, err := svc.Queries.GetAgentSystemPrompt(bgCtx(), agentID)
+	if err != nil {
+		if !errors.Is(err, sql.ErrNoRows) {
+			slog.Warn("failed to read agent system prompt", "agent_id", agentID, "error", err)
+		}
+		return ""
 	}
+	return row.Content
 }
 
 // registerAgentHandlers registers all agent-related inner RPC handlers.
@@ -70,6 +92,21 @@ func registerAgentHandlers(d registrar, svc *Service) {
 				return
 			}
 
+			// A retry of an OpenAgent that already ran (the client lost
+			// the response) gets the original agent rather than a second
+			// one with its own worktree. Claimed ahead of the git-mode
+			// validation, which the original's worktree could now fail.
+			claimKey, ok := svc.claimOpenAgentKey(ctx, userID, r, sender)
+			if !ok {
+				return
+			}
+			opened := false
+			defer func() {
+				if !opened {
+					svc.openAgentKeys.Release(claimKey)
+				}
+			}()
+
 			title, err := sanitizeOptionalTitle(r.GetTitle())
 			if err != nil {
 				sendInvalidArgument(sender, err.Error())
@@ -100,6 +137,12 @@ func registerAgentHandlers(d registrar, svc *Service) {
 				sendValidationError(sender, gmErr)
 				return
 			}
+			if plan.Mode == gitModeCreateWorktree {
+				if err := svc.checkDiskRoom(r.GetWorkspaceId()); err != nil {
+					sendResourceExhausted(sender, err)
+					return
+				}
+			}
 
 			// Resolve default model based on agent provider.
 			agentProvider := r.GetAgentProvider()
@@ -107,12 +150,27 @@ func registerAgentHandlers(d registrar, svc *Service) {
 				agentProvider = leapmuxv1.AgentProvider_AGENT_PROVIDER_CLAUDE_CODE
 			}
 			// Resolve the initial option selections: the client's requested values
-			// (model/effort/permissionMode/provider options), filled with provider
-			// defaults for any missing well-known and provider-specific ids.
+			// (model/effort/permissionMode/provider options), then the org's,
+			// workspace's, and caller's agent defaults for model/effort/permissionMode
+			// (see applyAgentDefaults), then provider defaults for any missing
+			// well-known and provider-specific ids.
 			requested := mergeOptions(nil, r.GetOptions())
+			if err := svc.permissionGuardrails().checkLaunchPermissionMode(requested); err != nil {
+				sendPermissionDenied(sender, err.Error())
+				return
+			}
+			requested = svc.applyAgentDefaults(ctx, r.GetWorkspaceId(), agentProvider, r.GetUserDefaults(), requested)
 			options := resolveProviderDefaults(requested, agentProvider)
 			if options[agent.OptionIDPermissionMode] == "" {
-				options[agent.OptionIDPermissionMode] = agent.PermissionModeOrDefault(agentProvider, "")
+				options[agent.OptionIDPermissionMode] = svc.permissionGuardrails().defaultMode(agentProvider)
+			}
+			if d := svc.permissionModeDecision(db.Agent{
+				WorkspaceID:   r.GetWorkspaceId(),
+				AgentProvider: agentProvider,
+				CreatedBy:     userID.String(),
+			}, options[agent.OptionIDPermissionMode]); d.Denied() {
+				sendPermissionDenied(sender, fmt.Sprintf("permission mode %q is denied by access policy rule %q", options[agent.OptionIDPermissionMode], d.Rule))
+				return
 			}
 			// Reject a spawn whose EXPLICITLY-requested permission mode isn't valid for the provider, so a
 			// typo'd --permission-mode fails fast with a clear error instead of reaching the provider and
@@ -124,6 +182,21 @@ func registerAgentHandlers(d registrar, svc *Service) {
 				sendInvalidArgument(sender, err.Error())
 				return
 			}
+			if err := validateSystemPromptRef(agentProvider, r.GetSystemPromptRef()); err != nil {
+				sendInvalidArgument(sender, err.Error())
+				return
+			}
+			if err := validateEnvVars(r.GetEnv()); err != nil {
+				sendInvalidArgument(sender, err.Error())
+				return
+			}
+
+			modelCred, err := svc.pickModelCredential(ctx, sender.ChannelID(), r.GetWorkspaceId(), agentProvider)
+			if err != nil {
+				slog.Error("failed to resolve model credential", "workspace_id", r.GetWorkspaceId(), "error", err)
+				sendInternalError(sender, "failed to resolve model credential")
+				return
+			}
 
 			// Track whether this agent was created via session resume.
 			resumed := ptrconv.BoolToInt64(r.GetAgentSessionId() != "")
@@ -145,12 +218,39 @@ func registerAgentHandlers(d registrar, svc *Service) {
 				Options:       marshalOptions(options),
 				AgentProvider: agentProvider,
 				Resumed:       resumed,
+				CreatedBy:     userID.String(),
 			}); err != nil {
 				slog.Error("failed to create agent", "error", err)
 				sendInternalError(sender, "failed to create agent")
 				return
 			}
 
+			if ref := r.GetSystemPromptRef(); ref != nil {
+				if err := svc.Queries.CreateAgentSystemPrompt(bgCtx(), db.CreateAgentSystemPromptParams{
+					AgentID:       agentID,
+					PromptID:      ref.GetId(),
+					PromptVersion: int64(ref.GetVersion()),
+					Content:       ref.GetContent(),
+				}); err != nil {
+					slog.Error("failed to store agent system prompt", "agent_id", agentID, "error", err)
+					sendInternalError(sender, "failed to create agent")
+					return
+				}
+			}
+			if err := svc.storeAgentEnv(bgCtx(), agentID, r.GetEnv()); err != nil {
+				slog.Error("failed to store agent env", "agent_id", agentID, "error", err)
+				sendInternalError(sender, "failed to create agent")
+				return
+			}
+
+			if modelCred != nil {
+				if err := svc.installModelCredential(bgCtx(), agentID, modelCred); err != nil {
+					slog.Error("failed to store agent model credential", "agent_id", agentID, "error", err)
+					sendInternalError(sender, "failed to create agent")
+					return
+				}
+			}
+
 			dbAgent, err := svc.getAgentByID(bgCtx(), agentID)
 			if err != nil {
 				slog.Error("failed to fetch created agent", "error", err)
@@ -225,6 +325,8 @@ func registerAgentHandlers(d registrar, svc *Service) {
 			agentOpts.Options = options
 			agentOpts.ExtraEnv = remoteEnvs
 
+			svc.openAgentKeys.Complete(claimKey, agentID)
+			opened = true
 			agent.TraceStartupPhase(agentID, "before_response")
 			sendProtoResponse(sender, &leapmuxv1.OpenAgentResponse{
 				Agent: svc.agentToProto(&dbAgent, false, nil),
@@ -263,6 +365,7 @@ func registerAgentHandlers(d registrar, svc *Service) {
 				},
 				func() error { return svc.Queries.CloseAgent(bgCtx(), agentID) },
 			)
+			svc.closeAgentTerminals(agentID)
 			sendProtoResponse(sender, &leapmuxv1.CloseAgentResponse{Result: result})
 		})
 
@@ -276,37 +379,31 @@ func registerAgentHandlers(d registrar, svc *Service) {
 		func(_ context.Context, _ userid.UserID, r *leapmuxv1.SendAgentMessageRequest, dbAgent db.Agent, sender channel.ResponseWriter) {
 			agentID := r.GetAgentId()
 
-			// Reject sends only on permanent startup failure — STARTING
-			// messages are queued on the frontend and dispatched on the
-			// status transition to ACTIVE. A STARTING-state send gate on
-			// the server would race with the ACTIVE broadcast that fires
-			// from the output sink before runAgentStartup's bookkeeping
-			// completes; ensureAgentRunning already restarts crashed
-			// subprocesses on demand. Also reject when the persisted
-			// startup_error is set (covers worker restart: the in-memory
-			// registry was wiped but the DB remembers the failure).
-			if status, _, _, ok := svc.AgentStartup.status(agentID); ok && status == leapmuxv1.AgentStatus_AGENT_STATUS_STARTUP_FAILED {
-				sendFailedPrecondition(sender, "agent failed to start; open a new agent")
+			if svc.rejectFailedStartup(sender, agentID, dbAgent) {
 				return
 			}
-			if dbAgent.StartupError != "" && !svc.Agents.HasAgent(agentID) {
-				sendFailedPrecondition(sender, "agent failed to start; open a new agent")
+
+			content, err := svc.expandSnippet(sender.ChannelID(), &dbAgent, r.GetContent())
+			if err != nil {
+				var snipErr snippetError
+				if errors.As(err, &snipErr) {
+					sendInvalidArgument(sender, snipErr.Error())
+					return
+				}
+				slog.Error("failed to look up snippet", "agent_id", agentID, "error", err)
+				sendInternalError(sender, "failed to look up snippet")
 				return
 			}
-
-			content := r.GetContent()
 			attachments := r.GetAttachments()
 
 			// Validate text: at least 1 character when no attachments,
 			// or allow empty text when attachments are present.
-			trimmed := strings.TrimSpace(content)
-			if len(attachments) == 0 && utf8.RuneCountInString(trimmed) < 1 {
+			if len(attachments) == 0 && utf8.RuneCountInString(strings.TrimSpace(content)) < 1 {
 				sendInvalidArgument(sender, "message must be at least 1 character")
 				return
 			}
 
 			// Validate total attachment size (max 10 MB).
-			const maxAttachmentSize = 10 * 1024 * 1024
 			var totalSize int
 			for _, a := range attachments {
 				totalSize += len(a.GetData())
@@ -316,163 +413,16 @@ func registerAgentHandlers(d registrar, svc *Service) {
 				return
 			}
 
-			// Pre-resolve the resume session ID BEFORE persisting the user
-			// message. HasUserMessages must run before the current message is
-			// written; otherwise the just-persisted message is counted as a
-			// prior conversation and --resume is used for a session that never
-			// had any messages (e.g. after an app restart on an idle tab).
-			resumeSessionID := svc.resolveResumeSessionID(agentID, dbAgent.AgentSessionID, dbAgent.Resumed)
-
-			attachments, err := agent.NormalizeAttachmentsForProvider(
-				leapmuxv1.AgentProvider(dbAgent.AgentProvider),
-				attachments,
-			)
-			if err != nil {
-				sendInvalidArgument(sender, err.Error())
-				return
-			}
-
-			messageID := id.Generate()
-			now := nowMillis()
-
-			// Store user content as a plain JSON object with a "content" field,
-			// which the frontend classifies as user_content and renders as markdown.
-			// When attachments are present, include their metadata (filename + mime_type)
-			// but not the raw binary data (too large for DB storage).
-			var payload interface{}
-			if len(attachments) > 0 {
-				type attachmentMeta struct {
-					Filename string `json:"filename"`
-					MimeType string `json:"mime_type"`
-				}
-				meta := make([]attachmentMeta, len(attachments))
-				for i, a := range attachments {
-					meta[i] = attachmentMeta{Filename: a.GetFilename(), MimeType: a.GetMimeType()}
-				}
-				payload = map[string]interface{}{"content": content, "attachments": meta}
-			} else {
-				payload = map[string]string{"content": content}
-			}
-			// A marshal failure must NOT fall through: innerJSON would stay nil and we'd
-			// compress + persist + broadcast an empty-content row (while still handing the
-			// agent the real content), silently corrupting the visible history. Fail the
-			// RPC instead so the caller can retry, mirroring the persist-failure path below.
-			innerJSON, err := json.Marshal(payload)
-			if err != nil {
-				slog.Error("failed to encode user message", "agent_id", agentID, "error", err)
-				sendInternalError(sender, "failed to encode message")
-				return
-			}
-			compressed, compressionType := msgcodec.Compress(innerJSON)
-
-			// Capture currently-active spans so the user message renders with
-			// passthrough vertical bars instead of breaking the column.
-			spanLines := svc.Output.snapshotPassthroughSpanLines(agentID)
-
-			// Persist the user message. mark_type=USER_MESSAGE so the scroll rail
-			// draws a jump dot for every message the human actually typed and sent.
-			seq, err := createMessageRow(bgCtx(), svc.Queries, db.CreateMessageParams{
-				ID:                 messageID,
-				AgentID:            agentID,
-				Source:             leapmuxv1.MessageSource_MESSAGE_SOURCE_USER,
-				Content:            compressed,
-				ContentCompression: compressionType,
-				Depth:              0,
-				SpanID:             "",
-				ParentSpanID:       "",
-				SpanLines:          spanLines,
-				SpanColor:          0,
-				AgentProvider:      dbAgent.AgentProvider,
-				MarkType:           leapmuxv1.MarkType_MARK_TYPE_USER_MESSAGE,
-				CreatedAt:          sqltime.NewSQLiteTime(now),
-			})
-			if err != nil {
-				slog.Error("failed to persist message", "agent_id", agentID, "error", err)
-				sendInternalError(sender, "failed to persist message")
-				return
-			}
-
-			// Check for leapmux-level slash commands (e.g. /clear) that
-			// Claude Code does not handle natively.
-			isSlashClear := trimmed == "/clear" || trimmed == "/reset" || trimmed == "/new"
-
-			userMsg := &leapmuxv1.AgentChatMessage{
-				Id:                 messageID,
-				Source:             leapmuxv1.MessageSource_MESSAGE_SOURCE_USER,
-				Content:            compressed,
-				ContentCompression: compressionType,
-				Seq:                seq,
-				AgentProvider:      dbAgent.AgentProvider,
-				CreatedAt:          timefmt.Format(now),
-				Depth:              0,
-				SpanLines:          spanLines,
-				MarkType:           leapmuxv1.MarkType_MARK_TYPE_USER_MESSAGE,
-			}
-
-			// For /clear, broadcast the user message before restarting so live
-			// watchers never see context_cleared ahead of the triggering command.
-			if isSlashClear {
-				svc.Watchers.BroadcastAgentEvent(agentID, &leapmuxv1.AgentEvent{
-					AgentId: agentID,
-					Event: &leapmuxv1.AgentEvent_AgentMessage{
-						AgentMessage: userMsg,
-					},
-				})
-			}
-
-			// Attempt to send the message to the agent process (unless it's
-			// a command that leapmux handles itself).
-			var deliveryError string
-			if isSlashClear {
-				// /clear: restart the agent with a fresh context.
-				svc.handleClearContext(agentID)
-			} else if !svc.Agents.HasAgent(agentID) {
-				// Agent is not running — try to auto-start it (e.g. after worker restart).
-				if startErr := svc.ensureAgentRunning(agentID, &resumeSessionID); startErr != nil {
-					deliveryError = "agent is not running"
-				} else if sendErr := svc.Agents.SendInput(agentID, content, attachments); sendErr != nil {
-					slog.Error("failed to send input to agent after auto-start", "agent_id", agentID, "error", sendErr)
-					deliveryError = sendErr.Error()
-				}
-			} else if sendErr := svc.Agents.SendInput(agentID, content, attachments); sendErr != nil {
-				slog.Error("failed to send input to agent", "agent_id", agentID, "error", sendErr)
-				deliveryError = sendErr.Error()
-			}
-			if deliveryError != "" {
-				_ = svc.Queries.SetMessageDeliveryError(bgCtx(), db.SetMessageDeliveryErrorParams{
-					DeliveryError: deliveryError,
-					ID:            messageID,
-					AgentID:       agentID,
-				})
-			}
-
-			sendProtoResponse(sender, &leapmuxv1.SendAgentMessageResponse{})
-
-			// Broadcast the user message to all watchers so it appears in
-			// every connected frontend's chat view.
-			if !isSlashClear {
-				userMsg.DeliveryError = deliveryError
-				svc.Watchers.BroadcastAgentEvent(agentID, &leapmuxv1.AgentEvent{
-					AgentId: agentID,
-					Event: &leapmuxv1.AgentEvent_AgentMessage{
-						AgentMessage: userMsg,
-					},
-				})
-			}
-
-			// Broadcast delivery error separately (frontend uses both events).
-			if deliveryError != "" {
-				svc.Watchers.BroadcastAgentEvent(agentID, &leapmuxv1.AgentEvent{
-					AgentId: agentID,
-					Event: &leapmuxv1.AgentEvent_MessageError{
-						MessageError: &leapmuxv1.AgentMessageError{
-							AgentId:   agentID,
-							MessageId: messageID,
-							Error:     deliveryError,
-						},
-					},
+			svc.submitUserMessage(sender, dbAgent, userInput{
+				content:        content,
+				attachments:    attachments,
+				idempotencyKey: r.GetIdempotencyKey(),
+			}, func(messageID string, duplicate bool) {
+				sendProtoResponse(sender, &leapmuxv1.SendAgentMessageResponse{
+					DuplicateSuppressed: duplicate,
+					MessageId:           messageID,
 				})
-			}
+			})
 		})
 
 	// SendAgentRawMessage forwards a provider-shaped raw message (Codex
@@ -480,7 +430,7 @@ func registerAgentHandlers(d registrar, svc *Service) {
 	// synthetic-message persistence must complete past a client
 	// disconnect; dispatcher ctx is intentionally not threaded.
 	registerAgentGated(d, "SendAgentRawMessage",
-		func(_ context.Context, _ userid.UserID, r *leapmuxv1.SendAgentRawMessageRequest, dbAgent db.Agent, sender channel.ResponseWriter) {
+		func(_ context.Context, userID userid.UserID, r *leapmuxv1.SendAgentRawMessageRequest, dbAgent db.Agent, sender channel.ResponseWriter) {
 			agentID := r.GetAgentId()
 			content := r.GetContent()
 			if notice := agent.ProviderFor(dbAgent.AgentProvider).SyntheticInterruptNotice(); notice != "" && agent.IsInterruptRequest(dbAgent.AgentProvider, content) {
@@ -488,6 +438,12 @@ func registerAgentHandlers(d registrar, svc *Service) {
 				// draws no rail dot.
 				svc.persistSyntheticUserMessage(agentID, dbAgent.AgentProvider, notice)
 			}
+			if agent.IsInterruptRequest(dbAgent.AgentProvider, content) {
+				// The raw interrupt frame predates InterruptAgent; it is
+				// always the user's.
+				slog.Info("agent interrupted",
+					"agent_id", agentID, "user_id", userID, "reason", interruptReasonNames[leapmuxv1.InterruptReason_INTERRUPT_REASON_USER], "detail", "raw interrupt frame")
+			}
 
 			svc.handleControlRequestMessage(agentID, dbAgent.AgentProvider, content)
 			sendProtoResponse(sender, &leapmuxv1.SendAgentRawMessageResponse{})
@@ -572,6 +528,11 @@ func registerAgentHandlers(d registrar, svc *Service) {
 			// unit tested without a DB; this handler only runs the selected query and
 			// plumbs the result.
 			plan := resolveMessagePage(r.GetAnchor(), r.GetCursorSeq(), int64(r.GetLimit()))
+			filter, filterErr := resolveMessageFilter(r.GetFilter())
+			if filterErr != nil {
+				sendInvalidArgument(sender, filterErr.Error())
+				return
+			}
 
 			// Only ship the to-do list on the cold-start LATEST page — scroll
 			// pagination requests don't need to re-fetch it (the client
@@ -600,7 +561,13 @@ func registerAgentHandlers(d registrar, svc *Service) {
 			}
 
 			// Fetch one extra (plan.limit+1) so a full page reveals has_more below.
-			dbMessages, queryErr := svc.fetchMessagePageRows(ctx, agentID, plan.mode, plan.bound, plan.limit+1)
+			var dbMessages []db.Message
+			var queryErr error
+			if filter != nil {
+				dbMessages, queryErr = svc.fetchFilteredMessagePageRows(ctx, agentID, plan, filter, plan.limit+1)
+			} else {
+				dbMessages, queryErr = svc.fetchMessagePageRows(ctx, agentID, plan.mode, plan.bound, plan.limit+1)
+			}
 			if queryErr != nil {
 				slog.Error("failed to list messages", "agent_id", agentID, "error", queryErr)
 				sendInternalError(sender, "failed to list messages")
@@ -622,6 +589,10 @@ func registerAgentHandlers(d registrar, svc *Service) {
 			for i := range dbMessages {
 				protoMessages = append(protoMessages, messageToProto(&dbMessages[i]))
 			}
+			svc.attachMessageUsage(ctx, agentID, protoMessages)
+			if svc.channelReadOnly(sender.ChannelID()) {
+				protoMessages = withoutReadOnlyWithheld(protoMessages)
+			}
 
 			// The authoritative live-tail seq, so the --follow CLI can resolve a resume
 			// point even when this page is empty (never inferring a spurious seq 0 from an
@@ -679,7 +650,13 @@ func registerAgentHandlers(d registrar, svc *Service) {
 				return
 			}
 
-			sendProtoResponse(sender, &leapmuxv1.GetAgentMessageResponse{Message: messageToProto(&row)})
+			msg := messageToProto(&row)
+			svc.attachMessageUsage(ctx, agentID, []*leapmuxv1.AgentChatMessage{msg})
+			if svc.channelReadOnly(sender.ChannelID()) && readOnlyWithholdsMessage(msg) {
+				sendProtoResponse(sender, &leapmuxv1.GetAgentMessageResponse{})
+				return
+			}
+			sendProtoResponse(sender, &leapmuxv1.GetAgentMessageResponse{Message: msg})
 		})
 
 	// ListMessageMarks returns the seqs of every marked message (scroll-rail jump
@@ -744,6 +721,117 @@ func registerAgentHandlers(d registrar, svc *Service) {
 			})
 		})
 
+	// ListAgentMessagesInRange serves an inclusive seq window for a virtualized
+	// list that jumps straight to the scrolled-to position. Same read path as
+	// ListAgentMessages (page cap, usage, read-only filtering), minus the to-do
+	// snapshot and latest_seq, which GetAgentMessageStats and the cold-start
+	// page already provide.
+	registerAgentGated(d, "ListAgentMessagesInRange",
+		func(ctx context.Context, _ userid.UserID, r *leapmuxv1.ListAgentMessagesInRangeRequest, agentRow db.Agent, sender channel.ResponseWriter) {
+			agentID := r.GetAgentId()
+			if r.GetToSeq() < r.GetFromSeq() {
+				sendInvalidArgument(sender, "to_seq must not be below from_seq")
+				return
+			}
+			if agentRow.ClosedAt.Valid {
+				sendProtoResponse(sender, &leapmuxv1.ListAgentMessagesInRangeResponse{})
+				return
+			}
+
+			limit := int64(r.GetLimit())
+			if limit <= 0 || limit > maxMessagePageLimit {
+				limit = maxMessagePageLimit
+			}
+			dbMessages, err := svc.Queries.ListMessagesByAgentIDInSeqRange(ctx, db.ListMessagesByAgentIDInSeqRangeParams{
+				AgentID:  agentID,
+				FromSeq:  r.GetFromSeq(),
+				ToSeq:    r.GetToSeq(),
+				RowLimit: limit + 1,
+			})
+			if err != nil {
+				slog.Error("failed to list messages in range", "agent_id", agentID, "error", err)
+				sendInternalError(sender, "failed to list messages")
+				return
+			}
+			hasMore := int64(len(dbMessages)) > limit
+			if hasMore {
+				dbMessages = dbMessages[:limit]
+			}
+
+			protoMessages := make([]*leapmuxv1.AgentChatMessage, 0, len(dbMessages))
+			for i := range dbMessages {
+				protoMessages = append(protoMessages, messageToProto(&dbMessages[i]))
+			}
+			svc.attachMessageUsage(ctx, agentID, protoMessages)
+			if svc.channelReadOnly(sender.ChannelID()) {
+				protoMessages = withoutReadOnlyWithheld(protoMessages)
+			}
+			sendProtoResponse(sender, &leapmuxv1.ListAgentMessagesInRangeResponse{
+				Messages: protoMessages,
+				HasMore:  hasMore,
+			})
+		})
+
+	// GetAgentMessageStats sizes an agent's history for a virtualized list:
+	// counts, seq range and stored bytes, grouped by source. Plain indexed SQL
+	// over one read transaction so the counts and the range agree.
+	registerAgentGated(d, "GetAgentMessageStats",
+		func(ctx context.Context, _ userid.UserID, r *leapmuxv1.GetAgentMessageStatsRequest, agentRow db.Agent, sender channel.ResponseWriter) {
+			agentID := r.GetAgentId()
+
+			// A closed agent serves no history (mirrors ListAgentMessages).
+			if agentRow.ClosedAt.Valid {
+				sendProtoResponse(sender, &leapmuxv1.GetAgentMessageStatsResponse{})
+				return
+			}
+
+			tx, txErr := svc.DB.BeginTx(ctx, &sql.TxOptions{ReadOnly: true})
+			if txErr != nil {
+				slog.Error("failed to start message stats read transaction", "agent_id", agentID, "error", txErr)
+				sendInternalError(sender, "failed to get message stats")
+				return
+			}
+			queries := svc.Queries.WithTx(tx)
+			rows, statsErr := queries.ListMessageStatsByAgentID(ctx, agentID)
+			if statsErr != nil {
+				_ = tx.Rollback()
+				slog.Error("failed to get message stats", "agent_id", agentID, "error", statsErr)
+				sendInternalError(sender, "failed to get message stats")
+				return
+			}
+			seqRange, rangeErr := queries.GetSeqRangeByAgentID(ctx, agentID)
+			if rangeErr != nil {
+				_ = tx.Rollback()
+				slog.Error("failed to read seq range for message stats", "agent_id", agentID, "error", rangeErr)
+				sendInternalError(sender, "failed to get message stats")
+				return
+			}
+			if commitErr := tx.Commit(); commitErr != nil {
+				slog.Error("failed to finish message stats read transaction", "agent_id", agentID, "error", commitErr)
+				sendInternalError(sender, "failed to get message stats")
+				return
+			}
+
+			// A read-only channel never sees the withheld rows, so they must
+			// not take up room in its scroll extent either. The seq range may
+			// still end on one; seqs have gaps anyway.
+			readOnly := svc.channelReadOnly(sender.ChannelID())
+			resp := &leapmuxv1.GetAgentMessageStatsResponse{MinSeq: seqRange.MinSeq, MaxSeq: seqRange.MaxSeq}
+			for _, row := range rows {
+				if readOnly && readOnlyWithholdsMessage(&leapmuxv1.AgentChatMessage{Source: row.Source}) {
+					continue
+				}
+				resp.MessageCount += row.MessageCount
+				resp.ContentBytes += row.ContentBytes
+				resp.Sources = append(resp.Sources, &leapmuxv1.MessageSourceStats{
+					Source:       row.Source,
+					MessageCount: row.MessageCount,
+					ContentBytes: row.ContentBytes,
+				})
+			}
+			sendProtoResponse(sender, resp)
+		})
+
 	// RenameAgent persists the new title and broadcasts a TabRenamed event
 	// to other clients in the same workspace. The DB write + broadcast
 	// must complete past a client disconnect (otherwise sibling clients
@@ -775,10 +863,12 @@ func registerAgentHandlers(d registrar, svc *Service) {
 		})
 
 	// DeleteAgentMessage removes the row and broadcasts a MessageDeleted
-	// event to every watcher. The DB write + broadcast must complete past
-	// a client disconnect; dispatcher ctx is intentionally not threaded.
+	// event to every watcher. When the org keeps immutable transcripts the
+	// row is kept as a tombstone instead; watchers see the same deletion.
+	// The DB write + broadcast must complete past a client disconnect;
+	// dispatcher ctx is intentionally not threaded.
 	registerAgentGatedByID(d, "DeleteAgentMessage",
-		func(_ context.Context, _ userid.UserID, r *leapmuxv1.DeleteAgentMessageRequest, sender channel.ResponseWriter) {
+		func(_ context.Context, userID userid.UserID, r *leapmuxv1.DeleteAgentMessageRequest, sender channel.ResponseWriter) {
 			agentID := r.GetAgentId()
 			messageID := r.GetMessageId()
 
@@ -812,10 +902,16 @@ func registerAgentHandlers(d registrar, svc *Service) {
 				return
 			}
 
-			deletedSeq, err := svc.Queries.DeleteMessageByAgentAndID(bgCtx(), db.DeleteMessageByAgentAndIDParams{
-				AgentID: agentID,
-				ID:      messageID,
-			})
+			tombstoned := svc.ImmutableTranscripts()
+			var deletedSeq int64
+			if tombstoned {
+				deletedSeq, err = svc.tombstoneMessage(bgCtx(), userID, row)
+			} else {
+				deletedSeq, err = svc.Queries.DeleteMessageByAgentAndID(bgCtx(), db.DeleteMessageByAgentAndIDParams{
+					AgentID: agentID,
+					ID:      messageID,
+				})
+			}
 			if errors.Is(err, sql.ErrNoRows) {
 				// Raced with a concurrent delete between the read above and here: still an
 				// idempotent no-op -- the delete that won already broadcast the real seq.
@@ -828,7 +924,7 @@ func registerAgentHandlers(d registrar, svc *Service) {
 				return
 			}
 
-			sendProtoResponse(sender, &leapmuxv1.DeleteAgentMessageResponse{})
+			sendProtoResponse(sender, &leapmuxv1.DeleteAgentMessageResponse{Tombstoned: tombstoned})
 
 			// The authoritative new live tail AFTER the delete (0 if no rows remain). A
 			// windowed client whose loaded window lags the live tail sets its recorded
@@ -867,7 +963,8 @@ func registerAgentHandlers(d registrar, svc *Service) {
 
 			provider := dbAgent.AgentProvider
 			oldOptions := loadOptions(dbAgent.Options, provider)
-			newOptions := svc.sanitizeIncomingOptions(agentID, provider, oldOptions, r.GetSettings().GetOptions())
+			incoming := svc.dropForbiddenPermissionMode(dbAgent, r.GetSettings().GetOptions())
+			newOptions := svc.sanitizeIncomingOptions(agentID, provider, oldOptions, incoming)
 
 			// Optimistic DB write of the requested options; corrected below to the values
 			// the session actually confirms (settledOptions). Persist only the axes this edit
@@ -918,10 +1015,9 @@ func registerAgentHandlers(d registrar, svc *Service) {
 			// the session actually confirmed).
 			changes := svc.buildSettingsChanges(&dbAgent, oldOptions, settledOptions, sortedOptionKeys(oldOptions, settledOptions), true)
 			if len(changes) > 0 {
-				svc.Output.PersistLeapMuxNotification(agentID, dbAgent.AgentProvider, map[string]interface{}{
-					"type":    agent.NotificationTypeSettingsChanged,
-					"changes": changes,
-				})
+				svc.Output.PersistLeapMuxNotification(agentID, dbAgent.AgentProvider, agent.NotificationContent(agent.SettingsChangedPayload{
+					Changes: changes,
+				}))
 			}
 
 			// Return the settled options so the client reconciles its optimistic state
@@ -947,7 +1043,7 @@ func registerAgentHandlers(d registrar, svc *Service) {
 				if err := svc.Agents.SendRawInput(agentID, forwardBytes); err != nil {
 					slog.Error("failed to send control response to agent",
 						"agent_id", agentID, "error", err)
-					sendNotFoundError(sender, "agent not found or not running")
+					sendCodedError(sender, codes.NotFound, errcode.Wrap(errcode.AgentNotFound, errors.New("agent not found or not running")))
 					return
 				}
 			}
@@ -959,13 +1055,30 @@ func registerAgentHandlers(d registrar, svc *Service) {
 	// delivery must happen even if the requesting client disconnects mid-
 	// RPC. Dispatcher ctx is intentionally not threaded.
 	registerAgentGatedByID(d, "InterruptAgent",
-		func(_ context.Context, _ userid.UserID, r *leapmuxv1.InterruptAgentRequest, sender channel.ResponseWriter) {
+		func(_ context.Context, userID userid.UserID, r *leapmuxv1.InterruptAgentRequest, sender channel.ResponseWriter) {
 			agentID := r.GetAgentId()
-			if err := svc.Agents.Interrupt(agentID); err != nil {
+			reason, ok := interruptReasonNames[r.GetReasonKind()]
+			if !ok {
+				sendInvalidArgument(sender, "unknown interrupt reason")
+				return
+			}
+			if err := svc.interruptAgent(agentID, userID, r.GetReasonKind(), r.GetReason()); err != nil {
 				slog.Warn("interrupt failed", "agent_id", agentID, "error", err)
-				sendNotFoundError(sender, "agent not found or not running")
+				sendCodedError(sender, codes.NotFound, errcode.Wrap(errcode.AgentNotFound, errors.New("agent not found or not running")))
 				return
 			}
+			if reason == "user" {
+				// Only the user's own interrupts count toward the interrupt
+				// rate; the provider marks the turn interrupted in the chat.
+				svc.Output.recordAgentActivity(agentID, activityInterrupt, 0, "")
+			} else if dbAgent, err := svc.Queries.GetAgentByID(bgCtx(), agentID); err != nil {
+				slog.Warn("interrupt: failed to load agent", "agent_id", agentID, "error", err)
+			} else {
+				svc.Output.PersistLeapMuxNotification(agentID, dbAgent.AgentProvider, agent.NotificationContent(agent.AgentInterruptedPayload{
+					Reason: reason,
+					Detail: r.GetReason(),
+				}))
+			}
 			sendProtoResponse(sender, &leapmuxv1.InterruptAgentResponse{})
 		})
 
@@ -1260,7 +1373,12 @@ func (svc *Service) replayAgentCatchUp(
 	if replayErr != nil {
 		slog.Error("failed to list messages for replay", "agent_id", agentID, "error", replayErr)
 	} else {
+		protoMessages := make([]*leapmuxv1.AgentChatMessage, len(replayMessages))
 		for j := range replayMessages {
+			protoMessages[j] = messageToProto(&replayMessages[j])
+		}
+		svc.attachMessageUsage(bgCtx(), agentID, protoMessages)
+		for _, msg := range protoMessages {
 			broadcastReplayAgentEvent(sink, &leapmuxv1.AgentEvent{
 				AgentId: agentID,
 				// No replayed flag: message seqs are monotonic (a deleted seq is
@@ -1268,7 +1386,7 @@ func (svc *Service) replayAgentCatchUp(
 				// at seq > the consumer's forwarded high-water and a plain
 				// seq <= cursor dedup drops only true replay duplicates.
 				Event: &leapmuxv1.AgentEvent_AgentMessage{
-					AgentMessage: messageToProto(&replayMessages[j]),
+					AgentMessage: msg,
 				},
 			})
 		}
@@ -1416,6 +1534,7 @@ func (svc *Service) agentToProto(a *db.Agent, isRunning bool, gs *leapmuxv1.Agen
 		OptionGroups:   svc.optionGroupsForAgent(a),
 		StartupError:   startupError,
 		StartupMessage: startupMessage,
+		CreatedBy:      a.CreatedBy,
 	}
 
 	if a.ClosedAt.Valid {
@@ -1436,6 +1555,13 @@ func (svc *Service) runAgentStartup(ctx context.Context, dbAgent db.Agent, plan
 	agentID := agentOpts.AgentID
 	sink := svc.Output.NewSink(agentID, agentOpts.AgentProvider)
 
+	// A workspace checked out from a registered repository may still be
+	// cloning; phase 0 and everything after need the repository on disk.
+	if err := svc.awaitRepoCheckout(ctx, &dbAgent, agentOpts.WorkingDir); err != nil {
+		svc.failAgentStartup(&dbAgent, gitModeResult{}, fmt.Errorf("clone repository: %w", err), nil)
+		return
+	}
+
 	// Phase 0: execute the git-mode mutation (worktree add, branch create,
 	// checkout). Validation already ran synchronously in OpenAgent; what
 	// runs here is only the potentially slow shell-outs.
@@ -1459,6 +1585,17 @@ func (svc *Service) runAgentStartup(ctx context.Context, dbAgent db.Agent, plan
 		agentOpts.WorkingDir = gm.WorkingDir
 	}
 
+	// Setup: run the workspace's provisioning script in the working dir,
+	// if it has one that has not yet succeeded there. The agent starts
+	// only on success; a failure rolls back a worktree phase 0 created,
+	// the same as a failed start below.
+	if !agentClosedDuringStartup {
+		if err := svc.provisionAgentWorkingDir(ctx, &dbAgent, agentOpts.WorkingDir); err != nil {
+			svc.failAgentStartup(&dbAgent, gm, err, nil)
+			return
+		}
+	}
+
 	// Phase 1: compute gitStatus here rather than in the sync prologue —
 	// the git shell-out would otherwise block the OpenAgent RPC. Record
 	// each phase label in the registry *before* broadcasting so a
@@ -1759,6 +1896,35 @@ func (svc *Service) broadcastAgentInactive(dbAgent *db.Agent) {
 	svc.broadcastStatusChange(dbAgent.ID, buildAgentInactiveStatus(dbAgent, nil))
 }
 
+// ReportAgentExit tells watchers an agent went INACTIVE when its subprocess
+// exits and nothing brings it back, so a crashed agent shows as stopped
+// instead of ACTIVE until the next message fails to deliver. The agent
+// manager's exit handler calls it.
+//
+// Restarts stop the old process under the per-agent lifecycle lock and
+// start the new one before releasing it, so the check waits for that lock
+// and stays quiet when a new process (or an in-flight startup) took over.
+// It runs on its own goroutine: the exit handler fires inside stopAndWait's
+// wait, and taking the lock there would deadlock the restart holding it.
+// A startup in flight or a persisted startup failure keeps its own status.
+func (svc *Service) ReportAgentExit(agentID string) {
+	go func() {
+		unlock := svc.Agents.LockAgent(agentID)
+		defer unlock()
+		if svc.Agents.HasAgent(agentID) {
+			return
+		}
+		dbAgent, err := svc.Queries.GetAgentByID(bgCtx(), agentID)
+		if err != nil || dbAgent.ClosedAt.Valid {
+			return
+		}
+		if status, _, _ := svc.deriveAgentStatus(&dbAgent, false); status != leapmuxv1.AgentStatus_AGENT_STATUS_INACTIVE {
+			return
+		}
+		svc.broadcastAgentInactive(&dbAgent)
+	}()
+}
+
 // runAgentPhase0 broadcasts the per-mode label and executes the git-mode
 // mutation. Returns the result (with rollback metadata populated iff a
 // mutation partially succeeded before failing) and any error.
@@ -1870,29 +2036,21 @@ func reportModelChange(provider leapmuxv1.AgentProvider, oldModel, settledModel
 }
 
 // optionChangeEntry is the settings_changed payload for one changed option group: the value
-// ids (old/new) and their human-readable labels, plus the group's own label. It marshals to
-// the {old,new,oldLabel,newLabel,label} JSON shape the chat-view notification renderer reads
-// (see frontend notificationRenderers). Using a typed struct rather than a bare
-// map[string]string makes a misspelled key a compile error here instead of a silently-absent
-// field in the UI, and documents the wire shape in one place that every emitter shares.
-type optionChangeEntry struct {
-	Old        string `json:"old"`
-	New        string `json:"new"`
-	OldLabel   string `json:"oldLabel"`
-	NewLabel   string `json:"newLabel"`
-	GroupLabel string `json:"label"`
-}
+// ids (old/new) and their human-readable labels, plus the group's own label. Its wire shape is
+// the SettingChange message in notification.proto, which the chat-view notification renderer
+// reads (see frontend notificationRenderers).
+type optionChangeEntry = agent.SettingChange
 
 // optionGroupChangeEntry builds the settings_changed entry a notification carries for one
 // changed option group. valueLabel resolves the option value labels; groupLabel is the
 // human-readable group name (e.g. "Output Style").
 func optionGroupChangeEntry(oldID, newID string, valueLabel func(string) string, groupLabel string) optionChangeEntry {
 	return optionChangeEntry{
-		Old:        oldID,
-		New:        newID,
-		OldLabel:   valueLabel(oldID),
-		NewLabel:   valueLabel(newID),
-		GroupLabel: groupLabel,
+		Old:      oldID,
+		New:      newID,
+		OldLabel: valueLabel(oldID),
+		NewLabel: valueLabel(newID),
+		Label:    groupLabel,
 	}
 }
 
@@ -2118,10 +2276,10 @@ func (svc *Service) applySettingsViaRestart(dbAgent db.Agent, newOptions OptionM
 			AgentSessionID: "",
 			ID:             agentID,
 		})
-		svc.Output.PersistLeapMuxNotification(agentID, provider, map[string]interface{}{
-			"type":  agent.NotificationTypeAgentError,
-			"error": "Failed to restart agent with new settings: " + err.Error(),
-		})
+		svc.Output.PersistLeapMuxNotification(agentID, provider, agent.NotificationContent(agent.AgentErrorPayload{
+			Code:  agent.AgentErrorSettingsRestartFailed,
+			Error: err.Error(),
+		}))
 		return newOptions
 	}
 	// confirmedOpts is the relaunched agent's COMPLETE surfaced snapshot (CurrentOptions), so
@@ -2399,10 +2557,10 @@ func (svc *Service) handleClearContext(agentID string) {
 		errMsg := err.Error()
 		svc.persistAgentStartupError(agentID, errMsg)
 		svc.broadcastAgentFailed(&dbAgent, errMsg, nil)
-		svc.Output.PersistLeapMuxNotification(agentID, dbAgent.AgentProvider, map[string]interface{}{
-			"type":  agent.NotificationTypeAgentError,
-			"error": "Failed to restart agent after clearing context: " + errMsg,
-		})
+		svc.Output.PersistLeapMuxNotification(agentID, dbAgent.AgentProvider, agent.NotificationContent(agent.AgentErrorPayload{
+			Code:  agent.AgentErrorClearContextRestartFailed,
+			Error: errMsg,
+		}))
 		return
 	}
 	activeDbAgent, err := svc.persistConfirmedStartupSettings(agentID, dbAgent.AgentProvider, launchOptions.Options, confirmedSettings)
@@ -2422,9 +2580,7 @@ func (svc *Service) handleClearContext(agentID string) {
 	// flicker the ordering avoids. On failure the agent_error /
 	// STARTUP_FAILED pair above stands on its own so clients do not see a
 	// "cleared" UI state for an agent that is down.
-	svc.Output.PersistLeapMuxNotification(agentID, dbAgent.AgentProvider, map[string]interface{}{
-		"type": agent.NotificationTypeContextCleared,
-	})
+	svc.Output.PersistLeapMuxNotification(agentID, dbAgent.AgentProvider, agent.NotificationContent(agent.ContextClearedPayload{}))
 
 	// Broadcast ACTIVE explicitly so the frontend leaves STARTING even if
 	// the OutputSink's init handshake didn't (or hasn't yet) emitted its
@@ -2535,6 +2691,17 @@ func (svc *Service) handleControlRequestMessage(agentID string, provider leapmux
 		unlock := svc.Agents.LockAgent(agentID)
 		defer unlock()
 
+		// A refused mode never reaches the agent. Re-broadcast the stored
+		// settings so a frontend that switched optimistically snaps back.
+		if dbAgent, err := svc.Queries.GetAgentByID(bgCtx(), agentID); err != nil {
+			if svc.permissionGuardrails().forbids(mode) {
+				return
+			}
+		} else if svc.refusePermissionMode(dbAgent, mode) {
+			svc.broadcastSettingsStatusChange(dbAgent)
+			return
+		}
+
 		svc.setAgentPermissionMode(agentID, mode)
 
 		if !svc.Agents.HasAgent(agentID) {
@@ -2609,6 +2776,20 @@ func (svc *Service) setAgentPermissionMode(agentID, mode string) {
 	svc.setAgentPermissionModeWithAgent(dbAgent, mode)
 }
 
+// switchAgentModel moves the agent onto model, pushing the change live when
+// the agent is running. Used by the retry policy engine's fallback-model
+// switch.
+func (svc *Service) switchAgentModel(agentID, model string) {
+	dbAgent, err := svc.Queries.GetAgentByID(bgCtx(), agentID)
+	if err != nil {
+		slog.Error("switch model: agent not found", "agent_id", agentID, "error", err)
+		return
+	}
+	svc.applyOptionChanges(dbAgent,
+		map[string]string{agent.OptionIDModel: model},
+		applyOptionsSpec{live: true, notifyFirstSet: true})
+}
+
 // applyOptionsSpec tunes how applyOptionChanges treats a set of option changes.
 type applyOptionsSpec struct {
 	// live pushes the changed values to a running agent via UpdateSettings. The
@@ -2691,19 +2872,23 @@ func (svc *Service) applyOptionChanges(dbAgent db.Agent, wanted OptionMap, spec
 	// honors spec.notifyFirstSet, the same emitter the model-settle path uses.
 	changes := svc.buildSettingsChanges(&dbAgent, oldVals, opts, sortedOptionKeys(applied), spec.notifyFirstSet)
 	if len(changes) > 0 {
-		svc.Output.PersistLeapMuxNotification(agentID, dbAgent.AgentProvider, map[string]interface{}{
-			"type":    agent.NotificationTypeSettingsChanged,
-			"changes": changes,
-		})
+		svc.Output.PersistLeapMuxNotification(agentID, dbAgent.AgentProvider, agent.NotificationContent(agent.SettingsChangedPayload{
+			Changes: changes,
+		}))
 	}
 
 	return dbAgent
 }
 
 func (svc *Service) setAgentPermissionModeWithAgent(dbAgent db.Agent, mode string) db.Agent {
+	spec := applyOptionsSpec{live: false, notifyFirstSet: false}
+	if permitted := svc.guardPermissionMode(dbAgent, mode); permitted != mode {
+		// The agent may already be switching itself to the refused mode (it
+		// rides in on the control response), so push the substitute live.
+		mode, spec.live = permitted, true
+	}
 	return svc.applyOptionChanges(dbAgent,
-		map[string]string{agent.OptionIDPermissionMode: mode},
-		applyOptionsSpec{live: false, notifyFirstSet: false})
+		map[string]string{agent.OptionIDPermissionMode: mode}, spec)
 }
 
 // sendSyntheticUserMessage persists a `{content}` user row AND forwards it to the agent as input --
@@ -2739,7 +2924,7 @@ func (svc *Service) sendSyntheticUserMessage(agentID, content string, markType l
 	// mark_type is caller-scoped: UNSPECIFIED for an auto-injected synthetic prompt
 	// (no rail dot), CONTROL_RESPONSE for the user's own typed control answer delivered
 	// as agent input (a rail dot, like every other control-answer path).
-	seq, err := createMessageRow(bgCtx(), svc.Queries, db.CreateMessageParams{
+	params := db.CreateMessageParams{
 		ID:                 messageID,
 		AgentID:            agentID,
 		Source:             leapmuxv1.MessageSource_MESSAGE_SOURCE_USER,
@@ -2753,23 +2938,43 @@ func (svc *Service) sendSyntheticUserMessage(agentID, content string, markType l
 		AgentProvider:      dbAgent.AgentProvider,
 		MarkType:           markType,
 		CreatedAt:          sqltime.NewSQLiteTime(now),
-	})
+	}
+	seq, err := svc.Output.persistMessage(bgCtx(), params)
 	if err != nil {
 		slog.Error("synthetic user message: failed to persist message", "agent_id", agentID, "error", err)
 		return
 	}
+	svc.Output.classifyMessage(agentID, messageID, innerJSON)
+
+	// Synthetic prompts are not routed; the turn inherits the previous
+	// turn's routing state so a routed turn still reverts afterwards.
+	recordTurn := func() { svc.recordTurnModel(agentID, messageID, svc.loadTurnRouting(dbAgent)) }
+	// A typed control answer is the user's own; every other synthetic
+	// prompt is LeapMux's and yields to them under a rate limit.
+	priority := turnBackground
+	if markType == leapmuxv1.MarkType_MARK_TYPE_CONTROL_RESPONSE {
+		priority = turnInteractive
+	}
 
 	deliveryError := ""
-	if !svc.Agents.HasAgent(agentID) {
+	held := svc.holdTurn(dbAgent, priority, func() {
+		svc.deliverHeldTurn(agentID, messageID, content, nil, resumeSessionID, recordTurn)
+	})
+	switch {
+	case held:
+		// Delivered once the account's rate limit allows it.
+	case !svc.Agents.HasAgent(agentID):
 		if startErr := svc.ensureAgentRunning(agentID, &resumeSessionID); startErr != nil {
 			deliveryError = "agent is not running"
-		} else if sendErr := svc.Agents.SendInput(agentID, content, nil); sendErr != nil {
+		} else if sendErr := svc.sendAgentInput(agentID, content, nil); sendErr != nil {
 			slog.Error("synthetic user message: failed to send after auto-start", "agent_id", agentID, "error", sendErr)
 			deliveryError = sendErr.Error()
 		}
-	} else if sendErr := svc.Agents.SendInput(agentID, content, nil); sendErr != nil {
-		slog.Error("synthetic user message: failed to send input", "agent_id", agentID, "error", sendErr)
-		deliveryError = sendErr.Error()
+	default:
+		if sendErr := svc.sendAgentInput(agentID, content, nil); sendErr != nil {
+			slog.Error("synthetic user message: failed to send input", "agent_id", agentID, "error", sendErr)
+			deliveryError = sendErr.Error()
+		}
 	}
 	if deliveryError != "" {
 		_ = svc.Queries.SetMessageDeliveryError(bgCtx(), db.SetMessageDeliveryErrorParams{
@@ -2777,6 +2982,8 @@ func (svc *Service) sendSyntheticUserMessage(agentID, content string, markType l
 			ID:            messageID,
 			AgentID:       agentID,
 		})
+	} else if !held {
+		recordTurn()
 	}
 
 	userMsg := &leapmuxv1.AgentChatMessage{
@@ -2812,10 +3019,8 @@ func (svc *Service) sendSyntheticUserMessage(agentID, content string, markType l
 	}
 }
 
-// initiatePlanExecution clears the agent's context and sends the plan as a
-// user message. For providers that support in-place context clearing (Codex),
-// it sends a new thread/start on the running process. For others (Claude Code),
-// it stops and restarts the agent process entirely.
+// initiatePlanExecution clears the agent's context and sends the agent's own
+// captured plan as a user message. See executePlanContent.
 func (svc *Service) initiatePlanExecution(agentID string, targetMode string) {
 	dbAgent, err := svc.Queries.GetAgentByID(bgCtx(), agentID)
 	if err != nil {
@@ -2835,16 +3040,29 @@ func (svc *Service) initiatePlanExecution(agentID string, targetMode string) {
 	if planContent == "" {
 		slog.Warn("plan exec: no plan content found, broadcasting notification without restart",
 			"agent_id", agentID)
-		svc.Output.PersistLeapMuxNotification(agentID, dbAgent.AgentProvider, map[string]interface{}{
-			"type":           agent.NotificationTypePlanExecution,
-			"plan_file_path": dbAgent.PlanFilePath,
-		})
+		svc.Output.PersistLeapMuxNotification(agentID, dbAgent.AgentProvider, agent.NotificationContent(agent.PlanExecutionPayload{
+			PlanFilePath: dbAgent.PlanFilePath,
+		}))
 		return
 	}
+	svc.executePlanContent(dbAgent, targetMode, planContent, dbAgent.PlanFilePath)
+}
+
+// executePlanContent clears the agent's context and sends planContent as a
+// user message. For providers that support in-place context clearing (Codex),
+// it sends a new thread/start on the running process. For others (Claude Code),
+// it stops and restarts the agent process entirely. planFilePath names the
+// file the plan lives in, or "" for a plan that has none (a library plan).
+func (svc *Service) executePlanContent(dbAgent db.Agent, targetMode, planContent, planFilePath string) {
+	agentID := dbAgent.ID
+	// The approval path already reported a refused mode when it applied the
+	// switch; here the substitute is just carried into the restart.
+	targetMode, _ = svc.policyPermitPermissionMode(dbAgent, targetMode)
+	targetMode = svc.permissionGuardrails().permit(dbAgent.AgentProvider, targetMode)
 
 	planMsg := "Execute the following plan:\n\n---\n\n" + planContent
-	if dbAgent.PlanFilePath != "" {
-		planMsg += "\n\n---\n\nThe above plan has been written to " + dbAgent.PlanFilePath + " — re-read it if needed."
+	if planFilePath != "" {
+		planMsg += "\n\n---\n\nThe above plan has been written to " + planFilePath + " — re-read it if needed."
 	}
 
 	// Try in-place context clearing first (e.g. Codex thread/start on
@@ -2860,20 +3078,17 @@ func (svc *Service) initiatePlanExecution(agentID string, targetMode string) {
 
 		// Clear span tracking and broadcast notifications.
 		svc.Output.ResetSpanTracker(agentID)
-		svc.Output.PersistLeapMuxNotification(agentID, dbAgent.AgentProvider, map[string]interface{}{
-			"type": agent.NotificationTypeContextCleared,
-		})
-		svc.Output.PersistLeapMuxNotification(agentID, dbAgent.AgentProvider, map[string]interface{}{
-			"type":           agent.NotificationTypePlanExecution,
-			"plan_file_path": dbAgent.PlanFilePath,
-		})
+		svc.Output.PersistLeapMuxNotification(agentID, dbAgent.AgentProvider, agent.NotificationContent(agent.ContextClearedPayload{}))
+		svc.Output.PersistLeapMuxNotification(agentID, dbAgent.AgentProvider, agent.NotificationContent(agent.PlanExecutionPayload{
+			PlanFilePath: planFilePath,
+		}))
 	} else {
 		// Full restart path (Claude Code and other providers).
-		svc.initiatePlanExecutionRestart(agentID, targetMode, dbAgent, planMsg)
+		svc.initiatePlanExecutionRestart(agentID, targetMode, dbAgent, planFilePath)
 	}
 
 	// Send plan content as user message and persist it for the frontend.
-	if err := svc.Agents.SendInput(agentID, planMsg, nil); err != nil {
+	if err := svc.sendAgentInput(agentID, planMsg, nil); err != nil {
 		slog.Error("plan exec: failed to send plan content", "agent_id", agentID, "error", err)
 	}
 
@@ -2894,7 +3109,7 @@ func (svc *Service) initiatePlanExecution(agentID string, targetMode string) {
 
 // initiatePlanExecutionRestart performs a full stop-and-restart to clear
 // context for providers that don't support in-place clearing (e.g. Claude Code).
-func (svc *Service) initiatePlanExecutionRestart(agentID, targetMode string, dbAgent db.Agent, planMsg string) {
+func (svc *Service) initiatePlanExecutionRestart(agentID, targetMode string, dbAgent db.Agent, planFilePath string) {
 	unlock := svc.Agents.LockAgent(agentID)
 	defer unlock()
 
@@ -2908,13 +3123,10 @@ func (svc *Service) initiatePlanExecutionRestart(agentID, targetMode string, dbA
 	svc.Output.ResetSpanTracker(agentID)
 
 	// Broadcast context_cleared and plan_execution as separate notifications.
-	svc.Output.PersistLeapMuxNotification(agentID, dbAgent.AgentProvider, map[string]interface{}{
-		"type": agent.NotificationTypeContextCleared,
-	})
-	svc.Output.PersistLeapMuxNotification(agentID, dbAgent.AgentProvider, map[string]interface{}{
-		"type":           agent.NotificationTypePlanExecution,
-		"plan_file_path": dbAgent.PlanFilePath,
-	})
+	svc.Output.PersistLeapMuxNotification(agentID, dbAgent.AgentProvider, agent.NotificationContent(agent.ContextClearedPayload{}))
+	svc.Output.PersistLeapMuxNotification(agentID, dbAgent.AgentProvider, agent.NotificationContent(agent.PlanExecutionPayload{
+		PlanFilePath: planFilePath,
+	}))
 
 	// Restart agent with plan content. Use svc.startAgent — the
 	// test-injectable wrapper that forwards to svc.Agents.StartAgent in
@@ -2932,10 +3144,10 @@ func (svc *Service) initiatePlanExecutionRestart(agentID, targetMode string, dbA
 			AgentSessionID: "",
 			ID:             agentID,
 		})
-		svc.Output.PersistLeapMuxNotification(agentID, dbAgent.AgentProvider, map[string]interface{}{
-			"type":  agent.NotificationTypeAgentError,
-			"error": "Failed to restart agent for plan execution: " + err.Error(),
-		})
+		svc.Output.PersistLeapMuxNotification(agentID, dbAgent.AgentProvider, agent.NotificationContent(agent.AgentErrorPayload{
+			Code:  agent.AgentErrorPlanExecutionRestartFailed,
+			Error: err.Error(),
+		}))
 		return
 	}
 	if _, err := svc.persistConfirmedStartupSettings(agentID, dbAgent.AgentProvider, launchOptions.Options, confirmedSettings); err != nil {
@@ -0,0 +1,206 @@
+package service
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"log/slog"
+
+	leapmuxv1 "github.com/leapmux/leapmux/generated/proto/leapmux/v1"
+	"github.com/leapmux/leapmux/internal/util/id"
+	"github.com/leapmux/leapmux/internal/util/userid"
+	"github.com/leapmux/leapmux/internal/worker/channel"
+	db "github.com/leapmux/leapmux/internal/worker/generated/db"
+	"github.com/leapmux/leapmux/util/validate"
+)
+
+// cloneHistoryBatch is how many messages CloneAgent copies per read, so a
+// long transcript is not held in memory at once.
+const cloneHistoryBatch = 500
+
+func registerAgentCloneHandlers(d registrar, svc *Service) {
+	// CloneAgent persists under a fresh background context, like OpenAgent:
+	// a retry after a mid-RPC disconnect must not find half a clone. The
+	// transaction already makes the clone all-or-nothing.
+	registerAgentGated(d, "CloneAgent",
+		func(_ context.Context, userID userid.UserID, r *leapmuxv1.CloneAgentRequest, src db.Agent, sender channel.ResponseWriter) {
+			title, err := sanitizeOptionalTitle(r.GetTitle())
+			if err != nil {
+				sendInvalidArgument(sender, err.Error())
+				return
+			}
+			if title == "" {
+				title = cloneTitle(src.Title)
+			}
+			clone, copied, err := svc.cloneAgent(bgCtx(), userID, src, title, r.GetCopyHistory())
+			if err != nil {
+				slog.Error("failed to clone agent", "agent_id", src.ID, "error", err)
+				sendInternalError(sender, "failed to clone agent")
+				return
+			}
+			sendProtoResponse(sender, &leapmuxv1.CloneAgentResponse{
+				Agent:          svc.agentToProto(&clone, false, nil),
+				CopiedMessages: uint32(copied),
+			})
+		})
+}
+
+// cloneTitle names a clone after its source, falling back to a fresh
+// random title when the source has none or the suffixed one would be too
+// long.
+func cloneTitle(source string) string {
+	if source == "" {
+		return pickAgentTitle()
+	}
+	if title, err := validate.SanitizeName(source + " (copy)"); err == nil {
+		return title
+	}
+	return pickAgentTitle()
+}
+
+// cloneAgent creates a copy of src titled title, atomically, and returns
+// it with the number of messages copied. The clone shares src's working
+// directory and is linked to src's worktree, if any, so closing either one
+// cannot remove the worktree from under the other. It keeps src's library
+// system prompt. Like an imported agent it carries no provider session and
+// is not started here.
+func (svc *Service) cloneAgent(ctx context.Context, userID userid.UserID, src db.Agent, title string, copyHistory bool) (db.Agent, int, error) {
+	tx, err := svc.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return db.Agent{}, 0, err
+	}
+	defer func() { _ = tx.Rollback() }()
+	queries := svc.Queries.WithTx(tx)
+
+	cloneID := id.Generate()
+	if err := queries.CreateAgent(ctx, db.CreateAgentParams{
+		ID:            cloneID,
+		WorkspaceID:   src.WorkspaceID,
+		WorkingDir:    src.WorkingDir,
+		HomeDir:       src.HomeDir,
+		Title:         title,
+		Options:       src.Options,
+		AgentProvider: src.AgentProvider,
+		CreatedBy:     userID.String(),
+	}); err != nil {
+		return db.Agent{}, 0, fmt.Errorf("create agent: %w", err)
+	}
+
+	wt, err := queries.GetWorktreeForTab(ctx, db.GetWorktreeForTabParams{
+		TabType: leapmuxv1.TabType_TAB_TYPE_AGENT,
+		TabID:   src.ID,
+	})
+	switch {
+	case err == nil:
+		if err := queries.AddWorktreeTab(ctx, db.AddWorktreeTabParams{
+			WorktreeID: wt.ID,
+			TabType:    leapmuxv1.TabType_TAB_TYPE_AGENT,
+			TabID:      cloneID,
+		}); err != nil {
+			return db.Agent{}, 0, fmt.Errorf("link worktree: %w", err)
+		}
+	case !errors.Is(err, sql.ErrNoRows):
+		return db.Agent{}, 0, fmt.Errorf("look up worktree: %w", err)
+	}
+
+	if err := queries.CopyAgentSystemPrompt(ctx, db.CopyAgentSystemPromptParams{
+		AgentID:       cloneID,
+		SourceAgentID: src.ID,
+	}); err != nil {
+		return db.Agent{}, 0, fmt.Errorf("copy system prompt: %w", err)
+	}
+	if err := queries.CopyAgentEnv(ctx, db.CopyAgentEnvParams{
+		AgentID:       cloneID,
+		SourceAgentID: src.ID,
+	}); err != nil {
+		return db.Agent{}, 0, fmt.Errorf("copy env: %w", err)
+	}
+	if err := queries.CopyAgentModelCredential(ctx, db.CopyAgentModelCredentialParams{
+		AgentID:       cloneID,
+		SourceAgentID: src.ID,
+	}); err != nil {
+		return db.Agent{}, 0, fmt.Errorf("copy model credential: %w", err)
+	}
+
+	copied := 0
+	if copyHistory {
+		if copied, err = svc.copyAgentMessages(ctx, queries, src, cloneID); err != nil {
+			return db.Agent{}, 0, err
+		}
+	}
+
+	clone, err := queries.GetAgentByID(ctx, cloneID)
+	if err != nil {
+		return db.Agent{}, 0, fmt.Errorf("get agent: %w", err)
+	}
+	return clone, copied, tx.Commit()
+}
+
+// copyAgentMessages copies src's transcript to agent dstID under fresh ids,
+// keeping each message's content, span and timestamp. Seqs are reassigned
+// from 1, in the same order.
+func (svc *Service) copyAgentMessages(ctx context.Context, queries *db.Queries, src db.Agent, dstID string) (int, error) {
+	copied := 0
+	var after int64
+	for {
+		rows, err := queries.ListMessagesByAgentID(ctx, db.ListMessagesByAgentIDParams{
+			AgentID: src.ID,
+			Seq:     after,
+			Limit:   cloneHistoryBatch,
+		})
+		if err != nil {
+			return 0, fmt.Errorf("list messages: %w", err)
+		}
+		for _, m := range rows {
+			provider := m.AgentProvider
+			if provider == leapmuxv1.AgentProvider_AGENT_PROVIDER_UNSPECIFIED {
+				provider = src.AgentProvider
+			}
+			messageID := id.Generate()
+			params := db.CreateMessageParams{
+				ID:                 messageID,
+				AgentID:            dstID,
+				Source:             m.Source,
+				Content:            m.Content,
+				ContentCompression: m.ContentCompression,
+				Depth:              m.Depth,
+				SpanID:             m.SpanID,
+				ParentSpanID:       m.ParentSpanID,
+				SpanType:           m.SpanType,
+				SpanLines:          m.SpanLines,
+				SpanColor:          m.SpanColor,
+				AgentProvider:      provider,
+				MarkType:           m.MarkType,
+				CreatedAt:          m.CreatedAt,
+			}
+			if _, err := createMessageRow(ctx, queries, params); err != nil {
+				return 0, fmt.Errorf("create message: %w", err)
+			}
+			if err := svc.Output.chainMessage(ctx, queries, params); err != nil {
+				return 0, fmt.Errorf("chain message: %w", err)
+			}
+			if m.DeliveryError != "" {
+				if err := queries.SetMessageDeliveryError(ctx, db.SetMessageDeliveryErrorParams{
+					DeliveryError: m.DeliveryError,
+					ID:            messageID,
+					AgentID:       dstID,
+				}); err != nil {
+					return 0, fmt.Errorf("set delivery error: %w", err)
+				}
+			}
+			if err := queries.CopyMessageClassification(ctx, db.CopyMessageClassificationParams{
+				DstMessageID: messageID,
+				DstAgentID:   dstID,
+				SrcMessageID: m.ID,
+			}); err != nil {
+				return 0, fmt.Errorf("copy classification: %w", err)
+			}
+			after = m.Seq
+			copied++
+		}
+		if len(rows) < cloneHistoryBatch {
+			return copied, nil
+		}
+	}
+}
@@ -0,0 +1,85 @@
+package service
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	leapmuxv1 "github.com/leapmux/leapmux/generated/proto/leapmux/v1"
+	"github.com/leapmux/leapmux/internal/util/sqltime"
+	db "github.com/leapmux/leapmux/internal/worker/generated/db"
+)
+
+func TestCloneAgent_CopiesConfigAndHistory(t *testing.T) {
+	svc, d, w := setupTestService(t, withWorkspaces("ws-1"))
+	ctx := context.Background()
+	src := seedGuardedAgent(t, svc, "")
+	require.NoError(t, svc.Queries.UpdateAgentSessionID(ctx, db.UpdateAgentSessionIDParams{AgentSessionID: "session-1", ID: src.ID}))
+	_, err := svc.Queries.RenameAgent(ctx, db.RenameAgentParams{Title: "Agent Ada", ID: src.ID})
+	require.NoError(t, err)
+	for i, content := range []string{`{"content":"hi"}`, `{"content":"hello"}`} {
+		_, err := createMessageRow(ctx, svc.Queries, db.CreateMessageParams{
+			ID:            []string{"m-1", "m-2"}[i],
+			AgentID:       src.ID,
+			Source:        leapmuxv1.MessageSource_MESSAGE_SOURCE_USER,
+			Content:       []byte(content),
+			AgentProvider: claudeProvider,
+			CreatedAt:     sqltime.NewSQLiteTime(nowMillis()),
+		})
+		require.NoError(t, err)
+	}
+
+	dispatch(d, "CloneAgent", &leapmuxv1.CloneAgentRequest{AgentId: src.ID, CopyHistory: true}, w)
+	require.Empty(t, w.errors)
+	resp := decodeResponse[leapmuxv1.CloneAgentResponse](t, w)
+	assert.Equal(t, uint32(2), resp.GetCopiedMessages())
+	cloneID := resp.GetAgent().GetId()
+	require.NotEqual(t, src.ID, cloneID)
+
+	clone, err := svc.Queries.GetAgentByID(ctx, cloneID)
+	require.NoError(t, err)
+	assert.Equal(t, src.WorkspaceID, clone.WorkspaceID)
+	assert.Equal(t, src.WorkingDir, clone.WorkingDir)
+	assert.Equal(t, src.Options, clone.Options)
+	assert.Equal(t, src.AgentProvider, clone.AgentProvider)
+	assert.Equal(t, "Agent Ada (copy)", clone.Title)
+	assert.Empty(t, clone.AgentSessionID, "a clone starts a fresh provider session")
+
+	msgs, err := svc.Queries.ListAllMessagesByAgentID(ctx, db.ListAllMessagesByAgentIDParams{AgentID: cloneID})
+	require.NoError(t, err)
+	require.Len(t, msgs, 2)
+	assert.Equal(t, `{"content":"hi"}`, string(msgs[0].Content))
+	assert.Equal(t, `{"content":"hello"}`, string(msgs[1].Content))
+	assert.NotEqual(t, "m-1", msgs[0].ID)
+}
+
+func TestCloneAgent_WithoutHistory(t *testing.T) {
+	svc, d, w := setupTestService(t, withWorkspaces("ws-1"))
+	ctx := context.Background()
+	src := seedGuardedAgent(t, svc, "")
+	_, err := createMessageRow(ctx, svc.Queries, db.CreateMessageParams{
+		ID: "m-1", AgentID: src.ID, Source: leapmuxv1.MessageSource_MESSAGE_SOURCE_USER,
+		Content: []byte(`{"content":"hi"}`), AgentProvider: claudeProvider, CreatedAt: sqltime.NewSQLiteTime(nowMillis()),
+	})
+	require.NoError(t, err)
+
+	dispatch(d, "CloneAgent", &leapmuxv1.CloneAgentRequest{AgentId: src.ID, Title: "Reference"}, w)
+	require.Empty(t, w.errors)
+	resp := decodeResponse[leapmuxv1.CloneAgentResponse](t, w)
+	assert.Zero(t, resp.GetCopiedMessages())
+	assert.Equal(t, "Reference", resp.GetAgent().GetTitle())
+	msgs, err := svc.Queries.ListAllMessagesByAgentID(ctx, db.ListAllMessagesByAgentIDParams{AgentID: resp.GetAgent().GetId()})
+	require.NoError(t, err)
+	assert.Empty(t, msgs)
+}
+
+func TestCloneTitle(t *testing.T) {
+	assert.Equal(t, "Agent Ada (copy)", cloneTitle("Agent Ada"))
+	assert.NotEmpty(t, cloneTitle(""))
+	long := cloneTitle(strings.Repeat("a", 128))
+	assert.NotEmpty(t, long)
+	assert.LessOrEqual(t, len(long), 128, "a title the suffix would overflow falls back to a fresh one")
+}
@@ -0,0 +1,251 @@
+package service
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"strings"
+	"unicode/utf8"
+
+	leapmuxv1 "github.com/leapmux/leapmux/generated/proto/leapmux/v1"
+	"github.com/leapmux/leapmux/internal/util/msgcodec"
+	"github.com/leapmux/leapmux/internal/util/userid"
+	"github.com/leapmux/leapmux/internal/worker/agent"
+	"github.com/leapmux/leapmux/internal/worker/channel"
+	db "github.com/leapmux/leapmux/internal/worker/generated/db"
+)
+
+const (
+	// compactDigestMessages is how many of the session's latest user
+	// messages a summary compaction carries over.
+	compactDigestMessages = 20
+	// compactDigestMessageRunes truncates each carried message.
+	compactDigestMessageRunes = 500
+)
+
+var errCompactionInProgress = errors.New("a compaction is already in progress")
+
+func registerAgentCompactHandlers(d registrar, svc *Service) {
+	// CompactAgentContext persists and delivers under a fresh background
+	// context, like SendAgentMessage: the compaction must complete past a
+	// client disconnect.
+	registerAgentGated(d, "CompactAgentContext",
+		func(_ context.Context, _ userid.UserID, _ *leapmuxv1.CompactAgentContextRequest, dbAgent db.Agent, sender channel.ResponseWriter) {
+			if svc.rejectFailedStartup(sender, dbAgent.ID, dbAgent) {
+				return
+			}
+			if err := svc.checkCompactable(dbAgent); err != nil {
+				sendFailedPrecondition(sender, err.Error())
+				return
+			}
+			resp, err := svc.compactAgentContext(dbAgent)
+			if errors.Is(err, errCompactionInProgress) {
+				sendFailedPrecondition(sender, err.Error())
+				return
+			}
+			if err != nil {
+				slog.Error("failed to compact agent context", "agent_id", dbAgent.ID, "error", err)
+				sendInternalError(sender, "failed to compact context")
+				return
+			}
+			sendProtoResponse(sender, resp)
+		})
+}
+
+// checkCompactable reports why dbAgent's context cannot be compacted now:
+// mid-turn, already compacting, or with no conversation to compact.
+func (svc *Service) checkCompactable(dbAgent db.Agent) error {
+	if _, idle := svc.Output.agentIdleFor(dbAgent.ID); !idle {
+		return errors.New("wait for the current turn to finish before compacting")
+	}
+	if _, busy := svc.compactions.Load(dbAgent.ID); busy {
+		return errCompactionInProgress
+	}
+	if dbAgent.Resumed != 0 {
+		return nil
+	}
+	has, err := svc.Queries.HasUserMessages(bgCtx(), dbAgent.ID)
+	if err != nil {
+		return fmt.Errorf("check conversation: %w", err)
+	}
+	if !has {
+		return errors.New("nothing to compact yet")
+	}
+	return nil
+}
+
+// compactAgentContext compacts dbAgent's context and reports it in the
+// chat: a started notification now, and a finished one with the new
+// context size once it is done. A provider that compacts natively is sent
+// its compaction input and finishes at the end of that turn. Any other is
+// restarted with a fresh context, and a digest of the session's recent
+// user messages is carried into its next turn (see withCarriedContext).
+func (svc *Service) compactAgentContext(dbAgent db.Agent) (*leapmuxv1.CompactAgentContextResponse, error) {
+	agentID := dbAgent.ID
+	if _, busy := svc.compactions.LoadOrStore(agentID, struct{}{}); busy {
+		return nil, errCompactionInProgress
+	}
+
+	beforeRaw, _ := svc.Output.latestSessionInfo(agentID, "context_usage")
+	before, window := parseContextUsage(beforeRaw)
+	input := agent.ProviderFor(dbAgent.AgentProvider).CompactInput()
+	method := leapmuxv1.ContextCompactionMethod_CONTEXT_COMPACTION_METHOD_SUMMARY
+	if input != "" {
+		method = leapmuxv1.ContextCompactionMethod_CONTEXT_COMPACTION_METHOD_NATIVE
+	}
+	resp := &leapmuxv1.CompactAgentContextResponse{
+		Method:              method,
+		ContextTokensBefore: before,
+		ContextWindow:       window,
+	}
+	notification := func(phase string) agent.ContextCompactionPayload {
+		return agent.ContextCompactionPayload{
+			Phase:         phase,
+			Method:        compactionMethodName(method),
+			BeforeTokens:  before,
+			ContextWindow: window,
+		}
+	}
+	finish := func() {
+		done := notification("finished")
+		if raw, ok := svc.Output.latestSessionInfo(agentID, "context_usage"); ok && string(raw) != string(beforeRaw) {
+			done.AfterTokens, _ = parseContextUsage(raw)
+		}
+		svc.Output.PersistLeapMuxNotification(agentID, dbAgent.AgentProvider, agent.NotificationContent(done))
+		svc.compactions.Delete(agentID)
+	}
+
+	if input == "" {
+		digest, err := svc.conversationDigest(agentID)
+		if err != nil {
+			svc.compactions.Delete(agentID)
+			return nil, err
+		}
+		svc.Output.PersistLeapMuxNotification(agentID, dbAgent.AgentProvider, agent.NotificationContent(notification("started")))
+		svc.handleClearContext(agentID)
+		if digest != "" {
+			svc.carriedContext.Store(agentID, digest)
+		}
+		finish()
+		return resp, nil
+	}
+
+	if err := svc.ensureAgentRunning(agentID, nil); err != nil {
+		svc.compactions.Delete(agentID)
+		return nil, fmt.Errorf("start agent: %w", err)
+	}
+	svc.Output.PersistLeapMuxNotification(agentID, dbAgent.AgentProvider, agent.NotificationContent(notification("started")))
+	svc.Output.onNextTurnEnd(agentID, finish)
+	if err := svc.sendAgentInput(agentID, input, nil); err != nil {
+		svc.Output.endAgentTurn(agentID)
+		svc.Output.turnEndHooks.Delete(agentID)
+		svc.compactions.Delete(agentID)
+		return nil, fmt.Errorf("send compaction input: %w", err)
+	}
+	return resp, nil
+}
+
+func compactionMethodName(m leapmuxv1.ContextCompactionMethod) string {
+	if m == leapmuxv1.ContextCompactionMethod_CONTEXT_COMPACTION_METHOD_NATIVE {
+		return "native"
+	}
+	return "summary"
+}
+
+// conversationDigest renders the current session's latest user messages,
+// oldest first, as the preamble a summary compaction carries into the
+// fresh context. It is empty when there is nothing worth carrying.
+// LeapMux slash commands are left out.
+func (svc *Service) conversationDigest(agentID string) (string, error) {
+	rows, err := svc.Queries.ListLatestSessionUserMessages(bgCtx(), db.ListLatestSessionUserMessagesParams{
+		AgentID: agentID,
+		Limit:   compactDigestMessages,
+	})
+	if err != nil {
+		return "", fmt.Errorf("list user messages: %w", err)
+	}
+	var asked []string
+	for i := len(rows) - 1; i >= 0; i-- {
+		raw, err := msgcodec.Decompress(rows[i].Content, rows[i].ContentCompression)
+		if err != nil {
+			continue
+		}
+		var msg struct {
+			Content string `json:"content"`
+		}
+		if json.Unmarshal(raw, &msg) != nil {
+			continue
+		}
+		text := strings.TrimSpace(msg.Content)
+		if _, _, isCommand := slashCommands.match(text); isCommand || text == "" {
+			continue
+		}
+		if utf8.RuneCountInString(text) > compactDigestMessageRunes {
+			text = string([]rune(text)[:compactDigestMessageRunes]) + "…"
+		}
+		asked = append(asked, text)
+	}
+	if len(asked) == 0 {
+		return "", nil
+	}
+	var b strings.Builder
+	b.WriteString("Your context was compacted. Before that, the user's latest requests in this session were:\n\n")
+	for i, text := range asked {
+		fmt.Fprintf(&b, "%d. %s\n", i+1, strings.ReplaceAll(text, "\n", "\n   "))
+	}
+	b.WriteString("\nPick up from there. The user's next message follows.\n\n---\n\n")
+	return b.String(), nil
+}
+
+// withCarriedContext prefixes content with the digest a summary compaction
+// left for agentID's next turn. Call the returned func once the turn is
+// delivered, so a failed delivery keeps the digest for the next attempt.
+func (svc *Service) withCarriedContext(agentID, content string) (string, func()) {
+	v, ok := svc.carriedContext.Load(agentID)
+	if !ok {
+		return content, func() {}
+	}
+	digest := v.(string)
+	return digest + content, func() { svc.carriedContext.CompareAndDelete(agentID, digest) }
+}
+
+// compactCommand is /compact: see compactAgentContext.
+type compactCommand struct{}
+
+func (compactCommand) Names() []string { return []string{"compact"} }
+
+func (compactCommand) Parse(args string) error {
+	if args != "" {
+		return errNoArguments
+	}
+	return nil
+}
+
+func (compactCommand) Permit(svc *Service, dbAgent db.Agent) error {
+	return svc.checkCompactable(dbAgent)
+}
+
+func (compactCommand) Run(svc *Service, dbAgent db.Agent, _ string) map[string]any {
+	if _, err := svc.compactAgentContext(dbAgent); err != nil {
+		slog.Error("failed to compact agent context", "agent_id", dbAgent.ID, "error", err)
+		return agent.NotificationContent(agent.AgentErrorPayload{
+			Code:  agent.AgentErrorCompactFailed,
+			Error: err.Error(),
+		})
+	}
+	return nil
+}
+
+// onNextTurnEnd runs fn once, when agentID next ends a turn.
+func (h *OutputHandler) onNextTurnEnd(agentID string, fn func()) {
+	h.turnEndHooks.Store(agentID, fn)
+}
+
+// runTurnEndHook runs and clears agentID's onNextTurnEnd hook, if any.
+func (h *OutputHandler) runTurnEndHook(agentID string) {
+	if fn, ok := h.turnEndHooks.LoadAndDelete(agentID); ok {
+		fn.(func())()
+	}
+}
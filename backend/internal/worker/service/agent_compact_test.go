@@ -0,0 +1,122 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	leapmuxv1 "github.com/leapmux/leapmux/generated/proto/leapmux/v1"
+	"github.com/leapmux/leapmux/internal/worker/agent"
+	"github.com/leapmux/leapmux/internal/worker/channel"
+	db "github.com/leapmux/leapmux/internal/worker/generated/db"
+)
+
+// seedCompactableAgent creates agent-1 on provider and sends it the given
+// user messages, each left undelivered so no turn is in flight.
+func seedCompactableAgent(t *testing.T, svc *Service, d *channel.Dispatcher, w *testResponseWriter, provider leapmuxv1.AgentProvider, messages ...string) {
+	t.Helper()
+	require.NoError(t, svc.Queries.CreateAgent(context.Background(), db.CreateAgentParams{
+		ID:            "agent-1",
+		WorkspaceID:   "ws-1",
+		WorkingDir:    t.TempDir(),
+		HomeDir:       t.TempDir(),
+		AgentProvider: provider,
+	}))
+	svc.startAgentFn = func(context.Context, agent.Options, agent.OutputSink) (map[string]string, error) {
+		return nil, errors.New("not started")
+	}
+	for _, content := range messages {
+		dispatch(d, "SendAgentMessage", &leapmuxv1.SendAgentMessageRequest{AgentId: "agent-1", Content: content}, w)
+	}
+	require.Empty(t, w.errors)
+}
+
+func compactionNotifications(t *testing.T, svc *Service) []map[string]any {
+	t.Helper()
+	return findNotificationsByType(readAllNotifications(t, svc.Queries, "agent-1"), agent.NotificationTypeContextCompaction)
+}
+
+func TestCompactAgentContext_SummaryCarriesDigestIntoNextTurn(t *testing.T) {
+	svc, d, w := setupTestService(t, withWorkspaces("ws-1"))
+	seedCompactableAgent(t, svc, d, w, leapmuxv1.AgentProvider_AGENT_PROVIDER_CODEX,
+		"fix the login bug", "/status", strings.Repeat("x", compactDigestMessageRunes+10))
+	svc.startAgentFn = mockAgentStarter(t, svc, nil)
+
+	dispatch(d, "CompactAgentContext", &leapmuxv1.CompactAgentContextRequest{AgentId: "agent-1"}, w)
+	require.Empty(t, w.errors)
+	resp := decodeResponse[leapmuxv1.CompactAgentContextResponse](t, w)
+	assert.Equal(t, leapmuxv1.ContextCompactionMethod_CONTEXT_COMPACTION_METHOD_SUMMARY, resp.GetMethod())
+
+	notes := compactionNotifications(t, svc)
+	require.Len(t, notes, 2)
+	assert.Equal(t, "started", notes[0]["phase"])
+	assert.Equal(t, "finished", notes[1]["phase"])
+	assert.Equal(t, "summary", notes[1]["method"])
+
+	digest, _ := svc.withCarriedContext("agent-1", "next")
+	assert.Contains(t, digest, "1. fix the login bug\n")
+	assert.NotContains(t, digest, "/status", "LeapMux commands are not carried over")
+	assert.Contains(t, digest, strings.Repeat("x", compactDigestMessageRunes)+"…\n")
+	assert.True(t, strings.HasSuffix(digest, "next"))
+
+	dispatch(d, "SendAgentMessage", &leapmuxv1.SendAgentMessageRequest{AgentId: "agent-1", Content: "next"}, w)
+	require.Empty(t, w.errors)
+	_, carried := svc.carriedContext.Load("agent-1")
+	assert.False(t, carried, "a delivered turn consumes the carried context")
+}
+
+func TestCompactAgentContext_NativeFinishesAtTurnEnd(t *testing.T) {
+	svc, d, w := setupTestService(t, withWorkspaces("ws-1"))
+	seedCompactableAgent(t, svc, d, w, claudeProvider, "fix the login bug")
+	var sink agent.OutputSink
+	starter := mockAgentStarter(t, svc, nil)
+	svc.startAgentFn = func(ctx context.Context, opts agent.Options, s agent.OutputSink) (map[string]string, error) {
+		sink = s
+		return starter(ctx, opts, s)
+	}
+	require.NoError(t, svc.ensureAgentRunning("agent-1", nil))
+	sink.BroadcastSessionInfo(map[string]any{"context_usage": map[string]any{"input_tokens": 1500, "context_window": 200000}})
+
+	dispatch(d, "CompactAgentContext", &leapmuxv1.CompactAgentContextRequest{AgentId: "agent-1"}, w)
+	require.Empty(t, w.errors)
+	resp := decodeResponse[leapmuxv1.CompactAgentContextResponse](t, w)
+	assert.Equal(t, leapmuxv1.ContextCompactionMethod_CONTEXT_COMPACTION_METHOD_NATIVE, resp.GetMethod())
+	assert.Equal(t, int64(1500), resp.GetContextTokensBefore())
+	assert.Equal(t, int64(200000), resp.GetContextWindow())
+	require.Len(t, compactionNotifications(t, svc), 1)
+
+	dispatch(d, "CompactAgentContext", &leapmuxv1.CompactAgentContextRequest{AgentId: "agent-1"}, w)
+	require.Len(t, w.errors, 1)
+	assert.Equal(t, codeFailedPrecondition, w.errors[0].code, "the compaction turn is still running")
+
+	sink.BroadcastSessionInfo(map[string]any{"context_usage": map[string]any{"input_tokens": 300, "context_window": 200000}})
+	require.NoError(t, sink.PersistTurnEnd([]byte(`{"type":"result"}`), agent.SpanInfo{}))
+
+	notes := compactionNotifications(t, svc)
+	require.Len(t, notes, 2)
+	assert.Equal(t, "finished", notes[1]["phase"])
+	assert.Equal(t, "native", notes[1]["method"])
+	assert.EqualValues(t, 1500, notes[1]["before_tokens"])
+	assert.EqualValues(t, 300, notes[1]["after_tokens"])
+	_, busy := svc.compactions.Load("agent-1")
+	assert.False(t, busy)
+}
+
+func TestSendAgentMessage_CompactCommandRejections(t *testing.T) {
+	svc, d, w := setupTestService(t, withWorkspaces("ws-1"))
+	seedCompactableAgent(t, svc, d, w, leapmuxv1.AgentProvider_AGENT_PROVIDER_CODEX)
+
+	dispatch(d, "SendAgentMessage", &leapmuxv1.SendAgentMessageRequest{AgentId: "agent-1", Content: "/compact now"}, w)
+	require.Len(t, w.errors, 1)
+	assert.Equal(t, codeInvalidArgument, w.errors[0].code)
+
+	dispatch(d, "SendAgentMessage", &leapmuxv1.SendAgentMessageRequest{AgentId: "agent-1", Content: "/compact"}, w)
+	require.Len(t, w.errors, 2)
+	assert.Equal(t, codeFailedPrecondition, w.errors[1].code)
+	assert.Equal(t, "nothing to compact yet", w.errors[1].message)
+	assert.Empty(t, compactionNotifications(t, svc))
+}
@@ -0,0 +1,200 @@
+package service
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"log/slog"
+	"time"
+
+	leapmuxv1 "github.com/leapmux/leapmux/generated/proto/leapmux/v1"
+	"github.com/leapmux/leapmux/internal/util/userid"
+	"github.com/leapmux/leapmux/internal/worker/agent"
+	"github.com/leapmux/leapmux/internal/worker/channel"
+	db "github.com/leapmux/leapmux/internal/worker/generated/db"
+	"google.golang.org/protobuf/encoding/protojson"
+	"google.golang.org/protobuf/proto"
+)
+
+// maxAgentDefaultLen caps each AgentDefaults value, matching the Hub's cap
+// on the org layer.
+const maxAgentDefaultLen = 128
+
+// SetOrgDefaults adopts the org's defaults. The Hub only checks the shape
+// of the auto-continue policy; one this worker would refuse as a workspace
+// policy is dropped with a warning rather than failing the rest.
+func (svc *Service) SetOrgDefaults(d *leapmuxv1.OrgDefaults) {
+	if err := validateRetryPolicy(d.GetAutoContinue()); err != nil {
+		slog.Warn("ignoring invalid org auto-continue policy", "error", err)
+		d = proto.Clone(d).(*leapmuxv1.OrgDefaults)
+		d.AutoContinue = nil
+	}
+	svc.orgDefaults.Store(d)
+	svc.recordImmutableTranscriptsStart()
+}
+
+// orgRetryPolicy is the org's auto-continue policy, nil until the Hub
+// delivers one.
+func (svc *Service) orgRetryPolicy() *leapmuxv1.RetryPolicy {
+	return svc.orgDefaults.Load().GetAutoContinue()
+}
+
+// ClosedRetention is how long closed agents and terminals are kept: the
+// org's closed_retention_days, or cleanupRetention when it sets none.
+func (svc *Service) ClosedRetention() time.Duration {
+	if days := svc.orgDefaults.Load().GetClosedRetentionDays(); days > 0 {
+		return time.Duration(days) * 24 * time.Hour
+	}
+	return cleanupRetention
+}
+
+// agentDefaultsFor returns the entry for provider, or nil.
+func agentDefaultsFor(list []*leapmuxv1.AgentDefaults, provider leapmuxv1.AgentProvider) *leapmuxv1.AgentDefaults {
+	for _, d := range list {
+		if d.GetAgentProvider() == provider {
+			return d
+		}
+	}
+	return nil
+}
+
+// applyAgentDefaults fills the model, effort, and permissionMode requested
+// left unset from the agent defaults layers, the org's first, then the
+// workspace's, then the caller's own. A permission mode the provider or
+// this worker's guardrails would refuse is skipped for the next layer, so
+// a stale default never blocks a launch. requested is left untouched.
+func (svc *Service) applyAgentDefaults(ctx context.Context, workspaceID string, provider leapmuxv1.AgentProvider, user *leapmuxv1.AgentDefaults, requested OptionMap) OptionMap {
+	workspace, err := loadWorkspaceAgentDefaults(ctx, svc.Queries, workspaceID)
+	if err != nil {
+		slog.Warn("workspace agent defaults load failed; skipping them",
+			"workspace_id", workspaceID, "error", err)
+		workspace = &leapmuxv1.WorkspaceAgentDefaults{}
+	}
+	layers := []*leapmuxv1.AgentDefaults{
+		agentDefaultsFor(svc.orgDefaults.Load().GetAgents(), provider),
+		agentDefaultsFor(workspace.GetAgents(), provider),
+		user,
+	}
+
+	out := requested.Clone()
+	for _, field := range []struct {
+		id  string
+		get func(*leapmuxv1.AgentDefaults) string
+	}{
+		{agent.OptionIDModel, (*leapmuxv1.AgentDefaults).GetModel},
+		{agent.OptionIDEffort, (*leapmuxv1.AgentDefaults).GetEffort},
+		{agent.OptionIDPermissionMode, (*leapmuxv1.AgentDefaults).GetPermissionMode},
+	} {
+		if out[field.id] != "" {
+			continue
+		}
+		for _, layer := range layers {
+			v := field.get(layer)
+			if v == "" {
+				continue
+			}
+			if field.id == agent.OptionIDPermissionMode && !svc.permissionModeUsable(provider, v) {
+				continue
+			}
+			out[field.id] = v
+			break
+		}
+	}
+	return out
+}
+
+// permissionModeUsable reports whether a defaulted permission mode would
+// pass the checks OpenAgent applies to a requested one.
+func (svc *Service) permissionModeUsable(provider leapmuxv1.AgentProvider, mode string) bool {
+	candidate := OptionMap{agent.OptionIDPermissionMode: mode}
+	return svc.permissionGuardrails().checkLaunchPermissionMode(candidate) == nil &&
+		agent.ValidateLaunchOptions(provider, candidate) == nil
+}
+
+// validateWorkspaceAgentDefaults rejects a second entry for a provider
+// already covered -- agentDefaultsFor would silently ignore it -- and
+// values past maxAgentDefaultLen.
+func validateWorkspaceAgentDefaults(defaults *leapmuxv1.WorkspaceAgentDefaults) error {
+	seen := make(map[leapmuxv1.AgentProvider]bool)
+	for _, d := range defaults.GetAgents() {
+		p := d.GetAgentProvider()
+		if _, ok := leapmuxv1.AgentProvider_name[int32(p)]; !ok || p == leapmuxv1.AgentProvider_AGENT_PROVIDER_UNSPECIFIED {
+			return errors.New("agent defaults provider is required")
+		}
+		if seen[p] {
+			return fmt.Errorf("duplicate agent defaults for %s", p)
+		}
+		seen[p] = true
+		if len(d.GetModel()) > maxAgentDefaultLen || len(d.GetEffort()) > maxAgentDefaultLen || len(d.GetPermissionMode()) > maxAgentDefaultLen {
+			return fmt.Errorf("agent defaults values must be at most %d bytes", maxAgentDefaultLen)
+		}
+	}
+	return nil
+}
+
+// loadWorkspaceAgentDefaults reads the workspace's stored defaults. A
+// workspace with no row yields empty defaults, not an error.
+func loadWorkspaceAgentDefaults(ctx context.Context, queries *db.Queries, workspaceID string) (*leapmuxv1.WorkspaceAgentDefaults, error) {
+	raw, err := queries.GetWorkspaceAgentDefaults(ctx, workspaceID)
+	if errors.Is(err, sql.ErrNoRows) {
+		return &leapmuxv1.WorkspaceAgentDefaults{}, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	defaults := &leapmuxv1.WorkspaceAgentDefaults{}
+	if err := protojson.Unmarshal([]byte(raw), defaults); err != nil {
+		return nil, fmt.Errorf("decode agent defaults: %w", err)
+	}
+	return defaults, nil
+}
+
+// registerAgentDefaultsHandlers registers the per-workspace agent defaults
+// RPCs.
+func registerAgentDefaultsHandlers(d registrar, svc *Service) {
+	registerWorkspaceGated(d, "GetWorkspaceAgentDefaults",
+		func(ctx context.Context, _ userid.UserID, r *leapmuxv1.GetWorkspaceAgentDefaultsRequest, sender channel.ResponseWriter) {
+			defaults, err := loadWorkspaceAgentDefaults(ctx, svc.Queries, r.GetWorkspaceId())
+			if err != nil {
+				slog.Error("failed to load agent defaults", "workspace_id", r.GetWorkspaceId(), "error", err)
+				sendInternalError(sender, "failed to load agent defaults")
+				return
+			}
+			sendProtoResponse(sender, &leapmuxv1.GetWorkspaceAgentDefaultsResponse{Defaults: defaults})
+		})
+
+	registerWorkspaceGated(d, "SetWorkspaceAgentDefaults",
+		func(_ context.Context, _ userid.UserID, r *leapmuxv1.SetWorkspaceAgentDefaultsRequest, sender channel.ResponseWriter) {
+			defaults := r.GetDefaults()
+			if defaults == nil {
+				defaults = &leapmuxv1.WorkspaceAgentDefaults{}
+			}
+			if err := validateWorkspaceAgentDefaults(defaults); err != nil {
+				sendInvalidArgument(sender, err.Error())
+				return
+			}
+
+			// Empty defaults leave everything to the other layers; drop the
+			// row rather than storing defaults that say nothing.
+			var err error
+			if len(defaults.GetAgents()) == 0 {
+				err = svc.Queries.DeleteWorkspaceAgentDefaults(bgCtx(), r.GetWorkspaceId())
+			} else {
+				var raw []byte
+				raw, err = protojson.Marshal(defaults)
+				if err == nil {
+					err = svc.Queries.UpsertWorkspaceAgentDefaults(bgCtx(), db.UpsertWorkspaceAgentDefaultsParams{
+						WorkspaceID: r.GetWorkspaceId(),
+						Defaults:    string(raw),
+					})
+				}
+			}
+			if err != nil {
+				slog.Error("failed to save agent defaults", "workspace_id", r.GetWorkspaceId(), "error", err)
+				sendInternalError(sender, "failed to save agent defaults")
+				return
+			}
+			sendProtoResponse(sender, &leapmuxv1.SetWorkspaceAgentDefaultsResponse{Defaults: defaults})
+		})
+}
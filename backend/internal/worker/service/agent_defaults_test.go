@@ -0,0 +1,141 @@
+package service
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/protobuf/proto"
+
+	leapmuxv1 "github.com/leapmux/leapmux/generated/proto/leapmux/v1"
+	"github.com/leapmux/leapmux/internal/worker/agent"
+)
+
+const claudeCode = leapmuxv1.AgentProvider_AGENT_PROVIDER_CLAUDE_CODE
+
+func TestApplyAgentDefaults_OrgOverridesWorkspaceOverridesUser(t *testing.T) {
+	svc, d, w := setupTestService(t, withWorkspaces("ws-1"))
+
+	svc.SetOrgDefaults(&leapmuxv1.OrgDefaults{Agents: []*leapmuxv1.AgentDefaults{
+		{AgentProvider: claudeCode, Model: "org-model"},
+		{AgentProvider: leapmuxv1.AgentProvider_AGENT_PROVIDER_CODEX, Effort: "codex-only"},
+	}})
+	dispatch(d, "SetWorkspaceAgentDefaults", &leapmuxv1.SetWorkspaceAgentDefaultsRequest{
+		WorkspaceId: "ws-1",
+		Defaults: &leapmuxv1.WorkspaceAgentDefaults{Agents: []*leapmuxv1.AgentDefaults{
+			{AgentProvider: claudeCode, Model: "ws-model", Effort: "high"},
+		}},
+	}, w)
+	require.Empty(t, w.errors)
+	user := &leapmuxv1.AgentDefaults{Model: "user-model", Effort: "low", PermissionMode: agent.PermissionModePlan}
+
+	got := svc.applyAgentDefaults(context.Background(), "ws-1", claudeCode, user, OptionMap{})
+	assert.Equal(t, "org-model", got[agent.OptionIDModel])
+	assert.Equal(t, "high", got[agent.OptionIDEffort], "the org sets no Claude effort, so the workspace's applies")
+	assert.Equal(t, agent.PermissionModePlan, got[agent.OptionIDPermissionMode], "only the user sets a permission mode")
+
+	got = svc.applyAgentDefaults(context.Background(), "ws-1", claudeCode, user, OptionMap{agent.OptionIDModel: "explicit"})
+	assert.Equal(t, "explicit", got[agent.OptionIDModel], "a requested value beats every default")
+}
+
+func TestApplyAgentDefaults_SkipsRefusedPermissionMode(t *testing.T) {
+	svc, _, _ := setupTestService(t, withWorkspaces("ws-1"))
+	svc.PermissionGuardrails = PermissionGuardrails{Forbidden: []string{agent.PermissionModeBypassPermissions}}
+	svc.SetOrgDefaults(&leapmuxv1.OrgDefaults{Agents: []*leapmuxv1.AgentDefaults{
+		{AgentProvider: claudeCode, PermissionMode: agent.PermissionModeBypassPermissions},
+	}})
+
+	got := svc.applyAgentDefaults(context.Background(), "ws-1", claudeCode,
+		&leapmuxv1.AgentDefaults{PermissionMode: agent.PermissionModeAcceptEdits}, OptionMap{})
+	assert.Equal(t, agent.PermissionModeAcceptEdits, got[agent.OptionIDPermissionMode])
+
+	got = svc.applyAgentDefaults(context.Background(), "ws-1", claudeCode,
+		&leapmuxv1.AgentDefaults{PermissionMode: "no-such-mode"}, OptionMap{})
+	assert.Empty(t, got[agent.OptionIDPermissionMode], "a mode the provider lacks is skipped too")
+}
+
+func TestOpenAgent_AppliesAgentDefaults(t *testing.T) {
+	svc, d, w := setupTestService(t, withWorkspaces("ws-1"))
+	svc.startAgentFn = func(context.Context, agent.Options, agent.OutputSink) (map[string]string, error) {
+		return map[string]string{}, nil
+	}
+	svc.SetOrgDefaults(&leapmuxv1.OrgDefaults{Agents: []*leapmuxv1.AgentDefaults{
+		{AgentProvider: claudeCode, Model: "org-model"},
+	}})
+
+	dispatch(d, "OpenAgent", &leapmuxv1.OpenAgentRequest{
+		WorkspaceId:   "ws-1",
+		WorkingDir:    t.TempDir(),
+		AgentProvider: claudeCode,
+		UserDefaults:  &leapmuxv1.AgentDefaults{Model: "user-model", Effort: "medium"},
+	}, w)
+	require.Empty(t, w.errors)
+	require.Len(t, w.responses, 1)
+
+	var resp leapmuxv1.OpenAgentResponse
+	require.NoError(t, proto.Unmarshal(w.responses[0].GetPayload(), &resp))
+	row, err := svc.Queries.GetAgentByID(context.Background(), resp.GetAgent().GetId())
+	require.NoError(t, err)
+	opts := loadOptions(row.Options, row.AgentProvider)
+	assert.Equal(t, "org-model", opts[agent.OptionIDModel])
+	assert.Equal(t, "medium", opts[agent.OptionIDEffort])
+}
+
+func TestWorkspaceAgentDefaults_SetAndGet(t *testing.T) {
+	_, d, w := setupTestService(t, withWorkspaces("ws-1"))
+
+	defaults := &leapmuxv1.WorkspaceAgentDefaults{Agents: []*leapmuxv1.AgentDefaults{
+		{AgentProvider: claudeCode, Model: "opus"},
+	}}
+	dispatch(d, "SetWorkspaceAgentDefaults", &leapmuxv1.SetWorkspaceAgentDefaultsRequest{
+		WorkspaceId: "ws-1",
+		Defaults:    defaults,
+	}, w)
+	dispatch(d, "GetWorkspaceAgentDefaults", &leapmuxv1.GetWorkspaceAgentDefaultsRequest{WorkspaceId: "ws-1"}, w)
+	require.Empty(t, w.errors)
+	require.Len(t, w.responses, 2)
+	var resp leapmuxv1.GetWorkspaceAgentDefaultsResponse
+	require.NoError(t, proto.Unmarshal(w.responses[1].GetPayload(), &resp))
+	assert.True(t, proto.Equal(defaults, resp.GetDefaults()))
+
+	// Empty defaults clear the stored ones.
+	dispatch(d, "SetWorkspaceAgentDefaults", &leapmuxv1.SetWorkspaceAgentDefaultsRequest{WorkspaceId: "ws-1"}, w)
+	dispatch(d, "GetWorkspaceAgentDefaults", &leapmuxv1.GetWorkspaceAgentDefaultsRequest{WorkspaceId: "ws-1"}, w)
+	require.Empty(t, w.errors)
+	require.Len(t, w.responses, 4)
+	require.NoError(t, proto.Unmarshal(w.responses[3].GetPayload(), &resp))
+	assert.Empty(t, resp.GetDefaults().GetAgents())
+}
+
+func TestWorkspaceAgentDefaults_RejectsDuplicateProvider(t *testing.T) {
+	_, d, w := setupTestService(t, withWorkspaces("ws-1"))
+
+	dispatch(d, "SetWorkspaceAgentDefaults", &leapmuxv1.SetWorkspaceAgentDefaultsRequest{
+		WorkspaceId: "ws-1",
+		Defaults: &leapmuxv1.WorkspaceAgentDefaults{Agents: []*leapmuxv1.AgentDefaults{
+			{AgentProvider: claudeCode, Model: "opus"},
+			{AgentProvider: claudeCode, Model: "sonnet"},
+		}},
+	}, w)
+	require.Len(t, w.errors, 1)
+	assert.Equal(t, codeInvalidArgument, w.errors[0].code)
+}
+
+func TestSetOrgDefaults_DropsInvalidAutoContinue(t *testing.T) {
+	svc, _, _ := setupTestService(t)
+
+	svc.SetOrgDefaults(&leapmuxv1.OrgDefaults{
+		AutoContinue: &leapmuxv1.RetryPolicy{Rules: []*leapmuxv1.RetryRule{{
+			Condition:   leapmuxv1.RetryCondition_RETRY_CONDITION_API_ERROR,
+			MaxAttempts: maxRetryAttempts + 1,
+		}}},
+		ClosedRetentionDays: 30,
+	})
+	assert.Nil(t, svc.orgRetryPolicy())
+	assert.Equal(t, 30*24*time.Hour, svc.ClosedRetention(), "the rest of the defaults still apply")
+
+	svc.SetOrgDefaults(&leapmuxv1.OrgDefaults{})
+	assert.Equal(t, cleanupRetention, svc.ClosedRetention())
+}
@@ -0,0 +1,64 @@
+package service
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	leapmuxv1 "github.com/leapmux/leapmux/generated/proto/leapmux/v1"
+	"github.com/leapmux/leapmux/internal/worker/agent"
+)
+
+// inactiveBroadcasts counts the INACTIVE status changes w has received.
+func inactiveBroadcasts(t *testing.T, w *testResponseWriter) int {
+	t.Helper()
+	n := 0
+	for _, stream := range w.streamsSnapshot() {
+		if decodeWatchAgentEvent(t, stream).GetStatusChange().GetStatus() == leapmuxv1.AgentStatus_AGENT_STATUS_INACTIVE {
+			n++
+		}
+	}
+	return n
+}
+
+func TestReportAgentExit_BroadcastsInactiveWhenProcessDies(t *testing.T) {
+	svc, _, w := setupTestService(t, withWorkspaces("ws-1"))
+	svc.Agents.SetOnExit(func(agentID string, _ int, _ error) { svc.ReportAgentExit(agentID) })
+	row := seedGuardedAgent(t, svc, agent.PermissionModeDefault)
+	svc.Watchers.SetAgentWatches(w.channelID, []string{row.ID}, w)
+
+	_, err := svc.Agents.MockStartAgent(context.Background(), agent.Options{AgentID: row.ID, WorkingDir: row.WorkingDir},
+		svc.Output.NewSink(row.ID, row.AgentProvider))
+	require.NoError(t, err)
+
+	svc.Agents.StopAgent(row.ID)
+	require.Eventually(t, func() bool { return inactiveBroadcasts(t, w) == 1 }, 5*time.Second, 10*time.Millisecond)
+}
+
+func TestReportAgentExit_QuietWhenRestartReplacesProcess(t *testing.T) {
+	svc, _, w := setupTestService(t, withWorkspaces("ws-1"))
+	svc.Agents.SetOnExit(func(agentID string, _ int, _ error) { svc.ReportAgentExit(agentID) })
+	row := seedGuardedAgent(t, svc, agent.PermissionModeDefault)
+	svc.Watchers.SetAgentWatches(w.channelID, []string{row.ID}, w)
+	opts := agent.Options{AgentID: row.ID, WorkingDir: row.WorkingDir}
+	sink := svc.Output.NewSink(row.ID, row.AgentProvider)
+
+	_, err := svc.Agents.MockStartAgent(context.Background(), opts, sink)
+	require.NoError(t, err)
+
+	// Stop and start under the lifecycle lock, as restarts do.
+	unlock := svc.Agents.LockAgent(row.ID)
+	svc.Agents.StopAndWaitAgent(row.ID)
+	_, err = svc.Agents.MockStartAgent(context.Background(), opts, sink)
+	unlock()
+	require.NoError(t, err)
+	t.Cleanup(func() { svc.Agents.StopAgent(row.ID) })
+
+	// The exit report re-checks under the same lock; give it the chance.
+	svc.Agents.LockAgent(row.ID)()
+	time.Sleep(50 * time.Millisecond)
+	assert.Zero(t, inactiveBroadcasts(t, w))
+}
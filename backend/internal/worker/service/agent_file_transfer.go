@@ -0,0 +1,463 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	leapmuxv1 "github.com/leapmux/leapmux/generated/proto/leapmux/v1"
+	"github.com/leapmux/leapmux/internal/util/pathutil"
+	"github.com/leapmux/leapmux/internal/util/userid"
+	"github.com/leapmux/leapmux/internal/worker/channel"
+	db "github.com/leapmux/leapmux/internal/worker/generated/db"
+)
+
+const (
+	// maxAgentTransferBytes bounds a file moved into or out of an agent's
+	// working directory. The browser holds a download whole before saving
+	// it, and the transfers are for sharing fixtures, not datasets.
+	maxAgentTransferBytes = 100 << 20
+	// maxAgentTransferChunkBytes bounds one upload chunk or download page.
+	maxAgentTransferChunkBytes = 1 << 20
+)
+
+var errAgentPathOutside = errors.New("path must stay inside the agent's working directory")
+
+// resolveAgentPath resolves rel against an agent's working directory and
+// returns the absolute path with the cleaned, slash-separated rel. Its
+// directory is resolved through symlinks and must stay inside the working
+// directory; its last element is left alone, so it can name a file yet to
+// be created.
+func resolveAgentPath(workingDir, rel string) (string, string, error) {
+	if rel == "" {
+		return "", "", errors.New("path is required")
+	}
+	clean := filepath.Clean(filepath.FromSlash(rel))
+	if filepath.IsAbs(clean) || filepath.VolumeName(clean) != "" ||
+		clean == "." || clean == ".." || strings.HasPrefix(clean, ".."+string(filepath.Separator)) {
+		return "", "", errAgentPathOutside
+	}
+	root := pathutil.Canonicalize(filepath.Clean(workingDir))
+	dir := pathutil.Canonicalize(filepath.Join(root, filepath.Dir(clean)))
+	if !pathutil.HasPathPrefix(dir, root) {
+		return "", "", errAgentPathOutside
+	}
+	return filepath.Join(dir, filepath.Base(clean)), filepath.ToSlash(clean), nil
+}
+
+// broadcastFileTransfer reports a transfer's progress to the agent's
+// watchers.
+func (svc *Service) broadcastFileTransfer(ft *leapmuxv1.AgentFileTransfer) {
+	svc.Watchers.BroadcastAgentEvent(ft.GetAgentId(), &leapmuxv1.AgentEvent{
+		AgentId: ft.GetAgentId(),
+		Event:   &leapmuxv1.AgentEvent_FileTransfer{FileTransfer: ft},
+	})
+}
+
+// agentUpload is one StartAgentUpload in flight: the bytes so far, written
+// to a temporary file beside the destination so finishing is a rename.
+type agentUpload struct {
+	id        string
+	agentID   string
+	userID    userid.UserID
+	path      string
+	rel       string
+	overwrite bool
+	total     int64
+	// stopCtxWatch detaches the session-lifetime watcher.
+	stopCtxWatch func() bool
+
+	mu       sync.Mutex
+	tmp      *os.File
+	received int64
+	// ended is set once the upload is in place or abandoned.
+	ended bool
+}
+
+// progress builds the upload's AgentFileTransfer in state.
+func (u *agentUpload) progress(state leapmuxv1.FileTransferState, errMsg string) *leapmuxv1.AgentFileTransfer {
+	return &leapmuxv1.AgentFileTransfer{
+		AgentId:    u.agentID,
+		TransferId: u.id,
+		Direction:  leapmuxv1.FileTransferDirection_FILE_TRANSFER_DIRECTION_UPLOAD,
+		Path:       u.rel,
+		BytesDone:  u.received,
+		TotalBytes: u.total,
+		State:      state,
+		Error:      errMsg,
+	}
+}
+
+// uploadManager tracks the worker's uploads by client-chosen id. Like
+// downloadManager it is worker-lifetime and shared across channel sessions.
+type uploadManager struct {
+	svc *Service
+
+	mu      sync.Mutex
+	uploads map[string]*agentUpload
+	// canceled fences a CancelAgentUpload dispatched ahead of its
+	// StartAgentUpload.
+	canceled cancelMarkers
+}
+
+func newUploadManager(svc *Service) *uploadManager {
+	return &uploadManager{
+		svc:      svc,
+		uploads:  make(map[string]*agentUpload),
+		canceled: newCancelMarkers(),
+	}
+}
+
+// register installs u under its id, refusing an id that is live or was
+// cancelled before it started.
+func (m *uploadManager) register(u *agentUpload) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.canceled.sweep(time.Now())
+	if m.canceled.take(u.id) {
+		return errors.New("upload_id was canceled")
+	}
+	if _, exists := m.uploads[u.id]; exists {
+		return errors.New("upload_id is already in use")
+	}
+	m.uploads[u.id] = u
+	return nil
+}
+
+// get returns agentID's upload id, nil when there is none.
+func (m *uploadManager) get(id, agentID string) *agentUpload {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if u := m.uploads[id]; u != nil && u.agentID == agentID {
+		return u
+	}
+	return nil
+}
+
+func (m *uploadManager) removeIf(u *agentUpload) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if m.uploads[u.id] == u {
+		delete(m.uploads, u.id)
+	}
+}
+
+// endLocked marks u ended and forgets it, removing the partial file unless
+// the upload was placed. Called with u.mu held; false when u had already
+// ended.
+func (m *uploadManager) endLocked(u *agentUpload, placed bool) bool {
+	if u.ended {
+		return false
+	}
+	u.ended = true
+	if !placed {
+		_ = u.tmp.Close()
+		_ = os.Remove(u.tmp.Name())
+	}
+	u.stopCtxWatch()
+	m.removeIf(u)
+	return true
+}
+
+// abandon ends u without placing it and tells the watchers why.
+func (m *uploadManager) abandon(u *agentUpload, state leapmuxv1.FileTransferState, errMsg string) {
+	u.mu.Lock()
+	ended := m.endLocked(u, false)
+	ft := u.progress(state, errMsg)
+	u.mu.Unlock()
+	if ended {
+		slog.Info("agent upload abandoned", "upload_id", u.id, "agent_id", u.agentID, "path", u.rel, "state", state)
+		m.svc.broadcastFileTransfer(ft)
+	}
+}
+
+// place moves a complete upload into place and reports the outcome,
+// answering the failure if there is one. Called with u.mu held; releases
+// it.
+func (m *uploadManager) place(u *agentUpload, sender channel.ResponseWriter) bool {
+	err := u.tmp.Sync()
+	if err == nil {
+		err = u.tmp.Close()
+	}
+	if err == nil && !u.overwrite {
+		// Re-checked here: the file may have appeared since the start.
+		if _, statErr := os.Lstat(u.path); statErr == nil {
+			err = os.ErrExist
+		}
+	}
+	if err == nil {
+		err = os.Rename(u.tmp.Name(), u.path)
+	}
+	m.endLocked(u, err == nil)
+	ft := u.progress(leapmuxv1.FileTransferState_FILE_TRANSFER_STATE_DONE, "")
+	switch {
+	case errors.Is(err, os.ErrExist):
+		ft = u.progress(leapmuxv1.FileTransferState_FILE_TRANSFER_STATE_FAILED, "file already exists")
+	case err != nil:
+		ft = u.progress(leapmuxv1.FileTransferState_FILE_TRANSFER_STATE_FAILED, "failed to save file")
+	}
+	u.mu.Unlock()
+	m.svc.broadcastFileTransfer(ft)
+
+	switch {
+	case errors.Is(err, os.ErrExist):
+		sendFailedPrecondition(sender, "file already exists; set overwrite to replace it")
+		return false
+	case err != nil:
+		slog.Error("failed to place upload", "upload_id", u.id, "path", u.path, "error", err)
+		sendInternalError(sender, "failed to save file")
+		return false
+	}
+	slog.Info("file uploaded to agent",
+		"user_id", u.userID, "agent_id", u.agentID, "path", u.rel, "bytes", u.total)
+	return true
+}
+
+// start opens an upload into agentRow's working directory.
+func (m *uploadManager) start(ctx context.Context, userID userid.UserID, r *leapmuxv1.StartAgentUploadRequest, agentRow db.Agent, sender channel.ResponseWriter) {
+	switch {
+	case r.GetUploadId() == "":
+		sendInvalidArgument(sender, "upload_id is required")
+		return
+	case r.GetTotalSize() < 0 || r.GetTotalSize() > maxAgentTransferBytes:
+		sendInvalidArgument(sender, fmt.Sprintf("total_size must be between 0 and %d bytes", maxAgentTransferBytes))
+		return
+	}
+	path, rel, err := resolveAgentPath(agentRow.WorkingDir, r.GetPath())
+	if err != nil {
+		sendInvalidArgument(sender, err.Error())
+		return
+	}
+	if info, err := os.Lstat(path); err == nil {
+		if info.IsDir() {
+			sendInvalidArgument(sender, "path is a directory")
+			return
+		}
+		if !r.GetOverwrite() {
+			sendFailedPrecondition(sender, "file already exists; set overwrite to replace it")
+			return
+		}
+	}
+	tmp, err := os.CreateTemp(filepath.Dir(path), ".leapmux-upload-*")
+	if err != nil {
+		switch {
+		case os.IsNotExist(err):
+			sendNotFoundError(sender, "directory not found")
+		case os.IsPermission(err):
+			sendPermissionDenied(sender, "permission denied")
+		default:
+			slog.Error("failed to create upload file", "agent_id", agentRow.ID, "path", path, "error", err)
+			sendInternalError(sender, "failed to create file")
+		}
+		return
+	}
+
+	u := &agentUpload{
+		id:        r.GetUploadId(),
+		agentID:   agentRow.ID,
+		userID:    userID,
+		path:      path,
+		rel:       rel,
+		overwrite: r.GetOverwrite(),
+		total:     r.GetTotalSize(),
+		tmp:       tmp,
+	}
+	// The session context ends with the channel; an upload its client can
+	// no longer finish is abandoned rather than left on disk. Armed before
+	// register so abandon never races the assignment.
+	u.stopCtxWatch = context.AfterFunc(ctx, func() {
+		m.abandon(u, leapmuxv1.FileTransferState_FILE_TRANSFER_STATE_FAILED, "connection closed")
+	})
+	if err := m.register(u); err != nil {
+		u.stopCtxWatch()
+		_ = tmp.Close()
+		_ = os.Remove(tmp.Name())
+		sendInvalidArgument(sender, err.Error())
+		return
+	}
+	m.svc.broadcastFileTransfer(u.progress(leapmuxv1.FileTransferState_FILE_TRANSFER_STATE_IN_PROGRESS, ""))
+	if u.total == 0 {
+		u.mu.Lock()
+		if !m.place(u, sender) {
+			return
+		}
+	}
+	sendProtoResponse(sender, &leapmuxv1.StartAgentUploadResponse{UploadId: u.id, Path: rel})
+}
+
+// write appends one chunk to an upload, placing the file once it is whole.
+func (m *uploadManager) write(r *leapmuxv1.WriteAgentUploadChunkRequest, sender channel.ResponseWriter) {
+	u := m.get(r.GetUploadId(), r.GetAgentId())
+	if u == nil {
+		sendNotFoundError(sender, "upload not found")
+		return
+	}
+	data := r.GetData()
+	u.mu.Lock()
+	var refusal string
+	switch {
+	case u.ended:
+		u.mu.Unlock()
+		sendNotFoundError(sender, "upload not found")
+		return
+	case r.GetOffset() != u.received:
+		refusal = fmt.Sprintf("offset %d does not match the %d bytes received", r.GetOffset(), u.received)
+	case len(data) > maxAgentTransferChunkBytes:
+		refusal = fmt.Sprintf("chunk exceeds %d bytes", maxAgentTransferChunkBytes)
+	case u.received+int64(len(data)) > u.total:
+		refusal = "chunk runs past total_size"
+	}
+	if refusal != "" {
+		u.mu.Unlock()
+		sendInvalidArgument(sender, refusal)
+		return
+	}
+	if _, err := u.tmp.Write(data); err != nil {
+		m.endLocked(u, false)
+		ft := u.progress(leapmuxv1.FileTransferState_FILE_TRANSFER_STATE_FAILED, "failed to write file")
+		u.mu.Unlock()
+		slog.Error("failed to write upload chunk", "upload_id", u.id, "error", err)
+		m.svc.broadcastFileTransfer(ft)
+		sendInternalError(sender, "failed to write file")
+		return
+	}
+	u.received += int64(len(data))
+	received := u.received
+	if received < u.total {
+		ft := u.progress(leapmuxv1.FileTransferState_FILE_TRANSFER_STATE_IN_PROGRESS, "")
+		u.mu.Unlock()
+		m.svc.broadcastFileTransfer(ft)
+		sendProtoResponse(sender, &leapmuxv1.WriteAgentUploadChunkResponse{Received: received})
+		return
+	}
+	if m.place(u, sender) {
+		sendProtoResponse(sender, &leapmuxv1.WriteAgentUploadChunkResponse{Received: received, Done: true})
+	}
+}
+
+// cancel abandons agentID's upload id, or fences one still to start.
+func (m *uploadManager) cancel(id, agentID string) {
+	if u := m.get(id, agentID); u != nil {
+		m.abandon(u, leapmuxv1.FileTransferState_FILE_TRANSFER_STATE_CANCELED, "")
+		return
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	now := time.Now()
+	m.canceled.sweep(now)
+	if _, live := m.uploads[id]; !live {
+		m.canceled.mark(id, now.Add(staleCancelMarkerTTL))
+	}
+}
+
+// readAgentFile answers one ReadAgentFile page.
+func (svc *Service) readAgentFile(r *leapmuxv1.ReadAgentFileRequest, agentRow db.Agent, sender channel.ResponseWriter) {
+	path, rel, err := resolveAgentPath(agentRow.WorkingDir, r.GetPath())
+	if err != nil {
+		sendInvalidArgument(sender, err.Error())
+		return
+	}
+	// Unlike an upload's, a download's last element is followed, so a
+	// symlink out of the working directory is refused too.
+	if !pathutil.HasPathPrefix(pathutil.Canonicalize(path), pathutil.Canonicalize(filepath.Clean(agentRow.WorkingDir))) {
+		sendInvalidArgument(sender, errAgentPathOutside.Error())
+		return
+	}
+	f, err := os.Open(path)
+	if err != nil {
+		switch {
+		case os.IsNotExist(err):
+			sendNotFoundError(sender, "file not found")
+		case os.IsPermission(err):
+			sendPermissionDenied(sender, "permission denied")
+		default:
+			slog.Error("failed to open agent file", "agent_id", agentRow.ID, "path", path, "error", err)
+			sendInternalError(sender, "failed to open file")
+		}
+		return
+	}
+	defer func() { _ = f.Close() }()
+	info, err := f.Stat()
+	switch {
+	case err != nil:
+		sendInternalError(sender, "failed to stat file")
+		return
+	case info.IsDir():
+		sendInvalidArgument(sender, "path is a directory")
+		return
+	case info.Size() > maxAgentTransferBytes:
+		sendFailedPrecondition(sender, fmt.Sprintf("file is larger than the %d-byte transfer limit", maxAgentTransferBytes))
+		return
+	case r.GetOffset() < 0 || r.GetOffset() > info.Size():
+		sendInvalidArgument(sender, "offset is outside the file")
+		return
+	}
+	limit := r.GetLimit()
+	if limit <= 0 || limit > maxAgentTransferChunkBytes {
+		limit = maxAgentTransferChunkBytes
+	}
+	buf := make([]byte, min(limit, info.Size()-r.GetOffset()))
+	n, err := f.ReadAt(buf, r.GetOffset())
+	if err != nil && !errors.Is(err, io.EOF) {
+		slog.Error("failed to read agent file", "agent_id", agentRow.ID, "path", path, "error", err)
+		sendInternalError(sender, "failed to read file")
+		return
+	}
+
+	if r.GetTransferId() != "" {
+		done := r.GetOffset() + int64(n)
+		state := leapmuxv1.FileTransferState_FILE_TRANSFER_STATE_IN_PROGRESS
+		if done >= info.Size() {
+			state = leapmuxv1.FileTransferState_FILE_TRANSFER_STATE_DONE
+		}
+		svc.broadcastFileTransfer(&leapmuxv1.AgentFileTransfer{
+			AgentId:    agentRow.ID,
+			TransferId: r.GetTransferId(),
+			Direction:  leapmuxv1.FileTransferDirection_FILE_TRANSFER_DIRECTION_DOWNLOAD,
+			Path:       rel,
+			BytesDone:  done,
+			TotalBytes: info.Size(),
+			State:      state,
+		})
+	}
+	sendProtoResponse(sender, &leapmuxv1.ReadAgentFileResponse{
+		Path:      rel,
+		Content:   buf[:n],
+		TotalSize: info.Size(),
+	})
+}
+
+// registerAgentFileTransferHandlers registers the agent working-directory
+// transfer RPCs. They are agent-gated rather than owner-only: the working
+// directory is the workspace's to share, and every path is held inside it.
+func registerAgentFileTransferHandlers(d registrar, svc *Service) {
+	uploads := newUploadManager(svc)
+	registerAgentGated(d, "StartAgentUpload",
+		func(ctx context.Context, userID userid.UserID, r *leapmuxv1.StartAgentUploadRequest, agentRow db.Agent, sender channel.ResponseWriter) {
+			uploads.start(ctx, userID, r, agentRow, sender)
+		})
+	registerAgentGatedByID(d, "WriteAgentUploadChunk",
+		func(_ context.Context, _ userid.UserID, r *leapmuxv1.WriteAgentUploadChunkRequest, sender channel.ResponseWriter) {
+			uploads.write(r, sender)
+		})
+	registerAgentGatedByID(d, "CancelAgentUpload",
+		func(_ context.Context, _ userid.UserID, r *leapmuxv1.CancelAgentUploadRequest, sender channel.ResponseWriter) {
+			if r.GetUploadId() == "" {
+				sendInvalidArgument(sender, "upload_id is required")
+				return
+			}
+			uploads.cancel(r.GetUploadId(), r.GetAgentId())
+			sendProtoResponse(sender, &leapmuxv1.CancelAgentUploadResponse{})
+		})
+	registerAgentGated(d, "ReadAgentFile",
+		func(_ context.Context, _ userid.UserID, r *leapmuxv1.ReadAgentFileRequest, agentRow db.Agent, sender channel.ResponseWriter) {
+			svc.readAgentFile(r, agentRow, sender)
+		})
+}
@@ -0,0 +1,205 @@
+package service
+
+import (
+	"os"
+	"path/filepath"
+	"runtime"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	leapmuxv1 "github.com/leapmux/leapmux/generated/proto/leapmux/v1"
+)
+
+// fileTransfers returns the AgentFileTransfer events w has been sent.
+func fileTransfers(t *testing.T, w *testResponseWriter) []*leapmuxv1.AgentFileTransfer {
+	t.Helper()
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	var out []*leapmuxv1.AgentFileTransfer
+	for _, stream := range w.streams {
+		if ft := decodeWatchAgentEvent(t, stream).GetFileTransfer(); ft != nil {
+			out = append(out, ft)
+		}
+	}
+	return out
+}
+
+func TestAgentUpload_PlacesFileAndReportsProgress(t *testing.T) {
+	svc, d, _ := setupTestService(t, withWorkspaces("ws-1"))
+	agentRow := seedGuardedAgent(t, svc, "")
+	require.NoError(t, os.Mkdir(filepath.Join(agentRow.WorkingDir, "fixtures"), 0o755))
+	watcher := newTestWriter()
+	svc.Watchers.SetAgentWatches(watcher.channelID, []string{"agent-1"}, watcher)
+
+	w := newTestWriter()
+	dispatch(d, "StartAgentUpload", &leapmuxv1.StartAgentUploadRequest{
+		AgentId: "agent-1", UploadId: "up-1", Path: "fixtures/in.json", TotalSize: 10,
+	}, w)
+	require.Empty(t, w.errors)
+	assert.Equal(t, "fixtures/in.json", decodeResponse[leapmuxv1.StartAgentUploadResponse](t, w).GetPath())
+
+	for i, chunk := range []string{"hello", "world"} {
+		w := newTestWriter()
+		dispatch(d, "WriteAgentUploadChunk", &leapmuxv1.WriteAgentUploadChunkRequest{
+			AgentId: "agent-1", UploadId: "up-1", Offset: int64(i * 5), Data: []byte(chunk),
+		}, w)
+		require.Empty(t, w.errors)
+		resp := decodeResponse[leapmuxv1.WriteAgentUploadChunkResponse](t, w)
+		assert.EqualValues(t, (i+1)*5, resp.GetReceived())
+		assert.Equal(t, i == 1, resp.GetDone())
+	}
+
+	got, err := os.ReadFile(filepath.Join(agentRow.WorkingDir, "fixtures", "in.json"))
+	require.NoError(t, err)
+	assert.Equal(t, "helloworld", string(got))
+	entries, err := os.ReadDir(filepath.Join(agentRow.WorkingDir, "fixtures"))
+	require.NoError(t, err)
+	assert.Len(t, entries, 1, "no partial file is left behind")
+
+	events := fileTransfers(t, watcher)
+	require.Len(t, events, 3)
+	for i, want := range []struct {
+		done  int64
+		state leapmuxv1.FileTransferState
+	}{
+		{0, leapmuxv1.FileTransferState_FILE_TRANSFER_STATE_IN_PROGRESS},
+		{5, leapmuxv1.FileTransferState_FILE_TRANSFER_STATE_IN_PROGRESS},
+		{10, leapmuxv1.FileTransferState_FILE_TRANSFER_STATE_DONE},
+	} {
+		assert.Equal(t, "up-1", events[i].GetTransferId())
+		assert.Equal(t, leapmuxv1.FileTransferDirection_FILE_TRANSFER_DIRECTION_UPLOAD, events[i].GetDirection())
+		assert.Equal(t, "fixtures/in.json", events[i].GetPath())
+		assert.EqualValues(t, 10, events[i].GetTotalBytes())
+		assert.Equal(t, want.done, events[i].GetBytesDone())
+		assert.Equal(t, want.state, events[i].GetState())
+	}
+
+	// The file now exists: a second upload must ask to overwrite it.
+	w = newTestWriter()
+	dispatch(d, "StartAgentUpload", &leapmuxv1.StartAgentUploadRequest{
+		AgentId: "agent-1", UploadId: "up-2", Path: "fixtures/in.json",
+	}, w)
+	require.Len(t, w.errors, 1)
+	w = newTestWriter()
+	dispatch(d, "StartAgentUpload", &leapmuxv1.StartAgentUploadRequest{
+		AgentId: "agent-1", UploadId: "up-3", Path: "fixtures/in.json", Overwrite: true,
+	}, w)
+	require.Empty(t, w.errors)
+	got, err = os.ReadFile(filepath.Join(agentRow.WorkingDir, "fixtures", "in.json"))
+	require.NoError(t, err)
+	assert.Empty(t, got, "an empty upload lands at once")
+}
+
+func TestAgentUpload_RefusesBadRequests(t *testing.T) {
+	svc, d, _ := setupTestService(t, withWorkspaces("ws-1"))
+	seedGuardedAgent(t, svc, "")
+
+	for name, req := range map[string]*leapmuxv1.StartAgentUploadRequest{
+		"no upload id":   {Path: "f.txt", TotalSize: 1},
+		"no path":        {UploadId: "u", TotalSize: 1},
+		"parent escape":  {UploadId: "u", Path: "../f.txt", TotalSize: 1},
+		"absolute":       {UploadId: "u", Path: "/tmp/f.txt", TotalSize: 1},
+		"working dir":    {UploadId: "u", Path: ".", TotalSize: 1},
+		"missing parent": {UploadId: "u", Path: "nope/f.txt", TotalSize: 1},
+		"too large":      {UploadId: "u", Path: "f.txt", TotalSize: maxAgentTransferBytes + 1},
+	} {
+		t.Run(name, func(t *testing.T) {
+			req.AgentId = "agent-1"
+			w := newTestWriter()
+			dispatch(d, "StartAgentUpload", req, w)
+			require.Len(t, w.errors, 1)
+		})
+	}
+
+	w := newTestWriter()
+	dispatch(d, "StartAgentUpload", &leapmuxv1.StartAgentUploadRequest{
+		AgentId: "agent-1", UploadId: "u", Path: "f.txt", TotalSize: 4,
+	}, w)
+	require.Empty(t, w.errors)
+	for name, req := range map[string]*leapmuxv1.WriteAgentUploadChunkRequest{
+		"wrong offset":  {UploadId: "u", Offset: 1, Data: []byte("a")},
+		"past total":    {UploadId: "u", Data: []byte("abcde")},
+		"unknown id":    {UploadId: "other", Data: []byte("a")},
+		"oversize page": {UploadId: "u", Data: make([]byte, maxAgentTransferChunkBytes+1)},
+	} {
+		t.Run(name, func(t *testing.T) {
+			req.AgentId = "agent-1"
+			w := newTestWriter()
+			dispatch(d, "WriteAgentUploadChunk", req, w)
+			require.Len(t, w.errors, 1)
+		})
+	}
+}
+
+func TestAgentUpload_CancelRemovesPartialFile(t *testing.T) {
+	svc, d, _ := setupTestService(t, withWorkspaces("ws-1"))
+	agentRow := seedGuardedAgent(t, svc, "")
+	watcher := newTestWriter()
+	svc.Watchers.SetAgentWatches(watcher.channelID, []string{"agent-1"}, watcher)
+
+	w := newTestWriter()
+	dispatch(d, "StartAgentUpload", &leapmuxv1.StartAgentUploadRequest{
+		AgentId: "agent-1", UploadId: "u", Path: "f.txt", TotalSize: 4,
+	}, w)
+	dispatch(d, "WriteAgentUploadChunk", &leapmuxv1.WriteAgentUploadChunkRequest{
+		AgentId: "agent-1", UploadId: "u", Data: []byte("ab"),
+	}, w)
+	dispatch(d, "CancelAgentUpload", &leapmuxv1.CancelAgentUploadRequest{AgentId: "agent-1", UploadId: "u"}, w)
+	require.Empty(t, w.errors)
+
+	entries, err := os.ReadDir(agentRow.WorkingDir)
+	require.NoError(t, err)
+	assert.Empty(t, entries)
+	events := fileTransfers(t, watcher)
+	require.NotEmpty(t, events)
+	assert.Equal(t, leapmuxv1.FileTransferState_FILE_TRANSFER_STATE_CANCELED, events[len(events)-1].GetState())
+
+	w = newTestWriter()
+	dispatch(d, "WriteAgentUploadChunk", &leapmuxv1.WriteAgentUploadChunkRequest{
+		AgentId: "agent-1", UploadId: "u", Offset: 2, Data: []byte("cd"),
+	}, w)
+	require.Len(t, w.errors, 1)
+}
+
+func TestReadAgentFile_PagesWithinWorkingDir(t *testing.T) {
+	svc, d, _ := setupTestService(t, withWorkspaces("ws-1"))
+	agentRow := seedGuardedAgent(t, svc, "")
+	require.NoError(t, os.WriteFile(filepath.Join(agentRow.WorkingDir, "out.txt"), []byte("abc"), 0o644))
+	watcher := newTestWriter()
+	svc.Watchers.SetAgentWatches(watcher.channelID, []string{"agent-1"}, watcher)
+
+	read := func(offset int64) *leapmuxv1.ReadAgentFileResponse {
+		w := newTestWriter()
+		dispatch(d, "ReadAgentFile", &leapmuxv1.ReadAgentFileRequest{
+			AgentId: "agent-1", Path: "./out.txt", Offset: offset, Limit: 2, TransferId: "dl-1",
+		}, w)
+		require.Empty(t, w.errors)
+		return decodeResponse[leapmuxv1.ReadAgentFileResponse](t, w)
+	}
+	first := read(0)
+	assert.Equal(t, "out.txt", first.GetPath())
+	assert.Equal(t, "ab", string(first.GetContent()))
+	assert.EqualValues(t, 3, first.GetTotalSize())
+	assert.Equal(t, "c", string(read(2).GetContent()))
+
+	events := fileTransfers(t, watcher)
+	require.Len(t, events, 2)
+	assert.Equal(t, leapmuxv1.FileTransferDirection_FILE_TRANSFER_DIRECTION_DOWNLOAD, events[1].GetDirection())
+	assert.EqualValues(t, 3, events[1].GetBytesDone())
+	assert.Equal(t, leapmuxv1.FileTransferState_FILE_TRANSFER_STATE_DONE, events[1].GetState())
+
+	outside := filepath.Join(t.TempDir(), "secret.txt")
+	require.NoError(t, os.WriteFile(outside, []byte("secret"), 0o644))
+	refused := []string{"../secret.txt", outside, "missing.txt"}
+	if runtime.GOOS != "windows" {
+		require.NoError(t, os.Symlink(outside, filepath.Join(agentRow.WorkingDir, "link.txt")))
+		refused = append(refused, "link.txt")
+	}
+	for _, path := range refused {
+		w := newTestWriter()
+		dispatch(d, "ReadAgentFile", &leapmuxv1.ReadAgentFileRequest{AgentId: "agent-1", Path: path}, w)
+		assert.Len(t, w.errors, 1, path)
+	}
+}
@@ -0,0 +1,56 @@
+package service
+
+import (
+	"log/slog"
+
+	db "github.com/leapmux/leapmux/internal/worker/generated/db"
+)
+
+// maxIdempotencyKeyLen caps SendAgentMessageRequest.idempotency_key.
+const maxIdempotencyKeyLen = 128
+
+// claimAgentInputKey claims key for agentID on behalf of the send that will
+// create messageID. It returns true when this send won and must be
+// delivered; otherwise it returns the message id the earlier send created.
+//
+// The claim is a durable row (agent_input_keys), so a retry straddling a
+// subprocess or worker restart is still recognized. Like
+// claimControlResponseAnswer it fails OPEN on a query error: risking a
+// duplicate prompt beats dropping the user's message on a transient DB error.
+func (svc *Service) claimAgentInputKey(agentID, key, messageID string) (string, bool) {
+	rows, err := svc.Queries.ClaimAgentInputKey(bgCtx(), db.ClaimAgentInputKeyParams{
+		AgentID:        agentID,
+		IdempotencyKey: key,
+		MessageID:      messageID,
+	})
+	if err != nil {
+		slog.Warn("claim agent input key", "agent_id", agentID, "error", err)
+		return "", true
+	}
+	if rows > 0 {
+		return "", true
+	}
+	originalID, err := svc.Queries.GetAgentInputKeyMessageID(bgCtx(), db.GetAgentInputKeyMessageIDParams{
+		AgentID:        agentID,
+		IdempotencyKey: key,
+	})
+	if err != nil {
+		slog.Warn("look up agent input key", "agent_id", agentID, "error", err)
+	}
+	return originalID, false
+}
+
+// releaseAgentInputKey drops key's claim after the send that made it failed
+// before persisting anything, so the caller's retry is delivered. An empty
+// key is a no-op.
+func (svc *Service) releaseAgentInputKey(agentID, key string) {
+	if key == "" {
+		return
+	}
+	if err := svc.Queries.ReleaseAgentInputKey(bgCtx(), db.ReleaseAgentInputKeyParams{
+		AgentID:        agentID,
+		IdempotencyKey: key,
+	}); err != nil {
+		slog.Warn("release agent input key", "agent_id", agentID, "error", err)
+	}
+}
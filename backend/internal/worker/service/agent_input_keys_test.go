@@ -0,0 +1,83 @@
+package service
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	leapmuxv1 "github.com/leapmux/leapmux/generated/proto/leapmux/v1"
+	db "github.com/leapmux/leapmux/internal/worker/generated/db"
+)
+
+func countAgentMessages(t *testing.T, svc *Service, agentID string) int {
+	t.Helper()
+	msgs, err := svc.Queries.ListAllMessagesByAgentID(context.Background(), db.ListAllMessagesByAgentIDParams{
+		AgentID: agentID,
+		Seq:     0,
+	})
+	require.NoError(t, err)
+	return len(msgs)
+}
+
+func TestSendAgentMessage_IdempotencyKeySuppressesRetry(t *testing.T) {
+	svc, d, w := setupTestService(t, withWorkspaces("ws-1"))
+	seedGuardedAgent(t, svc, "")
+
+	req := &leapmuxv1.SendAgentMessageRequest{AgentId: "agent-1", Content: "run the tests", IdempotencyKey: "key-1"}
+	dispatch(d, "SendAgentMessage", req, w)
+	require.Empty(t, w.errors)
+	first := decodeResponse[leapmuxv1.SendAgentMessageResponse](t, w)
+	assert.False(t, first.GetDuplicateSuppressed())
+	require.NotEmpty(t, first.GetMessageId())
+
+	dispatch(d, "SendAgentMessage", req, w)
+	require.Empty(t, w.errors)
+	retry := decodeResponse[leapmuxv1.SendAgentMessageResponse](t, w)
+	assert.True(t, retry.GetDuplicateSuppressed())
+	assert.Equal(t, first.GetMessageId(), retry.GetMessageId(), "the retry is acked with the original message")
+	assert.Equal(t, 1, countAgentMessages(t, svc, "agent-1"), "the retry must not persist a second message")
+}
+
+func TestSendAgentMessage_DistinctKeysAndNoKeyAreDelivered(t *testing.T) {
+	svc, d, w := setupTestService(t, withWorkspaces("ws-1"))
+	seedGuardedAgent(t, svc, "")
+
+	for _, key := range []string{"key-1", "key-2", "", ""} {
+		dispatch(d, "SendAgentMessage", &leapmuxv1.SendAgentMessageRequest{AgentId: "agent-1", Content: "hi", IdempotencyKey: key}, w)
+		require.Empty(t, w.errors)
+		assert.False(t, decodeResponse[leapmuxv1.SendAgentMessageResponse](t, w).GetDuplicateSuppressed())
+	}
+	assert.Equal(t, 4, countAgentMessages(t, svc, "agent-1"))
+}
+
+func TestSendAgentMessage_IdempotencyKeyTooLong(t *testing.T) {
+	svc, d, w := setupTestService(t, withWorkspaces("ws-1"))
+	seedGuardedAgent(t, svc, "")
+
+	dispatch(d, "SendAgentMessage", &leapmuxv1.SendAgentMessageRequest{
+		AgentId:        "agent-1",
+		Content:        "hi",
+		IdempotencyKey: strings.Repeat("k", maxIdempotencyKeyLen+1),
+	}, w)
+	require.Len(t, w.errors, 1)
+	assert.Contains(t, w.errors[0].message, "idempotency_key")
+	assert.Zero(t, countAgentMessages(t, svc, "agent-1"))
+}
+
+func TestReleaseAgentInputKey_AllowsRetry(t *testing.T) {
+	svc, _, _ := setupTestService(t, withWorkspaces("ws-1"))
+	seedGuardedAgent(t, svc, "")
+
+	_, claimed := svc.claimAgentInputKey("agent-1", "key-1", "msg-1")
+	require.True(t, claimed)
+	originalID, claimed := svc.claimAgentInputKey("agent-1", "key-1", "msg-2")
+	require.False(t, claimed)
+	assert.Equal(t, "msg-1", originalID)
+
+	svc.releaseAgentInputKey("agent-1", "key-1")
+	_, claimed = svc.claimAgentInputKey("agent-1", "key-1", "msg-3")
+	assert.True(t, claimed, "a released key is claimable again")
+}
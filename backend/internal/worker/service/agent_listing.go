@@ -0,0 +1,250 @@
+package service
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"log/slog"
+	"strings"
+	"time"
+
+	leapmuxv1 "github.com/leapmux/leapmux/generated/proto/leapmux/v1"
+	"github.com/leapmux/leapmux/internal/util/sqltime"
+	"github.com/leapmux/leapmux/internal/util/timefmt"
+	"github.com/leapmux/leapmux/internal/util/userid"
+	"github.com/leapmux/leapmux/internal/worker/channel"
+	db "github.com/leapmux/leapmux/internal/worker/generated/db"
+	"github.com/leapmux/leapmux/internal/worker/gitutil"
+)
+
+const (
+	// defaultAgentPageSize is the ListAllAgents page size when the request
+	// leaves page.limit unset.
+	defaultAgentPageSize = 50
+	// maxAgentPageSize caps page.limit.
+	maxAgentPageSize = 200
+)
+
+// errInvalidAgentCursor is returned by parseAgentCursor for any malformed
+// cursor so the handler can answer InvalidArgument.
+var errInvalidAgentCursor = errors.New("invalid cursor")
+
+// agentCursor is the keyset position of the last agent on the previous
+// ListAllAgents page. It is encoded the way the hub encodes its list cursors
+// (RFC 3339 nano time, "_", id); ids never contain "_".
+type agentCursor struct {
+	createdAt time.Time
+	id        string
+}
+
+func encodeAgentCursor(createdAt time.Time, id string) string {
+	return createdAt.UTC().Format(time.RFC3339Nano) + "_" + id
+}
+
+// parseAgentCursor decodes a cursor built by encodeAgentCursor. An empty
+// cursor is the first page.
+func parseAgentCursor(s string) (agentCursor, error) {
+	if s == "" {
+		return agentCursor{}, nil
+	}
+	ts, id, ok := strings.Cut(s, "_")
+	if !ok || id == "" {
+		return agentCursor{}, errInvalidAgentCursor
+	}
+	t, err := time.Parse(time.RFC3339Nano, ts)
+	if err != nil {
+		return agentCursor{}, errInvalidAgentCursor
+	}
+	return agentCursor{createdAt: t, id: id}, nil
+}
+
+// parseActivityBound parses an optional RFC 3339 activity filter bound.
+func parseActivityBound(s string) (time.Time, error) {
+	if s == "" {
+		return time.Time{}, nil
+	}
+	return time.Parse(time.RFC3339Nano, s)
+}
+
+// agentListFilter holds the ListAllAgents filters SQL cannot apply: status
+// is derived from the live subprocess table, and last activity is a
+// per-row subquery the keyset cannot bound.
+type agentListFilter struct {
+	statuses     map[leapmuxv1.AgentStatus]bool
+	activeAfter  time.Time
+	activeBefore time.Time
+}
+
+func (f *agentListFilter) matches(status leapmuxv1.AgentStatus, lastActivity time.Time) bool {
+	if len(f.statuses) > 0 && !f.statuses[status] {
+		return false
+	}
+	if !f.activeAfter.IsZero() && lastActivity.Before(f.activeAfter) {
+		return false
+	}
+	if !f.activeBefore.IsZero() && !lastActivity.Before(f.activeBefore) {
+		return false
+	}
+	return true
+}
+
+// listedAgent is one ListAllAgents match with the listing metadata the
+// agents row does not carry.
+type listedAgent struct {
+	agent        db.Agent
+	isRunning    bool
+	lastActivity time.Time
+}
+
+func agentFromPageRow(row *db.ListAgentsPageRow) db.Agent {
+	return db.Agent{
+		ID:              row.ID,
+		WorkspaceID:     row.WorkspaceID,
+		WorkingDir:      row.WorkingDir,
+		HomeDir:         row.HomeDir,
+		PlanFilePath:    row.PlanFilePath,
+		PlanTitle:       row.PlanTitle,
+		Title:           row.Title,
+		AgentSessionID:  row.AgentSessionID,
+		Resumed:         row.Resumed,
+		Options:         row.Options,
+		OptionGroups:    row.OptionGroups,
+		AgentProvider:   row.AgentProvider,
+		SessionStartSeq: row.SessionStartSeq,
+		MessageSeqHwm:   row.MessageSeqHwm,
+		StartupError:    row.StartupError,
+		CreatedAt:       row.CreatedAt,
+		ClosedAt:        row.ClosedAt,
+		CreatedBy:       row.CreatedBy,
+	}
+}
+
+// listAgentsPage collects up to limit+1 agents matching the request, starting
+// after cursor. SQL applies the workspace, closed, creator, and model filters;
+// status and activity are checked here, so a sparse match pulls further
+// batches until the page fills or the table runs out.
+func (svc *Service) listAgentsPage(ctx context.Context, wsIDs []string, r *leapmuxv1.ListAllAgentsRequest, filter *agentListFilter, cursor agentCursor, limit int) ([]listedAgent, error) {
+	wsIDsJSON, err := json.Marshal(wsIDs)
+	if err != nil {
+		return nil, err
+	}
+	var matched []listedAgent
+	for {
+		rows, err := svc.Queries.ListAgentsPage(ctx, db.ListAgentsPageParams{
+			WorkspaceIds:   string(wsIDsJSON),
+			IncludeClosed:  r.GetIncludeClosed(),
+			CreatedBy:      r.GetCreatedBy(),
+			Model:          r.GetModel(),
+			AfterID:        cursor.id,
+			AfterCreatedAt: sqltime.NewSQLiteTime(cursor.createdAt),
+			RowLimit:       int64(limit + 1),
+		})
+		if err != nil {
+			return nil, err
+		}
+		for i := range rows {
+			a := agentFromPageRow(&rows[i])
+			isRunning := svc.Agents.HasAgent(a.ID)
+			status, _, _ := svc.deriveAgentStatus(&a, isRunning)
+			lastActivity, err := time.Parse(time.RFC3339Nano, rows[i].LastActivityAt)
+			if err != nil {
+				lastActivity = a.CreatedAt.Time
+			}
+			if !filter.matches(status, lastActivity) {
+				continue
+			}
+			matched = append(matched, listedAgent{agent: a, isRunning: isRunning, lastActivity: lastActivity})
+			if len(matched) > limit {
+				return matched, nil
+			}
+		}
+		if len(rows) <= limit {
+			return matched, nil
+		}
+		last := rows[len(rows)-1]
+		cursor = agentCursor{createdAt: last.CreatedAt.Time, id: last.ID}
+	}
+}
+
+func registerAgentListingHandlers(d registrar, svc *Service) {
+	// ListAllAgents pages through every agent in the caller's accessible
+	// workspaces. Like ListAgents it filters by AccessibleSet() rather than
+	// rejecting: a caller with no workspaces on this worker sees none.
+	registerSetFiltered(d, "ListAllAgents", func(ctx context.Context, _ userid.UserID, req *leapmuxv1.InnerRpcRequest, sender channel.ResponseWriter) {
+		var r leapmuxv1.ListAllAgentsRequest
+		if err := unmarshalRequest(req, &r); err != nil {
+			sendInvalidArgument(sender, "invalid request")
+			return
+		}
+
+		cursor, err := parseAgentCursor(r.GetPage().GetCursor())
+		if err != nil {
+			sendInvalidArgument(sender, "invalid cursor")
+			return
+		}
+		filter := agentListFilter{}
+		if filter.activeAfter, err = parseActivityBound(r.GetActiveAfter()); err != nil {
+			sendInvalidArgument(sender, "active_after must be an RFC 3339 timestamp")
+			return
+		}
+		if filter.activeBefore, err = parseActivityBound(r.GetActiveBefore()); err != nil {
+			sendInvalidArgument(sender, "active_before must be an RFC 3339 timestamp")
+			return
+		}
+		if len(r.GetStatuses()) > 0 {
+			filter.statuses = make(map[leapmuxv1.AgentStatus]bool, len(r.GetStatuses()))
+			for _, s := range r.GetStatuses() {
+				filter.statuses[s] = true
+			}
+		}
+		limit := int(r.GetPage().GetLimit())
+		if limit <= 0 {
+			limit = defaultAgentPageSize
+		}
+		limit = min(limit, maxAgentPageSize)
+
+		accessible := svc.AuthorizerFor(sender.ChannelID()).AccessibleSet()
+		wsIDs := make([]string, 0, len(accessible))
+		for wsID, ok := range accessible {
+			if ok {
+				wsIDs = append(wsIDs, wsID)
+			}
+		}
+		if len(wsIDs) == 0 {
+			sendProtoResponse(sender, &leapmuxv1.ListAllAgentsResponse{Page: &leapmuxv1.PageResponse{}})
+			return
+		}
+
+		matched, err := svc.listAgentsPage(ctx, wsIDs, &r, &filter, cursor, limit)
+		if err != nil {
+			slog.Error("failed to list all agents", "error", err)
+			sendInternalError(sender, "failed to list agents")
+			return
+		}
+		page := &leapmuxv1.PageResponse{}
+		if len(matched) > limit {
+			matched = matched[:limit]
+			last := matched[limit-1].agent
+			page.HasMore = true
+			page.NextCursor = encodeAgentCursor(last.CreatedAt.Time, last.ID)
+		}
+
+		workingDirs := make([]string, len(matched))
+		for i := range matched {
+			workingDirs[i] = matched[i].agent.WorkingDir
+		}
+		gitStatuses := gitutil.BatchGetGitStatus(ctx, workingDirs)
+
+		protoAgents := make([]*leapmuxv1.AgentInfo, 0, len(matched))
+		for i := range matched {
+			info := svc.agentToProto(&matched[i].agent, matched[i].isRunning, gitStatuses[i])
+			info.LastActivityAt = timefmt.Format(matched[i].lastActivity)
+			protoAgents = append(protoAgents, info)
+		}
+
+		sendProtoResponse(sender, &leapmuxv1.ListAllAgentsResponse{
+			Agents: protoAgents,
+			Page:   page,
+		})
+	})
+}
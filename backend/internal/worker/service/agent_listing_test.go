@@ -0,0 +1,134 @@
+package service
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	leapmuxv1 "github.com/leapmux/leapmux/generated/proto/leapmux/v1"
+	"github.com/leapmux/leapmux/internal/worker/agent"
+	db "github.com/leapmux/leapmux/internal/worker/generated/db"
+)
+
+func seedListedAgent(t *testing.T, svc *Service, id, wsID, createdBy, model string) {
+	t.Helper()
+	require.NoError(t, svc.Queries.CreateAgent(context.Background(), db.CreateAgentParams{
+		ID:            id,
+		WorkspaceID:   wsID,
+		WorkingDir:    t.TempDir(),
+		HomeDir:       t.TempDir(),
+		AgentProvider: claudeProvider,
+		Options:       marshalOptions(map[string]string{agent.OptionIDModel: model}),
+		CreatedBy:     createdBy,
+	}))
+}
+
+func listAllAgentIDs(resp *leapmuxv1.ListAllAgentsResponse) []string {
+	ids := make([]string, len(resp.GetAgents()))
+	for i, a := range resp.GetAgents() {
+		ids[i] = a.GetId()
+	}
+	return ids
+}
+
+func TestListAllAgents_PagesNewestFirst(t *testing.T) {
+	svc, d, w := setupTestService(t, withWorkspaces("ws-1", "ws-2"))
+	for _, id := range []string{"agent-a", "agent-b", "agent-c", "agent-d", "agent-e"} {
+		seedListedAgent(t, svc, id, "ws-1", "user-1", "opus")
+	}
+
+	var got []string
+	cursor := ""
+	for range 3 {
+		dispatch(d, "ListAllAgents", &leapmuxv1.ListAllAgentsRequest{
+			Page: &leapmuxv1.PageRequest{Limit: 2, Cursor: cursor},
+		}, w)
+		require.Empty(t, w.errors)
+		resp := decodeResponse[leapmuxv1.ListAllAgentsResponse](t, w)
+		got = append(got, listAllAgentIDs(resp)...)
+		cursor = resp.GetPage().GetNextCursor()
+		if !resp.GetPage().GetHasMore() {
+			assert.Empty(t, cursor)
+			break
+		}
+	}
+	assert.Equal(t, []string{"agent-e", "agent-d", "agent-c", "agent-b", "agent-a"}, got)
+}
+
+func TestListAllAgents_Filters(t *testing.T) {
+	svc, d, w := setupTestService(t, withWorkspaces("ws-1", "ws-2"))
+	ctx := context.Background()
+	seedListedAgent(t, svc, "agent-a", "ws-1", "user-1", "opus")
+	seedListedAgent(t, svc, "agent-b", "ws-2", "user-2", "sonnet")
+	seedListedAgent(t, svc, "agent-c", "ws-1", "user-1", "sonnet")
+	require.NoError(t, svc.Queries.CloseAgent(ctx, "agent-c"))
+
+	cases := []struct {
+		name string
+		req  *leapmuxv1.ListAllAgentsRequest
+		want []string
+	}{
+		{"open only by default", &leapmuxv1.ListAllAgentsRequest{}, []string{"agent-b", "agent-a"}},
+		{"include closed", &leapmuxv1.ListAllAgentsRequest{IncludeClosed: true}, []string{"agent-c", "agent-b", "agent-a"}},
+		{"model", &leapmuxv1.ListAllAgentsRequest{Model: "sonnet", IncludeClosed: true}, []string{"agent-c", "agent-b"}},
+		{"created by", &leapmuxv1.ListAllAgentsRequest{CreatedBy: "user-1"}, []string{"agent-a"}},
+		{"status", &leapmuxv1.ListAllAgentsRequest{
+			Statuses:      []leapmuxv1.AgentStatus{leapmuxv1.AgentStatus_AGENT_STATUS_ACTIVE},
+			IncludeClosed: true,
+		}, nil},
+		{"active after", &leapmuxv1.ListAllAgentsRequest{
+			ActiveAfter: time.Now().Add(time.Hour).UTC().Format(time.RFC3339),
+		}, nil},
+		{"active before", &leapmuxv1.ListAllAgentsRequest{
+			ActiveBefore: time.Now().Add(time.Hour).UTC().Format(time.RFC3339),
+		}, []string{"agent-b", "agent-a"}},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			dispatch(d, "ListAllAgents", tc.req, w)
+			require.Empty(t, w.errors)
+			resp := decodeResponse[leapmuxv1.ListAllAgentsResponse](t, w)
+			assert.ElementsMatch(t, tc.want, listAllAgentIDs(resp))
+		})
+	}
+}
+
+func TestListAllAgents_ReportsListingMetadata(t *testing.T) {
+	svc, d, w := setupTestService(t, withWorkspaces("ws-1"))
+	seedListedAgent(t, svc, "agent-a", "ws-1", "user-1", "opus")
+
+	dispatch(d, "ListAllAgents", &leapmuxv1.ListAllAgentsRequest{}, w)
+	require.Empty(t, w.errors)
+	resp := decodeResponse[leapmuxv1.ListAllAgentsResponse](t, w)
+	require.Len(t, resp.GetAgents(), 1)
+	info := resp.GetAgents()[0]
+	assert.Equal(t, "user-1", info.GetCreatedBy())
+	assert.Equal(t, info.GetCreatedAt(), info.GetLastActivityAt(), "an agent with no messages was last active when created")
+}
+
+func TestListAllAgents_OnlyAccessibleWorkspaces(t *testing.T) {
+	svc, d, w := setupTestService(t, withWorkspaces("ws-1"))
+	seedListedAgent(t, svc, "agent-a", "ws-1", "user-1", "opus")
+	seedListedAgent(t, svc, "agent-b", "ws-other", "user-2", "opus")
+
+	dispatch(d, "ListAllAgents", &leapmuxv1.ListAllAgentsRequest{}, w)
+	require.Empty(t, w.errors)
+	assert.Equal(t, []string{"agent-a"}, listAllAgentIDs(decodeResponse[leapmuxv1.ListAllAgentsResponse](t, w)))
+}
+
+func TestListAllAgents_InvalidArguments(t *testing.T) {
+	_, d, w := setupTestService(t, withWorkspaces("ws-1"))
+
+	for _, req := range []*leapmuxv1.ListAllAgentsRequest{
+		{Page: &leapmuxv1.PageRequest{Cursor: "not-a-cursor"}},
+		{ActiveAfter: "yesterday"},
+		{ActiveBefore: "tomorrow"},
+	} {
+		w.errors = nil
+		dispatch(d, "ListAllAgents", req, w)
+		require.Len(t, w.errors, 1)
+	}
+}
@@ -0,0 +1,114 @@
+package service
+
+import (
+	"context"
+	"encoding/json"
+	"os"
+
+	leapmuxv1 "github.com/leapmux/leapmux/generated/proto/leapmux/v1"
+	"github.com/leapmux/leapmux/internal/util/userid"
+	"github.com/leapmux/leapmux/internal/worker/agent"
+	"github.com/leapmux/leapmux/internal/worker/channel"
+	db "github.com/leapmux/leapmux/internal/worker/generated/db"
+	"github.com/leapmux/leapmux/internal/worker/gitutil"
+)
+
+func registerAgentRuntimeInfoHandlers(d registrar, svc *Service) {
+	registerAgentGated(d, "GetAgentRuntimeInfo",
+		func(ctx context.Context, _ userid.UserID, _ *leapmuxv1.GetAgentRuntimeInfoRequest, dbAgent db.Agent, sender channel.ResponseWriter) {
+			sendProtoResponse(sender, &leapmuxv1.GetAgentRuntimeInfoResponse{
+				Info: svc.agentRuntimeInfo(ctx, dbAgent),
+			})
+		})
+}
+
+// agentRuntimeInfo gathers dbAgent's live state. The settings are the
+// persisted ones, which the worker keeps in step with what the provider
+// confirmed; context usage is the provider's last session-info report.
+func (svc *Service) agentRuntimeInfo(ctx context.Context, dbAgent db.Agent) *leapmuxv1.AgentRuntimeInfo {
+	status, _, _ := svc.deriveAgentStatus(&dbAgent, svc.Agents.HasAgent(dbAgent.ID))
+	opts := loadOptions(dbAgent.Options, dbAgent.AgentProvider)
+	hostname, _ := os.Hostname()
+	info := &leapmuxv1.AgentRuntimeInfo{
+		AgentId:        dbAgent.ID,
+		Status:         status,
+		AgentSessionId: dbAgent.AgentSessionID,
+		Model:          opts[agent.OptionIDModel],
+		Effort:         opts[agent.OptionIDEffort],
+		PermissionMode: opts[agent.OptionIDPermissionMode],
+		WorkerName:     svc.Name,
+		Hostname:       hostname,
+		WorkingDir:     dbAgent.WorkingDir,
+	}
+	if cred := svc.agentModelCredential(dbAgent.ID); cred != nil {
+		info.ModelCredentialName = cred.CredentialName
+	}
+	if raw, ok := svc.Output.latestSessionInfo(dbAgent.ID, "context_usage"); ok {
+		info.ContextTokens, info.ContextWindow = parseContextUsage(raw)
+	}
+	if gs := gitutil.GetGitStatus(ctx, dbAgent.WorkingDir); gs != nil {
+		info.GitBranch = gs.GetBranch()
+	}
+	return info
+}
+
+// parseContextUsage reads a context_usage session-info payload. Providers
+// that know the total report it as context_tokens (Pi natively as tokens);
+// the rest report its parts, summed the way the frontend's context grid
+// does.
+func parseContextUsage(raw []byte) (tokens, window int64) {
+	var u struct {
+		ContextTokens            *int64 `json:"context_tokens"`
+		Tokens                   *int64 `json:"tokens"`
+		InputTokens              int64  `json:"input_tokens"`
+		CacheCreationInputTokens int64  `json:"cache_creation_input_tokens"`
+		CacheReadInputTokens     int64  `json:"cache_read_input_tokens"`
+		OutputTokens             int64  `json:"output_tokens"`
+		ContextWindow            int64  `json:"context_window"`
+	}
+	if json.Unmarshal(raw, &u) != nil {
+		return 0, 0
+	}
+	switch {
+	case u.ContextTokens != nil:
+		tokens = *u.ContextTokens
+	case u.Tokens != nil:
+		tokens = *u.Tokens
+	default:
+		tokens = u.InputTokens + u.CacheCreationInputTokens + u.CacheReadInputTokens + u.OutputTokens
+	}
+	return tokens, max(u.ContextWindow, 0)
+}
+
+// statusCommand posts the agent's runtime info into the chat as an
+// agent_status notification.
+type statusCommand struct{}
+
+func (statusCommand) Names() []string { return []string{"status"} }
+
+func (statusCommand) Parse(args string) error {
+	if args != "" {
+		return errNoArguments
+	}
+	return nil
+}
+
+func (statusCommand) Permit(*Service, db.Agent) error { return nil }
+
+func (statusCommand) Run(svc *Service, dbAgent db.Agent, _ string) map[string]any {
+	info := svc.agentRuntimeInfo(bgCtx(), dbAgent)
+	return agent.NotificationContent(agent.AgentStatusPayload{
+		Status:          info.GetStatus().String(),
+		AgentSessionID:  info.GetAgentSessionId(),
+		Model:           info.GetModel(),
+		Effort:          info.GetEffort(),
+		PermissionMode:  info.GetPermissionMode(),
+		ContextTokens:   info.GetContextTokens(),
+		ContextWindow:   info.GetContextWindow(),
+		WorkerName:      info.GetWorkerName(),
+		Hostname:        info.GetHostname(),
+		WorkingDir:      info.GetWorkingDir(),
+		GitBranch:       info.GetGitBranch(),
+		ModelCredential: info.GetModelCredentialName(),
+	})
+}
@@ -0,0 +1,84 @@
+package service
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	leapmuxv1 "github.com/leapmux/leapmux/generated/proto/leapmux/v1"
+	"github.com/leapmux/leapmux/internal/util/msgcodec"
+	"github.com/leapmux/leapmux/internal/worker/agent"
+	db "github.com/leapmux/leapmux/internal/worker/generated/db"
+)
+
+func TestParseContextUsage(t *testing.T) {
+	for name, tc := range map[string]struct {
+		raw            string
+		tokens, window int64
+	}{
+		"claude parts":      {`{"input_tokens":10,"cache_creation_input_tokens":20,"cache_read_input_tokens":30,"output_tokens":5,"context_window":200000}`, 65, 200000},
+		"normalized total":  {`{"context_tokens":900,"input_tokens":10,"context_window":1000}`, 900, 1000},
+		"pi native total":   {`{"tokens":42}`, 42, 0},
+		"unknown window":    {`{"input_tokens":7,"context_window":-1}`, 7, 0},
+		"malformed payload": {`[1]`, 0, 0},
+	} {
+		t.Run(name, func(t *testing.T) {
+			tokens, window := parseContextUsage([]byte(tc.raw))
+			assert.Equal(t, tc.tokens, tokens)
+			assert.Equal(t, tc.window, window)
+		})
+	}
+}
+
+func TestGetAgentRuntimeInfo(t *testing.T) {
+	svc, d, w := setupTestService(t, withWorkspaces("ws-1"))
+	row := seedGuardedAgent(t, svc, "plan")
+	svc.Output.NewSink(row.ID, row.AgentProvider).BroadcastSessionInfo(map[string]any{
+		"context_usage": map[string]any{"input_tokens": 100, "cache_read_input_tokens": 50, "context_window": 200000},
+	})
+
+	dispatch(d, "GetAgentRuntimeInfo", &leapmuxv1.GetAgentRuntimeInfoRequest{AgentId: row.ID}, w)
+	require.Empty(t, w.errors)
+	info := decodeResponse[leapmuxv1.GetAgentRuntimeInfoResponse](t, w).GetInfo()
+
+	assert.Equal(t, row.ID, info.GetAgentId())
+	assert.Equal(t, leapmuxv1.AgentStatus_AGENT_STATUS_INACTIVE, info.GetStatus())
+	assert.Equal(t, "opus", info.GetModel())
+	assert.Equal(t, "plan", info.GetPermissionMode())
+	assert.Equal(t, int64(150), info.GetContextTokens())
+	assert.Equal(t, int64(200000), info.GetContextWindow())
+	assert.Equal(t, row.WorkingDir, info.GetWorkingDir())
+	assert.Equal(t, svc.Name, info.GetWorkerName())
+	assert.Empty(t, info.GetGitBranch(), "the working dir is not a git repository")
+}
+
+func TestSendAgentMessage_StatusCommandPostsRuntimeInfo(t *testing.T) {
+	svc, d, w := setupTestService(t, withWorkspaces("ws-1"))
+	row := seedGuardedAgent(t, svc, "plan")
+
+	dispatch(d, "SendAgentMessage", &leapmuxv1.SendAgentMessageRequest{AgentId: row.ID, Content: "/status"}, w)
+	require.Empty(t, w.errors)
+
+	msgs, err := svc.Queries.ListAllMessagesByAgentID(context.Background(), db.ListAllMessagesByAgentIDParams{AgentID: row.ID})
+	require.NoError(t, err)
+	require.Len(t, msgs, 2)
+	require.Equal(t, leapmuxv1.MessageSource_MESSAGE_SOURCE_LEAPMUX, msgs[1].Source)
+	raw, err := msgcodec.Decompress(msgs[1].Content, msgs[1].ContentCompression)
+	require.NoError(t, err)
+
+	// Notifications are persisted in a thread envelope.
+	var thread struct {
+		Messages []map[string]any `json:"messages"`
+	}
+	require.NoError(t, json.Unmarshal(raw, &thread))
+	require.Len(t, thread.Messages, 1)
+	report := thread.Messages[0]
+	assert.Equal(t, agent.NotificationTypeAgentStatus, report["type"])
+	assert.Equal(t, "opus", report["model"])
+	assert.Equal(t, "plan", report["permission_mode"])
+	assert.Equal(t, row.WorkingDir, report["working_dir"])
+	assert.False(t, svc.Agents.HasAgent(row.ID), "/status must not start the agent")
+}
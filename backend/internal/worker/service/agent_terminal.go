@@ -0,0 +1,253 @@
+package service
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"regexp"
+	"strings"
+	"unicode/utf8"
+
+	leapmuxv1 "github.com/leapmux/leapmux/generated/proto/leapmux/v1"
+	"github.com/leapmux/leapmux/internal/util/id"
+	"github.com/leapmux/leapmux/internal/util/userid"
+	db "github.com/leapmux/leapmux/internal/worker/generated/db"
+	"github.com/leapmux/leapmux/internal/worker/terminal"
+)
+
+// maxAgentTerminalTitleLen bounds the tab title taken from the command.
+const maxAgentTerminalTitleLen = 40
+
+// compiledAgentTerminalPolicy is the org's AgentTerminalPolicy with its
+// patterns compiled once per delivery rather than once per request.
+type compiledAgentTerminalPolicy struct {
+	patterns []*regexp.Regexp
+}
+
+func (p *compiledAgentTerminalPolicy) matches(command string) bool {
+	for _, re := range p.patterns {
+		if re.MatchString(command) {
+			return true
+		}
+	}
+	return false
+}
+
+// SetAgentTerminalPolicy adopts the org's agent terminal policy. The Hub
+// validates patterns before storing them; one that still fails to compile
+// here (a Hub on a different RE2 version) is dropped rather than failing
+// the whole policy.
+func (svc *Service) SetAgentTerminalPolicy(p *leapmuxv1.AgentTerminalPolicy) {
+	compiled := &compiledAgentTerminalPolicy{}
+	for _, pattern := range p.GetCommandPatterns() {
+		re, err := regexp.Compile(pattern)
+		if err != nil {
+			slog.Warn("ignoring invalid agent terminal pattern", "pattern", pattern, "error", err)
+			continue
+		}
+		compiled.patterns = append(compiled.patterns, re)
+	}
+	svc.agentTerminalPolicy.Store(compiled)
+}
+
+// divertToAgentTerminal is the OutputHandler's control request diverter.
+// A Bash permission request whose command matches the org's policy is run
+// in a new terminal in the agent's workspace and working directory, and
+// the request is denied with a message telling the agent where the command
+// went, so the agent's turn goes on instead of blocking on a process that
+// never exits.
+//
+// Only a permission request can be diverted: an agent in a mode that runs
+// Bash without asking never produces one. Anything that goes wrong before
+// the terminal exists leaves the request to the user as usual.
+func (svc *Service) divertToAgentTerminal(agentID, requestID string, payload []byte) bool {
+	policy := svc.agentTerminalPolicy.Load()
+	if policy == nil || len(policy.patterns) == 0 {
+		return false
+	}
+	var cr struct {
+		Request struct {
+			Subtype  string `json:"subtype"`
+			ToolName string `json:"tool_name"`
+			Input    struct {
+				Command string `json:"command"`
+			} `json:"input"`
+		} `json:"request"`
+	}
+	if err := json.Unmarshal(payload, &cr); err != nil {
+		return false
+	}
+	command := strings.TrimSpace(cr.Request.Input.Command)
+	if cr.Request.Subtype != "can_use_tool" || cr.Request.ToolName != "Bash" || command == "" || !policy.matches(command) {
+		return false
+	}
+
+	dbAgent, err := svc.getAgentByID(bgCtx(), agentID)
+	if err != nil {
+		slog.Warn("agent terminal: failed to load agent", "agent_id", agentID, "error", err)
+		return false
+	}
+	terminalID, err := svc.openAgentTerminal(dbAgent, agentTerminalSpec{
+		WorkingDir: dbAgent.WorkingDir,
+		Title:      agentTerminalTitle(command),
+		OnStarted: func(terminalID string) {
+			if !svc.Terminals.IsRunning(terminalID) {
+				return
+			}
+			if err := svc.Terminals.SendInput(terminalID, []byte(command+"\r")); err != nil {
+				slog.Warn("agent terminal: failed to type command", "terminal_id", terminalID, "error", err)
+			}
+		},
+	})
+	if err != nil {
+		slog.Warn("agent terminal: failed to open terminal", "agent_id", agentID, "error", err)
+		return false
+	}
+
+	message := fmt.Sprintf("This command was not run by the Bash tool. The workspace's agent terminal policy started it in a new terminal tab (terminal id %s), where it keeps running. "+
+		"Read its output with `leapmux remote terminal get --tab-id %s --screen`; it stops when the terminal is closed.", terminalID, terminalID)
+	if err := svc.sendControlDenial(dbAgent.AgentProvider, agentID, requestID, payload, cr.Request.ToolName, message); err != nil {
+		// The terminal runs regardless; the agent learns of it from the tab
+		// if not from the answer.
+		slog.Warn("agent terminal: failed to answer agent", "agent_id", agentID, "request_id", requestID, "error", err)
+	}
+	return true
+}
+
+// agentTerminalSpec describes a terminal opened on an agent's behalf.
+type agentTerminalSpec struct {
+	WorkingDir string
+	Title      string
+	// Command is run in place of an interactive shell (see
+	// terminal.Options.Command).
+	Command string
+	// OnStarted runs on the startup goroutine once startup is over, whether
+	// or not the shell came up.
+	OnStarted func(terminalID string)
+	// OnExit runs after the terminal's usual exit handling, on every exit
+	// including those of later restarts.
+	OnExit func(exitCode int)
+}
+
+// openAgentTerminal opens a terminal linked to dbAgent and asks the Hub to
+// show it next to the agent's tab. It returns once the row exists; the
+// shell starts in the background through the same startup path as
+// OpenTerminal.
+func (svc *Service) openAgentTerminal(dbAgent db.Agent, spec agentTerminalSpec) (string, error) {
+	// The terminal acts for whoever opened the agent. Agents opened before
+	// that was recorded fall back to the worker's owner.
+	user, ok := userid.New(dbAgent.CreatedBy)
+	if !ok {
+		user = svc.RegisteredBy()
+	}
+	if user.IsZero() {
+		return "", errors.New("no user to run the terminal as")
+	}
+
+	terminalID := id.Generate()
+	shell := terminal.ResolveDefaultShell()
+	if err := svc.Queries.UpsertTerminal(bgCtx(), db.UpsertTerminalParams{
+		ID:          terminalID,
+		WorkspaceID: dbAgent.WorkspaceID,
+		WorkingDir:  spec.WorkingDir,
+		HomeDir:     svc.HomeDir,
+		Shell:       shell,
+		Title:       spec.Title,
+		Cols:        80,
+		Rows:        25,
+		Screen:      []byte{},
+	}); err != nil {
+		return "", fmt.Errorf("persist terminal: %w", err)
+	}
+	if err := svc.Queries.SetTerminalAgentID(bgCtx(), db.SetTerminalAgentIDParams{
+		AgentID: dbAgent.ID,
+		ID:      terminalID,
+	}); err != nil {
+		_ = svc.Queries.CloseTerminal(bgCtx(), terminalID)
+		return "", fmt.Errorf("link terminal to agent: %w", err)
+	}
+
+	startupCtx := svc.beginTerminalStartup(terminalID, shell, nil)
+	spawnInfo := TerminalSpawnInfo{
+		UserID:      user,
+		WorkspaceID: dbAgent.WorkspaceID,
+		WorkerID:    svc.WorkerID,
+		TabID:       terminalID,
+		WorkingDir:  spec.WorkingDir,
+	}
+	opts := terminal.Options{
+		ID:          terminalID,
+		WorkspaceID: dbAgent.WorkspaceID,
+		Shell:       shell,
+		WorkingDir:  spec.WorkingDir,
+		Cols:        80,
+		Rows:        25,
+		Command:     spec.Command,
+	}
+	plan := gitModePlan{WorkingDir: spec.WorkingDir, PlannedWorkingDir: spec.WorkingDir}
+	exitFn := svc.makeTerminalExitFn()
+	if spec.OnExit != nil {
+		defaultExit := exitFn
+		exitFn = func(tid string, exitCode int) {
+			defaultExit(tid, exitCode)
+			spec.OnExit(exitCode)
+		}
+	}
+	go func() {
+		svc.runTerminalStartup(startupCtx, opts, spawnInfo, plan, svc.makeTerminalOutputFn(terminalID), exitFn)
+		if spec.OnStarted != nil {
+			spec.OnStarted(terminalID)
+		}
+	}()
+
+	if err := svc.Send(&leapmuxv1.ConnectRequest{
+		Payload: &leapmuxv1.ConnectRequest_AgentTerminalOpened{AgentTerminalOpened: &leapmuxv1.AgentTerminalOpened{
+			WorkspaceId: dbAgent.WorkspaceID,
+			AgentId:     dbAgent.ID,
+			TerminalId:  terminalID,
+		}},
+	}); err != nil {
+		slog.Warn("agent terminal: failed to ask the hub for a tab", "terminal_id", terminalID, "error", err)
+	}
+	return terminalID, nil
+}
+
+// closeAgentTerminals closes the terminals an agent's commands were moved
+// into, and asks the Hub to drop their tabs. Called once the agent itself
+// is closed.
+func (svc *Service) closeAgentTerminals(agentID string) {
+	ids, err := svc.Queries.ListOpenTerminalIDsByAgentID(bgCtx(), agentID)
+	if err != nil {
+		slog.Warn("failed to list agent terminals", "agent_id", agentID, "error", err)
+		return
+	}
+	for _, terminalID := range ids {
+		workspaceID, err := svc.Queries.GetTerminalWorkspaceID(bgCtx(), terminalID)
+		if err != nil {
+			slog.Warn("failed to look up agent terminal", "terminal_id", terminalID, "error", err)
+			continue
+		}
+		svc.closeTerminalTab(terminalID, leapmuxv1.WorktreeAction_WORKTREE_ACTION_KEEP)
+		if err := svc.Send(&leapmuxv1.ConnectRequest{
+			Payload: &leapmuxv1.ConnectRequest_AgentTerminalClosed{AgentTerminalClosed: &leapmuxv1.AgentTerminalClosed{
+				WorkspaceId: workspaceID,
+				TerminalId:  terminalID,
+			}},
+		}); err != nil {
+			slog.Warn("failed to ask the hub to drop an agent terminal tab", "terminal_id", terminalID, "error", err)
+		}
+	}
+}
+
+// agentTerminalTitle names an agent terminal after the first line of its
+// command, shortened to fit a tab.
+func agentTerminalTitle(command string) string {
+	title, _, _ := strings.Cut(command, "\n")
+	title = strings.TrimSpace(title)
+	if utf8.RuneCountInString(title) <= maxAgentTerminalTitleLen {
+		return title
+	}
+	runes := []rune(title)
+	return strings.TrimSpace(string(runes[:maxAgentTerminalTitleLen-1])) + "…"
+}
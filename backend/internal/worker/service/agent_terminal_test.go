@@ -0,0 +1,134 @@
+package service
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	leapmuxv1 "github.com/leapmux/leapmux/generated/proto/leapmux/v1"
+	"github.com/leapmux/leapmux/internal/util/testutil"
+)
+
+// captureSends records what the service sends to the Hub.
+type captureSends struct {
+	mu   sync.Mutex
+	msgs []*leapmuxv1.ConnectRequest
+}
+
+func (c *captureSends) send(msg *leapmuxv1.ConnectRequest) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.msgs = append(c.msgs, msg)
+	return nil
+}
+
+func (c *captureSends) snapshot() []*leapmuxv1.ConnectRequest {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return append([]*leapmuxv1.ConnectRequest(nil), c.msgs...)
+}
+
+func bashPermissionRequest(t *testing.T, command string) []byte {
+	t.Helper()
+	payload, err := json.Marshal(map[string]any{
+		"type":       "control_request",
+		"request_id": "req-1",
+		"request": map[string]any{
+			"subtype":   "can_use_tool",
+			"tool_name": "Bash",
+			"input":     map[string]any{"command": command},
+		},
+	})
+	require.NoError(t, err)
+	return payload
+}
+
+func TestDivertToAgentTerminal_RunsMatchingCommandInLinkedTerminal(t *testing.T) {
+	ctx := context.Background()
+	svc, d, w := setupTestService(t, withWorkspaces("ws-1"))
+	defer drainAllInFlight(svc)
+	sends := &captureSends{}
+	svc.Send = sends.send
+	seedGuardedAgent(t, svc, "")
+	svc.SetAgentTerminalPolicy(&leapmuxv1.AgentTerminalPolicy{CommandPatterns: []string{`^echo `}})
+
+	require.True(t, svc.divertToAgentTerminal("agent-1", "req-1", bashPermissionRequest(t, "echo diverted-$((40+2))")))
+
+	msgs := sends.snapshot()
+	require.Len(t, msgs, 1)
+	opened := msgs[0].GetAgentTerminalOpened()
+	require.NotNil(t, opened, "the Hub is asked to place the tab")
+	assert.Equal(t, "ws-1", opened.GetWorkspaceId())
+	assert.Equal(t, "agent-1", opened.GetAgentId())
+	terminalID := opened.GetTerminalId()
+
+	row, err := svc.Queries.GetTerminal(ctx, terminalID)
+	require.NoError(t, err)
+	assert.Equal(t, "agent-1", row.AgentID)
+	assert.Equal(t, "echo diverted-$((40+2))", row.Title)
+
+	testutil.RequireEventually(t, func() bool { return svc.Terminals.HasTerminal(terminalID) }, "terminal spawn")
+	testutil.RegisterTerminalCleanup(t, svc.Terminals, terminalID)
+	testutil.AssertEventually(t, func() bool {
+		screen, _, _ := svc.Terminals.ScreenSnapshotSince(terminalID, 0)
+		return bytes.Contains(screen, []byte("diverted-42"))
+	}, "command runs in the terminal")
+
+	// Closing the agent closes its terminal and drops the tab.
+	dispatch(d, "CloseAgent", &leapmuxv1.CloseAgentRequest{AgentId: "agent-1"}, w)
+	require.Empty(t, w.errors)
+	row, err = svc.Queries.GetTerminal(ctx, terminalID)
+	require.NoError(t, err)
+	assert.True(t, row.ClosedAt.Valid, "agent terminal closed with its agent")
+	var closed *leapmuxv1.AgentTerminalClosed
+	for _, msg := range sends.snapshot() {
+		if c := msg.GetAgentTerminalClosed(); c != nil {
+			closed = c
+		}
+	}
+	require.NotNil(t, closed, "the Hub is asked to drop the tab")
+	assert.Equal(t, "ws-1", closed.GetWorkspaceId())
+	assert.Equal(t, terminalID, closed.GetTerminalId())
+}
+
+func TestDivertToAgentTerminal_LeavesOtherRequestsAlone(t *testing.T) {
+	tests := []struct {
+		name     string
+		patterns []string
+		payload  string
+	}{
+		{name: "no policy", payload: `{"request":{"subtype":"can_use_tool","tool_name":"Bash","input":{"command":"echo hi"}}}`},
+		{name: "no match", patterns: []string{`^npm run dev`}, payload: `{"request":{"subtype":"can_use_tool","tool_name":"Bash","input":{"command":"echo hi"}}}`},
+		{name: "other tool", patterns: []string{`.`}, payload: `{"request":{"subtype":"can_use_tool","tool_name":"Write","input":{"command":"echo hi"}}}`},
+		{name: "other subtype", patterns: []string{`.`}, payload: `{"request":{"subtype":"elicitation","tool_name":"Bash","input":{"command":"echo hi"}}}`},
+		{name: "not json", patterns: []string{`.`}, payload: `{`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			svc, _, _ := setupTestService(t, withWorkspaces("ws-1"))
+			sends := &captureSends{}
+			svc.Send = sends.send
+			seedGuardedAgent(t, svc, "")
+			svc.SetAgentTerminalPolicy(&leapmuxv1.AgentTerminalPolicy{CommandPatterns: tt.patterns})
+
+			assert.False(t, svc.divertToAgentTerminal("agent-1", "req-1", []byte(tt.payload)))
+			assert.Empty(t, sends.snapshot())
+			ids, err := svc.Queries.ListOpenTerminalIDsByAgentID(context.Background(), "agent-1")
+			require.NoError(t, err)
+			assert.Empty(t, ids)
+		})
+	}
+}
+
+func TestAgentTerminalTitle(t *testing.T) {
+	assert.Equal(t, "npm run dev", agentTerminalTitle("  npm run dev  \n# second line"))
+	long := "tail -f /var/log/some/really/long/path/to/a/file.log"
+	got := agentTerminalTitle(long)
+	assert.Equal(t, maxAgentTerminalTitleLen, len([]rune(got)))
+	assert.Equal(t, "…", string([]rune(got)[maxAgentTerminalTitleLen-1:]))
+}
@@ -0,0 +1,176 @@
+package service
+
+import (
+	"cmp"
+	"context"
+	"log/slog"
+	"regexp"
+	"slices"
+	"sync"
+
+	leapmuxv1 "github.com/leapmux/leapmux/generated/proto/leapmux/v1"
+	"github.com/leapmux/leapmux/internal/util/sqltime"
+	"github.com/leapmux/leapmux/internal/util/userid"
+	"github.com/leapmux/leapmux/internal/worker/agent"
+	"github.com/leapmux/leapmux/internal/worker/channel"
+	db "github.com/leapmux/leapmux/internal/worker/generated/db"
+	"github.com/leapmux/leapmux/internal/worker/testruns"
+)
+
+// agent_activity kinds.
+const (
+	activityInterrupt   = "interrupt"
+	activityApproval    = "approval"
+	activityPullRequest = "pull_request"
+)
+
+var (
+	// pullRequestCommand matches a shell command that opens a pull request
+	// (GitHub) or merge request (GitLab) with the host's CLI.
+	pullRequestCommand = regexp.MustCompile(`(^|[\s;&|(])(gh\s+pr\s+create|glab\s+mr\s+create)(\s|$)`)
+	// pullRequestURL matches the URL those commands print on success.
+	pullRequestURL = regexp.MustCompile(`https://[^\s/]+/[^\s]+?/(pull/\d+|-/merge_requests/\d+)`)
+)
+
+// recordAgentActivity adds an analytics event for agentID. Like metrics it
+// is bookkeeping, so a failed write is logged rather than surfaced.
+func (h *OutputHandler) recordAgentActivity(agentID, kind string, latencyMs int64, detail string) {
+	if err := h.queries.CreateAgentActivity(bgCtx(), db.CreateAgentActivityParams{
+		AgentID:   agentID,
+		Kind:      kind,
+		LatencyMs: latencyMs,
+		Detail:    detail,
+	}); err != nil {
+		slog.Warn("failed to record agent activity", "agent_id", agentID, "kind", kind, "error", err)
+	}
+}
+
+// observePullRequest watches an agent's shell tool calls for one that opens
+// a pull request, the way observeTestRun watches for test runners, and
+// records the pull request once the call's output shows its URL.
+func (h *OutputHandler) observePullRequest(agentID string, content []byte, span agent.SpanInfo) {
+	if span.SpanID == "" {
+		return
+	}
+	if call := span.ToolCall; call != nil && call.Command != "" {
+		if pullRequestCommand.MatchString(call.Command) {
+			v, _ := h.pullRequestCalls.LoadOrStore(agentID, &sync.Map{})
+			v.(*sync.Map).Store(span.SpanID, struct{}{})
+		}
+		return
+	}
+	if !span.Closing {
+		return
+	}
+	v, ok := h.pullRequestCalls.Load(agentID)
+	if !ok {
+		return
+	}
+	if _, ok := v.(*sync.Map).LoadAndDelete(span.SpanID); !ok {
+		return
+	}
+	if url := pullRequestURL.FindString(testruns.ToolOutput(content)); url != "" {
+		h.recordAgentActivity(agentID, activityPullRequest, 0, url)
+	}
+}
+
+// analyticsKey is the (workspace, user) pair the totals queries group by.
+type analyticsKey struct {
+	workspaceID, userID string
+}
+
+func registerAnalyticsHandlers(d registrar, svc *Service) {
+	// ListAgentAnalytics reports team usage to leads. Like
+	// ListModelCredentialUsage it filters by AccessibleSet(), per
+	// workspace, before folding workspaces into users.
+	registerSetFiltered(d, "ListAgentAnalytics", func(ctx context.Context, _ userid.UserID, req *leapmuxv1.InnerRpcRequest, sender channel.ResponseWriter) {
+		var r leapmuxv1.ListAgentAnalyticsRequest
+		if err := unmarshalRequest(req, &r); err != nil {
+			sendInvalidArgument(sender, "invalid request")
+			return
+		}
+		since, until, err := parseUsagePeriod(r.GetSince(), r.GetUntil())
+		if err != nil {
+			sendInvalidArgument(sender, err.Error())
+			return
+		}
+		byUser := r.GetGroupBy() == leapmuxv1.AnalyticsGroupBy_ANALYTICS_GROUP_BY_USER
+
+		turns, err := svc.Queries.ListTurnTotals(ctx, db.ListTurnTotalsParams{
+			Since: sqltime.NewSQLiteTime(since),
+			Until: sqltime.NewSQLiteTime(until),
+		})
+		var activity []db.ListAgentActivityTotalsRow
+		if err == nil {
+			activity, err = svc.Queries.ListAgentActivityTotals(ctx, db.ListAgentActivityTotalsParams{
+				Since: sqltime.NewSQLiteTime(since),
+				Until: sqltime.NewSQLiteTime(until),
+			})
+		}
+		if err != nil {
+			slog.Error("failed to list agent analytics", "error", err)
+			sendInternalError(sender, "failed to list agent analytics")
+			return
+		}
+
+		accessible := svc.AuthorizerFor(sender.ChannelID()).AccessibleSet()
+		totals := map[analyticsKey]*leapmuxv1.AgentAnalytics{}
+		latency := map[analyticsKey]int64{}
+		row := func(workspaceID, userID string) (analyticsKey, *leapmuxv1.AgentAnalytics) {
+			key := analyticsKey{workspaceID: workspaceID}
+			if byUser {
+				key = analyticsKey{userID: userID}
+			}
+			t, ok := totals[key]
+			if !ok {
+				t = &leapmuxv1.AgentAnalytics{WorkspaceId: key.workspaceID, UserId: key.userID}
+				totals[key] = t
+			}
+			return key, t
+		}
+		for _, tr := range turns {
+			if !accessible[tr.WorkspaceID] {
+				continue
+			}
+			_, t := row(tr.WorkspaceID, tr.CreatedBy)
+			t.Agents += tr.Agents
+			t.Turns += tr.Turns
+			t.CostUsd += tr.CostUsd
+		}
+		for _, ar := range activity {
+			if !accessible[ar.WorkspaceID] {
+				continue
+			}
+			key, t := row(ar.WorkspaceID, ar.CreatedBy)
+			switch ar.Kind {
+			case activityInterrupt:
+				t.Interrupts += ar.Events
+			case activityApproval:
+				t.Approvals += ar.Events
+				latency[key] += ar.LatencyMs
+			case activityPullRequest:
+				t.PullRequests += ar.Events
+			}
+		}
+
+		rows := make([]*leapmuxv1.AgentAnalytics, 0, len(totals))
+		for key, t := range totals {
+			if t.Turns > 0 {
+				t.InterruptRate = float64(t.Interrupts) / float64(t.Turns)
+			}
+			if t.Approvals > 0 {
+				t.AvgApprovalLatencyMs = latency[key] / t.Approvals
+			}
+			rows = append(rows, t)
+		}
+		slices.SortFunc(rows, func(a, b *leapmuxv1.AgentAnalytics) int {
+			return cmp.Or(
+				cmp.Compare(b.GetCostUsd(), a.GetCostUsd()),
+				cmp.Compare(b.GetTurns(), a.GetTurns()),
+				cmp.Compare(a.GetWorkspaceId(), b.GetWorkspaceId()),
+				cmp.Compare(a.GetUserId(), b.GetUserId()),
+			)
+		})
+		sendProtoResponse(sender, &leapmuxv1.ListAgentAnalyticsResponse{Rows: rows})
+	})
+}
@@ -0,0 +1,87 @@
+package service
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	leapmuxv1 "github.com/leapmux/leapmux/generated/proto/leapmux/v1"
+	"github.com/leapmux/leapmux/internal/worker/agent"
+	db "github.com/leapmux/leapmux/internal/worker/generated/db"
+)
+
+func TestListAgentAnalytics(t *testing.T) {
+	svc, d, w := setupTestService(t, withWorkspaces("ws-1", "ws-2"))
+	ctx := context.Background()
+	for _, a := range []struct{ id, workspace, user string }{
+		{"agent-a", "ws-1", "user-1"},
+		{"agent-b", "ws-2", "user-1"},
+		{"agent-c", "ws-2", "user-2"},
+		{"agent-x", "ws-other", "user-1"},
+	} {
+		require.NoError(t, svc.Queries.CreateAgent(ctx, db.CreateAgentParams{
+			ID: a.id, WorkspaceID: a.workspace, WorkingDir: t.TempDir(), HomeDir: t.TempDir(),
+			AgentProvider: claudeCode, CreatedBy: a.user,
+		}))
+	}
+	turn := func(agentID string, cost float64) {
+		t.Helper()
+		require.NoError(t, svc.Output.NewSink(agentID, claudeCode).PersistTurnEnd([]byte(`{"type":"result"}`),
+			agent.SpanInfo{Usage: &agent.MessageUsage{CostUSD: cost, Turn: true}}))
+	}
+	turn("agent-a", 1)
+	turn("agent-a", 1)
+	turn("agent-b", 0.5)
+	turn("agent-c", 3)
+	turn("agent-x", 100)
+	svc.Output.recordAgentActivity("agent-a", activityInterrupt, 0, "")
+	svc.Output.recordAgentActivity("agent-a", activityApproval, 1000, "")
+	svc.Output.recordAgentActivity("agent-b", activityApproval, 3000, "")
+	svc.Output.recordAgentActivity("agent-x", activityInterrupt, 0, "")
+
+	sink := svc.Output.NewSink("agent-b", claudeCode)
+	require.NoError(t, sink.PersistMessage(leapmuxv1.MessageSource_MESSAGE_SOURCE_AGENT, []byte(`{"type":"assistant"}`),
+		agent.SpanInfo{SpanID: "toolu_1", SpanType: agent.ToolNameBash, ToolCall: &agent.ToolCall{Name: agent.ToolNameBash, Command: `git push -u origin fix && gh pr create --fill`}}))
+	require.NoError(t, sink.PersistMessage(leapmuxv1.MessageSource_MESSAGE_SOURCE_USER,
+		[]byte(`{"type":"user","tool_use_result":{"stdout":"https://github.com/a/b/pull/42\n"}}`),
+		agent.SpanInfo{SpanID: "toolu_1", SpanType: agent.ToolNameBash, Closing: true}))
+
+	dispatch(d, "ListAgentAnalytics", &leapmuxv1.ListAgentAnalyticsRequest{}, w)
+	require.Empty(t, w.errors)
+	rows := decodeResponse[leapmuxv1.ListAgentAnalyticsResponse](t, w).GetRows()
+	require.Len(t, rows, 2, "ws-other is not the caller's")
+	assert.Equal(t, "ws-2", rows[0].GetWorkspaceId(), "costliest first")
+	assert.EqualValues(t, 2, rows[0].GetAgents())
+	assert.EqualValues(t, 1, rows[0].GetPullRequests())
+	assert.Equal(t, "ws-1", rows[1].GetWorkspaceId())
+	assert.EqualValues(t, 2, rows[1].GetTurns())
+	assert.InDelta(t, 2, rows[1].GetCostUsd(), 1e-9)
+	assert.InDelta(t, 0.5, rows[1].GetInterruptRate(), 1e-9)
+
+	w.responses = nil
+	dispatch(d, "ListAgentAnalytics", &leapmuxv1.ListAgentAnalyticsRequest{GroupBy: leapmuxv1.AnalyticsGroupBy_ANALYTICS_GROUP_BY_USER}, w)
+	require.Empty(t, w.errors)
+	rows = decodeResponse[leapmuxv1.ListAgentAnalyticsResponse](t, w).GetRows()
+	require.Len(t, rows, 2)
+	assert.Equal(t, "user-2", rows[0].GetUserId())
+	assert.Empty(t, rows[0].GetWorkspaceId())
+	u1 := rows[1]
+	assert.Equal(t, "user-1", u1.GetUserId())
+	assert.EqualValues(t, 2, u1.GetAgents())
+	assert.EqualValues(t, 3, u1.GetTurns())
+	assert.InDelta(t, 2.5, u1.GetCostUsd(), 1e-9)
+	assert.EqualValues(t, 1, u1.GetInterrupts(), "agent-x's workspace is not the caller's")
+	assert.EqualValues(t, 2, u1.GetApprovals())
+	assert.EqualValues(t, 2000, u1.GetAvgApprovalLatencyMs())
+	assert.EqualValues(t, 1, u1.GetPullRequests())
+
+	w.responses = nil
+	dispatch(d, "ListAgentAnalytics", &leapmuxv1.ListAgentAnalyticsRequest{
+		Until: time.Now().Add(-time.Hour).Format(time.RFC3339),
+	}, w)
+	require.Empty(t, w.errors)
+	assert.Empty(t, decodeResponse[leapmuxv1.ListAgentAnalyticsResponse](t, w).GetRows())
+}
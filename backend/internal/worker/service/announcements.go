@@ -0,0 +1,83 @@
+package service
+
+import (
+	"log/slog"
+	"sync"
+	"time"
+
+	leapmuxv1 "github.com/leapmux/leapmux/generated/proto/leapmux/v1"
+)
+
+// announcementBoard holds the Hub admin's announcements that have not
+// ended, and the timers that broadcast the scheduled ones when they start.
+type announcementBoard struct {
+	mu     sync.Mutex
+	list   []*leapmuxv1.Announcement
+	timers []*time.Timer
+}
+
+// SetAnnouncements adopts the announcements the Hub sent, replacing the
+// previous list. Each one that is running is broadcast to every WatchEvents
+// stream now, and each scheduled one when it starts; the frontend shows
+// one it has already shown only once.
+func (svc *Service) SetAnnouncements(list *leapmuxv1.AnnouncementList) {
+	b := &svc.announcements
+	b.mu.Lock()
+	for _, t := range b.timers {
+		t.Stop()
+	}
+	b.timers = nil
+	b.list = list.GetAnnouncements()
+
+	now := time.Now()
+	var due []*leapmuxv1.Announcement
+	for _, a := range b.list {
+		if announcementEnded(a, now) {
+			continue
+		}
+		startsAt, err := time.Parse(time.RFC3339, a.GetStartsAt())
+		if err != nil {
+			slog.Warn("ignoring announcement with a bad start", "announcement_id", a.GetId(), "error", err)
+			continue
+		}
+		if wait := startsAt.Sub(now); wait > 0 {
+			b.timers = append(b.timers, time.AfterFunc(wait, func() {
+				svc.Watchers.BroadcastAnnouncement(a)
+			}))
+			continue
+		}
+		due = append(due, a)
+	}
+	b.mu.Unlock()
+
+	for _, a := range due {
+		svc.Watchers.BroadcastAnnouncement(a)
+	}
+}
+
+// runningAnnouncements returns the announcements that have started and not
+// ended, for a new WatchEvents stream's replay.
+func (svc *Service) runningAnnouncements() []*leapmuxv1.Announcement {
+	b := &svc.announcements
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	now := time.Now()
+	var running []*leapmuxv1.Announcement
+	for _, a := range b.list {
+		startsAt, err := time.Parse(time.RFC3339, a.GetStartsAt())
+		if err != nil || startsAt.After(now) || announcementEnded(a, now) {
+			continue
+		}
+		running = append(running, a)
+	}
+	return running
+}
+
+// announcementEnded reports whether a has an end and it has passed.
+func announcementEnded(a *leapmuxv1.Announcement, now time.Time) bool {
+	if a.GetEndsAt() == "" {
+		return false
+	}
+	endsAt, err := time.Parse(time.RFC3339, a.GetEndsAt())
+	return err == nil && !endsAt.After(now)
+}
@@ -0,0 +1,63 @@
+package service
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	leapmuxv1 "github.com/leapmux/leapmux/generated/proto/leapmux/v1"
+	"github.com/leapmux/leapmux/internal/util/timefmt"
+)
+
+func (w *recordingWriter) announcementIDs() []string {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	var ids []string
+	for _, f := range w.frames {
+		if a := f.GetAnnouncement(); a != nil {
+			ids = append(ids, a.GetId())
+		}
+	}
+	return ids
+}
+
+func TestSetAnnouncements_BroadcastsRunningAndScheduled(t *testing.T) {
+	svc := &Service{Watchers: NewWatcherManager()}
+	w := newRecordingWriter("ch-1")
+	svc.Watchers.SetAgentWatches("ch-1", []string{"agent-1"}, w)
+	idle := newRecordingWriter("ch-2")
+	svc.Watchers.SetAgentWatches("ch-2", nil, idle)
+
+	now := time.Now()
+	svc.SetAnnouncements(&leapmuxv1.AnnouncementList{Announcements: []*leapmuxv1.Announcement{
+		{Id: "running", StartsAt: timefmt.Format(now.Add(-time.Minute))},
+		{Id: "soon", StartsAt: timefmt.Format(now.Add(50 * time.Millisecond))},
+		{Id: "ended", StartsAt: timefmt.Format(now.Add(-time.Hour)), EndsAt: timefmt.Format(now.Add(-time.Minute))},
+		{Id: "scheduled", StartsAt: timefmt.Format(now.Add(time.Hour))},
+	}})
+	assert.Equal(t, []string{"running"}, w.announcementIDs())
+	assert.Empty(t, idle.announcementIDs(), "a channel that watches nothing has no stream to send on")
+
+	require.Eventually(t, func() bool { return len(w.announcementIDs()) == 2 }, 5*time.Second, 10*time.Millisecond)
+	assert.Equal(t, []string{"running", "soon"}, w.announcementIDs())
+	ids := func() []string {
+		var ids []string
+		for _, a := range svc.runningAnnouncements() {
+			ids = append(ids, a.GetId())
+		}
+		return ids
+	}
+	assert.Equal(t, []string{"running", "soon"}, ids(), "a new stream is sent the running ones")
+
+	// A new list cancels the old one's timers.
+	svc.SetAnnouncements(&leapmuxv1.AnnouncementList{Announcements: []*leapmuxv1.Announcement{
+		{Id: "later", StartsAt: timefmt.Format(now.Add(time.Hour))},
+	}})
+	assert.Empty(t, ids())
+	svc.announcements.mu.Lock()
+	assert.Len(t, svc.announcements.timers, 1)
+	svc.announcements.mu.Unlock()
+	svc.SetAnnouncements(nil)
+}
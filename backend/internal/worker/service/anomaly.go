@@ -0,0 +1,346 @@
+package service
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+	"unicode/utf8"
+
+	leapmuxv1 "github.com/leapmux/leapmux/generated/proto/leapmux/v1"
+	"github.com/leapmux/leapmux/internal/util/timefmt"
+	"github.com/leapmux/leapmux/internal/worker/agent"
+)
+
+// AnomalyPolicy flags turns that look like an agent stuck in a runaway or
+// destructive loop, so someone can step in before it burns through the
+// budget or the worktree. Each limit counts within one turn; zero turns
+// its check off. A flagged turn keeps running: the flag only warns.
+type AnomalyPolicy struct {
+	// MaxToolCalls flags a turn once it has made this many tool calls.
+	MaxToolCalls int
+	// MaxRepeatedCommands flags a turn once it has run the same shell
+	// command this many times.
+	MaxRepeatedCommands int
+	// MaxDeletedPaths flags a turn once its shell commands have named this
+	// many paths to delete.
+	MaxDeletedPaths int
+	// MaxTurnCostUSD flags a turn once it has cost this much.
+	MaxTurnCostUSD float64
+	// WebhookURL, when set, is sent each anomaly as a JSON POST.
+	WebhookURL string
+}
+
+func (p AnomalyPolicy) enabled() bool {
+	return p.MaxToolCalls > 0 || p.MaxRepeatedCommands > 0 || p.MaxDeletedPaths > 0 || p.MaxTurnCostUSD > 0
+}
+
+// Anomaly kinds, the `kind` of an agent_anomaly notification.
+const (
+	anomalyToolLoop        = "tool_loop"
+	anomalyRepeatedCommand = "repeated_command"
+	anomalyFileDeletions   = "file_deletions"
+	anomalyTurnCost        = "turn_cost"
+)
+
+const (
+	// anomalyWebhookTimeout bounds one webhook delivery.
+	anomalyWebhookTimeout = 10 * time.Second
+	// maxAnomalyCommandLen truncates the command an anomaly reports.
+	maxAnomalyCommandLen = 200
+)
+
+// anomaly is one limit a turn crossed.
+type anomaly struct {
+	kind    string
+	count   float64
+	limit   float64
+	command string
+}
+
+// anomalyTurn tallies what an agent's current turn has done.
+type anomalyTurn struct {
+	mu        sync.Mutex
+	toolCalls int
+	commands  map[string]int
+	deleted   int
+	costUSD   float64
+	flagged   map[string]bool
+}
+
+// observe counts span towards the turn and returns the limits it crossed
+// for the first time.
+func (t *anomalyTurn) observe(p AnomalyPolicy, span agent.SpanInfo) []anomaly {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	var found []anomaly
+	flag := func(a anomaly) {
+		if !t.flagged[a.kind] {
+			if t.flagged == nil {
+				t.flagged = make(map[string]bool)
+			}
+			t.flagged[a.kind] = true
+			found = append(found, a)
+		}
+	}
+
+	if call := span.ToolCall; call != nil {
+		t.toolCalls++
+		if p.MaxToolCalls > 0 && t.toolCalls >= p.MaxToolCalls {
+			flag(anomaly{kind: anomalyToolLoop, count: float64(t.toolCalls), limit: float64(p.MaxToolCalls)})
+		}
+		if command := strings.TrimSpace(call.Command); command != "" {
+			if t.commands == nil {
+				t.commands = make(map[string]int)
+			}
+			t.commands[command]++
+			if n := t.commands[command]; p.MaxRepeatedCommands > 0 && n >= p.MaxRepeatedCommands {
+				flag(anomaly{kind: anomalyRepeatedCommand, count: float64(n), limit: float64(p.MaxRepeatedCommands), command: truncateCommand(command)})
+			}
+			t.deleted += countDeletedPaths(command)
+			if p.MaxDeletedPaths > 0 && t.deleted >= p.MaxDeletedPaths {
+				flag(anomaly{kind: anomalyFileDeletions, count: float64(t.deleted), limit: float64(p.MaxDeletedPaths)})
+			}
+		}
+	}
+	// Usage a turn total repeats was already counted message by message.
+	if usage := span.Usage; usage != nil && !usage.InTurn {
+		t.costUSD += usage.CostUSD
+		if p.MaxTurnCostUSD > 0 && t.costUSD >= p.MaxTurnCostUSD {
+			flag(anomaly{kind: anomalyTurnCost, count: t.costUSD, limit: p.MaxTurnCostUSD})
+		}
+	}
+	return found
+}
+
+// observeSpan receives the span info of each message an agent persists,
+// with turnEnd set for its turn-end envelope, and raises each anomaly the
+// current turn newly shows.
+func (svc *Service) observeSpan(agentID string, provider leapmuxv1.AgentProvider, span agent.SpanInfo, turnEnd bool) {
+	if !svc.Anomaly.enabled() {
+		return
+	}
+	v, _ := svc.anomalyTurns.LoadOrStore(agentID, &anomalyTurn{})
+	found := v.(*anomalyTurn).observe(svc.Anomaly, span)
+	if turnEnd {
+		svc.anomalyTurns.Delete(agentID)
+	}
+	for _, a := range found {
+		svc.raiseAnomaly(agentID, provider, a)
+	}
+}
+
+// raiseAnomaly posts an agent_anomaly notification in the agent's chat and
+// sends it to the webhook, if one is set.
+func (svc *Service) raiseAnomaly(agentID string, provider leapmuxv1.AgentProvider, a anomaly) {
+	slog.Warn("agent anomaly", "agent_id", agentID, "kind", a.kind, "count", a.count, "limit", a.limit)
+	svc.Output.PersistLeapMuxNotification(agentID, provider, agent.NotificationContent(agent.AgentAnomalyPayload{
+		Kind:    a.kind,
+		Count:   a.count,
+		Limit:   a.limit,
+		Command: a.command,
+	}))
+	if svc.Anomaly.WebhookURL != "" {
+		go svc.postAnomalyWebhook(agentID, a, time.Now())
+	}
+}
+
+// anomalyWebhookPayload is the JSON body an anomaly webhook receives.
+type anomalyWebhookPayload struct {
+	WorkerID    string  `json:"worker_id"`
+	WorkerName  string  `json:"worker_name"`
+	WorkspaceID string  `json:"workspace_id"`
+	AgentID     string  `json:"agent_id"`
+	Kind        string  `json:"kind"`
+	Count       float64 `json:"count"`
+	Limit       float64 `json:"limit"`
+	Command     string  `json:"command,omitempty"`
+	DetectedAt  string  `json:"detected_at"`
+}
+
+// postAnomalyWebhook delivers a to the configured webhook once; a failure
+// is logged, since the chat notification already records it.
+func (svc *Service) postAnomalyWebhook(agentID string, a anomaly, at time.Time) {
+	ctx, cancel := context.WithTimeout(bgCtx(), anomalyWebhookTimeout)
+	defer cancel()
+	workspaceID, _ := svc.Queries.GetAgentWorkspaceID(ctx, agentID)
+	body, err := json.Marshal(anomalyWebhookPayload{
+		WorkerID:    svc.WorkerID,
+		WorkerName:  svc.Name,
+		WorkspaceID: workspaceID,
+		AgentID:     agentID,
+		Kind:        a.kind,
+		Count:       a.count,
+		Limit:       a.limit,
+		Command:     a.command,
+		DetectedAt:  timefmt.Format(at),
+	})
+	if err != nil {
+		slog.Warn("failed to encode anomaly webhook", "agent_id", agentID, "error", err)
+		return
+	}
+	if err := postJSON(ctx, svc.Anomaly.WebhookURL, body); err != nil {
+		slog.Warn("failed to send anomaly webhook", "agent_id", agentID, "kind", a.kind, "error", err)
+	}
+}
+
+func postJSON(ctx context.Context, url string, body []byte) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, url, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return err
+	}
+	_ = resp.Body.Close()
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return fmt.Errorf("webhook returned %s", resp.Status)
+	}
+	return nil
+}
+
+func truncateCommand(command string) string {
+	if len(command) <= maxAnomalyCommandLen {
+		return command
+	}
+	cut := maxAnomalyCommandLen
+	for cut > 0 && !utf8.RuneStart(command[cut]) {
+		cut--
+	}
+	return command[:cut] + "…"
+}
+
+// countDeletedPaths counts the paths command names for deletion: the
+// operands of rm, rmdir, unlink, shred and git rm, and the start paths of
+// a find that deletes what it finds. A glob counts as one path, and a
+// recursive delete as one per operand, so the count is a floor.
+func countDeletedPaths(command string) int {
+	n := 0
+	for _, words := range shellCommands(command) {
+		// Skip environment assignments and privilege wrappers.
+		for len(words) > 0 && (strings.Contains(words[0], "=") || words[0] == "sudo" || words[0] == "command") {
+			words = words[1:]
+		}
+		if len(words) == 0 {
+			continue
+		}
+		name := words[0]
+		if i := strings.LastIndexByte(name, '/'); i >= 0 {
+			name = name[i+1:]
+		}
+		switch name {
+		case "rm", "rmdir", "unlink", "shred":
+			n += countOperands(words[1:])
+		case "git":
+			// Find the subcommand past git's own options; -C and -c take
+			// a value.
+			i := 1
+			for i < len(words) && strings.HasPrefix(words[i], "-") {
+				if words[i] == "-C" || words[i] == "-c" {
+					i++
+				}
+				i++
+			}
+			if i < len(words) && words[i] == "rm" {
+				n += countOperands(words[i+1:])
+			}
+		case "find":
+			// Start paths come before the first expression word.
+			starts, deletes := 0, false
+			inExpr := false
+			for _, w := range words[1:] {
+				if w == "-delete" {
+					deletes = true
+				}
+				if strings.HasPrefix(w, "-") || w == "(" || w == "!" {
+					inExpr = true
+				}
+				if !inExpr {
+					starts++
+				}
+			}
+			if deletes {
+				n += max(starts, 1)
+			}
+		}
+	}
+	return n
+}
+
+// countOperands counts the non-option words of args; everything after
+// "--" is an operand.
+func countOperands(args []string) int {
+	n := 0
+	for i, a := range args {
+		if a == "--" {
+			return n + len(args) - i - 1
+		}
+		if !strings.HasPrefix(a, "-") {
+			n++
+		}
+	}
+	return n
+}
+
+// shellCommands splits a shell command line into the words of each
+// simple command in it, honoring quotes and backslashes and splitting at
+// unquoted ;, &, | and newlines. It does not expand anything.
+func shellCommands(line string) [][]string {
+	var commands [][]string
+	var words []string
+	var word strings.Builder
+	inWord := false
+	var quote rune
+	flushWord := func() {
+		if inWord {
+			words = append(words, word.String())
+			word.Reset()
+			inWord = false
+		}
+	}
+	flushCommand := func() {
+		flushWord()
+		if len(words) > 0 {
+			commands = append(commands, words)
+			words = nil
+		}
+	}
+	runes := []rune(line)
+	for i := 0; i < len(runes); i++ {
+		r := runes[i]
+		switch {
+		case quote != 0:
+			switch {
+			case r == quote:
+				quote = 0
+			case r == '\\' && quote == '"' && i+1 < len(runes):
+				i++
+				word.WriteRune(runes[i])
+			default:
+				word.WriteRune(r)
+			}
+		case r == '\'' || r == '"':
+			quote, inWord = r, true
+		case r == '\\' && i+1 < len(runes):
+			i++
+			word.WriteRune(runes[i])
+			inWord = true
+		case r == ';' || r == '&' || r == '|' || r == '\n':
+			flushCommand()
+		case r == ' ' || r == '\t':
+			flushWord()
+		default:
+			word.WriteRune(r)
+			inWord = true
+		}
+	}
+	flushCommand()
+	return commands
+}
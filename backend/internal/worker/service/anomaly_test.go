@@ -0,0 +1,132 @@
+package service
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	leapmuxv1 "github.com/leapmux/leapmux/generated/proto/leapmux/v1"
+	"github.com/leapmux/leapmux/internal/worker/agent"
+)
+
+func anomalyNotifications(t *testing.T, svc *Service) []map[string]any {
+	t.Helper()
+	return findNotificationsByType(readAllNotifications(t, svc.Queries, "agent-1"), agent.NotificationTypeAgentAnomaly)
+}
+
+func runTool(t *testing.T, sink agent.OutputSink, command string) {
+	t.Helper()
+	require.NoError(t, sink.PersistMessage(leapmuxv1.MessageSource_MESSAGE_SOURCE_AGENT, []byte(`{"type":"assistant"}`),
+		agent.SpanInfo{ToolCall: &agent.ToolCall{Name: agent.ToolNameBash, Command: command}}))
+}
+
+func TestAnomaly_FlagsEachKindOncePerTurn(t *testing.T) {
+	svc, _, _ := setupTestService(t, withWorkspaces("ws-1"))
+	svc.Anomaly = AnomalyPolicy{MaxToolCalls: 5, MaxRepeatedCommands: 3, MaxDeletedPaths: 4, MaxTurnCostUSD: 1}
+	row := seedGuardedAgent(t, svc, "default")
+	sink := svc.Output.NewSink(row.ID, row.AgentProvider)
+
+	runTool(t, sink, "go test ./...")
+	runTool(t, sink, "go test ./...")
+	assert.Empty(t, anomalyNotifications(t, svc))
+	runTool(t, sink, "go test ./...")
+	runTool(t, sink, "go test ./...")
+	notes := anomalyNotifications(t, svc)
+	require.Len(t, notes, 1, "a kind is flagged once per turn")
+	assert.Equal(t, anomalyRepeatedCommand, notes[0]["kind"])
+	assert.Equal(t, "go test ./...", notes[0]["command"])
+	assert.EqualValues(t, 3, notes[0]["count"])
+
+	runTool(t, sink, "rm -rf build dist && rm a.txt b.txt")
+	notes = anomalyNotifications(t, svc)
+	require.Len(t, notes, 3)
+	assert.Equal(t, anomalyToolLoop, notes[1]["kind"])
+	assert.Equal(t, anomalyFileDeletions, notes[2]["kind"])
+	assert.EqualValues(t, 4, notes[2]["count"])
+
+	require.NoError(t, sink.PersistMessage(leapmuxv1.MessageSource_MESSAGE_SOURCE_AGENT, []byte(`{"type":"assistant"}`),
+		agent.SpanInfo{Usage: &agent.MessageUsage{CostUSD: 0.6}}))
+	require.NoError(t, sink.PersistTurnEnd([]byte(`{"type":"result"}`),
+		agent.SpanInfo{Usage: &agent.MessageUsage{CostUSD: 0.6, InTurn: true}}))
+	assert.Len(t, anomalyNotifications(t, svc), 3, "usage the turn total repeats is not counted twice")
+
+	runTool(t, sink, "go test ./...")
+	runTool(t, sink, "go test ./...")
+	assert.Len(t, anomalyNotifications(t, svc), 3, "a new turn starts its counts over")
+}
+
+func TestAnomaly_TurnCost(t *testing.T) {
+	svc, _, _ := setupTestService(t, withWorkspaces("ws-1"))
+	svc.Anomaly = AnomalyPolicy{MaxTurnCostUSD: 1}
+	row := seedGuardedAgent(t, svc, "default")
+	sink := svc.Output.NewSink(row.ID, row.AgentProvider)
+
+	require.NoError(t, sink.PersistTurnEnd([]byte(`{"type":"result"}`),
+		agent.SpanInfo{Usage: &agent.MessageUsage{CostUSD: 1.25, Turn: true}}))
+	notes := anomalyNotifications(t, svc)
+	require.Len(t, notes, 1)
+	assert.Equal(t, anomalyTurnCost, notes[0]["kind"])
+	assert.InDelta(t, 1.25, notes[0]["count"], 1e-9)
+}
+
+func TestAnomaly_PostsWebhook(t *testing.T) {
+	received := make(chan anomalyWebhookPayload, 1)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var p anomalyWebhookPayload
+		assert.NoError(t, json.NewDecoder(r.Body).Decode(&p))
+		received <- p
+	}))
+	defer srv.Close()
+
+	svc, _, _ := setupTestService(t, withWorkspaces("ws-1"))
+	svc.Anomaly = AnomalyPolicy{MaxToolCalls: 1, WebhookURL: srv.URL}
+	row := seedGuardedAgent(t, svc, "default")
+	runTool(t, svc.Output.NewSink(row.ID, row.AgentProvider), "ls")
+
+	select {
+	case p := <-received:
+		assert.Equal(t, row.ID, p.AgentID)
+		assert.Equal(t, "ws-1", p.WorkspaceID)
+		assert.Equal(t, anomalyToolLoop, p.Kind)
+		assert.EqualValues(t, 1, p.Limit)
+	case <-time.After(5 * time.Second):
+		t.Fatal("webhook not called")
+	}
+}
+
+func TestCountDeletedPaths(t *testing.T) {
+	for _, tc := range []struct {
+		command string
+		want    int
+	}{
+		{"ls -la", 0},
+		{"rm -rf build", 1},
+		{"rm -f a.txt 'b c.txt' -- -weird", 3},
+		{"cd src && rm *.o; rmdir tmp", 2},
+		{"echo 'rm -rf /' | grep rm", 0},
+		{"sudo FOO=1 /bin/rm -r x y", 2},
+		{"git rm --cached a b", 2},
+		{"git -C repo rm -r docs", 1},
+		{"git status", 0},
+		{"find . -name '*.tmp' -delete", 1},
+		{"find a b -type f -delete", 2},
+		{"find . -name '*.tmp'", 0},
+		{"unlink x\nshred -u y", 2},
+	} {
+		assert.Equal(t, tc.want, countDeletedPaths(tc.command), tc.command)
+	}
+}
+
+func TestTruncateCommand(t *testing.T) {
+	long := strings.Repeat("é", maxAnomalyCommandLen)
+	got := truncateCommand(long)
+	assert.True(t, strings.HasSuffix(got, "…"))
+	assert.LessOrEqual(t, len(got), maxAnomalyCommandLen+len("…"))
+	assert.Equal(t, "ls", truncateCommand("ls"))
+}
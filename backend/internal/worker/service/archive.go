@@ -0,0 +1,295 @@
+package service
+
+import (
+	"bytes"
+	"compress/gzip"
+	"context"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"strings"
+	"time"
+
+	leapmuxv1 "github.com/leapmux/leapmux/generated/proto/leapmux/v1"
+	"github.com/leapmux/leapmux/internal/util/agentlabels"
+	"github.com/leapmux/leapmux/internal/util/msgcodec"
+	"github.com/leapmux/leapmux/internal/util/periodic"
+	"github.com/leapmux/leapmux/internal/util/timefmt"
+	db "github.com/leapmux/leapmux/internal/worker/generated/db"
+)
+
+const (
+	archiveInterval    = 10 * time.Minute
+	archiveJitter      = 1 * time.Minute
+	archiveBatch       = 50
+	archiveContentType = "application/x-ndjson"
+)
+
+// The records of a shipped object, one JSON object per line. A transcript
+// object holds an archivedAgent line, then an archivedMessage line per
+// message in seq order; an audit object holds an archivedDeletion line
+// per tombstoned message. The format is documented for offline queries,
+// so fields are only ever added.
+type archivedAgent struct {
+	Type          string `json:"type"` // "agent"
+	AgentID       string `json:"agent_id"`
+	OrgID         string `json:"org_id"`
+	WorkspaceID   string `json:"workspace_id"`
+	WorkerID      string `json:"worker_id"`
+	Title         string `json:"title"`
+	AgentProvider string `json:"agent_provider"`
+	CreatedBy     string `json:"created_by,omitempty"`
+	CreatedAt     string `json:"created_at"`
+	ClosedAt      string `json:"closed_at"`
+	// ChainHeadHash is the agent's last message chain link, when its org
+	// keeps immutable transcripts; VerifyAgentTranscript reports the same.
+	ChainHeadHash string `json:"chain_head_hash,omitempty"`
+}
+
+type archivedMessage struct {
+	Type               string          `json:"type"` // "message"
+	AgentID            string          `json:"agent_id"`
+	MessageID          string          `json:"message_id"`
+	Seq                int64           `json:"seq"`
+	Source             string          `json:"source"`
+	Content            json.RawMessage `json:"content"`
+	DeliveryError      string          `json:"delivery_error,omitempty"`
+	ClassificationTags []string        `json:"classification_tags,omitempty"`
+	CreatedAt          string          `json:"created_at"`
+}
+
+type archivedDeletion struct {
+	Type          string          `json:"type"` // "message_deleted"
+	AgentID       string          `json:"agent_id"`
+	MessageID     string          `json:"message_id"`
+	Seq           int64           `json:"seq"`
+	Source        string          `json:"source"`
+	Content       json.RawMessage `json:"content"`
+	DeliveryError string          `json:"delivery_error,omitempty"`
+	CreatedAt     string          `json:"created_at"`
+	DeletedBy     string          `json:"deleted_by"`
+	DeletedAt     string          `json:"deleted_at"`
+}
+
+// StartArchiveLoop ships closed agents' transcripts and audit records to
+// the Archive bucket, oldest first, and deletes shipped objects older
+// than ArchiveRetention. A no-op when no bucket is configured. Nothing is
+// shipped until the Hub has delivered the worker's org.
+func (svc *Service) StartArchiveLoop(ctx context.Context) {
+	if svc.Archive == nil {
+		return
+	}
+	periodic.Start(ctx, periodic.Schedule{Interval: archiveInterval, Jitter: archiveJitter}, func(ctx context.Context) {
+		orgID := svc.OrgID()
+		if orgID == "" {
+			return
+		}
+		svc.shipClosedAgents(ctx, orgID)
+		svc.expireArchive(ctx, orgID)
+	})
+}
+
+// shipClosedAgents ships every closed agent not shipped yet. It stops at
+// the first failure, which is the bucket's, and picks up from there on
+// the next run.
+func (svc *Service) shipClosedAgents(ctx context.Context, orgID string) {
+	for {
+		agents, err := svc.Queries.ListUnarchivedClosedAgents(ctx, archiveBatch)
+		if err != nil {
+			slog.Error("archive: list closed agents", "error", err)
+			return
+		}
+		for _, a := range agents {
+			params, err := svc.shipAgent(ctx, orgID, a)
+			if err != nil {
+				slog.Warn("archive: failed to ship agent", "agent_id", a.ID, "error", err)
+				return
+			}
+			if err := svc.Queries.CreateAgentArchive(ctx, params); err != nil {
+				slog.Error("archive: record shipped agent", "agent_id", a.ID, "error", err)
+				return
+			}
+		}
+		if len(agents) < archiveBatch {
+			return
+		}
+	}
+}
+
+// shipAgent writes a's transcript, and its audit records when it has
+// any, under <prefix>/<kind>/org=<org>/date=<day closed>/<agent>.jsonl.gz.
+func (svc *Service) shipAgent(ctx context.Context, orgID string, a db.Agent) (db.CreateAgentArchiveParams, error) {
+	partition := []string{"org=" + orgID, "date=" + a.ClosedAt.Time.UTC().Format(time.DateOnly), a.ID + ".jsonl.gz"}
+	params := db.CreateAgentArchiveParams{
+		AgentID:       a.ID,
+		TranscriptKey: svc.Archive.Key(append([]string{"transcripts"}, partition...)...),
+	}
+
+	transcript, err := svc.archivedTranscript(ctx, orgID, a)
+	if err != nil {
+		return params, err
+	}
+	if err := svc.Archive.Put(ctx, params.TranscriptKey, transcript, archiveContentType); err != nil {
+		return params, err
+	}
+
+	tombstones, err := svc.Queries.ListMessageTombstonesByAgentID(ctx, a.ID)
+	if err != nil {
+		return params, fmt.Errorf("list tombstones: %w", err)
+	}
+	if len(tombstones) == 0 {
+		return params, nil
+	}
+	records := make([]any, 0, len(tombstones))
+	for _, t := range tombstones {
+		records = append(records, archivedDeletion{
+			Type:          "message_deleted",
+			AgentID:       t.AgentID,
+			MessageID:     t.MessageID,
+			Seq:           t.Seq,
+			Source:        archivedSource(t.Source),
+			Content:       archivedContent(t.Content, t.ContentCompression),
+			DeliveryError: t.DeliveryError,
+			CreatedAt:     timefmt.Format(t.CreatedAt.Time),
+			DeletedBy:     t.DeletedBy,
+			DeletedAt:     timefmt.Format(t.DeletedAt.Time),
+		})
+	}
+	audit, err := gzipJSONLines(records)
+	if err != nil {
+		return params, err
+	}
+	params.AuditKey = svc.Archive.Key(append([]string{"audit"}, partition...)...)
+	if err := svc.Archive.Put(ctx, params.AuditKey, audit, archiveContentType); err != nil {
+		return params, err
+	}
+	return params, nil
+}
+
+// archivedTranscript renders a's header and messages as a gzipped
+// transcript object.
+func (svc *Service) archivedTranscript(ctx context.Context, orgID string, a db.Agent) ([]byte, error) {
+	rows, err := svc.Queries.ListAllMessagesByAgentID(ctx, db.ListAllMessagesByAgentIDParams{AgentID: a.ID, Seq: 0})
+	if err != nil {
+		return nil, fmt.Errorf("list messages: %w", err)
+	}
+	classifications, err := messageClassifications(ctx, svc.Queries, a.ID)
+	if err != nil {
+		return nil, fmt.Errorf("list classifications: %w", err)
+	}
+	chain, err := svc.Queries.ListMessageChainByAgentID(ctx, a.ID)
+	if err != nil {
+		return nil, fmt.Errorf("list chain: %w", err)
+	}
+
+	header := archivedAgent{
+		Type:          "agent",
+		AgentID:       a.ID,
+		OrgID:         orgID,
+		WorkspaceID:   a.WorkspaceID,
+		WorkerID:      svc.WorkerID,
+		Title:         a.Title,
+		AgentProvider: agentlabels.CLIAlias(a.AgentProvider),
+		CreatedBy:     a.CreatedBy,
+		CreatedAt:     timefmt.Format(a.CreatedAt.Time),
+		ClosedAt:      timefmt.Format(a.ClosedAt.Time),
+	}
+	if len(chain) > 0 {
+		header.ChainHeadHash = chain[len(chain)-1].Hash
+	}
+	records := make([]any, 0, len(rows)+1)
+	records = append(records, header)
+	for _, m := range rows {
+		records = append(records, archivedMessage{
+			Type:               "message",
+			AgentID:            m.AgentID,
+			MessageID:          m.ID,
+			Seq:                m.Seq,
+			Source:             archivedSource(m.Source),
+			Content:            archivedContent(m.Content, m.ContentCompression),
+			DeliveryError:      m.DeliveryError,
+			ClassificationTags: classifications[m.ID],
+			CreatedAt:          timefmt.Format(m.CreatedAt.Time),
+		})
+	}
+	return gzipJSONLines(records)
+}
+
+// archivedSource names a message source the way the archive format
+// documents it: "agent", "user", ...
+func archivedSource(s leapmuxv1.MessageSource) string {
+	return strings.ToLower(strings.TrimPrefix(s.String(), "MESSAGE_SOURCE_"))
+}
+
+// archivedContent returns a message's content as JSON: as is when it is
+// JSON already, as a string otherwise, and as null when it cannot be
+// decompressed, so one bad row does not hold up the whole shipment.
+func archivedContent(content []byte, compression leapmuxv1.ContentCompression) json.RawMessage {
+	raw, err := msgcodec.Decompress(content, compression)
+	if err != nil {
+		return json.RawMessage("null")
+	}
+	if json.Valid(raw) {
+		return raw
+	}
+	quoted, _ := json.Marshal(string(raw))
+	return quoted
+}
+
+// gzipJSONLines encodes records one per line and gzips the result.
+func gzipJSONLines(records []any) ([]byte, error) {
+	var buf bytes.Buffer
+	gz := gzip.NewWriter(&buf)
+	enc := json.NewEncoder(gz)
+	for _, r := range records {
+		if err := enc.Encode(r); err != nil {
+			return nil, err
+		}
+	}
+	if err := gz.Close(); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// expireArchive deletes the org's shipped objects whose date partition is
+// older than ArchiveRetention. Nothing expires while the org keeps
+// immutable transcripts.
+func (svc *Service) expireArchive(ctx context.Context, orgID string) {
+	if svc.ArchiveRetention <= 0 || svc.ImmutableTranscripts() {
+		return
+	}
+	cutoff := time.Now().Add(-svc.ArchiveRetention).UTC().Format(time.DateOnly)
+	var expired int
+	for _, kind := range []string{"transcripts", "audit"} {
+		keys, err := svc.Archive.List(ctx, svc.Archive.Key(kind, "org="+orgID)+"/")
+		if err != nil {
+			slog.Warn("archive: failed to list shipped objects", "error", err)
+			return
+		}
+		for _, key := range keys {
+			day, ok := archiveKeyDate(key)
+			if !ok || day >= cutoff {
+				continue
+			}
+			if err := svc.Archive.Delete(ctx, key); err != nil {
+				slog.Warn("archive: failed to delete expired object", "key", key, "error", err)
+				return
+			}
+			expired++
+		}
+	}
+	if expired > 0 {
+		slog.Info("archive: deleted expired objects", "count", expired)
+	}
+}
+
+// archiveKeyDate returns the YYYY-MM-DD of key's date= segment.
+func archiveKeyDate(key string) (string, bool) {
+	for _, part := range strings.Split(key, "/") {
+		if day, ok := strings.CutPrefix(part, "date="); ok {
+			return day, true
+		}
+	}
+	return "", false
+}
@@ -0,0 +1,147 @@
+package service
+
+import (
+	"bufio"
+	"bytes"
+	"compress/gzip"
+	"context"
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	leapmuxv1 "github.com/leapmux/leapmux/generated/proto/leapmux/v1"
+	"github.com/leapmux/leapmux/internal/util/sqltime"
+	"github.com/leapmux/leapmux/internal/util/userid"
+	"github.com/leapmux/leapmux/internal/worker/agent"
+	"github.com/leapmux/leapmux/internal/worker/archive"
+	"github.com/leapmux/leapmux/internal/worker/archive/archivetest"
+	db "github.com/leapmux/leapmux/internal/worker/generated/db"
+)
+
+// readJSONLines gunzips body and decodes each of its lines.
+func readJSONLines(t *testing.T, body []byte) []map[string]any {
+	t.Helper()
+	gz, err := gzip.NewReader(bytes.NewReader(body))
+	require.NoError(t, err)
+	var records []map[string]any
+	scanner := bufio.NewScanner(gz)
+	for scanner.Scan() {
+		var r map[string]any
+		require.NoError(t, json.Unmarshal(scanner.Bytes(), &r))
+		records = append(records, r)
+	}
+	require.NoError(t, scanner.Err())
+	return records
+}
+
+func TestArchive_ShipsClosedAgentsAndGatesCleanup(t *testing.T) {
+	srv := archivetest.NewServer(t)
+	bucket, err := archive.New(archive.Config{
+		Endpoint:        srv.URL,
+		Bucket:          "logs",
+		Prefix:          "leapmux",
+		AccessKeyID:     "k",
+		SecretAccessKey: "s",
+	})
+	require.NoError(t, err)
+	svc, _, _ := setupTestService(t, withWorkspaces("ws-1"))
+	svc.Archive = bucket
+	svc.SetOrgID("org-1")
+	seedGuardedAgent(t, svc, "")
+	ctx := context.Background()
+
+	require.NoError(t, svc.Output.persistAndBroadcast("agent-1", claudeProvider,
+		leapmuxv1.MessageSource_MESSAGE_SOURCE_AGENT,
+		[]byte(`{"type":"assistant","message":{"content":[{"type":"text","text":"Done."}]}}`), agent.SpanInfo{}, nil))
+	failed := db.CreateMessageParams{
+		ID:            "msg-failed",
+		AgentID:       "agent-1",
+		Source:        leapmuxv1.MessageSource_MESSAGE_SOURCE_USER,
+		Content:       []byte(`{"type":"text","text":"hi"}`),
+		AgentProvider: claudeProvider,
+		CreatedAt:     sqltime.NewSQLiteTime(time.Now()),
+	}
+	_, err = createMessageRow(ctx, svc.Queries, failed)
+	require.NoError(t, err)
+	row, err := svc.Queries.GetMessageByAgentAndID(ctx, db.GetMessageByAgentAndIDParams{ID: "msg-failed", AgentID: "agent-1"})
+	require.NoError(t, err)
+	_, err = svc.tombstoneMessage(ctx, userid.MustNew("user-1"), row)
+	require.NoError(t, err)
+
+	// An open agent is not shipped.
+	svc.shipClosedAgents(ctx, "org-1")
+	assert.Empty(t, srv.Keys("logs"))
+
+	require.NoError(t, svc.Queries.CloseAgent(ctx, "agent-1"))
+	svc.shipClosedAgents(ctx, "org-1")
+	day := time.Now().UTC().Format(time.DateOnly)
+	transcriptKey := "leapmux/transcripts/org=org-1/date=" + day + "/agent-1.jsonl.gz"
+	auditKey := "leapmux/audit/org=org-1/date=" + day + "/agent-1.jsonl.gz"
+	assert.Equal(t, []string{auditKey, transcriptKey}, srv.Keys("logs"))
+
+	body, ok := srv.Object("logs", transcriptKey)
+	require.True(t, ok)
+	records := readJSONLines(t, body)
+	require.Len(t, records, 2)
+	assert.Equal(t, "agent", records[0]["type"])
+	assert.Equal(t, "org-1", records[0]["org_id"])
+	assert.Equal(t, "ws-1", records[0]["workspace_id"])
+	assert.Equal(t, "message", records[1]["type"])
+	assert.Equal(t, "agent", records[1]["source"])
+	assert.Equal(t, "assistant", records[1]["content"].(map[string]any)["type"])
+
+	body, ok = srv.Object("logs", auditKey)
+	require.True(t, ok)
+	records = readJSONLines(t, body)
+	require.Len(t, records, 1)
+	assert.Equal(t, "message_deleted", records[0]["type"])
+	assert.Equal(t, "msg-failed", records[0]["message_id"])
+	assert.Equal(t, "user-1", records[0]["deleted_by"])
+
+	// A second run ships nothing new.
+	unshipped, err := svc.Queries.ListUnarchivedClosedAgents(ctx, archiveBatch)
+	require.NoError(t, err)
+	assert.Empty(t, unshipped)
+
+	// With archiving on, cleanup deletes only the shipped agent.
+	require.NoError(t, svc.Queries.CreateAgent(ctx, db.CreateAgentParams{
+		ID:            "agent-2",
+		WorkspaceID:   "ws-1",
+		AgentProvider: claudeProvider,
+	}))
+	require.NoError(t, svc.Queries.CloseAgent(ctx, "agent-2"))
+	runCleanup(ctx, svc.Queries, -time.Hour, false, true)
+	_, err = svc.Queries.GetAgentByID(ctx, "agent-1")
+	assert.Error(t, err)
+	_, err = svc.Queries.GetAgentByID(ctx, "agent-2")
+	assert.NoError(t, err)
+}
+
+func TestArchive_ExpiresOldObjects(t *testing.T) {
+	srv := archivetest.NewServer(t)
+	bucket, err := archive.New(archive.Config{Endpoint: srv.URL, Bucket: "logs", AccessKeyID: "k", SecretAccessKey: "s"})
+	require.NoError(t, err)
+	svc, _, _ := setupTestService(t)
+	svc.Archive = bucket
+	svc.ArchiveRetention = 30 * 24 * time.Hour
+	ctx := context.Background()
+
+	old := "transcripts/org=org-1/date=" + time.Now().AddDate(0, 0, -40).UTC().Format(time.DateOnly) + "/a.jsonl.gz"
+	recent := "transcripts/org=org-1/date=" + time.Now().UTC().Format(time.DateOnly) + "/b.jsonl.gz"
+	otherOrg := "transcripts/org=org-2/date=2020-01-01/c.jsonl.gz"
+	for _, k := range []string{old, recent, otherOrg} {
+		require.NoError(t, bucket.Put(ctx, k, []byte("x"), archiveContentType))
+	}
+
+	// Nothing expires while the org keeps immutable transcripts.
+	svc.SetOrgDefaults(&leapmuxv1.OrgDefaults{ImmutableTranscripts: true})
+	svc.expireArchive(ctx, "org-1")
+	assert.Len(t, srv.Keys("logs"), 3)
+
+	svc.SetOrgDefaults(&leapmuxv1.OrgDefaults{})
+	svc.expireArchive(ctx, "org-1")
+	assert.Equal(t, []string{recent, otherOrg}, srv.Keys("logs"))
+}
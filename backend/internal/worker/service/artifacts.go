@@ -0,0 +1,313 @@
+package service
+
+import (
+	"context"
+	"crypto/sha256"
+	"database/sql"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+
+	leapmuxv1 "github.com/leapmux/leapmux/generated/proto/leapmux/v1"
+	"github.com/leapmux/leapmux/internal/util/timefmt"
+	"github.com/leapmux/leapmux/internal/util/userid"
+	"github.com/leapmux/leapmux/internal/worker/channel"
+	db "github.com/leapmux/leapmux/internal/worker/generated/db"
+)
+
+const (
+	// artifactsDirName holds the snapshots, one directory per agent.
+	artifactsDirName = "artifacts"
+	// maxArtifactPatterns and maxArtifactPatternLen bound what an agent
+	// may ask to be collected.
+	maxArtifactPatterns   = 32
+	maxArtifactPatternLen = 256
+	// maxArtifactsPerTurn caps the snapshots one turn end takes, so a
+	// pattern that matches a whole tree cannot stall the agent's worker.
+	maxArtifactsPerTurn = 50
+	// maxArtifactBytes is the largest file collected; bigger ones are
+	// skipped.
+	maxArtifactBytes = 64 << 20
+	// maxAgentArtifacts is how many snapshots each agent keeps; the
+	// oldest go first.
+	maxAgentArtifacts = 200
+)
+
+// validateArtifactPatterns rejects a pattern that is malformed or could
+// reach outside the working directory.
+func validateArtifactPatterns(patterns []string) error {
+	if len(patterns) > maxArtifactPatterns {
+		return fmt.Errorf("at most %d patterns are allowed", maxArtifactPatterns)
+	}
+	for _, p := range patterns {
+		switch {
+		case strings.TrimSpace(p) == "":
+			return errors.New("patterns must not be empty")
+		case len(p) > maxArtifactPatternLen:
+			return fmt.Errorf("patterns must be at most %d bytes", maxArtifactPatternLen)
+		case filepath.IsAbs(p) || strings.HasPrefix(p, "/"):
+			return fmt.Errorf("pattern %q must be relative to the working directory", p)
+		case slices.Contains(strings.Split(filepath.ToSlash(p), "/"), ".."):
+			return fmt.Errorf("pattern %q must not contain ..", p)
+		}
+		if _, err := filepath.Match(p, ""); err != nil {
+			return fmt.Errorf("pattern %q: %w", p, err)
+		}
+	}
+	return nil
+}
+
+func (h *OutputHandler) artifactDir(agentID string) string {
+	return filepath.Join(h.DataDir, artifactsDirName, agentID)
+}
+
+func (h *OutputHandler) artifactFile(agentID string, id int64) string {
+	return filepath.Join(h.artifactDir(agentID), strconv.FormatInt(id, 10))
+}
+
+// collectArtifacts snapshots every file matching agentID's patterns that
+// changed since its last snapshot. Run at each turn end, off the agent's
+// output loop; one collection per agent runs at a time. Like the other
+// turn-end bookkeeping, failures are logged, not surfaced.
+func (h *OutputHandler) collectArtifacts(agentID string) {
+	if h.DataDir == "" {
+		return
+	}
+	lock, _ := h.artifactLocks.LoadOrStore(agentID, &sync.Mutex{})
+	mu := lock.(*sync.Mutex)
+	mu.Lock()
+	defer mu.Unlock()
+
+	ctx := bgCtx()
+	raw, err := h.queries.GetAgentArtifactPatterns(ctx, agentID)
+	if errors.Is(err, sql.ErrNoRows) {
+		return
+	} else if err != nil {
+		slog.Warn("failed to read artifact patterns", "agent_id", agentID, "error", err)
+		return
+	}
+	var patterns []string
+	if err := json.Unmarshal([]byte(raw), &patterns); err != nil || len(patterns) == 0 {
+		return
+	}
+	agentRow, err := h.queries.GetAgentByID(ctx, agentID)
+	if err != nil {
+		slog.Warn("failed to look up agent for artifacts", "agent_id", agentID, "error", err)
+		return
+	}
+	root := filepath.Clean(agentRow.WorkingDir)
+
+	collected := 0
+	seen := make(map[string]bool)
+	for _, pattern := range patterns {
+		matches, err := filepath.Glob(filepath.Join(root, filepath.FromSlash(pattern)))
+		if err != nil {
+			continue
+		}
+		for _, match := range matches {
+			rel, err := filepath.Rel(root, match)
+			if err != nil || rel == ".." || strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+				continue
+			}
+			rel = filepath.ToSlash(rel)
+			if seen[rel] {
+				continue
+			}
+			seen[rel] = true
+			if collected == maxArtifactsPerTurn {
+				slog.Warn("artifact patterns match too many files; the rest wait for the next turn",
+					"agent_id", agentID, "limit", maxArtifactsPerTurn)
+				break
+			}
+			ok, err := h.collectArtifact(ctx, agentID, match, rel)
+			if err != nil {
+				slog.Warn("failed to collect artifact", "agent_id", agentID, "path", rel, "error", err)
+				continue
+			}
+			if ok {
+				collected++
+			}
+		}
+	}
+	if collected == 0 {
+		return
+	}
+	dropped, err := h.queries.PruneAgentArtifacts(ctx, db.PruneAgentArtifactsParams{AgentID: agentID, Keep: maxAgentArtifacts})
+	if err != nil {
+		slog.Warn("failed to prune artifacts", "agent_id", agentID, "error", err)
+		return
+	}
+	for _, id := range dropped {
+		_ = os.Remove(h.artifactFile(agentID, id))
+	}
+}
+
+// collectArtifact snapshots one file unless it is not a regular file, is
+// too big, or has not changed since its last snapshot. It reports whether
+// a snapshot was taken.
+func (h *OutputHandler) collectArtifact(ctx context.Context, agentID, path, rel string) (bool, error) {
+	info, err := os.Lstat(path)
+	if err != nil || !info.Mode().IsRegular() || info.Size() > maxArtifactBytes {
+		return false, nil
+	}
+	src, err := os.Open(path)
+	if err != nil {
+		return false, err
+	}
+	defer func() { _ = src.Close() }()
+
+	dir := h.artifactDir(agentID)
+	if err := os.MkdirAll(dir, 0o700); err != nil {
+		return false, err
+	}
+	tmp, err := os.CreateTemp(dir, ".collect-*")
+	if err != nil {
+		return false, err
+	}
+	defer func() { _ = os.Remove(tmp.Name()) }()
+	hash := sha256.New()
+	size, err := io.Copy(io.MultiWriter(tmp, hash), io.LimitReader(src, maxArtifactBytes+1))
+	if closeErr := tmp.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		return false, err
+	}
+	if size > maxArtifactBytes {
+		return false, nil // Grew past the limit while being read.
+	}
+	sum := hex.EncodeToString(hash.Sum(nil))
+	latest, err := h.queries.GetLatestAgentArtifactSHA256(ctx, db.GetLatestAgentArtifactSHA256Params{AgentID: agentID, Path: rel})
+	if err == nil && latest == sum {
+		return false, nil
+	} else if err != nil && !errors.Is(err, sql.ErrNoRows) {
+		return false, err
+	}
+
+	id, err := h.queries.CreateAgentArtifact(ctx, db.CreateAgentArtifactParams{
+		AgentID: agentID,
+		Path:    rel,
+		Size:    size,
+		Sha256:  sum,
+	})
+	if err != nil {
+		return false, err
+	}
+	if err := os.Rename(tmp.Name(), h.artifactFile(agentID, id)); err != nil {
+		_ = h.queries.DeleteAgentArtifact(ctx, id)
+		return false, err
+	}
+	return true, nil
+}
+
+// sweepOrphanedArtifacts removes the snapshot directories of agents the DB
+// no longer has. Their rows went with the agent (ON DELETE CASCADE); the
+// files are left to this sweep.
+func (svc *Service) sweepOrphanedArtifacts() {
+	if svc.DataDir == "" {
+		return
+	}
+	root := filepath.Join(svc.DataDir, artifactsDirName)
+	entries, err := os.ReadDir(root)
+	if err != nil {
+		return
+	}
+	for _, e := range entries {
+		if !e.IsDir() {
+			continue
+		}
+		if _, err := svc.Queries.GetAgentByID(bgCtx(), e.Name()); errors.Is(err, sql.ErrNoRows) {
+			if err := os.RemoveAll(filepath.Join(root, e.Name())); err != nil {
+				slog.Warn("failed to remove orphaned artifacts", "agent_id", e.Name(), "error", err)
+			}
+		}
+	}
+}
+
+func artifactToProto(row db.AgentArtifact) *leapmuxv1.AgentArtifact {
+	return &leapmuxv1.AgentArtifact{
+		Id:        row.ID,
+		AgentId:   row.AgentID,
+		Path:      row.Path,
+		Size:      row.Size,
+		Sha256:    row.Sha256,
+		CreatedAt: timefmt.Format(row.CreatedAt.Time),
+	}
+}
+
+// openArtifactDownload streams an artifact snapshot through the download
+// sub-stream family, which is why it is owner-only like DownloadFile.
+func (svc *Service) openArtifactDownload(ctx context.Context, downloads *downloadManager, r *leapmuxv1.DownloadArtifactRequest, sender channel.ResponseWriter) {
+	row, err := svc.Queries.GetAgentArtifact(ctx, r.GetArtifactId())
+	if errors.Is(err, sql.ErrNoRows) {
+		sendNotFoundError(sender, "artifact not found")
+		return
+	} else if err != nil {
+		slog.Error("failed to look up artifact", "artifact_id", r.GetArtifactId(), "error", err)
+		sendInternalError(sender, "failed to look up artifact")
+		return
+	}
+	downloads.start(ctx, r.GetDownloadId(), svc.Output.artifactFile(row.AgentID, row.ID), row.Path, r.GetOffset(), sender)
+}
+
+func registerArtifactHandlers(d registrar, svc *Service) {
+	// SetAgentArtifactPatterns is agent-gated rather than owner-only so an
+	// agent can name its own artifacts over local IPC.
+	registerAgentGated(d, "SetAgentArtifactPatterns",
+		func(ctx context.Context, _ userid.UserID, r *leapmuxv1.SetAgentArtifactPatternsRequest, agentRow db.Agent, sender channel.ResponseWriter) {
+			if err := validateArtifactPatterns(r.GetPatterns()); err != nil {
+				sendInvalidArgument(sender, err.Error())
+				return
+			}
+			if svc.DataDir == "" {
+				sendFailedPrecondition(sender, "worker has no data directory to keep artifacts in")
+				return
+			}
+			patterns, err := json.Marshal(r.GetPatterns())
+			if err != nil {
+				sendInternalError(sender, "failed to encode patterns")
+				return
+			}
+			if err := svc.Queries.SetAgentArtifactPatterns(ctx, db.SetAgentArtifactPatternsParams{
+				AgentID:  agentRow.ID,
+				Patterns: string(patterns),
+			}); err != nil {
+				slog.Error("failed to set artifact patterns", "agent_id", agentRow.ID, "error", err)
+				sendInternalError(sender, "failed to set artifact patterns")
+				return
+			}
+			sendProtoResponse(sender, &leapmuxv1.SetAgentArtifactPatternsResponse{})
+		})
+
+	registerAgentGated(d, "ListAgentArtifacts",
+		func(ctx context.Context, _ userid.UserID, r *leapmuxv1.ListAgentArtifactsRequest, agentRow db.Agent, sender channel.ResponseWriter) {
+			resp := &leapmuxv1.ListAgentArtifactsResponse{}
+			raw, err := svc.Queries.GetAgentArtifactPatterns(ctx, agentRow.ID)
+			if err != nil && !errors.Is(err, sql.ErrNoRows) {
+				slog.Error("failed to read artifact patterns", "agent_id", agentRow.ID, "error", err)
+				sendInternalError(sender, "failed to list artifacts")
+				return
+			} else if err == nil {
+				_ = json.Unmarshal([]byte(raw), &resp.Patterns)
+			}
+			rows, err := svc.Queries.ListAgentArtifacts(ctx, agentRow.ID)
+			if err != nil {
+				slog.Error("failed to list artifacts", "agent_id", agentRow.ID, "error", err)
+				sendInternalError(sender, "failed to list artifacts")
+				return
+			}
+			for _, row := range rows {
+				resp.Artifacts = append(resp.Artifacts, artifactToProto(row))
+			}
+			sendProtoResponse(sender, resp)
+		})
+}
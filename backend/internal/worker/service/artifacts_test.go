@@ -0,0 +1,118 @@
+package service
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/protobuf/proto"
+
+	leapmuxv1 "github.com/leapmux/leapmux/generated/proto/leapmux/v1"
+	db "github.com/leapmux/leapmux/internal/worker/generated/db"
+)
+
+func TestValidateArtifactPatterns(t *testing.T) {
+	assert.NoError(t, validateArtifactPatterns([]string{"*.md", "out/**/*.png", "dist/report.html"}))
+	for _, bad := range [][]string{
+		{""},
+		{"/etc/passwd"},
+		{"../secrets/*"},
+		{"out/../../x"},
+		{"[unclosed"},
+	} {
+		assert.Error(t, validateArtifactPatterns(bad), "%q", bad)
+	}
+}
+
+func TestAgentArtifacts_CollectListDownload(t *testing.T) {
+	svc, d, w := setupTestService(t, withWorkspaces("ws-1"))
+	svc.DataDir = t.TempDir()
+	svc.Output.DataDir = svc.DataDir
+	agentRow := seedGuardedAgent(t, svc, "")
+	require.NoError(t, os.MkdirAll(filepath.Join(agentRow.WorkingDir, "out"), 0o755))
+	report := filepath.Join(agentRow.WorkingDir, "out", "report.md")
+	require.NoError(t, os.WriteFile(report, []byte("# v1"), 0o644))
+	require.NoError(t, os.WriteFile(filepath.Join(agentRow.WorkingDir, "notes.txt"), []byte("skip"), 0o644))
+
+	dispatch(d, "SetAgentArtifactPatterns", &leapmuxv1.SetAgentArtifactPatternsRequest{AgentId: "agent-1", Patterns: []string{"out/*.md"}}, w)
+	require.Empty(t, w.errors)
+
+	list := func() *leapmuxv1.ListAgentArtifactsResponse {
+		w := newTestWriter()
+		dispatch(d, "ListAgentArtifacts", &leapmuxv1.ListAgentArtifactsRequest{AgentId: "agent-1"}, w)
+		require.Empty(t, w.errors)
+		return decodeResponse[leapmuxv1.ListAgentArtifactsResponse](t, w)
+	}
+
+	svc.Output.collectArtifacts("agent-1")
+	got := list()
+	assert.Equal(t, []string{"out/*.md"}, got.GetPatterns())
+	require.Len(t, got.GetArtifacts(), 1)
+	assert.Equal(t, "out/report.md", got.GetArtifacts()[0].GetPath())
+	assert.EqualValues(t, 4, got.GetArtifacts()[0].GetSize())
+
+	// An unchanged file is not snapshotted again; a changed one is.
+	svc.Output.collectArtifacts("agent-1")
+	require.Len(t, list().GetArtifacts(), 1)
+	require.NoError(t, os.WriteFile(report, []byte("# v2!"), 0o644))
+	svc.Output.collectArtifacts("agent-1")
+	got = list()
+	require.Len(t, got.GetArtifacts(), 2)
+	assert.EqualValues(t, 5, got.GetArtifacts()[1].GetSize(), "oldest first")
+
+	// The first snapshot still downloads as it was taken.
+	oldest := got.GetArtifacts()[0]
+	dw := newTestWriter()
+	dispatch(d, "DownloadArtifact", &leapmuxv1.DownloadArtifactRequest{ArtifactId: oldest.GetId(), DownloadId: "dl-a"}, dw)
+	require.Empty(t, dw.errors)
+	require.Len(t, dw.responses, 1)
+	var resp leapmuxv1.DownloadFileResponse
+	require.NoError(t, proto.Unmarshal(dw.responses[0].GetPayload(), &resp))
+	assert.EqualValues(t, 4, resp.GetTotalSize())
+	require.Eventually(t, func() bool {
+		chunks := downloadChunks(t, dw)
+		return len(chunks) > 0 && chunks[len(chunks)-1].GetEof()
+	}, 5*time.Second, 10*time.Millisecond)
+	var data []byte
+	for _, chunk := range downloadChunks(t, dw) {
+		data = append(data, chunk.GetData()...)
+	}
+	assert.Equal(t, "# v1", string(data))
+
+	nw := newTestWriter()
+	dispatch(d, "DownloadArtifact", &leapmuxv1.DownloadArtifactRequest{ArtifactId: 9999, DownloadId: "dl-b"}, nw)
+	assert.NotEmpty(t, nw.errors)
+}
+
+func TestAgentArtifacts_SkipsFilesOutsideWorkingDir(t *testing.T) {
+	svc, _, _ := setupTestService(t, withWorkspaces("ws-1"))
+	svc.DataDir = t.TempDir()
+	svc.Output.DataDir = svc.DataDir
+	agentRow := seedGuardedAgent(t, svc, "")
+	outside := filepath.Join(t.TempDir(), "secret.md")
+	require.NoError(t, os.WriteFile(outside, []byte("secret"), 0o644))
+	require.NoError(t, os.Symlink(outside, filepath.Join(agentRow.WorkingDir, "link.md")))
+	require.NoError(t, svc.Queries.SetAgentArtifactPatterns(bgCtx(), db.SetAgentArtifactPatternsParams{AgentID: "agent-1", Patterns: `["*.md"]`}))
+
+	svc.Output.collectArtifacts("agent-1")
+	rows, err := svc.Queries.ListAgentArtifacts(bgCtx(), "agent-1")
+	require.NoError(t, err)
+	assert.Empty(t, rows, "symlinks are not followed")
+}
+
+func TestSweepOrphanedArtifacts(t *testing.T) {
+	svc, _, _ := setupTestService(t, withWorkspaces("ws-1"))
+	svc.DataDir = t.TempDir()
+	seedGuardedAgent(t, svc, "")
+	live := filepath.Join(svc.DataDir, artifactsDirName, "agent-1")
+	gone := filepath.Join(svc.DataDir, artifactsDirName, "agent-gone")
+	require.NoError(t, os.MkdirAll(live, 0o700))
+	require.NoError(t, os.MkdirAll(gone, 0o700))
+
+	svc.sweepOrphanedArtifacts()
+	assert.DirExists(t, live)
+	assert.NoDirExists(t, gone)
+}
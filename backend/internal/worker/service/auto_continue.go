@@ -7,10 +7,10 @@ import (
 	"log/slog"
 	"math"
 	"math/rand/v2"
-	"sync"
 	"time"
 
 	"github.com/leapmux/leapmux/internal/util/sqltime"
+	"github.com/leapmux/leapmux/internal/util/timefmt"
 	"github.com/leapmux/leapmux/internal/worker/agent"
 	db "github.com/leapmux/leapmux/internal/worker/generated/db"
 )
@@ -37,11 +37,6 @@ type autoContinueKey struct {
 	Reason  agent.AutoContinueReason
 }
 
-type autoContinueTimerState struct {
-	mu    sync.Mutex
-	timer *time.Timer
-}
-
 func (h *OutputHandler) restoreAutoContinueSchedules() {
 	schedules, err := h.queries.ListActiveAutoContinueSchedules(bgCtx())
 	if err != nil {
@@ -57,7 +52,49 @@ func (h *OutputHandler) restoreAutoContinueSchedules() {
 func (h *OutputHandler) scheduleAutoContinue(agentID string, schedule agent.AutoContinueSchedule) {
 	now := time.Now().UTC()
 
-	record, dueAt, err := h.buildAutoContinueRecord(agentID, schedule, now)
+	agentRow, err := h.queries.GetAgentByID(bgCtx(), agentID)
+	if err != nil {
+		slog.Error("auto-continue agent lookup failed", "agent_id", agentID, "reason", schedule.Reason, "error", err)
+		return
+	}
+	rule := h.retryRuleForAgent(agentRow, schedule.Reason)
+
+	attempt, err := h.nextAutoContinueAttempt(agentID, schedule.Reason)
+	if err != nil {
+		slog.Error("auto-continue attempt lookup failed", "agent_id", agentID, "reason", schedule.Reason, "error", err)
+		return
+	}
+
+	// A RATE_LIMITED routing rule beats waiting out the reset: move to its
+	// target model and restart the turn right away.
+	fallbackModel := ""
+	if schedule.Reason == agent.AutoContinueReasonRateLimit && h.switchModelFunc != nil {
+		if target := h.rateLimitFailoverModel(agentRow); target != "" {
+			slog.Info("auto-continue failing over rate-limited model",
+				"agent_id", agentID, "to", target)
+			h.switchModelFunc(agentID, target)
+			fallbackModel = target
+			attempt = 1
+			schedule.DueAt = now
+		}
+	}
+
+	// Over budget: switch to the policy's fallback model once (which starts
+	// the count over), or stop retrying and tell the user why.
+	if fallbackModel == "" && rule.exhausted(attempt) {
+		currentModel := loadOptions(agentRow.Options, agentRow.AgentProvider)[agent.OptionIDModel]
+		if rule.FallbackModel == "" || rule.FallbackModel == currentModel || h.switchModelFunc == nil {
+			h.giveUpAutoContinue(agentRow, schedule.Reason, attempt-1)
+			return
+		}
+		slog.Info("auto-continue switching to fallback model",
+			"agent_id", agentID, "reason", schedule.Reason, "from", currentModel, "to", rule.FallbackModel)
+		h.switchModelFunc(agentID, rule.FallbackModel)
+		fallbackModel = rule.FallbackModel
+		attempt = 1
+	}
+
+	record, dueAt, err := h.buildAutoContinueRecord(agentID, schedule, rule, attempt, now)
 	if err != nil {
 		slog.Error("auto-continue schedule build failed", "agent_id", agentID, "reason", schedule.Reason, "error", err)
 		return
@@ -70,8 +107,62 @@ func (h *OutputHandler) scheduleAutoContinue(agentID string, schedule agent.Auto
 
 	key := autoContinueKey{AgentID: agentID, Reason: schedule.Reason}
 	h.armAutoContinueTimer(key, dueAt)
+
+	h.PersistLeapMuxNotification(agentID, agentRow.AgentProvider, agent.NotificationContent(agent.RetryScheduledPayload{
+		Reason:        string(schedule.Reason),
+		Attempt:       attempt,
+		MaxAttempts:   int64(rule.MaxAttempts),
+		DueAt:         timefmt.Format(dueAt),
+		FallbackModel: fallbackModel,
+	}))
+}
+
+// nextAutoContinueAttempt returns the 1-based attempt number a new schedule
+// for (agentID, reason) represents. A fired schedule means the retried turn
+// failed again, so the count climbs; an active one is being re-armed for the
+// same failure (a refreshed rate-limit reset time), so it does not. Anything
+// else -- no row, or a cancelled one -- starts over.
+func (h *OutputHandler) nextAutoContinueAttempt(agentID string, reason agent.AutoContinueReason) (int64, error) {
+	existing, err := h.queries.GetAutoContinueSchedule(bgCtx(), db.GetAutoContinueScheduleParams{
+		AgentID: agentID,
+		Reason:  string(reason),
+	})
+	if errors.Is(err, sql.ErrNoRows) {
+		return 1, nil
+	}
+	if err != nil {
+		return 0, err
+	}
+	switch existing.State {
+	case autoContinueStateFired:
+		return existing.Attempts + 1, nil
+	case autoContinueStateActive:
+		return max(existing.Attempts, 1), nil
+	default:
+		return 1, nil
+	}
+}
+
+// giveUpAutoContinue retires any pending schedule for reason without
+// resetting its attempt count, and emits the retry_exhausted notification.
+func (h *OutputHandler) giveUpAutoContinue(agentRow db.Agent, reason agent.AutoContinueReason, attempts int64) {
+	slog.Warn("auto-continue retry budget exhausted", "agent_id", agentRow.ID, "reason", reason, "attempts", attempts)
+	if err := h.queries.CancelAutoContinueSchedule(bgCtx(), db.CancelAutoContinueScheduleParams{
+		AgentID: agentRow.ID,
+		Reason:  string(reason),
+	}); err != nil {
+		slog.Error("auto-continue cancel failed", "agent_id", agentRow.ID, "reason", reason, "error", err)
+	}
+	h.stopAutoContinueTimer(autoContinueKey{AgentID: agentRow.ID, Reason: reason}, false)
+	h.PersistLeapMuxNotification(agentRow.ID, agentRow.AgentProvider, agent.NotificationContent(agent.RetryExhaustedPayload{
+		Reason:   string(reason),
+		Attempts: attempts,
+	}))
 }
 
+// cancelAutoContinue retires the pending schedule for reason and resets its
+// attempt count. Providers call it when a turn ends without the matching
+// failure, which is what makes max_attempts a budget of CONSECUTIVE retries.
 func (h *OutputHandler) cancelAutoContinue(agentID string, reason agent.AutoContinueReason) {
 	if err := h.queries.CancelAutoContinueSchedule(bgCtx(), db.CancelAutoContinueScheduleParams{
 		AgentID: agentID,
@@ -79,6 +170,12 @@ func (h *OutputHandler) cancelAutoContinue(agentID string, reason agent.AutoCont
 	}); err != nil {
 		slog.Error("auto-continue cancel failed", "agent_id", agentID, "reason", reason, "error", err)
 	}
+	if err := h.queries.ResetAutoContinueAttempts(bgCtx(), db.ResetAutoContinueAttemptsParams{
+		AgentID: agentID,
+		Reason:  string(reason),
+	}); err != nil {
+		slog.Error("auto-continue attempt reset failed", "agent_id", agentID, "reason", reason, "error", err)
+	}
 	h.stopAutoContinueTimer(autoContinueKey{AgentID: agentID, Reason: reason}, false)
 }
 
@@ -99,40 +196,25 @@ func (h *OutputHandler) cleanupAutoContinue(agentID string) {
 // sqltime.SQLiteTime that floors to the millisecond and serializes the
 // canonical layout on bind; the builders also hand back the plain time.Time so
 // the caller arms its in-memory timer without unwrapping record.DueAt.Time.
-func (h *OutputHandler) buildAutoContinueRecord(agentID string, schedule agent.AutoContinueSchedule, now time.Time) (db.UpsertAutoContinueScheduleParams, time.Time, error) {
+func (h *OutputHandler) buildAutoContinueRecord(agentID string, schedule agent.AutoContinueSchedule, rule retryRule, attempt int64, now time.Time) (db.UpsertAutoContinueScheduleParams, time.Time, error) {
 	switch schedule.Reason {
 	case agent.AutoContinueReasonAPIError:
-		return h.buildAPIErrorScheduleRecord(agentID, schedule, now)
+		record, dueAt := buildAPIErrorScheduleRecord(agentID, schedule, rule, attempt, now)
+		return record, dueAt, nil
 	case agent.AutoContinueReasonRateLimit:
-		record, dueAt := h.buildRateLimitScheduleRecord(agentID, schedule, now)
+		record, dueAt := buildRateLimitScheduleRecord(agentID, schedule, attempt, now)
 		return record, dueAt, nil
 	default:
 		return db.UpsertAutoContinueScheduleParams{}, time.Time{}, errors.New("unknown auto-continue reason")
 	}
 }
 
-func (h *OutputHandler) buildAPIErrorScheduleRecord(agentID string, schedule agent.AutoContinueSchedule, now time.Time) (db.UpsertAutoContinueScheduleParams, time.Time, error) {
-	delay := autoContinueInitialDelay
-	nextBackoff := time.Duration(float64(autoContinueInitialDelay) * autoContinueMultiplier)
-
-	existing, err := h.queries.GetAutoContinueSchedule(bgCtx(), db.GetAutoContinueScheduleParams{
-		AgentID: agentID,
-		Reason:  string(schedule.Reason),
-	})
-	if err != nil && !errors.Is(err, sql.ErrNoRows) {
-		return db.UpsertAutoContinueScheduleParams{}, time.Time{}, err
-	}
-	if err == nil && existing.State == autoContinueStateActive && existing.NextBackoffMs > 0 {
-		delay = time.Duration(existing.NextBackoffMs) * time.Millisecond
-		nextBackoff = time.Duration(float64(delay) * autoContinueMultiplier)
-	}
-
-	if delay > autoContinueMaxDelay {
-		delay = autoContinueMaxDelay
-	}
-	if nextBackoff > autoContinueMaxDelay {
-		nextBackoff = autoContinueMaxDelay
-	}
+// buildAPIErrorScheduleRecord derives the delay from the attempt number
+// rather than from the previous row, so the backoff keeps climbing across
+// fired schedules and a policy change takes effect on the very next retry.
+func buildAPIErrorScheduleRecord(agentID string, schedule agent.AutoContinueSchedule, rule retryRule, attempt int64, now time.Time) (db.UpsertAutoContinueScheduleParams, time.Time) {
+	delay := rule.delayForAttempt(attempt)
+	nextBackoff := rule.delayForAttempt(attempt + 1)
 
 	jitter := symmetricJitter(delay, autoContinueJitterFrac)
 	// Floored via sqltime.FloorMillis because the upsert stores due_at in the
@@ -148,10 +230,11 @@ func (h *OutputHandler) buildAPIErrorScheduleRecord(agentID string, schedule age
 		JitterMs:      jitter.Milliseconds(),
 		NextBackoffMs: nextBackoff.Milliseconds(),
 		SourcePayload: cloneOrEmpty(schedule.SourcePayload),
-	}, dueAt, nil
+		Attempts:      attempt,
+	}, dueAt
 }
 
-func (h *OutputHandler) buildRateLimitScheduleRecord(agentID string, schedule agent.AutoContinueSchedule, now time.Time) (db.UpsertAutoContinueScheduleParams, time.Time) {
+func buildRateLimitScheduleRecord(agentID string, schedule agent.AutoContinueSchedule, attempt int64, now time.Time) (db.UpsertAutoContinueScheduleParams, time.Time) {
 	jitter := positiveRateLimitJitter(schedule.DueAt.Sub(now))
 	// Floored via sqltime.FloorMillis for the same DueAt.Equal roundtrip
 	// reason as buildAPIErrorScheduleRecord above.
@@ -164,26 +247,12 @@ func (h *OutputHandler) buildRateLimitScheduleRecord(agentID string, schedule ag
 		JitterMs:      jitter.Milliseconds(),
 		NextBackoffMs: 0,
 		SourcePayload: cloneOrEmpty(schedule.SourcePayload),
+		Attempts:      attempt,
 	}, dueAt
 }
 
 func (h *OutputHandler) armAutoContinueTimer(key autoContinueKey, dueAt time.Time) {
-	v, _ := h.autoContinue.LoadOrStore(key, &autoContinueTimerState{})
-	state := v.(*autoContinueTimerState)
-
-	state.mu.Lock()
-	defer state.mu.Unlock()
-
-	if state.timer != nil {
-		state.timer.Stop()
-	}
-
-	delay := time.Until(dueAt)
-	if delay < 0 {
-		delay = 0
-	}
-
-	state.timer = time.AfterFunc(delay, func() {
+	h.autoContinueTimers.arm(key, dueAt, func() {
 		h.fireAutoContinue(key, dueAt)
 	})
 
@@ -195,20 +264,7 @@ func (h *OutputHandler) armAutoContinueTimer(key autoContinueKey, dueAt time.Tim
 }
 
 func (h *OutputHandler) stopAutoContinueTimer(key autoContinueKey, remove bool) {
-	v, ok := h.autoContinue.Load(key)
-	if !ok {
-		return
-	}
-	state := v.(*autoContinueTimerState)
-	state.mu.Lock()
-	if state.timer != nil {
-		state.timer.Stop()
-		state.timer = nil
-	}
-	state.mu.Unlock()
-	if remove {
-		h.autoContinue.Delete(key)
-	}
+	h.autoContinueTimers.stop(key, remove)
 }
 
 func (h *OutputHandler) fireAutoContinue(key autoContinueKey, dueAt time.Time) {
@@ -159,7 +159,7 @@ func TestAutoContinueSchedule_ArmedDueAtSurvivesDBRoundtrip(t *testing.T) {
 		record, dueAt, err := h.buildAutoContinueRecord("agent-1", agent.AutoContinueSchedule{
 			Reason: reason,
 			DueAt:  now.Add(time.Hour),
-		}, now)
+		}, defaultRetryRule(), 1, now)
 		require.NoError(t, err, reason)
 		assert.True(t, dueAt.Equal(dueAt.Truncate(time.Millisecond)),
 			"%s: armed dueAt %s carries sub-millisecond residue the storage would floor away", reason, dueAt)
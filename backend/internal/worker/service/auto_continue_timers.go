@@ -0,0 +1,80 @@
+package service
+
+import (
+	"sort"
+	"sync"
+	"time"
+
+	leapmuxv1 "github.com/leapmux/leapmux/generated/proto/leapmux/v1"
+	"github.com/leapmux/leapmux/internal/util/timefmt"
+)
+
+type autoContinueTimerState struct {
+	mu    sync.Mutex
+	timer *time.Timer
+	dueAt time.Time
+}
+
+// autoContinueTimers holds the in-memory timers behind the persisted
+// auto-continue schedules, one per agent and reason. What a timer does
+// when it fires is up to the caller arming it.
+type autoContinueTimers struct {
+	timers sync.Map // autoContinueKey -> *autoContinueTimerState
+}
+
+// arm runs fire at dueAt (at once if it has passed), replacing any timer
+// already armed for key.
+func (t *autoContinueTimers) arm(key autoContinueKey, dueAt time.Time, fire func()) {
+	v, _ := t.timers.LoadOrStore(key, &autoContinueTimerState{})
+	state := v.(*autoContinueTimerState)
+
+	state.mu.Lock()
+	defer state.mu.Unlock()
+
+	if state.timer != nil {
+		state.timer.Stop()
+	}
+	state.dueAt = dueAt
+	state.timer = time.AfterFunc(max(time.Until(dueAt), 0), fire)
+}
+
+// stop disarms key's timer. remove also forgets its due time, which is
+// otherwise kept for DebugAgentState.
+func (t *autoContinueTimers) stop(key autoContinueKey, remove bool) {
+	v, ok := t.timers.Load(key)
+	if !ok {
+		return
+	}
+	state := v.(*autoContinueTimerState)
+	state.mu.Lock()
+	if state.timer != nil {
+		state.timer.Stop()
+		state.timer = nil
+	}
+	state.mu.Unlock()
+	if remove {
+		t.timers.Delete(key)
+	}
+}
+
+// forAgent describes agentID's timers, ordered by reason.
+func (t *autoContinueTimers) forAgent(agentID string) []*leapmuxv1.AutoContinueTimer {
+	var out []*leapmuxv1.AutoContinueTimer
+	t.timers.Range(func(k, v any) bool {
+		key := k.(autoContinueKey)
+		if key.AgentID != agentID {
+			return true
+		}
+		state := v.(*autoContinueTimerState)
+		state.mu.Lock()
+		out = append(out, &leapmuxv1.AutoContinueTimer{
+			Reason: string(key.Reason),
+			DueAt:  timefmt.Format(state.dueAt),
+			Armed:  state.timer != nil,
+		})
+		state.mu.Unlock()
+		return true
+	})
+	sort.Slice(out, func(i, j int) bool { return out[i].GetReason() < out[j].GetReason() })
+	return out
+}
@@ -0,0 +1,63 @@
+package service
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/leapmux/leapmux/internal/worker/agent"
+)
+
+func TestAutoContinueTimers_ArmFiresOnce(t *testing.T) {
+	var timers autoContinueTimers
+	key := autoContinueKey{AgentID: "agent-1", Reason: agent.AutoContinueReasonAPIError}
+	fired := make(chan int, 2)
+
+	timers.arm(key, time.Now().Add(time.Hour), func() { fired <- 1 })
+	// Rearming replaces the pending timer rather than adding one.
+	timers.arm(key, time.Now().Add(-time.Second), func() { fired <- 2 })
+
+	select {
+	case n := <-fired:
+		assert.Equal(t, 2, n)
+	case <-time.After(5 * time.Second):
+		t.Fatal("timer did not fire")
+	}
+	select {
+	case n := <-fired:
+		t.Fatalf("replaced timer %d fired", n)
+	case <-time.After(50 * time.Millisecond):
+	}
+}
+
+func TestAutoContinueTimers_StopAndForAgent(t *testing.T) {
+	var timers autoContinueTimers
+	apiErr := autoContinueKey{AgentID: "agent-1", Reason: agent.AutoContinueReasonAPIError}
+	rateLimit := autoContinueKey{AgentID: "agent-1", Reason: agent.AutoContinueReasonRateLimit}
+	other := autoContinueKey{AgentID: "agent-2", Reason: agent.AutoContinueReasonAPIError}
+	for _, key := range []autoContinueKey{apiErr, rateLimit, other} {
+		timers.arm(key, time.Now().Add(time.Hour), func() { t.Error("stopped timer fired") })
+	}
+	defer timers.stop(other, true)
+
+	timers.stop(rateLimit, false)
+	got := timers.forAgent("agent-1")
+	require.Len(t, got, 2)
+	byReason := map[string]bool{}
+	for _, timer := range got {
+		byReason[timer.GetReason()] = timer.GetArmed()
+		assert.NotEmpty(t, timer.GetDueAt())
+	}
+	assert.Equal(t, map[string]bool{
+		string(agent.AutoContinueReasonAPIError):  true,
+		string(agent.AutoContinueReasonRateLimit): false,
+	}, byReason, "a stopped timer keeps its due time until removed")
+
+	timers.stop(apiErr, true)
+	timers.stop(rateLimit, true)
+	assert.Empty(t, timers.forAgent("agent-1"))
+	// Stopping an unknown key is a no-op.
+	timers.stop(apiErr, true)
+}
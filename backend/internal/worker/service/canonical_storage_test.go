@@ -136,6 +136,183 @@ func TestAllDatetimeColumnsStoreCanonicalLayout(t *testing.T) {
 		FilePath:    "/tmp/file.txt",
 	}))
 
+	// workspace_retry_policies.updated_at via UpsertWorkspaceRetryPolicy's strftime.
+	require.NoError(t, queries.UpsertWorkspaceRetryPolicy(ctx, gendb.UpsertWorkspaceRetryPolicyParams{
+		WorkspaceID: "ws-1",
+		Policy:      "{}",
+	}))
+
+	// workspace_turn_limits.updated_at via UpsertWorkspaceTurnLimits's strftime.
+	require.NoError(t, queries.UpsertWorkspaceTurnLimits(ctx, gendb.UpsertWorkspaceTurnLimitsParams{
+		WorkspaceID: "ws-1",
+		Limits:      "{}",
+	}))
+
+	// workspace_agent_defaults.updated_at via UpsertWorkspaceAgentDefaults's strftime.
+	require.NoError(t, queries.UpsertWorkspaceAgentDefaults(ctx, gendb.UpsertWorkspaceAgentDefaultsParams{
+		WorkspaceID: "ws-1",
+		Defaults:    "{}",
+	}))
+
+	// workspace_model_routing.updated_at via UpsertWorkspaceModelRouting's strftime.
+	require.NoError(t, queries.UpsertWorkspaceModelRouting(ctx, gendb.UpsertWorkspaceModelRoutingParams{
+		WorkspaceID: "ws-1",
+		Policy:      "{}",
+	}))
+
+	// agent_turn_models.created_at via the column DEFAULT on RecordAgentTurnModel.
+	require.NoError(t, queries.RecordAgentTurnModel(ctx, gendb.RecordAgentTurnModelParams{
+		AgentID:   "agent-1",
+		MessageID: "msg-1",
+		Model:     "opus",
+	}))
+
+	// agent_archives.shipped_at via the column DEFAULT on CreateAgentArchive.
+	require.NoError(t, queries.CreateAgentArchive(ctx, gendb.CreateAgentArchiveParams{
+		AgentID:       "agent-1",
+		TranscriptKey: "transcripts/org=org-1/date=2026-10-18/agent-1.jsonl.gz",
+	}))
+
+	// agent_message_tallies.last_message_at via the messages insert trigger;
+	// agent_read_markers.updated_at via UpsertAgentReadMarker's strftime.
+	require.NoError(t, queries.UpsertAgentReadMarker(ctx, gendb.UpsertAgentReadMarkerParams{
+		AgentID:     "agent-1",
+		UserID:      "user-1",
+		LastReadSeq: 1,
+	}))
+
+	// message_tombstones: created_at Go-bound from the deleted row, deleted_at
+	// via the column DEFAULT on CreateMessageTombstone.
+	require.NoError(t, queries.CreateMessageTombstone(ctx, gendb.CreateMessageTombstoneParams{
+		MessageID:     "msg-deleted",
+		AgentID:       "agent-1",
+		Seq:           2,
+		Source:        leapmuxv1.MessageSource_MESSAGE_SOURCE_USER,
+		Content:       []byte("bye"),
+		AgentProvider: leapmuxv1.AgentProvider_AGENT_PROVIDER_CLAUDE_CODE,
+		CreatedAt:     sqltime.NewSQLiteTime(now),
+		DeletedBy:     "user-1",
+	}))
+
+	// immutable_transcripts.enabled_at is Go-bound.
+	require.NoError(t, queries.EnableImmutableTranscripts(ctx, sqltime.NewSQLiteTime(now)))
+
+	// workspace_plans: created_at DEFAULT + updated_at via TouchWorkspacePlan's
+	// strftime; plan_revisions.created_at via its column DEFAULT.
+	require.NoError(t, queries.CreateWorkspacePlan(ctx, gendb.CreateWorkspacePlanParams{
+		ID:          "plan-1",
+		WorkspaceID: "ws-1",
+		Title:       "plan",
+	}))
+	require.NoError(t, queries.TouchWorkspacePlan(ctx, gendb.TouchWorkspacePlanParams{Title: "plan", ID: "plan-1"}))
+	_, err = queries.AppendPlanRevision(ctx, gendb.AppendPlanRevisionParams{PlanID: "plan-1", Content: "do it"})
+	require.NoError(t, err)
+
+	// workspace_notification_consolidation.updated_at via its upsert's strftime.
+	require.NoError(t, queries.UpsertWorkspaceNotificationConsolidation(ctx, gendb.UpsertWorkspaceNotificationConsolidationParams{
+		WorkspaceID: "ws-1",
+		Policy:      "{}",
+	}))
+
+	// workspace_provisioning.updated_at and workspace_provisioning_runs.finished_at
+	// via their upserts' column DEFAULTs.
+	require.NoError(t, queries.UpsertWorkspaceProvisioning(ctx, gendb.UpsertWorkspaceProvisioningParams{
+		WorkspaceID: "ws-1",
+		Policy:      "{}",
+	}))
+	require.NoError(t, queries.UpsertWorkspaceProvisioningRun(ctx, gendb.UpsertWorkspaceProvisioningRunParams{
+		WorkspaceID:  "ws-1",
+		WorkingDir:   "/tmp/ws-1",
+		ScriptSha256: "0",
+		TerminalID:   "term-1",
+	}))
+
+	// agent_sub_agent_runs: started_at DEFAULT + ended_at via EndSubAgentRun's strftime.
+	require.NoError(t, queries.CreateSubAgentRun(ctx, gendb.CreateSubAgentRunParams{
+		AgentID:   "agent-1",
+		ToolUseID: "task-1",
+	}))
+	require.NoError(t, queries.EndSubAgentRun(ctx, gendb.EndSubAgentRunParams{
+		Status:    "completed",
+		AgentID:   "agent-1",
+		ToolUseID: "task-1",
+	}))
+
+	// metric_buckets.bucket_start via RecordMetric's bound SQLiteTime, and
+	// via RollUpMetrics' strftime bucket format.
+	require.NoError(t, queries.RecordMetric(ctx, gendb.RecordMetricParams{
+		Metric:      "turns",
+		WorkspaceID: "ws-1",
+		BucketStart: sqltime.NewSQLiteTime(time.Now().Add(-time.Hour)),
+		Value:       1,
+	}))
+	require.NoError(t, queries.RollUpMetrics(ctx, gendb.RollUpMetricsParams{
+		ToResolution:   int64(leapmuxv1.MetricResolution_METRIC_RESOLUTION_HOUR),
+		BucketFormat:   metricBuckets[leapmuxv1.MetricResolution_METRIC_RESOLUTION_HOUR].format,
+		FromResolution: leapmuxv1.MetricResolution_METRIC_RESOLUTION_MINUTE,
+		Cutoff:         sqltime.NewSQLiteTime(time.Now()),
+	}))
+
+	// agent_output_dead_letters.created_at via its DEFAULT, replayed_at via
+	// MarkAgentOutputDeadLetterReplayed's strftime.
+	require.NoError(t, queries.CreateAgentOutputDeadLetter(ctx, gendb.CreateAgentOutputDeadLetterParams{
+		AgentID: "agent-1",
+		Content: []byte("garbage"),
+	}))
+	letters, err := queries.ListAgentOutputDeadLetters(ctx, gendb.ListAgentOutputDeadLettersParams{AgentID: "agent-1", RowLimit: 1})
+	require.NoError(t, err)
+	require.Len(t, letters, 1)
+	require.NoError(t, queries.MarkAgentOutputDeadLetterReplayed(ctx, letters[0].ID))
+
+	// agent_test_runs.created_at via the column DEFAULT on CreateAgentTestRun.
+	_, err = queries.CreateAgentTestRun(ctx, gendb.CreateAgentTestRunParams{
+		AgentID:   "agent-1",
+		ToolUseID: "toolu-1",
+		Runner:    "go",
+		Command:   "go test ./...",
+	})
+	require.NoError(t, err)
+
+	// workspace_ci_policies.updated_at via UpsertWorkspaceCIPolicy's strftime.
+	require.NoError(t, queries.UpsertWorkspaceCIPolicy(ctx, gendb.UpsertWorkspaceCIPolicyParams{
+		WorkspaceID: "ws-1",
+		Policy:      "{}",
+	}))
+
+	// workspace_env.updated_at via UpsertWorkspaceEnv's strftime.
+	require.NoError(t, queries.UpsertWorkspaceEnv(ctx, gendb.UpsertWorkspaceEnvParams{
+		WorkspaceID: "ws-1",
+		Env:         "{}",
+	}))
+
+	// workspace_terminal_profiles.updated_at via UpsertWorkspaceTerminalProfiles's strftime.
+	require.NoError(t, queries.UpsertWorkspaceTerminalProfiles(ctx, gendb.UpsertWorkspaceTerminalProfilesParams{
+		WorkspaceID: "ws-1",
+		Profiles:    "{}",
+	}))
+
+	// agent_ci_status.updated_at via UpsertAgentCIStatus's strftime.
+	require.NoError(t, queries.UpsertAgentCIStatus(ctx, gendb.UpsertAgentCIStatusParams{
+		AgentID:   "agent-1",
+		CommitSha: "0",
+		Status:    "{}",
+	}))
+
+	// agent_activity.created_at via the column DEFAULT on CreateAgentActivity.
+	require.NoError(t, queries.CreateAgentActivity(ctx, gendb.CreateAgentActivityParams{
+		AgentID: "agent-1",
+		Kind:    "interrupt",
+	}))
+
+	// agent_artifacts.created_at via the column DEFAULT on CreateAgentArtifact.
+	_, err = queries.CreateAgentArtifact(ctx, gendb.CreateAgentArtifactParams{
+		AgentID: "agent-1",
+		Path:    "out/report.md",
+		Size:    1,
+		Sha256:  "0",
+	})
+	require.NoError(t, err)
+
 	offenders, columns, err := sqlitedb.FindNonCanonicalDatetimes(ctx, sqlDB, "goose_db_version")
 	require.NoError(t, err)
 	require.NotEmpty(t, columns, "walk discovered no DATETIME columns; the discovery query is broken")
@@ -0,0 +1,353 @@
+package service
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"log/slog"
+	"strings"
+	"time"
+
+	leapmuxv1 "github.com/leapmux/leapmux/generated/proto/leapmux/v1"
+	"github.com/leapmux/leapmux/internal/util/periodic"
+	"github.com/leapmux/leapmux/internal/util/timefmt"
+	"github.com/leapmux/leapmux/internal/util/userid"
+	"github.com/leapmux/leapmux/internal/worker/channel"
+	"github.com/leapmux/leapmux/internal/worker/cistatus"
+	db "github.com/leapmux/leapmux/internal/worker/generated/db"
+	"github.com/leapmux/leapmux/internal/worker/gitutil"
+	"google.golang.org/protobuf/encoding/protojson"
+	"google.golang.org/protobuf/proto"
+)
+
+// CI status is polled, not pushed: a code host's webhooks have no way to
+// reach a worker, which only talks to the Hub, and the Hub sees nothing
+// but ciphertext. Each poll reads the checks on the head of every open
+// agent's branch in workspaces whose CI policy enables it.
+
+const (
+	// ciPollInterval spaces polls well inside GitHub's anonymous rate
+	// limit (60 requests an hour, two per agent per poll).
+	ciPollInterval = 2 * time.Minute
+	ciPollJitter   = 30 * time.Second
+
+	// defaultCIFollowUps is max_follow_ups when the policy leaves it zero.
+	defaultCIFollowUps = 3
+	// maxCIFollowUps bounds max_follow_ups so a typo cannot loop an agent
+	// on a red branch indefinitely.
+	maxCIFollowUps = 20
+	// ciFollowUpLogs is how many failed checks' logs a follow-up quotes.
+	ciFollowUpLogs = 3
+)
+
+// StartCIStatusLoop polls CI status until ctx is canceled.
+func (svc *Service) StartCIStatusLoop(ctx context.Context) {
+	periodic.Start(ctx, periodic.Schedule{Interval: ciPollInterval, Jitter: ciPollJitter}, svc.PollCIStatus)
+}
+
+// PollCIStatus polls the CI status of every open agent in a workspace whose
+// policy enables polling.
+func (svc *Service) PollCIStatus(ctx context.Context) {
+	rows, err := svc.Queries.ListWorkspaceCIPolicies(ctx)
+	if err != nil {
+		slog.Warn("failed to list CI policies", "error", err)
+		return
+	}
+	for _, row := range rows {
+		policy := &leapmuxv1.WorkspaceCIPolicy{}
+		if err := protojson.Unmarshal([]byte(row.Policy), policy); err != nil {
+			slog.Warn("failed to decode CI policy", "workspace_id", row.WorkspaceID, "error", err)
+			continue
+		}
+		if !policy.GetEnabled() {
+			continue
+		}
+		agentIDs, err := svc.Queries.ListOpenAgentIDsByWorkspaceID(ctx, row.WorkspaceID)
+		if err != nil {
+			slog.Warn("failed to list agents for CI poll", "workspace_id", row.WorkspaceID, "error", err)
+			continue
+		}
+		for _, agentID := range agentIDs {
+			if ctx.Err() != nil {
+				return
+			}
+			svc.pollAgentCIStatus(ctx, agentID, policy)
+		}
+	}
+}
+
+// pollAgentCIStatus reads the checks on the agent's HEAD, stores and
+// broadcasts them when they changed, and sends a failure back to the agent
+// when the policy asks for it.
+func (svc *Service) pollAgentCIStatus(ctx context.Context, agentID string, policy *leapmuxv1.WorkspaceCIPolicy) {
+	agentRow, err := svc.Queries.GetAgentByID(ctx, agentID)
+	if err != nil || agentRow.WorkingDir == "" {
+		return
+	}
+	dir := agentRow.WorkingDir
+	repo, ok := cistatus.ParseGitHubRemote(gitutil.GetOriginURL(ctx, dir))
+	if !ok {
+		return
+	}
+	sha, err := gitutil.Output(ctx, dir, "rev-parse", "HEAD")
+	if err != nil {
+		return
+	}
+	sha = strings.TrimSpace(sha)
+	branch, _ := gitutil.Output(ctx, dir, "rev-parse", "--abbrev-ref", "HEAD")
+	if branch = strings.TrimSpace(branch); branch == "HEAD" {
+		branch = ""
+	}
+
+	prev, prevStatus, err := loadAgentCIStatus(ctx, svc.Queries, agentID)
+	if err != nil {
+		slog.Warn("failed to load CI status", "agent_id", agentID, "error", err)
+		return
+	}
+	// A finished commit stays finished until the branch moves; re-running
+	// a failed job in place is the one change this does not notice.
+	if prev.CommitSha == sha && ciStateFinal(prevStatus.GetState()) {
+		return
+	}
+
+	client := svc.ciClient(ctx, dir)
+	checks, err := client.Checks(ctx, repo, sha)
+	if err != nil {
+		slog.Debug("CI status poll failed", "agent_id", agentID, "repo", repo.Owner+"/"+repo.Name, "error", err)
+		return
+	}
+	status := &leapmuxv1.AgentCIStatus{
+		Branch:    branch,
+		CommitSha: sha,
+		State:     ciStateToProto(cistatus.Aggregate(checks)),
+	}
+	for _, c := range checks {
+		status.Checks = append(status.Checks, &leapmuxv1.CICheck{Name: c.Name, State: ciStateToProto(c.State), DetailsUrl: c.URL})
+	}
+	changed := prevStatus == nil || !proto.Equal(withoutUpdatedAt(prevStatus), status)
+	status.UpdatedAt = timefmt.Format(time.Now())
+
+	followUpSha, followUps := prev.FollowUpSha, prev.FollowUps
+	var followUp string
+	switch status.GetState() {
+	case leapmuxv1.CIState_CI_STATE_SUCCESS:
+		followUps = 0
+	case leapmuxv1.CIState_CI_STATE_FAILURE:
+		if policy.GetSendFailuresToAgent() && followUpSha != sha && followUps < ciFollowUpLimit(policy) {
+			followUp = composeCIFollowUp(ctx, client, repo, status, checks)
+			followUpSha = sha
+			followUps++
+		}
+	}
+	if !changed && followUps == prev.FollowUps && followUpSha == prev.FollowUpSha {
+		return
+	}
+
+	raw, err := protojson.Marshal(status)
+	if err == nil {
+		err = svc.Queries.UpsertAgentCIStatus(ctx, db.UpsertAgentCIStatusParams{
+			AgentID:     agentID,
+			CommitSha:   sha,
+			Status:      string(raw),
+			FollowUpSha: followUpSha,
+			FollowUps:   followUps,
+		})
+	}
+	if err != nil {
+		slog.Warn("failed to store CI status", "agent_id", agentID, "error", err)
+		return
+	}
+	if changed {
+		// Partial update, like BroadcastGitStatus: only a live worker sends it.
+		svc.Watchers.BroadcastAgentEvent(agentID, &leapmuxv1.AgentEvent{
+			AgentId: agentID,
+			Event: &leapmuxv1.AgentEvent_StatusChange{StatusChange: &leapmuxv1.AgentStatusChange{
+				AgentId:      agentID,
+				WorkerOnline: true,
+				CiStatus:     status,
+			}},
+		})
+	}
+	if followUp != "" && svc.Output.sendMessageFunc != nil {
+		slog.Info("sending CI failure to agent", "agent_id", agentID, "commit_sha", sha)
+		svc.Output.sendMessageFunc(agentID, followUp)
+	}
+}
+
+// ciClient returns the GitHub client for the repository at dir,
+// authenticated with the token git itself would push with.
+func (svc *Service) ciClient(ctx context.Context, dir string) *cistatus.Client {
+	if svc.ciClientFn != nil {
+		return svc.ciClientFn(ctx, dir)
+	}
+	return &cistatus.Client{Token: cistatus.TokenFromGit(ctx, dir)}
+}
+
+// composeCIFollowUp writes the prompt that hands a failed commit back to
+// the agent: every failed check, and the log tails of the first few.
+func composeCIFollowUp(ctx context.Context, client *cistatus.Client, repo cistatus.Repo, status *leapmuxv1.AgentCIStatus, checks []cistatus.Check) string {
+	var b strings.Builder
+	sha := status.GetCommitSha()
+	if len(sha) > 12 {
+		sha = sha[:12]
+	}
+	if status.GetBranch() != "" {
+		fmt.Fprintf(&b, "CI failed on branch %s at commit %s.\n\nFailed checks:\n", status.GetBranch(), sha)
+	} else {
+		fmt.Fprintf(&b, "CI failed at commit %s.\n\nFailed checks:\n", sha)
+	}
+	var failed []cistatus.Check
+	for _, c := range checks {
+		if c.State != cistatus.StateFailure {
+			continue
+		}
+		failed = append(failed, c)
+		fmt.Fprintf(&b, "- %s", c.Name)
+		if c.URL != "" {
+			fmt.Fprintf(&b, " (%s)", c.URL)
+		}
+		b.WriteByte('\n')
+	}
+	logs := 0
+	for _, c := range failed {
+		if logs == ciFollowUpLogs {
+			break
+		}
+		text := c.Summary
+		if c.JobID != 0 {
+			if log, err := client.JobLog(ctx, repo, c.JobID); err == nil && strings.TrimSpace(log) != "" {
+				text = log
+			}
+		}
+		if strings.TrimSpace(text) == "" {
+			continue
+		}
+		logs++
+		fmt.Fprintf(&b, "\nEnd of the %s output:\n```\n%s\n```\n", c.Name, strings.TrimRight(text, "\n"))
+	}
+	b.WriteString("\nPlease find the cause, fix it, and push the fix.")
+	return b.String()
+}
+
+func ciFollowUpLimit(policy *leapmuxv1.WorkspaceCIPolicy) int64 {
+	if n := policy.GetMaxFollowUps(); n > 0 {
+		return int64(n)
+	}
+	return defaultCIFollowUps
+}
+
+func ciStateFinal(state leapmuxv1.CIState) bool {
+	return state == leapmuxv1.CIState_CI_STATE_SUCCESS || state == leapmuxv1.CIState_CI_STATE_FAILURE
+}
+
+func ciStateToProto(state cistatus.State) leapmuxv1.CIState {
+	switch state {
+	case cistatus.StatePending:
+		return leapmuxv1.CIState_CI_STATE_PENDING
+	case cistatus.StateSuccess:
+		return leapmuxv1.CIState_CI_STATE_SUCCESS
+	case cistatus.StateFailure:
+		return leapmuxv1.CIState_CI_STATE_FAILURE
+	}
+	return leapmuxv1.CIState_CI_STATE_UNSPECIFIED
+}
+
+func withoutUpdatedAt(status *leapmuxv1.AgentCIStatus) *leapmuxv1.AgentCIStatus {
+	c := proto.Clone(status).(*leapmuxv1.AgentCIStatus)
+	c.UpdatedAt = ""
+	return c
+}
+
+// loadAgentCIStatus reads the agent's stored CI status. An agent never
+// polled yields a zero row and a nil status, not an error.
+func loadAgentCIStatus(ctx context.Context, queries *db.Queries, agentID string) (db.AgentCiStatus, *leapmuxv1.AgentCIStatus, error) {
+	row, err := queries.GetAgentCIStatus(ctx, agentID)
+	if errors.Is(err, sql.ErrNoRows) {
+		return db.AgentCiStatus{}, nil, nil
+	}
+	if err != nil {
+		return row, nil, err
+	}
+	status := &leapmuxv1.AgentCIStatus{}
+	if err := protojson.Unmarshal([]byte(row.Status), status); err != nil {
+		return row, nil, fmt.Errorf("decode CI status: %w", err)
+	}
+	return row, status, nil
+}
+
+// loadWorkspaceCIPolicy reads the workspace's stored policy. A workspace
+// with no row yields an empty policy (polling off), not an error.
+func loadWorkspaceCIPolicy(ctx context.Context, queries *db.Queries, workspaceID string) (*leapmuxv1.WorkspaceCIPolicy, error) {
+	raw, err := queries.GetWorkspaceCIPolicy(ctx, workspaceID)
+	if errors.Is(err, sql.ErrNoRows) {
+		return &leapmuxv1.WorkspaceCIPolicy{}, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	policy := &leapmuxv1.WorkspaceCIPolicy{}
+	if err := protojson.Unmarshal([]byte(raw), policy); err != nil {
+		return nil, fmt.Errorf("decode CI policy: %w", err)
+	}
+	return policy, nil
+}
+
+// registerCIStatusHandlers registers the CI policy and status RPCs.
+func registerCIStatusHandlers(d registrar, svc *Service) {
+	registerWorkspaceGated(d, "GetWorkspaceCIPolicy",
+		func(ctx context.Context, _ userid.UserID, r *leapmuxv1.GetWorkspaceCIPolicyRequest, sender channel.ResponseWriter) {
+			policy, err := loadWorkspaceCIPolicy(ctx, svc.Queries, r.GetWorkspaceId())
+			if err != nil {
+				slog.Error("failed to load CI policy", "workspace_id", r.GetWorkspaceId(), "error", err)
+				sendInternalError(sender, "failed to load CI policy")
+				return
+			}
+			sendProtoResponse(sender, &leapmuxv1.GetWorkspaceCIPolicyResponse{Policy: policy})
+		})
+
+	registerWorkspaceGated(d, "SetWorkspaceCIPolicy",
+		func(_ context.Context, _ userid.UserID, r *leapmuxv1.SetWorkspaceCIPolicyRequest, sender channel.ResponseWriter) {
+			policy := r.GetPolicy()
+			if policy == nil {
+				policy = &leapmuxv1.WorkspaceCIPolicy{}
+			}
+			if n := policy.GetMaxFollowUps(); n < 0 || n > maxCIFollowUps {
+				sendInvalidArgument(sender, fmt.Sprintf("max_follow_ups must be between 0 and %d", maxCIFollowUps))
+				return
+			}
+
+			// An empty policy is polling off; drop the row rather than
+			// storing a policy that says nothing.
+			var err error
+			if proto.Equal(policy, &leapmuxv1.WorkspaceCIPolicy{}) {
+				err = svc.Queries.DeleteWorkspaceCIPolicy(bgCtx(), r.GetWorkspaceId())
+			} else {
+				var raw []byte
+				raw, err = protojson.Marshal(policy)
+				if err == nil {
+					err = svc.Queries.UpsertWorkspaceCIPolicy(bgCtx(), db.UpsertWorkspaceCIPolicyParams{
+						WorkspaceID: r.GetWorkspaceId(),
+						Policy:      string(raw),
+					})
+				}
+			}
+			if err != nil {
+				slog.Error("failed to save CI policy", "workspace_id", r.GetWorkspaceId(), "error", err)
+				sendInternalError(sender, "failed to save CI policy")
+				return
+			}
+			sendProtoResponse(sender, &leapmuxv1.SetWorkspaceCIPolicyResponse{Policy: policy})
+		})
+
+	registerAgentGated(d, "GetAgentCIStatus",
+		func(ctx context.Context, _ userid.UserID, _ *leapmuxv1.GetAgentCIStatusRequest, dbAgent db.Agent, sender channel.ResponseWriter) {
+			_, status, err := loadAgentCIStatus(ctx, svc.Queries, dbAgent.ID)
+			if err != nil {
+				slog.Error("failed to load CI status", "agent_id", dbAgent.ID, "error", err)
+				sendInternalError(sender, "failed to load CI status")
+				return
+			}
+			sendProtoResponse(sender, &leapmuxv1.GetAgentCIStatusResponse{Status: status})
+		})
+}
@@ -0,0 +1,107 @@
+package service
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"os/exec"
+	"sync/atomic"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	leapmuxv1 "github.com/leapmux/leapmux/generated/proto/leapmux/v1"
+	"github.com/leapmux/leapmux/internal/worker/cistatus"
+)
+
+func TestCIStatus_PollBroadcastsAndSendsFailureOnce(t *testing.T) {
+	svc, d, w := setupTestService(t, withWorkspaces("ws-1"))
+	row := seedGuardedAgent(t, svc, "")
+	require.NoError(t, gitInitRepo(row.WorkingDir))
+	require.NoError(t, exec.Command("git", "-C", row.WorkingDir, "remote", "add", "origin", "git@github.com:a/b.git").Run())
+
+	var conclusion atomic.Value
+	conclusion.Store("")
+	var hits atomic.Int32
+	srv := httptest.NewServer(http.HandlerFunc(func(rw http.ResponseWriter, r *http.Request) {
+		hits.Add(1)
+		switch {
+		case r.URL.Path == "/repos/a/b/actions/jobs/7/logs":
+			_, _ = rw.Write([]byte("--- FAIL: TestX\n"))
+		case r.URL.Path == "/repos/a/b/commits/"+headSHA(t, row.WorkingDir)+"/check-runs":
+			status := "completed"
+			if conclusion.Load() == "" {
+				status = "in_progress"
+			}
+			_, _ = rw.Write([]byte(`{"check_runs":[{"id":7,"name":"test","status":"` + status + `","conclusion":"` + conclusion.Load().(string) + `","html_url":"https://gh/7","app":{"slug":"github-actions"}}]}`))
+		default:
+			_, _ = rw.Write([]byte(`{"statuses":[]}`))
+		}
+	}))
+	defer srv.Close()
+	svc.ciClientFn = func(_ context.Context, _ string) *cistatus.Client { return &cistatus.Client{BaseURL: srv.URL} }
+	var sent []string
+	svc.Output.SetSendMessageFunc(func(_, content string) { sent = append(sent, content) })
+	watch := newTestWriter()
+	svc.Watchers.SetAgentWatches(watch.channelID, []string{row.ID}, watch)
+
+	dispatch(d, "SetWorkspaceCIPolicy", &leapmuxv1.SetWorkspaceCIPolicyRequest{
+		WorkspaceId: "ws-1",
+		Policy:      &leapmuxv1.WorkspaceCIPolicy{Enabled: true, SendFailuresToAgent: true},
+	}, w)
+	require.Empty(t, w.errors)
+
+	svc.PollCIStatus(bgCtx())
+	svc.PollCIStatus(bgCtx())
+	conclusion.Store("failure")
+	svc.PollCIStatus(bgCtx())
+	before := hits.Load()
+	svc.PollCIStatus(bgCtx())
+	assert.Equal(t, before, hits.Load(), "a finished commit is not polled again")
+
+	var states []leapmuxv1.CIState
+	for _, stream := range watch.streamsSnapshot() {
+		if cs := decodeWatchAgentEvent(t, stream).GetStatusChange().GetCiStatus(); cs != nil {
+			states = append(states, cs.GetState())
+		}
+	}
+	assert.Equal(t, []leapmuxv1.CIState{leapmuxv1.CIState_CI_STATE_PENDING, leapmuxv1.CIState_CI_STATE_FAILURE}, states,
+		"an unchanged poll broadcasts nothing")
+
+	require.Len(t, sent, 1)
+	assert.Contains(t, sent[0], "CI failed on branch main")
+	assert.Contains(t, sent[0], "- test (https://gh/7)")
+	assert.Contains(t, sent[0], "--- FAIL: TestX")
+
+	w2 := newTestWriter()
+	dispatch(d, "GetAgentCIStatus", &leapmuxv1.GetAgentCIStatusRequest{AgentId: row.ID}, w2)
+	require.Empty(t, w2.errors)
+	status := decodeResponse[leapmuxv1.GetAgentCIStatusResponse](t, w2).GetStatus()
+	assert.Equal(t, "main", status.GetBranch())
+	assert.Equal(t, headSHA(t, row.WorkingDir), status.GetCommitSha())
+	assert.Equal(t, leapmuxv1.CIState_CI_STATE_FAILURE, status.GetState())
+	require.Len(t, status.GetChecks(), 1)
+	assert.Equal(t, "https://gh/7", status.GetChecks()[0].GetDetailsUrl())
+}
+
+func TestCIStatus_PolicyValidation(t *testing.T) {
+	_, d, w := setupTestService(t, withWorkspaces("ws-1"))
+	dispatch(d, "SetWorkspaceCIPolicy", &leapmuxv1.SetWorkspaceCIPolicyRequest{
+		WorkspaceId: "ws-1",
+		Policy:      &leapmuxv1.WorkspaceCIPolicy{Enabled: true, MaxFollowUps: maxCIFollowUps + 1},
+	}, w)
+	require.Len(t, w.rejections(), 1)
+	assert.Equal(t, codeInvalidArgument, w.rejections()[0].code)
+
+	w = newTestWriter()
+	dispatch(d, "GetWorkspaceCIPolicy", &leapmuxv1.GetWorkspaceCIPolicyRequest{WorkspaceId: "ws-1"}, w)
+	assert.False(t, decodeResponse[leapmuxv1.GetWorkspaceCIPolicyResponse](t, w).GetPolicy().GetEnabled())
+}
+
+func headSHA(t *testing.T, dir string) string {
+	t.Helper()
+	out, err := exec.Command("git", "-C", dir, "rev-parse", "HEAD").Output()
+	require.NoError(t, err)
+	return string(out[:len(out)-1])
+}
@@ -0,0 +1,74 @@
+package service
+
+import (
+	"context"
+	"database/sql"
+	"log/slog"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/leapmux/leapmux/internal/util/periodic"
+	"github.com/leapmux/leapmux/internal/util/sqltime"
+	db "github.com/leapmux/leapmux/internal/worker/generated/db"
+)
+
+// classifyMessage tags a just-persisted message with what the classifier
+// finds in its content. Like usage, the tags are bookkeeping beside the
+// transcript, so a failed write is logged rather than failing the message.
+func (h *OutputHandler) classifyMessage(agentID, messageID string, content []byte) {
+	tags := h.classifier.Classify(content)
+	if len(tags) == 0 {
+		return
+	}
+	if err := h.queries.CreateMessageClassification(bgCtx(), db.CreateMessageClassificationParams{
+		MessageID: messageID,
+		AgentID:   agentID,
+		Tags:      strings.Join(tags, ","),
+	}); err != nil {
+		slog.Warn("failed to record message classification", "agent_id", agentID, "message_id", messageID, "error", err)
+	}
+}
+
+// messageClassifications returns the tags of agentID's tagged messages,
+// keyed by message ID.
+func messageClassifications(ctx context.Context, queries *db.Queries, agentID string) (map[string][]string, error) {
+	rows, err := queries.ListMessageClassificationsByAgentID(ctx, agentID)
+	if err != nil {
+		return nil, err
+	}
+	out := make(map[string][]string, len(rows))
+	for _, r := range rows {
+		out[r.MessageID] = strings.Split(r.Tags, ",")
+	}
+	return out, nil
+}
+
+// hasAnyTag reports whether tags and exclude share a tag.
+func hasAnyTag(tags, exclude []string) bool {
+	for _, t := range tags {
+		if slices.Contains(exclude, t) {
+			return true
+		}
+	}
+	return false
+}
+
+// StartClassifiedRetentionLoop deletes tagged messages once they are
+// older than ClassifiedRetention, however long the rest of the transcript
+// is kept. A no-op when the override is off.
+func (svc *Service) StartClassifiedRetentionLoop(ctx context.Context) {
+	if svc.ClassifiedRetention <= 0 {
+		return
+	}
+	periodic.Start(ctx, periodic.Schedule{Interval: cleanupInterval, Jitter: cleanupJitter}, func(ctx context.Context) {
+		// Nothing is purged while the org keeps immutable transcripts.
+		if svc.ImmutableTranscripts() {
+			return
+		}
+		cutoff := sqltime.NewSQLiteTime(time.Now().Add(-svc.ClassifiedRetention))
+		cleanupStep(ctx, "classified messages", func() (sql.Result, error) {
+			return svc.Queries.DeleteClassifiedMessagesBefore(ctx, cutoff)
+		})
+	})
+}
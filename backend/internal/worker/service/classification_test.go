@@ -0,0 +1,74 @@
+package service
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	leapmuxv1 "github.com/leapmux/leapmux/generated/proto/leapmux/v1"
+	"github.com/leapmux/leapmux/internal/util/msgcodec"
+	"github.com/leapmux/leapmux/internal/util/sqltime"
+	"github.com/leapmux/leapmux/internal/worker/agent"
+	db "github.com/leapmux/leapmux/internal/worker/generated/db"
+	"github.com/leapmux/leapmux/internal/worker/redact"
+)
+
+func TestClassification_TagsExportsAndExpires(t *testing.T) {
+	svc, d, w := setupTestService(t, withWorkspaces("ws-1", "ws-2"))
+	svc.Output.SetClassifier(redact.NewClassifier(redact.PIIDefaults()...))
+	seedGuardedAgent(t, svc, "")
+	ctx := context.Background()
+
+	for _, content := range []string{
+		`{"type":"assistant","message":{"content":[{"type":"text","text":"Emailed jane.doe@example.com"}]}}`,
+		`{"type":"assistant","message":{"content":[{"type":"text","text":"Done."}]}}`,
+	} {
+		require.NoError(t, svc.Output.persistAndBroadcast("agent-1", claudeProvider,
+			leapmuxv1.MessageSource_MESSAGE_SOURCE_AGENT, []byte(content), agent.SpanInfo{}, nil))
+	}
+	tags, err := messageClassifications(ctx, svc.Queries, "agent-1")
+	require.NoError(t, err)
+	require.Len(t, tags, 1)
+	for _, v := range tags {
+		assert.Equal(t, []string{"email"}, v)
+	}
+
+	dispatch(d, "ExportWorkspaceAgents", &leapmuxv1.ExportWorkspaceAgentsRequest{WorkspaceId: "ws-1"}, w)
+	require.Empty(t, w.errors)
+	full := decodeResponse[leapmuxv1.ExportWorkspaceAgentsResponse](t, w).GetBundle()
+	require.Len(t, full.GetAgents()[0].GetMessages(), 2)
+	assert.Equal(t, []string{"email"}, full.GetAgents()[0].GetMessages()[0].GetClassificationTags())
+
+	dispatch(d, "ExportWorkspaceAgents", &leapmuxv1.ExportWorkspaceAgentsRequest{
+		WorkspaceId:               "ws-1",
+		ExcludeClassificationTags: []string{"phone", "email"},
+	}, w)
+	require.Empty(t, w.errors)
+	filtered := decodeResponse[leapmuxv1.ExportWorkspaceAgentsResponse](t, w).GetBundle()
+	require.Len(t, filtered.GetAgents()[0].GetMessages(), 1)
+	assert.Empty(t, filtered.GetAgents()[0].GetMessages()[0].GetClassificationTags())
+
+	// An import keeps the tags, so the copy expires and exports the same way.
+	dispatch(d, "ImportWorkspaceAgents", &leapmuxv1.ImportWorkspaceAgentsRequest{WorkspaceId: "ws-2", Bundle: full}, w)
+	require.Empty(t, w.errors)
+	newID := decodeResponse[leapmuxv1.ImportWorkspaceAgentsResponse](t, w).GetAgentIds()["agent-1"]
+	imported, err := messageClassifications(ctx, svc.Queries, newID)
+	require.NoError(t, err)
+	assert.Len(t, imported, 1)
+
+	// The retention override removes only tagged messages.
+	_, err = svc.Queries.DeleteClassifiedMessagesBefore(ctx, sqltime.NewSQLiteTime(time.Now().Add(time.Minute)))
+	require.NoError(t, err)
+	msgs, err := svc.Queries.ListAllMessagesByAgentID(ctx, db.ListAllMessagesByAgentIDParams{AgentID: "agent-1"})
+	require.NoError(t, err)
+	require.Len(t, msgs, 1)
+	raw, err := msgcodec.Decompress(msgs[0].Content, msgs[0].ContentCompression)
+	require.NoError(t, err)
+	assert.Contains(t, string(raw), "Done.")
+	tags, err = messageClassifications(ctx, svc.Queries, "agent-1")
+	require.NoError(t, err)
+	assert.Empty(t, tags)
+}
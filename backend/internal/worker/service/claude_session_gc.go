@@ -0,0 +1,202 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io/fs"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"time"
+
+	leapmuxv1 "github.com/leapmux/leapmux/generated/proto/leapmux/v1"
+	"github.com/leapmux/leapmux/internal/util/periodic"
+	"github.com/leapmux/leapmux/internal/util/userid"
+	"github.com/leapmux/leapmux/internal/worker/channel"
+)
+
+const (
+	claudeSessionGCInterval = 24 * time.Hour
+	// maxClaudeSessionRetentionDays bounds an on-demand request's
+	// retention, keeping the cutoff computation clear of overflow.
+	maxClaudeSessionRetentionDays = 3650
+)
+
+// claudeSessionIDPattern matches the UUID Claude Code names a session's
+// transcript after. Anything else in its projects directory is left alone.
+var claudeSessionIDPattern = regexp.MustCompile(`^[0-9a-f]{8}-[0-9a-f]{4}-[0-9a-f]{4}-[0-9a-f]{4}-[0-9a-f]{12}$`)
+
+// ClaudeSessionGCResult is what one collection removed, or would have on a
+// dry run.
+type ClaudeSessionGCResult struct {
+	Sessions       int
+	Plans          int
+	ReclaimedBytes uint64
+}
+
+func registerClaudeSessionGCHandlers(d ownerOnlyRegistrar, svc *Service) {
+	d.Register("CollectClaudeSessions", func(ctx context.Context, _ userid.UserID, req *leapmuxv1.InnerRpcRequest, sender channel.ResponseWriter) {
+		var r leapmuxv1.CollectClaudeSessionsRequest
+		if err := unmarshalRequest(req, &r); err != nil {
+			sendInvalidArgument(sender, "invalid request")
+			return
+		}
+		retention := svc.ClaudeSessionRetention
+		if days := r.GetRetentionDays(); days > 0 {
+			if days > maxClaudeSessionRetentionDays {
+				sendInvalidArgument(sender, fmt.Sprintf("retention_days must be at most %d", maxClaudeSessionRetentionDays))
+				return
+			}
+			retention = time.Duration(days) * 24 * time.Hour
+		}
+		if retention <= 0 {
+			sendInvalidArgument(sender, "retention_days is required: session collection is turned off on this worker")
+			return
+		}
+
+		res, err := svc.CollectClaudeSessions(ctx, retention, r.GetDryRun())
+		if err != nil {
+			sendInternalError(sender, "failed to collect sessions: "+err.Error())
+			return
+		}
+		sendProtoResponse(sender, &leapmuxv1.CollectClaudeSessionsResponse{
+			RemovedSessions: uint32(res.Sessions),
+			RemovedPlans:    uint32(res.Plans),
+			ReclaimedBytes:  res.ReclaimedBytes,
+		})
+	})
+}
+
+// StartClaudeSessionGCLoop collects stale Claude Code sessions at startup
+// and once a day after. It does nothing when ClaudeSessionRetention is
+// unset.
+func (svc *Service) StartClaudeSessionGCLoop(ctx context.Context) {
+	if svc.ClaudeSessionRetention <= 0 {
+		return
+	}
+	periodic.Start(ctx, periodic.Schedule{Interval: claudeSessionGCInterval, Jitter: cleanupJitter}, func(ctx context.Context) {
+		res, err := svc.CollectClaudeSessions(ctx, svc.ClaudeSessionRetention, false)
+		if err != nil {
+			slog.Warn("claude session collection failed", "error", err)
+			return
+		}
+		if res.Sessions > 0 || res.Plans > 0 {
+			slog.Info("collected stale claude sessions",
+				"sessions", res.Sessions, "plans", res.Plans, "reclaimed_bytes", res.ReclaimedBytes)
+		}
+	})
+}
+
+// CollectClaudeSessions removes the Claude Code session transcripts and
+// plan files under the home directories of this worker's agents that no
+// agent row names and that were last modified before retention ago. An
+// agent's row outlives it by the closed-agent retention, so a session is
+// only collectable once its agent is gone for good. A transcript's
+// sibling directory (subagent transcripts, tool results) goes with it.
+// Files are never followed through symlinks.
+func (svc *Service) CollectClaudeSessions(ctx context.Context, retention time.Duration, dryRun bool) (ClaudeSessionGCResult, error) {
+	var res ClaudeSessionGCResult
+	refs, err := svc.Queries.ListAgentSessionRefs(ctx)
+	if err != nil {
+		return res, err
+	}
+	sessions := map[string]bool{}
+	plans := map[string]bool{}
+	homes := map[string]bool{}
+	if svc.HomeDir != "" {
+		homes[svc.HomeDir] = true
+	}
+	for _, ref := range refs {
+		if ref.HomeDir != "" {
+			homes[ref.HomeDir] = true
+		}
+		if ref.AgentSessionID != "" {
+			sessions[ref.AgentSessionID] = true
+		}
+		if ref.PlanFilePath != "" {
+			plans[filepath.Clean(ref.PlanFilePath)] = true
+		}
+	}
+
+	cutoff := time.Now().Add(-retention)
+	remove := func(path string, size uint64) bool {
+		if !dryRun {
+			if err := os.RemoveAll(path); err != nil {
+				slog.Warn("failed to remove claude session file", "path", path, "error", err)
+				return false
+			}
+		}
+		res.ReclaimedBytes += size
+		return true
+	}
+	for home := range homes {
+		projects := filepath.Join(home, ".claude", "projects")
+		projectDirs, err := readDirIfExists(projects)
+		if err != nil {
+			return res, err
+		}
+		for _, p := range projectDirs {
+			if !p.IsDir() {
+				continue
+			}
+			dir := filepath.Join(projects, p.Name())
+			entries, err := readDirIfExists(dir)
+			if err != nil {
+				return res, err
+			}
+			for _, e := range entries {
+				if ctx.Err() != nil {
+					return res, ctx.Err()
+				}
+				id, ok := strings.CutSuffix(e.Name(), ".jsonl")
+				if !ok || !e.Type().IsRegular() || !claudeSessionIDPattern.MatchString(id) || sessions[id] {
+					continue
+				}
+				info, err := e.Info()
+				if err != nil || !info.ModTime().Before(cutoff) {
+					continue
+				}
+				sibling := filepath.Join(dir, id)
+				if fi, err := os.Lstat(sibling); err == nil && fi.IsDir() {
+					n, _ := dirSize(ctx, sibling)
+					remove(sibling, n)
+				}
+				if remove(filepath.Join(dir, e.Name()), uint64(info.Size())) {
+					res.Sessions++
+				}
+			}
+		}
+
+		planDir := filepath.Join(home, ".claude", "plans")
+		planFiles, err := readDirIfExists(planDir)
+		if err != nil {
+			return res, err
+		}
+		for _, e := range planFiles {
+			path := filepath.Join(planDir, e.Name())
+			if !e.Type().IsRegular() || filepath.Ext(e.Name()) != ".md" || plans[path] {
+				continue
+			}
+			info, err := e.Info()
+			if err != nil || !info.ModTime().Before(cutoff) {
+				continue
+			}
+			if remove(path, uint64(info.Size())) {
+				res.Plans++
+			}
+		}
+	}
+	return res, nil
+}
+
+// readDirIfExists is os.ReadDir with a missing directory read as empty.
+func readDirIfExists(dir string) ([]os.DirEntry, error) {
+	entries, err := os.ReadDir(dir)
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil, nil
+	}
+	return entries, err
+}
@@ -0,0 +1,106 @@
+package service
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/protobuf/proto"
+
+	leapmuxv1 "github.com/leapmux/leapmux/generated/proto/leapmux/v1"
+	db "github.com/leapmux/leapmux/internal/worker/generated/db"
+)
+
+const (
+	keptSessionID  = "11111111-1111-4111-8111-111111111111"
+	staleSessionID = "22222222-2222-4222-8222-222222222222"
+	freshSessionID = "33333333-3333-4333-8333-333333333333"
+)
+
+// seedClaudeHome lays out a ~/.claude with one file of each kind the
+// collector tells apart, and an agent referencing the kept session and
+// plan. Returns the paths by name.
+func seedClaudeHome(t *testing.T, svc *Service) map[string]string {
+	t.Helper()
+	ctx := context.Background()
+	project := filepath.Join(svc.HomeDir, ".claude", "projects", "-home-me-app")
+	plans := filepath.Join(svc.HomeDir, ".claude", "plans")
+	paths := map[string]string{
+		"kept":       filepath.Join(project, keptSessionID+".jsonl"),
+		"stale":      filepath.Join(project, staleSessionID+".jsonl"),
+		"staleDir":   filepath.Join(project, staleSessionID, "subagents", "agent-1.jsonl"),
+		"fresh":      filepath.Join(project, freshSessionID+".jsonl"),
+		"notSession": filepath.Join(project, "notes.jsonl"),
+		"keptPlan":   filepath.Join(plans, "kept-plan.md"),
+		"stalePlan":  filepath.Join(plans, "stale-plan.md"),
+		"freshPlan":  filepath.Join(plans, "fresh-plan.md"),
+		"staleOther": filepath.Join(plans, "notes.txt"),
+	}
+	old := time.Now().Add(-60 * 24 * time.Hour)
+	for name, path := range paths {
+		writeSizedFile(t, path, 100)
+		if name != "fresh" && name != "freshPlan" {
+			require.NoError(t, os.Chtimes(path, old, old))
+		}
+	}
+
+	require.NoError(t, svc.Queries.CreateAgent(ctx, db.CreateAgentParams{
+		ID: "agent-1", WorkspaceID: "ws-1", WorkingDir: "/tmp", HomeDir: svc.HomeDir,
+	}))
+	require.NoError(t, svc.Queries.UpdateAgentSessionID(ctx, db.UpdateAgentSessionIDParams{AgentSessionID: keptSessionID, ID: "agent-1"}))
+	require.NoError(t, svc.Queries.UpdateAgentPlanFilePath(ctx, db.UpdateAgentPlanFilePathParams{PlanFilePath: paths["keptPlan"], ID: "agent-1"}))
+	return paths
+}
+
+func TestCollectClaudeSessions(t *testing.T) {
+	svc, _, _ := setupTestService(t)
+	paths := seedClaudeHome(t, svc)
+
+	res, err := svc.CollectClaudeSessions(context.Background(), 30*24*time.Hour, true)
+	require.NoError(t, err)
+	assert.Equal(t, ClaudeSessionGCResult{Sessions: 1, Plans: 1, ReclaimedBytes: 300}, res)
+	for name, path := range paths {
+		assert.FileExists(t, path, "a dry run removes nothing: %s", name)
+	}
+
+	res, err = svc.CollectClaudeSessions(context.Background(), 30*24*time.Hour, false)
+	require.NoError(t, err)
+	assert.Equal(t, ClaudeSessionGCResult{Sessions: 1, Plans: 1, ReclaimedBytes: 300}, res)
+	for _, name := range []string{"stale", "staleDir", "stalePlan"} {
+		assert.NoFileExists(t, paths[name], name)
+	}
+	assert.NoDirExists(t, filepath.Join(filepath.Dir(paths["stale"]), staleSessionID), "the transcript's directory goes with it")
+	for _, name := range []string{"kept", "fresh", "notSession", "keptPlan", "freshPlan", "staleOther"} {
+		assert.FileExists(t, paths[name], name)
+	}
+}
+
+func TestCollectClaudeSessionsRPC(t *testing.T) {
+	svc, d, w := setupTestService(t)
+	seedClaudeHome(t, svc)
+
+	dispatch(d, "CollectClaudeSessions", &leapmuxv1.CollectClaudeSessionsRequest{DryRun: true}, w)
+	require.Len(t, w.errors, 1, "collection is off and no retention was given")
+	assert.Equal(t, codeInvalidArgument, w.errors[0].code)
+
+	w = newTestWriter()
+	dispatch(d, "CollectClaudeSessions", &leapmuxv1.CollectClaudeSessionsRequest{RetentionDays: 90, DryRun: true}, w)
+	require.Empty(t, w.errors)
+	require.Len(t, w.responses, 1)
+	var resp leapmuxv1.CollectClaudeSessionsResponse
+	require.NoError(t, proto.Unmarshal(w.responses[0].GetPayload(), &resp))
+	assert.Zero(t, resp.GetRemovedSessions(), "nothing is 90 days old")
+
+	svc.ClaudeSessionRetention = 7 * 24 * time.Hour
+	w = newTestWriter()
+	dispatch(d, "CollectClaudeSessions", &leapmuxv1.CollectClaudeSessionsRequest{}, w)
+	require.Empty(t, w.errors)
+	require.NoError(t, proto.Unmarshal(w.responses[0].GetPayload(), &resp))
+	assert.EqualValues(t, 1, resp.GetRemovedSessions())
+	assert.EqualValues(t, 1, resp.GetRemovedPlans())
+	assert.EqualValues(t, 300, resp.GetReclaimedBytes())
+}
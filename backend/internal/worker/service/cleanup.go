@@ -18,22 +18,42 @@ const (
 	cleanupBatchDelay = 50 * time.Millisecond
 )
 
+// CleanupOptions tunes StartCleanupLoop. Its funcs are asked on every
+// run, so an org that changes a setting takes effect at the next one.
+type CleanupOptions struct {
+	// Retention is how long closed agents and terminals are kept; nil
+	// keeps cleanupRetention.
+	Retention func() time.Duration
+	// KeepAgents holds back the agents -- and with them their transcripts
+	// -- while it reports true; nil never does.
+	KeepAgents func() bool
+	// ArchivedOnly deletes only the closed agents the archive shipper has
+	// shipped, so none is lost before it reaches the bucket.
+	ArchivedOnly bool
+}
+
 // StartCleanupLoop starts a background goroutine that periodically
 // hard-deletes agents and terminals that have been closed for longer
 // than the retention period. A random jitter of up to cleanupJitter is
 // added before each run.
-func StartCleanupLoop(ctx context.Context, queries *db.Queries) {
+func StartCleanupLoop(ctx context.Context, queries *db.Queries, opts CleanupOptions) {
 	periodic.Start(ctx, periodic.Schedule{Interval: cleanupInterval, Jitter: cleanupJitter}, func(ctx context.Context) {
-		runCleanup(ctx, queries)
+		period := cleanupRetention
+		if opts.Retention != nil {
+			period = opts.Retention()
+		}
+		runCleanup(ctx, queries, period, opts.KeepAgents != nil && opts.KeepAgents(), opts.ArchivedOnly)
 	})
 }
 
 // StartOrphanSweepLoop starts a background goroutine that periodically reclaims the
 // in-memory tracker state of agents the DB no longer lists as open (see
-// SweepOrphanedAgentState). Shares the cleanup cadence and jitter.
+// SweepOrphanedAgentState) and the artifact snapshots of deleted agents.
+// Shares the cleanup cadence and jitter.
 func (svc *Service) StartOrphanSweepLoop(ctx context.Context) {
 	periodic.Start(ctx, periodic.Schedule{Interval: cleanupInterval, Jitter: cleanupJitter}, func(context.Context) {
 		svc.SweepOrphanedAgentState()
+		svc.sweepOrphanedArtifacts()
 	})
 }
 
@@ -74,15 +94,21 @@ func (svc *Service) SweepOrphanedAgentState() {
 	}
 }
 
-func runCleanup(ctx context.Context, queries *db.Queries) {
+func runCleanup(ctx context.Context, queries *db.Queries, retention time.Duration, keepAgents, archivedOnly bool) {
 	// Bound as a SQLiteNullTime: the sweeps compare closed_at/deleted_at as raw
 	// strings, so the cutoff must be byte-exact against the stored bytes.
 	// SQLiteNullTime.Value() emits the canonical strftime layout; a raw time.Time
 	// bind would serialize in the driver's own layout (and is now a compile
 	// error) and skip every same-day row.
-	cutoff := sqltime.SQLiteNullTimeOf(time.Now().Add(-cleanupRetention))
+	cutoff := sqltime.SQLiteNullTimeOf(time.Now().Add(-retention))
 
-	cleanupStep(ctx, "agents", func() (sql.Result, error) { return queries.DeleteClosedAgentsBefore(ctx, cutoff) })
+	switch {
+	case keepAgents:
+	case archivedOnly:
+		cleanupStep(ctx, "agents", func() (sql.Result, error) { return queries.DeleteArchivedClosedAgentsBefore(ctx, cutoff) })
+	default:
+		cleanupStep(ctx, "agents", func() (sql.Result, error) { return queries.DeleteClosedAgentsBefore(ctx, cutoff) })
+	}
 	cleanupStep(ctx, "terminals", func() (sql.Result, error) { return queries.DeleteClosedTerminalsBefore(ctx, cutoff) })
 	cleanupStep(ctx, "worktrees", func() (sql.Result, error) { return queries.HardDeleteWorktreesBefore(ctx, cutoff) })
 }
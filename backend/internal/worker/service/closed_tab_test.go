@@ -44,11 +44,18 @@ type testResponseWriter struct {
 type testError struct {
 	code    int32
 	message string
+	reason  string
 }
 
+// SendResponse records an error response (see sendCodedError) in errors,
+// alongside the ones sent through SendError.
 func (w *testResponseWriter) SendResponse(r *leapmuxv1.InnerRpcResponse) error {
 	w.mu.Lock()
 	defer w.mu.Unlock()
+	if r.GetIsError() {
+		w.errors = append(w.errors, testError{r.GetErrorCode(), r.GetErrorMessage(), r.GetErrorReason()})
+		return nil
+	}
 	w.responses = append(w.responses, r)
 	return nil
 }
@@ -56,7 +63,7 @@ func (w *testResponseWriter) SendResponse(r *leapmuxv1.InnerRpcResponse) error {
 func (w *testResponseWriter) SendError(code int32, msg string) error {
 	w.mu.Lock()
 	defer w.mu.Unlock()
-	w.errors = append(w.errors, testError{code, msg})
+	w.errors = append(w.errors, testError{code: code, message: msg})
 	return nil
 }
 
@@ -119,6 +126,9 @@ type setupOption func(*setupConfig)
 type setupConfig struct {
 	workspaceIDs []string
 	remoteIPC    RemoteIPCFactory
+	readOnly     bool
+	guest        bool
+	scoped       bool
 }
 
 // withWorkspaces grants the test channel access to the given workspace
@@ -128,6 +138,24 @@ func withWorkspaces(ids ...string) setupOption {
 	return func(c *setupConfig) { c.workspaceIDs = ids }
 }
 
+// withReadOnly opens the test channel read-only, as the hub does for an
+// anonymous viewer of a public workspace.
+func withReadOnly() setupOption {
+	return func(c *setupConfig) { c.readOnly = true }
+}
+
+// withGuest opens the test channel as a workspace guest's, as the hub does
+// for a guest link bearer. The channel still carries the owner's user id.
+func withGuest() setupOption {
+	return func(c *setupConfig) { c.guest = true }
+}
+
+// withWorkspaceScoped opens the test channel as one made with a credential
+// pinned to a workspace, as the hub does for a delegation bearer.
+func withWorkspaceScoped() setupOption {
+	return func(c *setupConfig) { c.scoped = true }
+}
+
 // withRemoteIPC wires the worker's RemoteIPC factory before handlers are
 // registered so tests can assert mint/release semantics for the
 // LEAPMUX_REMOTE_* token without poking svc.RemoteIPC directly.
@@ -163,6 +191,9 @@ func setupTestService(t *testing.T, opts ...setupOption) (*Service, *channel.Dis
 		UserId:                 "user-1",
 		HandshakePayload:       msg1,
 		AccessibleWorkspaceIds: cfg.workspaceIDs,
+		ReadOnly:               cfg.readOnly,
+		Guest:                  cfg.guest,
+		WorkspaceScoped:        cfg.scoped,
 	})
 
 	// Built through service.New, not by hand.
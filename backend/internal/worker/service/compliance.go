@@ -0,0 +1,296 @@
+package service
+
+import (
+	"context"
+	"crypto/sha256"
+	"database/sql"
+	"encoding/binary"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"log/slog"
+	"sync"
+	"time"
+
+	leapmuxv1 "github.com/leapmux/leapmux/generated/proto/leapmux/v1"
+	"github.com/leapmux/leapmux/internal/util/sqltime"
+	"github.com/leapmux/leapmux/internal/util/userid"
+	"github.com/leapmux/leapmux/internal/worker/channel"
+	db "github.com/leapmux/leapmux/internal/worker/generated/db"
+)
+
+// ImmutableTranscripts reports whether the org keeps immutable transcripts
+// (OrgDefaults.immutable_transcripts): messages are hash-chained as they
+// are persisted, a deleted message is kept as a tombstone, notification
+// threads are not rewritten, and nothing is purged.
+func (svc *Service) ImmutableTranscripts() bool {
+	return svc.orgDefaults.Load().GetImmutableTranscripts()
+}
+
+// recordImmutableTranscriptsStart notes when this worker started chaining
+// messages, the first time the org's defaults turn the mode on. Call it
+// after the defaults are stored: a message created from then on sees the
+// mode on when it is persisted, so it is chained or not written at all.
+func (svc *Service) recordImmutableTranscriptsStart() {
+	if !svc.ImmutableTranscripts() {
+		return
+	}
+	if err := svc.Queries.EnableImmutableTranscripts(bgCtx(), sqltime.NewSQLiteTime(time.Now())); err != nil {
+		slog.Error("failed to record immutable transcripts start", "error", err)
+	}
+}
+
+// chainedMessage is what a chain link's hash covers: the fields of a
+// message that never change once it is persisted. delivery_error and seq
+// are left out on purpose -- a failed delivery is recorded after the fact
+// and a seq can be reassigned -- as are the span columns, which only place
+// the message in the chat.
+type chainedMessage struct {
+	id, agentID string
+	source      leapmuxv1.MessageSource
+	provider    leapmuxv1.AgentProvider
+	compression leapmuxv1.ContentCompression
+	content     []byte
+	createdAt   sqltime.SQLiteTime
+}
+
+// messageChainHash returns the hex SHA-256 of prev and m, each field
+// length-prefixed so no two different inputs share an encoding.
+func messageChainHash(prev string, m chainedMessage) string {
+	h := sha256.New()
+	field := func(b []byte) {
+		var n [8]byte
+		binary.BigEndian.PutUint64(n[:], uint64(len(b)))
+		h.Write(n[:])
+		h.Write(b)
+	}
+	number := func(v int64) {
+		var n [8]byte
+		binary.BigEndian.PutUint64(n[:], uint64(v))
+		field(n[:])
+	}
+	field([]byte(prev))
+	field([]byte(m.id))
+	field([]byte(m.agentID))
+	number(int64(m.source))
+	number(int64(m.provider))
+	number(int64(m.compression))
+	field(m.content)
+	number(m.createdAt.Time.UnixMilli())
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+// chainMessage appends a just-persisted message to its agent's hash chain
+// when the org keeps immutable transcripts. q is the caller's, so a
+// message written inside a transaction is chained inside it too.
+func (h *OutputHandler) chainMessage(ctx context.Context, q *db.Queries, params db.CreateMessageParams) error {
+	if h.immutableTranscripts == nil || !h.immutableTranscripts() {
+		return nil
+	}
+	mu, _ := h.chainLocks.LoadOrStore(params.AgentID, &sync.Mutex{})
+	mu.(*sync.Mutex).Lock()
+	defer mu.(*sync.Mutex).Unlock()
+
+	var prev string
+	var position int64
+	last, err := q.GetLatestMessageChainLink(ctx, params.AgentID)
+	switch {
+	case err == nil:
+		prev, position = last.Hash, last.Position
+	case !errors.Is(err, sql.ErrNoRows):
+		return fmt.Errorf("read chain head: %w", err)
+	}
+	return q.CreateMessageChainLink(ctx, db.CreateMessageChainLinkParams{
+		MessageID: params.ID,
+		AgentID:   params.AgentID,
+		Position:  position + 1,
+		Hash: messageChainHash(prev, chainedMessage{
+			id:          params.ID,
+			agentID:     params.AgentID,
+			source:      params.Source,
+			provider:    params.AgentProvider,
+			compression: params.ContentCompression,
+			content:     params.Content,
+			createdAt:   params.CreatedAt,
+		}),
+	})
+}
+
+// persistMessage writes a message row on the live persistence paths. When
+// the org keeps immutable transcripts the row and its chain link are
+// written in one transaction, so a message that cannot be chained is not
+// written at all.
+func (h *OutputHandler) persistMessage(ctx context.Context, params db.CreateMessageParams) (int64, error) {
+	if h.immutableTranscripts == nil || !h.immutableTranscripts() {
+		return createMessageRow(ctx, h.queries, params)
+	}
+	tx, err := h.db.BeginTx(ctx, nil)
+	if err != nil {
+		return 0, err
+	}
+	defer func() { _ = tx.Rollback() }()
+	queries := h.queries.WithTx(tx)
+	seq, err := createMessageRow(ctx, queries, params)
+	if err != nil {
+		return 0, err
+	}
+	if err := h.chainMessage(ctx, queries, params); err != nil {
+		return 0, fmt.Errorf("chain message: %w", err)
+	}
+	if err := tx.Commit(); err != nil {
+		return 0, err
+	}
+	return seq, nil
+}
+
+// tombstoneMessage removes row from the chat but keeps it, with who
+// removed it, in message_tombstones. Both happen in one transaction so a
+// message is never lost between the two.
+func (svc *Service) tombstoneMessage(ctx context.Context, userID userid.UserID, row db.Message) (int64, error) {
+	tx, err := svc.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return 0, err
+	}
+	defer func() { _ = tx.Rollback() }()
+	queries := svc.Queries.WithTx(tx)
+	if err := queries.CreateMessageTombstone(ctx, db.CreateMessageTombstoneParams{
+		MessageID:          row.ID,
+		AgentID:            row.AgentID,
+		Seq:                row.Seq,
+		Source:             row.Source,
+		Content:            row.Content,
+		ContentCompression: row.ContentCompression,
+		AgentProvider:      row.AgentProvider,
+		DeliveryError:      row.DeliveryError,
+		CreatedAt:          row.CreatedAt,
+		DeletedBy:          userID.String(),
+	}); err != nil {
+		return 0, fmt.Errorf("create tombstone: %w", err)
+	}
+	seq, err := queries.DeleteMessageByAgentAndID(ctx, db.DeleteMessageByAgentAndIDParams{
+		AgentID: row.AgentID,
+		ID:      row.ID,
+	})
+	if err != nil {
+		return 0, err
+	}
+	if err := tx.Commit(); err != nil {
+		return 0, err
+	}
+	return seq, nil
+}
+
+// verifyTranscript walks agentID's hash chain, checking each link against
+// the message it covers -- or the message's tombstone, once it was
+// deleted -- and against the link before it, then checks that no message
+// was written without a link.
+func (svc *Service) verifyTranscript(ctx context.Context, agentID string) (*leapmuxv1.VerifyAgentTranscriptResponse, error) {
+	links, err := svc.Queries.ListMessageChainByAgentID(ctx, agentID)
+	if err != nil {
+		return nil, fmt.Errorf("list chain: %w", err)
+	}
+	res := &leapmuxv1.VerifyAgentTranscriptResponse{Intact: true}
+	broken := func(link db.MessageChain, reason string) *leapmuxv1.VerifyAgentTranscriptResponse {
+		res.Intact = false
+		res.BrokenMessageId = link.MessageID
+		res.Reason = reason
+		return res
+	}
+	prev := ""
+	for i, link := range links {
+		if link.Position != int64(i)+1 {
+			return broken(link, fmt.Sprintf("link %d is missing", i+1)), nil
+		}
+		m, tombstoned, err := svc.chainedMessage(ctx, agentID, link.MessageID)
+		if errors.Is(err, sql.ErrNoRows) {
+			return broken(link, "message was removed without a tombstone"), nil
+		}
+		if err != nil {
+			return nil, err
+		}
+		if messageChainHash(prev, m) != link.Hash {
+			return broken(link, "message does not match its hash"), nil
+		}
+		if tombstoned {
+			res.TombstonedMessages++
+		}
+		res.VerifiedMessages++
+		res.HeadHash = link.Hash
+		prev = link.Hash
+	}
+	return svc.verifyEveryMessageChained(ctx, agentID, res)
+}
+
+// verifyEveryMessageChained fails res if agentID has a message, or the
+// tombstone of one, that was created after this worker started keeping
+// immutable transcripts but has no chain link: a message written around
+// the chain would otherwise verify as if it were never there.
+func (svc *Service) verifyEveryMessageChained(ctx context.Context, agentID string, res *leapmuxv1.VerifyAgentTranscriptResponse) (*leapmuxv1.VerifyAgentTranscriptResponse, error) {
+	since, err := svc.Queries.GetImmutableTranscriptsEnabledAt(ctx)
+	if errors.Is(err, sql.ErrNoRows) {
+		return res, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("read immutable transcripts start: %w", err)
+	}
+	unchained, err := svc.Queries.GetFirstUnchainedMessage(ctx, db.GetFirstUnchainedMessageParams{AgentID: agentID, CreatedAt: since})
+	if errors.Is(err, sql.ErrNoRows) {
+		unchained, err = svc.Queries.GetFirstUnchainedMessageTombstone(ctx, db.GetFirstUnchainedMessageTombstoneParams{AgentID: agentID, CreatedAt: since})
+	}
+	switch {
+	case errors.Is(err, sql.ErrNoRows):
+		return res, nil
+	case err != nil:
+		return nil, fmt.Errorf("find unchained message: %w", err)
+	}
+	res.Intact = false
+	res.BrokenMessageId = unchained
+	res.Reason = "message has no chain link"
+	return res, nil
+}
+
+// chainedMessage reads the hashed fields of messageID from its row, or
+// from its tombstone once it was deleted.
+func (svc *Service) chainedMessage(ctx context.Context, agentID, messageID string) (chainedMessage, bool, error) {
+	row, err := svc.Queries.GetMessageByAgentAndID(ctx, db.GetMessageByAgentAndIDParams{ID: messageID, AgentID: agentID})
+	if err == nil {
+		return chainedMessage{
+			id:          row.ID,
+			agentID:     row.AgentID,
+			source:      row.Source,
+			provider:    row.AgentProvider,
+			compression: row.ContentCompression,
+			content:     row.Content,
+			createdAt:   row.CreatedAt,
+		}, false, nil
+	}
+	if !errors.Is(err, sql.ErrNoRows) {
+		return chainedMessage{}, false, err
+	}
+	t, err := svc.Queries.GetMessageTombstone(ctx, db.GetMessageTombstoneParams{MessageID: messageID, AgentID: agentID})
+	if err != nil {
+		return chainedMessage{}, false, err
+	}
+	return chainedMessage{
+		id:          t.MessageID,
+		agentID:     t.AgentID,
+		source:      t.Source,
+		provider:    t.AgentProvider,
+		compression: t.ContentCompression,
+		content:     t.Content,
+		createdAt:   t.CreatedAt,
+	}, true, nil
+}
+
+func registerComplianceHandlers(d registrar, svc *Service) {
+	registerAgentGated(d, "VerifyAgentTranscript",
+		func(ctx context.Context, _ userid.UserID, _ *leapmuxv1.VerifyAgentTranscriptRequest, agentRow db.Agent, sender channel.ResponseWriter) {
+			res, err := svc.verifyTranscript(ctx, agentRow.ID)
+			if err != nil {
+				slog.Error("failed to verify transcript", "agent_id", agentRow.ID, "error", err)
+				sendInternalError(sender, "failed to verify transcript")
+				return
+			}
+			sendProtoResponse(sender, res)
+		})
+}
@@ -0,0 +1,154 @@
+package service
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	leapmuxv1 "github.com/leapmux/leapmux/generated/proto/leapmux/v1"
+	"github.com/leapmux/leapmux/internal/util/sqltime"
+	"github.com/leapmux/leapmux/internal/worker/agent"
+	db "github.com/leapmux/leapmux/internal/worker/generated/db"
+)
+
+func TestImmutableTranscripts_TombstonesAndVerifiesChain(t *testing.T) {
+	svc, d, w := setupTestService(t, withWorkspaces("ws-1"))
+	svc.SetOrgDefaults(&leapmuxv1.OrgDefaults{ImmutableTranscripts: true})
+	seedGuardedAgent(t, svc, "")
+	ctx := context.Background()
+
+	for _, content := range []string{
+		`{"type":"assistant","message":{"content":[{"type":"text","text":"one"}]}}`,
+		`{"type":"assistant","message":{"content":[{"type":"text","text":"two"}]}}`,
+	} {
+		require.NoError(t, svc.Output.persistAndBroadcast("agent-1", claudeProvider,
+			leapmuxv1.MessageSource_MESSAGE_SOURCE_AGENT, []byte(content), agent.SpanInfo{}, nil))
+	}
+	failed := db.CreateMessageParams{
+		ID:            "msg-failed",
+		AgentID:       "agent-1",
+		Source:        leapmuxv1.MessageSource_MESSAGE_SOURCE_USER,
+		Content:       []byte(`{"type":"text","text":"hi"}`),
+		AgentProvider: claudeProvider,
+		CreatedAt:     sqltime.NewSQLiteTime(time.Now()),
+	}
+	_, err := createMessageRow(ctx, svc.Queries, failed)
+	require.NoError(t, err)
+	require.NoError(t, svc.Output.chainMessage(ctx, svc.Queries, failed))
+	require.NoError(t, svc.Queries.SetMessageDeliveryError(ctx, db.SetMessageDeliveryErrorParams{
+		DeliveryError: "delivery failed", ID: "msg-failed", AgentID: "agent-1",
+	}))
+
+	verify := func() *leapmuxv1.VerifyAgentTranscriptResponse {
+		dispatch(d, "VerifyAgentTranscript", &leapmuxv1.VerifyAgentTranscriptRequest{AgentId: "agent-1"}, w)
+		require.Empty(t, w.errors)
+		return decodeResponse[leapmuxv1.VerifyAgentTranscriptResponse](t, w)
+	}
+	res := verify()
+	assert.True(t, res.GetIntact())
+	assert.Equal(t, int64(3), res.GetVerifiedMessages())
+	assert.NotEmpty(t, res.GetHeadHash())
+
+	// A delete keeps the message as a tombstone; the chain still verifies.
+	dispatch(d, "DeleteAgentMessage", &leapmuxv1.DeleteAgentMessageRequest{AgentId: "agent-1", MessageId: "msg-failed"}, w)
+	require.Empty(t, w.errors)
+	assert.True(t, decodeResponse[leapmuxv1.DeleteAgentMessageResponse](t, w).GetTombstoned())
+	_, err = svc.Queries.GetMessageByAgentAndID(ctx, db.GetMessageByAgentAndIDParams{ID: "msg-failed", AgentID: "agent-1"})
+	assert.Error(t, err)
+	tomb, err := svc.Queries.GetMessageTombstone(ctx, db.GetMessageTombstoneParams{MessageID: "msg-failed", AgentID: "agent-1"})
+	require.NoError(t, err)
+	assert.Equal(t, "delivery failed", tomb.DeliveryError)
+	res = verify()
+	assert.True(t, res.GetIntact())
+	assert.Equal(t, int64(3), res.GetVerifiedMessages())
+	assert.Equal(t, int64(1), res.GetTombstonedMessages())
+
+	// Rewriting a stored message breaks the chain at that message.
+	msgs, err := svc.Queries.ListAllMessagesByAgentID(ctx, db.ListAllMessagesByAgentIDParams{AgentID: "agent-1"})
+	require.NoError(t, err)
+	require.Len(t, msgs, 2)
+	_, err = svc.DB.ExecContext(ctx, `UPDATE messages SET content = ? WHERE id = ?`, []byte(`{"forged":true}`), msgs[1].ID)
+	require.NoError(t, err)
+	res = verify()
+	assert.False(t, res.GetIntact())
+	assert.Equal(t, msgs[1].ID, res.GetBrokenMessageId())
+	assert.Equal(t, int64(1), res.GetVerifiedMessages())
+}
+
+func TestImmutableTranscripts_OffDoesNotChain(t *testing.T) {
+	svc, _, _ := setupTestService(t, withWorkspaces("ws-1"))
+	seedGuardedAgent(t, svc, "")
+
+	require.NoError(t, svc.Output.persistAndBroadcast("agent-1", claudeProvider,
+		leapmuxv1.MessageSource_MESSAGE_SOURCE_AGENT, []byte(`{"type":"assistant"}`), agent.SpanInfo{}, nil))
+	links, err := svc.Queries.ListMessageChainByAgentID(context.Background(), "agent-1")
+	require.NoError(t, err)
+	assert.Empty(t, links)
+}
+
+func TestImmutableTranscripts_RejectsUnchainedMessages(t *testing.T) {
+	svc, d, w := setupTestService(t, withWorkspaces("ws-1"))
+	seedGuardedAgent(t, svc, "")
+	ctx := context.Background()
+	persist := func(text string) {
+		require.NoError(t, svc.Output.persistAndBroadcast("agent-1", claudeProvider,
+			leapmuxv1.MessageSource_MESSAGE_SOURCE_AGENT, []byte(`{"type":"assistant","text":"`+text+`"}`), agent.SpanInfo{}, nil))
+	}
+	verify := func() *leapmuxv1.VerifyAgentTranscriptResponse {
+		dispatch(d, "VerifyAgentTranscript", &leapmuxv1.VerifyAgentTranscriptRequest{AgentId: "agent-1"}, w)
+		require.Empty(t, w.errors)
+		return decodeResponse[leapmuxv1.VerifyAgentTranscriptResponse](t, w)
+	}
+
+	// A message from before the mode was on is not expected to be chained.
+	persist("before")
+	time.Sleep(2 * time.Millisecond)
+	svc.SetOrgDefaults(&leapmuxv1.OrgDefaults{ImmutableTranscripts: true})
+	persist("after")
+	res := verify()
+	assert.True(t, res.GetIntact())
+	assert.Equal(t, int64(1), res.GetVerifiedMessages())
+
+	// One written around the chain since is.
+	sneaked := db.CreateMessageParams{
+		ID:            "msg-sneaked",
+		AgentID:       "agent-1",
+		Source:        leapmuxv1.MessageSource_MESSAGE_SOURCE_USER,
+		Content:       []byte(`{"type":"text","text":"hi"}`),
+		AgentProvider: claudeProvider,
+		CreatedAt:     sqltime.NewSQLiteTime(time.Now()),
+	}
+	_, err := createMessageRow(ctx, svc.Queries, sneaked)
+	require.NoError(t, err)
+	res = verify()
+	assert.False(t, res.GetIntact())
+	assert.Equal(t, "msg-sneaked", res.GetBrokenMessageId())
+
+	// So is its tombstone once it is deleted.
+	require.NoError(t, svc.Queries.SetMessageDeliveryError(ctx, db.SetMessageDeliveryErrorParams{
+		DeliveryError: "delivery failed", ID: "msg-sneaked", AgentID: "agent-1",
+	}))
+	dispatch(d, "DeleteAgentMessage", &leapmuxv1.DeleteAgentMessageRequest{AgentId: "agent-1", MessageId: "msg-sneaked"}, w)
+	require.Empty(t, w.errors)
+	res = verify()
+	assert.False(t, res.GetIntact())
+	assert.Equal(t, "msg-sneaked", res.GetBrokenMessageId())
+}
+
+func TestImmutableTranscripts_UnchainableMessageIsNotWritten(t *testing.T) {
+	svc, _, _ := setupTestService(t, withWorkspaces("ws-1"))
+	svc.SetOrgDefaults(&leapmuxv1.OrgDefaults{ImmutableTranscripts: true})
+	seedGuardedAgent(t, svc, "")
+	ctx := context.Background()
+
+	_, err := svc.DB.ExecContext(ctx, `DROP TABLE message_chain`)
+	require.NoError(t, err)
+	assert.Error(t, svc.Output.persistAndBroadcast("agent-1", claudeProvider,
+		leapmuxv1.MessageSource_MESSAGE_SOURCE_AGENT, []byte(`{"type":"assistant"}`), agent.SpanInfo{}, nil))
+	msgs, err := svc.Queries.ListAllMessagesByAgentID(ctx, db.ListAllMessagesByAgentIDParams{AgentID: "agent-1"})
+	require.NoError(t, err)
+	assert.Empty(t, msgs)
+}
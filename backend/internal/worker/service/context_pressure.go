@@ -0,0 +1,152 @@
+package service
+
+import (
+	"log/slog"
+
+	leapmuxv1 "github.com/leapmux/leapmux/generated/proto/leapmux/v1"
+	"github.com/leapmux/leapmux/internal/worker/agent"
+)
+
+// ContextPressurePolicy warns in the chat as an agent's context window
+// fills, so a long session is compacted or wrapped up deliberately rather
+// than noticed only once the provider starts dropping context.
+type ContextPressurePolicy struct {
+	// WarnPercent posts a warning once the context reaches this share of
+	// the window. Zero disables the warning.
+	WarnPercent int
+	// CriticalPercent posts a critical warning and takes CriticalAction.
+	// Zero disables it.
+	CriticalPercent int
+	// CriticalAction runs once the turn that crossed CriticalPercent ends.
+	CriticalAction ContextPressureAction
+}
+
+// ContextPressureAction is what LeapMux does for an agent whose context
+// reaches the critical threshold.
+type ContextPressureAction string
+
+const (
+	// ContextPressureActionNone only warns.
+	ContextPressureActionNone ContextPressureAction = ""
+	// ContextPressureActionCompact compacts the context, as /compact does.
+	ContextPressureActionCompact ContextPressureAction = "compact"
+	// ContextPressureActionCheckpoint asks the agent to write down where
+	// it stands, so the work survives a truncated or cleared context.
+	ContextPressureActionCheckpoint ContextPressureAction = "checkpoint"
+)
+
+// contextCheckpointPrompt is the synthetic turn ContextPressureActionCheckpoint
+// sends.
+const contextCheckpointPrompt = "Your context window is nearly full. Before continuing, write a short checkpoint of this session: " +
+	"what is done, what is in progress, the files involved, and the next steps. Keep it self-contained, " +
+	"so the work can resume from it alone."
+
+type contextPressureLevel int
+
+const (
+	contextPressureNone contextPressureLevel = iota
+	contextPressureWarning
+	contextPressureCritical
+)
+
+func (l contextPressureLevel) String() string {
+	if l == contextPressureCritical {
+		return "critical"
+	}
+	return "warning"
+}
+
+// level classifies a context of tokens out of window. An unknown window
+// is never under pressure.
+func (p ContextPressurePolicy) level(tokens, window int64) contextPressureLevel {
+	if window <= 0 {
+		return contextPressureNone
+	}
+	percent := tokens * 100 / window
+	switch {
+	case p.CriticalPercent > 0 && percent >= int64(p.CriticalPercent):
+		return contextPressureCritical
+	case p.WarnPercent > 0 && percent >= int64(p.WarnPercent):
+		return contextPressureWarning
+	}
+	return contextPressureNone
+}
+
+// observeSessionInfo receives the session info values an agent's
+// broadcast changed, as JSON by key.
+func (svc *Service) observeSessionInfo(agentID string, provider leapmuxv1.AgentProvider, changed map[string][]byte) {
+	if usage, ok := changed["context_usage"]; ok {
+		svc.checkContextPressure(agentID, provider, usage)
+	}
+	if limits, ok := changed["rate_limits"]; ok {
+		svc.observeRateLimits(agentID, limits)
+	}
+}
+
+// checkContextPressure is called with each new context_usage value. It
+// posts a context_pressure notification each time agentID's context climbs
+// into a higher level, and schedules the critical action. Falling back to
+// a lower level (after a compaction, say) re-arms the higher ones.
+func (svc *Service) checkContextPressure(agentID string, provider leapmuxv1.AgentProvider, usage []byte) {
+	tokens, window := parseContextUsage(usage)
+	level := svc.ContextPressure.level(tokens, window)
+	if level == contextPressureNone {
+		svc.contextPressure.Delete(agentID)
+		return
+	}
+	if prev, ok := svc.contextPressure.Swap(agentID, level); ok && prev.(contextPressureLevel) >= level {
+		return
+	}
+
+	payload := agent.ContextPressurePayload{
+		Level:         level.String(),
+		ContextTokens: tokens,
+		ContextWindow: window,
+		Percent:       tokens * 100 / window,
+	}
+	action := ContextPressureActionNone
+	if level == contextPressureCritical {
+		action = svc.ContextPressure.CriticalAction
+	}
+	if _, busy := svc.compactions.Load(agentID); busy {
+		action = ContextPressureActionNone
+	}
+	if action != ContextPressureActionNone {
+		payload.Action = string(action)
+	}
+	svc.Output.PersistLeapMuxNotification(agentID, provider, agent.NotificationContent(payload))
+	if action == ContextPressureActionNone {
+		return
+	}
+
+	run := func() { go svc.runContextPressureAction(agentID, action) }
+	if _, idle := svc.Output.agentIdleFor(agentID); idle {
+		run()
+		return
+	}
+	svc.Output.onNextTurnEnd(agentID, run)
+}
+
+// runContextPressureAction takes action for agentID once it is idle. It
+// stands down if a turn started in the meantime: the user is driving.
+func (svc *Service) runContextPressureAction(agentID string, action ContextPressureAction) {
+	dbAgent, err := svc.Queries.GetAgentByID(bgCtx(), agentID)
+	if err != nil || dbAgent.ClosedAt.Valid {
+		return
+	}
+	if _, idle := svc.Output.agentIdleFor(agentID); !idle {
+		return
+	}
+	switch action {
+	case ContextPressureActionCompact:
+		if err := svc.checkCompactable(dbAgent); err != nil {
+			slog.Info("skipping automatic compaction", "agent_id", agentID, "reason", err)
+			return
+		}
+		if _, err := svc.compactAgentContext(dbAgent); err != nil {
+			slog.Warn("automatic compaction failed", "agent_id", agentID, "error", err)
+		}
+	case ContextPressureActionCheckpoint:
+		svc.sendSyntheticUserMessage(agentID, contextCheckpointPrompt, leapmuxv1.MarkType_MARK_TYPE_UNSPECIFIED)
+	}
+}
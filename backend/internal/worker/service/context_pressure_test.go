@@ -0,0 +1,95 @@
+package service
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	leapmuxv1 "github.com/leapmux/leapmux/generated/proto/leapmux/v1"
+	"github.com/leapmux/leapmux/internal/util/msgcodec"
+	"github.com/leapmux/leapmux/internal/worker/agent"
+	db "github.com/leapmux/leapmux/internal/worker/generated/db"
+)
+
+func reportContextUsage(sink agent.OutputSink, tokens int64) {
+	sink.BroadcastSessionInfo(map[string]any{
+		"context_usage": map[string]any{"input_tokens": tokens, "context_window": 200000},
+	})
+}
+
+func pressureNotifications(t *testing.T, svc *Service) []map[string]any {
+	t.Helper()
+	return findNotificationsByType(readAllNotifications(t, svc.Queries, "agent-1"), agent.NotificationTypeContextPressure)
+}
+
+func TestContextPressure_WarnsOncePerLevel(t *testing.T) {
+	svc, _, _ := setupTestService(t, withWorkspaces("ws-1"))
+	svc.ContextPressure = ContextPressurePolicy{WarnPercent: 80, CriticalPercent: 95}
+	row := seedGuardedAgent(t, svc, "default")
+	sink := svc.Output.NewSink(row.ID, row.AgentProvider)
+
+	reportContextUsage(sink, 100000)
+	assert.Empty(t, pressureNotifications(t, svc))
+
+	reportContextUsage(sink, 170000)
+	reportContextUsage(sink, 175000)
+	notes := pressureNotifications(t, svc)
+	require.Len(t, notes, 1, "staying at a level does not repeat its warning")
+	assert.Equal(t, "warning", notes[0]["level"])
+	assert.EqualValues(t, 85, notes[0]["percent"])
+
+	reportContextUsage(sink, 192000)
+	notes = pressureNotifications(t, svc)
+	require.Len(t, notes, 2)
+	assert.Equal(t, "critical", notes[1]["level"])
+	assert.NotContains(t, notes[1], "action", "no critical action is configured")
+
+	reportContextUsage(sink, 20000)
+	reportContextUsage(sink, 170000)
+	assert.Len(t, pressureNotifications(t, svc), 3, "dropping below the thresholds re-arms them")
+}
+
+func TestContextPressure_CheckpointRunsAfterTurnEnds(t *testing.T) {
+	svc, _, _ := setupTestService(t, withWorkspaces("ws-1"))
+	svc.ContextPressure = ContextPressurePolicy{CriticalPercent: 95, CriticalAction: ContextPressureActionCheckpoint}
+	svc.startAgentFn = func(context.Context, agent.Options, agent.OutputSink) (map[string]string, error) {
+		return nil, errors.New("not started")
+	}
+	row := seedGuardedAgent(t, svc, "default")
+	sink := svc.Output.NewSink(row.ID, row.AgentProvider)
+
+	svc.Output.beginAgentTurn(row.ID)
+	reportContextUsage(sink, 196000)
+	notes := pressureNotifications(t, svc)
+	require.Len(t, notes, 1)
+	assert.Equal(t, "checkpoint", notes[0]["action"])
+
+	userMessages := func() []string {
+		msgs, err := svc.Queries.ListAllMessagesByAgentID(context.Background(), db.ListAllMessagesByAgentIDParams{AgentID: row.ID})
+		require.NoError(t, err)
+		var out []string
+		for _, m := range msgs {
+			if m.Source != leapmuxv1.MessageSource_MESSAGE_SOURCE_USER {
+				continue
+			}
+			raw, err := msgcodec.Decompress(m.Content, m.ContentCompression)
+			require.NoError(t, err)
+			var body struct {
+				Content string `json:"content"`
+			}
+			require.NoError(t, json.Unmarshal(raw, &body))
+			out = append(out, body.Content)
+		}
+		return out
+	}
+	assert.Empty(t, userMessages(), "the checkpoint waits for the turn to end")
+
+	require.NoError(t, sink.PersistTurnEnd([]byte(`{"type":"result"}`), agent.SpanInfo{}))
+	require.Eventually(t, func() bool { return len(userMessages()) == 1 }, 5*time.Second, 20*time.Millisecond)
+	assert.Equal(t, contextCheckpointPrompt, userMessages()[0])
+}
@@ -2,8 +2,10 @@ package service
 
 import (
 	"encoding/json"
+	"fmt"
 	"log/slog"
 	"strings"
+	"time"
 
 	leapmuxv1 "github.com/leapmux/leapmux/generated/proto/leapmux/v1"
 	"github.com/leapmux/leapmux/internal/worker/agent"
@@ -29,6 +31,8 @@ type controlResponseRequestMetadata struct {
 	ToolUseID string
 	Payload   json.RawMessage
 	Loaded    bool
+	// CreatedAt is when the request was raised; zero when it was not found.
+	CreatedAt time.Time
 }
 
 type controlResponsePlan struct {
@@ -56,6 +60,7 @@ func (svc *Service) loadControlResponseRequestMetadata(agentID string, plugin ag
 		return meta
 	}
 	meta.Payload = json.RawMessage(cr.Payload)
+	meta.CreatedAt = cr.CreatedAt.Time
 
 	var crBody struct {
 		Request struct {
@@ -171,6 +176,9 @@ func (plan controlResponsePlan) isPlanPrompt() bool {
 // the caller forwards, so the user's answer precedes any async plan-execution rows.
 func (svc *Service) applyWinningControlResponse(agentID string, dbAgent db.Agent, plan controlResponsePlan) {
 	svc.deleteControlRequest(agentID, dbAgent.AgentProvider, plan.requestMeta, plan.resolution.SelfDisplayed)
+	if createdAt := plan.requestMeta.CreatedAt; !createdAt.IsZero() {
+		svc.Output.recordAgentActivity(agentID, activityApproval, time.Since(createdAt).Milliseconds(), "")
+	}
 	if plan.isPlanPrompt() {
 		svc.handleControlResponsePromptPlan(agentID, dbAgent, plan)
 	} else {
@@ -326,8 +334,9 @@ func (svc *Service) handleControlResponsePromptPlan(agentID string, dbAgent db.A
 		if crPayload.PermissionMode != "" {
 			dbAgent = svc.setAgentPermissionModeWithAgent(dbAgent, crPayload.PermissionMode)
 			// Grant the provider's bypass options for the approved mode (applied live, notify on
-			// first set) -- e.g. Codex's full network access + no sandbox.
-			if len(approvalOptions.Bypass) > 0 {
+			// first set) -- e.g. Codex's full network access + no sandbox. Withheld when the
+			// guardrails refused the mode: the substitute is never a bypass.
+			if len(approvalOptions.Bypass) > 0 && !svc.permissionGuardrails().forbids(crPayload.PermissionMode) {
 				svc.applyOptionChanges(dbAgent, approvalOptions.Bypass, applyOptionsSpec{live: true, notifyFirstSet: true})
 			}
 		}
@@ -409,7 +418,7 @@ func (svc *Service) applyControlResponsePlanModeMutations(agentID string, dbAgen
 		// Remove the planModeToolUse entry so detectPlanModeFromToolResult
 		// does not override the mode we just set.
 		if plan.requestMeta.ToolUseID != "" {
-			svc.Output.planModeToolUse.Delete(plan.requestMeta.ToolUseID)
+			svc.Output.planModes.forget(plan.requestMeta.ToolUseID)
 		}
 
 		// When clearing context, kick off the restart -- once. The forward itself is withheld for
@@ -511,3 +520,50 @@ func (svc *Service) persistControlResponseRow(agentID string, provider leapmuxv1
 		slog.Warn("failed to persist control response row", "agent_id", agentID, "error", err)
 	}
 }
+
+// sendControlDenial answers an agent's pending control request with a deny
+// carrying message, in whatever form the agent's provider expects, for the
+// requests the worker decides itself rather than a user.
+func (svc *Service) sendControlDenial(provider leapmuxv1.AgentProvider, agentID, requestID string, requestPayload []byte, toolName, message string) error {
+	return svc.sendControlAnswer(provider, agentID, requestID, requestPayload, toolName, map[string]interface{}{
+		"behavior": agent.ControlBehaviorDeny,
+		"message":  message,
+	})
+}
+
+// sendControlApproval is sendControlDenial's counterpart for an allow that
+// runs the tool with its input unchanged.
+func (svc *Service) sendControlApproval(provider leapmuxv1.AgentProvider, agentID, requestID string, requestPayload []byte, toolName string, input json.RawMessage) error {
+	if len(input) == 0 {
+		input = json.RawMessage("{}")
+	}
+	return svc.sendControlAnswer(provider, agentID, requestID, requestPayload, toolName, map[string]interface{}{
+		"behavior":     agent.ControlBehaviorAllow,
+		"updatedInput": input,
+	})
+}
+
+// sendControlAnswer sends answer as the response to an agent's pending
+// control request, shaped the way the frontend shapes the user's.
+func (svc *Service) sendControlAnswer(provider leapmuxv1.AgentProvider, agentID, requestID string, requestPayload []byte, toolName string, answer map[string]interface{}) error {
+	content, err := json.Marshal(map[string]interface{}{
+		"type": "control_response",
+		"response": map[string]interface{}{
+			"subtype":    "success",
+			"request_id": requestID,
+			"response":   answer,
+		},
+	})
+	if err != nil {
+		return fmt.Errorf("encode control response: %w", err)
+	}
+	resolution := agent.ProviderFor(provider).ResolveControlResponse(agent.ControlResponseContext{
+		RequestPayload:  requestPayload,
+		ResponseContent: content,
+		ToolName:        toolName,
+	})
+	if len(resolution.Content) == 0 {
+		resolution.Content = content
+	}
+	return svc.Agents.SendRawInput(agentID, resolution.Content)
+}
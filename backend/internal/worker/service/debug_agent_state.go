@@ -0,0 +1,76 @@
+package service
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+
+	leapmuxv1 "github.com/leapmux/leapmux/generated/proto/leapmux/v1"
+	"github.com/leapmux/leapmux/internal/errcode"
+	"github.com/leapmux/leapmux/internal/util/timefmt"
+	"github.com/leapmux/leapmux/internal/util/userid"
+	"github.com/leapmux/leapmux/internal/worker/channel"
+	"google.golang.org/grpc/codes"
+)
+
+func registerDebugAgentStateHandlers(d ownerOnlyRegistrar, svc *Service) {
+	d.Register("DebugAgentState", func(ctx context.Context, _ userid.UserID, req *leapmuxv1.InnerRpcRequest, sender channel.ResponseWriter) {
+		var r leapmuxv1.DebugAgentStateRequest
+		if err := unmarshalRequest(req, &r); err != nil || r.GetAgentId() == "" {
+			sendInvalidArgument(sender, "agent_id is required")
+			return
+		}
+		if _, err := svc.Queries.GetAgentByID(ctx, r.GetAgentId()); err != nil {
+			if errors.Is(err, sql.ErrNoRows) {
+				sendCodedError(sender, codes.NotFound, errcode.Wrap(errcode.AgentNotFound, errors.New("agent not found")))
+			} else {
+				sendInternalError(sender, "failed to look up agent")
+			}
+			return
+		}
+		state := svc.Output.debugAgentState(r.GetAgentId())
+		state.Running = svc.Agents.HasAgent(r.GetAgentId())
+		sendProtoResponse(sender, state)
+	})
+}
+
+// debugAgentState snapshots the handler's in-memory state for agentID.
+// It reads the maps without creating entries, so inspecting an agent
+// leaves nothing behind for the orphan sweep.
+func (h *OutputHandler) debugAgentState(agentID string) *leapmuxv1.DebugAgentStateResponse {
+	out := &leapmuxv1.DebugAgentStateResponse{}
+
+	if ref, ok := h.notifThreads.current(agentID); ok {
+		out.NotificationThread = h.notifThreads.state(agentID, ref)
+	}
+	out.PlanModeToolUses = h.planModes.forAgent(agentID)
+	out.AutoContinueTimers = h.autoContinueTimers.forAgent(agentID)
+
+	if v, ok := h.sinks.Load(agentID); ok {
+		sink := v.(*agentOutputSink)
+		sink.sessionInfoMu.Lock()
+		out.SessionInfo = make(map[string]string, len(sink.lastSessionInfo))
+		for k, b := range sink.lastSessionInfo {
+			out.SessionInfo[k] = string(b)
+		}
+		sink.sessionInfoMu.Unlock()
+	}
+
+	if v, ok := h.spanTrackers.Load(agentID); ok {
+		t := v.(*SpanTracker)
+		t.mu.Lock()
+		for _, span := range t.spans {
+			out.OpenSpanIds = append(out.OpenSpanIds, span.SpanID)
+		}
+		t.mu.Unlock()
+	}
+
+	if v, ok := h.activity.Load(agentID); ok {
+		a := v.(*agentActivity)
+		a.mu.Lock()
+		out.LastActivityAt = timefmt.Format(a.last)
+		out.InTurn = a.inTurn
+		a.mu.Unlock()
+	}
+	return out
+}
@@ -0,0 +1,56 @@
+package service
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/protobuf/proto"
+
+	leapmuxv1 "github.com/leapmux/leapmux/generated/proto/leapmux/v1"
+	"github.com/leapmux/leapmux/internal/errcode"
+	"github.com/leapmux/leapmux/internal/worker/agent"
+)
+
+func TestDebugAgentState(t *testing.T) {
+	svc, d, w := setupTestService(t, withWorkspaces("ws-1"))
+	seedGuardedAgent(t, svc, agent.PermissionModeDefault)
+
+	sink := svc.Output.NewSink("agent-1", claudeProvider)
+	sink.StorePlanModeToolUse("toolu-1", agent.PermissionModePlan)
+	svc.Output.NewSink("agent-2", claudeProvider).StorePlanModeToolUse("toolu-2", agent.PermissionModePlan)
+	sink.BroadcastSessionInfo(map[string]interface{}{"contextUsage": map[string]interface{}{"input_tokens": 5}})
+	sink.PersistLeapMuxNotification(map[string]interface{}{"type": agent.NotificationTypeInterrupted})
+	key := autoContinueKey{AgentID: "agent-1", Reason: agent.AutoContinueReasonRateLimit}
+	svc.Output.armAutoContinueTimer(key, time.Now().Add(time.Hour))
+	t.Cleanup(func() { svc.Output.stopAutoContinueTimer(key, true) })
+
+	dispatch(d, "DebugAgentState", &leapmuxv1.DebugAgentStateRequest{AgentId: "agent-1"}, w)
+	require.Empty(t, w.errors)
+	require.Len(t, w.responses, 1)
+	var resp leapmuxv1.DebugAgentStateResponse
+	require.NoError(t, proto.Unmarshal(w.responses[0].GetPayload(), &resp))
+
+	assert.False(t, resp.GetRunning())
+	assert.Equal(t, "agent-1", resp.GetNotificationThread().GetAgentId())
+	require.Len(t, resp.GetPlanModeToolUses(), 1, "only this agent's tool uses")
+	assert.Equal(t, "toolu-1", resp.GetPlanModeToolUses()[0].GetToolUseId())
+	assert.Equal(t, agent.PermissionModePlan, resp.GetPlanModeToolUses()[0].GetTargetMode())
+	require.Len(t, resp.GetAutoContinueTimers(), 1)
+	assert.Equal(t, string(agent.AutoContinueReasonRateLimit), resp.GetAutoContinueTimers()[0].GetReason())
+	assert.True(t, resp.GetAutoContinueTimers()[0].GetArmed())
+	assert.JSONEq(t, `{"input_tokens":5}`, resp.GetSessionInfo()["contextUsage"])
+	assert.NotEmpty(t, resp.GetLastActivityAt())
+
+	w = newTestWriter()
+	dispatch(d, "DebugAgentState", &leapmuxv1.DebugAgentStateRequest{AgentId: "missing"}, w)
+	require.Len(t, w.errors, 1)
+	assert.Equal(t, codeNotFound, w.errors[0].code)
+	assert.Equal(t, string(errcode.AgentNotFound), w.errors[0].reason)
+
+	w = newTestWriter()
+	dispatch(d, "DebugAgentState", &leapmuxv1.DebugAgentStateRequest{}, w)
+	require.Len(t, w.errors, 1)
+	assert.Equal(t, codeInvalidArgument, w.errors[0].code)
+}
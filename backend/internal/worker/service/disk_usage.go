@@ -0,0 +1,378 @@
+package service
+
+import (
+	"cmp"
+	"context"
+	"errors"
+	"fmt"
+	"io/fs"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	leapmuxv1 "github.com/leapmux/leapmux/generated/proto/leapmux/v1"
+	"github.com/leapmux/leapmux/internal/errcode"
+	"github.com/leapmux/leapmux/internal/util/periodic"
+	"github.com/leapmux/leapmux/internal/util/timefmt"
+	"github.com/leapmux/leapmux/internal/worker/agent"
+)
+
+const (
+	// diskUsageInterval is how often tracked worktrees and checkouts are
+	// measured. A measurement walks every file in them, so it is not run
+	// per request; quotas are enforced against the latest one.
+	diskUsageInterval = 10 * time.Minute
+	// defaultMinFreeBytes is the free-space floor when the org sets none.
+	defaultMinFreeBytes = 1 << 30
+	// diskQuotaWarnPercent is the share of a quota at which agents are
+	// warned, so they can clean up before new worktrees are refused.
+	diskQuotaWarnPercent = 90
+)
+
+// errDiskFull is wrapped by checkDiskRoom's refusals.
+var errDiskFull = errors.New("not enough disk space")
+
+// diskUsageState is the worker's latest disk measurement and the org's
+// quota. The zero value is ready to use.
+type diskUsageState struct {
+	mu    sync.Mutex
+	quota *leapmuxv1.WorkerDiskQuota
+	// usage is the latest measurement without free space or warnings,
+	// which are filled in per report. Nil until the first one finishes.
+	usage       *leapmuxv1.WorkerDiskUsage
+	byPath      map[string]uint64
+	byWorkspace map[string]uint64
+	// warned holds the keys of the conditions agents were last told
+	// about, so each is posted once per episode rather than per report.
+	warned map[string]bool
+}
+
+// diskCondition is one limit the worker is near or past.
+type diskCondition struct {
+	key         string
+	scope       string // "disk", "worker", or "workspace"
+	workspaceID string
+	used, limit uint64
+	free        uint64
+}
+
+func (c diskCondition) String() string {
+	switch c.scope {
+	case "disk":
+		return fmt.Sprintf("Only %s free on the worker's disk", formatDiskBytes(c.free))
+	case "workspace":
+		return fmt.Sprintf("Workspace %s uses %s of its %s quota", c.workspaceID, formatDiskBytes(c.used), formatDiskBytes(c.limit))
+	default:
+		return fmt.Sprintf("Worktrees and checkouts use %s of the worker's %s quota", formatDiskBytes(c.used), formatDiskBytes(c.limit))
+	}
+}
+
+// SetDiskQuota adopts the org's disk quota and reports against it right
+// away, so a lowered quota warns without waiting for the next measurement.
+func (svc *Service) SetDiskQuota(q *leapmuxv1.WorkerDiskQuota) {
+	svc.disk.mu.Lock()
+	svc.disk.quota = q
+	svc.disk.mu.Unlock()
+	// Off the connect loop: a report posts notifications to the database.
+	go svc.reportDiskUsage()
+}
+
+// StartDiskUsageLoop measures disk usage at startup and every
+// diskUsageInterval after.
+func (svc *Service) StartDiskUsageLoop(ctx context.Context) {
+	periodic.Start(ctx, periodic.Schedule{Interval: diskUsageInterval}, svc.MeasureDiskUsage)
+}
+
+// MeasureDiskUsage measures every tracked worktree and registered-repository
+// checkout, then reports the result to the Hub and warns agents about any
+// limit newly approached.
+func (svc *Service) MeasureDiskUsage(ctx context.Context) {
+	dirs, err := svc.diskTrackedDirs(ctx)
+	if err != nil {
+		slog.Warn("failed to list directories for disk usage", "error", err)
+		return
+	}
+	byPath := make(map[string]uint64, len(dirs))
+	byWorkspace := map[string]uint64{}
+	var used uint64
+	for path, workspaces := range dirs {
+		n, err := dirSize(ctx, path)
+		if ctx.Err() != nil {
+			return
+		}
+		if err != nil {
+			slog.Debug("failed to measure directory", "path", path, "error", err)
+			continue
+		}
+		byPath[path] = n
+		// git reports worktrees by their resolved path.
+		if real, err := filepath.EvalSymlinks(path); err == nil {
+			byPath[real] = n
+		}
+		used += n
+		for _, ws := range workspaces {
+			byWorkspace[ws] += n
+		}
+	}
+
+	workspaces := make([]*leapmuxv1.WorkspaceDiskUsage, 0, len(byWorkspace))
+	for ws, n := range byWorkspace {
+		workspaces = append(workspaces, &leapmuxv1.WorkspaceDiskUsage{WorkspaceId: ws, UsedBytes: n})
+	}
+	slices.SortFunc(workspaces, func(a, b *leapmuxv1.WorkspaceDiskUsage) int {
+		return cmp.Or(cmp.Compare(b.GetUsedBytes(), a.GetUsedBytes()), strings.Compare(a.GetWorkspaceId(), b.GetWorkspaceId()))
+	})
+
+	svc.disk.mu.Lock()
+	svc.disk.usage = &leapmuxv1.WorkerDiskUsage{
+		MeasuredAt: timefmt.Format(time.Now()),
+		UsedBytes:  used,
+		Workspaces: workspaces,
+	}
+	svc.disk.byPath = byPath
+	svc.disk.byWorkspace = byWorkspace
+	svc.disk.mu.Unlock()
+	svc.reportDiskUsage()
+}
+
+// reportDiskUsage sends the latest measurement to the Hub with the current
+// free space and warnings, and posts a disk_space_low notification to the
+// affected agents for each condition that was not already warned about.
+func (svc *Service) reportDiskUsage() {
+	free, total, spaceErr := diskSpace(svc.repoCheckoutBase())
+
+	svc.disk.mu.Lock()
+	if svc.disk.usage == nil {
+		svc.disk.mu.Unlock()
+		return
+	}
+	report := &leapmuxv1.WorkerDiskUsage{
+		MeasuredAt: svc.disk.usage.GetMeasuredAt(),
+		UsedBytes:  svc.disk.usage.GetUsedBytes(),
+		Workspaces: svc.disk.usage.GetWorkspaces(),
+	}
+	if spaceErr == nil {
+		report.FreeBytes, report.TotalBytes = free, total
+	}
+	conds := diskConditions(svc.disk.quota, report, spaceErr == nil)
+	warned := make(map[string]bool, len(conds))
+	var fresh []diskCondition
+	for _, c := range conds {
+		warned[c.key] = true
+		if !svc.disk.warned[c.key] {
+			fresh = append(fresh, c)
+		}
+		report.Warnings = append(report.Warnings, c.String())
+	}
+	svc.disk.warned = warned
+	svc.disk.mu.Unlock()
+
+	if err := svc.Send(&leapmuxv1.ConnectRequest{
+		Payload: &leapmuxv1.ConnectRequest_DiskUsage{DiskUsage: report},
+	}); err != nil {
+		slog.Debug("failed to send disk usage", "error", err)
+	}
+	for _, c := range fresh {
+		svc.notifyDiskSpaceLow(c)
+	}
+}
+
+// diskConditions lists the limits report is near or past under q.
+func diskConditions(q *leapmuxv1.WorkerDiskQuota, report *leapmuxv1.WorkerDiskUsage, freeKnown bool) []diskCondition {
+	var conds []diskCondition
+	if freeKnown && report.GetFreeBytes() < minFreeBytes(q) {
+		conds = append(conds, diskCondition{key: "disk", scope: "disk", free: report.GetFreeBytes()})
+	}
+	if limit := q.GetWorkerBytes(); nearQuota(report.GetUsedBytes(), limit) {
+		conds = append(conds, diskCondition{key: "worker", scope: "worker", used: report.GetUsedBytes(), limit: limit})
+	}
+	if limit := q.GetWorkspaceBytes(); limit > 0 {
+		for _, ws := range report.GetWorkspaces() {
+			if !nearQuota(ws.GetUsedBytes(), limit) {
+				// Largest first, so no later workspace is near it either.
+				break
+			}
+			conds = append(conds, diskCondition{
+				key: "workspace:" + ws.GetWorkspaceId(), scope: "workspace",
+				workspaceID: ws.GetWorkspaceId(), used: ws.GetUsedBytes(), limit: limit,
+			})
+		}
+	}
+	return conds
+}
+
+// notifyDiskSpaceLow posts c to every running agent it affects: those in
+// c's workspace, or all of them for a worker-wide condition.
+func (svc *Service) notifyDiskSpaceLow(c diskCondition) {
+	ctx := bgCtx()
+	var ids []string
+	var err error
+	if c.scope == "workspace" {
+		ids, err = svc.Queries.ListOpenAgentIDsByWorkspaceID(ctx, c.workspaceID)
+	} else {
+		ids, err = svc.Queries.ListAllOpenAgentIDs(ctx)
+	}
+	if err != nil {
+		slog.Warn("failed to list agents for disk warning", "scope", c.scope, "error", err)
+		return
+	}
+	payload := agent.DiskSpaceLowPayload{Scope: c.scope}
+	if c.scope == "disk" {
+		payload.FreeBytes = int64(c.free)
+	} else {
+		payload.UsedBytes = int64(c.used)
+		payload.LimitBytes = int64(c.limit)
+	}
+	notification := agent.NotificationContent(payload)
+	for _, id := range ids {
+		if !svc.Agents.HasAgent(id) {
+			continue
+		}
+		dbAgent, err := svc.Queries.GetAgentByID(ctx, id)
+		if err != nil {
+			continue
+		}
+		svc.Output.PersistLeapMuxNotification(id, dbAgent.AgentProvider, notification)
+	}
+}
+
+// checkDiskRoom refuses a new worktree or checkout for workspaceID when the
+// disk is below its free-space floor or a quota is used up. Free space is
+// read fresh; quota usage comes from the latest measurement.
+func (svc *Service) checkDiskRoom(workspaceID string) error {
+	svc.disk.mu.Lock()
+	q := svc.disk.quota
+	used := svc.disk.usage.GetUsedBytes()
+	wsUsed := svc.disk.byWorkspace[workspaceID]
+	svc.disk.mu.Unlock()
+
+	if free, _, err := diskSpace(svc.repoCheckoutBase()); err == nil && free < minFreeBytes(q) {
+		return errcode.Wrap(errcode.DiskQuotaExceeded, fmt.Errorf("%w: only %s free on the worker's disk", errDiskFull, formatDiskBytes(free)))
+	}
+	if limit := q.GetWorkerBytes(); limit > 0 && used >= limit {
+		return errcode.Wrap(errcode.DiskQuotaExceeded, fmt.Errorf("%w: worktrees and checkouts use %s of the worker's %s quota", errDiskFull, formatDiskBytes(used), formatDiskBytes(limit)))
+	}
+	if limit := q.GetWorkspaceBytes(); limit > 0 && wsUsed >= limit {
+		return errcode.Wrap(errcode.DiskQuotaExceeded, fmt.Errorf("%w: this workspace uses %s of its %s quota", errDiskFull, formatDiskBytes(wsUsed), formatDiskBytes(limit)))
+	}
+	return nil
+}
+
+// diskUsageForPath returns the measured size of the tracked directory at
+// path, if the latest measurement covered it.
+func (svc *Service) diskUsageForPath(path string) (uint64, bool) {
+	svc.disk.mu.Lock()
+	defer svc.disk.mu.Unlock()
+	n, ok := svc.disk.byPath[filepath.Clean(path)]
+	return n, ok
+}
+
+// diskTrackedDirs returns each directory disk usage covers with the
+// workspaces it counts toward: every live worktree, and every checkout
+// under <base>/leapmux-checkouts/<workspace>/. A checkout's
+// "<name>-worktrees" sibling is skipped, since its worktrees are tracked
+// rows of their own.
+func (svc *Service) diskTrackedDirs(ctx context.Context) (map[string][]string, error) {
+	rows, err := svc.Queries.ListLiveWorktreeWorkspaces(ctx)
+	if err != nil {
+		return nil, err
+	}
+	dirs := map[string][]string{}
+	for _, r := range rows {
+		path := filepath.Clean(r.WorktreePath)
+		workspaces := dirs[path]
+		if r.WorkspaceID != "" {
+			workspaces = append(workspaces, r.WorkspaceID)
+		}
+		dirs[path] = workspaces
+	}
+
+	base := svc.repoCheckoutBase()
+	if base == "" {
+		return dirs, nil
+	}
+	root := filepath.Join(base, repoCheckoutsDirName)
+	workspaceDirs, err := os.ReadDir(root)
+	if err != nil && !errors.Is(err, fs.ErrNotExist) {
+		return nil, err
+	}
+	for _, ws := range workspaceDirs {
+		if !ws.IsDir() {
+			continue
+		}
+		checkouts, err := os.ReadDir(filepath.Join(root, ws.Name()))
+		if err != nil {
+			continue
+		}
+		for _, c := range checkouts {
+			if !c.IsDir() || strings.HasSuffix(c.Name(), "-worktrees") {
+				continue
+			}
+			path := filepath.Join(root, ws.Name(), c.Name())
+			if !slices.Contains(dirs[path], ws.Name()) {
+				dirs[path] = append(dirs[path], ws.Name())
+			}
+		}
+	}
+	return dirs, nil
+}
+
+// dirSize sums the sizes of the regular files under dir without following
+// symlinks. A missing dir is empty.
+func dirSize(ctx context.Context, dir string) (uint64, error) {
+	var total uint64
+	err := filepath.WalkDir(dir, func(_ string, d fs.DirEntry, err error) error {
+		if err != nil {
+			if errors.Is(err, fs.ErrNotExist) {
+				return nil
+			}
+			// An unreadable subdirectory is skipped, not fatal.
+			if d != nil && d.IsDir() {
+				return fs.SkipDir
+			}
+			return nil
+		}
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+		if !d.Type().IsRegular() {
+			return nil
+		}
+		if info, err := d.Info(); err == nil {
+			total += uint64(info.Size())
+		}
+		return nil
+	})
+	return total, err
+}
+
+func minFreeBytes(q *leapmuxv1.WorkerDiskQuota) uint64 {
+	if n := q.GetMinFreeBytes(); n > 0 {
+		return n
+	}
+	return defaultMinFreeBytes
+}
+
+// nearQuota reports whether used is at least diskQuotaWarnPercent of a
+// non-zero limit.
+func nearQuota(used, limit uint64) bool {
+	return limit > 0 && used >= limit/100*diskQuotaWarnPercent
+}
+
+// formatDiskBytes renders n in binary units with one decimal, e.g. "1.5 GiB".
+func formatDiskBytes(n uint64) string {
+	const unit = 1024
+	if n < unit {
+		return fmt.Sprintf("%d B", n)
+	}
+	div, exp := uint64(unit), 0
+	for m := n / unit; m >= unit && exp < 4; m /= unit {
+		div *= unit
+		exp++
+	}
+	return fmt.Sprintf("%.1f %ciB", float64(n)/float64(div), "KMGTP"[exp])
+}
@@ -0,0 +1,112 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	leapmuxv1 "github.com/leapmux/leapmux/generated/proto/leapmux/v1"
+	"github.com/leapmux/leapmux/internal/errcode"
+	db "github.com/leapmux/leapmux/internal/worker/generated/db"
+)
+
+func writeSizedFile(t *testing.T, path string, size int) {
+	t.Helper()
+	require.NoError(t, os.MkdirAll(filepath.Dir(path), 0o755))
+	require.NoError(t, os.WriteFile(path, make([]byte, size), 0o644))
+}
+
+func TestMeasureDiskUsage(t *testing.T) {
+	svc, _, _ := setupTestService(t)
+	reports := make(chan *leapmuxv1.WorkerDiskUsage, 4)
+	svc.Send = func(msg *leapmuxv1.ConnectRequest) error {
+		if u := msg.GetDiskUsage(); u != nil {
+			reports <- u
+		}
+		return nil
+	}
+
+	checkouts := filepath.Join(svc.HomeDir, repoCheckoutsDirName)
+	writeSizedFile(t, filepath.Join(checkouts, "ws-1", "app", "a.bin"), 3000)
+	writeSizedFile(t, filepath.Join(checkouts, "ws-1", "app", "sub", "b.bin"), 1000)
+	writeSizedFile(t, filepath.Join(checkouts, "ws-2", "app", "a.bin"), 500)
+	// A checkout's worktrees are counted through their own rows.
+	writeSizedFile(t, filepath.Join(checkouts, "ws-1", "app-worktrees", "stray.bin"), 9000)
+
+	worktree := filepath.Join(t.TempDir(), "wt")
+	writeSizedFile(t, filepath.Join(worktree, "c.bin"), 200)
+	require.NoError(t, svc.Queries.CreateWorktree(context.Background(), db.CreateWorktreeParams{
+		ID: "wt-1", WorktreePath: worktree, RepoRoot: "/repo", BranchName: "feature",
+	}))
+
+	svc.MeasureDiskUsage(context.Background())
+
+	require.Len(t, reports, 1)
+	report := <-reports
+	assert.EqualValues(t, 4700, report.GetUsedBytes())
+	require.Len(t, report.GetWorkspaces(), 2)
+	assert.Equal(t, "ws-1", report.GetWorkspaces()[0].GetWorkspaceId(), "largest first")
+	assert.EqualValues(t, 4000, report.GetWorkspaces()[0].GetUsedBytes())
+	assert.EqualValues(t, 500, report.GetWorkspaces()[1].GetUsedBytes())
+	assert.NotEmpty(t, report.GetMeasuredAt())
+
+	n, ok := svc.diskUsageForPath(worktree)
+	require.True(t, ok)
+	assert.EqualValues(t, 200, n)
+}
+
+func TestCheckDiskRoom(t *testing.T) {
+	svc, _, _ := setupTestService(t)
+	writeSizedFile(t, filepath.Join(svc.HomeDir, repoCheckoutsDirName, "ws-1", "app", "a.bin"), 4096)
+	svc.MeasureDiskUsage(context.Background())
+
+	require.NoError(t, svc.checkDiskRoom("ws-1"), "no quota")
+
+	svc.SetDiskQuota(&leapmuxv1.WorkerDiskQuota{WorkspaceBytes: 4096})
+	err := svc.checkDiskRoom("ws-1")
+	assert.True(t, errors.Is(err, errDiskFull), "workspace at its quota: %v", err)
+	assert.Equal(t, errcode.DiskQuotaExceeded, errcode.Of(err))
+	assert.NoError(t, svc.checkDiskRoom("ws-2"), "another workspace has room")
+
+	svc.SetDiskQuota(&leapmuxv1.WorkerDiskQuota{WorkerBytes: 4096})
+	assert.True(t, errors.Is(svc.checkDiskRoom("ws-2"), errDiskFull), "worker at its quota")
+
+	svc.SetDiskQuota(&leapmuxv1.WorkerDiskQuota{MinFreeBytes: 1 << 62})
+	assert.True(t, errors.Is(svc.checkDiskRoom("ws-2"), errDiskFull), "below the free-space floor")
+}
+
+func TestDiskConditions(t *testing.T) {
+	usage := &leapmuxv1.WorkerDiskUsage{
+		FreeBytes: 10 << 30,
+		UsedBytes: 950,
+		Workspaces: []*leapmuxv1.WorkspaceDiskUsage{
+			{WorkspaceId: "ws-big", UsedBytes: 900},
+			{WorkspaceId: "ws-small", UsedBytes: 50},
+		},
+	}
+	keys := func(conds []diskCondition) []string {
+		var out []string
+		for _, c := range conds {
+			out = append(out, c.key)
+		}
+		return out
+	}
+
+	assert.Empty(t, diskConditions(nil, usage, true), "no quota, plenty free")
+	assert.Equal(t, []string{"worker", "workspace:ws-big"},
+		keys(diskConditions(&leapmuxv1.WorkerDiskQuota{WorkspaceBytes: 1000, WorkerBytes: 1000}, usage, true)))
+	assert.Empty(t, diskConditions(&leapmuxv1.WorkerDiskQuota{WorkerBytes: 2000}, usage, true), "under 90%")
+	assert.Equal(t, []string{"disk"}, keys(diskConditions(&leapmuxv1.WorkerDiskQuota{MinFreeBytes: 20 << 30}, usage, true)))
+	assert.Empty(t, diskConditions(&leapmuxv1.WorkerDiskQuota{MinFreeBytes: 20 << 30}, usage, false), "free space unknown")
+}
+
+func TestFormatDiskBytes(t *testing.T) {
+	assert.Equal(t, "512 B", formatDiskBytes(512))
+	assert.Equal(t, "1.5 KiB", formatDiskBytes(1536))
+	assert.Equal(t, "2.0 GiB", formatDiskBytes(2<<30))
+}
@@ -0,0 +1,64 @@
+package service
+
+import (
+	"errors"
+	"fmt"
+	"log/slog"
+
+	leapmuxv1 "github.com/leapmux/leapmux/generated/proto/leapmux/v1"
+	"github.com/leapmux/leapmux/internal/util/userid"
+	"github.com/leapmux/leapmux/internal/worker/agent"
+)
+
+// SetEmergencyStop adopts the emergency stop an admin put on the org or on
+// this worker. Turning one on interrupts every agent mid-turn and tells it
+// why; until it is released, sendAgentInput refuses every turn.
+func (svc *Service) SetEmergencyStop(st *leapmuxv1.EmergencyStopState) {
+	if !st.GetActive() {
+		if svc.emergencyStop.Swap(nil) != nil {
+			slog.Warn("emergency stop released")
+		}
+		return
+	}
+	svc.emergencyStop.Store(st)
+	slog.Warn("emergency stop in effect", "reason", st.GetReason(), "stopped_by", st.GetStoppedBy())
+	// Off the connect loop: interrupting and notifying touch every agent.
+	go svc.interruptActiveAgents(st)
+}
+
+// emergencyStopErr is the error a turn fails with while an emergency stop
+// is in effect; nil otherwise.
+func (svc *Service) emergencyStopErr() error {
+	st := svc.emergencyStop.Load()
+	if st == nil {
+		return nil
+	}
+	if st.GetReason() == "" {
+		return errors.New("emergency stop in effect")
+	}
+	return fmt.Errorf("emergency stop in effect: %s", st.GetReason())
+}
+
+// interruptActiveAgents posts the stop in the chat of every agent mid-turn
+// and interrupts it. Each interrupt runs on its own goroutine, since one
+// waits for the agent to answer.
+func (svc *Service) interruptActiveAgents(st *leapmuxv1.EmergencyStopState) {
+	for _, agentID := range svc.Agents.ListAgentIDs() {
+		if _, idle := svc.Output.agentIdleFor(agentID); idle {
+			continue
+		}
+		dbAgent, err := svc.Queries.GetAgentByID(bgCtx(), agentID)
+		if err != nil {
+			slog.Warn("emergency stop: failed to load agent", "agent_id", agentID, "error", err)
+		} else {
+			svc.Output.PersistLeapMuxNotification(agentID, dbAgent.AgentProvider, agent.NotificationContent(agent.EmergencyStopPayload{
+				Reason: st.GetReason(),
+			}))
+		}
+		go func() {
+			if err := svc.interruptAgent(agentID, userid.UserID{}, leapmuxv1.InterruptReason_INTERRUPT_REASON_POLICY, "emergency stop: "+st.GetReason()); err != nil {
+				slog.Warn("emergency stop: failed to interrupt agent", "agent_id", agentID, "error", err)
+			}
+		}()
+	}
+}
@@ -0,0 +1,53 @@
+package service
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	leapmuxv1 "github.com/leapmux/leapmux/generated/proto/leapmux/v1"
+	"github.com/leapmux/leapmux/internal/worker/agent"
+	db "github.com/leapmux/leapmux/internal/worker/generated/db"
+)
+
+func TestSetEmergencyStop_InterruptsAndRefusesTurns(t *testing.T) {
+	svc, _, _ := setupTestService(t, withWorkspaces("ws-1"))
+	startIdleParkAgent(t, svc)
+	svc.Output.beginAgentTurn("agent-1")
+
+	svc.SetEmergencyStop(&leapmuxv1.EmergencyStopState{Active: true, Reason: "runaway spend"})
+	require.Eventually(t, func() bool {
+		return len(findNotificationsByType(readAllNotifications(t, svc.Queries, "agent-1"), agent.NotificationTypeEmergencyStop)) == 1
+	}, 5*time.Second, 20*time.Millisecond, "the agent mid-turn is interrupted and told why")
+	notes := findNotificationsByType(readAllNotifications(t, svc.Queries, "agent-1"), agent.NotificationTypeEmergencyStop)
+	assert.Equal(t, "runaway spend", notes[0]["reason"])
+
+	err := svc.sendAgentInput("agent-1", "hello", nil)
+	require.EqualError(t, err, "emergency stop in effect: runaway spend")
+
+	svc.SetEmergencyStop(&leapmuxv1.EmergencyStopState{})
+	assert.NoError(t, svc.sendAgentInput("agent-1", "hello", nil))
+}
+
+func TestSendAgentMessage_RefusedDuringEmergencyStop(t *testing.T) {
+	svc, d, w := setupTestService(t, withWorkspaces("ws-1"))
+	row := seedGuardedAgent(t, svc, "default")
+	svc.startAgentFn = mockAgentStarter(t, svc, func(agent.Options) { t.Error("a refused turn must not start the agent") })
+	svc.SetEmergencyStop(&leapmuxv1.EmergencyStopState{Active: true})
+
+	dispatch(d, "SendAgentMessage", &leapmuxv1.SendAgentMessageRequest{AgentId: row.ID, Content: "hello"}, w)
+	require.Empty(t, w.errors)
+
+	msgs, err := svc.Queries.ListAllMessagesByAgentID(context.Background(), db.ListAllMessagesByAgentIDParams{AgentID: row.ID})
+	require.NoError(t, err)
+	var deliveryError string
+	for _, m := range msgs {
+		if m.Source == leapmuxv1.MessageSource_MESSAGE_SOURCE_USER {
+			deliveryError = m.DeliveryError
+		}
+	}
+	assert.Equal(t, "emergency stop in effect", deliveryError)
+}
@@ -0,0 +1,189 @@
+package service
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"log/slog"
+	"strings"
+
+	leapmuxv1 "github.com/leapmux/leapmux/generated/proto/leapmux/v1"
+	"github.com/leapmux/leapmux/internal/util/userid"
+	"github.com/leapmux/leapmux/internal/worker/agent"
+	"github.com/leapmux/leapmux/internal/worker/channel"
+	db "github.com/leapmux/leapmux/internal/worker/generated/db"
+	"github.com/leapmux/leapmux/util/validate"
+	"google.golang.org/protobuf/encoding/protojson"
+)
+
+// envSessionInfoKey is the agent_session_info key reporting the merged
+// environment an agent was started with.
+const envSessionInfoKey = "env"
+
+// validateEnvVars checks one layer of variables. The Hub checks the org
+// layer the same way.
+func validateEnvVars(vars []*leapmuxv1.EnvVar) error {
+	if len(vars) > validate.MaxEnvVars {
+		return fmt.Errorf("at most %d environment variables", validate.MaxEnvVars)
+	}
+	seen := make(map[string]bool, len(vars))
+	for _, v := range vars {
+		if err := validate.ValidateEnvVar(v.GetName(), v.GetValue()); err != nil {
+			return err
+		}
+		if seen[v.GetName()] {
+			return fmt.Errorf("duplicate environment variable %s", v.GetName())
+		}
+		seen[v.GetName()] = true
+	}
+	return nil
+}
+
+// mergeEnv merges layers by name, each overriding the ones before it, into
+// NAME=value pairs. A name keeps the position it first appeared at.
+func mergeEnv(layers ...[]*leapmuxv1.EnvVar) []string {
+	index := make(map[string]int)
+	var out []string
+	for _, layer := range layers {
+		for _, v := range layer {
+			pair := v.GetName() + "=" + v.GetValue()
+			if i, ok := index[v.GetName()]; ok {
+				out[i] = pair
+				continue
+			}
+			index[v.GetName()] = len(out)
+			out = append(out, pair)
+		}
+	}
+	return out
+}
+
+// decodeWorkspaceEnv decodes a stored WorkspaceEnv.
+func decodeWorkspaceEnv(raw string) (*leapmuxv1.WorkspaceEnv, error) {
+	env := &leapmuxv1.WorkspaceEnv{}
+	if err := protojson.Unmarshal([]byte(raw), env); err != nil {
+		return nil, fmt.Errorf("decode env: %w", err)
+	}
+	return env, nil
+}
+
+// loadWorkspaceEnv reads the workspace's stored variables. A workspace with
+// no row yields an empty env, not an error.
+func loadWorkspaceEnv(ctx context.Context, queries *db.Queries, workspaceID string) (*leapmuxv1.WorkspaceEnv, error) {
+	raw, err := queries.GetWorkspaceEnv(ctx, workspaceID)
+	if errors.Is(err, sql.ErrNoRows) {
+		return &leapmuxv1.WorkspaceEnv{}, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return decodeWorkspaceEnv(raw)
+}
+
+// workspaceEnvVars reads the workspace layer for a launch. A failed read
+// leaves it out rather than failing the launch.
+func (svc *Service) workspaceEnvVars(workspaceID string) []*leapmuxv1.EnvVar {
+	env, err := loadWorkspaceEnv(bgCtx(), svc.Queries, workspaceID)
+	if err != nil {
+		slog.Warn("workspace env load failed; skipping it", "workspace_id", workspaceID, "error", err)
+		return nil
+	}
+	return env.GetVars()
+}
+
+// agentEnv returns the org's, the workspace's and the agent's own variables
+// merged. Like agentSystemPrompt, a failed read launches without the layer.
+func (svc *Service) agentEnv(agentID string) []string {
+	dbAgent, err := svc.getAgentByID(bgCtx(), agentID)
+	if err != nil {
+		slog.Warn("failed to read agent for its env", "agent_id", agentID, "error", err)
+		return mergeEnv(svc.orgDefaults.Load().GetEnv())
+	}
+	var own []*leapmuxv1.EnvVar
+	raw, err := svc.Queries.GetAgentEnv(bgCtx(), agentID)
+	switch {
+	case err == nil:
+		if env, decodeErr := decodeWorkspaceEnv(raw); decodeErr == nil {
+			own = env.GetVars()
+		} else {
+			slog.Warn("failed to decode agent env", "agent_id", agentID, "error", decodeErr)
+		}
+	case !errors.Is(err, sql.ErrNoRows):
+		slog.Warn("failed to read agent env", "agent_id", agentID, "error", err)
+	}
+	return mergeEnv(svc.orgDefaults.Load().GetEnv(), svc.workspaceEnvVars(dbAgent.WorkspaceID), own)
+}
+
+// storeAgentEnv keeps the variables an agent was opened with.
+func (svc *Service) storeAgentEnv(ctx context.Context, agentID string, vars []*leapmuxv1.EnvVar) error {
+	if len(vars) == 0 {
+		return nil
+	}
+	raw, err := protojson.Marshal(&leapmuxv1.WorkspaceEnv{Vars: vars})
+	if err != nil {
+		return err
+	}
+	return svc.Queries.CreateAgentEnv(ctx, db.CreateAgentEnvParams{AgentID: agentID, Env: string(raw)})
+}
+
+// broadcastAgentEnv reports the environment a started agent runs with, so
+// clients can show which variables it received.
+func broadcastAgentEnv(sink agent.OutputSink, env []string) {
+	if sink == nil || len(env) == 0 {
+		return
+	}
+	vars := make(map[string]string, len(env))
+	for _, pair := range env {
+		name, value, _ := strings.Cut(pair, "=")
+		vars[name] = value
+	}
+	sink.BroadcastSessionInfo(map[string]interface{}{envSessionInfoKey: vars})
+}
+
+// registerEnvVarsHandlers registers the per-workspace environment RPCs.
+func registerEnvVarsHandlers(d registrar, svc *Service) {
+	registerWorkspaceGated(d, "GetWorkspaceEnv",
+		func(ctx context.Context, _ userid.UserID, r *leapmuxv1.GetWorkspaceEnvRequest, sender channel.ResponseWriter) {
+			env, err := loadWorkspaceEnv(ctx, svc.Queries, r.GetWorkspaceId())
+			if err != nil {
+				slog.Error("failed to load workspace env", "workspace_id", r.GetWorkspaceId(), "error", err)
+				sendInternalError(sender, "failed to load workspace env")
+				return
+			}
+			sendProtoResponse(sender, &leapmuxv1.GetWorkspaceEnvResponse{Env: env})
+		})
+
+	registerWorkspaceGated(d, "SetWorkspaceEnv",
+		func(_ context.Context, _ userid.UserID, r *leapmuxv1.SetWorkspaceEnvRequest, sender channel.ResponseWriter) {
+			env := r.GetEnv()
+			if env == nil {
+				env = &leapmuxv1.WorkspaceEnv{}
+			}
+			if err := validateEnvVars(env.GetVars()); err != nil {
+				sendInvalidArgument(sender, err.Error())
+				return
+			}
+
+			// An empty env adds nothing; drop the row rather than storing it.
+			var err error
+			if len(env.GetVars()) == 0 {
+				err = svc.Queries.DeleteWorkspaceEnv(bgCtx(), r.GetWorkspaceId())
+			} else {
+				var raw []byte
+				raw, err = protojson.Marshal(env)
+				if err == nil {
+					err = svc.Queries.UpsertWorkspaceEnv(bgCtx(), db.UpsertWorkspaceEnvParams{
+						WorkspaceID: r.GetWorkspaceId(),
+						Env:         string(raw),
+					})
+				}
+			}
+			if err != nil {
+				slog.Error("failed to save workspace env", "workspace_id", r.GetWorkspaceId(), "error", err)
+				sendInternalError(sender, "failed to save workspace env")
+				return
+			}
+			sendProtoResponse(sender, &leapmuxv1.SetWorkspaceEnvResponse{Env: env})
+		})
+}
@@ -0,0 +1,106 @@
+package service
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/protobuf/proto"
+
+	leapmuxv1 "github.com/leapmux/leapmux/generated/proto/leapmux/v1"
+	"github.com/leapmux/leapmux/internal/worker/agent"
+	"github.com/leapmux/leapmux/internal/worker/terminal"
+)
+
+func TestMergeEnv_LaterLayersOverride(t *testing.T) {
+	got := mergeEnv(
+		[]*leapmuxv1.EnvVar{{Name: "A", Value: "org"}, {Name: "B", Value: "org"}},
+		[]*leapmuxv1.EnvVar{{Name: "B", Value: "ws"}, {Name: "C", Value: "ws"}},
+		[]*leapmuxv1.EnvVar{{Name: "A", Value: "agent"}},
+	)
+	assert.Equal(t, []string{"A=agent", "B=ws", "C=ws"}, got)
+}
+
+func TestWorkspaceEnv_SetAndGet(t *testing.T) {
+	_, d, w := setupTestService(t, withWorkspaces("ws-1"))
+
+	env := &leapmuxv1.WorkspaceEnv{Vars: []*leapmuxv1.EnvVar{{Name: "NODE_ENV", Value: "development"}}}
+	dispatch(d, "SetWorkspaceEnv", &leapmuxv1.SetWorkspaceEnvRequest{WorkspaceId: "ws-1", Env: env}, w)
+	dispatch(d, "GetWorkspaceEnv", &leapmuxv1.GetWorkspaceEnvRequest{WorkspaceId: "ws-1"}, w)
+	require.Empty(t, w.errors)
+	require.Len(t, w.responses, 2)
+	var resp leapmuxv1.GetWorkspaceEnvResponse
+	require.NoError(t, proto.Unmarshal(w.responses[1].GetPayload(), &resp))
+	assert.True(t, proto.Equal(env, resp.GetEnv()))
+
+	// An empty env clears the stored one.
+	dispatch(d, "SetWorkspaceEnv", &leapmuxv1.SetWorkspaceEnvRequest{WorkspaceId: "ws-1"}, w)
+	dispatch(d, "GetWorkspaceEnv", &leapmuxv1.GetWorkspaceEnvRequest{WorkspaceId: "ws-1"}, w)
+	require.Empty(t, w.errors)
+	require.Len(t, w.responses, 4)
+	require.NoError(t, proto.Unmarshal(w.responses[3].GetPayload(), &resp))
+	assert.Empty(t, resp.GetEnv().GetVars())
+}
+
+func TestWorkspaceEnv_RejectsInvalidVars(t *testing.T) {
+	for name, vars := range map[string][]*leapmuxv1.EnvVar{
+		"reserved":  {{Name: "LEAPMUX_WORKER_ID", Value: "x"}},
+		"bad name":  {{Name: "1X", Value: "x"}},
+		"duplicate": {{Name: "X", Value: "1"}, {Name: "X", Value: "2"}},
+	} {
+		t.Run(name, func(t *testing.T) {
+			_, d, w := setupTestService(t, withWorkspaces("ws-1"))
+			dispatch(d, "SetWorkspaceEnv", &leapmuxv1.SetWorkspaceEnvRequest{
+				WorkspaceId: "ws-1",
+				Env:         &leapmuxv1.WorkspaceEnv{Vars: vars},
+			}, w)
+			require.Len(t, w.errors, 1)
+			assert.Equal(t, codeInvalidArgument, w.errors[0].code)
+		})
+	}
+}
+
+func TestOpenAgent_MergesEnvLayers(t *testing.T) {
+	svc, d, w := setupTestService(t, withWorkspaces("ws-1"))
+	svc.startAgentFn = func(context.Context, agent.Options, agent.OutputSink) (map[string]string, error) {
+		return map[string]string{}, nil
+	}
+	svc.SetOrgDefaults(&leapmuxv1.OrgDefaults{Env: []*leapmuxv1.EnvVar{
+		{Name: "REGION", Value: "org"}, {Name: "TIER", Value: "org"}, {Name: "DEBUG", Value: "0"},
+	}})
+	dispatch(d, "SetWorkspaceEnv", &leapmuxv1.SetWorkspaceEnvRequest{
+		WorkspaceId: "ws-1",
+		Env:         &leapmuxv1.WorkspaceEnv{Vars: []*leapmuxv1.EnvVar{{Name: "TIER", Value: "ws"}, {Name: "DEBUG", Value: "ws"}}},
+	}, w)
+
+	dispatch(d, "OpenAgent", &leapmuxv1.OpenAgentRequest{
+		WorkspaceId:   "ws-1",
+		WorkingDir:    t.TempDir(),
+		AgentProvider: claudeCode,
+		Env:           []*leapmuxv1.EnvVar{{Name: "DEBUG", Value: "1"}},
+	}, w)
+	require.Empty(t, w.errors)
+	require.Len(t, w.responses, 2)
+
+	var resp leapmuxv1.OpenAgentResponse
+	require.NoError(t, proto.Unmarshal(w.responses[1].GetPayload(), &resp))
+	assert.Equal(t, []string{"REGION=org", "TIER=ws", "DEBUG=1"}, svc.agentEnv(resp.GetAgent().GetId()))
+	opts := terminal.Options{WorkspaceID: "ws-1"}
+	svc.applyTerminalProfile(&opts, nil)
+	assert.Equal(t, []string{"REGION=org", "TIER=ws", "DEBUG=ws"}, opts.Env,
+		"terminals get the org and workspace layers only")
+}
+
+func TestOpenAgent_RejectsReservedEnv(t *testing.T) {
+	_, d, w := setupTestService(t, withWorkspaces("ws-1"))
+
+	dispatch(d, "OpenAgent", &leapmuxv1.OpenAgentRequest{
+		WorkspaceId:   "ws-1",
+		WorkingDir:    t.TempDir(),
+		AgentProvider: claudeCode,
+		Env:           []*leapmuxv1.EnvVar{{Name: "leapmux_token", Value: "x"}},
+	}, w)
+	require.Len(t, w.errors, 1)
+	assert.Equal(t, codeInvalidArgument, w.errors[0].code)
+}
@@ -0,0 +1,241 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"os"
+	"os/exec"
+	"sync"
+	"time"
+
+	leapmuxv1 "github.com/leapmux/leapmux/generated/proto/leapmux/v1"
+	"github.com/leapmux/leapmux/internal/tunnelflow"
+	"github.com/leapmux/leapmux/internal/util/pathutil"
+	"github.com/leapmux/leapmux/internal/util/userid"
+	"github.com/leapmux/leapmux/internal/worker/channel"
+	"github.com/leapmux/leapmux/util/procutil"
+	"github.com/leapmux/leapmux/util/validate"
+	"google.golang.org/protobuf/proto"
+)
+
+const (
+	// defaultExecTimeout applies when a request sets no timeout.
+	defaultExecTimeout = time.Minute
+	// maxExecTimeout bounds a requested timeout; ExecCommand is for quick
+	// checks, and a long job belongs in a terminal.
+	maxExecTimeout = 10 * time.Minute
+	// maxExecOutputBytes bounds the output one command streams back. It
+	// has no read credit, so the bound is what keeps a chatty command from
+	// flooding the shared channel.
+	maxExecOutputBytes = 1 << 20
+	// execWaitDelay is how long a killed command's leftover children may
+	// hold its output open before the pipes are closed on them.
+	execWaitDelay = 2 * time.Second
+)
+
+// execManager tracks the worker's running ExecCommand runs by client-chosen
+// id. Like downloadManager it is worker-lifetime and shared across channel
+// sessions.
+type execManager struct {
+	mu   sync.Mutex
+	runs map[string]context.CancelFunc
+	// canceled fences a CancelExec dispatched ahead of its ExecCommand, so
+	// the late start does not run a command the client already gave up on.
+	canceled cancelMarkers
+}
+
+func newExecManager() *execManager {
+	return &execManager{
+		runs:     make(map[string]context.CancelFunc),
+		canceled: newCancelMarkers(),
+	}
+}
+
+// register installs cancel under id, refusing an id that is live or was
+// cancelled before it started.
+func (m *execManager) register(id string, cancel context.CancelFunc) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.canceled.sweep(time.Now())
+	if m.canceled.take(id) {
+		return errors.New("exec_id was canceled")
+	}
+	if _, exists := m.runs[id]; exists {
+		return errors.New("exec_id is already in use")
+	}
+	m.runs[id] = cancel
+	return nil
+}
+
+func (m *execManager) remove(id string) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	delete(m.runs, id)
+}
+
+// cancel kills a running command, or fences one still to start.
+func (m *execManager) cancel(id string) {
+	m.mu.Lock()
+	now := time.Now()
+	m.canceled.sweep(now)
+	cancel := m.runs[id]
+	if cancel == nil {
+		m.canceled.mark(id, now.Add(staleCancelMarkerTTL))
+	}
+	m.mu.Unlock()
+	if cancel != nil {
+		cancel()
+	}
+}
+
+// execOutput streams one command's stdout and stderr as ExecOutputChunk
+// messages, within the run's output budget. It holds every chunk until
+// ready is closed, so none overtakes the ExecCommandResponse.
+type execOutput struct {
+	sender channel.ResponseWriter
+	ready  chan struct{}
+
+	mu        sync.Mutex
+	remaining int
+	truncated bool
+}
+
+// execStream is an io.Writer for one of a command's output streams.
+type execStream struct {
+	out    *execOutput
+	stderr bool
+}
+
+func (s execStream) Write(p []byte) (int, error) {
+	<-s.out.ready
+	s.out.mu.Lock()
+	defer s.out.mu.Unlock()
+	n := len(p)
+	if n > s.out.remaining {
+		p = p[:s.out.remaining]
+		s.out.truncated = true
+	}
+	s.out.remaining -= len(p)
+	for len(p) > 0 {
+		chunk := p[:min(len(p), tunnelflow.MaxChunkBytes)]
+		p = p[len(chunk):]
+		msg := &leapmuxv1.ExecOutputChunk{Stdout: chunk}
+		if s.stderr {
+			msg = &leapmuxv1.ExecOutputChunk{Stderr: chunk}
+		}
+		// A vanished client is not the command's failure: keep draining
+		// so it runs to its end or its timeout.
+		_ = sendExecChunk(s.out.sender, msg)
+	}
+	return n, nil
+}
+
+// registerExecHandlers registers ExecCommand and CancelExec. A command runs
+// as the worker's user, so like the file surface it is owner-only, and d must
+// also refuse the owner's workspace-scoped credentials: a delegation bearer
+// an agent holds is pinned to one workspace, and a shell is not.
+func registerExecHandlers(d ownerOnlyRegistrar, svc *Service) {
+	execs := newExecManager()
+	d.Register("ExecCommand", func(ctx context.Context, userID userid.UserID, req *leapmuxv1.InnerRpcRequest, sender channel.ResponseWriter) {
+		var r leapmuxv1.ExecCommandRequest
+		if err := unmarshalRequest(req, &r); err != nil {
+			sendInvalidArgument(sender, "invalid request")
+			return
+		}
+		svc.execCommand(ctx, execs, userID, &r, sender)
+	})
+	d.Register("CancelExec", func(_ context.Context, _ userid.UserID, req *leapmuxv1.InnerRpcRequest, sender channel.ResponseWriter) {
+		var r leapmuxv1.CancelExecRequest
+		if err := unmarshalRequest(req, &r); err != nil {
+			sendInvalidArgument(sender, "invalid request")
+			return
+		}
+		if r.GetExecId() == "" {
+			sendInvalidArgument(sender, "exec_id is required")
+			return
+		}
+		execs.cancel(r.GetExecId())
+		sendProtoResponse(sender, &leapmuxv1.CancelExecResponse{})
+	})
+}
+
+// execCommand starts r's command and streams its output. ctx is the
+// channel session's, so a client that goes away takes its command with it.
+func (svc *Service) execCommand(ctx context.Context, execs *execManager, userID userid.UserID, r *leapmuxv1.ExecCommandRequest, sender channel.ResponseWriter) {
+	id := r.GetExecId()
+	switch {
+	case id == "":
+		sendInvalidArgument(sender, "exec_id is required")
+		return
+	case len(r.GetArgv()) == 0 || r.GetArgv()[0] == "":
+		sendInvalidArgument(sender, "argv is required")
+		return
+	case r.GetTimeoutSeconds() < 0 || time.Duration(r.GetTimeoutSeconds())*time.Second > maxExecTimeout:
+		sendInvalidArgument(sender, "timeout_seconds must be between 0 and 600")
+		return
+	}
+	timeout := defaultExecTimeout
+	if r.GetTimeoutSeconds() > 0 {
+		timeout = time.Duration(r.GetTimeoutSeconds()) * time.Second
+	}
+	dir, err := validate.SanitizePath(r.GetWorkingDir(), svc.HomeDir)
+	if err != nil {
+		sendPermissionDenied(sender, "access denied")
+		return
+	}
+	dir = pathutil.Canonicalize(dir)
+	if info, err := os.Stat(dir); err != nil || !info.IsDir() {
+		sendNotFoundError(sender, "working directory not found")
+		return
+	}
+
+	runCtx, cancel := context.WithTimeout(ctx, timeout)
+	if err := execs.register(id, cancel); err != nil {
+		cancel()
+		sendInvalidArgument(sender, err.Error())
+		return
+	}
+	out := &execOutput{sender: sender, ready: make(chan struct{}), remaining: maxExecOutputBytes}
+	cmd := exec.CommandContext(runCtx, r.GetArgv()[0], r.GetArgv()[1:]...)
+	cmd.Dir = dir
+	cmd.Env = os.Environ()
+	cmd.Stdout = execStream{out: out}
+	cmd.Stderr = execStream{out: out, stderr: true}
+	cmd.WaitDelay = execWaitDelay
+	procutil.HideConsoleWindow(cmd)
+	if err := cmd.Start(); err != nil {
+		execs.remove(id)
+		cancel()
+		sendInvalidArgument(sender, "failed to start command: "+err.Error())
+		return
+	}
+
+	slog.Info("command executed", "user_id", userID, "exec_id", id, "dir", dir, "argv", r.GetArgv())
+	sendProtoResponse(sender, &leapmuxv1.ExecCommandResponse{ExecId: id, WorkingDir: dir})
+	close(out.ready)
+	go func() {
+		defer cancel()
+		defer execs.remove(id)
+		err := cmd.Wait()
+		done := &leapmuxv1.ExecOutputChunk{
+			Exited:   true,
+			ExitCode: int32(cmd.ProcessState.ExitCode()),
+			TimedOut: errors.Is(runCtx.Err(), context.DeadlineExceeded),
+		}
+		out.mu.Lock()
+		done.Truncated = out.truncated
+		out.mu.Unlock()
+		slog.Info("command exited", "exec_id", id, "exit_code", done.GetExitCode(), "timed_out", done.GetTimedOut(), "error", err)
+		_ = sendExecChunk(sender, done)
+	}()
+}
+
+// sendExecChunk sends one chunk as a stream message.
+func sendExecChunk(sender channel.ResponseWriter, chunk *leapmuxv1.ExecOutputChunk) error {
+	payload, err := proto.Marshal(chunk)
+	if err != nil {
+		return err
+	}
+	return sender.SendStream(&leapmuxv1.InnerStreamMessage{Payload: payload})
+}
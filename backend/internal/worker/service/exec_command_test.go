@@ -0,0 +1,149 @@
+package service
+
+import (
+	"runtime"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/protobuf/proto"
+
+	leapmuxv1 "github.com/leapmux/leapmux/generated/proto/leapmux/v1"
+)
+
+// execChunks decodes every chunk the writer has streamed so far.
+func execChunks(t *testing.T, w *testResponseWriter) []*leapmuxv1.ExecOutputChunk {
+	t.Helper()
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	chunks := make([]*leapmuxv1.ExecOutputChunk, 0, len(w.streams))
+	for _, msg := range w.streams {
+		var chunk leapmuxv1.ExecOutputChunk
+		require.NoError(t, proto.Unmarshal(msg.GetPayload(), &chunk))
+		chunks = append(chunks, &chunk)
+	}
+	return chunks
+}
+
+// execResult waits for the command to exit and returns its output and its
+// final chunk.
+func execResult(t *testing.T, w *testResponseWriter) (string, string, *leapmuxv1.ExecOutputChunk) {
+	t.Helper()
+	require.Eventually(t, func() bool {
+		chunks := execChunks(t, w)
+		return len(chunks) > 0 && chunks[len(chunks)-1].GetExited()
+	}, 10*time.Second, 10*time.Millisecond)
+	var stdout, stderr strings.Builder
+	chunks := execChunks(t, w)
+	for _, chunk := range chunks {
+		stdout.Write(chunk.GetStdout())
+		stderr.Write(chunk.GetStderr())
+	}
+	return stdout.String(), stderr.String(), chunks[len(chunks)-1]
+}
+
+func TestExecCommand_StreamsOutputAndExitCode(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("sh commands")
+	}
+	svc, d, w := setupTestService(t)
+
+	dispatch(d, "ExecCommand", &leapmuxv1.ExecCommandRequest{
+		ExecId:     "exec-1",
+		WorkingDir: svc.HomeDir,
+		Argv:       []string{"sh", "-c", "pwd; echo oops >&2; exit 3"},
+	}, w)
+	require.Empty(t, w.errors)
+	require.Len(t, w.responses, 1)
+	var resp leapmuxv1.ExecCommandResponse
+	require.NoError(t, proto.Unmarshal(w.responses[0].GetPayload(), &resp))
+	assert.Equal(t, "exec-1", resp.GetExecId())
+
+	stdout, stderr, last := execResult(t, w)
+	assert.Equal(t, resp.GetWorkingDir()+"\n", stdout)
+	assert.Equal(t, "oops\n", stderr)
+	assert.EqualValues(t, 3, last.GetExitCode())
+	assert.False(t, last.GetTimedOut())
+	assert.False(t, last.GetTruncated())
+}
+
+func TestExecCommand_TimesOutAndTruncates(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("sh commands")
+	}
+	svc, d, w := setupTestService(t)
+
+	dispatch(d, "ExecCommand", &leapmuxv1.ExecCommandRequest{
+		ExecId:         "exec-big",
+		WorkingDir:     svc.HomeDir,
+		Argv:           []string{"sh", "-c", "head -c 2000000 /dev/zero; sleep 30"},
+		TimeoutSeconds: 1,
+	}, w)
+	require.Empty(t, w.errors)
+	stdout, _, last := execResult(t, w)
+	assert.Len(t, stdout, maxExecOutputBytes)
+	assert.True(t, last.GetTruncated())
+	assert.True(t, last.GetTimedOut())
+	assert.EqualValues(t, -1, last.GetExitCode())
+}
+
+func TestExecCommand_CancelStopsCommand(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("sh commands")
+	}
+	svc, d, w := setupTestService(t)
+
+	dispatch(d, "ExecCommand", &leapmuxv1.ExecCommandRequest{
+		ExecId: "exec-cancel", WorkingDir: svc.HomeDir, Argv: []string{"sleep", "30"},
+	}, w)
+	require.Empty(t, w.errors)
+	dispatch(d, "CancelExec", &leapmuxv1.CancelExecRequest{ExecId: "exec-cancel"}, newTestWriter())
+	_, _, last := execResult(t, w)
+	assert.EqualValues(t, -1, last.GetExitCode())
+	assert.False(t, last.GetTimedOut())
+}
+
+func TestExecCommand_RejectsBadRequests(t *testing.T) {
+	svc, d, _ := setupTestService(t)
+	for name, req := range map[string]*leapmuxv1.ExecCommandRequest{
+		"no exec id":     {WorkingDir: svc.HomeDir, Argv: []string{"true"}},
+		"no argv":        {ExecId: "x", WorkingDir: svc.HomeDir},
+		"long timeout":   {ExecId: "x", WorkingDir: svc.HomeDir, Argv: []string{"true"}, TimeoutSeconds: 601},
+		"relative dir":   {ExecId: "x", WorkingDir: "repo", Argv: []string{"true"}},
+		"missing dir":    {ExecId: "x", WorkingDir: svc.HomeDir + "/missing", Argv: []string{"true"}},
+		"unknown binary": {ExecId: "x", WorkingDir: svc.HomeDir, Argv: []string{"leapmux-no-such-binary"}},
+	} {
+		t.Run(name, func(t *testing.T) {
+			w := newTestWriter()
+			dispatch(d, "ExecCommand", req, w)
+			require.Len(t, w.errors, 1)
+			assert.Empty(t, w.responses)
+		})
+	}
+}
+
+// A command runs as the owner, so only the owner's own unscoped credential
+// may start or cancel one: a driver guest's channel carries the owner's id,
+// and a delegation bearer is the owner's but pinned to a workspace.
+func TestExecCommand_RefusesGuestsAndScopedCredentials(t *testing.T) {
+	for name, opt := range map[string]setupOption{
+		"guest":            withGuest(),
+		"workspace scoped": withWorkspaceScoped(),
+	} {
+		t.Run(name, func(t *testing.T) {
+			svc, d, _ := setupTestService(t, withWorkspaces("ws-1"), opt)
+			for method, req := range map[string]proto.Message{
+				"ExecCommand": &leapmuxv1.ExecCommandRequest{ExecId: "x", WorkingDir: svc.HomeDir, Argv: []string{"true"}},
+				"CancelExec":  &leapmuxv1.CancelExecRequest{ExecId: "x"},
+			} {
+				w := newTestWriter()
+				dispatch(d, method, req, w)
+				require.Len(t, w.errors, 1, method)
+				assert.Equal(t, codePermissionDenied, w.errors[0].code, method)
+				assert.Empty(t, w.responses, method)
+			}
+		})
+	}
+}
@@ -0,0 +1,303 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"io"
+	"log/slog"
+	"os"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	leapmuxv1 "github.com/leapmux/leapmux/generated/proto/leapmux/v1"
+	"github.com/leapmux/leapmux/internal/tunnelflow"
+	"github.com/leapmux/leapmux/internal/util/pathutil"
+	"github.com/leapmux/leapmux/internal/util/userid"
+	"github.com/leapmux/leapmux/internal/worker/channel"
+	"github.com/leapmux/leapmux/util/validate"
+	"google.golang.org/protobuf/proto"
+)
+
+// fileDownload is one DownloadFile sub-stream: an open file streamed to the
+// client in tunnel-sized chunks under the client's read credit.
+//
+// It borrows the tunnel's read direction wholesale -- chunk bound, initial
+// window and creditWindow -- because the client's receive path is the same
+// shape: a bounded frame buffer drained by one reader that grants credit as it
+// goes. A download therefore pins at most InitialReadWindow chunks in flight,
+// and a client that stops reading stalls only its own download, never the
+// shared channel carrying every other tab's traffic.
+type fileDownload struct {
+	file   *os.File
+	sender channel.ResponseWriter
+	credit *creditWindow
+	closed atomic.Bool
+	// stopCtxWatch detaches the session-lifetime watcher. Only the goroutine
+	// that armed it calls it, so the watcher's own close never reads it.
+	stopCtxWatch func() bool
+}
+
+// close releases the file and wakes a read loop parked on credit. Idempotent;
+// reports whether this call performed the close.
+func (fd *fileDownload) close() bool {
+	if !fd.closed.CompareAndSwap(false, true) {
+		return false
+	}
+	_ = fd.file.Close()
+	fd.credit.close()
+	return true
+}
+
+// downloadManager tracks the worker's in-flight downloads by client-chosen id.
+// Like tunnelManager it is worker-lifetime and shared across channel sessions.
+type downloadManager struct {
+	mu        sync.Mutex
+	downloads map[string]*fileDownload
+	// canceled fences a CancelDownload dispatched ahead of its DownloadFile, so
+	// the late open does not stream a file the client already gave up on.
+	canceled cancelMarkers
+}
+
+func newDownloadManager() *downloadManager {
+	return &downloadManager{
+		downloads: make(map[string]*fileDownload),
+		canceled:  newCancelMarkers(),
+	}
+}
+
+// register installs fd under id, refusing an id that is live or was cancelled
+// before it opened.
+func (m *downloadManager) register(id string, fd *fileDownload) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.canceled.sweep(time.Now())
+	if m.canceled.take(id) {
+		return errors.New("download_id was canceled")
+	}
+	if _, exists := m.downloads[id]; exists {
+		return errors.New("download_id is already in use")
+	}
+	m.downloads[id] = fd
+	return nil
+}
+
+func (m *downloadManager) get(id string) *fileDownload {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.downloads[id]
+}
+
+// removeIf evicts id only while it still maps to fd.
+func (m *downloadManager) removeIf(id string, fd *fileDownload) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if m.downloads[id] == fd {
+		delete(m.downloads, id)
+	}
+}
+
+// cancel closes and evicts a live download, or fences one still to open.
+func (m *downloadManager) cancel(id string) {
+	m.mu.Lock()
+	now := time.Now()
+	m.canceled.sweep(now)
+	fd := m.downloads[id]
+	if fd != nil {
+		delete(m.downloads, id)
+	} else {
+		m.canceled.mark(id, now.Add(staleCancelMarkerTTL))
+	}
+	m.mu.Unlock()
+	if fd != nil {
+		fd.close()
+	}
+}
+
+// downloadRequest constrains a request to one that names a download, for
+// registerDownloadHandler.
+type downloadRequest[T any] interface {
+	*T
+	proto.Message
+	GetDownloadId() string
+}
+
+// registerDownloadHandler registers a download handler behind the prelude they
+// share: unmarshal, then refuse an empty download_id. Same idiom as
+// registerConnHandler.
+func registerDownloadHandler[T any, PT downloadRequest[T]](
+	d ownerOnlyRegistrar,
+	method string,
+	fn func(ctx context.Context, r PT, sender channel.ResponseWriter),
+) {
+	d.Register(method, func(ctx context.Context, _ userid.UserID, req *leapmuxv1.InnerRpcRequest, sender channel.ResponseWriter) {
+		var msg T
+		r := PT(&msg)
+		if err := unmarshalRequest(req, r); err != nil {
+			sendInvalidArgument(sender, "invalid request")
+			return
+		}
+		if r.GetDownloadId() == "" {
+			sendInvalidArgument(sender, "download_id is required")
+			return
+		}
+		fn(ctx, r, sender)
+	})
+}
+
+// registerDownloadHandlers registers the DownloadFile sub-stream family. Files
+// are machine-scoped, so like the rest of the file surface it is owner-only.
+func registerDownloadHandlers(d ownerOnlyRegistrar, svc *Service) {
+	downloads := newDownloadManager()
+	registerDownloadHandler(d, "DownloadFile", func(ctx context.Context, r *leapmuxv1.DownloadFileRequest, sender channel.ResponseWriter) {
+		downloads.open(ctx, svc.HomeDir, r, sender)
+	})
+	registerDownloadHandler(d, "DownloadArtifact", func(ctx context.Context, r *leapmuxv1.DownloadArtifactRequest, sender channel.ResponseWriter) {
+		svc.openArtifactDownload(ctx, downloads, r, sender)
+	})
+	registerDownloadHandler(d, "GrantDownloadCredit", downloads.grantCredit)
+	registerDownloadHandler(d, "CancelDownload", downloads.cancelDownload)
+}
+
+// open starts streaming a file. The response carries the size before any
+// chunk, so the client can show progress from the first byte.
+func (m *downloadManager) open(ctx context.Context, homeDir string, r *leapmuxv1.DownloadFileRequest, sender channel.ResponseWriter) {
+	id := r.GetDownloadId()
+	filePath, err := validate.SanitizePath(r.GetPath(), homeDir)
+	if err != nil {
+		sendPermissionDenied(sender, "access denied")
+		return
+	}
+	filePath = pathutil.Canonicalize(filePath)
+	m.start(ctx, id, filePath, filePath, r.GetOffset(), sender)
+}
+
+// start opens filePath and streams it from offset as download id. The
+// response reports displayPath, which for an artifact is its path in the
+// agent's working directory rather than its snapshot on disk.
+func (m *downloadManager) start(ctx context.Context, id, filePath, displayPath string, offset int64, sender channel.ResponseWriter) {
+	if offset < 0 {
+		sendInvalidArgument(sender, "offset must not be negative")
+		return
+	}
+
+	f, err := os.Open(filePath)
+	if err != nil {
+		switch {
+		case os.IsNotExist(err):
+			sendNotFoundError(sender, "file not found")
+		case os.IsPermission(err):
+			sendPermissionDenied(sender, "permission denied")
+		default:
+			slog.Error("failed to open file for download", "path", filePath, "error", err)
+			sendInternalError(sender, "failed to open file")
+		}
+		return
+	}
+	info, err := f.Stat()
+	if err != nil {
+		_ = f.Close()
+		slog.Error("failed to stat file for download", "path", filePath, "error", err)
+		sendInternalError(sender, "failed to stat file")
+		return
+	}
+	if info.IsDir() {
+		_ = f.Close()
+		sendInvalidArgument(sender, "path is a directory")
+		return
+	}
+	if offset > 0 {
+		if _, err := f.Seek(offset, io.SeekStart); err != nil {
+			_ = f.Close()
+			sendInternalError(sender, "failed to seek file")
+			return
+		}
+	}
+
+	fd := &fileDownload{
+		file:   f,
+		sender: sender,
+		credit: newCreditWindow(tunnelflow.InitialReadWindow),
+	}
+	// The session context ends with the channel; closing then unblocks a read
+	// loop parked on credit the vanished client will never grant. Armed before
+	// register so close never races the assignment.
+	fd.stopCtxWatch = context.AfterFunc(ctx, func() {
+		m.removeIf(id, fd)
+		fd.close()
+	})
+	if err := m.register(id, fd); err != nil {
+		fd.stopCtxWatch()
+		fd.close()
+		sendInvalidArgument(sender, err.Error())
+		return
+	}
+
+	sendProtoResponse(sender, &leapmuxv1.DownloadFileResponse{
+		DownloadId: id,
+		Path:       displayPath,
+		TotalSize:  info.Size(),
+	})
+	slog.Info("file download started", "download_id", id, "path", filePath, "size", info.Size())
+	go m.readLoop(id, fd)
+}
+
+// readLoop streams the file one credit at a time, ending with an eof or error
+// chunk, and evicts the download when it stops.
+func (m *downloadManager) readLoop(id string, fd *fileDownload) {
+	defer func() {
+		fd.stopCtxWatch()
+		fd.close()
+		m.removeIf(id, fd)
+	}()
+	buf := make([]byte, tunnelflow.MaxChunkBytes)
+	for {
+		n, err := fd.file.Read(buf)
+		if n > 0 {
+			if !fd.credit.acquire() {
+				return // cancelled or the session ended
+			}
+			if sendDownloadChunk(fd.sender, &leapmuxv1.FileDownloadChunk{Data: buf[:n]}) != nil {
+				return
+			}
+		}
+		if err == nil {
+			continue
+		}
+		if fd.closed.Load() {
+			return
+		}
+		if errors.Is(err, io.EOF) {
+			_ = sendDownloadChunk(fd.sender, &leapmuxv1.FileDownloadChunk{Eof: true})
+			slog.Info("file download finished", "download_id", id)
+			return
+		}
+		slog.Error("file download read failed", "download_id", id, "error", err)
+		_ = sendDownloadChunk(fd.sender, &leapmuxv1.FileDownloadChunk{Error: "failed to read file"})
+		return
+	}
+}
+
+// grantCredit replenishes a download's send window. A grant for a finished
+// download is a benign race and succeeds.
+func (m *downloadManager) grantCredit(_ context.Context, r *leapmuxv1.GrantDownloadCreditRequest, sender channel.ResponseWriter) {
+	if fd := m.get(r.GetDownloadId()); fd != nil {
+		fd.credit.add(r.GetCredit())
+	}
+	sendProtoResponse(sender, &leapmuxv1.GrantDownloadCreditResponse{})
+}
+
+func (m *downloadManager) cancelDownload(_ context.Context, r *leapmuxv1.CancelDownloadRequest, sender channel.ResponseWriter) {
+	m.cancel(r.GetDownloadId())
+	sendProtoResponse(sender, &leapmuxv1.CancelDownloadResponse{})
+}
+
+// sendDownloadChunk sends one chunk as a stream message. proto.Marshal copies
+// Data, so the read loop may reuse its buffer as soon as this returns.
+func sendDownloadChunk(sender channel.ResponseWriter, chunk *leapmuxv1.FileDownloadChunk) error {
+	payload, err := proto.Marshal(chunk)
+	if err != nil {
+		return err
+	}
+	return sender.SendStream(&leapmuxv1.InnerStreamMessage{Payload: payload})
+}
@@ -0,0 +1,151 @@
+package service
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/protobuf/proto"
+
+	leapmuxv1 "github.com/leapmux/leapmux/generated/proto/leapmux/v1"
+	"github.com/leapmux/leapmux/internal/tunnelflow"
+)
+
+// downloadChunks decodes every chunk the writer has streamed so far.
+func downloadChunks(t *testing.T, w *testResponseWriter) []*leapmuxv1.FileDownloadChunk {
+	t.Helper()
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	chunks := make([]*leapmuxv1.FileDownloadChunk, 0, len(w.streams))
+	for _, msg := range w.streams {
+		var chunk leapmuxv1.FileDownloadChunk
+		require.NoError(t, proto.Unmarshal(msg.GetPayload(), &chunk))
+		chunks = append(chunks, &chunk)
+	}
+	return chunks
+}
+
+func streamCount(w *testResponseWriter) int {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	return len(w.streams)
+}
+
+func TestDownloadFile_StreamsWholeFileInChunks(t *testing.T) {
+	svc, d, w := setupTestService(t)
+	content := bytes.Repeat([]byte("0123456789abcdef"), 3*tunnelflow.MaxChunkBytes/16+100)
+	path := filepath.Join(svc.HomeDir, "big.bin")
+	require.NoError(t, os.WriteFile(path, content, 0o644))
+
+	dispatch(d, "DownloadFile", &leapmuxv1.DownloadFileRequest{Path: path, DownloadId: "dl-1"}, w)
+	require.Empty(t, w.errors)
+	require.Len(t, w.responses, 1)
+	var resp leapmuxv1.DownloadFileResponse
+	require.NoError(t, proto.Unmarshal(w.responses[0].GetPayload(), &resp))
+	assert.Equal(t, "dl-1", resp.GetDownloadId())
+	assert.EqualValues(t, len(content), resp.GetTotalSize())
+
+	require.Eventually(t, func() bool {
+		chunks := downloadChunks(t, w)
+		return len(chunks) > 0 && chunks[len(chunks)-1].GetEof()
+	}, 5*time.Second, 10*time.Millisecond)
+
+	var got []byte
+	for _, chunk := range downloadChunks(t, w) {
+		assert.LessOrEqual(t, len(chunk.GetData()), tunnelflow.MaxChunkBytes)
+		got = append(got, chunk.GetData()...)
+	}
+	assert.Equal(t, content, got)
+}
+
+func TestDownloadFile_WaitsForCredit(t *testing.T) {
+	svc, d, w := setupTestService(t)
+	chunks := tunnelflow.InitialReadWindow + 10
+	path := filepath.Join(svc.HomeDir, "huge.bin")
+	require.NoError(t, os.WriteFile(path, make([]byte, chunks*tunnelflow.MaxChunkBytes), 0o644))
+
+	dispatch(d, "DownloadFile", &leapmuxv1.DownloadFileRequest{Path: path, DownloadId: "dl-credit"}, w)
+	require.Len(t, w.responses, 1)
+
+	// The worker stops at the self-seeded window until the client grants more.
+	require.Eventually(t, func() bool { return streamCount(w) == tunnelflow.InitialReadWindow }, 5*time.Second, 10*time.Millisecond)
+	time.Sleep(50 * time.Millisecond)
+	assert.Equal(t, tunnelflow.InitialReadWindow, streamCount(w))
+
+	dispatch(d, "GrantDownloadCredit", &leapmuxv1.GrantDownloadCreditRequest{DownloadId: "dl-credit", Credit: 64}, newTestWriter())
+	require.Eventually(t, func() bool {
+		all := downloadChunks(t, w)
+		return len(all) == chunks+1 && all[chunks].GetEof()
+	}, 5*time.Second, 10*time.Millisecond)
+}
+
+func TestDownloadFile_Cancel(t *testing.T) {
+	svc, d, w := setupTestService(t)
+	path := filepath.Join(svc.HomeDir, "huge.bin")
+	require.NoError(t, os.WriteFile(path, make([]byte, (tunnelflow.InitialReadWindow+10)*tunnelflow.MaxChunkBytes), 0o644))
+
+	dispatch(d, "DownloadFile", &leapmuxv1.DownloadFileRequest{Path: path, DownloadId: "dl-cancel"}, w)
+	require.Eventually(t, func() bool { return streamCount(w) == tunnelflow.InitialReadWindow }, 5*time.Second, 10*time.Millisecond)
+
+	cw := newTestWriter()
+	dispatch(d, "CancelDownload", &leapmuxv1.CancelDownloadRequest{DownloadId: "dl-cancel"}, cw)
+	require.Len(t, cw.responses, 1)
+
+	// Granting after a cancel is a no-op and streams nothing more.
+	dispatch(d, "GrantDownloadCredit", &leapmuxv1.GrantDownloadCreditRequest{DownloadId: "dl-cancel", Credit: 64}, newTestWriter())
+	time.Sleep(50 * time.Millisecond)
+	assert.Equal(t, tunnelflow.InitialReadWindow, streamCount(w))
+}
+
+func TestDownloadFile_CancelBeforeOpenFencesTheOpen(t *testing.T) {
+	svc, d, w := setupTestService(t)
+	path := filepath.Join(svc.HomeDir, "small.txt")
+	require.NoError(t, os.WriteFile(path, []byte("hello"), 0o644))
+
+	dispatch(d, "CancelDownload", &leapmuxv1.CancelDownloadRequest{DownloadId: "dl-early"}, newTestWriter())
+	dispatch(d, "DownloadFile", &leapmuxv1.DownloadFileRequest{Path: path, DownloadId: "dl-early"}, w)
+	require.Len(t, w.errors, 1)
+	assert.Contains(t, w.errors[0].message, "canceled")
+	assert.Empty(t, w.streams)
+}
+
+func TestDownloadFile_Rejects(t *testing.T) {
+	svc, d, _ := setupTestService(t)
+	file := filepath.Join(svc.HomeDir, "f.txt")
+	require.NoError(t, os.WriteFile(file, []byte("x"), 0o644))
+
+	for _, tc := range []struct {
+		name string
+		req  *leapmuxv1.DownloadFileRequest
+		code int32
+	}{
+		{"missing id", &leapmuxv1.DownloadFileRequest{Path: file}, 3},
+		{"directory", &leapmuxv1.DownloadFileRequest{Path: svc.HomeDir, DownloadId: "dir"}, 3},
+		{"missing file", &leapmuxv1.DownloadFileRequest{Path: filepath.Join(svc.HomeDir, "nope"), DownloadId: "nope"}, 5},
+		{"negative offset", &leapmuxv1.DownloadFileRequest{Path: file, DownloadId: "neg", Offset: -1}, 3},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			w := newTestWriter()
+			dispatch(d, "DownloadFile", tc.req, w)
+			require.Len(t, w.errors, 1)
+			assert.Equal(t, tc.code, w.errors[0].code)
+		})
+	}
+}
+
+func TestDownloadFile_ResumesAtOffset(t *testing.T) {
+	svc, d, w := setupTestService(t)
+	path := filepath.Join(svc.HomeDir, "resume.txt")
+	require.NoError(t, os.WriteFile(path, []byte("hello, world"), 0o644))
+
+	dispatch(d, "DownloadFile", &leapmuxv1.DownloadFileRequest{Path: path, DownloadId: "dl-resume", Offset: 7}, w)
+	require.Eventually(t, func() bool {
+		chunks := downloadChunks(t, w)
+		return len(chunks) > 0 && chunks[len(chunks)-1].GetEof()
+	}, 5*time.Second, 10*time.Millisecond)
+	assert.Equal(t, []byte("world"), downloadChunks(t, w)[0].GetData())
+}
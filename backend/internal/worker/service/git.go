@@ -389,6 +389,11 @@ func registerGitHandlers(d ownerOnlyRegistrar, svc *Service) {
 			sendInternalError(sender, "failed to list worktrees: "+err.Error())
 			return
 		}
+		for _, wt := range worktrees {
+			if n, ok := svc.diskUsageForPath(wt.GetPath()); ok {
+				wt.DiskUsageBytes = &n
+			}
+		}
 
 		sendProtoResponse(sender, &leapmuxv1.ListGitWorktreesResponse{
 			Worktrees: worktrees,
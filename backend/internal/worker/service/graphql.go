@@ -0,0 +1,387 @@
+package service
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"log/slog"
+	"slices"
+	"strings"
+	"unicode"
+
+	leapmuxv1 "github.com/leapmux/leapmux/generated/proto/leapmux/v1"
+	"github.com/leapmux/leapmux/internal/util/agentlabels"
+	"github.com/leapmux/leapmux/internal/util/msgcodec"
+	"github.com/leapmux/leapmux/internal/util/timefmt"
+	"github.com/leapmux/leapmux/internal/util/userid"
+	"github.com/leapmux/leapmux/internal/worker/channel"
+	db "github.com/leapmux/leapmux/internal/worker/generated/db"
+	"github.com/leapmux/leapmux/internal/worker/graphql"
+)
+
+const (
+	// maxGraphQLQueryLen bounds a query's text; the schema's own limits
+	// bound how much work it asks for.
+	maxGraphQLQueryLen = 32 << 10
+	// maxGraphQLResultBytes bounds a query's result. Message content is
+	// returned whole, so a few hundred messages could otherwise make a
+	// response of any size.
+	maxGraphQLResultBytes = 4 << 20
+
+	defaultGraphQLAgents   = 50
+	maxGraphQLAgents       = 200
+	defaultGraphQLMessages = 20
+	maxGraphQLMessages     = 200
+	defaultPreviewLength   = 140
+)
+
+// errGraphQLInternal is what a resolver reports in place of a database
+// error, which is logged instead.
+var errGraphQLInternal = errors.New("internal error")
+
+// graphQLAgentSource is an Agent's resolver source: the row, and whether
+// the worker is running it, read once for the fields that need it.
+type graphQLAgentSource struct {
+	agent   db.Agent
+	running bool
+}
+
+// graphQLSchema is the query schema over the workspaces in accessible.
+// Every root field filters by it, so a workspace or agent outside it reads
+// as null or is left out, the way set-filtered RPCs answer.
+func (svc *Service) graphQLSchema(accessible map[string]bool) *graphql.Schema {
+	internal := func(what string, err error) error {
+		slog.Error("graphql: failed to "+what, "error", err)
+		return errGraphQLInternal
+	}
+	agentSource := func(a db.Agent) *graphQLAgentSource {
+		return &graphQLAgentSource{agent: a, running: svc.Agents.HasAgent(a.ID)}
+	}
+
+	usage := &graphql.Object{Name: "Usage", Fields: map[string]*graphql.Field{
+		"turns":        usageField(func(u db.GetAgentUsageTotalsRow) any { return u.Turns }),
+		"inputTokens":  usageField(func(u db.GetAgentUsageTotalsRow) any { return u.InputTokens }),
+		"outputTokens": usageField(func(u db.GetAgentUsageTotalsRow) any { return u.OutputTokens }),
+		"cacheCreationInputTokens": usageField(func(u db.GetAgentUsageTotalsRow) any {
+			return u.CacheCreationInputTokens
+		}),
+		"cacheReadInputTokens": usageField(func(u db.GetAgentUsageTotalsRow) any { return u.CacheReadInputTokens }),
+		"costUsd": {Type: graphql.NonNullOf(graphql.Float), Resolve: func(_ context.Context, p graphql.ResolveParams) (any, error) {
+			return p.Source.(db.GetAgentUsageTotalsRow).CostUsd, nil
+		}},
+	}}
+
+	message := &graphql.Object{Name: "Message", Fields: map[string]*graphql.Field{
+		"id":        messageField(graphql.ID, func(m db.Message) any { return m.ID }),
+		"seq":       messageField(graphql.Int, func(m db.Message) any { return m.Seq }),
+		"source":    messageField(graphql.String, func(m db.Message) any { return archivedSource(m.Source) }),
+		"createdAt": messageField(graphql.String, func(m db.Message) any { return timefmt.Format(m.CreatedAt.Time) }),
+		"deliveryError": {Type: graphql.String, Resolve: func(_ context.Context, p graphql.ResolveParams) (any, error) {
+			if e := p.Source.(db.Message).DeliveryError; e != "" {
+				return e, nil
+			}
+			return nil, nil
+		}},
+		"content": {Type: graphql.NonNullOf(graphql.String), Resolve: func(_ context.Context, p graphql.ResolveParams) (any, error) {
+			m := p.Source.(db.Message)
+			raw, err := msgcodec.Decompress(m.Content, m.ContentCompression)
+			if err != nil {
+				return nil, internal("decompress message "+m.ID, err)
+			}
+			return string(raw), nil
+		}},
+		"preview": {
+			Type: graphql.NonNullOf(graphql.String),
+			Args: map[string]*graphql.Argument{"length": {Type: graphql.Int, Default: defaultPreviewLength}},
+			Resolve: func(_ context.Context, p graphql.ResolveParams) (any, error) {
+				m := p.Source.(db.Message)
+				raw, err := msgcodec.Decompress(m.Content, m.ContentCompression)
+				if err != nil {
+					return nil, internal("decompress message "+m.ID, err)
+				}
+				length, _ := p.Args["length"].(int64)
+				return messagePreview(raw, int(max(length, 1))), nil
+			},
+		},
+	}}
+
+	agent := &graphql.Object{Name: "Agent", Fields: map[string]*graphql.Field{
+		"id":          agentField(graphql.ID, func(a *graphQLAgentSource) any { return a.agent.ID }),
+		"workspaceId": agentField(graphql.ID, func(a *graphQLAgentSource) any { return a.agent.WorkspaceID }),
+		"title":       agentField(graphql.String, func(a *graphQLAgentSource) any { return a.agent.Title }),
+		"provider": agentField(graphql.String, func(a *graphQLAgentSource) any {
+			return agentlabels.CLIAlias(a.agent.AgentProvider)
+		}),
+		"status": agentField(graphql.String, func(a *graphQLAgentSource) any {
+			status, _, _ := svc.deriveAgentStatus(&a.agent, a.running)
+			return strings.ToLower(strings.TrimPrefix(status.String(), "AGENT_STATUS_"))
+		}),
+		"running":   agentField(graphql.Boolean, func(a *graphQLAgentSource) any { return a.running }),
+		"createdBy": agentField(graphql.String, func(a *graphQLAgentSource) any { return a.agent.CreatedBy }),
+		"createdAt": agentField(graphql.String, func(a *graphQLAgentSource) any {
+			return timefmt.Format(a.agent.CreatedAt.Time)
+		}),
+		"closedAt": {Type: graphql.String, Resolve: func(_ context.Context, p graphql.ResolveParams) (any, error) {
+			if a := p.Source.(*graphQLAgentSource).agent; a.ClosedAt.Valid {
+				return timefmt.Format(a.ClosedAt.Time), nil
+			}
+			return nil, nil
+		}},
+		"messageCount": {Type: graphql.NonNullOf(graphql.Int), Resolve: func(ctx context.Context, p graphql.ResolveParams) (any, error) {
+			stats, err := svc.Queries.ListMessageStatsByAgentID(ctx, p.Source.(*graphQLAgentSource).agent.ID)
+			if err != nil {
+				return nil, internal("count messages", err)
+			}
+			var n int64
+			for _, s := range stats {
+				n += s.MessageCount
+			}
+			return n, nil
+		}},
+		"messages": {
+			Type: graphql.NonNullOf(graphql.ListOf(graphql.NonNullOf(message))),
+			Args: map[string]*graphql.Argument{
+				"last":      {Type: graphql.Int, Default: defaultGraphQLMessages},
+				"beforeSeq": {Type: graphql.Int},
+			},
+			Resolve: func(ctx context.Context, p graphql.ResolveParams) (any, error) {
+				agentID := p.Source.(*graphQLAgentSource).agent.ID
+				last, _ := p.Args["last"].(int64)
+				last = min(max(last, 0), maxGraphQLMessages)
+				var rows []db.Message
+				var err error
+				if before, ok := p.Args["beforeSeq"].(int64); ok {
+					rows, err = svc.Queries.ListMessagesByAgentIDReverse(ctx, db.ListMessagesByAgentIDReverseParams{AgentID: agentID, Seq: before, Limit: last})
+				} else {
+					rows, err = svc.Queries.ListLatestMessagesByAgentID(ctx, db.ListLatestMessagesByAgentIDParams{AgentID: agentID, Limit: last})
+				}
+				if err != nil {
+					return nil, internal("list messages", err)
+				}
+				slices.Reverse(rows)
+				return rows, nil
+			},
+		},
+		"lastMessage": {Type: message, Resolve: func(ctx context.Context, p graphql.ResolveParams) (any, error) {
+			m, err := svc.Queries.GetLatestMessageByAgentID(ctx, p.Source.(*graphQLAgentSource).agent.ID)
+			if errors.Is(err, sql.ErrNoRows) {
+				return nil, nil
+			}
+			if err != nil {
+				return nil, internal("get latest message", err)
+			}
+			return m, nil
+		}},
+		"usage": {Type: graphql.NonNullOf(usage), Resolve: func(ctx context.Context, p graphql.ResolveParams) (any, error) {
+			u, err := svc.Queries.GetAgentUsageTotals(ctx, p.Source.(*graphQLAgentSource).agent.ID)
+			if err != nil {
+				return nil, internal("total agent usage", err)
+			}
+			return u, nil
+		}},
+	}}
+
+	// openAgents lists a workspace's open agents, for the fields that
+	// summarize them.
+	openAgents := func(ctx context.Context, wsID string) ([]string, error) {
+		ids, err := svc.Queries.ListOpenAgentIDsByWorkspaceID(ctx, wsID)
+		if err != nil {
+			return nil, internal("list open agents", err)
+		}
+		return ids, nil
+	}
+	workspace := &graphql.Object{Name: "Workspace", Fields: map[string]*graphql.Field{
+		"id": {Type: graphql.NonNullOf(graphql.ID), Resolve: func(_ context.Context, p graphql.ResolveParams) (any, error) {
+			return p.Source, nil
+		}},
+		"agents": {
+			Type: graphql.NonNullOf(graphql.ListOf(graphql.NonNullOf(agent))),
+			Args: map[string]*graphql.Argument{
+				"includeClosed": {Type: graphql.Boolean, Default: false},
+				"first":         {Type: graphql.Int, Default: defaultGraphQLAgents},
+			},
+			Resolve: func(ctx context.Context, p graphql.ResolveParams) (any, error) {
+				includeClosed, _ := p.Args["includeClosed"].(bool)
+				first, _ := p.Args["first"].(int64)
+				rows, err := svc.Queries.ListAgentsByWorkspaceID(ctx, db.ListAgentsByWorkspaceIDParams{
+					WorkspaceID:   p.Source.(string),
+					IncludeClosed: includeClosed,
+					RowLimit:      min(max(first, 0), maxGraphQLAgents),
+				})
+				if err != nil {
+					return nil, internal("list agents", err)
+				}
+				out := make([]*graphQLAgentSource, len(rows))
+				for i := range rows {
+					out[i] = agentSource(rows[i])
+				}
+				return out, nil
+			},
+		},
+		"agentCount": {Type: graphql.NonNullOf(graphql.Int), Resolve: func(ctx context.Context, p graphql.ResolveParams) (any, error) {
+			ids, err := openAgents(ctx, p.Source.(string))
+			return len(ids), err
+		}},
+		"runningAgentCount": {Type: graphql.NonNullOf(graphql.Int), Resolve: func(ctx context.Context, p graphql.ResolveParams) (any, error) {
+			ids, err := openAgents(ctx, p.Source.(string))
+			n := 0
+			for _, id := range ids {
+				if svc.Agents.HasAgent(id) {
+					n++
+				}
+			}
+			return n, err
+		}},
+		"lastMessage": {Type: message, Resolve: func(ctx context.Context, p graphql.ResolveParams) (any, error) {
+			ids, err := openAgents(ctx, p.Source.(string))
+			if err != nil {
+				return nil, err
+			}
+			var latest *db.Message
+			for _, id := range ids {
+				m, err := svc.Queries.GetLatestMessageByAgentID(ctx, id)
+				if errors.Is(err, sql.ErrNoRows) {
+					continue
+				}
+				if err != nil {
+					return nil, internal("get latest message", err)
+				}
+				if latest == nil || m.CreatedAt.Time.After(latest.CreatedAt.Time) {
+					latest = &m
+				}
+			}
+			if latest == nil {
+				return nil, nil
+			}
+			return *latest, nil
+		}},
+		"usage": {Type: graphql.NonNullOf(usage), Resolve: func(ctx context.Context, p graphql.ResolveParams) (any, error) {
+			u, err := svc.Queries.GetWorkspaceUsageTotals(ctx, p.Source.(string))
+			if err != nil {
+				return nil, internal("total workspace usage", err)
+			}
+			return db.GetAgentUsageTotalsRow(u), nil
+		}},
+	}}
+
+	query := &graphql.Object{Name: "Query", Fields: map[string]*graphql.Field{
+		"workspaces": {Type: graphql.NonNullOf(graphql.ListOf(graphql.NonNullOf(workspace))), Resolve: func(context.Context, graphql.ResolveParams) (any, error) {
+			ids := make([]string, 0, len(accessible))
+			for id, ok := range accessible {
+				if ok {
+					ids = append(ids, id)
+				}
+			}
+			slices.Sort(ids)
+			return ids, nil
+		}},
+		"workspace": {
+			Type: workspace,
+			Args: map[string]*graphql.Argument{"id": {Type: graphql.NonNullOf(graphql.ID)}},
+			Resolve: func(_ context.Context, p graphql.ResolveParams) (any, error) {
+				if id := p.Args["id"].(string); accessible[id] {
+					return id, nil
+				}
+				return nil, nil
+			},
+		},
+		"agent": {
+			Type: agent,
+			Args: map[string]*graphql.Argument{"id": {Type: graphql.NonNullOf(graphql.ID)}},
+			Resolve: func(ctx context.Context, p graphql.ResolveParams) (any, error) {
+				a, err := svc.Queries.GetAgentByID(ctx, p.Args["id"].(string))
+				if errors.Is(err, sql.ErrNoRows) {
+					return nil, nil
+				}
+				if err != nil {
+					return nil, internal("get agent", err)
+				}
+				if !accessible[a.WorkspaceID] {
+					return nil, nil
+				}
+				return agentSource(a), nil
+			},
+		},
+	}}
+	return &graphql.Schema{Query: query, MaxResultBytes: maxGraphQLResultBytes}
+}
+
+func usageField(get func(db.GetAgentUsageTotalsRow) any) *graphql.Field {
+	return &graphql.Field{Type: graphql.NonNullOf(graphql.Int), Resolve: func(_ context.Context, p graphql.ResolveParams) (any, error) {
+		return get(p.Source.(db.GetAgentUsageTotalsRow)), nil
+	}}
+}
+
+func messageField(t graphql.Type, get func(db.Message) any) *graphql.Field {
+	return &graphql.Field{Type: graphql.NonNullOf(t), Resolve: func(_ context.Context, p graphql.ResolveParams) (any, error) {
+		return get(p.Source.(db.Message)), nil
+	}}
+}
+
+func agentField(t graphql.Type, get func(*graphQLAgentSource) any) *graphql.Field {
+	return &graphql.Field{Type: graphql.NonNullOf(t), Resolve: func(_ context.Context, p graphql.ResolveParams) (any, error) {
+		return get(p.Source.(*graphQLAgentSource)), nil
+	}}
+}
+
+// messagePreview is a message's first text block -- or its first block of
+// any kind when it has no text -- on one line and cut to length runes,
+// for list rows that show a message's gist.
+func messagePreview(content []byte, length int) string {
+	var text string
+	for _, b := range transcriptBlocks(content) {
+		if b.Kind == blockText {
+			text = b.Text
+			break
+		}
+		if text == "" {
+			text = b.Text
+		}
+	}
+	text = strings.Join(strings.FieldsFunc(text, unicode.IsSpace), " ")
+	if runes := []rune(text); len(runes) > length {
+		return strings.TrimRightFunc(string(runes[:length-1]), unicode.IsSpace) + "…"
+	}
+	return text
+}
+
+func registerGraphQLHandlers(d registrar, svc *Service) {
+	// GraphQLQuery filters by AccessibleSet() like ListAllAgents: the
+	// schema only resolves the caller's workspaces, so a query about any
+	// other reads as null rather than failing.
+	registerSetFiltered(d, "GraphQLQuery", func(ctx context.Context, _ userid.UserID, req *leapmuxv1.InnerRpcRequest, sender channel.ResponseWriter) {
+		var r leapmuxv1.GraphQLQueryRequest
+		if err := unmarshalRequest(req, &r); err != nil {
+			sendInvalidArgument(sender, "invalid request")
+			return
+		}
+		if len(r.GetQuery()) > maxGraphQLQueryLen {
+			sendInvalidArgument(sender, "query is too long")
+			return
+		}
+		var vars map[string]any
+		if r.GetVariablesJson() != "" {
+			dec := json.NewDecoder(strings.NewReader(r.GetVariablesJson()))
+			dec.UseNumber()
+			if err := dec.Decode(&vars); err != nil {
+				sendInvalidArgument(sender, "variables_json must be a JSON object")
+				return
+			}
+		}
+
+		accessible := svc.AuthorizerFor(sender.ChannelID()).AccessibleSet()
+		resp := svc.graphQLSchema(accessible).Execute(ctx, graphql.Request{
+			Query:         r.GetQuery(),
+			Variables:     vars,
+			OperationName: r.GetOperationName(),
+		})
+		out, err := json.Marshal(resp)
+		if err != nil {
+			slog.Error("failed to encode graphql response", "error", err)
+			sendInternalError(sender, "failed to run query")
+			return
+		}
+		sendProtoResponse(sender, &leapmuxv1.GraphQLQueryResponse{ResultJson: out})
+	})
+}
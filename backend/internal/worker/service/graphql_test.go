@@ -0,0 +1,175 @@
+package service
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	leapmuxv1 "github.com/leapmux/leapmux/generated/proto/leapmux/v1"
+	"github.com/leapmux/leapmux/internal/worker/agent"
+	db "github.com/leapmux/leapmux/internal/worker/generated/db"
+)
+
+func TestGraphQLQuery_DashboardInOneRoundTrip(t *testing.T) {
+	svc, d, w := setupTestService(t, withWorkspaces("ws-1", "ws-2"))
+	ctx := context.Background()
+	for _, a := range []struct{ id, workspace string }{
+		{"agent-a", "ws-1"},
+		{"agent-b", "ws-1"},
+		{"agent-closed", "ws-1"},
+		{"agent-x", "ws-other"},
+	} {
+		require.NoError(t, svc.Queries.CreateAgent(ctx, db.CreateAgentParams{
+			ID: a.id, WorkspaceID: a.workspace, AgentProvider: claudeCode,
+		}))
+	}
+	require.NoError(t, svc.Queries.CloseAgent(ctx, "agent-closed"))
+
+	sink := svc.Output.NewSink("agent-a", claudeCode)
+	require.NoError(t, sink.PersistMessage(leapmuxv1.MessageSource_MESSAGE_SOURCE_USER,
+		[]byte(`{"content":"Fix   the\nflaky test"}`), agent.SpanInfo{}))
+	require.NoError(t, sink.PersistMessage(leapmuxv1.MessageSource_MESSAGE_SOURCE_AGENT,
+		[]byte(`{"type":"assistant","message":{"content":[{"type":"text","text":"The test races on the clock; I pinned it."}]}}`), agent.SpanInfo{}))
+	require.NoError(t, sink.PersistTurnEnd([]byte(`{"type":"result"}`),
+		agent.SpanInfo{Usage: &agent.MessageUsage{InputTokens: 10, OutputTokens: 4, CostUSD: 0.25, Turn: true}}))
+
+	dispatch(d, "GraphQLQuery", &leapmuxv1.GraphQLQueryRequest{Query: `
+		query Dashboard($preview: Int) {
+			workspaces {
+				id
+				agentCount
+				runningAgentCount
+				lastMessage { source preview(length: $preview) }
+				usage { turns costUsd }
+			}
+		}`, VariablesJson: `{"preview": 20}`}, w)
+	require.Empty(t, w.errors)
+	assert.JSONEq(t, `{"data":{"workspaces":[
+		{"id":"ws-1","agentCount":2,"runningAgentCount":0,
+		 "lastMessage":{"source":"agent","preview":"result"},
+		 "usage":{"turns":1,"costUsd":0.25}},
+		{"id":"ws-2","agentCount":0,"runningAgentCount":0,"lastMessage":null,
+		 "usage":{"turns":0,"costUsd":0}}
+	]}}`, string(decodeResponse[leapmuxv1.GraphQLQueryResponse](t, w).GetResultJson()))
+
+	w.responses = nil
+	dispatch(d, "GraphQLQuery", &leapmuxv1.GraphQLQueryRequest{Query: `{
+		workspace(id: "ws-1") {
+			open: agents { id }
+			all: agents(includeClosed: true) { id closedAt }
+		}
+		agent(id: "agent-a") {
+			provider status running messageCount
+			messages(last: 2) { seq source preview(length: 25) }
+			usage { inputTokens outputTokens }
+		}
+	}`}, w)
+	require.Empty(t, w.errors)
+	var got struct {
+		Data struct {
+			Workspace struct {
+				Open []struct{ ID string }
+				All  []struct {
+					ID       string
+					ClosedAt *string
+				}
+			}
+			Agent struct {
+				Provider     string
+				Status       string
+				Running      bool
+				MessageCount int
+				Messages     []struct {
+					Seq     int64
+					Source  string
+					Preview string
+				}
+				Usage struct{ InputTokens, OutputTokens int64 }
+			}
+		}
+		Errors []any
+	}
+	require.NoError(t, json.Unmarshal(decodeResponse[leapmuxv1.GraphQLQueryResponse](t, w).GetResultJson(), &got))
+	require.Empty(t, got.Errors)
+	assert.Len(t, got.Data.Workspace.Open, 2)
+	assert.Len(t, got.Data.Workspace.All, 3)
+	a := got.Data.Agent
+	assert.Equal(t, "claude-code", a.Provider)
+	assert.False(t, a.Running)
+	assert.Equal(t, 3, a.MessageCount)
+	require.Len(t, a.Messages, 2, "the last two, oldest first")
+	assert.Less(t, a.Messages[0].Seq, a.Messages[1].Seq)
+	assert.Equal(t, "The test races on the cl…", a.Messages[0].Preview)
+	assert.Equal(t, int64(10), a.Usage.InputTokens)
+	assert.Equal(t, int64(4), a.Usage.OutputTokens)
+}
+
+func TestGraphQLQuery_OnlyResolvesAccessibleWorkspaces(t *testing.T) {
+	svc, d, w := setupTestService(t, withWorkspaces("ws-1"))
+	seedAgent(t, svc, "agent-x", "ws-other")
+
+	dispatch(d, "GraphQLQuery", &leapmuxv1.GraphQLQueryRequest{Query: `{
+		workspaces { id }
+		workspace(id: "ws-other") { id }
+		agent(id: "agent-x") { id }
+	}`}, w)
+	require.Empty(t, w.errors)
+	assert.JSONEq(t, `{"data":{"workspaces":[{"id":"ws-1"}],"workspace":null,"agent":null}}`,
+		string(decodeResponse[leapmuxv1.GraphQLQueryResponse](t, w).GetResultJson()))
+}
+
+func TestGraphQLQuery_BoundsTheResultSize(t *testing.T) {
+	svc, d, w := setupTestService(t, withWorkspaces("ws-1"))
+	seedAgent(t, svc, "agent-a", "ws-1")
+	sink := svc.Output.NewSink("agent-a", claudeCode)
+	text := strings.Repeat("x", 1<<20)
+	for range 5 {
+		require.NoError(t, sink.PersistMessage(leapmuxv1.MessageSource_MESSAGE_SOURCE_USER,
+			[]byte(`{"content":"`+text+`"}`), agent.SpanInfo{}))
+	}
+
+	dispatch(d, "GraphQLQuery", &leapmuxv1.GraphQLQueryRequest{Query: `{ agent(id: "agent-a") { messages { content } } }`}, w)
+	require.Empty(t, w.errors)
+	out := decodeResponse[leapmuxv1.GraphQLQueryResponse](t, w).GetResultJson()
+	assert.Less(t, len(out), 1024, "no data past the budget")
+	var got struct {
+		Data   any
+		Errors []struct{ Message string }
+	}
+	require.NoError(t, json.Unmarshal(out, &got))
+	assert.Nil(t, got.Data)
+	require.Len(t, got.Errors, 1)
+	assert.Equal(t, fmt.Sprintf("the result is larger than %d bytes", maxGraphQLResultBytes), got.Errors[0].Message)
+
+	// A page that fits still comes back whole.
+	w.responses = nil
+	dispatch(d, "GraphQLQuery", &leapmuxv1.GraphQLQueryRequest{Query: `{ agent(id: "agent-a") { messages(last: 3) { content } } }`}, w)
+	require.Empty(t, w.errors)
+	var page struct {
+		Data struct {
+			Agent struct{ Messages []struct{ Content string } }
+		}
+		Errors []any
+	}
+	require.NoError(t, json.Unmarshal(decodeResponse[leapmuxv1.GraphQLQueryResponse](t, w).GetResultJson(), &page))
+	assert.Empty(t, page.Errors)
+	assert.Len(t, page.Data.Agent.Messages, 3)
+}
+
+func TestGraphQLQuery_ReportsQueryErrorsInTheBody(t *testing.T) {
+	_, d, w := setupTestService(t, withWorkspaces("ws-1"))
+
+	dispatch(d, "GraphQLQuery", &leapmuxv1.GraphQLQueryRequest{Query: `{ workspaces { secrets } }`}, w)
+	require.Empty(t, w.errors)
+	assert.JSONEq(t, `{"errors":[{"message":"Workspace has no field \"secrets\"","locations":[{"line":1,"column":16}]}]}`,
+		string(decodeResponse[leapmuxv1.GraphQLQueryResponse](t, w).GetResultJson()))
+
+	w.responses = nil
+	dispatch(d, "GraphQLQuery", &leapmuxv1.GraphQLQueryRequest{Query: `{ workspaces { id } }`, VariablesJson: `[1]`}, w)
+	require.Len(t, w.errors, 1)
+}
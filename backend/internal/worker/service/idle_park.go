@@ -0,0 +1,168 @@
+package service
+
+import (
+	"context"
+	"log/slog"
+	"slices"
+	"sync"
+	"time"
+
+	leapmuxv1 "github.com/leapmux/leapmux/generated/proto/leapmux/v1"
+	"github.com/leapmux/leapmux/internal/util/periodic"
+	db "github.com/leapmux/leapmux/internal/worker/generated/db"
+)
+
+// idleParkInterval is how often running agents are checked for idleness.
+const idleParkInterval = time.Minute
+
+// IdleParkPolicy stops agent subprocesses that have sat idle, so a worker
+// hosting many dormant agents does not keep all of them resident. A parked
+// agent is INACTIVE, not closed: the next message resumes its session
+// through ensureAgentRunning.
+type IdleParkPolicy struct {
+	// After is how long an agent must be idle before it is parked. Zero
+	// disables parking.
+	After time.Duration
+	// ExcludedProviders are never parked.
+	ExcludedProviders []leapmuxv1.AgentProvider
+	// ExcludedWorkspaces lists workspace ids whose agents are never parked.
+	ExcludedWorkspaces []string
+}
+
+// excludes reports whether dbAgent is exempt from parking.
+func (p IdleParkPolicy) excludes(dbAgent *db.Agent) bool {
+	return slices.Contains(p.ExcludedProviders, dbAgent.AgentProvider) ||
+		slices.Contains(p.ExcludedWorkspaces, dbAgent.WorkspaceID)
+}
+
+// agentActivity is the idle-parking view of one agent: when it last did
+// anything, and whether a turn is in flight. An agent mid-turn is never
+// idle, however long a tool call keeps it quiet.
+type agentActivity struct {
+	mu     sync.Mutex
+	last   time.Time
+	inTurn bool
+	// started is when the turn in flight began; input sent mid-turn joins
+	// it rather than starting another.
+	started time.Time
+}
+
+func (h *OutputHandler) agentActivity(agentID string) *agentActivity {
+	if v, ok := h.activity.Load(agentID); ok {
+		return v.(*agentActivity)
+	}
+	v, _ := h.activity.LoadOrStore(agentID, &agentActivity{last: h.now()})
+	return v.(*agentActivity)
+}
+
+// touchAgent records output or other activity from agentID.
+func (h *OutputHandler) touchAgent(agentID string) {
+	a := h.agentActivity(agentID)
+	a.mu.Lock()
+	a.last = h.now()
+	a.mu.Unlock()
+}
+
+// beginAgentTurn records that input was delivered to agentID.
+func (h *OutputHandler) beginAgentTurn(agentID string) {
+	a := h.agentActivity(agentID)
+	a.mu.Lock()
+	a.last = h.now()
+	if !a.inTurn {
+		a.started = a.last
+	}
+	a.inTurn = true
+	a.mu.Unlock()
+}
+
+// endAgentTurn records that agentID finished its turn.
+func (h *OutputHandler) endAgentTurn(agentID string) {
+	a := h.agentActivity(agentID)
+	a.mu.Lock()
+	a.last = h.now()
+	a.inTurn = false
+	a.mu.Unlock()
+}
+
+// ResetAgentActivity forgets agentID's activity. The exit handler calls it
+// so a turn the subprocess died in does not pin its next run as busy.
+func (h *OutputHandler) ResetAgentActivity(agentID string) {
+	h.activity.Delete(agentID)
+}
+
+// agentIdleFor returns how long agentID has been idle, or false when it is
+// mid-turn.
+func (h *OutputHandler) agentIdleFor(agentID string) (time.Duration, bool) {
+	a := h.agentActivity(agentID)
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	if a.inTurn {
+		return 0, false
+	}
+	return h.now().Sub(a.last), true
+}
+
+// sendAgentInput delivers a user turn to a running agent, marking the turn
+// in flight for idle parking. It refuses the turn while an emergency stop
+// is in effect.
+func (svc *Service) sendAgentInput(agentID, content string, attachments []*leapmuxv1.Attachment) error {
+	if err := svc.emergencyStopErr(); err != nil {
+		return err
+	}
+	svc.Output.beginAgentTurn(agentID)
+	return svc.Agents.SendInput(agentID, content, attachments)
+}
+
+// StartIdleParkLoop starts the background loop that parks idle agents. It
+// does nothing when the policy is disabled.
+func (svc *Service) StartIdleParkLoop(ctx context.Context) {
+	if svc.IdlePark.After <= 0 {
+		return
+	}
+	periodic.Start(ctx, periodic.Schedule{Interval: idleParkInterval, SkipFirstRun: true}, func(context.Context) {
+		svc.ParkIdleAgents()
+	})
+}
+
+// ParkIdleAgents stops every running agent that has been idle for at least
+// IdlePark.After and is not excluded.
+func (svc *Service) ParkIdleAgents() {
+	for _, agentID := range svc.Agents.ListAgentIDs() {
+		if idle, ok := svc.Output.agentIdleFor(agentID); !ok || idle < svc.IdlePark.After {
+			continue
+		}
+		svc.parkAgent(agentID)
+	}
+}
+
+// parkAgent stops agentID's subprocess and reports it INACTIVE. It holds
+// the per-agent lifecycle lock so a concurrent auto-start or restart is
+// not torn down, and re-checks idleness under it.
+func (svc *Service) parkAgent(agentID string) {
+	unlock := svc.Agents.LockAgent(agentID)
+	defer unlock()
+
+	if !svc.Agents.HasAgent(agentID) {
+		return
+	}
+	if _, _, _, starting := svc.AgentStartup.status(agentID); starting {
+		return
+	}
+	if idle, ok := svc.Output.agentIdleFor(agentID); !ok || idle < svc.IdlePark.After {
+		return
+	}
+	dbAgent, err := svc.Queries.GetAgentByID(bgCtx(), agentID)
+	if err != nil {
+		slog.Warn("idle park: failed to load agent", "agent_id", agentID, "error", err)
+		return
+	}
+	if svc.IdlePark.excludes(&dbAgent) {
+		return
+	}
+
+	// Discard the closing streams' output: the stop is ours, not a crash,
+	// and must not leave an error message in the chat.
+	svc.Agents.DiscardOutputAndStopAgent(agentID)
+	svc.broadcastAgentInactive(&dbAgent)
+	slog.Info("idle park: parked agent", "agent_id", agentID, "idle_after", svc.IdlePark.After)
+}
@@ -0,0 +1,81 @@
+package service
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	leapmuxv1 "github.com/leapmux/leapmux/generated/proto/leapmux/v1"
+	"github.com/leapmux/leapmux/internal/worker/agent"
+)
+
+// startIdleParkAgent seeds agent-1 and registers a mock process for it,
+// with the handler clock driven by the returned pointer.
+func startIdleParkAgent(t *testing.T, svc *Service) *time.Time {
+	t.Helper()
+	clock := time.Date(2026, time.March, 2, 9, 0, 0, 0, time.UTC)
+	svc.Output.now = func() time.Time { return clock }
+	row := seedGuardedAgent(t, svc, agent.PermissionModeDefault)
+	_, err := svc.Agents.MockStartAgent(context.Background(), agent.Options{AgentID: row.ID, WorkingDir: row.WorkingDir},
+		svc.Output.NewSink(row.ID, row.AgentProvider))
+	require.NoError(t, err)
+	t.Cleanup(func() { svc.Agents.StopAgent(row.ID) })
+	svc.Output.touchAgent(row.ID)
+	return &clock
+}
+
+func TestParkIdleAgents_StopsIdleAgent(t *testing.T) {
+	svc, _, _ := setupTestService(t, withWorkspaces("ws-1"))
+	svc.IdlePark = IdleParkPolicy{After: 30 * time.Minute}
+	clock := startIdleParkAgent(t, svc)
+
+	*clock = clock.Add(29 * time.Minute)
+	svc.ParkIdleAgents()
+	require.True(t, svc.Agents.HasAgent("agent-1"), "not idle long enough yet")
+
+	*clock = clock.Add(time.Minute)
+	svc.ParkIdleAgents()
+	assert.False(t, svc.Agents.HasAgent("agent-1"))
+
+	row, err := svc.Queries.GetAgentByID(context.Background(), "agent-1")
+	require.NoError(t, err)
+	assert.False(t, row.ClosedAt.Valid, "a parked agent stays open for resume")
+}
+
+func TestParkIdleAgents_SkipsAgentMidTurn(t *testing.T) {
+	svc, _, _ := setupTestService(t, withWorkspaces("ws-1"))
+	svc.IdlePark = IdleParkPolicy{After: 30 * time.Minute}
+	clock := startIdleParkAgent(t, svc)
+
+	// A long, silent tool call keeps the turn open.
+	svc.Output.beginAgentTurn("agent-1")
+	*clock = clock.Add(2 * time.Hour)
+	svc.ParkIdleAgents()
+	require.True(t, svc.Agents.HasAgent("agent-1"))
+
+	// The idle clock starts at the turn's end, not its start.
+	svc.Output.endAgentTurn("agent-1")
+	*clock = clock.Add(10 * time.Minute)
+	svc.ParkIdleAgents()
+	assert.True(t, svc.Agents.HasAgent("agent-1"))
+}
+
+func TestParkIdleAgents_HonorsExclusions(t *testing.T) {
+	for name, policy := range map[string]IdleParkPolicy{
+		"provider":  {After: time.Minute, ExcludedProviders: []leapmuxv1.AgentProvider{claudeProvider}},
+		"workspace": {After: time.Minute, ExcludedWorkspaces: []string{"ws-1"}},
+	} {
+		t.Run(name, func(t *testing.T) {
+			svc, _, _ := setupTestService(t, withWorkspaces("ws-1"))
+			svc.IdlePark = policy
+			clock := startIdleParkAgent(t, svc)
+
+			*clock = clock.Add(time.Hour)
+			svc.ParkIdleAgents()
+			assert.True(t, svc.Agents.HasAgent("agent-1"))
+		})
+	}
+}
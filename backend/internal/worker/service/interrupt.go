@@ -0,0 +1,35 @@
+package service
+
+import (
+	"log/slog"
+
+	leapmuxv1 "github.com/leapmux/leapmux/generated/proto/leapmux/v1"
+	"github.com/leapmux/leapmux/internal/util/userid"
+)
+
+// interruptReasonNames spell each InterruptReason the way the worker log
+// and the agent_interrupted notification do. Unspecified is the user.
+var interruptReasonNames = map[leapmuxv1.InterruptReason]string{
+	leapmuxv1.InterruptReason_INTERRUPT_REASON_UNSPECIFIED: "user",
+	leapmuxv1.InterruptReason_INTERRUPT_REASON_USER:        "user",
+	leapmuxv1.InterruptReason_INTERRUPT_REASON_WATCHDOG:    "watchdog",
+	leapmuxv1.InterruptReason_INTERRUPT_REASON_BUDGET:      "budget",
+	leapmuxv1.InterruptReason_INTERRUPT_REASON_POLICY:      "policy",
+}
+
+// interruptAgent interrupts agentID's current turn and logs who asked and
+// why. userID is zero when the worker interrupts on its own; detail is
+// free text for the log. It blocks until the agent answers, so callers
+// off an RPC run it on their own goroutine.
+func (svc *Service) interruptAgent(agentID string, userID userid.UserID, reason leapmuxv1.InterruptReason, detail string) error {
+	interrupt := svc.Agents.Interrupt
+	if svc.interruptAgentFn != nil {
+		interrupt = svc.interruptAgentFn
+	}
+	if err := interrupt(agentID); err != nil {
+		return err
+	}
+	slog.Info("agent interrupted",
+		"agent_id", agentID, "user_id", userID, "reason", interruptReasonNames[reason], "detail", detail)
+	return nil
+}
@@ -0,0 +1,49 @@
+package service
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	leapmuxv1 "github.com/leapmux/leapmux/generated/proto/leapmux/v1"
+	"github.com/leapmux/leapmux/internal/worker/agent"
+)
+
+func TestInterruptAgent_RecordsReason(t *testing.T) {
+	svc, d, _ := setupTestService(t, withWorkspaces("ws-1"))
+	seedGuardedAgent(t, svc, agent.PermissionModeDefault)
+	var calls []string
+	svc.interruptAgentFn = func(agentID string) error {
+		calls = append(calls, agentID)
+		return nil
+	}
+	interrupted := func() []map[string]interface{} {
+		return findNotificationsByType(readAllNotifications(t, svc.Queries, "agent-1"), agent.NotificationTypeAgentInterrupted)
+	}
+
+	// The user's own interrupt leaves the chat to the provider.
+	w := newTestWriter()
+	dispatch(d, "InterruptAgent", &leapmuxv1.InterruptAgentRequest{AgentId: "agent-1"}, w)
+	require.Empty(t, w.errors)
+	assert.Empty(t, interrupted())
+
+	w = newTestWriter()
+	dispatch(d, "InterruptAgent", &leapmuxv1.InterruptAgentRequest{
+		AgentId:    "agent-1",
+		Reason:     "nightly spend cap",
+		ReasonKind: leapmuxv1.InterruptReason_INTERRUPT_REASON_BUDGET,
+	}, w)
+	require.Empty(t, w.errors)
+	notes := interrupted()
+	require.Len(t, notes, 1)
+	assert.Equal(t, "budget", notes[0]["reason"])
+	assert.Equal(t, "nightly spend cap", notes[0]["detail"])
+
+	w = newTestWriter()
+	dispatch(d, "InterruptAgent", &leapmuxv1.InterruptAgentRequest{AgentId: "agent-1", ReasonKind: 99}, w)
+	require.Len(t, w.errors, 1)
+	assert.Equal(t, codeInvalidArgument, w.errors[0].code)
+	assert.Len(t, interrupted(), 1)
+	assert.Equal(t, []string{"agent-1", "agent-1"}, calls)
+}
@@ -0,0 +1,46 @@
+package service
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+
+	leapmuxv1 "github.com/leapmux/leapmux/generated/proto/leapmux/v1"
+	"github.com/leapmux/leapmux/internal/errcode"
+	"github.com/leapmux/leapmux/internal/logging"
+	"github.com/leapmux/leapmux/internal/util/userid"
+	"github.com/leapmux/leapmux/internal/worker/channel"
+	"google.golang.org/grpc/codes"
+)
+
+// registerLogLevelsHandlers exposes the worker process's runtime log
+// levels. They are process-wide, so a worker embedded in a solo Hub shares
+// them with the Hub.
+func registerLogLevelsHandlers(d ownerOnlyRegistrar, svc *Service) {
+	d.Register("GetLogLevels", func(_ context.Context, _ userid.UserID, _ *leapmuxv1.InnerRpcRequest, sender channel.ResponseWriter) {
+		sendProtoResponse(sender, &leapmuxv1.GetLogLevelsResponse{Levels: logging.LogLevelsProto()})
+	})
+
+	d.Register("SetLogLevels", func(ctx context.Context, _ userid.UserID, req *leapmuxv1.InnerRpcRequest, sender channel.ResponseWriter) {
+		var r leapmuxv1.SetLogLevelsRequest
+		if err := unmarshalRequest(req, &r); err != nil {
+			sendInvalidArgument(sender, "invalid request")
+			return
+		}
+		if id := r.GetCaptureAgentId(); id != "" && r.GetCaptureSeconds() > 0 {
+			if _, err := svc.Queries.GetAgentByID(ctx, id); err != nil {
+				if errors.Is(err, sql.ErrNoRows) {
+					sendCodedError(sender, codes.NotFound, errcode.Wrap(errcode.AgentNotFound, errors.New("agent not found")))
+				} else {
+					sendInternalError(sender, "failed to look up agent")
+				}
+				return
+			}
+		}
+		if err := logging.ApplyLogLevels(&r); err != nil {
+			sendInvalidArgument(sender, err.Error())
+			return
+		}
+		sendProtoResponse(sender, &leapmuxv1.SetLogLevelsResponse{Levels: logging.LogLevelsProto()})
+	})
+}
@@ -0,0 +1,52 @@
+package service
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/protobuf/proto"
+
+	leapmuxv1 "github.com/leapmux/leapmux/generated/proto/leapmux/v1"
+	"github.com/leapmux/leapmux/internal/logging"
+	"github.com/leapmux/leapmux/internal/worker/agent"
+)
+
+func TestLogLevelsRPC(t *testing.T) {
+	svc, d, w := setupTestService(t, withWorkspaces("ws-1"))
+	seedGuardedAgent(t, svc, agent.PermissionModeDefault)
+	t.Cleanup(func() {
+		logging.ClearComponentLevel("worker.agent")
+		logging.CaptureAgent("agent-1", 0)
+	})
+
+	dispatch(d, "SetLogLevels", &leapmuxv1.SetLogLevelsRequest{
+		Components:     map[string]string{"worker.agent": "debug"},
+		CaptureAgentId: "agent-1",
+		CaptureSeconds: 60,
+	}, w)
+	require.Empty(t, w.errors)
+	require.Len(t, w.responses, 1)
+	var resp leapmuxv1.SetLogLevelsResponse
+	require.NoError(t, proto.Unmarshal(w.responses[0].GetPayload(), &resp))
+	assert.Equal(t, "debug", resp.GetLevels().GetComponents()["worker.agent"])
+	require.Len(t, resp.GetLevels().GetAgentCaptures(), 1)
+	assert.Equal(t, "agent-1", resp.GetLevels().GetAgentCaptures()[0].GetAgentId())
+
+	w = newTestWriter()
+	dispatch(d, "GetLogLevels", &leapmuxv1.GetLogLevelsRequest{}, w)
+	require.Empty(t, w.errors)
+	var got leapmuxv1.GetLogLevelsResponse
+	require.NoError(t, proto.Unmarshal(w.responses[0].GetPayload(), &got))
+	assert.Equal(t, "debug", got.GetLevels().GetComponents()["worker.agent"])
+
+	w = newTestWriter()
+	dispatch(d, "SetLogLevels", &leapmuxv1.SetLogLevelsRequest{CaptureAgentId: "missing", CaptureSeconds: 60}, w)
+	require.Len(t, w.errors, 1)
+	assert.Equal(t, codeNotFound, w.errors[0].code)
+
+	w = newTestWriter()
+	dispatch(d, "SetLogLevels", &leapmuxv1.SetLogLevelsRequest{Components: map[string]string{"worker.agent": "loud"}}, w)
+	require.Len(t, w.errors, 1)
+	assert.Equal(t, codeInvalidArgument, w.errors[0].code)
+}
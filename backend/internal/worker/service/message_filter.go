@@ -0,0 +1,117 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"math"
+	"time"
+
+	"google.golang.org/protobuf/proto"
+
+	leapmuxv1 "github.com/leapmux/leapmux/generated/proto/leapmux/v1"
+	"github.com/leapmux/leapmux/internal/util/sqltime"
+	db "github.com/leapmux/leapmux/internal/worker/generated/db"
+)
+
+// allMessageSources is the source mask that keeps every source.
+const allMessageSources = int64(-1)
+
+// messageFilterUnbounded is the until bound of a filter without one: later
+// than any created_at the worker writes.
+var messageFilterUnbounded = time.Date(9999, 12, 31, 0, 0, 0, 0, time.UTC)
+
+// messageFilter is a MessageFilter resolved to the arguments of the filtered
+// page queries.
+type messageFilter struct {
+	sourceMask int64
+	onlyErrors bool
+	onlyUser   bool
+	hasToolUse bool
+	since      time.Time
+	until      time.Time
+}
+
+// resolveMessageFilter validates f and folds it into query arguments. An
+// unset or empty filter resolves to nil, which pages with the unfiltered
+// queries. The returned error is safe to show the caller.
+func resolveMessageFilter(f *leapmuxv1.MessageFilter) (*messageFilter, error) {
+	if f == nil || proto.Size(f) == 0 {
+		return nil, nil
+	}
+	resolved := &messageFilter{
+		sourceMask: allMessageSources,
+		onlyErrors: f.GetOnlyErrors(),
+		onlyUser:   f.GetOnlyUser(),
+		hasToolUse: f.GetHasToolUse(),
+		until:      messageFilterUnbounded,
+	}
+	if len(f.GetSources()) > 0 {
+		resolved.sourceMask = 0
+		for _, source := range f.GetSources() {
+			if _, ok := leapmuxv1.MessageSource_name[int32(source)]; !ok || source == leapmuxv1.MessageSource_MESSAGE_SOURCE_UNSPECIFIED {
+				return nil, errors.New("unknown message source")
+			}
+			resolved.sourceMask |= 1 << source
+		}
+	}
+	if f.GetOnlyNotifications() {
+		resolved.sourceMask &= 1 << leapmuxv1.MessageSource_MESSAGE_SOURCE_LEAPMUX
+	}
+	if f.GetSince() != "" {
+		t, err := time.Parse(time.RFC3339, f.GetSince())
+		if err != nil {
+			return nil, errors.New("since must be an RFC 3339 time")
+		}
+		resolved.since = t
+	}
+	if f.GetUntil() != "" {
+		t, err := time.Parse(time.RFC3339, f.GetUntil())
+		if err != nil {
+			return nil, errors.New("until must be an RFC 3339 time")
+		}
+		resolved.until = t
+	}
+	if !resolved.since.Before(resolved.until) {
+		return nil, errors.New("since must be before until")
+	}
+	return resolved, nil
+}
+
+// fetchFilteredMessagePageRows is fetchMessagePageRows with a filter applied.
+// The filtered queries take both seq bounds, so each mode only sets the one
+// it pages from; rows come back in the same natural order.
+func (svc *Service) fetchFilteredMessagePageRows(ctx context.Context, agentID string, plan messagePagePlan, f *messageFilter, limit int64) ([]db.Message, error) {
+	after, before := int64(0), int64(math.MaxInt64)
+	switch plan.mode {
+	case messagePageAscending:
+		after = plan.bound
+	case messagePageBefore:
+		before = plan.bound
+	}
+	if plan.mode.descending() {
+		return svc.Queries.ListFilteredMessagesByAgentIDReverse(ctx, db.ListFilteredMessagesByAgentIDReverseParams{
+			AgentID:    agentID,
+			AfterSeq:   after,
+			BeforeSeq:  before,
+			SourceMask: f.sourceMask,
+			OnlyErrors: f.onlyErrors,
+			OnlyUser:   f.onlyUser,
+			HasToolUse: f.hasToolUse,
+			Since:      sqltime.NewSQLiteTime(f.since),
+			Until:      sqltime.NewSQLiteTime(f.until),
+			RowLimit:   limit,
+		})
+	}
+	return svc.Queries.ListFilteredMessagesByAgentID(ctx, db.ListFilteredMessagesByAgentIDParams{
+		AgentID:    agentID,
+		AfterSeq:   after,
+		BeforeSeq:  before,
+		SourceMask: f.sourceMask,
+		OnlyErrors: f.onlyErrors,
+		OnlyUser:   f.onlyUser,
+		HasToolUse: f.hasToolUse,
+		Since:      sqltime.NewSQLiteTime(f.since),
+		Until:      sqltime.NewSQLiteTime(f.until),
+		RowLimit:   limit,
+	})
+}
@@ -0,0 +1,115 @@
+package service
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/protobuf/proto"
+
+	leapmuxv1 "github.com/leapmux/leapmux/generated/proto/leapmux/v1"
+	"github.com/leapmux/leapmux/internal/util/sqltime"
+	db "github.com/leapmux/leapmux/internal/worker/generated/db"
+)
+
+func TestListAgentMessages_Filter(t *testing.T) {
+	ctx := context.Background()
+	svc, d, _ := setupTestService(t, withWorkspaces("ws-1"))
+	require.NoError(t, svc.Queries.CreateAgent(ctx, db.CreateAgentParams{
+		ID: "agent-1", WorkspaceID: "ws-1", WorkingDir: "/tmp", HomeDir: "/tmp",
+	}))
+
+	base := time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)
+	seed := func(id string, minute int, p db.CreateMessageParams) int64 {
+		t.Helper()
+		p.ID, p.AgentID, p.Content = id, "agent-1", []byte("{}")
+		p.AgentProvider = leapmuxv1.AgentProvider_AGENT_PROVIDER_CODEX
+		p.CreatedAt = sqltime.NewSQLiteTime(base.Add(time.Duration(minute) * time.Minute))
+		seq, err := createMessageRow(ctx, svc.Queries, p)
+		require.NoError(t, err)
+		return seq
+	}
+	typed := seed("typed", 0, db.CreateMessageParams{Source: leapmuxv1.MessageSource_MESSAGE_SOURCE_USER, MarkType: leapmuxv1.MarkType_MARK_TYPE_USER_MESSAGE})
+	text := seed("text", 1, db.CreateMessageParams{Source: leapmuxv1.MessageSource_MESSAGE_SOURCE_AGENT, SpanID: "item-1", SpanType: "agentMessage"})
+	call := seed("call", 2, db.CreateMessageParams{Source: leapmuxv1.MessageSource_MESSAGE_SOURCE_AGENT, SpanID: "item-2", SpanType: "commandExecution"})
+	result := seed("result", 3, db.CreateMessageParams{Source: leapmuxv1.MessageSource_MESSAGE_SOURCE_AGENT, SpanID: "item-2", SpanType: "commandExecution"})
+	notice := seed("notice", 4, db.CreateMessageParams{Source: leapmuxv1.MessageSource_MESSAGE_SOURCE_LEAPMUX})
+	failed := seed("failed", 5, db.CreateMessageParams{Source: leapmuxv1.MessageSource_MESSAGE_SOURCE_USER, MarkType: leapmuxv1.MarkType_MARK_TYPE_USER_MESSAGE})
+	require.NoError(t, svc.Queries.SetMessageDeliveryError(ctx, db.SetMessageDeliveryErrorParams{
+		DeliveryError: "agent exited", ID: "failed", AgentID: "agent-1",
+	}))
+
+	list := func(f *leapmuxv1.MessageFilter, anchor leapmuxv1.MessagePageAnchor, cursor int64, limit int32) ([]int64, bool) {
+		t.Helper()
+		w := newTestWriter()
+		dispatch(d, "ListAgentMessages", &leapmuxv1.ListAgentMessagesRequest{
+			AgentId: "agent-1", Anchor: anchor, CursorSeq: cursor, Limit: limit, Filter: f,
+		}, w)
+		require.Empty(t, w.errors)
+		require.Len(t, w.responses, 1)
+		var resp leapmuxv1.ListAgentMessagesResponse
+		require.NoError(t, proto.Unmarshal(w.responses[0].GetPayload(), &resp))
+		seqs := make([]int64, 0, len(resp.GetMessages()))
+		for _, m := range resp.GetMessages() {
+			seqs = append(seqs, m.GetSeq())
+		}
+		return seqs, resp.GetHasMore()
+	}
+	latest := leapmuxv1.MessagePageAnchor_MESSAGE_PAGE_ANCHOR_LATEST
+
+	for name, tc := range map[string]struct {
+		filter *leapmuxv1.MessageFilter
+		want   []int64
+	}{
+		"empty filter":  {&leapmuxv1.MessageFilter{}, []int64{typed, text, call, result, notice, failed}},
+		"sources":       {&leapmuxv1.MessageFilter{Sources: []leapmuxv1.MessageSource{leapmuxv1.MessageSource_MESSAGE_SOURCE_AGENT}}, []int64{text, call, result}},
+		"only errors":   {&leapmuxv1.MessageFilter{OnlyErrors: true}, []int64{failed}},
+		"notifications": {&leapmuxv1.MessageFilter{OnlyNotifications: true}, []int64{notice}},
+		"only user":     {&leapmuxv1.MessageFilter{OnlyUser: true}, []int64{typed, failed}},
+		"tool use":      {&leapmuxv1.MessageFilter{HasToolUse: true}, []int64{call, result}},
+		"time range":    {&leapmuxv1.MessageFilter{Since: "2026-01-01T00:01:00Z", Until: "2026-01-01T00:03:00Z"}, []int64{text, call}},
+		"combined":      {&leapmuxv1.MessageFilter{OnlyUser: true, Since: "2026-01-01T00:01:00Z"}, []int64{failed}},
+		"disjoint": {&leapmuxv1.MessageFilter{
+			Sources:           []leapmuxv1.MessageSource{leapmuxv1.MessageSource_MESSAGE_SOURCE_USER},
+			OnlyNotifications: true,
+		}, []int64{}},
+	} {
+		t.Run(name, func(t *testing.T) {
+			got, _ := list(tc.filter, latest, 0, 0)
+			assert.Equal(t, tc.want, got)
+		})
+	}
+
+	// Paging keeps its meaning under a filter: the bound is a seq, and
+	// has_more counts only matching rows.
+	agentOnly := &leapmuxv1.MessageFilter{Sources: []leapmuxv1.MessageSource{leapmuxv1.MessageSource_MESSAGE_SOURCE_AGENT}}
+	got, more := list(agentOnly, latest, 0, 2)
+	assert.Equal(t, []int64{call, result}, got)
+	assert.True(t, more)
+	got, more = list(agentOnly, leapmuxv1.MessagePageAnchor_MESSAGE_PAGE_ANCHOR_BEFORE, call, 2)
+	assert.Equal(t, []int64{text}, got)
+	assert.False(t, more)
+	got, more = list(agentOnly, leapmuxv1.MessagePageAnchor_MESSAGE_PAGE_ANCHOR_AFTER, text, 1)
+	assert.Equal(t, []int64{call}, got)
+	assert.True(t, more)
+	got, more = list(agentOnly, leapmuxv1.MessagePageAnchor_MESSAGE_PAGE_ANCHOR_OLDEST, 0, 5)
+	assert.Equal(t, []int64{text, call, result}, got)
+	assert.False(t, more)
+}
+
+func TestResolveMessageFilter_Rejects(t *testing.T) {
+	for name, f := range map[string]*leapmuxv1.MessageFilter{
+		"unspecified source": {Sources: []leapmuxv1.MessageSource{leapmuxv1.MessageSource_MESSAGE_SOURCE_UNSPECIFIED}},
+		"unknown source":     {Sources: []leapmuxv1.MessageSource{99}},
+		"bad since":          {Since: "yesterday"},
+		"bad until":          {Until: "2026-01-01"},
+		"empty range":        {Since: "2026-01-02T00:00:00Z", Until: "2026-01-01T00:00:00Z"},
+	} {
+		t.Run(name, func(t *testing.T) {
+			_, err := resolveMessageFilter(f)
+			assert.Error(t, err)
+		})
+	}
+}
@@ -0,0 +1,124 @@
+package service
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/protobuf/proto"
+
+	leapmuxv1 "github.com/leapmux/leapmux/generated/proto/leapmux/v1"
+	"github.com/leapmux/leapmux/internal/util/sqltime"
+	"github.com/leapmux/leapmux/internal/worker/channel"
+	db "github.com/leapmux/leapmux/internal/worker/generated/db"
+)
+
+// seedSourced persists one message from source with content and returns its seq.
+func seedSourced(t *testing.T, svc *Service, id string, source leapmuxv1.MessageSource, content string) int64 {
+	t.Helper()
+	seq, err := createMessageRow(context.Background(), svc.Queries, db.CreateMessageParams{
+		ID:            id,
+		AgentID:       "agent-1",
+		Source:        source,
+		Content:       []byte(content),
+		AgentProvider: leapmuxv1.AgentProvider_AGENT_PROVIDER_CLAUDE_CODE,
+		CreatedAt:     sqltime.NewSQLiteTime(time.Now()),
+	})
+	require.NoError(t, err)
+	return seq
+}
+
+func listRange(t *testing.T, d *channel.Dispatcher, r *leapmuxv1.ListAgentMessagesInRangeRequest) *leapmuxv1.ListAgentMessagesInRangeResponse {
+	t.Helper()
+	w := newTestWriter()
+	dispatch(d, "ListAgentMessagesInRange", r, w)
+	require.Empty(t, w.errors)
+	require.Len(t, w.responses, 1)
+	var resp leapmuxv1.ListAgentMessagesInRangeResponse
+	require.NoError(t, proto.Unmarshal(w.responses[0].GetPayload(), &resp))
+	return &resp
+}
+
+func messageStats(t *testing.T, d *channel.Dispatcher) *leapmuxv1.GetAgentMessageStatsResponse {
+	t.Helper()
+	w := newTestWriter()
+	dispatch(d, "GetAgentMessageStats", &leapmuxv1.GetAgentMessageStatsRequest{AgentId: "agent-1"}, w)
+	require.Empty(t, w.errors)
+	require.Len(t, w.responses, 1)
+	var resp leapmuxv1.GetAgentMessageStatsResponse
+	require.NoError(t, proto.Unmarshal(w.responses[0].GetPayload(), &resp))
+	return &resp
+}
+
+func rangeSeqs(resp *leapmuxv1.ListAgentMessagesInRangeResponse) []int64 {
+	seqs := make([]int64, len(resp.GetMessages()))
+	for i, m := range resp.GetMessages() {
+		seqs[i] = m.GetSeq()
+	}
+	return seqs
+}
+
+func TestGetAgentMessageStats(t *testing.T) {
+	ctx := context.Background()
+	svc, d, _ := setupTestService(t, withWorkspaces("ws-1"))
+	require.NoError(t, svc.Queries.CreateAgent(ctx, db.CreateAgentParams{
+		ID: "agent-1", WorkspaceID: "ws-1", WorkingDir: "/tmp", HomeDir: "/tmp",
+	}))
+
+	empty := messageStats(t, d)
+	assert.Zero(t, empty.GetMessageCount())
+	assert.Zero(t, empty.GetMaxSeq())
+	assert.Empty(t, empty.GetSources())
+
+	seedSourced(t, svc, "m1", leapmuxv1.MessageSource_MESSAGE_SOURCE_USER, "hello")
+	first := seedSourced(t, svc, "m2", leapmuxv1.MessageSource_MESSAGE_SOURCE_AGENT, "abc")
+	seedSourced(t, svc, "m3", leapmuxv1.MessageSource_MESSAGE_SOURCE_AGENT, "defgh")
+	last := seedSourced(t, svc, "m4", leapmuxv1.MessageSource_MESSAGE_SOURCE_USER, "x")
+	_, err := svc.Queries.DeleteMessageByAgentAndID(ctx, db.DeleteMessageByAgentAndIDParams{AgentID: "agent-1", ID: "m1"})
+	require.NoError(t, err)
+
+	stats := messageStats(t, d)
+	assert.Equal(t, int64(3), stats.GetMessageCount())
+	assert.Equal(t, first, stats.GetMinSeq(), "min_seq follows the surviving oldest row")
+	assert.Equal(t, last, stats.GetMaxSeq())
+	assert.Equal(t, int64(9), stats.GetContentBytes())
+	require.Len(t, stats.GetSources(), 2)
+	assert.Equal(t, leapmuxv1.MessageSource_MESSAGE_SOURCE_USER, stats.GetSources()[0].GetSource())
+	assert.Equal(t, int64(1), stats.GetSources()[0].GetMessageCount())
+	assert.Equal(t, int64(1), stats.GetSources()[0].GetContentBytes())
+	assert.Equal(t, leapmuxv1.MessageSource_MESSAGE_SOURCE_AGENT, stats.GetSources()[1].GetSource())
+	assert.Equal(t, int64(2), stats.GetSources()[1].GetMessageCount())
+	assert.Equal(t, int64(8), stats.GetSources()[1].GetContentBytes())
+}
+
+func TestListAgentMessagesInRange(t *testing.T) {
+	ctx := context.Background()
+	svc, d, _ := setupTestService(t, withWorkspaces("ws-1"))
+	require.NoError(t, svc.Queries.CreateAgent(ctx, db.CreateAgentParams{
+		ID: "agent-1", WorkspaceID: "ws-1", WorkingDir: "/tmp", HomeDir: "/tmp",
+	}))
+	for _, id := range []string{"m1", "m2", "m3", "m4", "m5"} {
+		seedSourced(t, svc, id, leapmuxv1.MessageSource_MESSAGE_SOURCE_USER, id)
+	}
+	_, err := svc.Queries.DeleteMessageByAgentAndID(ctx, db.DeleteMessageByAgentAndIDParams{AgentID: "agent-1", ID: "m3"})
+	require.NoError(t, err)
+
+	resp := listRange(t, d, &leapmuxv1.ListAgentMessagesInRangeRequest{AgentId: "agent-1", FromSeq: 2, ToSeq: 4})
+	assert.Equal(t, []int64{2, 4}, rangeSeqs(resp), "both bounds are inclusive and gaps are skipped")
+	assert.False(t, resp.GetHasMore())
+
+	resp = listRange(t, d, &leapmuxv1.ListAgentMessagesInRangeRequest{AgentId: "agent-1", FromSeq: 1, ToSeq: 100, Limit: 2})
+	assert.Equal(t, []int64{1, 2}, rangeSeqs(resp))
+	assert.True(t, resp.GetHasMore())
+
+	resp = listRange(t, d, &leapmuxv1.ListAgentMessagesInRangeRequest{AgentId: "agent-1", FromSeq: 3, ToSeq: 100, Limit: 2})
+	assert.Equal(t, []int64{4, 5}, rangeSeqs(resp))
+	assert.False(t, resp.GetHasMore())
+
+	w := newTestWriter()
+	dispatch(d, "ListAgentMessagesInRange", &leapmuxv1.ListAgentMessagesInRangeRequest{AgentId: "agent-1", FromSeq: 4, ToSeq: 2}, w)
+	require.Len(t, w.errors, 1)
+	assert.Empty(t, w.responses)
+}
@@ -0,0 +1,81 @@
+package service
+
+import (
+	"context"
+	"log/slog"
+
+	leapmuxv1 "github.com/leapmux/leapmux/generated/proto/leapmux/v1"
+	"github.com/leapmux/leapmux/internal/util/ptrconv"
+	"github.com/leapmux/leapmux/internal/worker/agent"
+	db "github.com/leapmux/leapmux/internal/worker/generated/db"
+)
+
+// recordMessageUsage persists the usage a just-created message accounts
+// for. Like sub-agent runs it is bookkeeping beside the transcript, so a
+// failed write is logged rather than failing the message.
+func (h *OutputHandler) recordMessageUsage(agentID, messageID string, usage *agent.MessageUsage) {
+	if err := h.queries.CreateMessageUsage(bgCtx(), db.CreateMessageUsageParams{
+		MessageID:                messageID,
+		AgentID:                  agentID,
+		InputTokens:              usage.InputTokens,
+		OutputTokens:             usage.OutputTokens,
+		CacheCreationInputTokens: usage.CacheCreationInputTokens,
+		CacheReadInputTokens:     usage.CacheReadInputTokens,
+		CostUsd:                  usage.CostUSD,
+		Turn:                     ptrconv.BoolToInt64(usage.Turn),
+	}); err != nil {
+		slog.Warn("failed to record message usage", "agent_id", agentID, "message_id", messageID, "error", err)
+	}
+	h.recordUsageMetrics(agentID, usage)
+}
+
+func messageUsageToProto(usage *agent.MessageUsage) *leapmuxv1.MessageUsage {
+	return &leapmuxv1.MessageUsage{
+		InputTokens:              usage.InputTokens,
+		OutputTokens:             usage.OutputTokens,
+		CacheCreationInputTokens: usage.CacheCreationInputTokens,
+		CacheReadInputTokens:     usage.CacheReadInputTokens,
+		CostUsd:                  usage.CostUSD,
+	}
+}
+
+// attachMessageUsage fills in the usage of each of agentID's msgs that has
+// any. A lookup failure leaves the page without usage rather than failing
+// the read: the transcript itself is intact.
+func (svc *Service) attachMessageUsage(ctx context.Context, agentID string, msgs []*leapmuxv1.AgentChatMessage) {
+	if len(msgs) == 0 {
+		return
+	}
+	minSeq, maxSeq := msgs[0].GetSeq(), msgs[0].GetSeq()
+	for _, m := range msgs[1:] {
+		minSeq = min(minSeq, m.GetSeq())
+		maxSeq = max(maxSeq, m.GetSeq())
+	}
+	rows, err := svc.Queries.ListMessageUsageBySeqRange(ctx, db.ListMessageUsageBySeqRangeParams{
+		AgentID: agentID,
+		MinSeq:  minSeq,
+		MaxSeq:  maxSeq,
+	})
+	if err != nil {
+		slog.Warn("failed to load message usage", "agent_id", agentID, "error", err)
+		return
+	}
+	if len(rows) == 0 {
+		return
+	}
+	byID := make(map[string]*leapmuxv1.MessageUsage, len(rows))
+	for _, row := range rows {
+		byID[row.MessageID] = &leapmuxv1.MessageUsage{
+			InputTokens:              row.InputTokens,
+			OutputTokens:             row.OutputTokens,
+			CacheCreationInputTokens: row.CacheCreationInputTokens,
+			CacheReadInputTokens:     row.CacheReadInputTokens,
+			CostUsd:                  row.CostUsd,
+		}
+	}
+	for _, m := range msgs {
+		if usage, ok := byID[m.GetId()]; ok {
+			m.Usage = usage
+		}
+	}
+}
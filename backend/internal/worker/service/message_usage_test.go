@@ -0,0 +1,39 @@
+package service
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/protobuf/proto"
+
+	leapmuxv1 "github.com/leapmux/leapmux/generated/proto/leapmux/v1"
+	"github.com/leapmux/leapmux/internal/worker/agent"
+)
+
+func TestMessageUsage_PersistedAndListed(t *testing.T) {
+	svc, d, w := setupTestService(t, withWorkspaces("ws-1"))
+	seedModelAgent(t, svc.Queries, "agent-1", "opus")
+	sink := svc.Output.NewSink("agent-1", leapmuxv1.AgentProvider_AGENT_PROVIDER_CLAUDE_CODE)
+
+	require.NoError(t, sink.PersistMessage(leapmuxv1.MessageSource_MESSAGE_SOURCE_AGENT,
+		[]byte(`{"type":"assistant"}`), agent.SpanInfo{Usage: &agent.MessageUsage{InputTokens: 12, OutputTokens: 3}}))
+	require.NoError(t, sink.PersistMessage(leapmuxv1.MessageSource_MESSAGE_SOURCE_AGENT,
+		[]byte(`{"type":"assistant"}`), agent.SpanInfo{}))
+	require.NoError(t, sink.PersistTurnEnd([]byte(`{"type":"result"}`),
+		agent.SpanInfo{Usage: &agent.MessageUsage{OutputTokens: 3, CostUSD: 1.84}}))
+
+	dispatch(d, "ListAgentMessages", &leapmuxv1.ListAgentMessagesRequest{AgentId: "agent-1"}, w)
+	require.Empty(t, w.errors)
+	var resp leapmuxv1.ListAgentMessagesResponse
+	require.NoError(t, proto.Unmarshal(w.responses[0].GetPayload(), &resp))
+	require.Len(t, resp.GetMessages(), 3)
+	assert.Equal(t, int64(12), resp.GetMessages()[0].GetUsage().GetInputTokens())
+	assert.Nil(t, resp.GetMessages()[1].GetUsage())
+	assert.InDelta(t, 1.84, resp.GetMessages()[2].GetUsage().GetCostUsd(), 1e-9)
+
+	dispatch(d, "GetAgentMessage", &leapmuxv1.GetAgentMessageRequest{AgentId: "agent-1", Seq: resp.GetMessages()[2].GetSeq()}, w)
+	var single leapmuxv1.GetAgentMessageResponse
+	require.NoError(t, proto.Unmarshal(w.responses[1].GetPayload(), &single))
+	assert.Equal(t, int64(3), single.GetMessage().GetUsage().GetOutputTokens())
+}
@@ -0,0 +1,355 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"runtime"
+	"time"
+
+	leapmuxv1 "github.com/leapmux/leapmux/generated/proto/leapmux/v1"
+	"github.com/leapmux/leapmux/internal/util/periodic"
+	"github.com/leapmux/leapmux/internal/util/sqltime"
+	"github.com/leapmux/leapmux/internal/util/userid"
+	"github.com/leapmux/leapmux/internal/worker/agent"
+	"github.com/leapmux/leapmux/internal/worker/channel"
+	db "github.com/leapmux/leapmux/internal/worker/generated/db"
+)
+
+// Recorded metrics. Workspace series count turns, tokens and cost as they
+// are persisted and sample running agents and terminals; worker series
+// (workspace "") sample the worker's health.
+const (
+	metricTurns            = "turns"
+	metricInputTokens      = "input_tokens"
+	metricOutputTokens     = "output_tokens"
+	metricCostUSD          = "cost_usd"
+	metricRunningAgents    = "running_agents"
+	metricRunningTerminals = "running_terminals"
+	metricGoroutines       = "goroutines"
+	metricHeapBytes        = "heap_bytes"
+	metricDiskUsedBytes    = "disk_used_bytes"
+)
+
+const (
+	// metricSampleInterval is how often the running-agent, terminal and
+	// health gauges are sampled, one sample per minute bucket.
+	metricSampleInterval = time.Minute
+	// Each resolution is kept this long before being folded into the next
+	// coarser one; day buckets are then dropped.
+	metricMinuteRetention = 48 * time.Hour
+	metricHourRetention   = 90 * 24 * time.Hour
+	metricDayRetention    = 2 * 365 * 24 * time.Hour
+	// defaultMetricQueryRange is the range a query without since covers.
+	defaultMetricQueryRange = 24 * time.Hour
+	// maxMetricQueryBuckets bounds the buckets one series of a query spans,
+	// so a years-long range must be asked for in day buckets.
+	maxMetricQueryBuckets = 5000
+)
+
+// metricBuckets gives each resolution's bucket width and the strftime
+// format that truncates a canonical timestamp to its bucket's start.
+var metricBuckets = map[leapmuxv1.MetricResolution]struct {
+	width  time.Duration
+	format string
+}{
+	leapmuxv1.MetricResolution_METRIC_RESOLUTION_MINUTE: {time.Minute, "%Y-%m-%dT%H:%M:00.000Z"},
+	leapmuxv1.MetricResolution_METRIC_RESOLUTION_HOUR:   {time.Hour, "%Y-%m-%dT%H:00:00.000Z"},
+	leapmuxv1.MetricResolution_METRIC_RESOLUTION_DAY:    {24 * time.Hour, "%Y-%m-%dT00:00:00.000Z"},
+}
+
+// metricSample is one value recorded for a metric.
+type metricSample struct {
+	metric string
+	value  float64
+}
+
+// recordMetrics adds samples to workspaceID's minute buckets as of now.
+func recordMetrics(ctx context.Context, queries *db.Queries, workspaceID string, now time.Time, samples ...metricSample) error {
+	bucket := sqltime.NewSQLiteTime(now.UTC().Truncate(time.Minute))
+	for _, s := range samples {
+		if err := queries.RecordMetric(ctx, db.RecordMetricParams{
+			Metric:      s.metric,
+			WorkspaceID: workspaceID,
+			BucketStart: bucket,
+			Value:       s.value,
+		}); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// recordAgentMetrics adds samples to the metrics of agentID's workspace.
+// Like usage they are bookkeeping, so a failed write is logged rather than
+// failing the message that produced them.
+func (h *OutputHandler) recordAgentMetrics(agentID string, samples ...metricSample) {
+	workspaceID, err := h.queries.GetAgentWorkspaceID(bgCtx(), agentID)
+	if err == nil {
+		err = recordMetrics(bgCtx(), h.queries, workspaceID, time.Now(), samples...)
+	}
+	if err != nil {
+		slog.Warn("failed to record agent metrics", "agent_id", agentID, "error", err)
+	}
+}
+
+// recordUsageMetrics counts usage towards the workspace's token and cost
+// series, skipping usage a turn total will count again.
+func (h *OutputHandler) recordUsageMetrics(agentID string, usage *agent.MessageUsage) {
+	if usage.InTurn {
+		return
+	}
+	h.recordAgentMetrics(agentID,
+		metricSample{metricInputTokens, float64(usage.InputTokens)},
+		metricSample{metricOutputTokens, float64(usage.OutputTokens)},
+		metricSample{metricCostUSD, usage.CostUSD},
+	)
+}
+
+// StartMetricsLoops samples the gauges every metricSampleInterval and
+// folds aged buckets into coarser ones at the cleanup cadence.
+func (svc *Service) StartMetricsLoops(ctx context.Context) {
+	periodic.Start(ctx, periodic.Schedule{Interval: metricSampleInterval}, svc.SampleMetrics)
+	periodic.Start(ctx, periodic.Schedule{Interval: cleanupInterval, Jitter: cleanupJitter}, func(ctx context.Context) {
+		if err := svc.rollUpMetrics(ctx, time.Now()); err != nil {
+			slog.Error("failed to roll up metrics", "error", err)
+		}
+	})
+}
+
+// SampleMetrics records the running agents and terminals of each workspace
+// that has any, and the worker's own health.
+func (svc *Service) SampleMetrics(ctx context.Context) {
+	if err := svc.sampleMetrics(ctx, time.Now()); err != nil {
+		slog.Warn("failed to sample metrics", "error", err)
+	}
+}
+
+func (svc *Service) sampleMetrics(ctx context.Context, now time.Time) error {
+	running := make(map[string]bool)
+	for _, id := range svc.Agents.ListAgentIDs() {
+		running[id] = true
+	}
+	rows, err := svc.Queries.ListAllAgentIDsAndWorkspaces(ctx)
+	if err != nil {
+		return fmt.Errorf("list agents: %w", err)
+	}
+	agents := make(map[string]int)
+	for _, row := range rows {
+		if running[row.ID] {
+			agents[row.WorkspaceID]++
+		}
+	}
+	terminals := make(map[string]int)
+	var runningTerminals int
+	for _, id := range svc.Terminals.ListTerminalIDs() {
+		meta, ok := svc.Terminals.GetMeta(id)
+		if ok && svc.Terminals.IsRunning(id) {
+			terminals[meta.WorkspaceID]++
+			runningTerminals++
+		}
+	}
+
+	workspaces := make(map[string]bool, len(agents)+len(terminals))
+	for ws := range agents {
+		workspaces[ws] = true
+	}
+	for ws := range terminals {
+		workspaces[ws] = true
+	}
+	for ws := range workspaces {
+		if err := recordMetrics(ctx, svc.Queries, ws, now,
+			metricSample{metricRunningAgents, float64(agents[ws])},
+			metricSample{metricRunningTerminals, float64(terminals[ws])},
+		); err != nil {
+			return err
+		}
+	}
+
+	var mem runtime.MemStats
+	runtime.ReadMemStats(&mem)
+	samples := []metricSample{
+		{metricRunningAgents, float64(len(running))},
+		{metricRunningTerminals, float64(runningTerminals)},
+		{metricGoroutines, float64(runtime.NumGoroutine())},
+		{metricHeapBytes, float64(mem.HeapAlloc)},
+	}
+	svc.disk.mu.Lock()
+	if svc.disk.usage != nil {
+		samples = append(samples, metricSample{metricDiskUsedBytes, float64(svc.disk.usage.GetUsedBytes())})
+	}
+	svc.disk.mu.Unlock()
+	return recordMetrics(ctx, svc.Queries, "", now, samples...)
+}
+
+// rollUpMetrics folds minute and hour buckets past their retention into
+// the next coarser resolution and drops day buckets past theirs. The
+// cutoffs fall on bucket boundaries of the coarser resolution, so no
+// bucket is left split across the two.
+func (svc *Service) rollUpMetrics(ctx context.Context, now time.Time) error {
+	tx, err := svc.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer func() { _ = tx.Rollback() }()
+	q := svc.Queries.WithTx(tx)
+
+	for _, step := range []struct {
+		from, to leapmuxv1.MetricResolution
+		cutoff   time.Time
+	}{
+		{leapmuxv1.MetricResolution_METRIC_RESOLUTION_MINUTE, leapmuxv1.MetricResolution_METRIC_RESOLUTION_HOUR, now.Add(-metricMinuteRetention).Truncate(time.Hour)},
+		{leapmuxv1.MetricResolution_METRIC_RESOLUTION_HOUR, leapmuxv1.MetricResolution_METRIC_RESOLUTION_DAY, now.Add(-metricHourRetention).Truncate(24 * time.Hour)},
+	} {
+		cutoff := sqltime.NewSQLiteTime(step.cutoff)
+		if err := q.RollUpMetrics(ctx, db.RollUpMetricsParams{
+			ToResolution:   int64(step.to),
+			BucketFormat:   metricBuckets[step.to].format,
+			FromResolution: step.from,
+			Cutoff:         cutoff,
+		}); err != nil {
+			return fmt.Errorf("roll up %s buckets: %w", step.from, err)
+		}
+		if _, err := q.DeleteMetricsBefore(ctx, db.DeleteMetricsBeforeParams{Resolution: step.from, Cutoff: cutoff}); err != nil {
+			return fmt.Errorf("delete rolled-up %s buckets: %w", step.from, err)
+		}
+	}
+	if _, err := q.DeleteMetricsBefore(ctx, db.DeleteMetricsBeforeParams{
+		Resolution: leapmuxv1.MetricResolution_METRIC_RESOLUTION_DAY,
+		Cutoff:     sqltime.NewSQLiteTime(now.Add(-metricDayRetention)),
+	}); err != nil {
+		return fmt.Errorf("delete expired day buckets: %w", err)
+	}
+	return tx.Commit()
+}
+
+// errMetricQuery marks a query the caller got wrong.
+var errMetricQuery = errors.New("invalid metrics query")
+
+// queryMetrics answers r with the series whose workspace keep accepts.
+// An unspecified resolution is the finest still kept as far back as since.
+func (svc *Service) queryMetrics(ctx context.Context, r *leapmuxv1.QueryMetricsRequest, keep func(workspaceID string) bool, now time.Time) (*leapmuxv1.QueryMetricsResponse, error) {
+	until := now
+	if r.GetUntil() != "" {
+		t, err := time.Parse(time.RFC3339, r.GetUntil())
+		if err != nil {
+			return nil, fmt.Errorf("%w: until must be an RFC 3339 time", errMetricQuery)
+		}
+		until = t
+	}
+	since := until.Add(-defaultMetricQueryRange)
+	if r.GetSince() != "" {
+		t, err := time.Parse(time.RFC3339, r.GetSince())
+		if err != nil {
+			return nil, fmt.Errorf("%w: since must be an RFC 3339 time", errMetricQuery)
+		}
+		since = t
+	}
+	if !since.Before(until) {
+		return nil, fmt.Errorf("%w: since must be before until", errMetricQuery)
+	}
+
+	resolution := r.GetResolution()
+	if resolution == leapmuxv1.MetricResolution_METRIC_RESOLUTION_UNSPECIFIED {
+		switch age := now.Sub(since); {
+		case age <= metricMinuteRetention:
+			resolution = leapmuxv1.MetricResolution_METRIC_RESOLUTION_MINUTE
+		case age <= metricHourRetention:
+			resolution = leapmuxv1.MetricResolution_METRIC_RESOLUTION_HOUR
+		default:
+			resolution = leapmuxv1.MetricResolution_METRIC_RESOLUTION_DAY
+		}
+	}
+	bucket, ok := metricBuckets[resolution]
+	if !ok {
+		return nil, fmt.Errorf("%w: unknown resolution", errMetricQuery)
+	}
+	if until.Sub(since)/bucket.width > maxMetricQueryBuckets {
+		return nil, fmt.Errorf("%w: the range spans more than %d buckets at this resolution", errMetricQuery, maxMetricQueryBuckets)
+	}
+
+	metrics := make(map[string]bool, len(r.GetMetrics()))
+	for _, m := range r.GetMetrics() {
+		metrics[m] = true
+	}
+	rows, err := svc.Queries.ListMetrics(ctx, db.ListMetricsParams{
+		BucketFormat: bucket.format,
+		Resolution:   resolution,
+		Since:        sqltime.NewSQLiteTime(since),
+		Until:        sqltime.NewSQLiteTime(until),
+	})
+	if err != nil {
+		return nil, err
+	}
+	resp := &leapmuxv1.QueryMetricsResponse{Resolution: resolution}
+	var series *leapmuxv1.MetricSeries
+	for _, row := range rows {
+		if (len(metrics) > 0 && !metrics[row.Metric]) || !keep(row.WorkspaceID) {
+			continue
+		}
+		if series == nil || series.GetMetric() != row.Metric || series.GetWorkspaceId() != row.WorkspaceID {
+			series = &leapmuxv1.MetricSeries{Metric: row.Metric, WorkspaceId: row.WorkspaceID}
+			resp.Series = append(resp.Series, series)
+		}
+		series.Points = append(series.Points, &leapmuxv1.MetricPoint{
+			BucketStart: row.Bucket,
+			Count:       row.Count,
+			Sum:         row.Sum,
+			Min:         row.Min,
+			Max:         row.Max,
+		})
+	}
+	return resp, nil
+}
+
+// sendMetricQueryError answers a failed queryMetrics.
+func sendMetricQueryError(sender channel.ResponseWriter, err error) {
+	if errors.Is(err, errMetricQuery) {
+		sendInvalidArgument(sender, err.Error())
+		return
+	}
+	slog.Error("failed to query metrics", "error", err)
+	sendInternalError(sender, "failed to query metrics")
+}
+
+func registerWorkspaceMetricsHandlers(d registrar, svc *Service) {
+	// QueryWorkspaceMetrics answers the series of the caller's workspaces.
+	// Like ListAllAgents it filters by AccessibleSet().
+	registerSetFiltered(d, "QueryWorkspaceMetrics", func(ctx context.Context, _ userid.UserID, req *leapmuxv1.InnerRpcRequest, sender channel.ResponseWriter) {
+		var r leapmuxv1.QueryMetricsRequest
+		if err := unmarshalRequest(req, &r); err != nil {
+			sendInvalidArgument(sender, "invalid request")
+			return
+		}
+		accessible := svc.AuthorizerFor(sender.ChannelID()).AccessibleSet()
+		requested := make(map[string]bool, len(r.GetWorkspaceIds()))
+		for _, ws := range r.GetWorkspaceIds() {
+			requested[ws] = true
+		}
+		resp, err := svc.queryMetrics(ctx, &r, func(workspaceID string) bool {
+			return accessible[workspaceID] && (len(requested) == 0 || requested[workspaceID])
+		}, time.Now())
+		if err != nil {
+			sendMetricQueryError(sender, err)
+			return
+		}
+		sendProtoResponse(sender, resp)
+	})
+}
+
+func registerWorkerMetricsHandlers(d ownerOnlyRegistrar, svc *Service) {
+	d.Register("QueryWorkerMetrics", func(ctx context.Context, _ userid.UserID, req *leapmuxv1.InnerRpcRequest, sender channel.ResponseWriter) {
+		var r leapmuxv1.QueryMetricsRequest
+		if err := unmarshalRequest(req, &r); err != nil {
+			sendInvalidArgument(sender, "invalid request")
+			return
+		}
+		resp, err := svc.queryMetrics(ctx, &r, func(workspaceID string) bool { return workspaceID == "" }, time.Now())
+		if err != nil {
+			sendMetricQueryError(sender, err)
+			return
+		}
+		sendProtoResponse(sender, resp)
+	})
+}
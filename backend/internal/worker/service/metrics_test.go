@@ -0,0 +1,121 @@
+package service
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	leapmuxv1 "github.com/leapmux/leapmux/generated/proto/leapmux/v1"
+	"github.com/leapmux/leapmux/internal/worker/agent"
+)
+
+// metricSums maps each series' "metric/workspace" to the sum of its points.
+func metricSums(resp *leapmuxv1.QueryMetricsResponse) map[string]float64 {
+	sums := map[string]float64{}
+	for _, s := range resp.GetSeries() {
+		for _, p := range s.GetPoints() {
+			sums[s.GetMetric()+"/"+s.GetWorkspaceId()] += p.GetSum()
+		}
+	}
+	return sums
+}
+
+func TestQueryWorkspaceMetrics_CountsTurnsAndUsage(t *testing.T) {
+	svc, d, w := setupTestService(t, withWorkspaces("ws-1"))
+	a := seedGuardedAgent(t, svc, "")
+	sink := svc.Output.NewSink(a.ID, claudeCode)
+	require.NoError(t, sink.PersistMessage(leapmuxv1.MessageSource_MESSAGE_SOURCE_AGENT,
+		[]byte(`{"type":"assistant"}`), agent.SpanInfo{Usage: &agent.MessageUsage{InputTokens: 12, OutputTokens: 3, InTurn: true}}))
+	require.NoError(t, sink.PersistMessage(leapmuxv1.MessageSource_MESSAGE_SOURCE_AGENT,
+		[]byte(`{"type":"assistant"}`), agent.SpanInfo{Usage: &agent.MessageUsage{InputTokens: 5, OutputTokens: 1}}))
+	require.NoError(t, sink.PersistTurnEnd([]byte(`{"type":"result"}`),
+		agent.SpanInfo{Usage: &agent.MessageUsage{InputTokens: 12, OutputTokens: 3, CostUSD: 0.5, Turn: true}}))
+	require.NoError(t, svc.sampleMetrics(context.Background(), time.Now()))
+
+	dispatch(d, "QueryWorkspaceMetrics", &leapmuxv1.QueryMetricsRequest{}, w)
+	require.Empty(t, w.errors)
+	resp := decodeResponse[leapmuxv1.QueryMetricsResponse](t, w)
+	assert.Equal(t, leapmuxv1.MetricResolution_METRIC_RESOLUTION_MINUTE, resp.GetResolution())
+	sums := metricSums(resp)
+	assert.Equal(t, 1.0, sums["turns/ws-1"])
+	assert.Equal(t, 17.0, sums["input_tokens/ws-1"], "usage the turn total repeats counts once")
+	assert.InDelta(t, 0.5, sums["cost_usd/ws-1"], 1e-9)
+	assert.NotContains(t, sums, "goroutines/", "worker series are not a workspace's")
+
+	w.responses = nil
+	dispatch(d, "QueryWorkspaceMetrics", &leapmuxv1.QueryMetricsRequest{WorkspaceIds: []string{"ws-2"}}, w)
+	require.Empty(t, w.errors)
+	assert.Empty(t, decodeResponse[leapmuxv1.QueryMetricsResponse](t, w).GetSeries())
+
+	dispatch(d, "QueryWorkspaceMetrics", &leapmuxv1.QueryMetricsRequest{Since: "yesterday"}, w)
+	require.Len(t, w.errors, 1)
+	assert.Equal(t, codeInvalidArgument, w.errors[0].code)
+}
+
+func TestQueryWorkerMetrics(t *testing.T) {
+	svc, d, w := setupTestService(t)
+	require.NoError(t, svc.sampleMetrics(context.Background(), time.Now()))
+
+	dispatch(d, "QueryWorkerMetrics", &leapmuxv1.QueryMetricsRequest{Metrics: []string{metricGoroutines}}, w)
+	require.Empty(t, w.errors)
+	series := decodeResponse[leapmuxv1.QueryMetricsResponse](t, w).GetSeries()
+	require.Len(t, series, 1)
+	assert.Equal(t, metricGoroutines, series[0].GetMetric())
+	require.Len(t, series[0].GetPoints(), 1)
+	assert.Positive(t, series[0].GetPoints()[0].GetMax())
+
+	dispatch(d, "QueryWorkerMetrics", &leapmuxv1.QueryMetricsRequest{
+		Since:      time.Now().Add(-30 * 24 * time.Hour).Format(time.RFC3339),
+		Resolution: leapmuxv1.MetricResolution_METRIC_RESOLUTION_MINUTE,
+	}, w)
+	require.Len(t, w.errors, 1, "a month of minute buckets is too many")
+	assert.Equal(t, codeInvalidArgument, w.errors[0].code)
+}
+
+func TestRollUpMetrics(t *testing.T) {
+	svc, _, _ := setupTestService(t)
+	ctx := context.Background()
+	now := time.Date(2026, 6, 15, 12, 0, 0, 0, time.UTC)
+	for _, s := range []struct {
+		at    time.Time
+		value float64
+	}{
+		{now.Add(-72*time.Hour + 10*time.Minute), 4},
+		{now.Add(-72*time.Hour + 20*time.Minute), 2},
+		{now.Add(-time.Hour), 7},
+		{now.Add(-3 * 365 * 24 * time.Hour), 1},
+	} {
+		require.NoError(t, recordMetrics(ctx, svc.Queries, "ws-1", s.at, metricSample{metricTurns, s.value}))
+	}
+	require.NoError(t, svc.rollUpMetrics(ctx, now))
+
+	query := func(resolution leapmuxv1.MetricResolution, since time.Time) []*leapmuxv1.MetricPoint {
+		resp, err := svc.queryMetrics(ctx, &leapmuxv1.QueryMetricsRequest{
+			Since:      since.Format(time.RFC3339),
+			Resolution: resolution,
+		}, func(string) bool { return true }, now)
+		require.NoError(t, err)
+		if len(resp.GetSeries()) == 0 {
+			return nil
+		}
+		require.Len(t, resp.GetSeries(), 1)
+		return resp.GetSeries()[0].GetPoints()
+	}
+
+	minutes := query(leapmuxv1.MetricResolution_METRIC_RESOLUTION_MINUTE, now.Add(-80*time.Hour))
+	require.Len(t, minutes, 1, "minute buckets past their retention are folded away")
+	assert.Equal(t, 7.0, minutes[0].GetSum())
+
+	hours := query(leapmuxv1.MetricResolution_METRIC_RESOLUTION_HOUR, now.Add(-80*time.Hour))
+	require.Len(t, hours, 2)
+	assert.Equal(t, "2026-06-12T12:00:00.000Z", hours[0].GetBucketStart())
+	assert.Equal(t, []float64{2, 6, 2, 4}, []float64{float64(hours[0].GetCount()), hours[0].GetSum(), hours[0].GetMin(), hours[0].GetMax()},
+		"a folded bucket keeps count, sum, min and max")
+	assert.Equal(t, 7.0, hours[1].GetSum(), "unfolded minute buckets still count at a coarser resolution")
+
+	assert.Len(t, query(leapmuxv1.MetricResolution_METRIC_RESOLUTION_DAY, now.Add(-4*365*24*time.Hour)), 2,
+		"day buckets past their retention are dropped")
+}
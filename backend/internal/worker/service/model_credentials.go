@@ -0,0 +1,203 @@
+package service
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"time"
+
+	leapmuxv1 "github.com/leapmux/leapmux/generated/proto/leapmux/v1"
+	"github.com/leapmux/leapmux/internal/util/sqltime"
+	"github.com/leapmux/leapmux/internal/util/userid"
+	"github.com/leapmux/leapmux/internal/worker/agent"
+	"github.com/leapmux/leapmux/internal/worker/channel"
+	db "github.com/leapmux/leapmux/internal/worker/generated/db"
+)
+
+// modelCredentialsDirName is the directory under the data dir that holds
+// one subdirectory per model credential the Hub has delivered, readable
+// only by the worker's user.
+const modelCredentialsDirName = "model-credentials"
+
+// modelCredentialLookupTimeout bounds the Hub round trip that resolves a
+// workspace's model credentials.
+const modelCredentialLookupTimeout = 10 * time.Second
+
+// ModelCredentialResolver looks up the org model credentials serving
+// workspaceID, a workspace of the user behind channelID.
+type ModelCredentialResolver func(ctx context.Context, channelID, workspaceID string) ([]*leapmuxv1.ModelCredentialSecret, error)
+
+// pickModelCredential chooses the org model credential a new agent of
+// provider runs under: of those serving workspaceID that the provider
+// accepts, the one carrying the fewest open agents, preferring one whose
+// rate limits are not exhausted. nil means the agent uses the worker
+// host's own login: the workspace has no credential, or the agent did not
+// arrive on a channel the Hub can answer for.
+func (svc *Service) pickModelCredential(ctx context.Context, channelID, workspaceID string, provider leapmuxv1.AgentProvider) (*leapmuxv1.ModelCredentialSecret, error) {
+	if svc.ModelCredentials == nil || channelID == "" {
+		return nil, nil
+	}
+	ctx, cancel := context.WithTimeout(ctx, modelCredentialLookupTimeout)
+	defer cancel()
+	creds, err := svc.ModelCredentials(ctx, channelID, workspaceID)
+	if err != nil {
+		return nil, fmt.Errorf("look up model credentials: %w", err)
+	}
+	p := agent.ProviderFor(provider)
+	var candidates []*leapmuxv1.ModelCredentialSecret
+	for _, c := range creds {
+		if isPlainPathComponent(c.GetId()) && len(p.ModelCredentialEnv(c.GetKind(), c.GetSecret())) > 0 {
+			candidates = append(candidates, c)
+		}
+	}
+	if len(candidates) == 0 {
+		return nil, nil
+	}
+	counts, err := svc.Queries.CountOpenAgentsByModelCredential(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("count agents by model credential: %w", err)
+	}
+	load := make(map[string]int64, len(counts))
+	for _, row := range counts {
+		load[row.CredentialID] = row.Agents
+	}
+	var best *leapmuxv1.ModelCredentialSecret
+	var bestLimited bool
+	for _, c := range candidates {
+		limited := svc.rateLimits.limited(rateLimitAccount{provider: provider, credentialID: c.GetId()})
+		switch {
+		case best == nil,
+			bestLimited && !limited,
+			bestLimited == limited && load[c.GetId()] < load[best.GetId()]:
+			best, bestLimited = c, limited
+		}
+	}
+	return best, nil
+}
+
+// installModelCredential records that agentID runs under cred and writes
+// the secret where its launches read it. The file is named by credential
+// id, so a rotated secret replaces the old one for every agent using it.
+func (svc *Service) installModelCredential(ctx context.Context, agentID string, cred *leapmuxv1.ModelCredentialSecret) error {
+	if svc.DataDir == "" {
+		return errors.New("worker has no data directory to keep model credentials in")
+	}
+	if err := svc.writePrivateFile(svc.modelCredentialPath(cred.GetId()), []byte(cred.GetSecret())); err != nil {
+		return fmt.Errorf("write model credential: %w", err)
+	}
+	return svc.Queries.CreateAgentModelCredential(ctx, db.CreateAgentModelCredentialParams{
+		AgentID:        agentID,
+		CredentialID:   cred.GetId(),
+		CredentialName: cred.GetName(),
+		Kind:           cred.GetKind(),
+	})
+}
+
+func (svc *Service) modelCredentialPath(credentialID string) string {
+	return filepath.Join(svc.DataDir, modelCredentialsDirName, credentialID, "secret")
+}
+
+// agentModelCredential returns the credential the agent was opened with,
+// or nil when it uses the worker host's own login.
+func (svc *Service) agentModelCredential(agentID string) *db.AgentModelCredential {
+	row, err := svc.Queries.GetAgentModelCredential(bgCtx(), agentID)
+	if err != nil {
+		if !errors.Is(err, sql.ErrNoRows) {
+			slog.Warn("failed to read agent model credential", "agent_id", agentID, "error", err)
+		}
+		return nil
+	}
+	return &row
+}
+
+// agentCredentialEnv returns the environment that signs the agent in with
+// its model credential, or nil when it has none. A secret that cannot be
+// read launches the agent with an empty one rather than none, so the
+// launch fails to sign in instead of falling back to the worker host's own
+// login and billing another account.
+func (svc *Service) agentCredentialEnv(agentID string, provider leapmuxv1.AgentProvider) []string {
+	cred := svc.agentModelCredential(agentID)
+	if cred == nil {
+		return nil
+	}
+	secret, err := os.ReadFile(svc.modelCredentialPath(cred.CredentialID))
+	if err != nil {
+		slog.Error("failed to read model credential", "agent_id", agentID, "credential_id", cred.CredentialID, "error", err)
+	}
+	return agent.ProviderFor(provider).ModelCredentialEnv(cred.Kind, string(secret))
+}
+
+// parseUsagePeriod parses the optional RFC 3339 [since, until) bounds of a
+// usage report. An empty until is unbounded rather than the current
+// instant, which would drop a turn that ended in the same millisecond.
+func parseUsagePeriod(sinceStr, untilStr string) (since, until time.Time, err error) {
+	since, until = time.Time{}, time.Date(9999, 12, 31, 0, 0, 0, 0, time.UTC)
+	for _, f := range []struct {
+		name, value string
+		into        *time.Time
+	}{{"since", sinceStr, &since}, {"until", untilStr, &until}} {
+		if f.value == "" {
+			continue
+		}
+		t, err := time.Parse(time.RFC3339, f.value)
+		if err != nil {
+			return time.Time{}, time.Time{}, errors.New(f.name + " must be an RFC 3339 time")
+		}
+		*f.into = t
+	}
+	return since, until, nil
+}
+
+func registerModelCredentialHandlers(d registrar, svc *Service) {
+	// ListModelCredentialUsage reports, for billing reconciliation, what
+	// the agents opened with each org model credential used. Like
+	// ListAllAgents it filters by AccessibleSet().
+	registerSetFiltered(d, "ListModelCredentialUsage", func(ctx context.Context, _ userid.UserID, req *leapmuxv1.InnerRpcRequest, sender channel.ResponseWriter) {
+		var r leapmuxv1.ListModelCredentialUsageRequest
+		if err := unmarshalRequest(req, &r); err != nil {
+			sendInvalidArgument(sender, "invalid request")
+			return
+		}
+		since, until, err := parseUsagePeriod(r.GetSince(), r.GetUntil())
+		if err != nil {
+			sendInvalidArgument(sender, err.Error())
+			return
+		}
+
+		rows, err := svc.Queries.ListModelCredentialUsage(ctx, db.ListModelCredentialUsageParams{
+			Since: sqltime.NewSQLiteTime(since),
+			Until: sqltime.NewSQLiteTime(until),
+		})
+		if err != nil {
+			slog.Error("failed to list model credential usage", "error", err)
+			sendInternalError(sender, "failed to list model credential usage")
+			return
+		}
+		accessible := svc.AuthorizerFor(sender.ChannelID()).AccessibleSet()
+		usages := make([]*leapmuxv1.ModelCredentialUsage, 0, len(rows))
+		for _, row := range rows {
+			if !accessible[row.WorkspaceID] {
+				continue
+			}
+			usages = append(usages, &leapmuxv1.ModelCredentialUsage{
+				CredentialId:   row.CredentialID,
+				CredentialName: row.CredentialName,
+				WorkspaceId:    row.WorkspaceID,
+				Agents:         row.Agents,
+				Turns:          row.Turns,
+				Usage: &leapmuxv1.MessageUsage{
+					InputTokens:              row.InputTokens,
+					OutputTokens:             row.OutputTokens,
+					CacheCreationInputTokens: row.CacheCreationInputTokens,
+					CacheReadInputTokens:     row.CacheReadInputTokens,
+					CostUsd:                  row.CostUsd,
+				},
+			})
+		}
+		sendProtoResponse(sender, &leapmuxv1.ListModelCredentialUsageResponse{Usages: usages})
+	})
+}
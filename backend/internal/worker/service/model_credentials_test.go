@@ -0,0 +1,129 @@
+package service
+
+import (
+	"context"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	leapmuxv1 "github.com/leapmux/leapmux/generated/proto/leapmux/v1"
+	"github.com/leapmux/leapmux/internal/worker/agent"
+	db "github.com/leapmux/leapmux/internal/worker/generated/db"
+)
+
+const testAPIKeyKind = leapmuxv1.ModelCredentialKind_MODEL_CREDENTIAL_KIND_ANTHROPIC_API_KEY
+
+// withModelCredentials makes the Hub answer every workspace with creds.
+func withModelCredentials(svc *Service, creds ...*leapmuxv1.ModelCredentialSecret) {
+	svc.ModelCredentials = func(context.Context, string, string) ([]*leapmuxv1.ModelCredentialSecret, error) {
+		return creds, nil
+	}
+}
+
+func TestOpenAgent_RunsUnderLeastLoadedModelCredential(t *testing.T) {
+	svc, d, w := setupTestService(t, withWorkspaces("ws-1"))
+	withModelCredentials(svc,
+		&leapmuxv1.ModelCredentialSecret{Id: "cred-a", Name: "team-a", Kind: testAPIKeyKind, Secret: "sk-a"},
+		&leapmuxv1.ModelCredentialSecret{Id: "cred-b", Name: "team-b", Kind: testAPIKeyKind, Secret: "sk-b"},
+	)
+	busy := seedGuardedAgent(t, svc, "")
+	require.NoError(t, svc.Queries.CreateAgentModelCredential(context.Background(), db.CreateAgentModelCredentialParams{
+		AgentID: busy.ID, CredentialID: "cred-a", CredentialName: "team-a", Kind: testAPIKeyKind,
+	}))
+	launched := make(chan agent.Options, 1)
+	svc.startAgentFn = func(_ context.Context, opts agent.Options, _ agent.OutputSink) (map[string]string, error) {
+		launched <- opts
+		return map[string]string{}, nil
+	}
+
+	dispatch(d, "OpenAgent", &leapmuxv1.OpenAgentRequest{
+		WorkspaceId:   "ws-1",
+		WorkingDir:    t.TempDir(),
+		AgentProvider: claudeCode,
+	}, w)
+	require.Empty(t, w.errors)
+	resp := decodeResponse[leapmuxv1.OpenAgentResponse](t, w)
+
+	select {
+	case opts := <-launched:
+		assert.Equal(t, []string{"ANTHROPIC_API_KEY=sk-b"}, opts.CredentialEnv)
+	case <-time.After(5 * time.Second):
+		t.Fatal("agent was not launched")
+	}
+	secret, err := os.ReadFile(svc.modelCredentialPath("cred-b"))
+	require.NoError(t, err)
+	assert.Equal(t, "sk-b", string(secret))
+
+	dbAgent, err := svc.Queries.GetAgentByID(context.Background(), resp.GetAgent().GetId())
+	require.NoError(t, err)
+	assert.Equal(t, "team-b", svc.agentRuntimeInfo(context.Background(), dbAgent).GetModelCredentialName())
+	assert.Equal(t, rateLimitAccount{provider: claudeCode, credentialID: "cred-b"}, svc.rateLimitAccountOf(dbAgent),
+		"the agent draws on its credential's rate limits")
+}
+
+func TestPickModelCredential(t *testing.T) {
+	svc, _, _ := setupTestService(t, withWorkspaces("ws-1"))
+	ctx := context.Background()
+
+	got, err := svc.pickModelCredential(ctx, testChannelID, "ws-1", claudeCode)
+	require.NoError(t, err)
+	assert.Nil(t, got, "no resolver keeps the worker's own login")
+
+	withModelCredentials(svc,
+		&leapmuxv1.ModelCredentialSecret{Id: "cred-a", Kind: testAPIKeyKind, Secret: "sk-a"},
+		&leapmuxv1.ModelCredentialSecret{Id: "cred-b", Kind: testAPIKeyKind, Secret: "sk-b"},
+	)
+	got, err = svc.pickModelCredential(ctx, "", "ws-1", claudeCode)
+	require.NoError(t, err)
+	assert.Nil(t, got, "a local caller has no channel to resolve")
+
+	got, err = svc.pickModelCredential(ctx, testChannelID, "ws-1", leapmuxv1.AgentProvider_AGENT_PROVIDER_CODEX)
+	require.NoError(t, err)
+	assert.Nil(t, got, "a provider that takes no Anthropic credential keeps its own login")
+
+	svc.rateLimits.update(rateLimitAccount{provider: claudeCode, credentialID: "cred-a"}, map[string]rateLimitTier{
+		"five_hour": {Type: "five_hour", Status: "rejected", ResetsAt: time.Now().Add(time.Hour).Unix()},
+	})
+	got, err = svc.pickModelCredential(ctx, testChannelID, "ws-1", claudeCode)
+	require.NoError(t, err)
+	assert.Equal(t, "cred-b", got.GetId(), "a rate limited account is passed over")
+}
+
+func TestListModelCredentialUsage(t *testing.T) {
+	svc, d, w := setupTestService(t, withWorkspaces("ws-1"))
+	a := seedGuardedAgent(t, svc, "")
+	require.NoError(t, svc.Queries.CreateAgentModelCredential(context.Background(), db.CreateAgentModelCredentialParams{
+		AgentID: a.ID, CredentialID: "cred-a", CredentialName: "team-a", Kind: testAPIKeyKind,
+	}))
+	sink := svc.Output.NewSink(a.ID, claudeCode)
+	require.NoError(t, sink.PersistMessage(leapmuxv1.MessageSource_MESSAGE_SOURCE_AGENT,
+		[]byte(`{"type":"assistant"}`), agent.SpanInfo{Usage: &agent.MessageUsage{InputTokens: 12, OutputTokens: 3}}))
+	require.NoError(t, sink.PersistTurnEnd([]byte(`{"type":"result"}`),
+		agent.SpanInfo{Usage: &agent.MessageUsage{InputTokens: 12, OutputTokens: 3, CostUSD: 0.5, Turn: true}}))
+
+	dispatch(d, "ListModelCredentialUsage", &leapmuxv1.ListModelCredentialUsageRequest{}, w)
+	require.Empty(t, w.errors)
+	usages := decodeResponse[leapmuxv1.ListModelCredentialUsageResponse](t, w).GetUsages()
+	require.Len(t, usages, 1)
+	u := usages[0]
+	assert.Equal(t, "team-a", u.GetCredentialName())
+	assert.Equal(t, "ws-1", u.GetWorkspaceId())
+	assert.Equal(t, int64(1), u.GetAgents())
+	assert.Equal(t, int64(1), u.GetTurns())
+	assert.Equal(t, int64(12), u.GetUsage().GetInputTokens(), "only the turn total counts")
+	assert.InDelta(t, 0.5, u.GetUsage().GetCostUsd(), 1e-9)
+
+	w.responses = nil
+	dispatch(d, "ListModelCredentialUsage", &leapmuxv1.ListModelCredentialUsageRequest{
+		Until: time.Now().Add(-time.Hour).Format(time.RFC3339),
+	}, w)
+	require.Empty(t, w.errors)
+	assert.Empty(t, decodeResponse[leapmuxv1.ListModelCredentialUsageResponse](t, w).GetUsages())
+
+	dispatch(d, "ListModelCredentialUsage", &leapmuxv1.ListModelCredentialUsageRequest{Since: "yesterday"}, w)
+	require.Len(t, w.errors, 1)
+	assert.Equal(t, codeInvalidArgument, w.errors[0].code)
+}
@@ -0,0 +1,258 @@
+package service
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"log/slog"
+	"regexp"
+
+	leapmuxv1 "github.com/leapmux/leapmux/generated/proto/leapmux/v1"
+	"github.com/leapmux/leapmux/internal/util/timefmt"
+	"github.com/leapmux/leapmux/internal/util/userid"
+	"github.com/leapmux/leapmux/internal/worker/agent"
+	"github.com/leapmux/leapmux/internal/worker/channel"
+	db "github.com/leapmux/leapmux/internal/worker/generated/db"
+	"google.golang.org/protobuf/encoding/protojson"
+)
+
+// maxPromptPatternLen bounds prompt_pattern so a policy cannot make every
+// send compile and run an enormous expression.
+const maxPromptPatternLen = 1024
+
+// validateModelRoutingPolicy rejects rules the router could never apply:
+// a missing trigger or target, a rule that routes a model onto itself, and a
+// prompt rule whose pattern is absent or does not compile.
+func validateModelRoutingPolicy(policy *leapmuxv1.ModelRoutingPolicy) error {
+	for i, r := range policy.GetRules() {
+		switch r.GetTrigger() {
+		case leapmuxv1.ModelRoutingTrigger_MODEL_ROUTING_TRIGGER_RATE_LIMITED:
+		case leapmuxv1.ModelRoutingTrigger_MODEL_ROUTING_TRIGGER_PROMPT_MATCHES:
+			if r.GetPromptPattern() == "" {
+				return fmt.Errorf("rule %d: prompt_pattern is required", i)
+			}
+			if len(r.GetPromptPattern()) > maxPromptPatternLen {
+				return fmt.Errorf("rule %d: prompt_pattern must not exceed %d bytes", i, maxPromptPatternLen)
+			}
+			if _, err := regexp.Compile(r.GetPromptPattern()); err != nil {
+				return fmt.Errorf("rule %d: invalid prompt_pattern: %v", i, err)
+			}
+		default:
+			return fmt.Errorf("rule %d: trigger is required", i)
+		}
+		if r.GetTargetModel() == "" {
+			return fmt.Errorf("rule %d: target_model is required", i)
+		}
+		if r.GetTargetModel() == r.GetFromModel() {
+			return fmt.Errorf("rule %d: target_model must differ from from_model", i)
+		}
+	}
+	return nil
+}
+
+// matchModelRoute returns the first rule for trigger that applies to an agent
+// on currentModel, or nil. prompt is only consulted for PROMPT_MATCHES rules.
+// A rule whose target is the current model is skipped: it would route nowhere.
+func matchModelRoute(policy *leapmuxv1.ModelRoutingPolicy, trigger leapmuxv1.ModelRoutingTrigger, currentModel, prompt string) *leapmuxv1.ModelRoutingRule {
+	for _, r := range policy.GetRules() {
+		if r.GetTrigger() != trigger || r.GetTargetModel() == currentModel {
+			continue
+		}
+		if from := r.GetFromModel(); from != "" && from != currentModel {
+			continue
+		}
+		if trigger == leapmuxv1.ModelRoutingTrigger_MODEL_ROUTING_TRIGGER_PROMPT_MATCHES {
+			re, err := regexp.Compile(r.GetPromptPattern())
+			if err != nil || !re.MatchString(prompt) {
+				continue
+			}
+		}
+		return r
+	}
+	return nil
+}
+
+// loadWorkspaceModelRouting reads the workspace's stored routing rules. A
+// workspace with no row yields an empty policy (no routing), not an error.
+func loadWorkspaceModelRouting(ctx context.Context, queries *db.Queries, workspaceID string) (*leapmuxv1.ModelRoutingPolicy, error) {
+	raw, err := queries.GetWorkspaceModelRouting(ctx, workspaceID)
+	if errors.Is(err, sql.ErrNoRows) {
+		return &leapmuxv1.ModelRoutingPolicy{}, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	policy := &leapmuxv1.ModelRoutingPolicy{}
+	if err := protojson.Unmarshal([]byte(raw), policy); err != nil {
+		return nil, fmt.Errorf("decode model routing: %w", err)
+	}
+	return policy, nil
+}
+
+// turnRouting is the model state a new turn starts from: the model the agent
+// is on, and -- when the previous turn was routed by a prompt rule and the
+// agent is still on the routed model -- the model to fall back to.
+type turnRouting struct {
+	current string
+	base    string
+	routed  bool
+}
+
+// loadTurnRouting derives the starting routing state for agentRow's next
+// turn. A user who changed the model after a routed turn has taken it over,
+// so the routed state is dropped and their choice becomes the base.
+func (svc *Service) loadTurnRouting(agentRow db.Agent) turnRouting {
+	current := loadOptions(agentRow.Options, agentRow.AgentProvider)[agent.OptionIDModel]
+	state := turnRouting{current: current, base: current}
+	last, err := svc.Queries.GetLatestAgentTurnModel(bgCtx(), agentRow.ID)
+	if err != nil {
+		if !errors.Is(err, sql.ErrNoRows) {
+			slog.Warn("turn model lookup failed", "agent_id", agentRow.ID, "error", err)
+		}
+		return state
+	}
+	if last.Routed != 0 && last.Model == current {
+		state.base = last.BaseModel
+		state.routed = true
+	}
+	return state
+}
+
+// routeTurnModel applies the workspace's prompt routing rules to a user
+// message about to be delivered to agentRow, switching the agent's model
+// when a rule (or the end of a routed turn) calls for it. Rules are matched
+// against the base model, so a routed turn never chains into a second route.
+// Routing is best-effort: a policy that fails to load leaves the model alone.
+func (svc *Service) routeTurnModel(agentRow db.Agent, prompt string) turnRouting {
+	state := svc.loadTurnRouting(agentRow)
+	policy, err := loadWorkspaceModelRouting(bgCtx(), svc.Queries, agentRow.WorkspaceID)
+	if err != nil {
+		slog.Warn("model routing load failed; delivering on the current model",
+			"agent_id", agentRow.ID, "workspace_id", agentRow.WorkspaceID, "error", err)
+		return state
+	}
+
+	next := turnRouting{current: state.base, base: state.base}
+	if rule := matchModelRoute(policy, leapmuxv1.ModelRoutingTrigger_MODEL_ROUTING_TRIGGER_PROMPT_MATCHES, state.base, prompt); rule != nil {
+		next.current = rule.GetTargetModel()
+		next.routed = true
+	}
+	if next.current != state.current {
+		slog.Info("routing turn to model", "agent_id", agentRow.ID,
+			"from", state.current, "to", next.current, "routed", next.routed)
+		svc.switchAgentModel(agentRow.ID, next.current)
+	}
+	return next
+}
+
+// recordTurnModel stores the model messageID's turn was delivered on. A
+// failure only costs cost-reporting accuracy, so it is logged, not surfaced.
+func (svc *Service) recordTurnModel(agentID, messageID string, state turnRouting) {
+	var routed int64
+	if state.routed {
+		routed = 1
+	}
+	if err := svc.Queries.RecordAgentTurnModel(bgCtx(), db.RecordAgentTurnModelParams{
+		AgentID:   agentID,
+		MessageID: messageID,
+		Model:     state.current,
+		BaseModel: state.base,
+		Routed:    routed,
+	}); err != nil {
+		slog.Warn("failed to record turn model", "agent_id", agentID, "message_id", messageID, "error", err)
+	}
+}
+
+// rateLimitFailoverModel returns the model a RATE_LIMITED routing rule sends
+// agentRow to, or "" when no rule applies. Unlike a prompt route, the switch
+// sticks: the limit that triggered it outlives the turn.
+func (h *OutputHandler) rateLimitFailoverModel(agentRow db.Agent) string {
+	policy, err := loadWorkspaceModelRouting(bgCtx(), h.queries, agentRow.WorkspaceID)
+	if err != nil {
+		slog.Warn("model routing load failed; skipping rate-limit failover",
+			"agent_id", agentRow.ID, "workspace_id", agentRow.WorkspaceID, "error", err)
+		return ""
+	}
+	currentModel := loadOptions(agentRow.Options, agentRow.AgentProvider)[agent.OptionIDModel]
+	rule := matchModelRoute(policy, leapmuxv1.ModelRoutingTrigger_MODEL_ROUTING_TRIGGER_RATE_LIMITED, currentModel, "")
+	if rule == nil {
+		return ""
+	}
+	return rule.GetTargetModel()
+}
+
+// registerModelRoutingHandlers registers the per-workspace routing rule RPCs
+// and the per-turn model listing used for cost reporting.
+func registerModelRoutingHandlers(d registrar, svc *Service) {
+	registerWorkspaceGated(d, "GetWorkspaceModelRouting",
+		func(ctx context.Context, _ userid.UserID, r *leapmuxv1.GetWorkspaceModelRoutingRequest, sender channel.ResponseWriter) {
+			policy, err := loadWorkspaceModelRouting(ctx, svc.Queries, r.GetWorkspaceId())
+			if err != nil {
+				slog.Error("failed to load model routing", "workspace_id", r.GetWorkspaceId(), "error", err)
+				sendInternalError(sender, "failed to load model routing")
+				return
+			}
+			sendProtoResponse(sender, &leapmuxv1.GetWorkspaceModelRoutingResponse{Policy: policy})
+		})
+
+	registerWorkspaceGated(d, "SetWorkspaceModelRouting",
+		func(_ context.Context, _ userid.UserID, r *leapmuxv1.SetWorkspaceModelRoutingRequest, sender channel.ResponseWriter) {
+			policy := r.GetPolicy()
+			if policy == nil {
+				policy = &leapmuxv1.ModelRoutingPolicy{}
+			}
+			if err := validateModelRoutingPolicy(policy); err != nil {
+				sendInvalidArgument(sender, err.Error())
+				return
+			}
+
+			var err error
+			if len(policy.GetRules()) == 0 {
+				err = svc.Queries.DeleteWorkspaceModelRouting(bgCtx(), r.GetWorkspaceId())
+			} else {
+				var raw []byte
+				raw, err = protojson.Marshal(policy)
+				if err == nil {
+					err = svc.Queries.UpsertWorkspaceModelRouting(bgCtx(), db.UpsertWorkspaceModelRoutingParams{
+						WorkspaceID: r.GetWorkspaceId(),
+						Policy:      string(raw),
+					})
+				}
+			}
+			if err != nil {
+				slog.Error("failed to save model routing", "workspace_id", r.GetWorkspaceId(), "error", err)
+				sendInternalError(sender, "failed to save model routing")
+				return
+			}
+			sendProtoResponse(sender, &leapmuxv1.SetWorkspaceModelRoutingResponse{Policy: policy})
+		})
+
+	registerAgentGated(d, "ListAgentTurnModels",
+		func(ctx context.Context, _ userid.UserID, r *leapmuxv1.ListAgentTurnModelsRequest, _ db.Agent, sender channel.ResponseWriter) {
+			rows, err := svc.Queries.ListAgentTurnModels(ctx, r.GetAgentId())
+			if err != nil {
+				slog.Error("failed to list turn models", "agent_id", r.GetAgentId(), "error", err)
+				sendInternalError(sender, "failed to list turn models")
+				return
+			}
+			subAgentUsage, err := svc.subAgentUsageByTurn(ctx, r.GetAgentId())
+			if err != nil {
+				slog.Error("failed to sum sub-agent usage", "agent_id", r.GetAgentId(), "error", err)
+				sendInternalError(sender, "failed to list turn models")
+				return
+			}
+			turns := make([]*leapmuxv1.TurnModel, len(rows))
+			for i, row := range rows {
+				turns[i] = &leapmuxv1.TurnModel{
+					MessageId:     row.MessageID,
+					Model:         row.Model,
+					Routed:        row.Routed != 0,
+					BaseModel:     row.BaseModel,
+					CreatedAt:     timefmt.Format(row.CreatedAt.Time),
+					SubagentUsage: subAgentUsage[row.MessageID],
+				}
+			}
+			sendProtoResponse(sender, &leapmuxv1.ListAgentTurnModelsResponse{Turns: turns})
+		})
+}
@@ -0,0 +1,230 @@
+package service
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/protobuf/encoding/protojson"
+	"google.golang.org/protobuf/proto"
+
+	leapmuxv1 "github.com/leapmux/leapmux/generated/proto/leapmux/v1"
+	"github.com/leapmux/leapmux/internal/worker/agent"
+	db "github.com/leapmux/leapmux/internal/worker/generated/db"
+)
+
+const (
+	routeOnRateLimit = leapmuxv1.ModelRoutingTrigger_MODEL_ROUTING_TRIGGER_RATE_LIMITED
+	routeOnPrompt    = leapmuxv1.ModelRoutingTrigger_MODEL_ROUTING_TRIGGER_PROMPT_MATCHES
+)
+
+func setWorkspaceModelRouting(t *testing.T, queries *db.Queries, workspaceID string, rules ...*leapmuxv1.ModelRoutingRule) {
+	t.Helper()
+	raw, err := protojson.Marshal(&leapmuxv1.ModelRoutingPolicy{Rules: rules})
+	require.NoError(t, err)
+	require.NoError(t, queries.UpsertWorkspaceModelRouting(bgCtx(), db.UpsertWorkspaceModelRoutingParams{
+		WorkspaceID: workspaceID,
+		Policy:      string(raw),
+	}))
+}
+
+func seedModelAgent(t *testing.T, queries *db.Queries, agentID, model string) db.Agent {
+	t.Helper()
+	require.NoError(t, queries.CreateAgent(context.Background(), db.CreateAgentParams{
+		ID:          agentID,
+		WorkspaceID: "ws-1",
+		WorkingDir:  t.TempDir(),
+		HomeDir:     t.TempDir(),
+		Options:     marshalOptions(map[string]string{agent.OptionIDModel: model}),
+	}))
+	row, err := queries.GetAgentByID(context.Background(), agentID)
+	require.NoError(t, err)
+	return row
+}
+
+func agentModel(t *testing.T, queries *db.Queries, agentID string) string {
+	t.Helper()
+	row, err := queries.GetAgentByID(context.Background(), agentID)
+	require.NoError(t, err)
+	return parseOptions(row.Options)[agent.OptionIDModel]
+}
+
+func TestValidateModelRoutingPolicy(t *testing.T) {
+	tests := []struct {
+		name    string
+		rule    *leapmuxv1.ModelRoutingRule
+		wantErr bool
+	}{
+		{"rate limit", &leapmuxv1.ModelRoutingRule{Trigger: routeOnRateLimit, FromModel: "opus", TargetModel: "sonnet"}, false},
+		{"prompt", &leapmuxv1.ModelRoutingRule{Trigger: routeOnPrompt, PromptPattern: "^quick:", TargetModel: "haiku"}, false},
+		{"missing trigger", &leapmuxv1.ModelRoutingRule{TargetModel: "sonnet"}, true},
+		{"missing target", &leapmuxv1.ModelRoutingRule{Trigger: routeOnRateLimit}, true},
+		{"self route", &leapmuxv1.ModelRoutingRule{Trigger: routeOnRateLimit, FromModel: "opus", TargetModel: "opus"}, true},
+		{"prompt without pattern", &leapmuxv1.ModelRoutingRule{Trigger: routeOnPrompt, TargetModel: "haiku"}, true},
+		{"bad pattern", &leapmuxv1.ModelRoutingRule{Trigger: routeOnPrompt, PromptPattern: "(", TargetModel: "haiku"}, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := validateModelRoutingPolicy(&leapmuxv1.ModelRoutingPolicy{Rules: []*leapmuxv1.ModelRoutingRule{tt.rule}})
+			if tt.wantErr {
+				assert.Error(t, err)
+			} else {
+				assert.NoError(t, err)
+			}
+		})
+	}
+}
+
+func TestMatchModelRoute(t *testing.T) {
+	policy := &leapmuxv1.ModelRoutingPolicy{Rules: []*leapmuxv1.ModelRoutingRule{
+		{Trigger: routeOnRateLimit, FromModel: "opus", TargetModel: "sonnet"},
+		{Trigger: routeOnPrompt, PromptPattern: "(?i)typo", TargetModel: "haiku"},
+		{Trigger: routeOnPrompt, PromptPattern: ".", TargetModel: "sonnet"},
+	}}
+
+	rule := matchModelRoute(policy, routeOnRateLimit, "opus", "")
+	require.NotNil(t, rule)
+	assert.Equal(t, "sonnet", rule.GetTargetModel())
+	assert.Nil(t, matchModelRoute(policy, routeOnRateLimit, "haiku", ""), "from_model must match")
+
+	rule = matchModelRoute(policy, routeOnPrompt, "opus", "fix this Typo")
+	require.NotNil(t, rule)
+	assert.Equal(t, "haiku", rule.GetTargetModel(), "first matching rule wins")
+
+	rule = matchModelRoute(policy, routeOnPrompt, "opus", "refactor the parser")
+	require.NotNil(t, rule)
+	assert.Equal(t, "sonnet", rule.GetTargetModel())
+	assert.Nil(t, matchModelRoute(policy, routeOnPrompt, "sonnet", "refactor the parser"),
+		"a rule targeting the current model routes nowhere")
+}
+
+func TestRouteTurnModel_PromptRouteRevertsOnNextTurn(t *testing.T) {
+	svc, _, _ := setupTestService(t, withWorkspaces("ws-1"))
+	seedModelAgent(t, svc.Queries, "agent-1", "opus")
+	setWorkspaceModelRouting(t, svc.Queries, "ws-1", &leapmuxv1.ModelRoutingRule{
+		Trigger:       routeOnPrompt,
+		PromptPattern: "^quick:",
+		TargetModel:   "haiku",
+	})
+
+	turn := func(messageID, prompt string) turnRouting {
+		row, err := svc.Queries.GetAgentByID(context.Background(), "agent-1")
+		require.NoError(t, err)
+		state := svc.routeTurnModel(row, prompt)
+		svc.recordTurnModel("agent-1", messageID, state)
+		return state
+	}
+
+	state := turn("msg-1", "quick: rename foo")
+	assert.Equal(t, turnRouting{current: "haiku", base: "opus", routed: true}, state)
+	assert.Equal(t, "haiku", agentModel(t, svc.Queries, "agent-1"))
+
+	state = turn("msg-2", "now do the real work")
+	assert.Equal(t, turnRouting{current: "opus", base: "opus"}, state)
+	assert.Equal(t, "opus", agentModel(t, svc.Queries, "agent-1"))
+
+	rows, err := svc.Queries.ListAgentTurnModels(context.Background(), "agent-1")
+	require.NoError(t, err)
+	require.Len(t, rows, 2)
+	assert.Equal(t, "haiku", rows[0].Model)
+	assert.Equal(t, int64(1), rows[0].Routed)
+	assert.Equal(t, "opus", rows[1].Model)
+	assert.Equal(t, int64(0), rows[1].Routed)
+}
+
+func TestRouteTurnModel_UserModelChangeTakesOverRoutedTurn(t *testing.T) {
+	svc, _, _ := setupTestService(t, withWorkspaces("ws-1"))
+	row := seedModelAgent(t, svc.Queries, "agent-1", "haiku")
+	svc.recordTurnModel("agent-1", "msg-1", turnRouting{current: "haiku", base: "opus", routed: true})
+
+	// The user picked sonnet after the routed turn; it becomes the base.
+	require.NoError(t, svc.Queries.SetAgentOptions(context.Background(), db.SetAgentOptionsParams{
+		ID:      "agent-1",
+		Options: marshalOptions(map[string]string{agent.OptionIDModel: "sonnet"}),
+	}))
+	row, err := svc.Queries.GetAgentByID(context.Background(), row.ID)
+	require.NoError(t, err)
+
+	assert.Equal(t, turnRouting{current: "sonnet", base: "sonnet"}, svc.routeTurnModel(row, "hello"))
+	assert.Equal(t, "sonnet", agentModel(t, svc.Queries, "agent-1"))
+}
+
+func TestAutoContinue_RateLimitFailsOverToRoutedModel(t *testing.T) {
+	_, queries := setupTestDB(t)
+	seedModelAgent(t, queries, "agent-1", "opus")
+	setWorkspaceModelRouting(t, queries, "ws-1", &leapmuxv1.ModelRoutingRule{
+		Trigger:     routeOnRateLimit,
+		FromModel:   "opus",
+		TargetModel: "sonnet",
+	})
+
+	h := NewOutputHandler(nil, queries, nil, nil, nil)
+	t.Cleanup(func() { h.cleanupAutoContinue("agent-1") })
+	var switched []string
+	h.SetSwitchModelFunc(func(agentID, model string) {
+		switched = append(switched, agentID+":"+model)
+	})
+
+	before := time.Now().UTC()
+	h.scheduleAutoContinue("agent-1", agent.AutoContinueSchedule{
+		Reason: agent.AutoContinueReasonRateLimit,
+		DueAt:  before.Add(time.Hour),
+	})
+
+	assert.Equal(t, []string{"agent-1:sonnet"}, switched)
+	row, err := queries.GetAutoContinueSchedule(bgCtx(), db.GetAutoContinueScheduleParams{
+		AgentID: "agent-1",
+		Reason:  string(agent.AutoContinueReasonRateLimit),
+	})
+	require.NoError(t, err)
+	assert.True(t, row.DueAt.Before(before.Add(time.Minute)),
+		"failover restarts the turn instead of waiting for the reset, due_at=%s", row.DueAt.Time)
+}
+
+func TestWorkspaceModelRouting_SetAndGet(t *testing.T) {
+	_, d, w := setupTestService(t, withWorkspaces("ws-1"))
+
+	policy := &leapmuxv1.ModelRoutingPolicy{Rules: []*leapmuxv1.ModelRoutingRule{
+		{Trigger: routeOnRateLimit, FromModel: "opus", TargetModel: "sonnet"},
+	}}
+	dispatch(d, "SetWorkspaceModelRouting", &leapmuxv1.SetWorkspaceModelRoutingRequest{
+		WorkspaceId: "ws-1",
+		Policy:      policy,
+	}, w)
+	dispatch(d, "GetWorkspaceModelRouting", &leapmuxv1.GetWorkspaceModelRoutingRequest{WorkspaceId: "ws-1"}, w)
+	require.Empty(t, w.errors)
+	require.Len(t, w.responses, 2)
+	var resp leapmuxv1.GetWorkspaceModelRoutingResponse
+	require.NoError(t, proto.Unmarshal(w.responses[1].GetPayload(), &resp))
+	assert.True(t, proto.Equal(policy, resp.GetPolicy()))
+
+	dispatch(d, "SetWorkspaceModelRouting", &leapmuxv1.SetWorkspaceModelRoutingRequest{
+		WorkspaceId: "ws-1",
+		Policy: &leapmuxv1.ModelRoutingPolicy{Rules: []*leapmuxv1.ModelRoutingRule{
+			{Trigger: routeOnPrompt, TargetModel: "haiku"},
+		}},
+	}, w)
+	require.Len(t, w.errors, 1)
+	assert.Equal(t, codeInvalidArgument, w.errors[0].code)
+}
+
+func TestListAgentTurnModels(t *testing.T) {
+	svc, d, w := setupTestService(t, withWorkspaces("ws-1"))
+	seedModelAgent(t, svc.Queries, "agent-1", "opus")
+	svc.recordTurnModel("agent-1", "msg-1", turnRouting{current: "haiku", base: "opus", routed: true})
+
+	dispatch(d, "ListAgentTurnModels", &leapmuxv1.ListAgentTurnModelsRequest{AgentId: "agent-1"}, w)
+	require.Empty(t, w.errors)
+	require.Len(t, w.responses, 1)
+	var resp leapmuxv1.ListAgentTurnModelsResponse
+	require.NoError(t, proto.Unmarshal(w.responses[0].GetPayload(), &resp))
+	require.Len(t, resp.GetTurns(), 1)
+	turn := resp.GetTurns()[0]
+	assert.Equal(t, "msg-1", turn.GetMessageId())
+	assert.Equal(t, "haiku", turn.GetModel())
+	assert.Equal(t, "opus", turn.GetBaseModel())
+	assert.True(t, turn.GetRouted())
+	assert.NotEmpty(t, turn.GetCreatedAt())
+}
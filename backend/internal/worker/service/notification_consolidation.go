@@ -0,0 +1,124 @@
+package service
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"log/slog"
+
+	leapmuxv1 "github.com/leapmux/leapmux/generated/proto/leapmux/v1"
+	"github.com/leapmux/leapmux/internal/util/userid"
+	"github.com/leapmux/leapmux/internal/worker/channel"
+	db "github.com/leapmux/leapmux/internal/worker/generated/db"
+	"google.golang.org/protobuf/encoding/protojson"
+)
+
+// Bounds on a consolidation policy. A grace period beyond a day or a thread
+// cap beyond a thousand entries is indistinguishable from no limit.
+const (
+	maxUnmergedNotificationTypes    = 64
+	maxNotificationTypeLen          = 128
+	maxConsolidationGracePeriodSecs = 24 * 60 * 60
+	maxConsolidationThreadLength    = 1000
+)
+
+// validateNotificationConsolidationPolicy rejects negative or out-of-range
+// limits and malformed type lists.
+func validateNotificationConsolidationPolicy(policy *leapmuxv1.NotificationConsolidationPolicy) error {
+	if len(policy.GetUnmergedTypes()) > maxUnmergedNotificationTypes {
+		return fmt.Errorf("unmerged_types must not exceed %d entries", maxUnmergedNotificationTypes)
+	}
+	for i, t := range policy.GetUnmergedTypes() {
+		if t == "" {
+			return fmt.Errorf("unmerged_types[%d] is empty", i)
+		}
+		if len(t) > maxNotificationTypeLen {
+			return fmt.Errorf("unmerged_types[%d] must not exceed %d bytes", i, maxNotificationTypeLen)
+		}
+	}
+	if g := policy.GetGracePeriodSeconds(); g < 0 || g > maxConsolidationGracePeriodSecs {
+		return fmt.Errorf("grace_period_seconds must be between 0 and %d", maxConsolidationGracePeriodSecs)
+	}
+	if m := policy.GetMaxThreadLength(); m < 0 || m > maxConsolidationThreadLength {
+		return fmt.Errorf("max_thread_length must be between 0 and %d", maxConsolidationThreadLength)
+	}
+	return nil
+}
+
+func decodeNotificationConsolidation(raw string, err error) (*leapmuxv1.NotificationConsolidationPolicy, error) {
+	if errors.Is(err, sql.ErrNoRows) {
+		return &leapmuxv1.NotificationConsolidationPolicy{}, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	policy := &leapmuxv1.NotificationConsolidationPolicy{}
+	if err := protojson.Unmarshal([]byte(raw), policy); err != nil {
+		return nil, fmt.Errorf("decode notification consolidation: %w", err)
+	}
+	return policy, nil
+}
+
+// loadWorkspaceNotificationConsolidation reads the workspace's stored policy.
+// A workspace with no row yields an empty policy (built-in behavior).
+func loadWorkspaceNotificationConsolidation(ctx context.Context, queries *db.Queries, workspaceID string) (*leapmuxv1.NotificationConsolidationPolicy, error) {
+	return decodeNotificationConsolidation(queries.GetWorkspaceNotificationConsolidation(ctx, workspaceID))
+}
+
+// agentNotificationConsolidation returns the consolidation policy of
+// agentID's workspace. Consolidation is presentation only, so a policy that
+// fails to load falls back to the built-in behavior (nil).
+func (h *OutputHandler) agentNotificationConsolidation(agentID string) *leapmuxv1.NotificationConsolidationPolicy {
+	policy, err := decodeNotificationConsolidation(h.queries.GetAgentNotificationConsolidation(bgCtx(), agentID))
+	if err != nil {
+		slog.Warn("notification consolidation load failed; using built-in rules", "agent_id", agentID, "error", err)
+		return nil
+	}
+	return policy
+}
+
+func registerNotificationConsolidationHandlers(d registrar, svc *Service) {
+	registerWorkspaceGated(d, "GetWorkspaceNotificationConsolidation",
+		func(ctx context.Context, _ userid.UserID, r *leapmuxv1.GetWorkspaceNotificationConsolidationRequest, sender channel.ResponseWriter) {
+			policy, err := loadWorkspaceNotificationConsolidation(ctx, svc.Queries, r.GetWorkspaceId())
+			if err != nil {
+				slog.Error("failed to load notification consolidation", "workspace_id", r.GetWorkspaceId(), "error", err)
+				sendInternalError(sender, "failed to load notification consolidation")
+				return
+			}
+			sendProtoResponse(sender, &leapmuxv1.GetWorkspaceNotificationConsolidationResponse{Policy: policy})
+		})
+
+	registerWorkspaceGated(d, "SetWorkspaceNotificationConsolidation",
+		func(_ context.Context, _ userid.UserID, r *leapmuxv1.SetWorkspaceNotificationConsolidationRequest, sender channel.ResponseWriter) {
+			policy := r.GetPolicy()
+			if policy == nil {
+				policy = &leapmuxv1.NotificationConsolidationPolicy{}
+			}
+			if err := validateNotificationConsolidationPolicy(policy); err != nil {
+				sendInvalidArgument(sender, err.Error())
+				return
+			}
+
+			var err error
+			if len(policy.GetUnmergedTypes()) == 0 && policy.GetGracePeriodSeconds() == 0 && policy.GetMaxThreadLength() == 0 {
+				err = svc.Queries.DeleteWorkspaceNotificationConsolidation(bgCtx(), r.GetWorkspaceId())
+			} else {
+				var raw []byte
+				raw, err = protojson.Marshal(policy)
+				if err == nil {
+					err = svc.Queries.UpsertWorkspaceNotificationConsolidation(bgCtx(), db.UpsertWorkspaceNotificationConsolidationParams{
+						WorkspaceID: r.GetWorkspaceId(),
+						Policy:      string(raw),
+					})
+				}
+			}
+			if err != nil {
+				slog.Error("failed to save notification consolidation", "workspace_id", r.GetWorkspaceId(), "error", err)
+				sendInternalError(sender, "failed to save notification consolidation")
+				return
+			}
+			sendProtoResponse(sender, &leapmuxv1.SetWorkspaceNotificationConsolidationResponse{Policy: policy})
+		})
+}
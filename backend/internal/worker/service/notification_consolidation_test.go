@@ -0,0 +1,117 @@
+package service
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/protobuf/proto"
+
+	leapmuxv1 "github.com/leapmux/leapmux/generated/proto/leapmux/v1"
+	"github.com/leapmux/leapmux/internal/worker/agent"
+	db "github.com/leapmux/leapmux/internal/worker/generated/db"
+)
+
+func TestValidateNotificationConsolidationPolicy(t *testing.T) {
+	valid := &leapmuxv1.NotificationConsolidationPolicy{
+		UnmergedTypes:      []string{agent.NotificationTypeSettingsChanged},
+		GracePeriodSeconds: 30,
+		MaxThreadLength:    10,
+	}
+	assert.NoError(t, validateNotificationConsolidationPolicy(valid))
+	assert.NoError(t, validateNotificationConsolidationPolicy(&leapmuxv1.NotificationConsolidationPolicy{}))
+
+	for name, policy := range map[string]*leapmuxv1.NotificationConsolidationPolicy{
+		"empty type":       {UnmergedTypes: []string{""}},
+		"negative grace":   {GracePeriodSeconds: -1},
+		"grace too long":   {GracePeriodSeconds: maxConsolidationGracePeriodSecs + 1},
+		"negative length":  {MaxThreadLength: -1},
+		"length too large": {MaxThreadLength: maxConsolidationThreadLength + 1},
+	} {
+		assert.Error(t, validateNotificationConsolidationPolicy(policy), name)
+	}
+}
+
+func TestWorkspaceNotificationConsolidation_SetAndGet(t *testing.T) {
+	svc, d, w := setupTestService(t, withWorkspaces("ws-1"))
+
+	policy := &leapmuxv1.NotificationConsolidationPolicy{
+		UnmergedTypes:   []string{agent.NotificationTypeSettingsChanged},
+		MaxThreadLength: 5,
+	}
+	dispatch(d, "SetWorkspaceNotificationConsolidation", &leapmuxv1.SetWorkspaceNotificationConsolidationRequest{
+		WorkspaceId: "ws-1",
+		Policy:      policy,
+	}, w)
+	dispatch(d, "GetWorkspaceNotificationConsolidation", &leapmuxv1.GetWorkspaceNotificationConsolidationRequest{WorkspaceId: "ws-1"}, w)
+	require.Empty(t, w.errors)
+	require.Len(t, w.responses, 2)
+	var resp leapmuxv1.GetWorkspaceNotificationConsolidationResponse
+	require.NoError(t, proto.Unmarshal(w.responses[1].GetPayload(), &resp))
+	assert.True(t, proto.Equal(policy, resp.GetPolicy()))
+
+	// An empty policy restores the built-in rules by dropping the row.
+	dispatch(d, "SetWorkspaceNotificationConsolidation", &leapmuxv1.SetWorkspaceNotificationConsolidationRequest{WorkspaceId: "ws-1"}, w)
+	require.Empty(t, w.errors)
+	_, err := svc.Queries.GetWorkspaceNotificationConsolidation(context.Background(), "ws-1")
+	assert.Error(t, err)
+}
+
+func TestConsolidateNotificationThread_UnmergedTypesStaySeparate(t *testing.T) {
+	msgs := []json.RawMessage{
+		raw(t, settingsChanged("default", "plan")),
+		raw(t, settingsChanged("plan", "default")),
+	}
+	plugin := agent.ProviderFor(leapmuxv1.AgentProvider_AGENT_PROVIDER_CLAUDE_CODE)
+
+	assert.Empty(t, consolidateNotificationThread(msgs, plugin, nil), "built-in rules cancel the round trip out")
+
+	policy := &leapmuxv1.NotificationConsolidationPolicy{UnmergedTypes: []string{agent.NotificationTypeSettingsChanged}}
+	result := consolidateNotificationThread(msgs, plugin, policy)
+	require.Len(t, result, 2)
+	assert.Equal(t, []string{agent.NotificationTypeSettingsChanged, agent.NotificationTypeSettingsChanged}, types(t, result))
+}
+
+// notificationRowCount counts the persisted notification rows of agent-1.
+func notificationRowCount(t *testing.T, queries *db.Queries) int {
+	t.Helper()
+	rows, err := queries.ListMessagesByAgentID(context.Background(), db.ListMessagesByAgentIDParams{AgentID: "agent-1", Limit: 100})
+	require.NoError(t, err)
+	return len(rows)
+}
+
+func TestNotificationThread_MaxLengthStartsNewThread(t *testing.T) {
+	svc, _, _ := setupTestService(t, withWorkspaces("ws-1"))
+	seedGuardedAgent(t, svc, agent.PermissionModeDefault)
+	require.NoError(t, svc.Queries.UpsertWorkspaceNotificationConsolidation(context.Background(), db.UpsertWorkspaceNotificationConsolidationParams{
+		WorkspaceID: "ws-1",
+		Policy:      `{"maxThreadLength":2}`,
+	}))
+
+	for _, typ := range []string{agent.NotificationTypeInterrupted, agent.NotificationTypeContextCleared, agent.NotificationTypePlanExecution} {
+		svc.Output.PersistLeapMuxNotification("agent-1", claudeProvider, map[string]interface{}{"type": typ})
+	}
+	assert.Equal(t, 2, notificationRowCount(t, svc.Queries))
+}
+
+func TestNotificationThread_GracePeriodStartsNewThread(t *testing.T) {
+	svc, _, _ := setupTestService(t, withWorkspaces("ws-1"))
+	seedGuardedAgent(t, svc, agent.PermissionModeDefault)
+	require.NoError(t, svc.Queries.UpsertWorkspaceNotificationConsolidation(context.Background(), db.UpsertWorkspaceNotificationConsolidationParams{
+		WorkspaceID: "ws-1",
+		Policy:      `{"gracePeriodSeconds":60}`,
+	}))
+
+	svc.Output.PersistLeapMuxNotification("agent-1", claudeProvider, map[string]interface{}{"type": agent.NotificationTypeInterrupted})
+	svc.Output.PersistLeapMuxNotification("agent-1", claudeProvider, map[string]interface{}{"type": agent.NotificationTypeContextCleared})
+	require.Equal(t, 1, notificationRowCount(t, svc.Queries), "within the grace period the thread merges")
+
+	ref, ok := svc.Output.notifThreads.current("agent-1")
+	require.True(t, ok)
+	ref.lastAt = time.Now().Add(-2 * time.Minute)
+	svc.Output.PersistLeapMuxNotification("agent-1", claudeProvider, map[string]interface{}{"type": agent.NotificationTypePlanExecution})
+	assert.Equal(t, 2, notificationRowCount(t, svc.Queries))
+}
@@ -0,0 +1,63 @@
+package service
+
+import (
+	"context"
+	"sync/atomic"
+
+	leapmuxv1 "github.com/leapmux/leapmux/generated/proto/leapmux/v1"
+	"github.com/leapmux/leapmux/internal/metrics"
+	"github.com/leapmux/leapmux/internal/util/userid"
+	"github.com/leapmux/leapmux/internal/worker/channel"
+)
+
+// Notification threading outcomes, the label values of
+// metrics.NotificationThreadEventsTotal.
+const (
+	threadOutcomeMerge              = "merge"
+	threadOutcomeGraceRevive        = "grace_revive"
+	threadOutcomeMergeFailure       = "merge_failure"
+	threadOutcomeStandaloneFallback = "standalone_fallback"
+)
+
+// notifThreadStats counts notification threading outcomes since the
+// worker started. The Prometheus counters only reach a scrape when the
+// worker runs inside the Hub, so a standalone worker reports these
+// through GetNotificationThreadStats instead.
+type notifThreadStats struct {
+	merges              atomic.Uint64
+	graceRevives        atomic.Uint64
+	mergeFailures       atomic.Uint64
+	standaloneFallbacks atomic.Uint64
+}
+
+func (s *notifThreadStats) record(outcome string) {
+	switch outcome {
+	case threadOutcomeMerge:
+		s.merges.Add(1)
+	case threadOutcomeGraceRevive:
+		s.graceRevives.Add(1)
+	case threadOutcomeMergeFailure:
+		s.mergeFailures.Add(1)
+	case threadOutcomeStandaloneFallback:
+		s.standaloneFallbacks.Add(1)
+	}
+	metrics.NotificationThreadEventsTotal.WithLabelValues(outcome).Inc()
+}
+
+func registerNotificationThreadStatsHandlers(d ownerOnlyRegistrar, svc *Service) {
+	d.Register("GetNotificationThreadStats", func(_ context.Context, _ userid.UserID, req *leapmuxv1.InnerRpcRequest, sender channel.ResponseWriter) {
+		var r leapmuxv1.GetNotificationThreadStatsRequest
+		if err := unmarshalRequest(req, &r); err != nil {
+			sendInvalidArgument(sender, "invalid request")
+			return
+		}
+		stats := &svc.Output.notifThreads.stats
+		sendProtoResponse(sender, &leapmuxv1.GetNotificationThreadStatsResponse{
+			Merges:              stats.merges.Load(),
+			GraceRevives:        stats.graceRevives.Load(),
+			MergeFailures:       stats.mergeFailures.Load(),
+			StandaloneFallbacks: stats.standaloneFallbacks.Load(),
+			Threads:             svc.Output.notifThreads.states(r.GetAgentId()),
+		})
+	})
+}
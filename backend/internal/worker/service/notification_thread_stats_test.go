@@ -0,0 +1,54 @@
+package service
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/protobuf/proto"
+
+	leapmuxv1 "github.com/leapmux/leapmux/generated/proto/leapmux/v1"
+	"github.com/leapmux/leapmux/internal/worker/agent"
+	db "github.com/leapmux/leapmux/internal/worker/generated/db"
+)
+
+func TestGetNotificationThreadStats(t *testing.T) {
+	svc, d, w := setupTestService(t, withWorkspaces("ws-1"))
+	seedGuardedAgent(t, svc, agent.PermissionModeDefault)
+	require.NoError(t, svc.Queries.UpsertWorkspaceNotificationConsolidation(context.Background(), db.UpsertWorkspaceNotificationConsolidationParams{
+		WorkspaceID: "ws-1",
+		Policy:      `{"gracePeriodSeconds":60}`,
+	}))
+
+	svc.Output.PersistLeapMuxNotification("agent-1", claudeProvider, map[string]interface{}{"type": agent.NotificationTypeInterrupted})
+	svc.Output.PersistLeapMuxNotification("agent-1", claudeProvider, map[string]interface{}{"type": agent.NotificationTypeContextCleared})
+	ref, ok := svc.Output.notifThreads.current("agent-1")
+	require.True(t, ok)
+	ref.lastAt = time.Now().Add(-2 * time.Minute)
+	svc.Output.PersistLeapMuxNotification("agent-1", claudeProvider, map[string]interface{}{"type": agent.NotificationTypePlanExecution})
+
+	dispatch(d, "GetNotificationThreadStats", &leapmuxv1.GetNotificationThreadStatsRequest{AgentId: "agent-1"}, w)
+	require.Empty(t, w.errors)
+	require.Len(t, w.responses, 1)
+	var resp leapmuxv1.GetNotificationThreadStatsResponse
+	require.NoError(t, proto.Unmarshal(w.responses[0].GetPayload(), &resp))
+	assert.EqualValues(t, 1, resp.GetMerges())
+	assert.EqualValues(t, 1, resp.GetGraceRevives())
+	assert.Zero(t, resp.GetMergeFailures())
+	assert.EqualValues(t, 1, resp.GetStandaloneFallbacks(), "the lapsed grace period opened a new thread")
+
+	require.Len(t, resp.GetThreads(), 1)
+	thread := resp.GetThreads()[0]
+	ref, _ = svc.Output.notifThreads.current("agent-1")
+	assert.Equal(t, ref.msgID, thread.GetMessageId())
+	assert.Equal(t, leapmuxv1.MessageSource_MESSAGE_SOURCE_LEAPMUX, thread.GetSource())
+	assert.NotEmpty(t, thread.GetLastAt())
+
+	w = newTestWriter()
+	dispatch(d, "GetNotificationThreadStats", &leapmuxv1.GetNotificationThreadStatsRequest{AgentId: "agent-2"}, w)
+	require.Len(t, w.responses, 1)
+	require.NoError(t, proto.Unmarshal(w.responses[0].GetPayload(), &resp))
+	assert.Empty(t, resp.GetThreads(), "another agent has no open thread")
+}
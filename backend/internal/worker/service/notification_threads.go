@@ -0,0 +1,87 @@
+package service
+
+import (
+	"sort"
+	"sync"
+
+	leapmuxv1 "github.com/leapmux/leapmux/generated/proto/leapmux/v1"
+	"github.com/leapmux/leapmux/internal/util/timefmt"
+)
+
+// notifThreads holds each agent's open notification thread, the wrapper
+// row its next notification merges into, and counts how merges went.
+type notifThreads struct {
+	mus   sync.Map // agentID -> *sync.Mutex
+	refs  sync.Map // agentID -> *notifThreadRef
+	stats notifThreadStats
+}
+
+// mutex returns agentID's mutex, which serializes its notifications and
+// guards its thread ref's fields.
+func (t *notifThreads) mutex(agentID string) *sync.Mutex {
+	v, _ := t.mus.LoadOrStore(agentID, &sync.Mutex{})
+	return v.(*sync.Mutex)
+}
+
+// current returns agentID's open thread. Callers hold its mutex.
+func (t *notifThreads) current(agentID string) (*notifThreadRef, bool) {
+	v, ok := t.refs.Load(agentID)
+	if !ok {
+		return nil, false
+	}
+	return v.(*notifThreadRef), true
+}
+
+// set makes ref agentID's open thread. Callers hold its mutex.
+func (t *notifThreads) set(agentID string, ref *notifThreadRef) {
+	t.refs.Store(agentID, ref)
+}
+
+// clear closes agentID's open thread, so that its next notification
+// starts a new wrapper.
+func (t *notifThreads) clear(agentID string) {
+	if _, ok := t.refs.Load(agentID); !ok {
+		return
+	}
+	mu := t.mutex(agentID)
+	mu.Lock()
+	defer mu.Unlock()
+	t.refs.Delete(agentID)
+}
+
+// forget drops everything held for agentID, once it is closed for good.
+func (t *notifThreads) forget(agentID string) {
+	t.mus.Delete(agentID)
+	t.refs.Delete(agentID)
+}
+
+// states returns the open notification threads, ordered by agent,
+// limited to agentID when it is set.
+func (t *notifThreads) states(agentID string) []*leapmuxv1.NotificationThreadState {
+	var out []*leapmuxv1.NotificationThreadState
+	t.refs.Range(func(k, v any) bool {
+		id := k.(string)
+		if agentID != "" && id != agentID {
+			return true
+		}
+		out = append(out, t.state(id, v.(*notifThreadRef)))
+		return true
+	})
+	sort.Slice(out, func(i, j int) bool { return out[i].GetAgentId() < out[j].GetAgentId() })
+	return out
+}
+
+// state describes an agent's open notification thread.
+func (t *notifThreads) state(agentID string, ref *notifThreadRef) *leapmuxv1.NotificationThreadState {
+	// The ref is mutated under the agent's notification mutex.
+	mu := t.mutex(agentID)
+	mu.Lock()
+	defer mu.Unlock()
+	return &leapmuxv1.NotificationThreadState{
+		AgentId:   agentID,
+		MessageId: ref.msgID,
+		Seq:       ref.seq,
+		Source:    ref.source,
+		LastAt:    timefmt.Format(ref.lastAt),
+	}
+}
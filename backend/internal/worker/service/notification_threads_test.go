@@ -0,0 +1,44 @@
+package service
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	leapmuxv1 "github.com/leapmux/leapmux/generated/proto/leapmux/v1"
+)
+
+func TestNotifThreads(t *testing.T) {
+	var threads notifThreads
+	_, ok := threads.current("agent-1")
+	assert.False(t, ok)
+	threads.clear("agent-1")
+
+	threads.set("agent-2", &notifThreadRef{msgID: "m-2", seq: 7, source: leapmuxv1.MessageSource_MESSAGE_SOURCE_LEAPMUX, lastAt: time.Now()})
+	threads.set("agent-1", &notifThreadRef{msgID: "m-1", seq: 3})
+
+	ref, ok := threads.current("agent-1")
+	require.True(t, ok)
+	assert.Equal(t, "m-1", ref.msgID)
+
+	states := threads.states("")
+	require.Len(t, states, 2)
+	assert.Equal(t, "agent-1", states[0].GetAgentId())
+	assert.Equal(t, "agent-2", states[1].GetAgentId())
+	assert.Equal(t, int64(7), states[1].GetSeq())
+	assert.NotEmpty(t, states[1].GetLastAt())
+	assert.Len(t, threads.states("agent-2"), 1)
+
+	threads.clear("agent-1")
+	_, ok = threads.current("agent-1")
+	assert.False(t, ok, "clear closes the thread")
+	_, ok = threads.mus.Load("agent-1")
+	assert.True(t, ok, "clear keeps the agent's mutex")
+
+	threads.forget("agent-2")
+	assert.Empty(t, threads.states(""))
+	_, ok = threads.mus.Load("agent-2")
+	assert.False(t, ok, "forget drops the agent's mutex")
+}
@@ -0,0 +1,72 @@
+package service
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"log/slog"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/protobuf/proto"
+
+	leapmuxv1 "github.com/leapmux/leapmux/generated/proto/leapmux/v1"
+	"github.com/leapmux/leapmux/internal/errcode"
+	"github.com/leapmux/leapmux/internal/util/idempotency"
+	"github.com/leapmux/leapmux/internal/util/userid"
+	"github.com/leapmux/leapmux/internal/worker/channel"
+)
+
+// claimOpenAgentKey claims r's idempotency key on behalf of userID. It
+// returns ok=false when it has already answered the RPC: with the agent an
+// earlier OpenAgent under the same key opened, or with an error. With
+// ok=true the caller opens the agent and then either Completes the
+// returned key with the agent id or Releases it; the key is "" (and both
+// calls are no-ops) when r carries none.
+//
+// Keys are scoped to the user so two users cannot collide on, or probe,
+// each other's keys.
+func (svc *Service) claimOpenAgentKey(ctx context.Context, userID userid.UserID, r *leapmuxv1.OpenAgentRequest, sender channel.ResponseWriter) (string, bool) {
+	key := r.GetIdempotencyKey()
+	if key == "" {
+		return "", true
+	}
+	if len(key) > idempotency.MaxKeyLen {
+		sendInvalidArgument(sender, fmt.Sprintf("idempotency_key exceeds %d bytes", idempotency.MaxKeyLen))
+		return "", false
+	}
+	claimKey := userID.String() + "\x00" + key
+	fingerprint := proto.Clone(r).(*leapmuxv1.OpenAgentRequest)
+	fingerprint.IdempotencyKey = ""
+	agentID, owned, err := svc.openAgentKeys.Claim(ctx, claimKey, idempotency.Fingerprint(fingerprint))
+	switch {
+	case errors.Is(err, idempotency.ErrKeyReused):
+		sendInvalidArgument(sender, err.Error())
+		return "", false
+	case err != nil:
+		_ = sender.SendError(int32(codes.Canceled), "canceled waiting for the original OpenAgent")
+		return "", false
+	case !owned:
+		svc.replayOpenAgent(sender, agentID)
+		return "", false
+	}
+	return claimKey, true
+}
+
+// replayOpenAgent answers a retried OpenAgent with the agent the original
+// call opened, as it stands now.
+func (svc *Service) replayOpenAgent(sender channel.ResponseWriter, agentID string) {
+	dbAgent, err := svc.getAgentByID(bgCtx(), agentID)
+	if errors.Is(err, sql.ErrNoRows) {
+		sendCodedError(sender, codes.NotFound, errcode.Wrap(errcode.AgentNotFound, errors.New("agent not found")))
+		return
+	}
+	if err != nil {
+		slog.Error("failed to fetch agent for a retried OpenAgent", "agent_id", agentID, "error", err)
+		sendInternalError(sender, "failed to fetch agent")
+		return
+	}
+	sendProtoResponse(sender, &leapmuxv1.OpenAgentResponse{
+		Agent: svc.agentToProto(&dbAgent, svc.Agents.HasAgent(agentID), nil),
+	})
+}
@@ -0,0 +1,38 @@
+package service
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	leapmuxv1 "github.com/leapmux/leapmux/generated/proto/leapmux/v1"
+	"github.com/leapmux/leapmux/internal/worker/agent"
+)
+
+func TestOpenAgent_IdempotencyKeyReturnsOriginalAgent(t *testing.T) {
+	svc, d, w := setupTestService(t, withWorkspaces("ws-1"))
+	svc.startAgentFn = func(context.Context, agent.Options, agent.OutputSink) (map[string]string, error) {
+		return map[string]string{}, nil
+	}
+
+	req := &leapmuxv1.OpenAgentRequest{WorkspaceId: "ws-1", WorkingDir: t.TempDir(), Title: "retried", IdempotencyKey: "key-1"}
+	dispatch(d, "OpenAgent", req, w)
+	require.Empty(t, w.errors)
+	first := decodeResponse[leapmuxv1.OpenAgentResponse](t, w)
+
+	dispatch(d, "OpenAgent", req, w)
+	require.Empty(t, w.errors)
+	retry := decodeResponse[leapmuxv1.OpenAgentResponse](t, w)
+	assert.Equal(t, first.GetAgent().GetId(), retry.GetAgent().GetId(), "a retry returns the original agent")
+
+	ids, err := svc.Queries.ListOpenAgentIDsByWorkspaceID(context.Background(), "ws-1")
+	require.NoError(t, err)
+	assert.Len(t, ids, 1, "the retry must not open a second agent")
+
+	changed := &leapmuxv1.OpenAgentRequest{WorkspaceId: "ws-1", WorkingDir: req.GetWorkingDir(), Title: "renamed", IdempotencyKey: "key-1"}
+	dispatch(d, "OpenAgent", changed, w)
+	require.Len(t, w.errors, 1)
+	assert.Equal(t, codeInvalidArgument, w.errors[0].code)
+}
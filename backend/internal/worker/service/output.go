@@ -27,6 +27,7 @@ import (
 	"github.com/leapmux/leapmux/internal/worker/agent"
 	db "github.com/leapmux/leapmux/internal/worker/generated/db"
 	"github.com/leapmux/leapmux/internal/worker/gitutil"
+	"github.com/leapmux/leapmux/internal/worker/redact"
 	"github.com/leapmux/leapmux/internal/worker/todoevents"
 	"github.com/leapmux/leapmux/internal/worker/wakelock"
 )
@@ -515,6 +516,9 @@ type notifThreadRef struct {
 	msgID  string
 	seq    int64
 	source leapmuxv1.MessageSource
+	// lastAt is when the thread last took a notification, for the
+	// workspace's consolidation grace period.
+	lastAt time.Time
 }
 
 // notifThreadWrapperType is the constant value of the wrapper's `type`
@@ -597,9 +601,8 @@ type OutputHandler struct {
 	agents  *agent.Manager
 	DataDir string
 
-	// Per-agent notification threading state (concurrent access).
-	notifMu         sync.Map // agentID -> *sync.Mutex
-	lastNotifThread sync.Map // agentID -> *notifThreadRef
+	// Per-agent notification threading state (see notification_threads.go).
+	notifThreads notifThreads
 
 	// Per-agent span tracking (concurrent access).
 	spanTrackers sync.Map // agentID -> *SpanTracker
@@ -610,16 +613,43 @@ type OutputHandler struct {
 	// per-agent state above.
 	todos sync.Map // agentID -> *agentTodoCache
 
+	// Per-agent activity for idle parking (see idle_park.go).
+	activity sync.Map // agentID -> *agentActivity
+
 	// Plan mode tool_use tracking (shared across agents).
-	planModeToolUse sync.Map // tool_use_id -> target mode string ("plan" or "default")
+	planModes planModeTracker
 
 	// Auto-continue timers keyed by agent_id + reason.
-	autoContinue sync.Map // scheduleKey -> *autoContinueTimerState
+	autoContinueTimers autoContinueTimers
+
+	// The latest sink of each agent, whose session info DebugAgentState
+	// reports.
+	sinks sync.Map // agentID -> *agentOutputSink
+
+	// Serializes each agent's turn-end artifact collection (see artifacts.go).
+	artifactLocks sync.Map // agentID -> *sync.Mutex
+
+	// Per-agent shell tool calls that run tests, awaiting their output
+	// (see test_runs.go).
+	testRunCalls sync.Map // agentID -> *sync.Map (span id -> command)
+	// Per-agent shell tool calls that open a pull request, awaiting their
+	// output (see analytics.go).
+	pullRequestCalls sync.Map // agentID -> *sync.Map (span id -> struct{})
+
+	// One-shot hooks run when an agent next ends a turn (see
+	// onNextTurnEnd).
+	turnEndHooks sync.Map // agentID -> func()
 
 	// sendMessageFunc is called by auto-continue to inject a synthetic
 	// user message. Set via SetSendMessageFunc in service.New.
 	sendMessageFunc func(agentID, content string)
 
+	// switchModelFunc is called by the retry policy engine to move an agent
+	// onto its fallback model. Set via SetSwitchModelFunc in service.New; nil
+	// in tests that build an OutputHandler directly, where an exhausted
+	// policy then stops retrying instead of switching.
+	switchModelFunc func(agentID, model string)
+
 	// agentStarting reports whether the agent is still in its startup window
 	// (registered in the AgentStartup registry). Set via SetAgentStartingFunc
 	// in service.New; nil in tests that build an OutputHandler directly, where
@@ -628,6 +658,44 @@ type OutputHandler struct {
 	// that landed mid-startup with the agent's confirmed launch settings.
 	agentStarting func(agentID string) bool
 
+	// divertControlRequest lets the service answer a control request before
+	// it is persisted (see agent.OutputSink.DivertControlRequest). Set via
+	// SetControlRequestDiverter in service.New; nil diverts nothing.
+	divertControlRequest func(agentID, requestID string, payload []byte) bool
+
+	// observeSessionInfo is called with the JSON of the session info
+	// values each broadcast changed. Set via SetSessionInfoObserver in
+	// service.New; nil in tests that build an OutputHandler directly.
+	observeSessionInfo func(agentID string, provider leapmuxv1.AgentProvider, changed map[string][]byte)
+
+	// observeSpan is called with the span info of each message an agent
+	// persists, turnEnd set for its turn-end envelope. Set via
+	// SetSpanObserver in service.New; nil in tests that build an
+	// OutputHandler directly.
+	observeSpan func(agentID string, provider leapmuxv1.AgentProvider, span agent.SpanInfo, turnEnd bool)
+
+	// observeControlRequest is called with each control request an agent
+	// raises once it is persisted. Set via SetControlRequestObserver in
+	// service.New; nil in tests that build an OutputHandler directly.
+	observeControlRequest func(agentID string, provider leapmuxv1.AgentProvider, requestID string, payload []byte)
+
+	// orgRetryPolicy returns the org's auto-continue policy, whose rules
+	// replace the workspace's. Set via SetOrgRetryPolicyFunc in service.New;
+	// nil leaves the workspace policy alone.
+	orgRetryPolicy func() *leapmuxv1.RetryPolicy
+
+	// classifier tags persisted messages that hold personal data. Set via
+	// SetClassifier in service.New; nil tags nothing.
+	classifier *redact.Classifier
+
+	// immutableTranscripts reports whether the org keeps immutable
+	// transcripts (see Service.ImmutableTranscripts). Set via
+	// SetImmutableTranscriptsFunc in service.New; nil never chains.
+	immutableTranscripts func() bool
+	// chainLocks holds a *sync.Mutex per agent, serializing the
+	// read-then-append of its hash chain.
+	chainLocks sync.Map
+
 	// wakeLock prevents system sleep while there is agent/terminal activity.
 	wakeLock *wakelock.ActivityTracker
 
@@ -635,8 +703,8 @@ type OutputHandler struct {
 }
 
 // NewOutputHandler creates a new OutputHandler. sqlDB is used for the
-// agent_todos snapshot transaction; tests that never trigger a
-// snapshot may pass nil.
+// agent_todos snapshot transaction and for chaining messages; tests that
+// trigger neither may pass nil.
 func NewOutputHandler(sqlDB *sql.DB, queries *db.Queries, watcher *WatcherManager, agents *agent.Manager, wl *wakelock.ActivityTracker) *OutputHandler {
 	return &OutputHandler{
 		queries:  queries,
@@ -663,6 +731,13 @@ func (h *OutputHandler) SetSendMessageFunc(fn func(agentID, content string)) {
 	h.sendMessageFunc = fn
 }
 
+// SetSwitchModelFunc sets the callback the retry policy engine uses to
+// switch an agent to its fallback model. Must be called before any agent
+// output is processed.
+func (h *OutputHandler) SetSwitchModelFunc(fn func(agentID, model string)) {
+	h.switchModelFunc = fn
+}
+
 // SetAgentStartingFunc wires the predicate PersistSettingsRefresh uses to detect
 // the startup window (see the agentStarting field). Call before any agent output
 // is processed.
@@ -670,13 +745,59 @@ func (h *OutputHandler) SetAgentStartingFunc(fn func(agentID string) bool) {
 	h.agentStarting = fn
 }
 
+// SetControlRequestDiverter wires the hook DivertControlRequest consults.
+// Call before any agent output is processed.
+func (h *OutputHandler) SetControlRequestDiverter(fn func(agentID, requestID string, payload []byte) bool) {
+	h.divertControlRequest = fn
+}
+
+// SetSessionInfoObserver wires the observeSessionInfo hook. Call before
+// any agent output is processed.
+func (h *OutputHandler) SetSessionInfoObserver(fn func(agentID string, provider leapmuxv1.AgentProvider, changed map[string][]byte)) {
+	h.observeSessionInfo = fn
+}
+
+// SetSpanObserver wires the observeSpan hook. Call before any agent output
+// is processed.
+func (h *OutputHandler) SetSpanObserver(fn func(agentID string, provider leapmuxv1.AgentProvider, span agent.SpanInfo, turnEnd bool)) {
+	h.observeSpan = fn
+}
+
+// SetControlRequestObserver wires the observeControlRequest hook. Call
+// before any agent output is processed.
+func (h *OutputHandler) SetControlRequestObserver(fn func(agentID string, provider leapmuxv1.AgentProvider, requestID string, payload []byte)) {
+	h.observeControlRequest = fn
+}
+
+// SetOrgRetryPolicyFunc wires the org layer retryRuleForAgent consults.
+// Call before any agent output is processed.
+func (h *OutputHandler) SetOrgRetryPolicyFunc(fn func() *leapmuxv1.RetryPolicy) {
+	h.orgRetryPolicy = fn
+}
+
+// SetClassifier sets the classifier persisted messages are tagged with.
+// Call before any agent output is processed.
+func (h *OutputHandler) SetClassifier(c *redact.Classifier) {
+	h.classifier = c
+}
+
+// SetImmutableTranscriptsFunc wires the compliance mode check. Call before
+// any agent output is processed.
+func (h *OutputHandler) SetImmutableTranscriptsFunc(fn func() bool) {
+	h.immutableTranscripts = fn
+}
+
 // CleanupAgent removes all per-agent state from the handler's maps.
 // Call this when an agent is permanently closed.
 func (h *OutputHandler) CleanupAgent(agentID string) {
-	h.notifMu.Delete(agentID)
-	h.lastNotifThread.Delete(agentID)
+	h.notifThreads.forget(agentID)
 	h.spanTrackers.Delete(agentID)
 	h.todos.Delete(agentID)
+	h.activity.Delete(agentID)
+	h.sinks.Delete(agentID)
+	h.turnEndHooks.Delete(agentID)
+	h.testRunCalls.Delete(agentID)
+	h.pullRequestCalls.Delete(agentID)
 	h.cleanupAutoContinue(agentID)
 	// The control-response answer claims are DURABLE rows (control_response_answers), not in-memory
 	// state, so there is nothing to reclaim here -- a reused request_id is deduped per INSTANCE by its
@@ -728,7 +849,7 @@ func (h *OutputHandler) claimControlResponseAnswer(agentID, requestID, claimToke
 // per-exit handler keeps this state for a possible relaunch, so it isn't cleared there).
 func (h *OutputHandler) TrackedAgentIDs() []string {
 	seen := make(map[string]struct{})
-	for _, m := range []*sync.Map{&h.notifMu, &h.lastNotifThread, &h.spanTrackers, &h.todos} {
+	for _, m := range []*sync.Map{&h.notifThreads.mus, &h.notifThreads.refs, &h.spanTrackers, &h.todos, &h.activity, &h.sinks, &h.testRunCalls, &h.pullRequestCalls} {
 		m.Range(func(key, _ any) bool {
 			if id, ok := key.(string); ok {
 				seen[id] = struct{}{}
@@ -818,13 +939,15 @@ func (h *OutputHandler) snapshotPassthroughSpanLines(agentID string) string {
 
 // NewSink creates a per-agent OutputSink backed by this OutputHandler.
 func (h *OutputHandler) NewSink(agentID string, agentProvider leapmuxv1.AgentProvider) agent.OutputSink {
-	return &agentOutputSink{
+	sink := &agentOutputSink{
 		h:             h,
 		agentID:       agentID,
 		agentProvider: agentProvider,
 		plugin:        agent.ProviderFor(agentProvider),
 		tracker:       h.spanTracker(agentID),
 	}
+	h.sinks.Store(agentID, sink)
+	return sink
 }
 
 // agentOutputSink implements agent.OutputSink for a single agent.
@@ -862,7 +985,19 @@ type agentOutputSink struct {
 // --- OutputSink interface implementation ---
 
 func (s *agentOutputSink) PersistMessage(source leapmuxv1.MessageSource, content []byte, span agent.SpanInfo) error {
-	return s.h.persistAndBroadcast(s.agentID, s.agentProvider, source, content, span, s.tracker)
+	if err := s.h.persistAndBroadcast(s.agentID, s.agentProvider, source, content, span, s.tracker); err != nil {
+		return err
+	}
+	s.observeSpan(span, false)
+	s.h.observeTestRun(s.agentID, content, span)
+	s.h.observePullRequest(s.agentID, content, span)
+	return nil
+}
+
+func (s *agentOutputSink) observeSpan(span agent.SpanInfo, turnEnd bool) {
+	if s.h.observeSpan != nil {
+		s.h.observeSpan(s.agentID, s.agentProvider, span, turnEnd)
+	}
 }
 
 // PersistTurnEnd persists the universal turn-end divider envelope and
@@ -871,12 +1006,18 @@ func (s *agentOutputSink) PersistMessage(source leapmuxv1.MessageSource, content
 // response, Pi agent_end) routes here, so the side effect is explicit
 // at the call site. Runs BroadcastGitStatus on a goroutine so the
 // agent's stdout-read loop is not blocked by the git subprocesses plus
-// the DB lookup.
+// the DB lookup; artifact collection runs on its own goroutine for the
+// same reason.
 func (s *agentOutputSink) PersistTurnEnd(content []byte, span agent.SpanInfo) error {
+	s.h.endAgentTurn(s.agentID)
 	if err := s.h.persistAndBroadcast(s.agentID, s.agentProvider, leapmuxv1.MessageSource_MESSAGE_SOURCE_AGENT, content, span, s.tracker); err != nil {
 		return err
 	}
+	s.h.recordAgentMetrics(s.agentID, metricSample{metricTurns, 1})
+	s.observeSpan(span, true)
+	s.h.runTurnEndHook(s.agentID)
 	go s.BroadcastGitStatus()
+	go s.h.collectArtifacts(s.agentID)
 	return nil
 }
 
@@ -909,6 +1050,7 @@ func (s *agentOutputSink) ReserveSpanColor(spanID, parentSpanID string) int32 {
 }
 
 func (s *agentOutputSink) BroadcastStreamChunk(content []byte, spanID string, method string) {
+	s.h.touchAgent(s.agentID)
 	if !s.tracker.ShouldBroadcastStreamChunk() {
 		return
 	}
@@ -956,9 +1098,16 @@ func (s *agentOutputSink) PersistControlRequest(requestID string, payload []byte
 	}); err != nil {
 		slog.Error("persist control request", "agent_id", s.agentID, "request_id", requestID, "error", err)
 	}
+	if s.h.observeControlRequest != nil {
+		s.h.observeControlRequest(s.agentID, s.agentProvider, requestID, payload)
+	}
 	return claimToken
 }
 
+func (s *agentOutputSink) DivertControlRequest(requestID string, payload []byte) bool {
+	return s.h.divertControlRequest != nil && s.h.divertControlRequest(s.agentID, requestID, payload)
+}
+
 func (s *agentOutputSink) DeleteControlRequest(requestID string) {
 	_ = s.h.queries.DeleteControlRequest(bgCtx(), db.DeleteControlRequestParams{
 		AgentID:   s.agentID,
@@ -1085,12 +1234,9 @@ func (s *agentOutputSink) NotifyPermissionModeChanged(oldMode, newMode string) {
 			func(v string) string { return optionLabelInGroups(groups, agent.OptionIDPermissionMode, v) },
 			optionGroupLabelInGroups(groups, agent.OptionIDPermissionMode))
 	}
-	s.PersistLeapMuxNotification(map[string]interface{}{
-		"type": agent.NotificationTypeSettingsChanged,
-		"changes": map[string]interface{}{
-			agent.OptionIDPermissionMode: change,
-		},
-	})
+	s.PersistLeapMuxNotification(agent.NotificationContent(agent.SettingsChangedPayload{
+		Changes: map[string]any{agent.OptionIDPermissionMode: change},
+	}))
 }
 
 func (s *agentOutputSink) PersistSettingsRefresh(refresh optionmap.Map) {
@@ -1455,6 +1601,7 @@ func casPersistConfirmedSettings(ctx context.Context, q *db.Queries, agentID, ex
 }
 
 func (s *agentOutputSink) BroadcastStatusActive(sessionID string) {
+	s.h.touchAgent(s.agentID)
 	sc := s.persistCatalogAndBuildStatus(sessionID)
 	if sc == nil {
 		return
@@ -1595,6 +1742,7 @@ func (s *agentOutputSink) BroadcastSessionInfo(info map[string]interface{}) {
 		s.lastSessionInfo = make(map[string][]byte, len(info))
 	}
 	changed := make(map[string]interface{}, len(info))
+	observed := make(map[string][]byte, len(info))
 	for k, v := range info {
 		// thinking_tokens is exempt from dedup -- always ship it and never cache
 		// it, so a re-broadcast after a frontend-side clear is never suppressed.
@@ -1614,12 +1762,31 @@ func (s *agentOutputSink) BroadcastSessionInfo(info map[string]interface{}) {
 		}
 		changed[k] = v
 		s.lastSessionInfo[k] = encoded
+		observed[k] = encoded
 	}
 	s.sessionInfoMu.Unlock()
 	if len(changed) == 0 {
 		return
 	}
 	s.h.broadcastAgentSessionInfo(s.agentID, changed)
+	if len(observed) > 0 && s.h.observeSessionInfo != nil {
+		s.h.observeSessionInfo(s.agentID, s.agentProvider, observed)
+	}
+}
+
+// latestSessionInfo returns the JSON of the last agent_session_info value
+// broadcast under key for agentID's current run. ok is false when the agent
+// has not run since the worker started or has not reported key yet.
+func (h *OutputHandler) latestSessionInfo(agentID, key string) (raw []byte, ok bool) {
+	v, found := h.sinks.Load(agentID)
+	if !found {
+		return nil, false
+	}
+	sink := v.(*agentOutputSink)
+	sink.sessionInfoMu.Lock()
+	defer sink.sessionInfoMu.Unlock()
+	raw, ok = sink.lastSessionInfo[key]
+	return raw, ok
 }
 
 func (s *agentOutputSink) PersistLeapMuxNotification(content map[string]interface{}) {
@@ -1627,15 +1794,11 @@ func (s *agentOutputSink) PersistLeapMuxNotification(content map[string]interfac
 }
 
 func (s *agentOutputSink) StorePlanModeToolUse(toolUseID, targetMode string) {
-	s.h.planModeToolUse.Store(toolUseID, targetMode)
+	s.h.planModes.track(s.agentID, toolUseID, targetMode)
 }
 
 func (s *agentOutputSink) LoadAndDeletePlanModeToolUse(toolUseID string) (string, bool) {
-	v, ok := s.h.planModeToolUse.LoadAndDelete(toolUseID)
-	if !ok {
-		return "", false
-	}
-	return v.(string), true
+	return s.h.planModes.take(toolUseID)
 }
 
 func (s *agentOutputSink) UpdatePlan(content []byte, compression leapmuxv1.ContentCompression, title string) {
@@ -1650,26 +1813,24 @@ func (s *agentOutputSink) CancelAutoContinue(reason agent.AutoContinueReason) {
 	s.h.cancelAutoContinue(s.agentID, reason)
 }
 
-// --- Internal helpers ---
+func (s *agentOutputSink) StartSubAgentRun(toolUseID, parentToolUseID, subAgentType, description string) {
+	s.h.startSubAgentRun(s.agentID, toolUseID, parentToolUseID, subAgentType, description)
+}
 
-// notifMutex returns a per-agent mutex for notification threading.
-func (h *OutputHandler) notifMutex(agentID string) *sync.Mutex {
-	v, _ := h.notifMu.LoadOrStore(agentID, &sync.Mutex{})
-	return v.(*sync.Mutex)
+func (s *agentOutputSink) AddSubAgentUsage(toolUseID string, usage agent.SubAgentUsage) {
+	s.h.addSubAgentUsage(s.agentID, toolUseID, usage)
 }
 
-// clearNotifThread clears the current notification thread boundary so
-// that the next notification starts a new wrapper.
-func (h *OutputHandler) clearNotifThread(agentID string) {
-	if _, ok := h.lastNotifThread.Load(agentID); !ok {
-		return
-	}
-	mu := h.notifMutex(agentID)
-	mu.Lock()
-	defer mu.Unlock()
-	h.lastNotifThread.Delete(agentID)
+func (s *agentOutputSink) EndSubAgentRun(toolUseID, status string) {
+	s.h.endSubAgentRun(s.agentID, toolUseID, status)
+}
+
+func (s *agentOutputSink) PersistUnparsedOutput(line []byte, parseErr error) {
+	s.h.persistUnparsedOutput(s.agentID, line, parseErr)
 }
 
+// --- Internal helpers ---
+
 // createMessageRow persists a chat-message row, refusing invalid boundary values.
 // Every persisted message must carry a real provider so the client can render it
 // through that provider's renderers; an UNSPECIFIED provider is a persistence bug
@@ -1696,6 +1857,7 @@ func (h *OutputHandler) persistAndBroadcast(agentID string, agentProvider leapmu
 	if h.wakeLock != nil {
 		h.wakeLock.RecordActivity()
 	}
+	h.touchAgent(agentID)
 	if tracker == nil {
 		tracker = h.spanTracker(agentID)
 	}
@@ -1713,7 +1875,7 @@ func (h *OutputHandler) persistAndBroadcast(agentID string, agentProvider leapmu
 	compressed, compressionType := msgcodec.Compress(contentJSON)
 	now := nowMillis()
 
-	seq, err := createMessageRow(bgCtx(), h.queries, db.CreateMessageParams{
+	params := db.CreateMessageParams{
 		ID:                 msgID,
 		AgentID:            agentID,
 		Source:             source,
@@ -1728,13 +1890,21 @@ func (h *OutputHandler) persistAndBroadcast(agentID string, agentProvider leapmu
 		AgentProvider:      agentProvider,
 		MarkType:           span.MarkType,
 		CreatedAt:          sqltime.NewSQLiteTime(now),
-	})
+	}
+	seq, err := h.persistMessage(bgCtx(), params)
 	if err != nil {
 		return err
 	}
+	h.classifyMessage(agentID, msgID, contentJSON)
+
+	var usage *leapmuxv1.MessageUsage
+	if span.Usage != nil && !span.Usage.IsZero() {
+		h.recordMessageUsage(agentID, msgID, span.Usage)
+		usage = messageUsageToProto(span.Usage)
+	}
 
 	// Any persisted non-notification message breaks notification adjacency.
-	h.clearNotifThread(agentID)
+	h.notifThreads.clear(agentID)
 
 	h.broadcastMessage(agentID, &leapmuxv1.AgentChatMessage{
 		Id:                 msgID,
@@ -1751,6 +1921,7 @@ func (h *OutputHandler) persistAndBroadcast(agentID string, agentProvider leapmu
 		SpanColor:          spanColor,
 		SpanLines:          spanLines,
 		MarkType:           span.MarkType,
+		Usage:              usage,
 	})
 
 	// Update the provider-neutral to-do list off the just-persisted
@@ -2180,23 +2351,31 @@ func (h *OutputHandler) persistNotificationThreaded(agentID string, agentProvide
 	if h.wakeLock != nil {
 		h.wakeLock.RecordActivity()
 	}
-	mu := h.notifMutex(agentID)
+	h.touchAgent(agentID)
+	mu := h.notifThreads.mutex(agentID)
 	mu.Lock()
 	defer mu.Unlock()
 
-	if ref, ok := h.lastNotifThread.Load(agentID); ok {
-		threadRef := ref.(*notifThreadRef)
-		broadcast, err := h.appendToNotificationThread(agentID, agentProvider, plugin, threadRef, source, contentJSON)
+	if threadRef, ok := h.notifThreads.current(agentID); ok {
+		policy := h.agentNotificationConsolidation(agentID)
+		broadcast, err := h.appendToNotificationThread(agentID, agentProvider, plugin, policy, threadRef, source, contentJSON)
 		if err == nil {
+			h.notifThreads.stats.record(threadOutcomeMerge)
+			if policy.GetGracePeriodSeconds() > 0 {
+				h.notifThreads.stats.record(threadOutcomeGraceRevive)
+			}
 			return broadcast, nil
 		}
-		// errSourceMismatch is the documented fall-through signal — start a
-		// fresh standalone thread silently. Any other error is a real failure
-		// (DB read/write, decompress, marshal); log it but still fall through
-		// so the notification reaches users via a new standalone row.
-		if !errors.Is(err, errSourceMismatch) {
+		// errSourceMismatch and errThreadClosed are the documented
+		// fall-through signals — start a fresh standalone thread silently.
+		// Any other error is a real failure (DB read/write, decompress,
+		// marshal); log it but still fall through so the notification
+		// reaches users via a new standalone row.
+		if !errors.Is(err, errSourceMismatch) && !errors.Is(err, errThreadClosed) {
 			slog.Error("append to notification thread failed; creating standalone", "agent_id", agentID, "error", err)
+			h.notifThreads.stats.record(threadOutcomeMergeFailure)
 		}
+		h.notifThreads.stats.record(threadOutcomeStandaloneFallback)
 	}
 
 	return h.createNotificationStandalone(agentID, agentProvider, source, contentJSON)
@@ -2208,6 +2387,12 @@ func (h *OutputHandler) persistNotificationThreaded(agentID string, agentProvide
 // a fresh standalone thread.
 var errSourceMismatch = errors.New("notification thread source mismatch")
 
+// errThreadClosed is returned by appendToNotificationThread when the
+// workspace's consolidation policy closed the thread (its grace period
+// lapsed or it reached its maximum length). Like errSourceMismatch it is a
+// fall-through signal, not a failure.
+var errThreadClosed = errors.New("notification thread closed by consolidation policy")
+
 // appendToNotificationThread appends a message to an existing notification thread.
 // Returns whether a frontend-visible broadcast was emitted (false when the
 // notification collapses byte-identically into the existing tail), and an error
@@ -2215,14 +2400,23 @@ var errSourceMismatch = errors.New("notification thread source mismatch")
 // cross-source notifications must produce separate threads so that the persisted
 // source remains a truthful per-thread provenance signal. The caller treats the
 // error as a normal "fall through to a new standalone thread" signal, not as a
-// failure.
-func (h *OutputHandler) appendToNotificationThread(agentID string, agentProvider leapmuxv1.AgentProvider, plugin agent.Provider, threadRef *notifThreadRef, source leapmuxv1.MessageSource, contentJSON []byte) (bool, error) {
+// failure. policy (nil for the built-in behavior) is the workspace's
+// consolidation policy.
+func (h *OutputHandler) appendToNotificationThread(agentID string, agentProvider leapmuxv1.AgentProvider, plugin agent.Provider, policy *leapmuxv1.NotificationConsolidationPolicy, threadRef *notifThreadRef, source leapmuxv1.MessageSource, contentJSON []byte) (bool, error) {
 	// Short-circuit cross-source flips before the DB hit — the in-memory
 	// threadRef carries the source that was persisted when the thread
 	// opened, so we don't need to fetch + decompress the row to learn it.
 	if threadRef.source != source {
 		return false, errSourceMismatch
 	}
+	// A thread grows by rewriting its row, which an immutable transcript
+	// forbids: every notification gets a row of its own.
+	if h.immutableTranscripts != nil && h.immutableTranscripts() {
+		return false, errThreadClosed
+	}
+	if grace := time.Duration(policy.GetGracePeriodSeconds()) * time.Second; grace > 0 && time.Since(threadRef.lastAt) > grace {
+		return false, errThreadClosed
+	}
 
 	parentRow, err := h.queries.GetMessageByAgentAndID(bgCtx(), db.GetMessageByAgentAndIDParams{
 		ID:      threadRef.msgID,
@@ -2241,6 +2435,9 @@ func (h *OutputHandler) appendToNotificationThread(agentID string, agentProvider
 	if err != nil {
 		return false, err
 	}
+	if limit := int(policy.GetMaxThreadLength()); limit > 0 && len(wrapper.Messages) >= limit {
+		return false, errThreadClosed
+	}
 
 	// If a flapping ProviderScoped notification (e.g.
 	// remoteControl/status/changed) collapses into the existing tail and
@@ -2249,7 +2446,7 @@ func (h *OutputHandler) appendToNotificationThread(agentID string, agentProvider
 	// not reset the thinking-token estimate for this collapsed notification.
 	oldMessages := wrapper.Messages
 	nextMessages := append(slices.Clone(oldMessages), contentJSON)
-	nextMessages = consolidateNotificationThread(nextMessages, plugin)
+	nextMessages = consolidateNotificationThread(nextMessages, plugin, policy)
 	if rawMessageSlicesEqual(oldMessages, nextMessages) {
 		return false, nil
 	}
@@ -2285,7 +2482,8 @@ func (h *OutputHandler) appendToNotificationThread(agentID string, agentProvider
 	}
 
 	threadRef.seq = newSeq
-	h.lastNotifThread.Store(agentID, threadRef)
+	threadRef.lastAt = time.Now()
+	h.notifThreads.set(agentID, threadRef)
 
 	h.broadcastMessage(agentID, &leapmuxv1.AgentChatMessage{
 		Id:                 parentRow.ID,
@@ -2325,7 +2523,7 @@ func (h *OutputHandler) createNotificationStandalone(agentID string, agentProvid
 	// passthrough vertical bars instead of breaking the column.
 	spanLines := h.snapshotPassthroughSpanLines(agentID)
 
-	seq, err := createMessageRow(bgCtx(), h.queries, db.CreateMessageParams{
+	params := db.CreateMessageParams{
 		ID:                 msgID,
 		AgentID:            agentID,
 		Source:             source,
@@ -2338,15 +2536,17 @@ func (h *OutputHandler) createNotificationStandalone(agentID string, agentProvid
 		SpanColor:          0,
 		AgentProvider:      agentProvider,
 		CreatedAt:          sqltime.NewSQLiteTime(now),
-	})
+	}
+	seq, err := h.persistMessage(bgCtx(), params)
 	if err != nil {
 		return false, err
 	}
 
-	h.lastNotifThread.Store(agentID, &notifThreadRef{
+	h.notifThreads.set(agentID, &notifThreadRef{
 		msgID:  msgID,
 		seq:    seq,
 		source: source,
+		lastAt: now,
 	})
 
 	h.broadcastMessage(agentID, &leapmuxv1.AgentChatMessage{
@@ -2523,15 +2723,11 @@ func (h *OutputHandler) updatePlan(agentID string, compressed []byte, compressio
 		return
 	}
 
-	payload := map[string]interface{}{
-		"type":           agent.NotificationTypePlanUpdated,
-		"plan_title":     title,
-		"plan_file_path": canonicalPath,
-	}
-	if shouldAutoRename {
-		payload["update_agent_title"] = true
-	}
-	h.PersistLeapMuxNotification(agentID, agentRow.AgentProvider, payload)
+	h.PersistLeapMuxNotification(agentID, agentRow.AgentProvider, agent.NotificationContent(agent.PlanUpdatedPayload{
+		PlanTitle:        title,
+		PlanFilePath:     canonicalPath,
+		UpdateAgentTitle: shouldAutoRename,
+	}))
 }
 
 // indexedRaw bundles a message's original index, raw bytes, and (optional)
@@ -2555,8 +2751,9 @@ func rawMessageSlicesEqual(a, b []json.RawMessage) bool {
 // consolidateNotificationThread consolidates a notification thread's messages.
 // Service-owned LeapMux notification types are merged centrally, while
 // provider-owned raw payloads are classified through the injected plugin.
-// Ordering is preserved by the last occurrence index of each retained entry.
-func consolidateNotificationThread(messages []json.RawMessage, plugin agent.Provider) []json.RawMessage {
+// Types the workspace policy lists as unmerged are kept verbatim. Ordering
+// is preserved by the last occurrence index of each retained entry.
+func consolidateNotificationThread(messages []json.RawMessage, plugin agent.Provider, policy *leapmuxv1.NotificationConsolidationPolicy) []json.RawMessage {
 	if plugin == nil {
 		plugin = agent.ProviderFor(leapmuxv1.AgentProvider_AGENT_PROVIDER_UNSPECIFIED)
 	}
@@ -2586,6 +2783,7 @@ func consolidateNotificationThread(messages []json.RawMessage, plugin agent.Prov
 	planUpdated := indexedRaw{idx: -1}
 	status := indexedRaw{idx: -1}
 	apiRetry := indexedRaw{idx: -1}
+	retry := indexedRaw{idx: -1}
 
 	mergedChanges := map[string]settingsChange{}
 
@@ -2602,6 +2800,11 @@ func consolidateNotificationThread(messages []json.RawMessage, plugin agent.Prov
 			continue
 		}
 
+		if slices.Contains(policy.GetUnmergedTypes(), env.Type) {
+			keepAll = append(keepAll, indexedRaw{idx: i, raw: raw})
+			continue
+		}
+
 		switch env.Type {
 		case agent.NotificationTypeSettingsChanged:
 			for key, val := range env.Changes {
@@ -2633,6 +2836,11 @@ func consolidateNotificationThread(messages []json.RawMessage, plugin agent.Prov
 		case agent.NotificationTypeInterrupted:
 			interrupted = indexedRaw{idx: i, raw: raw}
 
+		case agent.NotificationTypeRetryScheduled, agent.NotificationTypeRetryExhausted:
+			// Only the latest retry state matters: "attempt 3 of 5" supersedes
+			// "attempt 2 of 5", and "gave up" supersedes both.
+			retry = indexedRaw{idx: i, raw: raw}
+
 		case agent.NotificationTypeRateLimit:
 			key := "unknown"
 			if env.RLInfo != nil && env.RLInfo.RateLimitType != "" {
@@ -2696,7 +2904,7 @@ func consolidateNotificationThread(messages []json.RawMessage, plugin agent.Prov
 		}
 	}
 
-	for _, slot := range []indexedRaw{contextCleared, planExec, planUpdated, interrupted, status, apiRetry} {
+	for _, slot := range []indexedRaw{contextCleared, planExec, planUpdated, interrupted, status, apiRetry, retry} {
 		if slot.idx >= 0 {
 			entries = append(entries, slot)
 		}
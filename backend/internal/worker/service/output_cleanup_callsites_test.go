@@ -93,7 +93,7 @@ func TestInitiatePlanExecutionRestart_ClearsPendingControlRequests(t *testing.T)
 	dbAgent, err := svc.Queries.GetAgentByID(ctx, "agent-plan")
 	require.NoError(t, err)
 
-	svc.initiatePlanExecutionRestart("agent-plan", agent.PermissionModeDefault, dbAgent, "Execute the plan.")
+	svc.initiatePlanExecutionRestart("agent-plan", agent.PermissionModeDefault, dbAgent, dbAgent.PlanFilePath)
 
 	assertControlRequestsCleared(t, ctx, svc, w, "agent-plan", requestID)
 }
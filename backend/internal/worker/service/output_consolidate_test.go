@@ -77,7 +77,7 @@ func codexMethod(method string, params map[string]interface{}) map[string]interf
 }
 
 func consolidateForProvider(provider leapmuxv1.AgentProvider, msgs []json.RawMessage) []json.RawMessage {
-	return consolidateNotificationThread(msgs, agent.ProviderFor(provider))
+	return consolidateNotificationThread(msgs, agent.ProviderFor(provider), nil)
 }
 
 func TestConsolidateNotificationThread_OrderPreserved(t *testing.T) {
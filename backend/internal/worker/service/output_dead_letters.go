@@ -0,0 +1,142 @@
+package service
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"log/slog"
+
+	leapmuxv1 "github.com/leapmux/leapmux/generated/proto/leapmux/v1"
+	"github.com/leapmux/leapmux/internal/util/timefmt"
+	"github.com/leapmux/leapmux/internal/util/userid"
+	"github.com/leapmux/leapmux/internal/worker/channel"
+	db "github.com/leapmux/leapmux/internal/worker/generated/db"
+)
+
+const (
+	// maxAgentDeadLetters is how many unparsed output lines each agent
+	// keeps; older ones are dropped as new ones arrive, so an agent stuck
+	// printing garbage cannot fill the disk.
+	maxAgentDeadLetters = 1000
+	// defaultDeadLetterPage and maxDeadLetterPage bound a list page.
+	defaultDeadLetterPage = 100
+	maxDeadLetterPage     = 500
+)
+
+// persistUnparsedOutput keeps a stdout line that did not parse. Like the
+// sub-agent bookkeeping, a failed write is logged rather than surfaced:
+// the line was already lost to the transcript.
+func (h *OutputHandler) persistUnparsedOutput(agentID string, line []byte, parseErr error) {
+	if err := h.queries.CreateAgentOutputDeadLetter(bgCtx(), db.CreateAgentOutputDeadLetterParams{
+		AgentID: agentID,
+		Content: line,
+		Error:   parseErr.Error(),
+	}); err != nil {
+		slog.Warn("failed to keep unparsed agent output", "agent_id", agentID, "error", err)
+		return
+	}
+	if err := h.queries.PruneAgentOutputDeadLetters(bgCtx(), db.PruneAgentOutputDeadLettersParams{
+		AgentID: agentID,
+		Keep:    maxAgentDeadLetters,
+	}); err != nil {
+		slog.Warn("failed to prune unparsed agent output", "agent_id", agentID, "error", err)
+	}
+}
+
+func deadLetterToProto(row db.AgentOutputDeadLetter) *leapmuxv1.AgentOutputDeadLetter {
+	letter := &leapmuxv1.AgentOutputDeadLetter{
+		Id:        row.ID,
+		AgentId:   row.AgentID,
+		Content:   row.Content,
+		Error:     row.Error,
+		CreatedAt: timefmt.Format(row.CreatedAt.Time),
+	}
+	if row.ReplayedAt.Valid {
+		letter.ReplayedAt = timefmt.Format(row.ReplayedAt.Time)
+	}
+	return letter
+}
+
+// replayDeadLetter feeds one dead letter to its agent and marks it
+// replayed. The returned error says why it was left alone.
+func (svc *Service) replayDeadLetter(ctx context.Context, id int64) error {
+	row, err := svc.Queries.GetAgentOutputDeadLetter(ctx, id)
+	if errors.Is(err, sql.ErrNoRows) {
+		return errors.New("not found")
+	} else if err != nil {
+		slog.Error("failed to look up dead letter", "id", id, "error", err)
+		return errors.New("failed to look up")
+	}
+	if row.ReplayedAt.Valid {
+		return errors.New("already replayed")
+	}
+	if !json.Valid(row.Content) {
+		return errors.New("still not valid JSON")
+	}
+	if err := svc.Agents.HandleOutput(row.AgentID, row.Content); err != nil {
+		return errors.New("agent is not running")
+	}
+	if err := svc.Queries.MarkAgentOutputDeadLetterReplayed(ctx, id); err != nil {
+		// The line was handled; only the record of it failed.
+		slog.Error("failed to mark dead letter replayed", "id", id, "error", err)
+	}
+	return nil
+}
+
+func registerOutputDeadLetterHandlers(d ownerOnlyRegistrar, svc *Service) {
+	d.Register("ListAgentOutputDeadLetters", func(ctx context.Context, _ userid.UserID, req *leapmuxv1.InnerRpcRequest, sender channel.ResponseWriter) {
+		var r leapmuxv1.ListAgentOutputDeadLettersRequest
+		if err := unmarshalRequest(req, &r); err != nil {
+			sendInvalidArgument(sender, "invalid request")
+			return
+		}
+		limit := int64(r.GetLimit())
+		switch {
+		case limit < 0:
+			sendInvalidArgument(sender, "limit must not be negative")
+			return
+		case limit == 0:
+			limit = defaultDeadLetterPage
+		case limit > maxDeadLetterPage:
+			limit = maxDeadLetterPage
+		}
+		rows, err := svc.Queries.ListAgentOutputDeadLetters(ctx, db.ListAgentOutputDeadLettersParams{
+			AgentID:         r.GetAgentId(),
+			IncludeReplayed: r.GetIncludeReplayed(),
+			AfterID:         r.GetAfterId(),
+			RowLimit:        limit,
+		})
+		if err != nil {
+			slog.Error("failed to list dead letters", "error", err)
+			sendInternalError(sender, "failed to list dead letters")
+			return
+		}
+		letters := make([]*leapmuxv1.AgentOutputDeadLetter, len(rows))
+		for i, row := range rows {
+			letters[i] = deadLetterToProto(row)
+		}
+		sendProtoResponse(sender, &leapmuxv1.ListAgentOutputDeadLettersResponse{DeadLetters: letters})
+	})
+
+	d.Register("ReplayAgentOutputDeadLetters", func(ctx context.Context, _ userid.UserID, req *leapmuxv1.InnerRpcRequest, sender channel.ResponseWriter) {
+		var r leapmuxv1.ReplayAgentOutputDeadLettersRequest
+		if err := unmarshalRequest(req, &r); err != nil || len(r.GetIds()) == 0 {
+			sendInvalidArgument(sender, "ids is required")
+			return
+		}
+		if len(r.GetIds()) > maxDeadLetterPage {
+			sendInvalidArgument(sender, "too many ids")
+			return
+		}
+		resp := &leapmuxv1.ReplayAgentOutputDeadLettersResponse{}
+		for _, id := range r.GetIds() {
+			if err := svc.replayDeadLetter(ctx, id); err != nil {
+				resp.Failures = append(resp.Failures, &leapmuxv1.DeadLetterReplayFailure{Id: id, Error: err.Error()})
+				continue
+			}
+			resp.ReplayedIds = append(resp.ReplayedIds, id)
+		}
+		sendProtoResponse(sender, resp)
+	})
+}
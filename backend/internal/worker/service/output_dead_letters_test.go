@@ -0,0 +1,108 @@
+package service
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/protobuf/proto"
+
+	leapmuxv1 "github.com/leapmux/leapmux/generated/proto/leapmux/v1"
+	"github.com/leapmux/leapmux/internal/worker/agent"
+	"github.com/leapmux/leapmux/internal/worker/channel"
+	db "github.com/leapmux/leapmux/internal/worker/generated/db"
+)
+
+func listDeadLetters(t *testing.T, d *channel.Dispatcher, r *leapmuxv1.ListAgentOutputDeadLettersRequest) []*leapmuxv1.AgentOutputDeadLetter {
+	t.Helper()
+	w := newTestWriter()
+	dispatch(d, "ListAgentOutputDeadLetters", r, w)
+	require.Empty(t, w.errors)
+	require.Len(t, w.responses, 1)
+	var resp leapmuxv1.ListAgentOutputDeadLettersResponse
+	require.NoError(t, proto.Unmarshal(w.responses[0].GetPayload(), &resp))
+	return resp.GetDeadLetters()
+}
+
+func TestOutputDeadLetters_KeepAndReplay(t *testing.T) {
+	svc, d, w := setupTestService(t, withWorkspaces("ws-1"))
+	row := seedGuardedAgent(t, svc, agent.PermissionModeDefault)
+	ctx := context.Background()
+
+	// The mock agent echoes stdin, so a truncated line comes back out of
+	// its stdout as output that does not parse.
+	_, err := svc.Agents.MockStartAgent(ctx, agent.Options{AgentID: row.ID, WorkingDir: row.WorkingDir},
+		svc.Output.NewSink(row.ID, row.AgentProvider))
+	require.NoError(t, err)
+	t.Cleanup(func() { svc.Agents.StopAgent(row.ID) })
+	truncated := `{"type":"assistant","message":{"role":"assistant","content":[{"type":"text","text":"hi"}]}`
+	require.NoError(t, svc.Agents.SendRawInput(row.ID, []byte(truncated+"\n")))
+
+	var letters []*leapmuxv1.AgentOutputDeadLetter
+	require.Eventually(t, func() bool {
+		letters = listDeadLetters(t, d, &leapmuxv1.ListAgentOutputDeadLettersRequest{AgentId: row.ID})
+		return len(letters) == 1
+	}, 5*time.Second, 10*time.Millisecond)
+	assert.Equal(t, truncated, string(letters[0].GetContent()))
+	assert.NotEmpty(t, letters[0].GetError())
+	assert.Empty(t, letters[0].GetReplayedAt())
+
+	// As though a parser fix had recovered the line.
+	require.NoError(t, svc.Queries.CreateAgentOutputDeadLetter(ctx, db.CreateAgentOutputDeadLetterParams{
+		AgentID: row.ID,
+		Content: []byte(truncated + "}"),
+		Error:   "unexpected end of JSON input",
+	}))
+	letters = listDeadLetters(t, d, &leapmuxv1.ListAgentOutputDeadLettersRequest{AgentId: row.ID})
+	require.Len(t, letters, 2)
+	fixed := letters[1].GetId()
+
+	dispatch(d, "ReplayAgentOutputDeadLetters", &leapmuxv1.ReplayAgentOutputDeadLettersRequest{
+		Ids: []int64{fixed, letters[0].GetId(), 999},
+	}, w)
+	require.Empty(t, w.errors)
+	require.Len(t, w.responses, 1)
+	var resp leapmuxv1.ReplayAgentOutputDeadLettersResponse
+	require.NoError(t, proto.Unmarshal(w.responses[0].GetPayload(), &resp))
+	assert.Equal(t, []int64{fixed}, resp.GetReplayedIds())
+	require.Len(t, resp.GetFailures(), 2)
+	assert.Equal(t, "still not valid JSON", resp.GetFailures()[0].GetError())
+	assert.Equal(t, "not found", resp.GetFailures()[1].GetError())
+
+	msgs, err := svc.Queries.ListMessagesByAgentID(ctx, db.ListMessagesByAgentIDParams{AgentID: row.ID, Limit: 10})
+	require.NoError(t, err)
+	require.Len(t, msgs, 1, "the replayed line is persisted like live output")
+	assert.Equal(t, leapmuxv1.MessageSource_MESSAGE_SOURCE_AGENT, msgs[0].Source)
+
+	assert.Len(t, listDeadLetters(t, d, &leapmuxv1.ListAgentOutputDeadLettersRequest{AgentId: row.ID}), 1,
+		"replayed lines are hidden by default")
+	all := listDeadLetters(t, d, &leapmuxv1.ListAgentOutputDeadLettersRequest{IncludeReplayed: true})
+	require.Len(t, all, 2)
+	assert.NotEmpty(t, all[1].GetReplayedAt())
+
+	w = newTestWriter()
+	dispatch(d, "ReplayAgentOutputDeadLetters", &leapmuxv1.ReplayAgentOutputDeadLettersRequest{Ids: []int64{fixed}}, w)
+	require.Len(t, w.responses, 1)
+	require.NoError(t, proto.Unmarshal(w.responses[0].GetPayload(), &resp))
+	assert.Empty(t, resp.GetReplayedIds())
+	assert.Equal(t, "already replayed", resp.GetFailures()[0].GetError())
+}
+
+func TestOutputDeadLetters_Pruned(t *testing.T) {
+	svc, _, _ := setupTestService(t, withWorkspaces("ws-1"))
+	row := seedGuardedAgent(t, svc, agent.PermissionModeDefault)
+
+	sink := svc.Output.NewSink(row.ID, row.AgentProvider)
+	for range maxAgentDeadLetters + 5 {
+		sink.PersistUnparsedOutput([]byte("garbage"), assert.AnError)
+	}
+	rows, err := svc.Queries.ListAgentOutputDeadLetters(context.Background(), db.ListAgentOutputDeadLettersParams{
+		AgentID:  row.ID,
+		RowLimit: 2 * maxAgentDeadLetters,
+	})
+	require.NoError(t, err)
+	assert.Len(t, rows, maxAgentDeadLetters)
+	assert.Equal(t, int64(6), rows[0].ID, "the oldest lines go first")
+}
@@ -0,0 +1,12 @@
+package service
+
+import "github.com/leapmux/leapmux/internal/worker/agent"
+
+// AddOutputProcessor appends p to the chain every agent's output passes
+// through before its provider handles it, so an extension can act on
+// agent output without a case in each provider's dispatch. Processors
+// run in the order they were added. Call before any agent starts: agents
+// launched earlier keep the chain they started with.
+func (svc *Service) AddOutputProcessor(p agent.OutputProcessor) {
+	svc.outputProcessors = append(svc.outputProcessors, p)
+}
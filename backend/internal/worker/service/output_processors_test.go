@@ -0,0 +1,20 @@
+package service
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	leapmuxv1 "github.com/leapmux/leapmux/generated/proto/leapmux/v1"
+	"github.com/leapmux/leapmux/internal/worker/agent"
+)
+
+func TestAddOutputProcessor_ReachesAgentOptions(t *testing.T) {
+	svc, _, _ := setupTestService(t)
+	assert.Empty(t, svc.baseAgentOptions("agent-1", "/tmp", leapmuxv1.AgentProvider_AGENT_PROVIDER_CLAUDE_CODE).OutputProcessors)
+
+	noop := agent.OutputProcessorFunc(func(*agent.OutputLine, agent.OutputSink) bool { return false })
+	svc.AddOutputProcessor(noop)
+	svc.AddOutputProcessor(noop)
+	assert.Len(t, svc.baseAgentOptions("agent-1", "/tmp", leapmuxv1.AgentProvider_AGENT_PROVIDER_CLAUDE_CODE).OutputProcessors, 2)
+}
@@ -134,7 +134,7 @@ func TestNotificationThreading_NonNotificationBreaksAdjacency(t *testing.T) {
 // dying process's pending control_requests WITHOUT clearing the in-memory notification
 // thread -- otherwise the notification persisted after the relaunch lands in a fresh
 // thread and can't consolidate with (cancel) the one before it. The bug was onExit
-// calling the full ClearAgentRuntimeState (which clears lastNotifThread via
+// calling the full ClearAgentRuntimeState (which clears the notification thread via
 // CleanupAgent); the fix routes onExit through ClearPendingControlRequests instead.
 func TestRelaunchOnExitPreservesNotificationThread(t *testing.T) {
 	ctx := context.Background()
@@ -0,0 +1,169 @@
+package service
+
+import (
+	"fmt"
+	"log/slog"
+	"slices"
+
+	leapmuxv1 "github.com/leapmux/leapmux/generated/proto/leapmux/v1"
+	"github.com/leapmux/leapmux/internal/policy"
+	"github.com/leapmux/leapmux/internal/worker/agent"
+	db "github.com/leapmux/leapmux/internal/worker/generated/db"
+)
+
+// PermissionGuardrails constrains the permission modes agents on this worker
+// may use. The worker's own are set from its flags; the org's arrive with
+// its defaults and are layered on top (see permissionGuardrails). Modes are provider-native strings (e.g. Claude Code's
+// "bypassPermissions") compared verbatim, so a guardrail naming one
+// provider's mode leaves the others alone.
+type PermissionGuardrails struct {
+	// Forbidden lists modes no agent may start in or switch to.
+	Forbidden []string
+	// Default is the mode new agents start in when the client requests none.
+	// Empty keeps the provider's own default.
+	Default string
+}
+
+// permissionGuardrails returns the guardrails in force: this worker's own,
+// with the org's forbidden modes added and the org's default in place of
+// the worker's.
+func (svc *Service) permissionGuardrails() PermissionGuardrails {
+	g := svc.PermissionGuardrails
+	org := svc.orgDefaults.Load()
+	if forbidden := org.GetForbiddenPermissionModes(); len(forbidden) > 0 {
+		g.Forbidden = append(slices.Clip(g.Forbidden), forbidden...)
+	}
+	if d := org.GetDefaultPermissionMode(); d != "" {
+		g.Default = d
+	}
+	return g
+}
+
+// forbids reports whether mode is on the forbidden list.
+func (g PermissionGuardrails) forbids(mode string) bool {
+	return mode != "" && slices.Contains(g.Forbidden, mode)
+}
+
+// defaultMode returns the mode a new agent of provider starts in: the
+// configured Default when the provider accepts it and it is not forbidden,
+// else the provider's own. A provider with no permission-mode axis gets "".
+func (g PermissionGuardrails) defaultMode(provider leapmuxv1.AgentProvider) string {
+	providerDefault := agent.PermissionModeOrDefault(provider, "")
+	if providerDefault == "" || g.Default == "" || g.forbids(g.Default) {
+		return providerDefault
+	}
+	if agent.ValidateLaunchOptions(provider, OptionMap{agent.OptionIDPermissionMode: g.Default}) != nil {
+		return providerDefault
+	}
+	return g.Default
+}
+
+// permit returns mode when it is allowed, else the mode to apply in its
+// place (the guardrail default, or the provider's).
+func (g PermissionGuardrails) permit(provider leapmuxv1.AgentProvider, mode string) string {
+	if !g.forbids(mode) {
+		return mode
+	}
+	return g.defaultMode(provider)
+}
+
+// checkLaunchPermissionMode rejects a spawn that explicitly requests a
+// forbidden mode. There is no agent yet to notify, so the caller fails the
+// RPC instead.
+func (g PermissionGuardrails) checkLaunchPermissionMode(requested OptionMap) error {
+	if mode := requested[agent.OptionIDPermissionMode]; g.forbids(mode) {
+		return fmt.Errorf("permission mode %q is not allowed on this worker", mode)
+	}
+	return nil
+}
+
+// guardPermissionMode returns the mode dbAgent may actually switch to in
+// place of mode, telling the user when the guardrails or the org's access
+// policy substituted one.
+func (svc *Service) guardPermissionMode(dbAgent db.Agent, mode string) string {
+	if substitute, d := svc.policyPermitPermissionMode(dbAgent, mode); d.Denied() {
+		svc.reportPolicyBlockedPermissionMode(dbAgent, mode, substitute, d)
+		return substitute
+	}
+	permitted := svc.permissionGuardrails().permit(dbAgent.AgentProvider, mode)
+	if permitted != mode {
+		svc.reportBlockedPermissionMode(dbAgent, mode, permitted)
+	}
+	return permitted
+}
+
+// policyPermitPermissionMode returns mode when the org's access policy
+// lets dbAgent switch to it, else the agent's current mode, or the
+// guardrails' default when the agent has none, along with the decision.
+// A mode the agent is already in is never refused, so a policy written
+// after the fact does not trap an agent.
+func (svc *Service) policyPermitPermissionMode(dbAgent db.Agent, mode string) (string, policy.Decision) {
+	current := loadOptions(dbAgent.Options, dbAgent.AgentProvider)[agent.OptionIDPermissionMode]
+	if mode == current {
+		return mode, policy.Decision{}
+	}
+	d := svc.permissionModeDecision(dbAgent, mode)
+	if !d.Denied() {
+		return mode, d
+	}
+	if current == "" {
+		current = svc.permissionGuardrails().defaultMode(dbAgent.AgentProvider)
+	}
+	return current, d
+}
+
+// refusePermissionMode reports whether dbAgent may not switch to mode,
+// telling the user when it may not. The agent keeps its current mode.
+func (svc *Service) refusePermissionMode(dbAgent db.Agent, mode string) bool {
+	if svc.permissionGuardrails().forbids(mode) {
+		svc.reportBlockedPermissionMode(dbAgent, mode, "")
+		return true
+	}
+	if _, d := svc.policyPermitPermissionMode(dbAgent, mode); d.Denied() {
+		svc.reportPolicyBlockedPermissionMode(dbAgent, mode, "", d)
+		return true
+	}
+	return false
+}
+
+// dropForbiddenPermissionMode returns incoming without a permission mode
+// the guardrails or the org's access policy refuse, so a settings edit
+// keeps the agent's current mode while its other axes still apply.
+func (svc *Service) dropForbiddenPermissionMode(dbAgent db.Agent, incoming map[string]string) map[string]string {
+	mode := incoming[agent.OptionIDPermissionMode]
+	if mode == "" || !svc.refusePermissionMode(dbAgent, mode) {
+		return incoming
+	}
+	out := make(map[string]string, len(incoming))
+	for k, v := range incoming {
+		if k != agent.OptionIDPermissionMode {
+			out[k] = v
+		}
+	}
+	return out
+}
+
+// reportBlockedPermissionMode persists a permission_mode_blocked
+// notification. applied is the mode used instead, or "" when the agent
+// kept its current one.
+func (svc *Service) reportBlockedPermissionMode(dbAgent db.Agent, mode, applied string) {
+	slog.Warn("permission mode blocked by guardrails",
+		"agent_id", dbAgent.ID, "mode", mode, "applied", applied)
+	svc.Output.PersistLeapMuxNotification(dbAgent.ID, dbAgent.AgentProvider, agent.NotificationContent(agent.PermissionModeBlockedPayload{
+		Mode:    mode,
+		Applied: applied,
+	}))
+}
+
+// reportPolicyBlockedPermissionMode is reportBlockedPermissionMode for a
+// mode the org's access policy refused.
+func (svc *Service) reportPolicyBlockedPermissionMode(dbAgent db.Agent, mode, applied string, d policy.Decision) {
+	slog.Warn("permission mode denied by access policy",
+		"agent_id", dbAgent.ID, "mode", mode, "applied", applied, "rule", d.Rule)
+	svc.Output.PersistLeapMuxNotification(dbAgent.ID, dbAgent.AgentProvider, agent.NotificationContent(agent.PermissionModeBlockedPayload{
+		Mode:    mode,
+		Applied: applied,
+		Rule:    d.Rule,
+		Message: d.Message,
+	}))
+}
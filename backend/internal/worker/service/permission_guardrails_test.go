@@ -0,0 +1,150 @@
+package service
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	leapmuxv1 "github.com/leapmux/leapmux/generated/proto/leapmux/v1"
+	"github.com/leapmux/leapmux/internal/worker/agent"
+	db "github.com/leapmux/leapmux/internal/worker/generated/db"
+)
+
+const claudeProvider = leapmuxv1.AgentProvider_AGENT_PROVIDER_CLAUDE_CODE
+
+func seedGuardedAgent(t *testing.T, svc *Service, mode string) db.Agent {
+	t.Helper()
+	ctx := context.Background()
+	require.NoError(t, svc.Queries.CreateAgent(ctx, db.CreateAgentParams{
+		ID:            "agent-1",
+		WorkspaceID:   "ws-1",
+		WorkingDir:    t.TempDir(),
+		HomeDir:       t.TempDir(),
+		AgentProvider: claudeProvider,
+		Options:       marshalOptions(map[string]string{agent.OptionIDModel: "opus", agent.OptionIDPermissionMode: mode}),
+	}))
+	row, err := svc.Queries.GetAgentByID(ctx, "agent-1")
+	require.NoError(t, err)
+	return row
+}
+
+func storedPermissionMode(t *testing.T, svc *Service) string {
+	t.Helper()
+	row, err := svc.Queries.GetAgentByID(context.Background(), "agent-1")
+	require.NoError(t, err)
+	return parseOptions(row.Options)[agent.OptionIDPermissionMode]
+}
+
+func TestPermissionGuardrails_DefaultMode(t *testing.T) {
+	providerDefault := agent.PermissionModeOrDefault(claudeProvider, "")
+
+	assert.Equal(t, providerDefault, PermissionGuardrails{}.defaultMode(claudeProvider))
+	assert.Equal(t, agent.PermissionModePlan, PermissionGuardrails{Default: agent.PermissionModePlan}.defaultMode(claudeProvider))
+	assert.Equal(t, providerDefault, PermissionGuardrails{Default: "no-such-mode"}.defaultMode(claudeProvider),
+		"a default the provider rejects falls back to the provider's own")
+}
+
+func TestPermissionGuardrails_Permit(t *testing.T) {
+	g := PermissionGuardrails{Forbidden: []string{"bypassPermissions"}, Default: agent.PermissionModePlan}
+	assert.Equal(t, agent.PermissionModeAcceptEdits, g.permit(claudeProvider, agent.PermissionModeAcceptEdits))
+	assert.Equal(t, agent.PermissionModePlan, g.permit(claudeProvider, "bypassPermissions"))
+	assert.Error(t, g.checkLaunchPermissionMode(OptionMap{agent.OptionIDPermissionMode: "bypassPermissions"}))
+	assert.NoError(t, g.checkLaunchPermissionMode(OptionMap{}))
+}
+
+func TestSetAgentPermissionMode_SubstitutesForbiddenMode(t *testing.T) {
+	svc, _, _ := setupTestService(t, withWorkspaces("ws-1"))
+	svc.PermissionGuardrails = PermissionGuardrails{Forbidden: []string{"bypassPermissions"}, Default: agent.PermissionModePlan}
+	dbAgent := seedGuardedAgent(t, svc, agent.PermissionModeDefault)
+
+	svc.setAgentPermissionModeWithAgent(dbAgent, "bypassPermissions")
+
+	assert.Equal(t, agent.PermissionModePlan, storedPermissionMode(t, svc))
+	blocked := findNotificationsByType(readAllNotifications(t, svc.Queries, "agent-1"), agent.NotificationTypePermissionModeBlocked)
+	require.Len(t, blocked, 1)
+	assert.Equal(t, "bypassPermissions", blocked[0]["mode"])
+	assert.Equal(t, agent.PermissionModePlan, blocked[0]["applied"])
+}
+
+func TestSendAgentRawMessage_ForbiddenPermissionModeNotApplied(t *testing.T) {
+	svc, d, w := setupTestService(t, withWorkspaces("ws-1"))
+	svc.PermissionGuardrails = PermissionGuardrails{Forbidden: []string{"bypassPermissions"}}
+	seedGuardedAgent(t, svc, agent.PermissionModeDefault)
+
+	dispatch(d, "SendAgentRawMessage", &leapmuxv1.SendAgentRawMessageRequest{
+		AgentId: "agent-1",
+		Content: `{"type":"control_request","request_id":"r1","request":{"subtype":"set_permission_mode","mode":"bypassPermissions"}}`,
+	}, w)
+
+	require.Empty(t, w.errors)
+	assert.Equal(t, agent.PermissionModeDefault, storedPermissionMode(t, svc))
+	blocked := findNotificationsByType(readAllNotifications(t, svc.Queries, "agent-1"), agent.NotificationTypePermissionModeBlocked)
+	require.Len(t, blocked, 1)
+	assert.NotContains(t, blocked[0], "applied")
+}
+
+func TestUpdateAgentSettings_DropsForbiddenPermissionMode(t *testing.T) {
+	svc, d, w := setupTestService(t, withWorkspaces("ws-1"))
+	svc.PermissionGuardrails = PermissionGuardrails{Forbidden: []string{"bypassPermissions"}}
+	seedGuardedAgent(t, svc, agent.PermissionModeDefault)
+
+	dispatch(d, "UpdateAgentSettings", &leapmuxv1.UpdateAgentSettingsRequest{
+		AgentId: "agent-1",
+		Settings: &leapmuxv1.AgentSettings{Options: map[string]string{
+			agent.OptionIDModel:          "sonnet",
+			agent.OptionIDPermissionMode: "bypassPermissions",
+		}},
+	}, w)
+
+	require.Empty(t, w.errors)
+	row, err := svc.Queries.GetAgentByID(context.Background(), "agent-1")
+	require.NoError(t, err)
+	opts := parseOptions(row.Options)
+	assert.Equal(t, "sonnet", opts[agent.OptionIDModel], "the allowed axes still apply")
+	assert.Equal(t, agent.PermissionModeDefault, opts[agent.OptionIDPermissionMode])
+}
+
+func TestOpenAgent_RejectsForbiddenPermissionMode(t *testing.T) {
+	svc, d, w := setupTestService(t, withWorkspaces("ws-1"))
+	defer drainAllInFlight(svc)
+	svc.PermissionGuardrails = PermissionGuardrails{Forbidden: []string{"bypassPermissions"}}
+
+	dispatch(d, "OpenAgent", &leapmuxv1.OpenAgentRequest{
+		WorkspaceId: "ws-1",
+		WorkingDir:  t.TempDir(),
+		Options:     map[string]string{agent.OptionIDPermissionMode: "bypassPermissions"},
+	}, w)
+
+	require.Len(t, w.errors, 1)
+	assert.Equal(t, codePermissionDenied, w.errors[0].code)
+	assert.Zero(t, countAgentRows(t, svc))
+}
+
+func TestPermissionGuardrails_OrgDefaultsAddToTheWorkers(t *testing.T) {
+	svc, _, _ := setupTestService(t, withWorkspaces("ws-1"))
+	svc.PermissionGuardrails = PermissionGuardrails{Forbidden: []string{agent.PermissionModeAcceptEdits}, Default: agent.PermissionModeAcceptEdits}
+	svc.SetOrgDefaults(&leapmuxv1.OrgDefaults{
+		ForbiddenPermissionModes: []string{"bypassPermissions"},
+		DefaultPermissionMode:    agent.PermissionModePlan,
+	})
+
+	g := svc.permissionGuardrails()
+	assert.True(t, g.forbids("bypassPermissions"), "the org's forbidden modes apply")
+	assert.True(t, g.forbids(agent.PermissionModeAcceptEdits), "the worker's own still apply")
+	assert.Equal(t, agent.PermissionModePlan, g.defaultMode(claudeProvider), "the org's default wins")
+	assert.Equal(t, []string{agent.PermissionModeAcceptEdits}, svc.PermissionGuardrails.Forbidden, "the worker's list is not modified")
+
+	svc.SetOrgDefaults(&leapmuxv1.OrgDefaults{DefaultPermissionMode: agent.PermissionModeAcceptEdits})
+	assert.Equal(t, agent.PermissionModeOrDefault(claudeProvider, ""), svc.permissionGuardrails().defaultMode(claudeProvider),
+		"an org default the worker forbids falls back to the provider's")
+
+	svc.PermissionGuardrails = PermissionGuardrails{}
+	svc.SetOrgDefaults(&leapmuxv1.OrgDefaults{
+		ForbiddenPermissionModes: []string{"bypassPermissions"},
+		DefaultPermissionMode:    agent.PermissionModePlan,
+	})
+	svc.setAgentPermissionModeWithAgent(seedGuardedAgent(t, svc, agent.PermissionModeDefault), "bypassPermissions")
+	assert.Equal(t, agent.PermissionModePlan, storedPermissionMode(t, svc), "a mode only the org forbids is replaced")
+}
@@ -0,0 +1,78 @@
+package service
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/pmezard/go-difflib/difflib"
+)
+
+// planDiffContext is how many unchanged lines surround each change in a
+// plan diff, as in `diff -u`.
+const planDiffContext = 3
+
+// unifiedPlanDiff returns a unified diff from revision fromRev's content to
+// toRev's, or "" when they are the same.
+func unifiedPlanDiff(from, to string, fromRev, toRev int64) string {
+	a, b := splitPlanLines(from), splitPlanLines(to)
+	groups := difflib.NewMatcher(a, b).GetGroupedOpCodes(planDiffContext)
+	if len(groups) == 0 {
+		return ""
+	}
+	var out strings.Builder
+	fmt.Fprintf(&out, "--- revision %d\n+++ revision %d\n", fromRev, toRev)
+	for _, group := range groups {
+		first, last := group[0], group[len(group)-1]
+		fmt.Fprintf(&out, "@@ -%s +%s @@\n", hunkRange(first.I1, last.I2-first.I1), hunkRange(first.J1, last.J2-first.J1))
+		for _, op := range group {
+			if op.Tag == 'e' {
+				writePlanLines(&out, ' ', a[op.I1:op.I2])
+				continue
+			}
+			if op.Tag == 'r' || op.Tag == 'd' {
+				writePlanLines(&out, '-', a[op.I1:op.I2])
+			}
+			if op.Tag == 'r' || op.Tag == 'i' {
+				writePlanLines(&out, '+', b[op.J1:op.J2])
+			}
+		}
+	}
+	return out.String()
+}
+
+// writePlanLines writes lines prefixed with prefix, marking a last line
+// that has no "\n" the way diff -u does.
+func writePlanLines(out *strings.Builder, prefix byte, lines []string) {
+	for _, line := range lines {
+		out.WriteByte(prefix)
+		out.WriteString(line)
+		if !strings.HasSuffix(line, "\n") {
+			out.WriteString("\n\\ No newline at end of file\n")
+		}
+	}
+}
+
+// hunkRange formats a hunk's line range the way diff -u does: 1-based,
+// with an empty range naming the line before it and a count of 1 omitted.
+func hunkRange(before, count int) string {
+	switch count {
+	case 0:
+		return fmt.Sprintf("%d,0", before)
+	case 1:
+		return fmt.Sprintf("%d", before+1)
+	}
+	return fmt.Sprintf("%d,%d", before+1, count)
+}
+
+// splitPlanLines splits s into lines that keep their "\n", so a missing
+// final newline is itself a difference.
+func splitPlanLines(s string) []string {
+	if s == "" {
+		return nil
+	}
+	lines := strings.SplitAfter(s, "\n")
+	if lines[len(lines)-1] == "" {
+		lines = lines[:len(lines)-1]
+	}
+	return lines
+}
@@ -0,0 +1,63 @@
+package service
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestUnifiedPlanDiff(t *testing.T) {
+	for name, tc := range map[string]struct {
+		from, to, want string
+	}{
+		"same":  {"a\nb\n", "a\nb\n", ""},
+		"empty": {"", "", ""},
+		"added line": {"1. copy\n", "1. copy\n2. verify\n", "--- revision 1\n+++ revision 2\n" +
+			"@@ -1 +1,2 @@\n 1. copy\n+2. verify\n"},
+		"from nothing": {"", "a\n", "--- revision 1\n+++ revision 2\n" +
+			"@@ -0,0 +1 @@\n+a\n"},
+		"changed line": {"a\nb\nc\n", "a\nB\nc\n", "--- revision 1\n+++ revision 2\n" +
+			"@@ -1,3 +1,3 @@\n a\n-b\n+B\n c\n"},
+		"final newline": {"a\nb", "a\nb\n", "--- revision 1\n+++ revision 2\n" +
+			"@@ -1,2 +1,2 @@\n a\n-b\n\\ No newline at end of file\n+b\n"},
+	} {
+		t.Run(name, func(t *testing.T) {
+			assert.Equal(t, tc.want, unifiedPlanDiff(tc.from, tc.to, 1, 2))
+		})
+	}
+}
+
+func TestUnifiedPlanDiff_SplitsDistantChangesIntoHunks(t *testing.T) {
+	var from, to strings.Builder
+	for i := 1; i <= 20; i++ {
+		fmt.Fprintf(&from, "%d\n", i)
+		switch i {
+		case 2:
+			to.WriteString("two\n")
+		case 18:
+		default:
+			fmt.Fprintf(&to, "%d\n", i)
+		}
+	}
+	assert.Equal(t, "--- revision 3\n+++ revision 5\n"+
+		"@@ -1,5 +1,5 @@\n 1\n-2\n+two\n 3\n 4\n 5\n"+
+		"@@ -15,6 +15,5 @@\n 15\n 16\n 17\n-18\n 19\n 20\n",
+		unifiedPlanDiff(from.String(), to.String(), 3, 5))
+}
+
+func TestUnifiedPlanDiff_BoundsUnrelatedRevisions(t *testing.T) {
+	var from, to strings.Builder
+	for i := range 20000 {
+		fmt.Fprintf(&from, "old %d\n", i)
+		fmt.Fprintf(&to, "new %d\n", i)
+	}
+	start := time.Now()
+	diff := unifiedPlanDiff("# Plan\n"+from.String(), "# Plan\n"+to.String(), 1, 2)
+	assert.Less(t, time.Since(start), 2*time.Second)
+	assert.True(t, strings.HasPrefix(diff, "--- revision 1\n+++ revision 2\n@@ -1,20001 +1,20001 @@\n # Plan\n-old 0\n"))
+	assert.Equal(t, 20000, strings.Count(diff, "\n-old "))
+	assert.Equal(t, 20000, strings.Count(diff, "\n+new "))
+}
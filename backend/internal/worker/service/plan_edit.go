@@ -0,0 +1,63 @@
+package service
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"log/slog"
+	"os"
+
+	leapmuxv1 "github.com/leapmux/leapmux/generated/proto/leapmux/v1"
+	"github.com/leapmux/leapmux/internal/util/userid"
+	"github.com/leapmux/leapmux/internal/worker/agent"
+	"github.com/leapmux/leapmux/internal/worker/channel"
+	db "github.com/leapmux/leapmux/internal/worker/generated/db"
+)
+
+func registerPlanEditHandlers(d registrar, svc *Service) {
+	// UpdateAgentPlan writes user-edited plan content through the same
+	// path the agent's own plan updates take (snapshot the prior file,
+	// write the new one, record it on the agents row), so the edit is
+	// what a later plan execution reads.
+	registerAgentGated(d, "UpdateAgentPlan",
+		func(ctx context.Context, _ userid.UserID, r *leapmuxv1.UpdateAgentPlanRequest, dbAgent db.Agent, sender channel.ResponseWriter) {
+			content := r.GetContent()
+			if content == "" {
+				sendInvalidArgument(sender, "content is required")
+				return
+			}
+			if len(content) > maxPlanContentLen {
+				sendInvalidArgument(sender, fmt.Sprintf("content must not exceed %d bytes", maxPlanContentLen))
+				return
+			}
+
+			svc.Output.updatePlan(dbAgent.ID, []byte(content), leapmuxv1.ContentCompression_CONTENT_COMPRESSION_NONE, agent.ExtractPlanTitle(content))
+
+			// updatePlan logs rather than returns its failures; confirm
+			// the edit landed by reading back what it recorded.
+			updated, err := svc.Queries.GetAgentByID(bgCtx(), dbAgent.ID)
+			if err != nil {
+				slog.Error("failed to reload agent after plan edit", "agent_id", dbAgent.ID, "error", err)
+				sendInternalError(sender, "failed to update plan")
+				return
+			}
+			onDisk, err := os.ReadFile(updated.PlanFilePath)
+			if err != nil || !bytes.Equal(onDisk, []byte(content)) {
+				sendInternalError(sender, "failed to update plan")
+				return
+			}
+
+			// updatePlan only announces a changed title or path; an edit
+			// that keeps both still changed what will execute, so say so.
+			if updated.PlanTitle == dbAgent.PlanTitle && updated.PlanFilePath == dbAgent.PlanFilePath && updated.PlanTitle != "" {
+				svc.Output.PersistLeapMuxNotification(dbAgent.ID, dbAgent.AgentProvider, agent.NotificationContent(agent.PlanUpdatedPayload{
+					PlanTitle:    updated.PlanTitle,
+					PlanFilePath: updated.PlanFilePath,
+				}))
+			}
+			sendProtoResponse(sender, &leapmuxv1.UpdateAgentPlanResponse{
+				PlanFilePath: updated.PlanFilePath,
+				PlanTitle:    updated.PlanTitle,
+			})
+		})
+}
@@ -0,0 +1,35 @@
+package service
+
+import (
+	"context"
+	"os"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/protobuf/proto"
+
+	leapmuxv1 "github.com/leapmux/leapmux/generated/proto/leapmux/v1"
+	"github.com/leapmux/leapmux/internal/worker/agent"
+)
+
+func TestUpdateAgentPlan_ReplacesPlanContent(t *testing.T) {
+	svc, d, w := setupTestService(t, withWorkspaces("ws-1"))
+	seedGuardedAgent(t, svc, agent.PermissionModePlan)
+
+	dispatch(d, "UpdateAgentPlan", &leapmuxv1.UpdateAgentPlanRequest{AgentId: "agent-1", Content: "# Rollout\n\n1. deploy\n"}, w)
+	require.Empty(t, w.errors)
+	var resp leapmuxv1.UpdateAgentPlanResponse
+	require.NoError(t, proto.Unmarshal(w.responses[0].GetPayload(), &resp))
+	assert.Equal(t, "Rollout", resp.GetPlanTitle())
+
+	// A same-title correction keeps the path and title.
+	dispatch(d, "UpdateAgentPlan", &leapmuxv1.UpdateAgentPlanRequest{AgentId: "agent-1", Content: "# Rollout\n\n1. deploy to staging\n"}, w)
+	require.Empty(t, w.errors)
+	row, err := svc.Queries.GetAgentByID(context.Background(), "agent-1")
+	require.NoError(t, err)
+	data, err := os.ReadFile(row.PlanFilePath)
+	require.NoError(t, err)
+	assert.Equal(t, "# Rollout\n\n1. deploy to staging\n", string(data))
+	assert.NotEmpty(t, findNotificationsByType(readAllNotifications(t, svc.Queries, "agent-1"), agent.NotificationTypePlanUpdated))
+}
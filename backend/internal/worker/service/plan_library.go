@@ -0,0 +1,279 @@
+package service
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"log/slog"
+	"os"
+
+	leapmuxv1 "github.com/leapmux/leapmux/generated/proto/leapmux/v1"
+	"github.com/leapmux/leapmux/internal/util/id"
+	"github.com/leapmux/leapmux/internal/util/timefmt"
+	"github.com/leapmux/leapmux/internal/util/userid"
+	"github.com/leapmux/leapmux/internal/worker/agent"
+	"github.com/leapmux/leapmux/internal/worker/channel"
+	db "github.com/leapmux/leapmux/internal/worker/generated/db"
+)
+
+// maxPlanContentLen bounds a stored plan revision. Plans are prose the agent
+// re-reads in full, so anything near this size is already unusable.
+const maxPlanContentLen = 1 << 20
+
+// maxPlanTitleLen bounds a library plan's title.
+const maxPlanTitleLen = 256
+
+// errPlanNotFound reports a plan or revision that does not exist in the
+// requested workspace.
+var errPlanNotFound = errors.New("plan not found")
+
+func planRevisionToProto(row db.PlanRevision) *leapmuxv1.PlanRevision {
+	return &leapmuxv1.PlanRevision{
+		PlanId:        row.PlanID,
+		Revision:      row.Revision,
+		Content:       row.Content,
+		SourceAgentId: row.SourceAgentID,
+		CreatedAt:     timefmt.Format(row.CreatedAt.Time),
+	}
+}
+
+// loadPlanRevision returns revision of planID, or its latest revision when
+// revision is 0, after checking the plan belongs to workspaceID.
+func (svc *Service) loadPlanRevision(ctx context.Context, workspaceID, planID string, revision int64) (db.PlanRevision, error) {
+	if _, err := svc.Queries.GetWorkspacePlan(ctx, db.GetWorkspacePlanParams{ID: planID, WorkspaceID: workspaceID}); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return db.PlanRevision{}, errPlanNotFound
+		}
+		return db.PlanRevision{}, err
+	}
+	var (
+		row db.PlanRevision
+		err error
+	)
+	if revision == 0 {
+		row, err = svc.Queries.GetLatestPlanRevision(ctx, planID)
+	} else {
+		row, err = svc.Queries.GetPlanRevision(ctx, db.GetPlanRevisionParams{PlanID: planID, Revision: revision})
+	}
+	if errors.Is(err, sql.ErrNoRows) {
+		return db.PlanRevision{}, errPlanNotFound
+	}
+	return row, err
+}
+
+// sendPlanLoadError maps a loadPlanRevision failure onto the response.
+func sendPlanLoadError(sender channel.ResponseWriter, planID string, err error) {
+	if errors.Is(err, errPlanNotFound) {
+		sendNotFoundError(sender, "plan not found")
+		return
+	}
+	slog.Error("failed to load plan", "plan_id", planID, "error", err)
+	sendInternalError(sender, "failed to load plan")
+}
+
+// capturedAgentPlan reads the current plan of agentID, which must belong to
+// workspaceID. The plan file is the sole source of truth for its content.
+func (svc *Service) capturedAgentPlan(ctx context.Context, workspaceID, agentID string) (string, error) {
+	dbAgent, err := svc.Queries.GetAgentByID(ctx, agentID)
+	if err != nil || dbAgent.WorkspaceID != workspaceID {
+		return "", errPlanNotFound
+	}
+	if dbAgent.PlanFilePath == "" {
+		return "", errPlanNotFound
+	}
+	data, err := os.ReadFile(dbAgent.PlanFilePath)
+	if err != nil || len(data) == 0 {
+		return "", errPlanNotFound
+	}
+	return string(data), nil
+}
+
+// savePlanRevision creates the plan when planID is empty (else retitles it
+// when title is set) and appends content as its next revision, atomically.
+func (svc *Service) savePlanRevision(ctx context.Context, workspaceID, planID, title, content, sourceAgentID string) (string, error) {
+	tx, err := svc.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return "", err
+	}
+	defer func() { _ = tx.Rollback() }()
+	queries := svc.Queries.WithTx(tx)
+
+	if planID == "" {
+		planID = id.Generate()
+		if err := queries.CreateWorkspacePlan(ctx, db.CreateWorkspacePlanParams{
+			ID:          planID,
+			WorkspaceID: workspaceID,
+			Title:       title,
+		}); err != nil {
+			return "", err
+		}
+	} else {
+		existing, err := queries.GetWorkspacePlan(ctx, db.GetWorkspacePlanParams{ID: planID, WorkspaceID: workspaceID})
+		if errors.Is(err, sql.ErrNoRows) {
+			return "", errPlanNotFound
+		}
+		if err != nil {
+			return "", err
+		}
+		if title == "" {
+			title = existing.Title
+		}
+		if err := queries.TouchWorkspacePlan(ctx, db.TouchWorkspacePlanParams{Title: title, ID: planID}); err != nil {
+			return "", err
+		}
+	}
+	if _, err := queries.AppendPlanRevision(ctx, db.AppendPlanRevisionParams{
+		PlanID:        planID,
+		Content:       content,
+		SourceAgentID: sourceAgentID,
+	}); err != nil {
+		return "", err
+	}
+	return planID, tx.Commit()
+}
+
+// planSummary returns the library entry for planID in workspaceID.
+func (svc *Service) planSummary(ctx context.Context, workspaceID, planID string) (*leapmuxv1.PlanSummary, error) {
+	rows, err := svc.Queries.ListWorkspacePlans(ctx, workspaceID)
+	if err != nil {
+		return nil, err
+	}
+	for _, row := range rows {
+		if row.ID == planID {
+			return planSummaryFromRow(row), nil
+		}
+	}
+	return nil, errPlanNotFound
+}
+
+func planSummaryFromRow(row db.ListWorkspacePlansRow) *leapmuxv1.PlanSummary {
+	return &leapmuxv1.PlanSummary{
+		PlanId:         row.ID,
+		Title:          row.Title,
+		LatestRevision: row.LatestRevision,
+		CreatedAt:      timefmt.Format(row.CreatedAt.Time),
+		UpdatedAt:      timefmt.Format(row.UpdatedAt.Time),
+	}
+}
+
+func registerPlanLibraryHandlers(d registrar, svc *Service) {
+	registerWorkspaceGated(d, "SavePlan",
+		func(ctx context.Context, _ userid.UserID, r *leapmuxv1.SavePlanRequest, sender channel.ResponseWriter) {
+			if len(r.GetTitle()) > maxPlanTitleLen {
+				sendInvalidArgument(sender, fmt.Sprintf("title must not exceed %d bytes", maxPlanTitleLen))
+				return
+			}
+			content := r.GetContent()
+			if content == "" && r.GetSourceAgentId() != "" {
+				captured, err := svc.capturedAgentPlan(ctx, r.GetWorkspaceId(), r.GetSourceAgentId())
+				if err != nil {
+					sendFailedPrecondition(sender, "agent has no plan to capture")
+					return
+				}
+				content = captured
+			}
+			if content == "" {
+				sendInvalidArgument(sender, "content is required")
+				return
+			}
+			if len(content) > maxPlanContentLen {
+				sendInvalidArgument(sender, fmt.Sprintf("content must not exceed %d bytes", maxPlanContentLen))
+				return
+			}
+
+			planID, err := svc.savePlanRevision(ctx, r.GetWorkspaceId(), r.GetPlanId(), r.GetTitle(), content, r.GetSourceAgentId())
+			if err != nil {
+				sendPlanLoadError(sender, r.GetPlanId(), err)
+				return
+			}
+			summary, err := svc.planSummary(ctx, r.GetWorkspaceId(), planID)
+			if err != nil {
+				sendPlanLoadError(sender, planID, err)
+				return
+			}
+			sendProtoResponse(sender, &leapmuxv1.SavePlanResponse{Plan: summary})
+		})
+
+	registerWorkspaceGated(d, "ListPlans",
+		func(ctx context.Context, _ userid.UserID, r *leapmuxv1.ListPlansRequest, sender channel.ResponseWriter) {
+			rows, err := svc.Queries.ListWorkspacePlans(ctx, r.GetWorkspaceId())
+			if err != nil {
+				slog.Error("failed to list plans", "workspace_id", r.GetWorkspaceId(), "error", err)
+				sendInternalError(sender, "failed to list plans")
+				return
+			}
+			plans := make([]*leapmuxv1.PlanSummary, len(rows))
+			for i, row := range rows {
+				plans[i] = planSummaryFromRow(row)
+			}
+			sendProtoResponse(sender, &leapmuxv1.ListPlansResponse{Plans: plans})
+		})
+
+	registerWorkspaceGated(d, "GetPlanRevision",
+		func(ctx context.Context, _ userid.UserID, r *leapmuxv1.GetPlanRevisionRequest, sender channel.ResponseWriter) {
+			row, err := svc.loadPlanRevision(ctx, r.GetWorkspaceId(), r.GetPlanId(), r.GetRevision())
+			if err != nil {
+				sendPlanLoadError(sender, r.GetPlanId(), err)
+				return
+			}
+			sendProtoResponse(sender, &leapmuxv1.GetPlanRevisionResponse{Revision: planRevisionToProto(row)})
+		})
+
+	registerWorkspaceGated(d, "ComparePlanRevisions",
+		func(ctx context.Context, _ userid.UserID, r *leapmuxv1.ComparePlanRevisionsRequest, sender channel.ResponseWriter) {
+			if r.GetFromRevision() <= 0 {
+				sendInvalidArgument(sender, "from_revision is required")
+				return
+			}
+			from, err := svc.loadPlanRevision(ctx, r.GetWorkspaceId(), r.GetPlanId(), r.GetFromRevision())
+			if err != nil {
+				sendPlanLoadError(sender, r.GetPlanId(), err)
+				return
+			}
+			to, err := svc.loadPlanRevision(ctx, r.GetWorkspaceId(), r.GetPlanId(), r.GetToRevision())
+			if err != nil {
+				sendPlanLoadError(sender, r.GetPlanId(), err)
+				return
+			}
+			sendProtoResponse(sender, &leapmuxv1.ComparePlanRevisionsResponse{
+				From: planRevisionToProto(from),
+				To:   planRevisionToProto(to),
+				Diff: unifiedPlanDiff(from.Content, to.Content, from.Revision, to.Revision),
+			})
+		})
+
+	registerWorkspaceGated(d, "DeletePlan",
+		func(ctx context.Context, _ userid.UserID, r *leapmuxv1.DeletePlanRequest, sender channel.ResponseWriter) {
+			n, err := svc.Queries.DeleteWorkspacePlan(ctx, db.DeleteWorkspacePlanParams{
+				ID:          r.GetPlanId(),
+				WorkspaceID: r.GetWorkspaceId(),
+			})
+			if err != nil {
+				slog.Error("failed to delete plan", "plan_id", r.GetPlanId(), "error", err)
+				sendInternalError(sender, "failed to delete plan")
+				return
+			}
+			if n == 0 {
+				sendNotFoundError(sender, "plan not found")
+				return
+			}
+			sendProtoResponse(sender, &leapmuxv1.DeletePlanResponse{})
+		})
+
+	// ExecutePlan runs a library plan on the agent. Like the ExitPlanMode
+	// approval it mirrors, execution continues after the RPC returns, so
+	// dispatcher ctx is not threaded into it.
+	registerAgentGated(d, "ExecutePlan",
+		func(ctx context.Context, _ userid.UserID, r *leapmuxv1.ExecutePlanRequest, dbAgent db.Agent, sender channel.ResponseWriter) {
+			row, err := svc.loadPlanRevision(ctx, dbAgent.WorkspaceID, r.GetPlanId(), r.GetRevision())
+			if err != nil {
+				sendPlanLoadError(sender, r.GetPlanId(), err)
+				return
+			}
+			targetMode := resolveTargetMode(r.GetPermissionMode(), agent.PermissionModeAcceptEdits)
+			dbAgent = svc.setAgentPermissionModeWithAgent(dbAgent, targetMode)
+			go svc.executePlanContent(dbAgent, targetMode, row.Content, "")
+			sendProtoResponse(sender, &leapmuxv1.ExecutePlanResponse{})
+		})
+}
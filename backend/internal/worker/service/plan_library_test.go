@@ -0,0 +1,106 @@
+package service
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/protobuf/proto"
+
+	leapmuxv1 "github.com/leapmux/leapmux/generated/proto/leapmux/v1"
+	"github.com/leapmux/leapmux/internal/worker/agent"
+	"github.com/leapmux/leapmux/internal/worker/channel"
+	db "github.com/leapmux/leapmux/internal/worker/generated/db"
+)
+
+func savePlan(t *testing.T, d *channel.Dispatcher, w *testResponseWriter, req *leapmuxv1.SavePlanRequest) *leapmuxv1.PlanSummary {
+	t.Helper()
+	n := len(w.responses)
+	dispatch(d, "SavePlan", req, w)
+	require.Empty(t, w.errors)
+	require.Len(t, w.responses, n+1)
+	var resp leapmuxv1.SavePlanResponse
+	require.NoError(t, proto.Unmarshal(w.responses[n].GetPayload(), &resp))
+	return resp.GetPlan()
+}
+
+func TestPlanLibrary_RevisionsAndCompare(t *testing.T) {
+	_, d, w := setupTestService(t, withWorkspaces("ws-1"))
+
+	plan := savePlan(t, d, w, &leapmuxv1.SavePlanRequest{WorkspaceId: "ws-1", Title: "Migrate", Content: "1. copy\n"})
+	require.NotEmpty(t, plan.GetPlanId())
+	assert.Equal(t, int64(1), plan.GetLatestRevision())
+
+	plan = savePlan(t, d, w, &leapmuxv1.SavePlanRequest{WorkspaceId: "ws-1", PlanId: plan.GetPlanId(), Content: "1. copy\n2. verify\n"})
+	assert.Equal(t, int64(2), plan.GetLatestRevision())
+	assert.Equal(t, "Migrate", plan.GetTitle(), "an untitled save keeps the title")
+
+	dispatch(d, "ComparePlanRevisions", &leapmuxv1.ComparePlanRevisionsRequest{
+		WorkspaceId: "ws-1", PlanId: plan.GetPlanId(), FromRevision: 1,
+	}, w)
+	require.Empty(t, w.errors)
+	var cmp leapmuxv1.ComparePlanRevisionsResponse
+	require.NoError(t, proto.Unmarshal(w.responses[len(w.responses)-1].GetPayload(), &cmp))
+	assert.Equal(t, "1. copy\n", cmp.GetFrom().GetContent())
+	assert.Equal(t, int64(2), cmp.GetTo().GetRevision(), "to_revision 0 is the latest")
+	assert.Equal(t, "--- revision 1\n+++ revision 2\n@@ -1 +1,2 @@\n 1. copy\n+2. verify\n", cmp.GetDiff())
+
+	dispatch(d, "ListPlans", &leapmuxv1.ListPlansRequest{WorkspaceId: "ws-1"}, w)
+	var list leapmuxv1.ListPlansResponse
+	require.NoError(t, proto.Unmarshal(w.responses[len(w.responses)-1].GetPayload(), &list))
+	require.Len(t, list.GetPlans(), 1)
+
+	dispatch(d, "DeletePlan", &leapmuxv1.DeletePlanRequest{WorkspaceId: "ws-1", PlanId: plan.GetPlanId()}, w)
+	dispatch(d, "GetPlanRevision", &leapmuxv1.GetPlanRevisionRequest{WorkspaceId: "ws-1", PlanId: plan.GetPlanId()}, w)
+	require.Len(t, w.errors, 1)
+	assert.Equal(t, codeNotFound, w.errors[0].code)
+}
+
+func TestPlanLibrary_PlansAreWorkspaceScoped(t *testing.T) {
+	_, d, w := setupTestService(t, withWorkspaces("ws-1", "ws-2"))
+	plan := savePlan(t, d, w, &leapmuxv1.SavePlanRequest{WorkspaceId: "ws-1", Content: "plan"})
+
+	dispatch(d, "GetPlanRevision", &leapmuxv1.GetPlanRevisionRequest{WorkspaceId: "ws-2", PlanId: plan.GetPlanId()}, w)
+	dispatch(d, "SavePlan", &leapmuxv1.SavePlanRequest{WorkspaceId: "ws-2", PlanId: plan.GetPlanId(), Content: "hijack"}, w)
+	require.Len(t, w.errors, 2)
+	assert.Equal(t, codeNotFound, w.errors[0].code)
+	assert.Equal(t, codeNotFound, w.errors[1].code)
+}
+
+func TestPlanLibrary_CapturesAgentPlan(t *testing.T) {
+	svc, d, w := setupTestService(t, withWorkspaces("ws-1"))
+	planPath := filepath.Join(t.TempDir(), "plan.md")
+	require.NoError(t, os.WriteFile(planPath, []byte("# Captured\n"), 0o600))
+	seedModelAgent(t, svc.Queries, "agent-1", "opus")
+	require.NoError(t, svc.Queries.UpdateAgentPlanFilePath(context.Background(), db.UpdateAgentPlanFilePathParams{
+		PlanFilePath: planPath,
+		ID:           "agent-1",
+	}))
+
+	plan := savePlan(t, d, w, &leapmuxv1.SavePlanRequest{WorkspaceId: "ws-1", SourceAgentId: "agent-1"})
+	row, err := svc.loadPlanRevision(context.Background(), "ws-1", plan.GetPlanId(), 0)
+	require.NoError(t, err)
+	assert.Equal(t, "# Captured\n", row.Content)
+	assert.Equal(t, "agent-1", row.SourceAgentID)
+}
+
+func TestExecutePlan_RunsLibraryPlanOnAgent(t *testing.T) {
+	svc, d, w := setupTestService(t, withWorkspaces("ws-1"))
+	seedGuardedAgent(t, svc, agent.PermissionModePlan)
+	svc.startAgentFn = func(context.Context, agent.Options, agent.OutputSink) (map[string]string, error) {
+		return map[string]string{}, nil
+	}
+	plan := savePlan(t, d, w, &leapmuxv1.SavePlanRequest{WorkspaceId: "ws-1", Content: "1. ship it"})
+
+	dispatch(d, "ExecutePlan", &leapmuxv1.ExecutePlanRequest{AgentId: "agent-1", PlanId: plan.GetPlanId()}, w)
+	require.Empty(t, w.errors)
+
+	require.Eventually(t, func() bool {
+		return len(findNotificationsByType(readAllNotifications(t, svc.Queries, "agent-1"), agent.NotificationTypePlanExecution)) == 1
+	}, 5*time.Second, 10*time.Millisecond)
+	assert.Equal(t, agent.PermissionModeAcceptEdits, storedPermissionMode(t, svc))
+}
@@ -0,0 +1,57 @@
+package service
+
+import (
+	"sort"
+	"sync"
+
+	leapmuxv1 "github.com/leapmux/leapmux/generated/proto/leapmux/v1"
+)
+
+// planModeToolUse is an EnterPlanMode / ExitPlanMode tool_use awaiting its
+// result, and the permission mode the result switches the agent to.
+type planModeToolUse struct {
+	agentID    string
+	targetMode string
+}
+
+// planModeTracker holds the plan-mode tool_uses awaiting their results,
+// across agents. tool_use ids are unique, so one map serves every agent.
+type planModeTracker struct {
+	uses sync.Map // tool_use_id -> planModeToolUse
+}
+
+// track records that toolUseID's result switches agentID to targetMode.
+func (t *planModeTracker) track(agentID, toolUseID, targetMode string) {
+	t.uses.Store(toolUseID, planModeToolUse{agentID: agentID, targetMode: targetMode})
+}
+
+// take returns toolUseID's target mode and stops tracking it.
+func (t *planModeTracker) take(toolUseID string) (string, bool) {
+	v, ok := t.uses.LoadAndDelete(toolUseID)
+	if !ok {
+		return "", false
+	}
+	return v.(planModeToolUse).targetMode, true
+}
+
+// forget stops tracking toolUseID, whose mode switch was applied some
+// other way.
+func (t *planModeTracker) forget(toolUseID string) {
+	t.uses.Delete(toolUseID)
+}
+
+// forAgent returns agentID's tracked tool_uses, ordered by id.
+func (t *planModeTracker) forAgent(agentID string) []*leapmuxv1.PlanModeToolUse {
+	var out []*leapmuxv1.PlanModeToolUse
+	t.uses.Range(func(k, v any) bool {
+		if use := v.(planModeToolUse); use.agentID == agentID {
+			out = append(out, &leapmuxv1.PlanModeToolUse{
+				ToolUseId:  k.(string),
+				TargetMode: use.targetMode,
+			})
+		}
+		return true
+	})
+	sort.Slice(out, func(i, j int) bool { return out[i].GetToolUseId() < out[j].GetToolUseId() })
+	return out
+}
@@ -0,0 +1,31 @@
+package service
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestPlanModeTracker(t *testing.T) {
+	var tr planModeTracker
+	tr.track("agent-1", "tu-2", "plan")
+	tr.track("agent-1", "tu-1", "default")
+	tr.track("agent-2", "tu-3", "plan")
+
+	uses := tr.forAgent("agent-1")
+	require.Len(t, uses, 2)
+	assert.Equal(t, "tu-1", uses[0].GetToolUseId())
+	assert.Equal(t, "default", uses[0].GetTargetMode())
+	assert.Equal(t, "tu-2", uses[1].GetToolUseId())
+
+	mode, ok := tr.take("tu-2")
+	assert.True(t, ok)
+	assert.Equal(t, "plan", mode)
+	_, ok = tr.take("tu-2")
+	assert.False(t, ok, "take stops tracking")
+
+	tr.forget("tu-1")
+	assert.Empty(t, tr.forAgent("agent-1"))
+	assert.Len(t, tr.forAgent("agent-2"), 1)
+}
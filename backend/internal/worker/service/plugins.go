@@ -0,0 +1,96 @@
+package service
+
+import (
+	"encoding/json"
+
+	leapmuxv1 "github.com/leapmux/leapmux/generated/proto/leapmux/v1"
+	"github.com/leapmux/leapmux/internal/plugin"
+	"github.com/leapmux/leapmux/internal/util/agentlabels"
+	"github.com/leapmux/leapmux/internal/worker/agent"
+)
+
+// Plugin event payloads, the `data` of each plugin.Event the worker sends.
+type (
+	pluginMessageReceived struct {
+		AgentID  string          `json:"agent_id"`
+		Provider string          `json:"provider"`
+		Type     string          `json:"type,omitempty"`
+		Method   string          `json:"method,omitempty"`
+		Message  json.RawMessage `json:"message"`
+	}
+	pluginControlRequest struct {
+		WorkspaceID string          `json:"workspace_id"`
+		AgentID     string          `json:"agent_id"`
+		Provider    string          `json:"provider"`
+		RequestID   string          `json:"request_id"`
+		Request     json.RawMessage `json:"request"`
+	}
+	pluginTurnCompleted struct {
+		WorkspaceID  string  `json:"workspace_id"`
+		AgentID      string  `json:"agent_id"`
+		Provider     string  `json:"provider"`
+		InputTokens  int64   `json:"input_tokens,omitempty"`
+		OutputTokens int64   `json:"output_tokens,omitempty"`
+		CostUSD      float64 `json:"cost_usd,omitempty"`
+	}
+)
+
+// wirePlugins hands agent output and control requests to the operator's
+// plugins, with their secrets replaced. Turn ends reach them through the
+// span observer New sets.
+func (svc *Service) wirePlugins() {
+	if svc.Plugins == nil {
+		return
+	}
+	svc.AddOutputProcessor(agent.OutputProcessorFunc(func(line *agent.OutputLine, _ agent.OutputSink) bool {
+		raw := line.Raw
+		if redactionSkips(line) {
+			var ok bool
+			if raw, ok = svc.redactForPlugins(line.AgentID, raw); !ok {
+				return false
+			}
+		}
+		svc.Plugins.Emit(plugin.HookMessageReceived, pluginMessageReceived{
+			AgentID:  line.AgentID,
+			Provider: agentlabels.CLIAlias(line.Provider),
+			Type:     line.Type,
+			Method:   line.Method,
+			Message:  raw,
+		})
+		return false
+	}))
+	svc.Output.SetControlRequestObserver(func(agentID string, provider leapmuxv1.AgentProvider, requestID string, payload []byte) {
+		payload, ok := svc.redactForPlugins(agentID, payload)
+		if !ok {
+			return
+		}
+		workspaceID, _ := svc.Queries.GetAgentWorkspaceID(bgCtx(), agentID)
+		svc.Plugins.Emit(plugin.HookControlRequest, pluginControlRequest{
+			WorkspaceID: workspaceID,
+			AgentID:     agentID,
+			Provider:    agentlabels.CLIAlias(provider),
+			RequestID:   requestID,
+			Request:     payload,
+		})
+	})
+}
+
+// emitTurnCompleted tells the plugins that an agent's turn ended, with
+// its usage when the turn-end span carries the turn's total.
+func (svc *Service) emitTurnCompleted(agentID string, provider leapmuxv1.AgentProvider, span agent.SpanInfo) {
+	if svc.Plugins == nil {
+		return
+	}
+	workspaceID, _ := svc.Queries.GetAgentWorkspaceID(bgCtx(), agentID)
+	ev := pluginTurnCompleted{
+		WorkspaceID: workspaceID,
+		AgentID:     agentID,
+		Provider:    agentlabels.CLIAlias(provider),
+	}
+	if u := span.Usage; u != nil && u.Turn {
+		ev.InputTokens = u.InputTokens
+		ev.OutputTokens = u.OutputTokens
+		ev.CostUSD = u.CostUSD
+	}
+	svc.Plugins.Emit(plugin.HookTurnCompleted, ev)
+}
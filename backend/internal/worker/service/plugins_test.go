@@ -0,0 +1,116 @@
+package service
+
+import (
+	"context"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/leapmux/leapmux/internal/plugin"
+	"github.com/leapmux/leapmux/internal/util/testutil"
+	"github.com/leapmux/leapmux/internal/worker/agent"
+	"github.com/leapmux/leapmux/internal/worker/redact"
+)
+
+// startRecorderPlugin starts a plugin host whose one exec plugin appends
+// every event it is sent to the returned file, one JSON object a line.
+func startRecorderPlugin(t *testing.T) (*plugin.Host, string) {
+	t.Helper()
+	if runtime.GOOS == "windows" {
+		t.Skip("shell script plugins")
+	}
+	dir := t.TempDir()
+	out := filepath.Join(t.TempDir(), "events")
+	t.Setenv("PLUGIN_TEST_OUT", out)
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "recorder"), []byte("#!/bin/sh\ncat >>\"$PLUGIN_TEST_OUT\"\n"), 0o755))
+	host, err := plugin.New(dir, "worker")
+	require.NoError(t, err)
+	ctx, cancel := context.WithCancel(context.Background())
+	t.Cleanup(cancel)
+	host.Start(ctx)
+	return host, out
+}
+
+// waitForPluginEvents waits until the recorder at out has written n
+// events and returns them.
+func waitForPluginEvents(t *testing.T, out string, n int) []map[string]any {
+	t.Helper()
+	var events []map[string]any
+	testutil.AssertEventually(t, func() bool {
+		data, _ := os.ReadFile(out)
+		events = nil
+		for _, line := range strings.Split(strings.TrimSpace(string(data)), "\n") {
+			var e map[string]any
+			if json.Unmarshal([]byte(line), &e) == nil {
+				events = append(events, e)
+			}
+		}
+		return len(events) == n
+	}, "expected %d plugin events", n)
+	return events
+}
+
+func TestPlugins_ReceiveAgentEvents(t *testing.T) {
+	host, out := startRecorderPlugin(t)
+	svc, _, _ := setupTestService(t, withWorkspaces("ws-1"))
+	seedGuardedAgent(t, svc, agent.PermissionModeDefault)
+	svc.Plugins = host
+	svc.wirePlugins()
+
+	require.Len(t, svc.outputProcessors, 1)
+	assert.False(t, svc.outputProcessors[0].ProcessOutput(&agent.OutputLine{
+		AgentID: "agent-1", Provider: claudeProvider, Type: "assistant", Raw: []byte(`{"type":"assistant"}`),
+	}, nil), "plugins only watch output")
+	svc.Output.observeControlRequest("agent-1", claudeProvider, "req-1", []byte(`{"tool_name":"Bash"}`))
+	svc.Output.observeSpan("agent-1", claudeProvider, agent.SpanInfo{
+		Usage: &agent.MessageUsage{InputTokens: 10, OutputTokens: 5, CostUSD: 0.25, Turn: true},
+	}, true)
+
+	events := waitForPluginEvents(t, out, 3)
+
+	assert.Equal(t, "message_received", events[0]["hook"])
+	assert.Equal(t, map[string]any{
+		"agent_id": "agent-1", "provider": "claude-code", "type": "assistant",
+		"message": map[string]any{"type": "assistant"},
+	}, events[0]["data"])
+	assert.Equal(t, "control_request", events[1]["hook"])
+	assert.Equal(t, map[string]any{
+		"workspace_id": "ws-1", "agent_id": "agent-1", "provider": "claude-code",
+		"request_id": "req-1", "request": map[string]any{"tool_name": "Bash"},
+	}, events[1]["data"])
+	assert.Equal(t, "turn_completed", events[2]["hook"])
+	assert.Equal(t, map[string]any{
+		"workspace_id": "ws-1", "agent_id": "agent-1", "provider": "claude-code",
+		"input_tokens": float64(10), "output_tokens": float64(5), "cost_usd": 0.25,
+	}, events[2]["data"])
+}
+
+func TestPlugins_ReceiveRedactedControlRequests(t *testing.T) {
+	host, out := startRecorderPlugin(t)
+	svc, _, _ := setupTestService(t, withWorkspaces("ws-1"))
+	seedGuardedAgent(t, svc, agent.PermissionModeDefault)
+	svc.Redactor = redact.New(redact.Defaults()...)
+	svc.Plugins = host
+	svc.wirePlugins()
+	key := "ghp_" + strings.Repeat("a1B2", 9)
+	request := `{"type":"control_request","request_id":"req-1","request":{"subtype":"can_use_tool","tool_name":"Bash","input":{"command":"gh auth login --with-token ` + key + `"}}}`
+
+	line := &agent.OutputLine{AgentID: "agent-1", Provider: claudeProvider, Type: "control_request", Raw: []byte(request)}
+	assert.False(t, svc.outputProcessors[0].ProcessOutput(line, nil))
+	assert.Equal(t, request, string(line.Raw), "the agent's own request is left alone")
+	svc.Output.observeControlRequest("agent-1", claudeProvider, "req-1", []byte(request))
+
+	events := waitForPluginEvents(t, out, 2)
+	for _, e := range events {
+		b, err := json.Marshal(e)
+		require.NoError(t, err)
+		assert.NotContains(t, string(b), key, e["hook"])
+		assert.Contains(t, string(b), "gh auth login --with-token [REDACTED:github-token]", e["hook"])
+	}
+}
@@ -0,0 +1,347 @@
+package service
+
+import (
+	"context"
+	"encoding/json"
+	"log/slog"
+	"slices"
+	"sort"
+	"sync"
+	"time"
+
+	leapmuxv1 "github.com/leapmux/leapmux/generated/proto/leapmux/v1"
+	"github.com/leapmux/leapmux/internal/util/timefmt"
+	"github.com/leapmux/leapmux/internal/util/userid"
+	"github.com/leapmux/leapmux/internal/worker/agent"
+	"github.com/leapmux/leapmux/internal/worker/channel"
+	db "github.com/leapmux/leapmux/internal/worker/generated/db"
+)
+
+// rateLimitNearUtilization is the share of a window past which an account
+// is near its limit, whatever status the provider reports.
+const rateLimitNearUtilization = 0.9
+
+// turnPriority orders the turns held back by a rate limit.
+type turnPriority int
+
+const (
+	// turnInteractive is a message a user sent.
+	turnInteractive turnPriority = iota
+	// turnBackground is a turn LeapMux sends by itself, such as an
+	// auto-continue. It also waits while the account is near its limit.
+	turnBackground
+)
+
+func (p turnPriority) String() string {
+	if p == turnBackground {
+		return "background"
+	}
+	return "interactive"
+}
+
+// rateLimitAccount identifies whose rate limits an agent draws on: the org
+// model credential it was opened with, or else the provider account signed
+// in under its home directory.
+type rateLimitAccount struct {
+	provider     leapmuxv1.AgentProvider
+	homeDir      string
+	credentialID string
+}
+
+func (svc *Service) rateLimitAccountOf(dbAgent db.Agent) rateLimitAccount {
+	if cred := svc.agentModelCredential(dbAgent.ID); cred != nil {
+		return rateLimitAccount{provider: dbAgent.AgentProvider, credentialID: cred.CredentialID}
+	}
+	return rateLimitAccount{provider: dbAgent.AgentProvider, homeDir: dbAgent.HomeDir}
+}
+
+// rateLimitTier is one window of the normalized rate_limits session info
+// every provider broadcasts (see the agent package's rate limit handlers).
+type rateLimitTier struct {
+	Type            string   `json:"rate_limit_type"`
+	Status          string   `json:"status"`
+	Utilization     *float64 `json:"utilization"`
+	ResetsAt        int64    `json:"resets_at"`
+	OverageStatus   string   `json:"overage_status"`
+	OverageResetsAt int64    `json:"overage_resets_at"`
+	IsUsingOverage  bool     `json:"is_using_overage"`
+
+	updatedAt time.Time
+}
+
+// blocked reports whether the window refuses requests, and when it lifts.
+// On overage the base window is absorbed, so only the overage blocks.
+func (t rateLimitTier) blocked() (bool, time.Time) {
+	if t.IsUsingOverage {
+		return t.OverageStatus == "rejected", unixTime(t.OverageResetsAt)
+	}
+	return t.Status == "rejected" || t.Status == "exceeded", unixTime(t.ResetsAt)
+}
+
+// near reports whether the window is close to blocking.
+func (t rateLimitTier) near() bool {
+	return t.Status == "allowed_warning" || (t.Utilization != nil && *t.Utilization >= rateLimitNearUtilization)
+}
+
+func unixTime(sec int64) time.Time {
+	if sec <= 0 {
+		return time.Time{}
+	}
+	return time.Unix(sec, 0).UTC()
+}
+
+type heldTurn struct {
+	priority turnPriority
+	deliver  func()
+}
+
+// rateLimitBudget is one account's last reported windows and the turns
+// held back until they allow more.
+type rateLimitBudget struct {
+	tiers map[string]rateLimitTier
+	held  []heldTurn // interactive first, each priority in arrival order
+	timer *time.Timer
+}
+
+// holdUntil returns when a turn of priority may go, or the zero time when
+// it may go now. A window whose reset has passed no longer holds anything,
+// and neither does one that never says when it resets.
+func (b *rateLimitBudget) holdUntil(priority turnPriority, now time.Time) time.Time {
+	var until time.Time
+	for _, t := range b.tiers {
+		blocked, reset := t.blocked()
+		if !blocked && priority == turnBackground && t.near() {
+			blocked, reset = true, unixTime(t.ResetsAt)
+		}
+		if blocked && reset.After(now) && reset.After(until) {
+			until = reset
+		}
+	}
+	return until
+}
+
+// rateLimitBudgets tracks the rate limit budget of every account the
+// worker's agents use.
+type rateLimitBudgets struct {
+	mu       sync.Mutex
+	accounts map[rateLimitAccount]*rateLimitBudget
+}
+
+func (r *rateLimitBudgets) budgetLocked(acct rateLimitAccount) *rateLimitBudget {
+	if r.accounts == nil {
+		r.accounts = map[rateLimitAccount]*rateLimitBudget{}
+	}
+	b, ok := r.accounts[acct]
+	if !ok {
+		b = &rateLimitBudget{tiers: map[string]rateLimitTier{}}
+		r.accounts[acct] = b
+	}
+	return b
+}
+
+// update merges a rate_limits report into acct's budget and releases any
+// turns it no longer holds.
+func (r *rateLimitBudgets) update(acct rateLimitAccount, tiers map[string]rateLimitTier) {
+	now := time.Now()
+	r.mu.Lock()
+	b := r.budgetLocked(acct)
+	for key, t := range tiers {
+		t.updatedAt = now
+		b.tiers[key] = t
+	}
+	r.mu.Unlock()
+	r.release(acct)
+}
+
+// hold queues deliver when acct's budget holds a turn of priority back,
+// behind any turn already held at that priority or above, and returns
+// when it is due. limited reports that the account is out of budget
+// rather than near its limit. held is false when the turn may go now;
+// deliver is then not kept.
+func (r *rateLimitBudgets) hold(acct rateLimitAccount, priority turnPriority, deliver func()) (until time.Time, limited, held bool) {
+	now := time.Now()
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	b := r.budgetLocked(acct)
+	queued := slices.ContainsFunc(b.held, func(h heldTurn) bool { return h.priority <= priority })
+	if b.holdUntil(priority, now).IsZero() && !queued {
+		return time.Time{}, false, false
+	}
+	b.held = append(b.held, heldTurn{priority: priority, deliver: deliver})
+	sort.SliceStable(b.held, func(i, j int) bool { return b.held[i].priority < b.held[j].priority })
+	until = r.armLocked(acct, b, now)
+	if own := b.holdUntil(priority, now); own.After(until) {
+		until = own
+	}
+	return until, !b.holdUntil(turnInteractive, now).IsZero(), true
+}
+
+// limited reports whether acct is out of budget for an interactive turn.
+func (r *rateLimitBudgets) limited(acct rateLimitAccount) bool {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	b, ok := r.accounts[acct]
+	return ok && !b.holdUntil(turnInteractive, time.Now()).IsZero()
+}
+
+// release delivers, in order, the held turns of acct that may go now, and
+// re-arms the timer for the rest.
+func (r *rateLimitBudgets) release(acct rateLimitAccount) {
+	now := time.Now()
+	r.mu.Lock()
+	b, ok := r.accounts[acct]
+	if !ok {
+		r.mu.Unlock()
+		return
+	}
+	var ready []func()
+	for len(b.held) > 0 && b.holdUntil(b.held[0].priority, now).IsZero() {
+		ready = append(ready, b.held[0].deliver)
+		b.held = b.held[1:]
+	}
+	r.armLocked(acct, b, now)
+	r.mu.Unlock()
+
+	if len(ready) > 0 {
+		go func() {
+			for _, deliver := range ready {
+				deliver()
+			}
+		}()
+	}
+}
+
+// armLocked schedules the next release of b's held turns and returns when
+// it is due: when the first of them may go.
+func (r *rateLimitBudgets) armLocked(acct rateLimitAccount, b *rateLimitBudget, now time.Time) time.Time {
+	if b.timer != nil {
+		b.timer.Stop()
+		b.timer = nil
+	}
+	if len(b.held) == 0 {
+		return time.Time{}
+	}
+	until := b.holdUntil(b.held[0].priority, now)
+	if until.IsZero() {
+		// Held behind a turn that has since gone: due now.
+		until = now
+	}
+	b.timer = time.AfterFunc(until.Sub(now), func() { r.release(acct) })
+	return until
+}
+
+// snapshot reports acct's budget.
+func (r *rateLimitBudgets) snapshot(acct rateLimitAccount) *leapmuxv1.RateLimitBudget {
+	now := time.Now()
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	out := &leapmuxv1.RateLimitBudget{AgentProvider: acct.provider}
+	b, ok := r.accounts[acct]
+	if !ok {
+		return out
+	}
+	for _, t := range b.tiers {
+		tier := &leapmuxv1.RateLimitTier{
+			RateLimitType:  t.Type,
+			Status:         t.Status,
+			Utilization:    t.Utilization,
+			IsUsingOverage: t.IsUsingOverage,
+			UpdatedAt:      timefmt.Format(t.updatedAt),
+		}
+		if reset := unixTime(t.ResetsAt); !reset.IsZero() {
+			tier.ResetsAt = timefmt.Format(reset)
+		}
+		out.Tiers = append(out.Tiers, tier)
+	}
+	sort.Slice(out.Tiers, func(i, j int) bool { return out.Tiers[i].RateLimitType < out.Tiers[j].RateLimitType })
+	for _, h := range b.held {
+		if h.priority == turnInteractive {
+			out.HeldInteractiveTurns++
+		} else {
+			out.HeldBackgroundTurns++
+		}
+	}
+	if len(b.held) > 0 {
+		if until := b.holdUntil(b.held[0].priority, now); !until.IsZero() {
+			out.HeldUntil = timefmt.Format(until)
+		}
+	}
+	return out
+}
+
+// observeRateLimits folds an agent's rate_limits report into its account's
+// budget.
+func (svc *Service) observeRateLimits(agentID string, raw []byte) {
+	var tiers map[string]rateLimitTier
+	if err := json.Unmarshal(raw, &tiers); err != nil {
+		slog.Warn("failed to decode rate limits", "agent_id", agentID, "error", err)
+		return
+	}
+	dbAgent, err := svc.Queries.GetAgentByID(bgCtx(), agentID)
+	if err != nil {
+		return
+	}
+	svc.rateLimits.update(svc.rateLimitAccountOf(dbAgent), tiers)
+}
+
+// holdTurn holds a turn for dbAgent back while its account's rate limit
+// does not allow it, posting a turn_held notification. deliver runs once
+// the turn may go. held is false when it may go now.
+func (svc *Service) holdTurn(dbAgent db.Agent, priority turnPriority, deliver func()) (held bool) {
+	until, limited, held := svc.rateLimits.hold(svc.rateLimitAccountOf(dbAgent), priority, deliver)
+	if !held {
+		return false
+	}
+	reason := "near_limit"
+	if limited {
+		reason = "rate_limited"
+	}
+	svc.Output.PersistLeapMuxNotification(dbAgent.ID, dbAgent.AgentProvider, agent.NotificationContent(agent.TurnHeldPayload{
+		Reason:   reason,
+		Priority: priority.String(),
+		Until:    timefmt.Format(until),
+	}))
+	return true
+}
+
+// deliverHeldTurn sends a turn the rate limit held back once it may go,
+// starting the agent if it stopped meanwhile. A failure is recorded on the
+// turn's message, as for a turn sent right away; onDelivered runs after a
+// successful send.
+func (svc *Service) deliverHeldTurn(agentID, messageID, content string, attachments []*leapmuxv1.Attachment, resumeSessionID string, onDelivered func()) {
+	deliveryError := ""
+	if err := svc.ensureAgentRunning(agentID, &resumeSessionID); err != nil {
+		deliveryError = "agent is not running"
+	} else if err := svc.sendAgentInput(agentID, content, attachments); err != nil {
+		slog.Error("failed to send held turn", "agent_id", agentID, "error", err)
+		deliveryError = err.Error()
+	}
+	if deliveryError == "" {
+		onDelivered()
+		return
+	}
+	_ = svc.Queries.SetMessageDeliveryError(bgCtx(), db.SetMessageDeliveryErrorParams{
+		DeliveryError: deliveryError,
+		ID:            messageID,
+		AgentID:       agentID,
+	})
+	svc.Watchers.BroadcastAgentEvent(agentID, &leapmuxv1.AgentEvent{
+		AgentId: agentID,
+		Event: &leapmuxv1.AgentEvent_MessageError{
+			MessageError: &leapmuxv1.AgentMessageError{
+				AgentId:   agentID,
+				MessageId: messageID,
+				Error:     deliveryError,
+			},
+		},
+	})
+}
+
+func registerRateLimitBudgetHandlers(d registrar, svc *Service) {
+	registerAgentGated(d, "GetRateLimitBudget",
+		func(_ context.Context, _ userid.UserID, _ *leapmuxv1.GetRateLimitBudgetRequest, dbAgent db.Agent, sender channel.ResponseWriter) {
+			sendProtoResponse(sender, &leapmuxv1.GetRateLimitBudgetResponse{
+				Budget: svc.rateLimits.snapshot(svc.rateLimitAccountOf(dbAgent)),
+			})
+		})
+}
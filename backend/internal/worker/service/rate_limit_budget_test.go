@@ -0,0 +1,131 @@
+package service
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	leapmuxv1 "github.com/leapmux/leapmux/generated/proto/leapmux/v1"
+	"github.com/leapmux/leapmux/internal/worker/agent"
+	db "github.com/leapmux/leapmux/internal/worker/generated/db"
+)
+
+var testAccount = rateLimitAccount{provider: claudeProvider, homeDir: "/home/a"}
+
+func utilization(v float64) *float64 { return &v }
+
+func TestRateLimitBudgets_ReleasesInteractiveFirst(t *testing.T) {
+	var r rateLimitBudgets
+	resets := time.Now().Add(time.Hour).Unix()
+	r.update(testAccount, map[string]rateLimitTier{
+		"five_hour": {Type: "five_hour", Status: "rejected", ResetsAt: resets},
+	})
+
+	delivered := make(chan string, 3)
+	deliver := func(name string) func() { return func() { delivered <- name } }
+	_, limited, held := r.hold(testAccount, turnBackground, deliver("background"))
+	require.True(t, held)
+	assert.True(t, limited)
+	until, _, held := r.hold(testAccount, turnInteractive, deliver("first"))
+	require.True(t, held)
+	assert.Equal(t, resets, until.Unix())
+	_, _, held = r.hold(testAccount, turnInteractive, deliver("second"))
+	require.True(t, held)
+
+	budget := r.snapshot(testAccount)
+	assert.EqualValues(t, 2, budget.HeldInteractiveTurns)
+	assert.EqualValues(t, 1, budget.HeldBackgroundTurns)
+	assert.NotEmpty(t, budget.HeldUntil)
+
+	r.update(testAccount, map[string]rateLimitTier{
+		"five_hour": {Type: "five_hour", Status: "allowed", ResetsAt: resets},
+	})
+	for _, want := range []string{"first", "second", "background"} {
+		select {
+		case got := <-delivered:
+			assert.Equal(t, want, got)
+		case <-time.After(5 * time.Second):
+			t.Fatalf("%s turn was not delivered", want)
+		}
+	}
+	assert.Zero(t, r.snapshot(testAccount).HeldInteractiveTurns)
+}
+
+func TestRateLimitBudgets_NearLimitHoldsOnlyBackground(t *testing.T) {
+	var r rateLimitBudgets
+	r.update(testAccount, map[string]rateLimitTier{
+		"seven_day": {Type: "seven_day", Status: "allowed", Utilization: utilization(0.95), ResetsAt: time.Now().Add(time.Hour).Unix()},
+	})
+
+	_, _, held := r.hold(testAccount, turnInteractive, func() {})
+	assert.False(t, held, "a user's turn goes while there is budget left")
+	_, limited, held := r.hold(testAccount, turnBackground, func() {})
+	assert.True(t, held)
+	assert.False(t, limited)
+
+	other := rateLimitAccount{provider: claudeProvider, homeDir: "/home/b"}
+	_, _, held = r.hold(other, turnBackground, func() {})
+	assert.False(t, held, "another account's budget is its own")
+}
+
+func TestRateLimitBudgets_PastResetNoLongerHolds(t *testing.T) {
+	var r rateLimitBudgets
+	r.update(testAccount, map[string]rateLimitTier{
+		"five_hour": {Type: "five_hour", Status: "rejected", ResetsAt: time.Now().Add(-time.Minute).Unix()},
+	})
+	_, _, held := r.hold(testAccount, turnInteractive, func() {})
+	assert.False(t, held)
+}
+
+func TestSendAgentMessage_HeldWhileRateLimited(t *testing.T) {
+	svc, d, w := setupTestService(t, withWorkspaces("ws-1"))
+	row := seedGuardedAgent(t, svc, "default")
+	started := make(chan struct{}, 1)
+	svc.startAgentFn = mockAgentStarter(t, svc, func(agent.Options) { started <- struct{}{} })
+	sink := svc.Output.NewSink(row.ID, row.AgentProvider)
+	resets := time.Now().Add(time.Hour).Unix()
+	report := func(status string) {
+		sink.BroadcastSessionInfo(map[string]any{
+			"rate_limits": map[string]any{
+				"five_hour": map[string]any{"rate_limit_type": "five_hour", "status": status, "resets_at": resets},
+			},
+		})
+	}
+
+	report("rejected")
+	dispatch(d, "SendAgentMessage", &leapmuxv1.SendAgentMessageRequest{AgentId: row.ID, Content: "hello"}, w)
+	require.Empty(t, w.errors)
+	assert.False(t, svc.Agents.HasAgent(row.ID), "a held turn must not start the agent")
+
+	notes := findNotificationsByType(readAllNotifications(t, svc.Queries, row.ID), agent.NotificationTypeTurnHeld)
+	require.Len(t, notes, 1)
+	assert.Equal(t, "rate_limited", notes[0]["reason"])
+	assert.Equal(t, "interactive", notes[0]["priority"])
+
+	w2 := &testResponseWriter{channelID: testChannelID}
+	dispatch(d, "GetRateLimitBudget", &leapmuxv1.GetRateLimitBudgetRequest{AgentId: row.ID}, w2)
+	budget := decodeResponse[leapmuxv1.GetRateLimitBudgetResponse](t, w2).GetBudget()
+	require.Len(t, budget.GetTiers(), 1)
+	assert.Equal(t, "rejected", budget.GetTiers()[0].GetStatus())
+	assert.EqualValues(t, 1, budget.GetHeldInteractiveTurns())
+
+	report("allowed")
+	select {
+	case <-started:
+	case <-time.After(5 * time.Second):
+		t.Fatal("the held turn was not delivered once the limit lifted")
+	}
+	require.Eventually(t, func() bool {
+		msgs, err := svc.Queries.ListAllMessagesByAgentID(context.Background(), db.ListAllMessagesByAgentIDParams{AgentID: row.ID})
+		require.NoError(t, err)
+		for _, m := range msgs {
+			if m.Source == leapmuxv1.MessageSource_MESSAGE_SOURCE_USER {
+				return m.DeliveryError == "" && svc.Agents.HasAgent(row.ID)
+			}
+		}
+		return false
+	}, 5*time.Second, 20*time.Millisecond)
+}
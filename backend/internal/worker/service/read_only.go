@@ -0,0 +1,106 @@
+package service
+
+import (
+	"context"
+	"strings"
+
+	"google.golang.org/grpc/codes"
+
+	"github.com/leapmux/leapmux/channelwire"
+	leapmuxv1 "github.com/leapmux/leapmux/generated/proto/leapmux/v1"
+	"github.com/leapmux/leapmux/internal/util/userid"
+	"github.com/leapmux/leapmux/internal/worker/channel"
+)
+
+// readOnlyMethods lists the inner RPCs a read-only channel may call: an
+// anonymous viewer of a public workspace, or a viewer guest, can follow its
+// agents and plans and nothing more. Every other method -- including the
+// owner-only machine surface (files, git, tunnels) the viewer would
+// otherwise reach by acting as the owner, and terminals, whose output is
+// the likeliest place for a secret to be on screen -- is denied before its
+// own gate runs.
+var readOnlyMethods = map[string]bool{
+	channelwire.PingMethod:     true,
+	"WatchEvents":              true,
+	"ListAgents":               true,
+	"ListAgentMessages":        true,
+	"GetAgentMessage":          true,
+	"ListMessageMarks":         true,
+	"ListAgentMessagesInRange": true,
+	"GetAgentMessageStats":     true,
+	"ListSubAgentRuns":         true,
+	"ListAgentTestRuns":        true,
+	"GetAgentCIStatus":         true,
+	"ListAgentTurnModels":      true,
+	"ListPlans":                true,
+	"GetPlanRevision":          true,
+	"ComparePlanRevisions":     true,
+}
+
+// readOnlyGate wraps handler so a read-only channel may call it only when
+// method is in readOnlyMethods. The denial answers in the method's reply
+// shape so a refused stream errors instead of hanging.
+func (r registrar) readOnlyGate(method string, shape methodShape, handler channel.HandlerFunc) channel.HandlerFunc {
+	if readOnlyMethods[method] {
+		return handler
+	}
+	return func(ctx context.Context, userID userid.UserID, req *leapmuxv1.InnerRpcRequest, sender channel.ResponseWriter) {
+		if r.svc.channelReadOnly(sender.ChannelID()) {
+			if shape == shapeStream {
+				sendStreamError(sender, codes.PermissionDenied, "channel is read-only")
+			} else {
+				sendPermissionDenied(sender, "channel is read-only")
+			}
+			return
+		}
+		handler(ctx, userID, req, sender)
+	}
+}
+
+// channelReadOnly reports whether channelID is a read-only channel. Local IPC
+// streams belong to spawned agents and are never read-only, and a Service
+// without a channel manager has no channels to restrict.
+func (svc *Service) channelReadOnly(channelID string) bool {
+	if svc.Channels == nil || strings.HasPrefix(channelID, LocalIPCStreamPrefix) {
+		return false
+	}
+	return svc.Channels.IsReadOnly(channelID)
+}
+
+// readOnlyWithholds reports whether a read-only channel should miss resp:
+// terminal output, pending control requests -- whose tool inputs are
+// exactly what the owner has not yet agreed to run -- and LeapMux's own
+// platform notifications, which carry agent settings and working paths
+// rather than conversation.
+func readOnlyWithholds(resp *leapmuxv1.WatchEventsResponse) bool {
+	switch e := resp.GetEvent().(type) {
+	case *leapmuxv1.WatchEventsResponse_TerminalEvent:
+		return true
+	case *leapmuxv1.WatchEventsResponse_AgentEvent:
+		switch ae := e.AgentEvent.GetEvent().(type) {
+		case *leapmuxv1.AgentEvent_ControlRequest, *leapmuxv1.AgentEvent_ControlCancel:
+			return true
+		case *leapmuxv1.AgentEvent_AgentMessage:
+			return readOnlyWithholdsMessage(ae.AgentMessage)
+		}
+	}
+	return false
+}
+
+// readOnlyWithholdsMessage reports whether a read-only channel should miss
+// msg, on every path that serves transcript rows.
+func readOnlyWithholdsMessage(msg *leapmuxv1.AgentChatMessage) bool {
+	return msg.GetSource() == leapmuxv1.MessageSource_MESSAGE_SOURCE_LEAPMUX
+}
+
+// withoutReadOnlyWithheld drops from msgs the rows a read-only channel
+// should miss.
+func withoutReadOnlyWithheld(msgs []*leapmuxv1.AgentChatMessage) []*leapmuxv1.AgentChatMessage {
+	kept := msgs[:0]
+	for _, m := range msgs {
+		if !readOnlyWithholdsMessage(m) {
+			kept = append(kept, m)
+		}
+	}
+	return kept
+}
@@ -0,0 +1,154 @@
+package service
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/protobuf/proto"
+
+	leapmuxv1 "github.com/leapmux/leapmux/generated/proto/leapmux/v1"
+	"github.com/leapmux/leapmux/internal/util/sqltime"
+	db "github.com/leapmux/leapmux/internal/worker/generated/db"
+)
+
+func TestReadOnlyMethodsAreRegistered(t *testing.T) {
+	svc, d, _ := setupTestService(t)
+	gates, _ := registerAllClassified(d, svc)
+	for method := range readOnlyMethods {
+		assert.Contains(t, gates, method, "read-only allowlist names an unregistered method")
+	}
+}
+
+func TestReadOnlyChannel_RejectsControlRPCs(t *testing.T) {
+	svc, d, _ := setupTestService(t, withWorkspaces("ws-1"), withReadOnly())
+	defer drainAllInFlight(svc)
+	seedAgent(t, svc, "agent-1", "ws-1")
+
+	for _, tc := range []struct {
+		method string
+		req    proto.Message
+	}{
+		{"SendAgentMessage", &leapmuxv1.SendAgentMessageRequest{AgentId: "agent-1", Content: "rm -rf /"}},
+		{"CloseAgent", &leapmuxv1.CloseAgentRequest{AgentId: "agent-1"}},
+		// The viewer acts as the owner, so its mark would clear the owner's
+		// unread counts.
+		{"MarkAgentRead", &leapmuxv1.MarkAgentReadRequest{AgentId: "agent-1"}},
+		// Owner-only: the viewer acts as the owner, so only the read-only
+		// gate stands between it and the owner's filesystem.
+		{"ListDirectory", &leapmuxv1.ListDirectoryRequest{Path: "/"}},
+		{"OpenTerminal", &leapmuxv1.OpenTerminalRequest{WorkspaceId: "ws-1"}},
+	} {
+		t.Run(tc.method, func(t *testing.T) {
+			w := newTestWriter()
+			dispatch(d, tc.method, tc.req, w)
+			rejections := w.rejections()
+			require.Len(t, rejections, 1)
+			assert.Equal(t, int32(codes.PermissionDenied), rejections[0].code)
+			assert.Empty(t, w.responses)
+		})
+	}
+}
+
+func TestReadOnlyChannel_AllowsReads(t *testing.T) {
+	svc, d, w := setupTestService(t, withWorkspaces("ws-1"), withReadOnly())
+	seedAgent(t, svc, "agent-1", "ws-1")
+
+	dispatch(d, "ListAgents", &leapmuxv1.ListAgentsRequest{TabIds: []string{"agent-1"}}, w)
+	assert.Empty(t, w.rejections())
+	require.Len(t, w.responses, 1)
+}
+
+func TestReadOnlyChannel_ListAgentMessagesWithholdsNotifications(t *testing.T) {
+	ctx := context.Background()
+	svc, d, w := setupTestService(t, withWorkspaces("ws-1"), withReadOnly())
+	seedAgent(t, svc, "agent-1", "ws-1")
+	for i, source := range []leapmuxv1.MessageSource{
+		leapmuxv1.MessageSource_MESSAGE_SOURCE_USER,
+		leapmuxv1.MessageSource_MESSAGE_SOURCE_LEAPMUX,
+		leapmuxv1.MessageSource_MESSAGE_SOURCE_AGENT,
+	} {
+		_, err := createMessageRow(ctx, svc.Queries, db.CreateMessageParams{
+			ID:            []string{"msg-user", "msg-leapmux", "msg-agent"}[i],
+			AgentID:       "agent-1",
+			Source:        source,
+			Content:       []byte("{}"),
+			AgentProvider: leapmuxv1.AgentProvider_AGENT_PROVIDER_CLAUDE_CODE,
+			CreatedAt:     sqltime.NewSQLiteTime(time.Now()),
+		})
+		require.NoError(t, err)
+	}
+
+	dispatch(d, "ListAgentMessages", &leapmuxv1.ListAgentMessagesRequest{AgentId: "agent-1"}, w)
+	require.Len(t, w.responses, 1)
+	var resp leapmuxv1.ListAgentMessagesResponse
+	require.NoError(t, proto.Unmarshal(w.responses[0].GetPayload(), &resp))
+	var ids []string
+	for _, m := range resp.GetMessages() {
+		ids = append(ids, m.GetId())
+	}
+	assert.Equal(t, []string{"msg-user", "msg-agent"}, ids)
+}
+
+func TestReadOnlyChannel_WatchEventsWithholdsSecretsBearingEvents(t *testing.T) {
+	svc, d, w := setupTestService(t, withWorkspaces("ws-1"), withReadOnly())
+	seedAgent(t, svc, "agent-1", "ws-1")
+	seedTerminal(t, svc, "term-1", "ws-1")
+
+	dispatch(d, "WatchEvents", &leapmuxv1.WatchEventsRequest{
+		Agents: []*leapmuxv1.WatchAgentEntry{
+			{AgentId: "agent-1", Replay: leapmuxv1.WatchReplayMode_WATCH_REPLAY_MODE_LATEST},
+		},
+		Terminals: []*leapmuxv1.WatchTerminalEntry{{TerminalId: "term-1"}},
+	}, w)
+	assert.Empty(t, w.rejections())
+
+	svc.Watchers.BroadcastTerminalEvent("term-1", &leapmuxv1.TerminalEvent{
+		TerminalId: "term-1",
+		Event:      &leapmuxv1.TerminalEvent_Data{Data: &leapmuxv1.TerminalData{Data: []byte("export TOKEN=secret")}},
+	})
+	before := len(w.streams)
+	for _, event := range []*leapmuxv1.AgentEvent{
+		{AgentId: "agent-1", Event: &leapmuxv1.AgentEvent_ControlRequest{ControlRequest: &leapmuxv1.AgentControlRequest{}}},
+		{AgentId: "agent-1", Event: &leapmuxv1.AgentEvent_AgentMessage{AgentMessage: &leapmuxv1.AgentChatMessage{
+			Source: leapmuxv1.MessageSource_MESSAGE_SOURCE_LEAPMUX, Seq: 1,
+		}}},
+	} {
+		svc.Watchers.BroadcastAgentEvent("agent-1", event)
+	}
+	assert.Len(t, w.streams, before, "a read-only channel must not receive terminal output, control requests or platform notifications")
+
+	svc.Watchers.BroadcastAgentEvent("agent-1", &leapmuxv1.AgentEvent{
+		AgentId: "agent-1",
+		Event: &leapmuxv1.AgentEvent_AgentMessage{AgentMessage: &leapmuxv1.AgentChatMessage{
+			Source: leapmuxv1.MessageSource_MESSAGE_SOURCE_AGENT, Seq: 2,
+		}},
+	})
+	assert.Len(t, w.streams, before+1, "a read-only channel still follows the conversation")
+}
+
+func TestReadOnlyWithholds(t *testing.T) {
+	agentEvent := func(e *leapmuxv1.AgentEvent) *leapmuxv1.WatchEventsResponse {
+		return &leapmuxv1.WatchEventsResponse{Event: &leapmuxv1.WatchEventsResponse_AgentEvent{AgentEvent: e}}
+	}
+	for _, tc := range []struct {
+		name     string
+		resp     *leapmuxv1.WatchEventsResponse
+		withheld bool
+	}{
+		{"terminal", &leapmuxv1.WatchEventsResponse{Event: &leapmuxv1.WatchEventsResponse_TerminalEvent{TerminalEvent: &leapmuxv1.TerminalEvent{}}}, true},
+		{"control request", agentEvent(&leapmuxv1.AgentEvent{Event: &leapmuxv1.AgentEvent_ControlRequest{ControlRequest: &leapmuxv1.AgentControlRequest{}}}), true},
+		{"control cancel", agentEvent(&leapmuxv1.AgentEvent{Event: &leapmuxv1.AgentEvent_ControlCancel{ControlCancel: &leapmuxv1.AgentControlCancelRequest{}}}), true},
+		{"leapmux notification", agentEvent(&leapmuxv1.AgentEvent{Event: &leapmuxv1.AgentEvent_AgentMessage{AgentMessage: &leapmuxv1.AgentChatMessage{Source: leapmuxv1.MessageSource_MESSAGE_SOURCE_LEAPMUX}}}), true},
+		{"agent message", agentEvent(&leapmuxv1.AgentEvent{Event: &leapmuxv1.AgentEvent_AgentMessage{AgentMessage: &leapmuxv1.AgentChatMessage{Source: leapmuxv1.MessageSource_MESSAGE_SOURCE_AGENT}}}), false},
+		{"stream chunk", agentEvent(&leapmuxv1.AgentEvent{Event: &leapmuxv1.AgentEvent_StreamChunk{StreamChunk: &leapmuxv1.AgentStreamChunk{}}}), false},
+		{"status change", agentEvent(&leapmuxv1.AgentEvent{Event: &leapmuxv1.AgentEvent_StatusChange{StatusChange: &leapmuxv1.AgentStatusChange{}}}), false},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			assert.Equal(t, tc.withheld, readOnlyWithholds(tc.resp))
+		})
+	}
+}
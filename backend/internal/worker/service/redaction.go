@@ -0,0 +1,63 @@
+package service
+
+import (
+	"log/slog"
+
+	"github.com/leapmux/leapmux/internal/worker/agent"
+)
+
+// wireRedaction puts the Redactor at the head of the output processor
+// chain, so the provider, and through it the database and every watcher,
+// and the operator's plugins only ever see agent output with its secrets
+// replaced.
+func (svc *Service) wireRedaction() {
+	if svc.Redactor == nil {
+		return
+	}
+	svc.AddOutputProcessor(agent.OutputProcessorFunc(svc.redactOutput))
+}
+
+// redactOutput replaces the secrets in one line of agent output and
+// leaves a secrets_redacted notice in the chat when it found any. It
+// never consumes the line.
+func (svc *Service) redactOutput(line *agent.OutputLine, _ agent.OutputSink) bool {
+	if redactionSkips(line) {
+		return false
+	}
+	out, res, err := svc.Redactor.Redact(line.Raw)
+	if err != nil {
+		slog.Warn("failed to redact agent output", "agent_id", line.AgentID, "error", err)
+		return false
+	}
+	if res.Total() == 0 {
+		return false
+	}
+	line.Raw = out
+	slog.Info("redacted secrets in agent output",
+		"agent_id", line.AgentID, "count", res.Total(), "detectors", res.Detectors())
+	svc.Output.PersistLeapMuxNotification(line.AgentID, line.Provider, agent.NotificationContent(agent.SecretsRedactedPayload{
+		Count:     res.Total(),
+		Detectors: res.Detectors(),
+	}))
+	return false
+}
+
+// redactionSkips reports whether redactOutput leaves line as the agent
+// wrote it: a request the agent waits on carries the tool input its
+// answer echoes back, and rewriting it would change what the tool runs.
+func redactionSkips(line *agent.OutputLine) bool {
+	return line.Type == "control_request" || line.IsRequest()
+}
+
+// redactForPlugins returns raw with its secrets replaced, for a plugin
+// event about output redactOutput left alone: plugins never answer the
+// request, so they get the redacted copy. ok is false when raw could not
+// be redacted, and the event must not be sent.
+func (svc *Service) redactForPlugins(agentID string, raw []byte) (out []byte, ok bool) {
+	out, _, err := svc.Redactor.Redact(raw)
+	if err != nil {
+		slog.Warn("failed to redact plugin event, not sending it", "agent_id", agentID, "error", err)
+		return nil, false
+	}
+	return out, true
+}
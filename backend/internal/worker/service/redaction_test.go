@@ -0,0 +1,43 @@
+package service
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/leapmux/leapmux/internal/worker/agent"
+	"github.com/leapmux/leapmux/internal/worker/redact"
+)
+
+func TestRedactOutput(t *testing.T) {
+	svc, _, _ := setupTestService(t, withWorkspaces("ws-1"))
+	svc.Redactor = redact.New(redact.Defaults()...)
+	seedGuardedAgent(t, svc, "")
+	key := "ghp_" + strings.Repeat("a1B2", 9)
+
+	line := &agent.OutputLine{
+		AgentID:  "agent-1",
+		Provider: claudeProvider,
+		Type:     "user",
+		Raw:      []byte(`{"type":"user","message":{"content":[{"type":"tool_result","content":"GH_TOKEN=` + key + `\nHOME=/root"}]}}`),
+	}
+	assert.False(t, svc.redactOutput(line, nil), "the line is rewritten, not consumed")
+	assert.NotContains(t, string(line.Raw), key)
+	assert.Contains(t, string(line.Raw), `GH_TOKEN=[REDACTED:github-token]\nHOME=/root`)
+
+	redacted := findNotificationsByType(readAllNotifications(t, svc.Queries, "agent-1"), agent.NotificationTypeSecretsRedacted)
+	require.Len(t, redacted, 1)
+	assert.EqualValues(t, 1, redacted[0]["count"])
+	assert.Equal(t, []any{"github-token"}, redacted[0]["detectors"])
+
+	// A permission request keeps the input its answer echoes back.
+	request := `{"type":"control_request","request_id":"r1","request":{"subtype":"can_use_tool","tool_name":"Bash","input":{"command":"gh auth login --with-token ` + key + `"}}}`
+	line = &agent.OutputLine{AgentID: "agent-1", Provider: claudeProvider, Type: "control_request", Raw: []byte(request)}
+	assert.False(t, svc.redactOutput(line, nil))
+	assert.Equal(t, request, string(line.Raw))
+	line = &agent.OutputLine{AgentID: "agent-1", Method: "session/request_permission", ID: []byte(`7`), Raw: []byte(`{"jsonrpc":"2.0","id":7,"method":"session/request_permission","params":{"token":"` + key + `"}}`)}
+	assert.False(t, svc.redactOutput(line, nil))
+	assert.Contains(t, string(line.Raw), key)
+}
@@ -107,6 +107,7 @@ func (r registrar) register(method string, gate methodGate, mode dispatchMode, h
 	}
 	r.gates[method] = gate
 	r.shapes[method] = shape
+	handler = r.readOnlyGate(method, shape, handler)
 
 	switch mode {
 	case dispatchTracked:
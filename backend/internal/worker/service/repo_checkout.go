@@ -0,0 +1,263 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	leapmuxv1 "github.com/leapmux/leapmux/generated/proto/leapmux/v1"
+	db "github.com/leapmux/leapmux/internal/worker/generated/db"
+	"github.com/leapmux/leapmux/internal/worker/gitutil"
+)
+
+const (
+	// repoCheckoutsDirName is the directory under the worker's home that
+	// holds one subdirectory per workspace checked out from a registered
+	// repository.
+	repoCheckoutsDirName = "leapmux-checkouts"
+	// repoCacheDirName is the directory under the data dir that holds a
+	// bare mirror per repository, so a second checkout only fetches what
+	// changed since the first.
+	repoCacheDirName = "repo-cache"
+	// repoCloneTimeout bounds one checkout, mirror update included.
+	repoCloneTimeout = 30 * time.Minute
+)
+
+// repoCheckout is a clone in progress (or finished) into dir. done closes
+// once err is final; req is kept to retry a failed clone.
+type repoCheckout struct {
+	dir  string
+	req  *leapmuxv1.PrepareRepoCheckout
+	done chan struct{}
+	err  error
+}
+
+// failed reports whether the clone has finished with an error.
+func (c *repoCheckout) failed() bool {
+	select {
+	case <-c.done:
+		return c.err != nil
+	default:
+		return false
+	}
+}
+
+// PrepareRepoCheckout answers the Hub's request for a workspace's checkout
+// of a registered repository. It creates the checkout directory and
+// answers at once; the clone runs in the background, and an agent opened
+// in the directory waits for it (see awaitRepoCheckout).
+func (svc *Service) PrepareRepoCheckout(req *leapmuxv1.PrepareRepoCheckout) *leapmuxv1.PrepareRepoCheckoutResponse {
+	dir, err := svc.startRepoCheckout(req)
+	if err != nil {
+		slog.Warn("failed to prepare repo checkout", "workspace_id", req.GetWorkspaceId(), "repo_id", req.GetRepoId(), "error", err)
+		return &leapmuxv1.PrepareRepoCheckoutResponse{Error: err.Error()}
+	}
+	return &leapmuxv1.PrepareRepoCheckoutResponse{CheckoutDir: dir}
+}
+
+func (svc *Service) startRepoCheckout(req *leapmuxv1.PrepareRepoCheckout) (string, error) {
+	for _, part := range []string{req.GetWorkspaceId(), req.GetRepoId(), req.GetName()} {
+		if !isPlainPathComponent(part) {
+			return "", fmt.Errorf("invalid checkout path component %q", part)
+		}
+	}
+	if req.GetUrl() == "" {
+		return "", errors.New("repository URL is required")
+	}
+	if branch := req.GetBranch(); branch != "" {
+		if err := gitutil.ValidateBranchName(branch); err != nil {
+			return "", err
+		}
+	}
+	base := svc.repoCheckoutBase()
+	if base == "" {
+		return "", errors.New("worker has no home directory to check out into")
+	}
+	cfg, err := svc.installRepoCredential(req)
+	if err != nil {
+		return "", err
+	}
+
+	dir := filepath.Join(base, repoCheckoutsDirName, req.GetWorkspaceId(), req.GetName())
+	job := &repoCheckout{dir: dir, req: req, done: make(chan struct{})}
+	if v, loaded := svc.repoCheckouts.LoadOrStore(dir, job); loaded {
+		// A retried request for the same checkout waits for the first
+		// one's clone, unless that clone failed.
+		if prev := v.(*repoCheckout); !prev.failed() || !svc.repoCheckouts.CompareAndSwap(dir, prev, job) {
+			return dir, nil
+		}
+	}
+	if entries, err := os.ReadDir(dir); err == nil && len(entries) > 0 {
+		// Already checked out, e.g. before a worker restart.
+		close(job.done)
+		if len(cfg) > 0 {
+			ctx, cancel := context.WithTimeout(bgCtx(), time.Minute)
+			defer cancel()
+			if err := applyRepoCredentialConfig(ctx, dir, cfg); err != nil {
+				slog.Warn("failed to update checkout credentials", "dir", dir, "error", err)
+			}
+		}
+		return dir, nil
+	}
+	if err := svc.checkDiskRoom(req.GetWorkspaceId()); err != nil {
+		svc.repoCheckouts.Delete(dir)
+		return "", err
+	}
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		svc.repoCheckouts.Delete(dir)
+		return "", fmt.Errorf("create checkout directory: %w", err)
+	}
+
+	go func() {
+		ctx, cancel := context.WithTimeout(bgCtx(), repoCloneTimeout)
+		defer cancel()
+		job.err = svc.cloneRepo(ctx, req, dir, cfg)
+		if job.err != nil {
+			slog.Warn("repo checkout failed", "workspace_id", req.GetWorkspaceId(), "repo_id", req.GetRepoId(), "dir", dir, "error", job.err)
+		}
+		close(job.done)
+	}()
+	return dir, nil
+}
+
+// cloneRepo clones req's repository into the empty directory dir, borrowing
+// objects from the repository's mirror when one can be brought up to date.
+// The mirror is a cache only: failing to update it costs a full clone, not
+// the checkout. cfg, the credential's configuration, applies to both and
+// stays in the checkout's own configuration for later fetches and pushes.
+func (svc *Service) cloneRepo(ctx context.Context, req *leapmuxv1.PrepareRepoCheckout, dir string, cfg []gitConfigEntry) error {
+	args := append(gitConfigArgs(cfg), "clone")
+	if cache, err := svc.updateRepoCache(ctx, req, cfg); err != nil {
+		slog.Debug("repo cache unavailable", "repo_id", req.GetRepoId(), "error", err)
+	} else {
+		args = append(args, "--reference-if-able", cache, "--dissociate")
+	}
+	if branch := req.GetBranch(); branch != "" {
+		args = append(args, "--branch", branch)
+	}
+	args = append(args, "--", req.GetUrl(), dir)
+	if err := gitutil.Run(ctx, filepath.Dir(dir), args...); err != nil {
+		// Leave the directory empty so a retry can clone into it again.
+		cleanupErr := emptyDir(dir)
+		return errors.Join(fmt.Errorf("clone %s: %w", req.GetUrl(), err), cleanupErr)
+	}
+	if len(cfg) > 0 {
+		if err := applyRepoCredentialConfig(ctx, dir, cfg); err != nil {
+			return fmt.Errorf("configure checkout credentials: %w", err)
+		}
+	}
+	return nil
+}
+
+// updateRepoCache creates or fetches the bare mirror of req's repository
+// and returns its path. Calls for one repository are serialized.
+func (svc *Service) updateRepoCache(ctx context.Context, req *leapmuxv1.PrepareRepoCheckout, cfg []gitConfigEntry) (string, error) {
+	if svc.DataDir == "" {
+		return "", errors.New("no data directory")
+	}
+	v, _ := svc.repoCacheLocks.LoadOrStore(req.GetRepoId(), &sync.Mutex{})
+	lock := v.(*sync.Mutex)
+	lock.Lock()
+	defer lock.Unlock()
+
+	cacheDir := filepath.Join(svc.DataDir, repoCacheDirName)
+	cache := filepath.Join(cacheDir, req.GetRepoId()+".git")
+	if _, err := os.Stat(cache); err == nil {
+		if err := gitutil.Run(ctx, cache, "remote", "set-url", "origin", req.GetUrl()); err != nil {
+			return "", err
+		}
+		if err := gitutil.Run(ctx, cache, append(gitConfigArgs(cfg), "fetch", "--prune", "origin")...); err != nil {
+			return "", err
+		}
+		return cache, nil
+	}
+	if err := os.MkdirAll(cacheDir, 0o700); err != nil {
+		return "", err
+	}
+	if err := gitutil.Run(ctx, cacheDir, append(gitConfigArgs(cfg), "clone", "--mirror", "--", req.GetUrl(), cache)...); err != nil {
+		_ = os.RemoveAll(cache)
+		return "", err
+	}
+	return cache, nil
+}
+
+// awaitRepoCheckout blocks until any checkout being cloned into workingDir,
+// or a directory containing it, has finished, and returns the clone's
+// error. A clone that failed earlier is started again first. A directory
+// no checkout covers returns at once.
+func (svc *Service) awaitRepoCheckout(ctx context.Context, dbAgent *db.Agent, workingDir string) error {
+	job := svc.repoCheckoutFor(workingDir)
+	if job == nil {
+		return nil
+	}
+	if job.failed() {
+		if _, err := svc.startRepoCheckout(job.req); err != nil {
+			return err
+		}
+		job = svc.repoCheckoutFor(workingDir)
+	}
+	select {
+	case <-job.done:
+		return job.err
+	default:
+	}
+
+	label := "Cloning repository…"
+	svc.AgentStartup.setMessage(dbAgent.ID, label)
+	svc.broadcastAgentStarting(dbAgent, label, nil)
+	select {
+	case <-job.done:
+		return job.err
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// repoCheckoutFor returns the checkout covering dir, or nil.
+func (svc *Service) repoCheckoutFor(dir string) *repoCheckout {
+	var job *repoCheckout
+	svc.repoCheckouts.Range(func(_, v any) bool {
+		c := v.(*repoCheckout)
+		if dir == c.dir || strings.HasPrefix(dir, c.dir+string(filepath.Separator)) {
+			job = c
+			return false
+		}
+		return true
+	})
+	return job
+}
+
+// repoCheckoutBase is the directory registered-repository checkouts go
+// under: the worker's home directory, else its data directory. Empty when
+// the worker has neither.
+func (svc *Service) repoCheckoutBase() string {
+	if svc.HomeDir != "" {
+		return svc.HomeDir
+	}
+	return svc.DataDir
+}
+
+// isPlainPathComponent reports whether s can be used as a single path
+// element without escaping its parent.
+func isPlainPathComponent(s string) bool {
+	return s != "" && s != "." && s != ".." && !strings.ContainsAny(s, `/\`) && filepath.Base(s) == s
+}
+
+// emptyDir removes everything inside dir, keeping dir itself.
+func emptyDir(dir string) error {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return err
+	}
+	var errs []error
+	for _, e := range entries {
+		errs = append(errs, os.RemoveAll(filepath.Join(dir, e.Name())))
+	}
+	return errors.Join(errs...)
+}
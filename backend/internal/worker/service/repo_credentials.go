@@ -0,0 +1,137 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+
+	leapmuxv1 "github.com/leapmux/leapmux/generated/proto/leapmux/v1"
+	"github.com/leapmux/leapmux/internal/util/atomicfile"
+	"github.com/leapmux/leapmux/internal/worker/gitutil"
+)
+
+// repoCredentialsDirName is the directory under the data dir that holds one
+// subdirectory per git credential the Hub has delivered, readable only by
+// the worker's user.
+const repoCredentialsDirName = "git-credentials"
+
+// gitConfigEntry is one git configuration key and value. Entries are
+// applied in order, so an empty value may reset a multi-valued key before
+// the next entry adds to it.
+type gitConfigEntry struct {
+	key, value string
+}
+
+// installRepoCredential writes req's credential where git can read it and
+// returns the configuration that points git at it. The file is named by
+// credential id, so a rotated secret replaces the old one for every
+// checkout using the credential. A request without a credential returns
+// no entries.
+func (svc *Service) installRepoCredential(req *leapmuxv1.PrepareRepoCheckout) ([]gitConfigEntry, error) {
+	cred := req.GetCredential()
+	if cred == nil {
+		return nil, nil
+	}
+	if !isPlainPathComponent(cred.GetId()) {
+		return nil, fmt.Errorf("invalid credential id %q", cred.GetId())
+	}
+	if svc.DataDir == "" {
+		return nil, errors.New("worker has no data directory to keep git credentials in")
+	}
+	dir := filepath.Join(svc.DataDir, repoCredentialsDirName, cred.GetId())
+
+	switch cred.GetKind() {
+	case leapmuxv1.GitCredentialKind_GIT_CREDENTIAL_KIND_SSH_KEY:
+		if isHTTPRepoURL(req.GetUrl()) {
+			return nil, errors.New("an SSH key credential needs an ssh or user@host:path URL")
+		}
+		secret := cred.GetSecret()
+		if !strings.HasSuffix(secret, "\n") {
+			// ssh rejects a key file without its final newline.
+			secret += "\n"
+		}
+		keyPath := filepath.Join(dir, "id")
+		if err := svc.writePrivateFile(keyPath, []byte(secret)); err != nil {
+			return nil, fmt.Errorf("write ssh key: %w", err)
+		}
+		return []gitConfigEntry{{
+			key: "core.sshCommand",
+			// accept-new: the worker has no one to ask about a host it has
+			// never seen, but a changed key still fails the connection.
+			value: "ssh -i " + shellQuote(keyPath) + " -o IdentitiesOnly=yes -o StrictHostKeyChecking=accept-new",
+		}}, nil
+
+	case leapmuxv1.GitCredentialKind_GIT_CREDENTIAL_KIND_HTTPS_TOKEN:
+		// Never http://: git would send the token in cleartext.
+		u, err := url.Parse(req.GetUrl())
+		if err != nil || u.Scheme != "https" || u.Host == "" {
+			return nil, errors.New("a token credential needs an https URL")
+		}
+		entry := url.URL{Scheme: u.Scheme, Host: u.Host, User: url.UserPassword(cred.GetUsername(), cred.GetSecret())}
+		storePath := filepath.Join(dir, "store")
+		if err := svc.writePrivateFile(storePath, []byte(entry.String()+"\n")); err != nil {
+			return nil, fmt.Errorf("write token: %w", err)
+		}
+		return []gitConfigEntry{
+			// The empty value drops helpers configured for the user, so
+			// theirs cannot answer for this repository first.
+			{key: "credential.helper", value: ""},
+			{key: "credential.helper", value: "store --file=" + shellQuote(storePath)},
+		}, nil
+
+	default:
+		return nil, fmt.Errorf("unsupported credential kind %v", cred.GetKind())
+	}
+}
+
+// applyRepoCredentialConfig writes cfg into the repository at dir's own
+// configuration, replacing what an earlier delivery wrote. Worktrees of
+// the repository share it.
+func applyRepoCredentialConfig(ctx context.Context, dir string, cfg []gitConfigEntry) error {
+	for _, key := range []string{"core.sshCommand", "credential.helper"} {
+		// Exit status 5 means nothing was set; anything else surfaces
+		// through the writes below.
+		_ = gitutil.Run(ctx, dir, "config", "--local", "--unset-all", key)
+	}
+	for _, e := range cfg {
+		if err := gitutil.Run(ctx, dir, "config", "--local", "--add", e.key, e.value); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// gitConfigArgs renders cfg as git's leading -c options.
+func gitConfigArgs(cfg []gitConfigEntry) []string {
+	args := make([]string, 0, 2*len(cfg))
+	for _, e := range cfg {
+		args = append(args, "-c", e.key+"="+e.value)
+	}
+	return args
+}
+
+// isHTTPRepoURL reports whether raw is an http:// or https:// URL.
+func isHTTPRepoURL(raw string) bool {
+	return strings.HasPrefix(raw, "https://") || strings.HasPrefix(raw, "http://")
+}
+
+// writePrivateFile replaces path with data, readable only by the worker's
+// user. The rename keeps a concurrent git from reading a partial file.
+func (svc *Service) writePrivateFile(path string, data []byte) error {
+	svc.repoCredentialMu.Lock()
+	defer svc.repoCredentialMu.Unlock()
+	if err := os.MkdirAll(filepath.Dir(path), 0o700); err != nil {
+		return err
+	}
+	return atomicfile.WriteFile(path, data, 0o600)
+}
+
+// shellQuote quotes s for the POSIX shell git runs helpers and ssh
+// commands through (Git for Windows included).
+func shellQuote(s string) string {
+	return "'" + strings.ReplaceAll(s, "'", `'\''`) + "'"
+}
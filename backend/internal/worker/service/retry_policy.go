@@ -0,0 +1,237 @@
+package service
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"log/slog"
+	"math"
+	"slices"
+	"time"
+
+	leapmuxv1 "github.com/leapmux/leapmux/generated/proto/leapmux/v1"
+	"github.com/leapmux/leapmux/internal/util/userid"
+	"github.com/leapmux/leapmux/internal/worker/agent"
+	"github.com/leapmux/leapmux/internal/worker/channel"
+	db "github.com/leapmux/leapmux/internal/worker/generated/db"
+	"google.golang.org/protobuf/encoding/protojson"
+)
+
+const (
+	// maxRetryBackoff bounds every configured backoff so a typo'd policy
+	// cannot park an agent for days.
+	maxRetryBackoff = time.Hour
+	// maxRetryAttempts bounds max_attempts for the same reason.
+	maxRetryAttempts = 100
+)
+
+// retryRule is the resolved form of a leapmuxv1.RetryRule: every field is
+// concrete, with the built-in defaults filled in for anything the workspace
+// policy left zero.
+type retryRule struct {
+	InitialDelay  time.Duration
+	MaxDelay      time.Duration
+	Multiplier    float64
+	MaxAttempts   int64 // 0 = unlimited
+	FallbackModel string
+}
+
+// defaultRetryRule is the schedule auto-continue used before policies were
+// configurable: exponential backoff for API errors, unlimited attempts, no
+// fallback.
+func defaultRetryRule() retryRule {
+	return retryRule{
+		InitialDelay: autoContinueInitialDelay,
+		MaxDelay:     autoContinueMaxDelay,
+		Multiplier:   autoContinueMultiplier,
+	}
+}
+
+// delayForAttempt returns the backoff before the given 1-based attempt,
+// capped at MaxDelay.
+func (r retryRule) delayForAttempt(attempt int64) time.Duration {
+	if attempt < 1 {
+		attempt = 1
+	}
+	delay := float64(r.InitialDelay) * math.Pow(r.Multiplier, float64(attempt-1))
+	if delay > float64(r.MaxDelay) || math.IsInf(delay, 0) || math.IsNaN(delay) {
+		return r.MaxDelay
+	}
+	return time.Duration(delay)
+}
+
+// exhausted reports whether the given 1-based attempt exceeds the rule's
+// attempt budget.
+func (r retryRule) exhausted(attempt int64) bool {
+	return r.MaxAttempts > 0 && attempt > r.MaxAttempts
+}
+
+// retryConditionFor maps an auto-continue reason onto the policy condition
+// that governs it.
+func retryConditionFor(reason agent.AutoContinueReason) leapmuxv1.RetryCondition {
+	switch reason {
+	case agent.AutoContinueReasonAPIError:
+		return leapmuxv1.RetryCondition_RETRY_CONDITION_API_ERROR
+	case agent.AutoContinueReasonRateLimit:
+		return leapmuxv1.RetryCondition_RETRY_CONDITION_RATE_LIMIT
+	default:
+		return leapmuxv1.RetryCondition_RETRY_CONDITION_UNSPECIFIED
+	}
+}
+
+// resolveRetryRule overlays the policy's rule for condition (if any) onto
+// the defaults.
+func resolveRetryRule(policy *leapmuxv1.RetryPolicy, condition leapmuxv1.RetryCondition) retryRule {
+	rule := defaultRetryRule()
+	for _, r := range policy.GetRules() {
+		if r.GetCondition() != condition {
+			continue
+		}
+		if v := r.GetInitialBackoffMs(); v > 0 {
+			rule.InitialDelay = time.Duration(v) * time.Millisecond
+		}
+		if v := r.GetMaxBackoffMs(); v > 0 {
+			rule.MaxDelay = time.Duration(v) * time.Millisecond
+		}
+		if v := r.GetMultiplier(); v > 0 {
+			rule.Multiplier = v
+		}
+		rule.MaxAttempts = int64(r.GetMaxAttempts())
+		rule.FallbackModel = r.GetFallbackModel()
+		break
+	}
+	if rule.MaxDelay < rule.InitialDelay {
+		rule.MaxDelay = rule.InitialDelay
+	}
+	return rule
+}
+
+// validateRetryPolicy rejects a policy the engine could not apply as
+// written. Zero fields are valid (they mean "use the default"); negative or
+// out-of-range ones are not, and neither is a second rule for a condition
+// already covered -- resolveRetryRule would silently ignore it.
+func validateRetryPolicy(policy *leapmuxv1.RetryPolicy) error {
+	seen := make(map[leapmuxv1.RetryCondition]bool)
+	for _, r := range policy.GetRules() {
+		cond := r.GetCondition()
+		if _, ok := leapmuxv1.RetryCondition_name[int32(cond)]; !ok || cond == leapmuxv1.RetryCondition_RETRY_CONDITION_UNSPECIFIED {
+			return errors.New("retry rule condition is required")
+		}
+		if seen[cond] {
+			return fmt.Errorf("duplicate retry rule for %s", cond)
+		}
+		seen[cond] = true
+		if r.GetInitialBackoffMs() < 0 || r.GetMaxBackoffMs() < 0 {
+			return errors.New("retry backoff must not be negative")
+		}
+		if r.GetInitialBackoffMs() > maxRetryBackoff.Milliseconds() || r.GetMaxBackoffMs() > maxRetryBackoff.Milliseconds() {
+			return fmt.Errorf("retry backoff must not exceed %s", maxRetryBackoff)
+		}
+		if m := r.GetMultiplier(); m != 0 && (m < 1 || math.IsInf(m, 0) || math.IsNaN(m)) {
+			return errors.New("retry multiplier must be at least 1")
+		}
+		if r.GetMaxAttempts() < 0 || r.GetMaxAttempts() > maxRetryAttempts {
+			return fmt.Errorf("retry max_attempts must be between 0 and %d", maxRetryAttempts)
+		}
+	}
+	return nil
+}
+
+// loadWorkspaceRetryPolicy reads the workspace's stored policy. A workspace
+// with no row yields an empty policy (all defaults), not an error.
+func loadWorkspaceRetryPolicy(ctx context.Context, queries *db.Queries, workspaceID string) (*leapmuxv1.RetryPolicy, error) {
+	raw, err := queries.GetWorkspaceRetryPolicy(ctx, workspaceID)
+	if errors.Is(err, sql.ErrNoRows) {
+		return &leapmuxv1.RetryPolicy{}, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	policy := &leapmuxv1.RetryPolicy{}
+	if err := protojson.Unmarshal([]byte(raw), policy); err != nil {
+		return nil, fmt.Errorf("decode retry policy: %w", err)
+	}
+	return policy, nil
+}
+
+// overlayRetryPolicy returns base with each of top's rules in place of
+// base's rule for the same condition.
+func overlayRetryPolicy(base, top *leapmuxv1.RetryPolicy) *leapmuxv1.RetryPolicy {
+	if len(top.GetRules()) == 0 {
+		return base
+	}
+	out := &leapmuxv1.RetryPolicy{Rules: top.GetRules()}
+	for _, r := range base.GetRules() {
+		if !slices.ContainsFunc(top.GetRules(), func(t *leapmuxv1.RetryRule) bool { return t.GetCondition() == r.GetCondition() }) {
+			out.Rules = append(out.Rules, r)
+		}
+	}
+	return out
+}
+
+// retryRuleForAgent resolves the rule governing agentRow's retries for
+// reason: the org's rule for it if there is one, else the workspace's. A
+// workspace policy that fails to load degrades to the defaults rather than
+// disabling auto-continue.
+func (h *OutputHandler) retryRuleForAgent(agentRow db.Agent, reason agent.AutoContinueReason) retryRule {
+	var org *leapmuxv1.RetryPolicy
+	if h.orgRetryPolicy != nil {
+		org = h.orgRetryPolicy()
+	}
+	policy, err := loadWorkspaceRetryPolicy(bgCtx(), h.queries, agentRow.WorkspaceID)
+	if err != nil {
+		slog.Warn("retry policy load failed; using defaults",
+			"agent_id", agentRow.ID, "workspace_id", agentRow.WorkspaceID, "error", err)
+		policy = &leapmuxv1.RetryPolicy{}
+	}
+	return resolveRetryRule(overlayRetryPolicy(policy, org), retryConditionFor(reason))
+}
+
+// registerRetryPolicyHandlers registers the per-workspace retry policy RPCs.
+func registerRetryPolicyHandlers(d registrar, svc *Service) {
+	registerWorkspaceGated(d, "GetWorkspaceRetryPolicy",
+		func(ctx context.Context, _ userid.UserID, r *leapmuxv1.GetWorkspaceRetryPolicyRequest, sender channel.ResponseWriter) {
+			policy, err := loadWorkspaceRetryPolicy(ctx, svc.Queries, r.GetWorkspaceId())
+			if err != nil {
+				slog.Error("failed to load retry policy", "workspace_id", r.GetWorkspaceId(), "error", err)
+				sendInternalError(sender, "failed to load retry policy")
+				return
+			}
+			sendProtoResponse(sender, &leapmuxv1.GetWorkspaceRetryPolicyResponse{Policy: policy})
+		})
+
+	registerWorkspaceGated(d, "SetWorkspaceRetryPolicy",
+		func(_ context.Context, _ userid.UserID, r *leapmuxv1.SetWorkspaceRetryPolicyRequest, sender channel.ResponseWriter) {
+			policy := r.GetPolicy()
+			if policy == nil {
+				policy = &leapmuxv1.RetryPolicy{}
+			}
+			if err := validateRetryPolicy(policy); err != nil {
+				sendInvalidArgument(sender, err.Error())
+				return
+			}
+
+			// An empty policy is the defaults; drop the row rather than
+			// storing a policy that says nothing.
+			var err error
+			if len(policy.GetRules()) == 0 {
+				err = svc.Queries.DeleteWorkspaceRetryPolicy(bgCtx(), r.GetWorkspaceId())
+			} else {
+				var raw []byte
+				raw, err = protojson.Marshal(policy)
+				if err == nil {
+					err = svc.Queries.UpsertWorkspaceRetryPolicy(bgCtx(), db.UpsertWorkspaceRetryPolicyParams{
+						WorkspaceID: r.GetWorkspaceId(),
+						Policy:      string(raw),
+					})
+				}
+			}
+			if err != nil {
+				slog.Error("failed to save retry policy", "workspace_id", r.GetWorkspaceId(), "error", err)
+				sendInternalError(sender, "failed to save retry policy")
+				return
+			}
+			sendProtoResponse(sender, &leapmuxv1.SetWorkspaceRetryPolicyResponse{Policy: policy})
+		})
+}
@@ -0,0 +1,251 @@
+package service
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/protobuf/encoding/protojson"
+	"google.golang.org/protobuf/proto"
+
+	leapmuxv1 "github.com/leapmux/leapmux/generated/proto/leapmux/v1"
+	"github.com/leapmux/leapmux/internal/worker/agent"
+	db "github.com/leapmux/leapmux/internal/worker/generated/db"
+)
+
+func setWorkspaceRetryPolicy(t *testing.T, queries *db.Queries, workspaceID string, rules ...*leapmuxv1.RetryRule) {
+	t.Helper()
+	raw, err := protojson.Marshal(&leapmuxv1.RetryPolicy{Rules: rules})
+	require.NoError(t, err)
+	require.NoError(t, queries.UpsertWorkspaceRetryPolicy(bgCtx(), db.UpsertWorkspaceRetryPolicyParams{
+		WorkspaceID: workspaceID,
+		Policy:      string(raw),
+	}))
+}
+
+func TestResolveRetryRule_DefaultsWithoutPolicy(t *testing.T) {
+	rule := resolveRetryRule(&leapmuxv1.RetryPolicy{}, leapmuxv1.RetryCondition_RETRY_CONDITION_API_ERROR)
+	assert.Equal(t, defaultRetryRule(), rule)
+	assert.False(t, rule.exhausted(1000), "default rule retries without limit")
+}
+
+func TestResolveRetryRule_OverlaysMatchingCondition(t *testing.T) {
+	policy := &leapmuxv1.RetryPolicy{Rules: []*leapmuxv1.RetryRule{
+		{Condition: leapmuxv1.RetryCondition_RETRY_CONDITION_RATE_LIMIT, MaxAttempts: 9},
+		{
+			Condition:        leapmuxv1.RetryCondition_RETRY_CONDITION_API_ERROR,
+			InitialBackoffMs: 2000,
+			MaxBackoffMs:     10000,
+			MaxAttempts:      3,
+			FallbackModel:    "sonnet",
+		},
+	}}
+
+	rule := resolveRetryRule(policy, leapmuxv1.RetryCondition_RETRY_CONDITION_API_ERROR)
+	assert.Equal(t, 2*time.Second, rule.InitialDelay)
+	assert.Equal(t, 10*time.Second, rule.MaxDelay)
+	assert.Equal(t, autoContinueMultiplier, rule.Multiplier, "zero multiplier keeps the default")
+	assert.Equal(t, int64(3), rule.MaxAttempts)
+	assert.Equal(t, "sonnet", rule.FallbackModel)
+}
+
+func TestResolveRetryRule_MaxBelowInitialIsRaised(t *testing.T) {
+	policy := &leapmuxv1.RetryPolicy{Rules: []*leapmuxv1.RetryRule{{
+		Condition:        leapmuxv1.RetryCondition_RETRY_CONDITION_API_ERROR,
+		InitialBackoffMs: 5000,
+		MaxBackoffMs:     1000,
+	}}}
+	rule := resolveRetryRule(policy, leapmuxv1.RetryCondition_RETRY_CONDITION_API_ERROR)
+	assert.Equal(t, rule.InitialDelay, rule.MaxDelay)
+}
+
+func TestRetryRuleForAgent_OrgRuleOverridesWorkspace(t *testing.T) {
+	_, queries := setupTestDB(t)
+	h := NewOutputHandler(nil, queries, nil, nil, nil)
+	setWorkspaceRetryPolicy(t, queries, "ws-1",
+		&leapmuxv1.RetryRule{Condition: leapmuxv1.RetryCondition_RETRY_CONDITION_API_ERROR, MaxAttempts: 3},
+		&leapmuxv1.RetryRule{Condition: leapmuxv1.RetryCondition_RETRY_CONDITION_RATE_LIMIT, MaxAttempts: 4},
+	)
+	h.SetOrgRetryPolicyFunc(func() *leapmuxv1.RetryPolicy {
+		return &leapmuxv1.RetryPolicy{Rules: []*leapmuxv1.RetryRule{
+			{Condition: leapmuxv1.RetryCondition_RETRY_CONDITION_API_ERROR, MaxAttempts: 7},
+		}}
+	})
+
+	row := db.Agent{ID: "agent-1", WorkspaceID: "ws-1"}
+	assert.Equal(t, int64(7), h.retryRuleForAgent(row, agent.AutoContinueReasonAPIError).MaxAttempts)
+	assert.Equal(t, int64(4), h.retryRuleForAgent(row, agent.AutoContinueReasonRateLimit).MaxAttempts,
+		"a condition the org leaves alone keeps the workspace rule")
+}
+
+func TestRetryRule_DelayForAttempt(t *testing.T) {
+	rule := retryRule{InitialDelay: time.Second, MaxDelay: 5 * time.Second, Multiplier: 2}
+	assert.Equal(t, time.Second, rule.delayForAttempt(0))
+	assert.Equal(t, time.Second, rule.delayForAttempt(1))
+	assert.Equal(t, 2*time.Second, rule.delayForAttempt(2))
+	assert.Equal(t, 4*time.Second, rule.delayForAttempt(3))
+	assert.Equal(t, 5*time.Second, rule.delayForAttempt(4))
+	assert.Equal(t, 5*time.Second, rule.delayForAttempt(10000))
+}
+
+func TestValidateRetryPolicy(t *testing.T) {
+	apiErr := leapmuxv1.RetryCondition_RETRY_CONDITION_API_ERROR
+	tests := []struct {
+		name    string
+		rules   []*leapmuxv1.RetryRule
+		wantErr bool
+	}{
+		{"empty", nil, false},
+		{"zero fields", []*leapmuxv1.RetryRule{{Condition: apiErr}}, false},
+		{"unspecified condition", []*leapmuxv1.RetryRule{{}}, true},
+		{"unknown condition", []*leapmuxv1.RetryRule{{Condition: 99}}, true},
+		{"duplicate condition", []*leapmuxv1.RetryRule{{Condition: apiErr}, {Condition: apiErr}}, true},
+		{"negative backoff", []*leapmuxv1.RetryRule{{Condition: apiErr, InitialBackoffMs: -1}}, true},
+		{"backoff too large", []*leapmuxv1.RetryRule{{Condition: apiErr, MaxBackoffMs: maxRetryBackoff.Milliseconds() + 1}}, true},
+		{"multiplier below one", []*leapmuxv1.RetryRule{{Condition: apiErr, Multiplier: 0.5}}, true},
+		{"negative attempts", []*leapmuxv1.RetryRule{{Condition: apiErr, MaxAttempts: -1}}, true},
+		{"too many attempts", []*leapmuxv1.RetryRule{{Condition: apiErr, MaxAttempts: maxRetryAttempts + 1}}, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := validateRetryPolicy(&leapmuxv1.RetryPolicy{Rules: tt.rules})
+			if tt.wantErr {
+				assert.Error(t, err)
+			} else {
+				assert.NoError(t, err)
+			}
+		})
+	}
+}
+
+func TestAutoContinue_RetryBudgetExhaustedStopsRetrying(t *testing.T) {
+	_, queries := setupTestDB(t)
+	createAutoContinueTestAgent(t, queries, "agent-1")
+	setWorkspaceRetryPolicy(t, queries, "ws-1", &leapmuxv1.RetryRule{
+		Condition:   leapmuxv1.RetryCondition_RETRY_CONDITION_RATE_LIMIT,
+		MaxAttempts: 1,
+	})
+
+	h := NewOutputHandler(nil, queries, nil, nil, nil)
+	t.Cleanup(func() { h.cleanupAutoContinue("agent-1") })
+	key := db.GetAutoContinueScheduleParams{AgentID: "agent-1", Reason: string(agent.AutoContinueReasonRateLimit)}
+	schedule := agent.AutoContinueSchedule{
+		Reason: agent.AutoContinueReasonRateLimit,
+		DueAt:  time.Now().UTC().Add(10 * time.Minute),
+	}
+
+	h.scheduleAutoContinue("agent-1", schedule)
+	row, err := queries.GetAutoContinueSchedule(bgCtx(), key)
+	require.NoError(t, err)
+	assert.Equal(t, autoContinueStateActive, row.State)
+	assert.Equal(t, int64(1), row.Attempts)
+
+	// The retried turn hit the limit again: attempt 2 is over budget.
+	require.NoError(t, queries.MarkAutoContinueScheduleFired(bgCtx(), db.MarkAutoContinueScheduleFiredParams(key)))
+	h.scheduleAutoContinue("agent-1", schedule)
+
+	active, err := queries.ListActiveAutoContinueSchedules(bgCtx())
+	require.NoError(t, err)
+	assert.Empty(t, active, "no retry is armed once the budget is spent")
+}
+
+func TestAutoContinue_RetryBudgetSwitchesToFallbackModel(t *testing.T) {
+	_, queries := setupTestDB(t)
+	createAutoContinueTestAgent(t, queries, "agent-1")
+	setWorkspaceRetryPolicy(t, queries, "ws-1", &leapmuxv1.RetryRule{
+		Condition:     leapmuxv1.RetryCondition_RETRY_CONDITION_RATE_LIMIT,
+		MaxAttempts:   1,
+		FallbackModel: "fallback-model",
+	})
+
+	h := NewOutputHandler(nil, queries, nil, nil, nil)
+	t.Cleanup(func() { h.cleanupAutoContinue("agent-1") })
+	var switched []string
+	h.SetSwitchModelFunc(func(agentID, model string) {
+		switched = append(switched, agentID+":"+model)
+	})
+	key := db.GetAutoContinueScheduleParams{AgentID: "agent-1", Reason: string(agent.AutoContinueReasonRateLimit)}
+	schedule := agent.AutoContinueSchedule{
+		Reason: agent.AutoContinueReasonRateLimit,
+		DueAt:  time.Now().UTC().Add(10 * time.Minute),
+	}
+
+	h.scheduleAutoContinue("agent-1", schedule)
+	require.NoError(t, queries.MarkAutoContinueScheduleFired(bgCtx(), db.MarkAutoContinueScheduleFiredParams(key)))
+	h.scheduleAutoContinue("agent-1", schedule)
+
+	assert.Equal(t, []string{"agent-1:fallback-model"}, switched)
+	row, err := queries.GetAutoContinueSchedule(bgCtx(), key)
+	require.NoError(t, err)
+	assert.Equal(t, autoContinueStateActive, row.State)
+	assert.Equal(t, int64(1), row.Attempts, "switching models starts the count over")
+}
+
+func TestAutoContinue_CancelResetsAttempts(t *testing.T) {
+	_, queries := setupTestDB(t)
+	createAutoContinueTestAgent(t, queries, "agent-1")
+
+	h := NewOutputHandler(nil, queries, nil, nil, nil)
+	t.Cleanup(func() { h.cleanupAutoContinue("agent-1") })
+	key := db.GetAutoContinueScheduleParams{AgentID: "agent-1", Reason: string(agent.AutoContinueReasonRateLimit)}
+	schedule := agent.AutoContinueSchedule{
+		Reason: agent.AutoContinueReasonRateLimit,
+		DueAt:  time.Now().UTC().Add(10 * time.Minute),
+	}
+
+	h.scheduleAutoContinue("agent-1", schedule)
+	require.NoError(t, queries.MarkAutoContinueScheduleFired(bgCtx(), db.MarkAutoContinueScheduleFiredParams(key)))
+	h.scheduleAutoContinue("agent-1", schedule)
+	row, err := queries.GetAutoContinueSchedule(bgCtx(), key)
+	require.NoError(t, err)
+	assert.Equal(t, int64(2), row.Attempts)
+
+	h.cancelAutoContinue("agent-1", agent.AutoContinueReasonRateLimit)
+	row, err = queries.GetAutoContinueSchedule(bgCtx(), key)
+	require.NoError(t, err)
+	assert.Equal(t, int64(0), row.Attempts)
+}
+
+func TestWorkspaceRetryPolicy_SetAndGet(t *testing.T) {
+	_, d, w := setupTestService(t, withWorkspaces("ws-1"))
+
+	policy := &leapmuxv1.RetryPolicy{Rules: []*leapmuxv1.RetryRule{{
+		Condition:   leapmuxv1.RetryCondition_RETRY_CONDITION_API_ERROR,
+		MaxAttempts: 5,
+	}}}
+	dispatch(d, "SetWorkspaceRetryPolicy", &leapmuxv1.SetWorkspaceRetryPolicyRequest{
+		WorkspaceId: "ws-1",
+		Policy:      policy,
+	}, w)
+	require.Empty(t, w.errors)
+
+	dispatch(d, "GetWorkspaceRetryPolicy", &leapmuxv1.GetWorkspaceRetryPolicyRequest{WorkspaceId: "ws-1"}, w)
+	require.Empty(t, w.errors)
+	require.Len(t, w.responses, 2)
+	var resp leapmuxv1.GetWorkspaceRetryPolicyResponse
+	require.NoError(t, proto.Unmarshal(w.responses[1].GetPayload(), &resp))
+	assert.True(t, proto.Equal(policy, resp.GetPolicy()))
+
+	// An empty policy clears the stored one.
+	dispatch(d, "SetWorkspaceRetryPolicy", &leapmuxv1.SetWorkspaceRetryPolicyRequest{WorkspaceId: "ws-1"}, w)
+	dispatch(d, "GetWorkspaceRetryPolicy", &leapmuxv1.GetWorkspaceRetryPolicyRequest{WorkspaceId: "ws-1"}, w)
+	require.Empty(t, w.errors)
+	require.Len(t, w.responses, 4)
+	require.NoError(t, proto.Unmarshal(w.responses[3].GetPayload(), &resp))
+	assert.Empty(t, resp.GetPolicy().GetRules())
+}
+
+func TestWorkspaceRetryPolicy_RejectsInvalidPolicy(t *testing.T) {
+	_, d, w := setupTestService(t, withWorkspaces("ws-1"))
+
+	dispatch(d, "SetWorkspaceRetryPolicy", &leapmuxv1.SetWorkspaceRetryPolicyRequest{
+		WorkspaceId: "ws-1",
+		Policy: &leapmuxv1.RetryPolicy{Rules: []*leapmuxv1.RetryRule{{
+			Condition:   leapmuxv1.RetryCondition_RETRY_CONDITION_API_ERROR,
+			MaxAttempts: -1,
+		}}},
+	}, w)
+	require.Len(t, w.errors, 1)
+	assert.Equal(t, codeInvalidArgument, w.errors[0].code)
+}
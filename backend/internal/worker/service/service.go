@@ -19,14 +19,23 @@ import (
 	"sync/atomic"
 	"time"
 
+	"github.com/leapmux/leapmux/channelwire"
 	leapmuxv1 "github.com/leapmux/leapmux/generated/proto/leapmux/v1"
+	"github.com/leapmux/leapmux/internal/errcode"
+	"github.com/leapmux/leapmux/internal/plugin"
+	"github.com/leapmux/leapmux/internal/policy"
+	"github.com/leapmux/leapmux/internal/util/idempotency"
 	"github.com/leapmux/leapmux/internal/util/optionids"
 	"github.com/leapmux/leapmux/internal/util/userid"
 	"github.com/leapmux/leapmux/internal/worker/agent"
+	"github.com/leapmux/leapmux/internal/worker/archive"
 	"github.com/leapmux/leapmux/internal/worker/channel"
+	"github.com/leapmux/leapmux/internal/worker/cistatus"
 	"github.com/leapmux/leapmux/internal/worker/config"
 	db "github.com/leapmux/leapmux/internal/worker/generated/db"
+	"github.com/leapmux/leapmux/internal/worker/redact"
 	"github.com/leapmux/leapmux/internal/worker/terminal"
+	"github.com/leapmux/leapmux/internal/worker/transcribe"
 	"github.com/leapmux/leapmux/internal/worker/wakelock"
 	"github.com/leapmux/leapmux/util/validate"
 	"google.golang.org/grpc/codes"
@@ -79,6 +88,12 @@ type Service struct {
 	startTerminalFn     func(context.Context, terminal.Options, terminal.OutputHandler, terminal.ExitHandler) error
 	createAgentRecordFn func(context.Context, db.CreateAgentParams) error
 	getAgentByIDFn      func(context.Context, string) (db.Agent, error)
+	// ciClientFn, when set, replaces the GitHub client CI polling uses
+	// for the repository at dir (see ciClient).
+	ciClientFn func(ctx context.Context, dir string) *cistatus.Client
+	// interruptAgentFn, when set, replaces Agents.Interrupt (see
+	// interruptAgent).
+	interruptAgentFn func(agentID string) error
 
 	// ---- Mutable runtime state: everything that changes over the worker's
 	// life, touched concurrently by the handler goroutines DispatchAsync
@@ -104,6 +119,39 @@ type Service struct {
 	// field's race invisible until the detector or a torn read finds it.
 	registeredBy atomic.Pointer[userid.UserID]
 
+	// orgID is the org of the worker's owner, which partitions what the
+	// archive shipper writes. The connect loop sets it on every greeting
+	// while the shipper reads it; empty until the Hub delivers it.
+	orgID atomic.Pointer[string]
+
+	// agentTerminalPolicy is the org's compiled agent terminal policy. The
+	// connect loop replaces it on every greeting and push while agent output
+	// goroutines read it, hence atomic. Nil until the Hub delivers one.
+	agentTerminalPolicy atomic.Pointer[compiledAgentTerminalPolicy]
+
+	// accessPolicy is the org's compiled access policy, replaced and read
+	// on the same goroutines as agentTerminalPolicy. Nil until the Hub
+	// delivers one.
+	accessPolicy atomic.Pointer[policy.Policy]
+
+	// orgDefaults is the org's defaults (see SetOrgDefaults), replaced and
+	// read on the same goroutines as agentTerminalPolicy. Nil until the Hub
+	// delivers them.
+	orgDefaults atomic.Pointer[leapmuxv1.OrgDefaults]
+
+	// emergencyStop is the emergency stop in effect (see SetEmergencyStop),
+	// replaced and read on the same goroutines as agentTerminalPolicy. Nil
+	// when there is none.
+	emergencyStop atomic.Pointer[leapmuxv1.EmergencyStopState]
+
+	// announcements is the Hub admin's announcements that have not ended
+	// (see SetAnnouncements).
+	announcements announcementBoard
+
+	// outputProcessors are handed to every agent launched (see
+	// AddOutputProcessor).
+	outputProcessors []agent.OutputProcessor
+
 	// AgentStartup / TerminalStartup track in-flight startups — the
 	// window between OpenAgent/OpenTerminal returning and the subprocess
 	// being ready. See startupstate.go.
@@ -149,6 +197,54 @@ type Service struct {
 	// contend. Entries are never deleted (bounded by the worker's
 	// distinct-worktree count over its lifetime).
 	worktreeRemovalLocks sync.Map
+
+	// provisioningLocks serializes setup script runs per workspace and
+	// working directory; see provisioningLock.
+	provisioningLocks sync.Map
+
+	// repoCheckouts tracks registered-repository clones by checkout
+	// directory -> *repoCheckout, so agents opened there wait for them;
+	// repoCacheLocks serializes updates of each repository's mirror by
+	// repo id -> *sync.Mutex. See repo_checkout.go.
+	repoCheckouts  sync.Map
+	repoCacheLocks sync.Map
+
+	// compactions marks agents with a compaction in flight (agent id ->
+	// struct{}); carriedContext holds the digest a summary compaction left
+	// for an agent's next turn (agent id -> string). See agent_compact.go.
+	compactions    sync.Map
+	carriedContext sync.Map
+	// contextPressure is the level each agent's context last reached
+	// (agent id -> contextPressureLevel), kept while above none. See
+	// context_pressure.go.
+	contextPressure sync.Map
+	// anomalyTurns tallies each agent's current turn for the anomaly
+	// checks (agent id -> *anomalyTurn). See anomaly.go.
+	anomalyTurns sync.Map
+	// stuckTurns is what the watchdog did about each agent's current turn
+	// (agent id -> *stuckTurn). See stuck_turn.go.
+	stuckTurns sync.Map
+	// turnTallies is the usage each agent's current turn has reported,
+	// held against its workspace's turn limits (agent id -> *turnTally). See turn_limits.go.
+	turnTallies sync.Map
+
+	// rateLimits is the rate limit budget of each provider account the
+	// agents use, and the turns it holds back. See rate_limit_budget.go.
+	rateLimits rateLimitBudgets
+	// repoCredentialMu serializes writes of delivered git credentials;
+	// see repo_credentials.go.
+	repoCredentialMu sync.Mutex
+
+	// disk is the latest disk usage measurement and the org's disk quota;
+	// see disk_usage.go.
+	disk diskUsageState
+	// workspaceActivity is what was last reported to the Hub for its
+	// ListWorkspaces; see workspace_activity.go.
+	workspaceActivity workspaceActivityState
+
+	// openAgentKeys maps OpenAgent idempotency keys to the agent id the
+	// keyed call opened; see open_agent_keys.go. Always non-nil after New.
+	openAgentKeys *idempotency.Keys[string]
 }
 
 // worktreeRemovalLock returns the per-worktree mutex that serializes the
@@ -276,8 +372,9 @@ type Config struct {
 	DataDir   string // Empty makes data-dir-relative paths resolve to CWD
 
 	// Optional.
-	WorkerID string // This worker's ID (set after registration)
-	Name     string // Worker display name (from LEAPMUX_WORKER_NAME, defaults to hostname)
+	WorkerID string   // This worker's ID (set after registration)
+	Name     string   // Worker display name (from LEAPMUX_WORKER_NAME, defaults to hostname)
+	Labels   []string // This worker's configured labels (see access policy conditions)
 	// SeedRegisteredBy seeds the worker owner. The Hub is the authority
 	// and re-delivers it on every Connect, so an entry point that expects
 	// the Hub to supply it leaves this empty (see UpdateRegisteredBy).
@@ -290,7 +387,26 @@ type Config struct {
 	AgentStartupTimeout time.Duration             // Timeout for agent startup handshake (default: 5m)
 	APITimeout          time.Duration             // Timeout for JSON-RPC requests (default: 10s)
 	UseLoginShell       bool                      // Wrap claude invocation in user's login shell
+	CaptureAgentOutput  string                    // Directory raw agent stdout is copied to (empty = off)
+	ClaudeOutputSchema  agent.OutputSchemaMode    // How Claude output schema findings are reported (zero = lenient)
 	WakeLock            *wakelock.ActivityTracker // Keep-awake tracker (nil = disabled)
+
+	PermissionGuardrails   PermissionGuardrails    // This worker's own permission mode constraints; the org's add to them
+	IdlePark               IdleParkPolicy          // Stops idle agent subprocesses (zero = never)
+	ContextPressure        ContextPressurePolicy   // Warns as agents' context windows fill (zero = never)
+	Anomaly                AnomalyPolicy           // Flags runaway or destructive agent turns (zero = never)
+	StuckTurn              StuckTurnPolicy         // Acts on turns that stop producing output (zero = never)
+	ClaudeSessionRetention time.Duration           // Keeps unreferenced Claude Code session files this long (zero = forever)
+	PersistTerminals       bool                    // Runs terminal shells under tmux so they outlive the worker
+	Plugins                *plugin.Host            // Exec plugins sent agent events (nil = none)
+	Redactor               *redact.Redactor        // Replaces secrets in agent output before it is stored (nil = off)
+	Classifier             *redact.Classifier      // Tags stored messages that hold personal data (nil = off)
+	ClassifiedRetention    time.Duration           // Deletes tagged messages this old (zero = kept like the rest)
+	Archive                *archive.Bucket         // Where closed agents' transcripts are shipped (nil = not shipped)
+	ArchiveRetention       time.Duration           // Deletes shipped objects this old from the bucket (zero = kept)
+	Transcriber            transcribe.Transcriber  // Voice note backend (nil = voice notes disabled)
+	Snippets               SnippetResolver         // Looks up senders' snippets on the Hub (nil = no snippet expansion)
+	ModelCredentials       ModelCredentialResolver // Looks up workspaces' model credentials on the Hub (nil = agents keep the worker's login)
 }
 
 // New creates a fully wired Service.
@@ -327,6 +443,7 @@ func New(cfg Config) *Service {
 		AgentStartup:    newAgentStartupRegistry(),
 		TerminalStartup: newTerminalStartupRegistry(),
 		PrivateEvents:   NewPrivateEventsBus(),
+		openAgentKeys:   idempotency.New[string](idempotency.DefaultWindow),
 	}
 	// The seed is config data, so it is minted here -- the one place the raw
 	// string exists -- rather than inside the setter.
@@ -345,21 +462,56 @@ func New(cfg Config) *Service {
 	svc.Output.SetSendMessageFunc(func(agentID, content string) {
 		svc.sendSyntheticUserMessage(agentID, content, leapmuxv1.MarkType_MARK_TYPE_UNSPECIFIED)
 	})
+	// Let the retry policy engine move an agent onto its fallback model. It
+	// goes through the same persist/push/notify path as a user-initiated
+	// model change, so the chat shows a settings_changed entry for it.
+	svc.Output.SetSwitchModelFunc(svc.switchAgentModel)
 	// Let PersistSettingsRefresh detect the startup window so it doesn't
 	// clobber a settings change made mid-startup (see SetAgentStartingFunc).
 	svc.Output.SetAgentStartingFunc(func(agentID string) bool {
 		_, _, _, ok := svc.AgentStartup.status(agentID)
 		return ok
 	})
+	// Let the org's agent terminal policy take matching commands out of the
+	// agent's Bash tool (see agent_terminal.go).
+	svc.Output.SetControlRequestDiverter(svc.divertControlRequest)
+	// Let the org's auto-continue rules override the workspace's.
+	svc.Output.SetOrgRetryPolicyFunc(svc.orgRetryPolicy)
+	// Let the service react to what agents report about their sessions.
+	svc.Output.SetSessionInfoObserver(svc.observeSessionInfo)
+	// Watch what agents do for runaway or destructive turns, and tell the
+	// operator's plugins when turns end.
+	svc.Output.SetSpanObserver(func(agentID string, provider leapmuxv1.AgentProvider, span agent.SpanInfo, turnEnd bool) {
+		svc.observeSpan(agentID, provider, span, turnEnd)
+		svc.observeTurnUsage(agentID, provider, span, turnEnd)
+		if turnEnd {
+			svc.emitTurnCompleted(agentID, provider, span)
+		}
+	})
+	// Tag messages that hold personal data as they are stored.
+	svc.Output.SetClassifier(svc.Classifier)
+	// Hash-chain messages as they are stored while the org keeps
+	// immutable transcripts.
+	svc.Output.SetImmutableTranscriptsFunc(svc.ImmutableTranscripts)
+	// Redaction goes first, so the plugins never see a secret.
+	svc.wireRedaction()
+	svc.wirePlugins()
 
 	return svc
 }
 
 func (svc *Service) startAgent(ctx context.Context, opts agent.Options, sink agent.OutputSink) (map[string]string, error) {
+	var settings map[string]string
+	var err error
 	if svc.startAgentFn != nil {
-		return svc.startAgentFn(ctx, opts, sink)
+		settings, err = svc.startAgentFn(ctx, opts, sink)
+	} else {
+		settings, err = svc.Agents.StartAgent(ctx, opts, sink)
+	}
+	if err == nil {
+		broadcastAgentEnv(sink, opts.Env)
 	}
-	return svc.Agents.StartAgent(ctx, opts, sink)
+	return settings, err
 }
 
 // restartAgent preserves Manager.RestartAgent's stop-before-start ordering while
@@ -405,19 +557,23 @@ func (svc *Service) getAgentByID(ctx context.Context, agentID string) (db.Agent,
 }
 
 // RestoreState re-arms what a previous worker process left persisted --
-// today, the auto-continue schedules whose timers inject synthetic user
-// messages when they fire.
+// the auto-continue schedules whose timers inject synthetic user messages
+// when they fire -- and ends the persistent terminal shells it left
+// running whose tabs have since closed.
 //
 // Separate from New because it reads the database and starts timers.
 // Construction should not do either: it is what lets every unit test
 // build a Service without touching the auto_continue tables or arming a
 // background goroutine it then has to stop.
 //
-// Agents and terminals need no equivalent: their status is derived from
+// Agents and terminals need nothing more: their status is derived from
 // runtime state (HasAgent/HasTerminal), not from the DB, so there is no
-// stale row to clear at startup.
+// stale row to clear at startup. Their tabs bring them back on demand --
+// agents on the next message, terminals through ListTerminals' restorable
+// flag.
 func (svc *Service) RestoreState() {
 	svc.Output.restoreAutoContinueSchedules()
+	svc.sweepPersistentTerminals()
 }
 
 // Shutdown persists in-memory terminal state to the database so it
@@ -549,8 +705,13 @@ func (svc *Service) persistTerminalOnExit(tid string, exitCode int) bool {
 // only one would leave a silent hole (git uses both). Each Register also records
 // gateOwnerOnly on the shared registrar so TestEveryRegisteredMethodIsClassified
 // sees the method without replaying the family register functions.
+//
+// unscoped additionally refuses the owner's own workspace-scoped credentials
+// (a delegation bearer handed to an agent), for the families that run code as
+// the owner rather than read or move data.
 type ownerOnlyRegistrar struct {
-	r registrar
+	r        registrar
+	unscoped bool
 }
 
 func (o ownerOnlyRegistrar) gate(handler channel.HandlerFunc) channel.HandlerFunc {
@@ -558,6 +719,10 @@ func (o ownerOnlyRegistrar) gate(handler channel.HandlerFunc) channel.HandlerFun
 		if !requireWorkerOwner(o.r.svc, userID, sender) {
 			return
 		}
+		if o.unscoped && o.r.svc.channelWorkspaceScoped(sender.ChannelID()) {
+			sendPermissionDenied(sender, "a workspace-scoped credential may not use this")
+			return
+		}
 		handler(ctx, userID, req, sender)
 	}
 }
@@ -619,6 +784,24 @@ func (svc *Service) UpdateRegisteredBy(userID string) {
 	svc.registeredBy.Store(&uid)
 }
 
+// SetOrgID applies the org the Hub delivered on connect. An empty id, from
+// a Hub that predates it, keeps the current one.
+func (svc *Service) SetOrgID(orgID string) {
+	if orgID == "" {
+		return
+	}
+	svc.orgID.Store(&orgID)
+}
+
+// OrgID returns the org of the worker's owner, or "" if the Hub has not
+// delivered it yet.
+func (svc *Service) OrgID() string {
+	if p := svc.orgID.Load(); p != nil {
+		return *p
+	}
+	return ""
+}
+
 // RegisteredBy returns the user who registered this worker, or the zero UserID
 // if the Hub has not delivered it yet. requireWorkerOwner refuses a zero id
 // via MatchesUser, so an undelivered identity fails closed rather than matching
@@ -640,10 +823,11 @@ func (svc *Service) RegisteredBy() userid.UserID {
 // path). The owner already has all of this: their agents run as them on their own
 // machine, so granting it over the channel adds nothing. Anyone ELSE holding a
 // channel must not have it -- notably a delegation bearer, which is pinned to one
-// workspace and is handed to a prompt-injectable agent. The Hub only ever opens a
-// channel for the worker's own owner, so this gate is defence in depth today; it is
-// what keeps any future way of reaching a worker failing closed rather than
-// silently exposing the filesystem.
+// workspace and is handed to a prompt-injectable agent, and a workspace guest. A
+// driver guest's channel is opened under the owner's user id (the guest acts as
+// the owner inside the shared workspace), so the id match alone would let an
+// outside guest read any file on the machine; the Hub marks such a channel as a
+// guest's and this gate refuses it before comparing ids.
 //
 // Workspace-scoped families (agent, terminal, tab moves, cleanup) must NOT use
 // this: they legitimately serve non-owners and gate on the Hub-supplied
@@ -658,13 +842,32 @@ func (svc *Service) RegisteredBy() userid.UserID {
 // ordering non-load-bearing. Its sibling in this package's Hub counterpart
 // (verifyDelegationWorkerScope) refuses an unrecorded minter for the same reason.
 func requireWorkerOwner(svc *Service, userID userid.UserID, sender channel.ResponseWriter) bool {
-	if userID.MatchesUser(svc.RegisteredBy()) {
+	if !svc.channelGuest(sender.ChannelID()) && userID.MatchesUser(svc.RegisteredBy()) {
 		return true
 	}
 	sendPermissionDenied(sender, "only the worker owner may use this")
 	return false
 }
 
+// channelGuest reports whether channelID belongs to a workspace guest. Local
+// IPC streams belong to spawned agents and are never a guest's.
+func (svc *Service) channelGuest(channelID string) bool {
+	if svc.Channels == nil || strings.HasPrefix(channelID, LocalIPCStreamPrefix) {
+		return false
+	}
+	return svc.Channels.IsGuest(channelID)
+}
+
+// channelWorkspaceScoped reports whether channelID was opened with a
+// credential pinned to one workspace. Local IPC streams carry the spawning
+// process's own token and are never workspace-scoped channels.
+func (svc *Service) channelWorkspaceScoped(channelID string) bool {
+	if svc.Channels == nil || strings.HasPrefix(channelID, LocalIPCStreamPrefix) {
+		return false
+	}
+	return svc.Channels.IsWorkspaceScoped(channelID)
+}
+
 // RegisterAll registers all service handlers with the dispatcher.
 //
 // Every method records a methodGate at registration time (default-deny: a
@@ -681,8 +884,9 @@ func requireWorkerOwner(svc *Service, userID userid.UserID, sender channel.Respo
 //     handlers that never read the row.
 //   - gateInBody     — heterogeneous in-body gates (file-tab-path dual checks,
 //     MoveTabWorkspace TabType switch); probe-enforced completeness.
-//   - gateSetFilter  — ListAgents / ListTerminals / WatchEvents filter via
-//     AccessibleSet(); denial is an empty result, not PERMISSION_DENIED.
+//   - gateSetFilter  — ListAgents / ListAllAgents / ListTerminals /
+//     WatchEvents / QueryWorkspaceMetrics filter via AccessibleSet(); denial
+//     is an empty result, not PERMISSION_DENIED.
 //   - gateNone       — Ping; a liveness probe that does no work and discloses
 //     nothing, ungated by design.
 //
@@ -719,12 +923,51 @@ func registerAllClassified(d *channel.Dispatcher, svc *Service) (map[string]meth
 	// Machine-scoped: owner-only by construction (see ownerOnlyRegistrar).
 	ownerOnly := ownerOnlyRegistrar{r: r}
 	registerFileHandlers(ownerOnly, svc)
+	registerDownloadHandlers(ownerOnly, svc)
+	registerExecHandlers(ownerOnlyRegistrar{r: r, unscoped: true}, svc)
 	registerGitHandlers(ownerOnly, svc)
 	registerTerminalHandlers(r, svc)
 	registerAgentHandlers(r, svc)
+	registerAgentListingHandlers(r, svc)
 	registerCleanupHandlers(r, svc)
 	registerTabMoveHandlers(r, svc)
+	registerRetryPolicyHandlers(r, svc)
+	registerTurnLimitHandlers(r, svc)
+	registerAgentDefaultsHandlers(r, svc)
+	registerModelRoutingHandlers(r, svc)
+	registerVoiceNoteHandlers(r, svc)
+	registerTerminalOutputHandlers(r, svc)
+	registerAgentCloneHandlers(r, svc)
+	registerAgentRuntimeInfoHandlers(r, svc)
+	registerAgentCompactHandlers(r, svc)
+	registerRateLimitBudgetHandlers(r, svc)
+	registerModelCredentialHandlers(r, svc)
+	registerWorkspaceMetricsHandlers(r, svc)
+	registerSystemPromptHandlers(r, svc)
+	registerPlanLibraryHandlers(r, svc)
+	registerWorkspaceTransferHandlers(r, svc)
+	registerTranscriptHandlers(r, svc)
+	registerComplianceHandlers(r, svc)
+	registerGraphQLHandlers(r, svc)
+	registerWorkspaceActivityHandlers(r, svc)
+	registerArtifactHandlers(r, svc)
+	registerAgentFileTransferHandlers(r, svc)
+	registerTestRunHandlers(r, svc)
+	registerCIStatusHandlers(r, svc)
+	registerAnalyticsHandlers(r, svc)
+	registerEnvVarsHandlers(r, svc)
+	registerTerminalProfileHandlers(r, svc)
+	registerPlanEditHandlers(r, svc)
+	registerSubAgentRunHandlers(r, svc)
+	registerNotificationConsolidationHandlers(r, svc)
+	registerWorkspaceProvisioningHandlers(r, svc)
 	registerSysInfoHandlers(ownerOnly, svc)
+	registerWorkerMetricsHandlers(ownerOnly, svc)
+	registerClaudeSessionGCHandlers(ownerOnly, svc)
+	registerNotificationThreadStatsHandlers(ownerOnly, svc)
+	registerDebugAgentStateHandlers(ownerOnly, svc)
+	registerOutputDeadLetterHandlers(ownerOnly, svc)
+	registerLogLevelsHandlers(ownerOnly, svc)
 	registerTunnelHandlers(ownerOnly)
 	return r.gates, r.shapes
 }
@@ -879,6 +1122,20 @@ func sendInvalidArgument(sender channel.ResponseWriter, msg string) {
 	_ = sender.SendError(int32(codes.InvalidArgument), msg)
 }
 
+// sendResourceExhausted sends a ResourceExhausted error response, for a
+// request refused because the worker is out of disk space or quota.
+func sendResourceExhausted(sender channel.ResponseWriter, err error) {
+	sendCodedError(sender, codes.ResourceExhausted, err)
+}
+
+// sendCodedError sends an error response whose error_reason carries err's
+// errcode, so clients can branch on the code rather than the message.
+func sendCodedError(sender channel.ResponseWriter, code codes.Code, err error) {
+	resp := channelwire.NewErrorResponse(int32(code), err.Error())
+	resp.ErrorReason = string(errcode.Of(err))
+	_ = sender.SendResponse(resp)
+}
+
 // sendStreamError reports a terminal failure on a STREAMING method.
 //
 // The sender helpers above emit an InnerRpcResponse, which the frontend
@@ -1017,6 +1274,13 @@ func (svc *Service) requireAccessibleTerminalID(sender channel.ResponseWriter, t
 	return ok
 }
 
+// notFoundCodes gives requireAccessibleRow's NotFound answer its errcode
+// for each kind of row.
+var notFoundCodes = map[string]errcode.Code{
+	"agent":    errcode.AgentNotFound,
+	"terminal": errcode.TerminalNotFound,
+}
+
 // requireAccessibleRow factors the ACL + error-mapping shell shared by
 // every "load a row by id, then check workspace access" helper. kind is
 // the user-facing entity label embedded in error messages ("agent",
@@ -1037,7 +1301,7 @@ func requireAccessibleRow[T any](
 	row, err := fetch(bgCtx(), id)
 	if err != nil {
 		if errors.Is(err, sql.ErrNoRows) {
-			sendNotFoundError(sender, kind+" not found")
+			sendCodedError(sender, codes.NotFound, errcode.Wrap(notFoundCodes[kind], errors.New(kind+" not found")))
 			return zero, false
 		}
 		slog.Error("failed to load "+kind+" for access check", kind+"_id", id, "error", err)
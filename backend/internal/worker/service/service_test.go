@@ -18,11 +18,14 @@ import (
 	"github.com/stretchr/testify/require"
 
 	leapmuxv1 "github.com/leapmux/leapmux/generated/proto/leapmux/v1"
+	"github.com/leapmux/leapmux/internal/plugin"
 	"github.com/leapmux/leapmux/internal/util/sqlitedb"
 	"github.com/leapmux/leapmux/internal/util/userid"
 	"github.com/leapmux/leapmux/internal/worker/agent"
+	"github.com/leapmux/leapmux/internal/worker/archive"
 	"github.com/leapmux/leapmux/internal/worker/channel"
 	workerdb "github.com/leapmux/leapmux/internal/worker/db"
+	"github.com/leapmux/leapmux/internal/worker/redact"
 	"github.com/leapmux/leapmux/internal/worker/terminal"
 	"github.com/leapmux/leapmux/internal/worker/wakelock"
 	"google.golang.org/grpc/codes"
@@ -249,11 +252,33 @@ func TestNew_CarriesEveryConfigField(t *testing.T) {
 		DataDir:             "/data/x",
 		WorkerID:            "worker-1",
 		Name:                "display-name",
+		Labels:              []string{"gpu"},
 		SeedRegisteredBy:    "user-1",
 		AgentStartupTimeout: 11 * time.Second,
 		APITimeout:          7 * time.Second,
 		UseLoginShell:       true,
+		CaptureAgentOutput:  "/capture/x",
+		ClaudeOutputSchema:  agent.OutputSchemaStrict,
 		WakeLock:            wakelock.NewActivityTracker(),
+		PermissionGuardrails: PermissionGuardrails{
+			Forbidden: []string{"bypassPermissions"},
+			Default:   "plan",
+		},
+		IdlePark:               IdleParkPolicy{After: time.Hour},
+		ContextPressure:        ContextPressurePolicy{WarnPercent: 80},
+		Anomaly:                AnomalyPolicy{MaxToolCalls: 100},
+		StuckTurn:              StuckTurnPolicy{After: 20 * time.Minute},
+		Transcriber:            &fakeTranscriber{},
+		Snippets:               func(context.Context, string, string) (*leapmuxv1.Snippet, error) { return nil, nil },
+		ModelCredentials:       func(context.Context, string, string) ([]*leapmuxv1.ModelCredentialSecret, error) { return nil, nil },
+		ClaudeSessionRetention: 30 * 24 * time.Hour,
+		PersistTerminals:       true,
+		Plugins:                &plugin.Host{},
+		Redactor:               redact.New(),
+		Classifier:             redact.NewClassifier(),
+		ClassifiedRetention:    90 * 24 * time.Hour,
+		Archive:                &archive.Bucket{},
+		ArchiveRetention:       365 * 24 * time.Hour,
 	}
 
 	v := reflect.ValueOf(cfg)
@@ -271,13 +296,27 @@ func TestNew_CarriesEveryConfigField(t *testing.T) {
 	assert.Same(t, cfg.Terminals, svc.Terminals)
 	assert.Same(t, cfg.Channels, svc.Channels)
 	assert.Same(t, cfg.WakeLock, svc.WakeLock)
+	assert.Same(t, cfg.Redactor, svc.Redactor)
+	assert.Same(t, cfg.Classifier, svc.Output.classifier)
+	assert.Same(t, cfg.Archive, svc.Archive)
 	assert.Equal(t, "/home/x", svc.HomeDir)
 	assert.Equal(t, "/data/x", svc.DataDir)
 	assert.Equal(t, "worker-1", svc.WorkerID)
 	assert.Equal(t, "display-name", svc.Name)
+	assert.Equal(t, []string{"gpu"}, svc.Labels)
 	assert.Equal(t, 11*time.Second, svc.AgentStartupTimeout)
 	assert.Equal(t, 7*time.Second, svc.APITimeout)
 	assert.True(t, svc.UseLoginShell)
+	assert.Equal(t, "/capture/x", svc.baseAgentOptions("a", "/w", leapmuxv1.AgentProvider_AGENT_PROVIDER_CLAUDE_CODE).CaptureDir,
+		"agents launch with the capture dir")
+	assert.Equal(t, agent.OutputSchemaStrict, svc.baseAgentOptions("a", "/w", leapmuxv1.AgentProvider_AGENT_PROVIDER_CLAUDE_CODE).OutputSchemaMode)
+	assert.Equal(t, cfg.PermissionGuardrails, svc.PermissionGuardrails)
+	assert.Equal(t, cfg.IdlePark, svc.IdlePark)
+	assert.Equal(t, cfg.ContextPressure, svc.ContextPressure)
+	assert.Equal(t, cfg.Anomaly, svc.Anomaly)
+	assert.Same(t, cfg.Transcriber, svc.Transcriber)
+	assert.NotNil(t, svc.Snippets, "Snippets must be carried over")
+	assert.Equal(t, 30*24*time.Hour, svc.ClaudeSessionRetention)
 	assert.NotNil(t, svc.Send, "Send must be carried over")
 
 	// The one field New still translates by hand: the seed becomes the
@@ -0,0 +1,115 @@
+package service
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"unicode"
+
+	db "github.com/leapmux/leapmux/internal/worker/generated/db"
+)
+
+// slashCommand is a command LeapMux runs itself when a user message
+// invokes it, instead of forwarding the message to the agent. A /name that
+// no command registers reaches the agent unchanged, so each provider's own
+// slash commands keep working.
+//
+// The invoking message is persisted and broadcast like any other user
+// message, so the transcript shows what was asked; Parse and Permit run
+// before that and refuse the send outright.
+type slashCommand interface {
+	// Names lists the names the command answers to, without the slash. The
+	// first is the canonical one.
+	Names() []string
+	// Parse checks args, the trimmed text after the name. An error is
+	// answered with InvalidArgument.
+	Parse(args string) error
+	// Permit reports whether the command may run on dbAgent. An error is
+	// answered with FailedPrecondition.
+	Permit(svc *Service, dbAgent db.Agent) error
+	// Run executes the command. It returns the LEAPMUX notification to
+	// persist after the invoking message, or nil when it reports its own
+	// outcome.
+	Run(svc *Service, dbAgent db.Agent, args string) map[string]any
+}
+
+// slashCommandRegistry maps each name of every registered command to it.
+type slashCommandRegistry map[string]slashCommand
+
+// slashCommands are the commands SendAgentMessage intercepts. Add a command
+// by implementing slashCommand and listing it here.
+var slashCommands = newSlashCommandRegistry(
+	clearCommand{},
+	statusCommand{},
+	compactCommand{},
+)
+
+func newSlashCommandRegistry(cmds ...slashCommand) slashCommandRegistry {
+	r := slashCommandRegistry{}
+	for _, cmd := range cmds {
+		for _, name := range cmd.Names() {
+			if _, dup := r[name]; dup {
+				panic(fmt.Sprintf("slash command /%s registered twice", name))
+			}
+			r[name] = cmd
+		}
+	}
+	return r
+}
+
+// match returns the command content invokes and the trimmed text after its
+// name. ok is false when content is not a registered command.
+func (r slashCommandRegistry) match(content string) (cmd slashCommand, args string, ok bool) {
+	rest, found := strings.CutPrefix(strings.TrimSpace(content), "/")
+	if !found {
+		return nil, "", false
+	}
+	name := rest
+	if i := strings.IndexFunc(rest, unicode.IsSpace); i >= 0 {
+		name, args = rest[:i], strings.TrimSpace(rest[i:])
+	}
+	cmd, ok = r[name]
+	if !ok {
+		return nil, "", false
+	}
+	return cmd, args, true
+}
+
+// has reports whether name, without the slash, is a registered command.
+func (r slashCommandRegistry) has(name string) bool {
+	_, ok := r[name]
+	return ok
+}
+
+// runSlashCommand runs cmd and persists the notification it returns.
+func (svc *Service) runSlashCommand(cmd slashCommand, dbAgent db.Agent, args string) {
+	if notification := cmd.Run(svc, dbAgent, args); notification != nil {
+		svc.Output.PersistLeapMuxNotification(dbAgent.ID, dbAgent.AgentProvider, notification)
+	}
+}
+
+// errNoArguments is Parse's answer for a command that takes none.
+var errNoArguments = errors.New("this command takes no arguments")
+
+// clearCommand restarts the agent with a fresh context window. Providers
+// running headless do not handle /clear themselves, so LeapMux does it for
+// all of them.
+type clearCommand struct{}
+
+func (clearCommand) Names() []string { return []string{"clear", "reset", "new"} }
+
+func (clearCommand) Parse(args string) error {
+	if args != "" {
+		return errNoArguments
+	}
+	return nil
+}
+
+func (clearCommand) Permit(*Service, db.Agent) error { return nil }
+
+// Run leaves the context_cleared notification to handleClearContext, which
+// must persist it before broadcasting ACTIVE.
+func (clearCommand) Run(svc *Service, dbAgent db.Agent, _ string) map[string]any {
+	svc.handleClearContext(dbAgent.ID)
+	return nil
+}
@@ -0,0 +1,118 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	leapmuxv1 "github.com/leapmux/leapmux/generated/proto/leapmux/v1"
+	"github.com/leapmux/leapmux/internal/worker/agent"
+	db "github.com/leapmux/leapmux/internal/worker/generated/db"
+)
+
+// fakeSlashCommand is a /fake command whose hooks a test controls.
+type fakeSlashCommand struct {
+	permitErr error
+	ran       chan string
+}
+
+func (fakeSlashCommand) Names() []string { return []string{"fake"} }
+
+func (fakeSlashCommand) Parse(args string) error {
+	if args == "bad" {
+		return errors.New("bad argument")
+	}
+	return nil
+}
+
+func (c fakeSlashCommand) Permit(*Service, db.Agent) error { return c.permitErr }
+
+func (c fakeSlashCommand) Run(_ *Service, _ db.Agent, args string) map[string]any {
+	c.ran <- args
+	return map[string]any{"type": agent.NotificationTypeAgentError, "error": "ran " + args}
+}
+
+// withSlashCommands replaces the registry for the duration of the test.
+func withSlashCommands(t *testing.T, cmds ...slashCommand) {
+	t.Helper()
+	prev := slashCommands
+	slashCommands = newSlashCommandRegistry(cmds...)
+	t.Cleanup(func() { slashCommands = prev })
+}
+
+func TestSlashCommandRegistry_Match(t *testing.T) {
+	for content, want := range map[string]struct {
+		ok   bool
+		args string
+	}{
+		"/clear":            {ok: true},
+		"  /reset  ":        {ok: true},
+		"/new\tnow please ": {ok: true, args: "now please"},
+		"/cost":             {},
+		"clear":             {},
+		"/ clear":           {},
+		"please /clear":     {},
+	} {
+		t.Run(content, func(t *testing.T) {
+			cmd, args, ok := slashCommands.match(content)
+			require.Equal(t, want.ok, ok)
+			if ok {
+				assert.IsType(t, clearCommand{}, cmd)
+				assert.Equal(t, want.args, args)
+			}
+		})
+	}
+}
+
+func TestNewSlashCommandRegistry_RejectsDuplicateNames(t *testing.T) {
+	assert.PanicsWithValue(t, "slash command /clear registered twice", func() {
+		newSlashCommandRegistry(clearCommand{}, clearCommand{})
+	})
+}
+
+func TestSendAgentMessage_SlashCommandRefusedBeforePersisting(t *testing.T) {
+	for name, tc := range map[string]struct {
+		content   string
+		permitErr error
+		code      int32
+	}{
+		"parse error":   {content: "/fake bad", code: codeInvalidArgument},
+		"not permitted": {content: "/fake", permitErr: errors.New("not now"), code: codeFailedPrecondition},
+	} {
+		t.Run(name, func(t *testing.T) {
+			withSlashCommands(t, fakeSlashCommand{permitErr: tc.permitErr, ran: make(chan string, 1)})
+			svc, d, w := setupTestService(t, withWorkspaces("ws-1"))
+			seedGuardedAgent(t, svc, "default")
+
+			dispatch(d, "SendAgentMessage", &leapmuxv1.SendAgentMessageRequest{AgentId: "agent-1", Content: tc.content}, w)
+			require.Len(t, w.errors, 1)
+			assert.Equal(t, tc.code, w.errors[0].code)
+
+			msgs, err := svc.Queries.ListAllMessagesByAgentID(context.Background(), db.ListAllMessagesByAgentIDParams{AgentID: "agent-1"})
+			require.NoError(t, err)
+			assert.Empty(t, msgs, "a refused command must leave no trace in the transcript")
+		})
+	}
+}
+
+func TestSendAgentMessage_SlashCommandRunsInsteadOfDelivering(t *testing.T) {
+	ran := make(chan string, 1)
+	withSlashCommands(t, fakeSlashCommand{ran: ran})
+	svc, d, w := setupTestService(t, withWorkspaces("ws-1"))
+	seedGuardedAgent(t, svc, "default")
+
+	dispatch(d, "SendAgentMessage", &leapmuxv1.SendAgentMessageRequest{AgentId: "agent-1", Content: "/fake  a b "}, w)
+	require.Empty(t, w.errors)
+	assert.Equal(t, "a b", <-ran)
+
+	msgs, err := svc.Queries.ListAllMessagesByAgentID(context.Background(), db.ListAllMessagesByAgentIDParams{AgentID: "agent-1"})
+	require.NoError(t, err)
+	require.Len(t, msgs, 2, "the invoking message, then the command's notification")
+	assert.Equal(t, leapmuxv1.MessageSource_MESSAGE_SOURCE_USER, msgs[0].Source)
+	assert.Empty(t, msgs[0].DeliveryError, "a command is never delivered, so it cannot fail delivery")
+	assert.Equal(t, leapmuxv1.MessageSource_MESSAGE_SOURCE_LEAPMUX, msgs[1].Source)
+	assert.False(t, svc.Agents.HasAgent("agent-1"), "the command must not start the agent")
+}
@@ -0,0 +1,76 @@
+package service
+
+import (
+	"context"
+	"path"
+	"path/filepath"
+	"strings"
+	"time"
+
+	leapmuxv1 "github.com/leapmux/leapmux/generated/proto/leapmux/v1"
+	"github.com/leapmux/leapmux/internal/util/snippet"
+	db "github.com/leapmux/leapmux/internal/worker/generated/db"
+	"github.com/leapmux/leapmux/internal/worker/gitutil"
+)
+
+// snippetLookupTimeout bounds the Hub round trip that resolves a snippet.
+const snippetLookupTimeout = 10 * time.Second
+
+// SnippetResolver looks up the snippet named name of the user behind
+// channelID. A snippet the user does not have is (nil, nil).
+type SnippetResolver func(ctx context.Context, channelID, name string) (*leapmuxv1.Snippet, error)
+
+// snippetError is an expansion failure the sender can fix, answered with
+// InvalidArgument; any other expandSnippet error is internal.
+type snippetError struct{ err error }
+
+func (e snippetError) Error() string { return e.err.Error() }
+
+// expandSnippet replaces a "/name args" message with the sender's snippet
+// of that name, expanded. Content that is not an invocation, or names a
+// snippet the sender does not have, is returned unchanged so the provider's
+// own slash commands keep working; LeapMux's slashCommands take precedence
+// over a snippet of the same name. Only messages that arrived on a channel
+// are expanded: the Hub answers for the channel's user, and local-IPC
+// callers have none.
+func (svc *Service) expandSnippet(channelID string, dbAgent *db.Agent, content string) (string, error) {
+	if svc.Snippets == nil || channelID == "" {
+		return content, nil
+	}
+	name, args, ok := snippet.Parse(content)
+	if !ok || slashCommands.has(name) {
+		return content, nil
+	}
+	ctx, cancel := context.WithTimeout(bgCtx(), snippetLookupTimeout)
+	defer cancel()
+	sn, err := svc.Snippets(ctx, channelID, name)
+	if err != nil {
+		return "", err
+	}
+	if sn == nil {
+		return content, nil
+	}
+	expanded, err := snippet.Expand(sn.GetBody(), args, snippetVars(ctx, dbAgent.WorkingDir))
+	if err != nil {
+		return "", snippetError{err}
+	}
+	return expanded, nil
+}
+
+// snippetVars reads the workspace variables of workingDir. Outside a git
+// repository branch and repo are empty.
+func snippetVars(ctx context.Context, workingDir string) snippet.Vars {
+	vars := snippet.Vars{Cwd: workingDir}
+	status := gitutil.GetGitStatus(ctx, workingDir)
+	if status == nil {
+		return vars
+	}
+	vars.Branch = status.GetBranch()
+	switch {
+	case status.GetOriginUrl() != "":
+		vars.Repo = strings.TrimSuffix(path.Base(strings.TrimRight(status.GetOriginUrl(), "/")), ".git")
+	case status.GetToplevel() != "":
+		vars.Repo = filepath.Base(status.GetToplevel())
+	}
+	return vars
+}
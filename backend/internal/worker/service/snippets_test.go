@@ -0,0 +1,101 @@
+package service
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	leapmuxv1 "github.com/leapmux/leapmux/generated/proto/leapmux/v1"
+	"github.com/leapmux/leapmux/internal/util/msgcodec"
+	db "github.com/leapmux/leapmux/internal/worker/generated/db"
+)
+
+// setupSnippetAgent creates agent-1 in ws-1 and points the service at a
+// snippet library holding snippets, keyed by name.
+func setupSnippetAgent(t *testing.T, snippets map[string]string) (*Service, *testResponseWriter, func(string)) {
+	t.Helper()
+	svc, d, w := setupTestService(t, withWorkspaces("ws-1"))
+	require.NoError(t, svc.Queries.CreateAgent(context.Background(), db.CreateAgentParams{
+		ID:            "agent-1",
+		WorkspaceID:   "ws-1",
+		WorkingDir:    t.TempDir(),
+		HomeDir:       t.TempDir(),
+		AgentProvider: claudeCode,
+	}))
+	svc.Snippets = func(_ context.Context, channelID, name string) (*leapmuxv1.Snippet, error) {
+		assert.Equal(t, testChannelID, channelID)
+		body, ok := snippets[name]
+		if !ok {
+			return nil, nil
+		}
+		return &leapmuxv1.Snippet{Name: name, Body: body}, nil
+	}
+	send := func(content string) {
+		dispatch(d, "SendAgentMessage", &leapmuxv1.SendAgentMessageRequest{
+			AgentId: "agent-1",
+			Content: content,
+		}, w)
+	}
+	return svc, w, send
+}
+
+// persistedUserContent returns the content of agent-1's only message.
+func persistedUserContent(t *testing.T, svc *Service) string {
+	t.Helper()
+	msgs, err := svc.Queries.ListAllMessagesByAgentID(context.Background(), db.ListAllMessagesByAgentIDParams{
+		AgentID: "agent-1",
+	})
+	require.NoError(t, err)
+	require.Len(t, msgs, 1)
+	raw, err := msgcodec.Decompress(msgs[0].Content, msgs[0].ContentCompression)
+	require.NoError(t, err)
+	var stored struct {
+		Content string `json:"content"`
+	}
+	require.NoError(t, json.Unmarshal(raw, &stored))
+	return stored.Content
+}
+
+func TestSendAgentMessage_ExpandsSnippet(t *testing.T) {
+	svc, w, send := setupSnippetAgent(t, map[string]string{
+		"review": "Review {{1}} for {{args}} in {{ cwd }}.",
+	})
+	dbAgent, err := svc.Queries.GetAgentByID(context.Background(), "agent-1")
+	require.NoError(t, err)
+
+	send("/review main.go  races")
+	require.Empty(t, w.errors)
+	assert.Equal(t, "Review main.go for main.go  races in "+dbAgent.WorkingDir+".", persistedUserContent(t, svc))
+}
+
+func TestSendAgentMessage_UnknownSnippetPassesThrough(t *testing.T) {
+	svc, w, send := setupSnippetAgent(t, map[string]string{"review": "Review."})
+
+	send("/cost")
+	require.Empty(t, w.errors)
+	assert.Equal(t, "/cost", persistedUserContent(t, svc),
+		"a provider slash command must reach the agent untouched")
+}
+
+func TestSendAgentMessage_SnippetErrors(t *testing.T) {
+	t.Run("missing argument", func(t *testing.T) {
+		_, w, send := setupSnippetAgent(t, map[string]string{"fix": "Fix {{1}} then {{2}}."})
+		send("/fix one")
+		require.Len(t, w.errors, 1)
+		assert.Equal(t, codeInvalidArgument, w.errors[0].code)
+		assert.Contains(t, w.errors[0].message, "needs at least 2 argument(s), got 1")
+	})
+	t.Run("lookup failure", func(t *testing.T) {
+		svc, w, send := setupSnippetAgent(t, nil)
+		svc.Snippets = func(context.Context, string, string) (*leapmuxv1.Snippet, error) {
+			return nil, errors.New("hub unreachable")
+		}
+		send("/review")
+		require.Len(t, w.errors, 1)
+		assert.Contains(t, w.errors[0].message, "failed to look up snippet")
+	})
+}
@@ -4,6 +4,7 @@ import (
 	"context"
 
 	leapmuxv1 "github.com/leapmux/leapmux/generated/proto/leapmux/v1"
+	"github.com/leapmux/leapmux/internal/errcode"
 	db "github.com/leapmux/leapmux/internal/worker/generated/db"
 )
 
@@ -17,7 +18,7 @@ type startupCallbacks struct {
 	setMessage        func(label string)
 	broadcastStarting func(label string)
 	persistError      func(errMsg string)
-	broadcastFailed   func(errMsg string)
+	broadcastFailed   func(errMsg string, code errcode.Code)
 	registryFail      func(errMsg string)
 }
 
@@ -48,7 +49,7 @@ func (svc *Service) failStartup(gm gitModeResult, cause error, cb startupCallbac
 	}
 	errMsg := cause.Error()
 	cb.persistError(errMsg)
-	cb.broadcastFailed(errMsg)
+	cb.broadcastFailed(errMsg, errcode.Of(cause))
 	cb.registryFail(errMsg)
 }
 
@@ -62,8 +63,12 @@ func (svc *Service) agentStartupCallbacks(dbAgent *db.Agent, gitStatus *leapmuxv
 		setMessage:        func(label string) { svc.AgentStartup.setMessage(dbAgent.ID, label) },
 		broadcastStarting: func(label string) { svc.broadcastAgentStarting(dbAgent, label, nil) },
 		persistError:      func(errMsg string) { svc.persistAgentStartupError(dbAgent.ID, errMsg) },
-		broadcastFailed:   func(errMsg string) { svc.broadcastAgentFailed(dbAgent, errMsg, gitStatus) },
-		registryFail:      func(errMsg string) { svc.AgentStartup.fail(dbAgent.ID, errMsg) },
+		broadcastFailed: func(errMsg string, code errcode.Code) {
+			sc := buildAgentFailedStatus(dbAgent, errMsg, gitStatus)
+			sc.StartupErrorCode = string(code)
+			svc.broadcastStatusChange(dbAgent.ID, sc)
+		},
+		registryFail: func(errMsg string) { svc.AgentStartup.fail(dbAgent.ID, errMsg) },
 	}
 }
 
@@ -75,7 +80,7 @@ func (svc *Service) terminalStartupCallbacks(terminalID string) startupCallbacks
 		setMessage:        func(label string) { svc.TerminalStartup.setMessage(terminalID, label) },
 		broadcastStarting: func(label string) { svc.broadcastTerminalStarting(terminalID, label, nil) },
 		persistError:      func(errMsg string) { svc.persistTerminalStartupError(terminalID, errMsg) },
-		broadcastFailed:   func(errMsg string) { svc.broadcastTerminalFailed(terminalID, errMsg) },
+		broadcastFailed:   func(errMsg string, _ errcode.Code) { svc.broadcastTerminalFailed(terminalID, errMsg) },
 		registryFail:      func(errMsg string) { svc.TerminalStartup.fail(terminalID, errMsg) },
 	}
 }
@@ -7,6 +7,8 @@ import (
 
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
+
+	"github.com/leapmux/leapmux/internal/errcode"
 )
 
 // recordedCallbacks captures the order each callback fires in. The
@@ -23,7 +25,7 @@ func (rc *recordedCallbacks) hooks() startupCallbacks {
 		setMessage:        func(label string) { rc.events = append(rc.events, "setMessage:"+label) },
 		broadcastStarting: func(label string) { rc.events = append(rc.events, "broadcastStarting:"+label) },
 		persistError:      func(errMsg string) { rc.events = append(rc.events, "persistError:"+errMsg) },
-		broadcastFailed:   func(errMsg string) { rc.events = append(rc.events, "broadcastFailed:"+errMsg) },
+		broadcastFailed:   func(errMsg string, _ errcode.Code) { rc.events = append(rc.events, "broadcastFailed:"+errMsg) },
 		registryFail:      func(errMsg string) { rc.events = append(rc.events, "registryFail:"+errMsg) },
 	}
 }
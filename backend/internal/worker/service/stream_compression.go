@@ -0,0 +1,51 @@
+package service
+
+import (
+	leapmuxv1 "github.com/leapmux/leapmux/generated/proto/leapmux/v1"
+	"github.com/leapmux/leapmux/internal/util/msgcodec"
+	"google.golang.org/protobuf/proto"
+)
+
+// minCompressBytes is the smallest payload worth compressing. Below it the
+// zstd frame header eats most of the saving, and most keystroke echoes and
+// token deltas are far smaller.
+const minCompressBytes = 256
+
+// compressWatchEvent returns a copy of resp with its bulk payload --
+// TerminalData.data or AgentStreamChunk.delta -- zstd-compressed, or nil when
+// resp carries no such payload, the payload is too small, or compressing it
+// would not shrink it. resp itself is never modified: the fan-out still sends
+// it as is to the subscribers that did not ask for compression.
+//
+// Compression runs on plaintext inside the E2EE channel, so the ciphertext
+// length now depends on the payload's content. That is the trade an org makes
+// by turning WorkerStreamSettings.compress_output on.
+func compressWatchEvent(resp *leapmuxv1.WatchEventsResponse) *leapmuxv1.WatchEventsResponse {
+	var raw []byte
+	switch {
+	case resp.GetTerminalEvent().GetData() != nil:
+		raw = resp.GetTerminalEvent().GetData().GetData()
+	case resp.GetAgentEvent().GetStreamChunk() != nil:
+		raw = resp.GetAgentEvent().GetStreamChunk().GetDelta()
+	default:
+		return nil
+	}
+	if len(raw) < minCompressBytes {
+		return nil
+	}
+	compressed, compression := msgcodec.Compress(raw)
+	if len(compressed) >= len(raw) {
+		return nil
+	}
+
+	out := proto.CloneOf(resp)
+	if data := out.GetTerminalEvent().GetData(); data != nil {
+		data.Data = compressed
+		data.DataCompression = compression
+	} else {
+		chunk := out.GetAgentEvent().GetStreamChunk()
+		chunk.Delta = compressed
+		chunk.DeltaCompression = compression
+	}
+	return out
+}
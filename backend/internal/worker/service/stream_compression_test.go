@@ -0,0 +1,94 @@
+package service
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/protobuf/proto"
+
+	leapmuxv1 "github.com/leapmux/leapmux/generated/proto/leapmux/v1"
+	"github.com/leapmux/leapmux/internal/util/msgcodec"
+)
+
+const zstd = leapmuxv1.ContentCompression_CONTENT_COMPRESSION_ZSTD
+
+func terminalDataResponse(data []byte) *leapmuxv1.WatchEventsResponse {
+	return &leapmuxv1.WatchEventsResponse{
+		Event: &leapmuxv1.WatchEventsResponse_TerminalEvent{TerminalEvent: testTerminalEvent("term-1", data)},
+	}
+}
+
+// lastTerminalData decodes the TerminalData of the last event w received.
+func lastTerminalData(t *testing.T, w *testResponseWriter) *leapmuxv1.TerminalData {
+	t.Helper()
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	require.NotEmpty(t, w.streams)
+	var resp leapmuxv1.WatchEventsResponse
+	require.NoError(t, proto.Unmarshal(w.streams[len(w.streams)-1].GetPayload(), &resp))
+	return resp.GetTerminalEvent().GetData()
+}
+
+func TestCompressWatchEvent(t *testing.T) {
+	output := bytes.Repeat([]byte("drwxr-xr-x  2 user user 4096 Jan  1 00:00 dir\r\n"), 40)
+
+	t.Run("terminal data", func(t *testing.T) {
+		resp := terminalDataResponse(output)
+		out := compressWatchEvent(resp)
+		require.NotNil(t, out)
+		assert.Equal(t, output, resp.GetTerminalEvent().GetData().GetData(), "the original is left for uncompressed subscribers")
+
+		data := out.GetTerminalEvent().GetData()
+		assert.Equal(t, zstd, data.GetDataCompression())
+		assert.Less(t, len(data.GetData()), len(output))
+		plain, err := msgcodec.Decompress(data.GetData(), data.GetDataCompression())
+		require.NoError(t, err)
+		assert.Equal(t, output, plain)
+	})
+
+	t.Run("agent stream chunk", func(t *testing.T) {
+		out := compressWatchEvent(&leapmuxv1.WatchEventsResponse{
+			Event: &leapmuxv1.WatchEventsResponse_AgentEvent{AgentEvent: &leapmuxv1.AgentEvent{
+				AgentId: "agent-1",
+				Event:   &leapmuxv1.AgentEvent_StreamChunk{StreamChunk: &leapmuxv1.AgentStreamChunk{MessageId: "m-1", Delta: output}},
+			}},
+		})
+		require.NotNil(t, out)
+		chunk := out.GetAgentEvent().GetStreamChunk()
+		assert.Equal(t, "m-1", chunk.GetMessageId())
+		assert.Equal(t, zstd, chunk.GetDeltaCompression())
+	})
+
+	t.Run("not worth it", func(t *testing.T) {
+		assert.Nil(t, compressWatchEvent(terminalDataResponse([]byte("ls\r\n"))), "too small")
+		assert.Nil(t, compressWatchEvent(&leapmuxv1.WatchEventsResponse{
+			Event: &leapmuxv1.WatchEventsResponse_AgentEvent{AgentEvent: testAgentEvent("agent-1")},
+		}), "no bulk payload")
+	})
+}
+
+func TestBroadcast_CompressesOnlyForAcceptingChannels(t *testing.T) {
+	m := NewWatcherManager()
+	lean := &testResponseWriter{channelID: "ch-lean"}
+	plain := &testResponseWriter{channelID: "ch-plain"}
+	m.SetTerminalWatches("ch-lean", []string{"term-1"}, lean)
+	m.SetTerminalWatches("ch-plain", []string{"term-1"}, plain)
+	m.SetAcceptCompression("ch-lean", true)
+	output := bytes.Repeat([]byte("building target 42/42\r\n"), 40)
+
+	// Accepting alone is not enough: the org has not turned compression on.
+	m.BroadcastTerminalEvent("term-1", testTerminalEvent("term-1", output))
+	assert.Equal(t, leapmuxv1.ContentCompression_CONTENT_COMPRESSION_UNSPECIFIED, lastTerminalData(t, lean).GetDataCompression())
+
+	m.SetCompressOutput(true)
+	m.BroadcastTerminalEvent("term-1", testTerminalEvent("term-1", output))
+	assert.Equal(t, zstd, lastTerminalData(t, lean).GetDataCompression())
+	assert.Equal(t, output, lastTerminalData(t, plain).GetData())
+	assert.Len(t, lean.streams, 2)
+	assert.Len(t, plain.streams, 2, "every subscriber gets the event exactly once")
+
+	m.UnwatchAll("ch-lean")
+	assert.False(t, m.compressesFor("ch-lean"))
+}
@@ -0,0 +1,239 @@
+package service
+
+import (
+	"context"
+	"log/slog"
+	"sync"
+	"time"
+
+	leapmuxv1 "github.com/leapmux/leapmux/generated/proto/leapmux/v1"
+	"github.com/leapmux/leapmux/internal/util/periodic"
+	"github.com/leapmux/leapmux/internal/util/userid"
+	"github.com/leapmux/leapmux/internal/worker/agent"
+)
+
+const (
+	// stuckTurnInterval is how often running agents are checked for
+	// stalled turns.
+	stuckTurnInterval = 30 * time.Second
+	// maxStuckTurnRestarts bounds the restarts one turn gets under
+	// StuckTurnActionRestart. A turn that stalls again after the last one
+	// is interrupted instead, so a hang the restart cannot cure does not
+	// loop forever.
+	maxStuckTurnRestarts = 2
+	// stuckTurnRetryPrompt is the synthetic turn a restarted agent is sent
+	// to pick its work back up.
+	stuckTurnRetryPrompt = "Continue."
+)
+
+// StuckTurnPolicy watches for agents stuck mid-turn: input was delivered,
+// but the agent has produced no output and no result since. A turn
+// waiting on a control request is waiting on the user, not stuck.
+type StuckTurnPolicy struct {
+	// After is how long a turn must go without output to count as stuck.
+	// Zero disables the watchdog.
+	After time.Duration
+	// Action is what the watchdog does with a stuck turn. Empty notifies.
+	Action StuckTurnAction
+}
+
+// StuckTurnAction is what the watchdog does with a stuck turn. Each one
+// first posts a turn_stalled notification in the chat saying so.
+type StuckTurnAction string
+
+const (
+	// StuckTurnActionNotify only posts the notification.
+	StuckTurnActionNotify StuckTurnAction = "notify"
+	// StuckTurnActionInterrupt interrupts the turn, as the user's stop
+	// button does.
+	StuckTurnActionInterrupt StuckTurnAction = "interrupt"
+	// StuckTurnActionRestart stops the agent's subprocess, resumes its
+	// session and asks it to continue.
+	StuckTurnActionRestart StuckTurnAction = "restart"
+)
+
+// stuckTurn is what the watchdog did about an agent's current turn.
+type stuckTurn struct {
+	mu sync.Mutex
+	// handled is the activity stamp of the stall last acted on; a stall is
+	// acted on once.
+	handled time.Time
+	// restarts counts the restarts the turn has had.
+	restarts int64
+	// restarting is set from a restart's stop until its retry prompt is
+	// sent, while the agent is not mid-turn but the turn is not over.
+	restarting bool
+}
+
+// agentSilentFor returns how long agentID's current turn has gone without
+// output, with the time of its last activity, or false when it is not
+// mid-turn.
+func (h *OutputHandler) agentSilentFor(agentID string) (time.Duration, time.Time, bool) {
+	a := h.agentActivity(agentID)
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	if !a.inTurn {
+		return 0, time.Time{}, false
+	}
+	return h.now().Sub(a.last), a.last, true
+}
+
+// StartStuckTurnLoop starts the background loop that watches for stuck
+// turns. It does nothing when the policy is disabled.
+func (svc *Service) StartStuckTurnLoop(ctx context.Context) {
+	if svc.StuckTurn.After <= 0 {
+		return
+	}
+	periodic.Start(ctx, periodic.Schedule{Interval: stuckTurnInterval, SkipFirstRun: true}, func(context.Context) {
+		svc.CheckStuckTurns()
+	})
+}
+
+// CheckStuckTurns acts on every running agent whose turn has gone
+// StuckTurn.After without output, and forgets the watchdog state of
+// agents no longer mid-turn.
+func (svc *Service) CheckStuckTurns() {
+	running := make(map[string]bool)
+	for _, agentID := range svc.Agents.ListAgentIDs() {
+		running[agentID] = true
+		silent, last, inTurn := svc.Output.agentSilentFor(agentID)
+		if !inTurn {
+			svc.forgetStuckTurn(agentID)
+			continue
+		}
+		if silent < svc.StuckTurn.After {
+			continue
+		}
+		svc.handleStuckTurn(agentID, silent, last)
+	}
+	svc.stuckTurns.Range(func(k, _ any) bool {
+		if !running[k.(string)] {
+			svc.forgetStuckTurn(k.(string))
+		}
+		return true
+	})
+}
+
+// forgetStuckTurn drops the watchdog state of agentID's turn, unless the
+// watchdog is restarting it.
+func (svc *Service) forgetStuckTurn(agentID string) {
+	v, ok := svc.stuckTurns.Load(agentID)
+	if !ok {
+		return
+	}
+	st := v.(*stuckTurn)
+	st.mu.Lock()
+	defer st.mu.Unlock()
+	if !st.restarting {
+		svc.stuckTurns.CompareAndDelete(agentID, st)
+	}
+}
+
+// handleStuckTurn posts the stall in agentID's chat and takes the
+// policy's action, unless this stall was already handled or the turn is
+// waiting on the user.
+func (svc *Service) handleStuckTurn(agentID string, silent time.Duration, last time.Time) {
+	if pending, err := svc.Queries.ListControlRequestsByAgentID(bgCtx(), agentID); err != nil {
+		slog.Warn("stuck turn: failed to list control requests", "agent_id", agentID, "error", err)
+		return
+	} else if len(pending) > 0 {
+		return
+	}
+	dbAgent, err := svc.Queries.GetAgentByID(bgCtx(), agentID)
+	if err != nil {
+		slog.Warn("stuck turn: failed to load agent", "agent_id", agentID, "error", err)
+		return
+	}
+
+	v, _ := svc.stuckTurns.LoadOrStore(agentID, &stuckTurn{})
+	st := v.(*stuckTurn)
+	st.mu.Lock()
+	if st.handled.Equal(last) {
+		st.mu.Unlock()
+		return
+	}
+	st.handled = last
+	action := svc.StuckTurn.Action
+	if action == "" {
+		action = StuckTurnActionNotify
+	}
+	if action == StuckTurnActionRestart {
+		if st.restarts >= maxStuckTurnRestarts {
+			action = StuckTurnActionInterrupt
+		} else {
+			st.restarts++
+		}
+	}
+	payload := agent.TurnStalledPayload{
+		Action:        string(action),
+		SilentSeconds: int64(silent / time.Second),
+	}
+	if action == StuckTurnActionRestart {
+		payload.Attempt = st.restarts
+	}
+	st.mu.Unlock()
+
+	slog.Warn("stuck turn", "agent_id", agentID, "silent", silent, "action", payload.Action)
+	svc.Output.PersistLeapMuxNotification(agentID, dbAgent.AgentProvider, agent.NotificationContent(payload))
+	// Persisting the notification counts as activity; the stall stays
+	// handled until the agent itself does something.
+	if _, at, inTurn := svc.Output.agentSilentFor(agentID); inTurn {
+		st.mu.Lock()
+		st.handled = at
+		st.mu.Unlock()
+		last = at
+	}
+	switch action {
+	case StuckTurnActionInterrupt:
+		// Off the loop: an interrupt waits for the agent to answer.
+		go func() {
+			detail := "no output for " + silent.Truncate(time.Second).String()
+			if err := svc.interruptAgent(agentID, userid.UserID{}, leapmuxv1.InterruptReason_INTERRUPT_REASON_WATCHDOG, detail); err != nil {
+				slog.Warn("stuck turn: failed to interrupt agent", "agent_id", agentID, "error", err)
+			}
+		}()
+	case StuckTurnActionRestart:
+		go svc.restartStuckAgent(agentID, last)
+	}
+}
+
+// restartStuckAgent stops agentID's subprocess and sends the retry prompt,
+// which resumes the session on a fresh one. It holds the per-agent
+// lifecycle lock while stopping, and gives up if the agent has done
+// anything since the stall was seen.
+func (svc *Service) restartStuckAgent(agentID string, last time.Time) {
+	unlock := svc.Agents.LockAgent(agentID)
+	if !svc.Agents.HasAgent(agentID) {
+		unlock()
+		return
+	}
+	if _, at, inTurn := svc.Output.agentSilentFor(agentID); !inTurn || !at.Equal(last) {
+		unlock()
+		return
+	}
+	dbAgent, err := svc.Queries.GetAgentByID(bgCtx(), agentID)
+	if err != nil {
+		unlock()
+		slog.Warn("stuck turn: failed to load agent", "agent_id", agentID, "error", err)
+		return
+	}
+	// Discard the closing streams' output: the stop is ours, not a crash,
+	// and must not leave an error message in the chat.
+	svc.Agents.DiscardOutputAndStopAgent(agentID)
+	svc.Output.ResetAgentActivity(agentID)
+	// The retried turn is watched afresh; only its restart count carries
+	// over.
+	v, _ := svc.stuckTurns.LoadOrStore(agentID, &stuckTurn{})
+	st := v.(*stuckTurn)
+	st.mu.Lock()
+	st.handled = time.Time{}
+	st.restarting = true
+	st.mu.Unlock()
+	svc.broadcastAgentInactive(&dbAgent)
+	unlock()
+
+	svc.sendSyntheticUserMessage(agentID, stuckTurnRetryPrompt, leapmuxv1.MarkType_MARK_TYPE_UNSPECIFIED)
+	st.mu.Lock()
+	st.restarting = false
+	st.mu.Unlock()
+}
@@ -0,0 +1,119 @@
+package service
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	leapmuxv1 "github.com/leapmux/leapmux/generated/proto/leapmux/v1"
+	"github.com/leapmux/leapmux/internal/worker/agent"
+	db "github.com/leapmux/leapmux/internal/worker/generated/db"
+)
+
+func stalledTurns(t *testing.T, svc *Service) []map[string]interface{} {
+	t.Helper()
+	return findNotificationsByType(readAllNotifications(t, svc.Queries, "agent-1"), agent.NotificationTypeTurnStalled)
+}
+
+func TestCheckStuckTurns_NotifiesOncePerStall(t *testing.T) {
+	svc, _, _ := setupTestService(t, withWorkspaces("ws-1"))
+	svc.StuckTurn = StuckTurnPolicy{After: 10 * time.Minute, Action: StuckTurnActionNotify}
+	clock := startIdleParkAgent(t, svc)
+	svc.Output.beginAgentTurn("agent-1")
+
+	*clock = clock.Add(9 * time.Minute)
+	svc.CheckStuckTurns()
+	require.Empty(t, stalledTurns(t, svc))
+
+	*clock = clock.Add(time.Minute)
+	svc.CheckStuckTurns()
+	notes := stalledTurns(t, svc)
+	require.Len(t, notes, 1)
+	assert.Equal(t, "notify", notes[0]["action"])
+	assert.EqualValues(t, 600, notes[0]["silent_seconds"])
+
+	// The same stall is not reported again, however long it lasts.
+	*clock = clock.Add(time.Hour)
+	svc.CheckStuckTurns()
+	require.Len(t, stalledTurns(t, svc), 1)
+
+	// Output ends the stall; a new one is reported anew.
+	svc.Output.touchAgent("agent-1")
+	*clock = clock.Add(15 * time.Minute)
+	svc.CheckStuckTurns()
+	notes = stalledTurns(t, svc)
+	require.Len(t, notes, 2)
+	assert.EqualValues(t, 900, notes[1]["silent_seconds"])
+
+	// A turn that ended is never stuck.
+	svc.Output.endAgentTurn("agent-1")
+	*clock = clock.Add(time.Hour)
+	svc.CheckStuckTurns()
+	assert.Len(t, stalledTurns(t, svc), 2)
+}
+
+func TestCheckStuckTurns_SkipsTurnAwaitingControlRequest(t *testing.T) {
+	svc, _, _ := setupTestService(t, withWorkspaces("ws-1"))
+	svc.StuckTurn = StuckTurnPolicy{After: 10 * time.Minute, Action: StuckTurnActionInterrupt}
+	clock := startIdleParkAgent(t, svc)
+	svc.Output.beginAgentTurn("agent-1")
+	require.NoError(t, svc.Queries.CreateControlRequest(context.Background(), db.CreateControlRequestParams{
+		AgentID: "agent-1", RequestID: "req-1", Payload: []byte("{}"), ClaimToken: "tok",
+	}))
+
+	*clock = clock.Add(time.Hour)
+	svc.CheckStuckTurns()
+	assert.Empty(t, stalledTurns(t, svc), "the turn is waiting on the user")
+}
+
+func TestCheckStuckTurns_RestartsThenInterrupts(t *testing.T) {
+	svc, _, _ := setupTestService(t, withWorkspaces("ws-1"))
+	svc.StuckTurn = StuckTurnPolicy{After: 10 * time.Minute, Action: StuckTurnActionRestart}
+	clock := startIdleParkAgent(t, svc)
+	starts := make(chan struct{}, maxStuckTurnRestarts+1)
+	svc.startAgentFn = mockAgentStarter(t, svc, func(agent.Options) { starts <- struct{}{} })
+	svc.Output.beginAgentTurn("agent-1")
+
+	retries := func() int {
+		msgs, err := svc.Queries.ListAllMessagesByAgentID(context.Background(), db.ListAllMessagesByAgentIDParams{AgentID: "agent-1"})
+		require.NoError(t, err)
+		n := 0
+		for _, m := range msgs {
+			if m.Source == leapmuxv1.MessageSource_MESSAGE_SOURCE_USER {
+				n++
+			}
+		}
+		return n
+	}
+
+	for attempt := 1; attempt <= maxStuckTurnRestarts; attempt++ {
+		*clock = clock.Add(11 * time.Minute)
+		svc.CheckStuckTurns()
+		select {
+		case <-starts:
+		case <-time.After(5 * time.Second):
+			t.Fatalf("restart %d did not start the agent again", attempt)
+		}
+		require.Eventually(t, func() bool {
+			_, _, inTurn := svc.Output.agentSilentFor("agent-1")
+			return inTurn && retries() == attempt
+		}, 5*time.Second, 20*time.Millisecond, "restart %d sends the retry prompt", attempt)
+		notes := stalledTurns(t, svc)
+		require.Len(t, notes, attempt)
+		assert.Equal(t, "restart", notes[attempt-1]["action"])
+		assert.EqualValues(t, attempt, notes[attempt-1]["attempt"])
+		require.True(t, svc.Agents.HasAgent("agent-1"))
+	}
+	t.Cleanup(func() { svc.Agents.StopAgent("agent-1") })
+
+	// Out of restarts: the next stall is interrupted instead.
+	*clock = clock.Add(11 * time.Minute)
+	svc.CheckStuckTurns()
+	notes := stalledTurns(t, svc)
+	require.Len(t, notes, maxStuckTurnRestarts+1)
+	assert.Equal(t, "interrupt", notes[maxStuckTurnRestarts]["action"])
+	assert.Equal(t, maxStuckTurnRestarts, retries())
+}
@@ -0,0 +1,140 @@
+package service
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"log/slog"
+
+	leapmuxv1 "github.com/leapmux/leapmux/generated/proto/leapmux/v1"
+	"github.com/leapmux/leapmux/internal/util/timefmt"
+	"github.com/leapmux/leapmux/internal/util/userid"
+	"github.com/leapmux/leapmux/internal/worker/agent"
+	"github.com/leapmux/leapmux/internal/worker/channel"
+	db "github.com/leapmux/leapmux/internal/worker/generated/db"
+)
+
+// Sub-agent runs are bookkeeping alongside the transcript: a failed write
+// costs the run listing and cost report accuracy, not the turn, so the
+// helpers below log rather than surface their errors.
+
+// startSubAgentRun records a sub-agent launch against the turn in flight,
+// which is the latest delivered user message.
+func (h *OutputHandler) startSubAgentRun(agentID, toolUseID, parentToolUseID, subAgentType, description string) {
+	var turnMessageID string
+	turn, err := h.queries.GetLatestAgentTurnModel(bgCtx(), agentID)
+	switch {
+	case err == nil:
+		turnMessageID = turn.MessageID
+	case !errors.Is(err, sql.ErrNoRows):
+		slog.Warn("turn lookup for sub-agent run failed", "agent_id", agentID, "error", err)
+	}
+	if err := h.queries.CreateSubAgentRun(bgCtx(), db.CreateSubAgentRunParams{
+		AgentID:         agentID,
+		ToolUseID:       toolUseID,
+		ParentToolUseID: parentToolUseID,
+		TurnMessageID:   turnMessageID,
+		SubagentType:    subAgentType,
+		Description:     description,
+	}); err != nil {
+		slog.Warn("failed to record sub-agent run", "agent_id", agentID, "tool_use_id", toolUseID, "error", err)
+	}
+}
+
+func (h *OutputHandler) addSubAgentUsage(agentID, toolUseID string, usage agent.SubAgentUsage) {
+	if err := h.queries.AddSubAgentRunUsage(bgCtx(), db.AddSubAgentRunUsageParams{
+		InputTokens:              usage.InputTokens,
+		OutputTokens:             usage.OutputTokens,
+		CacheCreationInputTokens: usage.CacheCreationInputTokens,
+		CacheReadInputTokens:     usage.CacheReadInputTokens,
+		AgentID:                  agentID,
+		ToolUseID:                toolUseID,
+	}); err != nil {
+		slog.Warn("failed to record sub-agent usage", "agent_id", agentID, "tool_use_id", toolUseID, "error", err)
+	}
+}
+
+func (h *OutputHandler) endSubAgentRun(agentID, toolUseID, status string) {
+	if err := h.queries.EndSubAgentRun(bgCtx(), db.EndSubAgentRunParams{
+		Status:    status,
+		AgentID:   agentID,
+		ToolUseID: toolUseID,
+	}); err != nil {
+		slog.Warn("failed to end sub-agent run", "agent_id", agentID, "tool_use_id", toolUseID, "error", err)
+	}
+}
+
+func subAgentRunStatusToProto(status string) leapmuxv1.SubAgentRunStatus {
+	switch status {
+	case "running":
+		return leapmuxv1.SubAgentRunStatus_SUB_AGENT_RUN_STATUS_RUNNING
+	case agent.SubAgentRunCompleted:
+		return leapmuxv1.SubAgentRunStatus_SUB_AGENT_RUN_STATUS_COMPLETED
+	case agent.SubAgentRunFailed:
+		return leapmuxv1.SubAgentRunStatus_SUB_AGENT_RUN_STATUS_FAILED
+	case agent.SubAgentRunInterrupted:
+		return leapmuxv1.SubAgentRunStatus_SUB_AGENT_RUN_STATUS_INTERRUPTED
+	}
+	return leapmuxv1.SubAgentRunStatus_SUB_AGENT_RUN_STATUS_UNSPECIFIED
+}
+
+func subAgentRunToProto(row db.AgentSubAgentRun) *leapmuxv1.SubAgentRun {
+	run := &leapmuxv1.SubAgentRun{
+		ToolUseId:       row.ToolUseID,
+		ParentToolUseId: row.ParentToolUseID,
+		TurnMessageId:   row.TurnMessageID,
+		SubagentType:    row.SubagentType,
+		Description:     row.Description,
+		Status:          subAgentRunStatusToProto(row.Status),
+		Usage: &leapmuxv1.SubAgentUsage{
+			InputTokens:              row.InputTokens,
+			OutputTokens:             row.OutputTokens,
+			CacheCreationInputTokens: row.CacheCreationInputTokens,
+			CacheReadInputTokens:     row.CacheReadInputTokens,
+		},
+		StartedAt: timefmt.Format(row.StartedAt.Time),
+	}
+	if row.EndedAt.Valid {
+		run.EndedAt = timefmt.Format(row.EndedAt.Time)
+	}
+	return run
+}
+
+// subAgentUsageByTurn sums agentID's sub-agent usage per parent turn, keyed
+// by the turn's user message id.
+func (svc *Service) subAgentUsageByTurn(ctx context.Context, agentID string) (map[string]*leapmuxv1.SubAgentUsage, error) {
+	rows, err := svc.Queries.ListSubAgentUsageByTurn(ctx, agentID)
+	if err != nil {
+		return nil, err
+	}
+	usage := make(map[string]*leapmuxv1.SubAgentUsage, len(rows))
+	for _, row := range rows {
+		usage[row.TurnMessageID] = &leapmuxv1.SubAgentUsage{
+			InputTokens:              row.InputTokens,
+			OutputTokens:             row.OutputTokens,
+			CacheCreationInputTokens: row.CacheCreationInputTokens,
+			CacheReadInputTokens:     row.CacheReadInputTokens,
+		}
+	}
+	return usage, nil
+}
+
+func registerSubAgentRunHandlers(d registrar, svc *Service) {
+	registerAgentGated(d, "ListSubAgentRuns",
+		func(ctx context.Context, _ userid.UserID, r *leapmuxv1.ListSubAgentRunsRequest, dbAgent db.Agent, sender channel.ResponseWriter) {
+			rows, err := svc.Queries.ListSubAgentRuns(ctx, db.ListSubAgentRunsParams{
+				AgentID:       dbAgent.ID,
+				TurnMessageID: r.GetTurnMessageId(),
+			})
+			if err != nil {
+				slog.Error("failed to list sub-agent runs", "agent_id", dbAgent.ID, "error", err)
+				sendInternalError(sender, "failed to list sub-agent runs")
+				return
+			}
+			runs := make([]*leapmuxv1.SubAgentRun, len(rows))
+			for i, row := range rows {
+				runs[i] = subAgentRunToProto(row)
+			}
+			sendProtoResponse(sender, &leapmuxv1.ListSubAgentRunsResponse{Runs: runs})
+		})
+}
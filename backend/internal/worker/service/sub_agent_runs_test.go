@@ -0,0 +1,69 @@
+package service
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/protobuf/proto"
+
+	leapmuxv1 "github.com/leapmux/leapmux/generated/proto/leapmux/v1"
+	"github.com/leapmux/leapmux/internal/worker/agent"
+)
+
+func TestSubAgentRuns_ListedPerTurn(t *testing.T) {
+	svc, d, w := setupTestService(t, withWorkspaces("ws-1"))
+	seedModelAgent(t, svc.Queries, "agent-1", "opus")
+	sink := svc.Output.NewSink("agent-1", leapmuxv1.AgentProvider_AGENT_PROVIDER_CLAUDE_CODE)
+
+	svc.recordTurnModel("agent-1", "msg-1", turnRouting{current: "opus", base: "opus"})
+	sink.StartSubAgentRun("task-1", "", "explorer", "scan the repo")
+	sink.AddSubAgentUsage("task-1", agent.SubAgentUsage{InputTokens: 10, OutputTokens: 3})
+	sink.AddSubAgentUsage("task-1", agent.SubAgentUsage{InputTokens: 20, OutputTokens: 4})
+	sink.EndSubAgentRun("task-1", agent.SubAgentRunCompleted)
+
+	svc.recordTurnModel("agent-1", "msg-2", turnRouting{current: "opus", base: "opus"})
+	sink.StartSubAgentRun("task-2", "", "", "")
+
+	dispatch(d, "ListSubAgentRuns", &leapmuxv1.ListSubAgentRunsRequest{AgentId: "agent-1", TurnMessageId: "msg-1"}, w)
+	require.Empty(t, w.errors)
+	var resp leapmuxv1.ListSubAgentRunsResponse
+	require.NoError(t, proto.Unmarshal(w.responses[0].GetPayload(), &resp))
+	require.Len(t, resp.GetRuns(), 1)
+	run := resp.GetRuns()[0]
+	assert.Equal(t, "task-1", run.GetToolUseId())
+	assert.Equal(t, "explorer", run.GetSubagentType())
+	assert.Equal(t, leapmuxv1.SubAgentRunStatus_SUB_AGENT_RUN_STATUS_COMPLETED, run.GetStatus())
+	assert.Equal(t, int64(30), run.GetUsage().GetInputTokens())
+	assert.Equal(t, int64(7), run.GetUsage().GetOutputTokens())
+	assert.NotEmpty(t, run.GetEndedAt())
+
+	dispatch(d, "ListSubAgentRuns", &leapmuxv1.ListSubAgentRunsRequest{AgentId: "agent-1"}, w)
+	require.NoError(t, proto.Unmarshal(w.responses[1].GetPayload(), &resp))
+	require.Len(t, resp.GetRuns(), 2)
+	assert.Equal(t, "msg-2", resp.GetRuns()[1].GetTurnMessageId())
+	assert.Equal(t, leapmuxv1.SubAgentRunStatus_SUB_AGENT_RUN_STATUS_RUNNING, resp.GetRuns()[1].GetStatus())
+	assert.Empty(t, resp.GetRuns()[1].GetEndedAt())
+}
+
+func TestListAgentTurnModels_ReportsSubAgentUsage(t *testing.T) {
+	svc, d, w := setupTestService(t, withWorkspaces("ws-1"))
+	seedModelAgent(t, svc.Queries, "agent-1", "opus")
+	sink := svc.Output.NewSink("agent-1", leapmuxv1.AgentProvider_AGENT_PROVIDER_CLAUDE_CODE)
+
+	svc.recordTurnModel("agent-1", "msg-1", turnRouting{current: "opus", base: "opus"})
+	sink.StartSubAgentRun("task-1", "", "", "")
+	sink.StartSubAgentRun("task-2", "task-1", "", "")
+	sink.AddSubAgentUsage("task-1", agent.SubAgentUsage{OutputTokens: 5})
+	sink.AddSubAgentUsage("task-2", agent.SubAgentUsage{OutputTokens: 6, CacheReadInputTokens: 2})
+	svc.recordTurnModel("agent-1", "msg-2", turnRouting{current: "opus", base: "opus"})
+
+	dispatch(d, "ListAgentTurnModels", &leapmuxv1.ListAgentTurnModelsRequest{AgentId: "agent-1"}, w)
+	require.Empty(t, w.errors)
+	var resp leapmuxv1.ListAgentTurnModelsResponse
+	require.NoError(t, proto.Unmarshal(w.responses[0].GetPayload(), &resp))
+	require.Len(t, resp.GetTurns(), 2)
+	assert.Equal(t, int64(11), resp.GetTurns()[0].GetSubagentUsage().GetOutputTokens())
+	assert.Equal(t, int64(2), resp.GetTurns()[0].GetSubagentUsage().GetCacheReadInputTokens())
+	assert.Nil(t, resp.GetTurns()[1].GetSubagentUsage(), "a turn without sub-agents reports none")
+}
@@ -0,0 +1,78 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"unicode/utf8"
+
+	leapmuxv1 "github.com/leapmux/leapmux/generated/proto/leapmux/v1"
+	"github.com/leapmux/leapmux/internal/util/userid"
+	"github.com/leapmux/leapmux/internal/worker/agent"
+	"github.com/leapmux/leapmux/internal/worker/channel"
+)
+
+// maxSystemPromptLen mirrors the hub's cap on a library prompt version.
+const maxSystemPromptLen = 64 << 10
+
+// validateSystemPromptRef checks an OpenAgent system prompt reference. A nil
+// ref is valid; otherwise the provider must accept one and the content must
+// be the text the hub library serves.
+func validateSystemPromptRef(provider leapmuxv1.AgentProvider, ref *leapmuxv1.SystemPromptRef) error {
+	if ref == nil {
+		return nil
+	}
+	if !agent.ProviderFor(provider).SupportsSystemPrompt() {
+		return errors.New("this agent provider does not accept a system prompt")
+	}
+	switch {
+	case ref.GetId() == "":
+		return errors.New("system prompt id is required")
+	case ref.GetContent() == "":
+		return errors.New("system prompt content is required")
+	case len(ref.GetContent()) > maxSystemPromptLen:
+		return errors.New("system prompt must be at most 64 KiB")
+	case !utf8.ValidString(ref.GetContent()):
+		return errors.New("system prompt must be valid UTF-8")
+	}
+	return nil
+}
+
+func registerSystemPromptHandlers(d registrar, svc *Service) {
+	// ListSystemPromptUsage reports which open agents run a library prompt,
+	// so the library can show where a prompt is in use before it is changed
+	// or deleted. Like ListAllAgents it filters by AccessibleSet().
+	registerSetFiltered(d, "ListSystemPromptUsage", func(ctx context.Context, _ userid.UserID, req *leapmuxv1.InnerRpcRequest, sender channel.ResponseWriter) {
+		var r leapmuxv1.ListSystemPromptUsageRequest
+		if err := unmarshalRequest(req, &r); err != nil {
+			sendInvalidArgument(sender, "invalid request")
+			return
+		}
+
+		rows, err := svc.Queries.ListOpenAgentSystemPrompts(ctx)
+		if err != nil {
+			slog.Error("failed to list system prompt usage", "error", err)
+			sendInternalError(sender, "failed to list system prompt usage")
+			return
+		}
+		accessible := svc.AuthorizerFor(sender.ChannelID()).AccessibleSet()
+		usages := make([]*leapmuxv1.SystemPromptUsage, 0, len(rows))
+		for _, row := range rows {
+			if !accessible[row.WorkspaceID] {
+				continue
+			}
+			if r.GetSystemPromptId() != "" && row.PromptID != r.GetSystemPromptId() {
+				continue
+			}
+			usages = append(usages, &leapmuxv1.SystemPromptUsage{
+				AgentId:        row.ID,
+				WorkspaceId:    row.WorkspaceID,
+				Title:          row.Title,
+				SystemPromptId: row.PromptID,
+				Version:        uint32(row.PromptVersion),
+				Running:        svc.Agents.HasAgent(row.ID),
+			})
+		}
+		sendProtoResponse(sender, &leapmuxv1.ListSystemPromptUsageResponse{Usages: usages})
+	})
+}
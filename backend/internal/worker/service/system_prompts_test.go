@@ -0,0 +1,96 @@
+package service
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	leapmuxv1 "github.com/leapmux/leapmux/generated/proto/leapmux/v1"
+	"github.com/leapmux/leapmux/internal/worker/agent"
+	db "github.com/leapmux/leapmux/internal/worker/generated/db"
+)
+
+func TestOpenAgent_LaunchesWithSystemPrompt(t *testing.T) {
+	svc, d, w := setupTestService(t, withWorkspaces("ws-1"))
+	launched := make(chan agent.Options, 1)
+	svc.startAgentFn = func(_ context.Context, opts agent.Options, _ agent.OutputSink) (map[string]string, error) {
+		launched <- opts
+		return map[string]string{}, nil
+	}
+
+	dispatch(d, "OpenAgent", &leapmuxv1.OpenAgentRequest{
+		WorkspaceId:     "ws-1",
+		WorkingDir:      t.TempDir(),
+		AgentProvider:   claudeCode,
+		SystemPromptRef: &leapmuxv1.SystemPromptRef{Id: "sp-1", Version: 3, Content: "Be terse."},
+	}, w)
+	require.Empty(t, w.errors)
+	resp := decodeResponse[leapmuxv1.OpenAgentResponse](t, w)
+
+	select {
+	case opts := <-launched:
+		assert.Equal(t, "Be terse.", opts.SystemPrompt)
+	case <-time.After(5 * time.Second):
+		t.Fatal("agent was not launched")
+	}
+	row, err := svc.Queries.GetAgentSystemPrompt(context.Background(), resp.GetAgent().GetId())
+	require.NoError(t, err)
+	assert.Equal(t, "sp-1", row.PromptID)
+	assert.Equal(t, int64(3), row.PromptVersion)
+	assert.Equal(t, "Be terse.", svc.baseAgentOptions(row.AgentID, "/w", claudeCode).SystemPrompt,
+		"a relaunch keeps the prompt the agent was opened with")
+}
+
+func TestOpenAgent_RejectsInvalidSystemPrompt(t *testing.T) {
+	for name, tc := range map[string]struct {
+		provider leapmuxv1.AgentProvider
+		ref      *leapmuxv1.SystemPromptRef
+	}{
+		"unsupported provider": {leapmuxv1.AgentProvider_AGENT_PROVIDER_CODEX, &leapmuxv1.SystemPromptRef{Id: "sp-1", Version: 1, Content: "x"}},
+		"missing id":           {claudeCode, &leapmuxv1.SystemPromptRef{Version: 1, Content: "x"}},
+		"empty content":        {claudeCode, &leapmuxv1.SystemPromptRef{Id: "sp-1", Version: 1}},
+	} {
+		t.Run(name, func(t *testing.T) {
+			_, d, w := setupTestService(t, withWorkspaces("ws-1"))
+			dispatch(d, "OpenAgent", &leapmuxv1.OpenAgentRequest{
+				WorkspaceId:     "ws-1",
+				WorkingDir:      t.TempDir(),
+				AgentProvider:   tc.provider,
+				SystemPromptRef: tc.ref,
+			}, w)
+			require.Len(t, w.errors, 1)
+			assert.Equal(t, codeInvalidArgument, w.errors[0].code)
+		})
+	}
+}
+
+func TestListSystemPromptUsage(t *testing.T) {
+	svc, d, w := setupTestService(t, withWorkspaces("ws-1"))
+	ctx := context.Background()
+	used := seedGuardedAgent(t, svc, "")
+	require.NoError(t, svc.Queries.CreateAgent(ctx, db.CreateAgentParams{
+		ID:            "agent-2",
+		WorkspaceID:   "ws-1",
+		WorkingDir:    t.TempDir(),
+		AgentProvider: claudeProvider,
+	}))
+	require.NoError(t, svc.Queries.CreateAgentSystemPrompt(ctx, db.CreateAgentSystemPromptParams{
+		AgentID: used.ID, PromptID: "sp-1", PromptVersion: 2, Content: "Be terse.",
+	}))
+
+	dispatch(d, "ListSystemPromptUsage", &leapmuxv1.ListSystemPromptUsageRequest{SystemPromptId: "sp-1"}, w)
+	require.Empty(t, w.errors)
+	resp := decodeResponse[leapmuxv1.ListSystemPromptUsageResponse](t, w)
+	require.Len(t, resp.GetUsages(), 1)
+	assert.Equal(t, used.ID, resp.GetUsages()[0].GetAgentId())
+	assert.Equal(t, uint32(2), resp.GetUsages()[0].GetVersion())
+	assert.False(t, resp.GetUsages()[0].GetRunning())
+
+	w.responses = nil
+	dispatch(d, "ListSystemPromptUsage", &leapmuxv1.ListSystemPromptUsageRequest{SystemPromptId: "sp-other"}, w)
+	require.Empty(t, w.errors)
+	assert.Empty(t, decodeResponse[leapmuxv1.ListSystemPromptUsageResponse](t, w).GetUsages())
+}
@@ -1,6 +1,8 @@
 package service
 
 import (
+	"bytes"
+	"cmp"
 	"context"
 	"errors"
 	"fmt"
@@ -63,15 +65,31 @@ func registerTerminalHandlers(d registrar, svc *Service) {
 				rows = 25
 			}
 
+			// A named profile fills in what the request leaves empty.
+			var profile *leapmuxv1.TerminalProfile
+			if name := r.GetProfile(); name != "" {
+				var err error
+				profile, err = svc.resolveTerminalProfile(ctx, workspaceID, name)
+				if errors.Is(err, errUnknownTerminalProfile) {
+					sendInvalidArgument(sender, err.Error())
+					return
+				}
+				if err != nil {
+					slog.Error("failed to resolve terminal profile", "workspace_id", workspaceID, "error", err)
+					sendInternalError(sender, "failed to resolve terminal profile")
+					return
+				}
+			}
+
 			// Resolve the default shell here (not inside terminal.Start) so
 			// the startup-panel label reflects the actual binary, e.g.
 			// "Starting zsh…" rather than a generic "Starting terminal…"
 			// fallback when the client passes shell="".
-			shell := r.GetShell()
+			shell := cmp.Or(r.GetShell(), profile.GetShell())
 			if shell == "" {
 				shell = terminal.ResolveDefaultShell()
 			}
-			shellStartDir := expandTilde(r.GetShellStartDir())
+			shellStartDir := expandTilde(cmp.Or(r.GetShellStartDir(), profile.GetShellStartDir()))
 			workingDir := expandTilde(r.GetWorkingDir())
 			if workingDir == "" {
 				workingDir = svc.HomeDir
@@ -85,6 +103,12 @@ func registerTerminalHandlers(d registrar, svc *Service) {
 				sendValidationError(sender, gmErr)
 				return
 			}
+			if plan.Mode == gitModeCreateWorktree {
+				if err := svc.checkDiskRoom(r.GetWorkspaceId()); err != nil {
+					sendResourceExhausted(sender, err)
+					return
+				}
+			}
 
 			terminalID := id.Generate()
 
@@ -116,6 +140,14 @@ func registerTerminalHandlers(d registrar, svc *Service) {
 				sendInternalError(sender, "failed to persist terminal")
 				return
 			}
+			if profile != nil {
+				if err := svc.storeTerminalProfile(bgCtx(), terminalID, profile); err != nil {
+					slog.Error("failed to store terminal profile", "terminal_id", terminalID, "error", err)
+					_ = svc.Queries.CloseTerminal(bgCtx(), terminalID)
+					sendInternalError(sender, "failed to persist terminal")
+					return
+				}
+			}
 
 			// Register the startup in the registry with a cancel ctx so
 			// CloseTerminal during phase 0 aborts executeGitMode, and seed
@@ -143,7 +175,7 @@ func registerTerminalHandlers(d registrar, svc *Service) {
 				TabID:       terminalID,
 				WorkingDir:  plan.PlannedWorkingDir,
 			}
-			go svc.runTerminalStartup(startupCtx, terminal.Options{
+			opts := terminal.Options{
 				ID:            terminalID,
 				WorkspaceID:   workspaceID,
 				Shell:         shell,
@@ -151,7 +183,10 @@ func registerTerminalHandlers(d registrar, svc *Service) {
 				ShellStartDir: shellStartDir,
 				Cols:          uint16(cols),
 				Rows:          uint16(rows),
-			}, spawnInfo, plan, outputFn, exitFn)
+				Persist:       svc.PersistTerminals,
+			}
+			svc.applyTerminalProfile(&opts, profile)
+			go svc.runTerminalStartup(startupCtx, opts, spawnInfo, plan, outputFn, exitFn)
 		})
 
 	// RestartTerminal respawns the shell process for a terminal whose
@@ -228,7 +263,7 @@ func registerTerminalHandlers(d registrar, svc *Service) {
 				TabID:       terminalID,
 				WorkingDir:  dbTerm.WorkingDir,
 			}
-			go svc.runTerminalRestart(startupCtx, terminal.Options{
+			opts := terminal.Options{
 				ID:            terminalID,
 				WorkspaceID:   dbTerm.WorkspaceID,
 				Shell:         shell,
@@ -236,7 +271,11 @@ func registerTerminalHandlers(d registrar, svc *Service) {
 				ShellStartDir: dbTerm.ShellStartDir,
 				Cols:          uint16(cols),
 				Rows:          uint16(rows),
-			}, spawnInfo, fallbackOffset, outputFn, exitFn)
+				Persist:       svc.PersistTerminals,
+			}
+			// The profile copy brings back its env and startup command.
+			svc.applyTerminalProfile(&opts, svc.terminalProfile(terminalID))
+			go svc.runTerminalRestart(startupCtx, opts, spawnInfo, fallbackOffset, outputFn, exitFn)
 		})
 
 	// CloseTerminal stops and removes a terminal session.
@@ -251,17 +290,7 @@ func registerTerminalHandlers(d registrar, svc *Service) {
 			// the tab from the UI. The TerminalStartup goroutine's
 			// trailing rollback work is tracked separately by
 			// TerminalStartup.WaitForInFlight and drained in Shutdown.
-			result := svc.closeTabCommon(
-				leapmuxv1.TabType_TAB_TYPE_TERMINAL,
-				terminalID,
-				r.GetWorktreeAction(),
-				func() {
-					svc.TerminalStartup.cancelAndClear(terminalID)
-					svc.Terminals.RemoveTerminal(terminalID)
-					svc.terminalCleanups.run(terminalID)
-				},
-				func() error { return svc.Queries.CloseTerminal(bgCtx(), terminalID) },
-			)
+			result := svc.closeTerminalTab(terminalID, r.GetWorktreeAction())
 			sendProtoResponse(sender, &leapmuxv1.CloseTerminalResponse{Result: result})
 		})
 
@@ -428,11 +457,17 @@ func registerTerminalHandlers(d registrar, svc *Service) {
 			gitDirs = append(gitDirs, gitutil.ResolveGitDir(e.Meta.ShellStartDir, e.Meta.WorkingDir))
 		}
 
+		// The agent link lives only in the row, so it is read from there for
+		// live terminals too.
+		agentIDs := make(map[string]string)
 		dbTerminals, err := svc.Queries.ListTerminalsByIDs(ctx, tabIDs)
 		if err != nil {
 			slog.Error("failed to list terminals from DB", "error", err)
 		} else {
 			for _, ts := range dbTerminals {
+				if ts.AgentID != "" {
+					agentIDs[ts.ID] = ts.AgentID
+				}
 				if seen[ts.ID] {
 					continue
 				}
@@ -440,6 +475,7 @@ func registerTerminalHandlers(d registrar, svc *Service) {
 					continue
 				}
 				status, startupError, startupMessage := svc.deriveTerminalStatus(&ts)
+				live := svc.Terminals.HasTerminal(ts.ID)
 				// DB-persisted screen is just the bytes; the backend has no
 				// live ring for this terminal (PTY exited or worker
 				// restarted), so the "end offset" equals the screen
@@ -452,19 +488,25 @@ func registerTerminalHandlers(d registrar, svc *Service) {
 					Rows:            uint32(ts.Rows),
 					Screen:          ts.Screen,
 					ScreenEndOffset: int64(len(ts.Screen)),
-					Exited:          !svc.Terminals.HasTerminal(ts.ID),
+					Exited:          !live,
 					WorkingDir:      ts.WorkingDir,
 					ShellStartDir:   ts.ShellStartDir,
 					Title:           ts.Title,
 					Status:          status,
 					StartupError:    startupError,
 					StartupMessage:  startupMessage,
+					Restorable:      !live && status == leapmuxv1.TerminalStatus_TERMINAL_STATUS_READY && terminalRestorable(&ts),
 				}
 				terminals = append(terminals, ti)
 				gitDirs = append(gitDirs, gitutil.ResolveGitDir(ts.ShellStartDir, ts.WorkingDir))
 			}
 		}
 
+		for _, ti := range terminals {
+			ti.AgentId = agentIDs[ti.TerminalId]
+			ti.Profile = svc.terminalProfile(ti.TerminalId).GetName()
+		}
+
 		gitStatuses := gitutil.BatchGetGitStatus(ctx, gitDirs)
 		for i, gs := range gitStatuses {
 			if gs != nil {
@@ -588,7 +630,7 @@ func (svc *Service) runTerminalStartup(ctx context.Context, opts terminal.Option
 	postSpawn, postSpawnErr := svc.Queries.GetTerminalForReady(bgCtx(), terminalID)
 	if postSpawnErr == nil && postSpawn.ClosedAt.Valid {
 		if startErr == nil {
-			svc.Terminals.RemoveTerminal(terminalID)
+			svc.removeTerminal(terminalID)
 		}
 		svc.TerminalStartup.succeed(terminalID)
 		svc.rollbackGitMode(gm)
@@ -621,6 +663,23 @@ func (svc *Service) runTerminalStartup(ctx context.Context, opts terminal.Option
 	svc.succeedTerminalStartup(terminalID)
 }
 
+// closeTerminalTab stops a terminal and closes its row through
+// closeTabCommon. Shared by CloseTerminal and the close of an agent's
+// terminals.
+func (svc *Service) closeTerminalTab(terminalID string, action leapmuxv1.WorktreeAction) *leapmuxv1.CloseTabResult {
+	return svc.closeTabCommon(
+		leapmuxv1.TabType_TAB_TYPE_TERMINAL,
+		terminalID,
+		action,
+		func() {
+			svc.TerminalStartup.cancelAndClear(terminalID)
+			svc.removeTerminal(terminalID)
+			svc.terminalCleanups.run(terminalID)
+		},
+		func() error { return svc.Queries.CloseTerminal(bgCtx(), terminalID) },
+	)
+}
+
 // runTerminalRestart is the async body of RestartTerminal: it spawns a
 // new PTY through Manager.RestartTerminal and broadcasts READY or
 // STARTUP_FAILED depending on the outcome. The handler seeded STARTING
@@ -706,7 +765,7 @@ func (svc *Service) runTerminalRestart(
 	// across restart), so it's read-and-discarded.
 	if postSpawn, fetchErr := svc.Queries.GetTerminalForReady(bgCtx(), terminalID); fetchErr == nil && postSpawn.ClosedAt.Valid {
 		if startErr == nil {
-			svc.Terminals.RemoveTerminal(terminalID)
+			svc.removeTerminal(terminalID)
 		}
 		svc.TerminalStartup.succeed(terminalID)
 		return
@@ -803,6 +862,18 @@ func buildTerminalReadyStatus(terminalID string) *leapmuxv1.TerminalStatusChange
 	}
 }
 
+// terminalRestorable reports whether a terminal with no PTY lost its
+// worker rather than its shell: a graceful shutdown records
+// exitCodeUnknown, and a crash leaves the row without the notice a
+// shell's own exit appends. An agent's terminal ran one command, so
+// there is nothing to bring back.
+func terminalRestorable(t *db.Terminal) bool {
+	if t.AgentID != "" || t.StartupError != "" {
+		return false
+	}
+	return t.ExitCode == exitCodeUnknown || !bytes.HasSuffix(t.Screen, terminalExitedNoticeSuffix)
+}
+
 // deriveTerminalStatus computes (status, startupError, startupMessage)
 // for a terminal, in priority order:
 //  1. in-memory startup registry — STARTING / STARTUP_FAILED while a
@@ -0,0 +1,142 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	leapmuxv1 "github.com/leapmux/leapmux/generated/proto/leapmux/v1"
+	"github.com/leapmux/leapmux/internal/util/userid"
+	"github.com/leapmux/leapmux/internal/worker/channel"
+	db "github.com/leapmux/leapmux/internal/worker/generated/db"
+	"github.com/leapmux/leapmux/internal/worker/terminal"
+)
+
+const (
+	// defaultTerminalOutputLines is what an unset lines field sends: enough
+	// for a failing test run or a stack trace, not a whole session.
+	defaultTerminalOutputLines = 100
+	maxTerminalOutputLines     = 1000
+	// maxTerminalOutputBytes caps the excerpt so a thousand very long lines
+	// cannot turn one prompt into most of the agent's context window.
+	maxTerminalOutputBytes = 64 * 1024
+)
+
+// terminalOutputMeta is the provenance persisted with a message built from
+// terminal output: which terminal it came from and how much of it was sent.
+type terminalOutputMeta struct {
+	TerminalID string `json:"terminal_id"`
+	Title      string `json:"title,omitempty"`
+	Lines      int    `json:"lines"`
+	Truncated  bool   `json:"truncated"`
+}
+
+func registerTerminalOutputHandlers(d registrar, svc *Service) {
+	// SendTerminalOutputToAgent submits the tail of a terminal's output as a
+	// user message, exactly like SendAgentMessage. The agent gate runs
+	// first; the terminal must pass the same workspace check on its own,
+	// so a caller cannot read a terminal it could not open.
+	registerAgentGated(d, "SendTerminalOutputToAgent",
+		func(_ context.Context, _ userid.UserID, r *leapmuxv1.SendTerminalOutputToAgentRequest, dbAgent db.Agent, sender channel.ResponseWriter) {
+			if svc.rejectFailedStartup(sender, dbAgent.ID, dbAgent) {
+				return
+			}
+			dbTerm, ok := svc.requireAccessibleTerminal(sender, r.GetTerminalId())
+			if !ok {
+				return
+			}
+			maxLines := int(r.GetLines())
+			if maxLines == 0 {
+				maxLines = defaultTerminalOutputLines
+			}
+			if maxLines > maxTerminalOutputLines {
+				sendInvalidArgument(sender, fmt.Sprintf("lines must be at most %d", maxTerminalOutputLines))
+				return
+			}
+
+			// A live terminal's buffer is ahead of the row, which is only
+			// written on title changes and close.
+			screen, _, _ := svc.Terminals.ScreenSnapshotSince(dbTerm.ID, 0)
+			if screen == nil {
+				screen = dbTerm.Screen
+			}
+			title := dbTerm.Title
+			if meta, ok := svc.Terminals.GetMeta(dbTerm.ID); ok && meta.Title != "" {
+				title = meta.Title
+			}
+
+			output, truncated := terminal.PlainTextTail(screen, maxLines)
+			if len(output) > maxTerminalOutputBytes {
+				output = output[len(output)-maxTerminalOutputBytes:]
+				// Start on a whole line; this also steps past a rune the cut split.
+				if nl := strings.IndexByte(output, '\n'); nl >= 0 {
+					output = output[nl+1:]
+				}
+				truncated = true
+			}
+			if strings.TrimSpace(output) == "" {
+				sendFailedPrecondition(sender, "terminal has no output to send")
+				return
+			}
+			lines := strings.Count(output, "\n") + 1
+
+			svc.submitUserMessage(sender, dbAgent, userInput{
+				content:        formatTerminalOutput(r.GetNote(), title, output, lines, truncated),
+				idempotencyKey: r.GetIdempotencyKey(),
+				terminalOutput: &terminalOutputMeta{
+					TerminalID: dbTerm.ID,
+					Title:      title,
+					Lines:      lines,
+					Truncated:  truncated,
+				},
+			}, func(messageID string, duplicate bool) {
+				sendProtoResponse(sender, &leapmuxv1.SendTerminalOutputToAgentResponse{
+					DuplicateSuppressed: duplicate,
+					MessageId:           messageID,
+					Lines:               uint32(lines),
+					Truncated:           truncated,
+				})
+			})
+		})
+}
+
+// formatTerminalOutput builds the prompt: the note, a one-line header
+// naming the terminal, and the output in a code fence long enough that
+// nothing in the output can close it.
+func formatTerminalOutput(note, title, output string, lines int, truncated bool) string {
+	var b strings.Builder
+	if note = strings.TrimSpace(note); note != "" {
+		b.WriteString(note)
+		b.WriteString("\n\n")
+	}
+	source := "terminal"
+	if title != "" {
+		source = fmt.Sprintf("terminal %q", title)
+	}
+	if truncated {
+		fmt.Fprintf(&b, "Last %d lines of output from %s:\n\n", lines, source)
+	} else {
+		fmt.Fprintf(&b, "Output from %s:\n\n", source)
+	}
+	fence := strings.Repeat("`", max(3, longestRun(output, '`')+1))
+	b.WriteString(fence)
+	b.WriteString("text\n")
+	b.WriteString(output)
+	b.WriteString("\n")
+	b.WriteString(fence)
+	return b.String()
+}
+
+// longestRun returns the length of the longest run of c in s.
+func longestRun(s string, c byte) int {
+	longest, run := 0, 0
+	for i := 0; i < len(s); i++ {
+		if s[i] != c {
+			run = 0
+			continue
+		}
+		run++
+		longest = max(longest, run)
+	}
+	return longest
+}
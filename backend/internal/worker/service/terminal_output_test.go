@@ -0,0 +1,106 @@
+package service
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	leapmuxv1 "github.com/leapmux/leapmux/generated/proto/leapmux/v1"
+	"github.com/leapmux/leapmux/internal/util/msgcodec"
+	db "github.com/leapmux/leapmux/internal/worker/generated/db"
+)
+
+// seedClosedTerminal stores a terminal row with a recorded screen, as the
+// close handler leaves it.
+func seedClosedTerminal(t *testing.T, svc *Service, workspaceID, title, screen string) {
+	t.Helper()
+	require.NoError(t, svc.Queries.UpsertTerminal(context.Background(), db.UpsertTerminalParams{
+		ID:          "term-1",
+		WorkspaceID: workspaceID,
+		WorkingDir:  t.TempDir(),
+		HomeDir:     t.TempDir(),
+		Title:       title,
+		Screen:      []byte(screen),
+	}))
+}
+
+func TestSendTerminalOutputToAgent_SubmitsTailWithProvenance(t *testing.T) {
+	svc, d, w := setupTestService(t, withWorkspaces("ws-1"))
+	seedGuardedAgent(t, svc, "")
+	var screen strings.Builder
+	for i := 1; i <= 5; i++ {
+		fmt.Fprintf(&screen, "\x1b[31mline %d\x1b[0m\r\n", i)
+	}
+	seedClosedTerminal(t, svc, "ws-1", "go test", screen.String())
+
+	dispatch(d, "SendTerminalOutputToAgent", &leapmuxv1.SendTerminalOutputToAgentRequest{
+		AgentId:    "agent-1",
+		TerminalId: "term-1",
+		Lines:      2,
+		Note:       "Why does this fail?",
+	}, w)
+	require.Empty(t, w.errors)
+	resp := decodeResponse[leapmuxv1.SendTerminalOutputToAgentResponse](t, w)
+	assert.EqualValues(t, 2, resp.GetLines())
+	assert.True(t, resp.GetTruncated())
+
+	msgs, err := svc.Queries.ListAllMessagesByAgentID(context.Background(), db.ListAllMessagesByAgentIDParams{AgentID: "agent-1"})
+	require.NoError(t, err)
+	require.Len(t, msgs, 1)
+	raw, err := msgcodec.Decompress(msgs[0].Content, msgs[0].ContentCompression)
+	require.NoError(t, err)
+
+	var stored struct {
+		Content        string             `json:"content"`
+		TerminalOutput terminalOutputMeta `json:"terminal_output"`
+	}
+	require.NoError(t, json.Unmarshal(raw, &stored))
+	assert.Equal(t, "Why does this fail?\n\nLast 2 lines of output from terminal \"go test\":\n\n```text\nline 4\nline 5\n```", stored.Content)
+	assert.Equal(t, terminalOutputMeta{TerminalID: "term-1", Title: "go test", Lines: 2, Truncated: true}, stored.TerminalOutput)
+}
+
+func TestSendTerminalOutputToAgent_Rejects(t *testing.T) {
+	tests := []struct {
+		name     string
+		terminal string // workspace of the seeded terminal; "" seeds none
+		screen   string
+		lines    uint32
+		code     int32
+	}{
+		{name: "terminal in another workspace", terminal: "ws-other", screen: "secret\n", code: codePermissionDenied},
+		{name: "missing terminal", code: codeNotFound},
+		{name: "too many lines", terminal: "ws-1", screen: "x\n", lines: maxTerminalOutputLines + 1, code: codeInvalidArgument},
+		{name: "only escapes", terminal: "ws-1", screen: "\x1b[H\x1b[2J", code: codeFailedPrecondition},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			svc, d, w := setupTestService(t, withWorkspaces("ws-1"))
+			seedGuardedAgent(t, svc, "")
+			if tt.terminal != "" {
+				seedClosedTerminal(t, svc, tt.terminal, "", tt.screen)
+			}
+
+			dispatch(d, "SendTerminalOutputToAgent", &leapmuxv1.SendTerminalOutputToAgentRequest{
+				AgentId:    "agent-1",
+				TerminalId: "term-1",
+				Lines:      tt.lines,
+			}, w)
+			require.Len(t, w.errors, 1)
+			assert.Equal(t, tt.code, w.errors[0].code)
+
+			msgs, err := svc.Queries.ListAllMessagesByAgentID(context.Background(), db.ListAllMessagesByAgentIDParams{AgentID: "agent-1"})
+			require.NoError(t, err)
+			assert.Empty(t, msgs)
+		})
+	}
+}
+
+func TestFormatTerminalOutput_FenceOutlastsOutput(t *testing.T) {
+	got := formatTerminalOutput("", "", "```go\nx\n```", 3, false)
+	assert.Equal(t, "Output from terminal:\n\n````text\n```go\nx\n```\n````", got)
+}
@@ -0,0 +1,49 @@
+package service
+
+import (
+	"log/slog"
+
+	"github.com/leapmux/leapmux/internal/worker/terminal"
+)
+
+// removeTerminal stops a terminal for good. A persistent terminal's shell
+// would otherwise keep running under tmux after its tab is gone, so the
+// session ends with it.
+func (svc *Service) removeTerminal(terminalID string) {
+	svc.Terminals.RemoveTerminal(terminalID)
+	if svc.PersistTerminals {
+		terminal.KillPersistentSession(terminalID)
+	}
+}
+
+// sweepPersistentTerminals ends the shells a previous worker process left
+// under tmux whose tabs are no longer open. The others stay for their
+// tabs' restores to reattach.
+func (svc *Service) sweepPersistentTerminals() {
+	if !svc.PersistTerminals {
+		return
+	}
+	if !terminal.PersistenceAvailable() {
+		slog.Warn("persistent terminals need tmux 3.0 or later on PATH; terminals will not survive a worker restart")
+		return
+	}
+	ids := terminal.ListPersistentSessions()
+	if len(ids) == 0 {
+		return
+	}
+	rows, err := svc.Queries.ListTerminalsByIDs(bgCtx(), ids)
+	if err != nil {
+		slog.Warn("failed to list terminals for persistent sessions", "error", err)
+		return
+	}
+	open := make(map[string]bool, len(rows))
+	for _, r := range rows {
+		open[r.ID] = true
+	}
+	for _, id := range ids {
+		if !open[id] {
+			slog.Info("ending persistent terminal session with no open tab", "terminal_id", id)
+			terminal.KillPersistentSession(id)
+		}
+	}
+}
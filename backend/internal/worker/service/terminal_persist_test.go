@@ -0,0 +1,52 @@
+package service
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/protobuf/proto"
+
+	leapmuxv1 "github.com/leapmux/leapmux/generated/proto/leapmux/v1"
+	db "github.com/leapmux/leapmux/internal/worker/generated/db"
+)
+
+func TestListTerminals_Restorable(t *testing.T) {
+	ctx := context.Background()
+	svc, d, w := setupTestService(t, withWorkspaces("ws-A"))
+
+	for id, row := range map[string]struct {
+		screen   []byte
+		exitCode int64
+	}{
+		"t-shutdown": {append([]byte("$ "), formatTerminalExitedNotice(exitCodeUnknown)...), exitCodeUnknown},
+		"t-crashed":  {[]byte("$ make"), 0},
+		"t-exited":   {append([]byte("$ exit"), formatTerminalExitedNotice(0)...), 0},
+		"t-failed":   {[]byte{}, exitCodeUnknown},
+	} {
+		require.NoError(t, svc.Queries.UpsertTerminal(ctx, db.UpsertTerminalParams{
+			ID: id, WorkspaceID: "ws-A", WorkingDir: "/tmp", HomeDir: "/tmp",
+			Cols: 80, Rows: 24, Screen: row.screen, ExitCode: row.exitCode,
+		}))
+	}
+	svc.persistTerminalStartupError("t-failed", "no such shell")
+
+	dispatch(d, "ListTerminals", &leapmuxv1.ListTerminalsRequest{
+		TabIds: []string{"t-shutdown", "t-crashed", "t-exited", "t-failed"},
+	}, w)
+
+	require.Len(t, w.responses, 1)
+	var resp leapmuxv1.ListTerminalsResponse
+	require.NoError(t, proto.Unmarshal(w.responses[0].GetPayload(), &resp))
+	restorable := make(map[string]bool)
+	for _, ti := range resp.GetTerminals() {
+		restorable[ti.GetTerminalId()] = ti.GetRestorable()
+	}
+	assert.Equal(t, map[string]bool{
+		"t-shutdown": true,
+		"t-crashed":  true,
+		"t-exited":   false,
+		"t-failed":   false,
+	}, restorable)
+}
@@ -0,0 +1,178 @@
+package service
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"log/slog"
+
+	leapmuxv1 "github.com/leapmux/leapmux/generated/proto/leapmux/v1"
+	"github.com/leapmux/leapmux/internal/util/userid"
+	"github.com/leapmux/leapmux/internal/worker/channel"
+	db "github.com/leapmux/leapmux/internal/worker/generated/db"
+	"github.com/leapmux/leapmux/internal/worker/terminal"
+	"github.com/leapmux/leapmux/util/validate"
+	"google.golang.org/protobuf/encoding/protojson"
+)
+
+// validateTerminalProfiles checks one layer of profiles. The Hub checks the
+// org layer the same way.
+func validateTerminalProfiles(profiles []*leapmuxv1.TerminalProfile) error {
+	if len(profiles) > validate.MaxTerminalProfiles {
+		return fmt.Errorf("at most %d terminal profiles", validate.MaxTerminalProfiles)
+	}
+	seen := make(map[string]bool, len(profiles))
+	for _, p := range profiles {
+		if err := validate.ValidateTerminalProfile(p.GetName(), p.GetShell(), p.GetShellStartDir(), p.GetStartupCommand()); err != nil {
+			return err
+		}
+		if seen[p.GetName()] {
+			return fmt.Errorf("duplicate terminal profile %s", p.GetName())
+		}
+		seen[p.GetName()] = true
+		if err := validateEnvVars(p.GetEnv()); err != nil {
+			return fmt.Errorf("terminal profile %s: %w", p.GetName(), err)
+		}
+	}
+	return nil
+}
+
+// loadWorkspaceTerminalProfiles reads the workspace's own profiles. A
+// workspace with no row yields none, not an error.
+func loadWorkspaceTerminalProfiles(ctx context.Context, queries *db.Queries, workspaceID string) (*leapmuxv1.WorkspaceTerminalProfiles, error) {
+	raw, err := queries.GetWorkspaceTerminalProfiles(ctx, workspaceID)
+	if errors.Is(err, sql.ErrNoRows) {
+		return &leapmuxv1.WorkspaceTerminalProfiles{}, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	profiles := &leapmuxv1.WorkspaceTerminalProfiles{}
+	if err := protojson.Unmarshal([]byte(raw), profiles); err != nil {
+		return nil, fmt.Errorf("decode terminal profiles: %w", err)
+	}
+	return profiles, nil
+}
+
+// availableTerminalProfiles lists what OpenTerminal can name: the
+// workspace's profiles, then the org's that no workspace profile hides.
+func (svc *Service) availableTerminalProfiles(own *leapmuxv1.WorkspaceTerminalProfiles) []*leapmuxv1.TerminalProfile {
+	out := append([]*leapmuxv1.TerminalProfile(nil), own.GetProfiles()...)
+	hidden := make(map[string]bool, len(out))
+	for _, p := range out {
+		hidden[p.GetName()] = true
+	}
+	for _, p := range svc.orgDefaults.Load().GetTerminalProfiles() {
+		if !hidden[p.GetName()] {
+			out = append(out, p)
+		}
+	}
+	return out
+}
+
+// errUnknownTerminalProfile is returned for an OpenTerminal profile that
+// neither the workspace nor the org defines.
+var errUnknownTerminalProfile = errors.New("unknown terminal profile")
+
+// resolveTerminalProfile finds the profile OpenTerminal named.
+func (svc *Service) resolveTerminalProfile(ctx context.Context, workspaceID, name string) (*leapmuxv1.TerminalProfile, error) {
+	own, err := loadWorkspaceTerminalProfiles(ctx, svc.Queries, workspaceID)
+	if err != nil {
+		return nil, err
+	}
+	for _, p := range svc.availableTerminalProfiles(own) {
+		if p.GetName() == name {
+			return p, nil
+		}
+	}
+	return nil, fmt.Errorf("%w %q", errUnknownTerminalProfile, name)
+}
+
+// storeTerminalProfile keeps the copy of the profile a terminal was opened
+// with, so its restarts run the same way.
+func (svc *Service) storeTerminalProfile(ctx context.Context, terminalID string, profile *leapmuxv1.TerminalProfile) error {
+	raw, err := protojson.Marshal(profile)
+	if err != nil {
+		return err
+	}
+	return svc.Queries.CreateTerminalProfile(ctx, db.CreateTerminalProfileParams{TerminalID: terminalID, Profile: string(raw)})
+}
+
+// terminalProfile returns the profile a terminal was opened with, or nil.
+// A failed read restarts the terminal without it rather than failing.
+func (svc *Service) terminalProfile(terminalID string) *leapmuxv1.TerminalProfile {
+	raw, err := svc.Queries.GetTerminalProfile(bgCtx(), terminalID)
+	if err != nil {
+		if !errors.Is(err, sql.ErrNoRows) {
+			slog.Warn("failed to read terminal profile", "terminal_id", terminalID, "error", err)
+		}
+		return nil
+	}
+	profile := &leapmuxv1.TerminalProfile{}
+	if err := protojson.Unmarshal([]byte(raw), profile); err != nil {
+		slog.Warn("failed to decode terminal profile", "terminal_id", terminalID, "error", err)
+		return nil
+	}
+	return profile
+}
+
+// applyTerminalProfile sets the environment and startup command a terminal
+// launches with: the org's and the workspace's variables, then the
+// profile's, which may be nil.
+func (svc *Service) applyTerminalProfile(opts *terminal.Options, profile *leapmuxv1.TerminalProfile) {
+	opts.Env = mergeEnv(svc.orgDefaults.Load().GetEnv(), svc.workspaceEnvVars(opts.WorkspaceID), profile.GetEnv())
+	opts.StartupCommand = profile.GetStartupCommand()
+}
+
+// registerTerminalProfileHandlers registers the per-workspace terminal
+// profile RPCs.
+func registerTerminalProfileHandlers(d registrar, svc *Service) {
+	registerWorkspaceGated(d, "GetWorkspaceTerminalProfiles",
+		func(ctx context.Context, _ userid.UserID, r *leapmuxv1.GetWorkspaceTerminalProfilesRequest, sender channel.ResponseWriter) {
+			profiles, err := loadWorkspaceTerminalProfiles(ctx, svc.Queries, r.GetWorkspaceId())
+			if err != nil {
+				slog.Error("failed to load terminal profiles", "workspace_id", r.GetWorkspaceId(), "error", err)
+				sendInternalError(sender, "failed to load terminal profiles")
+				return
+			}
+			sendProtoResponse(sender, &leapmuxv1.GetWorkspaceTerminalProfilesResponse{
+				Profiles:  profiles,
+				Available: svc.availableTerminalProfiles(profiles),
+			})
+		})
+
+	registerWorkspaceGated(d, "SetWorkspaceTerminalProfiles",
+		func(_ context.Context, _ userid.UserID, r *leapmuxv1.SetWorkspaceTerminalProfilesRequest, sender channel.ResponseWriter) {
+			profiles := r.GetProfiles()
+			if profiles == nil {
+				profiles = &leapmuxv1.WorkspaceTerminalProfiles{}
+			}
+			if err := validateTerminalProfiles(profiles.GetProfiles()); err != nil {
+				sendInvalidArgument(sender, err.Error())
+				return
+			}
+
+			// No profiles leaves only the org's; drop the row rather than
+			// storing an empty list.
+			var err error
+			if len(profiles.GetProfiles()) == 0 {
+				err = svc.Queries.DeleteWorkspaceTerminalProfiles(bgCtx(), r.GetWorkspaceId())
+			} else {
+				var raw []byte
+				raw, err = protojson.Marshal(profiles)
+				if err == nil {
+					err = svc.Queries.UpsertWorkspaceTerminalProfiles(bgCtx(), db.UpsertWorkspaceTerminalProfilesParams{
+						WorkspaceID: r.GetWorkspaceId(),
+						Profiles:    string(raw),
+					})
+				}
+			}
+			if err != nil {
+				slog.Error("failed to save terminal profiles", "workspace_id", r.GetWorkspaceId(), "error", err)
+				sendInternalError(sender, "failed to save terminal profiles")
+				return
+			}
+			sendProtoResponse(sender, &leapmuxv1.SetWorkspaceTerminalProfilesResponse{Profiles: profiles})
+		})
+}
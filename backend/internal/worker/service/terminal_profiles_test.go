@@ -0,0 +1,134 @@
+package service
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/protobuf/proto"
+
+	leapmuxv1 "github.com/leapmux/leapmux/generated/proto/leapmux/v1"
+	"github.com/leapmux/leapmux/internal/worker/terminal"
+)
+
+func TestWorkspaceTerminalProfiles_SetAndGet(t *testing.T) {
+	svc, d, w := setupTestService(t, withWorkspaces("ws-1"))
+	svc.SetOrgDefaults(&leapmuxv1.OrgDefaults{TerminalProfiles: []*leapmuxv1.TerminalProfile{
+		{Name: "psql", StartupCommand: "psql -h org-db"},
+		{Name: "logs", StartupCommand: "docker compose logs -f"},
+	}})
+
+	profiles := &leapmuxv1.WorkspaceTerminalProfiles{Profiles: []*leapmuxv1.TerminalProfile{
+		{Name: "psql", StartupCommand: "psql -h localhost"},
+	}}
+	dispatch(d, "SetWorkspaceTerminalProfiles", &leapmuxv1.SetWorkspaceTerminalProfilesRequest{
+		WorkspaceId: "ws-1",
+		Profiles:    profiles,
+	}, w)
+	dispatch(d, "GetWorkspaceTerminalProfiles", &leapmuxv1.GetWorkspaceTerminalProfilesRequest{WorkspaceId: "ws-1"}, w)
+	require.Empty(t, w.errors)
+	require.Len(t, w.responses, 2)
+	var resp leapmuxv1.GetWorkspaceTerminalProfilesResponse
+	require.NoError(t, proto.Unmarshal(w.responses[1].GetPayload(), &resp))
+	assert.True(t, proto.Equal(profiles, resp.GetProfiles()))
+	var available []string
+	for _, p := range resp.GetAvailable() {
+		available = append(available, p.GetName()+": "+p.GetStartupCommand())
+	}
+	assert.Equal(t, []string{"psql: psql -h localhost", "logs: docker compose logs -f"}, available,
+		"the workspace's psql hides the org's")
+
+	// No profiles clears the stored ones.
+	dispatch(d, "SetWorkspaceTerminalProfiles", &leapmuxv1.SetWorkspaceTerminalProfilesRequest{WorkspaceId: "ws-1"}, w)
+	dispatch(d, "GetWorkspaceTerminalProfiles", &leapmuxv1.GetWorkspaceTerminalProfilesRequest{WorkspaceId: "ws-1"}, w)
+	require.Empty(t, w.errors)
+	require.Len(t, w.responses, 4)
+	require.NoError(t, proto.Unmarshal(w.responses[3].GetPayload(), &resp))
+	assert.Empty(t, resp.GetProfiles().GetProfiles())
+	assert.Len(t, resp.GetAvailable(), 2)
+}
+
+func TestWorkspaceTerminalProfiles_RejectsInvalid(t *testing.T) {
+	for name, profiles := range map[string][]*leapmuxv1.TerminalProfile{
+		"duplicate":    {{Name: "psql"}, {Name: "psql"}},
+		"empty name":   {{StartupCommand: "psql"}},
+		"reserved env": {{Name: "psql", Env: []*leapmuxv1.EnvVar{{Name: "LEAPMUX_TAB_ID", Value: "x"}}}},
+	} {
+		t.Run(name, func(t *testing.T) {
+			_, d, w := setupTestService(t, withWorkspaces("ws-1"))
+			dispatch(d, "SetWorkspaceTerminalProfiles", &leapmuxv1.SetWorkspaceTerminalProfilesRequest{
+				WorkspaceId: "ws-1",
+				Profiles:    &leapmuxv1.WorkspaceTerminalProfiles{Profiles: profiles},
+			}, w)
+			require.Len(t, w.errors, 1)
+			assert.Equal(t, codeInvalidArgument, w.errors[0].code)
+		})
+	}
+}
+
+func TestOpenTerminal_AppliesProfile(t *testing.T) {
+	svc, d, w := setupTestService(t, withWorkspaces("ws-1"))
+	defer drainAllInFlight(svc)
+	started := make(chan terminal.Options, 1)
+	svc.startTerminalFn = func(_ context.Context, opts terminal.Options, _ terminal.OutputHandler, _ terminal.ExitHandler) error {
+		started <- opts
+		return nil
+	}
+	svc.SetOrgDefaults(&leapmuxv1.OrgDefaults{Env: []*leapmuxv1.EnvVar{
+		{Name: "PGHOST", Value: "org-db"}, {Name: "REGION", Value: "eu"},
+	}})
+	startDir := t.TempDir()
+	dispatch(d, "SetWorkspaceTerminalProfiles", &leapmuxv1.SetWorkspaceTerminalProfilesRequest{
+		WorkspaceId: "ws-1",
+		Profiles: &leapmuxv1.WorkspaceTerminalProfiles{Profiles: []*leapmuxv1.TerminalProfile{{
+			Name:           "psql",
+			Shell:          "/bin/sh",
+			ShellStartDir:  startDir,
+			Env:            []*leapmuxv1.EnvVar{{Name: "PGHOST", Value: "localhost"}},
+			StartupCommand: "psql",
+		}}},
+	}, w)
+
+	dispatch(d, "OpenTerminal", &leapmuxv1.OpenTerminalRequest{
+		WorkspaceId: "ws-1",
+		WorkingDir:  t.TempDir(),
+		Profile:     "psql",
+	}, w)
+	require.Empty(t, w.errors)
+	require.Len(t, w.responses, 2)
+	var resp leapmuxv1.OpenTerminalResponse
+	require.NoError(t, proto.Unmarshal(w.responses[1].GetPayload(), &resp))
+
+	var opts terminal.Options
+	select {
+	case opts = <-started:
+	case <-time.After(5 * time.Second):
+		t.Fatal("terminal never started")
+	}
+	assert.Equal(t, "/bin/sh", opts.Shell)
+	assert.Equal(t, startDir, opts.ShellStartDir)
+	assert.Equal(t, []string{"PGHOST=localhost", "REGION=eu"}, opts.Env)
+	assert.Equal(t, "psql", opts.StartupCommand)
+
+	// Restarts use the copy kept at open, not the profile as it is now.
+	dispatch(d, "SetWorkspaceTerminalProfiles", &leapmuxv1.SetWorkspaceTerminalProfilesRequest{WorkspaceId: "ws-1"}, w)
+	require.Empty(t, w.errors)
+	kept := svc.terminalProfile(resp.GetTerminalId())
+	require.NotNil(t, kept)
+	assert.Equal(t, "psql", kept.GetName())
+	assert.Equal(t, "psql", kept.GetStartupCommand())
+}
+
+func TestOpenTerminal_RejectsUnknownProfile(t *testing.T) {
+	_, d, w := setupTestService(t, withWorkspaces("ws-1"))
+
+	dispatch(d, "OpenTerminal", &leapmuxv1.OpenTerminalRequest{
+		WorkspaceId: "ws-1",
+		WorkingDir:  t.TempDir(),
+		Profile:     "nope",
+	}, w)
+	require.Len(t, w.errors, 1)
+	assert.Equal(t, codeInvalidArgument, w.errors[0].code)
+}
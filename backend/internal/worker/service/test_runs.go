@@ -0,0 +1,134 @@
+package service
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"log/slog"
+	"sync"
+
+	leapmuxv1 "github.com/leapmux/leapmux/generated/proto/leapmux/v1"
+	"github.com/leapmux/leapmux/internal/util/timefmt"
+	"github.com/leapmux/leapmux/internal/util/userid"
+	"github.com/leapmux/leapmux/internal/worker/agent"
+	"github.com/leapmux/leapmux/internal/worker/channel"
+	db "github.com/leapmux/leapmux/internal/worker/generated/db"
+	"github.com/leapmux/leapmux/internal/worker/testruns"
+)
+
+// Test runs are read out of transcript messages the agent already
+// persisted; like sub-agent runs, a failed write costs the summary, not
+// the turn, so the helpers below log rather than surface their errors.
+
+// observeTestRun watches an agent's shell tool calls for test runners. A
+// call whose command runs tests is remembered by its span id; the message
+// that closes that span carries the output, whose summary is recorded and
+// broadcast.
+func (h *OutputHandler) observeTestRun(agentID string, content []byte, span agent.SpanInfo) {
+	if span.SpanID == "" {
+		return
+	}
+	if call := span.ToolCall; call != nil && call.Command != "" {
+		if _, ok := testruns.DetectRunner(call.Command); ok {
+			v, _ := h.testRunCalls.LoadOrStore(agentID, &sync.Map{})
+			v.(*sync.Map).Store(span.SpanID, call.Command)
+		}
+		return
+	}
+	if !span.Closing {
+		return
+	}
+	v, ok := h.testRunCalls.Load(agentID)
+	if !ok {
+		return
+	}
+	command, ok := v.(*sync.Map).LoadAndDelete(span.SpanID)
+	if !ok {
+		return
+	}
+	runner, _ := testruns.DetectRunner(command.(string))
+	summary, ok := testruns.Parse(runner, testruns.ToolOutput(content))
+	if !ok {
+		return
+	}
+	h.recordTestRun(agentID, span.SpanID, command.(string), summary)
+}
+
+// recordTestRun stores a test run against the turn in flight and sends
+// it to the agent's watchers as a partial status change.
+func (h *OutputHandler) recordTestRun(agentID, toolUseID, command string, summary testruns.Summary) {
+	var turnMessageID string
+	turn, err := h.queries.GetLatestAgentTurnModel(bgCtx(), agentID)
+	switch {
+	case err == nil:
+		turnMessageID = turn.MessageID
+	case !errors.Is(err, sql.ErrNoRows):
+		slog.Warn("turn lookup for test run failed", "agent_id", agentID, "error", err)
+	}
+	params := db.CreateAgentTestRunParams{
+		AgentID:         agentID,
+		TurnMessageID:   turnMessageID,
+		ToolUseID:       toolUseID,
+		Runner:          summary.Runner,
+		Command:         command,
+		Passed:          summary.Passed,
+		Failed:          summary.Failed,
+		Skipped:         summary.Skipped,
+		CoveragePercent: summary.Coverage,
+	}
+	if summary.HasCoverage {
+		params.HasCoverage = 1
+	}
+	row, err := h.queries.CreateAgentTestRun(bgCtx(), params)
+	if err != nil {
+		slog.Warn("failed to record test run", "agent_id", agentID, "tool_use_id", toolUseID, "error", err)
+		return
+	}
+	// Partial update, like BroadcastGitStatus: only a live worker sends it.
+	h.watcher.BroadcastAgentEvent(agentID, &leapmuxv1.AgentEvent{
+		AgentId: agentID,
+		Event: &leapmuxv1.AgentEvent_StatusChange{StatusChange: &leapmuxv1.AgentStatusChange{
+			AgentId:      agentID,
+			WorkerOnline: true,
+			TestRun:      testRunToProto(row),
+		}},
+	})
+}
+
+func testRunToProto(row db.AgentTestRun) *leapmuxv1.TestRun {
+	run := &leapmuxv1.TestRun{
+		Id:            row.ID,
+		TurnMessageId: row.TurnMessageID,
+		ToolUseId:     row.ToolUseID,
+		Runner:        row.Runner,
+		Command:       row.Command,
+		Passed:        row.Passed,
+		Failed:        row.Failed,
+		Skipped:       row.Skipped,
+		CreatedAt:     timefmt.Format(row.CreatedAt.Time),
+	}
+	if row.HasCoverage != 0 {
+		run.CoveragePercent = &row.CoveragePercent
+	}
+	return run
+}
+
+func registerTestRunHandlers(d registrar, svc *Service) {
+	registerAgentGated(d, "ListAgentTestRuns",
+		func(ctx context.Context, _ userid.UserID, r *leapmuxv1.ListAgentTestRunsRequest, dbAgent db.Agent, sender channel.ResponseWriter) {
+			rows, err := svc.Queries.ListAgentTestRuns(ctx, db.ListAgentTestRunsParams{
+				AgentID:       dbAgent.ID,
+				TurnMessageID: r.GetTurnMessageId(),
+			})
+			if err != nil {
+				slog.Error("failed to list test runs", "agent_id", dbAgent.ID, "error", err)
+				sendInternalError(sender, "failed to list test runs")
+				return
+			}
+			runs := make([]*leapmuxv1.TestRun, len(rows))
+			for i, row := range rows {
+				runs[i] = testRunToProto(row)
+			}
+			sendProtoResponse(sender, &leapmuxv1.ListAgentTestRunsResponse{Runs: runs})
+		})
+}
@@ -0,0 +1,58 @@
+package service
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	leapmuxv1 "github.com/leapmux/leapmux/generated/proto/leapmux/v1"
+	"github.com/leapmux/leapmux/internal/worker/agent"
+)
+
+func TestTestRuns_RecordedFromShellOutput(t *testing.T) {
+	svc, d, w := setupTestService(t, withWorkspaces("ws-1"))
+	row := seedGuardedAgent(t, svc, "")
+	watch := newTestWriter()
+	svc.Watchers.SetAgentWatches(watch.channelID, []string{row.ID}, watch)
+	sink := svc.Output.NewSink(row.ID, row.AgentProvider)
+	svc.recordTurnModel(row.ID, "msg-1", turnRouting{current: "opus", base: "opus"})
+
+	shell := func(id, command, result string) {
+		t.Helper()
+		require.NoError(t, sink.PersistMessage(leapmuxv1.MessageSource_MESSAGE_SOURCE_AGENT, []byte(`{"type":"assistant"}`),
+			agent.SpanInfo{SpanID: id, SpanType: agent.ToolNameBash, ToolCall: &agent.ToolCall{Name: agent.ToolNameBash, Command: command}}))
+		require.NoError(t, sink.PersistMessage(leapmuxv1.MessageSource_MESSAGE_SOURCE_USER, []byte(result),
+			agent.SpanInfo{SpanID: id, SpanType: agent.ToolNameBash, Closing: true}))
+	}
+	shell("toolu_1", "ls", `{"type":"user","tool_use_result":{"stdout":"--- FAIL: TestX (0.00s)\n"}}`)
+	shell("toolu_2", "go test ./...", `{"type":"user","tool_use_result":{"stdout":"--- PASS: TestA (0.00s)\n--- FAIL: TestB (0.00s)\nFAIL\tex/a\t0.01s\n"}}`)
+	shell("toolu_3", "pytest", `{"type":"user","tool_use_result":{"stdout":"collecting ...\n","stderr":"Killed"}}`)
+	shell("toolu_4", "npm test", `{"type":"user","message":{"content":[{"type":"tool_result","tool_use_id":"toolu_4","content":"Tests:       3 passed, 3 total\nAll files |   90 |"}]}}`)
+
+	dispatch(d, "ListAgentTestRuns", &leapmuxv1.ListAgentTestRunsRequest{AgentId: row.ID, TurnMessageId: "msg-1"}, w)
+	require.Empty(t, w.errors)
+	runs := decodeResponse[leapmuxv1.ListAgentTestRunsResponse](t, w).GetRuns()
+	require.Len(t, runs, 2, "only test commands that printed a summary are recorded")
+
+	assert.Equal(t, "toolu_2", runs[0].GetToolUseId())
+	assert.Equal(t, "go", runs[0].GetRunner())
+	assert.Equal(t, "go test ./...", runs[0].GetCommand())
+	assert.EqualValues(t, 1, runs[0].GetPassed())
+	assert.EqualValues(t, 1, runs[0].GetFailed())
+	assert.Nil(t, runs[0].CoveragePercent)
+	assert.Equal(t, "msg-1", runs[0].GetTurnMessageId())
+
+	assert.Equal(t, "jest", runs[1].GetRunner())
+	assert.EqualValues(t, 3, runs[1].GetPassed())
+	assert.InDelta(t, 90, runs[1].GetCoveragePercent(), 0.001)
+
+	var broadcast []*leapmuxv1.TestRun
+	for _, stream := range watch.streamsSnapshot() {
+		if tr := decodeWatchAgentEvent(t, stream).GetStatusChange().GetTestRun(); tr != nil {
+			broadcast = append(broadcast, tr)
+		}
+	}
+	require.Len(t, broadcast, 2)
+	assert.Equal(t, runs[1].GetId(), broadcast[1].GetId())
+}
@@ -0,0 +1,397 @@
+package service
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"html/template"
+	"log/slog"
+	"strings"
+	"time"
+
+	leapmuxv1 "github.com/leapmux/leapmux/generated/proto/leapmux/v1"
+	"github.com/leapmux/leapmux/internal/util/msgcodec"
+	"github.com/leapmux/leapmux/internal/util/timefmt"
+	"github.com/leapmux/leapmux/internal/util/userid"
+	"github.com/leapmux/leapmux/internal/worker/channel"
+	db "github.com/leapmux/leapmux/internal/worker/generated/db"
+)
+
+// nonToolSpanTypes are the Codex item types that reuse the span columns for
+// plain agent output. Every other span is a tool call. Kept in step with
+// the has_tool_use predicate in ListFilteredMessagesByAgentID.
+var nonToolSpanTypes = map[string]bool{
+	"agentMessage":      true,
+	"plan":              true,
+	"reasoning":         true,
+	"contextCompaction": true,
+}
+
+// Block kinds of a rendered transcript entry.
+const (
+	blockText = "text"
+	blockCode = "code"
+	blockDiff = "diff"
+	blockNote = "note"
+)
+
+// transcriptBlock is one rendered piece of a message.
+type transcriptBlock struct {
+	Kind  string
+	Text  string
+	Lines []diffLine // Set for blockDiff
+}
+
+// diffLine is one line of a highlighted diff; Class is add, del, hunk or
+// ctx.
+type diffLine struct {
+	Class string
+	Text  string
+}
+
+// transcriptEntry is one row of a rendered transcript: a message, or a tool
+// thread gathering every message of one tool span.
+type transcriptEntry struct {
+	Role   string // user, agent, notice or tool
+	Tool   string // Tool name, for a tool thread
+	Time   string
+	Error  string // Delivery error of a user message
+	Blocks []transcriptBlock
+}
+
+type transcriptPage struct {
+	Title       string
+	Provider    string
+	WorkingDir  string
+	RenderedAt  string
+	ExpandTools bool
+	Entries     []transcriptEntry
+}
+
+// renderTranscriptHTML renders an exported agent as one self-contained HTML
+// page: inline styles, no scripts, nothing fetched. Tool threads are
+// collapsed unless expandTools is set, so a printout reads as the
+// conversation with its evidence one click away.
+func renderTranscriptHTML(a *leapmuxv1.BundledAgent, expandTools bool, now time.Time) ([]byte, error) {
+	page := transcriptPage{
+		Title:       a.GetTitle(),
+		Provider:    strings.TrimPrefix(a.GetAgentProvider().String(), "AGENT_PROVIDER_"),
+		WorkingDir:  a.GetWorkingDir(),
+		RenderedAt:  timefmt.Format(now),
+		ExpandTools: expandTools,
+	}
+	if page.Title == "" {
+		page.Title = "Agent transcript"
+	}
+	threads := make(map[string]int)
+	for _, m := range a.GetMessages() {
+		var blocks []transcriptBlock
+		if content, err := msgcodec.Decompress(m.GetContent(), m.GetContentCompression()); err != nil {
+			blocks = []transcriptBlock{{Kind: blockNote, Text: "content could not be decoded"}}
+		} else {
+			blocks = transcriptBlocks(content)
+		}
+		if len(blocks) == 0 && m.GetDeliveryError() == "" {
+			continue
+		}
+		if spanType := m.GetSpanType(); m.GetSpanId() != "" && spanType != "" && !nonToolSpanTypes[spanType] {
+			if i, ok := threads[m.GetSpanId()]; ok {
+				page.Entries[i].Blocks = append(page.Entries[i].Blocks, blocks...)
+				continue
+			}
+			threads[m.GetSpanId()] = len(page.Entries)
+			page.Entries = append(page.Entries, transcriptEntry{Role: "tool", Tool: spanType, Time: m.GetCreatedAt(), Blocks: blocks})
+			continue
+		}
+		entry := transcriptEntry{Time: m.GetCreatedAt(), Error: m.GetDeliveryError(), Blocks: blocks}
+		switch {
+		case m.GetSource() == leapmuxv1.MessageSource_MESSAGE_SOURCE_LEAPMUX:
+			entry.Role = "notice"
+		case m.GetSource() == leapmuxv1.MessageSource_MESSAGE_SOURCE_USER:
+			entry.Role = "user"
+		default:
+			entry.Role = "agent"
+		}
+		page.Entries = append(page.Entries, entry)
+	}
+	var buf bytes.Buffer
+	if err := transcriptTemplate.Execute(&buf, page); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// transcriptBlocks extracts the readable parts of one persisted message.
+// It knows the shapes LeapMux itself writes and the Claude Code and Codex
+// envelopes; anything else is shown as its envelope type, or verbatim.
+func transcriptBlocks(content []byte) []transcriptBlock {
+	var v map[string]any
+	if err := json.Unmarshal(content, &v); err != nil {
+		if len(bytes.TrimSpace(content)) == 0 {
+			return nil
+		}
+		return []transcriptBlock{{Kind: blockCode, Text: string(content)}}
+	}
+	// A message the user sent: {"content": "..."}.
+	if s, ok := v["content"].(string); ok {
+		return textBlocks(s)
+	}
+	// Claude Code: {"type": "assistant" | "user", "message": {"content": ...}}.
+	if msg, ok := v["message"].(map[string]any); ok {
+		switch c := msg["content"].(type) {
+		case string:
+			return textBlocks(c)
+		case []any:
+			var blocks []transcriptBlock
+			for _, b := range c {
+				if block, ok := b.(map[string]any); ok {
+					blocks = append(blocks, claudeContentBlocks(block)...)
+				}
+			}
+			return blocks
+		}
+	}
+	// Codex: {"method": "item/...", "params": {"item": {...}}}.
+	if params, ok := v["params"].(map[string]any); ok {
+		if item, ok := params["item"].(map[string]any); ok {
+			return codexItemBlocks(item)
+		}
+	}
+	for _, key := range []string{"type", "method"} {
+		if s, ok := v[key].(string); ok && s != "" {
+			return []transcriptBlock{{Kind: blockNote, Text: s}}
+		}
+	}
+	return []transcriptBlock{{Kind: blockCode, Text: prettyJSON(v)}}
+}
+
+func claudeContentBlocks(block map[string]any) []transcriptBlock {
+	switch block["type"] {
+	case "text":
+		s, _ := block["text"].(string)
+		return textBlocks(s)
+	case "tool_use":
+		input, _ := block["input"].(map[string]any)
+		return toolInputBlocks(input)
+	case "tool_result":
+		switch c := block["content"].(type) {
+		case string:
+			return outputBlocks(c)
+		case []any:
+			var blocks []transcriptBlock
+			for _, part := range c {
+				if p, ok := part.(map[string]any); ok && p["type"] == "text" {
+					s, _ := p["text"].(string)
+					blocks = append(blocks, outputBlocks(s)...)
+				}
+			}
+			return blocks
+		}
+	}
+	// Thinking and anything newer stay out of a printout.
+	return nil
+}
+
+// toolInputBlocks shows a tool call's input: an edit as the diff it makes,
+// a command as a shell line, anything else as JSON.
+func toolInputBlocks(input map[string]any) []transcriptBlock {
+	if input == nil {
+		return nil
+	}
+	oldText, hasOld := input["old_string"].(string)
+	newText, hasNew := input["new_string"].(string)
+	if hasOld && hasNew {
+		var blocks []transcriptBlock
+		if path, ok := input["file_path"].(string); ok {
+			blocks = append(blocks, transcriptBlock{Kind: blockNote, Text: path})
+		}
+		var lines []diffLine
+		for _, l := range strings.Split(oldText, "\n") {
+			lines = append(lines, diffLine{Class: "del", Text: "-" + l})
+		}
+		for _, l := range strings.Split(newText, "\n") {
+			lines = append(lines, diffLine{Class: "add", Text: "+" + l})
+		}
+		return append(blocks, transcriptBlock{Kind: blockDiff, Lines: lines})
+	}
+	if cmd, ok := input["command"].(string); ok {
+		return []transcriptBlock{{Kind: blockCode, Text: "$ " + cmd}}
+	}
+	return []transcriptBlock{{Kind: blockCode, Text: prettyJSON(input)}}
+}
+
+func codexItemBlocks(item map[string]any) []transcriptBlock {
+	switch item["type"] {
+	case "agentMessage", "plan":
+		s, _ := item["text"].(string)
+		return textBlocks(s)
+	case "commandExecution":
+		var blocks []transcriptBlock
+		if cmd, ok := item["command"].(string); ok && cmd != "" {
+			blocks = append(blocks, transcriptBlock{Kind: blockCode, Text: "$ " + cmd})
+		}
+		if out, ok := item["aggregatedOutput"].(string); ok {
+			blocks = append(blocks, outputBlocks(out)...)
+		}
+		return blocks
+	case "fileChange":
+		var blocks []transcriptBlock
+		changes, _ := item["changes"].([]any)
+		for _, c := range changes {
+			change, ok := c.(map[string]any)
+			if !ok {
+				continue
+			}
+			if path, ok := change["path"].(string); ok {
+				blocks = append(blocks, transcriptBlock{Kind: blockNote, Text: path})
+			}
+			if diff, ok := change["diff"].(string); ok {
+				blocks = append(blocks, transcriptBlock{Kind: blockDiff, Lines: diffLines(diff)})
+			}
+		}
+		return blocks
+	case "reasoning":
+		return nil
+	}
+	return []transcriptBlock{{Kind: blockCode, Text: prettyJSON(item)}}
+}
+
+func textBlocks(s string) []transcriptBlock {
+	if strings.TrimSpace(s) == "" {
+		return nil
+	}
+	return []transcriptBlock{{Kind: blockText, Text: s}}
+}
+
+// outputBlocks shows tool output, highlighted when it is a diff.
+func outputBlocks(s string) []transcriptBlock {
+	if strings.TrimSpace(s) == "" {
+		return nil
+	}
+	if looksLikeDiff(s) {
+		return []transcriptBlock{{Kind: blockDiff, Lines: diffLines(s)}}
+	}
+	return []transcriptBlock{{Kind: blockCode, Text: s}}
+}
+
+// looksLikeDiff reports whether s reads as a unified diff: it has a hunk
+// header, or a file header pair.
+func looksLikeDiff(s string) bool {
+	var oldHeader, newHeader bool
+	for _, l := range strings.Split(s, "\n") {
+		switch {
+		case strings.HasPrefix(l, "@@"):
+			return true
+		case strings.HasPrefix(l, "--- "):
+			oldHeader = true
+		case strings.HasPrefix(l, "+++ "):
+			newHeader = true
+		}
+	}
+	return oldHeader && newHeader
+}
+
+func diffLines(s string) []diffLine {
+	lines := strings.Split(strings.TrimSuffix(s, "\n"), "\n")
+	out := make([]diffLine, len(lines))
+	for i, l := range lines {
+		class := "ctx"
+		switch {
+		case strings.HasPrefix(l, "@@"):
+			class = "hunk"
+		case strings.HasPrefix(l, "+++"), strings.HasPrefix(l, "---"):
+			class = "ctx"
+		case strings.HasPrefix(l, "+"):
+			class = "add"
+		case strings.HasPrefix(l, "-"):
+			class = "del"
+		}
+		out[i] = diffLine{Class: class, Text: l}
+	}
+	return out
+}
+
+func prettyJSON(v any) string {
+	b, err := json.MarshalIndent(v, "", "  ")
+	if err != nil {
+		return ""
+	}
+	return string(b)
+}
+
+var transcriptTemplate = template.Must(template.New("transcript").Parse(`<!DOCTYPE html>
+<html lang="en">
+<head>
+<meta charset="utf-8">
+<title>{{.Title}}</title>
+<style>
+body { font: 14px/1.5 system-ui, sans-serif; color: #1f2328; max-width: 56rem; margin: 2rem auto; padding: 0 1rem; }
+header { border-bottom: 1px solid #d0d7de; margin-bottom: 1.5rem; }
+header p { color: #59636e; margin: 0.25rem 0 1rem; }
+.entry { margin: 0 0 1rem; break-inside: avoid; }
+.meta { color: #59636e; font-size: 12px; }
+.user { border-left: 3px solid #0969da; padding-left: 0.75rem; }
+.notice { color: #59636e; font-size: 12px; }
+.text { white-space: pre-wrap; margin: 0.25rem 0; }
+.note { color: #59636e; font-family: ui-monospace, monospace; font-size: 12px; margin: 0.25rem 0; }
+.error { color: #cf222e; font-size: 12px; }
+pre { font: 12px/1.45 ui-monospace, monospace; background: #f6f8fa; padding: 0.5rem; white-space: pre-wrap; word-break: break-all; margin: 0.25rem 0; }
+pre span { display: block; }
+.add { background: #dafbe1; }
+.del { background: #ffebe9; }
+.hunk { color: #0550ae; }
+details { border: 1px solid #d0d7de; border-radius: 4px; padding: 0.25rem 0.5rem; }
+summary { font-family: ui-monospace, monospace; font-size: 12px; cursor: pointer; }
+</style>
+</head>
+<body>
+<header>
+<h1>{{.Title}}</h1>
+<p>{{.Provider}}{{if .WorkingDir}} &middot; {{.WorkingDir}}{{end}} &middot; rendered {{.RenderedAt}}</p>
+</header>
+{{- range .Entries}}
+<div class="entry {{.Role}}">
+{{- if eq .Role "tool"}}
+<details{{if $.ExpandTools}} open{{end}}><summary>{{.Tool}} <span class="meta">{{.Time}}</span></summary>
+{{template "blocks" .Blocks}}
+</details>
+{{- else}}
+<div class="meta">{{.Role}} &middot; {{.Time}}</div>
+{{template "blocks" .Blocks}}
+{{- if .Error}}<div class="error">Not delivered: {{.Error}}</div>{{end}}
+{{- end}}
+</div>
+{{- end}}
+</body>
+</html>
+{{define "blocks"}}
+{{- range .}}
+{{- if eq .Kind "text"}}<div class="text">{{.Text}}</div>
+{{- else if eq .Kind "note"}}<div class="note">{{.Text}}</div>
+{{- else if eq .Kind "diff"}}<pre>{{range .Lines}}<span class="{{.Class}}">{{.Text}}</span>{{end}}</pre>
+{{- else}}<pre>{{.Text}}</pre>
+{{- end}}
+{{- end}}
+{{- end}}
+`))
+
+func registerTranscriptHandlers(d registrar, svc *Service) {
+	// RenderAgentTranscript builds on the workspace export: the agent is
+	// bundled exactly as ExportWorkspaceAgents would ship it, then rendered.
+	registerAgentGated(d, "RenderAgentTranscript",
+		func(ctx context.Context, _ userid.UserID, r *leapmuxv1.RenderAgentTranscriptRequest, agentRow db.Agent, sender channel.ResponseWriter) {
+			bundled, err := svc.bundleAgent(ctx, agentRow, r.GetExcludeClassificationTags())
+			if err != nil {
+				slog.Error("failed to export agent for transcript", "agent_id", agentRow.ID, "error", err)
+				sendInternalError(sender, "failed to render transcript")
+				return
+			}
+			html, err := renderTranscriptHTML(bundled, r.GetExpandToolThreads(), nowMillis())
+			if err != nil {
+				slog.Error("failed to render transcript", "agent_id", agentRow.ID, "error", err)
+				sendInternalError(sender, "failed to render transcript")
+				return
+			}
+			sendProtoResponse(sender, &leapmuxv1.RenderAgentTranscriptResponse{Html: string(html)})
+		})
+}
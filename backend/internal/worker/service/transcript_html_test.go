@@ -0,0 +1,79 @@
+package service
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	leapmuxv1 "github.com/leapmux/leapmux/generated/proto/leapmux/v1"
+	"github.com/leapmux/leapmux/internal/util/msgcodec"
+	"github.com/leapmux/leapmux/internal/util/sqltime"
+	db "github.com/leapmux/leapmux/internal/worker/generated/db"
+)
+
+func TestRenderAgentTranscript(t *testing.T) {
+	svc, d, w := setupTestService(t, withWorkspaces("ws-1"))
+	ctx := context.Background()
+	seedGuardedAgent(t, svc, "")
+	for i, m := range []struct {
+		source   leapmuxv1.MessageSource
+		spanID   string
+		spanType string
+		content  string
+	}{
+		{leapmuxv1.MessageSource_MESSAGE_SOURCE_USER, "", "", `{"content":"Fix the <b>bug</b>"}`},
+		{leapmuxv1.MessageSource_MESSAGE_SOURCE_AGENT, "", "", `{"type":"assistant","message":{"content":[{"type":"thinking","thinking":"secret plan"}]}}`},
+		{leapmuxv1.MessageSource_MESSAGE_SOURCE_AGENT, "", "", `{"type":"assistant","message":{"content":[{"type":"text","text":"Done."}]}}`},
+		{leapmuxv1.MessageSource_MESSAGE_SOURCE_AGENT, "toolu_1", "Edit", `{"type":"assistant","message":{"content":[{"type":"tool_use","id":"toolu_1","name":"Edit","input":{"file_path":"main.go","old_string":"a := 1","new_string":"a := 2"}}]}}`},
+		{leapmuxv1.MessageSource_MESSAGE_SOURCE_LEAPMUX, "", "", `{"type":"settings_changed"}`},
+		{leapmuxv1.MessageSource_MESSAGE_SOURCE_USER, "toolu_1", "Edit", `{"type":"user","message":{"content":[{"type":"tool_result","tool_use_id":"toolu_1","content":"updated"}]}}`},
+		{leapmuxv1.MessageSource_MESSAGE_SOURCE_AGENT, "fc-1", "fileChange", `{"method":"item/completed","params":{"item":{"type":"fileChange","changes":[{"path":"a.txt","diff":"@@ -1 +1 @@\n-old\n+new"}]}}}`},
+	} {
+		compressed, compression := msgcodec.Compress([]byte(m.content))
+		_, err := createMessageRow(ctx, svc.Queries, db.CreateMessageParams{
+			ID:                 []string{"m1", "m2", "m3", "m4", "m5", "m6", "m7"}[i],
+			AgentID:            "agent-1",
+			Source:             m.source,
+			Content:            compressed,
+			ContentCompression: compression,
+			SpanID:             m.spanID,
+			SpanType:           m.spanType,
+			AgentProvider:      claudeProvider,
+			CreatedAt:          sqltime.NewSQLiteTime(nowMillis()),
+		})
+		require.NoError(t, err)
+	}
+
+	dispatch(d, "RenderAgentTranscript", &leapmuxv1.RenderAgentTranscriptRequest{AgentId: "agent-1"}, w)
+	require.Empty(t, w.errors)
+	html := decodeResponse[leapmuxv1.RenderAgentTranscriptResponse](t, w).GetHtml()
+
+	assert.True(t, strings.HasPrefix(html, "<!DOCTYPE html>"))
+	assert.NotContains(t, html, "<script", "the page is self-contained and inert")
+	assert.Contains(t, html, "Fix the &lt;b&gt;bug&lt;/b&gt;", "message text is escaped")
+	assert.Contains(t, html, "Done.")
+	assert.NotContains(t, html, "secret plan", "thinking stays out of the printout")
+	assert.Contains(t, html, "settings_changed")
+
+	// The call and its result share one collapsed thread.
+	assert.Equal(t, 1, strings.Count(html, "<summary>Edit "))
+	assert.Contains(t, html, `<span class="del">-a := 1</span><span class="add">&#43;a := 2</span>`)
+	assert.Contains(t, html, "updated")
+	assert.NotContains(t, html, "<details open>")
+
+	// Codex file changes are highlighted diffs.
+	assert.Contains(t, html, `<span class="hunk">@@ -1 &#43;1 @@</span>`)
+
+	dispatch(d, "RenderAgentTranscript", &leapmuxv1.RenderAgentTranscriptRequest{AgentId: "agent-1", ExpandToolThreads: true}, w)
+	require.Empty(t, w.errors)
+	assert.Contains(t, decodeResponse[leapmuxv1.RenderAgentTranscriptResponse](t, w).GetHtml(), "<details open>")
+}
+
+func TestLooksLikeDiff(t *testing.T) {
+	assert.True(t, looksLikeDiff("@@ -1 +1 @@\n-a\n+b"))
+	assert.True(t, looksLikeDiff("--- a/x\n+++ b/x\n"))
+	assert.False(t, looksLikeDiff("- a list item\n+ not a diff"))
+}
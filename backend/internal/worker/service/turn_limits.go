@@ -0,0 +1,263 @@
+package service
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"log/slog"
+	"math"
+	"sync"
+	"time"
+
+	leapmuxv1 "github.com/leapmux/leapmux/generated/proto/leapmux/v1"
+	"github.com/leapmux/leapmux/internal/util/periodic"
+	"github.com/leapmux/leapmux/internal/util/userid"
+	"github.com/leapmux/leapmux/internal/worker/agent"
+	"github.com/leapmux/leapmux/internal/worker/channel"
+	db "github.com/leapmux/leapmux/internal/worker/generated/db"
+	"google.golang.org/protobuf/encoding/protojson"
+)
+
+// turnLimitInterval is how often running turns are checked against their
+// workspace's duration limit. The cost limit is checked as usage arrives.
+const turnLimitInterval = 10 * time.Second
+
+// Turn limits, the limit a limit_exceeded notification names.
+const (
+	turnLimitDuration = "duration"
+	turnLimitCost     = "cost"
+)
+
+// turnTally is the usage an agent's current turn has reported so far, and
+// the workspace limits it runs under, read when the turn is first seen.
+type turnTally struct {
+	limits *leapmuxv1.TurnLimits
+
+	mu           sync.Mutex
+	costUSD      float64
+	inputTokens  int64
+	outputTokens int64
+	// exceeded is set once a limit was reported; a turn is stopped once.
+	exceeded bool
+}
+
+// add folds one span's usage into the tally. A turn's total replaces the
+// per-message sums it repeats.
+func (t *turnTally) add(u agent.MessageUsage) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if u.Turn {
+		t.inputTokens = u.InputTokens
+		t.outputTokens = u.OutputTokens
+		t.costUSD = max(t.costUSD, u.CostUSD)
+		return
+	}
+	t.inputTokens += u.InputTokens
+	t.outputTokens += u.OutputTokens
+	t.costUSD += u.CostUSD
+}
+
+// loadWorkspaceTurnLimits returns the workspace's turn limits, empty when
+// it has none.
+func loadWorkspaceTurnLimits(ctx context.Context, queries *db.Queries, workspaceID string) (*leapmuxv1.TurnLimits, error) {
+	raw, err := queries.GetWorkspaceTurnLimits(ctx, workspaceID)
+	if errors.Is(err, sql.ErrNoRows) {
+		return &leapmuxv1.TurnLimits{}, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	limits := &leapmuxv1.TurnLimits{}
+	if err := protojson.Unmarshal([]byte(raw), limits); err != nil {
+		return nil, fmt.Errorf("decode turn limits: %w", err)
+	}
+	return limits, nil
+}
+
+// validateTurnLimits rejects limits no turn could be held to.
+func validateTurnLimits(limits *leapmuxv1.TurnLimits) error {
+	if limits.GetMaxTurnSeconds() < 0 {
+		return errors.New("max_turn_seconds must not be negative")
+	}
+	cost := limits.GetMaxTurnCostUsd()
+	if cost < 0 || math.IsNaN(cost) || math.IsInf(cost, 0) {
+		return errors.New("max_turn_cost_usd must be a non-negative number")
+	}
+	return nil
+}
+
+// turnTally returns agentID's tally for its current turn, starting one
+// under its workspace's limits if there is none.
+func (svc *Service) turnTally(agentID string) *turnTally {
+	if v, ok := svc.turnTallies.Load(agentID); ok {
+		return v.(*turnTally)
+	}
+	limits := &leapmuxv1.TurnLimits{}
+	if workspaceID, err := svc.Queries.GetAgentWorkspaceID(bgCtx(), agentID); err != nil {
+		slog.Warn("turn limits: failed to load agent workspace", "agent_id", agentID, "error", err)
+	} else if l, err := loadWorkspaceTurnLimits(bgCtx(), svc.Queries, workspaceID); err != nil {
+		slog.Warn("turn limits: failed to load limits; turn is unlimited",
+			"agent_id", agentID, "workspace_id", workspaceID, "error", err)
+	} else {
+		limits = l
+	}
+	v, _ := svc.turnTallies.LoadOrStore(agentID, &turnTally{limits: limits})
+	return v.(*turnTally)
+}
+
+// agentTurnStarted returns when agentID's current or last turn began, and
+// whether it is still in flight.
+func (h *OutputHandler) agentTurnStarted(agentID string) (time.Time, bool) {
+	a := h.agentActivity(agentID)
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	return a.started, a.inTurn
+}
+
+// observeTurnUsage tallies a span's usage against the turn's cost limit.
+// A turn whose result pushes it over is already over, so it is reported
+// but not interrupted.
+func (svc *Service) observeTurnUsage(agentID string, provider leapmuxv1.AgentProvider, span agent.SpanInfo, turnEnd bool) {
+	if span.Usage == nil {
+		if turnEnd {
+			svc.turnTallies.Delete(agentID)
+		}
+		return
+	}
+	t := svc.turnTally(agentID)
+	if turnEnd {
+		svc.turnTallies.Delete(agentID)
+	}
+	t.add(*span.Usage)
+	limit := t.limits.GetMaxTurnCostUsd()
+	t.mu.Lock()
+	over := limit > 0 && t.costUSD >= limit
+	t.mu.Unlock()
+	if over {
+		svc.exceedTurnLimit(agentID, provider, t, turnLimitCost, limit, !turnEnd)
+	}
+}
+
+// StartTurnLimitLoop starts the background loop that holds running turns
+// to their workspace's duration limit.
+func (svc *Service) StartTurnLimitLoop(ctx context.Context) {
+	periodic.Start(ctx, periodic.Schedule{Interval: turnLimitInterval, SkipFirstRun: true}, func(context.Context) {
+		svc.CheckTurnLimits()
+	})
+}
+
+// CheckTurnLimits interrupts every running turn that has outlasted its
+// workspace's max_turn_seconds, and forgets the tallies of agents no
+// longer mid-turn.
+func (svc *Service) CheckTurnLimits() {
+	running := make(map[string]bool)
+	for _, agentID := range svc.Agents.ListAgentIDs() {
+		started, inTurn := svc.Output.agentTurnStarted(agentID)
+		if !inTurn {
+			continue
+		}
+		running[agentID] = true
+		t := svc.turnTally(agentID)
+		limit := t.limits.GetMaxTurnSeconds()
+		if limit > 0 && svc.Output.now().Sub(started) >= time.Duration(limit)*time.Second {
+			svc.exceedTurnLimit(agentID, 0, t, turnLimitDuration, float64(limit), true)
+		}
+	}
+	svc.turnTallies.Range(func(k, _ any) bool {
+		if !running[k.(string)] {
+			svc.turnTallies.Delete(k)
+		}
+		return true
+	})
+}
+
+// exceedTurnLimit posts the limit agentID's turn ran past in its chat and,
+// when interrupt is set, interrupts the turn. A zero provider is looked up.
+func (svc *Service) exceedTurnLimit(agentID string, provider leapmuxv1.AgentProvider, t *turnTally, limit string, threshold float64, interrupt bool) {
+	t.mu.Lock()
+	if t.exceeded {
+		t.mu.Unlock()
+		return
+	}
+	t.exceeded = true
+	payload := agent.LimitExceededPayload{
+		Limit:        limit,
+		Threshold:    threshold,
+		CostUSD:      t.costUSD,
+		InputTokens:  t.inputTokens,
+		OutputTokens: t.outputTokens,
+		Interrupted:  interrupt,
+	}
+	t.mu.Unlock()
+	if started, _ := svc.Output.agentTurnStarted(agentID); !started.IsZero() {
+		payload.ElapsedSeconds = int64(svc.Output.now().Sub(started) / time.Second)
+	}
+	if provider == leapmuxv1.AgentProvider_AGENT_PROVIDER_UNSPECIFIED {
+		dbAgent, err := svc.Queries.GetAgentByID(bgCtx(), agentID)
+		if err != nil {
+			slog.Warn("turn limits: failed to load agent", "agent_id", agentID, "error", err)
+			return
+		}
+		provider = dbAgent.AgentProvider
+	}
+
+	slog.Warn("turn limit exceeded", "agent_id", agentID, "limit", limit, "threshold", threshold, "interrupt", interrupt)
+	svc.Output.PersistLeapMuxNotification(agentID, provider, agent.NotificationContent(payload))
+	if interrupt {
+		// Off the caller: an interrupt waits for the agent to answer.
+		go func() {
+			if err := svc.interruptAgent(agentID, userid.UserID{}, leapmuxv1.InterruptReason_INTERRUPT_REASON_BUDGET, "turn "+limit+" limit"); err != nil {
+				slog.Warn("turn limits: failed to interrupt agent", "agent_id", agentID, "error", err)
+			}
+		}()
+	}
+}
+
+// registerTurnLimitHandlers registers the per-workspace turn limit RPCs.
+func registerTurnLimitHandlers(d registrar, svc *Service) {
+	registerWorkspaceGated(d, "GetWorkspaceTurnLimits",
+		func(ctx context.Context, _ userid.UserID, r *leapmuxv1.GetWorkspaceTurnLimitsRequest, sender channel.ResponseWriter) {
+			limits, err := loadWorkspaceTurnLimits(ctx, svc.Queries, r.GetWorkspaceId())
+			if err != nil {
+				slog.Error("failed to load turn limits", "workspace_id", r.GetWorkspaceId(), "error", err)
+				sendInternalError(sender, "failed to load turn limits")
+				return
+			}
+			sendProtoResponse(sender, &leapmuxv1.GetWorkspaceTurnLimitsResponse{Limits: limits})
+		})
+
+	registerWorkspaceGated(d, "SetWorkspaceTurnLimits",
+		func(_ context.Context, _ userid.UserID, r *leapmuxv1.SetWorkspaceTurnLimitsRequest, sender channel.ResponseWriter) {
+			limits := r.GetLimits()
+			if limits == nil {
+				limits = &leapmuxv1.TurnLimits{}
+			}
+			if err := validateTurnLimits(limits); err != nil {
+				sendInvalidArgument(sender, err.Error())
+				return
+			}
+
+			// No limits is the default; drop the row rather than storing
+			// limits that say nothing.
+			var err error
+			if limits.GetMaxTurnSeconds() == 0 && limits.GetMaxTurnCostUsd() == 0 {
+				err = svc.Queries.DeleteWorkspaceTurnLimits(bgCtx(), r.GetWorkspaceId())
+			} else {
+				var raw []byte
+				raw, err = protojson.Marshal(limits)
+				if err == nil {
+					err = svc.Queries.UpsertWorkspaceTurnLimits(bgCtx(), db.UpsertWorkspaceTurnLimitsParams{
+						WorkspaceID: r.GetWorkspaceId(),
+						Limits:      string(raw),
+					})
+				}
+			}
+			if err != nil {
+				slog.Error("failed to save turn limits", "workspace_id", r.GetWorkspaceId(), "error", err)
+				sendInternalError(sender, "failed to save turn limits")
+				return
+			}
+			sendProtoResponse(sender, &leapmuxv1.SetWorkspaceTurnLimitsResponse{Limits: limits})
+		})
+}
@@ -0,0 +1,125 @@
+package service
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/protobuf/encoding/protojson"
+	"google.golang.org/protobuf/proto"
+
+	leapmuxv1 "github.com/leapmux/leapmux/generated/proto/leapmux/v1"
+	"github.com/leapmux/leapmux/internal/worker/agent"
+	db "github.com/leapmux/leapmux/internal/worker/generated/db"
+)
+
+func exceededLimits(t *testing.T, svc *Service) []map[string]interface{} {
+	t.Helper()
+	return findNotificationsByType(readAllNotifications(t, svc.Queries, "agent-1"), agent.NotificationTypeLimitExceeded)
+}
+
+func setTurnLimits(t *testing.T, svc *Service, limits *leapmuxv1.TurnLimits) {
+	t.Helper()
+	raw, err := protojson.Marshal(limits)
+	require.NoError(t, err)
+	require.NoError(t, svc.Queries.UpsertWorkspaceTurnLimits(bgCtx(), db.UpsertWorkspaceTurnLimitsParams{
+		WorkspaceID: "ws-1", Limits: string(raw),
+	}))
+}
+
+func TestCheckTurnLimits_InterruptsLongTurnOnce(t *testing.T) {
+	svc, _, _ := setupTestService(t, withWorkspaces("ws-1"))
+	setTurnLimits(t, svc, &leapmuxv1.TurnLimits{MaxTurnSeconds: 600})
+	clock := startIdleParkAgent(t, svc)
+	svc.Output.beginAgentTurn("agent-1")
+
+	*clock = clock.Add(9 * time.Minute)
+	svc.CheckTurnLimits()
+	require.Empty(t, exceededLimits(t, svc))
+
+	// Output does not extend the turn's budget.
+	svc.Output.touchAgent("agent-1")
+	*clock = clock.Add(time.Minute)
+	svc.CheckTurnLimits()
+	notes := exceededLimits(t, svc)
+	require.Len(t, notes, 1)
+	assert.Equal(t, "duration", notes[0]["limit"])
+	assert.EqualValues(t, 600, notes[0]["threshold"])
+	assert.EqualValues(t, 600, notes[0]["elapsed_seconds"])
+	assert.Equal(t, true, notes[0]["interrupted"])
+
+	*clock = clock.Add(time.Hour)
+	svc.CheckTurnLimits()
+	require.Len(t, exceededLimits(t, svc), 1, "a turn is stopped once")
+
+	// The next turn gets a fresh budget.
+	svc.Output.endAgentTurn("agent-1")
+	svc.CheckTurnLimits()
+	svc.Output.beginAgentTurn("agent-1")
+	*clock = clock.Add(11 * time.Minute)
+	svc.CheckTurnLimits()
+	assert.Len(t, exceededLimits(t, svc), 2)
+}
+
+func TestObserveTurnUsage_StopsTurnOverCostLimit(t *testing.T) {
+	svc, _, _ := setupTestService(t, withWorkspaces("ws-1"))
+	setTurnLimits(t, svc, &leapmuxv1.TurnLimits{MaxTurnCostUsd: 1})
+	startIdleParkAgent(t, svc)
+	svc.Output.beginAgentTurn("agent-1")
+
+	usage := func(u agent.MessageUsage) agent.SpanInfo { return agent.SpanInfo{Usage: &u} }
+	svc.observeTurnUsage("agent-1", claudeProvider, usage(agent.MessageUsage{InputTokens: 100, OutputTokens: 10, CostUSD: 0.6}), false)
+	require.Empty(t, exceededLimits(t, svc))
+	svc.observeTurnUsage("agent-1", claudeProvider, usage(agent.MessageUsage{InputTokens: 50, OutputTokens: 5, CostUSD: 0.5}), false)
+	notes := exceededLimits(t, svc)
+	require.Len(t, notes, 1)
+	assert.Equal(t, "cost", notes[0]["limit"])
+	assert.InDelta(t, 1.1, notes[0]["cost_usd"], 1e-9)
+	assert.EqualValues(t, 150, notes[0]["input_tokens"])
+	assert.EqualValues(t, 15, notes[0]["output_tokens"])
+	assert.Equal(t, true, notes[0]["interrupted"])
+
+	// A turn whose result is what crosses the limit is reported, but is
+	// already over.
+	svc.Output.endAgentTurn("agent-1")
+	svc.observeTurnUsage("agent-1", claudeProvider, agent.SpanInfo{}, true)
+	svc.Output.beginAgentTurn("agent-1")
+	svc.observeTurnUsage("agent-1", claudeProvider, usage(agent.MessageUsage{InputTokens: 10, InTurn: true}), false)
+	svc.Output.endAgentTurn("agent-1")
+	svc.observeTurnUsage("agent-1", claudeProvider, usage(agent.MessageUsage{InputTokens: 20, CostUSD: 2, Turn: true}), true)
+	notes = exceededLimits(t, svc)
+	require.Len(t, notes, 2)
+	assert.EqualValues(t, 20, notes[1]["input_tokens"], "the turn's total replaces its messages' sum")
+	assert.Equal(t, false, notes[1]["interrupted"])
+}
+
+func TestWorkspaceTurnLimits_SetAndGet(t *testing.T) {
+	_, d, w := setupTestService(t, withWorkspaces("ws-1"))
+
+	limits := &leapmuxv1.TurnLimits{MaxTurnSeconds: 3600, MaxTurnCostUsd: 5}
+	dispatch(d, "SetWorkspaceTurnLimits", &leapmuxv1.SetWorkspaceTurnLimitsRequest{WorkspaceId: "ws-1", Limits: limits}, w)
+	require.Empty(t, w.errors)
+
+	dispatch(d, "GetWorkspaceTurnLimits", &leapmuxv1.GetWorkspaceTurnLimitsRequest{WorkspaceId: "ws-1"}, w)
+	require.Empty(t, w.errors)
+	require.Len(t, w.responses, 2)
+	var resp leapmuxv1.GetWorkspaceTurnLimitsResponse
+	require.NoError(t, proto.Unmarshal(w.responses[1].GetPayload(), &resp))
+	assert.True(t, proto.Equal(limits, resp.GetLimits()))
+
+	// Empty limits clear the stored ones.
+	dispatch(d, "SetWorkspaceTurnLimits", &leapmuxv1.SetWorkspaceTurnLimitsRequest{WorkspaceId: "ws-1"}, w)
+	dispatch(d, "GetWorkspaceTurnLimits", &leapmuxv1.GetWorkspaceTurnLimitsRequest{WorkspaceId: "ws-1"}, w)
+	require.Empty(t, w.errors)
+	require.Len(t, w.responses, 4)
+	require.NoError(t, proto.Unmarshal(w.responses[3].GetPayload(), &resp))
+	assert.True(t, proto.Equal(&leapmuxv1.TurnLimits{}, resp.GetLimits()))
+
+	dispatch(d, "SetWorkspaceTurnLimits", &leapmuxv1.SetWorkspaceTurnLimitsRequest{
+		WorkspaceId: "ws-1",
+		Limits:      &leapmuxv1.TurnLimits{MaxTurnSeconds: -1},
+	}, w)
+	require.Len(t, w.errors, 1)
+	assert.Equal(t, codeInvalidArgument, w.errors[0].code)
+}
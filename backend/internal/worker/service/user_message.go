@@ -0,0 +1,284 @@
+package service
+
+import (
+	"encoding/json"
+	"fmt"
+	"log/slog"
+
+	leapmuxv1 "github.com/leapmux/leapmux/generated/proto/leapmux/v1"
+	"github.com/leapmux/leapmux/internal/util/id"
+	"github.com/leapmux/leapmux/internal/util/msgcodec"
+	"github.com/leapmux/leapmux/internal/util/sqltime"
+	"github.com/leapmux/leapmux/internal/util/timefmt"
+	"github.com/leapmux/leapmux/internal/worker/agent"
+	"github.com/leapmux/leapmux/internal/worker/channel"
+	db "github.com/leapmux/leapmux/internal/worker/generated/db"
+)
+
+// maxAttachmentSize caps the total bytes a single user message may carry.
+const maxAttachmentSize = 10 * 1024 * 1024
+
+// userInput is a validated user prompt on its way to an agent.
+type userInput struct {
+	content        string
+	attachments    []*leapmuxv1.Attachment
+	idempotencyKey string
+	// voiceNote, when set, records that content is a transcript of this
+	// clip. It is persisted with the message but never sent to the agent.
+	voiceNote *voiceNoteMeta
+	// terminalOutput, when set, records that content quotes this terminal's
+	// output. Like voiceNote it is persisted but never sent to the agent.
+	terminalOutput *terminalOutputMeta
+}
+
+// rejectFailedStartup answers FailedPrecondition and returns true when the
+// agent failed to start permanently.
+//
+// Sends are rejected only on permanent startup failure — STARTING messages
+// are queued on the frontend and dispatched on the status transition to
+// ACTIVE. A STARTING-state send gate on the server would race with the
+// ACTIVE broadcast that fires from the output sink before runAgentStartup's
+// bookkeeping completes; ensureAgentRunning already restarts crashed
+// subprocesses on demand. The persisted startup_error is checked too
+// (covers worker restart: the in-memory registry was wiped but the DB
+// remembers the failure).
+func (svc *Service) rejectFailedStartup(sender channel.ResponseWriter, agentID string, dbAgent db.Agent) bool {
+	if status, _, _, ok := svc.AgentStartup.status(agentID); ok && status == leapmuxv1.AgentStatus_AGENT_STATUS_STARTUP_FAILED {
+		sendFailedPrecondition(sender, "agent failed to start; open a new agent")
+		return true
+	}
+	if dbAgent.StartupError != "" && !svc.Agents.HasAgent(agentID) {
+		sendFailedPrecondition(sender, "agent failed to start; open a new agent")
+		return true
+	}
+	return false
+}
+
+// submitUserMessage persists in as a user message, forwards it to the agent
+// subprocess, and broadcasts it to every connected watcher. respond sends
+// the RPC's success response; it runs once the message is persisted and
+// delivery attempted, before the broadcast, and is told whether the send
+// was a suppressed duplicate. Error responses are sent directly.
+func (svc *Service) submitUserMessage(sender channel.ResponseWriter, dbAgent db.Agent, in userInput, respond func(messageID string, duplicate bool)) {
+	agentID := dbAgent.ID
+	content := in.content
+
+	// Pre-resolve the resume session ID BEFORE persisting the user
+	// message. HasUserMessages must run before the current message is
+	// written; otherwise the just-persisted message is counted as a
+	// prior conversation and --resume is used for a session that never
+	// had any messages (e.g. after an app restart on an idle tab).
+	resumeSessionID := svc.resolveResumeSessionID(agentID, dbAgent.AgentSessionID, dbAgent.Resumed)
+
+	attachments, err := agent.NormalizeAttachmentsForProvider(
+		leapmuxv1.AgentProvider(dbAgent.AgentProvider),
+		in.attachments,
+	)
+	if err != nil {
+		sendInvalidArgument(sender, err.Error())
+		return
+	}
+
+	// A LeapMux slash command (e.g. /clear) is refused here, before the
+	// message is persisted, when it is malformed or not allowed.
+	cmd, cmdArgs, isCommand := slashCommands.match(content)
+	if isCommand {
+		if err := cmd.Parse(cmdArgs); err != nil {
+			sendInvalidArgument(sender, err.Error())
+			return
+		}
+		if err := cmd.Permit(svc, dbAgent); err != nil {
+			sendFailedPrecondition(sender, err.Error())
+			return
+		}
+	}
+
+	// A retry of a send the worker already accepted (the caller timed
+	// out waiting for the ack) must not deliver the prompt twice.
+	idempotencyKey := in.idempotencyKey
+	if len(idempotencyKey) > maxIdempotencyKeyLen {
+		sendInvalidArgument(sender, fmt.Sprintf("idempotency_key exceeds %d bytes", maxIdempotencyKeyLen))
+		return
+	}
+
+	messageID := id.Generate()
+	if idempotencyKey != "" {
+		if originalID, claimed := svc.claimAgentInputKey(agentID, idempotencyKey, messageID); !claimed {
+			respond(originalID, true)
+			return
+		}
+	}
+	now := nowMillis()
+
+	// Store user content as a plain JSON object with a "content" field,
+	// which the frontend classifies as user_content and renders as markdown.
+	// When attachments are present, include their metadata (filename + mime_type)
+	// but not the raw binary data (too large for DB storage).
+	payload := map[string]interface{}{"content": content}
+	if len(attachments) > 0 {
+		type attachmentMeta struct {
+			Filename string `json:"filename"`
+			MimeType string `json:"mime_type"`
+		}
+		meta := make([]attachmentMeta, len(attachments))
+		for i, a := range attachments {
+			meta[i] = attachmentMeta{Filename: a.GetFilename(), MimeType: a.GetMimeType()}
+		}
+		payload["attachments"] = meta
+	}
+	if in.voiceNote != nil {
+		payload["voice_note"] = in.voiceNote
+	}
+	if in.terminalOutput != nil {
+		payload["terminal_output"] = in.terminalOutput
+	}
+	// A marshal failure must NOT fall through: innerJSON would stay nil and we'd
+	// compress + persist + broadcast an empty-content row (while still handing the
+	// agent the real content), silently corrupting the visible history. Fail the
+	// RPC instead so the caller can retry, mirroring the persist-failure path below.
+	innerJSON, err := json.Marshal(payload)
+	if err != nil {
+		slog.Error("failed to encode user message", "agent_id", agentID, "error", err)
+		svc.releaseAgentInputKey(agentID, idempotencyKey)
+		sendInternalError(sender, "failed to encode message")
+		return
+	}
+	compressed, compressionType := msgcodec.Compress(innerJSON)
+
+	// Capture currently-active spans so the user message renders with
+	// passthrough vertical bars instead of breaking the column.
+	spanLines := svc.Output.snapshotPassthroughSpanLines(agentID)
+
+	// Persist the user message. mark_type=USER_MESSAGE so the scroll rail
+	// draws a jump dot for every message the human actually typed and sent.
+	params := db.CreateMessageParams{
+		ID:                 messageID,
+		AgentID:            agentID,
+		Source:             leapmuxv1.MessageSource_MESSAGE_SOURCE_USER,
+		Content:            compressed,
+		ContentCompression: compressionType,
+		Depth:              0,
+		SpanID:             "",
+		ParentSpanID:       "",
+		SpanLines:          spanLines,
+		SpanColor:          0,
+		AgentProvider:      dbAgent.AgentProvider,
+		MarkType:           leapmuxv1.MarkType_MARK_TYPE_USER_MESSAGE,
+		CreatedAt:          sqltime.NewSQLiteTime(now),
+	}
+	seq, err := svc.Output.persistMessage(bgCtx(), params)
+	if err != nil {
+		slog.Error("failed to persist message", "agent_id", agentID, "error", err)
+		svc.releaseAgentInputKey(agentID, idempotencyKey)
+		sendInternalError(sender, "failed to persist message")
+		return
+	}
+	svc.Output.classifyMessage(agentID, messageID, innerJSON)
+
+	userMsg := &leapmuxv1.AgentChatMessage{
+		Id:                 messageID,
+		Source:             leapmuxv1.MessageSource_MESSAGE_SOURCE_USER,
+		Content:            compressed,
+		ContentCompression: compressionType,
+		Seq:                seq,
+		AgentProvider:      dbAgent.AgentProvider,
+		CreatedAt:          timefmt.Format(now),
+		Depth:              0,
+		SpanLines:          spanLines,
+		MarkType:           leapmuxv1.MarkType_MARK_TYPE_USER_MESSAGE,
+	}
+
+	// For a slash command, broadcast the user message before running it so
+	// live watchers never see its output (e.g. context_cleared) ahead of the
+	// triggering command.
+	if isCommand {
+		svc.Watchers.BroadcastAgentEvent(agentID, &leapmuxv1.AgentEvent{
+			AgentId: agentID,
+			Event: &leapmuxv1.AgentEvent_AgentMessage{
+				AgentMessage: userMsg,
+			},
+		})
+	}
+
+	// Apply the workspace's prompt routing rules before delivery so
+	// the turn runs on the routed model.
+	var routing turnRouting
+	if !isCommand {
+		routing = svc.routeTurnModel(dbAgent, content)
+	}
+
+	// Attempt to send the message to the agent process (unless it's
+	// a command that leapmux handles itself). The agent is handed any
+	// context a summary compaction carried over; the transcript keeps the
+	// message as typed.
+	var deliveryError string
+	input, consumeCarried := content, func() {}
+	if !isCommand {
+		input, consumeCarried = svc.withCarriedContext(agentID, content)
+	}
+	// A turn the account's rate limit holds back is delivered later, by
+	// deliverHeldTurn.
+	held := false
+	if isCommand {
+		svc.runSlashCommand(cmd, dbAgent, cmdArgs)
+	} else if svc.holdTurn(dbAgent, turnInteractive, func() {
+		svc.deliverHeldTurn(agentID, messageID, input, attachments, resumeSessionID, func() {
+			consumeCarried()
+			svc.recordTurnModel(agentID, messageID, routing)
+		})
+	}) {
+		held = true
+	} else if err := svc.emergencyStopErr(); err != nil {
+		// Checked before an auto-start, which a refused turn does not need.
+		deliveryError = err.Error()
+	} else if !svc.Agents.HasAgent(agentID) {
+		// Agent is not running — try to auto-start it (e.g. after worker restart).
+		if startErr := svc.ensureAgentRunning(agentID, &resumeSessionID); startErr != nil {
+			deliveryError = "agent is not running"
+		} else if sendErr := svc.sendAgentInput(agentID, input, attachments); sendErr != nil {
+			slog.Error("failed to send input to agent after auto-start", "agent_id", agentID, "error", sendErr)
+			deliveryError = sendErr.Error()
+		}
+	} else if sendErr := svc.sendAgentInput(agentID, input, attachments); sendErr != nil {
+		slog.Error("failed to send input to agent", "agent_id", agentID, "error", sendErr)
+		deliveryError = sendErr.Error()
+	}
+	if deliveryError != "" {
+		_ = svc.Queries.SetMessageDeliveryError(bgCtx(), db.SetMessageDeliveryErrorParams{
+			DeliveryError: deliveryError,
+			ID:            messageID,
+			AgentID:       agentID,
+		})
+	} else if !isCommand && !held {
+		consumeCarried()
+		svc.recordTurnModel(agentID, messageID, routing)
+	}
+
+	respond(messageID, false)
+
+	// Broadcast the user message to all watchers so it appears in
+	// every connected frontend's chat view.
+	if !isCommand {
+		userMsg.DeliveryError = deliveryError
+		svc.Watchers.BroadcastAgentEvent(agentID, &leapmuxv1.AgentEvent{
+			AgentId: agentID,
+			Event: &leapmuxv1.AgentEvent_AgentMessage{
+				AgentMessage: userMsg,
+			},
+		})
+	}
+
+	// Broadcast delivery error separately (frontend uses both events).
+	if deliveryError != "" {
+		svc.Watchers.BroadcastAgentEvent(agentID, &leapmuxv1.AgentEvent{
+			AgentId: agentID,
+			Event: &leapmuxv1.AgentEvent_MessageError{
+				MessageError: &leapmuxv1.AgentMessageError{
+					AgentId:   agentID,
+					MessageId: messageID,
+					Error:     deliveryError,
+				},
+			},
+		})
+	}
+}
@@ -0,0 +1,95 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"strings"
+	"time"
+
+	leapmuxv1 "github.com/leapmux/leapmux/generated/proto/leapmux/v1"
+	"github.com/leapmux/leapmux/internal/util/userid"
+	"github.com/leapmux/leapmux/internal/worker/channel"
+	db "github.com/leapmux/leapmux/internal/worker/generated/db"
+	"github.com/leapmux/leapmux/internal/worker/transcribe"
+)
+
+// voiceNoteTranscribeTimeout bounds one transcription. A short clip takes
+// seconds on either backend; the slack covers a cold whisper.cpp model load.
+const voiceNoteTranscribeTimeout = 2 * time.Minute
+
+// voiceNoteMeta is the provenance persisted with a transcribed message:
+// which clip it came from and which backend heard it. Like attachments,
+// only metadata is stored, never the audio.
+type voiceNoteMeta struct {
+	Filename    string `json:"filename"`
+	MimeType    string `json:"mime_type"`
+	Size        int    `json:"size"`
+	Transcriber string `json:"transcriber"`
+}
+
+func registerVoiceNoteHandlers(d registrar, svc *Service) {
+	// SendVoiceNote transcribes a recorded clip and submits the transcript
+	// exactly like SendAgentMessage. Transcription honours the dispatcher
+	// ctx — nothing is persisted yet, so an abandoned request can stop
+	// early — but the submit that follows does not, for the same reason
+	// SendAgentMessage ignores it.
+	registerAgentGated(d, "SendVoiceNote",
+		func(ctx context.Context, _ userid.UserID, r *leapmuxv1.SendVoiceNoteRequest, dbAgent db.Agent, sender channel.ResponseWriter) {
+			if svc.Transcriber == nil {
+				sendFailedPrecondition(sender, "voice notes are not configured on this worker")
+				return
+			}
+			if svc.rejectFailedStartup(sender, dbAgent.ID, dbAgent) {
+				return
+			}
+
+			audio := r.GetAudio()
+			if len(audio.GetData()) == 0 {
+				sendInvalidArgument(sender, "audio is required")
+				return
+			}
+			if !strings.HasPrefix(audio.GetMimeType(), "audio/") {
+				sendInvalidArgument(sender, "audio must have an audio/* MIME type")
+				return
+			}
+			if len(audio.GetData()) > maxAttachmentSize {
+				sendInvalidArgument(sender, "audio exceeds 10 MB")
+				return
+			}
+
+			tctx, cancel := context.WithTimeout(ctx, voiceNoteTranscribeTimeout)
+			transcript, err := svc.Transcriber.Transcribe(tctx, transcribe.Clip{
+				Filename: audio.GetFilename(),
+				MimeType: audio.GetMimeType(),
+				Data:     audio.GetData(),
+			})
+			cancel()
+			if errors.Is(err, transcribe.ErrEmptyTranscript) {
+				sendInvalidArgument(sender, "no speech was recognized in the voice note")
+				return
+			}
+			if err != nil {
+				slog.Warn("voice note transcription failed", "agent_id", dbAgent.ID, "transcriber", svc.Transcriber.Name(), "error", err)
+				sendInternalError(sender, "transcription failed")
+				return
+			}
+
+			svc.submitUserMessage(sender, dbAgent, userInput{
+				content:        transcript,
+				idempotencyKey: r.GetIdempotencyKey(),
+				voiceNote: &voiceNoteMeta{
+					Filename:    audio.GetFilename(),
+					MimeType:    audio.GetMimeType(),
+					Size:        len(audio.GetData()),
+					Transcriber: svc.Transcriber.Name(),
+				},
+			}, func(messageID string, duplicate bool) {
+				sendProtoResponse(sender, &leapmuxv1.SendVoiceNoteResponse{
+					DuplicateSuppressed: duplicate,
+					MessageId:           messageID,
+					Transcript:          transcript,
+				})
+			})
+		})
+}
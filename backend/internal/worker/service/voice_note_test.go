@@ -0,0 +1,118 @@
+package service
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	leapmuxv1 "github.com/leapmux/leapmux/generated/proto/leapmux/v1"
+	"github.com/leapmux/leapmux/internal/util/msgcodec"
+	db "github.com/leapmux/leapmux/internal/worker/generated/db"
+	"github.com/leapmux/leapmux/internal/worker/transcribe"
+)
+
+type fakeTranscriber struct {
+	text  string
+	err   error
+	clips []transcribe.Clip
+}
+
+func (f *fakeTranscriber) Name() string { return "fake" }
+
+func (f *fakeTranscriber) Transcribe(_ context.Context, clip transcribe.Clip) (string, error) {
+	f.clips = append(f.clips, clip)
+	return f.text, f.err
+}
+
+func voiceNoteRequest(data string) *leapmuxv1.SendVoiceNoteRequest {
+	return &leapmuxv1.SendVoiceNoteRequest{
+		AgentId: "agent-1",
+		Audio:   &leapmuxv1.Attachment{Filename: "note.wav", MimeType: "audio/wav", Data: []byte(data)},
+	}
+}
+
+func TestSendVoiceNote_SubmitsTranscriptWithProvenance(t *testing.T) {
+	svc, d, w := setupTestService(t, withWorkspaces("ws-1"))
+	seedGuardedAgent(t, svc, "")
+	fake := &fakeTranscriber{text: "run the tests"}
+	svc.Transcriber = fake
+
+	dispatch(d, "SendVoiceNote", voiceNoteRequest("RIFF...."), w)
+	require.Empty(t, w.errors)
+	resp := decodeResponse[leapmuxv1.SendVoiceNoteResponse](t, w)
+	assert.Equal(t, "run the tests", resp.GetTranscript())
+	require.NotEmpty(t, resp.GetMessageId())
+	require.Len(t, fake.clips, 1)
+	assert.Equal(t, "audio/wav", fake.clips[0].MimeType)
+
+	msgs, err := svc.Queries.ListAllMessagesByAgentID(context.Background(), db.ListAllMessagesByAgentIDParams{AgentID: "agent-1"})
+	require.NoError(t, err)
+	require.Len(t, msgs, 1)
+	assert.Equal(t, resp.GetMessageId(), msgs[0].ID)
+	raw, err := msgcodec.Decompress(msgs[0].Content, msgs[0].ContentCompression)
+	require.NoError(t, err)
+
+	var stored struct {
+		Content     string          `json:"content"`
+		Attachments json.RawMessage `json:"attachments"`
+		VoiceNote   voiceNoteMeta   `json:"voice_note"`
+	}
+	require.NoError(t, json.Unmarshal(raw, &stored))
+	assert.Equal(t, "run the tests", stored.Content)
+	assert.Nil(t, stored.Attachments, "the audio is provenance, not an attachment for the agent")
+	assert.Equal(t, voiceNoteMeta{Filename: "note.wav", MimeType: "audio/wav", Size: 8, Transcriber: "fake"}, stored.VoiceNote)
+}
+
+func TestSendVoiceNote_IdempotencyKeySuppressesRetry(t *testing.T) {
+	svc, d, w := setupTestService(t, withWorkspaces("ws-1"))
+	seedGuardedAgent(t, svc, "")
+	svc.Transcriber = &fakeTranscriber{text: "hello"}
+
+	req := voiceNoteRequest("RIFF")
+	req.IdempotencyKey = "voice-1"
+	dispatch(d, "SendVoiceNote", req, w)
+	require.Empty(t, w.errors)
+	first := decodeResponse[leapmuxv1.SendVoiceNoteResponse](t, w)
+
+	dispatch(d, "SendVoiceNote", req, w)
+	require.Empty(t, w.errors)
+	retry := decodeResponse[leapmuxv1.SendVoiceNoteResponse](t, w)
+	assert.True(t, retry.GetDuplicateSuppressed())
+	assert.Equal(t, first.GetMessageId(), retry.GetMessageId())
+	assert.Equal(t, 1, countAgentMessages(t, svc, "agent-1"))
+}
+
+func TestSendVoiceNote_Rejections(t *testing.T) {
+	tests := []struct {
+		name        string
+		transcriber transcribe.Transcriber
+		req         *leapmuxv1.SendVoiceNoteRequest
+		code        int32
+	}{
+		{"not configured", nil, voiceNoteRequest("RIFF"), codeFailedPrecondition},
+		{"no audio", &fakeTranscriber{text: "x"}, &leapmuxv1.SendVoiceNoteRequest{AgentId: "agent-1"}, codeInvalidArgument},
+		{"not audio", &fakeTranscriber{text: "x"}, &leapmuxv1.SendVoiceNoteRequest{
+			AgentId: "agent-1",
+			Audio:   &leapmuxv1.Attachment{Filename: "a.png", MimeType: "image/png", Data: []byte{1}},
+		}, codeInvalidArgument},
+		{"too large", &fakeTranscriber{text: "x"}, voiceNoteRequest(string(make([]byte, maxAttachmentSize+1))), codeInvalidArgument},
+		{"silence", &fakeTranscriber{err: transcribe.ErrEmptyTranscript}, voiceNoteRequest("RIFF"), codeInvalidArgument},
+		{"backend failure", &fakeTranscriber{err: errors.New("boom")}, voiceNoteRequest("RIFF"), codeInternal},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			svc, d, w := setupTestService(t, withWorkspaces("ws-1"))
+			seedGuardedAgent(t, svc, "")
+			svc.Transcriber = tt.transcriber
+
+			dispatch(d, "SendVoiceNote", tt.req, w)
+			require.Len(t, w.errors, 1)
+			assert.Equal(t, tt.code, w.errors[0].code)
+			assert.Zero(t, countAgentMessages(t, svc, "agent-1"))
+		})
+	}
+}
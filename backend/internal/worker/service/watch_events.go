@@ -33,6 +33,12 @@ import (
 type replaySink struct {
 	sender channel.ResponseWriter
 	dead   error
+	// withhold, when non-nil, drops the events it reports true for; a
+	// read-only channel's replay sets it to readOnlyWithholds.
+	withhold func(*leapmuxv1.WatchEventsResponse) bool
+	// compress sends bulk payloads compressed, as the live broadcasts to
+	// the same channel do; see compressWatchEvent.
+	compress bool
 }
 
 func newReplaySink(sender channel.ResponseWriter) *replaySink {
@@ -41,9 +47,14 @@ func newReplaySink(sender channel.ResponseWriter) *replaySink {
 
 // send emits one event, or does nothing once the transport is known dead.
 func (s *replaySink) send(resp *leapmuxv1.WatchEventsResponse) {
-	if s.dead != nil {
+	if s.dead != nil || (s.withhold != nil && s.withhold(resp)) {
 		return
 	}
+	if s.compress {
+		if compressed := compressWatchEvent(resp); compressed != nil {
+			resp = compressed
+		}
+	}
 	err := broadcastWatchEvent(s.sender, resp)
 	if transportDead(err) {
 		s.dead = err
@@ -127,6 +138,15 @@ func handleWatchEvents(svc *Service) channel.HandlerFunc {
 		channelID := sender.ChannelID()
 		allowedWorkspaces := svc.AuthorizerFor(channelID).AccessibleSet()
 
+		// The delivery mode follows the latest request on every path,
+		// including the rebind-only error paths below: it describes the
+		// client's link, which the request states whether or not its
+		// entity lookup succeeds.
+		svc.Watchers.SetLowBandwidth(channelID, r.GetLowBandwidth())
+		svc.Watchers.SetAcceptCompression(channelID, r.GetAcceptCompression())
+		readOnly := svc.channelReadOnly(channelID)
+		svc.Watchers.SetReadOnly(channelID, readOnly)
+
 		// Filter agents by access control and register watchers FIRST
 		// so no broadcasts are missed during the replay phase. Retain
 		// the fetched rows so the replay loop below doesn't have to
@@ -221,8 +241,9 @@ func handleWatchEvents(svc *Service) channel.HandlerFunc {
 		var verifiedTerminalRows []db.Terminal
 		var rejectedTerminalIDs []string
 		for _, termID := range requestedTerminalIDs {
+			// A read-only channel sees no terminal; see readOnlyWithholds.
 			termRow, ok := termRowsByID[termID]
-			if !ok || !allowedWorkspaces[termRow.WorkspaceID] {
+			if !ok || !allowedWorkspaces[termRow.WorkspaceID] || readOnly {
 				rejectedTerminalIDs = append(rejectedTerminalIDs, termID)
 				continue
 			}
@@ -326,6 +347,10 @@ func handleWatchEvents(svc *Service) channel.HandlerFunc {
 		// first alive() check meant a client that had already dropped
 		// still paid for every one of them.
 		sink := newReplaySink(sender)
+		if readOnly {
+			sink.withhold = readOnlyWithholds
+		}
+		sink.compress = svc.Watchers.compressesFor(channelID)
 
 		// Compute git statuses in a single deduplicated batch so the
 		// per-agent replay loop below doesn't serialize N git shell-outs
@@ -366,6 +391,14 @@ func handleWatchEvents(svc *Service) channel.HandlerFunc {
 			svc.replayTerminalCatchUp(sink, termID, afterOffsetByID[termID], verifiedTerminalRows[i])
 		}
 
+		// Then the announcements running now; each new stream gets them
+		// again, and the frontend shows one only once.
+		for _, a := range svc.runningAnnouncements() {
+			sink.send(&leapmuxv1.WatchEventsResponse{
+				Event: &leapmuxv1.WatchEventsResponse_Announcement{Announcement: a},
+			})
+		}
+
 		// Stream stays open — events are pushed through the sender this
 		// call registered in the WatcherManager. The handler returns
 		// immediately; the registration is retired when the channel closes
@@ -0,0 +1,39 @@
+package service
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	leapmuxv1 "github.com/leapmux/leapmux/generated/proto/leapmux/v1"
+)
+
+func TestWatchEvents_LowBandwidthFollowsTheLatestRequest(t *testing.T) {
+	svc, d, w := setupTestService(t, withWorkspaces("ws-1"))
+	seedAgent(t, svc, "agent-1", "ws-1")
+	watch := func(lowBandwidth bool) {
+		dispatch(d, "WatchEvents", &leapmuxv1.WatchEventsRequest{
+			Agents: []*leapmuxv1.WatchAgentEntry{
+				{AgentId: "agent-1", Replay: leapmuxv1.WatchReplayMode_WATCH_REPLAY_MODE_LATEST},
+			},
+			LowBandwidth: lowBandwidth,
+		}, w)
+	}
+	chunk := &leapmuxv1.AgentEvent{
+		AgentId: "agent-1",
+		Event:   &leapmuxv1.AgentEvent_StreamChunk{StreamChunk: &leapmuxv1.AgentStreamChunk{Delta: []byte("x")}},
+	}
+
+	watch(true)
+	require.True(t, svc.Watchers.isLowBandwidth(w.ChannelID()))
+	before := len(w.streams)
+	svc.Watchers.BroadcastAgentEvent("agent-1", chunk)
+	assert.Len(t, w.streams, before, "a low-bandwidth channel must not receive stream chunks")
+
+	watch(false)
+	assert.False(t, svc.Watchers.isLowBandwidth(w.ChannelID()))
+	before = len(w.streams)
+	svc.Watchers.BroadcastAgentEvent("agent-1", chunk)
+	assert.Len(t, w.streams, before+1, "a later full-bandwidth request restores stream chunks")
+}
@@ -3,12 +3,18 @@
 package service
 
 import (
+	"context"
 	"errors"
 	"log/slog"
 	"sync"
+	"sync/atomic"
+	"time"
 
 	leapmuxv1 "github.com/leapmux/leapmux/generated/proto/leapmux/v1"
+	"github.com/leapmux/leapmux/internal/util/periodic"
 	"github.com/leapmux/leapmux/internal/worker/channel"
+	"google.golang.org/protobuf/encoding/protowire"
+	"google.golang.org/protobuf/proto"
 )
 
 // registration is one channel's live subscription to one entity.
@@ -33,8 +39,72 @@ type registration struct {
 	// keeps a still-in-flight broadcast's stale snapshot from matching --
 	// and therefore retiring -- the new registration.
 	gen uint64
+
+	// seq numbers the live events sent through this registration. It is
+	// the one piece of a registration that is shared rather than copied:
+	// every snapshot of the registration points at the same counter, so
+	// concurrent broadcasts to one subscriber draw from one sequence.
+	// Each watch call mints a fresh counter alongside the fresh
+	// generation, because each one comes from a new stream and the client
+	// starts counting again on it.
+	seq *eventSeq
+}
+
+// eventSeq is one subscription's live-event counter; see
+// WatchEventsResponse.event_seq.
+//
+// The number is drawn and the frame sent under one lock. Drawing it
+// first and sending after would let two concurrent broadcasts to the
+// same subscriber reach the wire as 6 before 5, which the client cannot
+// tell apart from a lost event.
+type eventSeq struct {
+	mu   sync.Mutex
+	last uint64
+}
+
+// send numbers payload and hands it to sender. The number is consumed
+// whether or not the send succeeds: an event the subscriber never got is
+// exactly the gap the client is watching for.
+func (s *eventSeq) send(sender channel.ResponseWriter, payload []byte) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.last++
+	return sender.SendStream(&leapmuxv1.InnerStreamMessage{
+		Payload: appendEventSeq(payload, s.last),
+	})
+}
+
+// skip consumes a number for an event the subscriber was meant to get
+// but that was never sent at all.
+func (s *eventSeq) skip() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.last++
+}
+
+// current returns the number of the last event sent. Reading it under
+// the send lock means a heartbeat never reports a number whose event is
+// still on its way to the transport.
+func (s *eventSeq) current() uint64 {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.last
+}
+
+// appendEventSeq adds event_seq to an already-marshalled
+// WatchEventsResponse. A protobuf message may be extended by appending
+// fields to its encoding, so the fan-out can keep marshalling each event
+// once and stamp every subscriber's number onto its own copy. The
+// three-index slice forces that copy; payload itself is shared.
+func appendEventSeq(payload []byte, seq uint64) []byte {
+	out := payload[:len(payload):len(payload)]
+	out = protowire.AppendTag(out, watchEventSeqField, protowire.VarintType)
+	return protowire.AppendVarint(out, seq)
 }
 
+// watchEventSeqField is WatchEventsResponse.event_seq's field number.
+const watchEventSeqField = 3
+
 // watcherRegistry is one entity kind's subscription table:
 // entity ID -> channel ID -> registration.
 //
@@ -105,7 +175,7 @@ func (r *watcherRegistry) setWatches(channelID string, entityIDs []string, sende
 			r.byEntity[entityID] = byChannel
 		}
 		r.nextGen++
-		byChannel[channelID] = registration{channelID: channelID, sender: sender, gen: r.nextGen}
+		byChannel[channelID] = registration{channelID: channelID, sender: sender, gen: r.nextGen, seq: new(eventSeq)}
 	}
 }
 
@@ -128,7 +198,7 @@ func (r *watcherRegistry) rebindWatches(channelID string, sender channel.Respons
 			continue
 		}
 		r.nextGen++
-		byChannel[channelID] = registration{channelID: channelID, sender: sender, gen: r.nextGen}
+		byChannel[channelID] = registration{channelID: channelID, sender: sender, gen: r.nextGen, seq: new(eventSeq)}
 	}
 }
 
@@ -247,9 +317,23 @@ func transportDead(err error) bool {
 	}
 }
 
-// broadcast fans resp out to every channel subscribed to entityID.
-func (r *watcherRegistry) broadcast(entityID string, resp *leapmuxv1.WatchEventsResponse) {
+// broadcast fans resp out to every channel subscribed to entityID. skip,
+// when non-nil, withholds the event from the channels it reports true
+// for; their registrations are untouched.
+func (r *watcherRegistry) broadcast(entityID string, resp *leapmuxv1.WatchEventsResponse, skip func(channelID string) bool) {
 	watchers := r.snapshot(entityID)
+	if skip != nil {
+		kept := watchers[:0]
+		for _, w := range watchers {
+			if !skip(w.channelID) {
+				kept = append(kept, w)
+			}
+		}
+		watchers = kept
+	}
+	// Checked after the filter so an event every watcher skips is never
+	// marshalled. A skipped event consumes no number on the skipped
+	// channel: withholding it is the delivery mode working, not a loss.
 	if len(watchers) == 0 {
 		return
 	}
@@ -259,7 +343,11 @@ func (r *watcherRegistry) broadcast(entityID string, resp *leapmuxv1.WatchEvents
 		// Nothing to retire: the failure is this worker's own encoding
 		// defect, not a statement about any subscriber. Dropping the event
 		// and keeping every registration is what transportDead decides for
-		// the replay path too -- see errEventNotMarshalable.
+		// the replay path too -- see errEventNotMarshalable. The event is
+		// still numbered, so every subscriber can see it went missing.
+		for _, w := range watchers {
+			w.seq.skip()
+		}
 		return
 	}
 
@@ -276,9 +364,7 @@ func (r *watcherRegistry) broadcast(entityID string, resp *leapmuxv1.WatchEvents
 	// channel.ErrMessageRejected.
 	var dead []registration
 	for _, w := range watchers {
-		err := w.sender.SendStream(&leapmuxv1.InnerStreamMessage{
-			Payload: payload,
-		})
+		err := w.seq.send(w.sender, payload)
 		if err == nil {
 			continue
 		}
@@ -313,6 +399,66 @@ func (r *watcherRegistry) broadcast(entityID string, resp *leapmuxv1.WatchEvents
 type WatcherManager struct {
 	agents    *watcherRegistry
 	terminals *watcherRegistry
+
+	// lowBandwidth is the set of channels whose latest WatchEvents asked
+	// for low-bandwidth delivery. It is per channel rather than per
+	// registration because the flag describes the client's link, not its
+	// interest in any one entity.
+	lowBandwidth channelSet
+	// readOnly is the set of read-only channels that are watching; see
+	// readOnlyWithholds for what they miss.
+	readOnly channelSet
+	// acceptCompression is the set of channels whose latest WatchEvents can
+	// decode compressed payloads; compressOutput is whether the org wants
+	// them compressed. Both must hold for a channel to get compressed events.
+	acceptCompression channelSet
+	compressOutput    atomic.Bool
+}
+
+// channelSet is a concurrency-safe set of channel ids.
+type channelSet struct {
+	mu  sync.RWMutex
+	ids map[string]struct{}
+}
+
+func (c *channelSet) set(channelID string, on bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if !on {
+		delete(c.ids, channelID)
+		return
+	}
+	if c.ids == nil {
+		c.ids = make(map[string]struct{})
+	}
+	c.ids[channelID] = struct{}{}
+}
+
+// any lets the broadcast path skip classifying events while the set is
+// empty.
+func (c *channelSet) any() bool {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	return len(c.ids) > 0
+}
+
+func (c *channelSet) has(channelID string) bool {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	_, ok := c.ids[channelID]
+	return ok
+}
+
+// snapshot copies the set, for a caller that must answer membership the same
+// way across several passes.
+func (c *channelSet) snapshot() map[string]struct{} {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	out := make(map[string]struct{}, len(c.ids))
+	for id := range c.ids {
+		out[id] = struct{}{}
+	}
+	return out
 }
 
 // NewWatcherManager creates a new WatcherManager.
@@ -323,6 +469,108 @@ func NewWatcherManager() *WatcherManager {
 	}
 }
 
+// SetLowBandwidth records whether channelID wants low-bandwidth delivery.
+// See WatchEventsRequest.low_bandwidth for what it drops.
+func (m *WatcherManager) SetLowBandwidth(channelID string, on bool) {
+	m.lowBandwidth.set(channelID, on)
+}
+
+// anyLowBandwidth lets the broadcast path skip classifying events while no
+// channel has asked for low-bandwidth delivery.
+func (m *WatcherManager) anyLowBandwidth() bool {
+	return m.lowBandwidth.any()
+}
+
+func (m *WatcherManager) isLowBandwidth(channelID string) bool {
+	return m.lowBandwidth.has(channelID)
+}
+
+// SetReadOnly records whether channelID is a read-only channel, so live
+// broadcasts withhold from it what its catch-up replay did.
+func (m *WatcherManager) SetReadOnly(channelID string, on bool) {
+	m.readOnly.set(channelID, on)
+}
+
+// SetAcceptCompression records whether channelID can decode compressed
+// payloads. See WatchEventsRequest.accept_compression.
+func (m *WatcherManager) SetAcceptCompression(channelID string, on bool) {
+	m.acceptCompression.set(channelID, on)
+}
+
+// SetCompressOutput turns payload compression on or off for every accepting
+// channel, following the org's WorkerStreamSettings.compress_output.
+func (m *WatcherManager) SetCompressOutput(on bool) {
+	m.compressOutput.Store(on)
+}
+
+// compressesFor reports whether events sent to channelID are compressed.
+func (m *WatcherManager) compressesFor(channelID string) bool {
+	return m.compressOutput.Load() && m.acceptCompression.has(channelID)
+}
+
+// broadcast fans resp out through r, sending the compressed variant to the
+// channels that get one and resp itself to the rest.
+//
+// The accepting set is snapshotted once so the two passes partition the
+// subscribers exactly: a channel re-subscribing between them still gets the
+// event once, in one form or the other.
+func (m *WatcherManager) broadcast(r *watcherRegistry, entityID string, resp *leapmuxv1.WatchEventsResponse, skip func(string) bool) {
+	if !m.compressOutput.Load() || !m.acceptCompression.any() {
+		r.broadcast(entityID, resp, skip)
+		return
+	}
+	compressed := compressWatchEvent(resp)
+	if compressed == nil {
+		r.broadcast(entityID, resp, skip)
+		return
+	}
+	accepting := m.acceptCompression.snapshot()
+	skipped := func(channelID string) bool { return skip != nil && skip(channelID) }
+	r.broadcast(entityID, compressed, func(channelID string) bool {
+		_, ok := accepting[channelID]
+		return !ok || skipped(channelID)
+	})
+	r.broadcast(entityID, resp, func(channelID string) bool {
+		_, ok := accepting[channelID]
+		return ok || skipped(channelID)
+	})
+}
+
+// lowBandwidthDrops reports whether a low-bandwidth channel should miss
+// event: the high-frequency kinds a client can rebuild from the persisted
+// messages and status changes it still receives.
+func lowBandwidthDrops(event *leapmuxv1.AgentEvent) bool {
+	switch e := event.GetEvent().(type) {
+	case *leapmuxv1.AgentEvent_StreamChunk, *leapmuxv1.AgentEvent_StreamEnd:
+		return true
+	case *leapmuxv1.AgentEvent_AgentMessage:
+		// Ephemeral session info (thinking-token counts, cost ticks)
+		// carries the seq -1 sentinel and is never persisted.
+		return e.AgentMessage.GetSeq() < 0
+	case *leapmuxv1.AgentEvent_StatusChange:
+		return isGitStatusRefresh(e.StatusChange)
+	case *leapmuxv1.AgentEvent_FileTransfer:
+		// Per-chunk progress; the final state still goes out.
+		return e.FileTransfer.GetState() == leapmuxv1.FileTransferState_FILE_TRANSFER_STATE_IN_PROGRESS
+	default:
+		return false
+	}
+}
+
+// isGitStatusRefresh reports whether sc is the partial update
+// BroadcastGitStatus emits: no status transition, and nothing but the
+// agent id, worker liveness and git status populated.
+func isGitStatusRefresh(sc *leapmuxv1.AgentStatusChange) bool {
+	if sc.GetStatus() != leapmuxv1.AgentStatus_AGENT_STATUS_UNSPECIFIED || sc.GetGitStatus() == nil {
+		return false
+	}
+	rest := proto.CloneOf(sc)
+	rest.AgentId = ""
+	rest.WorkerOnline = false
+	rest.GitStatus = nil
+	return proto.Size(rest) == 0
+}
+
 // SetAgentWatches makes channelID's agent subscriptions exactly
 // agentIDs, routing their events through sender. Agents the channel
 // previously watched that are absent from agentIDs are unsubscribed.
@@ -366,22 +614,145 @@ func (m *WatcherManager) RebindWatches(channelID string, sender channel.Response
 func (m *WatcherManager) UnwatchAll(channelID string) {
 	m.agents.unwatchAll(channelID)
 	m.terminals.unwatchAll(channelID)
+	m.SetLowBandwidth(channelID, false)
+	m.SetReadOnly(channelID, false)
+	m.SetAcceptCompression(channelID, false)
 }
 
 // BroadcastAgentEvent sends an AgentEvent to all watchers of the given agent.
 func (m *WatcherManager) BroadcastAgentEvent(agentID string, event *leapmuxv1.AgentEvent) {
-	m.agents.broadcast(agentID, &leapmuxv1.WatchEventsResponse{
+	resp := &leapmuxv1.WatchEventsResponse{
 		Event: &leapmuxv1.WatchEventsResponse_AgentEvent{
 			AgentEvent: event,
 		},
-	})
+	}
+	lowBandwidth := m.anyLowBandwidth() && lowBandwidthDrops(event)
+	readOnly := m.readOnly.any() && readOnlyWithholds(resp)
+	var skip func(string) bool
+	switch {
+	case lowBandwidth && readOnly:
+		skip = func(channelID string) bool { return m.isLowBandwidth(channelID) || m.readOnly.has(channelID) }
+	case lowBandwidth:
+		skip = m.isLowBandwidth
+	case readOnly:
+		skip = m.readOnly.has
+	}
+	m.broadcast(m.agents, agentID, resp, skip)
 }
 
 // BroadcastTerminalEvent sends a TerminalEvent to all watchers of the given terminal.
 func (m *WatcherManager) BroadcastTerminalEvent(terminalID string, event *leapmuxv1.TerminalEvent) {
-	m.terminals.broadcast(terminalID, &leapmuxv1.WatchEventsResponse{
+	m.broadcast(m.terminals, terminalID, &leapmuxv1.WatchEventsResponse{
 		Event: &leapmuxv1.WatchEventsResponse_TerminalEvent{
 			TerminalEvent: event,
 		},
+	}, nil)
+}
+
+// watchHeartbeatInterval is how often every watching stream is told the
+// last event_seq sent to it. It bounds how long a lost event that no
+// later event follows -- the last message of a turn, a final status --
+// can go unnoticed.
+const watchHeartbeatInterval = 15 * time.Second
+
+// entitySeq is one subscription's event_seq as a heartbeat reports it.
+type entitySeq struct {
+	entityID string
+	sender   channel.ResponseWriter
+	seq      uint64
+}
+
+// lastSeqs returns, per channel, every subscription's last event_seq.
+// The counters are read after the registry lock is released: current
+// waits out an in-flight send, which must not hold up re-subscribes.
+func (r *watcherRegistry) lastSeqs() map[string][]entitySeq {
+	r.mu.RLock()
+	type sub struct {
+		entityID string
+		reg      registration
+	}
+	var subs []sub
+	for entityID, byChannel := range r.byEntity {
+		for _, reg := range byChannel {
+			subs = append(subs, sub{entityID: entityID, reg: reg})
+		}
+	}
+	r.mu.RUnlock()
+
+	out := make(map[string][]entitySeq)
+	for _, s := range subs {
+		out[s.reg.channelID] = append(out[s.reg.channelID], entitySeq{
+			entityID: s.entityID,
+			sender:   s.reg.sender,
+			seq:      s.reg.seq.current(),
+		})
+	}
+	return out
+}
+
+// SendHeartbeats sends one WatchHeartbeat to every channel that watches
+// anything, naming each of its subscriptions' last event_seq.
+//
+// A heartbeat goes out through whichever of the channel's registrations
+// it finds first. That is the stream all of them are bound to: one
+// channel carries at most one live WatchEvents stream, and every watch
+// call binds both registries to it (see setWatches). A send failure is
+// not acted on here; the next broadcast meets the same dead transport
+// and retires the subscription through the usual sweep.
+func (m *WatcherManager) SendHeartbeats() {
+	agents := m.agents.lastSeqs()
+	terminals := m.terminals.lastSeqs()
+	channelIDs := make(map[string]struct{}, len(agents)+len(terminals))
+	for channelID := range agents {
+		channelIDs[channelID] = struct{}{}
+	}
+	for channelID := range terminals {
+		channelIDs[channelID] = struct{}{}
+	}
+
+	for channelID := range channelIDs {
+		heartbeat := &leapmuxv1.WatchHeartbeat{}
+		var sender channel.ResponseWriter
+		for _, s := range agents[channelID] {
+			sender = s.sender
+			heartbeat.Agents = append(heartbeat.Agents, &leapmuxv1.WatchedEventSeq{Id: s.entityID, EventSeq: s.seq})
+		}
+		for _, s := range terminals[channelID] {
+			if sender == nil {
+				sender = s.sender
+			}
+			heartbeat.Terminals = append(heartbeat.Terminals, &leapmuxv1.WatchedEventSeq{Id: s.entityID, EventSeq: s.seq})
+		}
+		_ = broadcastWatchEvent(sender, &leapmuxv1.WatchEventsResponse{
+			Event: &leapmuxv1.WatchEventsResponse_Heartbeat{Heartbeat: heartbeat},
+		})
+	}
+}
+
+// BroadcastAnnouncement sends a to every channel that watches anything,
+// through the stream its registrations are bound to, as SendHeartbeats
+// does. An announcement carries no event_seq: it belongs to no entity.
+func (m *WatcherManager) BroadcastAnnouncement(a *leapmuxv1.Announcement) {
+	senders := make(map[string]channel.ResponseWriter)
+	for _, r := range []*watcherRegistry{m.agents, m.terminals} {
+		for channelID, subs := range r.lastSeqs() {
+			if _, ok := senders[channelID]; !ok && len(subs) > 0 {
+				senders[channelID] = subs[0].sender
+			}
+		}
+	}
+	resp := &leapmuxv1.WatchEventsResponse{
+		Event: &leapmuxv1.WatchEventsResponse_Announcement{Announcement: a},
+	}
+	for _, sender := range senders {
+		_ = broadcastWatchEvent(sender, resp)
+	}
+}
+
+// StartHeartbeatLoop sends heartbeats every watchHeartbeatInterval until
+// ctx is done.
+func (m *WatcherManager) StartHeartbeatLoop(ctx context.Context) {
+	periodic.Start(ctx, periodic.Schedule{Interval: watchHeartbeatInterval, SkipFirstRun: true}, func(context.Context) {
+		m.SendHeartbeats()
 	})
 }
@@ -3,6 +3,7 @@ package service
 import (
 	"errors"
 	"fmt"
+	"sync"
 	"sync/atomic"
 	"testing"
 
@@ -11,6 +12,7 @@ import (
 
 	leapmuxv1 "github.com/leapmux/leapmux/generated/proto/leapmux/v1"
 	"github.com/leapmux/leapmux/internal/worker/channel"
+	"google.golang.org/protobuf/proto"
 )
 
 // mockResponseWriter counts SendStream calls for testing broadcast deduplication.
@@ -993,3 +995,235 @@ func TestRetire_DropsTheEntityOnceItsLastWatcherGoes(t *testing.T) {
 	assert.False(t, r.hasEntity("e-1"),
 		"the entity entry goes with its last registration")
 }
+
+func TestLowBandwidthDrops(t *testing.T) {
+	agentEvent := func(event any) *leapmuxv1.AgentEvent {
+		e := &leapmuxv1.AgentEvent{AgentId: "agent-1"}
+		switch ev := event.(type) {
+		case *leapmuxv1.AgentStreamChunk:
+			e.Event = &leapmuxv1.AgentEvent_StreamChunk{StreamChunk: ev}
+		case *leapmuxv1.AgentStreamEnd:
+			e.Event = &leapmuxv1.AgentEvent_StreamEnd{StreamEnd: ev}
+		case *leapmuxv1.AgentChatMessage:
+			e.Event = &leapmuxv1.AgentEvent_AgentMessage{AgentMessage: ev}
+		case *leapmuxv1.AgentStatusChange:
+			e.Event = &leapmuxv1.AgentEvent_StatusChange{StatusChange: ev}
+		case *leapmuxv1.AgentControlRequest:
+			e.Event = &leapmuxv1.AgentEvent_ControlRequest{ControlRequest: ev}
+		}
+		return e
+	}
+	gitStatus := &leapmuxv1.AgentGitStatus{Branch: "main"}
+
+	tests := []struct {
+		name  string
+		event *leapmuxv1.AgentEvent
+		drop  bool
+	}{
+		{"stream chunk", agentEvent(&leapmuxv1.AgentStreamChunk{Delta: []byte("x")}), true},
+		{"stream end", agentEvent(&leapmuxv1.AgentStreamEnd{}), true},
+		{"ephemeral session info", agentEvent(&leapmuxv1.AgentChatMessage{Seq: -1}), true},
+		{"persisted message", agentEvent(&leapmuxv1.AgentChatMessage{Seq: 7}), false},
+		{"git status refresh", agentEvent(&leapmuxv1.AgentStatusChange{
+			AgentId: "agent-1", WorkerOnline: true, GitStatus: gitStatus,
+		}), true},
+		{"status transition with git status", agentEvent(&leapmuxv1.AgentStatusChange{
+			AgentId: "agent-1", Status: leapmuxv1.AgentStatus_AGENT_STATUS_ACTIVE, GitStatus: gitStatus,
+		}), false},
+		{"settings refresh with git status", agentEvent(&leapmuxv1.AgentStatusChange{
+			AgentId: "agent-1", WorkerOnline: true, GitStatus: gitStatus,
+			OptionGroups: []*leapmuxv1.AvailableOptionGroup{{Id: "model"}},
+		}), false},
+		{"partial status without git status", agentEvent(&leapmuxv1.AgentStatusChange{
+			AgentId: "agent-1", WorkerOnline: true,
+		}), false},
+		{"control request", agentEvent(&leapmuxv1.AgentControlRequest{}), false},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			assert.Equal(t, tc.drop, lowBandwidthDrops(tc.event))
+		})
+	}
+}
+
+func TestBroadcastAgentEvent_LowBandwidthChannelMissesStreamChunks(t *testing.T) {
+	m := NewWatcherManager()
+	full := newTestWatcher("ch-full")
+	lean := newTestWatcher("ch-lean")
+	m.SetAgentWatches("ch-full", []string{"agent-1"}, full)
+	m.SetAgentWatches("ch-lean", []string{"agent-1"}, lean)
+	m.SetLowBandwidth("ch-lean", true)
+
+	m.BroadcastAgentEvent("agent-1", &leapmuxv1.AgentEvent{
+		AgentId: "agent-1",
+		Event:   &leapmuxv1.AgentEvent_StreamChunk{StreamChunk: &leapmuxv1.AgentStreamChunk{Delta: []byte("x")}},
+	})
+	assert.Equal(t, int64(1), full.streamCount.Load())
+	assert.Equal(t, int64(0), lean.streamCount.Load())
+
+	m.BroadcastAgentEvent("agent-1", testAgentEvent("agent-1"))
+	assert.Equal(t, int64(2), full.streamCount.Load())
+	assert.Equal(t, int64(1), lean.streamCount.Load(), "a status transition still reaches the low-bandwidth channel")
+	assert.Equal(t, 2, m.agents.count("agent-1"), "skipping an event must not retire the registration")
+}
+
+func TestSetLowBandwidth_OffAndUnwatchAllRestoreFullDelivery(t *testing.T) {
+	chunk := &leapmuxv1.AgentEvent{
+		AgentId: "agent-1",
+		Event:   &leapmuxv1.AgentEvent_StreamChunk{StreamChunk: &leapmuxv1.AgentStreamChunk{Delta: []byte("x")}},
+	}
+
+	m := NewWatcherManager()
+	w := newTestWatcher("ch-1")
+	m.SetAgentWatches("ch-1", []string{"agent-1"}, w)
+	m.SetLowBandwidth("ch-1", true)
+	m.SetLowBandwidth("ch-1", false)
+	m.BroadcastAgentEvent("agent-1", chunk)
+	assert.Equal(t, int64(1), w.streamCount.Load())
+
+	// A channel id reused after UnwatchAll starts in full delivery.
+	m.SetLowBandwidth("ch-1", true)
+	m.UnwatchAll("ch-1")
+	assert.False(t, m.anyLowBandwidth())
+	m.SetAgentWatches("ch-1", []string{"agent-1"}, w)
+	m.BroadcastAgentEvent("agent-1", chunk)
+	assert.Equal(t, int64(2), w.streamCount.Load())
+}
+
+// recordingWriter keeps every frame sent to it, decoded, so a test can
+// read back the event_seq each one carried.
+type recordingWriter struct {
+	mockResponseWriter
+	mu     sync.Mutex
+	frames []*leapmuxv1.WatchEventsResponse
+}
+
+func newRecordingWriter(channelID string) *recordingWriter {
+	return &recordingWriter{mockResponseWriter: mockResponseWriter{channelID: channelID}}
+}
+
+func (w *recordingWriter) SendStream(msg *leapmuxv1.InnerStreamMessage) error {
+	var resp leapmuxv1.WatchEventsResponse
+	if err := proto.Unmarshal(msg.GetPayload(), &resp); err != nil {
+		panic(err)
+	}
+	w.mu.Lock()
+	w.frames = append(w.frames, &resp)
+	w.mu.Unlock()
+	return w.mockResponseWriter.SendStream(msg)
+}
+
+func (w *recordingWriter) eventSeqs() []uint64 {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	var out []uint64
+	for _, f := range w.frames {
+		if f.GetHeartbeat() == nil {
+			out = append(out, f.GetEventSeq())
+		}
+	}
+	return out
+}
+
+func (w *recordingWriter) lastHeartbeat() *leapmuxv1.WatchHeartbeat {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	for i := len(w.frames) - 1; i >= 0; i-- {
+		if hb := w.frames[i].GetHeartbeat(); hb != nil {
+			return hb
+		}
+	}
+	return nil
+}
+
+func TestBroadcast_NumbersEventsPerSubscription(t *testing.T) {
+	m := NewWatcherManager()
+	w := newRecordingWriter("ch-1")
+	m.SetAgentWatches("ch-1", []string{"agent-1", "agent-2"}, w)
+	m.SetTerminalWatches("ch-1", []string{"term-1"}, w)
+
+	m.BroadcastAgentEvent("agent-1", testAgentEvent("agent-1"))
+	m.BroadcastAgentEvent("agent-1", testAgentEvent("agent-1"))
+	m.BroadcastAgentEvent("agent-2", testAgentEvent("agent-2"))
+	m.BroadcastTerminalEvent("term-1", testTerminalEvent("term-1", []byte("x")))
+
+	assert.Equal(t, []uint64{1, 2, 1, 1}, w.eventSeqs(), "each entity counts on its own")
+
+	// A new stream is a new sequence.
+	fresh := newRecordingWriter("ch-1")
+	m.SetAgentWatches("ch-1", []string{"agent-1"}, fresh)
+	m.BroadcastAgentEvent("agent-1", testAgentEvent("agent-1"))
+	assert.Equal(t, []uint64{1}, fresh.eventSeqs())
+}
+
+// TestBroadcast_WithheldEventsLeaveNoGap pins that a low-bandwidth
+// channel is not told it lost the stream chunks it asked not to get.
+func TestBroadcast_WithheldEventsLeaveNoGap(t *testing.T) {
+	m := NewWatcherManager()
+	w := newRecordingWriter("ch-1")
+	m.SetAgentWatches("ch-1", []string{"agent-1"}, w)
+	m.SetLowBandwidth("ch-1", true)
+
+	m.BroadcastAgentEvent("agent-1", testAgentEvent("agent-1"))
+	m.BroadcastAgentEvent("agent-1", &leapmuxv1.AgentEvent{
+		AgentId: "agent-1",
+		Event:   &leapmuxv1.AgentEvent_StreamChunk{StreamChunk: &leapmuxv1.AgentStreamChunk{}},
+	})
+	m.BroadcastAgentEvent("agent-1", testAgentEvent("agent-1"))
+
+	assert.Equal(t, []uint64{1, 2}, w.eventSeqs())
+}
+
+// TestBroadcast_RejectedEventLeavesAGapTheHeartbeatReports is the case
+// the sequence exists for: the channel refuses one event, the watcher is
+// rightly kept, and without a number nothing would ever tell the client
+// it is missing something.
+func TestBroadcast_RejectedEventLeavesAGapTheHeartbeatReports(t *testing.T) {
+	m := NewWatcherManager()
+	w := newRecordingWriter("ch-1")
+	m.SetAgentWatches("ch-1", []string{"agent-1"}, w)
+
+	m.BroadcastAgentEvent("agent-1", testAgentEvent("agent-1"))
+	w.failSends(fmt.Errorf("message too large: %w", channel.ErrMessageRejected))
+	m.BroadcastAgentEvent("agent-1", testAgentEvent("agent-1"))
+	w.failSends(nil)
+
+	m.SendHeartbeats()
+	hb := w.lastHeartbeat()
+	require.NotNil(t, hb)
+	require.Len(t, hb.GetAgents(), 1)
+	assert.Equal(t, "agent-1", hb.GetAgents()[0].GetId())
+	assert.Equal(t, uint64(2), hb.GetAgents()[0].GetEventSeq(),
+		"the heartbeat names the rejected event, one past the last the client saw")
+
+	m.BroadcastAgentEvent("agent-1", testAgentEvent("agent-1"))
+	assert.Equal(t, []uint64{1, 2, 3}, w.eventSeqs(),
+		"the recorder saw the refused frame too; the client sees 1 then 3")
+}
+
+func TestSendHeartbeats_OneFramePerChannel(t *testing.T) {
+	m := NewWatcherManager()
+	w1 := newRecordingWriter("ch-1")
+	w2 := newRecordingWriter("ch-2")
+	m.SetAgentWatches("ch-1", []string{"agent-1", "agent-2"}, w1)
+	m.SetTerminalWatches("ch-1", []string{"term-1"}, w1)
+	m.SetTerminalWatches("ch-2", []string{"term-1"}, w2)
+	m.BroadcastTerminalEvent("term-1", testTerminalEvent("term-1", []byte("x")))
+
+	m.SendHeartbeats()
+
+	assert.Equal(t, int64(2), w1.streamCount.Load(), "one event, one heartbeat")
+	hb := w1.lastHeartbeat()
+	require.NotNil(t, hb)
+	assert.Len(t, hb.GetAgents(), 2)
+	require.Len(t, hb.GetTerminals(), 1)
+	assert.Equal(t, uint64(1), hb.GetTerminals()[0].GetEventSeq())
+	hb = w2.lastHeartbeat()
+	require.NotNil(t, hb)
+	assert.Empty(t, hb.GetAgents())
+	require.Len(t, hb.GetTerminals(), 1)
+
+	m.UnwatchAll("ch-1")
+	m.SendHeartbeats()
+	assert.Equal(t, int64(2), w1.streamCount.Load(), "an unwatched channel gets no heartbeat")
+}
@@ -0,0 +1,184 @@
+package service
+
+import (
+	"context"
+	"log/slog"
+	"maps"
+	"slices"
+	"sync"
+	"time"
+
+	"google.golang.org/protobuf/proto"
+
+	leapmuxv1 "github.com/leapmux/leapmux/generated/proto/leapmux/v1"
+	"github.com/leapmux/leapmux/internal/util/periodic"
+	"github.com/leapmux/leapmux/internal/util/timefmt"
+	"github.com/leapmux/leapmux/internal/util/userid"
+	"github.com/leapmux/leapmux/internal/worker/channel"
+	db "github.com/leapmux/leapmux/internal/worker/generated/db"
+)
+
+// workspaceActivityInterval is how often the worker looks for workspaces
+// whose activity changed since its last report to the Hub. Triggers keep
+// the per-agent message tallies current on every write, so a look reads a
+// row per open agent, never the messages themselves.
+const workspaceActivityInterval = 5 * time.Second
+
+// workspaceActivityState is the activity the worker last reported to the
+// Hub on the current connection. The zero value is ready to use.
+type workspaceActivityState struct {
+	mu sync.Mutex
+	// reported is nil until a full report goes out on the connection.
+	reported map[string]*leapmuxv1.WorkspaceActivity
+}
+
+// StartWorkspaceActivityLoop reports changed workspace activity to the Hub
+// every workspaceActivityInterval.
+func (svc *Service) StartWorkspaceActivityLoop(ctx context.Context) {
+	periodic.Start(ctx, periodic.Schedule{Interval: workspaceActivityInterval}, svc.ReportWorkspaceActivity)
+}
+
+// ResendWorkspaceActivity sends a full report, for a Hub connection that
+// has just been (re)established and so holds nothing from earlier ones.
+func (svc *Service) ResendWorkspaceActivity(ctx context.Context) {
+	svc.workspaceActivity.mu.Lock()
+	svc.workspaceActivity.reported = nil
+	svc.workspaceActivity.mu.Unlock()
+	svc.ReportWorkspaceActivity(ctx)
+}
+
+// ReportWorkspaceActivity sends the Hub the activity of every workspace
+// that changed since the last report, or of every workspace when none has
+// gone out on the connection yet. A failed send is retried whole on the
+// next call.
+func (svc *Service) ReportWorkspaceActivity(ctx context.Context) {
+	current, err := svc.summarizeWorkspaceActivity(ctx)
+	if err != nil {
+		slog.Warn("failed to summarize workspace activity", "error", err)
+		return
+	}
+
+	// Held across the send so two reports cannot reach the Hub out of order.
+	svc.workspaceActivity.mu.Lock()
+	defer svc.workspaceActivity.mu.Unlock()
+	reported := svc.workspaceActivity.reported
+	report := &leapmuxv1.WorkspaceActivityReport{Full: reported == nil}
+	for _, id := range slices.Sorted(maps.Keys(current)) {
+		if prev, ok := reported[id]; !ok || !proto.Equal(prev, current[id]) {
+			report.Workspaces = append(report.Workspaces, current[id])
+		}
+	}
+	for _, id := range slices.Sorted(maps.Keys(reported)) {
+		if _, ok := current[id]; !ok {
+			report.Workspaces = append(report.Workspaces, &leapmuxv1.WorkspaceActivity{WorkspaceId: id})
+		}
+	}
+	if !report.Full && len(report.Workspaces) == 0 {
+		return
+	}
+	if err := svc.Send(&leapmuxv1.ConnectRequest{
+		Payload: &leapmuxv1.ConnectRequest_WorkspaceActivity{WorkspaceActivity: report},
+	}); err != nil {
+		slog.Debug("failed to send workspace activity", "error", err)
+		return
+	}
+	svc.workspaceActivity.reported = current
+}
+
+// summarizeWorkspaceActivity folds the open agents' message tallies and
+// read markers into one summary per workspace that has any.
+func (svc *Service) summarizeWorkspaceActivity(ctx context.Context) (map[string]*leapmuxv1.WorkspaceActivity, error) {
+	tallies, err := svc.Queries.ListOpenAgentTallies(ctx)
+	if err != nil {
+		return nil, err
+	}
+	markers, err := svc.Queries.ListOpenAgentReadMarkers(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	out := make(map[string]*leapmuxv1.WorkspaceActivity)
+	lastAt := make(map[string]time.Time)
+	byAgent := make(map[string]db.ListOpenAgentTalliesRow, len(tallies))
+	for _, t := range tallies {
+		byAgent[t.ID] = t
+		a, ok := out[t.WorkspaceID]
+		if !ok {
+			a = &leapmuxv1.WorkspaceActivity{WorkspaceId: t.WorkspaceID}
+			out[t.WorkspaceID] = a
+		}
+		a.MessageCount += int32(t.MessageCount)
+		if t.PendingRequests > 0 {
+			a.AwaitingApprovalCount++
+		}
+		if t.LastMessageAt.Time.After(lastAt[t.WorkspaceID]) {
+			lastAt[t.WorkspaceID] = t.LastMessageAt.Time
+		}
+	}
+	for ws, at := range lastAt {
+		out[ws].LastMessageAt = timefmt.Format(at)
+	}
+
+	// A user's unread count is the workspace's message count less what
+	// their markers on its agents cover.
+	read := make(map[string]map[string]int64)
+	for _, m := range markers {
+		t, ok := byAgent[m.AgentID]
+		if !ok {
+			continue
+		}
+		if read[t.WorkspaceID] == nil {
+			read[t.WorkspaceID] = make(map[string]int64)
+		}
+		read[t.WorkspaceID][m.UserID] += max(0, min(m.ReadCount, t.MessageCount))
+	}
+	for ws, users := range read {
+		a := out[ws]
+		for _, user := range slices.Sorted(maps.Keys(users)) {
+			a.UserUnreadCounts = append(a.UserUnreadCounts, &leapmuxv1.UserUnreadCount{
+				UserId:      user,
+				UnreadCount: a.GetMessageCount() - int32(users[user]),
+			})
+		}
+	}
+	return out, nil
+}
+
+// markAgentRead moves userID's read marker on agentRow to seq, or to its
+// newest message when seq is 0, and returns the marker.
+func (svc *Service) markAgentRead(ctx context.Context, userID userid.UserID, agentRow db.Agent, seq int64) (int64, error) {
+	if seq == 0 {
+		seq = agentRow.MessageSeqHwm
+	}
+	if err := svc.Queries.UpsertAgentReadMarker(ctx, db.UpsertAgentReadMarkerParams{
+		AgentID:     agentRow.ID,
+		UserID:      userID.String(),
+		LastReadSeq: seq,
+	}); err != nil {
+		return 0, err
+	}
+	return svc.Queries.GetAgentReadMarkerSeq(ctx, db.GetAgentReadMarkerSeqParams{
+		AgentID: agentRow.ID,
+		UserID:  userID.String(),
+	})
+}
+
+func registerWorkspaceActivityHandlers(d registrar, svc *Service) {
+	// Not open to read-only channels: a public viewer or guest acts as the
+	// workspace owner, so the marker it would move is the owner's.
+	registerAgentGated(d, "MarkAgentRead",
+		func(ctx context.Context, userID userid.UserID, r *leapmuxv1.MarkAgentReadRequest, agentRow db.Agent, sender channel.ResponseWriter) {
+			if r.GetSeq() < 0 {
+				sendInvalidArgument(sender, "seq must not be negative")
+				return
+			}
+			seq, err := svc.markAgentRead(ctx, userID, agentRow, r.GetSeq())
+			if err != nil {
+				slog.Error("failed to mark agent read", "agent_id", agentRow.ID, "error", err)
+				sendInternalError(sender, "failed to mark agent read")
+				return
+			}
+			sendProtoResponse(sender, &leapmuxv1.MarkAgentReadResponse{Seq: seq})
+			go svc.ReportWorkspaceActivity(bgCtx())
+		})
+}
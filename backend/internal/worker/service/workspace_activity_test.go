@@ -0,0 +1,157 @@
+package service
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/protobuf/proto"
+
+	leapmuxv1 "github.com/leapmux/leapmux/generated/proto/leapmux/v1"
+	"github.com/leapmux/leapmux/internal/util/sqltime"
+	"github.com/leapmux/leapmux/internal/worker/channel"
+	db "github.com/leapmux/leapmux/internal/worker/generated/db"
+)
+
+// hubActivity folds the workspace activity reports a worker sends the way
+// the Hub keeps them.
+type hubActivity struct {
+	mu      sync.Mutex
+	reports int
+	byID    map[string]*leapmuxv1.WorkspaceActivity
+}
+
+func (h *hubActivity) send(msg *leapmuxv1.ConnectRequest) error {
+	r := msg.GetWorkspaceActivity()
+	if r == nil {
+		return nil
+	}
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.reports++
+	if r.GetFull() {
+		h.byID = map[string]*leapmuxv1.WorkspaceActivity{}
+	}
+	for _, a := range r.GetWorkspaces() {
+		h.byID[a.GetWorkspaceId()] = a
+	}
+	return nil
+}
+
+func (h *hubActivity) get(workspaceID string) *leapmuxv1.WorkspaceActivity {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	return h.byID[workspaceID]
+}
+
+func seedTallied(t *testing.T, svc *Service, agentID, id string, source leapmuxv1.MessageSource) int64 {
+	t.Helper()
+	seq, err := createMessageRow(context.Background(), svc.Queries, db.CreateMessageParams{
+		ID:            id,
+		AgentID:       agentID,
+		Source:        source,
+		Content:       []byte("{}"),
+		AgentProvider: leapmuxv1.AgentProvider_AGENT_PROVIDER_CLAUDE_CODE,
+		CreatedAt:     sqltime.NewSQLiteTime(time.Now()),
+	})
+	require.NoError(t, err)
+	return seq
+}
+
+func markRead(t *testing.T, d *channel.Dispatcher, agentID string, seq int64) int64 {
+	t.Helper()
+	w := newTestWriter()
+	dispatch(d, "MarkAgentRead", &leapmuxv1.MarkAgentReadRequest{AgentId: agentID, Seq: seq}, w)
+	require.Empty(t, w.errors)
+	return decodeResponse[leapmuxv1.MarkAgentReadResponse](t, w).GetSeq()
+}
+
+func TestWorkspaceActivity_CountsUnreadPerUser(t *testing.T) {
+	svc, d, _ := setupTestService(t, withWorkspaces("ws-1", "ws-2"))
+	hub := &hubActivity{}
+	svc.Send = hub.send
+	ctx := context.Background()
+	seedAgent(t, svc, "agent-a", "ws-1")
+	seedAgent(t, svc, "agent-b", "ws-1")
+	seedAgent(t, svc, "agent-c", "ws-2")
+
+	seedTallied(t, svc, "agent-a", "a1", leapmuxv1.MessageSource_MESSAGE_SOURCE_USER)
+	seedTallied(t, svc, "agent-a", "a2", leapmuxv1.MessageSource_MESSAGE_SOURCE_AGENT)
+	seedTallied(t, svc, "agent-a", "a3", leapmuxv1.MessageSource_MESSAGE_SOURCE_AGENT)
+	seedTallied(t, svc, "agent-b", "b1", leapmuxv1.MessageSource_MESSAGE_SOURCE_LEAPMUX)
+	seedTallied(t, svc, "agent-c", "c1", leapmuxv1.MessageSource_MESSAGE_SOURCE_AGENT)
+	require.NoError(t, svc.Queries.CreateControlRequest(ctx, db.CreateControlRequestParams{
+		AgentID: "agent-b", RequestID: "req-1", Payload: []byte("{}"), ClaimToken: "tok",
+	}))
+
+	svc.ReportWorkspaceActivity(ctx)
+	ws1 := hub.get("ws-1")
+	require.NotNil(t, ws1)
+	assert.EqualValues(t, 3, ws1.GetMessageCount(), "a user's own messages are never unread")
+	assert.EqualValues(t, 1, ws1.GetAwaitingApprovalCount())
+	assert.NotEmpty(t, ws1.GetLastMessageAt())
+	assert.Empty(t, ws1.GetUserUnreadCounts())
+	assert.EqualValues(t, 1, hub.get("ws-2").GetMessageCount())
+
+	// Nothing changed, so nothing is sent.
+	svc.ReportWorkspaceActivity(ctx)
+	hub.mu.Lock()
+	assert.Equal(t, 1, hub.reports)
+	hub.mu.Unlock()
+
+	assert.EqualValues(t, 3, markRead(t, d, "agent-a", 0))
+	svc.ReportWorkspaceActivity(ctx)
+	require.Len(t, hub.get("ws-1").GetUserUnreadCounts(), 1)
+	assert.Equal(t, "user-1", hub.get("ws-1").GetUserUnreadCounts()[0].GetUserId())
+	assert.EqualValues(t, 1, hub.get("ws-1").GetUserUnreadCounts()[0].GetUnreadCount(), "agent-b's notification")
+
+	// A marker never moves back.
+	assert.EqualValues(t, 3, markRead(t, d, "agent-a", 1))
+
+	// New and deleted messages move the counts without re-reading them.
+	seedTallied(t, svc, "agent-a", "a4", leapmuxv1.MessageSource_MESSAGE_SOURCE_AGENT)
+	_, err := svc.Queries.DeleteMessageByAgentAndID(ctx, db.DeleteMessageByAgentAndIDParams{ID: "a2", AgentID: "agent-a"})
+	require.NoError(t, err)
+	svc.ReportWorkspaceActivity(ctx)
+	assert.EqualValues(t, 3, hub.get("ws-1").GetMessageCount())
+	assert.EqualValues(t, 2, hub.get("ws-1").GetUserUnreadCounts()[0].GetUnreadCount())
+
+	// A workspace whose last agent closed is reported once more, zeroed.
+	require.NoError(t, svc.Queries.CloseAgent(ctx, "agent-c"))
+	svc.ReportWorkspaceActivity(ctx)
+	assert.True(t, proto.Equal(&leapmuxv1.WorkspaceActivity{WorkspaceId: "ws-2"}, hub.get("ws-2")))
+}
+
+func TestWorkspaceActivity_ResendIsFull(t *testing.T) {
+	svc, _, _ := setupTestService(t, withWorkspaces("ws-1"))
+	var reports []*leapmuxv1.WorkspaceActivityReport
+	svc.Send = func(msg *leapmuxv1.ConnectRequest) error {
+		if r := msg.GetWorkspaceActivity(); r != nil {
+			reports = append(reports, r)
+		}
+		return nil
+	}
+	seedAgent(t, svc, "agent-a", "ws-1")
+	seedTallied(t, svc, "agent-a", "a1", leapmuxv1.MessageSource_MESSAGE_SOURCE_AGENT)
+
+	svc.ReportWorkspaceActivity(context.Background())
+	svc.ResendWorkspaceActivity(context.Background())
+	require.Len(t, reports, 2)
+	for _, r := range reports {
+		assert.True(t, r.GetFull())
+		require.Len(t, r.GetWorkspaces(), 1)
+	}
+}
+
+func TestMarkAgentRead_DeniesInaccessibleAgents(t *testing.T) {
+	svc, d, w := setupTestService(t, withWorkspaces("ws-1"))
+	seedAgent(t, svc, "agent-x", "ws-other")
+
+	dispatch(d, "MarkAgentRead", &leapmuxv1.MarkAgentReadRequest{AgentId: "agent-x"}, w)
+	require.Len(t, w.errors, 1)
+	_, err := svc.Queries.GetAgentReadMarkerSeq(context.Background(), db.GetAgentReadMarkerSeqParams{AgentID: "agent-x", UserID: "user-1"})
+	assert.Error(t, err)
+}
@@ -15,9 +15,10 @@ func registerCleanupHandlers(d registrar, svc *Service) {
 }
 
 // handleCleanupWorkspace cleans up all local resources (agents, terminals,
-// worktrees) for a deleted workspace. This is called via E2EE channel by the
-// frontend after the hub deletes the workspace. Workspace access is enforced
-// by registerWorkspaceGated before this runs.
+// worktrees, retry policy, model routing, plan library, notification
+// consolidation) for a deleted workspace. This is called via E2EE channel
+// by the frontend after the hub deletes the workspace.
+// Workspace access is enforced by registerWorkspaceGated before this runs.
 func handleCleanupWorkspace(svc *Service) func(_ context.Context, _ userid.UserID, r *leapmuxv1.CleanupWorkspaceRequest, sender channel.ResponseWriter) {
 	return func(_ context.Context, _ userid.UserID, r *leapmuxv1.CleanupWorkspaceRequest, sender channel.ResponseWriter) {
 		workspaceID := r.GetWorkspaceId()
@@ -55,7 +56,7 @@ func handleCleanupWorkspace(svc *Service) func(_ context.Context, _ userid.UserI
 				"workspace_id", workspaceID, "error", err)
 		}
 		for _, ts := range terminals {
-			svc.Terminals.RemoveTerminal(ts.ID)
+			svc.removeTerminal(ts.ID)
 			svc.unregisterTab(leapmuxv1.TabType_TAB_TYPE_TERMINAL, ts.ID)
 		}
 
@@ -65,6 +66,65 @@ func handleCleanupWorkspace(svc *Service) func(_ context.Context, _ userid.UserI
 				"workspace_id", workspaceID, "error", err)
 		}
 
+		// 5. Drop the workspace's retry policy; nothing can consult it now.
+		if err := svc.Queries.DeleteWorkspaceRetryPolicy(bgCtx(), workspaceID); err != nil {
+			slog.Error("cleanup workspace: failed to delete retry policy",
+				"workspace_id", workspaceID, "error", err)
+		}
+
+		// 6. Drop the workspace's model routing rules.
+		if err := svc.Queries.DeleteWorkspaceModelRouting(bgCtx(), workspaceID); err != nil {
+			slog.Error("cleanup workspace: failed to delete model routing",
+				"workspace_id", workspaceID, "error", err)
+		}
+
+		// 7. Drop the workspace's plan library.
+		if err := svc.Queries.DeleteWorkspacePlansByWorkspace(bgCtx(), workspaceID); err != nil {
+			slog.Error("cleanup workspace: failed to delete plan library",
+				"workspace_id", workspaceID, "error", err)
+		}
+
+		// 8. Drop the workspace's notification consolidation rules.
+		if err := svc.Queries.DeleteWorkspaceNotificationConsolidation(bgCtx(), workspaceID); err != nil {
+			slog.Error("cleanup workspace: failed to delete notification consolidation",
+				"workspace_id", workspaceID, "error", err)
+		}
+
+		// 9. Drop the workspace's setup script and its record of set-up
+		// directories.
+		if err := svc.Queries.DeleteWorkspaceProvisioning(bgCtx(), workspaceID); err != nil {
+			slog.Error("cleanup workspace: failed to delete provisioning",
+				"workspace_id", workspaceID, "error", err)
+		}
+		if err := svc.Queries.DeleteWorkspaceProvisioningRuns(bgCtx(), workspaceID); err != nil {
+			slog.Error("cleanup workspace: failed to delete provisioning runs",
+				"workspace_id", workspaceID, "error", err)
+		}
+
+		// 10. Drop the workspace's agent defaults.
+		if err := svc.Queries.DeleteWorkspaceAgentDefaults(bgCtx(), workspaceID); err != nil {
+			slog.Error("cleanup workspace: failed to delete agent defaults",
+				"workspace_id", workspaceID, "error", err)
+		}
+
+		// 11. Drop the workspace's environment variables.
+		if err := svc.Queries.DeleteWorkspaceEnv(bgCtx(), workspaceID); err != nil {
+			slog.Error("cleanup workspace: failed to delete env",
+				"workspace_id", workspaceID, "error", err)
+		}
+
+		// 12. Drop the workspace's terminal profiles.
+		if err := svc.Queries.DeleteWorkspaceTerminalProfiles(bgCtx(), workspaceID); err != nil {
+			slog.Error("cleanup workspace: failed to delete terminal profiles",
+				"workspace_id", workspaceID, "error", err)
+		}
+
+		// 13. Drop the workspace's turn limits.
+		if err := svc.Queries.DeleteWorkspaceTurnLimits(bgCtx(), workspaceID); err != nil {
+			slog.Error("cleanup workspace: failed to delete turn limits",
+				"workspace_id", workspaceID, "error", err)
+		}
+
 		sendProtoResponse(sender, &leapmuxv1.CleanupWorkspaceResponse{})
 	}
 }
@@ -0,0 +1,215 @@
+package service
+
+import (
+	"context"
+	"crypto/sha256"
+	"database/sql"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"log/slog"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	leapmuxv1 "github.com/leapmux/leapmux/generated/proto/leapmux/v1"
+	"github.com/leapmux/leapmux/internal/util/userid"
+	"github.com/leapmux/leapmux/internal/worker/channel"
+	db "github.com/leapmux/leapmux/internal/worker/generated/db"
+	"google.golang.org/protobuf/encoding/protojson"
+)
+
+// Bounds on a provisioning script. A setup that needs more than a day, or
+// a script longer than 64 KiB, belongs in a file the script runs.
+const (
+	maxProvisioningScriptLen   = 64 << 10
+	maxProvisioningTimeoutSecs = 24 * 60 * 60
+	defaultProvisioningTimeout = 10 * time.Minute
+)
+
+// validateWorkspaceProvisioning rejects oversized scripts and out-of-range
+// timeouts.
+func validateWorkspaceProvisioning(p *leapmuxv1.WorkspaceProvisioning) error {
+	if len(p.GetScript()) > maxProvisioningScriptLen {
+		return fmt.Errorf("script must not exceed %d bytes", maxProvisioningScriptLen)
+	}
+	if t := p.GetTimeoutSeconds(); t < 0 || t > maxProvisioningTimeoutSecs {
+		return fmt.Errorf("timeout_seconds must be between 0 and %d", maxProvisioningTimeoutSecs)
+	}
+	return nil
+}
+
+// loadWorkspaceProvisioning reads the workspace's setup script. A workspace
+// with no row yields an empty one, which provisions nothing.
+func loadWorkspaceProvisioning(ctx context.Context, queries *db.Queries, workspaceID string) (*leapmuxv1.WorkspaceProvisioning, error) {
+	raw, err := queries.GetWorkspaceProvisioning(ctx, workspaceID)
+	if errors.Is(err, sql.ErrNoRows) {
+		return &leapmuxv1.WorkspaceProvisioning{}, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	p := &leapmuxv1.WorkspaceProvisioning{}
+	if err := protojson.Unmarshal([]byte(raw), p); err != nil {
+		return nil, fmt.Errorf("decode workspace provisioning: %w", err)
+	}
+	return p, nil
+}
+
+// provisioningScriptHash identifies a script version in
+// workspace_provisioning_runs.
+func provisioningScriptHash(script string) string {
+	sum := sha256.Sum256([]byte(script))
+	return hex.EncodeToString(sum[:])
+}
+
+// provisioningLock returns the mutex that keeps two agents opening in the
+// same directory at once from running the setup script twice. Entries are
+// never deleted (bounded by the distinct directories agents start in).
+func (svc *Service) provisioningLock(workspaceID, workingDir string) *sync.Mutex {
+	v, _ := svc.provisioningLocks.LoadOrStore(workspaceID+"\x00"+workingDir, &sync.Mutex{})
+	return v.(*sync.Mutex)
+}
+
+// provisionAgentWorkingDir runs the workspace's setup script in workingDir
+// before dbAgent starts there, unless the same script already succeeded
+// in that directory. The script runs in a terminal linked to the agent, so
+// its output is a tab next to the agent's. A non-nil error fails the
+// agent's start; the setup tab stays open to show why.
+func (svc *Service) provisionAgentWorkingDir(ctx context.Context, dbAgent *db.Agent, workingDir string) error {
+	p, err := loadWorkspaceProvisioning(ctx, svc.Queries, dbAgent.WorkspaceID)
+	if err != nil {
+		return fmt.Errorf("load setup script: %w", err)
+	}
+	if strings.TrimSpace(p.GetScript()) == "" {
+		return nil
+	}
+	hash := provisioningScriptHash(p.GetScript())
+
+	lock := svc.provisioningLock(dbAgent.WorkspaceID, workingDir)
+	lock.Lock()
+	defer lock.Unlock()
+
+	prev, err := svc.Queries.GetWorkspaceProvisioningRunHash(ctx, db.GetWorkspaceProvisioningRunHashParams{
+		WorkspaceID: dbAgent.WorkspaceID,
+		WorkingDir:  workingDir,
+	})
+	switch {
+	case err == nil && prev == hash:
+		return nil
+	case err != nil && !errors.Is(err, sql.ErrNoRows):
+		return fmt.Errorf("load setup state: %w", err)
+	}
+
+	label := "Running setup script…"
+	svc.AgentStartup.setMessage(dbAgent.ID, label)
+	svc.broadcastAgentStarting(dbAgent, label, nil)
+
+	title := "Setup: " + filepath.Base(workingDir)
+	started := make(chan struct{})
+	exited := make(chan int, 1)
+	terminalID, err := svc.openAgentTerminal(*dbAgent, agentTerminalSpec{
+		WorkingDir: workingDir,
+		Title:      title,
+		Command:    p.GetScript(),
+		OnStarted:  func(string) { close(started) },
+		OnExit: func(exitCode int) {
+			// Only the first exit is the script's; a restart of the tab is
+			// an ordinary shell.
+			select {
+			case exited <- exitCode:
+			default:
+			}
+		},
+	})
+	if err != nil {
+		return fmt.Errorf("open setup terminal: %w", err)
+	}
+
+	timeout := defaultProvisioningTimeout
+	if t := p.GetTimeoutSeconds(); t > 0 {
+		timeout = time.Duration(t) * time.Second
+	}
+	timer := time.NewTimer(timeout)
+	defer timer.Stop()
+
+	select {
+	case <-started:
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+	if !svc.Terminals.HasTerminal(terminalID) {
+		return fmt.Errorf("setup script failed to start; see the %q tab", title)
+	}
+	select {
+	case exitCode := <-exited:
+		if exitCode != 0 {
+			return fmt.Errorf("setup script exited with status %d; see the %q tab", exitCode, title)
+		}
+	case <-timer.C:
+		svc.Terminals.StopTerminal(terminalID)
+		return fmt.Errorf("setup script did not finish within %s; see the %q tab", timeout, title)
+	case <-ctx.Done():
+		svc.Terminals.StopTerminal(terminalID)
+		return ctx.Err()
+	}
+
+	if err := svc.Queries.UpsertWorkspaceProvisioningRun(bgCtx(), db.UpsertWorkspaceProvisioningRunParams{
+		WorkspaceID:  dbAgent.WorkspaceID,
+		WorkingDir:   workingDir,
+		ScriptSha256: hash,
+		TerminalID:   terminalID,
+	}); err != nil {
+		// The directory is set up; the cost of the lost record is one
+		// more run of the script next time.
+		slog.Warn("failed to record setup run", "workspace_id", dbAgent.WorkspaceID, "working_dir", workingDir, "error", err)
+	}
+	return nil
+}
+
+func registerWorkspaceProvisioningHandlers(d registrar, svc *Service) {
+	registerWorkspaceGated(d, "GetWorkspaceProvisioning",
+		func(ctx context.Context, _ userid.UserID, r *leapmuxv1.GetWorkspaceProvisioningRequest, sender channel.ResponseWriter) {
+			p, err := loadWorkspaceProvisioning(ctx, svc.Queries, r.GetWorkspaceId())
+			if err != nil {
+				slog.Error("failed to load workspace provisioning", "workspace_id", r.GetWorkspaceId(), "error", err)
+				sendInternalError(sender, "failed to load workspace provisioning")
+				return
+			}
+			sendProtoResponse(sender, &leapmuxv1.GetWorkspaceProvisioningResponse{Provisioning: p})
+		})
+
+	registerWorkspaceGated(d, "SetWorkspaceProvisioning",
+		func(_ context.Context, _ userid.UserID, r *leapmuxv1.SetWorkspaceProvisioningRequest, sender channel.ResponseWriter) {
+			p := r.GetProvisioning()
+			if p == nil {
+				p = &leapmuxv1.WorkspaceProvisioning{}
+			}
+			if err := validateWorkspaceProvisioning(p); err != nil {
+				sendInvalidArgument(sender, err.Error())
+				return
+			}
+
+			var err error
+			if strings.TrimSpace(p.GetScript()) == "" {
+				p = &leapmuxv1.WorkspaceProvisioning{}
+				err = svc.Queries.DeleteWorkspaceProvisioning(bgCtx(), r.GetWorkspaceId())
+			} else {
+				var raw []byte
+				raw, err = protojson.Marshal(p)
+				if err == nil {
+					err = svc.Queries.UpsertWorkspaceProvisioning(bgCtx(), db.UpsertWorkspaceProvisioningParams{
+						WorkspaceID: r.GetWorkspaceId(),
+						Policy:      string(raw),
+					})
+				}
+			}
+			if err != nil {
+				slog.Error("failed to save workspace provisioning", "workspace_id", r.GetWorkspaceId(), "error", err)
+				sendInternalError(sender, "failed to save workspace provisioning")
+				return
+			}
+			sendProtoResponse(sender, &leapmuxv1.SetWorkspaceProvisioningResponse{Provisioning: p})
+		})
+}
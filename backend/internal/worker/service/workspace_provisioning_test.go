@@ -0,0 +1,137 @@
+package service
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/protobuf/proto"
+
+	leapmuxv1 "github.com/leapmux/leapmux/generated/proto/leapmux/v1"
+	"github.com/leapmux/leapmux/internal/util/testutil"
+	"github.com/leapmux/leapmux/internal/worker/agent"
+	"github.com/leapmux/leapmux/internal/worker/channel"
+)
+
+func setProvisioning(t *testing.T, d *channel.Dispatcher, script string) {
+	t.Helper()
+	w := newTestWriter()
+	dispatch(d, "SetWorkspaceProvisioning", &leapmuxv1.SetWorkspaceProvisioningRequest{
+		WorkspaceId:  "ws-1",
+		Provisioning: &leapmuxv1.WorkspaceProvisioning{Script: script},
+	}, w)
+	require.Empty(t, w.errors)
+}
+
+func openAgentIn(t *testing.T, d *channel.Dispatcher, workingDir string) string {
+	t.Helper()
+	w := newTestWriter()
+	dispatch(d, "OpenAgent", &leapmuxv1.OpenAgentRequest{
+		WorkspaceId:   "ws-1",
+		WorkingDir:    workingDir,
+		AgentProvider: leapmuxv1.AgentProvider_AGENT_PROVIDER_CLAUDE_CODE,
+	}, w)
+	require.Empty(t, w.errors)
+	require.Len(t, w.responses, 1)
+	var resp leapmuxv1.OpenAgentResponse
+	require.NoError(t, proto.Unmarshal(w.responses[0].GetPayload(), &resp))
+	return resp.GetAgent().GetId()
+}
+
+func TestProvisioning_RunsOncePerDirectoryBeforeAgentStarts(t *testing.T) {
+	svc, d, _ := setupTestService(t, withWorkspaces("ws-1"))
+	defer drainAllInFlight(svc)
+	dir := t.TempDir()
+	setProvisioning(t, d, "echo setup >> provisioned.txt")
+
+	type start struct{ marker string }
+	starts := make(chan start, 2)
+	svc.startAgentFn = func(_ context.Context, opts agent.Options, _ agent.OutputSink) (map[string]string, error) {
+		marker, _ := os.ReadFile(filepath.Join(opts.WorkingDir, "provisioned.txt"))
+		starts <- start{marker: string(marker)}
+		return map[string]string{}, nil
+	}
+
+	agentID := openAgentIn(t, d, dir)
+	select {
+	case s := <-starts:
+		assert.Equal(t, "setup\n", s.marker, "the agent starts after its directory is set up")
+	case <-time.After(15 * time.Second):
+		t.Fatal("agent never started")
+	}
+	ids, err := svc.Queries.ListOpenTerminalIDsByAgentID(context.Background(), agentID)
+	require.NoError(t, err)
+	require.Len(t, ids, 1, "the script ran in a terminal linked to the agent")
+	testutil.RegisterTerminalCleanup(t, svc.Terminals, ids[0])
+	row, err := svc.Queries.GetTerminal(context.Background(), ids[0])
+	require.NoError(t, err)
+	assert.Equal(t, "Setup: "+filepath.Base(dir), row.Title)
+
+	// A second agent in the same directory starts without a second run.
+	second := openAgentIn(t, d, dir)
+	select {
+	case s := <-starts:
+		assert.Equal(t, "setup\n", s.marker)
+	case <-time.After(15 * time.Second):
+		t.Fatal("second agent never started")
+	}
+	ids, err = svc.Queries.ListOpenTerminalIDsByAgentID(context.Background(), second)
+	require.NoError(t, err)
+	assert.Empty(t, ids)
+}
+
+func TestProvisioning_FailedScriptFailsAgentStart(t *testing.T) {
+	svc, d, _ := setupTestService(t, withWorkspaces("ws-1"))
+	defer drainAllInFlight(svc)
+	setProvisioning(t, d, "echo missing dependency; exit 3")
+	svc.startAgentFn = func(context.Context, agent.Options, agent.OutputSink) (map[string]string, error) {
+		t.Error("agent started although its setup failed")
+		return map[string]string{}, nil
+	}
+
+	agentID := openAgentIn(t, d, t.TempDir())
+	testutil.RequireEventually(t, func() bool {
+		row, err := svc.Queries.GetAgentByID(context.Background(), agentID)
+		return err == nil && row.StartupError != ""
+	}, "startup error recorded")
+	row, err := svc.Queries.GetAgentByID(context.Background(), agentID)
+	require.NoError(t, err)
+	assert.Contains(t, row.StartupError, "setup script exited with status 3")
+	ids, err := svc.Queries.ListOpenTerminalIDsByAgentID(context.Background(), agentID)
+	require.NoError(t, err)
+	require.Len(t, ids, 1, "the setup tab stays open to show the failure")
+	testutil.RegisterTerminalCleanup(t, svc.Terminals, ids[0])
+}
+
+func TestWorkspaceProvisioning_SetAndGet(t *testing.T) {
+	svc, d, w := setupTestService(t, withWorkspaces("ws-1"))
+
+	p := &leapmuxv1.WorkspaceProvisioning{Script: "npm ci", TimeoutSeconds: 120}
+	dispatch(d, "SetWorkspaceProvisioning", &leapmuxv1.SetWorkspaceProvisioningRequest{WorkspaceId: "ws-1", Provisioning: p}, w)
+	dispatch(d, "GetWorkspaceProvisioning", &leapmuxv1.GetWorkspaceProvisioningRequest{WorkspaceId: "ws-1"}, w)
+	require.Empty(t, w.errors)
+	require.Len(t, w.responses, 2)
+	var resp leapmuxv1.GetWorkspaceProvisioningResponse
+	require.NoError(t, proto.Unmarshal(w.responses[1].GetPayload(), &resp))
+	assert.True(t, proto.Equal(p, resp.GetProvisioning()))
+
+	// A blank script turns provisioning off by dropping the row.
+	dispatch(d, "SetWorkspaceProvisioning", &leapmuxv1.SetWorkspaceProvisioningRequest{
+		WorkspaceId:  "ws-1",
+		Provisioning: &leapmuxv1.WorkspaceProvisioning{Script: "  \n", TimeoutSeconds: 5},
+	}, w)
+	require.Empty(t, w.errors)
+	_, err := svc.Queries.GetWorkspaceProvisioning(context.Background(), "ws-1")
+	assert.Error(t, err)
+
+	dispatch(d, "SetWorkspaceProvisioning", &leapmuxv1.SetWorkspaceProvisioningRequest{
+		WorkspaceId:  "ws-1",
+		Provisioning: &leapmuxv1.WorkspaceProvisioning{Script: "x", TimeoutSeconds: -1},
+	}, w)
+	require.Len(t, w.errors, 1)
+	assert.Equal(t, codeInvalidArgument, w.errors[0].code)
+}
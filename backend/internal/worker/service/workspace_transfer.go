@@ -0,0 +1,285 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"os"
+	"strings"
+	"time"
+
+	leapmuxv1 "github.com/leapmux/leapmux/generated/proto/leapmux/v1"
+	"github.com/leapmux/leapmux/internal/util/id"
+	"github.com/leapmux/leapmux/internal/util/sqltime"
+	"github.com/leapmux/leapmux/internal/util/timefmt"
+	"github.com/leapmux/leapmux/internal/util/userid"
+	"github.com/leapmux/leapmux/internal/worker/channel"
+	db "github.com/leapmux/leapmux/internal/worker/generated/db"
+)
+
+// bundleAgent converts an open agent and its full history for export,
+// leaving out the messages tagged with any of excludeTags.
+func (svc *Service) bundleAgent(ctx context.Context, a db.Agent, excludeTags []string) (*leapmuxv1.BundledAgent, error) {
+	rows, err := svc.Queries.ListAllMessagesByAgentID(ctx, db.ListAllMessagesByAgentIDParams{AgentID: a.ID, Seq: 0})
+	if err != nil {
+		return nil, fmt.Errorf("list messages: %w", err)
+	}
+	classifications, err := messageClassifications(ctx, svc.Queries, a.ID)
+	if err != nil {
+		return nil, fmt.Errorf("list classifications: %w", err)
+	}
+	messages := make([]*leapmuxv1.BundledMessage, 0, len(rows))
+	for _, m := range rows {
+		tags := classifications[m.ID]
+		if hasAnyTag(tags, excludeTags) {
+			continue
+		}
+		messages = append(messages, &leapmuxv1.BundledMessage{
+			Source:             m.Source,
+			Content:            m.Content,
+			ContentCompression: m.ContentCompression,
+			AgentProvider:      m.AgentProvider,
+			Depth:              int32(m.Depth),
+			SpanId:             m.SpanID,
+			ParentSpanId:       m.ParentSpanID,
+			SpanType:           m.SpanType,
+			SpanColor:          int32(m.SpanColor),
+			SpanLines:          m.SpanLines,
+			DeliveryError:      m.DeliveryError,
+			MarkType:           m.MarkType,
+			CreatedAt:          timefmt.Format(m.CreatedAt.Time),
+			ClassificationTags: tags,
+		})
+	}
+	return &leapmuxv1.BundledAgent{
+		Id:            a.ID,
+		Title:         a.Title,
+		AgentProvider: a.AgentProvider,
+		WorkingDir:    a.WorkingDir,
+		Options:       a.Options,
+		Messages:      messages,
+	}, nil
+}
+
+// exportWorkspaceAgents collects the open agents and the plan library of
+// workspaceID, without the messages tagged with any of excludeTags.
+func (svc *Service) exportWorkspaceAgents(ctx context.Context, workspaceID string, excludeTags []string) (*leapmuxv1.WorkerBundle, error) {
+	bundle := &leapmuxv1.WorkerBundle{}
+	agentIDs, err := svc.Queries.ListOpenAgentIDsByWorkspaceID(ctx, workspaceID)
+	if err != nil {
+		return nil, fmt.Errorf("list agents: %w", err)
+	}
+	for _, agentID := range agentIDs {
+		a, err := svc.Queries.GetAgentByID(ctx, agentID)
+		if err != nil {
+			return nil, fmt.Errorf("get agent %s: %w", agentID, err)
+		}
+		ba, err := svc.bundleAgent(ctx, a, excludeTags)
+		if err != nil {
+			return nil, fmt.Errorf("agent %s: %w", agentID, err)
+		}
+		bundle.Agents = append(bundle.Agents, ba)
+	}
+
+	plans, err := svc.Queries.ListWorkspacePlans(ctx, workspaceID)
+	if err != nil {
+		return nil, fmt.Errorf("list plans: %w", err)
+	}
+	for _, p := range plans {
+		revisions, err := svc.Queries.ListPlanRevisions(ctx, p.ID)
+		if err != nil {
+			return nil, fmt.Errorf("list revisions of plan %s: %w", p.ID, err)
+		}
+		bp := &leapmuxv1.BundledPlan{Title: p.Title}
+		for _, r := range revisions {
+			bp.Revisions = append(bp.Revisions, r.Content)
+		}
+		bundle.Plans = append(bundle.Plans, bp)
+	}
+	return bundle, nil
+}
+
+// validateWorkerBundle rejects a bundle the import would have to persist in
+// a shape no local path could have produced. The options are checked
+// against this worker's permission guardrails, since they take effect the
+// first time the imported agent starts.
+func (svc *Service) validateWorkerBundle(bundle *leapmuxv1.WorkerBundle) error {
+	seen := make(map[string]bool, len(bundle.GetAgents()))
+	for _, a := range bundle.GetAgents() {
+		if a.GetId() == "" || seen[a.GetId()] {
+			return fmt.Errorf("agent ids must be present and unique")
+		}
+		seen[a.GetId()] = true
+		if _, err := sanitizeOptionalTitle(a.GetTitle()); err != nil {
+			return fmt.Errorf("agent %s: %w", a.GetId(), err)
+		}
+		if a.GetAgentProvider() == leapmuxv1.AgentProvider_AGENT_PROVIDER_UNSPECIFIED {
+			return fmt.Errorf("agent %s: agent_provider is required", a.GetId())
+		}
+		if err := svc.permissionGuardrails().checkLaunchPermissionMode(parseOptions(a.GetOptions())); err != nil {
+			return fmt.Errorf("agent %s: %w", a.GetId(), err)
+		}
+		for _, m := range a.GetMessages() {
+			if _, err := time.Parse(timefmt.ISO8601, m.GetCreatedAt()); err != nil {
+				return fmt.Errorf("agent %s: invalid message created_at %q", a.GetId(), m.GetCreatedAt())
+			}
+			for _, tag := range m.GetClassificationTags() {
+				if tag == "" || strings.Contains(tag, ",") {
+					return fmt.Errorf("agent %s: invalid message classification tag %q", a.GetId(), tag)
+				}
+			}
+		}
+	}
+	for _, p := range bundle.GetPlans() {
+		if len(p.GetTitle()) > maxPlanTitleLen {
+			return fmt.Errorf("plan title must not exceed %d bytes", maxPlanTitleLen)
+		}
+		if len(p.GetRevisions()) == 0 {
+			return fmt.Errorf("plan %q has no revisions", p.GetTitle())
+		}
+		for _, content := range p.GetRevisions() {
+			if content == "" || len(content) > maxPlanContentLen {
+				return fmt.Errorf("plan %q has an empty or oversized revision", p.GetTitle())
+			}
+		}
+	}
+	return nil
+}
+
+// importWorkspaceAgents re-creates bundle in workspaceID under fresh ids,
+// atomically, and returns the exported agent ids mapped to the new ones.
+// Imported agents carry no provider session: the history is there to read,
+// and the first prompt starts a fresh session. They are not started here.
+func (svc *Service) importWorkspaceAgents(ctx context.Context, userID userid.UserID, workspaceID string, bundle *leapmuxv1.WorkerBundle) (map[string]string, error) {
+	tx, err := svc.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer func() { _ = tx.Rollback() }()
+	queries := svc.Queries.WithTx(tx)
+
+	agentIDs := make(map[string]string, len(bundle.GetAgents()))
+	for _, a := range bundle.GetAgents() {
+		title, _ := sanitizeOptionalTitle(a.GetTitle())
+		if title == "" {
+			title = pickAgentTitle()
+		}
+		workingDir := expandTilde(a.GetWorkingDir())
+		if fi, err := os.Stat(workingDir); workingDir == "" || err != nil || !fi.IsDir() {
+			workingDir = svc.HomeDir
+		}
+		agentID := id.Generate()
+		if err := queries.CreateAgent(ctx, db.CreateAgentParams{
+			ID:            agentID,
+			WorkspaceID:   workspaceID,
+			WorkingDir:    workingDir,
+			HomeDir:       svc.HomeDir,
+			Title:         title,
+			Options:       marshalOptions(parseOptions(a.GetOptions())),
+			AgentProvider: a.GetAgentProvider(),
+			CreatedBy:     userID.String(),
+		}); err != nil {
+			return nil, fmt.Errorf("create agent: %w", err)
+		}
+		for _, m := range a.GetMessages() {
+			createdAt, _ := time.Parse(timefmt.ISO8601, m.GetCreatedAt())
+			provider := m.GetAgentProvider()
+			if provider == leapmuxv1.AgentProvider_AGENT_PROVIDER_UNSPECIFIED {
+				provider = a.GetAgentProvider()
+			}
+			messageID := id.Generate()
+			params := db.CreateMessageParams{
+				ID:                 messageID,
+				AgentID:            agentID,
+				Source:             m.GetSource(),
+				Content:            m.GetContent(),
+				ContentCompression: m.GetContentCompression(),
+				Depth:              int64(m.GetDepth()),
+				SpanID:             m.GetSpanId(),
+				ParentSpanID:       m.GetParentSpanId(),
+				SpanType:           m.GetSpanType(),
+				SpanLines:          m.GetSpanLines(),
+				SpanColor:          int64(m.GetSpanColor()),
+				AgentProvider:      provider,
+				MarkType:           m.GetMarkType(),
+				CreatedAt:          sqltime.NewSQLiteTime(createdAt),
+			}
+			if _, err := createMessageRow(ctx, queries, params); err != nil {
+				return nil, fmt.Errorf("create message: %w", err)
+			}
+			if err := svc.Output.chainMessage(ctx, queries, params); err != nil {
+				return nil, fmt.Errorf("chain message: %w", err)
+			}
+			if m.GetDeliveryError() != "" {
+				if err := queries.SetMessageDeliveryError(ctx, db.SetMessageDeliveryErrorParams{
+					DeliveryError: m.GetDeliveryError(),
+					ID:            messageID,
+					AgentID:       agentID,
+				}); err != nil {
+					return nil, fmt.Errorf("set delivery error: %w", err)
+				}
+			}
+			if tags := m.GetClassificationTags(); len(tags) > 0 {
+				if err := queries.CreateMessageClassification(ctx, db.CreateMessageClassificationParams{
+					MessageID: messageID,
+					AgentID:   agentID,
+					Tags:      strings.Join(tags, ","),
+				}); err != nil {
+					return nil, fmt.Errorf("create classification: %w", err)
+				}
+			}
+		}
+		agentIDs[a.GetId()] = agentID
+	}
+
+	for _, p := range bundle.GetPlans() {
+		planID := id.Generate()
+		if err := queries.CreateWorkspacePlan(ctx, db.CreateWorkspacePlanParams{
+			ID:          planID,
+			WorkspaceID: workspaceID,
+			Title:       p.GetTitle(),
+		}); err != nil {
+			return nil, fmt.Errorf("create plan: %w", err)
+		}
+		for _, content := range p.GetRevisions() {
+			if _, err := queries.AppendPlanRevision(ctx, db.AppendPlanRevisionParams{
+				PlanID:  planID,
+				Content: content,
+			}); err != nil {
+				return nil, fmt.Errorf("append plan revision: %w", err)
+			}
+		}
+	}
+	return agentIDs, tx.Commit()
+}
+
+func registerWorkspaceTransferHandlers(d registrar, svc *Service) {
+	registerWorkspaceGated(d, "ExportWorkspaceAgents",
+		func(ctx context.Context, _ userid.UserID, r *leapmuxv1.ExportWorkspaceAgentsRequest, sender channel.ResponseWriter) {
+			bundle, err := svc.exportWorkspaceAgents(ctx, r.GetWorkspaceId(), r.GetExcludeClassificationTags())
+			if err != nil {
+				slog.Error("failed to export workspace agents", "workspace_id", r.GetWorkspaceId(), "error", err)
+				sendInternalError(sender, "failed to export workspace")
+				return
+			}
+			sendProtoResponse(sender, &leapmuxv1.ExportWorkspaceAgentsResponse{Bundle: bundle})
+		})
+
+	// ImportWorkspaceAgents persists under a fresh background context, like
+	// OpenAgent: a retry after a mid-RPC disconnect must not find half an
+	// import. The transaction already makes the import all-or-nothing.
+	registerWorkspaceGated(d, "ImportWorkspaceAgents",
+		func(_ context.Context, userID userid.UserID, r *leapmuxv1.ImportWorkspaceAgentsRequest, sender channel.ResponseWriter) {
+			if err := svc.validateWorkerBundle(r.GetBundle()); err != nil {
+				sendInvalidArgument(sender, err.Error())
+				return
+			}
+			agentIDs, err := svc.importWorkspaceAgents(bgCtx(), userID, r.GetWorkspaceId(), r.GetBundle())
+			if err != nil {
+				slog.Error("failed to import workspace agents", "workspace_id", r.GetWorkspaceId(), "error", err)
+				sendInternalError(sender, "failed to import workspace")
+				return
+			}
+			sendProtoResponse(sender, &leapmuxv1.ImportWorkspaceAgentsResponse{AgentIds: agentIDs})
+		})
+}
@@ -0,0 +1,90 @@
+package service
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/protobuf/proto"
+
+	leapmuxv1 "github.com/leapmux/leapmux/generated/proto/leapmux/v1"
+	"github.com/leapmux/leapmux/internal/util/sqltime"
+	db "github.com/leapmux/leapmux/internal/worker/generated/db"
+)
+
+func TestWorkspaceTransfer_RoundTrip(t *testing.T) {
+	svc, d, w := setupTestService(t, withWorkspaces("ws-1", "ws-2"))
+	ctx := context.Background()
+	seedGuardedAgent(t, svc, "")
+	for i, content := range []string{`{"content":"hi"}`, `{"content":"hello"}`} {
+		_, err := createMessageRow(ctx, svc.Queries, db.CreateMessageParams{
+			ID:            []string{"m-1", "m-2"}[i],
+			AgentID:       "agent-1",
+			Source:        leapmuxv1.MessageSource_MESSAGE_SOURCE_USER,
+			Content:       []byte(content),
+			AgentProvider: claudeProvider,
+			MarkType:      leapmuxv1.MarkType_MARK_TYPE_USER_MESSAGE,
+			CreatedAt:     sqltime.NewSQLiteTime(nowMillis()),
+		})
+		require.NoError(t, err)
+	}
+	require.NoError(t, svc.Queries.SetMessageDeliveryError(ctx, db.SetMessageDeliveryErrorParams{
+		DeliveryError: "agent is not running", ID: "m-2", AgentID: "agent-1",
+	}))
+	plan := savePlan(t, d, w, &leapmuxv1.SavePlanRequest{WorkspaceId: "ws-1", Title: "Ship", Content: "v1"})
+	savePlan(t, d, w, &leapmuxv1.SavePlanRequest{WorkspaceId: "ws-1", PlanId: plan.GetPlanId(), Content: "v2"})
+
+	dispatch(d, "ExportWorkspaceAgents", &leapmuxv1.ExportWorkspaceAgentsRequest{WorkspaceId: "ws-1"}, w)
+	require.Empty(t, w.errors)
+	exported := decodeResponse[leapmuxv1.ExportWorkspaceAgentsResponse](t, w).GetBundle()
+	require.Len(t, exported.GetAgents(), 1)
+	require.Len(t, exported.GetAgents()[0].GetMessages(), 2)
+	assert.Equal(t, "agent is not running", exported.GetAgents()[0].GetMessages()[1].GetDeliveryError())
+	require.Len(t, exported.GetPlans(), 1)
+	assert.Equal(t, []string{"v1", "v2"}, exported.GetPlans()[0].GetRevisions())
+
+	// The bundle passes through the frontend, so it must survive the wire.
+	raw, err := proto.Marshal(exported)
+	require.NoError(t, err)
+	var bundle leapmuxv1.WorkerBundle
+	require.NoError(t, proto.Unmarshal(raw, &bundle))
+
+	dispatch(d, "ImportWorkspaceAgents", &leapmuxv1.ImportWorkspaceAgentsRequest{WorkspaceId: "ws-2", Bundle: &bundle}, w)
+	require.Empty(t, w.errors)
+	newID := decodeResponse[leapmuxv1.ImportWorkspaceAgentsResponse](t, w).GetAgentIds()["agent-1"]
+	require.NotEmpty(t, newID)
+	assert.NotEqual(t, "agent-1", newID)
+
+	imported, err := svc.Queries.GetAgentByID(ctx, newID)
+	require.NoError(t, err)
+	assert.Equal(t, "ws-2", imported.WorkspaceID)
+	assert.Empty(t, imported.AgentSessionID, "an imported agent starts a fresh provider session")
+	msgs, err := svc.Queries.ListAllMessagesByAgentID(ctx, db.ListAllMessagesByAgentIDParams{AgentID: newID})
+	require.NoError(t, err)
+	require.Len(t, msgs, 2)
+	assert.Equal(t, `{"content":"hello"}`, string(msgs[1].Content))
+	assert.Equal(t, "agent is not running", msgs[1].DeliveryError)
+
+	plans, err := svc.Queries.ListWorkspacePlans(ctx, "ws-2")
+	require.NoError(t, err)
+	require.Len(t, plans, 1)
+	assert.Equal(t, "Ship", plans[0].Title)
+	assert.Equal(t, int64(2), plans[0].LatestRevision)
+}
+
+func TestWorkspaceTransfer_InvalidBundleImportsNothing(t *testing.T) {
+	svc, d, w := setupTestService(t, withWorkspaces("ws-1"))
+	agent := &leapmuxv1.BundledAgent{Id: "a", AgentProvider: claudeProvider}
+
+	dispatch(d, "ImportWorkspaceAgents", &leapmuxv1.ImportWorkspaceAgentsRequest{
+		WorkspaceId: "ws-1",
+		Bundle:      &leapmuxv1.WorkerBundle{Agents: []*leapmuxv1.BundledAgent{agent, agent}},
+	}, w)
+
+	require.Len(t, w.errors, 1)
+	assert.Equal(t, codeInvalidArgument, w.errors[0].code)
+	ids, err := svc.Queries.ListOpenAgentIDsByWorkspaceID(context.Background(), "ws-1")
+	require.NoError(t, err)
+	assert.Empty(t, ids)
+}
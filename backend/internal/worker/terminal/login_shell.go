@@ -63,3 +63,30 @@ func LoginShellArgs(shellPath string) []string {
 		return []string{"-i", "-l"}
 	}
 }
+
+// CommandShellArgs returns the flags that make the given shell run command
+// and exit with its status, as a login shell where the shell has one so
+// the command sees the PATH the user's profile sets up. Unlike
+// LoginShellArgs the shell is not interactive: no prompt, no rc file
+// meant for a person at a keyboard.
+//
+//   - pwsh/pwsh-preview:        ["-Login", "-Command", command]
+//   - powershell(-preview):     ["-Command", command]
+//   - cmd.exe:                  ["/D", "/C", command]
+//   - tcsh/csh:                 ["-c", command] — -l is only accepted alone
+//   - all others:               ["-l", "-c", command]
+func CommandShellArgs(shellPath, command string) []string {
+	name := ShellBaseName(shellPath)
+	switch {
+	case pwshCorePattern.MatchString(name):
+		return []string{"-Login", "-Command", command}
+	case IsPwsh(name):
+		return []string{"-Command", command}
+	case name == "cmd":
+		return []string{"/D", "/C", command}
+	case name == "tcsh" || name == "csh":
+		return []string{"-c", command}
+	default:
+		return []string{"-l", "-c", command}
+	}
+}
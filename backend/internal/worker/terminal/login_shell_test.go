@@ -55,6 +55,26 @@ func TestLoginShellArgs_WindowsPowerShellNoLogin(t *testing.T) {
 	assert.Equal(t, []string{"-Login"}, LoginShellArgs("pwsh"))
 }
 
+func TestCommandShellArgs(t *testing.T) {
+	tests := []struct {
+		name      string
+		shellPath string
+		want      []string
+	}{
+		{"bash", "/bin/bash", []string{"-l", "-c", "make setup"}},
+		{"fish", "/usr/bin/fish", []string{"-l", "-c", "make setup"}},
+		{"tcsh", "/bin/tcsh", []string{"-c", "make setup"}},
+		{"pwsh", `C:\Program Files\PowerShell\7\pwsh.exe`, []string{"-Login", "-Command", "make setup"}},
+		{"powershell", "powershell", []string{"-Command", "make setup"}},
+		{"cmd.exe", `C:\Windows\System32\cmd.exe`, []string{"/D", "/C", "make setup"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.want, CommandShellArgs(tt.shellPath, "make setup"))
+		})
+	}
+}
+
 func TestIsPwsh(t *testing.T) {
 	assert.True(t, IsPwsh("pwsh"))
 	assert.True(t, IsPwsh("powershell"))
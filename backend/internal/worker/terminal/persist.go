@@ -0,0 +1,126 @@
+package terminal
+
+import (
+	"log/slog"
+	"os"
+	"os/exec"
+	"runtime"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// tmuxSocket names the tmux server persistent terminals run under, so
+// they never mix with the user's own tmux sessions. A var so tests can
+// point at a throwaway server.
+var tmuxSocket = "leapmux"
+
+// tmuxOptions configure the server for a terminal that should look like
+// a bare shell: no status line, no prefix key to swallow Ctrl-B, no
+// alternate screen (so output still reaches the browser's scrollback),
+// and the shell's title passed through to the tab.
+var tmuxOptions = [][2]string{
+	{"status", "off"},
+	{"prefix", "None"},
+	{"prefix2", "None"},
+	{"escape-time", "0"},
+	{"set-titles", "on"},
+	{"set-titles-string", "#{pane_title}"},
+	{"terminal-overrides", "*:smcup@:rmcup@"},
+}
+
+var (
+	tmuxOnce sync.Once
+	tmuxPath string
+)
+
+// PersistenceAvailable reports whether terminals on this machine can
+// outlive the worker: it needs tmux 3.0 or later on PATH (earlier
+// releases cannot set a new session's environment).
+func PersistenceAvailable() bool {
+	tmuxOnce.Do(func() {
+		if runtime.GOOS == "windows" {
+			return
+		}
+		path, err := exec.LookPath("tmux")
+		if err != nil {
+			return
+		}
+		out, err := exec.Command(path, "-V").Output()
+		if err != nil || !tmuxVersionSupported(string(out)) {
+			slog.Warn("tmux too old for persistent terminals; need 3.0 or later", "version", strings.TrimSpace(string(out)))
+			return
+		}
+		tmuxPath = path
+	})
+	return tmuxPath != ""
+}
+
+// tmuxVersionSupported parses `tmux -V` output such as "tmux 3.3a",
+// "tmux next-3.4" or "tmux master".
+func tmuxVersionSupported(out string) bool {
+	fields := strings.Fields(out)
+	if len(fields) < 2 {
+		return false
+	}
+	v := strings.TrimPrefix(fields[1], "next-")
+	if v == "master" {
+		return true
+	}
+	major, _, _ := strings.Cut(v, ".")
+	n, err := strconv.Atoi(major)
+	return err == nil && n >= 3
+}
+
+// tmuxCommand runs a tmux subcommand against the worker's server.
+func tmuxCommand(args ...string) *exec.Cmd {
+	return exec.Command(tmuxPath, append([]string{"-L", tmuxSocket}, args...)...)
+}
+
+// persistentSessionExists reports whether a shell for terminalID outlived
+// its last attachment.
+func persistentSessionExists(terminalID string) bool {
+	// "=" makes tmux match the name exactly rather than as a prefix.
+	return tmuxCommand("has-session", "-t", "="+terminalID).Run() == nil
+}
+
+// persistentArgs builds the tmux command line that attaches to
+// terminalID's session, creating it around shell and shellArgs first if
+// there is none. env is set in a new session only, so the server's own
+// environment never carries one terminal's variables into another.
+func persistentArgs(terminalID string, cols, rows uint16, env []string, shell string, shellArgs []string) []string {
+	args := []string{"-L", tmuxSocket, "-f", os.DevNull, "start-server", ";"}
+	for _, o := range tmuxOptions {
+		args = append(args, "set-option", "-g", o[0], o[1], ";")
+	}
+	args = append(args, "new-session", "-A", "-s", terminalID,
+		"-x", strconv.Itoa(int(cols)), "-y", strconv.Itoa(int(rows)))
+	for _, e := range env {
+		args = append(args, "-e", e)
+	}
+	args = append(args, "--", shell)
+	return append(args, shellArgs...)
+}
+
+// KillPersistentSession ends the shell a persistent terminal left
+// running. Closing the tab calls it; stopping the terminal only detaches.
+// Does nothing when there is no such session.
+func KillPersistentSession(terminalID string) {
+	if !PersistenceAvailable() {
+		return
+	}
+	_ = tmuxCommand("kill-session", "-t", "="+terminalID).Run()
+}
+
+// ListPersistentSessions returns the terminal IDs whose shells are still
+// running under the worker's tmux server. None when no server is running.
+func ListPersistentSessions() []string {
+	if !PersistenceAvailable() {
+		return nil
+	}
+	out, err := tmuxCommand("list-sessions", "-F", "#{session_name}").Output()
+	if err != nil {
+		return nil
+	}
+	return strings.Fields(string(out))
+}
@@ -0,0 +1,95 @@
+package terminal
+
+import (
+	"context"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/leapmux/leapmux/internal/util/testutil"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestTmuxVersionSupported(t *testing.T) {
+	for out, want := range map[string]bool{
+		"tmux 3.3a\n":     true,
+		"tmux 3.0":        true,
+		"tmux next-3.5":   true,
+		"tmux master":     true,
+		"tmux 2.9a":       false,
+		"tmux":            false,
+		"tmux openbsd-7":  false,
+		"something else ": false,
+	} {
+		assert.Equal(t, want, tmuxVersionSupported(out), out)
+	}
+}
+
+// useTestTmuxServer points persistent terminals at a server of the
+// test's own and kills it afterwards.
+func useTestTmuxServer(t *testing.T) {
+	t.Helper()
+	if !PersistenceAvailable() {
+		t.Skip("needs tmux 3.0 or later")
+	}
+	prev := tmuxSocket
+	tmuxSocket = "leapmux-test-" + strconv.Itoa(os.Getpid())
+	t.Cleanup(func() {
+		_ = tmuxCommand("kill-server").Run()
+		tmuxSocket = prev
+	})
+}
+
+func TestTerminal_PersistReattaches(t *testing.T) {
+	useTestTmuxServer(t)
+	var mu sync.Mutex
+	var output []byte
+	outputFn := func(data []byte, _ int64) {
+		mu.Lock()
+		output = append(output, data...)
+		mu.Unlock()
+	}
+	opts := Options{
+		ID:             "test-persist",
+		Shell:          testutil.TestShell(),
+		WorkingDir:     t.TempDir(),
+		Env:            []string{"PERSIST_MARK=from-env"},
+		StartupCommand: "export PERSIST_MARK=kept",
+		Persist:        true,
+	}
+
+	term, err := Start(context.Background(), opts, outputFn)
+	require.NoError(t, err, "Start")
+	testutil.AssertEventually(t, func() bool {
+		return persistentSessionExists(opts.ID)
+	}, "expected a tmux session for the terminal")
+	// Stopping only detaches: the shell keeps running under tmux.
+	term.Stop()
+	term.Wait()
+	require.True(t, persistentSessionExists(opts.ID), "the shell outlives the terminal")
+	assert.Equal(t, []string{opts.ID}, ListPersistentSessions())
+
+	// The reattached shell kept the startup command's export; typing the
+	// command again would have been harmless here, but a reattach must
+	// not type it at all.
+	opts.StartupCommand = "export PERSIST_MARK=typed-again"
+	mu.Lock()
+	output = nil
+	mu.Unlock()
+	term, err = Start(context.Background(), opts, outputFn)
+	require.NoError(t, err, "reattach")
+	defer term.Stop()
+	require.NoError(t, term.SendInput([]byte("echo mark-$PERSIST_MARK\r")))
+	testutil.AssertEventually(t, func() bool {
+		mu.Lock()
+		defer mu.Unlock()
+		return strings.Contains(string(output), "mark-kept")
+	}, "expected the reattached shell's state")
+
+	KillPersistentSession(opts.ID)
+	assert.False(t, persistentSessionExists(opts.ID))
+	assert.Empty(t, ListPersistentSessions())
+}
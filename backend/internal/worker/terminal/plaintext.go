@@ -0,0 +1,115 @@
+package terminal
+
+import (
+	"strings"
+	"unicode/utf8"
+)
+
+// PlainTextTail renders raw PTY output as plain text and returns at most
+// its last maxLines lines, plus whether earlier lines were dropped.
+//
+// It is NOT a terminal emulator. Escape sequences (CSI, OSC, DCS and the
+// two- and three-byte forms) are dropped, a carriage return rewinds to the
+// start of the line so progress bars keep only their last redraw, and a
+// backspace erases the rune before it. Everything a full-screen program
+// draws with cursor addressing comes out in write order, not screen order;
+// the result is meant for line-oriented shell output. Trailing blank lines
+// (an idle prompt's newline, a cleared screen) are trimmed before counting.
+func PlainTextTail(data []byte, maxLines int) (text string, truncated bool) {
+	lines := plainTextLines(data)
+	for len(lines) > 0 && strings.TrimSpace(lines[len(lines)-1]) == "" {
+		lines = lines[:len(lines)-1]
+	}
+	if maxLines > 0 && len(lines) > maxLines {
+		lines = lines[len(lines)-maxLines:]
+		truncated = true
+	}
+	return strings.Join(lines, "\n"), truncated
+}
+
+// plainTextLines splits data into lines with escapes and control bytes
+// resolved as PlainTextTail describes.
+func plainTextLines(data []byte) []string {
+	var (
+		lines   []string
+		line    []byte
+		rewound bool // a \r was seen since the last printable byte
+		state   = stateGround
+	)
+	for i := 0; i < len(data); i++ {
+		b := data[i]
+		switch state {
+		case stateEsc:
+			switch b {
+			case '[':
+				state = stateCSI
+			case ']', 'P', '_', '^', 'X':
+				// OSC, DCS, APC, PM and SOS all run to BEL or ST.
+				state = stateOSC
+			case '(', ')', '*', '+', '#', '%', ' ':
+				// Charset designators and friends take one more byte.
+				i++
+				state = stateGround
+			case 0x1b:
+			default:
+				state = stateGround
+			}
+			continue
+		case stateCSI:
+			if b >= 0x40 && b <= 0x7e {
+				state = stateGround
+			} else if b == 0x1b {
+				state = stateEsc
+			}
+			continue
+		case stateOSC:
+			if b == 0x07 {
+				state = stateGround
+			} else if b == 0x1b {
+				state = stateOSCEsc
+			}
+			continue
+		case stateOSCEsc:
+			if b == '\\' {
+				state = stateGround
+			} else {
+				state = stateOSC
+			}
+			continue
+		}
+
+		switch b {
+		case 0x1b:
+			state = stateEsc
+		case '\n':
+			lines = append(lines, string(line))
+			line = line[:0]
+			rewound = false
+		case '\r':
+			// Only text after the \r replaces the line: \r\n (and \r\r\n) is
+			// just a line ending.
+			rewound = true
+		case '\b':
+			if len(line) > 0 {
+				_, size := utf8.DecodeLastRune(line)
+				line = line[:len(line)-size]
+			}
+		default:
+			if b != '\t' && (b < 0x20 || b == 0x7f) {
+				continue
+			}
+			if rewound {
+				line = line[:0]
+				rewound = false
+			}
+			line = append(line, b)
+		}
+	}
+	if len(line) > 0 {
+		lines = append(lines, string(line))
+	}
+	for i, l := range lines {
+		lines[i] = strings.ToValidUTF8(l, string(utf8.RuneError))
+	}
+	return lines
+}
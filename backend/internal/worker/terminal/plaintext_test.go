@@ -0,0 +1,62 @@
+package terminal
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestPlainTextTail(t *testing.T) {
+	tests := []struct {
+		name      string
+		data      string
+		maxLines  int
+		want      string
+		truncated bool
+	}{
+		{
+			name: "strips colors, titles and mode switches",
+			data: "\x1b]0;user@host: ~\x07\x1b[?2004h\x1b[01;32muser@host\x1b[00m:~$ ls\r\n\x1b[0m\x1b[01;34mdir\x1b[0m  file.txt\r\n",
+			want: "user@host:~$ ls\ndir  file.txt",
+		},
+		{
+			name: "carriage return keeps the last redraw",
+			data: "Downloading  10%\rDownloading  55%\rDownloading 100%\r\ndone\r\r\n",
+			want: "Downloading 100%\ndone",
+		},
+		{
+			name: "backspace erases the previous rune",
+			data: "git stauts\b \b\b \b\b \b\b \batus\r\n",
+			want: "git status",
+		},
+		{
+			name: "charset designators and ST-terminated OSC",
+			data: "\x1b(B\x1b]8;;https://example.com\x1b\\link\x1b]8;;\x1b\\ text\n",
+			want: "link text",
+		},
+		{
+			name:      "trailing blank lines are not counted",
+			data:      "one\ntwo\nthree\n\n\x1b[H\x1b[2J\n",
+			maxLines:  2,
+			want:      "two\nthree",
+			truncated: true,
+		},
+		{
+			name: "tabs kept, other control bytes dropped",
+			data: "a\tb\x07\x00c\n",
+			want: "a\tbc",
+		},
+		{
+			name: "no limit",
+			data: "one\ntwo",
+			want: "one\ntwo",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, truncated := PlainTextTail([]byte(tt.data), tt.maxLines)
+			assert.Equal(t, tt.want, got)
+			assert.Equal(t, tt.truncated, truncated)
+		})
+	}
+}
@@ -260,11 +260,28 @@ type Options struct {
 	ShellStartDir string
 	Cols          uint16
 	Rows          uint16
+	// Env is the user-set environment (NAME=value): the org's and the
+	// workspace's variables, already merged. It goes in ahead of ExtraEnv.
+	Env []string
 	// ExtraEnv is appended verbatim to the spawned shell's environment
 	// after TERM is set. The service.Service populates this with
 	// LEAPMUX_REMOTE_* so scripts inside the shell can drive LeapMux
 	// via `leapmux remote`.
 	ExtraEnv []string
+	// Command, when set, is run by the shell in place of an interactive
+	// session (see CommandShellArgs), so the terminal exits with its
+	// status. A restart starts an ordinary interactive shell.
+	Command string
+	// StartupCommand, when set, is typed into the interactive shell right
+	// after it starts, followed by Enter, so the shell stays open once the
+	// command ends. Unlike Command, a restart types it again.
+	StartupCommand string
+	// Persist runs an interactive shell inside a tmux session named after
+	// ID, so it outlives the worker. A later start with the same ID
+	// reattaches that shell instead of starting one, and does not type
+	// StartupCommand again. Ignored for Command terminals and where
+	// PersistenceAvailable is false.
+	Persist bool
 }
 
 // Start creates a new PTY terminal session. The supplied context
@@ -291,34 +308,54 @@ func startWithScreenBuffer(ctx context.Context, opts Options, screenBuf *ScreenB
 	}
 
 	args := LoginShellArgs(shell)
+	if opts.Command != "" {
+		args = CommandShellArgs(shell, opts.Command)
+	}
+
+	cols, rows := opts.Cols, opts.Rows
+	if cols == 0 {
+		cols = 80
+	}
+	if rows == 0 {
+		rows = 25
+	}
+
+	persist := opts.Persist && opts.Command == "" && PersistenceAvailable()
+	reattach := persist && persistentSessionExists(opts.ID)
+	name := shell
+	if persist {
+		// The shell's own variables go to tmux, which sets them in a new
+		// session only; the client gets the worker's environment alone.
+		sessionEnv := append(append([]string(nil), opts.Env...), opts.ExtraEnv...)
+		args = persistentArgs(opts.ID, cols, rows, sessionEnv, shell, args)
+		name = tmuxPath
+	}
 
 	ptmx, err := pty.New()
 	if err != nil {
 		return nil, fmt.Errorf("new pty: %w", err)
 	}
 
-	cmd := ptmx.CommandContext(ctx, shell, args...)
+	cmd := ptmx.CommandContext(ctx, name, args...)
 	cmd.Dir = opts.WorkingDir
 	cmd.Env = envutil.ScrubAppImageEnvSlice(append(os.Environ(),
 		"TERM=xterm-256color",
 	))
-	if len(opts.ExtraEnv) > 0 {
-		// Strip any pre-existing LEAPMUX_REMOTE_* (defensive — leapmux
-		// worker doesn't normally set them, but a recursive launch
-		// would inherit) before injecting the canonical values.
-		cmd.Env = append(envutil.StripByPrefix(cmd.Env, "LEAPMUX_REMOTE_"), opts.ExtraEnv...)
+	if persist {
+		cmd.Env = envutil.StripByPrefix(cmd.Env, "LEAPMUX_REMOTE_")
+	} else {
+		cmd.Env = append(cmd.Env, opts.Env...)
+		if len(opts.ExtraEnv) > 0 {
+			// Strip any pre-existing LEAPMUX_REMOTE_* (defensive — leapmux
+			// worker doesn't normally set them, but a recursive launch
+			// would inherit) before injecting the canonical values.
+			cmd.Env = append(envutil.StripByPrefix(cmd.Env, "LEAPMUX_REMOTE_"), opts.ExtraEnv...)
+		}
 	}
 	// No procutil.HideConsoleWindow here: on Windows, CREATE_NO_WINDOW is
 	// incompatible with ConPTY — the pseudo console already serves as the
 	// child's console, and the flag would leave it with none.
 
-	cols, rows := opts.Cols, opts.Rows
-	if cols == 0 {
-		cols = 80
-	}
-	if rows == 0 {
-		rows = 25
-	}
 	if err := ptmx.Resize(int(cols), int(rows)); err != nil {
 		_ = ptmx.Close()
 		return nil, fmt.Errorf("resize pty: %w", err)
@@ -363,11 +400,22 @@ func startWithScreenBuffer(ctx context.Context, opts Options, screenBuf *ScreenB
 	}()
 	go t.waitForExit()
 
+	// The PTY buffers the keystrokes until the shell reads its first line.
+	// "\r" is what the Enter key sends, on every platform. A reattached
+	// shell already ran it.
+	if opts.StartupCommand != "" && !reattach {
+		if err := t.SendInput([]byte(opts.StartupCommand + "\r")); err != nil {
+			slog.Warn("terminal startup command not sent", "terminal_id", opts.ID, "error", err)
+		}
+	}
+
 	slog.Info("terminal started",
 		"terminal_id", opts.ID,
 		"shell", shell,
 		"args", args,
 		"pid", cmd.Process.Pid,
+		"persistent", persist,
+		"reattached", reattach,
 	)
 
 	return t, nil
@@ -53,6 +53,36 @@ func TestTerminal_StartAndStop(t *testing.T) {
 	term.Stop()
 }
 
+func TestTerminal_StartupCommand(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("uses POSIX arithmetic expansion")
+	}
+	var mu sync.Mutex
+	var output []byte
+
+	term, err := Start(context.Background(), Options{
+		ID:             "test-startup",
+		Shell:          testutil.TestShell(),
+		WorkingDir:     t.TempDir(),
+		StartupCommand: "echo started-$((40+2))",
+	}, func(data []byte, _ int64) {
+		mu.Lock()
+		output = append(output, data...)
+		mu.Unlock()
+	})
+	require.NoError(t, err, "Start")
+	defer term.Stop()
+
+	// The echoed keystrokes hold the unexpanded "$((40+2))", so only the
+	// command's own output contains the sum.
+	testutil.AssertEventually(t, func() bool {
+		mu.Lock()
+		defer mu.Unlock()
+		return strings.Contains(string(output), "started-42")
+	}, "expected the startup command to run")
+	assert.False(t, term.IsExited(), "the shell stays open after the command")
+}
+
 // When the desktop app runs as a Linux AppImage, the runtime exports
 // ARGV0 (= AppImage filename), and zsh interprets that env var as the
 // argv[0] to use when execing every external command (AppImageKit#852).
@@ -0,0 +1,65 @@
+package testruns
+
+import (
+	"encoding/json"
+	"strings"
+)
+
+// toolOutputEnvelope covers the shell-result shapes the worker persists:
+// Claude's tool_result user message, whose structured tool_use_result holds
+// stdout/stderr apart, and Codex's completed commandExecution item.
+type toolOutputEnvelope struct {
+	ToolUseResult *struct {
+		Stdout string `json:"stdout"`
+		Stderr string `json:"stderr"`
+	} `json:"tool_use_result"`
+	Message struct {
+		Content []struct {
+			Type    string          `json:"type"`
+			Content json.RawMessage `json:"content"`
+		} `json:"content"`
+	} `json:"message"`
+	Item struct {
+		AggregatedOutput string `json:"aggregatedOutput"`
+	} `json:"item"`
+}
+
+// ToolOutput returns the text a shell tool printed, read from the persisted
+// message that closes the tool call, or "" for a shape it does not know.
+func ToolOutput(content []byte) string {
+	var env toolOutputEnvelope
+	if json.Unmarshal(content, &env) != nil {
+		return ""
+	}
+	if r := env.ToolUseResult; r != nil && r.Stdout+r.Stderr != "" {
+		return r.Stdout + "\n" + r.Stderr
+	}
+	if env.Item.AggregatedOutput != "" {
+		return env.Item.AggregatedOutput
+	}
+	var b strings.Builder
+	for _, block := range env.Message.Content {
+		if block.Type != "tool_result" {
+			continue
+		}
+		var text string
+		if json.Unmarshal(block.Content, &text) == nil {
+			b.WriteString(text)
+			b.WriteByte('\n')
+			continue
+		}
+		var parts []struct {
+			Type string `json:"type"`
+			Text string `json:"text"`
+		}
+		if json.Unmarshal(block.Content, &parts) == nil {
+			for _, p := range parts {
+				if p.Type == "text" {
+					b.WriteString(p.Text)
+					b.WriteByte('\n')
+				}
+			}
+		}
+	}
+	return b.String()
+}
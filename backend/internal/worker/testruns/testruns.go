@@ -0,0 +1,198 @@
+// Package testruns recognizes test-runner commands in an agent's shell tool
+// calls and reads the pass/fail/coverage summary out of their output, so
+// "did the tests pass" is a lookup rather than a scroll through Bash output.
+// It knows go test, pytest and jest; a generic test script (npm test, make
+// test, ...) is matched against each runner's summary format in turn.
+package testruns
+
+import (
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// Runner names, as stored in agent_test_runs.runner.
+const (
+	RunnerGo     = "go"
+	RunnerPytest = "pytest"
+	RunnerJest   = "jest"
+)
+
+// Summary is one test run's outcome. For go test without -v, which prints
+// no per-test lines, the counts are of packages instead of tests.
+type Summary struct {
+	Runner  string
+	Passed  int64
+	Failed  int64
+	Skipped int64
+	// Coverage is the statement coverage percentage, valid when HasCoverage.
+	Coverage    float64
+	HasCoverage bool
+}
+
+var (
+	goTestCmd      = regexp.MustCompile(`(^|[\s;&|(])go\s+test(\s|$)`)
+	pytestCmd      = regexp.MustCompile(`(^|[\s;&|(/])(pytest|py\.test)(\s|$)|\bpython[\d.]*\s+-m\s+pytest(\s|$)`)
+	jestCmd        = regexp.MustCompile(`(^|[\s;&|(/])jest(\s|$)`)
+	testScriptCmd  = regexp.MustCompile(`\b(npm|yarn|pnpm|bun)\s+(run\s+)?test(\s|$)|\bmake\s+(\S+\s+)*test(\s|$)`)
+	goResultLine   = regexp.MustCompile(`^--- (PASS|FAIL|SKIP): `)
+	goPackageLine  = regexp.MustCompile(`^(ok|FAIL|\?)\s+\S+`)
+	goBuildFailed  = regexp.MustCompile(`^FAIL\s+\S+\s+\[(build|setup) failed\]`)
+	goCoverage     = regexp.MustCompile(`coverage: ([\d.]+)% of statements`)
+	pytestCounts   = regexp.MustCompile(`(\d+) (passed|failed|skipped|errors?|xfailed|xpassed)\b`)
+	pytestSummary  = regexp.MustCompile(`(?m)^=+ .*\b(passed|failed|skipped|errors?|no tests ran)\b.* in [\d.]+m?s.* =+$`)
+	pytestCoverage = regexp.MustCompile(`(?m)^TOTAL\s+.*?(\d+(?:\.\d+)?)%\s*$`)
+	jestTests      = regexp.MustCompile(`(?m)^Tests:\s+(.*\btotal)\s*$`)
+	jestCounts     = regexp.MustCompile(`(\d+) (passed|failed|skipped|todo)\b`)
+	jestCoverage   = regexp.MustCompile(`(?m)^All files\s*\|\s*([\d.]+)`)
+)
+
+// DetectRunner returns the runner command runs, "" for a generic test
+// script whose runner is only known from its output, and ok=false for a
+// command that runs no tests.
+func DetectRunner(command string) (runner string, ok bool) {
+	switch {
+	case goTestCmd.MatchString(command):
+		return RunnerGo, true
+	case pytestCmd.MatchString(command):
+		return RunnerPytest, true
+	case jestCmd.MatchString(command):
+		return RunnerJest, true
+	case testScriptCmd.MatchString(command):
+		return "", true
+	}
+	return "", false
+}
+
+// Parse reads the summary of a run of runner out of output. An empty runner
+// tries each known format. ok is false when output holds no summary, for
+// example a run that was killed before it printed one.
+func Parse(runner, output string) (Summary, bool) {
+	switch runner {
+	case RunnerGo:
+		return parseGo(output)
+	case RunnerPytest:
+		return parsePytest(output)
+	case RunnerJest:
+		return parseJest(output)
+	case "":
+		for _, parse := range []func(string) (Summary, bool){parseJest, parsePytest, parseGo} {
+			if s, ok := parse(output); ok {
+				return s, true
+			}
+		}
+	}
+	return Summary{}, false
+}
+
+func parseGo(output string) (Summary, bool) {
+	s := Summary{Runner: RunnerGo}
+	var tests, pkgs Summary
+	var buildFailures int64
+	var covSum float64
+	var covN int
+	for _, line := range strings.Split(output, "\n") {
+		line = strings.TrimRight(line, "\r")
+		// Subtests are indented; only top-level results count.
+		if m := goResultLine.FindStringSubmatch(line); m != nil {
+			countStatus(&tests, m[1])
+			continue
+		}
+		if m := goPackageLine.FindStringSubmatch(line); m != nil {
+			switch m[1] {
+			case "ok":
+				pkgs.Passed++
+			case "FAIL":
+				pkgs.Failed++
+				if goBuildFailed.MatchString(line) {
+					buildFailures++
+				}
+			case "?":
+				pkgs.Skipped++
+			}
+		}
+		if m := goCoverage.FindStringSubmatch(line); m != nil {
+			if v, err := strconv.ParseFloat(m[1], 64); err == nil {
+				covSum += v
+				covN++
+			}
+		}
+	}
+	counts := tests
+	if tests.Passed+tests.Failed+tests.Skipped == 0 {
+		counts = pkgs
+	} else {
+		// A package that fails to build reports no test results.
+		counts.Failed += buildFailures
+	}
+	if counts.Passed+counts.Failed+counts.Skipped == 0 {
+		return Summary{}, false
+	}
+	s.Passed, s.Failed, s.Skipped = counts.Passed, counts.Failed, counts.Skipped
+	if covN > 0 {
+		s.Coverage, s.HasCoverage = covSum/float64(covN), true
+	}
+	return s, true
+}
+
+func countStatus(s *Summary, status string) {
+	switch status {
+	case "PASS":
+		s.Passed++
+	case "FAIL":
+		s.Failed++
+	case "SKIP":
+		s.Skipped++
+	}
+}
+
+func parsePytest(output string) (Summary, bool) {
+	lines := pytestSummary.FindAllString(output, -1)
+	if len(lines) == 0 {
+		return Summary{}, false
+	}
+	s := Summary{Runner: RunnerPytest}
+	for _, m := range pytestCounts.FindAllStringSubmatch(lines[len(lines)-1], -1) {
+		n, _ := strconv.ParseInt(m[1], 10, 64)
+		switch m[2] {
+		case "passed", "xfailed":
+			s.Passed += n
+		case "failed", "error", "errors", "xpassed":
+			s.Failed += n
+		case "skipped":
+			s.Skipped += n
+		}
+	}
+	if m := pytestCoverage.FindAllStringSubmatch(output, -1); m != nil {
+		s.Coverage, s.HasCoverage = parsePercent(m[len(m)-1][1])
+	}
+	return s, true
+}
+
+func parseJest(output string) (Summary, bool) {
+	lines := jestTests.FindAllStringSubmatch(output, -1)
+	if len(lines) == 0 {
+		return Summary{}, false
+	}
+	s := Summary{Runner: RunnerJest}
+	for _, m := range jestCounts.FindAllStringSubmatch(lines[len(lines)-1][1], -1) {
+		n, _ := strconv.ParseInt(m[1], 10, 64)
+		switch m[2] {
+		case "passed":
+			s.Passed += n
+		case "failed":
+			s.Failed += n
+		case "skipped", "todo":
+			s.Skipped += n
+		}
+	}
+	if m := jestCoverage.FindAllStringSubmatch(output, -1); m != nil {
+		s.Coverage, s.HasCoverage = parsePercent(m[len(m)-1][1])
+	}
+	return s, true
+}
+
+func parsePercent(s string) (float64, bool) {
+	v, err := strconv.ParseFloat(s, 64)
+	return v, err == nil
+}
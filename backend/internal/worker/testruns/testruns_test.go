@@ -0,0 +1,103 @@
+package testruns
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestDetectRunner(t *testing.T) {
+	for _, tc := range []struct {
+		command string
+		runner  string
+		ok      bool
+	}{
+		{"go test ./...", RunnerGo, true},
+		{"cd backend && GOFLAGS= go test -run Foo ./internal/...", RunnerGo, true},
+		{"pytest -q tests/", RunnerPytest, true},
+		{"python3 -m pytest", RunnerPytest, true},
+		{".venv/bin/pytest", RunnerPytest, true},
+		{"npx jest --coverage", RunnerJest, true},
+		{"npm test", "", true},
+		{"pnpm run test -- --watch=false", "", true},
+		{"make lint test", "", true},
+		{"go build ./...", "", false},
+		{"cat pytest.ini", "", false},
+		{"ls jest.config.js", "", false},
+	} {
+		runner, ok := DetectRunner(tc.command)
+		assert.Equal(t, tc.ok, ok, tc.command)
+		assert.Equal(t, tc.runner, runner, tc.command)
+	}
+}
+
+func TestParse_GoVerbose(t *testing.T) {
+	out := `=== RUN   TestA
+--- PASS: TestA (0.00s)
+=== RUN   TestB
+    --- PASS: TestB/sub (0.00s)
+--- FAIL: TestB (0.00s)
+--- SKIP: TestC (0.00s)
+FAIL
+coverage: 80.0% of statements
+FAIL	example.com/a	0.01s
+FAIL	example.com/b [build failed]
+ok  	example.com/c	0.01s	coverage: 60.0% of statements
+`
+	s, ok := Parse(RunnerGo, out)
+	require.True(t, ok)
+	assert.Equal(t, Summary{Runner: RunnerGo, Passed: 1, Failed: 2, Skipped: 1, Coverage: 70, HasCoverage: true}, s)
+}
+
+func TestParse_GoPackages(t *testing.T) {
+	out := "ok  \texample.com/a\t0.01s\n?   \texample.com/b\t[no test files]\nFAIL\texample.com/c\t0.02s\nFAIL\n"
+	s, ok := Parse(RunnerGo, out)
+	require.True(t, ok)
+	assert.Equal(t, Summary{Runner: RunnerGo, Passed: 1, Failed: 1, Skipped: 1}, s)
+
+	_, ok = Parse(RunnerGo, "signal: killed\n")
+	assert.False(t, ok)
+}
+
+func TestParse_Pytest(t *testing.T) {
+	out := `tests/test_a.py ..F.s
+
+---------- coverage: platform linux, python 3.12 ----------
+Name      Stmts   Miss  Cover
+-----------------------------
+a.py         10      2    80%
+TOTAL        10      2    80%
+
+=========== 1 failed, 3 passed, 1 skipped, 1 error in 0.12s ===========
+`
+	s, ok := Parse(RunnerPytest, out)
+	require.True(t, ok)
+	assert.Equal(t, Summary{Runner: RunnerPytest, Passed: 3, Failed: 2, Skipped: 1, Coverage: 80, HasCoverage: true}, s)
+}
+
+func TestParse_JestThroughGenericScript(t *testing.T) {
+	out := `PASS src/a.test.ts
+FAIL src/b.test.ts
+----------|---------|----------|---------|---------|
+File      | % Stmts | % Branch | % Funcs | % Lines |
+----------|---------|----------|---------|---------|
+All files |   85.71 |       50 |     100 |   85.71 |
+Test Suites: 1 failed, 1 passed, 2 total
+Tests:       1 failed, 1 skipped, 5 passed, 7 total
+`
+	s, ok := Parse("", out)
+	require.True(t, ok)
+	assert.Equal(t, Summary{Runner: RunnerJest, Passed: 5, Failed: 1, Skipped: 1, Coverage: 85.71, HasCoverage: true}, s)
+
+	_, ok = Parse("", "> vitest run\nno summary here\n")
+	assert.False(t, ok)
+}
+
+func TestToolOutput(t *testing.T) {
+	assert.Equal(t, "ok\n", ToolOutput([]byte(`{"type":"user","message":{"content":[{"type":"tool_result","tool_use_id":"t","content":"ok"}]}}`)))
+	assert.Equal(t, "a\n", ToolOutput([]byte(`{"type":"user","message":{"content":[{"type":"tool_result","content":[{"type":"text","text":"a"}]}]}}`)))
+	assert.Equal(t, "out\nerr", ToolOutput([]byte(`{"type":"user","tool_use_result":{"stdout":"out","stderr":"err"},"message":{"content":[]}}`)))
+	assert.Equal(t, "codex", ToolOutput([]byte(`{"item":{"type":"commandExecution","aggregatedOutput":"codex"}}`)))
+	assert.Empty(t, ToolOutput([]byte(`not json`)))
+}
@@ -0,0 +1,99 @@
+package transcribe
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"mime/multipart"
+	"net/http"
+	"net/textproto"
+	"time"
+)
+
+// apiTimeout bounds one transcription request when the caller's context
+// carries no deadline of its own.
+const apiTimeout = 2 * time.Minute
+
+// maxAPIResponseBytes caps how much of a response body is read; a
+// transcript of a short clip is a few kilobytes.
+const maxAPIResponseBytes = 1 << 20
+
+// API posts the clip to an OpenAI-compatible transcription endpoint
+// (POST multipart/form-data with "file" and "model", JSON {"text": ...}
+// back).
+type API struct {
+	URL   string
+	Key   string
+	Model string
+
+	// Client defaults to an http.Client with apiTimeout.
+	Client *http.Client
+}
+
+func (a *API) Name() string { return BackendAPI }
+
+func (a *API) Transcribe(ctx context.Context, clip Clip) (string, error) {
+	var body bytes.Buffer
+	form := multipart.NewWriter(&body)
+	if err := form.WriteField("model", a.Model); err != nil {
+		return "", err
+	}
+	if err := form.WriteField("response_format", "json"); err != nil {
+		return "", err
+	}
+	filename := clip.Filename
+	if filename == "" {
+		filename = "clip" + clipExtension(clip)
+	}
+	header := make(textproto.MIMEHeader)
+	header.Set("Content-Disposition", fmt.Sprintf(`form-data; name="file"; filename=%q`, filename))
+	if clip.MimeType != "" {
+		header.Set("Content-Type", clip.MimeType)
+	}
+	part, err := form.CreatePart(header)
+	if err != nil {
+		return "", err
+	}
+	if _, err := part.Write(clip.Data); err != nil {
+		return "", err
+	}
+	if err := form.Close(); err != nil {
+		return "", err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, a.URL, &body)
+	if err != nil {
+		return "", fmt.Errorf("build transcription request: %w", err)
+	}
+	req.Header.Set("Content-Type", form.FormDataContentType())
+	if a.Key != "" {
+		req.Header.Set("Authorization", "Bearer "+a.Key)
+	}
+
+	client := a.Client
+	if client == nil {
+		client = &http.Client{Timeout: apiTimeout}
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return "", fmt.Errorf("transcription request: %w", err)
+	}
+	defer func() { _ = resp.Body.Close() }()
+	raw, err := io.ReadAll(io.LimitReader(resp.Body, maxAPIResponseBytes))
+	if err != nil {
+		return "", fmt.Errorf("read transcription response: %w", err)
+	}
+	if resp.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("transcription API returned %s", resp.Status)
+	}
+
+	var out struct {
+		Text string `json:"text"`
+	}
+	if err := json.Unmarshal(raw, &out); err != nil {
+		return "", fmt.Errorf("decode transcription response: %w", err)
+	}
+	return normalize(out.Text)
+}
@@ -0,0 +1,92 @@
+// Package transcribe turns short voice-note clips into prompt text. The
+// backend is pluggable: a local whisper.cpp binary keeps audio on the
+// worker, an OpenAI-compatible transcription API trades that for not
+// needing a model on disk.
+package transcribe
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// Backend names accepted by Config.Backend.
+const (
+	BackendWhisperCPP = "whisper-cpp"
+	BackendAPI        = "api"
+)
+
+// Clip is one recorded voice note.
+type Clip struct {
+	Filename string
+	MimeType string
+	Data     []byte
+}
+
+// Transcriber converts a clip to text.
+type Transcriber interface {
+	Transcribe(ctx context.Context, clip Clip) (string, error)
+	// Name identifies the backend in a voice note's provenance.
+	Name() string
+}
+
+// Config selects and configures a backend. An empty Backend disables
+// transcription.
+type Config struct {
+	Backend string
+
+	WhisperBinary string // whisper.cpp CLI; defaults to "whisper-cli" on PATH
+	WhisperModel  string // ggml model file, required for whisper-cpp
+
+	APIURL   string // transcription endpoint, required for api
+	APIKey   string // sent as a bearer token when set
+	APIModel string // model form field; defaults to "whisper-1"
+}
+
+// ErrEmptyTranscript is returned when a backend ran but heard no speech.
+var ErrEmptyTranscript = errors.New("transcript is empty")
+
+// New builds the configured Transcriber, or nil when transcription is
+// disabled.
+func New(cfg Config) (Transcriber, error) {
+	switch strings.TrimSpace(cfg.Backend) {
+	case "":
+		return nil, nil
+	case BackendWhisperCPP:
+		if cfg.WhisperModel == "" {
+			return nil, errors.New("whisper-cpp backend needs a model file")
+		}
+		binary := cfg.WhisperBinary
+		if binary == "" {
+			binary = "whisper-cli"
+		}
+		return &WhisperCPP{Binary: binary, Model: cfg.WhisperModel}, nil
+	case BackendAPI:
+		if cfg.APIURL == "" {
+			return nil, errors.New("api backend needs a URL")
+		}
+		model := cfg.APIModel
+		if model == "" {
+			model = "whisper-1"
+		}
+		return &API{URL: cfg.APIURL, Key: cfg.APIKey, Model: model}, nil
+	default:
+		return nil, fmt.Errorf("unknown transcription backend %q", cfg.Backend)
+	}
+}
+
+// normalize joins the non-blank lines of a backend's raw output with
+// spaces, and reports an empty result as ErrEmptyTranscript.
+func normalize(raw string) (string, error) {
+	var lines []string
+	for _, line := range strings.Split(raw, "\n") {
+		if line = strings.TrimSpace(line); line != "" {
+			lines = append(lines, line)
+		}
+	}
+	if len(lines) == 0 {
+		return "", ErrEmptyTranscript
+	}
+	return strings.Join(lines, " "), nil
+}
@@ -0,0 +1,122 @@
+package transcribe
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"runtime"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestNew(t *testing.T) {
+	tr, err := New(Config{})
+	require.NoError(t, err)
+	assert.Nil(t, tr, "empty backend disables transcription")
+
+	_, err = New(Config{Backend: BackendWhisperCPP})
+	assert.Error(t, err, "whisper-cpp without a model")
+
+	tr, err = New(Config{Backend: BackendWhisperCPP, WhisperModel: "m.bin"})
+	require.NoError(t, err)
+	assert.Equal(t, &WhisperCPP{Binary: "whisper-cli", Model: "m.bin"}, tr)
+
+	_, err = New(Config{Backend: BackendAPI})
+	assert.Error(t, err, "api without a URL")
+
+	tr, err = New(Config{Backend: BackendAPI, APIURL: "http://x"})
+	require.NoError(t, err)
+	assert.Equal(t, "whisper-1", tr.(*API).Model)
+
+	_, err = New(Config{Backend: "vosk"})
+	assert.Error(t, err)
+}
+
+func TestNormalize(t *testing.T) {
+	got, err := normalize("\n  Hello there.\n\n General Kenobi. \n")
+	require.NoError(t, err)
+	assert.Equal(t, "Hello there. General Kenobi.", got)
+
+	_, err = normalize(" \n\t\n")
+	assert.ErrorIs(t, err, ErrEmptyTranscript)
+}
+
+func TestClipExtension(t *testing.T) {
+	assert.Equal(t, ".mp3", clipExtension(Clip{Filename: "dir/Note.MP3", MimeType: "audio/wav"}))
+	assert.Equal(t, ".flac", clipExtension(Clip{MimeType: "audio/flac"}))
+	assert.Equal(t, ".webm", clipExtension(Clip{MimeType: "audio/webm;codecs=opus"}))
+	assert.Equal(t, ".wav", clipExtension(Clip{}))
+}
+
+func TestWhisperCPP_Transcribe(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("fake whisper binary is a shell script")
+	}
+	dir := t.TempDir()
+	bin := filepath.Join(dir, "whisper-cli")
+	// Echo the arguments so the test can check them, then the transcript.
+	script := "#!/bin/sh\necho \"$@\" >&2\necho \"$@\" > \"" + filepath.Join(dir, "args") + "\"\n" +
+		"echo\necho ' Fix the build. '\necho 'Then run tests.'\n"
+	require.NoError(t, os.WriteFile(bin, []byte(script), 0o755))
+
+	w := &WhisperCPP{Binary: bin, Model: "ggml-base.bin"}
+	got, err := w.Transcribe(context.Background(), Clip{Filename: "note.wav", Data: []byte("RIFF")})
+	require.NoError(t, err)
+	assert.Equal(t, "Fix the build. Then run tests.", got)
+
+	args, err := os.ReadFile(filepath.Join(dir, "args"))
+	require.NoError(t, err)
+	assert.Contains(t, string(args), "-m ggml-base.bin -f ")
+	assert.Contains(t, string(args), "clip.wav -nt -np")
+}
+
+func TestWhisperCPP_Failure(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("fake whisper binary is a shell script")
+	}
+	bin := filepath.Join(t.TempDir(), "whisper-cli")
+	require.NoError(t, os.WriteFile(bin, []byte("#!/bin/sh\necho loading >&2\necho 'failed to read audio' >&2\nexit 3\n"), 0o755))
+
+	_, err := (&WhisperCPP{Binary: bin, Model: "m"}).Transcribe(context.Background(), Clip{Data: []byte("x")})
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "failed to read audio")
+	assert.NotContains(t, err.Error(), "loading")
+}
+
+func TestAPI_Transcribe(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "Bearer sk-test", r.Header.Get("Authorization"))
+		require.NoError(t, r.ParseMultipartForm(1<<20))
+		assert.Equal(t, "whisper-1", r.FormValue("model"))
+		f, hdr, err := r.FormFile("file")
+		require.NoError(t, err)
+		data, _ := io.ReadAll(f)
+		assert.Equal(t, "note.webm", hdr.Filename)
+		assert.Equal(t, "audio/webm", hdr.Header.Get("Content-Type"))
+		assert.Equal(t, "OggS", string(data))
+		_ = json.NewEncoder(w).Encode(map[string]string{"text": " Ship it.\n"})
+	}))
+	defer srv.Close()
+
+	a := &API{URL: srv.URL, Key: "sk-test", Model: "whisper-1"}
+	got, err := a.Transcribe(context.Background(), Clip{Filename: "note.webm", MimeType: "audio/webm", Data: []byte("OggS")})
+	require.NoError(t, err)
+	assert.Equal(t, "Ship it.", got)
+}
+
+func TestAPI_ErrorStatus(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.Error(w, "bad key", http.StatusUnauthorized)
+	}))
+	defer srv.Close()
+
+	_, err := (&API{URL: srv.URL, Model: "m"}).Transcribe(context.Background(), Clip{Data: []byte("x")})
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "401")
+}
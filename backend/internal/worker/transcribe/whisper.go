@@ -0,0 +1,84 @@
+package transcribe
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+
+	"github.com/leapmux/leapmux/util/procutil"
+)
+
+// WhisperCPP runs a local whisper.cpp CLI over the clip. The binary must
+// be able to decode the clip's format; whisper.cpp reads WAV, MP3 and
+// FLAC natively, so clients record in one of those.
+type WhisperCPP struct {
+	Binary string
+	Model  string
+}
+
+func (w *WhisperCPP) Name() string { return BackendWhisperCPP }
+
+func (w *WhisperCPP) Transcribe(ctx context.Context, clip Clip) (string, error) {
+	dir, err := os.MkdirTemp("", "leapmux-voice-*")
+	if err != nil {
+		return "", fmt.Errorf("create temp dir: %w", err)
+	}
+	defer func() { _ = os.RemoveAll(dir) }()
+
+	input := filepath.Join(dir, "clip"+clipExtension(clip))
+	if err := os.WriteFile(input, clip.Data, 0o600); err != nil {
+		return "", fmt.Errorf("write clip: %w", err)
+	}
+
+	// -nt drops the timestamp prefixes and -np the progress and model
+	// banners, so stdout carries only the transcript.
+	cmd := exec.CommandContext(ctx, w.Binary, "-m", w.Model, "-f", input, "-nt", "-np")
+	cmd.Stdin = nil
+	var stdout, stderr bytes.Buffer
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+	procutil.HideConsoleWindow(cmd)
+	if err := cmd.Run(); err != nil {
+		if msg := strings.TrimSpace(stderr.String()); msg != "" {
+			return "", fmt.Errorf("whisper.cpp: %w: %s", err, lastLine(msg))
+		}
+		return "", fmt.Errorf("whisper.cpp: %w", err)
+	}
+	return normalize(stdout.String())
+}
+
+// clipExtension picks the temp file's extension, which whisper.cpp uses to
+// choose a decoder: the uploaded filename's when it has one, else one
+// derived from the MIME type.
+func clipExtension(clip Clip) string {
+	if ext := filepath.Ext(clip.Filename); len(ext) > 1 {
+		return strings.ToLower(ext)
+	}
+	switch strings.ToLower(strings.TrimSpace(strings.SplitN(clip.MimeType, ";", 2)[0])) {
+	case "audio/mpeg", "audio/mp3":
+		return ".mp3"
+	case "audio/flac", "audio/x-flac":
+		return ".flac"
+	case "audio/ogg":
+		return ".ogg"
+	case "audio/webm":
+		return ".webm"
+	case "audio/mp4", "audio/m4a", "audio/x-m4a":
+		return ".m4a"
+	default:
+		return ".wav"
+	}
+}
+
+// lastLine keeps an error readable: whisper.cpp logs its whole model load
+// to stderr before the line that says what went wrong.
+func lastLine(s string) string {
+	if i := strings.LastIndexByte(s, '\n'); i >= 0 {
+		return s[i+1:]
+	}
+	return s
+}
@@ -0,0 +1,244 @@
+package tunnel
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"sync"
+
+	leapmuxv1 "github.com/leapmux/leapmux/generated/proto/leapmux/v1"
+	"github.com/leapmux/leapmux/internal/tunnelflow"
+	"github.com/leapmux/leapmux/internal/util/id"
+	"google.golang.org/protobuf/proto"
+)
+
+// Download is an io.ReadCloser over a worker file streamed with DownloadFile.
+//
+// It is a sub-stream of the channel rather than a series of ReadFile pages: the
+// worker pushes chunks as fast as the client drains them, bounded by the same
+// read-credit window a tunnel Conn uses, so a large transfer neither pays a
+// round trip per 60 KB nor pins more than the window in either process. Every
+// other RPC and tunnel on the channel keeps flowing while it runs.
+type Download struct {
+	ch         tunnelRPCChannel
+	downloadID string
+	reqID      uint64
+	path       string
+	totalSize  int64
+
+	readMu   sync.Mutex
+	readBuf  chan []byte
+	readPart []byte
+	// terminal latches the end of the stream: io.EOF after the last chunk, or
+	// the error that ended it.
+	terminal latchedErr
+	credit   *readCredit
+
+	closeOnce sync.Once
+	closed    chan struct{}
+}
+
+// DownloadFile starts downloading path from the channel's worker at offset.
+// ctx bounds only the open; the returned Download lives until Close or the
+// channel ends.
+func DownloadFile(ctx context.Context, ch *Channel, path string, offset int64) (*Download, error) {
+	if ch == nil {
+		return nil, errors.New("download file: channel is required")
+	}
+	return downloadFile(ctx, ch, path, offset)
+}
+
+func downloadFile(ctx context.Context, ch tunnelRPCChannel, path string, offset int64) (*Download, error) {
+	d := &Download{
+		ch:         ch,
+		downloadID: id.Generate(),
+		readBuf:    make(chan []byte, tunnelflow.ReadBufFrames),
+		closed:     make(chan struct{}),
+	}
+	payload, err := proto.Marshal(&leapmuxv1.DownloadFileRequest{
+		Path:       path,
+		DownloadId: d.downloadID,
+		Offset:     offset,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("marshal: %w", err)
+	}
+	d.credit = newReadCredit(ch.Context(), tunnelflow.ReadCreditBatch, d.sendCredit)
+
+	respCh := make(chan *leapmuxv1.InnerRpcResponse, 1)
+	reqID, err := ch.SendRPCNoWait(ctx, "DownloadFile", payload, RPCHandlers{
+		Response: respCh,
+		Stream:   d.onStreamMessage,
+	})
+	if err != nil {
+		d.credit.stop()
+		return nil, fmt.Errorf("send: %w", err)
+	}
+	d.reqID = reqID
+
+	select {
+	case resp := <-respCh:
+		ch.UnregisterPending(reqID)
+		if resp.GetIsError() {
+			d.unregister()
+			return nil, fmt.Errorf("rpc error (code %d): %s", resp.GetErrorCode(), resp.GetErrorMessage())
+		}
+		var openResp leapmuxv1.DownloadFileResponse
+		if err := proto.Unmarshal(resp.GetPayload(), &openResp); err != nil {
+			_ = d.Close()
+			return nil, fmt.Errorf("unmarshal response: %w", err)
+		}
+		d.path = openResp.GetPath()
+		d.totalSize = openResp.GetTotalSize()
+		return d, nil
+	case <-ctx.Done():
+		_ = d.Close()
+		return nil, ctx.Err()
+	case <-ch.Context().Done():
+		d.unregister()
+		return nil, ch.Context().Err()
+	}
+}
+
+// Path is the file's resolved path on the worker.
+func (d *Download) Path() string { return d.path }
+
+// TotalSize is the file's size when the download opened.
+func (d *Download) TotalSize() int64 { return d.totalSize }
+
+func (d *Download) onStreamMessage(msg *leapmuxv1.InnerStreamMessage) {
+	select {
+	case <-d.closed:
+		return
+	case <-d.terminal.Done():
+		return
+	default:
+	}
+	if msg.GetIsError() {
+		d.terminal.Set(fmt.Errorf("download stream: %s", msg.GetErrorMessage()))
+		return
+	}
+	var chunk leapmuxv1.FileDownloadChunk
+	if err := proto.Unmarshal(msg.GetPayload(), &chunk); err != nil {
+		d.terminal.Set(fmt.Errorf("decode download chunk: %w", err))
+		return
+	}
+	switch {
+	case chunk.GetError() != "":
+		d.terminal.Set(fmt.Errorf("download: %s", chunk.GetError()))
+		return
+	case len(chunk.GetData()) > tunnelflow.MaxChunkBytes:
+		// The window is a byte bound only while chunks respect the chunk bound;
+		// enforce it on receipt as Conn does rather than trust the worker.
+		d.terminal.Set(fmt.Errorf("download chunk is %d bytes, exceeding the %d-byte chunk bound",
+			len(chunk.GetData()), tunnelflow.MaxChunkBytes))
+		return
+	case len(chunk.GetData()) > 0:
+		select {
+		case d.readBuf <- chunk.GetData():
+		case <-d.closed:
+			return
+		case <-d.ch.Context().Done():
+			return
+		}
+	}
+	if chunk.GetEof() {
+		d.terminal.Set(io.EOF)
+	}
+}
+
+// Read implements io.Reader. It returns io.EOF once every chunk before the
+// worker's eof marker has been read.
+func (d *Download) Read(b []byte) (int, error) {
+	if len(b) == 0 {
+		return 0, nil
+	}
+	d.readMu.Lock()
+	defer d.readMu.Unlock()
+	for {
+		select {
+		case <-d.closed:
+			return 0, io.ErrClosedPipe
+		default:
+		}
+		if len(d.readPart) > 0 {
+			n := copy(b, d.readPart)
+			d.readPart = d.readPart[n:]
+			return n, nil
+		}
+		// Drain buffered chunks before reporting the terminal condition: the eof
+		// marker is latched as soon as it arrives, ahead of chunks still queued.
+		select {
+		case data := <-d.readBuf:
+			return d.consume(b, data), nil
+		default:
+		}
+		select {
+		case data := <-d.readBuf:
+			return d.consume(b, data), nil
+		case <-d.terminal.Done():
+			if len(d.readBuf) > 0 {
+				continue
+			}
+			return 0, d.terminal.Err()
+		case <-d.closed:
+			return 0, io.ErrClosedPipe
+		case <-d.ch.Context().Done():
+			d.terminal.Set(io.ErrUnexpectedEOF)
+		}
+	}
+}
+
+func (d *Download) consume(b, data []byte) int {
+	n := copy(b, data)
+	if n < len(data) {
+		d.readPart = data[n:]
+	}
+	d.credit.consume(1)
+	return n
+}
+
+// sendCredit grants the worker more chunks. Only the readCredit loop calls it.
+func (d *Download) sendCredit(ctx context.Context, credit uint64) {
+	payload, err := proto.Marshal(&leapmuxv1.GrantDownloadCreditRequest{DownloadId: d.downloadID, Credit: credit})
+	if err != nil {
+		return
+	}
+	_, _ = d.ch.SendRPCNoWait(ctx, "GrantDownloadCredit", payload, RPCHandlers{})
+}
+
+func (d *Download) unregister() {
+	d.credit.stop()
+	d.ch.UnregisterPending(d.reqID)
+	d.ch.UnregisterStream(d.reqID)
+}
+
+// Close stops the download. Closing before io.EOF tells the worker to release
+// the file; closing after is a local cleanup only.
+func (d *Download) Close() error {
+	d.closeOnce.Do(func() {
+		close(d.closed)
+		d.unregister()
+		if errors.Is(d.terminal.Err(), io.EOF) {
+			return
+		}
+		go d.sendCancel()
+	})
+	return nil
+}
+
+// sendCancel is best effort: a lost cancel leaves the worker's read loop parked
+// on credit until the channel ends, which also closes the file.
+func (d *Download) sendCancel() {
+	ctx, cancel := context.WithTimeout(context.Background(), remoteCloseSendBudget)
+	defer cancel()
+	payload, err := proto.Marshal(&leapmuxv1.CancelDownloadRequest{DownloadId: d.downloadID})
+	if err != nil {
+		return
+	}
+	if _, err := d.ch.SendRPCNoWait(ctx, "CancelDownload", payload, RPCHandlers{}); err != nil {
+		slog.Warn("download cancel not sent", "download_id", d.downloadID, "error", err)
+	}
+}
@@ -0,0 +1,179 @@
+package tunnel
+
+import (
+	"context"
+	"io"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/protobuf/proto"
+
+	leapmuxv1 "github.com/leapmux/leapmux/generated/proto/leapmux/v1"
+	"github.com/leapmux/leapmux/internal/tunnelflow"
+)
+
+// downloadWorkerChannel answers DownloadFile with openResp (or openErr) and
+// records every later call, leaving the test to push chunks through stream.
+type downloadWorkerChannel struct {
+	ctx      context.Context
+	openResp *leapmuxv1.DownloadFileResponse
+	openErr  string
+
+	mu      sync.Mutex
+	stream  func(*leapmuxv1.InnerStreamMessage)
+	methods []string
+	granted uint64
+}
+
+func (c *downloadWorkerChannel) Context() context.Context { return c.ctx }
+func (*downloadWorkerChannel) UnregisterPending(uint64)   {}
+func (*downloadWorkerChannel) UnregisterStream(uint64)    {}
+func (c *downloadWorkerChannel) SendRPCNoWait(_ context.Context, method string, payload []byte, handlers RPCHandlers) (uint64, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.methods = append(c.methods, method)
+	switch method {
+	case "DownloadFile":
+		c.stream = handlers.Stream
+		if c.openErr != "" {
+			handlers.Response <- &leapmuxv1.InnerRpcResponse{IsError: true, ErrorCode: 5, ErrorMessage: c.openErr}
+			break
+		}
+		payload, _ := proto.Marshal(c.openResp)
+		handlers.Response <- &leapmuxv1.InnerRpcResponse{Payload: payload}
+	case "GrantDownloadCredit":
+		var r leapmuxv1.GrantDownloadCreditRequest
+		if err := proto.Unmarshal(payload, &r); err == nil {
+			c.granted += r.GetCredit()
+		}
+	}
+	return 1, nil
+}
+
+func (c *downloadWorkerChannel) push(t *testing.T, chunk *leapmuxv1.FileDownloadChunk) {
+	t.Helper()
+	payload, err := proto.Marshal(chunk)
+	require.NoError(t, err)
+	c.mu.Lock()
+	stream := c.stream
+	c.mu.Unlock()
+	stream(&leapmuxv1.InnerStreamMessage{Payload: payload})
+}
+
+func (c *downloadWorkerChannel) called(method string) bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	for _, m := range c.methods {
+		if m == method {
+			return true
+		}
+	}
+	return false
+}
+
+func (c *downloadWorkerChannel) totalGranted() uint64 {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.granted
+}
+
+func newDownloadWorkerChannel(t *testing.T) *downloadWorkerChannel {
+	ctx, cancel := context.WithCancel(context.Background())
+	t.Cleanup(cancel)
+	return &downloadWorkerChannel{
+		ctx:      ctx,
+		openResp: &leapmuxv1.DownloadFileResponse{Path: "/home/u/f.bin", TotalSize: 11},
+	}
+}
+
+func TestDownload_ReadsChunksThenEOF(t *testing.T) {
+	ch := newDownloadWorkerChannel(t)
+	d, err := downloadFile(context.Background(), ch, "f.bin", 0)
+	require.NoError(t, err)
+	assert.Equal(t, "/home/u/f.bin", d.Path())
+	assert.EqualValues(t, 11, d.TotalSize())
+
+	ch.push(t, &leapmuxv1.FileDownloadChunk{Data: []byte("hello, ")})
+	ch.push(t, &leapmuxv1.FileDownloadChunk{Data: []byte("world")})
+	ch.push(t, &leapmuxv1.FileDownloadChunk{Eof: true})
+
+	got, err := io.ReadAll(d)
+	require.NoError(t, err)
+	assert.Equal(t, "hello, world", string(got))
+
+	require.NoError(t, d.Close())
+	assert.False(t, ch.called("CancelDownload"), "a finished download has nothing to cancel")
+}
+
+func TestDownload_GrantsCreditAsItDrains(t *testing.T) {
+	ch := newDownloadWorkerChannel(t)
+	d, err := downloadFile(context.Background(), ch, "f.bin", 0)
+	require.NoError(t, err)
+	defer func() { _ = d.Close() }()
+
+	for range tunnelflow.ReadCreditBatch {
+		ch.push(t, &leapmuxv1.FileDownloadChunk{Data: []byte("x")})
+	}
+	buf := make([]byte, 1)
+	for range tunnelflow.ReadCreditBatch {
+		_, err := d.Read(buf)
+		require.NoError(t, err)
+	}
+	assert.Eventually(t, func() bool { return ch.totalGranted() == tunnelflow.ReadCreditBatch }, time.Second, 5*time.Millisecond)
+}
+
+func TestDownload_CloseBeforeEOFCancels(t *testing.T) {
+	ch := newDownloadWorkerChannel(t)
+	d, err := downloadFile(context.Background(), ch, "f.bin", 0)
+	require.NoError(t, err)
+	ch.push(t, &leapmuxv1.FileDownloadChunk{Data: []byte("partial")})
+
+	require.NoError(t, d.Close())
+	assert.Eventually(t, func() bool { return ch.called("CancelDownload") }, time.Second, 5*time.Millisecond)
+	_, err = d.Read(make([]byte, 8))
+	assert.ErrorIs(t, err, io.ErrClosedPipe)
+}
+
+func TestDownload_SurfacesErrors(t *testing.T) {
+	t.Run("open refused", func(t *testing.T) {
+		ch := newDownloadWorkerChannel(t)
+		ch.openErr = "file not found"
+		_, err := downloadFile(context.Background(), ch, "missing", 0)
+		assert.ErrorContains(t, err, "file not found")
+	})
+
+	t.Run("worker read error", func(t *testing.T) {
+		ch := newDownloadWorkerChannel(t)
+		d, err := downloadFile(context.Background(), ch, "f.bin", 0)
+		require.NoError(t, err)
+		defer func() { _ = d.Close() }()
+		ch.push(t, &leapmuxv1.FileDownloadChunk{Error: "failed to read file"})
+		_, err = io.ReadAll(d)
+		assert.ErrorContains(t, err, "failed to read file")
+	})
+
+	t.Run("oversize chunk", func(t *testing.T) {
+		ch := newDownloadWorkerChannel(t)
+		d, err := downloadFile(context.Background(), ch, "f.bin", 0)
+		require.NoError(t, err)
+		defer func() { _ = d.Close() }()
+		ch.push(t, &leapmuxv1.FileDownloadChunk{Data: make([]byte, tunnelflow.MaxChunkBytes+1)})
+		_, err = io.ReadAll(d)
+		assert.ErrorContains(t, err, "chunk bound")
+	})
+
+	t.Run("channel ends mid-download", func(t *testing.T) {
+		ctx, cancel := context.WithCancel(context.Background())
+		ch := newDownloadWorkerChannel(t)
+		ch.ctx = ctx
+		d, err := downloadFile(context.Background(), ch, "f.bin", 0)
+		require.NoError(t, err)
+		defer func() { _ = d.Close() }()
+		cancel()
+		_, err = io.ReadAll(d)
+		assert.ErrorIs(t, err, io.ErrUnexpectedEOF)
+	})
+}
@@ -0,0 +1,167 @@
+package tunnel
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"time"
+
+	leapmuxv1 "github.com/leapmux/leapmux/generated/proto/leapmux/v1"
+	"github.com/leapmux/leapmux/internal/util/id"
+	"google.golang.org/protobuf/proto"
+)
+
+// execCancelGrace is how long ExecCommand waits, after its context ends and
+// it asks the worker to kill the command, for the command's final chunk.
+var execCancelGrace = 5 * time.Second
+
+// ExecResult is how a command run with ExecCommand ended.
+type ExecResult struct {
+	// WorkingDir is the resolved directory the command ran in.
+	WorkingDir string
+	// ExitCode is the command's exit status, -1 when it was killed.
+	ExitCode int32
+	// TimedOut is set when the worker killed the command at its timeout.
+	TimedOut bool
+	// Truncated is set when the command wrote more than the worker streams
+	// back; the excess was dropped.
+	Truncated bool
+}
+
+// ExecCommand runs req's command on the channel's worker, copying its
+// output to stdout and stderr as it arrives, and returns once it exits.
+// When ctx ends first the command is killed on the worker and ctx's error
+// returned. req's exec_id is filled in when empty.
+func ExecCommand(ctx context.Context, ch *Channel, req *leapmuxv1.ExecCommandRequest, stdout, stderr io.Writer) (*ExecResult, error) {
+	if ch == nil {
+		return nil, errors.New("exec command: channel is required")
+	}
+	return execCommand(ctx, ch, req, stdout, stderr)
+}
+
+func execCommand(ctx context.Context, ch tunnelRPCChannel, req *leapmuxv1.ExecCommandRequest, stdout, stderr io.Writer) (*ExecResult, error) {
+	req = proto.Clone(req).(*leapmuxv1.ExecCommandRequest)
+	if req.GetExecId() == "" {
+		req.ExecId = id.Generate()
+	}
+	payload, err := proto.Marshal(req)
+	if err != nil {
+		return nil, fmt.Errorf("marshal: %w", err)
+	}
+
+	// The worker bounds a command's output, so the queue between the
+	// channel's reader and the copy below is bounded by it too.
+	chunks := make(chan *leapmuxv1.ExecOutputChunk, 64)
+	streamErr := make(chan error, 1)
+	done := make(chan struct{})
+	defer close(done)
+	respCh := make(chan *leapmuxv1.InnerRpcResponse, 1)
+	reqID, err := ch.SendRPCNoWait(ctx, "ExecCommand", payload, RPCHandlers{
+		Response: respCh,
+		Stream: func(msg *leapmuxv1.InnerStreamMessage) {
+			var chunk leapmuxv1.ExecOutputChunk
+			var failed error
+			if msg.GetIsError() {
+				failed = fmt.Errorf("exec stream: %s", msg.GetErrorMessage())
+			} else if err := proto.Unmarshal(msg.GetPayload(), &chunk); err != nil {
+				failed = fmt.Errorf("decode exec chunk: %w", err)
+			} else {
+				select {
+				case chunks <- &chunk:
+				case <-done:
+				}
+				return
+			}
+			select {
+			case streamErr <- failed:
+			default:
+			}
+		},
+	})
+	if err != nil {
+		return nil, fmt.Errorf("send: %w", err)
+	}
+	defer func() {
+		ch.UnregisterPending(reqID)
+		ch.UnregisterStream(reqID)
+	}()
+
+	result := &ExecResult{}
+	select {
+	case resp := <-respCh:
+		if resp.GetIsError() {
+			return nil, fmt.Errorf("rpc error (code %d): %s", resp.GetErrorCode(), resp.GetErrorMessage())
+		}
+		var startResp leapmuxv1.ExecCommandResponse
+		if err := proto.Unmarshal(resp.GetPayload(), &startResp); err != nil {
+			sendCancelExec(ch, req.GetExecId())
+			return nil, fmt.Errorf("unmarshal response: %w", err)
+		}
+		result.WorkingDir = startResp.GetWorkingDir()
+	case <-ctx.Done():
+		sendCancelExec(ch, req.GetExecId())
+		return nil, ctx.Err()
+	case <-ch.Context().Done():
+		return nil, ch.Context().Err()
+	}
+
+	ctxDone := ctx.Done()
+	var grace <-chan time.Time
+	for {
+		select {
+		case chunk := <-chunks:
+			if err := writeExecChunk(chunk, stdout, stderr); err != nil {
+				sendCancelExec(ch, req.GetExecId())
+				return nil, err
+			}
+			if !chunk.GetExited() {
+				continue
+			}
+			if ctx.Err() != nil {
+				return nil, ctx.Err()
+			}
+			result.ExitCode = chunk.GetExitCode()
+			result.TimedOut = chunk.GetTimedOut()
+			result.Truncated = chunk.GetTruncated()
+			return result, nil
+		case err := <-streamErr:
+			sendCancelExec(ch, req.GetExecId())
+			return nil, err
+		case <-ctxDone:
+			// Keep copying until the killed command's last output is in.
+			ctxDone = nil
+			sendCancelExec(ch, req.GetExecId())
+			grace = time.After(execCancelGrace)
+		case <-grace:
+			return nil, ctx.Err()
+		case <-ch.Context().Done():
+			return nil, ch.Context().Err()
+		}
+	}
+}
+
+func writeExecChunk(chunk *leapmuxv1.ExecOutputChunk, stdout, stderr io.Writer) error {
+	if _, err := stdout.Write(chunk.GetStdout()); err != nil {
+		return fmt.Errorf("write stdout: %w", err)
+	}
+	if _, err := stderr.Write(chunk.GetStderr()); err != nil {
+		return fmt.Errorf("write stderr: %w", err)
+	}
+	return nil
+}
+
+// sendCancelExec is best effort: a lost cancel leaves the command to run to
+// its timeout.
+func sendCancelExec(ch tunnelRPCChannel, execID string) {
+	ctx, cancel := context.WithTimeout(context.Background(), remoteCloseSendBudget)
+	defer cancel()
+	payload, err := proto.Marshal(&leapmuxv1.CancelExecRequest{ExecId: execID})
+	if err != nil {
+		return
+	}
+	if _, err := ch.SendRPCNoWait(ctx, "CancelExec", payload, RPCHandlers{}); err != nil {
+		slog.Warn("exec cancel not sent", "exec_id", execID, "error", err)
+	}
+}
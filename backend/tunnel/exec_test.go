@@ -0,0 +1,92 @@
+package tunnel
+
+import (
+	"bytes"
+	"context"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/protobuf/proto"
+
+	leapmuxv1 "github.com/leapmux/leapmux/generated/proto/leapmux/v1"
+)
+
+// execWorkerChannel answers ExecCommand and then replays chunks on its
+// stream, or refuses with startErr. It records every CancelExec.
+type execWorkerChannel struct {
+	ctx      context.Context
+	startErr string
+	chunks   []*leapmuxv1.ExecOutputChunk
+
+	mu       sync.Mutex
+	canceled []string
+}
+
+func (c *execWorkerChannel) Context() context.Context { return c.ctx }
+func (*execWorkerChannel) UnregisterPending(uint64)   {}
+func (*execWorkerChannel) UnregisterStream(uint64)    {}
+func (c *execWorkerChannel) SendRPCNoWait(_ context.Context, method string, payload []byte, handlers RPCHandlers) (uint64, error) {
+	switch method {
+	case "ExecCommand":
+		if c.startErr != "" {
+			handlers.Response <- &leapmuxv1.InnerRpcResponse{IsError: true, ErrorCode: 3, ErrorMessage: c.startErr}
+			break
+		}
+		resp, _ := proto.Marshal(&leapmuxv1.ExecCommandResponse{WorkingDir: "/home/u"})
+		handlers.Response <- &leapmuxv1.InnerRpcResponse{Payload: resp}
+		go func() {
+			for _, chunk := range c.chunks {
+				payload, _ := proto.Marshal(chunk)
+				handlers.Stream(&leapmuxv1.InnerStreamMessage{Payload: payload})
+			}
+		}()
+	case "CancelExec":
+		var r leapmuxv1.CancelExecRequest
+		_ = proto.Unmarshal(payload, &r)
+		c.mu.Lock()
+		c.canceled = append(c.canceled, r.GetExecId())
+		c.mu.Unlock()
+	}
+	return 1, nil
+}
+
+func TestExecCommand_CopiesOutputUntilExit(t *testing.T) {
+	ch := &execWorkerChannel{ctx: context.Background(), chunks: []*leapmuxv1.ExecOutputChunk{
+		{Stdout: []byte("hello ")},
+		{Stderr: []byte("warning\n")},
+		{Stdout: []byte("world\n")},
+		{Exited: true, ExitCode: 2, Truncated: true},
+	}}
+	var stdout, stderr bytes.Buffer
+	res, err := execCommand(context.Background(), ch, &leapmuxv1.ExecCommandRequest{Argv: []string{"make"}}, &stdout, &stderr)
+	require.NoError(t, err)
+	assert.Equal(t, "hello world\n", stdout.String())
+	assert.Equal(t, "warning\n", stderr.String())
+	assert.Equal(t, &ExecResult{WorkingDir: "/home/u", ExitCode: 2, Truncated: true}, res)
+}
+
+func TestExecCommand_StartRefused(t *testing.T) {
+	ch := &execWorkerChannel{ctx: context.Background(), startErr: "argv is required"}
+	_, err := execCommand(context.Background(), ch, &leapmuxv1.ExecCommandRequest{}, &bytes.Buffer{}, &bytes.Buffer{})
+	assert.ErrorContains(t, err, "argv is required")
+}
+
+func TestExecCommand_ContextCancelKillsCommand(t *testing.T) {
+	old := execCancelGrace
+	execCancelGrace = 20 * time.Millisecond
+	t.Cleanup(func() { execCancelGrace = old })
+
+	// The worker never reports an exit; the client gives up after asking it
+	// to kill the command.
+	ch := &execWorkerChannel{ctx: context.Background()}
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	_, err := execCommand(ctx, ch, &leapmuxv1.ExecCommandRequest{ExecId: "e1", Argv: []string{"sleep", "60"}}, &bytes.Buffer{}, &bytes.Buffer{})
+	assert.ErrorIs(t, err, context.DeadlineExceeded)
+	ch.mu.Lock()
+	defer ch.mu.Unlock()
+	assert.Equal(t, []string{"e1"}, ch.canceled)
+}
@@ -0,0 +1,38 @@
+package validate
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+const (
+	// MaxEnvVars caps the environment variables one layer (org, workspace or
+	// agent) may set.
+	MaxEnvVars = 100
+	// MaxEnvVarValueLen caps an environment variable's value.
+	MaxEnvVarValueLen = 4 << 10
+)
+
+var envVarNamePattern = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_]*$`)
+
+// ValidateEnvVar checks a user-set environment variable. The LEAPMUX_
+// prefix is reserved for the variables the worker itself sets.
+func ValidateEnvVar(name, value string) error {
+	if !envVarNamePattern.MatchString(name) {
+		return fmt.Errorf("environment variable name %q must match [A-Za-z_][A-Za-z0-9_]*", name)
+	}
+	if len(name) > 128 {
+		return fmt.Errorf("environment variable name %q must be at most 128 characters", name)
+	}
+	if strings.HasPrefix(strings.ToUpper(name), "LEAPMUX_") {
+		return fmt.Errorf("environment variable %s: the LEAPMUX_ prefix is reserved", name)
+	}
+	if len(value) > MaxEnvVarValueLen {
+		return fmt.Errorf("environment variable %s: value must be at most %d bytes", name, MaxEnvVarValueLen)
+	}
+	if strings.ContainsRune(value, 0) {
+		return fmt.Errorf("environment variable %s: value must not contain NUL", name)
+	}
+	return nil
+}
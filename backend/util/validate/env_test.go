@@ -0,0 +1,38 @@
+package validate
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestValidateEnvVar(t *testing.T) {
+	tests := []struct {
+		name    string
+		varName string
+		value   string
+		wantErr bool
+	}{
+		{"plain", "GOFLAGS", "-mod=mod", false},
+		{"underscore start", "_PRIVATE", "", false},
+		{"lowercase", "http_proxy", "http://proxy:3128", false},
+		{"empty name", "", "x", true},
+		{"digit start", "1ABC", "x", true},
+		{"equals in name", "A=B", "x", true},
+		{"reserved prefix", "LEAPMUX_WORKER", "1", true},
+		{"reserved prefix any case", "leapmux_tab_id", "1", true},
+		{"value too long", "BIG", strings.Repeat("a", MaxEnvVarValueLen+1), true},
+		{"value with NUL", "NUL", "a\x00b", true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := ValidateEnvVar(tt.varName, tt.value)
+			if tt.wantErr {
+				assert.Error(t, err)
+			} else {
+				assert.NoError(t, err)
+			}
+		})
+	}
+}
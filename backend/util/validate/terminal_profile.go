@@ -0,0 +1,43 @@
+package validate
+
+import (
+	"fmt"
+	"strings"
+	"unicode"
+)
+
+const (
+	// MaxTerminalProfiles caps the terminal profiles one layer (org or
+	// workspace) may define.
+	MaxTerminalProfiles = 50
+	// MaxTerminalProfileNameLen caps a terminal profile's name.
+	MaxTerminalProfileNameLen = 64
+	// MaxStartupCommandLen caps a terminal profile's startup command.
+	MaxStartupCommandLen = 4 << 10
+)
+
+// ValidateTerminalProfile checks a terminal profile's fields other than its
+// environment, which callers check with ValidateEnvVar.
+func ValidateTerminalProfile(name, shell, shellStartDir, startupCommand string) error {
+	if strings.TrimSpace(name) != name || name == "" {
+		return fmt.Errorf("terminal profile name %q must be non-empty without surrounding spaces", name)
+	}
+	if len(name) > MaxTerminalProfileNameLen {
+		return fmt.Errorf("terminal profile name %q must be at most %d characters", name, MaxTerminalProfileNameLen)
+	}
+	if strings.IndexFunc(name, unicode.IsControl) >= 0 {
+		return fmt.Errorf("terminal profile name %q must not contain control characters", name)
+	}
+	for field, v := range map[string]string{"shell": shell, "shell_start_dir": shellStartDir} {
+		if len(v) > 4096 || strings.ContainsRune(v, 0) {
+			return fmt.Errorf("terminal profile %s: %s must be at most 4096 bytes without NUL", name, field)
+		}
+	}
+	if len(startupCommand) > MaxStartupCommandLen {
+		return fmt.Errorf("terminal profile %s: startup command must be at most %d bytes", name, MaxStartupCommandLen)
+	}
+	if strings.ContainsRune(startupCommand, 0) {
+		return fmt.Errorf("terminal profile %s: startup command must not contain NUL", name)
+	}
+	return nil
+}
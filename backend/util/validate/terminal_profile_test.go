@@ -0,0 +1,39 @@
+package validate
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestValidateTerminalProfile(t *testing.T) {
+	tests := []struct {
+		name           string
+		profile        string
+		shell          string
+		shellStartDir  string
+		startupCommand string
+		wantErr        bool
+	}{
+		{"plain", "psql", "/bin/zsh", "~/src", "psql -h localhost", false},
+		{"name only", "zsh", "", "", "", false},
+		{"empty name", "", "", "", "", true},
+		{"padded name", " psql", "", "", "", true},
+		{"name too long", strings.Repeat("a", MaxTerminalProfileNameLen+1), "", "", "", true},
+		{"control in name", "a\tb", "", "", "", true},
+		{"NUL in shell", "x", "/bin/\x00sh", "", "", true},
+		{"command too long", "x", "", "", strings.Repeat("a", MaxStartupCommandLen+1), true},
+		{"NUL in command", "x", "", "", "ls\x00", true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := ValidateTerminalProfile(tt.profile, tt.shell, tt.shellStartDir, tt.startupCommand)
+			if tt.wantErr {
+				assert.Error(t, err)
+			} else {
+				assert.NoError(t, err)
+			}
+		})
+	}
+}
@@ -17,6 +17,7 @@ import (
 	"github.com/leapmux/leapmux/internal/worker/bootstrap"
 	workerdb "github.com/leapmux/leapmux/internal/worker/db"
 	"github.com/leapmux/leapmux/internal/worker/hub"
+	"github.com/leapmux/leapmux/internal/worker/service"
 	"github.com/leapmux/leapmux/internal/worker/wakelock"
 )
 
@@ -125,7 +126,7 @@ func Run(ctx context.Context, cfg RunConfig) error {
 		// No composite key means no E2EE channel and therefore no service
 		// to wire, but the retention loops are about rows on disk and still
 		// have to run.
-		bootstrap.StartRetentionLoops(ctx, sqlDB, cfg.DataDir)
+		bootstrap.StartRetentionLoops(ctx, sqlDB, cfg.DataDir, service.CleanupOptions{})
 	}
 
 	// Detach the connect loop from ctx so cancellation reaches it only
@@ -1,11 +1,20 @@
 import { createClient } from '@connectrpc/connect'
+import { AnnouncementService } from '~/generated/leapmux/v1/announcement_pb'
 import { AuthService } from '~/generated/leapmux/v1/auth_pb'
 import { ChannelService } from '~/generated/leapmux/v1/channel_pb'
+import { LayoutService } from '~/generated/leapmux/v1/layout_pb'
+import { ModelCredentialService } from '~/generated/leapmux/v1/model_credential_pb'
 import { OrgCRDT } from '~/generated/leapmux/v1/org_ops_pb'
+import { PaletteService } from '~/generated/leapmux/v1/palette_pb'
+import { RepoService } from '~/generated/leapmux/v1/repo_pb'
 import { SectionService } from '~/generated/leapmux/v1/section_pb'
+import { SettingsService } from '~/generated/leapmux/v1/settings_pb'
+import { SnippetService } from '~/generated/leapmux/v1/snippet_pb'
+import { SystemPromptService } from '~/generated/leapmux/v1/system_prompt_pb'
 import { UserService } from '~/generated/leapmux/v1/user_pb'
 import { WorkerManagementService } from '~/generated/leapmux/v1/worker_pb'
 import { WorkspaceService } from '~/generated/leapmux/v1/workspace_pb'
+import { WorkspaceTransferService } from '~/generated/leapmux/v1/workspace_bundle_pb'
 import { transport } from './transport'
 
 export const authClient = createClient(AuthService, transport)
@@ -15,3 +24,12 @@ export const sectionClient = createClient(SectionService, transport)
 export const channelClient = createClient(ChannelService, transport)
 export const workspaceClient = createClient(WorkspaceService, transport)
 export const orgCRDTClient = createClient(OrgCRDT, transport)
+export const layoutClient = createClient(LayoutService, transport)
+export const paletteClient = createClient(PaletteService, transport)
+export const repoClient = createClient(RepoService, transport)
+export const modelCredentialClient = createClient(ModelCredentialService, transport)
+export const settingsClient = createClient(SettingsService, transport)
+export const systemPromptClient = createClient(SystemPromptService, transport)
+export const snippetClient = createClient(SnippetService, transport)
+export const announcementClient = createClient(AnnouncementService, transport)
+export const workspaceTransferClient = createClient(WorkspaceTransferService, transport)
@@ -0,0 +1,24 @@
+import { afterEach, describe, expect, it, vi } from 'vitest'
+import { hubOrigin, hubWebSocketOrigin } from './hubOrigin'
+
+describe('hubOrigin', () => {
+  afterEach(() => {
+    vi.unstubAllEnvs()
+  })
+
+  it('defaults to the page origin', () => {
+    vi.stubEnv('LEAPMUX_HUB_URL', '')
+    expect(hubOrigin()).toBe(window.location.origin)
+  })
+
+  it('uses the configured hub URL without a trailing slash', () => {
+    vi.stubEnv('LEAPMUX_HUB_URL', 'https://hub.example.com/')
+    expect(hubOrigin()).toBe('https://hub.example.com')
+    expect(hubWebSocketOrigin()).toBe('wss://hub.example.com')
+  })
+
+  it('maps plain http to ws', () => {
+    vi.stubEnv('LEAPMUX_HUB_URL', 'http://localhost:4327')
+    expect(hubWebSocketOrigin()).toBe('ws://localhost:4327')
+  })
+})
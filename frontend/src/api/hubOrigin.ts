@@ -0,0 +1,18 @@
+// The hub API's origin. A build normally talks to the hub that serves it;
+// setting LEAPMUX_HUB_URL at build time points the UI at a hub on another
+// origin instead, which must list the UI's origin in --allowed-origins
+// (and, across sites, enable --cross-site-cookies).
+
+function configuredHubUrl(): string {
+  return (import.meta.env.LEAPMUX_HUB_URL || '').replace(/\/+$/, '')
+}
+
+/** Base URL for Connect RPCs and plain HTTP requests to the hub. */
+export function hubOrigin(): string {
+  return configuredHubUrl() || window.location.origin
+}
+
+/** Base URL for the hub's WebSocket endpoints (`ws:` / `wss:`). */
+export function hubWebSocketOrigin(): string {
+  return hubOrigin().replace(/^http/, 'ws')
+}
@@ -1,6 +1,7 @@
 import type { Interceptor } from '@connectrpc/connect'
 import { Code, ConnectError, createClient } from '@connectrpc/connect'
 import { createConnectTransport } from '@connectrpc/connect-web'
+import { hubOrigin } from '~/api/hubOrigin'
 import { desktopFetch, getCapabilities, isTauriApp } from '~/api/platformBridge'
 import { UserService } from '~/generated/leapmux/v1/user_pb'
 
@@ -47,7 +48,7 @@ function getTransportFetch(): typeof globalThis.fetch {
 }
 
 export const transport = createConnectTransport({
-  baseUrl: window.location.origin,
+  baseUrl: hubOrigin(),
   fetch: getTransportFetch(),
   interceptors: [errorInterceptor],
   defaultTimeoutMs: 30_000,
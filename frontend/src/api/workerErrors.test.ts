@@ -1,6 +1,9 @@
+import { create } from '@bufbuild/protobuf'
 import { Code, ConnectError } from '@connectrpc/connect'
 import { describe, expect, it } from 'vitest'
-import { isWorkerUnreachable } from '~/api/workerErrors'
+import { errorCodeOf, isWorkerOffline, isWorkerUnreachable } from '~/api/workerErrors'
+import { ErrorDetailSchema } from '~/generated/leapmux/v1/common_pb'
+import { ChannelError } from '~/lib/channel'
 
 // isWorkerUnreachable backs the tab-close fallback for orphaned
 // workers (useTabOperations.handleTabClose). The contract MUST stay
@@ -34,3 +37,34 @@ describe('isworkerunreachable', () => {
     expect(isWorkerUnreachable({ code: Code.NotFound })).toBe(false)
   })
 })
+
+describe('isworkeroffline', () => {
+  it('matches Unavailable and channel transport failures', () => {
+    expect(isWorkerOffline(new ConnectError('worker is offline', Code.Unavailable))).toBe(true)
+    expect(isWorkerOffline(new ChannelError('transport', 'channel disconnected'))).toBe(true)
+  })
+
+  it('does not match errors the worker itself returned', () => {
+    expect(isWorkerOffline(new ConnectError('gone', Code.NotFound))).toBe(false)
+    expect(isWorkerOffline(new ChannelError('rpc', 'agent failed to start', 9))).toBe(false)
+    expect(isWorkerOffline(new Error('bare error'))).toBe(false)
+  })
+})
+
+describe('errorcodeof', () => {
+  it('reads the code from a ConnectError detail', () => {
+    const err = new ConnectError('worker is offline', Code.Unavailable, undefined, [
+      { desc: ErrorDetailSchema, value: create(ErrorDetailSchema, { code: 'LEAPMUX_WORKER_OFFLINE' }) },
+    ])
+    expect(errorCodeOf(err)).toBe('LEAPMUX_WORKER_OFFLINE')
+  })
+
+  it('reads the reason from a worker RPC error', () => {
+    expect(errorCodeOf(new ChannelError('rpc', 'agent not found', 5, 'LEAPMUX_AGENT_NOT_FOUND'))).toBe('LEAPMUX_AGENT_NOT_FOUND')
+  })
+
+  it('is empty when the error carries no code', () => {
+    expect(errorCodeOf(new ConnectError('gone', Code.NotFound))).toBe('')
+    expect(errorCodeOf(new Error('bare error'))).toBe('')
+  })
+})
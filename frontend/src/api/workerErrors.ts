@@ -1,4 +1,6 @@
 import { Code, ConnectError } from '@connectrpc/connect'
+import { ErrorDetailSchema } from '~/generated/leapmux/v1/common_pb'
+import { ChannelError } from '~/lib/channel'
 
 /**
  * isWorkerUnreachable reports whether `err` describes a worker we
@@ -33,3 +35,33 @@ export function isWorkerUnreachable(err: unknown): boolean {
       return false
   }
 }
+
+/**
+ * isWorkerOffline reports whether `err` means a worker RPC could not
+ * reach the worker right now: the hub refused to open a channel to an
+ * offline worker (Unavailable), or the channel's transport failed. It
+ * is the predicate the opt-in offline send spool uses to queue a
+ * message instead of failing it. A transport failure mid-call may
+ * still have delivered the request, so callers replaying on it must
+ * make the replay idempotent.
+ */
+export function isWorkerOffline(err: unknown): boolean {
+  if (err instanceof ChannelError)
+    return err.source === 'transport'
+  return err instanceof ConnectError && err.code === Code.Unavailable
+}
+
+/**
+ * errorCodeOf returns the stable LeapMux error code carried by `err`
+ * (e.g. `LEAPMUX_WORKER_OFFLINE`): the ErrorDetail of a hub ConnectError,
+ * or the reason of a worker RPC ChannelError. Empty when the error
+ * carries none. Branch on this rather than on the message text; the
+ * catalog lives in `backend/internal/errcode`.
+ */
+export function errorCodeOf(err: unknown): string {
+  if (err instanceof ChannelError)
+    return err.reason
+  if (err instanceof ConnectError)
+    return err.findDetails(ErrorDetailSchema).find(d => d.code !== '')?.code ?? ''
+  return ''
+}
@@ -10,25 +10,46 @@ import type { MessageInitShape, MessageShape } from '@bufbuild/protobuf'
 import type { GenMessage } from '@bufbuild/protobuf/codegenv2'
 import type {
   CloseAgentResponse,
+  CompactAgentContextResponse,
   DeleteAgentMessageResponse,
+  GetAgentCIStatusResponse,
   GetAgentMessageResponse,
+  GetAgentMessageStatsResponse,
+  GetAgentRuntimeInfoResponse,
+  GetRateLimitBudgetResponse,
+  GraphQLQueryResponse,
   InterruptAgentResponse,
+  ListAgentAnalyticsResponse,
+  ListAgentArtifactsResponse,
+  ListAgentMessagesInRangeResponse,
   ListAgentMessagesResponse,
   ListAgentsResponse,
+  ListAgentTestRunsResponse,
+  ListAllAgentsResponse,
   ListAvailableProvidersResponse,
   ListMessageMarksResponse,
+  ListModelCredentialUsageResponse,
+  ListSystemPromptUsageResponse,
+  MarkAgentReadResponse,
   OpenAgentResponse,
+  QueryMetricsResponse,
   RenameAgentResponse,
+  RenderAgentTranscriptResponse,
   SendAgentMessageResponse,
   SendAgentRawMessageResponse,
   SendControlResponseResponse,
+  SetAgentArtifactPatternsResponse,
   UpdateAgentSettingsResponse,
 } from '~/generated/leapmux/v1/agent_pb'
 import type { EncryptionMode, InnerStreamMessage } from '~/generated/leapmux/v1/channel_pb'
 import type {
+  CancelAgentUploadResponse,
   ListDirectoryResponse,
+  ReadAgentFileResponse,
   ReadFileResponse,
+  StartAgentUploadResponse,
   StatFileResponse,
+  WriteAgentUploadChunkResponse,
 } from '~/generated/leapmux/v1/file_pb'
 import type {
   CheckoutBranchResponse,
@@ -45,7 +66,10 @@ import type {
   ReadGitFileResponse,
 } from '~/generated/leapmux/v1/git_pb'
 import type {
+  CancelExecResponse,
   CloseTerminalResponse,
+  ExecOutputChunk,
+  GetWorkspaceTerminalProfilesResponse,
   ListAvailableShellsResponse,
   ListTerminalsResponse,
   OpenTerminalResponse,
@@ -71,6 +95,7 @@ import type {
 import type { ChannelSocket, ChannelTransport, KeyPinDecision, WorkerKeyBundle } from '~/lib/channel'
 import { create, fromBinary, toBinary, toJsonString } from '@bufbuild/protobuf'
 import { createClient } from '@connectrpc/connect'
+import { hubWebSocketOrigin } from '~/api/hubOrigin'
 import { getCapabilities, isTauriApp } from '~/api/platformBridge'
 import { bufferStreamHandle } from '~/api/streamBuffer'
 import { TauriRelayWebSocket } from '~/api/tauriRelaySocket'
@@ -78,41 +103,83 @@ import { apiLoadingTimeoutMs, transport } from '~/api/transport'
 import {
   CloseAgentRequestSchema,
   CloseAgentResponseSchema,
+  CompactAgentContextRequestSchema,
+  CompactAgentContextResponseSchema,
   DeleteAgentMessageRequestSchema,
   DeleteAgentMessageResponseSchema,
+  GetAgentCIStatusRequestSchema,
+  GetAgentCIStatusResponseSchema,
   GetAgentMessageRequestSchema,
   GetAgentMessageResponseSchema,
+  GetAgentMessageStatsRequestSchema,
+  GetAgentMessageStatsResponseSchema,
+  GetAgentRuntimeInfoRequestSchema,
+  GetAgentRuntimeInfoResponseSchema,
+  GetRateLimitBudgetRequestSchema,
+  GetRateLimitBudgetResponseSchema,
+  GraphQLQueryRequestSchema,
+  GraphQLQueryResponseSchema,
   InterruptAgentRequestSchema,
   InterruptAgentResponseSchema,
+  ListAgentAnalyticsRequestSchema,
+  ListAgentAnalyticsResponseSchema,
+  ListAgentArtifactsRequestSchema,
+  ListAgentArtifactsResponseSchema,
+  ListAgentMessagesInRangeRequestSchema,
+  ListAgentMessagesInRangeResponseSchema,
   ListAgentMessagesRequestSchema,
   ListAgentMessagesResponseSchema,
   ListAgentsRequestSchema,
   ListAgentsResponseSchema,
+  ListAgentTestRunsRequestSchema,
+  ListAgentTestRunsResponseSchema,
+  ListAllAgentsRequestSchema,
+  ListAllAgentsResponseSchema,
   ListAvailableProvidersRequestSchema,
   ListAvailableProvidersResponseSchema,
   ListMessageMarksRequestSchema,
   ListMessageMarksResponseSchema,
+  ListModelCredentialUsageRequestSchema,
+  ListModelCredentialUsageResponseSchema,
+  ListSystemPromptUsageRequestSchema,
+  ListSystemPromptUsageResponseSchema,
+  MarkAgentReadRequestSchema,
+  MarkAgentReadResponseSchema,
   OpenAgentRequestSchema,
   OpenAgentResponseSchema,
+  QueryMetricsRequestSchema,
+  QueryMetricsResponseSchema,
   RenameAgentRequestSchema,
   RenameAgentResponseSchema,
+  RenderAgentTranscriptRequestSchema,
+  RenderAgentTranscriptResponseSchema,
   SendAgentMessageRequestSchema,
   SendAgentMessageResponseSchema,
   SendAgentRawMessageRequestSchema,
   SendAgentRawMessageResponseSchema,
   SendControlResponseRequestSchema,
   SendControlResponseResponseSchema,
+  SetAgentArtifactPatternsRequestSchema,
+  SetAgentArtifactPatternsResponseSchema,
   UpdateAgentSettingsRequestSchema,
   UpdateAgentSettingsResponseSchema,
 } from '~/generated/leapmux/v1/agent_pb'
 import { ChannelService } from '~/generated/leapmux/v1/channel_pb'
 import {
+  CancelAgentUploadRequestSchema,
+  CancelAgentUploadResponseSchema,
   ListDirectoryRequestSchema,
   ListDirectoryResponseSchema,
+  ReadAgentFileRequestSchema,
+  ReadAgentFileResponseSchema,
   ReadFileRequestSchema,
   ReadFileResponseSchema,
+  StartAgentUploadRequestSchema,
+  StartAgentUploadResponseSchema,
   StatFileRequestSchema,
   StatFileResponseSchema,
+  WriteAgentUploadChunkRequestSchema,
+  WriteAgentUploadChunkResponseSchema,
 } from '~/generated/leapmux/v1/file_pb'
 import {
   CheckoutBranchRequestSchema,
@@ -141,8 +208,14 @@ import {
   ReadGitFileResponseSchema,
 } from '~/generated/leapmux/v1/git_pb'
 import {
+  CancelExecRequestSchema,
+  CancelExecResponseSchema,
   CloseTerminalRequestSchema,
   CloseTerminalResponseSchema,
+  ExecCommandRequestSchema,
+  ExecOutputChunkSchema,
+  GetWorkspaceTerminalProfilesRequestSchema,
+  GetWorkspaceTerminalProfilesResponseSchema,
   ListAvailableShellsRequestSchema,
   ListAvailableShellsResponseSchema,
   ListTerminalsRequestSchema,
@@ -181,6 +254,7 @@ import {
   RevokeFileTabPathResponseSchema,
 } from '~/generated/leapmux/v1/workspace_private_pb'
 import { ChannelManager } from '~/lib/channel'
+import { inflateWatchEvent } from '~/lib/decompress'
 import { emitDevEvent } from '~/lib/devInstrument'
 import { createLogger } from '~/lib/logger'
 
@@ -243,8 +317,7 @@ class BrowserChannelTransport implements ChannelTransport {
       return new TauriRelayWebSocket()
     }
 
-    const wsProtocol = window.location.protocol === 'https:' ? 'wss:' : 'ws:'
-    const wsUrl = `${wsProtocol}//${window.location.host}/ws/channel`
+    const wsUrl = `${hubWebSocketOrigin()}/ws/channel`
     const ws = new WebSocket(wsUrl, ['channel-relay'])
     ws.binaryType = 'arraybuffer'
     return ws
@@ -295,6 +368,10 @@ export function getWorkerSystemInfo(workerId: string): Promise<GetWorkerSystemIn
   return callWorker(workerId, 'GetWorkerSystemInfo', GetWorkerSystemInfoRequestSchema, GetWorkerSystemInfoResponseSchema, {})
 }
 
+export function queryWorkerMetrics(workerId: string, req: MessageInitShape<typeof QueryMetricsRequestSchema>): Promise<QueryMetricsResponse> {
+  return callWorker(workerId, 'QueryWorkerMetrics', QueryMetricsRequestSchema, QueryMetricsResponseSchema, req)
+}
+
 // ---------------------------------------------------------------------------
 // Workspace Cleanup (via E2EE channel to worker)
 // ---------------------------------------------------------------------------
@@ -365,6 +442,30 @@ export function listAgents(workerId: string, req: MessageInitShape<typeof ListAg
   return callWorker(workerId, 'ListAgents', ListAgentsRequestSchema, ListAgentsResponseSchema, req)
 }
 
+export function listAllAgents(workerId: string, req: MessageInitShape<typeof ListAllAgentsRequestSchema>): Promise<ListAllAgentsResponse> {
+  return callWorker(workerId, 'ListAllAgents', ListAllAgentsRequestSchema, ListAllAgentsResponseSchema, req)
+}
+
+export function listSystemPromptUsage(workerId: string, req: MessageInitShape<typeof ListSystemPromptUsageRequestSchema>): Promise<ListSystemPromptUsageResponse> {
+  return callWorker(workerId, 'ListSystemPromptUsage', ListSystemPromptUsageRequestSchema, ListSystemPromptUsageResponseSchema, req)
+}
+
+export function listModelCredentialUsage(workerId: string, req: MessageInitShape<typeof ListModelCredentialUsageRequestSchema>): Promise<ListModelCredentialUsageResponse> {
+  return callWorker(workerId, 'ListModelCredentialUsage', ListModelCredentialUsageRequestSchema, ListModelCredentialUsageResponseSchema, req)
+}
+
+export function listAgentAnalytics(workerId: string, req: MessageInitShape<typeof ListAgentAnalyticsRequestSchema>): Promise<ListAgentAnalyticsResponse> {
+  return callWorker(workerId, 'ListAgentAnalytics', ListAgentAnalyticsRequestSchema, ListAgentAnalyticsResponseSchema, req)
+}
+
+export function graphQLQuery(workerId: string, req: MessageInitShape<typeof GraphQLQueryRequestSchema>): Promise<GraphQLQueryResponse> {
+  return callWorker(workerId, 'GraphQLQuery', GraphQLQueryRequestSchema, GraphQLQueryResponseSchema, req)
+}
+
+export function queryWorkspaceMetrics(workerId: string, req: MessageInitShape<typeof QueryMetricsRequestSchema>): Promise<QueryMetricsResponse> {
+  return callWorker(workerId, 'QueryWorkspaceMetrics', QueryMetricsRequestSchema, QueryMetricsResponseSchema, req)
+}
+
 export function listAgentMessages(workerId: string, req: MessageInitShape<typeof ListAgentMessagesRequestSchema>): Promise<ListAgentMessagesResponse> {
   return callWorker(workerId, 'ListAgentMessages', ListAgentMessagesRequestSchema, ListAgentMessagesResponseSchema, req)
 }
@@ -377,6 +478,50 @@ export function getAgentMessage(workerId: string, req: MessageInitShape<typeof G
   return callWorker(workerId, 'GetAgentMessage', GetAgentMessageRequestSchema, GetAgentMessageResponseSchema, req)
 }
 
+export function listAgentMessagesInRange(workerId: string, req: MessageInitShape<typeof ListAgentMessagesInRangeRequestSchema>, opts?: { signal?: AbortSignal }): Promise<ListAgentMessagesInRangeResponse> {
+  return callWorker(workerId, 'ListAgentMessagesInRange', ListAgentMessagesInRangeRequestSchema, ListAgentMessagesInRangeResponseSchema, req, opts)
+}
+
+export function getAgentMessageStats(workerId: string, req: MessageInitShape<typeof GetAgentMessageStatsRequestSchema>): Promise<GetAgentMessageStatsResponse> {
+  return callWorker(workerId, 'GetAgentMessageStats', GetAgentMessageStatsRequestSchema, GetAgentMessageStatsResponseSchema, req)
+}
+
+export function markAgentRead(workerId: string, req: MessageInitShape<typeof MarkAgentReadRequestSchema>): Promise<MarkAgentReadResponse> {
+  return callWorker(workerId, 'MarkAgentRead', MarkAgentReadRequestSchema, MarkAgentReadResponseSchema, req)
+}
+
+export function renderAgentTranscript(workerId: string, req: MessageInitShape<typeof RenderAgentTranscriptRequestSchema>): Promise<RenderAgentTranscriptResponse> {
+  return callWorker(workerId, 'RenderAgentTranscript', RenderAgentTranscriptRequestSchema, RenderAgentTranscriptResponseSchema, req)
+}
+
+export function setAgentArtifactPatterns(workerId: string, req: MessageInitShape<typeof SetAgentArtifactPatternsRequestSchema>): Promise<SetAgentArtifactPatternsResponse> {
+  return callWorker(workerId, 'SetAgentArtifactPatterns', SetAgentArtifactPatternsRequestSchema, SetAgentArtifactPatternsResponseSchema, req)
+}
+
+export function listAgentArtifacts(workerId: string, req: MessageInitShape<typeof ListAgentArtifactsRequestSchema>): Promise<ListAgentArtifactsResponse> {
+  return callWorker(workerId, 'ListAgentArtifacts', ListAgentArtifactsRequestSchema, ListAgentArtifactsResponseSchema, req)
+}
+
+export function listAgentTestRuns(workerId: string, req: MessageInitShape<typeof ListAgentTestRunsRequestSchema>): Promise<ListAgentTestRunsResponse> {
+  return callWorker(workerId, 'ListAgentTestRuns', ListAgentTestRunsRequestSchema, ListAgentTestRunsResponseSchema, req)
+}
+
+export function getAgentCIStatus(workerId: string, req: MessageInitShape<typeof GetAgentCIStatusRequestSchema>): Promise<GetAgentCIStatusResponse> {
+  return callWorker(workerId, 'GetAgentCIStatus', GetAgentCIStatusRequestSchema, GetAgentCIStatusResponseSchema, req)
+}
+
+export function getAgentRuntimeInfo(workerId: string, req: MessageInitShape<typeof GetAgentRuntimeInfoRequestSchema>): Promise<GetAgentRuntimeInfoResponse> {
+  return callWorker(workerId, 'GetAgentRuntimeInfo', GetAgentRuntimeInfoRequestSchema, GetAgentRuntimeInfoResponseSchema, req)
+}
+
+export function compactAgentContext(workerId: string, req: MessageInitShape<typeof CompactAgentContextRequestSchema>): Promise<CompactAgentContextResponse> {
+  return callWorker(workerId, 'CompactAgentContext', CompactAgentContextRequestSchema, CompactAgentContextResponseSchema, req)
+}
+
+export function getRateLimitBudget(workerId: string, req: MessageInitShape<typeof GetRateLimitBudgetRequestSchema>): Promise<GetRateLimitBudgetResponse> {
+  return callWorker(workerId, 'GetRateLimitBudget', GetRateLimitBudgetRequestSchema, GetRateLimitBudgetResponseSchema, req)
+}
+
 export function renameAgent(workerId: string, req: MessageInitShape<typeof RenameAgentRequestSchema>): Promise<RenameAgentResponse> {
   return callWorker(workerId, 'RenameAgent', RenameAgentRequestSchema, RenameAgentResponseSchema, req)
 }
@@ -437,6 +582,44 @@ export function listAvailableShells(workerId: string, req: MessageInitShape<type
   return callWorker(workerId, 'ListAvailableShells', ListAvailableShellsRequestSchema, ListAvailableShellsResponseSchema, req)
 }
 
+export function getWorkspaceTerminalProfiles(workerId: string, req: MessageInitShape<typeof GetWorkspaceTerminalProfilesRequestSchema>): Promise<GetWorkspaceTerminalProfilesResponse> {
+  return callWorker(workerId, 'GetWorkspaceTerminalProfiles', GetWorkspaceTerminalProfilesRequestSchema, GetWorkspaceTerminalProfilesResponseSchema, req)
+}
+
+/**
+ * Runs one command on the Worker without a terminal, handing each output
+ * chunk to `onOutput` as it streams in. Resolves with the final chunk
+ * (exit code, timed_out, truncated) once the command exits; rejects when
+ * it cannot start or the channel drops. `cancelExec` with the same
+ * `execId` kills it early.
+ */
+export async function execCommand(
+  workerId: string,
+  req: MessageInitShape<typeof ExecCommandRequestSchema>,
+  onOutput: (chunk: ExecOutputChunk) => void,
+): Promise<ExecOutputChunk> {
+  const channelId = await channelManager.getOrOpenChannel(workerId)
+  const payload = toBinary(ExecCommandRequestSchema, create(ExecCommandRequestSchema, req))
+  const handle = channelManager.stream(channelId, 'ExecCommand', payload)
+  return new Promise((resolve, reject) => {
+    handle.onMessage((msg) => {
+      const chunk = fromBinary(ExecOutputChunkSchema, msg.payload)
+      if (chunk.stdout.length > 0 || chunk.stderr.length > 0)
+        onOutput(chunk)
+      if (chunk.exited) {
+        channelManager.removeStreamListener(channelId, handle.requestId)
+        resolve(chunk)
+      }
+    })
+    handle.onEnd(() => reject(new Error('command output ended before it exited')))
+    handle.onError(reject)
+  })
+}
+
+export function cancelExec(workerId: string, req: MessageInitShape<typeof CancelExecRequestSchema>): Promise<CancelExecResponse> {
+  return callWorker(workerId, 'CancelExec', CancelExecRequestSchema, CancelExecResponseSchema, req)
+}
+
 // ---------------------------------------------------------------------------
 // File
 // ---------------------------------------------------------------------------
@@ -453,6 +636,22 @@ export function statFile(workerId: string, req: MessageInitShape<typeof StatFile
   return callWorker(workerId, 'StatFile', StatFileRequestSchema, StatFileResponseSchema, req)
 }
 
+export function startAgentUpload(workerId: string, req: MessageInitShape<typeof StartAgentUploadRequestSchema>): Promise<StartAgentUploadResponse> {
+  return callWorker(workerId, 'StartAgentUpload', StartAgentUploadRequestSchema, StartAgentUploadResponseSchema, req)
+}
+
+export function writeAgentUploadChunk(workerId: string, req: MessageInitShape<typeof WriteAgentUploadChunkRequestSchema>): Promise<WriteAgentUploadChunkResponse> {
+  return callWorker(workerId, 'WriteAgentUploadChunk', WriteAgentUploadChunkRequestSchema, WriteAgentUploadChunkResponseSchema, req)
+}
+
+export function cancelAgentUpload(workerId: string, req: MessageInitShape<typeof CancelAgentUploadRequestSchema>): Promise<CancelAgentUploadResponse> {
+  return callWorker(workerId, 'CancelAgentUpload', CancelAgentUploadRequestSchema, CancelAgentUploadResponseSchema, req)
+}
+
+export function readAgentFile(workerId: string, req: MessageInitShape<typeof ReadAgentFileRequestSchema>): Promise<ReadAgentFileResponse> {
+  return callWorker(workerId, 'ReadAgentFile', ReadAgentFileRequestSchema, ReadAgentFileResponseSchema, req)
+}
+
 // ---------------------------------------------------------------------------
 // Git
 // ---------------------------------------------------------------------------
@@ -533,7 +732,9 @@ export async function watchEventsViaChannel(
   request: MessageInitShape<typeof WatchEventsRequestSchema>,
 ): Promise<WatchEventsHandle> {
   const channelId = await channelManager.getOrOpenChannel(workerId)
-  const msg = create(WatchEventsRequestSchema, request)
+  // The worker compresses bulk payloads only when its org turns compression
+  // on; inflateWatchEvent below undoes it before any consumer sees them.
+  const msg = create(WatchEventsRequestSchema, { ...request, acceptCompression: true })
   const payload = toBinary(WatchEventsRequestSchema, msg)
 
   const streamHandle = channelManager.stream(channelId, 'WatchEvents', payload)
@@ -542,6 +743,7 @@ export async function watchEventsViaChannel(
   // See streamBuffer.ts for the full rationale.
   const buffered = bufferStreamHandle<InnerStreamMessage, WatchEventsResponse>(streamHandle, (msg) => {
     const resp = fromBinary(WatchEventsResponseSchema, msg.payload)
+    inflateWatchEvent(resp)
     log.debug('WatchEvents stream message', { response: toJsonString(WatchEventsResponseSchema, resp) })
     return resp
   })
@@ -485,7 +485,7 @@ export const MessageBubble: Component<MessageBubbleProps> = (props) => {
                 : category().kind === 'notification'
                   ? (renderNotificationThread(notificationMessages(), props.message.agentProvider) ?? renderContent())
                   : category().kind === 'result_divider'
-                    ? (renderResultDivider(renderPayload(), props.message.agentProvider) ?? renderContent())
+                    ? (renderResultDivider(renderPayload(), props.message.agentProvider, props.message.usage) ?? renderContent())
                     : category().kind === 'unsupported_provider'
                       ? renderUnsupportedProvider()
                       : renderContent()}
@@ -9,6 +9,21 @@ const BASE_NOTIFICATION_TYPES = new Set([
   'interrupted',
   'rate_limit_event',
   'plan_updated',
+  'retry_scheduled',
+  'retry_exhausted',
+  'permission_mode_blocked',
+  'disk_space_low',
+  'agent_status',
+  'context_compaction',
+  'context_pressure',
+  'turn_held',
+  'agent_anomaly',
+  'emergency_stop',
+  'tool_use_decided',
+  'secrets_redacted',
+  'turn_stalled',
+  'limit_exceeded',
+  'agent_interrupted',
 ])
 
 /**
@@ -473,6 +473,46 @@ describe('single-message notification labels', () => {
   it('renders agent_error with the "Unknown error" fallback', () => {
     expect(renderText([{ type: 'agent_error' }])).toBe('Unknown error')
   })
+
+  it('words agent_error by its code', () => {
+    expect(renderText([{ type: 'agent_error', code: 'compact_failed', error: 'agent is busy' }]))
+      .toBe('Failed to compact context: agent is busy')
+    expect(renderText([{ type: 'agent_error', code: 'settings_restart_failed' }]))
+      .toBe('Failed to restart agent with new settings')
+    expect(renderText([{ type: 'agent_error', code: 'from_the_future', error: 'boom' }])).toBe('boom')
+  })
+
+  it('renders agent_anomaly by kind', () => {
+    expect(renderText([{ type: 'agent_anomaly', kind: 'repeated_command', count: 5, limit: 5, command: 'go test ./...' }]))
+      .toBe('Possible stuck loop: ran `go test ./...` 5 times this turn')
+    expect(renderText([{ type: 'agent_anomaly', kind: 'turn_cost', count: 10.5, limit: 10 }]))
+      .toBe('Expensive turn: $10.50 so far (limit $10.00)')
+  })
+
+  it('renders turn_stalled by action', () => {
+    expect(renderText([{ type: 'turn_stalled', action: 'notify', silent_seconds: 1200 }]))
+      .toBe('Turn stalled: no output for 20m')
+    expect(renderText([{ type: 'turn_stalled', action: 'restart', silent_seconds: 660, attempt: 2 }]))
+      .toBe('Turn stalled: no output for 11m, restarting the agent (attempt 2)')
+  })
+
+  it('renders limit_exceeded by limit', () => {
+    expect(renderText([{ type: 'limit_exceeded', limit: 'duration', threshold: 3600, elapsed_seconds: 3610, interrupted: true }]))
+      .toBe('Turn ran 60m, past the 60m limit, interrupting it')
+    expect(renderText([{ type: 'limit_exceeded', limit: 'cost', threshold: 5, cost_usd: 5.25, interrupted: false }]))
+      .toBe('Turn cost $5.25 passed the $5.00 limit')
+  })
+
+  it('renders agent_interrupted by reason', () => {
+    expect(renderText([{ type: 'agent_interrupted', reason: 'budget', detail: 'nightly spend cap' }]))
+      .toBe('Interrupted by a budget limit: nightly spend cap')
+    expect(renderText([{ type: 'agent_interrupted', reason: 'watchdog' }])).toBe('Interrupted by a watchdog')
+  })
+
+  it('renders emergency_stop with and without a reason', () => {
+    expect(renderText([{ type: 'emergency_stop', reason: 'runaway spend' }])).toBe('Stopped by an admin: runaway spend')
+    expect(renderText([{ type: 'emergency_stop' }])).toBe('Stopped by an admin')
+  })
 })
 
 describe('renderNotificationThread: plan_updated', () => {
@@ -5,7 +5,8 @@ import type { CompactionDetail } from '~/lib/messageParser'
 import ArrowDownToLine from 'lucide-solid/icons/arrow-down-to-line'
 import LoaderCircle from 'lucide-solid/icons/loader-circle'
 import { Icon } from '~/components/common/Icon'
-import { isObject, pickNumber, pickObject, pickString } from '~/lib/jsonPick'
+import { formatBytes } from '~/lib/formatBytes'
+import { isObject, pickNumber, pickObject, pickString, stringArray } from '~/lib/jsonPick'
 import { isCompactBoundary, parseBoundaryMeta, toTokenCount } from '~/lib/messageParser'
 import { NOTIFICATION_TYPE } from '~/lib/notificationTypes'
 import { getCachedSettingsGroupLabel, getCachedSettingsLabel } from '~/lib/settingsLabelCache'
@@ -135,6 +136,218 @@ function formatApiRetryLabel(data: Record<string, unknown>): string {
     : `API Retry ${attempt}/${maxRetries}`
 }
 
+function retryReasonLabel(data: Record<string, unknown>): string {
+  return pickString(data, 'reason', '') === 'rate_limit' ? 'rate limit' : 'API error'
+}
+
+/** Label for a worker-scheduled auto-continue retry (`retry_scheduled`). */
+function formatRetryScheduledLabel(data: Record<string, unknown>): string {
+  const attempt = pickNumber(data, 'attempt', '?' as const)
+  const maxAttempts = pickNumber(data, 'max_attempts', 0)
+  const counter = maxAttempts ? `${attempt}/${maxAttempts}` : `${attempt}`
+  const fallback = pickString(data, 'fallback_model', null)
+  const label = `Retry ${counter} scheduled after ${retryReasonLabel(data)}`
+  return fallback ? `${label} (switched to ${fallback})` : label
+}
+
+/** Label for an auto-continue retry budget that ran out (`retry_exhausted`). */
+function formatRetryExhaustedLabel(data: Record<string, unknown>): string {
+  const attempts = pickNumber(data, 'attempts', '?' as const)
+  return `Gave up retrying after ${attempts} ${retryReasonLabel(data)} retries`
+}
+
+// What an agent_error's `code` says failed, worded ahead of its `error`.
+// Rows from before the codes (and provider-reported failures) have no
+// code and show their `error` alone.
+const AGENT_ERROR_CODE_LABELS: Record<string, string> = {
+  settings_restart_failed: 'Failed to restart agent with new settings',
+  clear_context_restart_failed: 'Failed to restart agent after clearing context',
+  plan_execution_restart_failed: 'Failed to restart agent for plan execution',
+  compact_failed: 'Failed to compact context',
+  prompt_failed: 'Prompt failed',
+  output_schema_mismatch: 'Unexpected Claude Code output',
+}
+
+/** Label for an agent failure (`agent_error`). */
+function formatAgentErrorLabel(data: Record<string, unknown>): string {
+  const error = pickString(data, 'error', null)
+  const code = pickString(data, 'code', '')
+  const what = Object.hasOwn(AGENT_ERROR_CODE_LABELS, code) ? AGENT_ERROR_CODE_LABELS[code] : null
+  if (!what)
+    return error ?? UNKNOWN_ERROR_LABEL
+  return error ? `${what}: ${error}` : what
+}
+
+/**
+ * Label for a permission mode the worker's guardrails, or the org's access
+ * policy, refused (`permission_mode_blocked`).
+ */
+function formatPermissionModeBlockedLabel(data: Record<string, unknown>): string {
+  const mode = pickString(data, 'mode', 'unknown')
+  const applied = pickString(data, 'applied', null)
+  const rule = pickString(data, 'rule', null)
+  const message = pickString(data, 'message', null)
+  let label = rule
+    ? `Permission mode ${mode} is denied by access policy rule "${rule}"`
+    : `Permission mode ${mode} is not allowed on this worker`
+  if (applied)
+    label = `${label} (using ${applied})`
+  return message ? `${label}: ${message}` : label
+}
+
+/** Label for a worker nearing its free-space floor or a disk quota (`disk_space_low`). */
+function formatDiskSpaceLowLabel(data: Record<string, unknown>): string {
+  const scope = pickString(data, 'scope', 'disk')
+  if (scope === 'disk')
+    return `Worker disk nearly full (${formatBytes(pickNumber(data, 'free_bytes', 0))} free)`
+  const used = formatBytes(pickNumber(data, 'used_bytes', 0))
+  const limit = formatBytes(pickNumber(data, 'limit_bytes', 0))
+  return scope === 'workspace'
+    ? `This workspace's worktrees use ${used} of its ${limit} quota`
+    : `Worktrees on this worker use ${used} of the ${limit} quota`
+}
+
+/** Label for the /status command's report (`agent_status`). Empty fields are left out. */
+function formatAgentStatusLabel(data: Record<string, unknown>): string {
+  const parts: string[] = []
+  const model = pickString(data, 'model')
+  const effort = pickString(data, 'effort')
+  if (model)
+    parts.push(effort ? `${model} (${effort} effort)` : model)
+  const mode = pickString(data, 'permission_mode')
+  if (mode)
+    parts.push(`${mode} mode`)
+  const credential = pickString(data, 'model_credential')
+  if (credential)
+    parts.push(`as ${credential}`)
+  const tokens = pickNumber(data, 'context_tokens', 0)
+  const window = pickNumber(data, 'context_window', 0)
+  if (tokens > 0)
+    parts.push(window > 0 ? `${formatTokenCount(tokens)} / ${formatTokenCount(window)} tokens` : `${formatTokenCount(tokens)} tokens`)
+  const host = pickString(data, 'hostname')
+  const worker = pickString(data, 'worker_name')
+  if (worker || host)
+    parts.push(worker && host && worker !== host ? `${worker} (${host})` : worker || host)
+  const dir = pickString(data, 'working_dir')
+  const branch = pickString(data, 'git_branch')
+  if (dir)
+    parts.push(branch ? `${dir} on ${branch}` : dir)
+  const session = pickString(data, 'agent_session_id')
+  parts.push(session ? `session ${session}` : 'no session yet')
+  return `Status: ${parts.join(' · ')}`
+}
+
+/** Label for a /compact run (`context_compaction`), with the context size before and, once known, after. */
+function formatContextCompactionLabel(data: Record<string, unknown>): string {
+  const before = pickNumber(data, 'before_tokens', 0)
+  const window = pickNumber(data, 'context_window', 0)
+  const size = (tokens: number) => window > 0 ? `${formatTokenCount(tokens)} / ${formatTokenCount(window)} tokens` : `${formatTokenCount(tokens)} tokens`
+  const how = pickString(data, 'method') === 'summary' ? ' (restarted with a summary)' : ''
+  if (pickString(data, 'phase') !== 'finished')
+    return before > 0 ? `Compacting context at ${size(before)}` : 'Compacting context'
+  const after = pickNumber(data, 'after_tokens', -1)
+  if (after >= 0)
+    return `Context compacted${how}: ${before > 0 ? `${size(before)} → ` : ''}${size(after)}`
+  return `Context compacted${how}`
+}
+
+/** Label for a context window warning (`context_pressure`), naming the action queued for the turn's end. */
+function formatContextPressureLabel(data: Record<string, unknown>): string {
+  const tokens = formatTokenCount(pickNumber(data, 'context_tokens', 0))
+  const window = formatTokenCount(pickNumber(data, 'context_window', 0))
+  const base = `Context window ${pickNumber(data, 'percent', 0)}% full (${tokens} / ${window} tokens)`
+  const action = pickString(data, 'action')
+  if (action === 'compact')
+    return `${base}; compacting after this turn`
+  if (action === 'checkpoint')
+    return `${base}; asking the agent for a checkpoint after this turn`
+  return base
+}
+
+/** Label for a turn held back by the provider account's rate limit (`turn_held`). */
+function formatTurnHeldLabel(data: Record<string, unknown>): string {
+  const why = pickString(data, 'reason') === 'near_limit' ? 'near its rate limit' : 'rate limited'
+  const who = pickString(data, 'priority') === 'background' ? 'Background turn' : 'Message'
+  const until = Date.parse(pickString(data, 'until'))
+  const when = Number.isNaN(until) ? '' : ` until ${new Date(until).toLocaleTimeString()}`
+  return `${who} held${when}: the account is ${why}`
+}
+
+/** Label for a turn flagged by the worker's anomaly checks (`agent_anomaly`). */
+function formatAgentAnomalyLabel(data: Record<string, unknown>): string {
+  const count = pickNumber(data, 'count', 0)
+  const limit = pickNumber(data, 'limit', 0)
+  switch (pickString(data, 'kind')) {
+    case 'tool_loop':
+      return `Possible runaway loop: ${count} tool calls this turn (limit ${limit})`
+    case 'repeated_command': {
+      const command = pickString(data, 'command')
+      return `Possible stuck loop: ran ${command ? `\`${command}\`` : 'the same command'} ${count} times this turn`
+    }
+    case 'file_deletions':
+      return `Many deletions: ${count} paths deleted this turn (limit ${limit})`
+    case 'turn_cost':
+      return `Expensive turn: $${count.toFixed(2)} so far (limit $${limit.toFixed(2)})`
+    default:
+      return 'Unusual agent activity this turn'
+  }
+}
+
+/** Label for a tool use the org's access policy answered (`tool_use_decided`). */
+function formatToolUseDecidedLabel(data: Record<string, unknown>): string {
+  const tool = pickString(data, 'tool', 'tool')
+  const rule = pickString(data, 'rule')
+  const message = pickString(data, 'message', null)
+  const verb = pickString(data, 'effect') === 'allow' ? 'allowed' : 'denied'
+  const label = `${tool} ${verb} by access policy rule "${rule}"`
+  return message ? `${label}: ${message}` : label
+}
+
+/** Label for secrets the worker replaced in agent output (`secrets_redacted`). */
+function formatSecretsRedactedLabel(data: Record<string, unknown>): string {
+  const count = pickNumber(data, 'count', 1)
+  const detectors = stringArray(data.detectors)
+  const label = `Redacted ${count} ${count === 1 ? 'secret' : 'secrets'} from agent output`
+  return detectors.length > 0 ? `${label} (${detectors.join(', ')})` : label
+}
+
+/** Label for a turn the worker's watchdog found silent (`turn_stalled`). */
+function formatTurnStalledLabel(data: Record<string, unknown>): string {
+  const minutes = Math.max(1, Math.round(pickNumber(data, 'silent_seconds', 0) / 60))
+  const label = `Turn stalled: no output for ${minutes}m`
+  switch (pickString(data, 'action')) {
+    case 'interrupt':
+      return `${label}, interrupting it`
+    case 'restart':
+      return `${label}, restarting the agent (attempt ${pickNumber(data, 'attempt', 1)})`
+    default:
+      return label
+  }
+}
+
+/** Label for a turn that ran past its workspace's turn limits (`limit_exceeded`). */
+function formatLimitExceededLabel(data: Record<string, unknown>): string {
+  const threshold = pickNumber(data, 'threshold', 0)
+  const label = pickString(data, 'limit') === 'cost'
+    ? `Turn cost $${pickNumber(data, 'cost_usd', 0).toFixed(2)} passed the $${threshold.toFixed(2)} limit`
+    : `Turn ran ${Math.round(pickNumber(data, 'elapsed_seconds', 0) / 60)}m, past the ${Math.round(threshold / 60)}m limit`
+  return data.interrupted === true ? `${label}, interrupting it` : label
+}
+
+/** Label for a turn an InterruptAgent caller stopped for a reason other than the user (`agent_interrupted`). */
+function formatAgentInterruptedLabel(data: Record<string, unknown>): string {
+  const by = ({ watchdog: 'a watchdog', budget: 'a budget limit', policy: 'a policy' } as Record<string, string>)[pickString(data, 'reason')] ?? 'LeapMux'
+  const detail = pickString(data, 'detail', null)
+  const label = `Interrupted by ${by}`
+  return detail ? `${label}: ${detail}` : label
+}
+
+/** Label for a turn an admin's emergency stop interrupted (`emergency_stop`). */
+function formatEmergencyStopLabel(data: Record<string, unknown>): string {
+  const reason = pickString(data, 'reason')
+  return reason ? `Stopped by an admin: ${reason}` : 'Stopped by an admin'
+}
+
 // ---------------------------------------------------------------------------
 // Context compaction boundary renderers
 // ---------------------------------------------------------------------------
@@ -299,13 +512,43 @@ function threadEntriesFor(
   if (t === NOTIFICATION_TYPE.PlanExecution)
     return textEntry('Executing plan')
   if (t === NOTIFICATION_TYPE.AgentError)
-    return textEntry(pickString(m, 'error', UNKNOWN_ERROR_LABEL))
+    return textEntry(formatAgentErrorLabel(m))
   if (t === NOTIFICATION_TYPE.Interrupted)
     return textEntry(INTERRUPTED_LABEL)
   if (t === NOTIFICATION_TYPE.PlanUpdated) {
     const label = planUpdatedLabel(m)
     return label !== null ? textEntry(label) : []
   }
+  if (t === NOTIFICATION_TYPE.RetryScheduled)
+    return textEntry(formatRetryScheduledLabel(m))
+  if (t === NOTIFICATION_TYPE.RetryExhausted)
+    return textEntry(formatRetryExhaustedLabel(m))
+  if (t === NOTIFICATION_TYPE.PermissionModeBlocked)
+    return textEntry(formatPermissionModeBlockedLabel(m))
+  if (t === NOTIFICATION_TYPE.DiskSpaceLow)
+    return textEntry(formatDiskSpaceLowLabel(m))
+  if (t === NOTIFICATION_TYPE.AgentStatus)
+    return textEntry(formatAgentStatusLabel(m))
+  if (t === NOTIFICATION_TYPE.ContextCompaction)
+    return textEntry(formatContextCompactionLabel(m))
+  if (t === NOTIFICATION_TYPE.ContextPressure)
+    return textEntry(formatContextPressureLabel(m))
+  if (t === NOTIFICATION_TYPE.TurnHeld)
+    return textEntry(formatTurnHeldLabel(m))
+  if (t === NOTIFICATION_TYPE.AgentAnomaly)
+    return textEntry(formatAgentAnomalyLabel(m))
+  if (t === NOTIFICATION_TYPE.EmergencyStop)
+    return textEntry(formatEmergencyStopLabel(m))
+  if (t === NOTIFICATION_TYPE.ToolUseDecided)
+    return textEntry(formatToolUseDecidedLabel(m))
+  if (t === NOTIFICATION_TYPE.SecretsRedacted)
+    return textEntry(formatSecretsRedactedLabel(m))
+  if (t === NOTIFICATION_TYPE.TurnStalled)
+    return textEntry(formatTurnStalledLabel(m))
+  if (t === NOTIFICATION_TYPE.LimitExceeded)
+    return textEntry(formatLimitExceededLabel(m))
+  if (t === NOTIFICATION_TYPE.AgentInterrupted)
+    return textEntry(formatAgentInterruptedLabel(m))
   if (t === 'system' && st === 'api_retry')
     return textEntry(formatApiRetryLabel(m))
   if (isCompactingStatus(m))
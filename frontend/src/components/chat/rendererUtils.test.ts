@@ -1,5 +1,5 @@
 import { describe, expect, it } from 'vitest'
-import { formatCompactNumber, formatTokenCount, joinMetaParts } from './rendererUtils'
+import { formatCompactNumber, formatCostUsd, formatTokenCount, joinMetaParts } from './rendererUtils'
 
 describe('formatCompactNumber', () => {
   it('numbers below 1000 are returned as-is', () => {
@@ -118,6 +118,17 @@ describe('formatTokenCount with a custom decimal precision', () => {
   })
 })
 
+describe('formatCostUsd', () => {
+  it('shows cents', () => {
+    expect(formatCostUsd(1.84)).toBe('$1.84')
+    expect(formatCostUsd(12)).toBe('$12.00')
+  })
+
+  it('keeps sub-cent costs visible', () => {
+    expect(formatCostUsd(0.0042)).toBe('$0.0042')
+  })
+})
+
 describe('joinMetaParts', () => {
   it('joins truthy strings with ` · `', () => {
     expect(joinMetaParts(['a', 'b', 'c'])).toBe('a · b · c')
@@ -28,6 +28,11 @@ export function firstNonEmptyLine(text?: string): string | null {
   return null
 }
 
+/** Format a US dollar cost with cents, keeping sub-cent costs visible (e.g. "$1.84", "$0.0042"). */
+export function formatCostUsd(usd: number): string {
+  return usd > 0 && usd < 0.01 ? `$${usd.toFixed(4)}` : `$${usd.toFixed(2)}`
+}
+
 /** Format a duration in milliseconds as a human-readable string (e.g. "5ms", "3.2s", "2m 30s", "1h 5m"). */
 export function formatDuration(ms: number): string {
   if (ms < 1000)
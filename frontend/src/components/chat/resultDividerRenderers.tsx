@@ -1,8 +1,9 @@
 import type { JSXElement } from 'solid-js'
 import type { ResultDividerModel } from './providers/registry'
-import type { AgentProvider } from '~/generated/leapmux/v1/agent_pb'
+import type { AgentProvider, MessageUsage } from '~/generated/leapmux/v1/agent_pb'
 import { resultDivider, resultErrorDetail } from './messageStyles.css'
 import { pluginFor } from './providers/registry'
+import { formatCostUsd } from './rendererUtils'
 
 /**
  * The single renderer for a `result_divider` (turn-end) message across providers.
@@ -28,10 +29,14 @@ function ResultDivider(props: { model: ResultDividerModel }): JSXElement {
  * falls back to the raw-JSON renderer when this returns null (an unrecognized
  * turn-end shape). Dispatches strictly by the message's own provider: a message
  * only reaches here after classifyMessage produced `result_divider`, which it
- * does only for a registered provider, so there is no Claude fallback.
+ * does only for a registered provider, so there is no Claude fallback. When the
+ * worker attributed a cost to the turn (`usage`), it is appended to the label.
  */
-export function renderResultDivider(parsed: unknown, agentProvider?: AgentProvider): JSXElement | null {
+export function renderResultDivider(parsed: unknown, agentProvider?: AgentProvider, usage?: MessageUsage): JSXElement | null {
   const plugin = pluginFor(agentProvider)
   const model = plugin?.resultDivider?.(parsed)
-  return model ? <ResultDivider model={model} /> : null
+  if (!model)
+    return null
+  const costUsd = usage?.costUsd ?? 0
+  return <ResultDivider model={costUsd > 0 ? { ...model, label: `${model.label} · ${formatCostUsd(costUsd)}` } : model} />
 }
@@ -95,7 +95,12 @@ export const ContextUsageGrid: Component<ContextUsageGridProps> = (props) => {
 
   const tooltip = createMemo(() => {
     const pct = percentage()
-    return pct != null ? `Context: ${Math.round(pct)}%` : undefined
+    if (pct == null)
+      return undefined
+    const subAgentTokens = props.contextUsage?.subAgentTokens
+    return subAgentTokens
+      ? `Context: ${Math.round(pct)}% (sub-agents: ${subAgentTokens.toLocaleString()} tokens)`
+      : `Context: ${Math.round(pct)}%`
   })
 
   return (
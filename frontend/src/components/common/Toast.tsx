@@ -3,7 +3,7 @@ import { createLogger } from '~/lib/logger'
 
 const log = createLogger('toast')
 
-type ToastType = 'danger' | 'success'
+type ToastType = 'danger' | 'success' | 'warning'
 
 /** Show a warning toast and log the error at warn level. */
 export function showWarnToast(message: string, err?: unknown) {
@@ -22,11 +22,17 @@ export function showInfoToast(message: string) {
   renderToast(message, 'success')
 }
 
-function renderToast(message: string, type: ToastType) {
-  const variant = type === 'success' ? 'success' : 'danger'
+/**
+ * Show a toast that stays until the user closes it, and call onClose when
+ * they do.
+ */
+export function showStickyToast(message: string, type: ToastType, onClose?: () => void) {
+  renderToast(message, type, { duration: 0, onClose })
+}
 
+function renderToast(message: string, type: ToastType, opts: { duration?: number, onClose?: () => void } = {}) {
   const toast = document.createElement('output')
-  toast.setAttribute('data-variant', variant)
+  toast.setAttribute('data-variant', type)
   toast.style.display = 'flex'
   toast.style.alignItems = 'start'
   toast.style.gap = 'var(--space-3)'
@@ -40,11 +46,14 @@ function renderToast(message: string, type: ToastType) {
   const closeBtn = document.createElement('button')
   closeBtn.setAttribute('data-close', '')
   closeBtn.textContent = '\u00D7'
-  closeBtn.onclick = () => toast.remove()
+  closeBtn.onclick = () => {
+    toast.remove()
+    opts.onClose?.()
+  }
   toast.appendChild(closeBtn)
 
   window.ot.toast.el(toast, {
     placement: 'bottom-right',
-    duration: 3000,
+    duration: opts.duration ?? 3000,
   })
 }
@@ -602,6 +602,14 @@ export const AppShell: ParentComponent = (props) => {
     void loadSections()
   }
 
+  // Re-fetch sections when the Hub sends a SectionsChanged control frame
+  // (a section or workspace move made from another tab or device).
+  channelManager.onHubControl((frame) => {
+    if (frame.events.includes(HubControlEvent.SECTIONS_CHANGED)) {
+      void loadSections()
+    }
+  })
+
   // Auto-activate workspace when navigating to org root with no workspace selected
   createEffect(() => {
     if (!isWorkspaceRoute())
@@ -1,5 +1,6 @@
 import type { Component } from 'solid-js'
-import { Show } from 'solid-js'
+import type { TerminalProfile } from '~/generated/leapmux/v1/terminal_pb'
+import { createEffect, createSignal, For, on, Show } from 'solid-js'
 import * as workerRpc from '~/api/workerRpc'
 import { DialogColumns, DialogTopRow, DialogTopSection } from '~/components/common/Dialog'
 import { isTerminalCreateDisabled } from '~/components/shell/dialogValidation'
@@ -45,6 +46,37 @@ export const NewTerminalDialog: Component<NewTerminalDialogProps> = (props) => {
     err => setError(formatErrorMessage(err, 'Failed to load shells')),
   )
 
+  // Profiles are optional: a worker that fails to list them still opens
+  // plain terminals.
+  const [profiles, setProfiles] = createSignal<TerminalProfile[]>([])
+  const [profile, setProfile] = createSignal('')
+  createEffect(on(() => worker.workerId(), (workerId) => {
+    setProfiles([])
+    setProfile('')
+    if (!workerId)
+      return
+    workerRpc.getWorkspaceTerminalProfiles(workerId, { workspaceId: props.workspaceId })
+      .then((resp) => {
+        if (worker.workerId() === workerId)
+          setProfiles(resp.available)
+      })
+      .catch(() => {})
+  }))
+
+  const profileSelector = () => (
+    <Show when={profiles().length > 0}>
+      <label>
+        Profile
+        <select value={profile()} onChange={e => setProfile(e.currentTarget.value)}>
+          <option value="">None</option>
+          <For each={profiles()}>
+            {p => <option value={p.name}>{p.name}</option>}
+          </For>
+        </select>
+      </label>
+    </Show>
+  )
+
   const shellSelector = () => (
     <label>
       Shell
@@ -74,7 +106,9 @@ export const NewTerminalDialog: Component<NewTerminalDialogProps> = (props) => {
       cols: DEFAULT_TERMINAL_COLS,
       rows: DEFAULT_TERMINAL_ROWS,
       workingDir: worker.workingDir(),
-      shell: shell(),
+      // A profile names its own shell.
+      shell: profile() ? '' : shell(),
+      profile: profile(),
       workerId: worker.workerId(),
       ...gitMode.toGitFields(),
     })
@@ -101,7 +135,8 @@ export const NewTerminalDialog: Component<NewTerminalDialogProps> = (props) => {
       <DialogTopSection>
         <DialogTopRow>
           <WorkerSelector state={worker} />
-          {shellSelector()}
+          {profileSelector()}
+          <Show when={!profile()}>{shellSelector()}</Show>
         </DialogTopRow>
       </DialogTopSection>
       <DialogColumns
@@ -85,6 +85,33 @@ export const tabNotification = style({
   flexShrink: 0,
 })
 
+export const tabTestRun = style({
+  fontSize: 'var(--text-8)',
+  fontVariantNumeric: 'tabular-nums',
+  color: 'var(--success)',
+  flexShrink: 0,
+})
+
+export const tabTestRunFailed = style({
+  color: 'var(--danger)',
+})
+
+export const tabCiStatus = style({
+  width: '6px',
+  height: '6px',
+  borderRadius: '50%',
+  backgroundColor: 'var(--success)',
+  flexShrink: 0,
+})
+
+export const tabCiStatusPending = style({
+  backgroundColor: 'var(--warning)',
+})
+
+export const tabCiStatusFailed = style({
+  backgroundColor: 'var(--danger)',
+})
+
 export const tabLabel = style({
   fontSize: 'var(--text-8)',
   opacity: 0.6,
@@ -3,6 +3,7 @@ import { fireEvent, render, screen } from '@solidjs/testing-library'
 import { beforeEach, describe, expect, it, vi } from 'vitest'
 import { TabBar } from '~/components/shell/TabBar'
 import { PreferencesProvider } from '~/context/PreferencesContext'
+import { CIState } from '~/generated/leapmux/v1/agent_pb'
 import { TabType } from '~/generated/leapmux/v1/workspace_pb'
 import { loadBrowserPrefs } from '~/lib/browserStorage'
 import { WORKSPACE_KEYBINDINGS } from '~/lib/shortcuts/defaults'
@@ -63,6 +64,11 @@ vi.mock('~/components/shell/TabBar.css', () => ({
   tabText: 'tabText',
   tabEditInput: 'tabEditInput',
   tabNotification: 'tabNotification',
+  tabTestRun: 'tabTestRun',
+  tabTestRunFailed: 'tabTestRunFailed',
+  tabCiStatus: 'tabCiStatus',
+  tabCiStatusPending: 'tabCiStatusPending',
+  tabCiStatusFailed: 'tabCiStatusFailed',
   tabClose: 'tabClose',
   tooltipTrigger: 'tooltipTrigger',
   newTabWrapper: 'newTabWrapper',
@@ -295,6 +301,29 @@ describe('tabBar readOnly prop', () => {
     expect(menuItem).toHaveTextContent('✓')
     expect(getBrowserPrefs().expandAgentThoughts).toBeUndefined()
   })
+
+  it('toggles offline message queueing and persists the browser preference', () => {
+    render(() => (
+      <PreferencesProvider>
+        <TabBar
+          {...defaultProps}
+          newTab={{ ...defaultProps.newTab, availableProviders: [] }}
+        />
+      </PreferencesProvider>
+    ))
+
+    const menuItem = screen.getAllByRole('menuitem', { name: /Queue messages while worker is offline/ })[0]
+    expect(menuItem).not.toHaveTextContent('✓')
+    expect(getBrowserPrefs().spoolOfflineMessages).toBeUndefined()
+
+    fireEvent.click(menuItem)
+    expect(menuItem).toHaveTextContent('✓')
+    expect(getBrowserPrefs().spoolOfflineMessages).toBe(true)
+
+    fireEvent.click(menuItem)
+    expect(menuItem).not.toHaveTextContent('✓')
+    expect(getBrowserPrefs().spoolOfflineMessages).toBeUndefined()
+  })
 })
 
 describe('tabBar tileActions for grid', () => {
@@ -376,3 +405,39 @@ describe('tabBar tileActions for grid', () => {
     expect(screen.queryByTestId('close-tile-menu-item')).toBeNull()
   })
 })
+
+describe('tabBar test-run badge', () => {
+  it('shows passes, or failures when any, for an agent tab', () => {
+    const passing = { ...makeTab(TabType.AGENT, 'a1', 'Agent Olivia'), agentTestRun: { runner: 'go', passed: 12n, failed: 0n, skipped: 1n } } as Tab
+    const failing = { ...makeTab(TabType.AGENT, 'a2', 'Agent Liam'), agentTestRun: { runner: 'jest', passed: 5n, failed: 2n, skipped: 0n } } as Tab
+    render(() => (
+      <PreferencesProvider>
+        <TabBar {...defaultProps} tabs={[passing, failing, makeTab(TabType.AGENT, 'a3', 'Agent Emma')]} />
+      </PreferencesProvider>
+    ))
+    const badges = screen.getAllByTestId('tab-test-run')
+    expect(badges).toHaveLength(2)
+    expect(badges[0]).toHaveTextContent('\u2713 12')
+    expect(badges[0]).not.toHaveClass('tabTestRunFailed')
+    expect(badges[1]).toHaveTextContent('\u2717 2')
+    expect(badges[1]).toHaveClass('tabTestRunFailed')
+  })
+})
+
+describe('tabBar CI status badge', () => {
+  it('colors the dot by state and skips commits with no checks', () => {
+    const failing = { ...makeTab(TabType.AGENT, 'a1', 'Agent Olivia'), agentCiStatus: { branch: 'fix', commitSha: 'abc', state: CIState.FAILURE, checks: [{ name: 'test', state: CIState.FAILURE }] } } as unknown as Tab
+    const pending = { ...makeTab(TabType.AGENT, 'a2', 'Agent Liam'), agentCiStatus: { branch: 'main', commitSha: 'def', state: CIState.PENDING, checks: [] } } as unknown as Tab
+    const none = { ...makeTab(TabType.AGENT, 'a3', 'Agent Emma'), agentCiStatus: { branch: 'main', commitSha: 'ghi', state: CIState.UNSPECIFIED, checks: [] } } as unknown as Tab
+    render(() => (
+      <PreferencesProvider>
+        <TabBar {...defaultProps} tabs={[failing, pending, none]} />
+      </PreferencesProvider>
+    ))
+    const badges = screen.getAllByTestId('tab-ci-status')
+    expect(badges).toHaveLength(2)
+    expect(badges[0]).toHaveClass('tabCiStatusFailed')
+    expect(badges[0]).toHaveAttribute('data-state', 'failed')
+    expect(badges[1]).toHaveClass('tabCiStatusPending')
+  })
+})
@@ -1,6 +1,6 @@
 import type { Component, JSX } from 'solid-js'
 import type { TileActions } from './TileActionsMenu'
-import type { AgentProvider } from '~/generated/leapmux/v1/agent_pb'
+import type { AgentCIStatus, AgentProvider, TestRun } from '~/generated/leapmux/v1/agent_pb'
 import type { TerminalStatus } from '~/generated/leapmux/v1/terminal_pb'
 import type { Tab } from '~/stores/tab.types'
 import { createDroppable, createSortable, SortableProvider, transformStyle } from '@thisbeyond/solid-dnd'
@@ -18,11 +18,12 @@ import { IconButton, IconButtonState } from '~/components/common/IconButton'
 import { TabTypeIcon } from '~/components/common/TabTypeIcon'
 import { Tooltip } from '~/components/common/Tooltip'
 import { usePreferences } from '~/context/PreferencesContext'
+import { CIState } from '~/generated/leapmux/v1/agent_pb'
 import { TabType } from '~/generated/leapmux/v1/workspace_pb'
 import { useMruProviders } from '~/hooks/useMruProviders'
 import { getShortcutHintsText, shortcutHint } from '~/lib/shortcuts/display'
 import { canCloseTab, tabDisplayLabel, tabKey } from '~/stores/tab.helpers'
-import { isTerminalTab } from '~/stores/tab.types'
+import { isAgentTab, isTerminalTab } from '~/stores/tab.types'
 import { menuSectionHeader } from '~/styles/shared.css'
 import * as styles from './TabBar.css'
 import { TABBAR_ZONE_PREFIX, useTabDrag } from './TabDragContext'
@@ -61,6 +62,57 @@ const TabTextWithTooltip: Component<{ label: string, status?: TerminalStatus }>
   )
 }
 
+/** Pass/fail badge for an agent's latest test run: failures when any, else passes. */
+const TestRunBadge: Component<{ run: TestRun }> = (props) => {
+  const failed = () => props.run.failed > 0n
+  const title = () => {
+    const coverage = props.run.coveragePercent === undefined ? '' : `, ${props.run.coveragePercent.toFixed(1)}% coverage`
+    return `${props.run.runner}: ${props.run.passed} passed, ${props.run.failed} failed, ${props.run.skipped} skipped${coverage}`
+  }
+  return (
+    <Tooltip text={title()}>
+      <span
+        class={styles.tabTestRun}
+        classList={{ [styles.tabTestRunFailed]: failed() }}
+        data-testid="tab-test-run"
+      >
+        {failed() ? `\u2717 ${props.run.failed}` : `\u2713 ${props.run.passed}`}
+      </span>
+    </Tooltip>
+  )
+}
+
+const CI_STATE_LABEL: Partial<Record<CIState, string>> = {
+  [CIState.PENDING]: 'running',
+  [CIState.SUCCESS]: 'passed',
+  [CIState.FAILURE]: 'failed',
+}
+
+/** Dot for the CI status of an agent's branch head; none when no checks are reported. */
+const CIStatusBadge: Component<{ status: AgentCIStatus }> = (props) => {
+  const label = () => CI_STATE_LABEL[props.status.state]
+  const title = () => {
+    const failed = props.status.checks.filter(c => c.state === CIState.FAILURE).map(c => c.name)
+    const where = props.status.branch || props.status.commitSha.slice(0, 12)
+    return `CI ${label()} on ${where}${failed.length > 0 ? `: ${failed.join(', ')}` : ''}`
+  }
+  return (
+    <Show when={label()}>
+      <Tooltip text={title()}>
+        <span
+          class={styles.tabCiStatus}
+          classList={{
+            [styles.tabCiStatusPending]: props.status.state === CIState.PENDING,
+            [styles.tabCiStatusFailed]: props.status.state === CIState.FAILURE,
+          }}
+          data-testid="tab-ci-status"
+          data-state={label()}
+        />
+      </Tooltip>
+    </Show>
+  )
+}
+
 function tabTypeLabel(type: TabType): string {
   switch (type) {
     case TabType.AGENT: return 'agent'
@@ -276,6 +328,12 @@ export const TabBar: Component<TabBarProps> = (props) => {
             }}
           />
         </Show>
+        <Show when={isAgentTab(tab) ? tab.agentTestRun : undefined}>
+          {run => <TestRunBadge run={run()} />}
+        </Show>
+        <Show when={isAgentTab(tab) ? tab.agentCiStatus : undefined}>
+          {status => <CIStatusBadge status={status()} />}
+        </Show>
         <Show when={tab.hasNotification}>
           <span class={styles.tabNotification} data-testid="tab-notification" />
         </Show>
@@ -363,6 +421,15 @@ export const TabBar: Component<TabBarProps> = (props) => {
       >
         <DropdownMenuItemContent label={renderToggleMenuLabel('Show hidden messages', prefs.showHiddenMessages())} />
       </button>
+      <button
+        role="menuitem"
+        onClick={(e) => {
+          e.preventDefault()
+          prefs.setSpoolOfflineMessages(!prefs.spoolOfflineMessages())
+        }}
+      >
+        <DropdownMenuItemContent label={renderToggleMenuLabel('Queue messages while worker is offline', prefs.spoolOfflineMessages())} />
+      </button>
     </>
   )
 
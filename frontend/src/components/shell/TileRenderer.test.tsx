@@ -18,6 +18,7 @@ vi.mock('~/context/PreferencesContext', () => ({
     setExpandAgentThoughts: () => {},
     showHiddenMessages: () => false,
     setShowHiddenMessages: () => {},
+    spoolOfflineMessages: () => false,
   }),
 }))
 
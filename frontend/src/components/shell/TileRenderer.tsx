@@ -12,6 +12,7 @@ import type { createLoadingSignal } from '~/hooks/createLoadingSignal'
 import type { ImperativeRef } from '~/lib/imperativeRef'
 import type { createAgentSessionStore } from '~/stores/agentSession.store'
 import type { createChatStore } from '~/stores/chat.store'
+import type { SpooledMessage } from '~/stores/chatOfflineSpool'
 import type { SavedViewportScroll } from '~/stores/chatTypes'
 import type { createControlStore } from '~/stores/control.store'
 import type { createFloatingWindowStore } from '~/stores/floatingWindow.store'
@@ -22,6 +23,7 @@ import type { createTabStore } from '~/stores/tab.store'
 import type { AgentTab, FileTab, Tab, TerminalTab } from '~/stores/tab.types'
 import { create } from '@bufbuild/protobuf'
 import { createEffect, createMemo, For, mapArray, onCleanup, Show } from 'solid-js'
+import { isWorkerOffline } from '~/api/workerErrors'
 import * as workerRpc from '~/api/workerRpc'
 import { AgentEditorPanel } from '~/components/chat/AgentEditorPanel'
 import { getCachedMarkPreview, warmMarkPreview } from '~/components/chat/chatMarkPreview'
@@ -31,6 +33,7 @@ import { ConfirmDialog } from '~/components/common/ConfirmDialog'
 import { showWarnToast } from '~/components/common/Toast'
 import { FileViewer } from '~/components/fileviewer/FileViewer'
 import { TerminalView } from '~/components/terminal/TerminalView'
+import { usePreferences } from '~/context/PreferencesContext'
 import { AgentChatMessageSchema, AgentStatus, ContentCompression, MessageSource } from '~/generated/leapmux/v1/agent_pb'
 import { GitFileStatusCode } from '~/generated/leapmux/v1/common_pb'
 import { TabType } from '~/generated/leapmux/v1/workspace_pb'
@@ -40,6 +43,7 @@ import { createImperativeRef } from '~/lib/imperativeRef'
 import { relativizePath } from '~/lib/paths'
 import { pluralize } from '~/lib/plural'
 import { formatFileMention, formatFileQuote } from '~/lib/quoteUtils'
+import { OFFLINE_SPOOL_PENDING_LABEL, OFFLINE_SPOOL_TTL_MS, spoolMessage } from '~/stores/chatOfflineSpool'
 import { appendText, insertIntoMruAgentEditor } from '~/stores/editorRef.store'
 import { buildTilePredicateMap, CLOSE_MODE_NONE } from '~/stores/layout.store'
 import { agentTabToInfo } from '~/stores/tab.helpers'
@@ -823,6 +827,7 @@ export function createTileRenderer(opts: TileRendererOpts) {
 
   const FocusedAgentEditorPanel: Component<{ containerHeight: number }> = (props) => {
     const agentId = () => focusedAgentId()!
+    const prefs = usePreferences()
     return (
       <AgentEditorPanel
         agentId={agentId()}
@@ -896,16 +901,37 @@ export function createTileRenderer(opts: TileRendererOpts) {
             return
           }
 
+          const workerId = sendAgent?.workerId ?? ''
           try {
-            await workerRpc.sendAgentMessage(sendAgent?.workerId ?? '', {
+            // The local id doubles as the idempotency key, so a spooled
+            // replay of a send that did land is suppressed by the worker.
+            await workerRpc.sendAgentMessage(workerId, {
               agentId: id,
               content,
               attachments: protoAttachments,
+              idempotencyKey: localId,
             })
             // Keep the optimistic message until the persisted message arrives.
             // chatStore.addMessage() reconciles the matching server echo in place.
           }
-          catch {
+          catch (err) {
+            // Worker offline and the user opted in: park the message until
+            // the worker reconnects (useWorkspaceConnection flushes it).
+            if (isWorkerOffline(err) && prefs.spoolOfflineMessages()) {
+              const attachments = optimisticPayload.attachments as SpooledMessage['attachments'] | undefined
+              const now = Date.now()
+              spoolMessage(workerId, {
+                localId,
+                agentId: id,
+                content,
+                attachments: attachments ?? [],
+                queuedAt: now,
+                expiresAt: now + OFFLINE_SPOOL_TTL_MS,
+              })
+              chatStore.setMessagePendingLabel(localId, OFFLINE_SPOOL_PENDING_LABEL)
+              chatStore.persistLocalMessage(id, localId, content, '', attachments)
+              return
+            }
             persistFailed('Failed to deliver')
           }
         }}
@@ -17,7 +17,7 @@ import { openAgentRequestOptions } from '~/components/chat/providers/registry'
 import { ACCOUNT_DEFAULT_MODEL, OPTION_ID_EFFORT, OPTION_ID_MODEL, OPTION_ID_PERMISSION_MODE, optionGroupLabel } from '~/components/chat/settingsGroups'
 import { showWarnToast } from '~/components/common/Toast'
 import { awaitCloseResult, warnWorktreeUnreachable } from '~/components/shell/closeResultToast'
-import { AgentProvider } from '~/generated/leapmux/v1/agent_pb'
+import { AgentProvider, InterruptReason } from '~/generated/leapmux/v1/agent_pb'
 import { WorktreeAction } from '~/generated/leapmux/v1/common_pb'
 import { TabType } from '~/generated/leapmux/v1/workspace_pb'
 import { base64ToUint8Array } from '~/lib/base64'
@@ -217,7 +217,7 @@ export function useAgentOperations(props: UseAgentOperationsProps) {
   const handleInterrupt = async (agentId: string) => {
     try {
       const workerId = getAgentWorkerId(agentId)
-      await workerRpc.interruptAgent(workerId, { agentId })
+      await workerRpc.interruptAgent(workerId, { agentId, reasonKind: InterruptReason.USER })
     }
     catch (err) {
       showWarnToast('Failed to interrupt', err)
@@ -4,6 +4,7 @@ import type { HLCClock, PendingOpsManager } from '~/lib/crdt'
 import type { ActiveClientStore } from '~/lib/presence/activeClient'
 import { fromBinary } from '@bufbuild/protobuf'
 import { createEffect, createSignal, on, onCleanup } from 'solid-js'
+import { hubWebSocketOrigin } from '~/api/hubOrigin'
 import { isTauriApp, parseRelayClosePayload, platformBridge } from '~/api/platformBridge'
 import { WatchOrgEventSchema } from '~/generated/leapmux/v1/org_ops_pb'
 import { base64ToUint8Array } from '~/lib/base64'
@@ -406,7 +407,7 @@ export function useOrgEvents(opts: UseOrgEventsOpts): OrgEventsHook {
 // the two must stay in lockstep: a rename of a query key on the Go side has no
 // compile-time or fixture check to catch a missed edit here.
 function defaultBuildWsUrl(orgId: string, workspaceIds: string[]): string {
-  const base = window.location.origin.replace(/^http/, 'ws')
+  const base = hubWebSocketOrigin()
   const params = new URLSearchParams({ org_id: orgId })
   if (workspaceIds.length > 0)
     params.set('workspace_ids', workspaceIds.join(','))
@@ -52,6 +52,7 @@ interface TabOverrides {
   id?: string
   cols?: number
   rows?: number
+  restorable?: boolean
 }
 
 const disposers: Array<() => void> = []
@@ -366,6 +367,32 @@ describe('useterminaloperations.handleterminalinput', () => {
   })
 })
 
+describe('useterminaloperations.restore', () => {
+  it('restarts a restorable EXITED terminal once without waiting for Enter', async () => {
+    const { tabStore } = setup(TerminalStatus.EXITED, { restorable: true })
+    await flush()
+    expect(restartTerminalMock).toHaveBeenCalledTimes(1)
+    expect(restartTerminalMock.mock.calls[0][1]).toMatchObject({ terminalId: 'tid-1', cols: 100, rows: 30 })
+    expect(tabStore.getTerminalTab('tid-1')?.restorable).toBeUndefined()
+  })
+
+  it('leaves a failed restore to Enter without a toast', async () => {
+    restartTerminalMock.mockImplementation(async () => {
+      throw new Error('worker unreachable')
+    })
+    setup(TerminalStatus.EXITED, { restorable: true })
+    await flush()
+    expect(restartTerminalMock).toHaveBeenCalledTimes(1)
+    expect(showWarnToastMock).not.toHaveBeenCalled()
+  })
+
+  it('does not restart an EXITED terminal whose shell exited', async () => {
+    setup(TerminalStatus.EXITED)
+    await flush()
+    expect(restartTerminalMock).not.toHaveBeenCalled()
+  })
+})
+
 describe('useterminaloperations.availableshells', () => {
   it('loads shells from listAvailableShells on mount when workspace + worker are present', async () => {
     listAvailableShellsMock.mockResolvedValueOnce({
@@ -7,6 +7,7 @@ import type { createLayoutStore } from '~/stores/layout.store'
 import type { createTabStore } from '~/stores/tab.store'
 
 import type { TerminalTab } from '~/stores/tab.types'
+import { createEffect } from 'solid-js'
 import * as workerRpc from '~/api/workerRpc'
 import { showWarnToast } from '~/components/common/Toast'
 import { awaitCloseResult, warnWorktreeUnreachable } from '~/components/shell/closeResultToast'
@@ -16,6 +17,7 @@ import { TerminalStatus } from '~/generated/leapmux/v1/terminal_pb'
 import { TabType } from '~/generated/leapmux/v1/workspace_pb'
 import { useAvailableShells } from '~/hooks/useAvailableShells'
 import { createInflightCache } from '~/lib/inflightCache'
+import { createLogger } from '~/lib/logger'
 import { DEFAULT_TERMINAL_COLS, DEFAULT_TERMINAL_ROWS } from '~/lib/terminal'
 import { resolveOptimisticGitInfo, tabKey } from '~/stores/tab.helpers'
 
@@ -24,6 +26,8 @@ import { resolveOptimisticGitInfo, tabKey } from '~/stores/tab.helpers'
 // keystroke (or the autorepeat from a held key) doesn't fire a restart.
 const ENTER_KEY_CR = 0x0D
 
+const log = createLogger('useTerminalOperations')
+
 export interface UseTerminalOperationsProps {
   org: { orgId: () => string }
   tabStore: ReturnType<typeof createTabStore>
@@ -57,6 +61,39 @@ export function useTerminalOperations(props: UseTerminalOperationsProps) {
   // promise's eventual rejection (which would multi-toast).
   const restartInflight = createInflightCache<string, void>()
 
+  // Shared by Enter on an exited tab and the automatic restore below.
+  const restartTerminalTab = (workspaceId: string, tab: TerminalTab) =>
+    restartInflight.run(tab.id, async () => {
+      await workerRpc.restartTerminal(tab.workerId ?? '', {
+        orgId: props.org.orgId(),
+        workspaceId,
+        terminalId: tab.id,
+        cols: tab.cols ?? DEFAULT_TERMINAL_COLS,
+        rows: tab.rows ?? DEFAULT_TERMINAL_ROWS,
+      })
+    })
+
+  // A tab whose worker went away under a live shell comes back by
+  // itself, the way an agent resumes on its next message; a worker that
+  // persists terminals reattaches the shell that outlived it. Clearing
+  // the flag first makes it one attempt per hydration, so a failure
+  // falls back to Enter instead of looping.
+  createEffect(() => {
+    const ws = props.activeWorkspace()
+    if (!ws || !props.isActiveWorkspaceMutatable())
+      return
+    for (const tab of props.tabStore.state.tabs) {
+      if (tab.type !== TabType.TERMINAL || !tab.restorable || tab.status !== TerminalStatus.EXITED)
+        continue
+      props.tabStore.updateTab(TabType.TERMINAL, tab.id, { restorable: undefined })
+      if (restartInflight.has(tab.id))
+        continue
+      restartTerminalTab(ws.id, tab).catch((err) => {
+        log.warn('failed to restore terminal', { terminalId: tab.id, err })
+      })
+    }
+  })
+
   // Shared open path for both the default-shell quick-action and the
   // shell-picker dropdown. The only call-site differences captured by
   // the args are which loading setter fires, which `shell` is sent, and
@@ -151,15 +188,7 @@ export function useTerminalOperations(props: UseTerminalOperationsProps) {
       if (restartInflight.has(terminalId))
         return
       try {
-        await restartInflight.run(terminalId, async () => {
-          await workerRpc.restartTerminal(tab.workerId ?? '', {
-            orgId: props.org.orgId(),
-            workspaceId: ws.id,
-            terminalId,
-            cols: tab.cols ?? DEFAULT_TERMINAL_COLS,
-            rows: tab.rows ?? DEFAULT_TERMINAL_ROWS,
-          })
-        })
+        await restartTerminalTab(ws.id, tab)
       }
       catch (err) {
         showWarnToast('Failed to restart terminal', err)
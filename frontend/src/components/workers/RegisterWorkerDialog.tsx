@@ -5,6 +5,7 @@ import Copy from 'lucide-solid/icons/copy'
 import Mail from 'lucide-solid/icons/mail'
 import { createMemo, createSignal, onCleanup, onMount, Show } from 'solid-js'
 import { workerClient } from '~/api/clients'
+import { hubOrigin } from '~/api/hubOrigin'
 import { Dialog } from '~/components/common/Dialog'
 import { Icon } from '~/components/common/Icon'
 import { useAuth } from '~/context/AuthContext'
@@ -37,9 +38,9 @@ export const RegisterWorkerDialog: Component<RegisterWorkerDialogProps> = (props
     if (!k)
       return ''
     // Prefer the URL the hub advertises (unix:/npipe: in desktop's
-    // local-only mode); fall back to the browser origin everywhere else
+    // local-only mode); fall back to the hub origin everywhere else
     // since that already reflects any reverse-proxy hostname.
-    const hubUrl = getWorkerHubUrl() || window.location.origin
+    const hubUrl = getWorkerHubUrl() || hubOrigin()
     return `leapmux worker --hub ${hubUrl} --registration-key ${k}`
   })
 
@@ -1,6 +1,9 @@
 /// <reference types="vitest/globals" />
+import type { WorkerConnectionStats } from '~/generated/leapmux/v1/worker_pb'
+import { create } from '@bufbuild/protobuf'
 import { render, screen } from '@solidjs/testing-library'
 import { afterEach, beforeAll, describe, expect, it, vi } from 'vitest'
+import { WorkerConnectionStatsSchema } from '~/generated/leapmux/v1/worker_pb'
 import { WorkerContextMenu } from './WorkerContextMenu'
 
 // Mock the modules that affect visibility.
@@ -19,7 +22,7 @@ beforeAll(() => {
   HTMLElement.prototype.togglePopover = vi.fn()
 })
 
-function renderMenu(opts?: { hasTunnels?: boolean, autoRegistered?: boolean }) {
+function renderMenu(opts?: { hasTunnels?: boolean, autoRegistered?: boolean, connectionStats?: WorkerConnectionStats }) {
   const onAddTunnel = vi.fn()
   const onDeleteAllTunnels = vi.fn()
   const onDeregister = vi.fn()
@@ -27,6 +30,7 @@ function renderMenu(opts?: { hasTunnels?: boolean, autoRegistered?: boolean }) {
   render(() => (
     <WorkerContextMenu
       workerInfo={{ name: 'test', os: 'linux', arch: 'amd64', homeDir: '/home', version: '1.0', commitHash: '', buildTime: '', updatedAt: Date.now() }}
+      connectionStats={opts?.connectionStats}
       autoRegistered={opts?.autoRegistered ?? false}
       hasTunnels={opts?.hasTunnels ?? false}
       onAddTunnel={onAddTunnel}
@@ -72,6 +76,19 @@ describe('workerContextMenu', () => {
     expect(onAddTunnel).toHaveBeenCalled()
   })
 
+  it('shows the reported hub connection', () => {
+    renderMenu({
+      connectionStats: create(WorkerConnectionStatsSchema, {
+        hubUrl: 'https://hub-b.example',
+        connects: 4,
+        failovers: 1,
+      }),
+    })
+    expect(screen.getByText('https://hub-b.example')).toBeInTheDocument()
+    expect(screen.getByText('3 (1 failover)')).toBeInTheDocument()
+    expect(screen.queryByText('Last drop:')).not.toBeInTheDocument()
+  })
+
   it('"deregister..." visible for manually-registered workers', () => {
     renderMenu({ autoRegistered: false })
     expect(screen.getByText('Deregister...')).toBeInTheDocument()
@@ -1,4 +1,5 @@
 import type { Component } from 'solid-js'
+import type { WorkerConnectionStats } from '~/generated/leapmux/v1/worker_pb'
 import type { WorkerInfo } from '~/lib/workerInfoCache'
 import { For, Show } from 'solid-js'
 import { isTunnelAvailable } from '~/api/platformBridge'
@@ -12,6 +13,9 @@ import * as styles from './workerContextMenu.css'
 
 interface WorkerContextMenuProps {
   workerInfo: WorkerInfo | null
+  // What the worker reported about its hub connection; absent for a
+  // worker that predates the report.
+  connectionStats?: WorkerConnectionStats
   // True for the in-process worker the solo launcher auto-registers.
   // The deregister handler refuses these (it would just re-register on
   // next start), so the menu item would be a dead-end click.
@@ -43,6 +47,16 @@ export const WorkerContextMenu: Component<WorkerContextMenuProps> = (props) => {
     if (info.buildTime)
       rows.push({ label: 'Built at:', value: info.buildTime, kind: 'relative_time' })
     rows.push({ label: 'OS:', value: `${info.os} (${info.arch})`, kind: 'text' })
+    const stats = props.connectionStats
+    if (stats) {
+      rows.push({ label: 'Hub:', value: stats.hubUrl, kind: 'text' })
+      let reconnects = String(Math.max(stats.connects - 1, 0))
+      if (stats.failovers > 0)
+        reconnects += ` (${stats.failovers} failover${stats.failovers === 1 ? '' : 's'})`
+      rows.push({ label: 'Reconnects:', value: reconnects, kind: 'text' })
+      if (stats.lastDisconnectAt)
+        rows.push({ label: 'Last drop:', value: stats.lastDisconnectAt, kind: 'relative_time' })
+    }
     return rows
   }
 
@@ -58,6 +72,16 @@ export const WorkerContextMenu: Component<WorkerContextMenuProps> = (props) => {
       os: info.os,
       arch: info.arch,
       homeDir: info.homeDir,
+      connection: props.connectionStats
+        ? {
+            hubUrl: props.connectionStats.hubUrl,
+            connects: props.connectionStats.connects,
+            failedAttempts: props.connectionStats.failedAttempts,
+            failovers: props.connectionStats.failovers,
+            lastDisconnectReason: props.connectionStats.lastDisconnectReason || undefined,
+            lastDisconnectAt: props.connectionStats.lastDisconnectAt || undefined,
+          }
+        : undefined,
     })
   }
 
@@ -117,6 +117,14 @@ describe('workerSectionContent', () => {
     expect(screen.getAllByText('test-worker').length).toBeGreaterThanOrEqual(1)
   })
 
+  it('flags a worker the hub refused as too old', () => {
+    const old = create(WorkerSchema, { id: 'w2', registeredBy: 'user-1', upgradeRequired: true })
+    renderSection({ workers: [makeWorker('w1'), old] })
+    const badges = screen.getAllByTestId('worker-upgrade-required')
+    expect(badges).toHaveLength(1)
+    expect(badges[0]).toHaveTextContent('Upgrade required')
+  })
+
   it('shows dash when workerInfo is null', () => {
     const tunnelStore = createTunnelStore()
     render(() => (
@@ -84,6 +84,13 @@ export const WorkerSectionContent: Component<WorkerSectionContentProps> = (props
                       {workerName()}
                     </span>
                   </Tooltip>
+                  <Show when={worker.upgradeRequired}>
+                    <Tooltip text="This worker is too old for the hub. Upgrade it to reconnect.">
+                      <span class={styles.upgradeRequired} data-testid="worker-upgrade-required">
+                        Upgrade required
+                      </span>
+                    </Tooltip>
+                  </Show>
                   <div
                     class={`${styles.statusDot} ${statusClass[status()]}`}
                     data-status={status()}
@@ -91,6 +98,7 @@ export const WorkerSectionContent: Component<WorkerSectionContentProps> = (props
                   <div class={sidebarActions}>
                     <WorkerContextMenu
                       workerInfo={props.workerInfo(worker.id)}
+                      connectionStats={worker.connectionStats}
                       autoRegistered={worker.autoRegistered}
                       hasTunnels={workerTunnels().length > 0}
                       onAddTunnel={() => props.onAddTunnel(worker)}
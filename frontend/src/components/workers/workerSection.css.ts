@@ -15,6 +15,12 @@ export const statusDisconnected = style({
   background: 'var(--danger)',
 })
 
+export const upgradeRequired = style({
+  fontSize: 'var(--text-8)',
+  color: 'var(--danger)',
+  flexShrink: 0,
+})
+
 export const tunnelItem = style({
   cursor: 'default',
   paddingLeft: '20px',
@@ -7,6 +7,7 @@ import { platformBridge } from '~/api/platformBridge'
 import { loadTimeouts, setOnAuthError } from '~/api/transport'
 import { channelManager } from '~/api/workerRpc'
 import { LoginRequestSchema } from '~/generated/leapmux/v1/auth_pb'
+import { showAnnouncement } from '~/lib/announcements'
 import { formatErrorMessage } from '~/lib/errors'
 import { createLogger } from '~/lib/logger'
 import { isSoloMode, loadSystemInfo } from '~/lib/systemInfo'
@@ -84,6 +85,8 @@ export const AuthProvider: ParentComponent = (props) => {
     try {
       const resp = await authClient.getCurrentUser({})
       setUser(resp.user ?? null)
+      for (const a of resp.announcements ?? [])
+        showAnnouncement(a)
       loadTimeouts().catch(() => {})
     }
     catch {
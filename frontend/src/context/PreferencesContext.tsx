@@ -51,6 +51,12 @@ interface PreferencesState {
    */
   revealAfterDownload: () => boolean
   setRevealAfterDownload: (value: boolean) => void
+  /**
+   * Whether a message sent while its worker is offline is queued and
+   * delivered on reconnect instead of failing.
+   */
+  spoolOfflineMessages: () => boolean
+  setSpoolOfflineMessages: (value: boolean) => void
   /** Resolved enter key mode. */
   enterKeyMode: () => EnterKeyMode
   setEnterKeyMode: (value: EnterKeyMode) => void
@@ -238,6 +244,14 @@ export const PreferencesProvider: ParentComponent = (props) => {
     updateBrowserPref('revealAfterDownload', value ? undefined : false)
   }
 
+  const [spoolOfflineMessages, setSpoolOfflineMessagesSignal] = createSignal(
+    initialPrefs.spoolOfflineMessages === true,
+  )
+  const setSpoolOfflineMessages = (value: boolean) => {
+    setSpoolOfflineMessagesSignal(value)
+    updateBrowserPref('spoolOfflineMessages', value || undefined)
+  }
+
   const [enterKeyMode, setEnterKeyModeSignal] = createSignal<EnterKeyMode>(
     initialPrefs.enterKeyMode ?? 'cmd-enter-sends',
   )
@@ -366,6 +380,8 @@ export const PreferencesProvider: ParentComponent = (props) => {
       setShowHiddenMessages,
       revealAfterDownload,
       setRevealAfterDownload,
+      spoolOfflineMessages,
+      setSpoolOfflineMessages,
       enterKeyMode,
       setEnterKeyMode,
       customKeybindings,
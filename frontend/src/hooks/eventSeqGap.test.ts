@@ -0,0 +1,37 @@
+import { describe, expect, it } from 'vitest'
+import { agentSeqKey, createEventSeqTracker, terminalSeqKey } from './eventSeqGap'
+
+describe('createEventSeqTracker', () => {
+  it('accepts a contiguous sequence', () => {
+    const tracker = createEventSeqTracker()
+    expect(tracker.observe(agentSeqKey('a'), 1n)).toBe(false)
+    expect(tracker.observe(agentSeqKey('a'), 2n)).toBe(false)
+    expect(tracker.heartbeat(agentSeqKey('a'), 2n)).toBe(false)
+  })
+
+  it('counts each entity separately', () => {
+    const tracker = createEventSeqTracker()
+    expect(tracker.observe(agentSeqKey('a'), 1n)).toBe(false)
+    expect(tracker.observe(terminalSeqKey('a'), 1n)).toBe(false)
+    expect(tracker.observe(agentSeqKey('b'), 1n)).toBe(false)
+  })
+
+  it('ignores replayed events', () => {
+    const tracker = createEventSeqTracker()
+    expect(tracker.observe(agentSeqKey('a'), 0n)).toBe(false)
+    expect(tracker.observe(agentSeqKey('a'), 1n)).toBe(false)
+  })
+
+  it('reports a skipped number', () => {
+    const tracker = createEventSeqTracker()
+    tracker.observe(agentSeqKey('a'), 1n)
+    expect(tracker.observe(agentSeqKey('a'), 3n)).toBe(true)
+  })
+
+  it('reports a heartbeat ahead of what arrived', () => {
+    const tracker = createEventSeqTracker()
+    expect(tracker.heartbeat(agentSeqKey('a'), 0n)).toBe(false)
+    tracker.observe(agentSeqKey('a'), 1n)
+    expect(tracker.heartbeat(agentSeqKey('a'), 2n)).toBe(true)
+  })
+})
@@ -0,0 +1,60 @@
+// Tracks the worker's per-subscription event_seq on one WatchEvents
+// stream and reports when an event went missing. Live events count up
+// from 1 per watched agent or terminal; replayed events carry 0 and are
+// outside the sequence. A number past the next expected one, or a
+// heartbeat naming a number the stream has not delivered yet, means the
+// worker sent (or meant to send) an event this client never got -- the
+// caller restarts the stream so its replay fills the hole.
+//
+// One tracker per stream: a resubscribe starts every count again at 1.
+
+import type { WatchEventsResponse } from '~/generated/leapmux/v1/workspace_pb'
+
+export interface EventSeqTracker {
+  // Records a live event's number. Returns true when it reveals a gap.
+  observe: (key: string, eventSeq: bigint) => boolean
+  // Checks a heartbeat's last-sent number. Returns true when it reveals a gap.
+  heartbeat: (key: string, eventSeq: bigint) => boolean
+}
+
+export function createEventSeqTracker(): EventSeqTracker {
+  const seen = new Map<string, bigint>()
+  return {
+    observe(key, eventSeq) {
+      if (eventSeq === 0n)
+        return false
+      const last = seen.get(key) ?? 0n
+      if (eventSeq > last)
+        seen.set(key, eventSeq)
+      return eventSeq > last + 1n
+    },
+    heartbeat(key, eventSeq) {
+      return eventSeq > (seen.get(key) ?? 0n)
+    },
+  }
+}
+
+export function agentSeqKey(agentId: string): string {
+  return `agent:${agentId}`
+}
+
+export function terminalSeqKey(terminalId: string): string {
+  return `terminal:${terminalId}`
+}
+
+// revealsGap feeds one WatchEvents frame to tracker and reports whether
+// it shows an event was lost.
+export function revealsGap(tracker: EventSeqTracker, response: WatchEventsResponse): boolean {
+  switch (response.event.case) {
+    case 'agentEvent':
+      return tracker.observe(agentSeqKey(response.event.value.agentId), response.eventSeq)
+    case 'terminalEvent':
+      return tracker.observe(terminalSeqKey(response.event.value.terminalId), response.eventSeq)
+    case 'heartbeat': {
+      const { agents, terminals } = response.event.value
+      return agents.some(a => tracker.heartbeat(agentSeqKey(a.id), a.eventSeq))
+        || terminals.some(t => tracker.heartbeat(terminalSeqKey(t.id), t.eventSeq))
+    }
+  }
+  return false
+}
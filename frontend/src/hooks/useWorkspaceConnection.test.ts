@@ -1830,6 +1830,11 @@ describe('wireSessionInfoToUpdates', () => {
     expect(updates.streamingType).toBe('plan')
   })
 
+  it('passes the launch env through', () => {
+    const updates = wireSessionInfoToUpdates({ env: { NODE_ENV: 'test' } })
+    expect(updates.env).toEqual({ NODE_ENV: 'test' })
+  })
+
   it('deep-maps rate_limits tiers', () => {
     const updates = wireSessionInfoToUpdates({
       rate_limits: { five_hour: { status: 'allowed', utilization: 0.5 } },
@@ -5,21 +5,26 @@ import type { createLoadingSignal } from '~/hooks/createLoadingSignal'
 import type { ParsedMessageContent } from '~/lib/messageParser'
 import type { createAgentSessionStore, RateLimitInfo } from '~/stores/agentSession.store'
 import type { createChatStore } from '~/stores/chat.store'
+import type { SpooledMessage } from '~/stores/chatOfflineSpool'
 import type { createControlStore } from '~/stores/control.store'
 import type { createTabStore } from '~/stores/tab.store'
 import type { AgentTab, Tab } from '~/stores/tab.types'
 import type { WorkspaceStoreRegistryType } from '~/stores/workspaceStoreRegistry'
 import { batch, createEffect, createSignal, onCleanup, untrack } from 'solid-js'
+import { isWorkerOffline } from '~/api/workerErrors'
 import { channelManager, sendAgentMessage, watchEventsViaChannel } from '~/api/workerRpc'
 import { classifyAgentMessage, shouldClearStreamingText } from '~/components/chat/messageClassification'
 import { pluginFor, providerFor } from '~/components/chat/providers/registry'
 import { mergeStableOptionGroupRefs, OPTION_ID_MODEL, optionGroup } from '~/components/chat/settingsGroups'
-import { showWarnToast } from '~/components/common/Toast'
+import { showInfoToast, showWarnToast } from '~/components/common/Toast'
 import { getTerminalInstance } from '~/components/terminal/TerminalView'
-import { AgentStatus, MessageSource, WatchReplayMode } from '~/generated/leapmux/v1/agent_pb'
+import { AgentStatus, FileTransferDirection, FileTransferState, MessageSource, WatchReplayMode } from '~/generated/leapmux/v1/agent_pb'
 import { TerminalStatus } from '~/generated/leapmux/v1/terminal_pb'
 import { TabType } from '~/generated/leapmux/v1/workspace_pb'
+import { createEventSeqTracker, revealsGap } from '~/hooks/eventSeqGap'
 import { waitForStreamCompletion } from '~/hooks/streamCompletion'
+import { showAnnouncement } from '~/lib/announcements'
+import { base64ToUint8Array } from '~/lib/base64'
 import { ChannelError } from '~/lib/channel'
 import { createLogger } from '~/lib/logger'
 import { extractCompactionContextTokens, extractContextUsage, extractPlanFilePath, extractPlanUpdated, extractResultMetadata, extractSettingsChanges, getInnerMessage, normalizeContextUsage, parseMessageContent } from '~/lib/messageParser'
@@ -30,6 +35,7 @@ import { shallowEqual } from '~/lib/shallowEqual'
 import { applyTerminalData, bufferHasVisibleContent } from '~/lib/terminal'
 import { compactionContextUsage } from '~/stores/agentSession.store'
 import { MAX_BACKGROUND_CHAT_MESSAGES } from '~/stores/chat.store'
+import { flushOfflineSpool, getSpooledMessages, OFFLINE_SPOOL_PENDING_LABEL } from '~/stores/chatOfflineSpool'
 import { deriveOptionGroupTabFields, gitTabFieldsDiffer, spliceTabGitFields, tabKey, toGitTabFields } from '~/stores/tab.helpers'
 import { isAgentTab, isTerminalTab } from '~/stores/tab.types'
 
@@ -191,6 +197,8 @@ export function wireSessionInfoToUpdates(
     updates.codexTurnId = info.codex_turn_id as string
   if (info.streaming_type !== undefined)
     updates.streamingType = info.streaming_type as string
+  if (info.env !== undefined)
+    updates.env = info.env as Record<string, string>
   // Only positive estimates: `> 0` rejects both the zero-estimate first delta
   // (nothing to show yet) and a NaN a future provider might emit (NaN > 0 is
   // false), so the indicator never has to defend against "0 tokens" or a NaN
@@ -623,8 +631,8 @@ export function resolveSettingsTabFields(
  * Assemble the single consolidated tab update for an agent statusChange: status +
  * session id (only when status is SET, so a git-only push can't overwrite valid state
  * with proto3's UNSPECIFIED default and make the agent unwatchable), the startupError /
- * startupMessage transitions, the already-reconciled per-axis settings fields, and the
- * git fields. Pure; the caller applies it in ONE tabStore.updateTab so the store walks
+ * startupMessage transitions, the already-reconciled per-axis settings fields, the
+ * git fields, and the latest test run. Pure; the caller applies it in ONE tabStore.updateTab so the store walks
  * state.tabs once (vs. the historical split that walked it twice per push).
  */
 export function buildAgentStatusTabUpdate(
@@ -653,6 +661,8 @@ export function buildAgentStatusTabUpdate(
           ...toGitTabFields(sc.gitStatus.branch, sc.gitStatus.originUrl, sc.gitStatus.toplevel, sc.gitStatus.isWorktree),
         }
       : {}),
+    ...(sc.testRun ? { agentTestRun: sc.testRun } : {}),
+    ...(sc.ciStatus ? { agentCiStatus: sc.ciStatus } : {}),
   }
 }
 
@@ -684,6 +694,48 @@ export function drainPendingOutboundOnStart(
   }
 }
 
+/**
+ * Replay the messages spooled while `workerId` was offline (the opt-in offline
+ * spool, see chatOfflineSpool), restoring their "queued" labels first so a spool
+ * that outlived a page refresh reads as pending again. Each outcome is surfaced
+ * on the message's bubble and with a toast. Fire-and-forget; a no-op on an empty
+ * spool.
+ */
+export function deliverOfflineSpool(workerId: string, chatStore: ReturnType<typeof createChatStore>): void {
+  const queued = getSpooledMessages(workerId)
+  if (queued.length === 0)
+    return
+  for (const m of queued)
+    chatStore.setMessagePendingLabel(m.localId, OFFLINE_SPOOL_PENDING_LABEL)
+  const fail = (m: SpooledMessage, error: string) => {
+    chatStore.clearMessagePendingLabel(m.localId)
+    chatStore.setMessageError(m.localId, error)
+    chatStore.setPersistedLocalMessageError(m.agentId, m.localId, error)
+  }
+  void flushOfflineSpool(workerId, {
+    send: m => sendAgentMessage(workerId, {
+      agentId: m.agentId,
+      content: m.content,
+      attachments: m.attachments.map(a => ({ filename: a.filename, mimeType: a.mime_type, data: base64ToUint8Array(a.data) })),
+      idempotencyKey: m.localId,
+    }),
+    isOffline: isWorkerOffline,
+    onDelivered: (m) => {
+      chatStore.clearMessagePendingLabel(m.localId)
+      chatStore.forgetPersistedLocalMessage(m.agentId, m.localId)
+      showInfoToast('Queued message delivered')
+    },
+    onExpired: (m) => {
+      fail(m, 'Queued message expired')
+      showWarnToast('A queued message expired before its worker came back online')
+    },
+    onFailed: (m, err) => {
+      fail(m, 'Failed to deliver')
+      showWarnToast('Failed to deliver a queued message', err)
+    },
+  })
+}
+
 /**
  * INACTIVE cleanup: the agent subprocess stopped. Clear stale control requests (so the
  * user can send a regular message that auto-starts the agent instead of being stuck on
@@ -825,11 +877,11 @@ export function handleAgentStatusChange(
   if (hasStatus)
     setWorkerOnline(sc.workerOnline)
 
-  // Skip events that carry no status, git, or settings payload -- they only surface as
+  // Skip events that carry no status, git, test-run, or settings payload -- they only surface as
   // catch-up sentinels (the forward-fill they used to drive now runs from the continuous
   // reconcileLaggingTails effect) and would otherwise allocate a full updates object and
   // iterate every reactive reader for a no-op.
-  const hasPayload = hasStatus || sc.gitStatus !== undefined || sc.optionGroups.length > 0
+  const hasPayload = hasStatus || sc.gitStatus !== undefined || sc.testRun !== undefined || sc.ciStatus !== undefined || sc.optionGroups.length > 0
   if (!hasPayload)
     return
 
@@ -1081,6 +1133,24 @@ export function useWorkspaceConnection(params: WorkspaceConnectionParams) {
         chatStore.todos.replace(tc.agentId, tc.todos)
         break
       }
+      case 'fileTransfer': {
+        // Progress for a browser upload/download; the transfer's own RPCs
+        // report its outcome, so a finished one just leaves the store.
+        const ft = inner.value
+        agentSessionStore.setFileTransfer(
+          ft.agentId,
+          ft.transferId,
+          ft.state === FileTransferState.IN_PROGRESS
+            ? {
+                direction: ft.direction === FileTransferDirection.DOWNLOAD ? 'download' : 'upload',
+                path: ft.path,
+                bytesDone: Number(ft.bytesDone),
+                totalBytes: Number(ft.totalBytes),
+              }
+            : undefined,
+        )
+        break
+      }
       case 'catchUpStart':
         // Pre-trim BEFORE the message replay renders: the worker ships the
         // authoritative live tail up front so a reconnecting client drops phantom rows
@@ -1386,8 +1456,18 @@ export function useWorkspaceConnection(params: WorkspaceConnectionParams) {
         // workerRpc.ts buffers any events that arrive before onEvent is
         // wired; waitForStreamCompletion captures end / error / abort that
         // fire during the synchronous setup window.
+        // A numbered event past the next expected one, or a heartbeat ahead of
+        // what arrived, means the worker lost an event for this stream (e.g. the
+        // channel refused an oversized frame). Ending the stream makes the next
+        // pass resubscribe, and its AFTER_CURSOR replay fills the hole.
+        const eventSeqs = createEventSeqTracker()
+        const gapFound = new AbortController()
         handle.onEvent((response) => {
           backoff.reset(BACKOFF_KEY)
+          if (!gapFound.signal.aborted && revealsGap(eventSeqs, response)) {
+            log.warn('[watchEvents] missed an event, resubscribing')
+            gapFound.abort()
+          }
           switch (response.event.case) {
             case 'agentEvent':
               handleAgentEvent(response.event.value, catchUpPhases, resumeTails)
@@ -1395,6 +1475,9 @@ export function useWorkspaceConnection(params: WorkspaceConnectionParams) {
             case 'terminalEvent':
               handleTerminalEvent(response.event.value)
               break
+            case 'announcement':
+              showAnnouncement(response.event.value)
+              break
           }
         })
 
@@ -1404,7 +1487,7 @@ export function useWorkspaceConnection(params: WorkspaceConnectionParams) {
         previousHandle?.close()
         previousHandle = handle
 
-        await waitForStreamCompletion(handle, signal)
+        await waitForStreamCompletion(handle, AbortSignal.any([signal, gapFound.signal]))
       }
       catch (err) {
         if (signal.aborted)
@@ -1431,7 +1514,18 @@ export function useWorkspaceConnection(params: WorkspaceConnectionParams) {
 
       if (signal.aborted)
         return
+      // A Hub that announced its shutdown said how long to stay away;
+      // honour that instead of hammering it with backoff retries.
+      const hubRetryDelay = channelManager.hubRetryDelayMs()
       await new Promise<void>((resolve) => {
+        if (hubRetryDelay > 0) {
+          const timer = setTimeout(resolve, hubRetryDelay)
+          signal.addEventListener('abort', () => {
+            clearTimeout(timer)
+            resolve()
+          }, { once: true })
+          return
+        }
         backoff.schedule(BACKOFF_KEY, resolve)
       })
     }
@@ -1526,6 +1620,18 @@ export function useWorkspaceConnection(params: WorkspaceConnectionParams) {
     watchEvents(agentEntries, terminalIds, abort.signal)
   })
 
+  // Deliver messages spooled while the worker was offline once it is
+  // reachable again. Also runs on mount, so a spool that outlived a page
+  // refresh is retried; a spool for a worker whose workspace is not active
+  // waits until that workspace is opened.
+  createEffect(() => {
+    if (!workerOnline())
+      return
+    const workerId = params.getWorkerId()
+    if (workerId)
+      untrack(() => deliverOfflineSpool(workerId, chatStore))
+  })
+
   // When the worker goes offline, mark running terminals as disconnected,
   // clear stale streaming text, and set active agents to inactive so the
   // thinking indicator hides. The real status will arrive when the WatchEvents
@@ -0,0 +1,106 @@
+import { beforeEach, describe, expect, it, vi } from 'vitest'
+
+const startAgentUploadImpl = vi.fn()
+const writeAgentUploadChunkImpl = vi.fn()
+const cancelAgentUploadImpl = vi.fn()
+const readAgentFileImpl = vi.fn()
+const clickDownloadAnchorImpl = vi.fn()
+
+vi.mock('~/api/workerRpc', () => ({
+  startAgentUpload: (...args: unknown[]) => startAgentUploadImpl(...args),
+  writeAgentUploadChunk: (...args: unknown[]) => writeAgentUploadChunkImpl(...args),
+  cancelAgentUpload: (...args: unknown[]) => cancelAgentUploadImpl(...args),
+  readAgentFile: (...args: unknown[]) => readAgentFileImpl(...args),
+}))
+
+vi.mock('~/lib/fileDownload', () => ({
+  clickDownloadAnchor: (...args: unknown[]) => clickDownloadAnchorImpl(...args),
+}))
+
+const { downloadFileFromAgent, uploadFileToAgent } = await import('~/lib/agentFileTransfer')
+
+const MiB = 1 << 20
+
+beforeEach(() => {
+  startAgentUploadImpl.mockReset()
+  writeAgentUploadChunkImpl.mockReset()
+  cancelAgentUploadImpl.mockReset()
+  readAgentFileImpl.mockReset()
+  clickDownloadAnchorImpl.mockReset()
+  cancelAgentUploadImpl.mockResolvedValue({})
+})
+
+describe('uploadFileToAgent', () => {
+  it('sends the file in chunks at increasing offsets', async () => {
+    startAgentUploadImpl.mockResolvedValue({ uploadId: 'u', path: 'fixtures/big.bin' })
+    writeAgentUploadChunkImpl.mockResolvedValue({})
+    const onProgress = vi.fn()
+
+    const path = await uploadFileToAgent('w1', 'a1', new Blob([new Uint8Array(MiB + 3)]), './fixtures/big.bin', { onProgress })
+
+    expect(path).toBe('fixtures/big.bin')
+    expect(startAgentUploadImpl.mock.calls[0][1]).toMatchObject({ agentId: 'a1', path: './fixtures/big.bin', totalSize: BigInt(MiB + 3), overwrite: false })
+    const writes = writeAgentUploadChunkImpl.mock.calls.map(([, req]) => [req.offset, req.data.length])
+    expect(writes).toEqual([[0n, MiB], [BigInt(MiB), 3]])
+    expect(onProgress.mock.calls).toEqual([[MiB, MiB + 3], [MiB + 3, MiB + 3]])
+    expect(cancelAgentUploadImpl).not.toHaveBeenCalled()
+  })
+
+  it('sends no chunks for an empty file', async () => {
+    startAgentUploadImpl.mockResolvedValue({ uploadId: 'u', path: 'empty' })
+
+    await uploadFileToAgent('w1', 'a1', new Blob([]), 'empty')
+
+    expect(writeAgentUploadChunkImpl).not.toHaveBeenCalled()
+  })
+
+  it('cancels the upload when a chunk fails', async () => {
+    startAgentUploadImpl.mockResolvedValue({ uploadId: 'u', path: 'f' })
+    writeAgentUploadChunkImpl.mockRejectedValue(new Error('disk full'))
+
+    await expect(uploadFileToAgent('w1', 'a1', new Blob(['abc']), 'f')).rejects.toThrow('disk full')
+
+    const uploadId = startAgentUploadImpl.mock.calls[0][1].uploadId
+    expect(cancelAgentUploadImpl).toHaveBeenCalledWith('w1', { agentId: 'a1', uploadId })
+  })
+
+  it('cancels the upload when the signal aborts', async () => {
+    startAgentUploadImpl.mockResolvedValue({ uploadId: 'u', path: 'f' })
+    const abort = new AbortController()
+    abort.abort()
+
+    await expect(uploadFileToAgent('w1', 'a1', new Blob(['abc']), 'f', { signal: abort.signal })).rejects.toThrow()
+
+    expect(writeAgentUploadChunkImpl).not.toHaveBeenCalled()
+    expect(cancelAgentUploadImpl).toHaveBeenCalledTimes(1)
+  })
+})
+
+describe('downloadFileFromAgent', () => {
+  it('pages through the file under one transfer id and saves it', async () => {
+    readAgentFileImpl
+      .mockResolvedValueOnce({ path: 'out/report.txt', content: new TextEncoder().encode('ab'), totalSize: 3n })
+      .mockResolvedValueOnce({ path: 'out/report.txt', content: new TextEncoder().encode('c'), totalSize: 3n })
+    const onProgress = vi.fn()
+
+    await downloadFileFromAgent('w1', 'a1', 'out/report.txt', onProgress)
+
+    const reqs = readAgentFileImpl.mock.calls.map(([, req]) => req)
+    expect(reqs.map(r => r.offset)).toEqual([0n, 2n])
+    expect(reqs[0].transferId).toBeTruthy()
+    expect(reqs[1].transferId).toBe(reqs[0].transferId)
+    expect(onProgress.mock.calls).toEqual([[2, 3], [3, 3]])
+    const [blob, name] = clickDownloadAnchorImpl.mock.calls[0]
+    expect(name).toBe('report.txt')
+    expect(await (blob as Blob).text()).toBe('abc')
+  })
+
+  it('stops at a short file instead of looping', async () => {
+    readAgentFileImpl.mockResolvedValue({ path: 'f', content: new Uint8Array(), totalSize: 5n })
+
+    await downloadFileFromAgent('w1', 'a1', 'f')
+
+    expect(readAgentFileImpl).toHaveBeenCalledTimes(1)
+    expect(clickDownloadAnchorImpl).toHaveBeenCalledTimes(1)
+  })
+})
@@ -0,0 +1,93 @@
+import * as workerRpc from '~/api/workerRpc'
+import { clickDownloadAnchor } from '~/lib/fileDownload'
+import { randomUUID } from '~/lib/idGenerator'
+import { basename } from '~/lib/paths'
+
+// The worker's per-request ceiling for both directions; keeping each call
+// at the cap keeps the progress reports to one per MiB.
+const AGENT_TRANSFER_CHUNK_SIZE = 1 << 20
+
+/** `(done, total)` in bytes, called after each chunk. */
+export type TransferProgress = (done: number, total: number) => void
+
+export interface UploadToAgentOptions {
+  /** Replace a file already at `path`; otherwise the upload is refused. */
+  overwrite?: boolean
+  onProgress?: TransferProgress
+  /** Aborting cancels the upload on the worker, leaving no partial file. */
+  signal?: AbortSignal
+}
+
+/**
+ * Upload `file` into the agent's working directory at `path` (relative to
+ * it; the parent directory must exist). The worker writes to a temporary
+ * file and only moves it into place once every byte has arrived, so a
+ * failed or canceled upload never leaves a truncated file for the agent.
+ * Resolves with the path the worker placed the file at.
+ */
+export async function uploadFileToAgent(
+  workerId: string,
+  agentId: string,
+  file: Blob,
+  path: string,
+  opts: UploadToAgentOptions = {},
+): Promise<string> {
+  const uploadId = randomUUID()
+  const started = await workerRpc.startAgentUpload(workerId, {
+    agentId,
+    uploadId,
+    path,
+    totalSize: BigInt(file.size),
+    overwrite: opts.overwrite ?? false,
+  })
+  try {
+    let offset = 0
+    while (offset < file.size) {
+      opts.signal?.throwIfAborted()
+      const end = Math.min(offset + AGENT_TRANSFER_CHUNK_SIZE, file.size)
+      const data = new Uint8Array(await file.slice(offset, end).arrayBuffer())
+      await workerRpc.writeAgentUploadChunk(workerId, { agentId, uploadId, offset: BigInt(offset), data })
+      offset = end
+      opts.onProgress?.(offset, file.size)
+    }
+  }
+  catch (err) {
+    // Best effort: the worker also drops the upload when the channel closes.
+    workerRpc.cancelAgentUpload(workerId, { agentId, uploadId }).catch(() => {})
+    throw err
+  }
+  return started.path
+}
+
+/**
+ * Download `path` (relative to the agent's working directory) to the
+ * browser in paged reads, each reported to the agent's watchers as
+ * progress.
+ */
+export async function downloadFileFromAgent(
+  workerId: string,
+  agentId: string,
+  path: string,
+  onProgress?: TransferProgress,
+): Promise<void> {
+  const transferId = randomUUID()
+  const parts: BlobPart[] = []
+  let received = 0
+  let totalSize = -1
+  while (totalSize < 0 || received < totalSize) {
+    const resp = await workerRpc.readAgentFile(workerId, {
+      agentId,
+      path,
+      offset: BigInt(received),
+      limit: BigInt(AGENT_TRANSFER_CHUNK_SIZE),
+      transferId,
+    })
+    totalSize = Number(resp.totalSize)
+    if (resp.content.length === 0)
+      break
+    parts.push(new Uint8Array(resp.content))
+    received += resp.content.length
+    onProgress?.(received, totalSize)
+  }
+  clickDownloadAnchor(new Blob(parts), basename(path))
+}
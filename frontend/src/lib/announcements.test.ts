@@ -0,0 +1,48 @@
+import type { Announcement } from '~/generated/leapmux/v1/announcement_pb'
+import { create } from '@bufbuild/protobuf'
+import { beforeEach, describe, expect, it, vi } from 'vitest'
+import { announcementClient } from '~/api/clients'
+import { showStickyToast } from '~/components/common/Toast'
+import { AnnouncementSchema, AnnouncementSeverity } from '~/generated/leapmux/v1/announcement_pb'
+import { _resetShownAnnouncementsForTests, showAnnouncement } from './announcements'
+
+vi.mock('~/api/clients', () => ({
+  announcementClient: {
+    acknowledgeAnnouncement: vi.fn(() => Promise.resolve({})),
+  },
+}))
+
+vi.mock('~/components/common/Toast', () => ({
+  showStickyToast: vi.fn(),
+}))
+
+function announcement(overrides: Partial<Announcement> = {}): Announcement {
+  return create(AnnouncementSchema, { id: 'a-1', title: 'Maintenance tonight', ...overrides })
+}
+
+beforeEach(() => {
+  vi.clearAllMocks()
+  _resetShownAnnouncementsForTests()
+})
+
+describe('showAnnouncement', () => {
+  it('shows each announcement once', () => {
+    showAnnouncement(announcement({ body: 'Expect a short outage.' }))
+    showAnnouncement(announcement({ body: 'Expect a short outage.' }))
+    expect(showStickyToast).toHaveBeenCalledTimes(1)
+    expect(showStickyToast).toHaveBeenCalledWith('Maintenance tonight: Expect a short outage.', 'success', expect.any(Function))
+  })
+
+  it('picks the toast variant from the severity', () => {
+    showAnnouncement(announcement({ id: 'm', severity: AnnouncementSeverity.MAINTENANCE }))
+    showAnnouncement(announcement({ id: 'i', severity: AnnouncementSeverity.INCIDENT }))
+    expect(vi.mocked(showStickyToast).mock.calls.map(c => c[1])).toEqual(['warning', 'danger'])
+  })
+
+  it('acknowledges the announcement when the toast is closed', () => {
+    showAnnouncement(announcement())
+    const onClose = vi.mocked(showStickyToast).mock.calls[0][2]
+    onClose?.()
+    expect(announcementClient.acknowledgeAnnouncement).toHaveBeenCalledWith({ announcementId: 'a-1' })
+  })
+})
@@ -0,0 +1,35 @@
+import type { Announcement } from '~/generated/leapmux/v1/announcement_pb'
+import { announcementClient } from '~/api/clients'
+import { showStickyToast } from '~/components/common/Toast'
+import { AnnouncementSeverity } from '~/generated/leapmux/v1/announcement_pb'
+import { createLogger } from '~/lib/logger'
+
+const log = createLogger('announcements')
+
+// Every worker sends each running announcement, and again on each new
+// WatchEvents stream, so one is shown once per page load.
+const shown = new Set<string>()
+
+/**
+ * Show an admin announcement until the user closes it, which acknowledges
+ * it so Login stops returning it.
+ */
+export function showAnnouncement(a: Announcement) {
+  if (shown.has(a.id))
+    return
+  shown.add(a.id)
+  const message = a.body ? `${a.title}: ${a.body}` : a.title
+  const type = a.severity === AnnouncementSeverity.INCIDENT
+    ? 'danger'
+    : a.severity === AnnouncementSeverity.MAINTENANCE ? 'warning' : 'success'
+  showStickyToast(message, type, () => {
+    announcementClient.acknowledgeAnnouncement({ announcementId: a.id }).catch((err) => {
+      log.warn('failed to acknowledge announcement', err)
+    })
+  })
+}
+
+/** Forget which announcements were shown. Test-only helper. */
+export function _resetShownAnnouncementsForTests(): void {
+  shown.clear()
+}
@@ -55,6 +55,12 @@ export interface BrowserPreferences {
    * `false` explicitly to opt out.
    */
   revealAfterDownload?: boolean
+  /**
+   * When true, a message sent while its worker is offline is queued in
+   * this browser and delivered when the worker reconnects, instead of
+   * failing. Default false.
+   */
+  spoolOfflineMessages?: boolean
 }
 
 // ---------------------------------------------------------------------------
@@ -79,6 +85,7 @@ export const PREFIX_WORKER_INFO = 'leapmux:worker-info:'
 export const PREFIX_LOCAL_MESSAGES = 'leapmux:local-messages:'
 export const PREFIX_FILES_SHOW_HIDDEN = 'leapmux:files-show-hidden:'
 export const PREFIX_CHAT_ROW_HEIGHTS = 'leapmux:chat-row-heights:'
+export const PREFIX_OFFLINE_SPOOL = 'leapmux:offline-spool:'
 
 /** sessionStorage dynamic key prefixes. */
 export const PREFIX_FILE_SCROLL = 'leapmux:fileScroll:'
@@ -140,6 +147,10 @@ export const DYNAMIC_KEY_TTLS: ReadonlyArray<{ prefix: string, ttlMs: number }>
   // cache: stale entries are harmless (each row's key digest must match its
   // live heightKey to hydrate), so the TTL only bounds storage growth.
   { prefix: PREFIX_CHAT_ROW_HEIGHTS, ttlMs: 7 * DAY_MS },
+  // Messages spooled while their worker was offline (see chatOfflineSpool).
+  // Each entry carries its own, shorter expiry; the TTL only reclaims the
+  // spool of a worker that never came back.
+  { prefix: PREFIX_OFFLINE_SPOOL, ttlMs: 7 * DAY_MS },
 ]
 
 /**
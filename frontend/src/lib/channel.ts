@@ -23,6 +23,7 @@ import {
   ChannelMessageFlags,
   ChannelMessageSchema,
   EncryptionMode,
+  HubControlEvent,
   HubControlFrameSchema,
   InnerMessageSchema,
   InnerRpcRequestSchema,
@@ -87,12 +88,15 @@ export type ChannelErrorSource = 'transport' | 'stream' | 'rpc' | 'client'
 export class ChannelError extends Error {
   readonly source: ChannelErrorSource
   readonly code: number
+  /** Stable LeapMux error code (e.g. LEAPMUX_AGENT_NOT_FOUND), when the worker sent one. */
+  readonly reason: string
 
-  constructor(source: ChannelErrorSource, message: string, code = 0) {
+  constructor(source: ChannelErrorSource, message: string, code = 0, reason = '') {
     super(message)
     this.name = 'ChannelError'
     this.source = source
     this.code = code
+    this.reason = reason
   }
 }
 
@@ -287,6 +291,8 @@ export class ChannelManager {
   private stateListeners = new Set<() => void>()
   private errorListeners = new Set<(workerId: string, error: ChannelError) => void>()
   private hubControlListeners = new Set<(frame: HubControlFrame) => void>()
+  /** Epoch ms before which a shutting-down Hub asked not to be retried. */
+  private hubRetryAt = 0
 
   constructor(transport: ChannelTransport, opts?: ChannelManagerOpts) {
     this.transport = transport
@@ -323,6 +329,14 @@ export class ChannelManager {
     }
   }
 
+  /**
+   * How long a shutting-down Hub asked clients to wait before reconnecting,
+   * or 0 once that window has passed (or none was given).
+   */
+  hubRetryDelayMs(): number {
+    return Math.max(0, this.hubRetryAt - Date.now())
+  }
+
   /**
    * Check if a usable channel exists for a worker.
    *
@@ -1238,6 +1252,8 @@ export class ChannelManager {
   private handleHubControl(msg: ChannelMessage): void {
     try {
       const frame = fromBinary(HubControlFrameSchema, msg.ciphertext)
+      if (frame.events.includes(HubControlEvent.SHUTTING_DOWN) && frame.retryDelaySeconds > 0)
+        this.hubRetryAt = Date.now() + frame.retryDelaySeconds * 1000
       for (const cb of this.hubControlListeners) {
         try {
           cb(frame)
@@ -1424,7 +1440,7 @@ export class ChannelManager {
     if (pending) {
       this.unregisterRequest(ch, correlationId)
       if (resp.isError) {
-        const err = new ChannelError('rpc', resp.errorMessage || `RPC error code ${resp.errorCode}`, resp.errorCode)
+        const err = new ChannelError('rpc', resp.errorMessage || `RPC error code ${resp.errorCode}`, resp.errorCode, resp.errorReason)
         this.notifyError(ch.workerId, err)
         pending.reject(err)
       }
@@ -1454,7 +1470,7 @@ export class ChannelManager {
       return
     }
     this.unregisterRequest(ch, correlationId)
-    const err = new ChannelError('rpc', resp.errorMessage || `RPC error code ${resp.errorCode}`, resp.errorCode)
+    const err = new ChannelError('rpc', resp.errorMessage || `RPC error code ${resp.errorCode}`, resp.errorCode, resp.errorReason)
     this.notifyError(ch.workerId, err)
     // safeCall for the same reason rejectPendingRequest uses it: a throwing
     // app callback must not unwind back through handleMessage.
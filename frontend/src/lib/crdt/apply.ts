@@ -236,6 +236,18 @@ const tabRegisterHandlers: Record<string, TabFieldHandler> = {
     if (shouldWrite(rec.fileDiffBase?.hlc, hlc))
       rec.fileDiffBase = lwwString(value as string, hlc)
   },
+  groupLabel: (rec, hlc, value) => {
+    if (shouldWrite(rec.groupLabel?.hlc, hlc))
+      rec.groupLabel = lwwString(value as string, hlc)
+  },
+  groupColor: (rec, hlc, value) => {
+    if (shouldWrite(rec.groupColor?.hlc, hlc))
+      rec.groupColor = lwwString(value as string, hlc)
+  },
+  groupCollapsed: (rec, hlc, value) => {
+    if (shouldWrite(rec.groupCollapsed?.hlc, hlc))
+      rec.groupCollapsed = lwwInt32(value ? 1 : 0, hlc)
+  },
 }
 
 function applySetTabRegister(state: OrgCrdtState, op: { tabType: number, tabId: string, field: { case?: string, value?: unknown } }, hlc: HLC): void {
@@ -17,6 +17,9 @@ import {
   setNodeRows,
   setTabFileDiffBase,
   setTabFileViewMode,
+  setTabGroupCollapsed,
+  setTabGroupColor,
+  setTabGroupLabel,
   setTabPosition,
   setTabTileId,
   setTabWorkerId,
@@ -108,6 +111,9 @@ describe('tab op builders', () => {
     expect((setTabWorkerId(ctx, tt, 't', 'w').body.value as { field: { case: string } }).field.case).toBe('workerId')
     expect((setTabFileViewMode(ctx, TabType.FILE, 't', 1).body.value as { field: { case: string } }).field.case).toBe('fileViewMode')
     expect((setTabFileDiffBase(ctx, TabType.FILE, 't', 'HEAD').body.value as { field: { case: string } }).field.case).toBe('fileDiffBase')
+    expect((setTabGroupLabel(ctx, tt, 't', 'Backend').body.value as { field: { case: string } }).field.case).toBe('groupLabel')
+    expect((setTabGroupColor(ctx, tt, 't', '#3366ff').body.value as { field: { case: string } }).field.case).toBe('groupColor')
+    expect((setTabGroupCollapsed(ctx, tt, 't', true).body.value as { field: { case: string } }).field.case).toBe('groupCollapsed')
   })
 
   it('tombstoneTab includes tab_type so the validator can confirm uniqueness', () => {
@@ -141,6 +141,18 @@ export function setTabFileDiffBase(ctx: OpBuilderCtx, tabType: TabType, tabId: s
   return setTabRegister(ctx, tabType, tabId, { case: 'fileDiffBase', value: base })
 }
 
+export function setTabGroupLabel(ctx: OpBuilderCtx, tabType: TabType, tabId: string, label: string): OrgOp {
+  return setTabRegister(ctx, tabType, tabId, { case: 'groupLabel', value: label })
+}
+
+export function setTabGroupColor(ctx: OpBuilderCtx, tabType: TabType, tabId: string, color: string): OrgOp {
+  return setTabRegister(ctx, tabType, tabId, { case: 'groupColor', value: color })
+}
+
+export function setTabGroupCollapsed(ctx: OpBuilderCtx, tabType: TabType, tabId: string, collapsed: boolean): OrgOp {
+  return setTabRegister(ctx, tabType, tabId, { case: 'groupCollapsed', value: collapsed })
+}
+
 export function tombstoneTab(ctx: OpBuilderCtx, tabType: TabType, tabId: string): OrgOp {
   return buildOp(ctx, {
     case: 'tombstoneTab',
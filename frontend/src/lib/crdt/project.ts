@@ -28,6 +28,11 @@ export interface RenderedTab {
   workerId: string
   tileId: string
   position: string
+  /** Tab group label; '' when the tab is ungrouped. */
+  groupLabel: string
+  /** Tab group color ("#rrggbb"); '' for the default color. */
+  groupColor: string
+  groupCollapsed: boolean
 }
 
 export interface RenderedFloatingWindow {
@@ -297,6 +302,9 @@ export function project(state: OrgCrdtState): Projection {
       workerId: t.workerId?.value ?? '',
       tileId: tile,
       position: t.position?.value ?? '',
+      groupLabel: t.groupLabel?.value ?? '',
+      groupColor: t.groupColor?.value ?? '',
+      groupCollapsed: (t.groupCollapsed?.value ?? 0) !== 0,
     }
     out.ownedTabs.push(row)
     if (alive && tileIsLeaf(state, tile))
@@ -373,6 +381,9 @@ export function projectWorkspaceTabs(state: OrgCrdtState, workspaceId: string):
       workerId: t.workerId?.value ?? '',
       tileId: tile,
       position: t.position?.value ?? '',
+      groupLabel: t.groupLabel?.value ?? '',
+      groupColor: t.groupColor?.value ?? '',
+      groupCollapsed: (t.groupCollapsed?.value ?? 0) !== 0,
     })
   }
   out.sort((a, b) => cmpStr(a.tabId, b.tabId))
@@ -1,5 +1,7 @@
 import { describe, expect, it, vi } from 'vitest'
+import { create } from '@bufbuild/protobuf'
 import { ContentCompression } from '~/generated/leapmux/v1/agent_pb'
+import { WatchEventsResponseSchema } from '~/generated/leapmux/v1/workspace_pb'
 
 // Mock fzstd before importing the module under test
 vi.mock('fzstd', () => ({
@@ -12,7 +14,7 @@ vi.mock('fzstd', () => ({
 }))
 
 // Import after mock is set up
-const { decompressContent, decompressContentToString } = await import('~/lib/decompress')
+const { decompressContent, decompressContentToString, inflateWatchEvent } = await import('~/lib/decompress')
 const { decompress: mockFzstdDecompress } = await import('fzstd')
 
 describe('decompressContent', () => {
@@ -65,3 +67,37 @@ describe('decompressContentToString', () => {
     expect(result).toBe('compressed data')
   })
 })
+
+describe('inflateWatchEvent', () => {
+  it('should inflate compressed terminal data and mark it plain', () => {
+    const data = new Uint8Array([1, 2, 3])
+    const resp = create(WatchEventsResponseSchema, {
+      event: { case: 'terminalEvent', value: { terminalId: 't-1', event: { case: 'data', value: { data, dataCompression: ContentCompression.ZSTD } } } },
+    })
+    inflateWatchEvent(resp)
+    expect(mockFzstdDecompress).toHaveBeenCalledWith(data)
+    const event = resp.event.case === 'terminalEvent' ? resp.event.value.event : undefined
+    expect(event?.case === 'data' && event.value.dataCompression).toBe(ContentCompression.UNSPECIFIED)
+  })
+
+  it('should inflate a compressed agent stream chunk', () => {
+    const delta = new Uint8Array([4, 5, 6])
+    const resp = create(WatchEventsResponseSchema, {
+      event: { case: 'agentEvent', value: { agentId: 'a-1', event: { case: 'streamChunk', value: { delta, deltaCompression: ContentCompression.ZSTD } } } },
+    })
+    inflateWatchEvent(resp)
+    expect(mockFzstdDecompress).toHaveBeenCalledWith(delta)
+  })
+
+  it('should leave plain payloads alone', () => {
+    vi.mocked(mockFzstdDecompress).mockClear()
+    const data = new Uint8Array([7, 8, 9])
+    const resp = create(WatchEventsResponseSchema, {
+      event: { case: 'terminalEvent', value: { terminalId: 't-1', event: { case: 'data', value: { data } } } },
+    })
+    inflateWatchEvent(resp)
+    expect(mockFzstdDecompress).not.toHaveBeenCalled()
+    const event = resp.event.case === 'terminalEvent' ? resp.event.value.event : undefined
+    expect(event?.case === 'data' && event.value.data).toBe(data)
+  })
+})
@@ -1,3 +1,4 @@
+import type { WatchEventsResponse } from '~/generated/leapmux/v1/workspace_pb'
 import { decompress as fzstdDecompress } from 'fzstd'
 import { ContentCompression } from '~/generated/leapmux/v1/agent_pb'
 
@@ -34,3 +35,26 @@ export function decompressContentToString(
     return null
   return textDecoder.decode(decompressed)
 }
+
+/**
+ * Inflate, in place, the payload a worker compressed for a WatchEvents stream
+ * that set accept_compression: terminal output and agent stream chunk deltas.
+ * Consumers downstream only ever see plain bytes.
+ */
+export function inflateWatchEvent(resp: WatchEventsResponse): void {
+  const event = resp.event
+  if (event.case === 'terminalEvent' && event.value.event.case === 'data') {
+    const data = event.value.event.value
+    if (data.dataCompression === ContentCompression.ZSTD) {
+      data.data = fzstdDecompress(data.data)
+      data.dataCompression = ContentCompression.UNSPECIFIED
+    }
+  }
+  else if (event.case === 'agentEvent' && event.value.event.case === 'streamChunk') {
+    const chunk = event.value.event.value
+    if (chunk.deltaCompression === ContentCompression.ZSTD) {
+      chunk.delta = fzstdDecompress(chunk.delta)
+      chunk.deltaCompression = ContentCompression.UNSPECIFIED
+    }
+  }
+}
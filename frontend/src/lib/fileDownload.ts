@@ -171,11 +171,19 @@ export async function downloadFileFromWorker(
   // spinner reaches 100% for the actual bytes we got.
   if (lastReceived < lastTotalSize)
     emit?.(lastReceived, lastReceived)
-  const url = URL.createObjectURL(new Blob(parts))
+  clickDownloadAnchor(new Blob(parts), basename(filePath, flavor))
+}
+
+/**
+ * Hand `blob` to the browser as a download named `name`, via a
+ * temporary anchor click.
+ */
+export function clickDownloadAnchor(blob: Blob, name: string): void {
+  const url = URL.createObjectURL(blob)
   try {
     const a = document.createElement('a')
     a.href = url
-    a.download = basename(filePath, flavor)
+    a.download = name
     a.rel = 'noopener'
     document.body.appendChild(a)
     a.click()
@@ -223,6 +223,24 @@ describe('extractContextUsage', () => {
     expect(extractContextUsage(parseMessageContent(msg), noProviderUsage)).toBeNull()
   })
 
+  it('totals normalized sub-agent usage apart from the context', () => {
+    const content = {
+      type: 'result',
+      context_usage: {
+        input_tokens: 100,
+        subagent_usage: { input_tokens: 40, output_tokens: 5, cache_read_input_tokens: 10 },
+      },
+    }
+    const msg = makeMsg(MessageSource.AGENT, content)
+    const result = extractContextUsage(parseMessageContent(msg), noProviderUsage)
+    expect(result?.contextUsage).toEqual({
+      inputTokens: 100,
+      cacheCreationInputTokens: 0,
+      cacheReadInputTokens: 0,
+      subAgentTokens: 55,
+    })
+  })
+
   it('extracts normalized Pi usage and cumulative cost from augmented message_end', () => {
     const content = {
       type: 'message_end',
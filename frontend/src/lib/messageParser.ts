@@ -206,6 +206,15 @@ export function normalizeContextUsage(value: unknown): ContextUsageInfo | undefi
     usage.contextTokens = contextTokens
   if (contextWindow !== undefined && contextWindow > 0)
     usage.contextWindow = contextWindow
+  const subAgent = value.subagent_usage
+  if (isObject(subAgent)) {
+    const subAgentTokens = pickNumber(subAgent, 'input_tokens', 0)
+      + pickNumber(subAgent, 'cache_creation_input_tokens', 0)
+      + pickNumber(subAgent, 'cache_read_input_tokens', 0)
+      + pickNumber(subAgent, 'output_tokens', 0)
+    if (subAgentTokens > 0)
+      usage.subAgentTokens = subAgentTokens
+  }
   return usage
 }
 
@@ -20,6 +20,21 @@ export const NOTIFICATION_TYPE = {
   AgentSessionInfo: 'agent_session_info',
   RateLimit: 'rate_limit',
   RateLimitEvent: 'rate_limit_event',
+  RetryScheduled: 'retry_scheduled',
+  RetryExhausted: 'retry_exhausted',
+  PermissionModeBlocked: 'permission_mode_blocked',
+  DiskSpaceLow: 'disk_space_low',
+  AgentStatus: 'agent_status',
+  ContextCompaction: 'context_compaction',
+  ContextPressure: 'context_pressure',
+  TurnHeld: 'turn_held',
+  AgentAnomaly: 'agent_anomaly',
+  EmergencyStop: 'emergency_stop',
+  ToolUseDecided: 'tool_use_decided',
+  SecretsRedacted: 'secrets_redacted',
+  TurnStalled: 'turn_stalled',
+  LimitExceeded: 'limit_exceeded',
+  AgentInterrupted: 'agent_interrupted',
 } as const
 
 export type NotificationType = typeof NOTIFICATION_TYPE[keyof typeof NOTIFICATION_TYPE]
@@ -240,6 +240,22 @@ describe('agentSessionStore thinkingTokens', () => {
     })
   })
 
+  it('setFileTransfer tracks a transfer until it ends, without persisting it', () => {
+    createRoot((dispose) => {
+      const store = createAgentSessionStore()
+      const progress = { direction: 'upload' as const, path: 'in.json', bytesDone: 5, totalBytes: 10 }
+      store.setFileTransfer('a-ft', 'up-1', progress)
+      store.setFileTransfer('a-ft', 'up-1', { ...progress, bytesDone: 8 })
+
+      expect(store.getInfo('a-ft').fileTransfers?.['up-1']?.bytesDone).toBe(8)
+      expect(localStorageGet(`${PREFIX_AGENT_SESSION}a-ft`)).toBeUndefined()
+
+      store.setFileTransfer('a-ft', 'up-1', undefined)
+      expect(store.getInfo('a-ft').fileTransfers?.['up-1']).toBeUndefined()
+      dispose()
+    })
+  })
+
   it('writes nothing to localStorage for an estimate-only update', () => {
     createRoot((dispose) => {
       const store = createAgentSessionStore()
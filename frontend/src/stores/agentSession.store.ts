@@ -10,6 +10,8 @@ export interface ContextUsageInfo {
   /** Authoritative provider-reported current context size, when available. */
   contextTokens?: number
   contextWindow?: number
+  /** Cumulative tokens spent by sub-agents, reported apart from the context above. */
+  subAgentTokens?: number
 }
 
 export interface RateLimitInfo {
@@ -23,6 +25,15 @@ export interface RateLimitInfo {
   isUsingOverage?: boolean
 }
 
+/** A browser transfer in flight to or from the agent's working directory. */
+export interface FileTransferInfo {
+  direction: 'upload' | 'download'
+  /** Path relative to the agent's working directory. */
+  path: string
+  bytesDone: number
+  totalBytes: number
+}
+
 export interface AgentSessionInfo {
   totalCostUsd?: number
   contextUsage?: ContextUsageInfo
@@ -36,6 +47,16 @@ export interface AgentSessionInfo {
    * at each turn boundary so a stale per-turn count never lingers.
    */
   thinkingTokens?: number
+  /**
+   * The user-set environment the agent was started with: org defaults, then
+   * the workspace's variables, then the agent's own, merged by name.
+   */
+  env?: Record<string, string>
+  /**
+   * In-flight file transfers keyed by transfer ID, fed by the worker's
+   * progress reports. Ephemeral: a transfer ends with its connection.
+   */
+  fileTransfers?: Record<string, FileTransferInfo>
 }
 
 /**
@@ -66,8 +87,9 @@ export function compactionContextUsage(
 // delta AND rehydrate a stale count on reload (the indicator would show the
 // pre-reload total until a fresh broadcast or turn-end clear corrects it).
 // Stripped from every write, so the store mutates reactively but the value
-// never reaches disk.
-const EPHEMERAL_KEYS = ['thinkingTokens'] as const satisfies readonly (keyof AgentSessionInfo)[]
+// never reaches disk. fileTransfers is live progress that means nothing after
+// a reload.
+const EPHEMERAL_KEYS = ['thinkingTokens', 'fileTransfers'] as const satisfies readonly (keyof AgentSessionInfo)[]
 
 function loadFromStorage(agentId: string): AgentSessionInfo {
   return localStorageGet<AgentSessionInfo>(`${PREFIX_AGENT_SESSION}${agentId}`) ?? {}
@@ -193,5 +215,25 @@ export function createAgentSessionStore() {
         return
       setState('infoByAgent', agentId, 'thinkingTokens', undefined)
     },
+
+    /**
+     * Record a transfer's progress, or drop it once it has ended (`info`
+     * undefined). Never persisted: fileTransfers is an EPHEMERAL_KEY.
+     */
+    setFileTransfer(agentId: string, transferId: string, info: FileTransferInfo | undefined) {
+      const current = state.infoByAgent[agentId]?.fileTransfers
+      if (info === undefined) {
+        if (current?.[transferId] === undefined)
+          return
+        setState('infoByAgent', agentId, 'fileTransfers', transferId, undefined!)
+        return
+      }
+      if (shallowEqual(current?.[transferId], info))
+        return
+      if (current === undefined)
+        setState('infoByAgent', agentId, prev => ({ ...prev, fileTransfers: { [transferId]: info } }))
+      else
+        setState('infoByAgent', agentId, 'fileTransfers', transferId, info)
+    },
   }
 }
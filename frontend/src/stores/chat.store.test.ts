@@ -1541,6 +1541,24 @@ describe('createChatStore', () => {
     })
   })
 
+  it('updates and forgets the persisted copy of a local message', () => {
+    createRoot((dispose) => {
+      const store = createChatStore()
+      store.persistLocalMessage('agent-spool', 'local-1', 'hi', '')
+      store.setPersistedLocalMessageError('agent-spool', 'local-1', 'Queued message expired')
+
+      const reloaded = createChatStore()
+      reloaded.loadLocalMessages('agent-spool')
+      expect(reloaded.getMessages('agent-spool')[0].deliveryError).toBe('Queued message expired')
+
+      store.forgetPersistedLocalMessage('agent-spool', 'local-1')
+      const empty = createChatStore()
+      empty.loadLocalMessages('agent-spool')
+      expect(empty.getMessages('agent-spool')).toHaveLength(0)
+      dispose()
+    })
+  })
+
   it('loadLocalMessages skips a local already in the window (no duplicate, no version churn)', () => {
     createRoot((dispose) => {
       const store = createChatStore()
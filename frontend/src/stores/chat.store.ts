@@ -15,7 +15,7 @@ import { createCommandStreamStore } from './chatCommandStreams'
 import { createContentVersionStore } from './chatContentVersions'
 import { createHistoryPaginator, linkWatchSignal, MESSAGE_PAGE_SIZE } from './chatHistoryPaginator'
 import { createLiveTailTracker } from './chatLiveTail'
-import { getPersistedLocalMessages, hydrateLocalMessage, persistLocalMessage, removePersistedLocalMessage } from './chatLocalMessages'
+import { getPersistedLocalMessages, hydrateLocalMessage, persistLocalMessage, removePersistedLocalMessage, setPersistedLocalMessageError } from './chatLocalMessages'
 import { createMessageAnnotationStore } from './chatMessageAnnotations'
 import { createMessageMarksStore, resolveRailRange } from './chatMessageMarks'
 import { createMessageMarkSeeder } from './chatMessageMarkSeeder'
@@ -1172,6 +1172,19 @@ export function createChatStore() {
       })
     },
 
+    /** Update the delivery error of a persisted local message. */
+    setPersistedLocalMessageError(agentId: string, messageId: string, deliveryError: string) {
+      setPersistedLocalMessageError(agentId, messageId, deliveryError)
+    },
+
+    /**
+     * Drop a local message's localStorage copy, keeping the in-memory bubble
+     * for its server echo to reconcile.
+     */
+    forgetPersistedLocalMessage(agentId: string, messageId: string) {
+      removePersistedLocalMessage(agentId, messageId)
+    },
+
     /** Load persisted local messages from localStorage and add them to the store. */
     loadLocalMessages(agentId: string) {
       const list = getPersistedLocalMessages(agentId)
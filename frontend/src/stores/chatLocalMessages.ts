@@ -42,6 +42,15 @@ export function removePersistedLocalMessage(agentId: string, messageId: string)
   }
 }
 
+export function setPersistedLocalMessageError(agentId: string, messageId: string, deliveryError: string) {
+  const list = getPersistedLocalMessages(agentId)
+  const i = list.findIndex(m => m.id === messageId)
+  if (i < 0)
+    return
+  list[i] = { ...list[i], deliveryError }
+  localStorageSet(`${PREFIX_LOCAL_MESSAGES}${agentId}`, list)
+}
+
 /** Reconstruct an AgentChatMessage from a persisted local message. */
 export function hydrateLocalMessage(p: PersistedLocalMessage): AgentChatMessage {
   const contentJson = JSON.stringify({
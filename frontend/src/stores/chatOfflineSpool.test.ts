@@ -0,0 +1,91 @@
+import type { SpooledMessage } from '~/stores/chatOfflineSpool'
+import { beforeEach, describe, expect, it, vi } from 'vitest'
+import { flushOfflineSpool, getSpooledMessages, removeSpooledMessage, spoolMessage } from '~/stores/chatOfflineSpool'
+
+function msg(localId: string, expiresAt = 1_000): SpooledMessage {
+  return { localId, agentId: 'a1', content: localId, attachments: [], queuedAt: 0, expiresAt }
+}
+
+const offline = new Error('offline')
+
+function deps(send: (m: SpooledMessage) => Promise<unknown>) {
+  return {
+    send,
+    isOffline: (err: unknown) => err === offline,
+    onDelivered: vi.fn(),
+    onExpired: vi.fn(),
+    onFailed: vi.fn(),
+    now: () => 500,
+  }
+}
+
+describe('chatOfflineSpool', () => {
+  beforeEach(() => {
+    localStorage.clear()
+  })
+
+  it('spools per worker in order and removes by local id', () => {
+    spoolMessage('w1', msg('l1'))
+    spoolMessage('w1', msg('l2'))
+    spoolMessage('w2', msg('l3'))
+    expect(getSpooledMessages('w1').map(m => m.localId)).toEqual(['l1', 'l2'])
+
+    removeSpooledMessage('w1', 'l1')
+    expect(getSpooledMessages('w1').map(m => m.localId)).toEqual(['l2'])
+    expect(getSpooledMessages('w2').map(m => m.localId)).toEqual(['l3'])
+  })
+
+  it('delivers in order and empties the spool', async () => {
+    spoolMessage('w1', msg('l1'))
+    spoolMessage('w1', msg('l2'))
+    const sent: string[] = []
+    const d = deps(async (m) => { sent.push(m.localId) })
+
+    await flushOfflineSpool('w1', d)
+
+    expect(sent).toEqual(['l1', 'l2'])
+    expect(d.onDelivered).toHaveBeenCalledTimes(2)
+    expect(getSpooledMessages('w1')).toEqual([])
+  })
+
+  it('drops expired messages without sending them', async () => {
+    spoolMessage('w1', msg('old', 100))
+    spoolMessage('w1', msg('l1'))
+    const send = vi.fn(async () => {})
+    const d = deps(send)
+
+    await flushOfflineSpool('w1', d)
+
+    expect(send).toHaveBeenCalledTimes(1)
+    expect(d.onExpired).toHaveBeenCalledWith(expect.objectContaining({ localId: 'old' }))
+    expect(getSpooledMessages('w1')).toEqual([])
+  })
+
+  it('stops and keeps the rest spooled while the worker is still offline', async () => {
+    spoolMessage('w1', msg('l1'))
+    spoolMessage('w1', msg('l2'))
+    const d = deps(async () => { throw offline })
+
+    await flushOfflineSpool('w1', d)
+
+    expect(d.onDelivered).not.toHaveBeenCalled()
+    expect(d.onFailed).not.toHaveBeenCalled()
+    expect(getSpooledMessages('w1').map(m => m.localId)).toEqual(['l1', 'l2'])
+  })
+
+  it('drops a message the worker rejects and carries on', async () => {
+    spoolMessage('w1', msg('bad'))
+    spoolMessage('w1', msg('l1'))
+    const rejected = new Error('agent closed')
+    const d = deps(async (m) => {
+      if (m.localId === 'bad')
+        throw rejected
+    })
+
+    await flushOfflineSpool('w1', d)
+
+    expect(d.onFailed).toHaveBeenCalledWith(expect.objectContaining({ localId: 'bad' }), rejected)
+    expect(d.onDelivered).toHaveBeenCalledWith(expect.objectContaining({ localId: 'l1' }))
+    expect(getSpooledMessages('w1')).toEqual([])
+  })
+})
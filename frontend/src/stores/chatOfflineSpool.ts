@@ -0,0 +1,105 @@
+import { localStorageGet, localStorageRemove, localStorageSet, PREFIX_OFFLINE_SPOOL } from '~/lib/browserStorage'
+
+// ---------------------------------------------------------------------------
+// Offline send spool (opt-in)
+//
+// Messages sent while their worker is offline are parked here instead of
+// failing, and replayed when the worker reconnects. The worker's channel is
+// end-to-end encrypted, so the hub cannot hold them for us: the spool lives in
+// localStorage, per worker, so it also survives a page refresh. Each replay
+// carries the optimistic bubble's local id as its idempotency key, so a send
+// that actually landed before the connection dropped is not delivered twice.
+// ---------------------------------------------------------------------------
+
+/** How long a spooled message waits for its worker before it expires. */
+export const OFFLINE_SPOOL_TTL_MS = 24 * 60 * 60 * 1000
+
+/** Pending label shown on a spooled message's bubble. */
+export const OFFLINE_SPOOL_PENDING_LABEL = 'Queued — worker is offline'
+
+export interface SpooledMessage {
+  /** The optimistic bubble's id; doubles as the idempotency key. */
+  localId: string
+  agentId: string
+  content: string
+  /** Attachments with base64 data, as in the persisted local bubble. */
+  attachments: Array<{ filename: string, mime_type: string, data: string }>
+  queuedAt: number
+  expiresAt: number
+}
+
+function spoolKey(workerId: string): string {
+  return `${PREFIX_OFFLINE_SPOOL}${workerId}`
+}
+
+export function getSpooledMessages(workerId: string): SpooledMessage[] {
+  return localStorageGet<SpooledMessage[]>(spoolKey(workerId)) ?? []
+}
+
+function setSpooledMessages(workerId: string, list: SpooledMessage[]) {
+  if (list.length === 0)
+    localStorageRemove(spoolKey(workerId))
+  else
+    localStorageSet(spoolKey(workerId), list)
+}
+
+export function spoolMessage(workerId: string, msg: SpooledMessage) {
+  setSpooledMessages(workerId, [...getSpooledMessages(workerId), msg])
+}
+
+export function removeSpooledMessage(workerId: string, localId: string) {
+  const list = getSpooledMessages(workerId)
+  const filtered = list.filter(m => m.localId !== localId)
+  if (filtered.length !== list.length)
+    setSpooledMessages(workerId, filtered)
+}
+
+export interface OfflineSpoolFlushDeps {
+  /** Deliver one message; rejects on failure. */
+  send: (msg: SpooledMessage) => Promise<unknown>
+  /** Whether a send failure means the worker is still offline. */
+  isOffline: (err: unknown) => boolean
+  onDelivered: (msg: SpooledMessage) => void
+  onExpired: (msg: SpooledMessage) => void
+  onFailed: (msg: SpooledMessage, err: unknown) => void
+  now?: () => number
+}
+
+const flushing = new Set<string>()
+
+/**
+ * Replay workerId's spool in order. Expired messages are dropped and reported;
+ * a send failing because the worker is still offline stops the flush and keeps
+ * the rest spooled for the next reconnect; any other failure drops that message
+ * as undeliverable. Concurrent flushes of the same worker collapse into one.
+ */
+export async function flushOfflineSpool(workerId: string, deps: OfflineSpoolFlushDeps): Promise<void> {
+  if (flushing.has(workerId))
+    return
+  flushing.add(workerId)
+  try {
+    const now = deps.now ?? Date.now
+    for (const msg of getSpooledMessages(workerId)) {
+      if (now() >= msg.expiresAt) {
+        removeSpooledMessage(workerId, msg.localId)
+        deps.onExpired(msg)
+        continue
+      }
+      try {
+        await deps.send(msg)
+      }
+      catch (err) {
+        if (deps.isOffline(err))
+          return
+        removeSpooledMessage(workerId, msg.localId)
+        deps.onFailed(msg, err)
+        continue
+      }
+      removeSpooledMessage(workerId, msg.localId)
+      deps.onDelivered(msg)
+    }
+  }
+  finally {
+    flushing.delete(workerId)
+  }
+}
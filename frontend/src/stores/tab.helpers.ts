@@ -62,6 +62,7 @@ export function protoToTerminalTabFields(workerId: string, term: ProtoTerminal):
     status,
     startupError: term.startupError || undefined,
     startupMessage: term.startupMessage || undefined,
+    restorable: term.restorable || undefined,
     // Any persisted screen means the shell already painted content; an
     // exited DB-only terminal has no future data source, so it must not
     // remain covered by the startup overlay either.
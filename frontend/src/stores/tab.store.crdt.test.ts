@@ -225,6 +225,49 @@ describe('tab.store CRDT op emissions', () => {
         expect(op.body.value.field.case).toBe('position')
     })
   })
+
+  it('setTabGroup emits label + color and joining adopts the group\'s color and collapsed state', () => {
+    withTestBridge((harness) => {
+      const store = createTabStore()
+      store.addTab({ type: TabType.AGENT, id: 'a1', tileId: harness.rootTileId, position: 'A' })
+      store.addTab({ type: TabType.AGENT, id: 'a2', tileId: harness.rootTileId, position: 'B' })
+      const before = harness.pending.state.pendingBatches.length
+      store.setTabGroup(TabType.AGENT, 'a1', '  Backend  ', '#3366ff')
+      expect(harness.pending.state.pendingBatches.length).toBe(before + 1)
+      const fields = (harness.pending.state.pendingBatches.at(-1)?.ops ?? []).map((o) => {
+        if (o.body.case === 'setTabRegister')
+          return o.body.value.field.case
+        return ''
+      })
+      expect(fields).toEqual(['groupLabel', 'groupColor'])
+
+      store.updateTabGroup(harness.rootTileId, 'Backend', { groupCollapsed: true })
+      store.setTabGroup(TabType.AGENT, 'a2', 'Backend', '#000000')
+      const a2 = store.state.tabs.find(t => t.id === 'a2')
+      expect(a2?.groupLabel).toBe('Backend')
+      expect(a2?.groupColor).toBe('#3366ff')
+      expect(a2?.groupCollapsed).toBe(true)
+    })
+  })
+
+  it('updateTabGroup writes every member of the group and skips unchanged tabs', () => {
+    withTestBridge((harness) => {
+      const store = createTabStore()
+      store.addTab({ type: TabType.AGENT, id: 'a1', tileId: harness.rootTileId, position: 'A' })
+      store.addTab({ type: TabType.TERMINAL, id: 't1', tileId: harness.rootTileId, position: 'B' })
+      store.addTab({ type: TabType.AGENT, id: 'a2', tileId: harness.rootTileId, position: 'C' })
+      store.setTabGroup(TabType.AGENT, 'a1', 'Infra')
+      store.setTabGroup(TabType.TERMINAL, 't1', 'Infra')
+      const before = harness.pending.state.pendingBatches.length
+      store.updateTabGroup(harness.rootTileId, 'Infra', { groupCollapsed: true })
+      expect(harness.pending.state.pendingBatches.length).toBe(before + 1)
+      expect(harness.pending.state.pendingBatches.at(-1)?.ops.length).toBe(2)
+      expect(store.state.tabs.filter(t => t.groupCollapsed).map(t => t.id)).toEqual(['a1', 't1'])
+
+      store.updateTabGroup(harness.rootTileId, 'Infra', { groupCollapsed: true })
+      expect(harness.pending.state.pendingBatches.length).toBe(before + 1)
+    })
+  })
 })
 
 describe('tab.store reconcileFromProjection', () => {
@@ -361,4 +404,29 @@ describe('tab.store reconcileFromProjection', () => {
       expect(store.state.tabs.find(t => t.id === 'remote-term-1')).toBeTruthy()
     })
   })
+
+  it('syncs tab group registers from the projection', () => {
+    withTestBridge((harness) => {
+      const store = createTabStore()
+      store.addTab({ type: TabType.AGENT, id: 'a1', tileId: harness.rootTileId, position: 'M' }, { silent: true })
+      store.reconcileFromProjection({
+        workspaceId: harness.workspaceId,
+        renderedTabs: [{
+          tabType: TabType.AGENT,
+          tabId: 'a1',
+          tileId: harness.rootTileId,
+          position: 'M',
+          workerId: '',
+          groupLabel: 'Backend',
+          groupColor: '#3366ff',
+          groupCollapsed: true,
+        }],
+        crdtKnownTabIds: new Set(['a1']),
+      })
+      const tab = store.state.tabs[0]
+      expect(tab.groupLabel).toBe('Backend')
+      expect(tab.groupColor).toBe('#3366ff')
+      expect(tab.groupCollapsed).toBe(true)
+    })
+  })
 })
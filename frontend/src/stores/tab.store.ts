@@ -1,4 +1,4 @@
-import type { AddTabOptions, AgentTab, FileDiffBase, FileTab, FileViewMode, RemoveTabOptions, RestorableTabState, Tab, TabStoreState, TerminalTab } from './tab.types'
+import type { AddTabOptions, AgentTab, FileDiffBase, FileTab, FileViewMode, RemoveTabOptions, RestorableTabState, Tab, TabGroupFields, TabStoreState, TerminalTab } from './tab.types'
 import type { OrgOp } from '~/generated/leapmux/v1/org_ops_pb'
 import type { OpBuilderCtx } from '~/lib/crdt'
 import { createMemo } from 'solid-js'
@@ -11,15 +11,33 @@ import {
   newBatch,
   setTabPosition as opSetTabPosition,
   tombstoneTab as opTombstoneTab,
+  setTabGroupCollapsed,
+  setTabGroupColor,
+  setTabGroupLabel,
   setTabTileId,
   setTabWorkerId,
 } from '~/lib/crdt'
 import { after, first, positionAtInsertIdx } from '~/lib/lexorank'
 import { createLogger } from '~/lib/logger'
 import { isSameRepo, parseTabKey, tabKey } from './tab.helpers'
+import { MAX_TAB_GROUP_LABEL_LENGTH } from './tab.types'
 
 const log = createLogger('tab-store')
 
+const defaultGroupFields: Required<TabGroupFields> = { groupLabel: '', groupColor: '', groupCollapsed: false }
+
+// Group registers as projected from the CRDT. Optional so reconcile
+// callers that only project placement can omit them.
+interface TabGroupProjection {
+  groupLabel?: string
+  groupColor?: string
+  groupCollapsed?: boolean
+}
+
+function normalizeGroupLabel(label: string): string {
+  return Array.from(label.trim()).slice(0, MAX_TAB_GROUP_LABEL_LENGTH).join('')
+}
+
 // CRDT op emission. `emitOps` is the single point of contact for the
 // store's mutators: it resolves the bridge context, runs the caller's
 // op-builder with that context, and enqueues the resulting batch. Each
@@ -190,6 +208,27 @@ export function createTabStore() {
     )
   }
 
+  // Write group fields onto `tabs` locally and emit one register op per
+  // changed field per tab. Only fields present in `fields` are touched.
+  function applyGroupFields(tabs: Tab[], fields: TabGroupFields): void {
+    const keys = Object.keys(fields) as Array<keyof TabGroupFields>
+    const changed = tabs.filter(t => keys.some(k => (t[k] ?? defaultGroupFields[k]) !== fields[k]))
+    if (changed.length === 0)
+      return
+    const changedKeys = new Set(changed.map(t => tabKey(t)))
+    setState('tabs', t => changedKeys.has(tabKey(t)), prev => ({ ...prev, ...fields } as Tab))
+    emitOps(ctx => changed.flatMap((t) => {
+      const ops: OrgOp[] = []
+      if (fields.groupLabel !== undefined && (t.groupLabel ?? '') !== fields.groupLabel)
+        ops.push(setTabGroupLabel(ctx, t.type, t.id, fields.groupLabel))
+      if (fields.groupColor !== undefined && (t.groupColor ?? '') !== fields.groupColor)
+        ops.push(setTabGroupColor(ctx, t.type, t.id, fields.groupColor))
+      if (fields.groupCollapsed !== undefined && !!t.groupCollapsed !== fields.groupCollapsed)
+        ops.push(setTabGroupCollapsed(ctx, t.type, t.id, fields.groupCollapsed))
+      return ops
+    }))
+  }
+
   return {
     state,
 
@@ -465,6 +504,45 @@ export function createTabStore() {
         emitOps(ctx => [opSetTabPosition(ctx, parsed.type, parsed.id, position)])
     },
 
+    /**
+     * Move a tab into the group labeled `label` in its tile, or out of
+     * any group when `label` is empty. Joining an existing group adopts
+     * that group's color and collapsed state; `color` only seeds a new
+     * group. Labels are trimmed and capped at MAX_TAB_GROUP_LABEL_LENGTH.
+     */
+    setTabGroup(type: TabType, id: string, label: string, color = '') {
+      const key = tabKey({ type, id })
+      const tab = tabsByKey().get(key)
+      if (!tab)
+        return
+      const groupLabel = normalizeGroupLabel(label)
+      const peer = groupLabel && tab.tileId
+        ? tabsByTile().get(tab.tileId)?.find(t => tabKey(t) !== key && t.groupLabel === groupLabel)
+        : undefined
+      const fields: Required<TabGroupFields> = {
+        groupLabel,
+        groupColor: groupLabel ? (peer ? peer.groupColor ?? '' : color) : '',
+        groupCollapsed: groupLabel ? !!peer?.groupCollapsed : false,
+      }
+      applyGroupFields([tab], fields)
+    },
+
+    /**
+     * Update the label, color, or collapsed state of every tab in the
+     * group labeled `label` within `tileId`. Renaming to '' ungroups.
+     */
+    updateTabGroup(tileId: string, label: string, fields: TabGroupFields) {
+      if (!label)
+        return
+      const members = (tabsByTile().get(tileId) ?? []).filter(t => t.groupLabel === label)
+      if (members.length === 0)
+        return
+      const next = { ...fields }
+      if (next.groupLabel !== undefined)
+        next.groupLabel = normalizeGroupLabel(next.groupLabel)
+      applyGroupFields(members, next)
+    },
+
     /** Set the display mode (render/source/split) for a file tab. */
     setTabDisplayMode(type: TabType, id: string, displayMode: string) {
       const key = tabKey({ type, id })
@@ -918,7 +996,7 @@ export function createTabStore() {
      */
     reconcileFromProjection(opts: {
       workspaceId: string
-      renderedTabs: { tabType: TabType, tabId: string, tileId: string, position: string, workerId: string }[]
+      renderedTabs: Array<{ tabType: TabType, tabId: string, tileId: string, position: string, workerId: string } & TabGroupProjection>
       crdtKnownTabIds: Set<string>
       // Tabs whose CRDT TabRecord exists, is NOT tombstoned, but whose
       // tile chain currently dead-ends at an unknown node id. These
@@ -975,6 +1053,9 @@ export function createTabStore() {
           tileId: r.tileId,
           position: r.position,
           workerId: r.workerId || undefined,
+          groupLabel: r.groupLabel || undefined,
+          groupColor: r.groupColor || undefined,
+          groupCollapsed: r.groupCollapsed || undefined,
         }
         if (r.tabType === TabType.AGENT)
           this.addTab({ type: TabType.AGENT, ...base }, { activate: false, silent: true })
@@ -985,11 +1066,11 @@ export function createTabStore() {
       }
 
       // 3) For tabs that exist on both sides, sync CRDT-driven fields
-      //    (tile_id, position, worker_id) when they differ. Use a
+      //    (tile_id, position, worker_id, group) when they differ. Use a
       //    single `produce` so the reactive subscribers fire once.
       //    Per-tile MRU/active state follows tile_id automatically via
       //    the derived memo — no per-tile bookkeeping needed.
-      const updates: Array<{ key: string, tileId?: string, position?: string, workerId?: string }> = []
+      const updates: Array<{ key: string, tileId?: string, position?: string, workerId?: string, group?: TabGroupFields }> = []
       for (const r of opts.renderedTabs) {
         const key = tabKey({ type: r.tabType, id: r.tabId })
         const local = localByKey.get(key)
@@ -1002,7 +1083,15 @@ export function createTabStore() {
           u.position = r.position
         if (r.workerId && (local.workerId ?? '') !== r.workerId)
           u.workerId = r.workerId
-        if (u.tileId !== undefined || u.position !== undefined || u.workerId !== undefined)
+        // Rows without group registers (callers that only project
+        // placement) leave the local group fields alone.
+        if (r.groupLabel !== undefined
+          && ((local.groupLabel ?? '') !== r.groupLabel
+            || (local.groupColor ?? '') !== (r.groupColor ?? '')
+            || !!local.groupCollapsed !== !!r.groupCollapsed)) {
+          u.group = { groupLabel: r.groupLabel, groupColor: r.groupColor ?? '', groupCollapsed: !!r.groupCollapsed }
+        }
+        if (u.tileId !== undefined || u.position !== undefined || u.workerId !== undefined || u.group !== undefined)
           updates.push(u)
       }
       if (updates.length > 0) {
@@ -1024,6 +1113,8 @@ export function createTabStore() {
               tabs[idx].position = u.position
             if (u.workerId !== undefined)
               tabs[idx].workerId = u.workerId
+            if (u.group !== undefined)
+              Object.assign(tabs[idx], u.group)
           }
         }))
 
@@ -1,4 +1,4 @@
-import type { AgentGitStatus, AgentProvider, AgentStatus, AvailableOptionGroup } from '~/generated/leapmux/v1/agent_pb'
+import type { AgentCIStatus, AgentGitStatus, AgentProvider, AgentStatus, AvailableOptionGroup, TestRun } from '~/generated/leapmux/v1/agent_pb'
 import type { TerminalStatus } from '~/generated/leapmux/v1/terminal_pb'
 import { TabType } from '~/generated/leapmux/v1/workspace_pb'
 
@@ -13,6 +13,13 @@ export type FileViewMode = 'working' | 'head' | 'staged' | 'unified-diff' | 'spl
 export type FileDiffBase = 'head-vs-working' | 'head-vs-staged'
 export type FileOpenSource = 'all' | 'changed' | 'staged' | 'unstaged'
 
+/**
+ * Maximum tab group label length, in code points. Mirrors the hub's
+ * crdt.MaxTabGroupLabelLength; longer labels are rejected as
+ * VALUE_DOMAIN.
+ */
+export const MAX_TAB_GROUP_LABEL_LENGTH = 64
+
 /**
  * Fields every tab carries regardless of kind. AGENT/TERMINAL/FILE
  * variants extend BaseTab with their own kind-specific fields and
@@ -26,6 +33,16 @@ export interface BaseTab {
   position?: string
   tileId?: string
   workerId?: string
+  /**
+   * Tab group this tab belongs to within its tile; unset or '' when
+   * ungrouped. Group label, color, and collapsed state are CRDT
+   * registers on the tab, so every tab in a group carries the same
+   * values and they follow the user across devices.
+   */
+  groupLabel?: string
+  /** Group color as "#rrggbb"; unset or '' for the default color. */
+  groupColor?: string
+  groupCollapsed?: boolean
   /**
    * Local-only monotonic activation counter. Higher = more recently
    * activated. Set when the tab is added with `activate: true` or
@@ -87,6 +104,18 @@ export interface AgentTab extends BaseTab {
    * conflicted/modified/etc.
    */
   agentGitStatus?: AgentGitStatus
+  /**
+   * Latest test run the worker read out of the agent's shell output, pushed
+   * as a partial statusChange. Live only: not hydrated, so a reload shows no
+   * badge until the next run (ListAgentTestRuns has the history).
+   */
+  agentTestRun?: TestRun
+  /**
+   * CI status of the agent's branch head, pushed as a partial statusChange
+   * when a worker poll finds it changed. Live only, like agentTestRun
+   * (GetAgentCIStatus has the last polled value).
+   */
+  agentCiStatus?: AgentCIStatus
   /**
    * Error string carried while AgentStatus.STARTUP_FAILED so the chat
    * startup banner can render the agent's failure reason.
@@ -128,6 +157,11 @@ export interface TerminalTab extends BaseTab {
    * reconnect when a screen snapshot is restored.
    */
   contentReady?: boolean
+  /**
+   * Set when the worker went away under a live shell. The tab restarts
+   * on its own once, rather than waiting for Enter.
+   */
+  restorable?: boolean
 }
 
 /**
@@ -143,6 +177,9 @@ export interface FileTab extends BaseTab {
   fileOpenSource?: FileOpenSource
 }
 
+/** The CRDT-backed tab group fields. */
+export type TabGroupFields = Pick<BaseTab, 'groupLabel' | 'groupColor' | 'groupCollapsed'>
+
 /**
  * Discriminated union of every tab kind. Narrow with `switch (tab.type)`
  * or the per-kind guards below.